@@ -2,24 +2,43 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
+	"time"
 
+	"bot-go/internal/access"
+	"bot-go/internal/apisurface"
+	"bot-go/internal/bench"
+	"bot-go/internal/cluster"
 	"bot-go/internal/codeapi"
+	"bot-go/internal/coldstorage"
 	"bot-go/internal/config"
 	"bot-go/internal/controller"
 	"bot-go/internal/db"
+	"bot-go/internal/fsck"
 	"bot-go/internal/handler"
 	init_services "bot-go/internal/init"
+	"bot-go/internal/logging"
+	"bot-go/internal/parse"
+	"bot-go/internal/script"
+	"bot-go/internal/service/codegraph"
+	"bot-go/internal/service/session"
+	"bot-go/internal/service/vector"
+	"bot-go/internal/storage"
+	"bot-go/internal/tracing"
 	"bot-go/internal/util"
 	"bot-go/pkg/lsp"
 	"bot-go/pkg/mcp"
 
 	"go.uber.org/zap"
-	"go.uber.org/zap/zapcore"
 )
 
 // stringSliceFlag is a custom flag type that allows multiple values
@@ -34,42 +53,111 @@ func (s *stringSliceFlag) Set(value string) error {
 	return nil
 }
 
+// splitProcessorNames parses the comma-separated --processors flag
+func splitProcessorNames(value string) []string {
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// dumpOptionsForPath picks the --test-dump output format from the file name:
+// ".jsonl" selects JSONL, and a ".gz" suffix compresses the output. Only text dumps can be
+// reproducible; JSONL records keep the FileIDs and node IDs of the graph.
+func dumpOptionsForPath(path string, reproducible bool) codegraph.DumpOptions {
+	opts := codegraph.DumpOptions{Format: codegraph.DumpFormatText, Reproducible: reproducible}
+	if strings.HasSuffix(strings.TrimSuffix(path, ".gz"), ".jsonl") {
+		opts.Format = codegraph.DumpFormatJSONL
+	}
+	return opts
+}
+
 func main() {
 	var sourceConfigPath = flag.String("source", "source.yaml", "Path to source configuration file")
 	var appConfigPath = flag.String("app", "app.yaml", "Path to app configuration file")
+	var environment = flag.String("env", os.Getenv("BOT_GO_ENV"), "Environment whose overlay (app.<env>.yaml next to --app) is layered over the app configuration (default $BOT_GO_ENV)")
+	var appOverlays stringSliceFlag
+	flag.Var(&appOverlays, "app-overlay", "App configuration overlay applied after --env's (can be specified multiple times)")
 	var workDir = flag.String("workdir", "", "Working directory to store files")
 	//var port = flag.String("port", "8080", "Server port")
 	var test = flag.Bool("test", false, "Run in test mode")
 	var buildIndex stringSliceFlag
 	flag.Var(&buildIndex, "build-index", "Repository name to build index for (can be specified multiple times)")
+	var archive = flag.String("archive", "", "Index this source archive (.zip, .tar, .tar.gz, .tgz) instead of the repository's checkout (only valid with a single --build-index)")
+	var archiveVersion = flag.String("archive-version", "", "Version the files of --archive are recorded under, e.g. a release tag (default: ephemeral)")
 	var useHead = flag.Bool("head", false, "Use git HEAD version instead of working directory (only valid with --build-index)")
 	var testDump = flag.String("test-dump", "", "Path to output file for dumping code graph after index building (only valid with --build-index)")
+	var dumpToStorage = flag.Bool("dump-to-storage", false, "Write --test-dump to the storage backend of app.yaml, taking its value as the object key")
+	var deterministic = flag.Bool("deterministic", false, "Write --test-dump with IDs derived from repository and path, so builds of the same tree produce identical dumps (only valid with --build-index)")
 	var clean = flag.Bool("clean", false, "Clean up all DB entries (MySQL, Neo4j, Qdrant) for the repository after processing (only valid with --build-index)")
+	var processors = flag.String("processors", "", "Comma-separated processors to run, e.g. CodeGraph,Embedding,NGram (only valid with --build-index, --replay-journal or --bench; default all)")
+	var replayJournal = flag.String("replay-journal", "", "Repository whose index journal to replay into the graph and vector stores, e.g. after restoring them from a backup")
+	var replaySince = flag.String("replay-since", "", "Replay only journal entries recorded at or after this RFC 3339 time, e.g. when the backup was taken (only valid with --replay-journal)")
+	var fsckRepos stringSliceFlag
+	flag.Var(&fsckRepos, "fsck", "Repository to check for inconsistencies between MySQL, Neo4j and the vector store (can be specified multiple times)")
+	var repair = flag.Bool("repair", false, "Repair the inconsistencies found (only valid with --fsck)")
+	var apiDiff = flag.String("api-diff", "", "Library repository whose API snapshots to compare, reporting breaking changes")
+	var apiDiffFrom = flag.String("api-diff-from", "", "Version to compare from (only valid with --api-diff; default the version indexed before --api-diff-to)")
+	var apiDiffTo = flag.String("api-diff-to", "", "Version to compare to (only valid with --api-diff; default the latest)")
+	var apiDiffFormat = flag.String("api-diff-format", apisurface.FormatText, "Report format: text, json or github (GitHub Actions annotations) (only valid with --api-diff)")
+	var apiDiffOutput = flag.String("api-diff-output", "", "Write the --api-diff report to this file instead of stdout, where logs may also go")
+	var reduceEmbeddings = flag.String("reduce-embeddings", "", "Vector collection (a repository name, or the name with _cards or _graph suffixes) to rebuild with the fewer dimensions per vector of qdrant.reduction")
+	var scriptFile = flag.String("script", "", "Run this Starlark analysis script against the code graph and print its result as JSON")
+	var scriptArgs stringSliceFlag
+	flag.Var(&scriptArgs, "script-arg", "key=value passed to --script in its args dict; values are parsed as JSON, else taken as strings (can be specified multiple times)")
+	var importTraces = flag.String("import-traces", "", "Import the calls observed in this OTLP/JSON trace export as RUNTIME_CALLS relations of the code graph")
+	var importTracesRepo = flag.String("import-traces-repo", "", "Repository whose functions the traced spans are mapped to (required with --import-traces)")
+	var importTracesSource = flag.String("import-traces-source", tracing.DefaultSource, "Provenance source of the imported relations (only valid with --import-traces)")
+	var importTracesReplace = flag.Bool("import-traces-replace", false, "Delete the relations previously imported from the same source first (only valid with --import-traces)")
+	var benchFiles = flag.Int("bench", 0, "Benchmark indexing throughput on a generated repository with this many files")
+	var benchFunctions = flag.Int("bench-functions", 20, "Functions per generated file (only valid with --bench)")
+	var benchBaseline = flag.String("bench-baseline", "", "Baseline JSON to compare the benchmark against (only valid with --bench)")
+	var benchUpdate = flag.Bool("bench-update-baseline", false, "Write this run's results to --bench-baseline (only valid with --bench)")
+	var benchTolerance = flag.Float64("bench-tolerance", 0.1, "Allowed fractional slowdown before a metric counts as a regression (only valid with --bench)")
+	var validateConfig = flag.Bool("validate-config", false, "Validate the configuration, report every problem and exit without starting services")
+	var profileDir = flag.String("profile", "", "Write CPU and heap profiles of the run to this directory (only valid with --build-index or --bench)")
 	flag.Parse()
 
-	//logger, err := zap.NewProduction()
-	cfgZap := zap.NewProductionConfig()
-	//cfgZap.Level.SetLevel(zapcore.DebugLevel)
-	cfgZap.Level.SetLevel(zapcore.InfoLevel)
-	cfgZap.OutputPaths = []string{"stdout", "all.log"}
-	logger, err := cfgZap.Build()
+	cfg, err := config.LoadLayeredConfig(config.LoadOptions{
+		AppConfigPath:    *appConfigPath,
+		SourceConfigPath: *sourceConfigPath,
+		Environment:      *environment,
+		Overlays:         appOverlays,
+	})
 	if err != nil {
-		log.Fatal("Failed to initialize logger:", err)
+		log.Fatal("Failed to load configuration: ", err)
 	}
 
-	defer logger.Sync()
+	// Report every configuration problem before any service starts
+	validateOpts := config.ValidateOptions{HeadMode: *useHead, Repositories: buildIndex, Archive: *archive}
+	if err := cfg.Validate(validateOpts); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *validateConfig {
+		fmt.Println("Configuration is valid")
+		return
+	}
 
-	cfg, err := config.LoadConfig(*appConfigPath, *sourceConfigPath)
+	logger, err := logging.Setup(cfg.Logging)
 	if err != nil {
-		logger.Fatal("Failed to load configuration", zap.Error(err))
+		log.Fatal("Failed to initialize logger: ", err)
 	}
 
+	defer logger.Sync()
+
 	// Override workdir from command line if provided
 	if *workDir != "" {
 		cfg.App.WorkDir = *workDir
 	}
+	if *deterministic {
+		cfg.IndexBuilding.Deterministic = true
+	}
 
-	logger.Info("Configuration loaded successfully", zap.Any("config", cfg))
+	logger.Info("Configuration loaded successfully", zap.Any("config", cfg.Redacted()))
 
 	if test != nil && *test {
 		logger.Info("Running in test mode")
@@ -77,16 +165,118 @@ func main() {
 		return
 	}
 
+	if *benchFiles > 0 {
+		logger.Info("Running in CLI mode - bench")
+		stopProfiling := startProfiling(*profileDir, logger)
+		opts := bench.GenerateOptions{Files: *benchFiles, FunctionsPerFile: *benchFunctions}
+		ok := BenchCommand(cfg, logger, opts, *benchBaseline, *benchUpdate, *benchTolerance, splitProcessorNames(*processors))
+		stopProfiling()
+		if !ok {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(fsckRepos) > 0 {
+		logger.Info("Running in CLI mode - fsck")
+		if !FsckCommand(cfg, logger, fsckRepos, *repair) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *apiDiff != "" {
+		logger.Info("Running in CLI mode - api-diff")
+		if !APIDiffCommand(cfg, logger, *apiDiff, *apiDiffFrom, *apiDiffTo, *apiDiffFormat, *apiDiffOutput) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *reduceEmbeddings != "" {
+		logger.Info("Running in CLI mode - reduce-embeddings")
+		if !ReduceEmbeddingsCommand(cfg, logger, *reduceEmbeddings) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *importTraces != "" {
+		logger.Info("Running in CLI mode - import-traces")
+		if !ImportTracesCommand(cfg, logger, *importTraces, *importTracesRepo, *importTracesSource, *importTracesReplace) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *scriptFile != "" {
+		logger.Info("Running in CLI mode - script")
+		if !ScriptCommand(cfg, logger, *scriptFile, scriptArgs) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Validate --script-arg flag usage
+	if len(scriptArgs) > 0 {
+		logger.Fatal("--script-arg flag is only valid with --script")
+	}
+
+	// Validate --api-diff-* flag usage
+	if *apiDiffFrom != "" || *apiDiffTo != "" || *apiDiffOutput != "" {
+		logger.Fatal("--api-diff-from, --api-diff-to and --api-diff-output flags are only valid with --api-diff")
+	}
+
+	// Validate --repair flag usage
+	if *repair {
+		logger.Fatal("--repair flag is only valid with --fsck")
+	}
+
+	if *replayJournal != "" {
+		logger.Info("Running in CLI mode - replay-journal")
+		var since time.Time
+		if *replaySince != "" {
+			if since, err = time.Parse(time.RFC3339, *replaySince); err != nil {
+				logger.Fatal("Invalid --replay-since value, expected an RFC 3339 time", zap.Error(err))
+			}
+		}
+		if !ReplayJournalCommand(cfg, logger, *replayJournal, since, splitProcessorNames(*processors)) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Validate --replay-since flag usage
+	if *replaySince != "" {
+		logger.Fatal("--replay-since flag is only valid with --replay-journal")
+	}
+
 	// Check if we're in CLI mode (build-index specified)
 	if len(buildIndex) > 0 {
 		logger.Info("Running in CLI mode - build-index")
-		BuildIndexCommand(cfg, logger, buildIndex, *useHead, *testDump, *clean)
+		var fromArchive *archiveBuild
+		if *archive != "" {
+			var cleanup func()
+			fromArchive, cleanup = extractArchiveRepository(cfg, logger, buildIndex[0], *archive, *archiveVersion)
+			defer cleanup()
+		}
+		if *dumpToStorage && *testDump == "" {
+			logger.Fatal("--dump-to-storage requires --test-dump")
+		}
+		stopProfiling := startProfiling(*profileDir, logger)
+		BuildIndexCommand(cfg, logger, buildIndex, *useHead, *testDump, *dumpToStorage, *clean, splitProcessorNames(*processors), fromArchive)
+		stopProfiling()
 		return
 	}
 
+	// Validate --archive flag usage
+	if *archive != "" || *archiveVersion != "" {
+		logger.Fatal("--archive and --archive-version flags are only valid with --build-index")
+	}
+
 	// Validate --test-dump flag usage
-	if *testDump != "" {
-		logger.Fatal("--test-dump flag is only valid with --build-index")
+	if *testDump != "" || *dumpToStorage {
+		logger.Fatal("--test-dump and --dump-to-storage flags are only valid with --build-index")
 	}
 
 	// Validate --clean flag usage
@@ -94,11 +284,31 @@ func main() {
 		logger.Fatal("--clean flag is only valid with --build-index")
 	}
 
+	// Validate --deterministic flag usage
+	if *deterministic {
+		logger.Fatal("--deterministic flag is only valid with --build-index")
+	}
+
 	// Validate --head flag usage
 	if *useHead {
 		logger.Fatal("--head flag is only valid with --build-index")
 	}
 
+	// Validate --processors flag usage
+	if *processors != "" {
+		logger.Fatal("--processors flag is only valid with --build-index, --replay-journal or --bench")
+	}
+
+	// Validate --profile flag usage
+	if *profileDir != "" {
+		logger.Fatal("--profile flag is only valid with --build-index or --bench")
+	}
+
+	// Validate --bench-* flag usage
+	if *benchBaseline != "" || *benchUpdate {
+		logger.Fatal("--bench-baseline and --bench-update-baseline flags are only valid with --bench")
+	}
+
 	// Initialize all services using the new initialization module
 	opts := init_services.GetServerModeOptions(cfg)
 	container, err := init_services.NewServiceContainer(cfg, opts, logger)
@@ -112,6 +322,24 @@ func main() {
 		logger.Fatal("Failed to initialize processors", zap.Error(err))
 	}
 
+	// Preload models and open connections before accepting requests
+	container.Warmup(context.Background(), cfg)
+
+	// Scheduled jobs run on one instance at a time, the elected leader
+	jobs := cluster.NewScheduler(container.Locker, time.Duration(cfg.Cluster.LeaderCheckSeconds)*time.Second, logger)
+	if container.ChunkService != nil {
+		// Purge chunks whose TTL has passed
+		sweepInterval := time.Duration(cfg.Chunking.TTLSweepMinutes) * time.Minute
+		if sweepInterval <= 0 {
+			sweepInterval = time.Hour
+		}
+		jobs.Every("chunk_ttl_sweep", sweepInterval, func(ctx context.Context) error {
+			_, err := container.ChunkService.SweepExpiredChunks(ctx)
+			return err
+		})
+	}
+	jobs.Start(context.Background())
+
 	// Start CodeGraph processing in background if enabled
 	/*
 		if container.CodeGraph != nil {
@@ -119,17 +347,75 @@ func main() {
 		}
 	*/
 
-	repoController := controller.NewRepoController(container.RepoService, container.ChunkService, container.NgramService, container.Processors, container.MySQLConn, cfg, logger)
+	handlerLogger := logging.Module(logger, logging.ModuleHandler)
+	repoController := controller.NewRepoController(container.RepoService, container.ChunkService, container.NgramService, container.Processors, container.Scheduler, container.MySQLConn, cfg, handlerLogger)
 	mcpServer := mcp.NewCodeGraphServer(container.RepoService, cfg, logger)
+	var codeAPI codeapi.CodeAPI
+	if container.CodeGraph != nil {
+		codeAPI = codeapi.NewCodeAPI(container.CodeGraph, logger)
+		mcpServer.SetCodeGraph(container.CodeGraph)
+		mcpServer.SetCodeAPI(codeAPI)
+		repoController.SetCodeGraph(container.CodeGraph)
+		repoController.SetCodeAPI(codeAPI)
+	}
 
 	// Initialize CodeAPI controller if CodeGraph is available
 	var codeAPIController *controller.CodeAPIController
-	if container.CodeGraph != nil {
-		codeAPI := codeapi.NewCodeAPI(container.CodeGraph, logger)
-		codeAPIController = controller.NewCodeAPIController(codeAPI, logger)
+	if codeAPI != nil {
+		codeAPIController = controller.NewCodeAPIController(codeAPI, handlerLogger)
+		codeAPIController.SetConfig(cfg)
+	}
+
+	var graphEmbeddingController *controller.GraphEmbeddingController
+	if container.GraphEmbeddingService != nil {
+		graphEmbeddingController = controller.NewGraphEmbeddingController(container.GraphEmbeddingService, handlerLogger)
 	}
 
-	router := handler.SetupRouter(repoController, mcpServer, codeAPIController, logger)
+	sessionStore := session.NewStore(time.Duration(cfg.App.SessionTTLMinutes)*time.Minute, 0)
+	apiPolicy := access.NewPolicy(cfg.API, cfg.Source.Repositories, cfg.App.WorkDir)
+	if container.Cache != nil {
+		// Instances behind the same load balancer share sessions, tool results and quotas
+		sessionStore.SetCache(container.Cache, handlerLogger)
+		mcpServer.SetCache(container.Cache)
+		apiPolicy.Meter().SetCache(container.Cache, handlerLogger)
+	}
+	repoController.SetSessionStore(sessionStore)
+	repoController.SetUnavailableServices(container.Unavailable)
+	repoController.SetQueryLog(container.QueryLog)
+	repoController.SetLocker(container.Locker)
+	repoController.SetStorage(container.Storage)
+	if container.TextSearch != nil {
+		repoController.SetTextSearchService(container.TextSearch)
+	}
+	if container.CodeCardService != nil {
+		repoController.SetCodeCardService(container.CodeCardService)
+	}
+	if container.CompletionService != nil {
+		repoController.SetCompletionService(container.CompletionService)
+	}
+	if codeAPIController != nil {
+		codeAPIController.SetSessionStore(sessionStore)
+	}
+	sessionController := controller.NewSessionController(sessionStore, handlerLogger)
+
+	var archiver *coldstorage.Archiver
+	if cfg.ColdStorage.Enabled {
+		if container.Storage == nil {
+			logger.Fatal("cold_storage needs a storage backend: set storage in app.yaml, or app.workdir for the local one")
+		}
+		archiver, err = coldstorage.NewArchiver(context.Background(), container.Storage, cfg.Source.Repositories, container.CodeGraph, container.VectorDB, logger)
+		if err != nil {
+			logger.Fatal("Failed to initialize cold storage", zap.Error(err))
+		}
+		archiver.Start(context.Background())
+	}
+	router := handler.SetupRouter(repoController, mcpServer, codeAPIController, graphEmbeddingController, sessionController, container.SlowLog, container.QueryLog, apiPolicy, archiver, handlerLogger)
+	handler.RegisterAdminRoutes(router, cfg.Admin, container.Storage, codeAPIController, apiPolicy, handlerLogger)
+	var enrichmentController *controller.EnrichmentController
+	if container.CodeGraph != nil {
+		enrichmentController = controller.NewEnrichmentController(container.CodeGraph, handlerLogger)
+	}
+	handler.RegisterEnrichmentRoutes(router, cfg.Enrichment, enrichmentController, handlerLogger)
 
 	logger.Info("Starting server", zap.Int("port", cfg.App.Port))
 	if err := http.ListenAndServe(fmt.Sprintf(":%d", cfg.App.Port), router); err != nil {
@@ -137,6 +423,26 @@ func main() {
 	}
 }
 
+// startProfiling starts the CPU profile requested with --profile and returns the function
+// that stops it and writes the heap profile; without --profile both do nothing
+func startProfiling(dir string, logger *zap.Logger) func() {
+	if dir == "" {
+		return func() {}
+	}
+	stop, err := util.StartProfiling(dir)
+	if err != nil {
+		logger.Fatal("Failed to start profiling", zap.Error(err))
+	}
+	logger.Info("Profiling the run", zap.String("dir", dir))
+	return func() {
+		if err := stop(); err != nil {
+			logger.Error("Failed to write profiles", zap.Error(err))
+			return
+		}
+		logger.Info("Profiles written", zap.String("dir", dir))
+	}
+}
+
 func LSPTest(cfg *config.Config, logger *zap.Logger) {
 	logger.Info("Testing LSP client")
 	repo, _ := cfg.GetRepository("mcp-server")
@@ -159,7 +465,40 @@ func LSPTest(cfg *config.Config, logger *zap.Logger) {
 	baseClient.TestCommand(ctx)
 }
 
-func BuildIndexCommand(cfg *config.Config, logger *zap.Logger, repoNames []string, useHead bool, testDumpPath string, clean bool) {
+// archiveBuild marks a build of a repository extracted from --archive
+type archiveBuild struct {
+	version string
+}
+
+// extractArchiveRepository extracts --archive to a temporary directory and points the
+// repository at it, adding the repository to cfg when it is not configured. The returned
+// function removes the extracted files.
+func extractArchiveRepository(cfg *config.Config, logger *zap.Logger, repoName, archivePath, version string) (*archiveBuild, func()) {
+	dir, err := os.MkdirTemp(cfg.App.WorkDir, "archive-*")
+	if err != nil {
+		logger.Fatal("Failed to create extraction directory", zap.Error(err))
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+	root, err := util.ExtractArchive(archivePath, dir, util.ArchiveLimits{})
+	if err != nil {
+		cleanup()
+		logger.Fatal("Failed to extract archive", zap.String("archive", archivePath), zap.Error(err))
+	}
+	logger.Info("Extracted archive", zap.String("archive", archivePath), zap.String("path", root))
+
+	for i := range cfg.Source.Repositories {
+		if cfg.Source.Repositories[i].Name == repoName {
+			cfg.Source.Repositories[i].Path = root
+			return &archiveBuild{version: version}, cleanup
+		}
+	}
+	cfg.Source.Repositories = append(cfg.Source.Repositories, config.Repository{Name: repoName, Path: root})
+	return &archiveBuild{version: version}, cleanup
+}
+
+// BuildIndexCommand builds the indexes of the named repositories; fromArchive is set when the
+// repository was extracted from --archive
+func BuildIndexCommand(cfg *config.Config, logger *zap.Logger, repoNames []string, useHead bool, testDumpPath string, dumpToStorage bool, clean bool, processorNames []string, fromArchive *archiveBuild) {
 	ctx := context.Background()
 
 	logger.Info("Build index command started",
@@ -167,9 +506,11 @@ func BuildIndexCommand(cfg *config.Config, logger *zap.Logger, repoNames []strin
 		zap.Bool("use_head", useHead),
 		zap.String("test_dump_path", testDumpPath),
 		zap.Bool("clean", clean),
+		zap.Strings("processors", processorNames),
 		zap.Bool("code_graph_enabled", cfg.IndexBuilding.EnableCodeGraph),
 		zap.Bool("embeddings_enabled", cfg.IndexBuilding.EnableEmbeddings),
-		zap.Bool("ngram_enabled", cfg.IndexBuilding.EnableNgram))
+		zap.Bool("ngram_enabled", cfg.IndexBuilding.EnableNgram),
+		zap.Bool("text_search_enabled", cfg.IndexBuilding.EnableTextSearch))
 
 	// Initialize all services using the new initialization module
 	opts := init_services.GetIndexBuildingOptions(cfg)
@@ -216,6 +557,16 @@ func BuildIndexCommand(cfg *config.Config, logger *zap.Logger, repoNames []strin
 
 		// Create index builder with FileVersionRepository for this specific repo
 		indexBuilder := controller.NewIndexBuilder(cfg, container.Processors, fileVersionRepo, logger)
+		indexBuilder.SetScheduler(container.Scheduler)
+		indexBuilder.SetLocker(container.Locker)
+		indexBuilder.SetStorage(container.Storage)
+		if err := indexBuilder.SelectProcessors(processorNames); err != nil {
+			logger.Fatal("Invalid --processors value", zap.Error(err))
+			return
+		}
+		if fromArchive != nil {
+			indexBuilder.SetArchiveSource(fromArchive.version)
+		}
 
 		// Get git info if using HEAD mode
 		var gitInfo *util.GitInfo
@@ -243,17 +594,34 @@ func BuildIndexCommand(cfg *config.Config, logger *zap.Logger, repoNames []strin
 			continue
 		}
 
+		summary := indexBuilder.Summary()
 		logger.Info("Completed index building for repository",
-			zap.String("repo_name", repo.Name))
+			zap.String("repo_name", repo.Name),
+			zap.Int("files_processed", summary.FilesProcessed),
+			zap.Int("files_lightweight", summary.FilesLightweight),
+			zap.Any("files_skipped", summary.FilesSkipped))
 	}
 
 	// If test-dump is specified, dump the code graph after all processing is complete
 	if testDumpPath != "" && container.CodeGraph != nil {
-		logger.Info("Dumping code graph to file", zap.String("path", testDumpPath))
-		if err := container.CodeGraph.DumpToFile(ctx, testDumpPath, repoNames); err != nil {
+		opts := dumpOptionsForPath(testDumpPath, cfg.IndexBuilding.Deterministic)
+		var err error
+		location := testDumpPath
+		if dumpToStorage {
+			if container.Storage == nil {
+				logger.Fatal("--dump-to-storage needs a storage backend: set storage in app.yaml, or app.workdir for the local one")
+			}
+			location = container.Storage.Location(testDumpPath)
+			logger.Info("Dumping code graph to storage", zap.String("location", location))
+			err = container.CodeGraph.DumpToStore(ctx, container.Storage, testDumpPath, repoNames, opts)
+		} else {
+			logger.Info("Dumping code graph to file", zap.String("path", testDumpPath))
+			err = container.CodeGraph.DumpToFileWithOptions(ctx, testDumpPath, repoNames, opts)
+		}
+		if err != nil {
 			logger.Error("Failed to dump code graph", zap.Error(err))
 		} else {
-			logger.Info("Code graph dumped successfully", zap.String("path", testDumpPath))
+			logger.Info("Code graph dumped successfully", zap.String("path", location))
 		}
 	} else if testDumpPath != "" && container.CodeGraph == nil {
 		logger.Warn("Cannot dump code graph: CodeGraph is not enabled")
@@ -317,6 +685,554 @@ func BuildIndexCommand(cfg *config.Config, logger *zap.Logger, repoNames []strin
 	logger.Info("Build index command completed")
 }
 
+// ReplayJournalCommand replays the index journal of the repository recorded at or after
+// since. It returns false if the replay failed.
+func ReplayJournalCommand(cfg *config.Config, logger *zap.Logger, repoName string, since time.Time, processorNames []string) bool {
+	ctx := context.Background()
+
+	repo, err := cfg.GetRepository(repoName)
+	if err != nil {
+		logger.Error("Repository not found in configuration", zap.String("repo_name", repoName), zap.Error(err))
+		return false
+	}
+
+	opts := init_services.GetIndexBuildingOptions(cfg)
+	container, err := init_services.NewServiceContainer(cfg, opts, logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize services", zap.Error(err))
+	}
+	defer container.Close(ctx)
+
+	if err := container.InitProcessors(cfg); err != nil {
+		logger.Fatal("Failed to initialize processors", zap.Error(err))
+	}
+
+	indexBuilder := controller.NewIndexBuilder(cfg, container.Processors, nil, logger)
+	indexBuilder.SetScheduler(container.Scheduler)
+	indexBuilder.SetLocker(container.Locker)
+	indexBuilder.SetStorage(container.Storage)
+	if err := indexBuilder.SelectProcessors(processorNames); err != nil {
+		logger.Fatal("Invalid --processors value", zap.Error(err))
+	}
+
+	summary, err := indexBuilder.ReplayJournal(ctx, repo, since)
+	if err != nil {
+		logger.Error("Failed to replay index journal", zap.String("repo_name", repo.Name), zap.Error(err))
+		return false
+	}
+	logger.Info("Replayed index journal",
+		zap.String("repo_name", repo.Name),
+		zap.Int("entries", summary.Entries),
+		zap.Int("replayed", summary.Replayed),
+		zap.Int("superseded", summary.Superseded),
+		zap.Int("skipped", summary.Skipped),
+		zap.Int("unreadable", summary.Unreadable),
+		zap.Int("failed", summary.Failed))
+	return summary.Failed == 0
+}
+
+// ReduceEmbeddingsCommand rebuilds a vector collection with its vectors reduced as configured
+// in qdrant.reduction and records the transform under the work directory, where servers
+// started afterwards pick it up. It returns false if the reduction failed.
+func ReduceEmbeddingsCommand(cfg *config.Config, logger *zap.Logger, collectionName string) bool {
+	ctx := context.Background()
+
+	if cfg.App.WorkDir == "" {
+		logger.Error("--reduce-embeddings requires app.work_dir (or --workdir), where the reduction is recorded")
+		return false
+	}
+	if cfg.Qdrant.Reduction.Dimension <= 0 {
+		logger.Error("--reduce-embeddings requires qdrant.reduction.dimension")
+		return false
+	}
+	method := cfg.Qdrant.Reduction.Method
+	if method == "" {
+		method = vector.ReductionPCA
+	}
+
+	opts := init_services.GetIndexBuildingOptions(cfg)
+	container, err := init_services.NewServiceContainer(cfg, opts, logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize services", zap.Error(err))
+	}
+	defer container.Close(ctx)
+	if container.VectorDB == nil {
+		logger.Fatal("--reduce-embeddings requires the vector store (qdrant.host and ollama.url)")
+	}
+
+	r, err := vector.ReduceCollection(ctx, container.VectorDB, vector.ReductionDir(cfg.App.WorkDir), collectionName, vector.ReduceOptions{
+		Method:     method,
+		Dimension:  cfg.Qdrant.Reduction.Dimension,
+		SampleSize: cfg.Qdrant.Reduction.SampleSize,
+	}, logger)
+	if err != nil {
+		logger.Error("Failed to reduce embeddings", zap.String("collection", collectionName), zap.Error(err))
+		return false
+	}
+	logger.Info("Reduced embeddings; restart running servers to apply the reduction",
+		zap.String("collection", collectionName),
+		zap.String("method", r.Method),
+		zap.Int("source_dim", r.SourceDim),
+		zap.Int("dimension", r.Dimension),
+		zap.String("record", vector.ReductionFile(vector.ReductionDir(cfg.App.WorkDir), collectionName)))
+	return true
+}
+
+// FsckCommand cross-checks file tracking, the code graph and the vector store of the named
+// repositories and prints what it finds. With repair, files marked done without graph nodes
+// are reset so the next build processes them again, and chunks and graph nodes of unknown
+// or deleted files are deleted. It returns false if inconsistencies remain.
+func FsckCommand(cfg *config.Config, logger *zap.Logger, repoNames []string, repair bool) bool {
+	ctx := codegraph.WithLeaderReads(context.Background())
+
+	opts := init_services.GetIndexBuildingOptions(cfg)
+	container, err := init_services.NewServiceContainer(cfg, opts, logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize services", zap.Error(err))
+	}
+	defer container.Close(ctx)
+	if container.MySQLConn == nil {
+		logger.Fatal("--fsck requires MySQL, which tracks the indexed files")
+	}
+
+	consistent := true
+	for _, repoName := range repoNames {
+		repo, err := cfg.GetRepository(repoName)
+		if err != nil {
+			logger.Error("Repository not found in configuration", zap.String("repo_name", repoName), zap.Error(err))
+			consistent = false
+			continue
+		}
+		fileVersionRepo, err := db.NewFileVersionRepository(container.MySQLConn.GetDB(), repo.Name, logger)
+		if err != nil {
+			logger.Error("Failed to create file version repository", zap.String("repo_name", repo.Name), zap.Error(err))
+			consistent = false
+			continue
+		}
+
+		snap, err := fsckSnapshot(ctx, cfg, container, fileVersionRepo, repo, logger)
+		if err != nil {
+			logger.Error("Failed to read repository state", zap.String("repo_name", repo.Name), zap.Error(err))
+			consistent = false
+			continue
+		}
+		report := fsck.Check(repo.Name, snap)
+		fsck.WriteReport(os.Stdout, report)
+		if report.Issues() == 0 {
+			continue
+		}
+		if !repair {
+			consistent = false
+			continue
+		}
+		if !fsckRepair(ctx, container, fileVersionRepo, repo.Name, report, logger) {
+			consistent = false
+		}
+	}
+	return consistent
+}
+
+// APIDiffCommand compares two API snapshots of a library repository, taken by its index
+// builds, and writes the breaking changes (removed, renamed and changed symbols) in the given
+// format, to stdout or the output file. It returns false if there are any, so CI
+// jobs fail on them.
+func APIDiffCommand(cfg *config.Config, logger *zap.Logger, repoName, from, to, format, output string) bool {
+	repo, err := cfg.GetRepository(repoName)
+	if err != nil {
+		logger.Fatal("Repository not found in configuration", zap.String("repo_name", repoName), zap.Error(err))
+	}
+	store, err := storage.New(cfg.Storage, cfg.App.WorkDir)
+	if err != nil {
+		logger.Fatal("Failed to open storage", zap.Error(err))
+	}
+	if store == nil {
+		logger.Fatal("--api-diff requires storage (app.work_dir or the storage section), where index builds store API snapshots")
+	}
+	ctx := context.Background()
+
+	after, err := apisurface.Load(ctx, store, repo.Name, to)
+	if err != nil {
+		logger.Fatal("Failed to load API snapshot", zap.String("repo_name", repo.Name), zap.String("version", to), zap.Error(err))
+	}
+	if from == "" {
+		if from, err = apisurface.PreviousVersion(ctx, store, repo.Name, after.Version); err != nil {
+			logger.Fatal("Failed to list API snapshots", zap.String("repo_name", repo.Name), zap.Error(err))
+		}
+		if from == "" {
+			logger.Fatal("No API snapshot before the compared version, pass --api-diff-from",
+				zap.String("repo_name", repo.Name),
+				zap.String("version", after.Version))
+		}
+	}
+	before, err := apisurface.Load(ctx, store, repo.Name, from)
+	if err != nil {
+		logger.Fatal("Failed to load API snapshot", zap.String("repo_name", repo.Name), zap.String("version", from), zap.Error(err))
+	}
+
+	report := apisurface.FindBreakingChanges(apisurface.Compare(before, after))
+	out := os.Stdout
+	if output != "" {
+		if out, err = os.Create(output); err != nil {
+			logger.Fatal("Failed to create API diff report", zap.String("path", output), zap.Error(err))
+		}
+		defer out.Close()
+	}
+	if err := apisurface.WriteBreakingReport(out, report, format); err != nil {
+		logger.Fatal("Failed to write API diff report", zap.Error(err))
+	}
+	logger.Info("Compared API snapshots",
+		zap.String("repo_name", repo.Name),
+		zap.String("from", report.From),
+		zap.String("to", report.To),
+		zap.Int("breaking_changes", len(report.Breaking)),
+		zap.Int("added", report.Added))
+	return !report.HasBreaking
+}
+
+// ScriptCommand runs a Starlark analysis script (see package script) with the admin script
+// limits and prints its result, output and counters as JSON. It returns false if the script
+// fails.
+func ScriptCommand(cfg *config.Config, logger *zap.Logger, path string, rawArgs []string) bool {
+	ctx := context.Background()
+	src, err := os.ReadFile(path)
+	if err != nil {
+		logger.Fatal("Failed to read script", zap.String("path", path), zap.Error(err))
+	}
+	args := make(map[string]any, len(rawArgs))
+	for _, raw := range rawArgs {
+		key, value, ok := strings.Cut(raw, "=")
+		if !ok || key == "" {
+			logger.Fatal("Invalid --script-arg, expected key=value", zap.String("arg", raw))
+		}
+		var parsed any
+		if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+			parsed = value
+		}
+		args[key] = parsed
+	}
+
+	opts := init_services.GetIndexBuildingOptions(cfg)
+	container, err := init_services.NewServiceContainer(cfg, opts, logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize services", zap.Error(err))
+	}
+	defer container.Close(ctx)
+	if container.CodeGraph == nil {
+		logger.Fatal("--script requires the code graph")
+	}
+
+	api := codeapi.NewCodeAPI(container.CodeGraph, logger)
+	result, err := script.Run(ctx, api, string(src), args, script.ConfigLimits(cfg.Admin))
+	if err != nil {
+		var scriptErr *script.Error
+		if errors.As(err, &scriptErr) {
+			for _, line := range scriptErr.Output {
+				fmt.Println(line)
+			}
+			if scriptErr.Backtrace != "" {
+				fmt.Fprintln(os.Stderr, scriptErr.Backtrace)
+			}
+		}
+		logger.Error("Script failed", zap.String("path", path), zap.Error(err))
+		return false
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		logger.Error("Failed to write script result", zap.Error(err))
+		return false
+	}
+	return true
+}
+
+// ImportTracesCommand maps the spans of an OTLP/JSON trace export to the functions of a
+// repository and writes the calls between them as RUNTIME_CALLS relations
+func ImportTracesCommand(cfg *config.Config, logger *zap.Logger, path, repoName, source string, replace bool) bool {
+	ctx := context.Background()
+	if repoName == "" {
+		logger.Fatal("--import-traces requires --import-traces-repo")
+	}
+	if _, err := cfg.GetRepository(repoName); err != nil {
+		logger.Fatal("Unknown repository", zap.String("repo_name", repoName), zap.Error(err))
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		logger.Fatal("Failed to open trace export", zap.String("path", path), zap.Error(err))
+	}
+	defer f.Close()
+	spans, err := tracing.ParseOTLP(f)
+	if err != nil {
+		logger.Error("Failed to read trace export", zap.String("path", path), zap.Error(err))
+		return false
+	}
+
+	opts := init_services.GetIndexBuildingOptions(cfg)
+	container, err := init_services.NewServiceContainer(cfg, opts, logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize services", zap.Error(err))
+	}
+	defer container.Close(ctx)
+	if container.CodeGraph == nil {
+		logger.Fatal("--import-traces requires the code graph")
+	}
+
+	if replace {
+		if _, err := container.CodeGraph.DeleteEnrichedRelations(ctx, tracing.RelRuntimeCalls, source); err != nil {
+			logger.Error("Failed to delete previously imported relations", zap.Error(err))
+			return false
+		}
+	}
+	importer := tracing.NewImporter(container.CodeGraph, logger)
+	result, err := importer.Import(ctx, repoName, spans, codegraph.RelationProvenance{Source: source, RequestID: filepath.Base(path)})
+	if err != nil {
+		logger.Error("Failed to import traces", zap.String("path", path), zap.Error(err))
+		return false
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		logger.Error("Failed to write import result", zap.Error(err))
+		return false
+	}
+	return true
+}
+
+// fsckSnapshot reads what each enabled store records about the files of the repository
+func fsckSnapshot(ctx context.Context, cfg *config.Config, container *init_services.ServiceContainer, fileVersionRepo *db.FileVersionRepository, repo *config.Repository, logger *zap.Logger) (fsck.Snapshot, error) {
+	var snap fsck.Snapshot
+	files, err := fileVersionRepo.GetAllFiles()
+	if err != nil {
+		return snap, fmt.Errorf("failed to list tracked files: %w", err)
+	}
+	snap.Files = files
+
+	if container.CodeGraph != nil {
+		scopes, err := container.CodeGraph.FindFileScopes(ctx, repo.Name, "")
+		if err != nil {
+			return snap, fmt.Errorf("failed to list FileScopes: %w", err)
+		}
+		snap.FileScopes = make(map[int32]string, len(scopes))
+		for _, scope := range scopes {
+			path, _ := scope.MetaData["path"].(string)
+			snap.FileScopes[scope.FileID] = path
+		}
+		parser := parse.NewFileParser(logger, container.CodeGraph, cfg)
+		snap.ExpectsFileScope = func(relPath string) bool { return parser.ExpectsFileScope(repo, relPath) }
+	}
+
+	if container.VectorDB != nil {
+		// The collection is named after the repository
+		exists, err := container.VectorDB.CollectionExists(ctx, repo.Name)
+		if err != nil {
+			return snap, fmt.Errorf("failed to check vector collection: %w", err)
+		}
+		snap.ChunkFiles = []vector.ChunkFile{}
+		if exists {
+			if snap.ChunkFiles, err = container.VectorDB.ListChunkFiles(ctx, repo.Name); err != nil {
+				return snap, fmt.Errorf("failed to list chunk files: %w", err)
+			}
+		}
+	}
+
+	if info, err := os.Stat(repo.Path); err == nil && info.IsDir() {
+		snap.PathExists = func(relPath string) bool {
+			_, err := os.Stat(filepath.Join(repo.Path, filepath.FromSlash(relPath)))
+			return err == nil
+		}
+	} else {
+		logger.Warn("Repository checkout not found, not checking for deleted files", zap.String("path", repo.Path))
+	}
+	return snap, nil
+}
+
+// fsckRepair fixes the inconsistencies of a report and returns false if any repair failed
+func fsckRepair(ctx context.Context, container *init_services.ServiceContainer, fileVersionRepo *db.FileVersionRepository, repoName string, report *fsck.Report, logger *zap.Logger) bool {
+	ok := true
+
+	// The default status, so the next build processes the file again
+	for _, ref := range report.DoneWithoutGraph {
+		if err := fileVersionRepo.UpdateStatus(ref.FileID, "processing"); err != nil {
+			logger.Error("Failed to reset file status", zap.Int32("file_id", ref.FileID), zap.Error(err))
+			ok = false
+		}
+	}
+
+	var chunkFileIDs []int32
+	for _, ref := range report.UnknownChunkFiles {
+		if ref.FileID != 0 {
+			chunkFileIDs = append(chunkFileIDs, ref.FileID)
+			continue
+		}
+		// Chunks without a file ID are deleted one by one, by exact path
+		chunks, err := container.VectorDB.GetChunksByFilePath(ctx, repoName, ref.Path)
+		if err == nil {
+			for _, chunk := range chunks {
+				if chunk.FileID != 0 {
+					continue
+				}
+				if err = container.VectorDB.DeleteChunk(ctx, repoName, chunk.ID); err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			logger.Error("Failed to delete chunks", zap.String("path", ref.Path), zap.Error(err))
+			ok = false
+		}
+	}
+	if len(chunkFileIDs) > 0 {
+		deleted, err := container.VectorDB.PurgeChunks(ctx, repoName, vector.PurgeFilter{FileIDs: chunkFileIDs})
+		if err != nil {
+			logger.Error("Failed to delete chunks of unknown files", zap.Error(err))
+			ok = false
+		}
+		logger.Info("Deleted chunks of unknown files", zap.String("repo_name", repoName), zap.Int("chunks", deleted))
+	}
+
+	if len(report.DeletedGraphFiles) > 0 {
+		fileIDs := make([]int32, len(report.DeletedGraphFiles))
+		for i, ref := range report.DeletedGraphFiles {
+			fileIDs[i] = ref.FileID
+		}
+		deleted, err := container.CodeGraph.DeleteFileNodes(ctx, fileIDs)
+		if err != nil {
+			logger.Error("Failed to delete graph nodes of deleted files", zap.Error(err))
+			ok = false
+		}
+		logger.Info("Deleted graph nodes of deleted files", zap.String("repo_name", repoName), zap.Int64("nodes", deleted))
+	}
+
+	if ok {
+		fmt.Printf("  Repaired %d inconsistencies\n", report.Issues())
+	}
+	return ok
+}
+
+// benchRepoName is the repository name the synthetic benchmark repository is indexed under
+const benchRepoName = "bench-synthetic"
+
+// BenchCommand indexes a generated repository, reports throughput and peak memory, and
+// compares them with the baseline. All data written for the synthetic repository is removed
+// afterwards. It returns false if the build failed or a metric regressed.
+func BenchCommand(cfg *config.Config, logger *zap.Logger, genOpts bench.GenerateOptions, baselinePath string, updateBaseline bool, tolerance float64, processorNames []string) bool {
+	ctx := context.Background()
+
+	repoDir, err := os.MkdirTemp("", "bot-go-bench-")
+	if err != nil {
+		logger.Error("Failed to create benchmark directory", zap.Error(err))
+		return false
+	}
+	defer os.RemoveAll(repoDir)
+
+	if err := bench.GenerateRepo(repoDir, genOpts); err != nil {
+		logger.Error("Failed to generate benchmark repository", zap.Error(err))
+		return false
+	}
+	cfg.Source.Repositories = append(cfg.Source.Repositories, config.Repository{
+		Name:     benchRepoName,
+		Path:     repoDir,
+		Language: "go",
+	})
+	repo, _ := cfg.GetRepository(benchRepoName)
+
+	opts := init_services.GetIndexBuildingOptions(cfg)
+	container, err := init_services.NewServiceContainer(cfg, opts, logger)
+	if err != nil {
+		logger.Error("Failed to initialize services", zap.Error(err))
+		return false
+	}
+	defer container.Close(ctx)
+	if err := container.InitProcessors(cfg); err != nil {
+		logger.Error("Failed to initialize processors", zap.Error(err))
+		return false
+	}
+
+	fileVersionRepo, err := db.NewFileVersionRepository(container.MySQLConn.GetDB(), repo.Name, logger)
+	if err != nil {
+		logger.Error("Failed to create file version repository", zap.Error(err))
+		return false
+	}
+	defer cleanBenchData(ctx, container, fileVersionRepo, logger)
+
+	indexBuilder := controller.NewIndexBuilder(cfg, container.Processors, fileVersionRepo, logger)
+	indexBuilder.SetScheduler(container.Scheduler)
+	indexBuilder.SetLocker(container.Locker)
+	indexBuilder.SetStorage(container.Storage)
+	if err := indexBuilder.SelectProcessors(processorNames); err != nil {
+		logger.Error("Invalid --processors value", zap.Error(err))
+		return false
+	}
+
+	sampler := bench.StartMemorySampler(50 * time.Millisecond)
+	start := time.Now()
+	buildErr := indexBuilder.BuildIndex(ctx, repo)
+	result := &bench.Result{
+		Duration:      time.Since(start),
+		PeakHeapBytes: sampler.Stop(),
+		GoVersion:     runtime.Version(),
+		RecordedAt:    time.Now().UTC(),
+	}
+	if buildErr != nil {
+		logger.Error("Benchmark build failed", zap.Error(buildErr))
+		return false
+	}
+
+	result.Files = indexBuilder.Summary().FilesProcessed
+	if container.CodeGraph != nil {
+		if result.Nodes, err = container.CodeGraph.CountRepoNodes(ctx, repo.Name); err != nil {
+			logger.Warn("Failed to count graph nodes", zap.Error(err))
+		}
+	}
+	for _, p := range container.Processors {
+		if ep, ok := p.(*controller.EmbeddingProcessor); ok {
+			result.Embeddings = ep.EmbeddedChunks()
+		}
+	}
+
+	var comparisons []bench.Comparison
+	if baselinePath != "" && !updateBaseline {
+		baseline, err := bench.LoadBaseline(baselinePath)
+		if err != nil {
+			logger.Error("Failed to load benchmark baseline", zap.Error(err))
+			return false
+		}
+		if baseline != nil {
+			comparisons = bench.Compare(result, baseline, tolerance)
+		}
+	}
+	bench.WriteReport(os.Stdout, result, comparisons)
+
+	if updateBaseline {
+		if baselinePath == "" {
+			logger.Error("--bench-update-baseline requires --bench-baseline")
+			return false
+		}
+		if err := bench.SaveBaseline(baselinePath, result); err != nil {
+			logger.Error("Failed to save benchmark baseline", zap.Error(err))
+			return false
+		}
+		logger.Info("Benchmark baseline updated", zap.String("path", baselinePath))
+	}
+	return !bench.HasRegression(comparisons)
+}
+
+// cleanBenchData removes everything the benchmark wrote for the synthetic repository
+func cleanBenchData(ctx context.Context, container *init_services.ServiceContainer, fileVersionRepo *db.FileVersionRepository, logger *zap.Logger) {
+	if container.CodeGraph != nil {
+		if err := container.CodeGraph.CleanRepository(ctx, benchRepoName); err != nil {
+			logger.Warn("Failed to clean benchmark graph data", zap.Error(err))
+		}
+	}
+	if container.VectorDB != nil {
+		if err := container.VectorDB.DeleteCollection(ctx, benchRepoName); err != nil {
+			logger.Warn("Failed to clean benchmark collection", zap.Error(err))
+		}
+	}
+	if err := fileVersionRepo.DropTable(); err != nil {
+		logger.Warn("Failed to drop benchmark file version table", zap.Error(err))
+	}
+}
+
 func CodeGraphEntry(cfg *config.Config, logger *zap.Logger, container *init_services.ServiceContainer) {
 	if !cfg.App.CodeGraph {
 		logger.Info("CodeGraph is disabled in the configuration")
@@ -364,6 +1280,9 @@ func CodeGraphEntry(cfg *config.Config, logger *zap.Logger, container *init_serv
 			}
 
 			indexBuilder := controller.NewIndexBuilder(cfg, container.Processors, fileVersionRepo, logger)
+			indexBuilder.SetScheduler(container.Scheduler)
+			indexBuilder.SetLocker(container.Locker)
+			indexBuilder.SetStorage(container.Storage)
 
 			err = indexBuilder.BuildIndex(ctx, &repo)
 			if err != nil {
@@ -0,0 +1,125 @@
+// Package access identifies the clients of the HTTP API by API key (see config.APIConfig),
+// accounts their usage against their quotas, and redacts the responses sent to partially
+// trusted ones: absolute paths, masked directories and metadata keys, as configured per scope.
+package access
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"bot-go/internal/config"
+)
+
+// APIKeyHeader carries an API key when the Authorization header is used for something else
+const APIKeyHeader = "X-API-Key"
+
+var (
+	// ErrMissingKey is returned for requests without a key when one is required
+	ErrMissingKey = errors.New("missing API key")
+	// ErrInvalidKey is returned for requests carrying a key that is not configured
+	ErrInvalidKey = errors.New("invalid API key")
+)
+
+// Client is the caller of a request
+type Client struct {
+	Name    string   // of its API key; empty without one
+	Scope   string   // redaction scope; empty for none
+	Account *Account // usage account
+}
+
+type clientKey struct{}
+
+// WithClient returns a context carrying the client of a request
+func WithClient(ctx context.Context, client *Client) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+// ClientFromContext returns the client of a request, or nil when the request was not
+// authenticated
+func ClientFromContext(ctx context.Context) *Client {
+	client, _ := ctx.Value(clientKey{}).(*Client)
+	return client
+}
+
+// Policy authenticates API requests, keeps the usage accounts of their clients and holds the
+// redaction rules of each scope
+type Policy struct {
+	enabled      bool
+	keys         []config.APIKey
+	requireKey   bool
+	defaultScope string
+	redactors    map[string]*Redactor
+	meter        *Meter
+}
+
+// NewPolicy compiles the api section of the configuration. Paths under the roots of the
+// configured repositories and under workDir are made relative by HideAbsolutePaths.
+func NewPolicy(cfg config.APIConfig, repos []config.Repository, workDir string) *Policy {
+	roots := make([]string, 0, len(repos)+1)
+	for _, repo := range repos {
+		roots = append(roots, repo.Path)
+	}
+	if workDir != "" {
+		roots = append(roots, workDir)
+	}
+	p := &Policy{
+		enabled:      cfg.Enabled(),
+		keys:         cfg.Keys,
+		requireKey:   cfg.RequireKey,
+		defaultScope: cfg.DefaultScope,
+		redactors:    make(map[string]*Redactor, len(cfg.Redaction)),
+		meter:        NewMeter(cfg.QuotaPeriod),
+	}
+	for scope, rules := range cfg.Redaction {
+		p.redactors[scope] = NewRedactor(rules, roots)
+	}
+	return p
+}
+
+// Enabled reports whether the policy does anything: check keys, account usage or redact
+// responses
+func (p *Policy) Enabled() bool {
+	return p != nil && p.enabled
+}
+
+// Authenticate returns the client of a request from its API key. Requests without a key get
+// the default scope, unless a key is required.
+func (p *Policy) Authenticate(r *http.Request) (*Client, error) {
+	given := r.Header.Get(APIKeyHeader)
+	if given == "" {
+		given, _ = strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+	if given == "" {
+		if p.requireKey {
+			return nil, ErrMissingKey
+		}
+		return &Client{Scope: p.defaultScope, Account: p.meter.Account("", config.UsageQuota{})}, nil
+	}
+	// Every key is compared so the time taken does not tell which one matched
+	var match *config.APIKey
+	for i := range p.keys {
+		if subtle.ConstantTimeCompare([]byte(given), []byte(p.keys[i].Key)) == 1 {
+			match = &p.keys[i]
+		}
+	}
+	if match == nil {
+		return nil, ErrInvalidKey
+	}
+	return &Client{Name: match.Name, Scope: match.Scope, Account: p.meter.Account(match.Name, match.Quota)}, nil
+}
+
+// Meter returns the usage accounts of the clients
+func (p *Policy) Meter() *Meter {
+	return p.meter
+}
+
+// Redactor returns the redactor of a scope, or nil when its responses are not redacted
+func (p *Policy) Redactor(scope string) *Redactor {
+	if scope == "" {
+		return nil
+	}
+	return p.redactors[scope]
+}
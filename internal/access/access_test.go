@@ -0,0 +1,194 @@
+package access
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"bot-go/internal/cache"
+	"bot-go/internal/config"
+
+	"go.uber.org/zap"
+)
+
+func TestAuthenticate(t *testing.T) {
+	cfg := config.APIConfig{
+		DefaultScope: "public",
+		Keys: []config.APIKey{
+			{Name: "ci", Key: "k1"},
+			{Name: "partner", Key: "k2", Scope: "partner"},
+		},
+	}
+	policy := NewPolicy(cfg, nil, "")
+
+	tests := []struct {
+		header, value string
+		want          *Client
+		wantErr       error
+	}{
+		{"", "", &Client{Scope: "public"}, nil},
+		{"Authorization", "Bearer k1", &Client{Name: "ci"}, nil},
+		{APIKeyHeader, "k2", &Client{Name: "partner", Scope: "partner"}, nil},
+		{APIKeyHeader, "k3", nil, ErrInvalidKey},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/api/v1/health", nil)
+		if tt.header != "" {
+			r.Header.Set(tt.header, tt.value)
+		}
+		got, err := policy.Authenticate(r)
+		if got != nil {
+			if got.Account == nil {
+				t.Errorf("Authenticate(%s: %s) returned a client without an account", tt.header, tt.value)
+			}
+			got.Account = nil
+		}
+		if err != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Authenticate(%s: %s) = %+v, %v; want %+v, %v", tt.header, tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+
+	cfg.RequireKey = true
+	if _, err := NewPolicy(cfg, nil, "").Authenticate(httptest.NewRequest("GET", "/", nil)); err != ErrMissingKey {
+		t.Errorf("Authenticate without a key = %v, want ErrMissingKey", err)
+	}
+}
+
+func TestRedactJSON(t *testing.T) {
+	redactor := NewRedactor(config.RedactionRules{
+		HideAbsolutePaths: true,
+		MaskDirectories:   []string{"internal/secret/"},
+		DropMetadataKeys:  []string{"last_modified_by", "blame_*"},
+	}, []string{"/srv/repos/app", "/srv/repos"})
+
+	body := `{
+		"repo_path": "/srv/repos/app",
+		"error": "failed to read /srv/repos/app/main.go: denied",
+		"config": "/etc/bot-go/app.yaml",
+		"endpoint": "POST /api/v1/review",
+		"count": 12345678901234567890,
+		"results": [
+			{"chunk": {"file_path": "/srv/repos/app/internal/secret/keys.go", "content": "const key = 1", "name": "key"}},
+			{"file_path": "internal/api/api.go", "content": "func Serve()", "md_last_modified_by": "ann", "metadata": {"blame_commit": "abc", "signature": "func Serve()"}}
+		],
+		"files": ["internal/secret/a.go", "cmd/main.go"]
+	}`
+	got, err := redactor.RedactJSON([]byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+		"repo_path": ".",
+		"error": "failed to read main.go: denied",
+		"config": "[redacted]",
+		"endpoint": "POST /api/v1/review",
+		"count": 12345678901234567890,
+		"results": [
+			{"chunk": {"file_path": "[masked]", "name": "key"}},
+			{"file_path": "internal/api/api.go", "content": "func Serve()", "metadata": {"signature": "func Serve()"}}
+		],
+		"files": ["[masked]", "cmd/main.go"]
+	}`
+	var gotValue, wantValue any
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(want), &wantValue); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		t.Errorf("RedactJSON =\n%s\nwant\n%s", got, want)
+	}
+	if !strings.Contains(string(got), "12345678901234567890") {
+		t.Errorf("RedactJSON changed a large number: %s", got)
+	}
+
+	// codeapi results are untagged structs with capitalized keys
+	got, err = redactor.RedactJSON([]byte(`{"Constants": [
+		{"ID": 1, "Name": "apiKey", "Value": "\"k-123\"", "FilePath": "internal/secret/keys.go", "FileID": 3},
+		{"ID": 2, "Name": "port", "Value": "8080", "FilePath": "cmd/main.go", "FileID": 4}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = `{"Constants": [
+		{"ID": 1, "Name": "apiKey", "FilePath": "[masked]", "FileID": 3},
+		{"ID": 2, "Name": "port", "Value": "8080", "FilePath": "cmd/main.go", "FileID": 4}
+	]}`
+	gotValue, wantValue = nil, nil
+	json.Unmarshal(got, &gotValue)
+	json.Unmarshal([]byte(want), &wantValue)
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		t.Errorf("RedactJSON of codeapi result =\n%s\nwant\n%s", got, want)
+	}
+
+	if _, err := redactor.RedactJSON([]byte("not json")); err == nil {
+		t.Error("RedactJSON of invalid JSON succeeded")
+	}
+}
+
+func TestMeter(t *testing.T) {
+	now := time.Date(2026, 3, 31, 23, 0, 0, 0, time.UTC)
+	meter := NewMeter(QuotaPeriodDay)
+	meter.now = func() time.Time { return now }
+
+	partner := meter.Account("partner", config.UsageQuota{EmbeddingTokens: 100, QueryTimeSeconds: 2})
+	client := &Client{Name: "partner", Account: partner}
+	ctx := WithClient(context.Background(), client)
+	Record(ctx, Usage{EmbeddingTokens: 60, QueryTime: time.Second})
+	Record(ctx, Usage{QueryTime: 1500 * time.Millisecond, FilesIndexed: 3})
+	Record(context.Background(), Usage{EmbeddingTokens: 1000}) // no client: not charged
+
+	resource, resets := partner.Exhausted()
+	if resource != ResourceQueryTime || !resets.Equal(time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Exhausted = %q, %v; want query_time until April 1", resource, resets)
+	}
+	meter.Account("", config.UsageQuota{}).Add(Usage{FilesIndexed: 1})
+	reports := meter.Reports()
+	want := UsageAmounts{EmbeddingTokens: 60, QueryTimeMs: 2500, FilesIndexed: 3}
+	if len(reports) != 2 || reports[0].Name != "" || reports[1].Used != want || reports[1].Quota.QueryTimeMs != 2000 {
+		t.Errorf("Reports = %+v, want the anonymous account and partner with %+v", reports, want)
+	}
+	if reports[0].Quota != nil || reports[0].Exhausted != "" {
+		t.Errorf("anonymous report = %+v, want no quota", reports[0])
+	}
+
+	// A new day starts from zero
+	now = now.Add(2 * time.Hour)
+	if resource, _ := partner.Exhausted(); resource != "" {
+		t.Errorf("Exhausted after the period = %q, want none", resource)
+	}
+	if used := partner.Report().Used; used != (UsageAmounts{}) {
+		t.Errorf("usage after the period = %+v, want none", used)
+	}
+
+	monthly := NewMeter(QuotaPeriodMonth)
+	if start := monthly.periodStart(now); !start.Equal(time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("periodStart = %v, want April 1", start)
+	}
+}
+
+func TestSharedMeter(t *testing.T) {
+	shared := cache.NewMemory()
+	quota := config.UsageQuota{FilesIndexed: 5}
+	a, b := NewMeter(QuotaPeriodDay), NewMeter(QuotaPeriodDay)
+	a.SetCache(shared, zap.NewNop())
+	b.SetCache(shared, zap.NewNop())
+
+	a.Account("ci", quota).Add(Usage{FilesIndexed: 3, QueryTime: time.Second})
+	other := b.Account("ci", quota)
+	if resource, _ := other.Exhausted(); resource != "" {
+		t.Fatalf("Exhausted = %q before the quota is used up", resource)
+	}
+	other.Add(Usage{FilesIndexed: 2})
+	if resource, _ := a.Account("ci", quota).Exhausted(); resource != ResourceFilesIndexed {
+		t.Errorf("Exhausted = %q, want files_indexed counted on both instances", resource)
+	}
+	if used := other.Report().Used; used != (UsageAmounts{QueryTimeMs: 1000, FilesIndexed: 5}) {
+		t.Errorf("shared usage = %+v", used)
+	}
+}
@@ -0,0 +1,177 @@
+package access
+
+import (
+	"bytes"
+	"encoding/json"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"bot-go/internal/config"
+	"bot-go/internal/util"
+)
+
+const (
+	// Redacted replaces absolute paths outside the repository roots and the workdir
+	Redacted = "[redacted]"
+	// Masked replaces paths inside masked directories
+	Masked = "[masked]"
+)
+
+// pathKeys are the object keys holding file paths; an object whose path is in a masked
+// directory loses its sourceKeys. Both are compared after normalizeKey, so file_path,
+// filePath and the FilePath of untagged structs all match.
+var pathKeys = map[string]bool{
+	"filepath": true, "path": true, "file": true, "oldpath": true, "relativepath": true,
+}
+
+var sourceKeys = map[string]bool{
+	"content": true, "source": true, "code": true, "snippet": true, "text": true, "value": true,
+}
+
+// normalizeKey lowercases a key and removes its underscores
+func normalizeKey(key string) string {
+	return strings.ToLower(strings.ReplaceAll(key, "_", ""))
+}
+
+// Redactor applies the redaction rules of one scope to JSON values
+type Redactor struct {
+	hideAbsolute bool
+	roots        []string // absolute, longest first
+	masks        []string // canonical repository-relative directories
+	drop         []string // key patterns
+}
+
+// NewRedactor compiles redaction rules. roots are the directories whose absolute paths are
+// made relative, e.g. the repository roots.
+func NewRedactor(rules config.RedactionRules, roots []string) *Redactor {
+	r := &Redactor{hideAbsolute: rules.HideAbsolutePaths, drop: rules.DropMetadataKeys}
+	for _, root := range roots {
+		if abs, err := filepath.Abs(root); err == nil && abs != string(filepath.Separator) {
+			r.roots = append(r.roots, abs)
+		}
+	}
+	sort.Slice(r.roots, func(i, j int) bool { return len(r.roots[i]) > len(r.roots[j]) })
+	for _, dir := range rules.MaskDirectories {
+		if dir = util.CanonicalPath(dir); dir != "" {
+			r.masks = append(r.masks, dir)
+		}
+	}
+	return r
+}
+
+// RedactJSON redacts a JSON document. Numbers are kept as written.
+func (r *Redactor) RedactJSON(body []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var v any
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(r.Redact(v))
+}
+
+// Redact returns a decoded JSON value with its metadata keys dropped, its paths in masked
+// directories masked along with the source of their objects, and, with HideAbsolutePaths,
+// its absolute paths made relative or hidden
+func (r *Redactor) Redact(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		masked := false
+		for key, value := range v {
+			if r.dropKey(key) {
+				delete(v, key)
+				continue
+			}
+			if s, ok := value.(string); ok && pathKeys[normalizeKey(key)] && r.masked(s) {
+				masked = true
+			}
+		}
+		if masked {
+			for key := range v {
+				if sourceKeys[normalizeKey(key)] {
+					delete(v, key)
+				}
+			}
+		}
+		for key, value := range v {
+			v[key] = r.Redact(value)
+		}
+		return v
+	case []any:
+		for i, value := range v {
+			v[i] = r.Redact(value)
+		}
+		return v
+	case string:
+		return r.redactString(v)
+	}
+	return v
+}
+
+func (r *Redactor) redactString(s string) string {
+	if r.masked(s) {
+		return Masked
+	}
+	if !r.hideAbsolute {
+		return s
+	}
+	// Paths under a root are made relative wherever they appear, e.g. in error messages
+	for _, root := range r.roots {
+		s = strings.ReplaceAll(s, root+string(filepath.Separator), "")
+		if s == root {
+			return "."
+		}
+	}
+	if filepath.IsAbs(s) && !strings.ContainsAny(s, " \t\n") {
+		return Redacted
+	}
+	return s
+}
+
+// masked reports whether s is a path inside a masked directory, relative or under a root
+func (r *Redactor) masked(s string) bool {
+	if len(r.masks) == 0 || s == "" {
+		return false
+	}
+	if filepath.IsAbs(s) {
+		rel, ok := r.relative(s)
+		if !ok {
+			return false
+		}
+		s = rel
+	}
+	p := util.CanonicalPath(s)
+	for _, dir := range r.masks {
+		if p == dir || strings.HasPrefix(p, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// relative returns an absolute path relative to the longest root containing it
+func (r *Redactor) relative(s string) (string, bool) {
+	for _, root := range r.roots {
+		if rel, ok := strings.CutPrefix(s, root+string(filepath.Separator)); ok {
+			return filepath.ToSlash(rel), true
+		}
+	}
+	return "", false
+}
+
+// dropKey reports whether a key matches a pattern of DropMetadataKeys, with or without the
+// md_ prefix of graph metadata properties
+func (r *Redactor) dropKey(key string) bool {
+	bare := strings.TrimPrefix(key, "md_")
+	for _, pattern := range r.drop {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, bare); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,279 @@
+package access
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"bot-go/internal/cache"
+	"bot-go/internal/config"
+
+	"go.uber.org/zap"
+)
+
+// Quota periods of config.APIConfig.QuotaPeriod
+const (
+	QuotaPeriodDay   = "day"
+	QuotaPeriodMonth = "month"
+)
+
+// Usage resources, as named in usage reports and 429 responses
+const (
+	ResourceEmbeddingTokens = "embedding_tokens"
+	ResourceQueryTime       = "query_time"
+	ResourceFilesIndexed    = "files_indexed"
+)
+
+// BytesPerToken estimates the tokens of embedded text, as models do not report them
+const BytesPerToken = 4
+
+// Usage is the work done for an API client
+type Usage struct {
+	EmbeddingTokens int64
+	QueryTime       time.Duration // in graph database queries
+	FilesIndexed    int64
+}
+
+func (u *Usage) add(other Usage) {
+	u.EmbeddingTokens += other.EmbeddingTokens
+	u.QueryTime += other.QueryTime
+	u.FilesIndexed += other.FilesIndexed
+}
+
+// Record charges usage to the client of a request. Work done outside API requests, e.g. by
+// command line builds, is not charged.
+func Record(ctx context.Context, usage Usage) {
+	if client := ClientFromContext(ctx); client != nil && client.Account != nil {
+		client.Account.Add(usage)
+	}
+}
+
+// Meter keeps the usage accounts of API clients for the current quota period. Usage is kept
+// in memory, so it restarts from zero with the server, unless the meter shares counters
+// through a cache.
+type Meter struct {
+	period string
+	now    func() time.Time
+	cache  cache.Cache // optional; counts the usage of every instance using it
+	logger *zap.Logger
+
+	mu       sync.Mutex
+	accounts map[string]*Account // by client name; "" for requests without a key
+}
+
+// NewMeter returns a meter whose quotas reset every period (QuotaPeriodDay by default)
+func NewMeter(period string) *Meter {
+	if period != QuotaPeriodMonth {
+		period = QuotaPeriodDay
+	}
+	return &Meter{period: period, now: time.Now, accounts: make(map[string]*Account)}
+}
+
+// SetCache counts usage in counters of c shared by the instances using it, so quotas hold
+// for a deployment rather than for each instance. Usage is still counted in memory, which
+// quotas fall back to while c fails.
+func (m *Meter) SetCache(c cache.Cache, logger *zap.Logger) {
+	m.cache = c
+	m.logger = logger
+}
+
+// Account returns the account of a client, created on first use
+func (m *Meter) Account(name string, quota config.UsageQuota) *Account {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	account, ok := m.accounts[name]
+	if !ok {
+		account = &Account{name: name, quota: quota, meter: m}
+		m.accounts[name] = account
+	}
+	return account
+}
+
+// Reports returns the usage of every client that made requests, by name
+func (m *Meter) Reports() []UsageReport {
+	m.mu.Lock()
+	accounts := make([]*Account, 0, len(m.accounts))
+	for _, account := range m.accounts {
+		accounts = append(accounts, account)
+	}
+	m.mu.Unlock()
+
+	reports := make([]UsageReport, len(accounts))
+	for i, account := range accounts {
+		reports[i] = account.Report()
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Name < reports[j].Name })
+	return reports
+}
+
+// periodStart returns the start of the quota period containing t
+func (m *Meter) periodStart(t time.Time) time.Time {
+	t = t.UTC()
+	if m.period == QuotaPeriodMonth {
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func (m *Meter) periodEnd(start time.Time) time.Time {
+	if m.period == QuotaPeriodMonth {
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// Account is the usage of one API client in the current quota period
+type Account struct {
+	name  string
+	quota config.UsageQuota
+	meter *Meter
+
+	mu    sync.Mutex
+	start time.Time // of the period of used
+	used  Usage
+}
+
+// Add charges usage to the account
+func (a *Account) Add(usage Usage) {
+	a.mu.Lock()
+	a.roll()
+	a.used.add(usage)
+	start := a.start
+	a.mu.Unlock()
+	a.meter.share(a.name, start, usage)
+}
+
+// Exhausted returns the first resource whose quota is used up, with the end of the period,
+// or "" while the account is within its quota
+func (a *Account) Exhausted() (string, time.Time) {
+	used, start := a.usage()
+	return a.exhausted(used), a.meter.periodEnd(start)
+}
+
+func (a *Account) exhausted(used Usage) string {
+	switch q := a.quota; {
+	case q.EmbeddingTokens > 0 && used.EmbeddingTokens >= q.EmbeddingTokens:
+		return ResourceEmbeddingTokens
+	case q.QueryTimeSeconds > 0 && used.QueryTime >= time.Duration(q.QueryTimeSeconds)*time.Second:
+		return ResourceQueryTime
+	case q.FilesIndexed > 0 && used.FilesIndexed >= q.FilesIndexed:
+		return ResourceFilesIndexed
+	}
+	return ""
+}
+
+// usage returns the usage in the current period, with its start. Shared counters are
+// preferred over the usage counted in memory.
+func (a *Account) usage() (Usage, time.Time) {
+	a.mu.Lock()
+	a.roll()
+	used, start := a.used, a.start
+	a.mu.Unlock()
+	if shared, ok := a.meter.sharedUsage(a.name, start); ok {
+		used = shared
+	}
+	return used, start
+}
+
+// roll starts a new period when the current one is over; a.mu must be held
+func (a *Account) roll() {
+	if start := a.meter.periodStart(a.meter.now()); !start.Equal(a.start) {
+		a.start = start
+		a.used = Usage{}
+	}
+}
+
+// usageCounterKey names the shared counter of a resource used by a client in the period
+// starting at start
+func usageCounterKey(name string, start time.Time, resource string) string {
+	return "usage:" + start.Format("2006-01-02") + ":" + name + ":" + resource
+}
+
+// share adds usage to the shared counters of a client. Counters expire a day after their
+// period ends.
+func (m *Meter) share(name string, start time.Time, usage Usage) {
+	if m.cache == nil {
+		return
+	}
+	ttl := m.periodEnd(start).Add(24 * time.Hour).Sub(m.now())
+	for resource, amount := range map[string]int64{
+		ResourceEmbeddingTokens: usage.EmbeddingTokens,
+		ResourceQueryTime:       int64(usage.QueryTime),
+		ResourceFilesIndexed:    usage.FilesIndexed,
+	} {
+		if amount == 0 {
+			continue
+		}
+		if _, err := m.cache.IncrBy(context.Background(), usageCounterKey(name, start, resource), amount, ttl); err != nil {
+			m.logger.Warn("Failed to count shared usage", zap.String("client", name), zap.String("resource", resource), zap.Error(err))
+		}
+	}
+}
+
+// sharedUsage reads the shared counters of a client; ok is false without them
+func (m *Meter) sharedUsage(name string, start time.Time) (used Usage, ok bool) {
+	if m.cache == nil {
+		return Usage{}, false
+	}
+	for _, counter := range []struct {
+		resource string
+		value    *int64
+	}{
+		{ResourceEmbeddingTokens, &used.EmbeddingTokens},
+		{ResourceQueryTime, (*int64)(&used.QueryTime)},
+		{ResourceFilesIndexed, &used.FilesIndexed},
+	} {
+		data, found, err := m.cache.Get(context.Background(), usageCounterKey(name, start, counter.resource))
+		if err == nil && found {
+			*counter.value, err = strconv.ParseInt(string(data), 10, 64)
+		}
+		if err != nil {
+			m.logger.Warn("Failed to read shared usage, using this instance's", zap.String("client", name), zap.Error(err))
+			return Usage{}, false
+		}
+	}
+	return used, true
+}
+
+// UsageReport is the usage of a client in the current quota period against its quota
+type UsageReport struct {
+	Name        string        `json:"name"` // "" for requests without a key
+	PeriodStart time.Time     `json:"period_start"`
+	PeriodEnd   time.Time     `json:"period_end"`
+	Used        UsageAmounts  `json:"used"`
+	Quota       *UsageAmounts `json:"quota,omitempty"`     // omitted without limits; 0 is unlimited
+	Exhausted   string        `json:"exhausted,omitempty"` // the resource whose quota is used up
+}
+
+// UsageAmounts are amounts of each resource
+type UsageAmounts struct {
+	EmbeddingTokens int64 `json:"embedding_tokens"`
+	QueryTimeMs     int64 `json:"query_time_ms"`
+	FilesIndexed    int64 `json:"files_indexed"`
+}
+
+// Report returns the usage of the account
+func (a *Account) Report() UsageReport {
+	used, start := a.usage()
+	report := UsageReport{
+		Name:        a.name,
+		PeriodStart: start,
+		PeriodEnd:   a.meter.periodEnd(start),
+		Used: UsageAmounts{
+			EmbeddingTokens: used.EmbeddingTokens,
+			QueryTimeMs:     used.QueryTime.Milliseconds(),
+			FilesIndexed:    used.FilesIndexed,
+		},
+		Exhausted: a.exhausted(used),
+	}
+	if a.quota != (config.UsageQuota{}) {
+		report.Quota = &UsageAmounts{
+			EmbeddingTokens: a.quota.EmbeddingTokens,
+			QueryTimeMs:     a.quota.QueryTimeSeconds * 1000,
+			FilesIndexed:    a.quota.FilesIndexed,
+		}
+	}
+	return report
+}
@@ -0,0 +1,445 @@
+// Package apisurface extracts the public API surface of a library repository (its exported
+// classes, functions and methods, with their signatures and doc comments), stores one
+// snapshot per indexed version and diffs snapshots to show how the API changed.
+package apisurface
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"bot-go/internal/chunk"
+	"bot-go/internal/model"
+	"bot-go/internal/util"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	golang "github.com/tree-sitter/tree-sitter-go/bindings/go"
+	java "github.com/tree-sitter/tree-sitter-java/bindings/go"
+	javascript "github.com/tree-sitter/tree-sitter-javascript/bindings/go"
+	python "github.com/tree-sitter/tree-sitter-python/bindings/go"
+	typescript "github.com/tree-sitter/tree-sitter-typescript/bindings/go"
+	"go.uber.org/zap"
+)
+
+// Kinds of a Symbol
+const (
+	KindClass    = "class"
+	KindFunction = "function"
+	KindMethod   = "method"
+)
+
+// maxFileBytes bounds the size of the files parsed for their API
+const maxFileBytes = 2 << 20
+
+// Surface is the public API of one version of a repository
+type Surface struct {
+	Repo        string    `json:"repo"`
+	Version     string    `json:"version"`          // release version, commit or "working-tree"
+	Commit      string    `json:"commit,omitempty"` // HEAD of the checkout, when it is a git repository
+	GeneratedAt time.Time `json:"generated_at"`
+	Symbols     []Symbol  `json:"symbols"` // ordered by key
+}
+
+// Symbol is an exported class, function or method
+type Symbol struct {
+	// Key identifies the symbol across versions: module, then the qualified name, e.g.
+	// "internal/db#FileVersionRepository.GetStats"
+	Key       string `json:"key"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Class     string `json:"class,omitempty"` // containing class or receiver type of a method
+	Module    string `json:"module"`          // package directory (Go, Java) or module file (Python, JavaScript)
+	Language  string `json:"language"`
+	File      string `json:"file"`
+	Line      int    `json:"line"` // 0-based
+	Signature string `json:"signature"`
+	Doc       string `json:"doc,omitempty"`
+}
+
+// Extract walks the repository checkout at root and collects its public API. Test files and
+// the directories index builds skip (dependencies, build output, hidden directories) are left
+// out.
+func Extract(ctx context.Context, logger *zap.Logger, repo, root string) (*Surface, error) {
+	parser := tree_sitter.NewParser()
+	defer parser.Close()
+
+	var symbols []Symbol
+	err := filepath.WalkDir(root, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if filePath != root && util.ShouldSkipDirectory(filePath) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		language, grammar := grammarFor(filePath)
+		if grammar == nil || !entry.Type().IsRegular() || util.IsTestFile(filePath) {
+			return nil
+		}
+		relPath, err := filepath.Rel(root, filePath)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		if language == "python" && isPrivatePythonModule(relPath) {
+			return nil
+		}
+		if info, err := entry.Info(); err != nil || info.Size() > maxFileBytes {
+			return nil
+		}
+		content, err := os.ReadFile(filePath)
+		if err != nil || util.ContentSkipReason(content) != "" {
+			return nil
+		}
+
+		fileSymbols, err := fileAPI(ctx, parser, grammar, logger, language, relPath, content, true)
+		if err != nil {
+			logger.Debug("Skipping file that does not parse", zap.String("path", relPath), zap.Error(err))
+			return nil
+		}
+		symbols = append(symbols, fileSymbols...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract the API of %s: %w", root, err)
+	}
+
+	// Overloads share a key; they are told apart by their signatures
+	keys := make(map[string]int, len(symbols))
+	for _, s := range symbols {
+		keys[s.Key]++
+	}
+	for i := range symbols {
+		if keys[symbols[i].Key] > 1 {
+			symbols[i].Key += " " + symbols[i].Signature
+		}
+	}
+	sort.SliceStable(symbols, func(i, j int) bool { return symbols[i].Key < symbols[j].Key })
+
+	return &Surface{
+		Repo:        repo,
+		GeneratedAt: time.Now().UTC(),
+		Symbols:     symbols,
+	}, nil
+}
+
+// grammarFor returns the language and tree-sitter grammar of a file, or a nil grammar for
+// languages without API extraction
+func grammarFor(filePath string) (string, *tree_sitter.Language) {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".go":
+		return "go", tree_sitter.NewLanguage(golang.Language())
+	case ".py":
+		return "python", tree_sitter.NewLanguage(python.Language())
+	case ".java":
+		return "java", tree_sitter.NewLanguage(java.Language())
+	case ".js", ".jsx", ".mjs":
+		return "javascript", tree_sitter.NewLanguage(javascript.Language())
+	case ".ts":
+		return "typescript", tree_sitter.NewLanguage(typescript.LanguageTypescript())
+	case ".tsx":
+		return "typescript", tree_sitter.NewLanguage(typescript.LanguageTSX())
+	}
+	return "", nil
+}
+
+// isPrivatePythonModule reports whether a module or one of its packages is underscore-prefixed
+// (e.g. mylib/_impl/util.py); package __init__ files are public
+func isPrivatePythonModule(relPath string) bool {
+	for _, part := range strings.Split(relPath, "/") {
+		if strings.HasPrefix(part, "_") && part != "__init__.py" {
+			return true
+		}
+	}
+	return false
+}
+
+// Functions parses one file and returns all of its functions and methods, exported or not,
+// with their signatures and doc comments. Functions declared inside other functions are left
+// out. A file in a language without API extraction has none.
+func Functions(ctx context.Context, logger *zap.Logger, relPath string, content []byte) ([]Symbol, error) {
+	language, grammar := grammarFor(relPath)
+	if grammar == nil {
+		return nil, nil
+	}
+	parser := tree_sitter.NewParser()
+	defer parser.Close()
+
+	symbols, err := fileAPI(ctx, parser, grammar, logger, language, relPath, content, false)
+	if err != nil {
+		return nil, err
+	}
+	functions := symbols[:0]
+	for _, s := range symbols {
+		if s.Kind != KindClass {
+			functions = append(functions, s)
+		}
+	}
+	return functions, nil
+}
+
+// fileAPI parses one file and returns its symbols, only the exported ones if exportedOnly
+func fileAPI(ctx context.Context, parser *tree_sitter.Parser, grammar *tree_sitter.Language, logger *zap.Logger, language, relPath string, content []byte, exportedOnly bool) ([]Symbol, error) {
+	if err := parser.SetLanguage(grammar); err != nil {
+		return nil, fmt.Errorf("failed to set parser language: %w", err)
+	}
+	tree := parser.Parse(content, nil)
+	if tree == nil {
+		return nil, fmt.Errorf("failed to parse file")
+	}
+	defer tree.Close()
+
+	visitor := chunk.NewChunkVisitor(logger, language, relPath, content, 0, 0)
+	visitor.TraverseNode(ctx, tree.RootNode(), nil)
+
+	lines := strings.Split(string(content), "\n")
+	var classes, functions []*model.CodeChunk
+	for _, c := range visitor.GetChunks() {
+		switch c.ChunkType {
+		case model.ChunkTypeClass:
+			classes = append(classes, c)
+		case model.ChunkTypeFunction:
+			functions = append(functions, c)
+		}
+	}
+	exportedClass := make(map[string]bool, len(classes))
+
+	module := moduleOf(language, relPath)
+	var symbols []Symbol
+	for _, c := range classes {
+		if !classExported(language, c, lines) {
+			if exportedOnly {
+				continue
+			}
+		} else {
+			exportedClass[c.Name] = true
+		}
+		symbols = append(symbols, newSymbol(KindClass, module, "", c, classSignature(language, c), lines))
+	}
+	for _, f := range functions {
+		if nestedInFunction(f, functions) {
+			continue
+		}
+		class, signature := functionSignature(language, f)
+		kind := KindFunction
+		if class != "" {
+			kind = KindMethod
+		}
+		if exportedOnly && !functionExported(language, f, class, exportedClass, lines) {
+			continue
+		}
+		symbols = append(symbols, newSymbol(kind, module, class, f, signature, lines))
+	}
+	return symbols, nil
+}
+
+func newSymbol(kind, module, class string, c *model.CodeChunk, signature string, lines []string) Symbol {
+	qualified := c.Name
+	if class != "" {
+		qualified = class + "." + c.Name
+	}
+	doc := strings.TrimSpace(c.Docstring)
+	if doc == "" {
+		doc = commentAbove(lines, c.StartLine)
+	}
+	return Symbol{
+		Key:       module + "#" + qualified,
+		Kind:      kind,
+		Name:      c.Name,
+		Class:     class,
+		Module:    module,
+		Language:  c.Language,
+		File:      c.FilePath,
+		Line:      c.StartLine,
+		Signature: signature,
+		Doc:       doc,
+	}
+}
+
+// moduleOf names the module a file belongs to: its package directory in Go and Java, the
+// file itself (without extension) in Python and JavaScript
+func moduleOf(language, relPath string) string {
+	switch language {
+	case "go", "java":
+		return path.Dir(relPath)
+	}
+	module := strings.TrimSuffix(relPath, path.Ext(relPath))
+	if language == "python" && path.Base(module) == "__init__" {
+		return path.Dir(module)
+	}
+	return module
+}
+
+// nestedInFunction reports whether f is declared inside another function, so it is local
+func nestedInFunction(f *model.CodeChunk, functions []*model.CodeChunk) bool {
+	for _, outer := range functions {
+		if outer != f && outer.Range.ContainsRange(&f.Range) && outer.Range != f.Range {
+			return true
+		}
+	}
+	return false
+}
+
+// classSignature is the declaration line of a class without its body
+func classSignature(language string, c *model.CodeChunk) string {
+	header := firstLine(c.Content)
+	header = strings.TrimSuffix(strings.TrimSpace(header), "{}")
+	header = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(header), "{"))
+	header = strings.TrimSuffix(header, ":")
+	if language == "go" {
+		return "type " + header // a Go type_spec does not include the keyword
+	}
+	return header
+}
+
+// functionSignature returns the class of a method and the signature of a function or method.
+// Go methods get their class from the receiver type.
+func functionSignature(language string, f *model.CodeChunk) (class, signature string) {
+	switch language {
+	case "go":
+		if receiver, ok := goReceiver(f.Content); ok {
+			return goReceiverType(receiver), "func (" + receiver + ") " + f.Signature
+		}
+		return "", "func " + f.Signature
+	case "python":
+		keyword := "def "
+		if strings.HasPrefix(f.Content, "async ") {
+			keyword = "async def "
+		}
+		return f.ClassName, keyword + f.Signature
+	case "javascript", "typescript":
+		if f.ClassName == "" {
+			return "", "function " + f.Signature
+		}
+	}
+	return f.ClassName, f.Signature
+}
+
+// goReceiver returns the receiver list of a Go method declaration, e.g. "r *Repo"
+func goReceiver(content string) (string, bool) {
+	if !strings.HasPrefix(content, "func (") {
+		return "", false
+	}
+	end := strings.Index(content, ")")
+	if end < 0 {
+		return "", false
+	}
+	return strings.TrimSpace(content[len("func ("):end]), true
+}
+
+// goReceiverType returns the type name of a receiver: "r *Repo[T]" gives "Repo"
+func goReceiverType(receiver string) string {
+	fields := strings.Fields(receiver)
+	if len(fields) == 0 {
+		return ""
+	}
+	typ := strings.TrimPrefix(fields[len(fields)-1], "*")
+	if i := strings.Index(typ, "["); i >= 0 {
+		typ = typ[:i]
+	}
+	return typ
+}
+
+func classExported(language string, c *model.CodeChunk, lines []string) bool {
+	switch language {
+	case "go":
+		return isUpper(c.Name)
+	case "python":
+		return !strings.HasPrefix(c.Name, "_")
+	case "java":
+		return strings.Contains(" "+firstLine(c.Content), " public ")
+	case "javascript", "typescript":
+		return exportedDeclaration(lines, c.StartLine)
+	}
+	return false
+}
+
+func functionExported(language string, f *model.CodeChunk, class string, exportedClass map[string]bool, lines []string) bool {
+	switch language {
+	case "go":
+		return isUpper(f.Name) && (class == "" || isUpper(class))
+	case "python":
+		if class == "" {
+			return !strings.HasPrefix(f.Name, "_")
+		}
+		// Dunder methods such as __init__ are part of a class's interface
+		dunder := strings.HasPrefix(f.Name, "__") && strings.HasSuffix(f.Name, "__")
+		return exportedClass[class] && (dunder || !strings.HasPrefix(f.Name, "_"))
+	case "java":
+		if !exportedClass[class] {
+			return false
+		}
+		// Interface members are public without the modifier
+		return strings.Contains(" "+f.Signature+" ", " public ") || !strings.Contains(f.Content, "{")
+	case "javascript", "typescript":
+		if class == "" {
+			return exportedDeclaration(lines, f.StartLine)
+		}
+		private := strings.HasPrefix(f.Content, "private ") || strings.HasPrefix(f.Content, "protected ")
+		return exportedClass[class] && !private && !strings.HasPrefix(f.Name, "_") && !strings.HasPrefix(f.Name, "#")
+	}
+	return false
+}
+
+// exportedDeclaration reports whether the JavaScript declaration starting on line is
+// exported (export function f, export default class C, ...)
+func exportedDeclaration(lines []string, line int) bool {
+	return line >= 0 && line < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[line]), "export ")
+}
+
+// commentAbove returns the text of the comment block directly above line, without comment
+// markers. Annotations and decorators between the comment and the declaration are skipped.
+func commentAbove(lines []string, line int) string {
+	i := line - 1
+	for i >= 0 && strings.HasPrefix(strings.TrimSpace(lines[i]), "@") {
+		i--
+	}
+	var block []string
+	for ; i >= 0; i-- {
+		text := strings.TrimSpace(lines[i])
+		if !strings.HasPrefix(text, "//") && !strings.HasPrefix(text, "/*") && !strings.HasPrefix(text, "*") && !strings.HasPrefix(text, "#") {
+			break
+		}
+		block = append(block, text)
+	}
+	for l, r := 0, len(block)-1; l < r; l, r = l+1, r-1 {
+		block[l], block[r] = block[r], block[l]
+	}
+
+	var doc []string
+	for _, text := range block {
+		for _, marker := range []string{"/**", "/*", "*/", "//", "#", "*"} {
+			text = strings.TrimPrefix(text, marker)
+		}
+		text = strings.TrimSpace(strings.TrimSuffix(text, "*/"))
+		if text != "" || len(doc) > 0 {
+			doc = append(doc, text)
+		}
+	}
+	return strings.TrimSpace(strings.Join(doc, "\n"))
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+func isUpper(name string) bool {
+	r, _ := utf8.DecodeRuneInString(name)
+	return unicode.IsUpper(r)
+}
@@ -0,0 +1,255 @@
+package apisurface
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"bot-go/internal/apperrors"
+	"bot-go/internal/storage"
+
+	"go.uber.org/zap"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for rel, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestExtract(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"store/store.go": `package store
+
+// Store keeps values
+type Store struct{}
+
+type cache struct{}
+
+// Get returns the value of key
+func (s *Store) Get(key string) (string, error) {
+	return "", nil
+}
+
+func (c *cache) Get(key string) string { return "" }
+
+func New() *Store { return nil }
+
+func helper() {}
+`,
+		"store/store_test.go": "package store\n\nfunc TestGet() {}\n",
+		"py/client.py": `class Client:
+    """Talks to the server."""
+
+    def __init__(self, url):
+        self.url = url
+
+    def fetch(self, path):
+        def local():
+            pass
+        return local
+
+    def _retry(self):
+        pass
+
+
+def connect(url) -> Client:
+    return Client(url)
+`,
+		"py/_impl.py": "def internal():\n    pass\n",
+	})
+
+	surface, err := Extract(context.Background(), zap.NewNop(), "lib", root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]Symbol)
+	for _, s := range surface.Symbols {
+		got[s.Key] = s
+	}
+
+	want := map[string]string{
+		"store#Store":               "type Store struct",
+		"store#Store.Get":           "func (s *Store) Get(key string) (string, error)",
+		"store#New":                 "func New() *Store",
+		"py/client#Client":          "class Client",
+		"py/client#Client.__init__": "def __init__(self, url)",
+		"py/client#Client.fetch":    "def fetch(self, path)",
+		"py/client#connect":         "def connect(url) -> Client",
+	}
+	for key, signature := range want {
+		s, ok := got[key]
+		if !ok {
+			t.Errorf("missing %s", key)
+			continue
+		}
+		if s.Signature != signature {
+			t.Errorf("%s signature = %q, want %q", key, s.Signature, signature)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %d symbols, want %d: %+v", len(got), len(want), surface.Symbols)
+	}
+	if doc := got["store#Store.Get"].Doc; doc != "Get returns the value of key" {
+		t.Errorf("Go doc = %q", doc)
+	}
+	if doc := got["py/client#Client"].Doc; doc != "Talks to the server." {
+		t.Errorf("Python doc = %q", doc)
+	}
+	if s := got["store#Store.Get"]; s.Kind != KindMethod || s.Class != "Store" {
+		t.Errorf("Store.Get = %+v", s)
+	}
+}
+
+func TestFunctions(t *testing.T) {
+	content := `package store
+
+type cache struct{}
+
+// Get reads the cache
+func (c *cache) Get(key string) string {
+	lookup := func() string { return "" }
+	return lookup()
+}
+
+func helper() {}
+`
+	symbols, err := Functions(context.Background(), zap.NewNop(), "store/cache.go", []byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]Symbol)
+	for _, s := range symbols {
+		got[s.Key] = s
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %+v, want cache.Get and helper", symbols)
+	}
+	if s := got["store#cache.Get"]; s.Signature != "func (c *cache) Get(key string) string" || s.Doc != "Get reads the cache" || s.Line != 5 {
+		t.Errorf("cache.Get = %+v", s)
+	}
+	if s := got["store#helper"]; s.Kind != KindFunction || s.Signature != "func helper()" {
+		t.Errorf("helper = %+v", s)
+	}
+
+	if symbols, err := Functions(context.Background(), zap.NewNop(), "README.md", []byte("# x")); symbols != nil || err != nil {
+		t.Errorf("Functions of README.md = %v, %v", symbols, err)
+	}
+}
+
+func TestStoreAndCompare(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	store := storage.NewLocal(root)
+	if _, err := Load(ctx, store, "lib", ""); !errors.Is(err, apperrors.ErrNotFound) {
+		t.Fatalf("Load without snapshots: err = %v, want ErrNotFound", err)
+	}
+
+	v1 := &Surface{Repo: "lib", Version: "v1.0.0", GeneratedAt: time.Now().Add(-time.Hour), Symbols: []Symbol{
+		{Key: "a#Get", Signature: "func Get() string"},
+		{Key: "a#Put", Signature: "func Put(v string)", Doc: "Put stores v"},
+		{Key: "a#Old", Signature: "func Old()"},
+	}}
+	v2 := &Surface{Repo: "lib", Version: "release/v2", GeneratedAt: time.Now(), Symbols: []Symbol{
+		{Key: "a#Get", Signature: "func Get() string", Line: 40}, // moved only
+		{Key: "a#Put", Signature: "func Put(v string)", Doc: "Put stores v durably"},
+		{Key: "a#New", Signature: "func New()"},
+	}}
+	for _, s := range []*Surface{v2, v1} {
+		if err := Save(ctx, store, "lib", s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Snapshots of another repository whose name starts the same are not listed
+	if err := Save(ctx, store, "lib2", v1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "apisurface", "lib", "release_v2.json")); err != nil {
+		t.Errorf("snapshot not stored under the work directory layout: %v", err)
+	}
+
+	versions, err := Versions(ctx, store, "lib")
+	if err != nil || len(versions) != 2 || versions[0].Version != "v1.0.0" || versions[1].Symbols != 3 {
+		t.Fatalf("Versions = %+v, %v", versions, err)
+	}
+	latest, err := Load(ctx, store, "lib", "")
+	if err != nil || latest.Version != "release/v2" {
+		t.Fatalf("latest = %+v, %v", latest, err)
+	}
+
+	diff := Compare(v1, latest)
+	if len(diff.Added) != 1 || diff.Added[0].Key != "a#New" {
+		t.Errorf("Added = %+v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].Key != "a#Old" || !diff.Breaking {
+		t.Errorf("Removed = %+v, breaking = %v", diff.Removed, diff.Breaking)
+	}
+	if len(diff.Changed) != 1 || !diff.Changed[0].DocChanged || diff.Changed[0].SignatureChanged {
+		t.Errorf("Changed = %+v", diff.Changed)
+	}
+}
+
+func TestFindBreakingChanges(t *testing.T) {
+	v1 := &Surface{Repo: "lib", Version: "v1", Symbols: []Symbol{
+		{Key: "db#Store", Kind: KindClass, Name: "Store", Module: "db", Language: "go", Signature: "type Store struct"},
+		{Key: "db#Store.Get", Kind: KindMethod, Name: "Get", Class: "Store", Module: "db", Language: "go", Signature: "func (s *Store) Get(key string) string"},
+		{Key: "db#Open", Kind: KindFunction, Name: "Open", Module: "db", Language: "go", Signature: "func Open(path string) (*Store, error)"},
+		{Key: "db#Close", Kind: KindFunction, Name: "Close", Module: "db", Language: "go", Signature: "func Close()"},
+		{Key: "db#Put", Kind: KindFunction, Name: "Put", Module: "db", Language: "go", File: "db/put.go", Line: 9, Signature: "func Put(v string)", Doc: "a"},
+	}}
+	v2 := &Surface{Repo: "lib", Version: "v2", Symbols: []Symbol{
+		{Key: "db#KV", Kind: KindClass, Name: "KV", Module: "db", Language: "go", Signature: "type KV struct"},
+		{Key: "db#KV.Get", Kind: KindMethod, Name: "Get", Class: "KV", Module: "db", Language: "go", Signature: "func (s *KV) Get(key string) string"},
+		{Key: "db#Connect", Kind: KindFunction, Name: "Connect", Module: "db", Language: "go", Signature: "func Connect(path string) (*Store, error)"},
+		{Key: "db#Put", Kind: KindFunction, Name: "Put", Module: "db", Language: "go", File: "db/put.go", Line: 9, Signature: "func Put(v string, sync bool)", Doc: "a"},
+		{Key: "db#Dump", Kind: KindFunction, Name: "Dump", Module: "db", Language: "go", Signature: "func Dump(w io.Writer)"},
+	}}
+
+	report := FindBreakingChanges(Compare(v1, v2))
+	kinds := make(map[string]string)
+	for _, change := range report.Breaking {
+		kinds[change.Key] = change.Kind + " " + change.NewKey
+	}
+	want := map[string]string{
+		"db#Store":     "renamed db#KV",
+		"db#Store.Get": "renamed db#KV.Get",
+		"db#Open":      "renamed db#Connect",
+		"db#Close":     "removed ",
+		"db#Put":       "signature_changed ",
+	}
+	if len(kinds) != len(want) {
+		t.Errorf("breaking changes = %v, want %v", kinds, want)
+	}
+	for key, kind := range want {
+		if kinds[key] != kind {
+			t.Errorf("%s: %q, want %q", key, kinds[key], kind)
+		}
+	}
+	if report.Added != 1 || !report.HasBreaking {
+		t.Errorf("added = %d, has_breaking = %v", report.Added, report.HasBreaking)
+	}
+
+	var out strings.Builder
+	if err := WriteBreakingReport(&out, report, FormatGitHub); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "::error file=db/put.go,line=10,title=API signature changed::signature of function Put changed") {
+		t.Errorf("github annotations:\n%s", out.String())
+	}
+	if err := WriteBreakingReport(&out, report, "xml"); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
@@ -0,0 +1,217 @@
+package apisurface
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Kinds of a BreakingChange
+const (
+	BreakingRemoved          = "removed"
+	BreakingRenamed          = "renamed"
+	BreakingSignatureChanged = "signature_changed"
+)
+
+// Report formats accepted by WriteBreakingReport
+const (
+	FormatText   = "text"
+	FormatJSON   = "json"
+	FormatGitHub = "github" // GitHub Actions workflow commands, shown as annotations on the diff
+)
+
+// BreakingChange is a change of the API that can break code using the library
+type BreakingChange struct {
+	Kind    string  `json:"kind"`
+	Key     string  `json:"key"`               // key in the old version
+	NewKey  string  `json:"new_key,omitempty"` // key in the new version, for renamed symbols
+	Before  Symbol  `json:"before"`
+	After   *Symbol `json:"after,omitempty"` // nil for removed symbols
+	Message string  `json:"message"`
+}
+
+// BreakingReport lists the breaking changes between two versions of an API
+type BreakingReport struct {
+	Repo        string           `json:"repo"`
+	From        string           `json:"from"`
+	To          string           `json:"to"`
+	Breaking    []BreakingChange `json:"breaking_changes"`
+	Added       int              `json:"added"`    // new symbols, which break nothing
+	DocOnly     int              `json:"doc_only"` // symbols whose doc comment alone changed
+	HasBreaking bool             `json:"has_breaking"`
+}
+
+// FindBreakingChanges classifies the changes of a diff. A removed symbol is reported as
+// renamed when an added symbol of the same kind, module and class has the same signature
+// apart from its name. Methods follow the renames of their class.
+func FindBreakingChanges(diff *Diff) *BreakingReport {
+	report := &BreakingReport{
+		Repo:     diff.Repo,
+		From:     diff.From,
+		To:       diff.To,
+		Breaking: []BreakingChange{},
+	}
+
+	// Classes first, so that the methods of a renamed class can be matched under its new name
+	matched := make(map[string]bool, len(diff.Added))
+	renamedClasses := make(map[string]string)
+	var removed []Symbol
+	for _, pass := range []bool{true, false} {
+		for _, old := range diff.Removed {
+			if (old.Kind == KindClass) != pass {
+				continue
+			}
+			renamed, ok := findRename(old, diff.Added, matched, renamedClasses)
+			if !ok {
+				removed = append(removed, old)
+				continue
+			}
+			matched[renamed.Key] = true
+			if old.Kind == KindClass {
+				renamedClasses[classKey(old.Module, old.Name)] = renamed.Name
+			}
+			after := renamed
+			report.Breaking = append(report.Breaking, BreakingChange{
+				Kind:    BreakingRenamed,
+				Key:     old.Key,
+				NewKey:  renamed.Key,
+				Before:  old,
+				After:   &after,
+				Message: fmt.Sprintf("%s %s was renamed to %s", old.Kind, qualifiedName(old), qualifiedName(renamed)),
+			})
+		}
+	}
+	for _, old := range removed {
+		report.Breaking = append(report.Breaking, BreakingChange{
+			Kind:    BreakingRemoved,
+			Key:     old.Key,
+			Before:  old,
+			Message: fmt.Sprintf("%s %s was removed", old.Kind, qualifiedName(old)),
+		})
+	}
+	for _, change := range diff.Changed {
+		if !change.SignatureChanged {
+			report.DocOnly++
+			continue
+		}
+		after := change.After
+		report.Breaking = append(report.Breaking, BreakingChange{
+			Kind:    BreakingSignatureChanged,
+			Key:     change.Key,
+			Before:  change.Before,
+			After:   &after,
+			Message: fmt.Sprintf("signature of %s %s changed from %q to %q", change.After.Kind, qualifiedName(change.After), change.Before.Signature, change.After.Signature),
+		})
+	}
+	report.Added = len(diff.Added) - len(matched)
+	report.HasBreaking = len(report.Breaking) > 0
+	return report
+}
+
+// findRename returns the first unmatched added symbol that old could have been renamed to
+func findRename(old Symbol, added []Symbol, matched map[string]bool, renamedClasses map[string]string) (Symbol, bool) {
+	class := old.Class
+	if renamed, ok := renamedClasses[classKey(old.Module, old.Class)]; ok {
+		class = renamed
+	}
+	shape := signatureShape(old)
+	for _, s := range added {
+		if matched[s.Key] || s.Kind != old.Kind || s.Module != old.Module || s.Language != old.Language || s.Class != class {
+			continue
+		}
+		if s.Name == old.Name && class != old.Class {
+			return s, true // a method that moved with its class
+		}
+		if signatureShape(s) == shape {
+			return s, true
+		}
+	}
+	return Symbol{}, false
+}
+
+// signatureShape is the signature of a symbol with its name blanked out. The class name of a
+// Go method is blanked too, as the receiver names it.
+func signatureShape(s Symbol) string {
+	shape := blankWord(s.Signature, s.Name)
+	if s.Class != "" {
+		shape = blankWord(shape, s.Class)
+	}
+	return shape
+}
+
+// blankWord replaces the first whole-word occurrence of word in text with "_"
+func blankWord(text, word string) string {
+	if word == "" {
+		return text
+	}
+	loc := regexp.MustCompile(`\b` + regexp.QuoteMeta(word) + `\b`).FindStringIndex(text)
+	if loc == nil {
+		return text
+	}
+	return text[:loc[0]] + "_" + text[loc[1]:]
+}
+
+func classKey(module, class string) string {
+	return module + "#" + class
+}
+
+func qualifiedName(s Symbol) string {
+	if s.Class != "" {
+		return s.Class + "." + s.Name
+	}
+	return s.Name
+}
+
+// WriteBreakingReport writes the report in the given format. The github format emits one
+// error annotation per breaking change, at the symbol's location in the new version (or in
+// the old one for removed symbols), followed by a notice summarizing the report.
+func WriteBreakingReport(w io.Writer, r *BreakingReport, format string) error {
+	switch format {
+	case FormatText, "":
+		fmt.Fprintf(w, "%s: %s -> %s: %d breaking changes, %d added, %d doc-only changes\n",
+			r.Repo, r.From, r.To, len(r.Breaking), r.Added, r.DocOnly)
+		for _, change := range r.Breaking {
+			file, line := changeLocation(change)
+			fmt.Fprintf(w, "  %-17s %s:%d  %s\n", change.Kind, file, line, change.Message)
+		}
+		return nil
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(r)
+	case FormatGitHub:
+		for _, change := range r.Breaking {
+			file, line := changeLocation(change)
+			fmt.Fprintf(w, "::error file=%s,line=%d,title=%s::%s\n",
+				escapeProperty(file), line, escapeProperty("API "+strings.ReplaceAll(change.Kind, "_", " ")), escapeData(change.Message))
+		}
+		fmt.Fprintf(w, "::notice title=%s::%s\n", escapeProperty("API diff"),
+			escapeData(fmt.Sprintf("%s %s -> %s: %d breaking changes, %d added", r.Repo, r.From, r.To, len(r.Breaking), r.Added)))
+		return nil
+	}
+	return fmt.Errorf("unknown report format %q (want %s, %s or %s)", format, FormatText, FormatJSON, FormatGitHub)
+}
+
+// changeLocation returns the file and 1-based line a change is reported at
+func changeLocation(change BreakingChange) (string, int) {
+	if change.After != nil {
+		return change.After.File, change.After.Line + 1
+	}
+	return change.Before.File, change.Before.Line + 1
+}
+
+// escapeData escapes the message of a workflow command
+func escapeData(s string) string {
+	s = strings.ReplaceAll(s, "%", "%25")
+	s = strings.ReplaceAll(s, "\r", "%0D")
+	return strings.ReplaceAll(s, "\n", "%0A")
+}
+
+// escapeProperty escapes a property value of a workflow command
+func escapeProperty(s string) string {
+	s = escapeData(s)
+	s = strings.ReplaceAll(s, ":", "%3A")
+	return strings.ReplaceAll(s, ",", "%2C")
+}
@@ -0,0 +1,206 @@
+package apisurface
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"bot-go/internal/apperrors"
+	"bot-go/internal/storage"
+)
+
+// unsafeFileChars are replaced in version names to form storage keys (release tags may
+// contain slashes)
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// Prefix returns the storage key prefix of the API snapshots of the repository. With the
+// local backend they are files under <work_dir>/apisurface/<repo>.
+func Prefix(repo string) string {
+	return "apisurface/" + repo + "/"
+}
+
+// VersionInfo describes a stored snapshot
+type VersionInfo struct {
+	Version     string    `json:"version"`
+	Commit      string    `json:"commit,omitempty"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Symbols     int       `json:"symbols"`
+}
+
+// Save stores the snapshot of a repository under its version, replacing an earlier snapshot
+// of that version
+func Save(ctx context.Context, store storage.Store, repo string, surface *Surface) error {
+	data, err := json.MarshalIndent(surface, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode API surface: %w", err)
+	}
+	err = store.Put(ctx, versionKey(repo, surface.Version), func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write API surface: %w", err)
+	}
+	return nil
+}
+
+// Load returns the snapshot of a version, or of the latest version when version is empty
+func Load(ctx context.Context, store storage.Store, repo, version string) (*Surface, error) {
+	if version == "" {
+		versions, err := Versions(ctx, store, repo)
+		if err != nil {
+			return nil, err
+		}
+		if len(versions) == 0 {
+			return nil, apperrors.NotFound("API surface", repo)
+		}
+		version = versions[len(versions)-1].Version
+	}
+
+	surface, err := read(ctx, store, versionKey(repo, version))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, apperrors.NotFound("API surface version", version)
+	}
+	return surface, err
+}
+
+// Versions lists the stored snapshots of a repository, oldest first
+func Versions(ctx context.Context, store storage.Store, repo string) ([]VersionInfo, error) {
+	keys, err := store.List(ctx, Prefix(repo))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API surfaces: %w", err)
+	}
+
+	var versions []VersionInfo
+	for _, key := range keys {
+		// Snapshots are directly under the prefix
+		if name := strings.TrimPrefix(key, Prefix(repo)); strings.Contains(name, "/") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		surface, err := read(ctx, store, key)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, VersionInfo{
+			Version:     surface.Version,
+			Commit:      surface.Commit,
+			GeneratedAt: surface.GeneratedAt,
+			Symbols:     len(surface.Symbols),
+		})
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].GeneratedAt.Before(versions[j].GeneratedAt)
+	})
+	return versions, nil
+}
+
+// PreviousVersion returns the version stored before the given one, or "" if it is the oldest
+func PreviousVersion(ctx context.Context, store storage.Store, repo, version string) (string, error) {
+	versions, err := Versions(ctx, store, repo)
+	if err != nil {
+		return "", err
+	}
+	for i, v := range versions {
+		if v.Version == version {
+			if i == 0 {
+				return "", nil
+			}
+			return versions[i-1].Version, nil
+		}
+	}
+	return "", apperrors.NotFound("API surface version", version)
+}
+
+// read decodes the snapshot stored under key. A missing snapshot is an error wrapping
+// fs.ErrNotExist.
+func read(ctx context.Context, store storage.Store, key string) (*Surface, error) {
+	r, err := store.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to read API surface: %w", err)
+	}
+	defer r.Close()
+	var surface Surface
+	if err := json.NewDecoder(r).Decode(&surface); err != nil {
+		return nil, fmt.Errorf("failed to parse API surface %s: %w", path.Base(key), err)
+	}
+	return &surface, nil
+}
+
+func versionKey(repo, version string) string {
+	return Prefix(repo) + unsafeFileChars.ReplaceAllString(version, "_") + ".json"
+}
+
+// Diff lists how the API changed from one snapshot to another
+type Diff struct {
+	Repo    string   `json:"repo"`
+	From    string   `json:"from"`
+	To      string   `json:"to"`
+	Added   []Symbol `json:"added"`
+	Removed []Symbol `json:"removed"`
+	Changed []Change `json:"changed"`
+	// Breaking is set when a symbol was removed or its signature changed
+	Breaking bool `json:"breaking"`
+}
+
+// Change is a symbol whose signature or doc comment differs between two snapshots
+type Change struct {
+	Key              string `json:"key"`
+	Before           Symbol `json:"before"`
+	After            Symbol `json:"after"`
+	SignatureChanged bool   `json:"signature_changed"`
+	DocChanged       bool   `json:"doc_changed"`
+}
+
+// Compare diffs two snapshots by symbol key. Moving a symbol within its module is not a change.
+func Compare(from, to *Surface) *Diff {
+	diff := &Diff{
+		Repo:    to.Repo,
+		From:    from.Version,
+		To:      to.Version,
+		Added:   []Symbol{},
+		Removed: []Symbol{},
+		Changed: []Change{},
+	}
+	before := make(map[string]Symbol, len(from.Symbols))
+	for _, s := range from.Symbols {
+		before[s.Key] = s
+	}
+	after := make(map[string]bool, len(to.Symbols))
+	for _, s := range to.Symbols {
+		after[s.Key] = true
+		old, ok := before[s.Key]
+		if !ok {
+			diff.Added = append(diff.Added, s)
+			continue
+		}
+		change := Change{
+			Key:              s.Key,
+			Before:           old,
+			After:            s,
+			SignatureChanged: old.Signature != s.Signature,
+			DocChanged:       old.Doc != s.Doc,
+		}
+		if change.SignatureChanged || change.DocChanged {
+			diff.Changed = append(diff.Changed, change)
+			diff.Breaking = diff.Breaking || change.SignatureChanged
+		}
+	}
+	for _, s := range from.Symbols {
+		if !after[s.Key] {
+			diff.Removed = append(diff.Removed, s)
+			diff.Breaking = true
+		}
+	}
+	return diff
+}
@@ -0,0 +1,62 @@
+// Package apperrors defines the error taxonomy shared by the services and controllers.
+// Services wrap these sentinels (fmt.Errorf with %w, or the helpers below) so handlers can
+// map failures to status codes with errors.Is instead of matching on message text.
+package apperrors
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNotFound is the parent of every "not found" error
+	ErrNotFound = errors.New("not found")
+	// ErrRepoNotFound means the repository is not configured
+	ErrRepoNotFound = fmt.Errorf("repository %w", ErrNotFound)
+	// ErrProjectNotFound means the project is not configured
+	ErrProjectNotFound = fmt.Errorf("project %w", ErrNotFound)
+	// ErrNodeNotFound means a code graph node (file, class, function, ...) does not exist
+	ErrNodeNotFound = fmt.Errorf("node %w", ErrNotFound)
+	// ErrBackendUnavailable means a backing store (Neo4j, Qdrant, MySQL, Ollama) is unreachable or not configured
+	ErrBackendUnavailable = errors.New("backend unavailable")
+	// ErrEmbeddingUnavailable means the embedding provider failed, so vector search cannot run
+	ErrEmbeddingUnavailable = fmt.Errorf("embedding provider %w", ErrBackendUnavailable)
+	// ErrUnsupportedLanguage means the language has no parser, chunker or language server
+	ErrUnsupportedLanguage = errors.New("unsupported language")
+	// ErrInvalidArgument means the caller supplied an invalid value
+	ErrInvalidArgument = errors.New("invalid argument")
+	// ErrConflict means the operation conflicts with one in progress, e.g. a build of the
+	// same repository on another server instance
+	ErrConflict = errors.New("conflict")
+)
+
+// notFoundError keeps the existing "<kind> not found: <key>" messages while matching a sentinel
+type notFoundError struct {
+	kind   string
+	key    any
+	target error
+}
+
+func (e *notFoundError) Error() string {
+	return fmt.Sprintf("%s not found: %v", e.kind, e.key)
+}
+
+func (e *notFoundError) Unwrap() error {
+	return e.target
+}
+
+// NodeNotFound returns an error matching ErrNodeNotFound, e.g. NodeNotFound("class", id)
+func NodeNotFound(kind string, key any) error {
+	return &notFoundError{kind: kind, key: key, target: ErrNodeNotFound}
+}
+
+// NotFound returns an error matching ErrNotFound for entities that are not graph nodes
+// (chunks, corpus files, ...)
+func NotFound(kind string, key any) error {
+	return &notFoundError{kind: kind, key: key, target: ErrNotFound}
+}
+
+// Unavailable wraps err from a backend so it matches ErrBackendUnavailable
+func Unavailable(backend string, err error) error {
+	return fmt.Errorf("%s %w: %w", backend, ErrBackendUnavailable, err)
+}
@@ -0,0 +1,29 @@
+package apperrors
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestErrorTaxonomy(t *testing.T) {
+	err := fmt.Errorf("lookup failed: %w", NodeNotFound("class", 42))
+	if err.Error() != "lookup failed: class not found: 42" {
+		t.Errorf("unexpected message: %s", err)
+	}
+	if !errors.Is(err, ErrNodeNotFound) || !errors.Is(err, ErrNotFound) {
+		t.Error("expected NodeNotFound to match ErrNodeNotFound and ErrNotFound")
+	}
+	if errors.Is(err, ErrRepoNotFound) {
+		t.Error("NodeNotFound should not match ErrRepoNotFound")
+	}
+	if !errors.Is(ErrRepoNotFound, ErrNotFound) {
+		t.Error("expected ErrRepoNotFound to match ErrNotFound")
+	}
+
+	cause := errors.New("connection refused")
+	unavailable := Unavailable("qdrant", cause)
+	if !errors.Is(unavailable, ErrBackendUnavailable) || !errors.Is(unavailable, cause) {
+		t.Errorf("expected Unavailable to wrap both sentinel and cause: %v", unavailable)
+	}
+}
@@ -0,0 +1,191 @@
+// Package bench measures indexing throughput on a synthetic repository and compares runs
+// against a stored baseline so regressions in the pipeline show up early.
+package bench
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Result is the outcome of one benchmark run
+type Result struct {
+	Files         int           `json:"files"`
+	Nodes         int64         `json:"nodes"`
+	Embeddings    int64         `json:"embeddings"`
+	Duration      time.Duration `json:"duration_ns"`
+	PeakHeapBytes uint64        `json:"peak_heap_bytes"`
+	GoVersion     string        `json:"go_version"`
+	RecordedAt    time.Time     `json:"recorded_at"`
+}
+
+// FilesPerSec is the file indexing throughput
+func (r *Result) FilesPerSec() float64 { return r.rate(int64(r.Files)) }
+
+// NodesPerSec is the graph node creation throughput
+func (r *Result) NodesPerSec() float64 { return r.rate(r.Nodes) }
+
+// EmbeddingsPerSec is the chunk embedding throughput
+func (r *Result) EmbeddingsPerSec() float64 { return r.rate(r.Embeddings) }
+
+func (r *Result) rate(count int64) float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(count) / r.Duration.Seconds()
+}
+
+// MemorySampler records the peak Go heap size while a benchmark runs
+type MemorySampler struct {
+	stop chan struct{}
+	done sync.WaitGroup
+	peak uint64
+}
+
+// StartMemorySampler samples the heap every interval until Stop is called
+func StartMemorySampler(interval time.Duration) *MemorySampler {
+	s := &MemorySampler{stop: make(chan struct{})}
+	s.done.Add(1)
+	go func() {
+		defer s.done.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			s.sample()
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return s
+}
+
+func (s *MemorySampler) sample() {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	if stats.HeapAlloc > s.peak {
+		s.peak = stats.HeapAlloc
+	}
+}
+
+// Stop ends sampling and returns the peak heap size in bytes
+func (s *MemorySampler) Stop() uint64 {
+	close(s.stop)
+	s.done.Wait()
+	return s.peak
+}
+
+// LoadBaseline reads a baseline written by SaveBaseline. A missing file returns nil
+// without error, since the first run has nothing to compare against.
+func LoadBaseline(path string) (*Result, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read baseline: %w", err)
+	}
+	var result Result
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse baseline %s: %w", path, err)
+	}
+	return &result, nil
+}
+
+// SaveBaseline writes result as the new baseline
+func SaveBaseline(path string, result *Result) error {
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write baseline: %w", err)
+	}
+	return nil
+}
+
+// Comparison is one metric of a run compared with the baseline
+type Comparison struct {
+	Metric     string
+	Baseline   float64
+	Current    float64
+	ChangePct  float64 // positive = better
+	Regression bool    // worse than the baseline by more than the tolerance
+}
+
+// Compare checks the throughput and memory metrics of current against baseline. A metric
+// regresses when it is worse by more than tolerance (e.g. 0.1 for 10%). Metrics that are
+// zero in the baseline, such as embeddings when they were disabled, are not compared.
+func Compare(current, baseline *Result, tolerance float64) []Comparison {
+	metrics := []struct {
+		name           string
+		base, cur      float64
+		higherIsBetter bool
+	}{
+		{"files/sec", baseline.FilesPerSec(), current.FilesPerSec(), true},
+		{"nodes/sec", baseline.NodesPerSec(), current.NodesPerSec(), true},
+		{"embeddings/sec", baseline.EmbeddingsPerSec(), current.EmbeddingsPerSec(), true},
+		{"peak heap MB", mb(baseline.PeakHeapBytes), mb(current.PeakHeapBytes), false},
+	}
+
+	var comparisons []Comparison
+	for _, m := range metrics {
+		if m.base == 0 {
+			continue
+		}
+		change := (m.cur - m.base) / m.base
+		if !m.higherIsBetter {
+			change = -change
+		}
+		comparisons = append(comparisons, Comparison{
+			Metric:     m.name,
+			Baseline:   m.base,
+			Current:    m.cur,
+			ChangePct:  change * 100,
+			Regression: change < -tolerance,
+		})
+	}
+	return comparisons
+}
+
+// HasRegression reports whether any comparison regressed
+func HasRegression(comparisons []Comparison) bool {
+	for _, c := range comparisons {
+		if c.Regression {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteReport prints the run's metrics and, when a baseline exists, the comparison
+func WriteReport(w io.Writer, current *Result, comparisons []Comparison) {
+	fmt.Fprintf(w, "Indexed %d files in %s\n", current.Files, current.Duration.Round(time.Millisecond))
+	fmt.Fprintf(w, "  files/sec:      %10.1f\n", current.FilesPerSec())
+	fmt.Fprintf(w, "  nodes/sec:      %10.1f  (%d nodes)\n", current.NodesPerSec(), current.Nodes)
+	fmt.Fprintf(w, "  embeddings/sec: %10.1f  (%d embeddings)\n", current.EmbeddingsPerSec(), current.Embeddings)
+	fmt.Fprintf(w, "  peak heap MB:   %10.1f\n", mb(current.PeakHeapBytes))
+
+	if comparisons == nil {
+		fmt.Fprintln(w, "No baseline to compare against")
+		return
+	}
+	fmt.Fprintln(w, "Compared with baseline:")
+	for _, c := range comparisons {
+		status := "ok"
+		if c.Regression {
+			status = "REGRESSION"
+		}
+		fmt.Fprintf(w, "  %-15s %10.1f -> %10.1f  %+6.1f%%  %s\n", c.Metric+":", c.Baseline, c.Current, c.ChangePct, status)
+	}
+}
+
+func mb(bytes uint64) float64 {
+	return float64(bytes) / (1024 * 1024)
+}
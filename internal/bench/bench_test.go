@@ -0,0 +1,58 @@
+package bench
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompareFlagsRegressions(t *testing.T) {
+	baseline := &Result{Files: 100, Nodes: 1000, Duration: 10 * time.Second, PeakHeapBytes: 100 << 20}
+	current := &Result{Files: 100, Nodes: 1000, Duration: 20 * time.Second, PeakHeapBytes: 105 << 20}
+
+	comparisons := Compare(current, baseline, 0.1)
+	got := map[string]bool{}
+	for _, c := range comparisons {
+		got[c.Metric] = c.Regression
+	}
+	if !got["files/sec"] || !got["nodes/sec"] {
+		t.Errorf("expected halved throughput to regress, got %+v", comparisons)
+	}
+	if got["peak heap MB"] {
+		t.Errorf("5%% more memory is within tolerance, got %+v", comparisons)
+	}
+	if _, ok := got["embeddings/sec"]; ok {
+		t.Error("embeddings were not measured in the baseline and should not be compared")
+	}
+	if !HasRegression(comparisons) {
+		t.Error("HasRegression should report the throughput regression")
+	}
+}
+
+func TestGenerateRepo(t *testing.T) {
+	dir := t.TempDir()
+	if err := GenerateRepo(dir, GenerateOptions{Files: 12, FunctionsPerFile: 3, FilesPerPackage: 5}); err != nil {
+		t.Fatal(err)
+	}
+
+	var files int
+	filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && strings.HasSuffix(path, ".go") {
+			files++
+		}
+		return err
+	})
+	if files != 12 {
+		t.Errorf("generated %d files, want 12", files)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "pkg001", "file0006.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "Compute6_2(") || !strings.Contains(string(content), "Compute5_0(values, nil)") {
+		t.Errorf("unexpected generated content:\n%s", content)
+	}
+}
@@ -0,0 +1,86 @@
+package bench
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// GenerateOptions sizes the synthetic repository
+type GenerateOptions struct {
+	Files            int // number of source files (default 100)
+	FunctionsPerFile int // functions per file (default 20)
+	FilesPerPackage  int // files per package directory (default 10)
+}
+
+func (o GenerateOptions) withDefaults() GenerateOptions {
+	if o.Files <= 0 {
+		o.Files = 100
+	}
+	if o.FunctionsPerFile <= 0 {
+		o.FunctionsPerFile = 20
+	}
+	if o.FilesPerPackage <= 0 {
+		o.FilesPerPackage = 10
+	}
+	return o
+}
+
+// GenerateRepo writes a synthetic Go repository into dir. Every file has a struct with
+// methods and free functions containing branches, loops and calls to other functions in
+// the same package, so all processors do representative work. Output is deterministic
+// for the same options, keeping runs comparable with a baseline.
+func GenerateRepo(dir string, opts GenerateOptions) error {
+	opts = opts.withDefaults()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/benchrepo\n\ngo 1.23\n"), 0o644); err != nil {
+		return fmt.Errorf("failed to write go.mod: %w", err)
+	}
+
+	for i := 0; i < opts.Files; i++ {
+		pkg := fmt.Sprintf("pkg%03d", i/opts.FilesPerPackage)
+		pkgDir := filepath.Join(dir, pkg)
+		if err := os.MkdirAll(pkgDir, 0o755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", pkgDir, err)
+		}
+		path := filepath.Join(pkgDir, fmt.Sprintf("file%04d.go", i))
+		if err := os.WriteFile(path, []byte(generateFile(pkg, i, opts)), 0o644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// generateFile renders one synthetic source file. Function n of file i calls function
+// n-1 of the same file and the first function of the previous file in the package.
+func generateFile(pkg string, fileIndex int, opts GenerateOptions) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "package %s\n\nimport \"strings\"\n\n", pkg)
+
+	typeName := fmt.Sprintf("Record%d", fileIndex)
+	fmt.Fprintf(&b, "// %s holds synthetic state for file %d\n", typeName, fileIndex)
+	fmt.Fprintf(&b, "type %s struct {\n\tName  string\n\tCount int\n\tTags  []string\n}\n\n", typeName)
+	fmt.Fprintf(&b, "// Describe formats the record\nfunc (r *%s) Describe() string {\n\treturn r.Name + \":\" + strings.Join(r.Tags, \",\")\n}\n\n", typeName)
+
+	firstInPackage := fileIndex%opts.FilesPerPackage == 0
+	for n := 0; n < opts.FunctionsPerFile; n++ {
+		name := fmt.Sprintf("Compute%d_%d", fileIndex, n)
+		fmt.Fprintf(&b, "// %s is synthetic function %d of file %d\n", name, n, fileIndex)
+		fmt.Fprintf(&b, "func %s(values []int, r *%s) int {\n", name, typeName)
+		b.WriteString("\ttotal := 0\n")
+		b.WriteString("\tfor i, v := range values {\n")
+		b.WriteString("\t\tif v%2 == 0 {\n\t\t\ttotal += v * i\n\t\t} else {\n\t\t\ttotal -= v\n\t\t}\n")
+		b.WriteString("\t}\n")
+		b.WriteString("\tif r != nil && r.Count > total {\n\t\ttotal = r.Count\n\t}\n")
+		if n > 0 {
+			fmt.Fprintf(&b, "\ttotal += Compute%d_%d(values[:len(values)/2], r)\n", fileIndex, n-1)
+		} else if !firstInPackage {
+			fmt.Fprintf(&b, "\ttotal += Compute%d_0(values, nil)\n", fileIndex-1)
+		}
+		b.WriteString("\treturn total\n}\n\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,124 @@
+// Package cache holds state that server instances share through Redis, as configured by the
+// cache section of app.yaml: MCP tool results, sessions, embeddings and usage counters.
+// Without Redis each instance keeps that state in memory, as before.
+package cache
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"bot-go/internal/config"
+)
+
+// Cache is a key-value store whose entries may expire. Keys are namespaced by their users,
+// e.g. session:<id>.
+type Cache interface {
+	// Get returns the value under key; ok is false when there is none
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	// Set stores value under key, expiring after ttl (0 keeps it until deleted)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes key; removing a missing key is not an error
+	Delete(ctx context.Context, key string) error
+	// IncrBy adds n to the integer under key and returns the sum. A key it creates
+	// expires after ttl (0 keeps it until deleted).
+	IncrBy(ctx context.Context, key string, n int64, ttl time.Duration) (int64, error)
+}
+
+// New returns the Redis cache configured by cfg after checking it answers, or nil when no
+// Redis is configured
+func New(ctx context.Context, cfg config.CacheConfig) (Cache, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+	redis := NewRedis(cfg)
+	if err := redis.Ping(ctx); err != nil {
+		redis.Close()
+		return nil, err
+	}
+	return redis, nil
+}
+
+// Memory is a Cache in the memory of this process. It is safe for concurrent use.
+type Memory struct {
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+}
+
+type memoryEntry struct {
+	value   []byte
+	expires time.Time // zero when the entry does not expire
+}
+
+// NewMemory returns an empty in-memory cache
+func NewMemory() *Memory {
+	return &Memory{now: time.Now, entries: make(map[string]memoryEntry)}
+}
+
+// Get returns a copy of the value under key
+func (m *Memory) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.entry(key)
+	if !ok {
+		return nil, false, nil
+	}
+	return append([]byte(nil), entry.value...), true, nil
+}
+
+// Set stores a copy of value under key
+func (m *Memory) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[key] = memoryEntry{value: append([]byte(nil), value...), expires: m.expiry(ttl)}
+	return nil
+}
+
+// Delete removes key
+func (m *Memory) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, key)
+	return nil
+}
+
+// IncrBy adds n to the decimal integer under key, keeping its expiry
+func (m *Memory) IncrBy(ctx context.Context, key string, n int64, ttl time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.entry(key)
+	var sum int64
+	if ok {
+		current, err := strconv.ParseInt(string(entry.value), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		sum = current
+	} else {
+		entry.expires = m.expiry(ttl)
+	}
+	sum += n
+	entry.value = strconv.AppendInt(nil, sum, 10)
+	m.entries[key] = entry
+	return sum, nil
+}
+
+// entry returns the live entry of key, dropping it if it expired; mu must be held
+func (m *Memory) entry(key string) (memoryEntry, bool) {
+	entry, ok := m.entries[key]
+	if ok && !entry.expires.IsZero() && !m.now().Before(entry.expires) {
+		delete(m.entries, key)
+		return memoryEntry{}, false
+	}
+	return entry, ok
+}
+
+func (m *Memory) expiry(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return m.now().Add(ttl)
+}
@@ -0,0 +1,217 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"bot-go/internal/config"
+)
+
+func TestMemory(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1000, 0)
+	m := NewMemory()
+	m.now = func() time.Time { return now }
+
+	if _, ok, _ := m.Get(ctx, "a"); ok {
+		t.Fatal("expected a miss on an empty cache")
+	}
+	m.Set(ctx, "a", []byte("1"), time.Minute)
+	m.Set(ctx, "b", []byte("2"), 0)
+	if sum, _ := m.IncrBy(ctx, "n", 3, time.Minute); sum != 3 {
+		t.Errorf("first IncrBy = %d, want 3", sum)
+	}
+	now = now.Add(30 * time.Second)
+	if sum, _ := m.IncrBy(ctx, "n", 4, time.Hour); sum != 7 {
+		t.Errorf("second IncrBy = %d, want 7", sum)
+	}
+	if value, ok, _ := m.Get(ctx, "a"); !ok || string(value) != "1" {
+		t.Errorf("Get(a) = %q, %v", value, ok)
+	}
+
+	// The second IncrBy kept the expiry of the counter
+	now = now.Add(time.Minute)
+	for _, key := range []string{"a", "n"} {
+		if _, ok, _ := m.Get(ctx, key); ok {
+			t.Errorf("expected %s to expire", key)
+		}
+	}
+	if _, ok, _ := m.Get(ctx, "b"); !ok {
+		t.Error("expected b to be kept without a ttl")
+	}
+	m.Delete(ctx, "b")
+	if _, ok, _ := m.Get(ctx, "b"); ok {
+		t.Error("expected b to be deleted")
+	}
+}
+
+// fakeRedis serves the commands of the Redis client from a Memory cache
+type fakeRedis struct {
+	password string
+	data     *Memory
+
+	mu       sync.Mutex
+	commands []string // command names in the order received
+}
+
+func startFakeRedis(t *testing.T, password string) (*fakeRedis, string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	f := &fakeRedis{password: password, data: NewMemory()}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f, ln.Addr().String()
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authed := f.password == ""
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.commands = append(f.commands, args[0])
+		f.mu.Unlock()
+
+		ctx := context.Background()
+		reply := "+OK\r\n"
+		switch {
+		case args[0] == "AUTH":
+			if args[len(args)-1] != f.password {
+				reply = "-WRONGPASS invalid password\r\n"
+			} else {
+				authed = true
+			}
+		case !authed:
+			reply = "-NOAUTH Authentication required.\r\n"
+		case args[0] == "PING":
+			reply = "+PONG\r\n"
+		case args[0] == "SELECT":
+		case args[0] == "GET":
+			value, ok, _ := f.data.Get(ctx, args[1])
+			reply = "$-1\r\n"
+			if ok {
+				reply = fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+			}
+		case args[0] == "SET":
+			var ttl time.Duration
+			if len(args) == 5 && args[3] == "PX" {
+				ms, _ := strconv.Atoi(args[4])
+				ttl = time.Duration(ms) * time.Millisecond
+			}
+			f.data.Set(ctx, args[1], []byte(args[2]), ttl)
+		case args[0] == "DEL":
+			f.data.Delete(ctx, args[1])
+			reply = ":1\r\n"
+		case args[0] == "INCRBY":
+			n, _ := strconv.ParseInt(args[2], 10, 64)
+			sum, err := f.data.IncrBy(ctx, args[1], n, 0)
+			reply = fmt.Sprintf(":%d\r\n", sum)
+			if err != nil {
+				reply = "-ERR value is not an integer or out of range\r\n"
+			}
+		case args[0] == "PEXPIRE":
+			reply = ":1\r\n"
+		default:
+			reply = "-ERR unknown command\r\n"
+		}
+		io.WriteString(conn, reply)
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	args := make([]string, n)
+	for i := range args {
+		if line, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		args[i] = string(data[:size])
+	}
+	return args, nil
+}
+
+func TestRedis(t *testing.T) {
+	ctx := context.Background()
+	fake, addr := startFakeRedis(t, "hunter2")
+
+	if _, err := New(ctx, config.CacheConfig{RedisAddress: addr, RedisPassword: "wrong"}); err == nil {
+		t.Fatal("expected a wrong password to fail")
+	}
+	c, err := New(ctx, config.CacheConfig{RedisAddress: addr, RedisPassword: "hunter2", RedisDB: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.(*Redis).Close()
+
+	if _, ok, err := c.Get(ctx, "missing"); ok || err != nil {
+		t.Fatalf("Get(missing) = %v, %v", ok, err)
+	}
+	value := []byte("binary\r\n\x00value")
+	if err := c.Set(ctx, "k", value, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok, err := c.Get(ctx, "k"); err != nil || !ok || string(got) != string(value) {
+		t.Errorf("Get(k) = %q, %v, %v", got, ok, err)
+	}
+	if _, ok, _ := fake.data.Get(ctx, "bot-go:k"); !ok {
+		t.Error("expected keys to carry the default prefix")
+	}
+	for want := int64(2); want <= 4; want += 2 {
+		if sum, err := c.IncrBy(ctx, "n", 2, time.Hour); err != nil || sum != want {
+			t.Errorf("IncrBy = %d, %v, want %d", sum, err, want)
+		}
+	}
+	var redisErr RedisError
+	if _, err := c.IncrBy(ctx, "k", 1, 0); !errors.As(err, &redisErr) {
+		t.Errorf("IncrBy of a string = %v, want a RedisError", err)
+	}
+	if err := c.Delete(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := c.Get(ctx, "k"); ok {
+		t.Error("expected k to be deleted")
+	}
+
+	// One connection served every command after an error reply; PEXPIRE followed the
+	// INCRBY that created the counter only
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	got := strings.Join(fake.commands, " ")
+	want := "AUTH AUTH SELECT PING GET SET GET INCRBY PEXPIRE INCRBY INCRBY DEL GET"
+	if got != want {
+		t.Errorf("commands = %s\nwant %s", got, want)
+	}
+}
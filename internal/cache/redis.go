@@ -0,0 +1,253 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"bot-go/internal/config"
+)
+
+const (
+	defaultKeyPrefix    = "bot-go:"
+	defaultRedisTimeout = 2 * time.Second
+	maxIdleConns        = 16
+	maxBulkLen          = 512 << 20 // the largest string Redis stores
+)
+
+// RedisError is an error reply of the server, e.g. WRONGTYPE for a key of another type
+type RedisError string
+
+func (e RedisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// Redis is a Cache on a Redis server, talking RESP over a small pool of connections. Keys
+// are prefixed with cache.key_prefix so several deployments can share a server. It is safe
+// for concurrent use.
+type Redis struct {
+	cfg     config.CacheConfig
+	prefix  string
+	timeout time.Duration
+	idle    chan *redisConn
+}
+
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+// NewRedis returns a client of the server configured by cfg. Connections are opened on
+// first use.
+func NewRedis(cfg config.CacheConfig) *Redis {
+	prefix := cfg.KeyPrefix
+	if prefix == "" {
+		prefix = defaultKeyPrefix
+	}
+	timeout := time.Duration(cfg.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = defaultRedisTimeout
+	}
+	return &Redis{cfg: cfg, prefix: prefix, timeout: timeout, idle: make(chan *redisConn, maxIdleConns)}
+}
+
+// Ping checks the server answers
+func (r *Redis) Ping(ctx context.Context) error {
+	_, err := r.do(ctx, "PING")
+	return err
+}
+
+// Get returns the value under key
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	reply, err := r.do(ctx, "GET", r.prefix+key)
+	if err != nil || reply == nil {
+		return nil, false, err
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("redis: unexpected GET reply %T", reply)
+	}
+	return value, true, nil
+}
+
+// Set stores value under key with SET, expiring it with PX
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	args := []any{"SET", r.prefix + key, value}
+	if ttl > 0 {
+		args = append(args, "PX", ttl.Milliseconds())
+	}
+	_, err := r.do(ctx, args...)
+	return err
+}
+
+// Delete removes key with DEL
+func (r *Redis) Delete(ctx context.Context, key string) error {
+	_, err := r.do(ctx, "DEL", r.prefix+key)
+	return err
+}
+
+// IncrBy adds n to key with INCRBY, then sets the expiry of a key it created. A key created
+// by a client that fails before PEXPIRE does not expire.
+func (r *Redis) IncrBy(ctx context.Context, key string, n int64, ttl time.Duration) (int64, error) {
+	reply, err := r.do(ctx, "INCRBY", r.prefix+key, n)
+	if err != nil {
+		return 0, err
+	}
+	sum, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("redis: unexpected INCRBY reply %T", reply)
+	}
+	if sum == n && ttl > 0 {
+		if _, err := r.do(ctx, "PEXPIRE", r.prefix+key, ttl.Milliseconds()); err != nil {
+			return sum, err
+		}
+	}
+	return sum, nil
+}
+
+// Close closes the idle connections
+func (r *Redis) Close() {
+	for {
+		select {
+		case c := <-r.idle:
+			c.conn.Close()
+		default:
+			return
+		}
+	}
+}
+
+// do sends a command and reads its reply: nil, a string, an int64 or []byte. Error
+// replies are returned as RedisError, keeping the connection.
+func (r *Redis) do(ctx context.Context, args ...any) (any, error) {
+	c, err := r.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	deadline, ok := ctx.Deadline()
+	if limit := time.Now().Add(r.timeout); !ok || limit.Before(deadline) {
+		deadline = limit
+	}
+	c.conn.SetDeadline(deadline)
+
+	reply, err := c.roundTrip(args)
+	var redisErr RedisError
+	if err != nil && !errors.As(err, &redisErr) {
+		c.conn.Close()
+		return nil, err
+	}
+	select {
+	case r.idle <- c:
+	default:
+		c.conn.Close()
+	}
+	return reply, err
+}
+
+// conn takes an idle connection or opens one, authenticating and selecting the database
+func (r *Redis) conn(ctx context.Context) (*redisConn, error) {
+	select {
+	case c := <-r.idle:
+		return c, nil
+	default:
+	}
+
+	dialer := &net.Dialer{Timeout: r.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", r.cfg.RedisAddress)
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	if r.cfg.RedisTLS {
+		host, _, _ := net.SplitHostPort(r.cfg.RedisAddress)
+		conn = tls.Client(conn, &tls.Config{ServerName: host})
+	}
+	c := &redisConn{conn: conn, r: bufio.NewReader(conn), w: bufio.NewWriter(conn)}
+	conn.SetDeadline(time.Now().Add(r.timeout))
+
+	var setup [][]any
+	switch {
+	case r.cfg.RedisUsername != "":
+		setup = append(setup, []any{"AUTH", r.cfg.RedisUsername, r.cfg.RedisPassword})
+	case r.cfg.RedisPassword != "":
+		setup = append(setup, []any{"AUTH", r.cfg.RedisPassword})
+	}
+	if r.cfg.RedisDB != 0 {
+		setup = append(setup, []any{"SELECT", r.cfg.RedisDB})
+	}
+	for _, args := range setup {
+		if _, err := c.roundTrip(args); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("redis %s: %w", args[0], err)
+		}
+	}
+	return c, nil
+}
+
+// roundTrip writes a command as an array of bulk strings and reads the reply
+func (c *redisConn) roundTrip(args []any) (any, error) {
+	fmt.Fprintf(c.w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		var b []byte
+		switch v := arg.(type) {
+		case string:
+			b = []byte(v)
+		case []byte:
+			b = v
+		case int:
+			b = strconv.AppendInt(nil, int64(v), 10)
+		case int64:
+			b = strconv.AppendInt(nil, v, 10)
+		default:
+			return nil, fmt.Errorf("redis: unsupported argument %T", arg)
+		}
+		fmt.Fprintf(c.w, "$%d\r\n", len(b))
+		c.w.Write(b)
+		c.w.WriteString("\r\n")
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+	return readReply(c.r)
+}
+
+// readReply reads one RESP2 reply
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, RedisError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil || n > maxBulkLen {
+			return nil, fmt.Errorf("redis: malformed bulk length %q", body)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return data[:n], nil
+	default:
+		return nil, fmt.Errorf("redis: unknown reply type %q", kind)
+	}
+}
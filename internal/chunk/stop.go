@@ -0,0 +1,506 @@
+package chunk
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// Stop chunk kinds: boilerplate recognized by its syntax tree that is not worth embedding
+const (
+	StopGetters        = "getters"         // methods that only return a field of the receiver
+	StopSetters        = "setters"         // methods that only assign their parameter to a field
+	StopConstructors   = "constructors"    // constructors that only copy their parameters or literals into fields
+	StopLicenseHeaders = "license_headers" // leading comments with a license, removed from file chunks
+	StopNone           = "none"            // disables filtering, e.g. to override a default
+)
+
+// StopChunkKinds lists the kinds a StopSet can hold
+var StopChunkKinds = []string{StopGetters, StopSetters, StopConstructors, StopLicenseHeaders}
+
+// StopSet holds the stop chunk kinds to skip
+type StopSet map[string]bool
+
+// NewStopSet returns the set of the given kinds. StopNone on its own gives an empty set.
+func NewStopSet(kinds []string) (StopSet, error) {
+	set := make(StopSet, len(kinds))
+	for _, kind := range kinds {
+		switch kind {
+		case StopGetters, StopSetters, StopConstructors, StopLicenseHeaders:
+			set[kind] = true
+		case StopNone:
+			if len(kinds) > 1 {
+				return nil, fmt.Errorf("stop chunk kind %q cannot be combined with others", StopNone)
+			}
+		default:
+			return nil, fmt.Errorf("unknown stop chunk kind %q (valid: %s, %s)", kind, strings.Join(StopChunkKinds, ", "), StopNone)
+		}
+	}
+	return set, nil
+}
+
+// licensePattern matches the comments of license headers
+var licensePattern = regexp.MustCompile(`(?i)copyright|licen[cs]e|spdx-license-identifier`)
+
+// stopFunctionKind returns the stop chunk kind of a function or method declaration, or ""
+// if it is not boilerplate or its kind is not in the set
+func (cv *ChunkVisitor) stopFunctionKind(tsNode *tree_sitter.Node, name string) string {
+	if len(cv.stop) == 0 {
+		return ""
+	}
+	var kind string
+	switch cv.language {
+	case "go":
+		kind = cv.goStopKind(tsNode, name)
+	case "python":
+		if cv.currentClass != nil {
+			kind = cv.pythonStopKind(tsNode, name)
+		}
+	case "java":
+		kind = cv.javaStopKind(tsNode)
+	case "javascript", "typescript":
+		kind = cv.jsStopKind(tsNode, name)
+	case "rust":
+		kind = cv.rustStopKind(tsNode, name)
+	}
+	if !cv.stop[kind] {
+		return ""
+	}
+	return kind
+}
+
+// licenseHeaderEnd returns the end byte of the comments leading a file when they contain a
+// license, or 0
+func (cv *ChunkVisitor) licenseHeaderEnd(root *tree_sitter.Node) uint {
+	if !cv.stop[StopLicenseHeaders] {
+		return 0
+	}
+	var end uint
+	var header strings.Builder
+	for i := uint(0); i < root.ChildCount(); i++ {
+		child := root.Child(i)
+		if !isComment(child) {
+			break
+		}
+		header.WriteString(cv.getNodeText(child))
+		end = child.EndByte()
+	}
+	if end == 0 || !licensePattern.MatchString(header.String()) {
+		return 0
+	}
+	return end
+}
+
+// goStopKind recognizes Go getters and setters (methods whose body returns or assigns one
+// field of the receiver) and constructors (New functions returning a struct literal)
+func (cv *ChunkVisitor) goStopKind(tsNode *tree_sitter.Node, name string) string {
+	body := statements(tsNode.ChildByFieldName("body"))
+	if len(body) != 1 {
+		return ""
+	}
+	stmt := body[0]
+	params := tsNode.ChildByFieldName("parameters")
+
+	if tsNode.Kind() == "function_declaration" {
+		if strings.HasPrefix(name, "New") && stmt.Kind() == "return_statement" && cv.isGoStructLiteralReturn(stmt) {
+			return StopConstructors
+		}
+		return ""
+	}
+
+	receiver := cv.goParameterNames(tsNode.ChildByFieldName("receiver"))
+	if len(receiver) != 1 {
+		return ""
+	}
+	paramNames := cv.goParameterNames(params)
+	switch stmt.Kind() {
+	case "return_statement":
+		values := namedChildren(firstNamedChild(stmt))
+		if len(paramNames) == 0 && len(values) == 1 && cv.isFieldOf(values[0], receiver[0]) {
+			return StopGetters
+		}
+	case "assignment_statement":
+		left := namedChildren(stmt.ChildByFieldName("left"))
+		right := namedChildren(stmt.ChildByFieldName("right"))
+		if len(paramNames) == 1 && tsNode.ChildByFieldName("result") == nil &&
+			len(left) == 1 && len(right) == 1 && cv.getNodeText(stmt.ChildByFieldName("operator")) == "=" &&
+			cv.isFieldOf(left[0], receiver[0]) && cv.getNodeText(right[0]) == paramNames[0] {
+			return StopSetters
+		}
+	}
+	return ""
+}
+
+// goParameterNames returns the names declared by a Go parameter list. Unnamed parameters
+// are returned as "".
+func (cv *ChunkVisitor) goParameterNames(params *tree_sitter.Node) []string {
+	var names []string
+	for _, param := range namedChildren(params) {
+		if param.Kind() != "parameter_declaration" && param.Kind() != "variadic_parameter_declaration" {
+			continue
+		}
+		declared := false
+		for i := uint(0); i < param.ChildCount(); i++ {
+			if param.FieldNameForChild(uint32(i)) == "name" {
+				names = append(names, cv.getNodeText(param.Child(i)))
+				declared = true
+			}
+		}
+		if !declared {
+			names = append(names, "")
+		}
+	}
+	return names
+}
+
+// isGoStructLiteralReturn reports whether a return statement returns a struct literal (or a
+// pointer to one) of plain values, optionally followed by nil
+func (cv *ChunkVisitor) isGoStructLiteralReturn(stmt *tree_sitter.Node) bool {
+	values := namedChildren(firstNamedChild(stmt))
+	if len(values) == 2 && values[1].Kind() == "nil" {
+		values = values[:1]
+	}
+	if len(values) != 1 {
+		return false
+	}
+	literal := values[0]
+	if literal.Kind() == "unary_expression" && cv.getNodeText(literal.ChildByFieldName("operator")) == "&" {
+		literal = literal.ChildByFieldName("operand")
+	}
+	if literal == nil || literal.Kind() != "composite_literal" {
+		return false
+	}
+	for _, element := range namedChildren(literal.ChildByFieldName("body")) {
+		value := element
+		if element.Kind() == "keyed_element" {
+			parts := namedChildren(element)
+			value = parts[len(parts)-1]
+		}
+		if !isPlainValue(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// pythonStopKind recognizes Python getters (methods returning self.x), setters (methods
+// assigning their parameter to self.x) and constructors (__init__ methods only assigning
+// parameters or literals to attributes of self). A docstring is allowed in each.
+func (cv *ChunkVisitor) pythonStopKind(tsNode *tree_sitter.Node, name string) string {
+	params := cv.pythonParameterNames(tsNode.ChildByFieldName("parameters"))
+	if len(params) == 0 {
+		return ""
+	}
+	self := params[0]
+	body := statements(tsNode.ChildByFieldName("body"))
+	if len(body) > 0 && body[0].Kind() == "expression_statement" && kindOf(firstNamedChild(body[0])) == "string" {
+		body = body[1:]
+	}
+
+	if name == "__init__" {
+		for _, stmt := range body {
+			if stmt.Kind() == "pass_statement" {
+				continue
+			}
+			assignment := firstNamedChild(stmt)
+			if stmt.Kind() != "expression_statement" || kindOf(assignment) != "assignment" ||
+				!cv.isFieldOf(assignment.ChildByFieldName("left"), self) || !isPlainValue(assignment.ChildByFieldName("right")) {
+				return ""
+			}
+		}
+		return StopConstructors
+	}
+
+	if len(body) != 1 {
+		return ""
+	}
+	stmt := body[0]
+	switch {
+	case stmt.Kind() == "return_statement" && len(params) == 1:
+		if value := firstNamedChild(stmt); value != nil && cv.isFieldOf(value, self) {
+			return StopGetters
+		}
+	case stmt.Kind() == "expression_statement" && len(params) == 2:
+		assignment := firstNamedChild(stmt)
+		if kindOf(assignment) == "assignment" && cv.isFieldOf(assignment.ChildByFieldName("left"), self) &&
+			cv.getNodeText(assignment.ChildByFieldName("right")) == params[1] {
+			return StopSetters
+		}
+	}
+	return ""
+}
+
+// pythonParameterNames returns the names of plain and typed Python parameters, or nil if
+// there are parameters with defaults or splats
+func (cv *ChunkVisitor) pythonParameterNames(params *tree_sitter.Node) []string {
+	var names []string
+	for _, param := range namedChildren(params) {
+		switch param.Kind() {
+		case "identifier":
+			names = append(names, cv.getNodeText(param))
+		case "typed_parameter":
+			names = append(names, cv.getNodeText(firstNamedChild(param)))
+		default:
+			return nil
+		}
+	}
+	return names
+}
+
+// javaStopKind recognizes Java getters (methods without parameters returning a field) and
+// setters (void methods assigning their parameter to a field). Constructors are not chunked.
+func (cv *ChunkVisitor) javaStopKind(tsNode *tree_sitter.Node) string {
+	body := statements(tsNode.ChildByFieldName("body"))
+	if len(body) != 1 {
+		return ""
+	}
+	stmt := body[0]
+	var params []string
+	for _, param := range namedChildren(tsNode.ChildByFieldName("parameters")) {
+		if param.Kind() == "formal_parameter" {
+			params = append(params, cv.getNodeText(param.ChildByFieldName("name")))
+		}
+	}
+	switch stmt.Kind() {
+	case "return_statement":
+		if value := firstNamedChild(stmt); len(params) == 0 && value != nil && cv.isJavaField(value) {
+			return StopGetters
+		}
+	case "expression_statement":
+		assignment := firstNamedChild(stmt)
+		if len(params) == 1 && kindOf(tsNode.ChildByFieldName("type")) == "void_type" &&
+			kindOf(assignment) == "assignment_expression" && cv.getNodeText(assignment.ChildByFieldName("operator")) == "=" &&
+			cv.isJavaField(assignment.ChildByFieldName("left")) && cv.getNodeText(assignment.ChildByFieldName("right")) == params[0] {
+			return StopSetters
+		}
+	}
+	return ""
+}
+
+// isJavaField reports whether an expression is this.x or a bare field name
+func (cv *ChunkVisitor) isJavaField(node *tree_sitter.Node) bool {
+	switch kindOf(node) {
+	case "identifier":
+		return true
+	case "field_access":
+		return cv.getNodeText(node.ChildByFieldName("object")) == "this"
+	}
+	return false
+}
+
+// jsStopKind recognizes JavaScript/TypeScript getters (methods returning this.x), setters
+// (methods assigning their parameter to this.x) and constructors only assigning
+// parameters or literals to fields of this
+func (cv *ChunkVisitor) jsStopKind(tsNode *tree_sitter.Node, name string) string {
+	if tsNode.Kind() != "method_definition" {
+		return ""
+	}
+	body := statements(tsNode.ChildByFieldName("body"))
+	var params []string
+	for _, param := range namedChildren(tsNode.ChildByFieldName("parameters")) {
+		if pattern := param.ChildByFieldName("pattern"); pattern != nil { // TypeScript
+			param = pattern
+		}
+		params = append(params, cv.getNodeText(param))
+	}
+
+	if name == "constructor" {
+		for _, stmt := range body {
+			assignment := firstNamedChild(stmt)
+			if stmt.Kind() != "expression_statement" || kindOf(assignment) != "assignment_expression" ||
+				!cv.isFieldOf(assignment.ChildByFieldName("left"), "this") || !isPlainValue(assignment.ChildByFieldName("right")) {
+				return ""
+			}
+		}
+		return StopConstructors
+	}
+
+	if len(body) != 1 {
+		return ""
+	}
+	stmt := body[0]
+	switch stmt.Kind() {
+	case "return_statement":
+		if value := firstNamedChild(stmt); len(params) == 0 && value != nil && cv.isFieldOf(value, "this") {
+			return StopGetters
+		}
+	case "expression_statement":
+		assignment := firstNamedChild(stmt)
+		if len(params) == 1 && kindOf(assignment) == "assignment_expression" &&
+			cv.isFieldOf(assignment.ChildByFieldName("left"), "this") && cv.getNodeText(assignment.ChildByFieldName("right")) == params[0] {
+			return StopSetters
+		}
+	}
+	return ""
+}
+
+// rustStopKind recognizes Rust getters (methods taking only self and returning self.x or
+// &self.x), setters (methods assigning their parameter to self.x) and constructors (new
+// functions returning a struct expression of plain values)
+func (cv *ChunkVisitor) rustStopKind(tsNode *tree_sitter.Node, name string) string {
+	body := statements(tsNode.ChildByFieldName("body"))
+	if len(body) != 1 {
+		return ""
+	}
+	stmt := body[0]
+	if stmt.Kind() == "expression_statement" {
+		stmt = firstNamedChild(stmt)
+	}
+	if kindOf(stmt) == "return_expression" {
+		stmt = firstNamedChild(stmt)
+	}
+	if stmt == nil {
+		return ""
+	}
+
+	hasSelf := false
+	var params []string
+	for _, param := range namedChildren(tsNode.ChildByFieldName("parameters")) {
+		switch param.Kind() {
+		case "self_parameter":
+			hasSelf = true
+		case "parameter":
+			params = append(params, cv.getNodeText(param.ChildByFieldName("pattern")))
+		}
+	}
+
+	if !hasSelf {
+		if name == "new" && stmt.Kind() == "struct_expression" && cv.isRustPlainStruct(stmt) {
+			return StopConstructors
+		}
+		return ""
+	}
+	if stmt.Kind() == "reference_expression" {
+		stmt = stmt.ChildByFieldName("value")
+	}
+	switch kindOf(stmt) {
+	case "field_expression":
+		if len(params) == 0 && cv.isFieldOf(stmt, "self") {
+			return StopGetters
+		}
+	case "assignment_expression":
+		if len(params) == 1 && tsNode.ChildByFieldName("return_type") == nil &&
+			cv.isFieldOf(stmt.ChildByFieldName("left"), "self") && cv.getNodeText(stmt.ChildByFieldName("right")) == params[0] {
+			return StopSetters
+		}
+	}
+	return ""
+}
+
+// isRustPlainStruct reports whether a struct expression only holds plain values, such as
+// Self { name, size: 0 }
+func (cv *ChunkVisitor) isRustPlainStruct(literal *tree_sitter.Node) bool {
+	for _, field := range namedChildren(literal.ChildByFieldName("body")) {
+		switch field.Kind() {
+		case "shorthand_field_initializer":
+		case "field_initializer":
+			if !isPlainValue(field.ChildByFieldName("value")) {
+				return false
+			}
+		default:
+			if !isComment(field) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isFieldOf reports whether an expression selects a field of the named object: r.x in Go,
+// self.x in Python and Rust, this.x in JavaScript
+func (cv *ChunkVisitor) isFieldOf(node *tree_sitter.Node, object string) bool {
+	if node == nil {
+		return false
+	}
+	var operand, field *tree_sitter.Node
+	switch node.Kind() {
+	case "selector_expression":
+		operand, field = node.ChildByFieldName("operand"), node.ChildByFieldName("field")
+	case "attribute":
+		operand, field = node.ChildByFieldName("object"), node.ChildByFieldName("attribute")
+	case "member_expression":
+		operand, field = node.ChildByFieldName("object"), node.ChildByFieldName("property")
+	case "field_expression":
+		operand, field = node.ChildByFieldName("value"), node.ChildByFieldName("field")
+	default:
+		return false
+	}
+	return operand != nil && field != nil && cv.getNodeText(operand) == object
+}
+
+// isPlainValue reports whether an expression is a name, a literal or an empty collection
+// literal, i.e. a value copied rather than computed
+func isPlainValue(node *tree_sitter.Node) bool {
+	if node == nil {
+		return false
+	}
+	switch node.Kind() {
+	case "literal_element": // Go keyed and positional struct literal values
+		return isPlainValue(firstNamedChild(node))
+	case "identifier", "this",
+		"int_literal", "float_literal", "interpreted_string_literal", "raw_string_literal", "rune_literal",
+		"nil", "true", "false", "none", "null", "undefined",
+		"integer", "float", "string", "number", "template_string",
+		"integer_literal", "string_literal", "char_literal", "boolean_literal":
+		return true
+	case "list", "dictionary", "tuple", "set", "array", "object", "literal_value":
+		return node.NamedChildCount() == 0
+	case "composite_literal":
+		return len(namedChildren(node.ChildByFieldName("body"))) == 0
+	}
+	return false
+}
+
+// kindOf returns the kind of a node, or "" for a nil node
+func kindOf(node *tree_sitter.Node) string {
+	if node == nil {
+		return ""
+	}
+	return node.Kind()
+}
+
+// isComment reports whether a node is a comment in any supported grammar
+func isComment(node *tree_sitter.Node) bool {
+	switch node.Kind() {
+	case "comment", "line_comment", "block_comment":
+		return true
+	}
+	return false
+}
+
+// statements returns the statements of a block, without comments
+func statements(block *tree_sitter.Node) []*tree_sitter.Node {
+	var result []*tree_sitter.Node
+	for _, child := range namedChildren(block) {
+		if child.Kind() == "statement_list" { // Go blocks wrap their statements
+			result = append(result, statements(child)...)
+		} else if !isComment(child) {
+			result = append(result, child)
+		}
+	}
+	return result
+}
+
+// namedChildren returns the named children of a node, or nil for a nil node
+func namedChildren(node *tree_sitter.Node) []*tree_sitter.Node {
+	if node == nil {
+		return nil
+	}
+	children := make([]*tree_sitter.Node, 0, node.NamedChildCount())
+	for i := uint(0); i < node.NamedChildCount(); i++ {
+		children = append(children, node.NamedChild(i))
+	}
+	return children
+}
+
+// firstNamedChild returns the first named child that is not a comment, or nil
+func firstNamedChild(node *tree_sitter.Node) *tree_sitter.Node {
+	for _, child := range namedChildren(node) {
+		if !isComment(child) {
+			return child
+		}
+	}
+	return nil
+}
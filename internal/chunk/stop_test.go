@@ -0,0 +1,271 @@
+package chunk
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"bot-go/internal/model"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	golang "github.com/tree-sitter/tree-sitter-go/bindings/go"
+	java "github.com/tree-sitter/tree-sitter-java/bindings/go"
+	javascript "github.com/tree-sitter/tree-sitter-javascript/bindings/go"
+	python "github.com/tree-sitter/tree-sitter-python/bindings/go"
+	rust "github.com/tree-sitter/tree-sitter-rust/bindings/go"
+	typescript "github.com/tree-sitter/tree-sitter-typescript/bindings/go"
+	"go.uber.org/zap"
+)
+
+// chunkWithStop chunks source with every stop chunk kind set and returns the names of the
+// function chunks, the file chunk and what was skipped
+func chunkWithStop(t *testing.T, language string, tsLanguage *tree_sitter.Language, source string) ([]string, *model.CodeChunk, map[string]int) {
+	t.Helper()
+	parser := tree_sitter.NewParser()
+	defer parser.Close()
+	if err := parser.SetLanguage(tsLanguage); err != nil {
+		t.Fatal(err)
+	}
+	tree := parser.Parse([]byte(source), nil)
+	defer tree.Close()
+
+	stop, err := NewStopSet(StopChunkKinds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	visitor := NewChunkVisitor(zap.NewNop(), language, "src", []byte(source), 5, 5)
+	visitor.SetStopChunks(stop)
+	visitor.TraverseNode(context.Background(), tree.RootNode(), nil)
+
+	var functions []string
+	var file *model.CodeChunk
+	for _, chunk := range visitor.GetChunks() {
+		switch chunk.ChunkType {
+		case model.ChunkTypeFunction:
+			functions = append(functions, chunk.Name)
+		case model.ChunkTypeFile:
+			file = chunk
+		}
+	}
+	sort.Strings(functions)
+	return functions, file, visitor.Stopped()
+}
+
+func TestStopChunksGo(t *testing.T) {
+	source := `// Copyright 2026 Example Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package store
+
+type Store struct{ name string; size int }
+
+func NewStore(name string) *Store {
+	return &Store{name: name, size: 0}
+}
+
+func NewDefault() *Store {
+	return &Store{name: defaultName()}
+}
+
+func (s *Store) Name() string {
+	return s.name
+}
+
+func (s *Store) SetName(name string) {
+	s.name = name
+}
+
+func (s *Store) Grow(n int) {
+	s.size += n
+}
+
+func (s *Store) Other(o *Store) string {
+	return o.name
+}
+`
+	functions, file, stopped := chunkWithStop(t, "go", tree_sitter.NewLanguage(golang.Language()), source)
+	if want := []string{"Grow", "NewDefault", "Other"}; !reflect.DeepEqual(functions, want) {
+		t.Errorf("functions = %v, want %v", functions, want)
+	}
+	if want := map[string]int{StopGetters: 1, StopSetters: 1, StopConstructors: 1, StopLicenseHeaders: 1}; !reflect.DeepEqual(stopped, want) {
+		t.Errorf("stopped = %v, want %v", stopped, want)
+	}
+	if !strings.HasPrefix(file.Content, "package store") {
+		t.Errorf("file chunk starts with %q, want the license header removed", file.Content[:20])
+	}
+}
+
+func TestStopChunksPython(t *testing.T) {
+	source := `# Geometry helpers
+
+class Point:
+    def __init__(self, x, y):
+        """Create a point."""
+        self.x = x
+        self.y = y
+        self.tags = []
+
+    @property
+    def x_value(self):
+        return self.x
+
+    def set_x(self, x):
+        self.x = x
+
+    def length(self):
+        return (self.x ** 2 + self.y ** 2) ** 0.5
+
+
+def name(obj):
+    return obj.name
+`
+	functions, file, stopped := chunkWithStop(t, "python", tree_sitter.NewLanguage(python.Language()), source)
+	if want := []string{"length", "name"}; !reflect.DeepEqual(functions, want) {
+		t.Errorf("functions = %v, want %v", functions, want)
+	}
+	if want := map[string]int{StopGetters: 1, StopSetters: 1, StopConstructors: 1}; !reflect.DeepEqual(stopped, want) {
+		t.Errorf("stopped = %v, want %v", stopped, want)
+	}
+	if !strings.HasPrefix(file.Content, "# Geometry helpers") {
+		t.Errorf("file chunk starts with %q, want the comment kept", file.Content[:20])
+	}
+}
+
+func TestStopChunksJava(t *testing.T) {
+	source := `/*
+ * Licensed under the MIT License.
+ */
+package com.example;
+
+public class Account {
+    private long balance;
+
+    public long getBalance() {
+        return balance;
+    }
+
+    public void setBalance(long balance) {
+        this.balance = balance;
+    }
+
+    public void deposit(long amount) {
+        this.balance = this.balance + amount;
+    }
+}
+`
+	functions, file, stopped := chunkWithStop(t, "java", tree_sitter.NewLanguage(java.Language()), source)
+	if want := []string{"deposit"}; !reflect.DeepEqual(functions, want) {
+		t.Errorf("functions = %v, want %v", functions, want)
+	}
+	if want := map[string]int{StopGetters: 1, StopSetters: 1, StopLicenseHeaders: 1}; !reflect.DeepEqual(stopped, want) {
+		t.Errorf("stopped = %v, want %v", stopped, want)
+	}
+	if !strings.HasPrefix(file.Content, "package com.example") {
+		t.Errorf("file chunk starts with %q, want the license header removed", file.Content[:20])
+	}
+}
+
+func TestStopChunksJavaScript(t *testing.T) {
+	source := `class User {
+  constructor(name, email) {
+    this.name = name;
+    this.email = email;
+  }
+
+  get displayName() {
+    return this.name;
+  }
+
+  set displayName(value) {
+    this.name = value;
+  }
+
+  greet() {
+    return "Hello, " + this.name;
+  }
+}
+`
+	functions, _, stopped := chunkWithStop(t, "javascript", tree_sitter.NewLanguage(javascript.Language()), source)
+	if want := []string{"greet"}; !reflect.DeepEqual(functions, want) {
+		t.Errorf("functions = %v, want %v", functions, want)
+	}
+	if want := map[string]int{StopGetters: 1, StopSetters: 1, StopConstructors: 1}; !reflect.DeepEqual(stopped, want) {
+		t.Errorf("stopped = %v, want %v", stopped, want)
+	}
+}
+
+func TestStopChunksTypeScript(t *testing.T) {
+	source := `class Repo {
+  constructor(private readonly db: Database) {}
+
+  getDb(): Database {
+    return this.db;
+  }
+
+  setLimit(limit: number): void {
+    this.limit = limit;
+  }
+}
+`
+	functions, _, stopped := chunkWithStop(t, "typescript", tree_sitter.NewLanguage(typescript.LanguageTypescript()), source)
+	if len(functions) != 0 {
+		t.Errorf("functions = %v, want none", functions)
+	}
+	if want := map[string]int{StopGetters: 1, StopSetters: 1, StopConstructors: 1}; !reflect.DeepEqual(stopped, want) {
+		t.Errorf("stopped = %v, want %v", stopped, want)
+	}
+}
+
+func TestStopChunksRust(t *testing.T) {
+	source := `// Copyright 2026 Example Authors.
+// SPDX-License-Identifier: MIT
+
+pub struct Store {
+    name: String,
+    size: usize,
+}
+
+impl Store {
+    pub fn new(name: String) -> Self {
+        Self { name, size: 0 }
+    }
+
+    pub fn name(&self) -> &str {
+        &self.name
+    }
+
+    pub fn set_name(&mut self, name: String) {
+        self.name = name;
+    }
+
+    /// Grows the store by n items
+    pub fn grow(&mut self, n: usize) {
+        self.size += n;
+    }
+}
+`
+	functions, file, stopped := chunkWithStop(t, "rust", tree_sitter.NewLanguage(rust.Language()), source)
+	if want := []string{"grow"}; !reflect.DeepEqual(functions, want) {
+		t.Errorf("functions = %v, want %v", functions, want)
+	}
+	if want := map[string]int{StopGetters: 1, StopSetters: 1, StopConstructors: 1, StopLicenseHeaders: 1}; !reflect.DeepEqual(stopped, want) {
+		t.Errorf("stopped = %v, want %v", stopped, want)
+	}
+	if !strings.HasPrefix(file.Content, "pub struct Store") {
+		t.Errorf("file chunk starts with %q, want the license header removed", file.Content[:20])
+	}
+}
+
+func TestNewStopSet(t *testing.T) {
+	if set, err := NewStopSet([]string{StopNone}); err != nil || len(set) != 0 {
+		t.Errorf("NewStopSet(none) = %v, %v", set, err)
+	}
+	if _, err := NewStopSet([]string{StopNone, StopGetters}); err == nil {
+		t.Error("NewStopSet accepted none with other kinds")
+	}
+	if _, err := NewStopSet([]string{"tests"}); err == nil {
+		t.Error("NewStopSet accepted an unknown kind")
+	}
+}
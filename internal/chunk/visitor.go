@@ -2,6 +2,7 @@ package chunk
 
 import (
 	"bot-go/internal/model"
+	"bot-go/internal/util"
 	"bot-go/pkg/lsp/base"
 	"context"
 	"crypto/sha256"
@@ -20,12 +21,15 @@ type ChunkVisitor struct {
 	language            string
 	filePath            string
 	sourceCode          []byte
+	lineIndex           *util.LineIndex
 	chunks              []*model.CodeChunk
 	currentFile         *model.CodeChunk
 	currentClass        *model.CodeChunk
 	moduleName          string
 	minConditionalLines int
 	minLoopLines        int
+	stop                StopSet        // boilerplate kinds not chunked
+	stopped             map[string]int // boilerplate skipped, by kind
 }
 
 // NewChunkVisitor creates a new chunk visitor
@@ -35,17 +39,30 @@ func NewChunkVisitor(logger *zap.Logger, language, filePath string, sourceCode [
 		language:            language,
 		filePath:            filePath,
 		sourceCode:          sourceCode,
+		lineIndex:           util.NewLineIndex(sourceCode),
 		chunks:              make([]*model.CodeChunk, 0),
 		minConditionalLines: minConditionalLines,
 		minLoopLines:        minLoopLines,
+		stopped:             make(map[string]int),
 	}
 }
 
+// SetStopChunks makes the visitor skip the boilerplate of the given kinds: functions are
+// left out, license headers removed from the file chunk
+func (cv *ChunkVisitor) SetStopChunks(stop StopSet) {
+	cv.stop = stop
+}
+
 // GetChunks returns all collected code chunks
 func (cv *ChunkVisitor) GetChunks() []*model.CodeChunk {
 	return cv.chunks
 }
 
+// Stopped returns how much boilerplate was skipped, by stop chunk kind
+func (cv *ChunkVisitor) Stopped() map[string]int {
+	return cv.stopped
+}
+
 // TraverseNode is the main entry point for traversing syntax tree nodes
 func (cv *ChunkVisitor) TraverseNode(ctx context.Context, tsNode *tree_sitter.Node, scopeID any) any {
 	if tsNode == nil {
@@ -64,6 +81,8 @@ func (cv *ChunkVisitor) TraverseNode(ctx context.Context, tsNode *tree_sitter.No
 		return cv.traverseJavaNode(ctx, tsNode, kind)
 	case "javascript", "typescript":
 		return cv.traverseJavaScriptNode(ctx, tsNode, kind)
+	case "rust":
+		return cv.traverseRustNode(ctx, tsNode, kind)
 	default:
 		// Fallback: traverse children
 		cv.traverseChildren(ctx, tsNode)
@@ -183,9 +202,40 @@ func (cv *ChunkVisitor) traverseJavaScriptNode(ctx context.Context, tsNode *tree
 	return nil
 }
 
+// Rust-specific node handling
+func (cv *ChunkVisitor) traverseRustNode(ctx context.Context, tsNode *tree_sitter.Node, kind string) any {
+	switch kind {
+	case "source_file":
+		return cv.handleSourceFile(ctx, tsNode)
+	case "struct_item", "enum_item", "trait_item":
+		return cv.handleRustType(ctx, tsNode, cv.getChildByFieldName(tsNode, "name"))
+	case "impl_item":
+		return cv.handleRustType(ctx, tsNode, cv.getChildByFieldName(tsNode, "type"))
+	case "function_item":
+		return cv.handleRustFunction(ctx, tsNode)
+	case "if_expression":
+		return cv.handleConditional(ctx, tsNode, "if")
+	case "match_expression":
+		return cv.handleConditional(ctx, tsNode, "match")
+	case "for_expression":
+		return cv.handleLoop(ctx, tsNode, "for")
+	case "while_expression":
+		return cv.handleLoop(ctx, tsNode, "while")
+	case "loop_expression":
+		return cv.handleLoop(ctx, tsNode, "loop")
+	}
+
+	cv.traverseChildren(ctx, tsNode)
+	return nil
+}
+
 // handleSourceFile creates a file-level chunk
 func (cv *ChunkVisitor) handleSourceFile(ctx context.Context, tsNode *tree_sitter.Node) any {
 	content := cv.getNodeText(tsNode)
+	if end := cv.licenseHeaderEnd(tsNode); end > 0 {
+		content = strings.TrimLeft(content[end-tsNode.StartByte():], " \t\r\n")
+		cv.stopped[StopLicenseHeaders]++
+	}
 	rng := cv.toRange(tsNode)
 
 	chunkID := cv.generateChunkID(cv.filePath, "file", 0)
@@ -201,7 +251,9 @@ func (cv *ChunkVisitor) handleSourceFile(ctx context.Context, tsNode *tree_sitte
 	).WithName(cv.filePath)
 
 	cv.currentFile = chunk
-	cv.chunks = append(cv.chunks, chunk)
+	if content != "" {
+		cv.chunks = append(cv.chunks, chunk)
+	}
 
 	cv.traverseChildren(ctx, tsNode)
 	return chunk
@@ -215,6 +267,10 @@ func (cv *ChunkVisitor) handleFunctionDeclaration(ctx context.Context, tsNode *t
 	}
 
 	name := cv.getNodeText(nameNode)
+	if kind := cv.stopFunctionKind(tsNode, name); kind != "" {
+		cv.stopped[kind]++
+		return nil
+	}
 	content := cv.getNodeText(tsNode)
 	signature := cv.extractGoFunctionSignature(tsNode)
 	docstring := cv.extractGoDocstring(tsNode)
@@ -301,6 +357,10 @@ func (cv *ChunkVisitor) handlePythonFunction(ctx context.Context, tsNode *tree_s
 	}
 
 	name := cv.getNodeText(nameNode)
+	if kind := cv.stopFunctionKind(tsNode, name); kind != "" {
+		cv.stopped[kind]++
+		return nil
+	}
 	content := cv.getNodeText(tsNode)
 	signature := cv.extractPythonFunctionSignature(tsNode)
 	docstring := cv.extractPythonDocstring(tsNode)
@@ -385,6 +445,10 @@ func (cv *ChunkVisitor) handleJavaMethod(ctx context.Context, tsNode *tree_sitte
 	}
 
 	name := cv.getNodeText(nameNode)
+	if kind := cv.stopFunctionKind(tsNode, name); kind != "" {
+		cv.stopped[kind]++
+		return nil
+	}
 	content := cv.getNodeText(tsNode)
 	signature := cv.extractJavaMethodSignature(tsNode)
 
@@ -506,6 +570,10 @@ func (cv *ChunkVisitor) handleJSMethod(ctx context.Context, tsNode *tree_sitter.
 	}
 
 	name := cv.getNodeText(nameNode)
+	if kind := cv.stopFunctionKind(tsNode, name); kind != "" {
+		cv.stopped[kind]++
+		return nil
+	}
 	content := cv.getNodeText(tsNode)
 	signature := cv.extractJSFunctionSignature(tsNode)
 
@@ -541,6 +609,96 @@ func (cv *ChunkVisitor) handleJSMethod(ctx context.Context, tsNode *tree_sitter.
 	return chunk
 }
 
+// handleRustType handles Rust structs, enums, traits and impl blocks. An impl block is
+// named after the type it implements, and its functions are methods of that type.
+func (cv *ChunkVisitor) handleRustType(ctx context.Context, tsNode, nameNode *tree_sitter.Node) any {
+	if nameNode == nil {
+		return nil
+	}
+
+	name := cv.getNodeText(nameNode)
+	content := cv.getNodeText(tsNode)
+	docstring := cv.extractRustDocstring(tsNode)
+
+	chunkID := cv.generateChunkID(cv.filePath, name, tsNode.StartPosition().Row)
+
+	parentID := ""
+	if cv.currentFile != nil {
+		parentID = cv.currentFile.ID
+	}
+
+	chunk := model.NewCodeChunk(
+		chunkID,
+		model.ChunkTypeClass,
+		2,
+		content,
+		cv.language,
+		cv.filePath,
+		cv.toRange(tsNode),
+	).WithParent(parentID).
+		WithName(name).
+		WithDocstring(docstring).
+		WithContext(cv.moduleName, "")
+
+	oldClass := cv.currentClass
+	cv.currentClass = chunk
+	cv.chunks = append(cv.chunks, chunk)
+
+	cv.traverseChildren(ctx, tsNode)
+
+	cv.currentClass = oldClass
+	return chunk
+}
+
+// handleRustFunction handles Rust functions and methods
+func (cv *ChunkVisitor) handleRustFunction(ctx context.Context, tsNode *tree_sitter.Node) any {
+	nameNode := cv.getChildByFieldName(tsNode, "name")
+	if nameNode == nil {
+		return nil
+	}
+
+	name := cv.getNodeText(nameNode)
+	if kind := cv.stopFunctionKind(tsNode, name); kind != "" {
+		cv.stopped[kind]++
+		return nil
+	}
+	content := cv.getNodeText(tsNode)
+	signature := cv.extractRustFunctionSignature(tsNode)
+	docstring := cv.extractRustDocstring(tsNode)
+
+	chunkID := cv.generateChunkID(cv.filePath, name, tsNode.StartPosition().Row)
+
+	parentID := ""
+	className := ""
+	if cv.currentClass != nil {
+		parentID = cv.currentClass.ID
+		className = cv.currentClass.Name
+	} else if cv.currentFile != nil {
+		parentID = cv.currentFile.ID
+	}
+
+	chunk := model.NewCodeChunk(
+		chunkID,
+		model.ChunkTypeFunction,
+		3,
+		content,
+		cv.language,
+		cv.filePath,
+		cv.toRange(tsNode),
+	).WithParent(parentID).
+		WithName(name).
+		WithSignature(signature).
+		WithDocstring(docstring).
+		WithContext(cv.moduleName, className)
+
+	cv.chunks = append(cv.chunks, chunk)
+
+	// Traverse function body to find conditionals and loops
+	cv.traverseChildren(ctx, tsNode)
+
+	return chunk
+}
+
 // handleTypeDeclaration handles Go type declarations
 func (cv *ChunkVisitor) handleTypeDeclaration(ctx context.Context, tsNode *tree_sitter.Node) {
 	for i := uint(0); i < tsNode.ChildCount(); i++ {
@@ -609,15 +767,18 @@ func (cv *ChunkVisitor) getNodeText(tsNode *tree_sitter.Node) string {
 	return string(cv.sourceCode[startByte:endByte])
 }
 
+// toRange converts a node's tree-sitter (row, byte column) span to an LSP range with UTF-16 characters
 func (cv *ChunkVisitor) toRange(tsNode *tree_sitter.Node) base.Range {
+	startPos := tsNode.StartPosition()
+	endPos := tsNode.EndPosition()
 	return base.Range{
 		Start: base.Position{
-			Line:      int(tsNode.StartPosition().Row),
-			Character: int(tsNode.StartPosition().Column),
+			Line:      int(startPos.Row),
+			Character: cv.lineIndex.UTF16Column(int(startPos.Row), int(startPos.Column)),
 		},
 		End: base.Position{
-			Line:      int(tsNode.EndPosition().Row),
-			Character: int(tsNode.EndPosition().Column),
+			Line:      int(endPos.Row),
+			Character: cv.lineIndex.UTF16Column(int(endPos.Row), int(endPos.Column)),
 		},
 	}
 }
@@ -739,6 +900,42 @@ func (cv *ChunkVisitor) extractJSFunctionSignature(tsNode *tree_sitter.Node) str
 	return sig
 }
 
+func (cv *ChunkVisitor) extractRustFunctionSignature(tsNode *tree_sitter.Node) string {
+	nameNode := cv.getChildByFieldName(tsNode, "name")
+	paramsNode := cv.getChildByFieldName(tsNode, "parameters")
+	returnNode := cv.getChildByFieldName(tsNode, "return_type")
+
+	sig := ""
+	if nameNode != nil {
+		sig = cv.getNodeText(nameNode)
+	}
+	if paramsNode != nil {
+		sig += cv.getNodeText(paramsNode)
+	}
+	if returnNode != nil {
+		sig += " -> " + cv.getNodeText(returnNode)
+	}
+
+	return sig
+}
+
+// extractRustDocstring joins the /// doc comments right before an item, skipping its
+// attributes
+func (cv *ChunkVisitor) extractRustDocstring(tsNode *tree_sitter.Node) string {
+	var lines []string
+	for prev := tsNode.PrevSibling(); prev != nil; prev = prev.PrevSibling() {
+		if prev.Kind() == "attribute_item" {
+			continue
+		}
+		text := cv.getNodeText(prev)
+		if prev.Kind() != "line_comment" || !strings.HasPrefix(text, "///") {
+			break
+		}
+		lines = append([]string{strings.TrimSpace(strings.TrimPrefix(text, "///"))}, lines...)
+	}
+	return strings.Join(lines, "\n")
+}
+
 func (cv *ChunkVisitor) extractGoDocstring(tsNode *tree_sitter.Node) string {
 	// Go docstrings are comments immediately before the function
 	// This is a simplified implementation
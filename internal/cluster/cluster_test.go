@@ -0,0 +1,102 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"bot-go/internal/apperrors"
+
+	"go.uber.org/zap"
+)
+
+func TestLocalLocker(t *testing.T) {
+	ctx := context.Background()
+	locker := NewLocalLocker()
+	lock, err := locker.Lock(ctx, "build:shop", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := locker.Lock(ctx, "build:shop", 0); !errors.Is(err, ErrLocked) || !errors.Is(err, apperrors.ErrConflict) {
+		t.Errorf("second Lock() error = %v, want ErrLocked", err)
+	}
+	other, err := locker.Lock(ctx, "build:blog", 0)
+	if err != nil {
+		t.Fatalf("Lock() of another name error = %v", err)
+	}
+	other.Unlock()
+
+	// A waiting Lock gets the lock once it is released
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		lock.Unlock()
+		lock.Unlock() // releasing twice is harmless
+	}()
+	waited, err := locker.Lock(ctx, "build:shop", time.Second)
+	if err != nil {
+		t.Fatalf("waiting Lock() error = %v", err)
+	}
+	if _, err := locker.Lock(ctx, "build:shop", 10*time.Millisecond); !errors.Is(err, ErrLocked) {
+		t.Errorf("Lock() after the wait error = %v, want ErrLocked", err)
+	}
+	waited.Unlock()
+}
+
+func TestLockName(t *testing.T) {
+	if got := lockName("build:shop"); got != "bot-go:build:shop" {
+		t.Errorf("lockName() = %q", got)
+	}
+	long := lockName("build:" + strings.Repeat("x", 100))
+	if len(long) != maxLockName || !strings.HasPrefix(long, "bot-go:") {
+		t.Errorf("lockName() of a long name = %q (%d bytes)", long, len(long))
+	}
+}
+
+func TestSchedulerFailover(t *testing.T) {
+	locker := NewLocalLocker()
+	var runs [2]atomic.Int32
+	var schedulers [2]*Scheduler
+	var cancels [2]context.CancelFunc
+	for i := range schedulers {
+		schedulers[i] = NewScheduler(locker, 10*time.Millisecond, zap.NewNop())
+		schedulers[i].Every("count", 5*time.Millisecond, func(ctx context.Context) error {
+			runs[i].Add(1)
+			return nil
+		})
+		var ctx context.Context
+		ctx, cancels[i] = context.WithCancel(context.Background())
+		defer cancels[i]()
+		schedulers[i].Start(ctx)
+		if i == 0 {
+			waitUntil(t, schedulers[0].Leader)
+		}
+	}
+
+	waitUntil(t, func() bool { return runs[0].Load() >= 2 })
+	if schedulers[1].Leader() || runs[1].Load() != 0 {
+		t.Fatal("expected only the first scheduler to run jobs")
+	}
+
+	// The second takes over once the leader stops
+	cancels[0]()
+	waitUntil(t, schedulers[1].Leader)
+	waitUntil(t, func() bool { return runs[1].Load() >= 2 })
+	if schedulers[0].Leader() {
+		t.Error("expected the stopped scheduler to step down")
+	}
+}
+
+// waitUntil polls cond for up to two seconds
+func waitUntil(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
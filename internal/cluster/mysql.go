@@ -0,0 +1,91 @@
+package cluster
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// maxLockName is the longest name GET_LOCK accepts
+const maxLockName = 64
+
+// MySQLLocker holds locks with GET_LOCK. Each lock keeps a connection of the pool until it is
+// released; MySQL releases the locks of an instance that dies with its connections.
+type MySQLLocker struct {
+	db *sql.DB
+}
+
+// NewMySQLLocker returns a locker over the connections of db
+func NewMySQLLocker(db *sql.DB) *MySQLLocker {
+	return &MySQLLocker{db: db}
+}
+
+// lockName namespaces a name, hashing names too long for GET_LOCK
+func lockName(name string) string {
+	if full := "bot-go:" + name; len(full) <= maxLockName {
+		return full
+	}
+	sum := sha256.Sum256([]byte(name))
+	return "bot-go:" + hex.EncodeToString(sum[:])[:maxLockName-len("bot-go:")]
+}
+
+func (m *MySQLLocker) Lock(ctx context.Context, name string, wait time.Duration) (Lock, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to take lock %s: %w", name, err)
+	}
+	var got sql.NullInt64
+	err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName(name), int(math.Ceil(wait.Seconds()))).Scan(&got)
+	switch {
+	case err != nil:
+		conn.Close()
+		return nil, fmt.Errorf("failed to take lock %s: %w", name, err)
+	case !got.Valid:
+		conn.Close()
+		return nil, fmt.Errorf("failed to take lock %s: GET_LOCK returned NULL", name)
+	case got.Int64 == 0:
+		conn.Close()
+		return nil, ErrLocked
+	}
+	return &mysqlLock{conn: conn, name: lockName(name)}, nil
+}
+
+type mysqlLock struct {
+	mu   sync.Mutex
+	conn *sql.Conn // nil once released
+	name string
+}
+
+// Held checks that the connection holding the lock is still its owner
+func (l *mysqlLock) Held(ctx context.Context) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conn == nil {
+		return false
+	}
+	var owned sql.NullBool
+	err := l.conn.QueryRowContext(ctx, "SELECT IS_USED_LOCK(?) = CONNECTION_ID()", l.name).Scan(&owned)
+	return err == nil && owned.Valid && owned.Bool
+}
+
+func (l *mysqlLock) Unlock() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conn == nil {
+		return nil
+	}
+	// Closing the connection releases the lock even if RELEASE_LOCK fails
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := l.conn.ExecContext(ctx, "DO RELEASE_LOCK(?)", l.name)
+	if closeErr := l.conn.Close(); err == nil {
+		err = closeErr
+	}
+	l.conn = nil
+	return err
+}
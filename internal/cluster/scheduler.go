@@ -0,0 +1,131 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// leaderLock is held by the instance running the scheduled jobs
+	leaderLock = "scheduler"
+	// defaultLeaderCheck is how often instances contend for leadership and the leader checks
+	// that it still holds the lock
+	defaultLeaderCheck = 15 * time.Second
+)
+
+// Scheduler runs periodic jobs on one instance of a deployment: the holder of the scheduler
+// lock. The other instances try to take the lock every check interval, so the jobs move to
+// another instance within about that interval after the leader stops.
+type Scheduler struct {
+	locker Locker
+	check  time.Duration
+	logger *zap.Logger
+	jobs   []job
+
+	mu     sync.Mutex
+	leader bool
+}
+
+type job struct {
+	name     string
+	interval time.Duration
+	run      func(ctx context.Context) error
+}
+
+// NewScheduler returns a scheduler contending for leadership through locker every check
+// interval (default 15s)
+func NewScheduler(locker Locker, check time.Duration, logger *zap.Logger) *Scheduler {
+	if check <= 0 {
+		check = defaultLeaderCheck
+	}
+	return &Scheduler{locker: locker, check: check, logger: logger}
+}
+
+// Every adds a job run every interval while this instance leads. It must be called before
+// Start.
+func (s *Scheduler) Every(name string, interval time.Duration, run func(ctx context.Context) error) {
+	s.jobs = append(s.jobs, job{name: name, interval: interval, run: run})
+}
+
+// Leader reports whether this instance runs the jobs
+func (s *Scheduler) Leader() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.leader
+}
+
+// Start contends for leadership until ctx is done, running the jobs while leading
+func (s *Scheduler) Start(ctx context.Context) {
+	go func() {
+		var lock Lock
+		var stopJobs context.CancelFunc
+		stepDown := func() {
+			s.setLeader(false)
+			stopJobs()
+			lock.Unlock()
+			lock = nil
+		}
+		ticker := time.NewTicker(s.check)
+		defer ticker.Stop()
+		for {
+			switch {
+			case lock == nil:
+				var err error
+				lock, err = s.locker.Lock(ctx, leaderLock, 0)
+				if err == nil {
+					stopJobs = s.lead(ctx)
+				} else if !errors.Is(err, ErrLocked) && ctx.Err() == nil {
+					s.logger.Warn("Failed to contend for the scheduler", zap.Error(err))
+				}
+			case !lock.Held(ctx) && ctx.Err() == nil:
+				s.logger.Warn("Lost the scheduler lock; stopping scheduled jobs")
+				stepDown()
+			}
+			select {
+			case <-ctx.Done():
+				if lock != nil {
+					stepDown()
+				}
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// lead starts the jobs, returning the function stopping them
+func (s *Scheduler) lead(ctx context.Context) context.CancelFunc {
+	s.setLeader(true)
+	s.logger.Info("Elected to run scheduled jobs", zap.Int("jobs", len(s.jobs)))
+	jobsCtx, cancel := context.WithCancel(ctx)
+	for _, j := range s.jobs {
+		go s.runJob(jobsCtx, j)
+	}
+	return cancel
+}
+
+func (s *Scheduler) setLeader(leader bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.leader = leader
+}
+
+// runJob runs a job every interval until ctx is cancelled
+func (s *Scheduler) runJob(ctx context.Context, j job) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := j.run(ctx); err != nil && ctx.Err() == nil {
+				s.logger.Warn("Scheduled job failed", zap.String("job", j.name), zap.Error(err))
+			}
+		}
+	}
+}
@@ -2,8 +2,11 @@ package codeapi
 
 import (
 	"context"
+	"time"
 
 	"bot-go/internal/model/ast"
+	"bot-go/internal/service/codegraph"
+	"bot-go/pkg/lsp/base"
 )
 
 // GraphAnalyzer provides graph traversal operations on the code graph.
@@ -70,6 +73,134 @@ type GraphAnalyzer interface {
 
 	// GetImpactByName is a convenience method for impact analysis by name.
 	GetImpactByName(ctx context.Context, repoName, filePath, name string, nodeType ast.NodeType, opts ImpactOptions) (*ImpactResult, error)
+
+	// GetChangeImpact runs impact analysis for every function of the given files (repository
+	// relative paths, latest indexed version) and merges the results.
+	GetChangeImpact(ctx context.Context, repoName string, filePaths []string, opts ImpactOptions) (*ChangeImpact, error)
+
+	// FindCallPaths returns the shortest call chains through which one function reaches
+	// another, e.g. how a request handler ends up calling a database write.
+	FindCallPaths(ctx context.Context, fromID, toID ast.NodeID, opts CallPathOptions) (*CallPaths, error)
+
+	// --- Duplicate Detection ---
+
+	// FindDuplicateFunctions groups functions in a repository whose normalized AST
+	// fingerprints are identical (same structure, identifiers and literals abstracted).
+	FindDuplicateFunctions(ctx context.Context, repoName string, opts DuplicateOptions) ([]*DuplicateGroup, error)
+
+	// --- History ---
+
+	// GetNodeCommits returns the commits that changed a FileScope, Function or Class node,
+	// newest first. Only commits of repoName are returned. Needs commit ingestion
+	// (git_analysis.commits).
+	GetNodeCommits(ctx context.Context, repoName string, nodeID ast.NodeID, limit int) ([]codegraph.Commit, error)
+
+	// --- Error Resolution ---
+
+	// ResolveErrorLocation returns the code locations a runtime log line or stack trace most
+	// likely comes from, best first. It combines string literals matching the message (needs
+	// the string literal index), the frames of the trace and the call graph between them.
+	ResolveErrorLocation(ctx context.Context, repoName, text string, limit int) (*ErrorResolution, error)
+
+	// SymbolicateStackTrace maps the frames of a Go, Python, Java or Node stack trace to the
+	// functions containing their lines, across all indexed repositories and file versions.
+	SymbolicateStackTrace(ctx context.Context, text string, opts SymbolicateOptions) (*SymbolicatedTrace, error)
+}
+
+// StackFrame is a frame parsed from a stack trace or a file:line reference in a log line
+type StackFrame struct {
+	Function string // as printed, e.g. main.(*Server).handle; empty when only file:line is known
+	File     string
+	Line     int // 1-based
+}
+
+// ErrorResolution is the result of resolving a log line or stack trace to code
+type ErrorResolution struct {
+	Message   string       // the text left after removing stack frames
+	Frames    []StackFrame // innermost first
+	Locations []*ErrorLocation
+}
+
+// SymbolicateOptions controls stack trace symbolication
+type SymbolicateOptions struct {
+	Repos         []string // repositories to search, empty = all
+	Commit        string   // prefer file versions indexed at this commit (a prefix is enough)
+	MaxCandidates int      // alternative locations per frame (default 5)
+}
+
+// SymbolicatedTrace is a stack trace with its frames mapped to code
+type SymbolicatedTrace struct {
+	Message        string // the text left after removing stack frames
+	Frames         []*SymbolicatedFrame
+	ResolvedFrames int
+}
+
+// SymbolicatedFrame is a stack frame with the code locations it may refer to
+type SymbolicatedFrame struct {
+	StackFrame
+	Resolved   *FrameLocation   // best match, nil when no indexed file matches
+	Candidates []*FrameLocation // other repositories, paths and versions
+}
+
+// FrameLocation is a function (or, outside functions, a file) a stack frame refers to in one
+// indexed version of a file
+type FrameLocation struct {
+	RepoName string
+	FilePath string
+	FileID   int32
+	Commit   string // empty for versions indexed without a commit
+
+	FunctionID   ast.NodeID // InvalidNodeID when no function matches
+	FunctionName string
+	ClassName    string
+	Range        base.Range
+
+	ContainsLine bool // the function contains the frame's line
+	NameMatch    bool // the function has the frame's function name
+	CommitMatch  bool // the file version is at SymbolicateOptions.Commit
+	Latest       bool // the latest indexed version of the file
+
+	// Last change of the function per git blame, empty without blame metadata
+	LastModifiedBy    string
+	LastModifiedEmail string
+	LastModifiedAt    int64
+
+	// Set by the API layer from the repository configuration
+	Owners    []string // CODEOWNERS owners of the file
+	SourceURL string   // link to the line on the repository host
+}
+
+// ErrorLocation is a candidate source location of an error
+type ErrorLocation struct {
+	FilePath     string
+	Range        base.Range
+	FunctionID   ast.NodeID // InvalidNodeID outside functions
+	FunctionName string
+	Literal      string   // the matching string literal, if any
+	Confidence   float64  // 0..1
+	Evidence     []string // EvidenceLiteral, EvidenceFrame, EvidenceCallGraph
+}
+
+// DuplicateOptions controls duplicate function detection
+type DuplicateOptions struct {
+	MinSize  int // ignore functions with fewer AST nodes than this (filters trivial getters)
+	MinCount int // minimum group size to report (default 2)
+	Limit    int // max number of groups to return (0 = unlimited)
+}
+
+// DefaultDuplicateOptions returns sensible defaults for duplicate detection
+func DefaultDuplicateOptions() DuplicateOptions {
+	return DuplicateOptions{
+		MinSize:  20,
+		MinCount: 2,
+	}
+}
+
+// DuplicateGroup is a set of functions sharing the same normalized AST fingerprint
+type DuplicateGroup struct {
+	Fingerprint string
+	Size        int // number of AST nodes in each function
+	Functions   []*MethodInfo
 }
 
 // FieldAccessResult contains methods that access a field
@@ -93,6 +224,12 @@ type ImpactOptions struct {
 	IncludeDataFlow  bool // include data dependents in impact
 	IncludeTests     bool // include test files
 	Scope            ImpactScope
+	ModifiedSince    time.Time      // keep only affected nodes last changed at or after this time (needs blame metadata)
+	Scoring          *ImpactScoring // ranking weights; nil = DefaultImpactScoring()
+	// ProjectRepos are the repositories of the project the analysis is scoped to. When set,
+	// callers are also followed into the other repositories of the project (see
+	// crossRepoCallers), affected nodes outside them are dropped, and nodes carry their Repo.
+	ProjectRepos []string
 }
 
 // ImpactScope defines the boundary for impact analysis
@@ -130,12 +267,48 @@ type ImpactResult struct {
 	// AffectedByDataFlow are nodes affected via data dependencies
 	AffectedByDataFlow []*ImpactNode
 
+	// Owners are the authors who last changed the affected nodes, most nodes first
+	// (needs blame metadata, see git_analysis.blame)
+	Owners []ImpactOwner
+
+	// Ranked are the affected nodes by descending Score
+	Ranked []*ImpactNode
+
+	// RiskScore sums the scores of the affected nodes; RiskLevel is "low", "medium" or "high"
+	// per the scoring thresholds
+	RiskScore float64
+	RiskLevel string
+
 	// Summary statistics
 	TotalAffected   int
 	MaxDepthReached int
 	Truncated       bool
 }
 
+// ChangeImpact is the merged impact of changing a set of files
+type ChangeImpact struct {
+	ChangedFiles []string
+	Functions    int  // functions of the changed files that were analyzed
+	Truncated    bool // true if the changed files had more than maxChangeImpactFunctions functions
+
+	// Affected are the nodes affected by any changed function, by descending score. A node
+	// reached from several functions keeps its highest score. FilePath is always set.
+	Affected []*ImpactNode
+
+	// Owners are the authors who last changed the affected nodes (needs blame metadata)
+	Owners []ImpactOwner
+
+	RiskScore float64
+	RiskLevel string
+}
+
+// ImpactOwner is an author who last changed some of the affected nodes
+type ImpactOwner struct {
+	Author string
+	Email  string
+	Nodes  int
+}
+
 // ImpactNode represents a node in the impact analysis
 type ImpactNode struct {
 	ID       ast.NodeID
@@ -143,8 +316,16 @@ type ImpactNode struct {
 	NodeType ast.NodeType
 	FilePath string
 	FileID   int32
+	Repo     string // set by project-scoped analysis
 	Depth    int
 	Impact   ImpactType // how this node is affected
+
+	// Last change of the node per git blame, empty without blame metadata
+	LastModifiedBy string
+	LastModifiedAt int64 // unix seconds
+
+	FanIn int     // number of functions calling this node (functions only)
+	Score float64 // weight in the impact ranking, see ImpactScoring
 }
 
 // ImpactType describes how a node is affected
@@ -155,4 +336,5 @@ const (
 	ImpactTypeTransitive ImpactType = "transitive" // indirectly affected
 	ImpactTypeCallGraph  ImpactType = "call_graph" // affected via call relationship
 	ImpactTypeDataFlow   ImpactType = "data_flow"  // affected via data dependency
+	ImpactTypeCrossRepo  ImpactType = "cross_repo" // calls the node from another repository of the project
 )
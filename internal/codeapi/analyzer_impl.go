@@ -3,9 +3,13 @@ package codeapi
 import (
 	"context"
 	"fmt"
+	"sort"
+	"time"
 
+	"bot-go/internal/apperrors"
 	"bot-go/internal/model/ast"
 	"bot-go/internal/service/codegraph"
+	"bot-go/internal/util"
 
 	"go.uber.org/zap"
 )
@@ -47,25 +51,52 @@ func (a *graphAnalyzerImpl) GetCallGraph(ctx context.Context, functionID ast.Nod
 	result.Root = rootNode
 	result.Nodes[functionID] = rootNode
 
-	// Traverse based on direction
+	// Traverse breadth first from the root, or from where the previous page stopped
 	visited := make(map[ast.NodeID]bool)
 	visited[functionID] = true
-
-	switch opts.Direction {
-	case DirectionOutgoing:
-		err = a.traverseCallees(ctx, functionID, 1, opts.MaxDepth, result, visited, opts)
-	case DirectionIncoming:
-		err = a.traverseCallers(ctx, functionID, 1, opts.MaxDepth, result, visited, opts)
-	case DirectionBoth:
-		err = a.traverseCallees(ctx, functionID, 1, opts.MaxDepth, result, visited, opts)
-		if err == nil {
-			err = a.traverseCallers(ctx, functionID, 1, opts.MaxDepth, result, visited, opts)
+	var queue []callFrontier
+	if opts.Continuation != "" {
+		cont, err := decodeCallGraphContinuation(opts.Continuation, functionID, opts.Direction)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range cont.Visited {
+			visited[id] = true
+		}
+		queue = cont.Pending
+	} else {
+		if opts.Direction != DirectionIncoming {
+			queue = append(queue, callFrontier{ID: functionID})
+		}
+		if opts.Direction != DirectionOutgoing {
+			queue = append(queue, callFrontier{ID: functionID, Incoming: true})
 		}
 	}
 
+	pending, err := a.traverseCalls(ctx, queue, result, visited, opts)
 	if err != nil {
 		return nil, err
 	}
+	if len(pending) > 0 {
+		result.Truncated = true
+		if len(visited) > maxContinuationNodes {
+			// Too many nodes to carry in a token: the caller has to narrow the query instead
+			return result, nil
+		}
+		cont := &callGraphContinuation{
+			Root:      functionID,
+			Direction: opts.Direction,
+			Pending:   pending,
+			Visited:   make([]ast.NodeID, 0, len(visited)),
+		}
+		for id := range visited {
+			cont.Visited = append(cont.Visited, id)
+		}
+		sort.Slice(cont.Visited, func(i, j int) bool { return cont.Visited[i] < cont.Visited[j] })
+		if result.Continuation, err = encodeCallGraphContinuation(cont); err != nil {
+			return nil, err
+		}
+	}
 
 	return result, nil
 }
@@ -94,120 +125,157 @@ func (a *graphAnalyzerImpl) GetCallees(ctx context.Context, functionID ast.NodeI
 	})
 }
 
-func (a *graphAnalyzerImpl) traverseCallees(ctx context.Context, functionID ast.NodeID, depth, maxDepth int, result *CallGraph, visited map[ast.NodeID]bool, opts CallGraphOptions) error {
-	if depth > maxDepth {
-		result.Truncated = true
-		return nil
-	}
-
-	// Query: function -[:CONTAINS]-> functionCall -[:CALLS_FUNCTION]-> callee
-	query := `
-		MATCH (f:Function {id: $functionId})-[:CONTAINS*]->(fc:FunctionCall)-[:CALLS_FUNCTION]->(callee:Function)
-		RETURN DISTINCT callee.id AS calleeId, callee.name AS calleeName,
-		       callee.fileId AS fileId, callee.range AS range,
-		       fc.id AS callSiteId, fc.range AS callSiteRange
-	`
-	records, err := a.graph.ExecuteRead(ctx, query, map[string]any{"functionId": int64(functionID)})
-	if err != nil {
-		return fmt.Errorf("failed to query callees: %w", err)
-	}
-
-	for _, record := range records {
-		calleeID := ast.NodeID(toInt64(record["calleeId"]))
-
-		// Add edge
-		result.Edges = append(result.Edges, &CallEdge{
-			CallerID: functionID,
-			CalleeID: calleeID,
-			CallSite: &Location{
-				FileID: int32(toInt64(record["fileId"])),
-				Range:  parseRange(toString(record["callSiteRange"])),
-			},
-		})
-
-		// Skip if already visited
-		if visited[calleeID] {
+// traverseCalls expands the queued functions breadth first until the queue is empty or a
+// cap in opts is reached, and returns the functions left to expand. Functions at MaxDepth
+// are returned unexpanded so a later page with a larger MaxDepth can continue from them.
+// A page that expands nothing returns nothing left, so resuming with the same MaxDepth ends.
+func (a *graphAnalyzerImpl) traverseCalls(ctx context.Context, queue []callFrontier, result *CallGraph, visited map[ast.NodeID]bool, opts CallGraphOptions) ([]callFrontier, error) {
+	var deferred []callFrontier
+	nodesAdded, expanded := 0, 0
+
+	for len(queue) > 0 {
+		item := queue[0]
+		if item.Depth >= opts.MaxDepth {
+			deferred = append(deferred, item)
+			queue = queue[1:]
 			continue
 		}
-		visited[calleeID] = true
 
-		// Add node
-		node := &CallNode{
-			ID:       calleeID,
-			Name:     toString(record["calleeName"]),
-			FileID:   int32(toInt64(record["fileId"])),
-			Depth:    depth,
+		limit := 0
+		if opts.MaxEdges > 0 {
+			limit = opts.MaxEdges - len(result.Edges)
+			if limit <= 0 {
+				break
+			}
 		}
-		if rangeStr := toString(record["range"]); rangeStr != "" {
-			node.Range = parseRange(rangeStr)
+		records, err := a.queryCalls(ctx, item, limit)
+		if err != nil {
+			return nil, err
 		}
-		result.Nodes[calleeID] = node
+		expanded++
 
-		// Recurse
-		if err := a.traverseCallees(ctx, calleeID, depth+1, maxDepth, result, visited, opts); err != nil {
-			return err
+		consumed := 0
+		for _, record := range records {
+			otherID := ast.NodeID(toInt64(record["otherId"]))
+			if !visited[otherID] && opts.MaxNodes > 0 && nodesAdded >= opts.MaxNodes {
+				break
+			}
+			consumed++
+
+			sites := callSiteLocations(record["callSites"])
+			edge := &CallEdge{
+				CallerID:  item.ID,
+				CalleeID:  otherID,
+				Count:     len(sites),
+				CallSites: sites,
+			}
+			if len(sites) > 0 {
+				edge.CallSite = sites[0]
+			}
+			if item.Incoming {
+				edge.CallerID, edge.CalleeID = otherID, item.ID
+			}
+			result.Edges = append(result.Edges, edge)
+
+			if visited[otherID] {
+				continue
+			}
+			visited[otherID] = true
+			nodesAdded++
+
+			depth := item.Depth + 1
+			node := &CallNode{
+				ID:     otherID,
+				Name:   toString(record["otherName"]),
+				FileID: int32(toInt64(record["fileId"])),
+				Depth:  depth,
+				Range:  codegraph.RangeFromValue(record["range"]),
+			}
+			if item.Incoming {
+				node.Depth = -depth // negative depth for callers
+			}
+			result.Nodes[otherID] = node
+			queue = append(queue, callFrontier{ID: otherID, Depth: depth, Incoming: item.Incoming})
 		}
-	}
 
-	return nil
-}
+		// A cap stopped this function part way: resume it after the records returned so far
+		if consumed < len(records) || (limit > 0 && len(records) == limit) {
+			queue[0].Skip += consumed
+			break
+		}
+		queue = queue[1:]
+	}
 
-func (a *graphAnalyzerImpl) traverseCallers(ctx context.Context, functionID ast.NodeID, depth, maxDepth int, result *CallGraph, visited map[ast.NodeID]bool, opts CallGraphOptions) error {
-	if depth > maxDepth {
-		result.Truncated = true
-		return nil
+	if expanded == 0 {
+		return nil, nil
 	}
+	return append(queue, deferred...), nil
+}
 
-	// Query: caller -[:CONTAINS]-> functionCall -[:CALLS_FUNCTION]-> function
+// queryCalls returns one record per function called by (or, for incoming frontiers,
+// calling) a function, with the list of call sites, in a stable order. It skips the records
+// returned on earlier pages; limit 0 means all.
+func (a *graphAnalyzerImpl) queryCalls(ctx context.Context, item callFrontier, limit int) ([]map[string]any, error) {
+	// function -[:CONTAINS]-> functionCall -[:CALLS_FUNCTION]-> callee
 	query := `
-		MATCH (caller:Function)-[:CONTAINS*]->(fc:FunctionCall)-[:CALLS_FUNCTION]->(f:Function {id: $functionId})
-		RETURN DISTINCT caller.id AS callerId, caller.name AS callerName,
-		       caller.fileId AS fileId, caller.range AS range,
-		       fc.id AS callSiteId, fc.range AS callSiteRange
+		MATCH (f:Function {id: $functionId})-[:CONTAINS*]->(fc:FunctionCall)-[:CALLS_FUNCTION]->(other:Function)
+	`
+	if item.Incoming {
+		query = `
+		MATCH (other:Function)-[:CONTAINS*]->(fc:FunctionCall)-[:CALLS_FUNCTION]->(f:Function {id: $functionId})
 	`
-	records, err := a.graph.ExecuteRead(ctx, query, map[string]any{"functionId": int64(functionID)})
-	if err != nil {
-		return fmt.Errorf("failed to query callers: %w", err)
+	}
+	query += `
+		WITH DISTINCT other, fc
+		WITH other, collect(fc {.fileId, .startLine, .startChar, .endLine, .endChar, .range}) AS callSites
+		RETURN other.id AS otherId, other.name AS otherName,
+		       other.fileId AS fileId, other {.startLine, .startChar, .endLine, .endChar, .range} AS range,
+		       callSites
+		ORDER BY otherId
+		SKIP $skip
+	`
+	params := map[string]any{"functionId": int64(item.ID), "skip": int64(item.Skip)}
+	if limit > 0 {
+		query += " LIMIT $limit"
+		params["limit"] = int64(limit)
 	}
 
-	for _, record := range records {
-		callerID := ast.NodeID(toInt64(record["callerId"]))
-
-		// Add edge
-		result.Edges = append(result.Edges, &CallEdge{
-			CallerID: callerID,
-			CalleeID: functionID,
-			CallSite: &Location{
-				FileID: int32(toInt64(record["fileId"])),
-				Range:  parseRange(toString(record["callSiteRange"])),
-			},
-		})
-
-		// Skip if already visited
-		if visited[callerID] {
-			continue
+	records, err := a.graph.ExecuteRead(ctx, query, params)
+	if err != nil {
+		if item.Incoming {
+			return nil, fmt.Errorf("failed to query callers: %w", err)
 		}
-		visited[callerID] = true
+		return nil, fmt.Errorf("failed to query callees: %w", err)
+	}
+	return records, nil
+}
 
-		// Add node
-		node := &CallNode{
-			ID:       callerID,
-			Name:     toString(record["callerName"]),
-			FileID:   int32(toInt64(record["fileId"])),
-			Depth:    -depth, // negative depth for callers
+// callSiteLocations converts the call site maps collected by queryCalls into locations
+// sorted by file, then position
+func callSiteLocations(v any) []*Location {
+	raw, _ := v.([]any)
+	sites := make([]*Location, 0, len(raw))
+	for _, r := range raw {
+		props, ok := r.(map[string]any)
+		if !ok {
+			continue
 		}
-		if rangeStr := toString(record["range"]); rangeStr != "" {
-			node.Range = parseRange(rangeStr)
+		sites = append(sites, &Location{
+			FileID: int32(toInt64(props["fileId"])),
+			Range:  codegraph.RangeFromProperties(props),
+		})
+	}
+	sort.Slice(sites, func(i, j int) bool {
+		if sites[i].FileID != sites[j].FileID {
+			return sites[i].FileID < sites[j].FileID
 		}
-		result.Nodes[callerID] = node
-
-		// Recurse
-		if err := a.traverseCallers(ctx, callerID, depth+1, maxDepth, result, visited, opts); err != nil {
-			return err
+		a, b := sites[i].Range.Start, sites[j].Range.Start
+		if a.Line != b.Line {
+			return a.Line < b.Line
 		}
-	}
-
-	return nil
+		return a.Character < b.Character
+	})
+	return sites
 }
 
 // -----------------------------------------------------------------------------
@@ -284,7 +352,7 @@ func (a *graphAnalyzerImpl) GetVariableDependents(ctx context.Context, repoName,
 
 	if filePath != "" {
 		query += " AND v.path = $path"
-		params["path"] = filePath
+		params["path"] = util.CanonicalPath(filePath)
 	}
 
 	query += " RETURN v.id AS id LIMIT 1"
@@ -294,7 +362,7 @@ func (a *graphAnalyzerImpl) GetVariableDependents(ctx context.Context, repoName,
 		return nil, fmt.Errorf("failed to find variable: %w", err)
 	}
 	if len(records) == 0 {
-		return nil, fmt.Errorf("variable not found: %s", variableName)
+		return nil, apperrors.NodeNotFound("variable", variableName)
 	}
 
 	varID := ast.NodeID(toInt64(records[0]["id"]))
@@ -401,7 +469,7 @@ func (a *graphAnalyzerImpl) GetFieldAccessors(ctx context.Context, fieldID ast.N
 		return nil, fmt.Errorf("failed to get field: %w", err)
 	}
 	if len(fieldRecords) == 0 {
-		return nil, fmt.Errorf("field not found: %d", fieldID)
+		return nil, apperrors.NodeNotFound("field", fieldID)
 	}
 
 	result := &FieldAccessResult{
@@ -478,7 +546,7 @@ func (a *graphAnalyzerImpl) GetFieldAccessorsByName(ctx context.Context, repoNam
 		return nil, fmt.Errorf("failed to find field: %w", err)
 	}
 	if len(records) == 0 {
-		return nil, fmt.Errorf("field not found: %s.%s", className, fieldName)
+		return nil, apperrors.NodeNotFound("field", className+"."+fieldName)
 	}
 
 	fieldID := ast.NodeID(toInt64(records[0]["fieldId"]))
@@ -504,7 +572,7 @@ func (a *graphAnalyzerImpl) GetInheritanceTree(ctx context.Context, classID ast.
 		return nil, fmt.Errorf("failed to get class: %w", err)
 	}
 	if len(rootRecords) == 0 {
-		return nil, fmt.Errorf("class not found: %d", classID)
+		return nil, apperrors.NodeNotFound("class", classID)
 	}
 
 	rootNode := &InheritanceNode{
@@ -723,11 +791,343 @@ func (a *graphAnalyzerImpl) GetImpact(ctx context.Context, nodeID ast.NodeID, op
 		}
 	}
 
+	if len(opts.ProjectRepos) > 0 {
+		if err := a.scopeImpactToProject(ctx, result, seen, opts); err != nil {
+			a.logger.Warn("Failed to follow impact across the project", zap.Error(err))
+		}
+	}
+
+	if err := a.attributeImpactOwners(ctx, result, opts.ModifiedSince); err != nil {
+		a.logger.Warn("Failed to read blame metadata for impact", zap.Error(err))
+	}
+
+	scoring := DefaultImpactScoring()
+	if opts.Scoring != nil {
+		scoring = *opts.Scoring
+	}
+	if err := a.setImpactFanIn(ctx, result.AffectedNodes); err != nil {
+		a.logger.Warn("Failed to read fan-in for impact", zap.Error(err))
+	}
+	rankImpact(result, scoring)
+
 	result.TotalAffected = len(result.AffectedNodes)
 
 	return result, nil
 }
 
+// scopeImpactToProject follows callers across the repositories of opts.ProjectRepos, then
+// sets the repository of every node and drops the affected nodes outside the project.
+// Callers found in another repository are expanded within it, and their own cross-repo
+// callers followed in turn, until MaxDepth.
+func (a *graphAnalyzerImpl) scopeImpactToProject(ctx context.Context, result *ImpactResult, seen map[ast.NodeID]bool, opts ImpactOptions) error {
+	repos := make(map[int32]string)
+	if err := a.loadFileRepos(ctx, repos, append([]*ImpactNode{result.Source}, result.AffectedNodes...)); err != nil {
+		return err
+	}
+
+	if opts.IncludeCallGraph {
+		var frontier []*ImpactNode
+		if result.Source.NodeType == ast.NodeTypeFunction {
+			frontier = append(frontier, result.Source)
+		}
+		frontier = append(frontier, result.AffectedByCallGraph...)
+
+		for len(frontier) > 0 {
+			var targets []*ImpactNode
+			for _, node := range frontier {
+				if absDepth(node.Depth) < opts.MaxDepth {
+					node.Repo = repos[node.FileID]
+					targets = append(targets, node)
+				}
+			}
+			callers, err := a.crossRepoCallers(ctx, targets, opts.ProjectRepos)
+			if err != nil {
+				return err
+			}
+
+			frontier = nil
+			for _, caller := range callers {
+				if seen[caller.ID] {
+					continue
+				}
+				seen[caller.ID] = true
+				repos[caller.FileID] = caller.Repo
+				result.AffectedByCallGraph = append(result.AffectedByCallGraph, caller)
+				result.AffectedNodes = append(result.AffectedNodes, caller)
+				frontier = append(frontier, caller)
+
+				// Callers of the caller within its own repository
+				remaining := opts.MaxDepth - absDepth(caller.Depth)
+				if remaining <= 0 {
+					continue
+				}
+				callGraph, err := a.GetCallers(ctx, caller.ID, remaining)
+				if err != nil || callGraph == nil {
+					continue
+				}
+				var expanded []*ImpactNode
+				for id, node := range callGraph.Nodes {
+					if seen[id] {
+						continue
+					}
+					seen[id] = true
+					impactNode := &ImpactNode{
+						ID:       id,
+						Name:     node.Name,
+						NodeType: ast.NodeTypeFunction,
+						FilePath: node.FilePath,
+						FileID:   node.FileID,
+						Depth:    caller.Depth + node.Depth, // both negative, as callers
+						Impact:   ImpactTypeCallGraph,
+					}
+					result.AffectedByCallGraph = append(result.AffectedByCallGraph, impactNode)
+					result.AffectedNodes = append(result.AffectedNodes, impactNode)
+					expanded = append(expanded, impactNode)
+				}
+				if err := a.loadFileRepos(ctx, repos, expanded); err != nil {
+					return err
+				}
+				frontier = append(frontier, expanded...)
+			}
+		}
+	}
+
+	inProject := make(map[string]bool, len(opts.ProjectRepos))
+	for _, repo := range opts.ProjectRepos {
+		inProject[repo] = true
+	}
+	keep := func(nodes []*ImpactNode) []*ImpactNode {
+		kept := nodes[:0]
+		for _, node := range nodes {
+			node.Repo = repos[node.FileID]
+			if inProject[node.Repo] {
+				kept = append(kept, node)
+			}
+		}
+		return kept
+	}
+	result.Source.Repo = repos[result.Source.FileID]
+	result.AffectedNodes = keep(result.AffectedNodes)
+	result.AffectedByCallGraph = keep(result.AffectedByCallGraph)
+	result.AffectedByDataFlow = keep(result.AffectedByDataFlow)
+	return nil
+}
+
+// absDepth returns the distance of an impact node from the source; callers have negative depths
+func absDepth(depth int) int {
+	if depth < 0 {
+		return -depth
+	}
+	return depth
+}
+
+// loadFileRepos adds the repositories of the files of nodes missing from repos
+func (a *graphAnalyzerImpl) loadFileRepos(ctx context.Context, repos map[int32]string, nodes []*ImpactNode) error {
+	var fileIDs []int64
+	for _, node := range nodes {
+		if _, ok := repos[node.FileID]; !ok {
+			repos[node.FileID] = ""
+			fileIDs = append(fileIDs, int64(node.FileID))
+		}
+	}
+	if len(fileIDs) == 0 {
+		return nil
+	}
+	records, err := a.graph.ExecuteRead(ctx, `
+		MATCH (fs:FileScope)
+		WHERE fs.fileId IN $fileIds
+		RETURN fs.fileId AS fileId, fs.repo AS repo
+	`, map[string]any{"fileIds": fileIDs})
+	if err != nil {
+		return fmt.Errorf("failed to read file repositories: %w", err)
+	}
+	for _, record := range records {
+		repos[int32(toInt64(record["fileId"]))] = toString(record["repo"])
+	}
+	return nil
+}
+
+// crossRepoCallers links the target functions to the functions calling them from the other
+// repositories of the project. Calls between repositories are not resolved by the language
+// servers, so a call counts when it resolved to no function in its own repository and is
+// named like the target, either exactly or as the last part of a qualified name (pkg.Name).
+// Only the latest version of each file is searched, and each call is attributed to the
+// innermost function containing it.
+func (a *graphAnalyzerImpl) crossRepoCallers(ctx context.Context, targets []*ImpactNode, projectRepos []string) ([]*ImpactNode, error) {
+	if len(targets) == 0 {
+		return nil, nil
+	}
+	params := make([]map[string]any, len(targets))
+	depths := make(map[ast.NodeID]int, len(targets))
+	for i, target := range targets {
+		params[i] = map[string]any{"id": int64(target.ID), "name": target.Name, "repo": target.Repo}
+		depths[target.ID] = target.Depth
+	}
+	records, err := a.graph.ExecuteRead(ctx, `
+		UNWIND $targets AS target
+		MATCH (fs:FileScope)
+		WHERE fs.repo IN $repos AND fs.repo <> target.repo
+		WITH target, fs.repo AS repo, fs.path AS path, max(fs.id) AS fileId
+		MATCH (fc:FunctionCall {fileId: fileId})
+		WHERE (fc.name = target.name OR fc.name ENDS WITH '.' + target.name)
+		  AND NOT (fc)-[:CALLS_FUNCTION]->()
+		MATCH p = (caller:Function)-[:CONTAINS*]->(fc)
+		WITH target, repo, path, fc, caller, p
+		ORDER BY length(p)
+		WITH target, repo, path, fc, head(collect(caller)) AS caller
+		RETURN DISTINCT target.id AS targetId, caller.id AS id, caller.name AS name,
+		       caller.fileId AS fileId, path, repo
+		ORDER BY id
+	`, map[string]any{"targets": params, "repos": projectRepos})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cross-repository callers: %w", err)
+	}
+
+	callers := make([]*ImpactNode, 0, len(records))
+	for _, record := range records {
+		callers = append(callers, &ImpactNode{
+			ID:       ast.NodeID(toInt64(record["id"])),
+			Name:     toString(record["name"]),
+			NodeType: ast.NodeTypeFunction,
+			FilePath: toString(record["path"]),
+			FileID:   int32(toInt64(record["fileId"])),
+			Repo:     toString(record["repo"]),
+			Depth:    -(absDepth(depths[ast.NodeID(toInt64(record["targetId"]))]) + 1), // negative, as callers
+			Impact:   ImpactTypeCrossRepo,
+		})
+	}
+	return callers, nil
+}
+
+// attributeImpactOwners copies blame metadata onto the source and affected nodes and sums up
+// the owners of the affected nodes. With a non-zero modifiedSince, affected nodes changed
+// earlier, or without blame metadata, are dropped.
+func (a *graphAnalyzerImpl) attributeImpactOwners(ctx context.Context, result *ImpactResult, modifiedSince time.Time) error {
+	result.Owners = make([]ImpactOwner, 0)
+	ids := []int64{int64(result.Source.ID)}
+	for _, node := range result.AffectedNodes {
+		ids = append(ids, int64(node.ID))
+	}
+	records, err := a.graph.ExecuteRead(ctx, `
+		MATCH (n)
+		WHERE n.id IN $ids AND n.md_last_modified_at IS NOT NULL
+		RETURN n.id AS id, n.md_last_modified_by AS author, n.md_last_modified_email AS email,
+		       n.md_last_modified_at AS modifiedAt
+	`, map[string]any{"ids": ids})
+	if err != nil {
+		return err
+	}
+
+	type blame struct {
+		author, email string
+		at            int64
+	}
+	blames := make(map[ast.NodeID]blame, len(records))
+	for _, record := range records {
+		blames[ast.NodeID(toInt64(record["id"]))] = blame{
+			author: toString(record["author"]),
+			email:  toString(record["email"]),
+			at:     toInt64(record["modifiedAt"]),
+		}
+	}
+
+	apply := func(nodes []*ImpactNode) []*ImpactNode {
+		kept := nodes[:0]
+		for _, node := range nodes {
+			b, ok := blames[node.ID]
+			if ok {
+				node.LastModifiedBy = b.author
+				node.LastModifiedAt = b.at
+			}
+			if !modifiedSince.IsZero() && (!ok || b.at < modifiedSince.Unix()) {
+				continue
+			}
+			kept = append(kept, node)
+		}
+		return kept
+	}
+	apply([]*ImpactNode{result.Source})
+	result.AffectedNodes = apply(result.AffectedNodes)
+	if !modifiedSince.IsZero() {
+		result.AffectedByCallGraph = apply(result.AffectedByCallGraph)
+		result.AffectedByDataFlow = apply(result.AffectedByDataFlow)
+	}
+
+	counts := make(map[string]*ImpactOwner)
+	for _, node := range result.AffectedNodes {
+		b, ok := blames[node.ID]
+		if !ok {
+			continue
+		}
+		owner := counts[b.author]
+		if owner == nil {
+			owner = &ImpactOwner{Author: b.author, Email: b.email}
+			counts[b.author] = owner
+		}
+		owner.Nodes++
+	}
+	for _, owner := range counts {
+		result.Owners = append(result.Owners, *owner)
+	}
+	sort.Slice(result.Owners, func(i, j int) bool {
+		if result.Owners[i].Nodes != result.Owners[j].Nodes {
+			return result.Owners[i].Nodes > result.Owners[j].Nodes
+		}
+		return result.Owners[i].Author < result.Owners[j].Author
+	})
+	return nil
+}
+
+// setImpactFanIn sets FanIn on the affected functions to their number of distinct callers,
+// precomputed by the last build (see codegraph.ComputePopularity). Functions without the
+// count, from graphs built before it existed, are counted now.
+func (a *graphAnalyzerImpl) setImpactFanIn(ctx context.Context, nodes []*ImpactNode) error {
+	ids := make([]int64, 0, len(nodes))
+	for _, node := range nodes {
+		if node.NodeType == ast.NodeTypeFunction {
+			ids = append(ids, int64(node.ID))
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	records, err := a.graph.ExecuteRead(ctx, `
+		MATCH (f:Function)
+		WHERE f.id IN $ids
+		RETURN f.id AS id, f.`+codegraph.PropCallerCount+` AS fanIn
+	`, map[string]any{"ids": ids})
+	if err != nil {
+		return err
+	}
+	fanIn := make(map[ast.NodeID]int, len(records))
+	var uncounted []int64
+	for _, record := range records {
+		id := toInt64(record["id"])
+		if record["fanIn"] == nil {
+			uncounted = append(uncounted, id)
+			continue
+		}
+		fanIn[ast.NodeID(id)] = int(toInt64(record["fanIn"]))
+	}
+	if len(uncounted) > 0 {
+		records, err = a.graph.ExecuteRead(ctx, `
+			MATCH (caller:Function)-[:CONTAINS*]->(:FunctionCall)-[:CALLS_FUNCTION]->(f:Function)
+			WHERE f.id IN $ids
+			RETURN f.id AS id, count(DISTINCT caller) AS fanIn
+		`, map[string]any{"ids": uncounted})
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			fanIn[ast.NodeID(toInt64(record["id"]))] = int(toInt64(record["fanIn"]))
+		}
+	}
+	for _, node := range nodes {
+		node.FanIn = fanIn[node.ID]
+	}
+	return nil
+}
+
 func (a *graphAnalyzerImpl) GetImpactByName(ctx context.Context, repoName, filePath, name string, nodeType ast.NodeType, opts ImpactOptions) (*ImpactResult, error) {
 	// Find the node
 	var query string
@@ -748,7 +1148,7 @@ func (a *graphAnalyzerImpl) GetImpactByName(ctx context.Context, repoName, fileP
 
 	if filePath != "" {
 		query += " AND n.path = $path"
-		params["path"] = filePath
+		params["path"] = util.CanonicalPath(filePath)
 	}
 
 	query += " RETURN n.id AS id LIMIT 1"
@@ -758,13 +1158,172 @@ func (a *graphAnalyzerImpl) GetImpactByName(ctx context.Context, repoName, fileP
 		return nil, fmt.Errorf("failed to find node: %w", err)
 	}
 	if len(records) == 0 {
-		return nil, fmt.Errorf("node not found: %s", name)
+		return nil, apperrors.NodeNotFound("node", name)
 	}
 
 	nodeID := ast.NodeID(toInt64(records[0]["id"]))
 	return a.GetImpact(ctx, nodeID, opts)
 }
 
+// maxChangeImpactFunctions caps the functions analyzed by one GetChangeImpact call
+const maxChangeImpactFunctions = 200
+
+func (a *graphAnalyzerImpl) GetChangeImpact(ctx context.Context, repoName string, filePaths []string, opts ImpactOptions) (*ChangeImpact, error) {
+	paths := make([]string, len(filePaths))
+	for i, p := range filePaths {
+		paths[i] = util.CanonicalPath(p)
+	}
+	records, err := a.graph.ExecuteRead(ctx, `
+		MATCH (fs:FileScope {repo: $repo})
+		WHERE fs.path IN $paths
+		WITH fs.path AS path, max(fs.id) AS fileId
+		MATCH (f:Function {fileId: fileId})
+		RETURN f.id AS id
+		ORDER BY f.id
+		LIMIT $limit
+	`, map[string]any{"repo": repoName, "paths": paths, "limit": int64(maxChangeImpactFunctions + 1)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find functions of changed files: %w", err)
+	}
+
+	result := &ChangeImpact{
+		ChangedFiles: paths,
+		Affected:     make([]*ImpactNode, 0),
+		Owners:       make([]ImpactOwner, 0),
+	}
+	if len(records) > maxChangeImpactFunctions {
+		records = records[:maxChangeImpactFunctions]
+		result.Truncated = true
+	}
+
+	changed := make(map[ast.NodeID]bool, len(records))
+	for _, record := range records {
+		changed[ast.NodeID(toInt64(record["id"]))] = true
+	}
+	affected := make(map[ast.NodeID]*ImpactNode)
+	emails := make(map[string]string)
+	for _, record := range records {
+		id := ast.NodeID(toInt64(record["id"]))
+		impact, err := a.GetImpact(ctx, id, opts)
+		if err != nil {
+			a.logger.Warn("Skipping impact of changed function", zap.Int64("function_id", int64(id)), zap.Error(err))
+			continue
+		}
+		result.Functions++
+		for _, node := range impact.AffectedNodes {
+			// Changed functions calling each other are not impact
+			if changed[node.ID] {
+				continue
+			}
+			if prev, ok := affected[node.ID]; !ok || node.Score > prev.Score {
+				affected[node.ID] = node
+			}
+		}
+		for _, owner := range impact.Owners {
+			emails[owner.Author] = owner.Email
+		}
+	}
+
+	scoring := DefaultImpactScoring()
+	if opts.Scoring != nil {
+		scoring = *opts.Scoring
+	}
+	counts := make(map[string]int)
+	for _, node := range affected {
+		if node.FilePath == "" {
+			node.FilePath = a.graph.GetFilePath(ctx, node.FileID)
+		}
+		if node.LastModifiedBy != "" {
+			counts[node.LastModifiedBy]++
+		}
+		result.RiskScore += node.Score
+		result.Affected = append(result.Affected, node)
+	}
+	sort.Slice(result.Affected, func(i, j int) bool {
+		if result.Affected[i].Score != result.Affected[j].Score {
+			return result.Affected[i].Score > result.Affected[j].Score
+		}
+		return result.Affected[i].ID < result.Affected[j].ID
+	})
+	result.RiskLevel = scoring.riskLevel(result.RiskScore)
+
+	for author, nodes := range counts {
+		result.Owners = append(result.Owners, ImpactOwner{Author: author, Email: emails[author], Nodes: nodes})
+	}
+	sort.Slice(result.Owners, func(i, j int) bool {
+		if result.Owners[i].Nodes != result.Owners[j].Nodes {
+			return result.Owners[i].Nodes > result.Owners[j].Nodes
+		}
+		return result.Owners[i].Author < result.Owners[j].Author
+	})
+	return result, nil
+}
+
+// -----------------------------------------------------------------------------
+// Duplicate Detection
+// -----------------------------------------------------------------------------
+
+func (a *graphAnalyzerImpl) FindDuplicateFunctions(ctx context.Context, repoName string, opts DuplicateOptions) ([]*DuplicateGroup, error) {
+	if opts.MinCount < 2 {
+		opts.MinCount = 2
+	}
+
+	query := `
+		MATCH (file:FileScope {repo: $repo})
+		MATCH (m:Function)
+		WHERE m.fileId = file.fileId AND m.md_ast_hash IS NOT NULL AND m.md_ast_size >= $minSize
+		WITH m.md_ast_hash AS hash, m.md_ast_size AS size,
+		     collect({id: m.id, name: m.name, fileId: m.fileId, range: m {.startLine, .startChar, .endLine, .endChar, .range}, path: file.path}) AS functions
+		WHERE size(functions) >= $minCount
+		RETURN hash, size, functions
+		ORDER BY size(functions) DESC, size DESC
+	`
+	if opts.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", opts.Limit)
+	}
+
+	records, err := a.graph.ExecuteRead(ctx, query, map[string]any{
+		"repo":     repoName,
+		"minSize":  opts.MinSize,
+		"minCount": opts.MinCount,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find duplicate functions: %w", err)
+	}
+
+	groups := make([]*DuplicateGroup, 0, len(records))
+	for _, record := range records {
+		group := &DuplicateGroup{
+			Fingerprint: toString(record["hash"]),
+			Size:        int(toInt64(record["size"])),
+		}
+		functions, _ := record["functions"].([]any)
+		for _, f := range functions {
+			fn, ok := f.(map[string]any)
+			if !ok {
+				continue
+			}
+			group.Functions = append(group.Functions, &MethodInfo{
+				ID:       ast.NodeID(toInt64(fn["id"])),
+				Name:     toString(fn["name"]),
+				FilePath: toString(fn["path"]),
+				FileID:   int32(toInt64(fn["fileId"])),
+				Range:    codegraph.RangeFromValue(fn["range"]),
+			})
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// -----------------------------------------------------------------------------
+// History
+// -----------------------------------------------------------------------------
+
+func (a *graphAnalyzerImpl) GetNodeCommits(ctx context.Context, repoName string, nodeID ast.NodeID, limit int) ([]codegraph.Commit, error) {
+	return a.graph.GetNodeCommits(ctx, repoName, nodeID, limit)
+}
+
 // -----------------------------------------------------------------------------
 // Helper Methods
 // -----------------------------------------------------------------------------
@@ -772,14 +1331,14 @@ func (a *graphAnalyzerImpl) GetImpactByName(ctx context.Context, repoName, fileP
 func (a *graphAnalyzerImpl) getFunctionAsCallNode(ctx context.Context, functionID ast.NodeID, depth int) (*CallNode, error) {
 	query := `
 		MATCH (f:Function {id: $id})
-		RETURN f.name AS name, f.fileId AS fileId, f.range AS range
+		RETURN f.name AS name, f.fileId AS fileId, f {.startLine, .startChar, .endLine, .endChar, .range} AS range
 	`
 	records, err := a.graph.ExecuteRead(ctx, query, map[string]any{"id": int64(functionID)})
 	if err != nil {
 		return nil, err
 	}
 	if len(records) == 0 {
-		return nil, fmt.Errorf("function not found: %d", functionID)
+		return nil, apperrors.NodeNotFound("function", functionID)
 	}
 
 	record := records[0]
@@ -789,9 +1348,7 @@ func (a *graphAnalyzerImpl) getFunctionAsCallNode(ctx context.Context, functionI
 		FileID: int32(toInt64(record["fileId"])),
 		Depth:  depth,
 	}
-	if rangeStr := toString(record["range"]); rangeStr != "" {
-		node.Range = parseRange(rangeStr)
-	}
+	node.Range = codegraph.RangeFromValue(record["range"])
 
 	return node, nil
 }
@@ -806,7 +1363,7 @@ func (a *graphAnalyzerImpl) getNodeAsDependencyNode(ctx context.Context, nodeID
 		return nil, err
 	}
 	if len(records) == 0 {
-		return nil, fmt.Errorf("node not found: %d", nodeID)
+		return nil, apperrors.NodeNotFound("node", nodeID)
 	}
 
 	record := records[0]
@@ -829,7 +1386,7 @@ func (a *graphAnalyzerImpl) getNodeAsImpactNode(ctx context.Context, nodeID ast.
 		return nil, err
 	}
 	if len(records) == 0 {
-		return nil, fmt.Errorf("node not found: %d", nodeID)
+		return nil, apperrors.NodeNotFound("node", nodeID)
 	}
 
 	record := records[0]
@@ -863,7 +1420,7 @@ func (a *graphAnalyzerImpl) findFunctionID(ctx context.Context, repoName, filePa
 
 	if filePath != "" {
 		query += " AND f.path = $path"
-		params["path"] = filePath
+		params["path"] = util.CanonicalPath(filePath)
 	}
 
 	query += " RETURN f.id AS id LIMIT 1"
@@ -873,7 +1430,7 @@ func (a *graphAnalyzerImpl) findFunctionID(ctx context.Context, repoName, filePa
 		return 0, fmt.Errorf("failed to find function: %w", err)
 	}
 	if len(records) == 0 {
-		return 0, fmt.Errorf("function not found: %s", functionName)
+		return 0, apperrors.NodeNotFound("function", functionName)
 	}
 
 	return ast.NodeID(toInt64(records[0]["id"])), nil
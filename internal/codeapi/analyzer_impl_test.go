@@ -0,0 +1,133 @@
+package codeapi
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"bot-go/internal/model/ast"
+
+	"go.uber.org/zap"
+)
+
+func TestCrossRepoCallers(t *testing.T) {
+	f := newGraphFixture(t)
+	other := f.repo + "-shop"
+
+	// Charge in the fixture repository is called from two versions of shop/pay.go, from a
+	// closure in Pay in the latest one, and from shop/refund.go, which calls its own Charge
+	f.write(t, `
+		CREATE (:FileScope {id: $f1, fileId: $f1, repo: $repo, path: 'billing/charge.go', name: 'charge.go'})
+		       -[:CONTAINS]->(:Function {id: $charge, fileId: $f1, name: 'Charge'})
+		CREATE (:FileScope {id: $f2, fileId: $f2, repo: $other, path: 'shop/pay.go', name: 'pay.go'})
+		       -[:CONTAINS]->(:Function {id: $oldPay, fileId: $f2, name: 'Pay'})
+		       -[:CONTAINS]->(:FunctionCall {id: $oldCall, fileId: $f2, name: 'billing.Charge'})
+		CREATE (:FileScope {id: $f3, fileId: $f3, repo: $other, path: 'shop/pay.go', name: 'pay.go'})
+		       -[:CONTAINS]->(:Function {id: $pay, fileId: $f3, name: 'Pay'})
+		       -[:CONTAINS]->(:Function {id: $closure, fileId: $f3, name: '<anonymous>'})
+		       -[:CONTAINS]->(:Block {id: $block, fileId: $f3, name: ''})
+		       -[:CONTAINS]->(:FunctionCall {id: $call, fileId: $f3, name: 'billing.Charge'})
+		CREATE (refundFile:FileScope {id: $f4, fileId: $f4, repo: $other, path: 'shop/refund.go', name: 'refund.go'})
+		       -[:CONTAINS]->(:Function {id: $refund, fileId: $f4, name: 'Refund'})
+		       -[:CONTAINS]->(:FunctionCall {id: $localCall, fileId: $f4, name: 'Charge'})
+		       -[:CALLS_FUNCTION]->(localCharge:Function {id: $localCharge, fileId: $f4, name: 'Charge'})
+		CREATE (refundFile)-[:CONTAINS]->(localCharge)
+	`, map[string]any{
+		"repo": f.repo, "other": other,
+		"f1": f.fileID(1), "charge": f.id(1, 2),
+		"f2": f.fileID(2), "oldPay": f.id(2, 2), "oldCall": f.id(2, 3),
+		"f3": f.fileID(3), "pay": f.id(3, 2), "closure": f.id(3, 3), "block": f.id(3, 4), "call": f.id(3, 5),
+		"f4": f.fileID(4), "refund": f.id(4, 2), "localCall": f.id(4, 3), "localCharge": f.id(4, 4),
+	})
+
+	a := newGraphAnalyzerImpl(f.graph, zap.NewNop())
+	target := &ImpactNode{ID: ast.NodeID(f.id(1, 2)), Name: "Charge", NodeType: ast.NodeTypeFunction, Repo: f.repo, Depth: 1}
+	callers, err := a.crossRepoCallers(context.Background(), []*ImpactNode{target}, []string{f.repo, other})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(callers) != 1 {
+		t.Fatalf("got %d callers, want the closure of the latest pay.go: %+v", len(callers), callers)
+	}
+	want := ImpactNode{
+		ID: ast.NodeID(f.id(3, 3)), Name: "<anonymous>", NodeType: ast.NodeTypeFunction,
+		FilePath: "shop/pay.go", FileID: int32(f.fileID(3)), Repo: other, Depth: -2, Impact: ImpactTypeCrossRepo,
+	}
+	if *callers[0] != want {
+		t.Errorf("caller = %+v, want %+v", *callers[0], want)
+	}
+}
+
+func TestCallGraphCallSites(t *testing.T) {
+	f := newGraphFixture(t)
+
+	// Handle calls Get twice itself and once from a closure; Serve in another file calls it once
+	f.write(t, `
+		CREATE (:FileScope {id: $f1, fileId: $f1, repo: $repo, path: 'store/store.go'})
+		       -[:CONTAINS]->(get:Function {id: $get, fileId: $f1, name: 'Get', startLine: 2})
+		CREATE (handle:Function {id: $handle, fileId: $f2, name: 'Handle', startLine: 1})
+		CREATE (:FileScope {id: $f2, fileId: $f2, repo: $repo, path: 'api/handler.go'})-[:CONTAINS]->(handle)
+		CREATE (handle)-[:CONTAINS]->(:FunctionCall {id: $late, fileId: $f2, name: 'store.Get', startLine: 8, startChar: 2})-[:CALLS_FUNCTION]->(get)
+		CREATE (handle)-[:CONTAINS]->(:FunctionCall {id: $early, fileId: $f2, name: 'store.Get', startLine: 3, startChar: 9})-[:CALLS_FUNCTION]->(get)
+		CREATE (handle)-[:CONTAINS]->(:Function {id: $closure, fileId: $f2, name: '<anonymous>', startLine: 5})
+		       -[:CONTAINS]->(:FunctionCall {id: $inClosure, fileId: $f2, name: 'store.Get', startLine: 5, startChar: 4})-[:CALLS_FUNCTION]->(get)
+		CREATE (:FileScope {id: $f3, fileId: $f3, repo: $repo, path: 'api/server.go'})
+		       -[:CONTAINS]->(:Function {id: $serve, fileId: $f3, name: 'Serve', startLine: 0})
+		       -[:CONTAINS]->(:FunctionCall {id: $serveCall, fileId: $f3, name: 'store.Get', startLine: 1, startChar: 1})-[:CALLS_FUNCTION]->(get)
+	`, map[string]any{
+		"repo": f.repo,
+		"f1":   f.fileID(1), "get": f.id(1, 2),
+		"f2": f.fileID(2), "handle": f.id(2, 2), "late": f.id(2, 3), "early": f.id(2, 4), "closure": f.id(2, 5), "inClosure": f.id(2, 6),
+		"f3": f.fileID(3), "serve": f.id(3, 2), "serveCall": f.id(3, 3),
+	})
+
+	a := newGraphAnalyzerImpl(f.graph, zap.NewNop())
+	callers, err := a.GetCallers(context.Background(), ast.NodeID(f.id(1, 2)), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := make(map[ast.NodeID][]string)
+	for _, edge := range callers.Edges {
+		if edge.CalleeID != ast.NodeID(f.id(1, 2)) {
+			t.Errorf("edge %d -> %d, want calls of Get only", edge.CallerID, edge.CalleeID)
+		}
+		if _, dup := lines[edge.CallerID]; dup {
+			t.Errorf("several edges from %d, want one per caller", edge.CallerID)
+		}
+		if edge.Count != len(edge.CallSites) || edge.CallSite != edge.CallSites[0] {
+			t.Errorf("edge from %d: count %d, first site %v, sites %v", edge.CallerID, edge.Count, edge.CallSite, edge.CallSites)
+		}
+		for _, site := range edge.CallSites {
+			lines[edge.CallerID] = append(lines[edge.CallerID], fmt.Sprintf("%d:%d:%d", site.FileID-int32(f.fileBase), site.Range.Start.Line, site.Range.Start.Character))
+		}
+	}
+	want := map[ast.NodeID][]string{
+		ast.NodeID(f.id(2, 2)): {"2:3:9", "2:5:4", "2:8:2"},
+		ast.NodeID(f.id(2, 5)): {"2:5:4"},
+		ast.NodeID(f.id(3, 2)): {"3:1:1"},
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("call sites = %v, want %v", lines, want)
+	}
+}
+
+func TestCallSiteLocations(t *testing.T) {
+	sites := callSiteLocations([]any{
+		map[string]any{"fileId": int64(9), "startLine": int64(1), "startChar": int64(0)},
+		map[string]any{"fileId": int64(7), "startLine": int64(12), "startChar": int64(4)},
+		map[string]any{"fileId": int64(7), "startLine": int64(3), "startChar": int64(8)},
+		map[string]any{"fileId": int64(7), "startLine": int64(3), "startChar": int64(2)},
+		"not a call site",
+	})
+
+	var got []string
+	for _, site := range sites {
+		got = append(got, fmt.Sprintf("%d:%d:%d", site.FileID, site.Range.Start.Line, site.Range.Start.Character))
+	}
+	want := []string{"7:3:2", "7:3:8", "7:12:4", "9:1:0"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("call sites = %v, want %v", got, want)
+	}
+}
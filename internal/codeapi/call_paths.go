@@ -0,0 +1,227 @@
+package codeapi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"bot-go/internal/model/ast"
+	"bot-go/internal/service/codegraph"
+)
+
+// CallPathOptions controls call path search
+type CallPathOptions struct {
+	MaxDepth int // longest path searched, in calls (default 6)
+	MaxPaths int // paths returned (default 10)
+	MaxNodes int // functions visited before giving up (default 10,000)
+}
+
+// DefaultCallPathOptions returns sensible defaults for call path search
+func DefaultCallPathOptions() CallPathOptions {
+	return CallPathOptions{
+		MaxDepth: 6,
+		MaxPaths: 10,
+		MaxNodes: 10000,
+	}
+}
+
+// CallPaths are the shortest call chains from one function to another
+type CallPaths struct {
+	From *CallNode
+	To   *CallNode
+
+	// Paths run from From to To, one node per function, with Depth the position in the path.
+	// All paths have the same length, Length calls; no path means To is not reachable from
+	// From within MaxDepth.
+	Paths  [][]*CallNode
+	Length int
+
+	// Truncated is true if there were more than MaxPaths paths, or the search visited
+	// MaxNodes functions before reaching To
+	Truncated bool
+}
+
+func (a *graphAnalyzerImpl) FindCallPaths(ctx context.Context, fromID, toID ast.NodeID, opts CallPathOptions) (*CallPaths, error) {
+	defaults := DefaultCallPathOptions()
+	if opts.MaxDepth <= 0 {
+		opts.MaxDepth = defaults.MaxDepth
+	}
+	if opts.MaxPaths <= 0 {
+		opts.MaxPaths = defaults.MaxPaths
+	}
+	if opts.MaxNodes <= 0 {
+		opts.MaxNodes = defaults.MaxNodes
+	}
+
+	from, err := a.getFunctionAsCallNode(ctx, fromID, 0)
+	if err != nil {
+		return nil, err
+	}
+	to, err := a.getFunctionAsCallNode(ctx, toID, 0)
+	if err != nil {
+		return nil, err
+	}
+	result := &CallPaths{From: from, To: to, Paths: make([][]*CallNode, 0)}
+
+	// Expand callees breadth first, one query per level, keeping every caller a function is
+	// first reached from so that all shortest paths can be rebuilt
+	depths := map[ast.NodeID]int{fromID: 0}
+	parents := make(map[ast.NodeID][]ast.NodeID)
+	frontier := []ast.NodeID{fromID}
+	for depth := 1; depth <= opts.MaxDepth && len(frontier) > 0 && fromID != toID; depth++ {
+		edges, err := a.queryCallEdges(ctx, frontier)
+		if err != nil {
+			return nil, err
+		}
+		frontier = nil
+		for _, edge := range edges {
+			d, seen := depths[edge.CalleeID]
+			if !seen {
+				if len(depths) >= opts.MaxNodes {
+					result.Truncated = true
+					break
+				}
+				depths[edge.CalleeID] = depth
+				frontier = append(frontier, edge.CalleeID)
+			} else if d != depth {
+				continue
+			}
+			parents[edge.CalleeID] = append(parents[edge.CalleeID], edge.CallerID)
+		}
+		if _, ok := depths[toID]; ok || result.Truncated {
+			break
+		}
+	}
+	if _, ok := depths[toID]; !ok {
+		return result, nil
+	}
+
+	idPaths, more := enumerateCallPaths(parents, fromID, toID, opts.MaxPaths)
+	result.Truncated = result.Truncated || more
+	result.Length = len(idPaths[0]) - 1
+
+	ids := make([]ast.NodeID, 0, len(depths))
+	for _, path := range idPaths {
+		ids = append(ids, path...)
+	}
+	nodes, err := a.loadCallNodes(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range idPaths {
+		nodePath := make([]*CallNode, len(path))
+		for i, id := range path {
+			node, ok := nodes[id]
+			if !ok {
+				node = &CallNode{ID: id}
+			}
+			copied := *node
+			copied.Depth = i
+			nodePath[i] = &copied
+		}
+		result.Paths = append(result.Paths, nodePath)
+	}
+	return result, nil
+}
+
+// enumerateCallPaths walks the callers recorded by the search back from to and returns up
+// to limit paths from from to to, in ID order, and whether there were more
+func enumerateCallPaths(parents map[ast.NodeID][]ast.NodeID, from, to ast.NodeID, limit int) ([][]ast.NodeID, bool) {
+	if from == to {
+		return [][]ast.NodeID{{from}}, false
+	}
+	var paths [][]ast.NodeID
+	more := false
+	var reversed []ast.NodeID
+	var walk func(id ast.NodeID)
+	walk = func(id ast.NodeID) {
+		if more {
+			return
+		}
+		reversed = append(reversed, id)
+		defer func() { reversed = reversed[:len(reversed)-1] }()
+		if id == from {
+			if len(paths) == limit {
+				more = true
+				return
+			}
+			path := make([]ast.NodeID, len(reversed))
+			for i, step := range reversed {
+				path[len(reversed)-1-i] = step
+			}
+			paths = append(paths, path)
+			return
+		}
+		callers := append([]ast.NodeID(nil), parents[id]...)
+		sort.Slice(callers, func(i, j int) bool { return callers[i] < callers[j] })
+		for _, caller := range callers {
+			walk(caller)
+		}
+	}
+	walk(to)
+	sort.Slice(paths, func(i, j int) bool {
+		for k := range paths[i] {
+			if paths[i][k] != paths[j][k] {
+				return paths[i][k] < paths[j][k]
+			}
+		}
+		return false
+	})
+	return paths, more
+}
+
+// queryCallEdges returns the distinct calls made by a set of functions, in a stable order
+func (a *graphAnalyzerImpl) queryCallEdges(ctx context.Context, callerIDs []ast.NodeID) ([]*CallEdge, error) {
+	ids := make([]int64, len(callerIDs))
+	for i, id := range callerIDs {
+		ids[i] = int64(id)
+	}
+	records, err := a.graph.ExecuteRead(ctx, `
+		MATCH (f:Function)-[:CONTAINS*]->(:FunctionCall)-[:CALLS_FUNCTION]->(other:Function)
+		WHERE f.id IN $ids
+		RETURN DISTINCT f.id AS callerId, other.id AS calleeId
+		ORDER BY callerId, calleeId
+	`, map[string]any{"ids": ids})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query callees: %w", err)
+	}
+	edges := make([]*CallEdge, len(records))
+	for i, record := range records {
+		edges[i] = &CallEdge{
+			CallerID: ast.NodeID(toInt64(record["callerId"])),
+			CalleeID: ast.NodeID(toInt64(record["calleeId"])),
+		}
+	}
+	return edges, nil
+}
+
+// loadCallNodes reads the functions of a call path, with their file paths
+func (a *graphAnalyzerImpl) loadCallNodes(ctx context.Context, functionIDs []ast.NodeID) (map[ast.NodeID]*CallNode, error) {
+	ids := make([]int64, len(functionIDs))
+	for i, id := range functionIDs {
+		ids[i] = int64(id)
+	}
+	records, err := a.graph.ExecuteRead(ctx, `
+		MATCH (f:Function)
+		WHERE f.id IN $ids
+		OPTIONAL MATCH (c:Class)-[:CONTAINS]->(f)
+		RETURN f.id AS id, f.name AS name, f.fileId AS fileId, c.name AS className,
+		       f {.startLine, .startChar, .endLine, .endChar, .range} AS range
+	`, map[string]any{"ids": ids})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load call path functions: %w", err)
+	}
+	nodes := make(map[ast.NodeID]*CallNode, len(records))
+	for _, record := range records {
+		node := &CallNode{
+			ID:        ast.NodeID(toInt64(record["id"])),
+			Name:      toString(record["name"]),
+			ClassName: toString(record["className"]),
+			FileID:    int32(toInt64(record["fileId"])),
+			Range:     codegraph.RangeFromValue(record["range"]),
+		}
+		node.FilePath = a.graph.GetFilePath(ctx, node.FileID)
+		nodes[node.ID] = node
+	}
+	return nodes, nil
+}
@@ -0,0 +1,34 @@
+package codeapi
+
+import (
+	"reflect"
+	"testing"
+
+	"bot-go/internal/model/ast"
+)
+
+func TestEnumerateCallPaths(t *testing.T) {
+	// 1 calls 2 and 3, both of which call 4; 4 calls 5
+	parents := map[ast.NodeID][]ast.NodeID{
+		2: {1},
+		3: {1},
+		4: {3, 2},
+		5: {4},
+	}
+
+	paths, more := enumerateCallPaths(parents, 1, 5, 10)
+	want := [][]ast.NodeID{{1, 2, 4, 5}, {1, 3, 4, 5}}
+	if !reflect.DeepEqual(paths, want) || more {
+		t.Errorf("paths = %v, more = %v, want %v", paths, more, want)
+	}
+
+	paths, more = enumerateCallPaths(parents, 1, 5, 1)
+	if !reflect.DeepEqual(paths, want[:1]) || !more {
+		t.Errorf("limited paths = %v, more = %v", paths, more)
+	}
+
+	paths, _ = enumerateCallPaths(parents, 3, 3, 10)
+	if !reflect.DeepEqual(paths, [][]ast.NodeID{{3}}) {
+		t.Errorf("paths to self = %v", paths)
+	}
+}
@@ -0,0 +1,67 @@
+package codeapi
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"bot-go/internal/apperrors"
+	"bot-go/internal/model/ast"
+)
+
+// maxContinuationNodes caps the visited set a continuation token carries. A traversal that
+// has returned more nodes than this stops with Truncated set and no token.
+const maxContinuationNodes = 5000
+
+// maxContinuationTokenSize bounds the tokens accepted, well above what a capped visited set
+// encodes to
+const maxContinuationTokenSize = 256 << 10
+
+// callFrontier is a function whose calls are not (fully) expanded yet
+type callFrontier struct {
+	ID       ast.NodeID `json:"id"`
+	Depth    int        `json:"d"` // distance from the root
+	Incoming bool       `json:"in,omitempty"`
+	Skip     int        `json:"s,omitempty"` // callees (or callers) already returned for this function
+}
+
+// callGraphContinuation is the state carried by CallGraph.Continuation: the frontier left
+// when a page stopped and every node returned so far, so later pages neither repeat nor
+// re-expand them
+type callGraphContinuation struct {
+	Root      ast.NodeID     `json:"root"`
+	Direction Direction      `json:"dir"`
+	Pending   []callFrontier `json:"pending"`
+	Visited   []ast.NodeID   `json:"visited"`
+}
+
+func encodeCallGraphContinuation(c *callGraphContinuation) (string, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode continuation: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCallGraphContinuation parses a token and checks that it belongs to the call graph
+// of root in the given direction
+func decodeCallGraphContinuation(token string, root ast.NodeID, direction Direction) (*callGraphContinuation, error) {
+	if len(token) > maxContinuationTokenSize {
+		return nil, fmt.Errorf("%w: continuation token too large", apperrors.ErrInvalidArgument)
+	}
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed continuation token", apperrors.ErrInvalidArgument)
+	}
+	var c callGraphContinuation
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("%w: malformed continuation token", apperrors.ErrInvalidArgument)
+	}
+	if len(c.Visited) > maxContinuationNodes {
+		return nil, fmt.Errorf("%w: continuation token too large", apperrors.ErrInvalidArgument)
+	}
+	if c.Root != root || c.Direction != direction {
+		return nil, fmt.Errorf("%w: continuation token belongs to another call graph", apperrors.ErrInvalidArgument)
+	}
+	return &c, nil
+}
@@ -0,0 +1,214 @@
+package codeapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"bot-go/internal/apperrors"
+	"bot-go/internal/config"
+	"bot-go/internal/model/ast"
+	"bot-go/internal/service/codegraph"
+
+	"go.uber.org/zap"
+)
+
+// callsDatabase answers the function and call queries of GetCallGraph from a fixed call map
+type callsDatabase struct {
+	codegraph.GraphDatabase
+	calls map[ast.NodeID][]ast.NodeID // caller -> callees
+}
+
+func (d *callsDatabase) ExecuteRead(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	if id, ok := params["id"].(int64); ok {
+		return []map[string]any{{"name": fmt.Sprintf("f%d", id), "fileId": int64(1)}}, nil
+	}
+
+	id := ast.NodeID(params["functionId"].(int64))
+	var others []ast.NodeID
+	if strings.Contains(query, "(f:Function {id: $functionId})-[:CONTAINS*]") {
+		others = append(others, d.calls[id]...)
+	} else {
+		for caller, callees := range d.calls {
+			for _, callee := range callees {
+				if callee == id {
+					others = append(others, caller)
+				}
+			}
+		}
+	}
+	sort.Slice(others, func(i, j int) bool { return others[i] < others[j] })
+
+	skip := int(params["skip"].(int64))
+	if skip > len(others) {
+		skip = len(others)
+	}
+	others = others[skip:]
+	if limit, ok := params["limit"].(int64); ok && int(limit) < len(others) {
+		others = others[:limit]
+	}
+	records := make([]map[string]any, 0, len(others))
+	for _, other := range others {
+		records = append(records, map[string]any{
+			"otherId":   int64(other),
+			"otherName": fmt.Sprintf("f%d", other),
+			"fileId":    int64(1),
+			"callSites": []any{map[string]any{"fileId": int64(1), "startLine": int64(other)}},
+		})
+	}
+	return records, nil
+}
+
+func newCallsAnalyzer(calls map[ast.NodeID][]ast.NodeID) *graphAnalyzerImpl {
+	graph := codegraph.NewCodeGraphWithDatabase(&callsDatabase{calls: calls}, &config.Config{}, zap.NewNop())
+	return newGraphAnalyzerImpl(graph, zap.NewNop())
+}
+
+// collectPages follows continuation tokens from the first page and returns every node and
+// edge returned, failing on any node returned twice
+func collectPages(t *testing.T, a *graphAnalyzerImpl, root ast.NodeID, opts CallGraphOptions) (map[ast.NodeID]int, []string, int) {
+	t.Helper()
+	nodes := make(map[ast.NodeID]int)
+	var edges []string
+	pages := 0
+	for {
+		page, err := a.GetCallGraph(context.Background(), root, opts)
+		if err != nil {
+			t.Fatalf("page %d: %v", pages, err)
+		}
+		pages++
+		for id, node := range page.Nodes {
+			if _, seen := nodes[id]; seen && id != root {
+				t.Errorf("page %d repeats node %d", pages, id)
+			}
+			nodes[id] = node.Depth
+		}
+		for _, edge := range page.Edges {
+			edges = append(edges, fmt.Sprintf("%d -> %d", edge.CallerID, edge.CalleeID))
+		}
+		if page.Continuation == "" {
+			sort.Strings(edges)
+			return nodes, edges, pages
+		}
+		if pages > 50 {
+			t.Fatal("continuation never ends")
+		}
+		opts.Continuation = page.Continuation
+	}
+}
+
+func TestCallGraphPaging(t *testing.T) {
+	// 1 calls 2, 3 and 4; 2 and 3 call 5; 3 calls 6; 6 calls 1 back
+	calls := map[ast.NodeID][]ast.NodeID{
+		1: {2, 3, 4},
+		2: {5},
+		3: {5, 6},
+		6: {1},
+	}
+	a := newCallsAnalyzer(calls)
+
+	for _, direction := range []Direction{DirectionOutgoing, DirectionIncoming, DirectionBoth} {
+		opts := CallGraphOptions{Direction: direction, MaxDepth: 3}
+		wantNodes, wantEdges, _ := collectPages(t, a, 1, opts)
+
+		for _, capped := range []CallGraphOptions{
+			{Direction: direction, MaxDepth: 3, MaxNodes: 1},
+			{Direction: direction, MaxDepth: 3, MaxEdges: 1},
+			{Direction: direction, MaxDepth: 3, MaxNodes: 2, MaxEdges: 3},
+		} {
+			nodes, edges, pages := collectPages(t, a, 1, capped)
+			if pages < 2 && len(wantEdges) > 1 {
+				t.Errorf("%v nodes %d edges %d: one page, want several", direction, capped.MaxNodes, capped.MaxEdges)
+			}
+			if !reflect.DeepEqual(nodes, wantNodes) || !reflect.DeepEqual(edges, wantEdges) {
+				t.Errorf("%v nodes %d edges %d: paged %v %v, want %v %v", direction, capped.MaxNodes, capped.MaxEdges, nodes, edges, wantNodes, wantEdges)
+			}
+		}
+	}
+
+	// The outgoing graph reaches every function, the cycle back to the root adds only an edge
+	nodes, edges, _ := collectPages(t, a, 1, CallGraphOptions{Direction: DirectionOutgoing, MaxDepth: 3})
+	wantNodes := map[ast.NodeID]int{1: 0, 2: 1, 3: 1, 4: 1, 5: 2, 6: 2}
+	wantEdges := []string{"1 -> 2", "1 -> 3", "1 -> 4", "2 -> 5", "3 -> 5", "3 -> 6", "6 -> 1"}
+	if !reflect.DeepEqual(nodes, wantNodes) || !reflect.DeepEqual(edges, wantEdges) {
+		t.Errorf("outgoing graph %v %v, want %v %v", nodes, edges, wantNodes, wantEdges)
+	}
+}
+
+func TestCallGraphPaging_DeeperContinuation(t *testing.T) {
+	a := newCallsAnalyzer(map[ast.NodeID][]ast.NodeID{1: {2}, 2: {3}, 3: {4}})
+
+	page, err := a.GetCallGraph(context.Background(), 1, CallGraphOptions{Direction: DirectionOutgoing, MaxDepth: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !page.Truncated || page.Continuation == "" || len(page.Nodes) != 2 {
+		t.Fatalf("first page: %d nodes, truncated %v, token %q", len(page.Nodes), page.Truncated, page.Continuation)
+	}
+
+	// The same depth expands nothing more and ends the paging
+	same, err := a.GetCallGraph(context.Background(), 1, CallGraphOptions{Direction: DirectionOutgoing, MaxDepth: 1, Continuation: page.Continuation})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(same.Edges) != 0 || same.Continuation != "" {
+		t.Errorf("same depth: %d edges, token %q, want none", len(same.Edges), same.Continuation)
+	}
+
+	deeper, err := a.GetCallGraph(context.Background(), 1, CallGraphOptions{Direction: DirectionOutgoing, MaxDepth: 4, Continuation: page.Continuation})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deeper.Continuation != "" || len(deeper.Edges) != 2 || deeper.Nodes[3] == nil || deeper.Nodes[4] == nil {
+		t.Errorf("deeper page: %d edges, nodes %v, token %q", len(deeper.Edges), deeper.Nodes, deeper.Continuation)
+	}
+}
+
+func TestCallGraphContinuation_Rejected(t *testing.T) {
+	a := newCallsAnalyzer(map[ast.NodeID][]ast.NodeID{1: {2, 3}, 2: {3}})
+	page, err := a.GetCallGraph(context.Background(), 1, CallGraphOptions{Direction: DirectionOutgoing, MaxDepth: 3, MaxNodes: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Continuation == "" {
+		t.Fatal("no continuation token")
+	}
+
+	oversized, _ := encodeCallGraphContinuation(&callGraphContinuation{Root: 1, Visited: make([]ast.NodeID, maxContinuationNodes+1)})
+	tests := []struct {
+		name string
+		root ast.NodeID
+		opts CallGraphOptions
+	}{
+		{"other root", 2, CallGraphOptions{Direction: DirectionOutgoing, Continuation: page.Continuation}},
+		{"other direction", 1, CallGraphOptions{Direction: DirectionIncoming, Continuation: page.Continuation}},
+		{"malformed", 1, CallGraphOptions{Direction: DirectionOutgoing, Continuation: "not a token!"}},
+		{"too many nodes", 1, CallGraphOptions{Continuation: oversized}},
+		{"too long", 1, CallGraphOptions{Continuation: strings.Repeat("A", maxContinuationTokenSize+1)}},
+	}
+	for _, tt := range tests {
+		if _, err := a.GetCallGraph(context.Background(), tt.root, tt.opts); !errors.Is(err, apperrors.ErrInvalidArgument) {
+			t.Errorf("%s: err = %v, want an invalid argument", tt.name, err)
+		}
+	}
+}
+
+func TestCallGraphContinuation_VisitedCap(t *testing.T) {
+	callees := make([]ast.NodeID, 0, maxContinuationNodes+10)
+	for i := 0; i < maxContinuationNodes+10; i++ {
+		callees = append(callees, ast.NodeID(i+2))
+	}
+	a := newCallsAnalyzer(map[ast.NodeID][]ast.NodeID{1: callees})
+
+	page, err := a.GetCallGraph(context.Background(), 1, CallGraphOptions{Direction: DirectionOutgoing, MaxDepth: 1, MaxNodes: maxContinuationNodes + 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !page.Truncated || page.Continuation != "" {
+		t.Errorf("truncated %v, token of %d bytes, want truncated without a token", page.Truncated, len(page.Continuation))
+	}
+}
@@ -0,0 +1,387 @@
+package codeapi
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"bot-go/internal/model/ast"
+	"bot-go/internal/service/codegraph"
+	"bot-go/pkg/lsp/base"
+
+	"go.uber.org/zap"
+)
+
+// Evidence kinds of an ErrorLocation
+const (
+	EvidenceLiteral   = "literal"    // a string literal matches the message
+	EvidenceFrame     = "frame"      // a stack frame names the location
+	EvidenceCallGraph = "call_graph" // the literal's function calls or is called by a frame's function
+)
+
+// Confidence of each kind of evidence, combined as independent signals
+const (
+	literalConfidence     = 0.8 // scaled by the share of the literal's words found in the message
+	frameLineConfidence   = 0.9 // the frame's file and line fall in the function
+	frameNameConfidence   = 0.5 // only the function name matched; split between candidates
+	callGraphConfidence   = 0.3
+	minLiteralCoverage    = 0.5 // literals sharing fewer of their words with the message are dropped
+	maxResolvedFrames     = 10
+	maxNameCandidates     = 5
+	literalCandidateLimit = 20
+)
+
+var (
+	// pkg.(*T).Method(0xc000010000, ...) followed by \t/path/file.go:42 +0x1d
+	goFrameFunc = regexp.MustCompile(`^\s*([\w./\-]+(?:\.\(\*?[\w]+\))?\.[\w.]+)\(.*\)$`)
+	goFrameFile = regexp.MustCompile(`^\s+(\S+\.go):(\d+)`)
+	// File "app/views.py", line 12, in handler
+	pythonFrame = regexp.MustCompile(`File "([^"]+)", line (\d+), in (\S+)`)
+	// at Object.handler (/srv/app/routes.js:10:5) or at /srv/app/routes.js:10:5
+	jsFrame = regexp.MustCompile(`at (?:(\S+) \()?(\S+?\.(?:js|jsx|mjs|cjs|ts|tsx)):(\d+):\d+\)?`)
+	// at com.example.Service.load(Service.java:42)
+	javaFrame = regexp.MustCompile(`at ([\w$.<>]+)\(([\w$]+\.java):(\d+)\)`)
+	// A bare file:line, e.g. a log caller field "caller":"controller/repo.go:120"
+	fileLine = regexp.MustCompile(`([\w./\-]+\.(?:go|py|js|jsx|ts|tsx|java)):(\d+)`)
+)
+
+// parseStackTrace splits a log line or stack trace into its frames, innermost first, and the
+// remaining message text
+func parseStackTrace(text string) ([]StackFrame, string) {
+	var frames []StackFrame
+	var message []string
+	var pythonFrames []StackFrame
+	lines := strings.Split(text, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+		case strings.HasPrefix(trimmed, "goroutine ") && strings.HasSuffix(trimmed, ":"),
+			strings.HasPrefix(trimmed, "Traceback (most recent call last)"):
+		case goFrameFunc.MatchString(line) && i+1 < len(lines) && goFrameFile.MatchString(lines[i+1]):
+			m := goFrameFile.FindStringSubmatch(lines[i+1])
+			frames = append(frames, StackFrame{
+				Function: goFrameFunc.FindStringSubmatch(line)[1], File: m[1], Line: atoi(m[2]),
+			})
+			i++
+		case pythonFrame.MatchString(line):
+			m := pythonFrame.FindStringSubmatch(line)
+			pythonFrames = append(pythonFrames, StackFrame{Function: m[3], File: m[1], Line: atoi(m[2])})
+			// The next line is the source of the frame, not part of the message
+			if i+1 < len(lines) && strings.HasPrefix(lines[i+1], "    ") && !pythonFrame.MatchString(lines[i+1]) {
+				i++
+			}
+		case javaFrame.MatchString(line):
+			m := javaFrame.FindStringSubmatch(line)
+			frames = append(frames, StackFrame{Function: m[1], File: m[2], Line: atoi(m[3])})
+		case jsFrame.MatchString(line):
+			m := jsFrame.FindStringSubmatch(line)
+			frames = append(frames, StackFrame{Function: m[1], File: m[2], Line: atoi(m[3])})
+		default:
+			for _, m := range fileLine.FindAllStringSubmatch(line, -1) {
+				frames = append(frames, StackFrame{File: m[1], Line: atoi(m[2])})
+			}
+			message = append(message, trimmed)
+		}
+	}
+	// Python prints the innermost frame last
+	for i := len(pythonFrames) - 1; i >= 0; i-- {
+		frames = append(frames, pythonFrames[i])
+	}
+	return frames, strings.Join(message, "\n")
+}
+
+// frameFunctionName returns the bare function name of a qualified frame function, e.g.
+// handle for main.(*Server).handle.func1 and load for com.example.Service.load
+func frameFunctionName(qualified string) string {
+	parts := strings.FieldsFunc(qualified, func(r rune) bool { return r == '.' || r == '/' })
+	for i := len(parts) - 1; i >= 0; i-- {
+		part := strings.Trim(parts[i], "(*)")
+		switch {
+		case part == "" || part == "<anonymous>" || part == "<module>" || part == "<lambda>":
+		case isClosureSuffix(part):
+		case isDigits(part):
+		case part == "<init>" && i > 0:
+			return strings.Trim(parts[i-1], "(*)")
+		default:
+			return part
+		}
+	}
+	return ""
+}
+
+// literalCoverage returns the share of a literal's words that occur in the message. Format
+// verbs and placeholders such as %s, {} and {name} are not words.
+func literalCoverage(literal string, messageWords map[string]bool) float64 {
+	words := 0
+	found := 0
+	for _, word := range splitWords(literal) {
+		words++
+		if messageWords[word] {
+			found++
+		}
+	}
+	if words == 0 {
+		return 0
+	}
+	return float64(found) / float64(words)
+}
+
+// splitWords returns the lowercased words of text, leaving out format verbs and placeholders
+func splitWords(text string) []string {
+	var words []string
+	for _, field := range strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '%' && r != '_'
+	}) {
+		if strings.HasPrefix(field, "%") || len(field) < 2 {
+			continue
+		}
+		words = append(words, strings.ToLower(field))
+	}
+	return words
+}
+
+// isClosureSuffix reports whether a name segment is one the Go runtime gives closures and
+// go statement wrappers, e.g. func1 and gowrap2
+func isClosureSuffix(part string) bool {
+	name := strings.TrimRightFunc(part, unicode.IsDigit)
+	return name != part && (name == "func" || name == "gowrap")
+}
+
+func isDigits(s string) bool {
+	return s != "" && strings.IndexFunc(s, func(r rune) bool { return !unicode.IsDigit(r) }) < 0
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// combineConfidence merges independent evidence: the chance that at least one is right
+func combineConfidence(a, b float64) float64 {
+	return 1 - (1-a)*(1-b)
+}
+
+func (a *graphAnalyzerImpl) ResolveErrorLocation(ctx context.Context, repoName, text string, limit int) (*ErrorResolution, error) {
+	frames, message := parseStackTrace(text)
+	result := &ErrorResolution{Message: message, Frames: frames}
+	if limit <= 0 {
+		limit = 10
+	}
+
+	locations := make(map[string]*ErrorLocation)
+	add := func(loc *ErrorLocation, confidence float64, evidence string) *ErrorLocation {
+		key := loc.FilePath + ":" + strconv.Itoa(loc.Range.Start.Line)
+		if loc.FunctionID != ast.InvalidNodeID && loc.Literal == "" {
+			key = "fn:" + strconv.FormatInt(int64(loc.FunctionID), 10)
+		}
+		existing, ok := locations[key]
+		if !ok {
+			loc.Confidence = confidence
+			loc.Evidence = append([]string{evidence}, loc.Evidence...)
+			locations[key] = loc
+			return loc
+		}
+		existing.Confidence = combineConfidence(existing.Confidence, confidence)
+		existing.Evidence = append(existing.Evidence, evidence)
+		return existing
+	}
+
+	// Functions named by frames, with the confidence of the frame
+	frameFunctions := make(map[ast.NodeID]float64)
+	for i, frame := range frames {
+		if i == maxResolvedFrames {
+			break
+		}
+		// Outer frames are weaker evidence of where the message comes from
+		weight := 1 - 0.05*float64(i)
+		for _, candidate := range a.resolveFrame(ctx, repoName, frame) {
+			loc := add(candidate.location, candidate.confidence*weight, EvidenceFrame)
+			frameFunctions[loc.FunctionID] = max(frameFunctions[loc.FunctionID], loc.Confidence)
+		}
+	}
+
+	// String literals matching the message, from the literal index
+	var literalLocations []*ErrorLocation
+	if strings.TrimSpace(message) != "" {
+		matches, err := a.graph.SearchStringLiterals(ctx, repoName, message, codegraph.StringLiteralSearchOptions{Limit: literalCandidateLimit})
+		if err != nil {
+			// Without the literal index frames can still be resolved
+			a.logger.Warn("String literal search failed", zap.String("repo", repoName), zap.Error(err))
+		}
+		messageWords := make(map[string]bool)
+		for _, word := range splitWords(message) {
+			messageWords[word] = true
+		}
+		for _, match := range matches {
+			coverage := literalCoverage(match.Value, messageWords)
+			if coverage < minLiteralCoverage {
+				continue
+			}
+			loc := &ErrorLocation{
+				FilePath:     match.FilePath,
+				Range:        match.Range,
+				FunctionID:   match.FunctionID,
+				FunctionName: match.FunctionName,
+				Literal:      match.Value,
+			}
+			confidence := literalConfidence * coverage
+			if frameConfidence, ok := frameFunctions[match.FunctionID]; ok && match.FunctionID != ast.InvalidNodeID {
+				// The literal is in a function the trace passes through
+				confidence = combineConfidence(confidence, frameConfidence)
+				loc.Evidence = append(loc.Evidence, EvidenceFrame)
+				// The literal location replaces the frame's, which is less precise
+				delete(locations, "fn:"+strconv.FormatInt(int64(match.FunctionID), 10))
+			}
+			loc = add(loc, confidence, EvidenceLiteral)
+			literalLocations = append(literalLocations, loc)
+		}
+	}
+
+	a.addCallGraphEvidence(ctx, literalLocations, frameFunctions)
+
+	for _, loc := range locations {
+		result.Locations = append(result.Locations, loc)
+	}
+	sort.SliceStable(result.Locations, func(i, j int) bool {
+		li, lj := result.Locations[i], result.Locations[j]
+		if li.Confidence != lj.Confidence {
+			return li.Confidence > lj.Confidence
+		}
+		if li.FilePath != lj.FilePath {
+			return li.FilePath < lj.FilePath
+		}
+		return li.Range.Start.Line < lj.Range.Start.Line
+	})
+	if len(result.Locations) > limit {
+		result.Locations = result.Locations[:limit]
+	}
+	return result, nil
+}
+
+// addCallGraphEvidence raises the confidence of literals whose function directly calls, or is
+// called by, a function named in the trace
+func (a *graphAnalyzerImpl) addCallGraphEvidence(ctx context.Context, literals []*ErrorLocation, frameFunctions map[ast.NodeID]float64) {
+	var literalIDs, frameIDs []int64
+	for _, loc := range literals {
+		if loc.FunctionID != ast.InvalidNodeID {
+			literalIDs = append(literalIDs, int64(loc.FunctionID))
+		}
+	}
+	for id := range frameFunctions {
+		if id != ast.InvalidNodeID {
+			frameIDs = append(frameIDs, int64(id))
+		}
+	}
+	if len(literalIDs) == 0 || len(frameIDs) == 0 {
+		return
+	}
+
+	records, err := a.graph.ExecuteRead(ctx, `
+		MATCH (x:Function)-[:CONTAINS*]->(:FunctionCall)-[:CALLS_FUNCTION]->(y:Function)
+		WHERE (x.id IN $literals AND y.id IN $frames) OR (x.id IN $frames AND y.id IN $literals)
+		RETURN DISTINCT CASE WHEN x.id IN $literals THEN x.id ELSE y.id END AS id
+	`, map[string]any{"literals": literalIDs, "frames": frameIDs})
+	if err != nil {
+		a.logger.Warn("Failed to relate literals to stack frames", zap.Error(err))
+		return
+	}
+	related := make(map[ast.NodeID]bool, len(records))
+	for _, record := range records {
+		related[ast.NodeID(toInt64(record["id"]))] = true
+	}
+	for _, loc := range literals {
+		if related[loc.FunctionID] && !containsString(loc.Evidence, EvidenceFrame) {
+			loc.Confidence = combineConfidence(loc.Confidence, callGraphConfidence)
+			loc.Evidence = append(loc.Evidence, EvidenceCallGraph)
+		}
+	}
+}
+
+type frameCandidate struct {
+	location   *ErrorLocation
+	confidence float64
+}
+
+// resolveFrame finds the functions a stack frame may refer to: the innermost function
+// containing the frame's line in the longest file path ending like the frame's, or else
+// functions with its name
+func (a *graphAnalyzerImpl) resolveFrame(ctx context.Context, repoName string, frame StackFrame) []frameCandidate {
+	name := frameFunctionName(frame.Function)
+	frameLine := base.Range{
+		Start: base.Position{Line: frame.Line - 1},
+		End:   base.Position{Line: frame.Line - 1},
+	}
+
+	if frame.File != "" && frame.Line > 0 {
+		records, err := a.graph.ExecuteRead(ctx, `
+			MATCH (fs:FileScope {repo: $repo})
+			WHERE $file = fs.path OR $file ENDS WITH '/' + fs.path OR fs.path ENDS WITH '/' + $file
+			WITH fs.path AS path, max(fs.id) AS fileId
+			MATCH (f:Function)
+			WHERE f.fileId = fileId AND f.startLine <= $line AND f.endLine >= $line
+			RETURN f.id AS id, f.name AS name, path
+			ORDER BY size(path) DESC, f.startLine DESC
+			LIMIT 1
+		`, map[string]any{"repo": repoName, "file": strings.TrimPrefix(frame.File, "./"), "line": frame.Line - 1})
+		if err != nil {
+			a.logger.Warn("Failed to resolve stack frame", zap.String("file", frame.File), zap.Error(err))
+		} else if len(records) > 0 {
+			return []frameCandidate{{
+				location: &ErrorLocation{
+					FilePath:     toString(records[0]["path"]),
+					Range:        frameLine,
+					FunctionID:   ast.NodeID(toInt64(records[0]["id"])),
+					FunctionName: toString(records[0]["name"]),
+				},
+				confidence: frameLineConfidence,
+			}}
+		}
+	}
+	if name == "" {
+		return nil
+	}
+
+	records, err := a.graph.ExecuteRead(ctx, `
+		MATCH (fs:FileScope {repo: $repo})
+		MATCH (f:Function {name: $name})
+		WHERE f.fileId = fs.id
+		RETURN f, fs.path AS path
+		ORDER BY path, f.id
+		LIMIT $limit
+	`, map[string]any{"repo": repoName, "name": name, "limit": int64(maxNameCandidates)})
+	if err != nil {
+		a.logger.Warn("Failed to resolve stack frame", zap.String("function", name), zap.Error(err))
+		return nil
+	}
+	candidates := make([]frameCandidate, 0, len(records))
+	for _, record := range records {
+		props, ok := record["f"].(map[string]any)
+		if !ok {
+			continue
+		}
+		candidates = append(candidates, frameCandidate{
+			location: &ErrorLocation{
+				FilePath:     toString(record["path"]),
+				Range:        codegraph.RangeFromProperties(props),
+				FunctionID:   ast.NodeID(toInt64(props["id"])),
+				FunctionName: name,
+			},
+			confidence: frameNameConfidence / float64(len(records)),
+		})
+	}
+	return candidates
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package codeapi
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseStackTrace(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		frames  []StackFrame
+		message string
+	}{
+		{
+			name: "go panic",
+			text: "panic: runtime error: index out of range\n\ngoroutine 1 [running]:\n" +
+				"bot-go/internal/parse.(*GoVisitor).handleCall.func1(0xc000010000)\n" +
+				"\t/src/bot-go/internal/parse/go_visitor.go:412 +0x1d\n" +
+				"main.main()\n\t/src/bot-go/cmd/main.go:20 +0x25\n",
+			frames: []StackFrame{
+				{Function: "bot-go/internal/parse.(*GoVisitor).handleCall.func1", File: "/src/bot-go/internal/parse/go_visitor.go", Line: 412},
+				{Function: "main.main", File: "/src/bot-go/cmd/main.go", Line: 20},
+			},
+			message: "panic: runtime error: index out of range",
+		},
+		{
+			name: "python traceback",
+			text: "Traceback (most recent call last):\n" +
+				"  File \"app/main.py\", line 10, in <module>\n    run()\n" +
+				"  File \"app/jobs.py\", line 42, in run\n    raise ValueError(\"bad job config\")\n" +
+				"ValueError: bad job config",
+			frames: []StackFrame{
+				{Function: "run", File: "app/jobs.py", Line: 42},
+				{Function: "<module>", File: "app/main.py", Line: 10},
+			},
+			message: "ValueError: bad job config",
+		},
+		{
+			name: "javascript",
+			text: "Error: user not found\n    at Object.findUser (/srv/app/users.js:10:5)\n    at /srv/app/routes.js:3:1",
+			frames: []StackFrame{
+				{Function: "Object.findUser", File: "/srv/app/users.js", Line: 10},
+				{File: "/srv/app/routes.js", Line: 3},
+			},
+			message: "Error: user not found",
+		},
+		{
+			name: "java",
+			text: "java.lang.IllegalStateException: cache closed\n\tat com.example.Cache.<init>(Cache.java:31)",
+			frames: []StackFrame{
+				{Function: "com.example.Cache.<init>", File: "Cache.java", Line: 31},
+			},
+			message: "java.lang.IllegalStateException: cache closed",
+		},
+		{
+			name:    "log line with caller",
+			text:    `{"level":"error","caller":"controller/repo_controller.go:120","msg":"failed to build index"}`,
+			frames:  []StackFrame{{File: "controller/repo_controller.go", Line: 120}},
+			message: `{"level":"error","caller":"controller/repo_controller.go:120","msg":"failed to build index"}`,
+		},
+	}
+	for _, tt := range tests {
+		frames, message := parseStackTrace(tt.text)
+		if len(frames) != len(tt.frames) {
+			t.Errorf("%s: frames = %+v, want %+v", tt.name, frames, tt.frames)
+			continue
+		}
+		for i := range frames {
+			if frames[i] != tt.frames[i] {
+				t.Errorf("%s: frame %d = %+v, want %+v", tt.name, i, frames[i], tt.frames[i])
+			}
+		}
+		if message != tt.message {
+			t.Errorf("%s: message = %q, want %q", tt.name, message, tt.message)
+		}
+	}
+}
+
+func TestFrameFunctionName(t *testing.T) {
+	tests := map[string]string{
+		"bot-go/internal/parse.(*GoVisitor).handleCall.func1": "handleCall",
+		"main.main":                      "main",
+		"Object.findUser":                "findUser",
+		"com.example.Cache.<init>":       "Cache",
+		"<module>":                       "",
+		"":                               "",
+		"com.example.Service.load":       "load",
+		"Foo.bar.<anonymous>":            "bar",
+		"server.(*Server).Serve.gowrap1": "Serve",
+	}
+	for qualified, want := range tests {
+		if got := frameFunctionName(qualified); got != want {
+			t.Errorf("frameFunctionName(%q) = %q, want %q", qualified, got, want)
+		}
+	}
+}
+
+func TestLiteralCoverage(t *testing.T) {
+	words := make(map[string]bool)
+	for _, word := range splitWords("failed to read nodes of internal/foo.go: connection refused") {
+		words[word] = true
+	}
+	// %s and %w are placeholders, "to" and "of" count
+	if got := literalCoverage("failed to read nodes of %s: %w", words); got != 1 {
+		t.Errorf("coverage = %g, want 1", got)
+	}
+	if got := literalCoverage("failed to write nodes", words); got != 0.75 {
+		t.Errorf("coverage = %g, want 0.75", got)
+	}
+	if got := literalCoverage("%s", words); got != 0 {
+		t.Errorf("coverage = %g, want 0", got)
+	}
+	if got := combineConfidence(0.8, 0.5); math.Abs(got-0.9) > 1e-9 {
+		t.Errorf("combineConfidence = %g, want 0.9", got)
+	}
+}
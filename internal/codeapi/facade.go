@@ -18,6 +18,9 @@ type CodeAPI interface {
 	// Analyzer returns the GraphAnalyzer for graph traversals
 	Analyzer() GraphAnalyzer
 
+	// Modules returns the ModuleAnalyzer for module-level summaries
+	Modules() ModuleAnalyzer
+
 	// --- Raw Query Access ---
 
 	// ExecuteCypher executes a raw Cypher query and returns the results.
@@ -104,6 +107,7 @@ type VariableInfo struct {
 type codeAPIImpl struct {
 	reader   CodeReader
 	analyzer GraphAnalyzer
+	modules  ModuleAnalyzer
 	graph    *codegraph.CodeGraph
 	logger   *zap.Logger
 }
@@ -116,6 +120,7 @@ func NewCodeAPI(graph *codegraph.CodeGraph, logger *zap.Logger) CodeAPI {
 	return &codeAPIImpl{
 		reader:   reader,
 		analyzer: analyzer,
+		modules:  newModuleAnalyzerImpl(graph, logger),
 		graph:    graph,
 		logger:   logger,
 	}
@@ -131,6 +136,11 @@ func (api *codeAPIImpl) Analyzer() GraphAnalyzer {
 	return api.analyzer
 }
 
+// Modules returns the ModuleAnalyzer
+func (api *codeAPIImpl) Modules() ModuleAnalyzer {
+	return api.modules
+}
+
 // ExecuteCypher executes a raw read-only Cypher query
 func (api *codeAPIImpl) ExecuteCypher(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
 	return api.graph.ExecuteRead(ctx, query, params)
@@ -0,0 +1,66 @@
+package codeapi
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	"bot-go/internal/service/codegraph"
+	"bot-go/internal/testenv"
+
+	"go.uber.org/zap"
+)
+
+// graphFixture is a Neo4j graph that tests seed with Cypher. Its files get IDs from a random
+// range so tests sharing a server do not collide, and their nodes are deleted when the test
+// ends.
+type graphFixture struct {
+	graph    *codegraph.CodeGraph
+	repo     string // the testenv repository; other repositories are named after it
+	fileBase int64
+}
+
+// fixtureFiles is the number of file IDs a fixture reserves
+const fixtureFiles = 100
+
+func newGraphFixture(t *testing.T) *graphFixture {
+	t.Helper()
+	env := testenv.Start(t, testenv.Options{Neo4j: true})
+	ctx := context.Background()
+
+	graph, err := codegraph.NewCodeGraph(env.Config.Neo4j.URI, env.Config.Neo4j.Username, env.Config.Neo4j.Password, env.Config, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to connect to Neo4j: %v", err)
+	}
+	f := &graphFixture{graph: graph, repo: env.Repo.Name, fileBase: 1<<30 + rand.Int63n(1<<20)*fixtureFiles}
+	t.Cleanup(func() {
+		_, err := graph.ExecuteWrite(ctx, `
+			MATCH (n)
+			WHERE n.fileId >= $from AND n.fileId < $to
+			DETACH DELETE n
+		`, map[string]any{"from": f.fileBase, "to": f.fileBase + fixtureFiles})
+		if err != nil {
+			t.Logf("failed to delete the fixture graph: %v", err)
+		}
+		graph.Close(ctx)
+	})
+	return f
+}
+
+// fileID returns the ID of the fixture's nth file
+func (f *graphFixture) fileID(n int) int64 {
+	return f.fileBase + int64(n)
+}
+
+// id returns the ID of the seq-th node of the fixture's nth file
+func (f *graphFixture) id(n, seq int) int64 {
+	return f.fileID(n)<<32 | int64(seq)
+}
+
+// write runs a Cypher write seeding the fixture
+func (f *graphFixture) write(t *testing.T, query string, params map[string]any) {
+	t.Helper()
+	if _, err := f.graph.ExecuteWrite(context.Background(), query, params); err != nil {
+		t.Fatalf("failed to seed the graph: %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package codeapi
+
+import (
+	"math"
+	"sort"
+)
+
+// ImpactScoring weights affected nodes for GetImpact's ranking. A node scores
+//
+//	edge weight × DepthDecay^(depth-1) × (1 + FanInWeight × ln(1 + fan-in))
+//
+// where the edge weight depends on how the node is reached and fan-in is its number of
+// callers. The risk score of a change is the sum of the node scores.
+type ImpactScoring struct {
+	DepthDecay      float64 // multiplier per extra level of distance, in (0, 1]
+	CallGraphWeight float64 // edge weight of callers
+	DataFlowWeight  float64 // edge weight of data dependents
+	FanInWeight     float64 // 0 ignores fan-in
+
+	MediumRiskThreshold float64 // risk scores at or above this are "medium"
+	HighRiskThreshold   float64 // risk scores at or above this are "high"
+}
+
+// Risk levels of an ImpactResult
+const (
+	RiskLow    = "low"
+	RiskMedium = "medium"
+	RiskHigh   = "high"
+)
+
+// DefaultImpactScoring returns the scoring used when ImpactOptions.Scoring is nil
+func DefaultImpactScoring() ImpactScoring {
+	return ImpactScoring{
+		DepthDecay:          0.5,
+		CallGraphWeight:     1.0,
+		DataFlowWeight:      0.8,
+		FanInWeight:         0.25,
+		MediumRiskThreshold: 3,
+		HighRiskThreshold:   10,
+	}
+}
+
+// score returns the score of one affected node
+func (s ImpactScoring) score(node *ImpactNode) float64 {
+	weight := s.CallGraphWeight
+	if node.Impact == ImpactTypeDataFlow {
+		weight = s.DataFlowWeight
+	}
+	depth := node.Depth
+	if depth < 0 {
+		depth = -depth // callers have negative depths
+	}
+	decay := math.Pow(s.DepthDecay, float64(max(depth, 1)-1))
+	return weight * decay * (1 + s.FanInWeight*math.Log1p(float64(node.FanIn)))
+}
+
+// riskLevel maps a risk score to a risk level
+func (s ImpactScoring) riskLevel(risk float64) string {
+	switch {
+	case risk >= s.HighRiskThreshold:
+		return RiskHigh
+	case risk >= s.MediumRiskThreshold:
+		return RiskMedium
+	default:
+		return RiskLow
+	}
+}
+
+// rankImpact scores the affected nodes of result (whose FanIn must be set), orders them by
+// descending score into Ranked and sets the overall risk
+func rankImpact(result *ImpactResult, scoring ImpactScoring) {
+	result.Ranked = make([]*ImpactNode, 0, len(result.AffectedNodes))
+	result.RiskScore = 0
+	for _, node := range result.AffectedNodes {
+		node.Score = scoring.score(node)
+		result.RiskScore += node.Score
+		result.Ranked = append(result.Ranked, node)
+	}
+	sort.SliceStable(result.Ranked, func(i, j int) bool {
+		if result.Ranked[i].Score != result.Ranked[j].Score {
+			return result.Ranked[i].Score > result.Ranked[j].Score
+		}
+		return result.Ranked[i].ID < result.Ranked[j].ID
+	})
+	result.RiskLevel = scoring.riskLevel(result.RiskScore)
+}
@@ -0,0 +1,38 @@
+package codeapi
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRankImpact(t *testing.T) {
+	result := &ImpactResult{AffectedNodes: []*ImpactNode{
+		{ID: 1, Depth: -2, Impact: ImpactTypeCallGraph},           // 1 × 0.5
+		{ID: 2, Depth: -1, Impact: ImpactTypeCallGraph, FanIn: 3}, // 1 × 1 × (1 + 0.25 ln 4)
+		{ID: 3, Depth: 1, Impact: ImpactTypeDataFlow},             // 0.8
+	}}
+	rankImpact(result, DefaultImpactScoring())
+
+	var order []int
+	for _, node := range result.Ranked {
+		order = append(order, int(node.ID))
+	}
+	if len(order) != 3 || order[0] != 2 || order[1] != 3 || order[2] != 1 {
+		t.Fatalf("unexpected ranking %v", order)
+	}
+
+	want := 0.5 + (1 + 0.25*math.Log(4)) + 0.8
+	if math.Abs(result.RiskScore-want) > 1e-9 {
+		t.Errorf("RiskScore = %g, want %g", result.RiskScore, want)
+	}
+	if result.RiskLevel != RiskLow {
+		t.Errorf("RiskLevel = %q, want %q", result.RiskLevel, RiskLow)
+	}
+
+	scoring := DefaultImpactScoring()
+	scoring.HighRiskThreshold = 2
+	rankImpact(result, scoring)
+	if result.RiskLevel != RiskHigh {
+		t.Errorf("RiskLevel = %q, want %q", result.RiskLevel, RiskHigh)
+	}
+}
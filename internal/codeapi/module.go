@@ -0,0 +1,63 @@
+package codeapi
+
+import (
+	"context"
+
+	"bot-go/internal/model/ast"
+)
+
+// ModuleAnalyzer summarizes the code graph per module, complementing the function and
+// class centric GraphAnalyzer. A module is a directory of source files (a Go package, a
+// Python package, a JavaScript folder); only the latest indexed version of each file counts.
+type ModuleAnalyzer interface {
+	// ListModules returns the modules of a repository with their size metrics, ordered by path
+	ListModules(ctx context.Context, repoName string) ([]*ModuleInfo, error)
+
+	// GetModuleSummary returns a module with its exported symbols, the modules it calls
+	// into and the modules calling into it. modulePath is the directory relative to the
+	// repository root, "." for the root.
+	GetModuleSummary(ctx context.Context, repoName, modulePath string) (*ModuleSummary, error)
+}
+
+// ModuleInfo describes a module and its size
+type ModuleInfo struct {
+	Path      string   // Directory relative to the repository root, "." for the root
+	Name      string   // Package name declared by the files (Go), otherwise the directory name
+	Languages []string // Languages of the files, sorted
+	Files     int
+	Lines     int
+	Classes   int
+	Functions int // Top-level functions
+	Methods   int
+}
+
+// ModuleSummary is a module with its public surface and its call dependencies
+type ModuleSummary struct {
+	Module       *ModuleInfo
+	Exported     []*ModuleSymbol     // Ordered by file and position
+	Dependencies []*ModuleDependency // Modules this module calls, most calls first
+	Dependents   []*ModuleDependency // Modules calling this module, most calls first
+}
+
+// ModuleSymbol is a class, function or method exported by a module
+type ModuleSymbol struct {
+	ID        ast.NodeID
+	Name      string
+	Kind      string // "class", "function" or "method"
+	ClassName string // Containing class of a method
+	Location  Location
+}
+
+// ModuleDependency is a call dependency between two modules
+type ModuleDependency struct {
+	Path      string // The other module
+	Calls     int    // Call sites crossing the module boundary
+	Functions int    // Distinct functions of the other module involved
+}
+
+// Kinds of a ModuleSymbol
+const (
+	SymbolKindClass    = "class"
+	SymbolKindFunction = "function"
+	SymbolKindMethod   = "method"
+)
@@ -0,0 +1,331 @@
+package codeapi
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"bot-go/internal/apperrors"
+	"bot-go/internal/model/ast"
+	"bot-go/internal/service/codegraph"
+	"bot-go/internal/util"
+
+	"go.uber.org/zap"
+)
+
+// moduleAnalyzerImpl implements ModuleAnalyzer
+type moduleAnalyzerImpl struct {
+	graph  *codegraph.CodeGraph
+	logger *zap.Logger
+}
+
+func newModuleAnalyzerImpl(graph *codegraph.CodeGraph, logger *zap.Logger) *moduleAnalyzerImpl {
+	return &moduleAnalyzerImpl{
+		graph:  graph,
+		logger: logger,
+	}
+}
+
+// moduleFile is the latest indexed version of a file
+type moduleFile struct {
+	fileID   int64
+	path     string
+	language string
+	lines    int
+	module   string // ModuleScope name, the package name for Go
+}
+
+func (m *moduleAnalyzerImpl) ListModules(ctx context.Context, repoName string) ([]*ModuleInfo, error) {
+	files, err := m.latestFiles(ctx, repoName)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := m.symbolCounts(ctx, files)
+	if err != nil {
+		return nil, err
+	}
+
+	byDir := groupByModule(files)
+	modules := make([]*ModuleInfo, 0, len(byDir))
+	for dir, dirFiles := range byDir {
+		modules = append(modules, moduleInfo(dir, dirFiles, counts))
+	}
+	sort.Slice(modules, func(i, j int) bool { return modules[i].Path < modules[j].Path })
+	return modules, nil
+}
+
+func (m *moduleAnalyzerImpl) GetModuleSummary(ctx context.Context, repoName, modulePath string) (*ModuleSummary, error) {
+	modulePath = util.CanonicalPath(modulePath)
+	if modulePath == "" {
+		modulePath = "."
+	}
+	files, err := m.latestFiles(ctx, repoName)
+	if err != nil {
+		return nil, err
+	}
+	moduleFiles := groupByModule(files)[modulePath]
+	if len(moduleFiles) == 0 {
+		return nil, apperrors.NotFound("module", modulePath)
+	}
+	counts, err := m.symbolCounts(ctx, moduleFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &ModuleSummary{Module: moduleInfo(modulePath, moduleFiles, counts)}
+	if summary.Exported, err = m.exportedSymbols(ctx, moduleFiles); err != nil {
+		return nil, err
+	}
+
+	// Calls resolved to or from older versions of a file are stale and not counted
+	latest := make(map[int64]string, len(files))
+	for _, f := range files {
+		latest[f.fileID] = f.path
+	}
+	ids := fileIDs(moduleFiles)
+	if summary.Dependencies, err = m.moduleCalls(ctx, modulePath, ids, latest, `
+		MATCH (f:Function)-[:CONTAINS*]->(fc:FunctionCall)-[:CALLS_FUNCTION]->(g:Function)
+		WHERE f.fileId IN $fileIds AND NOT g.fileId IN $fileIds
+		RETURN g.fileId AS fileId, count(DISTINCT fc) AS calls, count(DISTINCT g) AS functions
+	`); err != nil {
+		return nil, err
+	}
+	if summary.Dependents, err = m.moduleCalls(ctx, modulePath, ids, latest, `
+		MATCH (f:Function)-[:CONTAINS*]->(fc:FunctionCall)-[:CALLS_FUNCTION]->(g:Function)
+		WHERE g.fileId IN $fileIds AND NOT f.fileId IN $fileIds
+		RETURN f.fileId AS fileId, count(DISTINCT fc) AS calls, count(DISTINCT f) AS functions
+	`); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// latestFiles returns the latest indexed version of every file of a repository
+func (m *moduleAnalyzerImpl) latestFiles(ctx context.Context, repoName string) ([]*moduleFile, error) {
+	records, err := m.graph.ExecuteRead(ctx, `
+		MATCH (fs:FileScope {repo: $repo})
+		WITH fs.path AS path, max(fs.id) AS fileId
+		MATCH (f:FileScope {id: fileId, repo: $repo})
+		OPTIONAL MATCH (f)-[:CONTAINS]->(ms:ModuleScope)
+		RETURN path, fileId, f.language AS language, ms.name AS module,
+		       f {.startLine, .startChar, .endLine, .endChar, .range} AS range
+		ORDER BY path
+	`, map[string]any{"repo": repoName})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+
+	files := make([]*moduleFile, 0, len(records))
+	for _, record := range records {
+		f := &moduleFile{
+			fileID:   toInt64(record["fileId"]),
+			path:     toString(record["path"]),
+			language: toString(record["language"]),
+			module:   toString(record["module"]),
+		}
+		if rng := codegraph.RangeFromValue(record["range"]); rng.End.Line > 0 {
+			f.lines = rng.End.Line + 1
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// symbolCounts counts the classes, top-level functions and methods per file. Functions
+// nested in another function (closures, local functions and their classes) are not counted.
+func (m *moduleAnalyzerImpl) symbolCounts(ctx context.Context, files []*moduleFile) (map[int64]map[string]int, error) {
+	records, err := m.graph.ExecuteRead(ctx, `
+		MATCH (c:Class)
+		WHERE c.fileId IN $fileIds AND NOT EXISTS { MATCH (:Function)-[:CONTAINS*]->(c) }
+		RETURN c.fileId AS fileId, 'class' AS kind, count(c) AS n
+		UNION ALL
+		MATCH (f:Function)
+		WHERE f.fileId IN $fileIds AND coalesce(f.md_anonymous, false) = false
+		  AND NOT EXISTS { MATCH (:Function)-[:CONTAINS*]->(f) }
+		OPTIONAL MATCH (c:Class)-[:CONTAINS]->(f)
+		RETURN f.fileId AS fileId, CASE WHEN c IS NULL THEN 'function' ELSE 'method' END AS kind, count(f) AS n
+	`, map[string]any{"fileIds": fileIDs(files)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to count module symbols: %w", err)
+	}
+
+	counts := make(map[int64]map[string]int)
+	for _, record := range records {
+		fileID := toInt64(record["fileId"])
+		if counts[fileID] == nil {
+			counts[fileID] = make(map[string]int)
+		}
+		counts[fileID][toString(record["kind"])] += int(toInt64(record["n"]))
+	}
+	return counts, nil
+}
+
+// exportedSymbols returns the exported classes, functions and methods of a module's files.
+// Methods are exported only when their class is; functions nested in another function
+// never are.
+func (m *moduleAnalyzerImpl) exportedSymbols(ctx context.Context, files []*moduleFile) ([]*ModuleSymbol, error) {
+	records, err := m.graph.ExecuteRead(ctx, `
+		MATCH (c:Class)
+		WHERE c.fileId IN $fileIds AND NOT EXISTS { MATCH (:Function)-[:CONTAINS*]->(c) }
+		RETURN c.id AS id, c.name AS name, c.fileId AS fileId, 'class' AS kind, null AS className,
+		       c {.startLine, .startChar, .endLine, .endChar, .range} AS range
+		UNION ALL
+		MATCH (f:Function)
+		WHERE f.fileId IN $fileIds AND coalesce(f.md_anonymous, false) = false
+		  AND NOT EXISTS { MATCH (:Function)-[:CONTAINS*]->(f) }
+		OPTIONAL MATCH (c:Class)-[:CONTAINS]->(f)
+		RETURN f.id AS id, f.name AS name, f.fileId AS fileId,
+		       CASE WHEN c IS NULL THEN 'function' ELSE 'method' END AS kind, c.name AS className,
+		       f {.startLine, .startChar, .endLine, .endChar, .range} AS range
+	`, map[string]any{"fileIds": fileIDs(files)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list module symbols: %w", err)
+	}
+
+	byID := make(map[int64]*moduleFile, len(files))
+	for _, f := range files {
+		byID[f.fileID] = f
+	}
+	symbols := make([]*ModuleSymbol, 0)
+	for _, record := range records {
+		file := byID[toInt64(record["fileId"])]
+		if file == nil {
+			continue
+		}
+		symbol := &ModuleSymbol{
+			ID:        ast.NodeID(toInt64(record["id"])),
+			Name:      toString(record["name"]),
+			Kind:      toString(record["kind"]),
+			ClassName: toString(record["className"]),
+			Location: Location{
+				FilePath: file.path,
+				FileID:   int32(file.fileID),
+				Range:    codegraph.RangeFromValue(record["range"]),
+			},
+		}
+		if !isExported(symbol.Name, file.language) {
+			continue
+		}
+		if symbol.Kind == SymbolKindMethod && !isExported(symbol.ClassName, file.language) {
+			continue
+		}
+		symbols = append(symbols, symbol)
+	}
+	sort.Slice(symbols, func(i, j int) bool {
+		a, b := symbols[i].Location, symbols[j].Location
+		if a.FilePath != b.FilePath {
+			return a.FilePath < b.FilePath
+		}
+		if a.Range.Start.Line != b.Range.Start.Line {
+			return a.Range.Start.Line < b.Range.Start.Line
+		}
+		return symbols[i].ID < symbols[j].ID
+	})
+	return symbols, nil
+}
+
+// moduleCalls runs a query returning call counts per file on the other side of the module
+// boundary and sums them per module
+func (m *moduleAnalyzerImpl) moduleCalls(ctx context.Context, modulePath string, ids []int64, latest map[int64]string, query string) ([]*ModuleDependency, error) {
+	records, err := m.graph.ExecuteRead(ctx, query, map[string]any{"fileIds": ids})
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate module calls: %w", err)
+	}
+
+	byModule := make(map[string]*ModuleDependency)
+	for _, record := range records {
+		filePath, ok := latest[toInt64(record["fileId"])]
+		if !ok {
+			continue
+		}
+		dir := path.Dir(filePath)
+		if dir == modulePath {
+			continue
+		}
+		dep := byModule[dir]
+		if dep == nil {
+			dep = &ModuleDependency{Path: dir}
+			byModule[dir] = dep
+		}
+		dep.Calls += int(toInt64(record["calls"]))
+		dep.Functions += int(toInt64(record["functions"]))
+	}
+
+	deps := make([]*ModuleDependency, 0, len(byModule))
+	for _, dep := range byModule {
+		deps = append(deps, dep)
+	}
+	sort.Slice(deps, func(i, j int) bool {
+		if deps[i].Calls != deps[j].Calls {
+			return deps[i].Calls > deps[j].Calls
+		}
+		return deps[i].Path < deps[j].Path
+	})
+	return deps, nil
+}
+
+// groupByModule groups files by directory
+func groupByModule(files []*moduleFile) map[string][]*moduleFile {
+	byDir := make(map[string][]*moduleFile)
+	for _, f := range files {
+		dir := path.Dir(f.path)
+		byDir[dir] = append(byDir[dir], f)
+	}
+	return byDir
+}
+
+// moduleInfo sums the metrics of a module's files
+func moduleInfo(dir string, files []*moduleFile, counts map[int64]map[string]int) *ModuleInfo {
+	info := &ModuleInfo{Path: dir, Languages: make([]string, 0)}
+	if dir != "." {
+		info.Name = path.Base(dir)
+	}
+	languages := make(map[string]bool)
+	for _, f := range files {
+		info.Files++
+		info.Lines += f.lines
+		info.Classes += counts[f.fileID][SymbolKindClass]
+		info.Functions += counts[f.fileID][SymbolKindFunction]
+		info.Methods += counts[f.fileID][SymbolKindMethod]
+		if f.language != "" && !languages[f.language] {
+			languages[f.language] = true
+			info.Languages = append(info.Languages, f.language)
+		}
+		// External test packages ("foo_test") share the directory of the package they test
+		if f.language == "go" && f.module != "" && !strings.HasSuffix(f.module, "_test") {
+			info.Name = f.module
+		}
+	}
+	sort.Strings(info.Languages)
+	return info
+}
+
+// isExported reports whether a symbol is part of its module's public surface: capitalized
+// in Go, not underscore- or hash-prefixed in Python and JavaScript. Other languages carry no
+// visibility in the graph and export everything.
+func isExported(name, language string) bool {
+	if name == "" {
+		return false
+	}
+	switch language {
+	case "go":
+		r, _ := utf8.DecodeRuneInString(name)
+		return unicode.IsUpper(r)
+	case "python", "javascript", "typescript":
+		return !strings.HasPrefix(name, "_") && !strings.HasPrefix(name, "#")
+	default:
+		return true
+	}
+}
+
+func fileIDs(files []*moduleFile) []int64 {
+	ids := make([]int64, len(files))
+	for i, f := range files {
+		ids[i] = f.fileID
+	}
+	return ids
+}
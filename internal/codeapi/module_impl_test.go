@@ -0,0 +1,177 @@
+package codeapi
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"bot-go/internal/config"
+	"bot-go/internal/service/codegraph"
+
+	"go.uber.org/zap"
+)
+
+// fixedRecordsDatabase answers every read with the same records
+type fixedRecordsDatabase struct {
+	codegraph.GraphDatabase
+	records []map[string]any
+}
+
+func (d *fixedRecordsDatabase) ExecuteRead(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	return d.records, nil
+}
+
+func TestModuleCalls(t *testing.T) {
+	db := &fixedRecordsDatabase{records: []map[string]any{
+		{"fileId": int64(2), "calls": int64(3), "functions": int64(2)},
+		{"fileId": int64(3), "calls": int64(1), "functions": int64(1)},
+		{"fileId": int64(4), "calls": int64(4), "functions": int64(1)},
+		{"fileId": int64(5), "calls": int64(2), "functions": int64(1)}, // same module
+		{"fileId": int64(9), "calls": int64(7), "functions": int64(3)}, // stale version
+	}}
+	m := newModuleAnalyzerImpl(codegraph.NewCodeGraphWithDatabase(db, &config.Config{}, zap.NewNop()), zap.NewNop())
+	latest := map[int64]string{
+		2: "api/handler.go",
+		3: "api/routes.go",
+		4: "cache/lru.go",
+		5: "store/store.go",
+	}
+
+	deps, err := m.moduleCalls(context.Background(), "store", []int64{5}, latest, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*ModuleDependency{
+		{Path: "api", Calls: 4, Functions: 3},
+		{Path: "cache", Calls: 4, Functions: 1},
+	}
+	if !reflect.DeepEqual(deps, want) {
+		t.Errorf("dependencies = %+v, want %+v", deps, want)
+	}
+}
+
+func TestModuleInfo(t *testing.T) {
+	files := []*moduleFile{
+		{fileID: 1, path: "store/store.go", language: "go", lines: 40, module: "kv"},
+		{fileID: 2, path: "store/store_test.go", language: "go", lines: 20, module: "kv_test"},
+		{fileID: 3, path: "store/gen.py", language: "python", lines: 10},
+		{fileID: 4, path: "api/handler.go", language: "go", lines: 30, module: "api"},
+	}
+	counts := map[int64]map[string]int{
+		1: {SymbolKindClass: 1, SymbolKindFunction: 2, SymbolKindMethod: 3},
+		2: {SymbolKindFunction: 4},
+		3: {SymbolKindFunction: 1},
+		4: {SymbolKindFunction: 5},
+	}
+
+	byDir := groupByModule(files)
+	if len(byDir) != 2 || len(byDir["store"]) != 3 {
+		t.Fatalf("modules = %v, want store and api", byDir)
+	}
+	info := moduleInfo("store", byDir["store"], counts)
+	want := &ModuleInfo{
+		Path:      "store",
+		Name:      "kv",
+		Languages: []string{"go", "python"},
+		Files:     3,
+		Lines:     70,
+		Classes:   1,
+		Functions: 7,
+		Methods:   3,
+	}
+	if !reflect.DeepEqual(info, want) {
+		t.Errorf("module = %+v, want %+v", info, want)
+	}
+
+	root := moduleInfo(".", []*moduleFile{{fileID: 5, path: "setup.py", language: "python"}}, counts)
+	if root.Name != "" || root.Files != 1 {
+		t.Errorf("root module = %+v, want no name", root)
+	}
+}
+
+func TestModuleSummary(t *testing.T) {
+	f := newGraphFixture(t)
+
+	// store/store.go (file 2, replacing file 1) has a class with a method, an exported and an
+	// unexported function, and a closure and a named function nested in New. store/cache.go
+	// adds a function. api/handler.go (file 5, replacing file 4) calls into store, and the
+	// nested function of New calls back into api.
+	f.write(t, `
+		CREATE (:FileScope {id: $f1, fileId: $f1, repo: $repo, path: 'store/store.go', language: 'go', startLine: 0, endLine: 9})
+		       -[:CONTAINS]->(:Function {id: $oldGet, fileId: $f1, name: 'Get', startLine: 2})
+		CREATE (store:FileScope {id: $f2, fileId: $f2, repo: $repo, path: 'store/store.go', language: 'go', startLine: 0, endLine: 39})
+		CREATE (store)-[:CONTAINS]->(:ModuleScope {id: $module, fileId: $f2, name: 'kv'})
+		CREATE (store)-[:CONTAINS]->(:Class {id: $class, fileId: $f2, name: 'Store', startLine: 3})
+		       -[:CONTAINS]->(get:Function {id: $get, fileId: $f2, name: 'Get', startLine: 4})
+		CREATE (store)-[:CONTAINS]->(new:Function {id: $new, fileId: $f2, name: 'New', startLine: 10})
+		CREATE (new)-[:CONTAINS]->(:Function {id: $closure, fileId: $f2, name: '<anonymous>', md_anonymous: true, startLine: 11})
+		CREATE (new)-[:CONTAINS]->(:Function {id: $nested, fileId: $f2, name: 'Nested', startLine: 12})
+		       -[:CONTAINS]->(callBack:FunctionCall {id: $callBack, fileId: $f2, name: 'api.Handle'})
+		CREATE (new)-[:CONTAINS]->(:Class {id: $localClass, fileId: $f2, name: 'Local', startLine: 14})
+		CREATE (store)-[:CONTAINS]->(:Function {id: $open, fileId: $f2, name: 'open', startLine: 20})
+		CREATE (cache:FileScope {id: $f3, fileId: $f3, repo: $repo, path: 'store/cache.go', language: 'go', startLine: 0, endLine: 9})
+		       -[:CONTAINS]->(put:Function {id: $put, fileId: $f3, name: 'Put', startLine: 2})
+		CREATE (:FileScope {id: $f4, fileId: $f4, repo: $repo, path: 'api/handler.go', language: 'go', startLine: 0, endLine: 9})
+		       -[:CONTAINS]->(:Function {id: $oldHandle, fileId: $f4, name: 'Handle', startLine: 2})
+		       -[:CONTAINS]->(:FunctionCall {id: $oldCall, fileId: $f4, name: 'store.Get'})
+		       -[:CALLS_FUNCTION]->(get)
+		CREATE (:FileScope {id: $f5, fileId: $f5, repo: $repo, path: 'api/handler.go', language: 'go', startLine: 0, endLine: 19})
+		       -[:CONTAINS]->(handle:Function {id: $handle, fileId: $f5, name: 'Handle', startLine: 2})
+		CREATE (handle)-[:CONTAINS]->(:FunctionCall {id: $callGet, fileId: $f5, name: 'store.Get'})-[:CALLS_FUNCTION]->(get)
+		CREATE (handle)-[:CONTAINS]->(:FunctionCall {id: $callNew, fileId: $f5, name: 'store.New'})-[:CALLS_FUNCTION]->(new)
+		CREATE (handle)-[:CONTAINS]->(:FunctionCall {id: $callPut, fileId: $f5, name: 'store.Put'})-[:CALLS_FUNCTION]->(put)
+		CREATE (callBack)-[:CALLS_FUNCTION]->(handle)
+	`, map[string]any{
+		"repo": f.repo,
+		"f1":   f.fileID(1), "oldGet": f.id(1, 2),
+		"f2": f.fileID(2), "module": f.id(2, 2), "class": f.id(2, 3), "get": f.id(2, 4), "new": f.id(2, 5),
+		"closure": f.id(2, 6), "nested": f.id(2, 7), "callBack": f.id(2, 8), "localClass": f.id(2, 9), "open": f.id(2, 10),
+		"f3": f.fileID(3), "put": f.id(3, 2),
+		"f4": f.fileID(4), "oldHandle": f.id(4, 2), "oldCall": f.id(4, 3),
+		"f5": f.fileID(5), "handle": f.id(5, 2), "callGet": f.id(5, 3), "callNew": f.id(5, 4), "callPut": f.id(5, 5),
+	})
+	m := newModuleAnalyzerImpl(f.graph, zap.NewNop())
+	ctx := context.Background()
+
+	modules, err := m.ListModules(ctx, f.repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byPath := make(map[string]*ModuleInfo)
+	for _, module := range modules {
+		byPath[module.Path] = module
+	}
+	wantStore := &ModuleInfo{Path: "store", Name: "kv", Languages: []string{"go"}, Files: 2, Lines: 50, Classes: 1, Functions: 3, Methods: 1}
+	if !reflect.DeepEqual(byPath["store"], wantStore) {
+		t.Errorf("store module = %+v, want %+v", byPath["store"], wantStore)
+	}
+	if api := byPath["api"]; api == nil || api.Files != 1 || api.Functions != 1 {
+		t.Errorf("api module = %+v, want the latest handler.go only", api)
+	}
+
+	summary, err := m.GetModuleSummary(ctx, f.repo, "store/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var exported []string
+	for _, symbol := range summary.Exported {
+		exported = append(exported, symbol.Location.FilePath+" "+symbol.Kind+" "+symbol.Name)
+	}
+	wantExported := []string{
+		"store/cache.go function Put",
+		"store/store.go class Store",
+		"store/store.go method Get",
+		"store/store.go function New",
+	}
+	if !reflect.DeepEqual(exported, wantExported) {
+		t.Errorf("exported = %v, want %v", exported, wantExported)
+	}
+	wantDependents := []*ModuleDependency{{Path: "api", Calls: 3, Functions: 1}}
+	if !reflect.DeepEqual(summary.Dependents, wantDependents) {
+		t.Errorf("dependents = %+v, want %+v", summary.Dependents, wantDependents)
+	}
+	wantDependencies := []*ModuleDependency{{Path: "api", Calls: 1, Functions: 1}}
+	if !reflect.DeepEqual(summary.Dependencies, wantDependencies) {
+		t.Errorf("dependencies = %+v, want %+v", summary.Dependencies, wantDependencies)
+	}
+}
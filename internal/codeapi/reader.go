@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"bot-go/internal/model/ast"
+	"bot-go/pkg/lsp/base"
 )
 
 // CodeReader provides repository-scoped access to code entities.
@@ -83,6 +84,29 @@ type RepoReader interface {
 	// GetField returns a field by its ID
 	GetField(ctx context.Context, id ast.NodeID) (*FieldInfo, error)
 
+	// --- Symbol Search ---
+
+	// SearchSymbols finds symbols whose name or docstring matches the filter's query using
+	// the graph's full-text index (substring and fuzzy matching), ordered by relevance
+	SearchSymbols(ctx context.Context, filter SymbolSearchFilter) ([]*SymbolMatch, error)
+
+	// SearchStrings finds indexed string literals (log messages, error strings, URLs) matching
+	// the filter's query, ordered by relevance. Literals are only indexed when the string
+	// literal processor is enabled.
+	SearchStrings(ctx context.Context, filter StringSearchFilter) ([]*StringMatch, error)
+
+	// --- Constant Operations ---
+
+	// FindConstants returns constants and enum members matching the filter, ordered by name,
+	// optionally with the nodes their values flow into
+	FindConstants(ctx context.Context, filter ConstantFilter) ([]*ConstantInfo, error)
+
+	// --- Location Queries ---
+
+	// NodeAtPosition returns the innermost node of a file containing an LSP position
+	// (0-based line, UTF-16 character), optionally restricted to some node types
+	NodeAtPosition(ctx context.Context, path string, pos base.Position, nodeTypes ...ast.NodeType) (*PositionMatch, error)
+
 	// --- Relationship Queries ---
 
 	// GetClassMethods returns all methods belonging to a class
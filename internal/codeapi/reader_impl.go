@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 
+	"bot-go/internal/apperrors"
 	"bot-go/internal/model/ast"
 	"bot-go/internal/service/codegraph"
+	"bot-go/internal/util"
 	"bot-go/pkg/lsp/base"
 
 	"go.uber.org/zap"
@@ -85,8 +87,11 @@ func (r *repoReaderImpl) FindFiles(ctx context.Context, filter FileFilter) ([]*F
 	// Add filter conditions
 	conditions := []string{}
 	if filter.Path != "" {
-		conditions = append(conditions, "f.path = $path")
-		params["path"] = filter.Path
+		// Exact canonical match, falling back to a case-insensitive one for paths
+		// from case-insensitive filesystems
+		conditions = append(conditions, "(f.path = $path OR toLower(f.path) = $pathKey)")
+		params["path"] = util.CanonicalPath(filter.Path)
+		params["pathKey"] = util.PathKey(filter.Path)
 	}
 	if filter.PathLike != "" {
 		conditions = append(conditions, "f.path CONTAINS $pathLike")
@@ -107,7 +112,11 @@ func (r *repoReaderImpl) FindFiles(ctx context.Context, filter FileFilter) ([]*F
 		}
 	}
 
-	query += " RETURN f ORDER BY f.path"
+	if filter.Path != "" {
+		query += " RETURN f ORDER BY f.path = $path DESC, f.path"
+	} else {
+		query += " RETURN f ORDER BY f.path"
+	}
 
 	if filter.Limit > 0 {
 		query += fmt.Sprintf(" LIMIT %d", filter.Limit)
@@ -137,7 +146,7 @@ func (r *repoReaderImpl) GetFile(ctx context.Context, id ast.NodeID) (*FileInfo,
 		return nil, fmt.Errorf("failed to get file: %w", err)
 	}
 	if len(records) == 0 {
-		return nil, fmt.Errorf("file not found: %d", id)
+		return nil, apperrors.NodeNotFound("file", id)
 	}
 
 	files, err := r.recordsToFileInfos(records)
@@ -153,7 +162,7 @@ func (r *repoReaderImpl) GetFileByPath(ctx context.Context, path string) (*FileI
 		return nil, err
 	}
 	if len(files) == 0 {
-		return nil, fmt.Errorf("file not found: %s", path)
+		return nil, apperrors.NodeNotFound("file", path)
 	}
 	return files[0], nil
 }
@@ -161,7 +170,7 @@ func (r *repoReaderImpl) GetFileByPath(ctx context.Context, path string) (*FileI
 func (r *repoReaderImpl) File(path string) FileReader {
 	return &fileReaderImpl{
 		repoName: r.repoName,
-		filePath: path,
+		filePath: util.CanonicalPath(path),
 		fileID:   0, // will be resolved lazily
 		graph:    r.graph,
 		logger:   r.logger,
@@ -231,7 +240,7 @@ func (r *repoReaderImpl) GetClass(ctx context.Context, id ast.NodeID) (*ClassInf
 		return nil, fmt.Errorf("failed to get class: %w", err)
 	}
 	if len(records) == 0 {
-		return nil, fmt.Errorf("class not found: %d", id)
+		return nil, apperrors.NodeNotFound("class", id)
 	}
 
 	classes, err := r.recordsToClassInfos(records, "c")
@@ -274,7 +283,7 @@ func (r *repoReaderImpl) FindClassByName(ctx context.Context, name string) (*Cla
 		return nil, err
 	}
 	if len(classes) == 0 {
-		return nil, fmt.Errorf("class not found: %s", name)
+		return nil, apperrors.NodeNotFound("class", name)
 	}
 	return classes[0], nil
 }
@@ -338,7 +347,7 @@ func (r *repoReaderImpl) GetMethod(ctx context.Context, id ast.NodeID) (*MethodI
 		return nil, fmt.Errorf("failed to get method: %w", err)
 	}
 	if len(records) == 0 {
-		return nil, fmt.Errorf("method not found: %d", id)
+		return nil, apperrors.NodeNotFound("method", id)
 	}
 
 	methods, err := r.recordsToMethodInfos(records, "m")
@@ -359,7 +368,7 @@ func (r *repoReaderImpl) FindMethodByName(ctx context.Context, methodName string
 		return nil, err
 	}
 	if len(methods) == 0 {
-		return nil, fmt.Errorf("method not found: %s", methodName)
+		return nil, apperrors.NodeNotFound("method", methodName)
 	}
 	return methods[0], nil
 }
@@ -406,7 +415,7 @@ func (r *repoReaderImpl) GetField(ctx context.Context, id ast.NodeID) (*FieldInf
 		return nil, fmt.Errorf("failed to get field: %w", err)
 	}
 	if len(records) == 0 {
-		return nil, fmt.Errorf("field not found: %d", id)
+		return nil, apperrors.NodeNotFound("field", id)
 	}
 
 	fields, err := r.recordsToFieldInfos(records, "f")
@@ -416,6 +425,184 @@ func (r *repoReaderImpl) GetField(ctx context.Context, id ast.NodeID) (*FieldInf
 	return fields[0], nil
 }
 
+// --- Symbol Search ---
+
+func (r *repoReaderImpl) SearchSymbols(ctx context.Context, filter SymbolSearchFilter) ([]*SymbolMatch, error) {
+	results, err := r.graph.SearchSymbols(ctx, r.repoName, filter.Query, codegraph.SymbolSearchOptions{
+		Mode:      codegraph.SymbolMatchMode(filter.Mode),
+		NodeTypes: filter.NodeTypes,
+		Limit:     filter.Limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make([]*SymbolMatch, 0, len(results))
+	for _, result := range results {
+		matches = append(matches, &SymbolMatch{
+			ID:       result.Node.ID,
+			Name:     result.Node.Name,
+			NodeType: result.Label,
+			FilePath: result.FilePath,
+			FileID:   result.Node.FileID,
+			Range:    result.Node.Range,
+			Score:    result.Score,
+		})
+	}
+	return matches, nil
+}
+
+func (r *repoReaderImpl) SearchStrings(ctx context.Context, filter StringSearchFilter) ([]*StringMatch, error) {
+	results, err := r.graph.SearchStringLiterals(ctx, r.repoName, filter.Query, codegraph.StringLiteralSearchOptions{
+		MatchAll: filter.MatchAll,
+		Kind:     filter.Kind,
+		Limit:    filter.Limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make([]*StringMatch, 0, len(results))
+	for _, result := range results {
+		matches = append(matches, &StringMatch{
+			Value:        result.Value,
+			Kind:         result.Kind,
+			FilePath:     result.FilePath,
+			FileID:       result.FileID,
+			Range:        result.Range,
+			FunctionID:   result.FunctionID,
+			FunctionName: result.FunctionName,
+			Score:        result.Score,
+		})
+	}
+	return matches, nil
+}
+
+// --- Location Queries ---
+
+func (r *repoReaderImpl) NodeAtPosition(ctx context.Context, path string, pos base.Position, nodeTypes ...ast.NodeType) (*PositionMatch, error) {
+	result, err := r.graph.FindNodeAtPosition(ctx, r.repoName, path, pos.Line, pos.Character, nodeTypes...)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, apperrors.NodeNotFound("node at position", fmt.Sprintf("%s:%d:%d", util.CanonicalPath(path), pos.Line, pos.Character))
+	}
+	return &PositionMatch{
+		ID:       result.Node.ID,
+		Name:     result.Node.Name,
+		NodeType: result.Label,
+		FilePath: result.FilePath,
+		FileID:   result.Node.FileID,
+		Range:    result.Node.Range,
+	}, nil
+}
+
+// --- Constant Operations ---
+
+func (r *repoReaderImpl) FindConstants(ctx context.Context, filter ConstantFilter) ([]*ConstantInfo, error) {
+	query := `
+		MATCH (fs:FileScope {repo: $repo})
+		MATCH (c:Constant)
+		WHERE c.fileId = fs.id
+	`
+	params := map[string]any{"repo": r.repoName}
+
+	if filter.Name != "" {
+		query += " AND c.name = $name"
+		params["name"] = filter.Name
+	}
+	if filter.Value != "" {
+		query += " AND c.md_value = $value"
+		params["value"] = filter.Value
+	}
+	if filter.Enum != "" {
+		query += " AND c.md_enum = $enum"
+		params["enum"] = filter.Enum
+	}
+
+	query += `
+		OPTIONAL MATCH (e:Enum)-[:CONTAINS]->(c)
+		RETURN c, fs.path AS path, e.id AS enumId
+		ORDER BY c.name, path, c.id
+	`
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", filter.Limit)
+	}
+
+	records, err := r.graph.ExecuteRead(ctx, query, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find constants: %w", err)
+	}
+
+	constants := make([]*ConstantInfo, 0, len(records))
+	byID := make(map[ast.NodeID]*ConstantInfo, len(records))
+	for _, record := range records {
+		nodeData, ok := record["c"].(map[string]any)
+		if !ok {
+			continue
+		}
+		constant := &ConstantInfo{
+			ID:       ast.NodeID(toInt64(nodeData["id"])),
+			Name:     toString(nodeData["name"]),
+			Value:    toString(nodeData["md_value"]),
+			Enum:     toString(nodeData["md_enum"]),
+			EnumID:   ast.NodeID(toInt64(record["enumId"])),
+			FilePath: toString(record["path"]),
+			FileID:   int32(toInt64(nodeData["fileId"])),
+			Range:    codegraph.RangeFromProperties(nodeData),
+		}
+		constants = append(constants, constant)
+		byID[constant.ID] = constant
+	}
+
+	if filter.IncludeUses && len(constants) > 0 {
+		if err := r.loadConstantUses(ctx, byID); err != nil {
+			return nil, err
+		}
+	}
+	return constants, nil
+}
+
+// loadConstantUses fills in the nodes the values of constants flow into, with the innermost
+// function containing each one
+func (r *repoReaderImpl) loadConstantUses(ctx context.Context, constants map[ast.NodeID]*ConstantInfo) error {
+	ids := make([]int64, 0, len(constants))
+	for id := range constants {
+		ids = append(ids, int64(id))
+	}
+	query := `
+		MATCH (c:Constant)-[:DATA_FLOW]->(u)
+		WHERE c.id IN $ids
+		OPTIONAL MATCH (ufs:FileScope {id: u.fileId})
+		OPTIONAL MATCH p = (fn:Function)-[:CONTAINS*]->(u)
+		WITH c, u, ufs, fn, p
+		ORDER BY length(p)
+		WITH c, u, ufs, head(collect(fn)) AS fn
+		RETURN c.id AS constantId, u, ufs.path AS path, fn.id AS functionId, fn.name AS functionName
+		ORDER BY path, u.id
+	`
+	records, err := r.graph.ExecuteRead(ctx, query, map[string]any{"ids": ids})
+	if err != nil {
+		return fmt.Errorf("failed to find constant uses: %w", err)
+	}
+	for _, record := range records {
+		constant := constants[ast.NodeID(toInt64(record["constantId"]))]
+		nodeData, ok := record["u"].(map[string]any)
+		if constant == nil || !ok {
+			continue
+		}
+		constant.Uses = append(constant.Uses, &ConstantUse{
+			NodeID:       ast.NodeID(toInt64(nodeData["id"])),
+			FunctionID:   ast.NodeID(toInt64(record["functionId"])),
+			FunctionName: toString(record["functionName"]),
+			FilePath:     toString(record["path"]),
+			Range:        codegraph.RangeFromProperties(nodeData),
+		})
+	}
+	return nil
+}
+
 // --- Relationship Queries ---
 
 func (r *repoReaderImpl) GetClassMethods(ctx context.Context, classID ast.NodeID) ([]*MethodInfo, error) {
@@ -511,10 +698,7 @@ func (r *repoReaderImpl) recordsToClassInfos(records []map[string]any, varName s
 		if path, ok := nodeData["path"].(string); ok {
 			class.FilePath = path
 		}
-		// Parse range if present
-		if rangeStr, ok := nodeData["range"].(string); ok {
-			class.Range = parseRange(rangeStr)
-		}
+		class.Range = codegraph.RangeFromProperties(nodeData)
 
 		classes = append(classes, class)
 	}
@@ -537,9 +721,7 @@ func (r *repoReaderImpl) recordsToMethodInfos(records []map[string]any, varName
 		if path, ok := nodeData["path"].(string); ok {
 			method.FilePath = path
 		}
-		if rangeStr, ok := nodeData["range"].(string); ok {
-			method.Range = parseRange(rangeStr)
-		}
+		method.Range = codegraph.RangeFromProperties(nodeData)
 
 		methods = append(methods, method)
 	}
@@ -561,9 +743,7 @@ func (r *repoReaderImpl) recordsToFieldInfos(records []map[string]any, varName s
 		if typeStr, ok := nodeData["type"].(string); ok {
 			field.Type = typeStr
 		}
-		if rangeStr, ok := nodeData["range"].(string); ok {
-			field.Range = parseRange(rangeStr)
-		}
+		field.Range = codegraph.RangeFromProperties(nodeData)
 
 		fields = append(fields, field)
 	}
@@ -620,7 +800,7 @@ func (f *fileReaderImpl) FindClassByName(ctx context.Context, name string) (*Cla
 		return nil, err
 	}
 	if len(classes) == 0 {
-		return nil, fmt.Errorf("class not found: %s in file %s", name, f.filePath)
+		return nil, apperrors.NodeNotFound("class", name+" in file "+f.filePath)
 	}
 	return classes[0], nil
 }
@@ -658,7 +838,7 @@ func (f *fileReaderImpl) FindMethodByName(ctx context.Context, name string) (*Me
 		return nil, err
 	}
 	if len(methods) == 0 {
-		return nil, fmt.Errorf("method not found: %s in file %s", name, f.filePath)
+		return nil, apperrors.NodeNotFound("method", name+" in file "+f.filePath)
 	}
 	return methods[0], nil
 }
@@ -677,7 +857,7 @@ func (f *fileReaderImpl) FindMethodInClass(ctx context.Context, methodName, clas
 		return nil, err
 	}
 	if len(methods) == 0 {
-		return nil, fmt.Errorf("method not found: %s.%s", className, methodName)
+		return nil, apperrors.NodeNotFound("method", className+"."+methodName)
 	}
 	return methods[0], nil
 }
@@ -729,7 +909,7 @@ func (f *fileReaderImpl) FindFieldByName(ctx context.Context, fieldName, classNa
 		return nil, fmt.Errorf("failed to find field: %w", err)
 	}
 	if len(records) == 0 {
-		return nil, fmt.Errorf("field not found: %s", fieldName)
+		return nil, apperrors.NodeNotFound("field", fieldName)
 	}
 
 	repo := &repoReaderImpl{repoName: f.repoName, graph: f.graph, logger: f.logger}
@@ -747,19 +927,22 @@ func (f *fileReaderImpl) resolveFileID(ctx context.Context) (int32, error) {
 
 	// Resolve file ID from path
 	query := `
-		MATCH (file:FileScope {path: $path, repo: $repo})
+		MATCH (file:FileScope {repo: $repo})
+		WHERE file.path = $path OR toLower(file.path) = $pathKey
 		RETURN file.fileId AS fileId
+		ORDER BY file.path = $path DESC, file.fileId DESC
 		LIMIT 1
 	`
 	records, err := f.graph.ExecuteRead(ctx, query, map[string]any{
-		"path": f.filePath,
-		"repo": f.repoName,
+		"path":    f.filePath,
+		"pathKey": util.PathKey(f.filePath),
+		"repo":    f.repoName,
 	})
 	if err != nil {
 		return 0, fmt.Errorf("failed to resolve file ID: %w", err)
 	}
 	if len(records) == 0 {
-		return 0, fmt.Errorf("file not found: %s", f.filePath)
+		return 0, apperrors.NodeNotFound("file", f.filePath)
 	}
 
 	f.fileID = int32(toInt64(records[0]["fileId"]))
@@ -791,11 +974,3 @@ func toString(v any) string {
 	}
 	return ""
 }
-
-func parseRange(s string) base.Range {
-	var r base.Range
-	fmt.Sscanf(s, "(%d,%d)-(%d,%d)",
-		&r.Start.Line, &r.Start.Character,
-		&r.End.Line, &r.End.Character)
-	return r
-}
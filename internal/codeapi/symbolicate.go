@@ -0,0 +1,160 @@
+package codeapi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"bot-go/internal/apperrors"
+	"bot-go/internal/model/ast"
+	"bot-go/internal/service/codegraph"
+)
+
+const (
+	maxSymbolicatedFrames  = 100
+	defaultFrameCandidates = 5
+)
+
+func (a *graphAnalyzerImpl) SymbolicateStackTrace(ctx context.Context, text string, opts SymbolicateOptions) (*SymbolicatedTrace, error) {
+	frames, message := parseStackTrace(text)
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("%w: no stack frames found in the text", apperrors.ErrInvalidArgument)
+	}
+	if len(frames) > maxSymbolicatedFrames {
+		frames = frames[:maxSymbolicatedFrames]
+	}
+	if opts.MaxCandidates <= 0 {
+		opts.MaxCandidates = defaultFrameCandidates
+	}
+
+	trace := &SymbolicatedTrace{Message: message, Frames: make([]*SymbolicatedFrame, 0, len(frames))}
+	for _, frame := range frames {
+		resolved := &SymbolicatedFrame{StackFrame: frame}
+		trace.Frames = append(trace.Frames, resolved)
+		if frame.File == "" {
+			continue
+		}
+		candidates, err := a.frameLocations(ctx, frame, opts)
+		if err != nil {
+			return nil, err
+		}
+		if len(candidates) == 0 {
+			continue
+		}
+		resolved.Resolved = candidates[0]
+		if len(candidates) > 1 {
+			resolved.Candidates = candidates[1:min(len(candidates), opts.MaxCandidates+1)]
+		}
+		trace.ResolvedFrames++
+	}
+	return trace, nil
+}
+
+// frameLocations returns the locations a frame may refer to in every indexed version of the
+// files whose path ends like the frame's, best first: versions at opts.Commit, then functions
+// containing the line with the frame's name, then the latest versions of the longest paths
+func (a *graphAnalyzerImpl) frameLocations(ctx context.Context, frame StackFrame, opts SymbolicateOptions) ([]*FrameLocation, error) {
+	repos := opts.Repos
+	if repos == nil {
+		repos = []string{}
+	}
+	line := frame.Line - 1
+	name := frameFunctionName(frame.Function)
+	records, err := a.graph.ExecuteRead(ctx, `
+		MATCH (fs:FileScope)
+		WHERE (size($repos) = 0 OR fs.repo IN $repos)
+		  AND ($file = fs.path OR $file ENDS WITH '/' + fs.path OR fs.path ENDS WITH '/' + $file)
+		OPTIONAL MATCH (f:Function)
+		WHERE f.fileId = fs.id
+		  AND ((f.startLine <= $line AND f.endLine >= $line) OR ($name <> '' AND f.name = $name))
+		OPTIONAL MATCH (c:Class)-[:CONTAINS]->(f)
+		RETURN fs.repo AS repo, fs.path AS path, fs.id AS fileId, fs.commit AS commit, f, c.name AS className
+	`, map[string]any{
+		"repos": repos,
+		"file":  strings.TrimPrefix(frame.File, "./"),
+		"line":  line,
+		"name":  name,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve frame %s:%d: %w", frame.File, frame.Line, err)
+	}
+
+	// The best function of each file version: the innermost one containing the line, or
+	// else one with the frame's name (the line moved between versions)
+	byFile := make(map[int32]*FrameLocation)
+	latest := make(map[string]int32)
+	for _, record := range records {
+		fileID := int32(toInt64(record["fileId"]))
+		loc := &FrameLocation{
+			RepoName: toString(record["repo"]),
+			FilePath: toString(record["path"]),
+			FileID:   fileID,
+			Commit:   toString(record["commit"]),
+		}
+		if key := loc.RepoName + "\x00" + loc.FilePath; fileID > latest[key] {
+			latest[key] = fileID
+		}
+		if props, ok := record["f"].(map[string]any); ok {
+			loc.FunctionID = ast.NodeID(toInt64(props["id"]))
+			loc.FunctionName = toString(props["name"])
+			loc.ClassName = toString(record["className"])
+			loc.Range = codegraph.RangeFromProperties(props)
+			loc.ContainsLine = loc.Range.Start.Line <= line && loc.Range.End.Line >= line
+			loc.NameMatch = name != "" && loc.FunctionName == name
+			loc.LastModifiedBy = toString(props["md_last_modified_by"])
+			loc.LastModifiedEmail = toString(props["md_last_modified_email"])
+			loc.LastModifiedAt = toInt64(props["md_last_modified_at"])
+		}
+		if best, ok := byFile[fileID]; ok && !betterFunction(loc, best) {
+			continue
+		}
+		byFile[fileID] = loc
+	}
+
+	locations := make([]*FrameLocation, 0, len(byFile))
+	for _, loc := range byFile {
+		loc.Latest = latest[loc.RepoName+"\x00"+loc.FilePath] == loc.FileID
+		loc.CommitMatch = opts.Commit != "" && loc.Commit != "" && strings.HasPrefix(loc.Commit, opts.Commit)
+		locations = append(locations, loc)
+	}
+	sort.Slice(locations, func(i, j int) bool {
+		li, lj := locations[i], locations[j]
+		if li.CommitMatch != lj.CommitMatch {
+			return li.CommitMatch
+		}
+		if ri, rj := li.rank(), lj.rank(); ri != rj {
+			return ri > rj
+		}
+		if li.Latest != lj.Latest {
+			return li.Latest
+		}
+		if len(li.FilePath) != len(lj.FilePath) {
+			return len(li.FilePath) > len(lj.FilePath)
+		}
+		return li.FileID > lj.FileID
+	})
+	return locations, nil
+}
+
+// rank orders the functions matched in a file version: containing the line and named like
+// the frame, containing the line, named like the frame, no function
+func (l *FrameLocation) rank() int {
+	rank := 0
+	if l.ContainsLine {
+		rank += 2
+	}
+	if l.NameMatch {
+		rank++
+	}
+	return rank
+}
+
+// betterFunction reports whether loc is a better match than best within one file version
+func betterFunction(loc, best *FrameLocation) bool {
+	if loc.rank() != best.rank() {
+		return loc.rank() > best.rank()
+	}
+	// Among functions containing the line the innermost starts last
+	return loc.Range.Start.Line > best.Range.Start.Line
+}
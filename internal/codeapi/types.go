@@ -115,6 +115,63 @@ type FileInfo struct {
 	Functions []*MethodInfo // top-level functions
 }
 
+// SymbolMatch is a symbol found by full-text search over names and docstrings
+type SymbolMatch struct {
+	ID       ast.NodeID
+	Name     string
+	NodeType string // node label, e.g. "Function", "Class"
+	FilePath string
+	FileID   int32
+	Range    base.Range
+	Score    float64
+}
+
+// PositionMatch is the innermost node found at a source position
+type PositionMatch struct {
+	ID       ast.NodeID
+	Name     string
+	NodeType string // node label, e.g. "Function", "Block"
+	FilePath string
+	FileID   int32
+	Range    base.Range
+}
+
+// StringMatch is a string literal found by string search, with the function containing it
+type StringMatch struct {
+	Value        string
+	Kind         string // "error", "log", "url" or "text"
+	FilePath     string
+	FileID       int32
+	Range        base.Range
+	FunctionID   ast.NodeID // 0 at top level
+	FunctionName string
+	Score        float64
+}
+
+// ConstantInfo is a named constant or enum member with the value it is declared with
+type ConstantInfo struct {
+	ID       ast.NodeID
+	Name     string
+	Value    string     // source text of the value; empty when implicit, e.g. a TypeScript enum member
+	Enum     string     // name of the enum the constant is a member of, if any
+	EnumID   ast.NodeID // 0 when the constant is not an enum member
+	FilePath string
+	FileID   int32
+	Range    base.Range
+
+	// Populated when requested
+	Uses []*ConstantUse
+}
+
+// ConstantUse is a node the value of a constant flows into
+type ConstantUse struct {
+	NodeID       ast.NodeID
+	FunctionID   ast.NodeID // enclosing function, 0 at top level
+	FunctionName string
+	FilePath     string
+	Range        base.Range
+}
+
 // -----------------------------------------------------------------------------
 // Filter Types - For querying entities
 // -----------------------------------------------------------------------------
@@ -168,6 +225,35 @@ type FileFilter struct {
 	Offset int
 }
 
+// SymbolSearchFilter specifies a full-text symbol search
+type SymbolSearchFilter struct {
+	Query     string
+	Mode      string         // "exact", "prefix" (default), "substring" or "fuzzy"
+	NodeTypes []ast.NodeType // empty = functions, classes, fields, variables and scopes
+
+	Limit int
+}
+
+// StringSearchFilter specifies a full-text search over indexed string literals
+type StringSearchFilter struct {
+	Query    string
+	MatchAll bool   // require every term; by default the best partial matches come first
+	Kind     string // "error", "log", "url" or "text"; empty = all
+
+	Limit int
+}
+
+// ConstantFilter specifies criteria for finding constants. Value matches the source text
+// of the value exactly, so "3600" finds a magic number wherever it is named.
+type ConstantFilter struct {
+	Name        string
+	Value       string
+	Enum        string
+	IncludeUses bool
+
+	Limit int
+}
+
 // -----------------------------------------------------------------------------
 // Graph Result Types - For traversal queries
 // -----------------------------------------------------------------------------
@@ -180,6 +266,10 @@ type CallGraph struct {
 	Direction Direction
 	MaxDepth  int
 	Truncated bool // true if results were limited
+	// Continuation resumes the traversal where it stopped (node or edge cap, or MaxDepth);
+	// empty when the graph is complete, or when more than maxContinuationNodes nodes were
+	// returned so far. Pass it back in CallGraphOptions.Continuation.
+	Continuation string
 }
 
 // CallNode represents a function in the call graph
@@ -193,11 +283,14 @@ type CallNode struct {
 	Range     base.Range
 }
 
-// CallEdge represents a call relationship
+// CallEdge represents a call relationship, aggregating every call site of the callee in the
+// caller
 type CallEdge struct {
-	CallerID ast.NodeID
-	CalleeID ast.NodeID
-	CallSite *Location // where the call occurs
+	CallerID  ast.NodeID
+	CalleeID  ast.NodeID
+	CallSite  *Location   // where the first call occurs
+	Count     int         // number of call sites
+	CallSites []*Location // every call site, by file then source order
 }
 
 // DependencyGraph represents data dependencies
@@ -262,6 +355,9 @@ type CallGraphOptions struct {
 	IncludeExternal bool         // include calls to external packages
 	IncludeTests    bool         // include test files
 	StopAt          []ast.NodeID // don't traverse past these nodes
+	MaxNodes        int          // stop after adding this many nodes (0 = unlimited)
+	MaxEdges        int          // stop after adding this many edges (0 = unlimited)
+	Continuation    string       // token from a previous page of the same call graph
 }
 
 // DefaultCallGraphOptions returns sensible defaults
@@ -0,0 +1,485 @@
+// Package coldstorage archives the indexes of rarely queried repositories: their code graph
+// and vector collections are exported to the storage backend and removed from Neo4j and
+// Qdrant, so they
+// stop taking memory and disk there, and are imported back when a request needs them.
+// Archives and restores run one at a time in the background; their progress is reported
+// by Status.
+package coldstorage
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"bot-go/internal/apperrors"
+	"bot-go/internal/config"
+	"bot-go/internal/service/codecard"
+	"bot-go/internal/service/codegraph"
+	"bot-go/internal/service/graphembed"
+	"bot-go/internal/service/vector"
+	"bot-go/internal/storage"
+
+	"go.uber.org/zap"
+)
+
+// States of a repository
+const (
+	StateActive        = "active"
+	StateArchiveQueued = "archive_queued"
+	StateArchiving     = "archiving"
+	StateArchived      = "archived"
+	StateRestoreQueued = "restore_queued"
+	StateRestoring     = "restoring"
+)
+
+// Objects of an archive, under keyPrefix + repository name + "/"
+const (
+	keyPrefix          = "cold_storage/"
+	manifestFile       = "manifest.json"
+	graphNodesFile     = "graph_nodes.jsonl.gz"
+	graphRelationsFile = "graph_relations.jsonl.gz"
+	collectionPrefix   = "collection_" // + collection name + ".jsonl.gz"
+)
+
+// ErrBusy is returned when a repository cannot be archived because it is being restored
+var ErrBusy = errors.New("the repository is being restored")
+
+// Manifest describes a complete archive. It is written last, so objects without one are
+// left from an interrupted archive and the repository is still in the hot stores.
+type Manifest struct {
+	Repo        string                              `json:"repo"`
+	ArchivedAt  time.Time                           `json:"archived_at"`
+	Graph       *codegraph.GraphArchive             `json:"graph,omitempty"`
+	Collections map[string]vector.CollectionArchive `json:"collections,omitempty"`
+}
+
+// Status is the cold storage state of a repository
+type Status struct {
+	Repo       string     `json:"repo"`
+	State      string     `json:"state"`
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
+	QueuedAt   *time.Time `json:"queued_at,omitempty"`
+	Position   int        `json:"position,omitempty"` // in the job queue, from 1
+	Nodes      int        `json:"nodes,omitempty"`    // archived graph nodes
+	Chunks     int        `json:"chunks,omitempty"`   // archived vector chunks
+	Error      string     `json:"error,omitempty"`    // of the last failed job
+}
+
+// Archiver moves repositories between the hot stores and the storage backend
+type Archiver struct {
+	store    storage.Store
+	repos    map[string]bool // configured repositories
+	graph    *codegraph.CodeGraph
+	vectorDB vector.VectorDatabase
+	logger   *zap.Logger
+
+	mu     sync.Mutex
+	states map[string]*repoState // repositories that are archived or have a job
+	queue  []string              // repositories with a queued job, in order
+	wake   chan struct{}
+}
+
+type repoState struct {
+	state    string
+	manifest *Manifest // while archived
+	queuedAt time.Time
+	err      string
+}
+
+// NewArchiver returns an archiver keeping archives in store and loads the archives found
+// there. graph and vectorDB may be nil when their store is not used; archives holding data
+// for a missing store cannot be restored.
+func NewArchiver(ctx context.Context, store storage.Store, repos []config.Repository, graph *codegraph.CodeGraph, vectorDB vector.VectorDatabase, logger *zap.Logger) (*Archiver, error) {
+	a := &Archiver{
+		store:    store,
+		repos:    make(map[string]bool, len(repos)),
+		graph:    graph,
+		vectorDB: vectorDB,
+		logger:   logger,
+		states:   make(map[string]*repoState),
+		wake:     make(chan struct{}, 1),
+	}
+	for _, repo := range repos {
+		a.repos[repo.Name] = true
+	}
+	keys, err := store.List(ctx, keyPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cold storage archives: %w", err)
+	}
+	for _, key := range keys {
+		if !strings.HasSuffix(key, "/"+manifestFile) {
+			continue
+		}
+		manifest, err := a.readManifest(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		a.states[manifest.Repo] = &repoState{state: StateArchived, manifest: manifest}
+	}
+	return a, nil
+}
+
+// Start runs the queued jobs until ctx is done
+func (a *Archiver) Start(ctx context.Context) {
+	go func() {
+		for {
+			repo, ok := a.next()
+			if !ok {
+				select {
+				case <-a.wake:
+					continue
+				case <-ctx.Done():
+					return
+				}
+			}
+			a.run(ctx, repo)
+		}
+	}()
+}
+
+// Archive queues the archive of a repository. Archiving an archived repository, or one
+// already queued, does nothing.
+func (a *Archiver) Archive(repo string) (Status, error) {
+	if !a.repos[repo] {
+		return Status{}, fmt.Errorf("%w: %s", apperrors.ErrRepoNotFound, repo)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	s := a.states[repo]
+	switch {
+	case s == nil:
+		a.enqueueLocked(repo, &repoState{state: StateArchiveQueued})
+	case s.state == StateRestoreQueued || s.state == StateRestoring:
+		return a.statusLocked(repo), ErrBusy
+	}
+	return a.statusLocked(repo), nil
+}
+
+// Restore queues the restore of an archived repository and returns its status. Restoring
+// a repository that is not archived, or is already queued, does nothing.
+func (a *Archiver) Restore(repo string) (Status, error) {
+	if !a.repos[repo] {
+		return Status{}, fmt.Errorf("%w: %s", apperrors.ErrRepoNotFound, repo)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if s := a.states[repo]; s != nil && s.state == StateArchived {
+		s.state = StateRestoreQueued
+		a.enqueueLocked(repo, s)
+	}
+	return a.statusLocked(repo), nil
+}
+
+// Status returns the cold storage state of a repository
+func (a *Archiver) Status(repo string) (Status, error) {
+	if !a.repos[repo] {
+		return Status{}, fmt.Errorf("%w: %s", apperrors.ErrRepoNotFound, repo)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.statusLocked(repo), nil
+}
+
+// Archived reports whether the indexes of a repository are in the archive rather than the
+// hot stores, including while they are being restored
+func (a *Archiver) Archived(repo string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	s := a.states[repo]
+	return s != nil && (s.state == StateArchived || s.state == StateRestoreQueued || s.state == StateRestoring)
+}
+
+func (a *Archiver) enqueueLocked(repo string, s *repoState) {
+	s.queuedAt = time.Now().UTC()
+	a.states[repo] = s
+	a.queue = append(a.queue, repo)
+	select {
+	case a.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (a *Archiver) statusLocked(repo string) Status {
+	status := Status{Repo: repo, State: StateActive}
+	s := a.states[repo]
+	if s == nil {
+		return status
+	}
+	status.State, status.Error = s.state, s.err
+	if s.state == StateArchiveQueued || s.state == StateRestoreQueued {
+		queuedAt := s.queuedAt
+		status.QueuedAt = &queuedAt
+		for i, queued := range a.queue {
+			if queued == repo {
+				status.Position = i + 1
+			}
+		}
+	}
+	if m := s.manifest; m != nil {
+		archivedAt := m.ArchivedAt
+		status.ArchivedAt = &archivedAt
+		if m.Graph != nil {
+			status.Nodes = m.Graph.Nodes
+		}
+		for _, c := range m.Collections {
+			status.Chunks += c.Chunks
+		}
+	}
+	return status
+}
+
+// next takes the first queued job and marks it running
+func (a *Archiver) next() (string, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.queue) == 0 {
+		return "", false
+	}
+	repo := a.queue[0]
+	a.queue = a.queue[1:]
+	s := a.states[repo]
+	if s.state == StateArchiveQueued {
+		s.state = StateArchiving
+	} else {
+		s.state = StateRestoring
+	}
+	return repo, true
+}
+
+// run archives or restores a repository and records the outcome
+func (a *Archiver) run(ctx context.Context, repo string) {
+	a.mu.Lock()
+	s := a.states[repo]
+	restoring := s.state == StateRestoring
+	a.mu.Unlock()
+
+	start := time.Now()
+	var manifest *Manifest
+	var err error
+	if restoring {
+		err = a.restore(ctx, repo, s.manifest)
+	} else {
+		manifest, err = a.archive(ctx, repo)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	switch {
+	case restoring && err == nil:
+		delete(a.states, repo)
+	case restoring:
+		// The archive is intact; the next restore drops what this one imported
+		s.state, s.err = StateArchived, err.Error()
+	case manifest != nil:
+		// Complete, even if removing the hot copies failed: a restore replaces them
+		s.state, s.manifest, s.err = StateArchived, manifest, ""
+		if err != nil {
+			s.err = err.Error()
+		}
+	default:
+		// Nothing was removed; the repository is served from the hot stores
+		delete(a.states, repo)
+	}
+	logger := a.logger.With(zap.String("repo", repo), zap.Duration("elapsed", time.Since(start)))
+	if err != nil {
+		logger.Error("Cold storage job failed", zap.Bool("restore", restoring), zap.Error(err))
+	} else {
+		logger.Info("Cold storage job done", zap.Bool("restore", restoring))
+	}
+}
+
+// archive exports the indexes of a repository, writes the manifest and removes the indexes
+// from the hot stores. The manifest is returned once written, with any error removing them.
+func (a *Archiver) archive(ctx context.Context, repo string) (*Manifest, error) {
+	prefix := keyPrefix + repo + "/"
+	if err := storage.DeletePrefix(ctx, a.store, prefix); err != nil {
+		return nil, fmt.Errorf("failed to clear %s: %w", a.store.Location(prefix), err)
+	}
+	manifest := &Manifest{Repo: repo, Collections: make(map[string]vector.CollectionArchive)}
+	if a.graph != nil {
+		err := a.writeObjects(ctx, prefix, []string{graphNodesFile, graphRelationsFile}, func(w []io.Writer) error {
+			var err error
+			manifest.Graph, err = a.graph.ExportRepository(ctx, repo, w[0], w[1])
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if a.vectorDB != nil {
+		for _, name := range collectionNames(repo) {
+			exists, err := a.vectorDB.CollectionExists(ctx, name)
+			if err != nil {
+				return nil, fmt.Errorf("failed to check collection %s: %w", name, err)
+			}
+			if !exists {
+				continue
+			}
+			err = a.writeObjects(ctx, prefix, []string{collectionFile(name)}, func(w []io.Writer) error {
+				archive, err := vector.ExportCollection(ctx, a.vectorDB, name, w[0])
+				if err == nil {
+					manifest.Collections[name] = *archive
+				}
+				return err
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	manifest.ArchivedAt = time.Now().UTC()
+	if err := a.writeManifest(ctx, prefix, manifest); err != nil {
+		return nil, err
+	}
+
+	// The archive is complete; remove the hot copies
+	if a.graph != nil {
+		if err := a.graph.DropRepository(ctx, repo, manifest.Graph.FileIDs); err != nil {
+			return manifest, fmt.Errorf("failed to remove the code graph: %w", err)
+		}
+	}
+	for name := range manifest.Collections {
+		if err := a.vectorDB.DeleteCollection(ctx, name); err != nil {
+			return manifest, fmt.Errorf("failed to delete collection %s: %w", name, err)
+		}
+	}
+	return manifest, nil
+}
+
+// restore imports an archive back into the hot stores, after dropping what an interrupted
+// restore left there, and deletes the archive
+func (a *Archiver) restore(ctx context.Context, repo string, manifest *Manifest) error {
+	prefix := keyPrefix + repo + "/"
+	if manifest.Graph != nil {
+		if a.graph == nil {
+			return fmt.Errorf("the archive holds a code graph but the code graph is not enabled")
+		}
+		if err := a.graph.DropRepository(ctx, repo, manifest.Graph.FileIDs); err != nil {
+			return fmt.Errorf("failed to clear the code graph: %w", err)
+		}
+		err := a.readObjects(ctx, prefix, []string{graphNodesFile, graphRelationsFile}, func(r []io.Reader) error {
+			_, err := a.graph.ImportRepository(ctx, r[0], r[1])
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	names := make([]string, 0, len(manifest.Collections))
+	for name := range manifest.Collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if a.vectorDB == nil {
+			return fmt.Errorf("the archive holds collection %s but the vector store is not enabled", name)
+		}
+		if exists, err := a.vectorDB.CollectionExists(ctx, name); err != nil {
+			return fmt.Errorf("failed to check collection %s: %w", name, err)
+		} else if exists {
+			if err := a.vectorDB.DeleteCollection(ctx, name); err != nil {
+				return fmt.Errorf("failed to clear collection %s: %w", name, err)
+			}
+		}
+		err := a.readObjects(ctx, prefix, []string{collectionFile(name)}, func(r []io.Reader) error {
+			_, err := vector.ImportCollection(ctx, a.vectorDB, name, manifest.Collections[name], r[0])
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	// The manifest goes first, so a failure here leaves leftovers rather than an archive
+	if err := a.store.Delete(ctx, prefix+manifestFile); err != nil {
+		return fmt.Errorf("restored, but failed to delete the archive: %w", err)
+	}
+	if err := storage.DeletePrefix(ctx, a.store, prefix); err != nil {
+		a.logger.Warn("Failed to delete a restored archive", zap.String("repo", repo), zap.Error(err))
+	}
+	return nil
+}
+
+// collectionNames returns the vector collections a repository may have
+func collectionNames(repo string) []string {
+	return []string{repo, codecard.CollectionName(repo), graphembed.CollectionName(repo)}
+}
+
+func collectionFile(name string) string {
+	return collectionPrefix + name + ".jsonl.gz"
+}
+
+// writeObjects stores gzipped objects under prefix with what write writes to them
+func (a *Archiver) writeObjects(ctx context.Context, prefix string, names []string, write func([]io.Writer) error) error {
+	writers := make([]io.Writer, 0, len(names))
+	var put func(i int) error
+	put = func(i int) error {
+		if i == len(names) {
+			return write(writers)
+		}
+		return a.store.Put(ctx, prefix+names[i], func(w io.Writer) error {
+			gz := gzip.NewWriter(w)
+			writers = append(writers, gz)
+			if err := put(i + 1); err != nil {
+				return err
+			}
+			if err := gz.Close(); err != nil {
+				return fmt.Errorf("failed to write %s: %w", names[i], err)
+			}
+			return nil
+		})
+	}
+	return put(0)
+}
+
+// readObjects opens gzipped objects under prefix and passes them to read
+func (a *Archiver) readObjects(ctx context.Context, prefix string, names []string, read func([]io.Reader) error) error {
+	readers := make([]io.Reader, len(names))
+	for i, name := range names {
+		object, err := a.store.Get(ctx, prefix+name)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", name, err)
+		}
+		defer object.Close()
+		gz, err := gzip.NewReader(object)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		readers[i] = gz
+	}
+	return read(readers)
+}
+
+func (a *Archiver) readManifest(ctx context.Context, key string) (*Manifest, error) {
+	object, err := a.store.Get(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", a.store.Location(key), err)
+	}
+	defer object.Close()
+	var manifest Manifest
+	if err := json.NewDecoder(object).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", a.store.Location(key), err)
+	}
+	return &manifest, nil
+}
+
+func (a *Archiver) writeManifest(ctx context.Context, prefix string, manifest *Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = a.store.Put(ctx, prefix+manifestFile, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package coldstorage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"bot-go/internal/config"
+	"bot-go/internal/model"
+	"bot-go/internal/service/vector"
+	"bot-go/internal/storage"
+
+	"go.uber.org/zap"
+)
+
+// memoryVectorDatabase keeps collections in memory, paging ScrollChunks by chunk ID
+type memoryVectorDatabase struct {
+	vector.VectorDatabase
+	collections map[string]map[string]*model.CodeChunk
+}
+
+func (db *memoryVectorDatabase) CreateCollection(ctx context.Context, name string, dim int, distance vector.DistanceMetric) error {
+	db.collections[name] = map[string]*model.CodeChunk{}
+	return nil
+}
+
+func (db *memoryVectorDatabase) DeleteCollection(ctx context.Context, name string) error {
+	delete(db.collections, name)
+	return nil
+}
+
+func (db *memoryVectorDatabase) CollectionExists(ctx context.Context, name string) (bool, error) {
+	_, ok := db.collections[name]
+	return ok, nil
+}
+
+func (db *memoryVectorDatabase) UpsertChunks(ctx context.Context, name string, chunks []*model.CodeChunk) error {
+	for _, chunk := range chunks {
+		db.collections[name][chunk.ID] = chunk
+	}
+	return nil
+}
+
+func (db *memoryVectorDatabase) ScrollChunks(ctx context.Context, name string, offset string, limit int) ([]*model.CodeChunk, string, error) {
+	ids := make([]string, 0, len(db.collections[name]))
+	for id := range db.collections[name] {
+		if id >= offset {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	next := ""
+	if len(ids) > limit {
+		next = ids[limit]
+		ids = ids[:limit]
+	}
+	chunks := make([]*model.CodeChunk, len(ids))
+	for i, id := range ids {
+		chunks[i] = db.collections[name][id]
+	}
+	return chunks, next, nil
+}
+
+// waitFor polls the state of a repository until it is want
+func waitFor(t *testing.T, a *Archiver, repo, want string) Status {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status, err := a.Status(repo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status.State == want {
+			return status
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("state of %s = %+v, want %s", repo, status, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestArchiveAndRestore(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dir := t.TempDir()
+	repos := []config.Repository{{Name: "shop"}, {Name: "blog"}}
+	db := &memoryVectorDatabase{collections: map[string]map[string]*model.CodeChunk{"shop": {}}}
+	for _, id := range []string{"a", "b", "c"} {
+		db.collections["shop"][id] = &model.CodeChunk{ID: id, Content: "func " + id + "() {}", Embedding: []float32{1, 0, 0}}
+	}
+
+	a, err := NewArchiver(ctx, storage.NewLocal(dir), repos, nil, db, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Start(ctx)
+	if _, err := a.Archive("missing"); err == nil {
+		t.Error("expected an error archiving an unknown repository")
+	}
+	if _, err := a.Archive("shop"); err != nil {
+		t.Fatal(err)
+	}
+	status := waitFor(t, a, "shop", StateArchived)
+	if status.Chunks != 3 || status.ArchivedAt == nil {
+		t.Errorf("archived status = %+v", status)
+	}
+	if _, ok := db.collections["shop"]; ok {
+		t.Error("expected the archived collection to be deleted")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "cold_storage", "shop", "manifest.json")); err != nil {
+		t.Errorf("expected a manifest in the store: %v", err)
+	}
+	if !a.Archived("shop") || a.Archived("blog") {
+		t.Error("expected only shop to be archived")
+	}
+
+	// A new archiver finds the archive
+	b, err := NewArchiver(ctx, storage.NewLocal(dir), repos, nil, db, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Start(ctx)
+	if !b.Archived("shop") {
+		t.Fatal("expected shop to be archived after a restart")
+	}
+	if _, err := b.Restore("shop"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, b, "shop", StateActive)
+	if got := len(db.collections["shop"]); got != 3 {
+		t.Errorf("restored %d chunks, want 3", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "cold_storage")); !os.IsNotExist(err) {
+		t.Errorf("expected the restored archive to be deleted, stat error = %v", err)
+	}
+	if chunk := db.collections["shop"]["b"]; chunk == nil || chunk.Content != "func b() {}" || len(chunk.Embedding) != 3 {
+		t.Errorf("restored chunk = %+v", chunk)
+	}
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"bot-go/internal/apperrors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -11,15 +12,61 @@ import (
 
 type SourceConfig struct {
 	Repositories []Repository `yaml:"repositories"`
+	Projects     []Project    `yaml:"projects,omitempty"`
+}
+
+// Project groups repositories that are searched, analyzed and reported on together, e.g.
+// the services and libraries of one product. A repository may belong to several projects.
+type Project struct {
+	Name         string   `yaml:"name"`
+	Description  string   `yaml:"description,omitempty"`
+	Repositories []string `yaml:"repositories"`
+}
+
+// HasRepository reports whether the repository belongs to the project
+func (p *Project) HasRepository(name string) bool {
+	for _, repo := range p.Repositories {
+		if repo == name {
+			return true
+		}
+	}
+	return false
 }
 
 type Repository struct {
-	Name               string `yaml:"name"`
-	Path               string `yaml:"path"`
-	Test               string `yaml:"test,omitempty"`
-	Language           string `yaml:"language"`
-	Disabled           bool   `yaml:"disabled,omitempty"`
-	SkipOtherLanguages bool   `yaml:"skip_other_languages,omitempty"`
+	Name               string         `yaml:"name"`
+	Path               string         `yaml:"path"`
+	Test               string         `yaml:"test,omitempty"`
+	Language           string         `yaml:"language"`
+	Disabled           bool           `yaml:"disabled,omitempty"`
+	SkipOtherLanguages bool           `yaml:"skip_other_languages,omitempty"`
+	FollowSymlinks     bool           `yaml:"follow_symlinks,omitempty"`    // Walk symlinked files/directories (cycles are detected)
+	IncludeSubmodules  bool           `yaml:"include_submodules,omitempty"` // Descend into git submodules; pinned commits are recorded on FileScope
+	MaxFileSizeKB      int            `yaml:"max_file_size_kb,omitempty"`   // Larger files get a FileScope only (0 = 1024, <0 = no limit)
+	MaxFileLines       int            `yaml:"max_file_lines,omitempty"`     // Longer files get a FileScope only (0 = 20000, <0 = no limit)
+	IndexGenerated     bool           `yaml:"index_generated,omitempty"`    // Fully index generated and minified files
+	Scoring            *ScoringConfig `yaml:"scoring,omitempty"`            // Search score adjustments for this repository
+	GitHubRepo         string         `yaml:"github_repo,omitempty"`        // "owner/name" on GitHub, for pull request metadata of commits
+	Library            bool           `yaml:"library,omitempty"`            // Snapshot the public API on every index build (see apisurface)
+	StopChunks         []string       `yaml:"stop_chunks,omitempty"`        // Boilerplate not embedded, replacing chunking.stop_chunks ([none] = embed everything)
+}
+
+// ScoringConfig adjusts the scores of search results from a repository. Adjustments multiply
+// the raw score and compound when several apply.
+type ScoringConfig struct {
+	PathRules           []PathScoreRule `yaml:"path_rules,omitempty"`
+	RecencyBoost        float64         `yaml:"recency_boost,omitempty"`          // Boost for code changed just now, halving every half-life (0 = off)
+	RecencyHalfLifeDays int             `yaml:"recency_half_life_days,omitempty"` // Age at which the recency boost halves (0 = 90)
+	ExactNameBoost      float64         `yaml:"exact_name_boost,omitempty"`       // Boost when a result's name is a symbol name of the query (0 = off)
+}
+
+// PathScoreRule scales the score of results whose file matches. Pattern is a glob over the
+// repository-relative path where "**" spans directories; Class matches built-in file classes
+// ("test" or "generated") instead.
+type PathScoreRule struct {
+	Pattern string  `yaml:"pattern,omitempty"`
+	Class   string  `yaml:"class,omitempty"`
+	Weight  float64 `yaml:"weight"` // >1 boosts, <1 penalizes
 }
 
 type App struct {
@@ -31,23 +78,162 @@ type App struct {
 	GCThreshold                 int64  `yaml:"gc_threshold,omitempty"`
 	NumFileThreads              int    `yaml:"num_file_threads,omitempty"`
 	MaxConcurrentFileProcessing int    `yaml:"max_concurrent_file_processing,omitempty"`
+	MaxConcurrentProcessors     int    `yaml:"max_concurrent_processors,omitempty"` // Shared limit across HTTP and CLI builds
+	MemoryBudgetMB              int    `yaml:"memory_budget_mb,omitempty"`          // Heap budget for index builds (0 = unlimited)
+	SessionTTLMinutes           int    `yaml:"session_ttl_minutes,omitempty"`       // Idle time before an agent session expires (0 = 30)
+	// Optional subsystems (vector_search, ngram, lsp, mysql) whose failure aborts startup;
+	// the server starts in degraded mode without the others
+	RequiredServices []string `yaml:"required_services,omitempty"`
+}
+
+// AdminConfig guards the diagnostic endpoints: /debug/pprof and /api/v1/admin/dump are only
+// served with EnableProfiling, and /api/v1/admin/script with EnableScripting, and then only
+// to requests carrying Token as a bearer token (or, without a token, to requests from the
+// local machine)
+type AdminConfig struct {
+	EnableProfiling      bool   `yaml:"enable_profiling"`
+	EnableScripting      bool   `yaml:"enable_scripting,omitempty"`
+	Token                string `yaml:"token"`
+	ScriptTimeoutSeconds int    `yaml:"script_timeout_seconds,omitempty"` // Wall-clock limit of an analysis script (0 = 30)
+	ScriptMaxCalls       int    `yaml:"script_max_calls,omitempty"`       // codeapi calls a script may make (0 = 200)
+}
+
+// EnrichmentConfig lets external tools add relations between existing code graph nodes, e.g.
+// COVERS edges from coverage data. With Enabled, /api/v1/enrichment is served to
+// requests carrying Token as a bearer token (or, without a token, to requests from the local
+// machine). Only the relation types listed in Relations can be created.
+type EnrichmentConfig struct {
+	Enabled   bool                 `yaml:"enabled"`
+	Token     string               `yaml:"token"`
+	Relations []EnrichmentRelation `yaml:"relations"`
+}
+
+// EnrichmentRelation declares a relation type external tools may create. It is registered in
+// the schema registry at startup.
+type EnrichmentRelation struct {
+	Type        string   `yaml:"type"` // UPPER_SNAKE_CASE, e.g. COVERS
+	Description string   `yaml:"description"`
+	From        []string `yaml:"from,omitempty"` // labels of source nodes, e.g. [Function]; empty allows any
+	To          []string `yaml:"to,omitempty"`   // labels of target nodes; empty allows any
+}
+
+// APIConfig identifies the clients of the HTTP API (/api/v1 and /codeapi/v1) by API key, sent
+// as "Authorization: Bearer <key>" or "X-API-Key: <key>", and filters the responses of each
+// key's scope with the rules in Redaction. Requests without a key get DefaultScope, or are
+// refused with RequireKey.
+type APIConfig struct {
+	RequireKey   bool                      `yaml:"require_key,omitempty"`
+	DefaultScope string                    `yaml:"default_scope,omitempty"` // Scope of requests without a key (empty = no redaction)
+	Keys         []APIKey                  `yaml:"keys,omitempty"`
+	Redaction    map[string]RedactionRules `yaml:"redaction,omitempty"`    // Rules by scope name
+	QuotaPeriod  string                    `yaml:"quota_period,omitempty"` // "day" or "month" (UTC); default day
+}
+
+// Enabled reports whether API requests are authenticated, which also accounts their usage
+func (a *APIConfig) Enabled() bool {
+	return len(a.Keys) > 0 || a.RequireKey || a.DefaultScope != ""
+}
+
+// APIKey is a key clients of the HTTP API authenticate with
+type APIKey struct {
+	Name  string     `yaml:"name"`            // Names the client in logs and usage reports
+	Key   string     `yaml:"key"`             // The secret; may reference a secret store
+	Scope string     `yaml:"scope,omitempty"` // Redaction scope (empty = no redaction)
+	Quota UsageQuota `yaml:"quota,omitempty"` // Usage allowed per quota period
+}
+
+// UsageQuota bounds the usage of an API key per quota period; 0 is unlimited. A request
+// started within the quota runs to completion, so usage may end up above it.
+type UsageQuota struct {
+	EmbeddingTokens  int64 `yaml:"embedding_tokens,omitempty"`   // Estimated tokens of the texts embedded
+	QueryTimeSeconds int64 `yaml:"query_time_seconds,omitempty"` // Time spent in graph database queries
+	FilesIndexed     int64 `yaml:"files_indexed,omitempty"`
+}
+
+// RedactionRules hide parts of the JSON responses sent to a scope, for clients that are only
+// partially trusted
+type RedactionRules struct {
+	HideAbsolutePaths bool     `yaml:"hide_absolute_paths,omitempty"` // Make paths under repository roots and the workdir relative; hide other absolute paths
+	MaskDirectories   []string `yaml:"mask_directories,omitempty"`    // Repository-relative directories whose paths and source are masked
+	DropMetadataKeys  []string `yaml:"drop_metadata_keys,omitempty"`  // Keys removed from every object, with or without the md_ prefix; "*" matches any characters
+}
+
+// ColdStorageConfig enables archiving rarely queried repositories: their code graph and
+// vector collections are exported to the storage backend under cold_storage/ and removed
+// from Neo4j and Qdrant, then restored when a request targets them
+type ColdStorageConfig struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+// ClusterConfig coordinates server instances that share the same backends
+type ClusterConfig struct {
+	Locking              string `yaml:"locking,omitempty"`                 // local (default; this process only) or mysql (GET_LOCK, shared by every instance using the database)
+	BuildLockWaitSeconds int    `yaml:"build_lock_wait_seconds,omitempty"` // how long a build waits for another build of the repository (0 = fails at once)
+	LeaderCheckSeconds   int    `yaml:"leader_check_seconds,omitempty"`    // how often standby instances try to take over the scheduled jobs (0 = 15)
+}
+
+// CacheConfig configures the Redis cache shared by server instances: MCP tool results,
+// sessions, embeddings and usage counters. Without an address every instance keeps its own
+// in memory.
+type CacheConfig struct {
+	RedisAddress      string `yaml:"redis_address,omitempty"`  // host:port
+	RedisUsername     string `yaml:"redis_username,omitempty"` // ACL user (Redis 6+); empty authenticates with the password only
+	RedisPassword     string `yaml:"redis_password,omitempty"`
+	RedisDB           int    `yaml:"redis_db,omitempty"`
+	RedisTLS          bool   `yaml:"redis_tls,omitempty"`
+	KeyPrefix         string `yaml:"key_prefix,omitempty"`          // prepended to every key (default "bot-go:")
+	TimeoutSeconds    int    `yaml:"timeout_seconds,omitempty"`     // bound on each command (0 = 2)
+	EmbeddingTTLHours int    `yaml:"embedding_ttl_hours,omitempty"` // how long embeddings are cached (0 = 168; < 0 disables the embedding cache)
+}
+
+// Enabled reports whether a Redis cache is configured
+func (c CacheConfig) Enabled() bool {
+	return c.RedisAddress != ""
+}
+
+// StorageConfig selects where graph dumps, profile dumps and cold storage archives are
+// written: a local directory, an S3 bucket or a GCS bucket
+type StorageConfig struct {
+	Backend         string `yaml:"backend,omitempty"`          // local (default), s3 or gcs
+	Dir             string `yaml:"dir,omitempty"`              // local: default <app.workdir>
+	Bucket          string `yaml:"bucket,omitempty"`           // s3 and gcs
+	Prefix          string `yaml:"prefix,omitempty"`           // s3 and gcs: prepended to every key, e.g. "prod/"
+	Region          string `yaml:"region,omitempty"`           // s3: default $AWS_REGION, then $AWS_DEFAULT_REGION
+	Endpoint        string `yaml:"endpoint,omitempty"`         // s3 and gcs endpoint override, e.g. MinIO (s3 requests become path-style)
+	CredentialsFile string `yaml:"credentials_file,omitempty"` // gcs service account key; default $GOOGLE_APPLICATION_CREDENTIALS, then the metadata server
 }
 
 type McpConfig struct {
-	Host string `yaml:"host"`
-	Port int    `yaml:"port"`
+	Host            string `yaml:"host"`
+	Port            int    `yaml:"port"`
+	CacheTTLSeconds int    `yaml:"cache_ttl_seconds,omitempty"` // Tool results are reused within a session this long (0 = 30, <0 = no caching)
+	CacheMaxEntries int    `yaml:"cache_max_entries,omitempty"` // Cached results across all sessions (0 = 1000)
 }
 
 type Neo4jConfig struct {
 	URI      string `yaml:"uri"`
 	Username string `yaml:"username"`
 	Password string `yaml:"password"`
+
+	// ReadURIs are read replicas (same credentials) that take reads off the leader at URI.
+	// Not needed with a neo4j:// URI, where the driver routes reads within the cluster.
+	ReadURIs           []string `yaml:"read_uris,omitempty"`
+	HealthCheckSeconds int      `yaml:"health_check_seconds,omitempty"` // Replica probe interval (0 = 30)
 }
 
 type QdrantConfig struct {
-	Host   string `yaml:"host"`
-	Port   int    `yaml:"port"`
-	APIKey string `yaml:"apikey"`
+	Host      string          `yaml:"host"`
+	Port      int             `yaml:"port"`
+	APIKey    string          `yaml:"apikey"`
+	Reduction ReductionConfig `yaml:"reduction,omitempty"` // Used by --reduce-embeddings
+}
+
+// ReductionConfig controls the offline job that rebuilds a collection with fewer dimensions
+// per vector (see --reduce-embeddings)
+type ReductionConfig struct {
+	Method     string `yaml:"method,omitempty"`      // pca (default) or truncate, for Matryoshka-trained models
+	Dimension  int    `yaml:"dimension,omitempty"`   // Reduced dimension, below ollama.dimension
+	SampleSize int    `yaml:"sample_size,omitempty"` // Vectors PCA is fitted on (0 = 20000)
 }
 
 type OllamaConfig struct {
@@ -57,9 +243,24 @@ type OllamaConfig struct {
 	Dimension int    `yaml:"dimension"`
 }
 
+// WarmupConfig controls the startup phase that preloads models and opens connections before
+// the server accepts requests
+type WarmupConfig struct {
+	Enabled        bool     `yaml:"enabled"`
+	Repositories   []string `yaml:"repositories,omitempty"`    // Active repositories to warm (empty = all enabled)
+	LSP            bool     `yaml:"lsp,omitempty"`             // Start language servers for the active repositories
+	TimeoutSeconds int      `yaml:"timeout_seconds,omitempty"` // Bound on the whole phase (0 = 120)
+}
+
 type ChunkingConfig struct {
 	MinConditionalLines int `yaml:"min_conditional_lines"`
 	MinLoopLines        int `yaml:"min_loop_lines"`
+	TTLHours            int `yaml:"ttl_hours,omitempty"`         // Chunks expire this long after indexing (0 = never)
+	TTLSweepMinutes     int `yaml:"ttl_sweep_minutes,omitempty"` // Interval of the expired chunk sweep (0 = 60)
+
+	// StopChunks are the kinds of boilerplate not embedded: getters, setters, constructors
+	// and license_headers. Repositories can set their own.
+	StopChunks []string `yaml:"stop_chunks,omitempty"`
 }
 
 type BloomFilterConfig struct {
@@ -73,6 +274,22 @@ type IndexBuildingConfig struct {
 	EnableCodeGraph  bool `yaml:"enable_code_graph"`
 	EnableEmbeddings bool `yaml:"enable_embeddings"`
 	EnableNgram      bool `yaml:"enable_ngram"`
+	// EnableTextSearch builds the trigram index of exact and regular expression search
+	EnableTextSearch bool `yaml:"enable_text_search,omitempty"`
+	// Deterministic writes --test-dump text dumps with IDs derived from repository and path
+	// in place of FileIDs, so two builds of the same tree produce byte-identical dumps
+	Deterministic bool `yaml:"deterministic,omitempty"`
+	// Source archives: /api/v1/buildIndex/archive indexes uploads of up to MaxArchiveMB
+	// (default 512), and archive_path requests only name archives inside ArchiveDir
+	ArchiveDir   string `yaml:"archive_dir,omitempty"`
+	MaxArchiveMB int    `yaml:"max_archive_mb,omitempty"`
+	// Journal records the operations of every build in <workdir>/journals/<repo>.jsonl,
+	// so stores restored from a backup can be caught up with --replay-journal
+	Journal bool `yaml:"journal,omitempty"`
+	// CodeCards summarizes every function as a code card (signature, doc comment, callers,
+	// callees, complexity) after the code graph is built. Cards are stored in
+	// <workdir>/codecards/<repo>.json and, with vector search, embedded into <repo>_cards.
+	CodeCards bool `yaml:"code_cards,omitempty"`
 }
 
 type MySQLConfig struct {
@@ -87,6 +304,41 @@ type CodeGraphConfig struct {
 	EnableBatchWrites bool `yaml:"enable_batch_writes"`
 	BatchSize         int  `yaml:"batch_size"` // Number of nodes/relations to batch before writing
 	PrintParseTree    bool `yaml:"print_parse_tree"`
+	// CrossFileBatching merges what is left in finished files' buffers into shared batches of
+	// batch_size, instead of one small write per file. Requires enable_batch_writes.
+	CrossFileBatching bool `yaml:"cross_file_batching"`
+	// AutoTuneBatchSize adjusts the batch size between MinBatchSize (default 10) and
+	// MaxBatchSize (default 5000) from measured write throughput, starting at BatchSize
+	AutoTuneBatchSize bool `yaml:"auto_tune_batch_size"`
+	MinBatchSize      int  `yaml:"min_batch_size"`
+	MaxBatchSize      int  `yaml:"max_batch_size"`
+	// IndexStringLiterals stores log messages, error strings and URLs as StringLiteral nodes
+	// linked to their functions during index builds. Literals shorter than
+	// StringLiteralMinLength characters (default 8) are skipped; URLs are always kept.
+	IndexStringLiterals    bool `yaml:"index_string_literals"`
+	StringLiteralMinLength int  `yaml:"string_literal_min_length"`
+	// Languages tunes how each language is translated into the graph, by language name
+	// (go, python, javascript, typescript, java, rust). Languages left out keep the defaults.
+	Languages map[string]LanguageOptions `yaml:"languages,omitempty"`
+}
+
+// Lambda modes of LanguageOptions.Lambdas
+const (
+	LambdasAsFunctions   = "function"
+	LambdasAsExpressions = "expression"
+)
+
+// LanguageOptions sets the granularity of the graph built from files of one language
+type LanguageOptions struct {
+	SkipTestFiles   bool   `yaml:"skip_test_files,omitempty"`   // leave test files (foo_test.go, test_foo.py, tests/...) out of the graph
+	SkipTypeOnly    bool   `yaml:"skip_type_only,omitempty"`    // leave out constructs without runtime behavior, e.g. Go interfaces and Python protocols
+	MaxNestingDepth int    `yaml:"max_nesting_depth,omitempty"` // conditionals and loops modeled per function, counting nested ones (0 = unlimited); deeper ones are flattened into their parent
+	Lambdas         string `yaml:"lambdas,omitempty"`           // function (default): closures and lambdas are Function nodes; expression: their bodies belong to the enclosing function
+}
+
+// Language returns the translation options of a language
+func (c CodeGraphConfig) Language(name string) LanguageOptions {
+	return c.Languages[name]
 }
 
 // GitAnalysisMode defines how git analysis is performed
@@ -99,8 +351,58 @@ const (
 
 type GitAnalysisConfig struct {
 	Enabled         bool            `yaml:"enabled"`
-	Mode            GitAnalysisMode `yaml:"mode"`              // "ondemand" or "precompute"
-	LookbackCommits int             `yaml:"lookback_commits"`  // How many commits to analyze (default: 1000)
+	Mode            GitAnalysisMode `yaml:"mode"`             // "ondemand" or "precompute"
+	LookbackCommits int             `yaml:"lookback_commits"` // How many commits to analyze (default: 1000)
+	Blame           bool            `yaml:"blame"`            // Store last-modified author and time on Function/Class nodes during index builds
+	Commits         bool            `yaml:"commits"`          // Create Commit nodes linked to the FileScope/Function/Class nodes they changed
+	GitHubToken     string          `yaml:"github_token"`     // Token for reading pull request titles of repositories with github_repo set
+}
+
+// LoggingConfig controls log encoding, sinks, rotation and per-module levels
+type LoggingConfig struct {
+	Level     string             `yaml:"level"`    // default level: debug, info, warn, error (default: info)
+	Encoding  string             `yaml:"encoding"` // "json" or "console" (default: json)
+	Outputs   []string           `yaml:"outputs"`  // "stdout", "stderr" or file paths (default: stdout, all.log)
+	Modules   map[string]string  `yaml:"modules"`  // module name (parse, codegraph, vector, handler, ...) -> level
+	Rotation  LogRotationConfig  `yaml:"rotation"`
+	Sampling  *LogSamplingConfig `yaml:"sampling,omitempty"`
+	SlowQuery SlowQueryConfig    `yaml:"slow_query"`
+	QueryLog  QueryLogConfig     `yaml:"query_log"`
+}
+
+// SlowQueryConfig enables the slow query log. Neo4j queries, vector searches and embedding
+// calls slower than ThresholdMs are logged with their sanitized parameters, as are HTTP
+// requests over the latency budget of their endpoint. Counts and durations per operation
+// are served by /api/v1/metrics.
+type SlowQueryConfig struct {
+	Enabled          bool           `yaml:"enabled"`
+	ThresholdMs      int            `yaml:"threshold_ms"`       // default 500
+	EndpointBudgetMs int            `yaml:"endpoint_budget_ms"` // budget of endpoints not listed below (0 = none)
+	EndpointBudgets  map[string]int `yaml:"endpoint_budgets"`   // route ("POST /codeapi/v1/impact") -> budget in ms
+}
+
+// QueryLogConfig enables the query history: the queries of search and graph endpoints are
+// stored in MySQL with their latency and result counts, without client addresses or request
+// IDs, and summarized by /api/v1/analytics/queries. Requires MySQL.
+type QueryLogConfig struct {
+	Enabled       bool `yaml:"enabled"`
+	RetentionDays int  `yaml:"retention_days"` // entries older than this are deleted (default 30)
+	HashQueries   bool `yaml:"hash_queries"`   // store a SHA-256 of each query instead of its text
+}
+
+// LogRotationConfig configures rotation of file outputs (disabled when MaxSizeMB is 0)
+type LogRotationConfig struct {
+	MaxSizeMB  int  `yaml:"max_size_mb"`
+	MaxBackups int  `yaml:"max_backups"`
+	MaxAgeDays int  `yaml:"max_age_days"`
+	Compress   bool `yaml:"compress"`
+}
+
+// LogSamplingConfig limits repeated messages: per second, the first Initial entries with the
+// same level and message are logged, then every Thereafter-th
+type LogSamplingConfig struct {
+	Initial    int `yaml:"initial"`
+	Thereafter int `yaml:"thereafter"`
 }
 
 func (c *McpConfig) GetAddress() string {
@@ -120,7 +422,17 @@ type Config struct {
 	MySQL         MySQLConfig         `yaml:"mysql"`
 	CodeGraph     CodeGraphConfig     `yaml:"code_graph"`
 	GitAnalysis   GitAnalysisConfig   `yaml:"git_analysis"`
+	Logging       LoggingConfig       `yaml:"logging"`
+	Warmup        WarmupConfig        `yaml:"warmup"`
 	App           App                 `yaml:"app"`
+	Admin         AdminConfig         `yaml:"admin"`
+	Enrichment    EnrichmentConfig    `yaml:"enrichment"`
+	API           APIConfig           `yaml:"api"`
+	ColdStorage   ColdStorageConfig   `yaml:"cold_storage"`
+	Storage       StorageConfig       `yaml:"storage"`
+	Cluster       ClusterConfig       `yaml:"cluster"`
+	Cache         CacheConfig         `yaml:"cache"`
+	Secrets       SecretsConfig       `yaml:"secrets"`
 }
 
 // expandEnvVars expands environment variables in the given string
@@ -162,16 +474,21 @@ func expandEnvVars(s string) string {
 }
 
 func LoadConfig(appConfigPath string, sourceConfigPath string) (*Config, error) {
-	if _, err := os.Stat(appConfigPath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("app config file does not exist: %s", appConfigPath)
-	}
+	return LoadLayeredConfig(LoadOptions{AppConfigPath: appConfigPath, SourceConfigPath: sourceConfigPath})
+}
+
+// LoadLayeredConfig loads the app config layered as described by LoadOptions, then the
+// source config
+func LoadLayeredConfig(opts LoadOptions) (*Config, error) {
+	sourceConfigPath := opts.SourceConfigPath
 	if _, err := os.Stat(sourceConfigPath); os.IsNotExist(err) {
 		return nil, fmt.Errorf("source config file does not exist: %s", sourceConfigPath)
 	}
 
-	dataApp, err := ioutil.ReadFile(appConfigPath)
+	// Environment variables are expanded in each app config layer
+	dataApp, err := appConfigLayers(opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read app config file: %w", err)
+		return nil, err
 	}
 
 	dataSource, err := ioutil.ReadFile(sourceConfigPath)
@@ -179,8 +496,7 @@ func LoadConfig(appConfigPath string, sourceConfigPath string) (*Config, error)
 		return nil, fmt.Errorf("failed to read source config file: %w", err)
 	}
 
-	// Expand environment variables in both config files
-	dataApp = []byte(expandEnvVars(string(dataApp)))
+	// Expand environment variables in the source config
 	dataSource = []byte(expandEnvVars(string(dataSource)))
 
 	var configApp Config
@@ -198,7 +514,7 @@ func LoadConfig(appConfigPath string, sourceConfigPath string) (*Config, error)
 
 	// Validate repository configurations
 	if err := validateRepositories(&configApp); err != nil {
-		return nil, fmt.Errorf("invalid repository configuration: %w", err)
+		return nil, err
 	}
 
 	if configSource.Mcp.Host != "" {
@@ -217,6 +533,11 @@ func LoadConfig(appConfigPath string, sourceConfigPath string) (*Config, error)
 		configApp.Ollama = configSource.Ollama
 	}
 
+	// Replace references to Vault, AWS Secrets Manager or secret files in credential fields
+	if err := resolveSecrets(&configApp); err != nil {
+		return nil, err
+	}
+
 	return &configApp, nil
 }
 
@@ -226,16 +547,49 @@ func (c *Config) GetRepository(name string) (*Repository, error) {
 			return &repo, nil
 		}
 	}
-	return nil, fmt.Errorf("repository not found: %s", name)
+	return nil, fmt.Errorf("%w: %s", apperrors.ErrRepoNotFound, name)
+}
+
+// GetProject returns the project configuration by name
+func (c *Config) GetProject(name string) (*Project, error) {
+	for _, project := range c.Source.Projects {
+		if project.Name == name {
+			return &project, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", apperrors.ErrProjectNotFound, name)
 }
 
 // validateRepositories validates repository configurations
 func validateRepositories(config *Config) error {
+	if problems := repositoryProblems(config); len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// repositoryProblems returns the problems in repository settings that need no file system
+// access
+func repositoryProblems(config *Config) []string {
+	var problems []string
 	for _, repo := range config.Source.Repositories {
 		// If skip_other_languages is true, language must be specified
 		if repo.SkipOtherLanguages && repo.Language == "" {
-			return fmt.Errorf("repository '%s': skip_other_languages is true but language is not specified", repo.Name)
+			problems = append(problems, fmt.Sprintf("repository '%s': skip_other_languages is true but language is not specified", repo.Name))
+		}
+		if repo.Scoring != nil {
+			for i, rule := range repo.Scoring.PathRules {
+				if (rule.Pattern == "") == (rule.Class == "") {
+					problems = append(problems, fmt.Sprintf("repository '%s': scoring path rule %d needs exactly one of pattern or class", repo.Name, i))
+				}
+				if rule.Class != "" && rule.Class != "test" && rule.Class != "generated" {
+					problems = append(problems, fmt.Sprintf("repository '%s': unknown scoring class %q (valid: test, generated)", repo.Name, rule.Class))
+				}
+				if rule.Weight < 0 {
+					problems = append(problems, fmt.Sprintf("repository '%s': scoring path rule %d has a negative weight", repo.Name, i))
+				}
+			}
 		}
 	}
-	return nil
+	return problems
 }
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// EnvOverridePrefix starts the environment variables that override single app config keys,
+// with "__" between the keys of the path: BOT_GO__NEO4J__URI sets neo4j.uri and
+// BOT_GO__APP__NUM_FILE_THREADS sets app.num_file_threads
+const EnvOverridePrefix = "BOT_GO__"
+
+// LoadOptions selects the layers of a configuration. From lowest to highest precedence the
+// app config is made of: AppConfigPath, the overlay of Environment, Overlays in order, and
+// the BOT_GO__ environment variables. The source config is layered on top as before.
+type LoadOptions struct {
+	AppConfigPath    string
+	SourceConfigPath string
+	Environment      string   // e.g. "prod" overlays app.prod.yaml from next to AppConfigPath
+	Overlays         []string // further app config files, each overriding the ones before
+}
+
+// EnvironmentOverlayPath returns the overlay of an environment for an app config path:
+// config/app.yaml and "prod" give config/app.prod.yaml
+func EnvironmentOverlayPath(appConfigPath, environment string) string {
+	ext := filepath.Ext(appConfigPath)
+	return strings.TrimSuffix(appConfigPath, ext) + "." + environment + ext
+}
+
+// appConfigLayers reads the app config with its overlays and environment overrides merged,
+// as YAML. Overlays are partial: mappings merge key by key, while scalars and lists replace
+// the value below them and null removes it.
+func appConfigLayers(opts LoadOptions) ([]byte, error) {
+	paths := []string{opts.AppConfigPath}
+	if opts.Environment != "" {
+		paths = append(paths, EnvironmentOverlayPath(opts.AppConfigPath, opts.Environment))
+	}
+	paths = append(paths, opts.Overlays...)
+
+	merged := map[interface{}]interface{}{}
+	for i, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				if i == 0 {
+					return nil, fmt.Errorf("app config file does not exist: %s", path)
+				}
+				return nil, fmt.Errorf("app config overlay does not exist: %s", path)
+			}
+			return nil, fmt.Errorf("failed to read app config file %s: %w", path, err)
+		}
+		var layer map[interface{}]interface{}
+		if err := yaml.Unmarshal([]byte(expandEnvVars(string(data))), &layer); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal app config %s: %w", path, err)
+		}
+		merged = mergeLayer(merged, layer)
+	}
+	if err := applyEnvOverrides(merged, os.Environ()); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(merged)
+}
+
+// mergeLayer merges overlay into base and returns base
+func mergeLayer(base, overlay map[interface{}]interface{}) map[interface{}]interface{} {
+	for key, value := range overlay {
+		if value == nil {
+			delete(base, key)
+			continue
+		}
+		baseMap, baseIsMap := base[key].(map[interface{}]interface{})
+		overlayMap, overlayIsMap := value.(map[interface{}]interface{})
+		if baseIsMap && overlayIsMap {
+			base[key] = mergeLayer(baseMap, overlayMap)
+			continue
+		}
+		base[key] = value
+	}
+	return base
+}
+
+// applyEnvOverrides sets the keys named by BOT_GO__ variables in environ
+func applyEnvOverrides(config map[interface{}]interface{}, environ []string) error {
+	// Sorted so that overrides apply in a fixed order
+	sort.Strings(environ)
+	for _, entry := range environ {
+		name, raw, ok := strings.Cut(entry, "=")
+		if !ok || !strings.HasPrefix(name, EnvOverridePrefix) {
+			continue
+		}
+		path := strings.Split(strings.ToLower(strings.TrimPrefix(name, EnvOverridePrefix)), "__")
+		for _, key := range path {
+			if key == "" {
+				return fmt.Errorf("invalid config override %s: empty key", name)
+			}
+		}
+		node := config
+		for _, key := range path[:len(path)-1] {
+			child, ok := node[key].(map[interface{}]interface{})
+			if !ok {
+				child = map[interface{}]interface{}{}
+				node[key] = child
+			}
+			node = child
+		}
+		node[path[len(path)-1]] = overrideValue(raw)
+	}
+	return nil
+}
+
+// overrideValue types the value of an environment override. Numbers, booleans and lists are
+// parsed as YAML; anything else stays the literal string, so a password containing "#" or
+// ": " is not cut short by YAML comment or mapping syntax.
+func overrideValue(raw string) interface{} {
+	var value interface{}
+	if err := yaml.Unmarshal([]byte(raw), &value); err != nil {
+		return raw
+	}
+	// Only canonical spellings are typed: "007" and "yes" stay strings rather than 7 and true
+	switch value.(type) {
+	case bool, int, int64, uint64, float64:
+		if fmt.Sprint(value) == raw {
+			return value
+		}
+	case []interface{}:
+		if strings.HasPrefix(raw, "[") {
+			return value
+		}
+	}
+	return raw
+}
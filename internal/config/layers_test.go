@@ -0,0 +1,88 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadLayeredConfig(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	appPath := write("app.yaml", `
+app:
+  port: 8181
+  codegraph: true
+  num_file_threads: 5
+neo4j:
+  uri: "bolt://localhost:7687"
+  username: "neo4j"
+  password: "neo4j"
+logging:
+  level: "info"
+  modules:
+    parse: "debug"
+`)
+	write("app.prod.yaml", `
+app:
+  codegraph: false
+neo4j:
+  uri: "bolt://neo4j.prod:7687"
+logging:
+  modules: null
+`)
+	overlay := write("local.yaml", `
+app:
+  num_file_threads: 2
+`)
+	sourcePath := write("source.yaml", "source:\n  repositories: []\n")
+	t.Setenv("BOT_GO__NEO4J__PASSWORD", "007#pass")
+	t.Setenv("BOT_GO__APP__PORT", "9090")
+
+	cfg, err := LoadLayeredConfig(LoadOptions{
+		AppConfigPath:    appPath,
+		SourceConfigPath: sourcePath,
+		Environment:      "prod",
+		Overlays:         []string{overlay},
+	})
+	if err != nil {
+		t.Fatalf("LoadLayeredConfig() error = %v", err)
+	}
+
+	if cfg.App.Port != 9090 {
+		t.Errorf("app.port = %d, want 9090 from the environment", cfg.App.Port)
+	}
+	if cfg.App.CodeGraph {
+		t.Errorf("app.codegraph = true, want false from the prod overlay")
+	}
+	if cfg.App.NumFileThreads != 2 {
+		t.Errorf("app.num_file_threads = %d, want 2 from the overlay", cfg.App.NumFileThreads)
+	}
+	if cfg.Neo4j.URI != "bolt://neo4j.prod:7687" || cfg.Neo4j.Username != "neo4j" {
+		t.Errorf("neo4j = %+v, want the prod URI merged with the base username", cfg.Neo4j)
+	}
+	if cfg.Neo4j.Password != "007#pass" {
+		t.Errorf("neo4j.password = %q, want the literal environment value", cfg.Neo4j.Password)
+	}
+	if cfg.Logging.Level != "info" || cfg.Logging.Modules != nil {
+		t.Errorf("logging = %+v, want the base level with modules removed", cfg.Logging)
+	}
+}
+
+func TestLoadLayeredConfigMissingOverlay(t *testing.T) {
+	dir := t.TempDir()
+	appPath := filepath.Join(dir, "app.yaml")
+	sourcePath := filepath.Join(dir, "source.yaml")
+	os.WriteFile(appPath, []byte("app:\n  port: 8181\n"), 0o644)
+	os.WriteFile(sourcePath, []byte("source: {}\n"), 0o644)
+
+	if _, err := LoadLayeredConfig(LoadOptions{AppConfigPath: appPath, SourceConfigPath: sourcePath, Environment: "staging"}); err == nil {
+		t.Error("LoadLayeredConfig() should fail when the environment overlay does not exist")
+	}
+}
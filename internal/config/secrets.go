@@ -0,0 +1,260 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"bot-go/internal/awsauth"
+)
+
+// Secret reference schemes. A credential field holding "${scheme:name#key}" is replaced by
+// the secret when the configuration loads; #key picks a field of a JSON secret.
+const (
+	SecretSchemeVault = "vault"  // ${vault:secret/data/bot-go#neo4j_password}, KV v1 or v2
+	SecretSchemeAWS   = "aws-sm" // ${aws-sm:prod/bot-go#password}, AWS Secrets Manager
+	SecretSchemeFile  = "file"   // ${file:/run/secrets/neo4j_password}, Docker and Kubernetes secrets
+)
+
+const (
+	defaultSecretsTimeout = 10 * time.Second
+	redactedSecret        = "[redacted]"
+)
+
+var secretRefPattern = regexp.MustCompile(`^\$\{(vault|aws-sm|file):([^}#]+)(?:#([^}]+))?\}$`)
+
+// SecretsConfig configures the secret stores credential fields may reference. Each setting
+// falls back to the environment variable the store's own tools use.
+type SecretsConfig struct {
+	VaultAddress   string `yaml:"vault_address,omitempty"`   // default $VAULT_ADDR
+	VaultToken     string `yaml:"vault_token,omitempty"`     // default $VAULT_TOKEN
+	VaultNamespace string `yaml:"vault_namespace,omitempty"` // default $VAULT_NAMESPACE (Vault Enterprise)
+	AWSRegion      string `yaml:"aws_region,omitempty"`      // default $AWS_REGION, then $AWS_DEFAULT_REGION
+	AWSEndpoint    string `yaml:"aws_endpoint,omitempty"`    // Secrets Manager endpoint override, e.g. for LocalStack
+	TimeoutSeconds int    `yaml:"timeout_seconds,omitempty"` // Bound on resolving all secrets (0 = 10)
+}
+
+// secretField is a configuration field that may hold a credential
+type secretField struct {
+	name  string
+	value *string
+}
+
+// secretFields lists the credential fields: they may reference secret stores and are
+// redacted from logs
+func (c *Config) secretFields() []secretField {
+	fields := []secretField{
+		{"neo4j.username", &c.Neo4j.Username},
+		{"neo4j.password", &c.Neo4j.Password},
+		{"mysql.username", &c.MySQL.Username},
+		{"mysql.password", &c.MySQL.Password},
+		{"qdrant.apikey", &c.Qdrant.APIKey},
+		{"ollama.apikey", &c.Ollama.APIKey},
+		{"git_analysis.github_token", &c.GitAnalysis.GitHubToken},
+		{"admin.token", &c.Admin.Token},
+		{"enrichment.token", &c.Enrichment.Token},
+		{"cache.redis_password", &c.Cache.RedisPassword},
+	}
+	for i := range c.API.Keys {
+		fields = append(fields, secretField{fmt.Sprintf("api.keys[%d].key", i), &c.API.Keys[i].Key})
+	}
+	return fields
+}
+
+// Redacted returns a copy of the configuration with its credentials blanked, for logging
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	redacted.Secrets.VaultToken = ""
+	redacted.API.Keys = append([]APIKey(nil), c.API.Keys...) // not shared with c
+	for _, field := range redacted.secretFields() {
+		if *field.value != "" {
+			*field.value = redactedSecret
+		}
+	}
+	return &redacted
+}
+
+// resolveSecrets replaces the secret references in credential fields with the secrets.
+// Documents are fetched once even when several fields pick keys of the same secret.
+func resolveSecrets(c *Config) error {
+	r := &secretResolver{
+		cfg:    c.Secrets,
+		client: &http.Client{},
+		docs:   make(map[string]string),
+	}
+	timeout := time.Duration(c.Secrets.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = defaultSecretsTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	for _, field := range c.secretFields() {
+		m := secretRefPattern.FindStringSubmatch(*field.value)
+		if m == nil {
+			continue
+		}
+		secret, err := r.resolve(ctx, m[1], m[2], m[3])
+		if err != nil {
+			// The error names the reference, never the secret
+			return fmt.Errorf("failed to resolve %s from %s:%s: %w", field.name, m[1], m[2], err)
+		}
+		*field.value = secret
+	}
+	return nil
+}
+
+type secretResolver struct {
+	cfg    SecretsConfig
+	client *http.Client
+	docs   map[string]string // scheme:name -> secret document
+}
+
+func (r *secretResolver) resolve(ctx context.Context, scheme, name, key string) (string, error) {
+	cacheKey := scheme + ":" + name
+	doc, ok := r.docs[cacheKey]
+	if !ok {
+		var err error
+		switch scheme {
+		case SecretSchemeVault:
+			doc, err = r.fetchVault(ctx, name)
+		case SecretSchemeAWS:
+			doc, err = r.fetchAWS(ctx, name)
+		case SecretSchemeFile:
+			var data []byte
+			data, err = os.ReadFile(name)
+			doc = strings.TrimRight(string(data), "\r\n")
+		}
+		if err != nil {
+			return "", err
+		}
+		r.docs[cacheKey] = doc
+	}
+	if key == "" {
+		if scheme == SecretSchemeVault {
+			return "", fmt.Errorf("vault secrets hold several keys; pick one with #key")
+		}
+		return doc, nil
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(doc), &fields); err != nil {
+		return "", fmt.Errorf("secret is not a JSON object, cannot pick key %q", key)
+	}
+	value, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("secret has no key %q", key)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(value), nil
+}
+
+// fetchVault reads a secret through the Vault HTTP API and returns its data as a JSON object.
+// KV v2 paths include "data/" (secret/data/app) and nest the fields one level deeper.
+func (r *secretResolver) fetchVault(ctx context.Context, path string) (string, error) {
+	addr := firstNonEmpty(r.cfg.VaultAddress, os.Getenv("VAULT_ADDR"))
+	token := firstNonEmpty(r.cfg.VaultToken, os.Getenv("VAULT_TOKEN"))
+	if addr == "" || token == "" {
+		return "", fmt.Errorf("vault address and token are not set (secrets.vault_address/vault_token or VAULT_ADDR/VAULT_TOKEN)")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(addr, "/")+"/v1/"+strings.TrimLeft(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if ns := firstNonEmpty(r.cfg.VaultNamespace, os.Getenv("VAULT_NAMESPACE")); ns != "" {
+		req.Header.Set("X-Vault-Namespace", ns)
+	}
+	body, err := r.do(req)
+	if err != nil {
+		return "", err
+	}
+
+	var resp struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", fmt.Errorf("unexpected vault response: %w", err)
+	}
+	// KV v2 wraps the fields with their metadata
+	if inner, ok := resp.Data["data"]; ok && resp.Data["metadata"] != nil {
+		return string(inner), nil
+	}
+	data, err := json.Marshal(resp.Data)
+	return string(data), err
+}
+
+// fetchAWS calls GetSecretValue of AWS Secrets Manager, signed with the credentials in
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN
+func (r *secretResolver) fetchAWS(ctx context.Context, secretID string) (string, error) {
+	region := firstNonEmpty(r.cfg.AWSRegion, os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"))
+	creds := awsauth.CredentialsFromEnv()
+	if region == "" {
+		return "", fmt.Errorf("AWS region is not set (secrets.aws_region or AWS_REGION)")
+	}
+	if !creds.Valid() {
+		return "", fmt.Errorf("AWS credentials are not set (AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY)")
+	}
+	endpoint := firstNonEmpty(r.cfg.AWSEndpoint, "https://secretsmanager."+region+".amazonaws.com")
+
+	payload, _ := json.Marshal(map[string]string{"SecretId": secretID})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(endpoint, "/")+"/", bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	awsauth.Sign(req, awsauth.PayloadHash(payload), creds, region, "secretsmanager", time.Now())
+	body, err := r.do(req)
+	if err != nil {
+		return "", err
+	}
+
+	var resp struct {
+		SecretString string `json:"SecretString"`
+		SecretBinary string `json:"SecretBinary"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", fmt.Errorf("unexpected Secrets Manager response: %w", err)
+	}
+	if resp.SecretString != "" || resp.SecretBinary == "" {
+		return resp.SecretString, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(resp.SecretBinary)
+	return string(data), err
+}
+
+func (r *secretResolver) do(req *http.Request) ([]byte, error) {
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", req.URL.Host, resp.Status)
+	}
+	return body, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
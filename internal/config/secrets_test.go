@@ -0,0 +1,89 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveSecrets(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "vault-token" || r.URL.Path != "/v1/secret/data/bot-go" {
+			http.Error(w, "denied", http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"data":{"data":{"neo4j_user":"graph","neo4j_password":"s3cret"},"metadata":{"version":3}}}`))
+	}))
+	defer vault.Close()
+
+	aws := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" ||
+			!strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			http.Error(w, "denied", http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"SecretString":"{\"password\":\"db-pass\"}"}`))
+	}))
+	defer aws.Close()
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	keyFile := filepath.Join(t.TempDir(), "apikey")
+	if err := os.WriteFile(keyFile, []byte("file-key\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &Config{
+		Neo4j:  Neo4jConfig{Username: "${vault:secret/data/bot-go#neo4j_user}", Password: "${vault:secret/data/bot-go#neo4j_password}"},
+		MySQL:  MySQLConfig{Username: "root", Password: "${aws-sm:prod/bot-go#password}"},
+		Ollama: OllamaConfig{APIKey: "${file:" + keyFile + "}"},
+		Secrets: SecretsConfig{
+			VaultAddress: vault.URL,
+			VaultToken:   "vault-token",
+			AWSRegion:    "us-east-1",
+			AWSEndpoint:  aws.URL,
+		},
+	}
+	if err := resolveSecrets(cfg); err != nil {
+		t.Fatalf("resolveSecrets() error = %v", err)
+	}
+	got := map[string]string{
+		"neo4j.username": cfg.Neo4j.Username,
+		"neo4j.password": cfg.Neo4j.Password,
+		"mysql.username": cfg.MySQL.Username,
+		"mysql.password": cfg.MySQL.Password,
+		"ollama.apikey":  cfg.Ollama.APIKey,
+	}
+	want := map[string]string{
+		"neo4j.username": "graph",
+		"neo4j.password": "s3cret",
+		"mysql.username": "root",
+		"mysql.password": "db-pass",
+		"ollama.apikey":  "file-key",
+	}
+	for field, w := range want {
+		if got[field] != w {
+			t.Errorf("%s = %q, want %q", field, got[field], w)
+		}
+	}
+
+	redacted := cfg.Redacted()
+	if redacted.Neo4j.Password != redactedSecret || redacted.Secrets.VaultToken != "" || cfg.Neo4j.Password != "s3cret" {
+		t.Errorf("Redacted() should blank credentials of a copy only")
+	}
+}
+
+func TestResolveSecretsMissingKey(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "creds.json")
+	if err := os.WriteFile(keyFile, []byte(`{"user":"u"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{Neo4j: Neo4jConfig{Password: "${file:" + keyFile + "#password}"}}
+	err := resolveSecrets(cfg)
+	if err == nil || !strings.Contains(err.Error(), "neo4j.password") {
+		t.Fatalf("resolveSecrets() error = %v, want an error naming neo4j.password", err)
+	}
+}
@@ -0,0 +1,418 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ValidateOptions carries the command line options the configuration is checked against
+type ValidateOptions struct {
+	// HeadMode is set by --head: the repositories to index must be git repositories
+	HeadMode bool
+	// Repositories are the repositories named by --build-index (empty = all enabled)
+	Repositories []string
+	// Archive is set by --archive: the single repository to index is extracted from it, so
+	// it need not be configured or checked out
+	Archive string
+}
+
+// ValidationError lists every problem found in a configuration
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Problems) == 1 {
+		return "invalid configuration: " + e.Problems[0]
+	}
+	return fmt.Sprintf("invalid configuration (%d problems):\n  - %s", len(e.Problems), strings.Join(e.Problems, "\n  - "))
+}
+
+// Names accepted by app.required_services; see controller.OptionalServices
+var requiredServiceNames = []string{"vector_search", "ngram", "lsp", "mysql", "text_search", "cache"}
+
+// Languages accepted by code_graph.languages; see parse.LanguageType
+var graphLanguages = []string{"go", "javascript", "typescript", "python", "java", "rust"}
+
+// Languages whose visitor builds graph nodes and so honors skip_type_only,
+// max_nesting_depth and lambdas; see parse.GetLanguageVisitor
+var translatedLanguages = []string{"go", "python", "rust"}
+
+// Kinds accepted by chunking.stop_chunks and repository stop_chunks; see chunk.StopChunkKinds
+var stopChunkKinds = []string{"getters", "setters", "constructors", "license_headers"}
+
+var logLevels = []string{"debug", "info", "warn", "error", "dpanic", "panic", "fatal"}
+
+// Validate checks the whole configuration and returns a *ValidationError listing every
+// problem at once, or nil. Repository paths must exist on disk. Unlike LoadConfig it runs
+// once at startup, where each problem would otherwise surface later and one at a time.
+func (c *Config) Validate(opts ValidateOptions) error {
+	v := &validator{}
+
+	v.port("app.port", c.App.Port, true)
+	v.port("mcp.port", c.Mcp.Port, false)
+	if c.App.Port != 0 && c.App.Port == c.Mcp.Port {
+		v.addf("app.port and mcp.port are both %d; give the MCP server its own port", c.App.Port)
+	}
+	if c.App.NumFileThreads < 0 || c.App.MaxConcurrentFileProcessing < 0 || c.App.MaxConcurrentProcessors < 0 {
+		v.addf("app.num_file_threads, app.max_concurrent_file_processing and app.max_concurrent_processors cannot be negative; use 0 for the default")
+	}
+	for _, name := range c.App.RequiredServices {
+		if !contains(requiredServiceNames, name) {
+			v.addf("app.required_services: unknown service %q (valid: %s)", name, strings.Join(requiredServiceNames, ", "))
+		}
+	}
+	if contains(c.App.RequiredServices, "mysql") && c.MySQL.Host == "" {
+		v.addf("app.required_services lists mysql but mysql.host is not set")
+	}
+	if contains(c.App.RequiredServices, "cache") && !c.Cache.Enabled() {
+		v.addf("app.required_services lists cache but cache.redis_address is not set")
+	}
+
+	// Backends
+	if (c.App.CodeGraph || c.IndexBuilding.EnableCodeGraph) && c.Neo4j.URI == "" {
+		v.addf("neo4j.uri is required when app.codegraph or index_building.enable_code_graph is set, e.g. bolt://localhost:7687")
+	}
+	if (c.Qdrant.Host == "") != (c.Ollama.URL == "") {
+		v.addf("vector search needs both qdrant.host and ollama.url; set both, or neither to disable it")
+	}
+	if c.IndexBuilding.EnableEmbeddings && (c.Qdrant.Host == "" || c.Ollama.URL == "") {
+		v.addf("index_building.enable_embeddings needs qdrant.host and ollama.url")
+	}
+	if c.Qdrant.Host != "" {
+		v.port("qdrant.port", c.Qdrant.Port, true)
+	}
+	if c.Ollama.URL != "" {
+		if c.Ollama.Model == "" {
+			v.addf("ollama.model is required with ollama.url, e.g. qwen3-embedding:0.6b")
+		}
+		if c.Ollama.Dimension <= 0 {
+			v.addf("ollama.dimension must be the positive output dimension of ollama.model, e.g. 1024")
+		}
+	}
+	if r := c.Qdrant.Reduction; r != (ReductionConfig{}) {
+		if r.Method != "" && r.Method != "pca" && r.Method != "truncate" {
+			v.addf("qdrant.reduction.method: unknown method %q (valid: pca, truncate)", r.Method)
+		}
+		if r.Dimension <= 0 || (c.Ollama.Dimension > 0 && r.Dimension >= c.Ollama.Dimension) {
+			v.addf("qdrant.reduction.dimension must be positive and below ollama.dimension (%d), e.g. 256", c.Ollama.Dimension)
+		}
+		if r.SampleSize < 0 {
+			v.addf("qdrant.reduction.sample_size must not be negative")
+		}
+	}
+	if c.MySQL.Host != "" {
+		v.port("mysql.port", c.MySQL.Port, false)
+		if c.MySQL.Database == "" {
+			v.addf("mysql.database is required with mysql.host")
+		}
+	}
+	if c.BloomFilter.Enabled {
+		if c.BloomFilter.StorageDir == "" {
+			v.addf("bloom_filter.storage_dir is required when bloom_filter.enabled is set")
+		}
+		if c.BloomFilter.FalsePositiveRate <= 0 || c.BloomFilter.FalsePositiveRate >= 1 {
+			v.addf("bloom_filter.false_positive_rate must be between 0 and 1, e.g. 0.01")
+		}
+	}
+
+	// Options that depend on each other
+	if c.CodeGraph.CrossFileBatching && !c.CodeGraph.EnableBatchWrites {
+		v.addf("code_graph.cross_file_batching requires code_graph.enable_batch_writes")
+	}
+	if c.CodeGraph.MinBatchSize > 0 && c.CodeGraph.MaxBatchSize > 0 && c.CodeGraph.MinBatchSize > c.CodeGraph.MaxBatchSize {
+		v.addf("code_graph.min_batch_size (%d) is larger than code_graph.max_batch_size (%d)", c.CodeGraph.MinBatchSize, c.CodeGraph.MaxBatchSize)
+	}
+	if c.GitAnalysis.Enabled && c.GitAnalysis.Mode != "" &&
+		c.GitAnalysis.Mode != GitAnalysisModeOnDemand && c.GitAnalysis.Mode != GitAnalysisModePrecompute {
+		v.addf("git_analysis.mode %q is unknown (valid: %s, %s)", c.GitAnalysis.Mode, GitAnalysisModeOnDemand, GitAnalysisModePrecompute)
+	}
+
+	// Graph translation per language
+	languageNames := make([]string, 0, len(c.CodeGraph.Languages))
+	for name := range c.CodeGraph.Languages {
+		languageNames = append(languageNames, name)
+	}
+	sort.Strings(languageNames)
+	for _, name := range languageNames {
+		opts := c.CodeGraph.Languages[name]
+		if !contains(graphLanguages, name) {
+			v.addf("code_graph.languages: unknown language %q (valid: %s)", name, strings.Join(graphLanguages, ", "))
+		}
+		if opts.MaxNestingDepth < 0 {
+			v.addf("code_graph.languages.%s.max_nesting_depth cannot be negative; use 0 for unlimited", name)
+		}
+		switch opts.Lambdas {
+		case "", LambdasAsFunctions, LambdasAsExpressions:
+		default:
+			v.addf("code_graph.languages.%s.lambdas %q is unknown (valid: %s, %s)", name, opts.Lambdas, LambdasAsFunctions, LambdasAsExpressions)
+		}
+		if contains(graphLanguages, name) && !contains(translatedLanguages, name) &&
+			(opts.SkipTypeOnly || opts.MaxNestingDepth != 0 || opts.Lambdas != "") {
+			v.addf("code_graph.languages.%s: skip_type_only, max_nesting_depth and lambdas are only supported for %s", name, strings.Join(translatedLanguages, ", "))
+		}
+	}
+
+	v.stopChunks("chunking.stop_chunks", c.Chunking.StopChunks)
+
+	// Logging
+	v.logLevel("logging.level", c.Logging.Level)
+	modules := make([]string, 0, len(c.Logging.Modules))
+	for module := range c.Logging.Modules {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+	for _, module := range modules {
+		v.logLevel("logging.modules."+module, c.Logging.Modules[module])
+	}
+	if c.Logging.Encoding != "" && c.Logging.Encoding != "json" && c.Logging.Encoding != "console" {
+		v.addf("logging.encoding %q is unknown (valid: json, console)", c.Logging.Encoding)
+	}
+	if c.Logging.SlowQuery.ThresholdMs < 0 || c.Logging.SlowQuery.EndpointBudgetMs < 0 {
+		v.addf("logging.slow_query thresholds cannot be negative")
+	}
+	if c.Logging.QueryLog.RetentionDays < 0 {
+		v.addf("logging.query_log.retention_days cannot be negative")
+	}
+
+	// Enrichment
+	if c.Enrichment.Enabled {
+		if !c.App.CodeGraph {
+			v.addf("enrichment.enabled requires app.codegraph")
+		}
+		if len(c.Enrichment.Relations) == 0 {
+			v.addf("enrichment.enabled needs at least one relation type in enrichment.relations")
+		}
+	}
+	relationTypes := make(map[string]bool, len(c.Enrichment.Relations))
+	for i, r := range c.Enrichment.Relations {
+		if !upperSnakeCase(r.Type) {
+			v.addf("enrichment.relations[%d].type %q must be UPPER_SNAKE_CASE, e.g. COVERS", i, r.Type)
+		} else if relationTypes[r.Type] {
+			v.addf("enrichment.relations: %s is listed more than once", r.Type)
+		}
+		relationTypes[r.Type] = true
+	}
+
+	// API keys
+	v.apiScope("api.default_scope", c.API.DefaultScope, c.API.Redaction)
+	keyNames := make(map[string]bool, len(c.API.Keys))
+	keys := make(map[string]bool, len(c.API.Keys))
+	for i, key := range c.API.Keys {
+		switch {
+		case key.Name == "":
+			v.addf("api.keys[%d] has no name", i)
+		case keyNames[key.Name]:
+			v.addf("api.keys: %s is listed more than once", key.Name)
+		}
+		keyNames[key.Name] = true
+		if key.Key == "" {
+			v.addf("api.keys[%d].key is empty; an unset environment variable?", i)
+		} else if keys[key.Key] {
+			v.addf("api.keys[%d].key is the key of another entry", i)
+		}
+		keys[key.Key] = true
+		v.apiScope(fmt.Sprintf("api.keys[%d].scope", i), key.Scope, c.API.Redaction)
+		if q := key.Quota; q.EmbeddingTokens < 0 || q.QueryTimeSeconds < 0 || q.FilesIndexed < 0 {
+			v.addf("api.keys[%d].quota cannot be negative; use 0 for no limit", i)
+		}
+	}
+	if c.API.QuotaPeriod != "" && c.API.QuotaPeriod != "day" && c.API.QuotaPeriod != "month" {
+		v.addf("api.quota_period %q is unknown (valid: day, month)", c.API.QuotaPeriod)
+	}
+	if c.API.RequireKey && len(c.API.Keys) == 0 {
+		v.addf("api.require_key is set but api.keys is empty; no request would be accepted")
+	}
+	for scope, rules := range c.API.Redaction {
+		for _, dir := range rules.MaskDirectories {
+			if dir == "" || filepath.IsAbs(dir) || strings.HasPrefix(filepath.ToSlash(filepath.Clean(dir)), "..") {
+				v.addf("api.redaction.%s.mask_directories: %q must be a directory relative to the repository roots", scope, dir)
+			}
+		}
+	}
+
+	// Storage backend
+	switch c.Storage.Backend {
+	case "", "local":
+	case "s3", "gcs":
+		if c.Storage.Bucket == "" {
+			v.addf("storage.bucket is required for the %s backend", c.Storage.Backend)
+		}
+	default:
+		v.addf("storage.backend %q is unknown (valid: local, s3, gcs)", c.Storage.Backend)
+	}
+
+	// Cluster coordination
+	switch c.Cluster.Locking {
+	case "", "local":
+	case "mysql":
+		if c.MySQL.Host == "" {
+			v.addf("cluster.locking is mysql but mysql.host is not set")
+		}
+	default:
+		v.addf("cluster.locking %q is unknown (valid: local, mysql)", c.Cluster.Locking)
+	}
+	if c.Cluster.BuildLockWaitSeconds < 0 || c.Cluster.LeaderCheckSeconds < 0 {
+		v.addf("cluster.build_lock_wait_seconds and cluster.leader_check_seconds cannot be negative")
+	}
+
+	// Shared cache
+	if c.Cache.RedisDB < 0 || c.Cache.TimeoutSeconds < 0 {
+		v.addf("cache.redis_db and cache.timeout_seconds cannot be negative")
+	}
+	if !c.Cache.Enabled() && (c.Cache.RedisPassword != "" || c.Cache.RedisTLS) {
+		v.addf("cache.redis_password or cache.redis_tls is set but cache.redis_address is not")
+	}
+
+	// Repositories
+	for _, problem := range repositoryProblems(c) {
+		v.addf("%s", problem)
+	}
+	names := make(map[string]bool, len(c.Source.Repositories))
+	for _, repo := range c.Source.Repositories {
+		if repo.Name == "" {
+			v.addf("a repository with path %q has no name", repo.Path)
+			continue
+		}
+		if names[repo.Name] {
+			v.addf("repository '%s' is listed more than once", repo.Name)
+		}
+		names[repo.Name] = true
+		v.stopChunks(fmt.Sprintf("repository '%s': stop_chunks", repo.Name), repo.StopChunks)
+		if repo.Disabled || (opts.Archive != "" && contains(opts.Repositories, repo.Name)) {
+			continue // an archive replaces the checkout
+		}
+		if repo.Path == "" {
+			v.addf("repository '%s': path is required", repo.Name)
+			continue
+		}
+		if info, err := os.Stat(repo.Path); err != nil {
+			v.addf("repository '%s': path %s does not exist; clone it there or fix the path", repo.Name, repo.Path)
+			continue
+		} else if !info.IsDir() {
+			v.addf("repository '%s': path %s is not a directory", repo.Name, repo.Path)
+			continue
+		}
+		if opts.HeadMode && (len(opts.Repositories) == 0 || contains(opts.Repositories, repo.Name)) {
+			// .git is a directory, or a file in worktrees and submodules
+			if _, err := os.Stat(filepath.Join(repo.Path, ".git")); err != nil {
+				v.addf("repository '%s': --head reads files from git HEAD but %s is not a git repository; drop --head to index the working directory", repo.Name, repo.Path)
+			}
+		}
+	}
+	if opts.Archive != "" {
+		if len(opts.Repositories) != 1 {
+			v.addf("--archive indexes a single repository; name it with one --build-index")
+		}
+		if opts.HeadMode {
+			v.addf("--head and --archive are mutually exclusive: an archive has no git history")
+		}
+		if _, err := os.Stat(opts.Archive); err != nil {
+			v.addf("--archive %s does not exist", opts.Archive)
+		}
+	}
+	for _, name := range opts.Repositories {
+		if opts.Archive != "" {
+			break // an archive may be indexed under a name that is not configured
+		}
+		if !names[name] {
+			v.addf("--build-index names repository '%s', which is not in the source configuration", name)
+		}
+	}
+	projects := make(map[string]bool, len(c.Source.Projects))
+	for _, project := range c.Source.Projects {
+		if project.Name == "" {
+			v.addf("a project has no name")
+			continue
+		}
+		if projects[project.Name] {
+			v.addf("project '%s' is listed more than once", project.Name)
+		}
+		projects[project.Name] = true
+		if len(project.Repositories) == 0 {
+			v.addf("project '%s' has no repositories", project.Name)
+		}
+		for _, name := range project.Repositories {
+			if !names[name] {
+				v.addf("project '%s' names repository '%s', which is not in the source configuration", project.Name, name)
+			}
+		}
+	}
+	for _, name := range c.Warmup.Repositories {
+		if !names[name] {
+			v.addf("warmup.repositories names repository '%s', which is not in the source configuration", name)
+		}
+	}
+
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: v.problems}
+}
+
+// validator collects the problems found by Validate
+type validator struct {
+	problems []string
+}
+
+func (v *validator) addf(format string, args ...any) {
+	v.problems = append(v.problems, fmt.Sprintf(format, args...))
+}
+
+// apiScope checks that a scope named by the api section has redaction rules
+func (v *validator) apiScope(field, scope string, redaction map[string]RedactionRules) {
+	if _, ok := redaction[scope]; scope != "" && !ok {
+		v.addf("%s names scope %q, which has no rules in api.redaction", field, scope)
+	}
+}
+
+func (v *validator) port(field string, port int, required bool) {
+	if port == 0 && required {
+		v.addf("%s is required", field)
+	} else if port < 0 || port > 65535 {
+		v.addf("%s %d is out of range (1-65535)", field, port)
+	}
+}
+
+func (v *validator) logLevel(field, level string) {
+	if level != "" && !contains(logLevels, strings.ToLower(level)) {
+		v.addf("%s %q is unknown (valid: %s)", field, level, strings.Join(logLevels, ", "))
+	}
+}
+
+func (v *validator) stopChunks(field string, kinds []string) {
+	for _, kind := range kinds {
+		if kind == "none" {
+			if len(kinds) > 1 {
+				v.addf("%s: none cannot be combined with other kinds", field)
+			}
+		} else if !contains(stopChunkKinds, kind) {
+			v.addf("%s: unknown kind %q (valid: %s, none)", field, kind, strings.Join(stopChunkKinds, ", "))
+		}
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// upperSnakeCase reports whether s is an UPPER_SNAKE_CASE identifier
+func upperSnakeCase(s string) bool {
+	if s == "" || s[0] < 'A' || s[0] > 'Z' {
+		return false
+	}
+	for _, r := range s {
+		if r != '_' && (r < 'A' || r > 'Z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateReportsAllProblems(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &Config{
+		App:     App{Port: 8181, RequiredServices: []string{"qdrant"}},
+		Mcp:     McpConfig{Port: 8181},
+		Qdrant:  QdrantConfig{Host: "localhost", Port: 6334},
+		Logging: LoggingConfig{Level: "verbose"},
+		CodeGraph: CodeGraphConfig{
+			CrossFileBatching: true,
+			Languages:         map[string]LanguageOptions{"go": {Lambdas: "inline"}, "cobol": {}, "java": {SkipTypeOnly: true}},
+		},
+		Enrichment: EnrichmentConfig{
+			Enabled:   true,
+			Relations: []EnrichmentRelation{{Type: "runtime-calls"}},
+		},
+		API: APIConfig{
+			DefaultScope: "public",
+			QuotaPeriod:  "week",
+			Keys:         []APIKey{{Name: "ci"}, {Name: "ci", Key: "k", Scope: "partner", Quota: UsageQuota{FilesIndexed: -1}}},
+			Redaction:    map[string]RedactionRules{"partner": {MaskDirectories: []string{"../x"}}},
+		},
+		Storage: StorageConfig{Backend: "s3"},
+		Cluster: ClusterConfig{Locking: "mysql"},
+		Cache:   CacheConfig{RedisPassword: "secret"},
+		Source: SourceConfig{
+			Repositories: []Repository{
+				{Name: "plain", Path: dir},
+				{Name: "missing", Path: filepath.Join(dir, "missing")},
+				{Name: "off", Path: filepath.Join(dir, "missing"), Disabled: true},
+			},
+			Projects: []Project{
+				{Name: "shop", Repositories: []string{"plain", "ghost"}},
+				{Name: "empty"},
+			},
+		},
+	}
+
+	err := cfg.Validate(ValidateOptions{HeadMode: true, Repositories: []string{"plain", "unknown"}})
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Validate() = %v, want *ValidationError", err)
+	}
+	want := []string{
+		"app.port and mcp.port are both 8181",
+		`unknown service "qdrant"`,
+		"needs both qdrant.host and ollama.url",
+		"cross_file_batching requires code_graph.enable_batch_writes",
+		`code_graph.languages: unknown language "cobol"`,
+		`code_graph.languages.go.lambdas "inline" is unknown`,
+		"code_graph.languages.java: skip_type_only, max_nesting_depth and lambdas are only supported for go, python, rust",
+		`logging.level "verbose" is unknown`,
+		"enrichment.enabled requires app.codegraph",
+		`enrichment.relations[0].type "runtime-calls" must be UPPER_SNAKE_CASE`,
+		`api.default_scope names scope "public"`,
+		"api.keys[0].key is empty",
+		"api.keys: ci is listed more than once",
+		"api.keys[1].quota cannot be negative",
+		`api.quota_period "week" is unknown`,
+		`api.redaction.partner.mask_directories: "../x"`,
+		"storage.bucket is required for the s3 backend",
+		"cluster.locking is mysql but mysql.host is not set",
+		"cache.redis_password or cache.redis_tls is set but cache.redis_address is not",
+		"repository 'plain': --head",
+		"repository 'missing': path",
+		"repository 'unknown', which is not in the source configuration",
+		"project 'shop' names repository 'ghost'",
+		"project 'empty' has no repositories",
+	}
+	if len(verr.Problems) != len(want) {
+		t.Fatalf("got %d problems, want %d:\n%s", len(verr.Problems), len(want), err)
+	}
+	for i, w := range want {
+		if !strings.Contains(verr.Problems[i], w) {
+			t.Errorf("problem %d = %q, want it to contain %q", i, verr.Problems[i], w)
+		}
+	}
+}
+
+func TestValidateAcceptsGitRepositoryInHeadMode(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{
+		App:    App{Port: 8181},
+		Mcp:    McpConfig{Port: 8282},
+		Source: SourceConfig{Repositories: []Repository{{Name: "repo", Path: dir}}},
+	}
+	if err := cfg.Validate(ValidateOptions{HeadMode: true}); err != nil {
+		t.Errorf("Validate() = %v, want nil", err)
+	}
+}
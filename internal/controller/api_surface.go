@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"bot-go/internal/apisurface"
+	"bot-go/internal/apperrors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiSurfaceRepo returns the name of the configured repository whose API snapshots are
+// requested. Snapshots are taken by index builds of repositories configured with
+// library: true and kept in the configured storage.
+func (rc *RepoController) apiSurfaceRepo(name string) (string, error) {
+	repo, err := rc.config.GetRepository(name)
+	if err != nil {
+		return "", err
+	}
+	if rc.store == nil {
+		return "", apperrors.NotFound("API surface", repo.Name+" (no storage is configured)")
+	}
+	return repo.Name, nil
+}
+
+// GetAPISurface returns the public API of a library repository at ?version= (the latest
+// snapshot by default)
+func (rc *RepoController) GetAPISurface(c *gin.Context) {
+	repo, err := rc.apiSurfaceRepo(c.Param("name"))
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	surface, err := apisurface.Load(c.Request.Context(), rc.store, repo, c.Query("version"))
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, surface)
+}
+
+// ListAPISurfaceVersions lists the API snapshots of a library repository, oldest first
+func (rc *RepoController) ListAPISurfaceVersions(c *gin.Context) {
+	repo, err := rc.apiSurfaceRepo(c.Param("name"))
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	versions, err := apisurface.Versions(c.Request.Context(), rc.store, repo)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	if versions == nil {
+		versions = []apisurface.VersionInfo{}
+	}
+	c.JSON(http.StatusOK, gin.H{"repo_name": c.Param("name"), "versions": versions})
+}
+
+// DiffAPISurface compares the API snapshots ?from= and ?to= (the latest by default) of a
+// library repository
+func (rc *RepoController) DiffAPISurface(c *gin.Context) {
+	from := c.Query("from")
+	if from == "" {
+		c.JSON(http.StatusBadRequest, invalidRequestBody(&ValidationError{
+			Fields: []FieldError{{Field: "from", Message: "is required"}},
+		}))
+		return
+	}
+	repo, err := rc.apiSurfaceRepo(c.Param("name"))
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	before, err := apisurface.Load(c.Request.Context(), rc.store, repo, from)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	after, err := apisurface.Load(c.Request.Context(), rc.store, repo, c.Query("to"))
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, apisurface.Compare(before, after))
+}
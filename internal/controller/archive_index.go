@@ -0,0 +1,165 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"bot-go/internal/apperrors"
+	"bot-go/internal/config"
+	"bot-go/internal/util"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultMaxArchiveMB = 512
+	maxVersionLength    = 40 // file versions store it in place of a commit SHA
+	archiveFormField    = "archive"
+)
+
+// BuildIndexFromArchiveRequest indexes a source archive (.zip, .tar, .tar.gz, .tgz) as a
+// version of a repository, without a checkout. It is sent as JSON naming an archive on the
+// server, or as multipart/form-data uploading the archive in the "archive" field.
+type BuildIndexFromArchiveRequest struct {
+	RepoName    string   `json:"repo_name" form:"repo_name" binding:"required"` // Configured settings apply when the name is configured
+	ArchivePath string   `json:"archive_path" form:"-"`                         // Archive inside index_building.archive_dir (JSON requests)
+	Version     string   `json:"version" form:"version"`                        // Label the files are recorded under, e.g. "v1.4.2"; empty = ephemeral
+	Language    string   `json:"language" form:"language"`                      // Language of a repository that is not configured
+	Processors  []string `json:"processors" form:"processors"`
+}
+
+// BuildIndexFromArchive extracts a source archive to a temporary directory, indexes it as a
+// version of the repository and removes the extracted files
+func (rc *RepoController) BuildIndexFromArchive(c *gin.Context) {
+	upload := strings.HasPrefix(c.ContentType(), "multipart/")
+	maxMB := rc.config.IndexBuilding.MaxArchiveMB
+	if maxMB <= 0 {
+		maxMB = defaultMaxArchiveMB
+	}
+
+	var request BuildIndexFromArchiveRequest
+	var err error
+	if upload {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, int64(maxMB)<<20)
+		if err = c.ShouldBind(&request); err == nil {
+			err = validateRequest(&request)
+		} else {
+			err = fmt.Errorf("%w: %v", apperrors.ErrInvalidArgument, err)
+		}
+	} else {
+		err = bindRequest(c, &request)
+	}
+	if err != nil {
+		rc.log(c).Error("Invalid request payload", zap.Error(err))
+		c.JSON(http.StatusBadRequest, invalidRequestBody(err))
+		return
+	}
+
+	workDir, err := os.MkdirTemp(rc.config.App.WorkDir, "archive-*")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create extraction directory", "details": err.Error()})
+		return
+	}
+	defer os.RemoveAll(workDir)
+
+	archivePath, err := rc.archiveSource(c, &request, upload, workDir)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": "Invalid archive", "details": err.Error()})
+		return
+	}
+	root, err := util.ExtractArchive(archivePath, filepath.Join(workDir, "src"), util.ArchiveLimits{})
+	if err != nil {
+		rc.log(c).Error("Failed to extract archive", zap.String("archive", filepath.Base(archivePath)), zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to extract archive", "details": err.Error()})
+		return
+	}
+
+	// The configured settings of the repository apply to its archives
+	repo := &config.Repository{Name: request.RepoName, Language: request.Language}
+	if configured, err := rc.config.GetRepository(request.RepoName); err == nil {
+		repo = configured
+		if request.Language != "" {
+			repo.Language = request.Language
+		}
+	}
+	repo.Path = root
+
+	rc.log(c).Info("Indexing source archive",
+		zap.String("repo_name", repo.Name),
+		zap.String("archive", filepath.Base(archivePath)),
+		zap.String("version", request.Version),
+		zap.Strings("processors", request.Processors))
+
+	indexBuilder := rc.newIndexBuilder(c, repo, request.Processors)
+	if indexBuilder == nil {
+		return
+	}
+	indexBuilder.SetArchiveSource(request.Version)
+	if err := indexBuilder.BuildIndex(c.Request.Context(), repo); err != nil {
+		rc.log(c).Error("Failed to build indexes for archive",
+			zap.String("repo_name", repo.Name),
+			zap.Error(err))
+		c.JSON(errorStatus(err), gin.H{
+			"error":   "Failed to process archive",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	summary := indexBuilder.Summary()
+	c.JSON(http.StatusOK, BuildIndexResponse{
+		RepoName: repo.Name,
+		Status:   "completed",
+		Message:  "Archive indexed successfully",
+		Summary:  &summary,
+	})
+}
+
+// archiveSource returns the path of the archive to extract: the upload saved into workDir,
+// or archive_path when it lies inside index_building.archive_dir
+func (rc *RepoController) archiveSource(c *gin.Context, request *BuildIndexFromArchiveRequest, upload bool, workDir string) (string, error) {
+	if upload {
+		file, err := c.FormFile(archiveFormField)
+		if err != nil {
+			return "", fmt.Errorf("%w: the archive must be uploaded in the %q field", apperrors.ErrInvalidArgument, archiveFormField)
+		}
+		if !util.IsArchive(file.Filename) {
+			return "", fmt.Errorf("%w: unsupported archive %q (supported: .zip, .tar, .tar.gz, .tgz)", apperrors.ErrInvalidArgument, file.Filename)
+		}
+		dest := filepath.Join(workDir, "upload-"+filepath.Base(file.Filename))
+		if err := c.SaveUploadedFile(file, dest); err != nil {
+			return "", fmt.Errorf("failed to save the uploaded archive: %w", err)
+		}
+		return dest, nil
+	}
+
+	if request.ArchivePath == "" {
+		return "", fmt.Errorf("%w: archive_path is required, or upload the archive as multipart/form-data", apperrors.ErrInvalidArgument)
+	}
+	archiveDir := rc.config.IndexBuilding.ArchiveDir
+	if archiveDir == "" {
+		return "", fmt.Errorf("%w: archive_path needs index_building.archive_dir to be configured; upload the archive instead", apperrors.ErrInvalidArgument)
+	}
+	dir, err := filepath.Abs(archiveDir)
+	if err != nil {
+		return "", err
+	}
+	archivePath, err := filepath.Abs(filepath.Join(dir, request.ArchivePath))
+	if err != nil {
+		return "", err
+	}
+	if rel, err := filepath.Rel(dir, archivePath); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: archive_path must be inside index_building.archive_dir", apperrors.ErrInvalidArgument)
+	}
+	if !util.IsArchive(archivePath) {
+		return "", fmt.Errorf("%w: unsupported archive %q (supported: .zip, .tar, .tar.gz, .tgz)", apperrors.ErrInvalidArgument, request.ArchivePath)
+	}
+	if _, err := os.Stat(archivePath); err != nil {
+		return "", fmt.Errorf("%w: archive %s", apperrors.ErrNotFound, request.ArchivePath)
+	}
+	return archivePath, nil
+}
@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"bot-go/internal/config"
+	"bot-go/internal/logging"
+	"bot-go/internal/model/ast"
+	"bot-go/internal/service/codegraph"
+	gitutil "bot-go/internal/signals/util"
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+// Metadata keys written by BlameProcessor on Function and Class nodes
+const (
+	MetaLastModifiedAt     = "last_modified_at" // unix seconds of the newest commit touching the node
+	MetaLastModifiedBy     = "last_modified_by" // author of that commit
+	MetaLastModifiedEmail  = "last_modified_email"
+	MetaLastModifiedCommit = "last_modified_commit"
+)
+
+// BlameProcessor implements FileProcessor for git blame enrichment: it records the author
+// and time of the newest commit touching each Function and Class node. It must run after
+// CodeGraphProcessor, which creates the nodes of a file.
+type BlameProcessor struct {
+	codeGraph *codegraph.CodeGraph
+	logger    *zap.Logger
+	fileCount atomic.Int64
+}
+
+// NewBlameProcessor creates a new blame processor
+func NewBlameProcessor(codeGraph *codegraph.CodeGraph, logger *zap.Logger) *BlameProcessor {
+	return &BlameProcessor{
+		codeGraph: codeGraph,
+		logger:    logger,
+	}
+}
+
+// Name returns the processor name
+func (bp *BlameProcessor) Name() string {
+	return "Blame"
+}
+
+// ProcessFile blames the file at the commit it was read from and stores the result on the
+// file's Function and Class nodes
+func (bp *BlameProcessor) ProcessFile(ctx context.Context, repo *config.Repository, fileCtx *FileContext) error {
+	// Lightweight files have no Function or Class nodes
+	if fileCtx.LightweightReason != "" {
+		return nil
+	}
+
+	nodes, err := bp.codeGraph.FindNodesInFile(ctx, fileCtx.FileID, ast.NodeTypeFunction, ast.NodeTypeClass)
+	if err != nil {
+		return fmt.Errorf("failed to read nodes of %s: %w", fileCtx.RelativePath, err)
+	}
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	analyzer := gitutil.NewOnDemandGitAnalyzer(repo.Path, 0)
+	lines, err := analyzer.GetBlame(ctx, fileCtx.FilePath, fileCtx.CommitSHA())
+	if err != nil {
+		// Untracked files and repositories without git have no blame
+		bp.log(ctx).Debug("Skipping blame for file",
+			zap.String("path", fileCtx.RelativePath),
+			zap.Error(err))
+		return nil
+	}
+
+	updates := blameMetadata(nodes, lines)
+	if len(updates) == 0 {
+		return nil
+	}
+	if err := bp.codeGraph.BatchUpdateNodeMetaData(ctx, updates); err != nil {
+		return fmt.Errorf("failed to store blame for %s: %w", fileCtx.RelativePath, err)
+	}
+	bp.fileCount.Add(1)
+
+	bp.log(ctx).Debug("Stored blame metadata",
+		zap.String("path", fileCtx.RelativePath),
+		zap.Int("nodes", len(updates)))
+	return nil
+}
+
+// PostProcess has no repository-level work; it logs how many files were blamed
+func (bp *BlameProcessor) PostProcess(ctx context.Context, repo *config.Repository) error {
+	bp.log(ctx).Info("Blame enrichment completed",
+		zap.String("repo_name", repo.Name),
+		zap.Int64("files", bp.fileCount.Swap(0)))
+	return nil
+}
+
+// blameMetadata picks for every node the newest committed line within its range. Nodes whose
+// lines are all uncommitted get no metadata.
+func blameMetadata(nodes []*ast.Node, lines []gitutil.BlameLine) map[ast.NodeID]map[string]any {
+	updates := make(map[ast.NodeID]map[string]any)
+	for _, node := range nodes {
+		// Node ranges are 0-based; blame lines are 1-based and in file order
+		start, end := node.Range.Start.Line, node.Range.End.Line
+		var newest *gitutil.BlameLine
+		for i := start; i <= end && i < len(lines); i++ {
+			if i < 0 || lines[i].Uncommitted() {
+				continue
+			}
+			if newest == nil || lines[i].CommitterTime.After(newest.CommitterTime) {
+				newest = &lines[i]
+			}
+		}
+		if newest == nil {
+			continue
+		}
+		updates[node.ID] = map[string]any{
+			MetaLastModifiedAt:     newest.CommitterTime.Unix(),
+			MetaLastModifiedBy:     newest.Author,
+			MetaLastModifiedEmail:  newest.AuthorEmail,
+			MetaLastModifiedCommit: newest.CommitHash,
+		}
+	}
+	return updates
+}
+
+// blameTime returns the MetaLastModifiedAt of a node, 0 without blame metadata
+func blameTime(node *ast.Node) int64 {
+	switch at := node.MetaData[MetaLastModifiedAt].(type) {
+	case int64:
+		return at
+	case float64:
+		return int64(at)
+	}
+	return 0
+}
+
+// log returns the logger with the request ID carried by ctx attached
+func (bp *BlameProcessor) log(ctx context.Context) *zap.Logger {
+	return logging.FromContext(ctx, bp.logger)
+}
@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bot-go/internal/config"
+	"bot-go/internal/model"
+	"bot-go/internal/model/ast"
+	"bot-go/internal/service/codegraph"
+	gitutil "bot-go/internal/signals/util"
+	"bot-go/pkg/lsp/base"
+
+	"go.uber.org/zap"
+)
+
+func TestBlameMetadata(t *testing.T) {
+	day := func(d int) time.Time { return time.Unix(int64(d)*86400, 0) }
+	lines := []gitutil.BlameLine{
+		{Line: 1, CommitHash: "a", Author: "ann", CommitterTime: day(1)},
+		{Line: 2, CommitHash: "b", Author: "bob", CommitterTime: day(3)},
+		{Line: 3, CommitHash: "c", Author: "cat", CommitterTime: day(2)},
+		{Line: 4, CommitHash: "0000000000000000000000000000000000000000", Author: "Not Committed Yet", CommitterTime: day(9)},
+	}
+	node := func(id ast.NodeID, start, end int) *ast.Node {
+		return &ast.Node{ID: id, Range: base.Range{Start: base.Position{Line: start}, End: base.Position{Line: end}}}
+	}
+
+	updates := blameMetadata([]*ast.Node{node(1, 0, 3), node(2, 2, 2), node(3, 3, 3)}, lines)
+	if got := updates[1][MetaLastModifiedBy]; got != "bob" {
+		t.Errorf("node 1: expected newest committed author bob, got %v", got)
+	}
+	if got := updates[2][MetaLastModifiedAt]; got != day(2).Unix() {
+		t.Errorf("node 2: expected time of line 3, got %v", got)
+	}
+	if _, ok := updates[3]; ok {
+		t.Error("node 3 only has uncommitted lines and should get no metadata")
+	}
+}
+
+func TestBlameLastModified(t *testing.T) {
+	node := func(id, start, end int, modifiedAt int64) map[string]any {
+		props := map[string]any{
+			"id": int64(id), "nodeType": int64(ast.NodeTypeFunction), "fileId": int64(7), "name": "f",
+			"startLine": int64(start), "startChar": int64(0), "endLine": int64(end), "endChar": int64(1),
+		}
+		if modifiedAt != 0 {
+			props["md_"+MetaLastModifiedAt] = modifiedAt
+		}
+		return map[string]any{"n": props}
+	}
+	// A class spanning lines 0-30 holding two methods, and an unblamed function
+	db := &recordsDatabase{records: []map[string]any{
+		node(1, 0, 30, 500),
+		node(2, 2, 10, 100),
+		node(3, 12, 20, 500),
+		node(4, 40, 50, 0),
+	}}
+	lastModified := blameLastModified(codegraph.NewCodeGraphWithDatabase(db, &config.Config{}, zap.NewNop()))
+
+	tests := []struct {
+		name       string
+		start, end int
+		want       int64 // 0 for an error
+	}{
+		{"method", 2, 10, 100},
+		{"part of a method", 4, 6, 100},
+		{"whole file", 0, 60, 500},
+		{"unblamed function", 40, 50, 0},
+	}
+	for _, tt := range tests {
+		got, err := lastModified(context.Background(), &model.CodeChunk{FileID: 7, StartLine: tt.start, EndLine: tt.end})
+		switch {
+		case tt.want == 0 && err == nil:
+			t.Errorf("%s: got %v, want an error", tt.name, got)
+		case tt.want != 0 && (err != nil || got.Unix() != tt.want):
+			t.Errorf("%s: got %v, %v, want %d", tt.name, got.Unix(), err, tt.want)
+		}
+	}
+	if db.reads != 1 {
+		t.Errorf("read the file's nodes %d times, want once", db.reads)
+	}
+}
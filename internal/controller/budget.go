@@ -0,0 +1,283 @@
+package controller
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+
+	"bot-go/internal/codeapi"
+	"bot-go/internal/model/ast"
+	"bot-go/internal/model/schema"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Token budgets let agent-facing endpoints return a result sized for a context window: the
+// server keeps the most relevant entries whose JSON fits the budget and reports counts for
+// the rest under "budget", instead of clients trimming the full result.
+const (
+	defaultSummaryTokens = 2000   // budget of summary=true without max_tokens
+	maxBudgetTokens      = 100000 // bound on max_tokens
+	bytesPerToken        = 4      // rough size of a token of JSON
+)
+
+// ResponseBudget is embedded in the requests of endpoints that support token budgets
+type ResponseBudget struct {
+	MaxTokens int  `json:"max_tokens"` // approximate size limit of the result in tokens (0 = full result)
+	Summary   bool `json:"summary"`    // summarize within defaultSummaryTokens when max_tokens is 0
+}
+
+// tokens returns the budget to apply, 0 for none
+func (b ResponseBudget) tokens() int {
+	if b.MaxTokens == 0 && b.Summary {
+		return defaultSummaryTokens
+	}
+	return b.MaxTokens
+}
+
+// BudgetInfo describes how a result was cut to fit its budget
+type BudgetInfo struct {
+	MaxTokens       int  `json:"max_tokens"`
+	EstimatedTokens int  `json:"estimated_tokens"` // of the returned result
+	Truncated       bool `json:"truncated"`
+	Total           int  `json:"total"` // entries in the full result (nodes, affected elements, symbols)
+	Kept            int  `json:"kept"`
+	// Omitted counts the entries left out per grouping, e.g. {"depth": {"2": 14}}
+	Omitted map[string]map[string]int `json:"omitted,omitempty"`
+}
+
+func (info *BudgetInfo) omit(group, key string) {
+	if info.Omitted == nil {
+		info.Omitted = make(map[string]map[string]int)
+	}
+	if info.Omitted[group] == nil {
+		info.Omitted[group] = make(map[string]int)
+	}
+	info.Omitted[group][key]++
+}
+
+// budgetedModuleSummary is a module summary response with its budget information
+type budgetedModuleSummary struct {
+	*codeapi.ModuleSummary
+	Budget *BudgetInfo `json:"budget"`
+}
+
+// withBudget adds the budget information, if any, to a response body under "budget"
+func withBudget(body gin.H, info *BudgetInfo) gin.H {
+	if info != nil {
+		body["budget"] = info
+	}
+	return body
+}
+
+// estimateTokens approximates the tokens of a value's JSON
+func estimateTokens(v any) int {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	return (len(data) + bytesPerToken - 1) / bytesPerToken
+}
+
+// fitBudget returns the largest n in [0, total] whose build(n) fits in maxTokens, with the
+// result and its estimated tokens. build(0) is returned when nothing fits; results must grow
+// with n.
+func fitBudget(maxTokens, total int, build func(n int) any) (int, any, int) {
+	full := build(total)
+	if tokens := estimateTokens(full); tokens <= maxTokens {
+		return total, full, tokens
+	}
+	lo, hi := 0, total-1 // build(lo) is the answer if nothing larger fits
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if estimateTokens(build(mid)) <= maxTokens {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	result := build(lo)
+	return lo, result, estimateTokens(result)
+}
+
+func absDepth(depth int) int {
+	if depth < 0 {
+		return -depth
+	}
+	return depth
+}
+
+// budgetCallGraph keeps the call graph nodes closest to the root, and among them those with
+// the most edges, with the edges between kept nodes. Edges keep their first call site and
+// count but not the full call site list.
+func budgetCallGraph(graph *codeapi.CallGraph, maxTokens int) (*codeapi.CallGraph, *BudgetInfo) {
+	degree := make(map[ast.NodeID]int)
+	edges := make([]*codeapi.CallEdge, len(graph.Edges))
+	for i, edge := range graph.Edges {
+		degree[edge.CallerID]++
+		degree[edge.CalleeID]++
+		trimmed := *edge
+		trimmed.CallSites = nil
+		edges[i] = &trimmed
+	}
+	nodes := make([]*codeapi.CallNode, 0, len(graph.Nodes))
+	for _, node := range graph.Nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		a, b := nodes[i], nodes[j]
+		if absDepth(a.Depth) != absDepth(b.Depth) {
+			return absDepth(a.Depth) < absDepth(b.Depth)
+		}
+		if degree[a.ID] != degree[b.ID] {
+			return degree[a.ID] > degree[b.ID]
+		}
+		return a.ID < b.ID
+	})
+
+	build := func(n int) any {
+		trimmed := *graph
+		trimmed.Nodes = make(map[ast.NodeID]*codeapi.CallNode, n)
+		for _, node := range nodes[:n] {
+			trimmed.Nodes[node.ID] = node
+		}
+		trimmed.Edges = make([]*codeapi.CallEdge, 0)
+		for _, edge := range edges {
+			if trimmed.Nodes[edge.CallerID] != nil && trimmed.Nodes[edge.CalleeID] != nil {
+				trimmed.Edges = append(trimmed.Edges, edge)
+			}
+		}
+		return &trimmed
+	}
+	kept, result, tokens := fitBudget(maxTokens, len(nodes), build)
+
+	info := &BudgetInfo{MaxTokens: maxTokens, EstimatedTokens: tokens, Truncated: kept < len(nodes), Total: len(nodes), Kept: kept}
+	for _, node := range nodes[kept:] {
+		info.omit("depth", strconv.Itoa(absDepth(node.Depth)))
+	}
+	return result.(*codeapi.CallGraph), info
+}
+
+// budgetDependencyGraph keeps the dependency graph nodes closest to the root, with the edges
+// between kept nodes
+func budgetDependencyGraph(graph *codeapi.DependencyGraph, maxTokens int) (*codeapi.DependencyGraph, *BudgetInfo) {
+	degree := make(map[ast.NodeID]int)
+	for _, edge := range graph.Edges {
+		degree[edge.SourceID]++
+		degree[edge.TargetID]++
+	}
+	nodes := make([]*codeapi.DependencyNode, 0, len(graph.Nodes))
+	for _, node := range graph.Nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		a, b := nodes[i], nodes[j]
+		if absDepth(a.Depth) != absDepth(b.Depth) {
+			return absDepth(a.Depth) < absDepth(b.Depth)
+		}
+		if degree[a.ID] != degree[b.ID] {
+			return degree[a.ID] > degree[b.ID]
+		}
+		return a.ID < b.ID
+	})
+
+	build := func(n int) any {
+		trimmed := *graph
+		trimmed.Nodes = make(map[ast.NodeID]*codeapi.DependencyNode, n)
+		for _, node := range nodes[:n] {
+			trimmed.Nodes[node.ID] = node
+		}
+		trimmed.Edges = make([]*codeapi.DependencyEdge, 0)
+		for _, edge := range graph.Edges {
+			if trimmed.Nodes[edge.SourceID] != nil && trimmed.Nodes[edge.TargetID] != nil {
+				trimmed.Edges = append(trimmed.Edges, edge)
+			}
+		}
+		return &trimmed
+	}
+	kept, result, tokens := fitBudget(maxTokens, len(nodes), build)
+
+	info := &BudgetInfo{MaxTokens: maxTokens, EstimatedTokens: tokens, Truncated: kept < len(nodes), Total: len(nodes), Kept: kept}
+	for _, node := range nodes[kept:] {
+		info.omit("depth", strconv.Itoa(absDepth(node.Depth)))
+		info.omit("label", string(schema.LabelOf(node.NodeType)))
+	}
+	return result.(*codeapi.DependencyGraph), info
+}
+
+// budgetImpact keeps the affected nodes with the highest scores in Ranked. The other node
+// lists repeat the same nodes and are left empty; the risk score and owners still cover the
+// full result.
+func budgetImpact(impact *codeapi.ImpactResult, maxTokens int) (*codeapi.ImpactResult, *BudgetInfo) {
+	ranked := impact.Ranked
+	if len(ranked) == 0 {
+		ranked = impact.AffectedNodes
+	}
+	build := func(n int) any {
+		trimmed := *impact
+		trimmed.AffectedNodes = []*codeapi.ImpactNode{}
+		trimmed.AffectedByCallGraph = []*codeapi.ImpactNode{}
+		trimmed.AffectedByDataFlow = []*codeapi.ImpactNode{}
+		trimmed.Ranked = ranked[:n]
+		return &trimmed
+	}
+	kept, result, tokens := fitBudget(maxTokens, len(ranked), build)
+
+	info := &BudgetInfo{MaxTokens: maxTokens, EstimatedTokens: tokens, Truncated: kept < len(ranked), Total: len(ranked), Kept: kept}
+	for _, node := range ranked[kept:] {
+		info.omit("depth", strconv.Itoa(absDepth(node.Depth)))
+		info.omit("impact", string(node.Impact))
+	}
+	return result.(*codeapi.ImpactResult), info
+}
+
+// symbolKindRank orders exported symbols for module summaries: types first, then functions,
+// then methods
+var symbolKindRank = map[string]int{
+	codeapi.SymbolKindClass:    0,
+	codeapi.SymbolKindFunction: 1,
+	codeapi.SymbolKindMethod:   2,
+}
+
+// budgetModuleSummary keeps the first n exported symbols by kind (classes, functions, then
+// methods), and the first n dependencies and dependents (the most calls), in their original
+// order
+func budgetModuleSummary(summary *codeapi.ModuleSummary, maxTokens int) (*codeapi.ModuleSummary, *BudgetInfo) {
+	order := make([]int, len(summary.Exported))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return symbolKindRank[summary.Exported[order[i]].Kind] < symbolKindRank[summary.Exported[order[j]].Kind]
+	})
+	total := max(len(summary.Exported), len(summary.Dependencies), len(summary.Dependents))
+
+	build := func(n int) any {
+		trimmed := *summary
+		keep := append([]int(nil), order[:min(n, len(order))]...)
+		sort.Ints(keep)
+		trimmed.Exported = make([]*codeapi.ModuleSymbol, len(keep))
+		for i, index := range keep {
+			trimmed.Exported[i] = summary.Exported[index]
+		}
+		trimmed.Dependencies = summary.Dependencies[:min(n, len(summary.Dependencies))]
+		trimmed.Dependents = summary.Dependents[:min(n, len(summary.Dependents))]
+		return &trimmed
+	}
+	n, result, tokens := fitBudget(maxTokens, total, build)
+	trimmed := result.(*codeapi.ModuleSummary)
+
+	all := len(summary.Exported) + len(summary.Dependencies) + len(summary.Dependents)
+	kept := len(trimmed.Exported) + len(trimmed.Dependencies) + len(trimmed.Dependents)
+	info := &BudgetInfo{MaxTokens: maxTokens, EstimatedTokens: tokens, Truncated: kept < all, Total: all, Kept: kept}
+	for _, index := range order[min(n, len(order)):] {
+		info.omit("exported", summary.Exported[index].Kind)
+	}
+	for range summary.Dependencies[len(trimmed.Dependencies):] {
+		info.omit("module", "dependencies")
+	}
+	for range summary.Dependents[len(trimmed.Dependents):] {
+		info.omit("module", "dependents")
+	}
+	return trimmed, info
+}
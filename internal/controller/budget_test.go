@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"fmt"
+	"testing"
+
+	"bot-go/internal/codeapi"
+	"bot-go/internal/model/ast"
+)
+
+func TestBudgetCallGraph(t *testing.T) {
+	// A root calling 50 functions, each calling one more
+	graph := &codeapi.CallGraph{Nodes: map[ast.NodeID]*codeapi.CallNode{}}
+	graph.Root = &codeapi.CallNode{ID: 1, Name: "root"}
+	graph.Nodes[1] = graph.Root
+	for i := 0; i < 50; i++ {
+		child, grandchild := ast.NodeID(100+i), ast.NodeID(200+i)
+		graph.Nodes[child] = &codeapi.CallNode{ID: child, Name: fmt.Sprintf("child%d", i), Depth: 1}
+		graph.Nodes[grandchild] = &codeapi.CallNode{ID: grandchild, Name: fmt.Sprintf("grandchild%d", i), Depth: 2}
+		sites := []*codeapi.Location{{FileID: 7}, {FileID: 7}}
+		graph.Edges = append(graph.Edges,
+			&codeapi.CallEdge{CallerID: 1, CalleeID: child, Count: 2, CallSite: sites[0], CallSites: sites},
+			&codeapi.CallEdge{CallerID: child, CalleeID: grandchild, Count: 1})
+	}
+
+	trimmed, info := budgetCallGraph(graph, 1000)
+	if !info.Truncated || info.Total != 101 || info.Kept != len(trimmed.Nodes) || info.EstimatedTokens > 1000 {
+		t.Fatalf("budget info = %+v, kept nodes = %d", info, len(trimmed.Nodes))
+	}
+	if trimmed.Nodes[1] == nil {
+		t.Error("root was dropped")
+	}
+	keptDepth2 := 0
+	for _, node := range trimmed.Nodes {
+		if node.Depth == 2 {
+			keptDepth2++
+		}
+	}
+	if keptDepth2 > 0 && len(trimmed.Nodes)-1-keptDepth2 < 50 {
+		t.Error("kept depth 2 nodes before all depth 1 nodes")
+	}
+	omitted := info.Omitted["depth"]["1"] + info.Omitted["depth"]["2"]
+	if omitted != info.Total-info.Kept {
+		t.Errorf("omitted counts %v do not add up to %d", info.Omitted, info.Total-info.Kept)
+	}
+	for _, edge := range trimmed.Edges {
+		if trimmed.Nodes[edge.CallerID] == nil || trimmed.Nodes[edge.CalleeID] == nil {
+			t.Fatalf("edge %d -> %d has a dropped end", edge.CallerID, edge.CalleeID)
+		}
+		if edge.CallSites != nil {
+			t.Fatal("edge kept its call site list")
+		}
+	}
+	if len(graph.Nodes) != 101 || graph.Edges[0].CallSites == nil {
+		t.Error("the original graph was modified")
+	}
+
+	if _, info := budgetCallGraph(graph, 1000000); info.Truncated || info.Kept != 101 {
+		t.Errorf("a large budget truncated the graph: %+v", info)
+	}
+}
+
+func TestBudgetImpact(t *testing.T) {
+	impact := &codeapi.ImpactResult{RiskLevel: codeapi.RiskHigh}
+	for i := 0; i < 100; i++ {
+		node := &codeapi.ImpactNode{ID: ast.NodeID(i), Name: fmt.Sprintf("fn%d", i), Depth: -1 - i%3, Impact: codeapi.ImpactTypeCallGraph, Score: float64(100 - i)}
+		impact.AffectedNodes = append(impact.AffectedNodes, node)
+		impact.AffectedByCallGraph = append(impact.AffectedByCallGraph, node)
+		impact.Ranked = append(impact.Ranked, node)
+	}
+	impact.TotalAffected = 100
+
+	trimmed, info := budgetImpact(impact, 500)
+	if !info.Truncated || info.Kept == 0 || info.Kept != len(trimmed.Ranked) || info.EstimatedTokens > 500 {
+		t.Fatalf("budget info = %+v", info)
+	}
+	if trimmed.Ranked[0].ID != 0 || len(trimmed.AffectedNodes) != 0 || trimmed.TotalAffected != 100 || trimmed.RiskLevel != codeapi.RiskHigh {
+		t.Errorf("unexpected trimmed impact %+v", trimmed)
+	}
+	if info.Omitted["impact"][string(codeapi.ImpactTypeCallGraph)] != 100-info.Kept {
+		t.Errorf("omitted = %v", info.Omitted)
+	}
+}
+
+func TestBudgetModuleSummary(t *testing.T) {
+	summary := &codeapi.ModuleSummary{Module: &codeapi.ModuleInfo{Path: "store"}}
+	for i := 0; i < 40; i++ {
+		kind := codeapi.SymbolKindMethod
+		if i%10 == 0 {
+			kind = codeapi.SymbolKindClass
+		}
+		summary.Exported = append(summary.Exported, &codeapi.ModuleSymbol{ID: ast.NodeID(i), Name: fmt.Sprintf("Symbol%d", i), Kind: kind})
+	}
+	summary.Dependencies = []*codeapi.ModuleDependency{{Path: "db", Calls: 9}, {Path: "log", Calls: 1}}
+
+	trimmed, info := budgetModuleSummary(summary, 300)
+	if !info.Truncated || info.Total != 42 || info.EstimatedTokens > 300 {
+		t.Fatalf("budget info = %+v", info)
+	}
+	classes := 0
+	for i, symbol := range trimmed.Exported {
+		if symbol.Kind == codeapi.SymbolKindClass {
+			classes++
+		}
+		if i > 0 && symbol.ID < trimmed.Exported[i-1].ID {
+			t.Fatal("exported symbols are out of order")
+		}
+	}
+	if len(trimmed.Exported) >= 4 && classes != 4 {
+		t.Errorf("kept %d symbols but only %d of the 4 classes", len(trimmed.Exported), classes)
+	}
+	if trimmed.Dependencies[0].Path != "db" {
+		t.Errorf("dependencies = %+v", trimmed.Dependencies)
+	}
+}
+
+func TestValidateResponseBudget(t *testing.T) {
+	err := validateRequest(&GetCallGraphRequest{ResponseBudget: ResponseBudget{MaxTokens: maxBudgetTokens + 1}})
+	if err == nil {
+		t.Fatal("expected max_tokens to be rejected")
+	}
+	if (ResponseBudget{Summary: true}).tokens() != defaultSummaryTokens || (ResponseBudget{MaxTokens: 50, Summary: true}).tokens() != 50 {
+		t.Error("unexpected budget tokens")
+	}
+}
@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"bot-go/internal/config"
+	"bot-go/internal/logging"
+	"bot-go/internal/service/codecard"
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// CodeCardProcessor implements FileProcessor for code cards: once the build has resolved
+// calls, it summarizes every function as a card (signature, doc comment, callers, callees,
+// complexity), stores the cards under the work directory and embeds them into the
+// repository's card collection. It has no per-file work.
+type CodeCardProcessor struct {
+	service *codecard.Service
+	logger  *zap.Logger
+}
+
+// NewCodeCardProcessor creates a new code card processor
+func NewCodeCardProcessor(service *codecard.Service, logger *zap.Logger) *CodeCardProcessor {
+	return &CodeCardProcessor{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// Name returns the processor name
+func (cp *CodeCardProcessor) Name() string {
+	return "CodeCards"
+}
+
+// ProcessFile does nothing; cards are built from the whole graph in Finalize
+func (cp *CodeCardProcessor) ProcessFile(ctx context.Context, repo *config.Repository, fileCtx *FileContext) error {
+	return nil
+}
+
+// PostProcess does nothing; calls are not resolved until the code graph post-processing
+// completes (see Finalize)
+func (cp *CodeCardProcessor) PostProcess(ctx context.Context, repo *config.Repository) error {
+	return nil
+}
+
+// Finalize builds, stores and embeds the code cards of the repository (see Finalizer)
+func (cp *CodeCardProcessor) Finalize(ctx context.Context, repo *config.Repository) error {
+	set, err := cp.service.Build(ctx, repo)
+	if err != nil {
+		return fmt.Errorf("failed to build code cards: %w", err)
+	}
+	cp.log(ctx).Info("Code cards built",
+		zap.String("repo_name", repo.Name),
+		zap.Int("cards", len(set.Cards)),
+		zap.Bool("embedded", cp.service.Searchable()))
+	return nil
+}
+
+// log returns the logger with the request ID carried by ctx attached
+func (cp *CodeCardProcessor) log(ctx context.Context) *zap.Logger {
+	return logging.FromContext(ctx, cp.logger)
+}
@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"bot-go/internal/apperrors"
+	"bot-go/internal/service/codecard"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SearchCodeCardsRequest is the request for searching the code cards of a repository
+type SearchCodeCardsRequest struct {
+	Query string `json:"query" binding:"required"`
+	Limit int    `json:"limit"` // default 10
+}
+
+// SetCodeCardService enables code card search
+func (rc *RepoController) SetCodeCardService(codeCards *codecard.Service) {
+	rc.codeCards = codeCards
+}
+
+// GetCodeCards returns the code cards stored by the last index build of a repository, only
+// those of ?file= or ?function_id= when given
+func (rc *RepoController) GetCodeCards(c *gin.Context) {
+	repo, err := rc.config.GetRepository(c.Param("name"))
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	var functionID int64
+	if value := c.Query("function_id"); value != "" {
+		if functionID, err = strconv.ParseInt(value, 10, 64); err != nil {
+			c.JSON(http.StatusBadRequest, invalidRequestBody(&ValidationError{
+				Fields: []FieldError{{Field: "function_id", Message: "must be a node ID"}},
+			}))
+			return
+		}
+	}
+	if rc.config.App.WorkDir == "" {
+		err := apperrors.NotFound("code cards", repo.Name+" (app.work_dir is not set)")
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	set, err := codecard.Load(codecard.File(rc.config.App.WorkDir, repo.Name))
+	if err == nil && set == nil {
+		err = apperrors.NotFound("code cards", repo.Name+" (index it with index_building.code_cards)")
+	}
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	file := c.Query("file")
+	cards := make([]*codecard.Card, 0, len(set.Cards))
+	for _, card := range set.Cards {
+		if (file == "" || card.File == file) && (functionID == 0 || card.FunctionID == functionID) {
+			cards = append(cards, card)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"repo_name":    repo.Name,
+		"generated_at": set.GeneratedAt,
+		"cards":        cards,
+	})
+}
+
+// SearchCodeCards returns the code cards whose embedding is closest to the query
+func (rc *RepoController) SearchCodeCards(c *gin.Context) {
+	var req SearchCodeCardsRequest
+	if err := bindRequest(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, invalidRequestBody(err))
+		return
+	}
+	repo, err := rc.config.GetRepository(c.Param("name"))
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	noteQuery(c, repo.Name, req.Query)
+	if rc.codeCards == nil || !rc.codeCards.Searchable() {
+		serviceUnavailable(c, rc.unavailable, ServiceVectorSearch, "Code card search not available")
+		return
+	}
+	if req.Limit <= 0 {
+		req.Limit = 10
+	}
+
+	matches, err := rc.codeCards.Search(c.Request.Context(), repo.Name, req.Query, req.Limit)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	noteResults(c, len(matches))
+	response := gin.H{"repo_name": repo.Name, "matches": matches}
+	if len(matches) == 0 {
+		d := rc.newSearchDiagnostics(c)
+		d.collection(codecard.CollectionName(repo.Name))
+		d.indexAge(repo)
+		if diagnostics := d.result(); len(diagnostics) > 0 {
+			response["diagnostics"] = diagnostics
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -2,9 +2,13 @@ package controller
 
 import (
 	"net/http"
+	"time"
 
 	"bot-go/internal/codeapi"
+	"bot-go/internal/config"
 	"bot-go/internal/model/ast"
+	"bot-go/internal/service/session"
+	"bot-go/pkg/lsp/base"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -12,8 +16,10 @@ import (
 
 // CodeAPIController handles HTTP requests for the CodeAPI
 type CodeAPIController struct {
-	api    codeapi.CodeAPI
-	logger *zap.Logger
+	api      codeapi.CodeAPI
+	sessions *session.Store
+	config   *config.Config
+	logger   *zap.Logger
 }
 
 // NewCodeAPIController creates a new CodeAPIController
@@ -24,6 +30,17 @@ func NewCodeAPIController(api codeapi.CodeAPI, logger *zap.Logger) *CodeAPIContr
 	}
 }
 
+// SetSessionStore enables recording retrieved nodes in the session named by X-Session-ID
+func (c *CodeAPIController) SetSessionStore(store *session.Store) {
+	c.sessions = store
+}
+
+// SetConfig gives endpoints that read repository files (such as CODEOWNERS) the repository
+// paths
+func (c *CodeAPIController) SetConfig(cfg *config.Config) {
+	c.config = cfg
+}
+
 // -----------------------------------------------------------------------------
 // Request/Response Types
 // -----------------------------------------------------------------------------
@@ -78,6 +95,46 @@ type FindMethodsRequest struct {
 	Offset    int         `json:"offset"`
 }
 
+// SearchSymbolsRequest is the request for full-text symbol search
+type SearchSymbolsRequest struct {
+	RepoName  string         `json:"repo_name" binding:"required"`
+	Query     string         `json:"query" binding:"required"`
+	Mode      string         `json:"mode"` // exact, prefix (default), substring, fuzzy
+	NodeTypes []ast.NodeType `json:"node_types"`
+	Limit     int            `json:"limit"`
+	SeenMode  string         `json:"seen_mode"` // exclude or deprioritize nodes already returned in the session
+}
+
+// SearchStringsRequest is the request for searching indexed string literals
+type SearchStringsRequest struct {
+	RepoName string `json:"repo_name" binding:"required"`
+	Query    string `json:"query" binding:"required"`
+	MatchAll bool   `json:"match_all"` // require every term of the query
+	Kind     string `json:"kind"`      // error, log, url or text
+	Limit    int    `json:"limit"`
+}
+
+// NodeAtPositionRequest is the request for finding the node at a source location. Line
+// and character are 0-based LSP positions (character counts UTF-16 code units).
+type NodeAtPositionRequest struct {
+	RepoName  string         `json:"repo_name" binding:"required"`
+	FilePath  string         `json:"file_path" binding:"required"`
+	Line      int            `json:"line"`
+	Character int            `json:"character"`
+	NodeTypes []ast.NodeType `json:"node_types"`
+}
+
+// FindConstantsRequest is the request for finding constants and enum members by name,
+// value or enum
+type FindConstantsRequest struct {
+	RepoName    string `json:"repo_name" binding:"required"`
+	Name        string `json:"name"`
+	Value       string `json:"value"` // source text of the value, e.g. "3600" or "\"admin\""
+	Enum        string `json:"enum"`
+	IncludeUses bool   `json:"include_uses"`
+	Limit       int    `json:"limit"`
+}
+
 // GetClassRequest is the request for getting a class by ID
 type GetClassRequest struct {
 	RepoName       string `json:"repo_name" binding:"required"`
@@ -102,6 +159,40 @@ type GetCallGraphRequest struct {
 	Direction       string `json:"direction"` // "outgoing", "incoming", "both"
 	MaxDepth        int    `json:"max_depth"`
 	IncludeExternal bool   `json:"include_external"`
+	MaxNodes        int    `json:"max_nodes"`    // 0 = defaultCallGraphMaxNodes
+	MaxEdges        int    `json:"max_edges"`    // 0 = defaultCallGraphMaxEdges
+	Continuation    string `json:"continuation"` // token from a previous truncated response
+	ResponseBudget
+}
+
+// Server-side caps on a call graph page; the response carries a continuation token when a
+// cap stops the traversal
+const (
+	defaultCallGraphMaxNodes = 500
+	defaultCallGraphMaxEdges = 2000
+)
+
+// callGraphOptions builds traversal options from a call graph request, applying the
+// default depth and caps
+func callGraphOptions(req *GetCallGraphRequest, direction codeapi.Direction) codeapi.CallGraphOptions {
+	opts := codeapi.CallGraphOptions{
+		Direction:       direction,
+		MaxDepth:        req.MaxDepth,
+		IncludeExternal: req.IncludeExternal,
+		MaxNodes:        req.MaxNodes,
+		MaxEdges:        req.MaxEdges,
+		Continuation:    req.Continuation,
+	}
+	if opts.MaxDepth <= 0 {
+		opts.MaxDepth = 3
+	}
+	if opts.MaxNodes <= 0 {
+		opts.MaxNodes = defaultCallGraphMaxNodes
+	}
+	if opts.MaxEdges <= 0 {
+		opts.MaxEdges = defaultCallGraphMaxEdges
+	}
+	return opts
 }
 
 // GetDataDependentsRequest is the request for getting data dependents
@@ -112,18 +203,89 @@ type GetDataDependentsRequest struct {
 	FilePath        string `json:"file_path"`
 	MaxDepth        int    `json:"max_depth"`
 	IncludeIndirect bool   `json:"include_indirect"`
+	ResponseBudget
 }
 
 // GetImpactRequest is the request for impact analysis
 type GetImpactRequest struct {
-	RepoName         string `json:"repo_name" binding:"required"`
-	NodeID           int64  `json:"node_id"`
-	Name             string `json:"name"`
-	NodeType         string `json:"node_type"` // "function", "class", "field", "variable"
-	FilePath         string `json:"file_path"`
-	MaxDepth         int    `json:"max_depth"`
-	IncludeCallGraph bool   `json:"include_call_graph"`
-	IncludeDataFlow  bool   `json:"include_data_flow"`
+	RepoName          string `json:"repo_name" binding:"required"`
+	NodeID            int64  `json:"node_id"`
+	Name              string `json:"name"`
+	NodeType          string `json:"node_type"` // "function", "class", "field", "variable"
+	FilePath          string `json:"file_path"`
+	MaxDepth          int    `json:"max_depth"`
+	IncludeCallGraph  bool   `json:"include_call_graph"`
+	IncludeDataFlow   bool   `json:"include_data_flow"`
+	ModifiedSinceDays int    `json:"modified_since_days"` // Keep only affected nodes changed in the last N days (needs blame metadata)
+	Project           string `json:"project"`             // Follow callers into the other repositories of this project
+
+	Scoring *ImpactScoringRequest `json:"scoring"` // Overrides of the default ranking weights
+	ResponseBudget
+}
+
+// ImpactScoringRequest overrides fields of codeapi.DefaultImpactScoring; absent fields keep
+// their defaults
+type ImpactScoringRequest struct {
+	DepthDecay          *float64 `json:"depth_decay"`
+	CallGraphWeight     *float64 `json:"call_graph_weight"`
+	DataFlowWeight      *float64 `json:"data_flow_weight"`
+	FanInWeight         *float64 `json:"fan_in_weight"`
+	MediumRiskThreshold *float64 `json:"medium_risk_threshold"`
+	HighRiskThreshold   *float64 `json:"high_risk_threshold"`
+}
+
+// scoring returns the default impact scoring with the request's overrides applied
+func (r *ImpactScoringRequest) scoring() codeapi.ImpactScoring {
+	scoring := codeapi.DefaultImpactScoring()
+	if r == nil {
+		return scoring
+	}
+	override := func(dst *float64, src *float64) {
+		if src != nil {
+			*dst = *src
+		}
+	}
+	override(&scoring.DepthDecay, r.DepthDecay)
+	override(&scoring.CallGraphWeight, r.CallGraphWeight)
+	override(&scoring.DataFlowWeight, r.DataFlowWeight)
+	override(&scoring.FanInWeight, r.FanInWeight)
+	override(&scoring.MediumRiskThreshold, r.MediumRiskThreshold)
+	override(&scoring.HighRiskThreshold, r.HighRiskThreshold)
+	return scoring
+}
+
+// FindDuplicatesRequest is the request for duplicate function detection
+type FindDuplicatesRequest struct {
+	RepoName string `json:"repo_name" binding:"required"`
+	MinSize  int    `json:"min_size"`
+	MinCount int    `json:"min_count"`
+	Limit    int    `json:"limit"`
+}
+
+// GetNodeCommitsRequest is the request for the commits that changed a node
+type GetNodeCommitsRequest struct {
+	RepoName string `json:"repo_name" binding:"required"`
+	NodeID   int64  `json:"node_id" binding:"required"`
+	Limit    int    `json:"limit"`
+}
+
+// ResolveErrorLocationRequest is the request for resolving a runtime error to source locations
+type ResolveErrorLocationRequest struct {
+	RepoName string `json:"repo_name" binding:"required"`
+	Text     string `json:"text" binding:"required"` // log line, error message and/or stack trace
+	Limit    int    `json:"limit"`
+}
+
+// ListModulesRequest is the request for listing the modules of a repository
+type ListModulesRequest struct {
+	RepoName string `json:"repo_name" binding:"required"`
+}
+
+// GetModuleSummaryRequest is the request for a module summary
+type GetModuleSummaryRequest struct {
+	RepoName string `json:"repo_name" binding:"required"`
+	Path     string `json:"path" binding:"required"` // Directory relative to the repository root, "." for the root
+	ResponseBudget
 }
 
 // ExecuteCypherRequest is the request for executing raw Cypher
@@ -136,27 +298,35 @@ type ExecuteCypherRequest struct {
 // Reader Endpoints
 // -----------------------------------------------------------------------------
 
-// ListRepos returns all available repositories
+// ListRepos returns all available repositories, or those of the project named by ?project=
 func (c *CodeAPIController) ListRepos(ctx *gin.Context) {
 	repos, err := c.api.Reader().ListRepos(ctx.Request.Context())
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
+	if name := ctx.Query("project"); name != "" {
+		members, err := projectRepos(c.config, name, "")
+		if err != nil {
+			ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		repos = filterRepos(repos, members)
+	}
 	ctx.JSON(http.StatusOK, ListReposResponse{Repos: repos})
 }
 
 // ListFiles returns files in a repository
 func (c *CodeAPIController) ListFiles(ctx *gin.Context) {
 	var req ListFilesRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := bindRequest(ctx, &req); err != nil {
+		ctx.JSON(http.StatusBadRequest, invalidRequestBody(err))
 		return
 	}
 
 	files, err := c.api.Reader().Repo(req.RepoName).ListFiles(ctx.Request.Context(), req.Limit, req.Offset)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 	ctx.JSON(http.StatusOK, gin.H{"files": files})
@@ -165,14 +335,14 @@ func (c *CodeAPIController) ListFiles(ctx *gin.Context) {
 // ListClasses returns classes in a repository
 func (c *CodeAPIController) ListClasses(ctx *gin.Context) {
 	var req ListClassesRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := bindRequest(ctx, &req); err != nil {
+		ctx.JSON(http.StatusBadRequest, invalidRequestBody(err))
 		return
 	}
 
 	classes, err := c.api.Reader().Repo(req.RepoName).ListClasses(ctx.Request.Context(), req.Limit, req.Offset)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 	ctx.JSON(http.StatusOK, gin.H{"classes": classes})
@@ -181,14 +351,14 @@ func (c *CodeAPIController) ListClasses(ctx *gin.Context) {
 // ListMethods returns methods in a repository
 func (c *CodeAPIController) ListMethods(ctx *gin.Context) {
 	var req ListMethodsRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := bindRequest(ctx, &req); err != nil {
+		ctx.JSON(http.StatusBadRequest, invalidRequestBody(err))
 		return
 	}
 
 	methods, err := c.api.Reader().Repo(req.RepoName).ListMethods(ctx.Request.Context(), req.Limit, req.Offset)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 	ctx.JSON(http.StatusOK, gin.H{"methods": methods})
@@ -197,14 +367,14 @@ func (c *CodeAPIController) ListMethods(ctx *gin.Context) {
 // ListFunctions returns top-level functions in a repository
 func (c *CodeAPIController) ListFunctions(ctx *gin.Context) {
 	var req ListMethodsRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := bindRequest(ctx, &req); err != nil {
+		ctx.JSON(http.StatusBadRequest, invalidRequestBody(err))
 		return
 	}
 
 	functions, err := c.api.Reader().Repo(req.RepoName).ListFunctions(ctx.Request.Context(), req.Limit, req.Offset)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 	ctx.JSON(http.StatusOK, gin.H{"functions": functions})
@@ -213,8 +383,8 @@ func (c *CodeAPIController) ListFunctions(ctx *gin.Context) {
 // FindClasses finds classes matching criteria
 func (c *CodeAPIController) FindClasses(ctx *gin.Context) {
 	var req FindClassesRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := bindRequest(ctx, &req); err != nil {
+		ctx.JSON(http.StatusBadRequest, invalidRequestBody(err))
 		return
 	}
 
@@ -229,7 +399,7 @@ func (c *CodeAPIController) FindClasses(ctx *gin.Context) {
 
 	classes, err := c.api.Reader().Repo(req.RepoName).FindClasses(ctx.Request.Context(), filter)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 	ctx.JSON(http.StatusOK, gin.H{"classes": classes})
@@ -238,8 +408,8 @@ func (c *CodeAPIController) FindClasses(ctx *gin.Context) {
 // FindMethods finds methods matching criteria
 func (c *CodeAPIController) FindMethods(ctx *gin.Context) {
 	var req FindMethodsRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := bindRequest(ctx, &req); err != nil {
+		ctx.JSON(http.StatusBadRequest, invalidRequestBody(err))
 		return
 	}
 
@@ -256,23 +426,166 @@ func (c *CodeAPIController) FindMethods(ctx *gin.Context) {
 
 	methods, err := c.api.Reader().Repo(req.RepoName).FindMethods(ctx.Request.Context(), filter)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 	ctx.JSON(http.StatusOK, gin.H{"methods": methods})
 }
 
+// SearchSymbols finds symbols by name or docstring using the full-text index
+func (c *CodeAPIController) SearchSymbols(ctx *gin.Context) {
+	var req SearchSymbolsRequest
+	if err := bindRequest(ctx, &req); err != nil {
+		ctx.JSON(http.StatusBadRequest, invalidRequestBody(err))
+		return
+	}
+	noteQuery(ctx, req.RepoName, req.Query)
+
+	switch req.Mode {
+	case "", "exact", "prefix", "substring", "fuzzy":
+	default:
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "mode must be one of exact, prefix, substring, fuzzy"})
+		return
+	}
+
+	sess, err := requestSession(ctx, c.sessions)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	filter := codeapi.SymbolSearchFilter{
+		Query:     req.Query,
+		Mode:      req.Mode,
+		NodeTypes: req.NodeTypes,
+		Limit:     req.Limit,
+	}
+
+	symbols, err := c.api.Reader().Repo(req.RepoName).SearchSymbols(ctx.Request.Context(), filter)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	if sess != nil {
+		symbols = session.Order(sess, req.SeenMode, symbols, func(m *codeapi.SymbolMatch) (string, string) {
+			return nodeKey(m.ID)
+		})
+		for _, m := range symbols {
+			sess.Record(nodeItem("symbols/search", m.ID, m.Name, m.FilePath))
+		}
+	}
+	noteResults(ctx, len(symbols))
+	ctx.JSON(http.StatusOK, gin.H{"symbols": symbols})
+}
+
+// SearchStrings finds where a log message, error string or URL comes from. A message with
+// runtime values filled in still finds its format string, best match first.
+func (c *CodeAPIController) SearchStrings(ctx *gin.Context) {
+	var req SearchStringsRequest
+	if err := bindRequest(ctx, &req); err != nil {
+		ctx.JSON(http.StatusBadRequest, invalidRequestBody(err))
+		return
+	}
+	noteQuery(ctx, req.RepoName, req.Query)
+
+	filter := codeapi.StringSearchFilter{
+		Query:    req.Query,
+		MatchAll: req.MatchAll,
+		Kind:     req.Kind,
+		Limit:    req.Limit,
+	}
+	matches, err := c.api.Reader().Repo(req.RepoName).SearchStrings(ctx.Request.Context(), filter)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	noteResults(ctx, len(matches))
+	ctx.JSON(http.StatusOK, gin.H{"strings": matches})
+}
+
+// NodeAtPosition returns the innermost node containing a file position, e.g. the function
+// an editor cursor is in
+func (c *CodeAPIController) NodeAtPosition(ctx *gin.Context) {
+	var req NodeAtPositionRequest
+	if err := bindRequest(ctx, &req); err != nil {
+		ctx.JSON(http.StatusBadRequest, invalidRequestBody(err))
+		return
+	}
+
+	sess, err := requestSession(ctx, c.sessions)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	pos := base.Position{Line: req.Line, Character: req.Character}
+	node, err := c.api.Reader().Repo(req.RepoName).NodeAtPosition(ctx.Request.Context(), req.FilePath, pos, req.NodeTypes...)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	if sess != nil {
+		sess.Record(nodeItem("nodes/at", node.ID, node.Name, node.FilePath))
+	}
+	ctx.JSON(http.StatusOK, gin.H{"node": node})
+}
+
+// FindConstants finds where a constant, magic value or enum member is defined and,
+// optionally, where it is used
+func (c *CodeAPIController) FindConstants(ctx *gin.Context) {
+	var req FindConstantsRequest
+	if err := bindRequest(ctx, &req); err != nil {
+		ctx.JSON(http.StatusBadRequest, invalidRequestBody(err))
+		return
+	}
+	if req.Name == "" && req.Value == "" && req.Enum == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "one of name, value or enum is required"})
+		return
+	}
+
+	sess, err := requestSession(ctx, c.sessions)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	filter := codeapi.ConstantFilter{
+		Name:        req.Name,
+		Value:       req.Value,
+		Enum:        req.Enum,
+		IncludeUses: req.IncludeUses,
+		Limit:       req.Limit,
+	}
+	constants, err := c.api.Reader().Repo(req.RepoName).FindConstants(ctx.Request.Context(), filter)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	if sess != nil {
+		for _, constant := range constants {
+			sess.Record(nodeItem("constants/find", constant.ID, constant.Name, constant.FilePath))
+		}
+	}
+	ctx.JSON(http.StatusOK, gin.H{"constants": constants})
+}
+
 // GetClass returns a class by ID
 func (c *CodeAPIController) GetClass(ctx *gin.Context) {
 	var req GetClassRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := bindRequest(ctx, &req); err != nil {
+		ctx.JSON(http.StatusBadRequest, invalidRequestBody(err))
+		return
+	}
+
+	sess, err := requestSession(ctx, c.sessions)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
 	repo := c.api.Reader().Repo(req.RepoName)
 	var class *codeapi.ClassInfo
-	var err error
 
 	if req.IncludeMethods || req.IncludeFields {
 		class, err = repo.GetClassFull(ctx.Request.Context(), ast.NodeID(req.ClassID), codeapi.LoadOptions{
@@ -284,39 +597,51 @@ func (c *CodeAPIController) GetClass(ctx *gin.Context) {
 	}
 
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
+	if sess != nil {
+		sess.Record(nodeItem("class", class.ID, class.Name, class.FilePath))
+	}
 	ctx.JSON(http.StatusOK, gin.H{"class": class})
 }
 
 // GetMethod returns a method by ID
 func (c *CodeAPIController) GetMethod(ctx *gin.Context) {
 	var req GetMethodRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := bindRequest(ctx, &req); err != nil {
+		ctx.JSON(http.StatusBadRequest, invalidRequestBody(err))
+		return
+	}
+
+	sess, err := requestSession(ctx, c.sessions)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
 	method, err := c.api.Reader().Repo(req.RepoName).GetMethod(ctx.Request.Context(), ast.NodeID(req.MethodID))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
+	if sess != nil {
+		sess.Record(nodeItem("method", method.ID, method.Name, method.FilePath))
+	}
 	ctx.JSON(http.StatusOK, gin.H{"method": method})
 }
 
 // GetClassMethods returns methods belonging to a class
 func (c *CodeAPIController) GetClassMethods(ctx *gin.Context) {
 	var req GetClassRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := bindRequest(ctx, &req); err != nil {
+		ctx.JSON(http.StatusBadRequest, invalidRequestBody(err))
 		return
 	}
 
 	methods, err := c.api.Reader().Repo(req.RepoName).GetClassMethods(ctx.Request.Context(), ast.NodeID(req.ClassID))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 	ctx.JSON(http.StatusOK, gin.H{"methods": methods})
@@ -325,14 +650,14 @@ func (c *CodeAPIController) GetClassMethods(ctx *gin.Context) {
 // GetClassFields returns fields belonging to a class
 func (c *CodeAPIController) GetClassFields(ctx *gin.Context) {
 	var req GetClassRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := bindRequest(ctx, &req); err != nil {
+		ctx.JSON(http.StatusBadRequest, invalidRequestBody(err))
 		return
 	}
 
 	fields, err := c.api.Reader().Repo(req.RepoName).GetClassFields(ctx.Request.Context(), ast.NodeID(req.ClassID))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 	ctx.JSON(http.StatusOK, gin.H{"fields": fields})
@@ -345,15 +670,12 @@ func (c *CodeAPIController) GetClassFields(ctx *gin.Context) {
 // GetCallGraph returns the call graph for a function
 func (c *CodeAPIController) GetCallGraph(ctx *gin.Context) {
 	var req GetCallGraphRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := bindRequest(ctx, &req); err != nil {
+		ctx.JSON(http.StatusBadRequest, invalidRequestBody(err))
 		return
 	}
+	noteQuery(ctx, req.RepoName, graphQuery(req.FunctionID, req.FilePath, req.ClassName, req.FunctionName))
 
-	// Set defaults
-	if req.MaxDepth <= 0 {
-		req.MaxDepth = 3
-	}
 	direction := codeapi.DirectionOutgoing
 	switch req.Direction {
 	case "incoming":
@@ -361,12 +683,7 @@ func (c *CodeAPIController) GetCallGraph(ctx *gin.Context) {
 	case "both":
 		direction = codeapi.DirectionBoth
 	}
-
-	opts := codeapi.CallGraphOptions{
-		Direction:       direction,
-		MaxDepth:        req.MaxDepth,
-		IncludeExternal: req.IncludeExternal,
-	}
+	opts := callGraphOptions(&req, direction)
 
 	var callGraph *codeapi.CallGraph
 	var err error
@@ -385,67 +702,73 @@ func (c *CodeAPIController) GetCallGraph(ctx *gin.Context) {
 	}
 
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
-	ctx.JSON(http.StatusOK, gin.H{"call_graph": callGraph})
+	c.respondCallGraph(ctx, callGraph, req.ResponseBudget)
+}
+
+// respondCallGraph writes a call graph response, cut to the request's token budget if any
+func (c *CodeAPIController) respondCallGraph(ctx *gin.Context, callGraph *codeapi.CallGraph, budget ResponseBudget) {
+	noteResults(ctx, len(callGraph.Edges))
+	var info *BudgetInfo
+	if tokens := budget.tokens(); tokens > 0 {
+		callGraph, info = budgetCallGraph(callGraph, tokens)
+	}
+	ctx.JSON(http.StatusOK, withQueryPlans(ctx, withBudget(gin.H{"call_graph": callGraph}, info)))
 }
 
 // GetCallers returns functions that call the specified function
 func (c *CodeAPIController) GetCallers(ctx *gin.Context) {
 	var req GetCallGraphRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := bindRequest(ctx, &req); err != nil {
+		ctx.JSON(http.StatusBadRequest, invalidRequestBody(err))
 		return
 	}
+	noteQuery(ctx, req.RepoName, graphQuery(req.FunctionID, req.FilePath, req.ClassName, req.FunctionName))
 
 	if req.FunctionID == 0 {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "function_id is required"})
 		return
 	}
 
-	if req.MaxDepth <= 0 {
-		req.MaxDepth = 3
-	}
-
-	callGraph, err := c.api.Analyzer().GetCallers(ctx.Request.Context(), ast.NodeID(req.FunctionID), req.MaxDepth)
+	opts := callGraphOptions(&req, codeapi.DirectionIncoming)
+	callGraph, err := c.api.Analyzer().GetCallGraph(ctx.Request.Context(), ast.NodeID(req.FunctionID), opts)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
-	ctx.JSON(http.StatusOK, gin.H{"call_graph": callGraph})
+	c.respondCallGraph(ctx, callGraph, req.ResponseBudget)
 }
 
 // GetCallees returns functions called by the specified function
 func (c *CodeAPIController) GetCallees(ctx *gin.Context) {
 	var req GetCallGraphRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := bindRequest(ctx, &req); err != nil {
+		ctx.JSON(http.StatusBadRequest, invalidRequestBody(err))
 		return
 	}
+	noteQuery(ctx, req.RepoName, graphQuery(req.FunctionID, req.FilePath, req.ClassName, req.FunctionName))
 
 	if req.FunctionID == 0 {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": "function_id is required"})
 		return
 	}
 
-	if req.MaxDepth <= 0 {
-		req.MaxDepth = 3
-	}
-
-	callGraph, err := c.api.Analyzer().GetCallees(ctx.Request.Context(), ast.NodeID(req.FunctionID), req.MaxDepth)
+	opts := callGraphOptions(&req, codeapi.DirectionOutgoing)
+	callGraph, err := c.api.Analyzer().GetCallGraph(ctx.Request.Context(), ast.NodeID(req.FunctionID), opts)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
-	ctx.JSON(http.StatusOK, gin.H{"call_graph": callGraph})
+	c.respondCallGraph(ctx, callGraph, req.ResponseBudget)
 }
 
 // GetDataDependents returns nodes that depend on a value
 func (c *CodeAPIController) GetDataDependents(ctx *gin.Context) {
 	var req GetDataDependentsRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := bindRequest(ctx, &req); err != nil {
+		ctx.JSON(http.StatusBadRequest, invalidRequestBody(err))
 		return
 	}
 
@@ -471,17 +794,21 @@ func (c *CodeAPIController) GetDataDependents(ctx *gin.Context) {
 	}
 
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
-	ctx.JSON(http.StatusOK, gin.H{"dependency_graph": graph})
+	var budget *BudgetInfo
+	if tokens := req.tokens(); tokens > 0 {
+		graph, budget = budgetDependencyGraph(graph, tokens)
+	}
+	ctx.JSON(http.StatusOK, withQueryPlans(ctx, withBudget(gin.H{"dependency_graph": graph}, budget)))
 }
 
 // GetDataSources returns nodes that contribute to a value
 func (c *CodeAPIController) GetDataSources(ctx *gin.Context) {
 	var req GetDataDependentsRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := bindRequest(ctx, &req); err != nil {
+		ctx.JSON(http.StatusBadRequest, invalidRequestBody(err))
 		return
 	}
 
@@ -497,19 +824,24 @@ func (c *CodeAPIController) GetDataSources(ctx *gin.Context) {
 
 	graph, err := c.api.Analyzer().GetDataSources(ctx.Request.Context(), ast.NodeID(req.NodeID), opts)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
-	ctx.JSON(http.StatusOK, gin.H{"dependency_graph": graph})
+	var budget *BudgetInfo
+	if tokens := req.tokens(); tokens > 0 {
+		graph, budget = budgetDependencyGraph(graph, tokens)
+	}
+	ctx.JSON(http.StatusOK, withQueryPlans(ctx, withBudget(gin.H{"dependency_graph": graph}, budget)))
 }
 
 // GetImpact returns impact analysis for a node
 func (c *CodeAPIController) GetImpact(ctx *gin.Context) {
 	var req GetImpactRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := bindRequest(ctx, &req); err != nil {
+		ctx.JSON(http.StatusBadRequest, invalidRequestBody(err))
 		return
 	}
+	noteQuery(ctx, req.RepoName, graphQuery(req.NodeID, req.FilePath, "", req.Name))
 
 	if req.MaxDepth <= 0 {
 		req.MaxDepth = 3
@@ -520,6 +852,19 @@ func (c *CodeAPIController) GetImpact(ctx *gin.Context) {
 		IncludeCallGraph: req.IncludeCallGraph,
 		IncludeDataFlow:  req.IncludeDataFlow,
 	}
+	if req.ModifiedSinceDays > 0 {
+		opts.ModifiedSince = time.Now().AddDate(0, 0, -req.ModifiedSinceDays)
+	}
+	scoring := req.Scoring.scoring()
+	opts.Scoring = &scoring
+	if req.Project != "" {
+		repos, err := projectRepos(c.config, req.Project, req.RepoName)
+		if err != nil {
+			ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		opts.ProjectRepos = repos
+	}
 
 	var impact *codeapi.ImpactResult
 	var err error
@@ -547,26 +892,31 @@ func (c *CodeAPIController) GetImpact(ctx *gin.Context) {
 	}
 
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
-	ctx.JSON(http.StatusOK, gin.H{"impact": impact})
+	noteResults(ctx, len(impact.AffectedNodes))
+	var budget *BudgetInfo
+	if tokens := req.tokens(); tokens > 0 {
+		impact, budget = budgetImpact(impact, tokens)
+	}
+	ctx.JSON(http.StatusOK, withQueryPlans(ctx, withBudget(gin.H{"impact": impact}, budget)))
 }
 
 // GetInheritanceTree returns the inheritance hierarchy for a class
 func (c *CodeAPIController) GetInheritanceTree(ctx *gin.Context) {
 	var req GetClassRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := bindRequest(ctx, &req); err != nil {
+		ctx.JSON(http.StatusBadRequest, invalidRequestBody(err))
 		return
 	}
 
 	tree, err := c.api.Analyzer().GetInheritanceTree(ctx.Request.Context(), ast.NodeID(req.ClassID))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
-	ctx.JSON(http.StatusOK, gin.H{"inheritance_tree": tree})
+	ctx.JSON(http.StatusOK, withQueryPlans(ctx, gin.H{"inheritance_tree": tree}))
 }
 
 // GetFieldAccessors returns methods that access a field
@@ -579,8 +929,8 @@ func (c *CodeAPIController) GetFieldAccessors(ctx *gin.Context) {
 	}
 
 	var req FieldAccessorsRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := bindRequest(ctx, &req); err != nil {
+		ctx.JSON(http.StatusBadRequest, invalidRequestBody(err))
 		return
 	}
 
@@ -600,10 +950,111 @@ func (c *CodeAPIController) GetFieldAccessors(ctx *gin.Context) {
 	}
 
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, withQueryPlans(ctx, gin.H{"field_accessors": result}))
+}
+
+// FindDuplicateFunctions groups functions with identical normalized AST fingerprints
+func (c *CodeAPIController) FindDuplicateFunctions(ctx *gin.Context) {
+	var req FindDuplicatesRequest
+	if err := bindRequest(ctx, &req); err != nil {
+		ctx.JSON(http.StatusBadRequest, invalidRequestBody(err))
+		return
+	}
+
+	opts := codeapi.DefaultDuplicateOptions()
+	if req.MinSize > 0 {
+		opts.MinSize = req.MinSize
+	}
+	if req.MinCount > 0 {
+		opts.MinCount = req.MinCount
+	}
+	opts.Limit = req.Limit
+
+	groups, err := c.api.Analyzer().FindDuplicateFunctions(ctx.Request.Context(), req.RepoName, opts)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"duplicates": groups})
+}
+
+// GetNodeCommits returns the commits that changed a FileScope, Function or Class node, with
+// their messages and pull requests
+func (c *CodeAPIController) GetNodeCommits(ctx *gin.Context) {
+	var req GetNodeCommitsRequest
+	if err := bindRequest(ctx, &req); err != nil {
+		ctx.JSON(http.StatusBadRequest, invalidRequestBody(err))
+		return
+	}
+
+	commits, err := c.api.Analyzer().GetNodeCommits(ctx.Request.Context(), req.RepoName, ast.NodeID(req.NodeID), req.Limit)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"commits": commits})
+}
+
+// ResolveErrorLocation returns the code locations a log line or stack trace most likely comes
+// from, ranked by confidence
+func (c *CodeAPIController) ResolveErrorLocation(ctx *gin.Context) {
+	var req ResolveErrorLocationRequest
+	if err := bindRequest(ctx, &req); err != nil {
+		ctx.JSON(http.StatusBadRequest, invalidRequestBody(err))
+		return
+	}
+
+	resolution, err := c.api.Analyzer().ResolveErrorLocation(ctx.Request.Context(), req.RepoName, req.Text, req.Limit)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, resolution)
+}
+
+// -----------------------------------------------------------------------------
+// Module Endpoints
+// -----------------------------------------------------------------------------
+
+// ListModules returns the modules (directories) of a repository with size metrics
+func (c *CodeAPIController) ListModules(ctx *gin.Context) {
+	var req ListModulesRequest
+	if err := bindRequest(ctx, &req); err != nil {
+		ctx.JSON(http.StatusBadRequest, invalidRequestBody(err))
+		return
+	}
+
+	modules, err := c.api.Modules().ListModules(ctx.Request.Context(), req.RepoName)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"modules": modules})
+}
+
+// GetModuleSummary returns a module's exported symbols, dependencies and dependents
+func (c *CodeAPIController) GetModuleSummary(ctx *gin.Context) {
+	var req GetModuleSummaryRequest
+	if err := bindRequest(ctx, &req); err != nil {
+		ctx.JSON(http.StatusBadRequest, invalidRequestBody(err))
+		return
+	}
+
+	summary, err := c.api.Modules().GetModuleSummary(ctx.Request.Context(), req.RepoName, req.Path)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	if tokens := req.tokens(); tokens > 0 {
+		var budget *BudgetInfo
+		summary, budget = budgetModuleSummary(summary, tokens)
+		ctx.JSON(http.StatusOK, budgetedModuleSummary{ModuleSummary: summary, Budget: budget})
 		return
 	}
-	ctx.JSON(http.StatusOK, gin.H{"field_accessors": result})
+	ctx.JSON(http.StatusOK, summary)
 }
 
 // -----------------------------------------------------------------------------
@@ -613,14 +1064,14 @@ func (c *CodeAPIController) GetFieldAccessors(ctx *gin.Context) {
 // ExecuteCypher executes a raw read-only Cypher query
 func (c *CodeAPIController) ExecuteCypher(ctx *gin.Context) {
 	var req ExecuteCypherRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := bindRequest(ctx, &req); err != nil {
+		ctx.JSON(http.StatusBadRequest, invalidRequestBody(err))
 		return
 	}
 
 	results, err := c.api.ExecuteCypher(ctx.Request.Context(), req.Query, req.Params)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 	ctx.JSON(http.StatusOK, gin.H{"results": results})
@@ -629,14 +1080,14 @@ func (c *CodeAPIController) ExecuteCypher(ctx *gin.Context) {
 // ExecuteCypherWrite executes a raw write Cypher query
 func (c *CodeAPIController) ExecuteCypherWrite(ctx *gin.Context) {
 	var req ExecuteCypherRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := bindRequest(ctx, &req); err != nil {
+		ctx.JSON(http.StatusBadRequest, invalidRequestBody(err))
 		return
 	}
 
 	results, err := c.api.ExecuteCypherWrite(ctx.Request.Context(), req.Query, req.Params)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 	ctx.JSON(http.StatusOK, gin.H{"results": results})
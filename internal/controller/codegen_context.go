@@ -0,0 +1,244 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"bot-go/internal/apperrors"
+	"bot-go/internal/config"
+	"bot-go/internal/model"
+	"bot-go/internal/parse"
+	"bot-go/internal/service/codegraph"
+	"bot-go/internal/service/ngram"
+	"bot-go/internal/util"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultCodegenSiblings  = 30
+	defaultCodegenSimilar   = 5
+	codegenDirectoryImports = 20 // most common imports of the directory listed
+	codegenDirectoryFiles   = 50 // files of the directory read for their imports
+)
+
+// CodegenContextRequest asks for the conventions code added to a file should follow
+type CodegenContextRequest struct {
+	FilePath     string `json:"file_path" binding:"required"`   // Relative to the repository root; the file may not exist yet
+	Description  string `json:"description" binding:"required"` // What is being added, e.g. "retry failed webhook deliveries"
+	SiblingLimit int    `json:"sibling_limit"`                  // Sibling function signatures listed (default 30)
+	SimilarLimit int    `json:"similar_limit"`                  // Similar existing functions listed (default 5)
+}
+
+// CodegenContextResponse is the local style of a file: what it imports, the shape of the
+// functions next to it, how natural the code of its directory is and the existing functions
+// closest to what is being added
+type CodegenContextResponse struct {
+	RepoName string   `json:"repo_name"`
+	FilePath string   `json:"file_path"`
+	Exists   bool     `json:"exists"`
+	Imports  []string `json:"imports"` // Of the file, as written; empty for a new file
+	// Most common imports of the other files of the directory with the same extension
+	DirectoryImports []CodegenImport            `json:"directory_imports"`
+	Siblings         []codegraph.SignatureMatch `json:"siblings"`
+	SiblingsFrom     string                     `json:"siblings_from"` // "file", or "directory" when the file has no indexed functions
+	Naturalness      *ngram.DirectoryProfile    `json:"naturalness,omitempty"`
+	Similar          []CodegenSimilarResult     `json:"similar,omitempty"`
+	// Context left out because its service is not running, with the reason
+	Unavailable map[string]string `json:"unavailable,omitempty"`
+}
+
+// CodegenImport is an import shared by files of a directory
+type CodegenImport struct {
+	Import string `json:"import"`
+	Files  int    `json:"files"`
+}
+
+// CodegenSimilarResult is an existing function similar to the description of the added code
+type CodegenSimilarResult struct {
+	Chunk         *model.CodeChunk `json:"chunk"`
+	Score         float32          `json:"score"`
+	SameDirectory bool             `json:"same_directory"`
+}
+
+// CodegenContext returns the conventions context of code about to be added to a file, so that
+// code generation agents match the local style: the imports of the file and its directory, the
+// signatures of the functions next to it, the naturalness profile of its directory and the
+// existing functions most similar to the description. Naturalness and similar code are left
+// out, and listed under "unavailable", when their services are down.
+func (rc *RepoController) CodegenContext(c *gin.Context) {
+	var req CodegenContextRequest
+	if err := bindRequest(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, invalidRequestBody(err))
+		return
+	}
+	repo, err := rc.config.GetRepository(c.Param("name"))
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	if rc.codeGraph == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "code graph is not enabled"})
+		return
+	}
+	if req.SiblingLimit <= 0 {
+		req.SiblingLimit = defaultCodegenSiblings
+	}
+	if req.SimilarLimit <= 0 {
+		req.SimilarLimit = defaultCodegenSimilar
+	}
+
+	ctx := c.Request.Context()
+	filePath := util.CanonicalPath(req.FilePath)
+	response := CodegenContextResponse{RepoName: repo.Name, FilePath: filePath, Imports: []string{}}
+	unavailable := make(map[string]string)
+
+	content, err := os.ReadFile(filepath.Join(repo.Path, filepath.FromSlash(filePath)))
+	switch {
+	case err == nil:
+		response.Exists = true
+		if imports, err := parse.Imports(filePath, content); err == nil {
+			response.Imports = imports
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file", "details": err.Error()})
+		return
+	}
+	response.DirectoryImports = directoryImports(repo, filePath)
+
+	siblings, own, err := rc.codeGraph.SiblingSignatures(ctx, repo.Name, filePath, req.SiblingLimit)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	if siblings == nil {
+		siblings = []codegraph.SignatureMatch{}
+	}
+	response.Siblings, response.SiblingsFrom = siblings, "directory"
+	if own {
+		response.SiblingsFrom = "file"
+	}
+
+	if rc.ngramService == nil {
+		unavailable[ServiceNgram] = rc.unavailableReason(ServiceNgram, "")
+	} else {
+		dir := filepath.Join(repo.Path, filepath.FromSlash(path.Dir(filePath)))
+		profile, err := rc.ngramService.GetDirectoryProfile(ctx, repo.Name, dir)
+		if err != nil {
+			unavailable[ServiceNgram] = rc.unavailableReason(ServiceNgram, "no n-gram corpus for the repository; run /processNGram")
+		} else {
+			profile.Directory = path.Dir(filePath)
+			response.Naturalness = profile
+		}
+	}
+
+	if rc.chunkService == nil {
+		unavailable[ServiceVectorSearch] = rc.unavailableReason(ServiceVectorSearch, "")
+	} else {
+		similar, err := rc.codegenSimilar(ctx, repo, filePath, req)
+		if errors.Is(err, apperrors.ErrEmbeddingUnavailable) {
+			unavailable[ServiceVectorSearch] = err.Error()
+		} else if err != nil {
+			rc.log(c).Warn("Failed to search code similar to the description",
+				zap.String("repo_name", repo.Name),
+				zap.String("file_path", filePath),
+				zap.Error(err))
+		}
+		response.Similar = similar
+	}
+	if len(unavailable) > 0 {
+		response.Unavailable = unavailable
+	}
+
+	noteResults(c, len(response.Siblings)+len(response.Similar))
+	c.JSON(http.StatusOK, response)
+}
+
+// codegenSimilar returns the indexed functions most similar to the description of the added
+// code, in order of similarity
+func (rc *RepoController) codegenSimilar(ctx context.Context, repo *config.Repository, filePath string, req CodegenContextRequest) ([]CodegenSimilarResult, error) {
+	filter := map[string]interface{}{"chunk_type": string(model.ChunkTypeFunction)}
+	chunks, scores, err := rc.chunkService.SearchSimilarCode(ctx, repo.Name, req.Description, req.SimilarLimit, filter, true)
+	if err != nil {
+		return nil, err
+	}
+	results := []CodegenSimilarResult{}
+	for i, chunk := range chunks {
+		chunkPath := chunk.FilePath
+		if filepath.IsAbs(chunkPath) {
+			if rel, err := filepath.Rel(repo.Path, chunkPath); err == nil {
+				chunkPath = filepath.ToSlash(rel)
+			}
+		}
+		results = append(results, CodegenSimilarResult{
+			Chunk:         chunk,
+			Score:         scores[i],
+			SameDirectory: path.Dir(chunkPath) == path.Dir(filePath),
+		})
+	}
+	return results, nil
+}
+
+// directoryImports counts the imports of up to codegenDirectoryFiles other files of the
+// directory of filePath with its extension, most common first. Files that cannot be read
+// or parsed are skipped.
+func directoryImports(repo *config.Repository, filePath string) []CodegenImport {
+	dir := filepath.Join(repo.Path, filepath.FromSlash(path.Dir(filePath)))
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return []CodegenImport{}
+	}
+	counts := make(map[string]int)
+	read := 0
+	for _, entry := range entries {
+		if read == codegenDirectoryFiles {
+			break
+		}
+		name := entry.Name()
+		if entry.IsDir() || name == path.Base(filePath) || filepath.Ext(name) != path.Ext(filePath) {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		imports, err := parse.Imports(name, content)
+		if err != nil {
+			continue
+		}
+		read++
+		seen := make(map[string]bool)
+		for _, imp := range imports {
+			if !seen[imp] {
+				seen[imp] = true
+				counts[imp]++
+			}
+		}
+	}
+	return topImports(counts, codegenDirectoryImports)
+}
+
+// topImports returns up to limit imports, most common first
+func topImports(counts map[string]int, limit int) []CodegenImport {
+	imports := make([]CodegenImport, 0, len(counts))
+	for imp, files := range counts {
+		imports = append(imports, CodegenImport{Import: imp, Files: files})
+	}
+	sort.Slice(imports, func(i, j int) bool {
+		if imports[i].Files != imports[j].Files {
+			return imports[i].Files > imports[j].Files
+		}
+		return imports[i].Import < imports[j].Import
+	})
+	if len(imports) > limit {
+		imports = imports[:limit]
+	}
+	return imports
+}
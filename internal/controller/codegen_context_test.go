@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"bot-go/internal/config"
+)
+
+func TestDirectoryImports(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "pkg")
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"a.go":      "package pkg\n\nimport (\n\t\"context\"\n\t\"fmt\"\n)\n",
+		"b.go":      "package pkg\n\nimport \"context\"\nimport \"context\"\n",
+		"target.go": "package pkg\n\nimport \"os\"\n",
+		"c.py":      "import os\n",
+		"sub/d.go":  "package sub\n\nimport \"strings\"\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, filepath.FromSlash(name)), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := directoryImports(&config.Repository{Path: root}, "pkg/target.go")
+	want := []CodegenImport{{Import: `"context"`, Files: 2}, {Import: `"fmt"`, Files: 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("directoryImports = %+v, want %+v", got, want)
+	}
+
+	if got := directoryImports(&config.Repository{Path: root}, "missing/new.go"); len(got) != 0 {
+		t.Errorf("directoryImports of a missing directory = %+v, want none", got)
+	}
+}
@@ -2,6 +2,8 @@ package controller
 
 import (
 	"bot-go/internal/config"
+	"bot-go/internal/logging"
+	"bot-go/internal/model/ast"
 	"bot-go/internal/parse"
 	"bot-go/internal/service"
 	"bot-go/internal/service/codegraph"
@@ -52,7 +54,7 @@ func (cgp *CodeGraphProcessor) ProcessFile(ctx context.Context, repo *config.Rep
 		return nil
 	}
 
-	cgp.logger.Debug("Parsing file for code graph",
+	cgp.log(ctx).Debug("Parsing file for code graph",
 		zap.String("path", fileCtx.FilePath),
 		zap.Int32("file_id", fileCtx.FileID),
 		zap.String("sha", fileCtx.FileSHA),
@@ -65,9 +67,15 @@ func (cgp *CodeGraphProcessor) ProcessFile(ctx context.Context, repo *config.Rep
 	// Use FileID from FileContext (already generated by IndexBuilder)
 	version := int32(1) // Default version
 
-	err := fileParser.ParseAndTraverseWithContent(ctx, repo, info, fileCtx.FilePath, fileCtx.FileID, version, fileCtx.Content)
+	var err error
+	if fileCtx.LightweightReason != "" {
+		// Large or generated file: record the file without parsing it
+		err = fileParser.CreateFileScopeOnly(ctx, repo, info, fileCtx.FilePath, fileCtx.FileID, version, fileCtx.CommitSHA(), fileCtx.Content, fileCtx.LightweightReason)
+	} else {
+		err = fileParser.ParseAndTraverseWithContent(ctx, repo, info, fileCtx.FilePath, fileCtx.FileID, version, fileCtx.CommitSHA(), fileCtx.Content)
+	}
 	if err != nil {
-		cgp.logger.Error("Failed to parse file for code graph",
+		cgp.log(ctx).Error("Failed to parse file for code graph",
 			zap.String("path", fileCtx.FilePath),
 			zap.Int32("file_id", fileCtx.FileID),
 			zap.Error(err))
@@ -76,30 +84,49 @@ func (cgp *CodeGraphProcessor) ProcessFile(ctx context.Context, repo *config.Rep
 		return nil // Continue processing other files
 	}
 
+	// Record which submodule commit the file was indexed from
+	if fileCtx.Submodule != "" {
+		err := cgp.codeGraph.UpdateNodeMetaData(ctx, ast.NodeID(fileCtx.FileID), fileCtx.FileID, map[string]any{
+			"submodule":        fileCtx.Submodule,
+			"submodule_commit": fileCtx.SubmoduleCommit,
+		})
+		if err != nil {
+			cgp.log(ctx).Warn("Failed to record submodule on file scope",
+				zap.String("path", fileCtx.FilePath),
+				zap.String("submodule", fileCtx.Submodule),
+				zap.Error(err))
+		}
+	}
+
 	// Cleanup: flush remaining data and remove buffers for this file
 	// This ensures data is written to DB and memory is freed
 	if err := cgp.codeGraph.CleanupFileBuffers(ctx, fileCtx.FileID); err != nil {
-		cgp.logger.Error("Failed to cleanup code graph buffers after file processing",
+		cgp.log(ctx).Error("Failed to cleanup code graph buffers after file processing",
 			zap.String("path", fileCtx.FilePath),
 			zap.Int32("file_id", fileCtx.FileID),
 			zap.Error(err))
 		return nil // Continue processing other files
 	}
 
-	cgp.logger.Debug("Successfully parsed file for code graph",
+	cgp.log(ctx).Debug("Successfully parsed file for code graph",
 		zap.String("path", fileCtx.FilePath),
 		zap.Int32("file_id", fileCtx.FileID))
 	return nil
 }
 
+// FlushBuffers writes all buffered nodes and relations to the database (see BufferFlusher)
+func (cgp *CodeGraphProcessor) FlushBuffers(ctx context.Context) error {
+	return cgp.codeGraph.Flush(ctx, nil)
+}
+
 // PostProcess performs LSP-based post-processing on the repository
 func (cgp *CodeGraphProcessor) PostProcess(ctx context.Context, repo *config.Repository) error {
-	cgp.logger.Info("Running code graph post-processing", zap.String("repo_name", repo.Name))
+	cgp.log(ctx).Info("Running code graph post-processing", zap.String("repo_name", repo.Name))
 
 	// Flush any remaining buffered nodes and relations before post-processing
-	cgp.logger.Debug("Flushing all buffered nodes and relations before post-processing")
+	cgp.log(ctx).Debug("Flushing all buffered nodes and relations before post-processing")
 	if err := cgp.codeGraph.Flush(ctx, nil); err != nil {
-		cgp.logger.Error("Failed to flush code graph buffers",
+		cgp.log(ctx).Error("Failed to flush code graph buffers",
 			zap.String("repo_name", repo.Name),
 			zap.Error(err))
 		return err
@@ -108,22 +135,22 @@ func (cgp *CodeGraphProcessor) PostProcess(ctx context.Context, repo *config.Rep
 	postProcessor := NewPostProcessor(cgp.codeGraph, cgp.repoService.GetLspService(), cgp.logger)
 	err := postProcessor.PostProcessRepository(ctx, repo)
 	if err != nil {
-		cgp.logger.Error("Code graph post-processing failed",
+		cgp.log(ctx).Error("Code graph post-processing failed",
 			zap.String("repo_name", repo.Name),
 			zap.Error(err))
 		return err
 	}
 
 	// Flush again after post-processing (in case post-processor created new relations)
-	cgp.logger.Debug("Flushing all code graph buffers after post-processing")
+	cgp.log(ctx).Debug("Flushing all code graph buffers after post-processing")
 	if err := cgp.codeGraph.Flush(ctx, nil); err != nil {
-		cgp.logger.Error("Failed to flush code graph buffers after post-processing",
+		cgp.log(ctx).Error("Failed to flush code graph buffers after post-processing",
 			zap.String("repo_name", repo.Name),
 			zap.Error(err))
 		return err
 	}
 
-	cgp.logger.Info("Code graph post-processing completed", zap.String("repo_name", repo.Name))
+	cgp.log(ctx).Info("Code graph post-processing completed", zap.String("repo_name", repo.Name))
 	return nil
 }
 
@@ -137,3 +164,8 @@ func (d *dummyFileInfo) Mode() os.FileMode  { return 0 }
 func (d *dummyFileInfo) ModTime() time.Time { return time.Time{} }
 func (d *dummyFileInfo) IsDir() bool        { return false }
 func (d *dummyFileInfo) Sys() interface{}   { return nil }
+
+// log returns the logger with the request ID carried by ctx attached
+func (cgp *CodeGraphProcessor) log(ctx context.Context) *zap.Logger {
+	return logging.FromContext(ctx, cgp.logger)
+}
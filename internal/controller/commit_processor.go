@@ -0,0 +1,208 @@
+package controller
+
+import (
+	"bot-go/internal/config"
+	"bot-go/internal/logging"
+	"bot-go/internal/model/ast"
+	"bot-go/internal/service/codegraph"
+	gitutil "bot-go/internal/signals/util"
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Limits of the commit history ingestion
+const (
+	commitLookupBatch     = 100 // hashes per git log --no-walk call
+	maxPullRequestLookups = 200 // GitHub API calls per index build
+)
+
+// CommitProcessor implements FileProcessor for commit history ingestion. It creates Commit
+// nodes for the repository's recent history and links them with MODIFIED_IN relations to
+// the FileScopes they touched and to the Function and Class nodes whose current lines they
+// wrote (per git blame). It must run after CodeGraphProcessor.
+type CommitProcessor struct {
+	codeGraph  *codegraph.CodeGraph
+	gitConfig  config.GitAnalysisConfig
+	httpClient *http.Client
+	logger     *zap.Logger
+
+	// Node links collected per file, written in PostProcess once the commits exist
+	mu        sync.Mutex
+	nodeLinks []codegraph.CommitLink
+}
+
+// NewCommitProcessor creates a new commit history processor
+func NewCommitProcessor(codeGraph *codegraph.CodeGraph, gitConfig config.GitAnalysisConfig, logger *zap.Logger) *CommitProcessor {
+	return &CommitProcessor{
+		codeGraph:  codeGraph,
+		gitConfig:  gitConfig,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		logger:     logger,
+	}
+}
+
+// Name returns the processor name
+func (cp *CommitProcessor) Name() string {
+	return "Commits"
+}
+
+// ProcessFile blames the file and records which commits wrote each Function and Class node
+func (cp *CommitProcessor) ProcessFile(ctx context.Context, repo *config.Repository, fileCtx *FileContext) error {
+	if fileCtx.LightweightReason != "" {
+		return nil
+	}
+
+	nodes, err := cp.codeGraph.FindNodesInFile(ctx, fileCtx.FileID, ast.NodeTypeFunction, ast.NodeTypeClass)
+	if err != nil {
+		return fmt.Errorf("failed to read nodes of %s: %w", fileCtx.RelativePath, err)
+	}
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	analyzer := gitutil.NewOnDemandGitAnalyzer(repo.Path, 0)
+	lines, err := analyzer.GetBlame(ctx, fileCtx.FilePath, fileCtx.CommitSHA())
+	if err != nil {
+		cp.log(ctx).Debug("Skipping commit linkage for file",
+			zap.String("path", fileCtx.RelativePath),
+			zap.Error(err))
+		return nil
+	}
+
+	links := commitLinks(nodes, lines)
+	cp.mu.Lock()
+	cp.nodeLinks = append(cp.nodeLinks, links...)
+	cp.mu.Unlock()
+	return nil
+}
+
+// PostProcess reads the commit log, writes the Commit nodes and links them to the files and
+// nodes they changed
+func (cp *CommitProcessor) PostProcess(ctx context.Context, repo *config.Repository) error {
+	cp.mu.Lock()
+	nodeLinks := cp.nodeLinks
+	cp.nodeLinks = nil
+	cp.mu.Unlock()
+
+	analyzer := gitutil.NewOnDemandGitAnalyzer(repo.Path, cp.gitConfig.LookbackCommits)
+	history, err := analyzer.GetCommitLog(ctx, 0)
+	if err != nil {
+		return fmt.Errorf("failed to read commit log of %s: %w", repo.Name, err)
+	}
+
+	// Blamed commits can be older than the lookback window
+	known := make(map[string]bool, len(history))
+	for _, c := range history {
+		known[c.Hash] = true
+	}
+	var missing []string
+	for _, l := range nodeLinks {
+		if !known[l.Hash] {
+			known[l.Hash] = true
+			missing = append(missing, l.Hash)
+		}
+	}
+	for start := 0; start < len(missing); start += commitLookupBatch {
+		end := min(start+commitLookupBatch, len(missing))
+		older, err := analyzer.GetCommits(ctx, missing[start:end])
+		if err != nil {
+			cp.log(ctx).Warn("Failed to read blamed commits", zap.String("repo_name", repo.Name), zap.Error(err))
+			continue
+		}
+		history = append(history, older...)
+	}
+
+	commits := make([]codegraph.Commit, 0, len(history))
+	filesByCommit := make(map[string][]string, len(history))
+	prs := cp.pullRequestLookup(ctx, repo)
+	for _, c := range history {
+		commit := codegraph.Commit{
+			Hash:        c.Hash,
+			Author:      c.Author,
+			AuthorEmail: c.AuthorEmail,
+			Time:        c.Time.Unix(),
+			Subject:     c.Subject,
+			Message:     c.Body,
+			PRNumber:    gitutil.PullRequestNumber(c.Subject),
+		}
+		if pr := prs(commit.PRNumber); pr != nil {
+			commit.PRTitle = pr.Title
+			commit.PRURL = pr.URL
+		}
+		commits = append(commits, commit)
+		filesByCommit[c.Hash] = c.Files
+	}
+
+	if err := cp.codeGraph.UpsertCommits(ctx, repo.Name, commits); err != nil {
+		return err
+	}
+	fileLinks, err := cp.codeGraph.LinkCommitsToFiles(ctx, repo.Name, filesByCommit)
+	if err != nil {
+		return err
+	}
+	linked, err := cp.codeGraph.LinkCommitsToNodes(ctx, repo.Name, nodeLinks)
+	if err != nil {
+		return err
+	}
+
+	cp.log(ctx).Info("Commit history ingested",
+		zap.String("repo_name", repo.Name),
+		zap.Int("commits", len(commits)),
+		zap.Int("file_links", fileLinks),
+		zap.Int("node_links", linked))
+	return nil
+}
+
+// pullRequestLookup returns a function resolving pull request numbers to their GitHub
+// metadata, or one that always returns nil when the repository is not on GitHub. Lookups
+// are cached and capped per build; failures are logged and yield nil.
+func (cp *CommitProcessor) pullRequestLookup(ctx context.Context, repo *config.Repository) func(number int) *gitutil.PullRequestInfo {
+	if repo.GitHubRepo == "" {
+		return func(int) *gitutil.PullRequestInfo { return nil }
+	}
+	cache := make(map[int]*gitutil.PullRequestInfo)
+	return func(number int) *gitutil.PullRequestInfo {
+		if number == 0 {
+			return nil
+		}
+		if pr, ok := cache[number]; ok {
+			return pr
+		}
+		if len(cache) >= maxPullRequestLookups {
+			return nil
+		}
+		pr, err := gitutil.FetchPullRequest(ctx, cp.httpClient, repo.GitHubRepo, cp.gitConfig.GitHubToken, number)
+		if err != nil {
+			cp.log(ctx).Warn("Failed to fetch pull request", zap.String("repo_name", repo.Name), zap.Error(err))
+		}
+		cache[number] = pr
+		return pr
+	}
+}
+
+// commitLinks returns one link per node and distinct commit among the node's committed lines
+func commitLinks(nodes []*ast.Node, lines []gitutil.BlameLine) []codegraph.CommitLink {
+	var links []codegraph.CommitLink
+	for _, node := range nodes {
+		seen := make(map[string]bool)
+		for i := max(node.Range.Start.Line, 0); i <= node.Range.End.Line && i < len(lines); i++ {
+			hash := lines[i].CommitHash
+			if lines[i].Uncommitted() || seen[hash] {
+				continue
+			}
+			seen[hash] = true
+			links = append(links, codegraph.CommitLink{NodeID: node.ID, Hash: hash})
+		}
+	}
+	return links
+}
+
+// log returns the logger with the request ID carried by ctx attached
+func (cp *CommitProcessor) log(ctx context.Context) *zap.Logger {
+	return logging.FromContext(ctx, cp.logger)
+}
@@ -0,0 +1,27 @@
+package controller
+
+import (
+	"testing"
+
+	"bot-go/internal/model/ast"
+	gitutil "bot-go/internal/signals/util"
+	"bot-go/pkg/lsp/base"
+)
+
+func TestCommitLinks(t *testing.T) {
+	lines := []gitutil.BlameLine{
+		{Line: 1, CommitHash: "a"},
+		{Line: 2, CommitHash: "b"},
+		{Line: 3, CommitHash: "a"},
+		{Line: 4, CommitHash: "0000000000000000000000000000000000000000"},
+	}
+	nodes := []*ast.Node{
+		{ID: 1, Range: base.Range{Start: base.Position{Line: 0}, End: base.Position{Line: 3}}},
+		{ID: 2, Range: base.Range{Start: base.Position{Line: 3}, End: base.Position{Line: 3}}},
+	}
+
+	links := commitLinks(nodes, lines)
+	if len(links) != 2 || links[0].Hash != "a" || links[1].Hash != "b" || links[0].NodeID != 1 {
+		t.Errorf("expected node 1 linked to a and b only, got %+v", links)
+	}
+}
@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"bot-go/internal/service/completion"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const defaultCompletionLimit = 10
+
+// SetCompletionService enables symbol completion
+func (rc *RepoController) SetCompletionService(completions *completion.Service) {
+	rc.completions = completions
+}
+
+// Complete returns the functions, classes and fields of a repository whose name starts with
+// ?prefix= (ignoring case), most referenced first, with their kind and enclosing class.
+// ?limit= bounds the completions (default 10). Completions are served from an in-memory
+// index loaded on the first request and rebuilt after every index build.
+func (rc *RepoController) Complete(c *gin.Context) {
+	if rc.completions == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "code graph is not enabled"})
+		return
+	}
+	prefix, ok := c.GetQuery("prefix")
+	if !ok {
+		c.JSON(http.StatusBadRequest, invalidRequestBody(&ValidationError{
+			Fields: []FieldError{{Field: "prefix", Message: "is required"}},
+		}))
+		return
+	}
+	limit, ok := queryInt(c, "limit", defaultCompletionLimit, completion.MaxSuggestions)
+	if !ok {
+		return
+	}
+	repo, err := rc.config.GetRepository(c.Param("name"))
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	completions, err := rc.completions.Complete(c.Request.Context(), repo.Name, prefix, limit)
+	if err != nil {
+		rc.log(c).Error("Failed to complete symbol", zap.String("repo_name", repo.Name), zap.Error(err))
+		c.JSON(errorStatus(err), gin.H{"error": "Failed to load completions", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"repo_name":   repo.Name,
+		"prefix":      prefix,
+		"completions": completions,
+	})
+}
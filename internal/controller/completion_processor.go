@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"bot-go/internal/config"
+	"bot-go/internal/logging"
+	"bot-go/internal/service/completion"
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// CompletionProcessor implements FileProcessor for symbol completion: once the build has
+// resolved calls, it rebuilds the in-memory completion index of the repository so that
+// completions rank by the new reference counts. It has no per-file work.
+type CompletionProcessor struct {
+	service *completion.Service
+	logger  *zap.Logger
+}
+
+// NewCompletionProcessor creates a new completion processor
+func NewCompletionProcessor(service *completion.Service, logger *zap.Logger) *CompletionProcessor {
+	return &CompletionProcessor{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// Name returns the processor name
+func (cp *CompletionProcessor) Name() string {
+	return "Completion"
+}
+
+// ProcessFile does nothing; the index is rebuilt from the whole graph in Finalize
+func (cp *CompletionProcessor) ProcessFile(ctx context.Context, repo *config.Repository, fileCtx *FileContext) error {
+	return nil
+}
+
+// PostProcess does nothing; calls are not resolved until the code graph post-processing
+// completes (see Finalize)
+func (cp *CompletionProcessor) PostProcess(ctx context.Context, repo *config.Repository) error {
+	return nil
+}
+
+// Finalize rebuilds the completion index of the repository (see Finalizer)
+func (cp *CompletionProcessor) Finalize(ctx context.Context, repo *config.Repository) error {
+	if err := cp.service.Refresh(ctx, repo.Name); err != nil {
+		return fmt.Errorf("failed to refresh completion index: %w", err)
+	}
+	logging.FromContext(ctx, cp.logger).Debug("Completion index refreshed", zap.String("repo_name", repo.Name))
+	return nil
+}
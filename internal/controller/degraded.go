@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Optional subsystems: the server starts without them when they are unreachable, unless
+// they are listed in app.required_services
+const (
+	ServiceVectorSearch = "vector_search" // Qdrant and the embedding model
+	ServiceNgram        = "ngram"
+	ServiceLSP          = "lsp"
+	ServiceMySQL        = "mysql"
+	ServiceTextSearch   = "text_search" // trigram indexes of exact and regular expression search
+	ServiceCache        = "cache"       // the shared Redis cache; instances keep their caches in memory without it
+)
+
+// OptionalServices lists the subsystems app.required_services may name
+var OptionalServices = []string{ServiceVectorSearch, ServiceNgram, ServiceLSP, ServiceMySQL, ServiceTextSearch, ServiceCache}
+
+// UnavailableServices maps the optional subsystems that failed to start to the reason. A
+// server with unavailable subsystems runs in degraded mode.
+type UnavailableServices map[string]string
+
+// serviceUnavailable responds 503 to a request that needs a subsystem the server runs
+// without, with the reason it is unavailable
+func serviceUnavailable(c *gin.Context, unavailable UnavailableServices, service, message string) {
+	reason, ok := unavailable[service]
+	if !ok {
+		reason = "not enabled in the configuration"
+	}
+	c.JSON(http.StatusServiceUnavailable, gin.H{
+		"error":   message,
+		"service": service,
+		"reason":  reason,
+	})
+}
+
+// unavailableReason returns why a subsystem is left out of a response: reason when given,
+// else why it failed to start
+func (rc *RepoController) unavailableReason(service, reason string) string {
+	if reason == "" {
+		reason = rc.unavailable[service]
+	}
+	if reason == "" {
+		reason = "not enabled in the configuration"
+	}
+	return reason
+}
+
+// SetUnavailableServices records the subsystems the server started without, for the 503
+// responses of the endpoints that need them and the health check
+func (rc *RepoController) SetUnavailableServices(unavailable UnavailableServices) {
+	rc.unavailable = unavailable
+}
+
+// Health reports whether the server is healthy or running in degraded mode, and why
+func (rc *RepoController) Health(c *gin.Context) {
+	if len(rc.unavailable) == 0 {
+		c.JSON(http.StatusOK, gin.H{"status": "healthy"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":      "degraded",
+		"unavailable": rc.unavailable,
+	})
+}
@@ -2,6 +2,7 @@ package controller
 
 import (
 	"bot-go/internal/config"
+	"bot-go/internal/logging"
 	"bot-go/internal/service/vector"
 	"context"
 	"sync/atomic"
@@ -11,9 +12,10 @@ import (
 
 // EmbeddingProcessor implements FileProcessor for code chunk embeddings
 type EmbeddingProcessor struct {
-	chunkService         *vector.CodeChunkService
-	logger               *zap.Logger
-	chunkCount           atomic.Int64
+	chunkService          *vector.CodeChunkService
+	logger                *zap.Logger
+	chunkCount            atomic.Int64
+	totalChunks           atomic.Int64    // lifetime count, not reset between repositories
 	collectionInitialized map[string]bool // Track which collections have been created
 }
 
@@ -45,14 +47,14 @@ func (ep *EmbeddingProcessor) ensureCollection(ctx context.Context, collectionNa
 	}
 
 	if !exists {
-		ep.logger.Info("Creating Qdrant collection", zap.String("collection", collectionName))
+		ep.log(ctx).Info("Creating Qdrant collection", zap.String("collection", collectionName))
 		// Get embedding dimension from the embedding model
 		vectorDim := ep.chunkService.GetEmbeddingModel().GetDimension()
 		err = ep.chunkService.GetVectorDB().CreateCollection(ctx, collectionName, vectorDim, vector.DistanceMetricCosine)
 		if err != nil {
 			return err
 		}
-		ep.logger.Info("Qdrant collection created successfully", zap.String("collection", collectionName))
+		ep.log(ctx).Info("Qdrant collection created successfully", zap.String("collection", collectionName))
 	}
 
 	// Mark collection as initialized
@@ -62,7 +64,14 @@ func (ep *EmbeddingProcessor) ensureCollection(ctx context.Context, collectionNa
 
 // ProcessFile processes a single file for embedding generation
 func (ep *EmbeddingProcessor) ProcessFile(ctx context.Context, repo *config.Repository, fileCtx *FileContext) error {
-	ep.logger.Debug("Processing file for embeddings",
+	if fileCtx.LightweightReason != "" {
+		ep.log(ctx).Debug("Skipping embeddings for lightweight file",
+			zap.String("path", fileCtx.FilePath),
+			zap.String("reason", fileCtx.LightweightReason))
+		return nil
+	}
+
+	ep.log(ctx).Debug("Processing file for embeddings",
 		zap.String("path", fileCtx.FilePath),
 		zap.Int32("file_id", fileCtx.FileID))
 
@@ -70,7 +79,7 @@ func (ep *EmbeddingProcessor) ProcessFile(ctx context.Context, repo *config.Repo
 
 	// Ensure collection exists before processing
 	if err := ep.ensureCollection(ctx, collectionName); err != nil {
-		ep.logger.Error("Failed to ensure collection exists",
+		ep.log(ctx).Error("Failed to ensure collection exists",
 			zap.String("collection", collectionName),
 			zap.Error(err))
 		return nil // Continue processing other files
@@ -83,9 +92,10 @@ func (ep *EmbeddingProcessor) ProcessFile(ctx context.Context, repo *config.Repo
 		collectionName,
 		fileCtx.Content,
 		fileCtx.FileID,
+		fileCtx.CommitSHA(),
 	)
 	if err != nil {
-		ep.logger.Error("Failed to process file for embeddings",
+		ep.log(ctx).Error("Failed to process file for embeddings",
 			zap.String("path", fileCtx.FilePath),
 			zap.Int32("file_id", fileCtx.FileID),
 			zap.Error(err))
@@ -94,8 +104,9 @@ func (ep *EmbeddingProcessor) ProcessFile(ctx context.Context, repo *config.Repo
 
 	// Track total chunks processed
 	ep.chunkCount.Add(int64(len(chunks)))
+	ep.totalChunks.Add(int64(len(chunks)))
 
-	ep.logger.Debug("Successfully processed file for embeddings",
+	ep.log(ctx).Debug("Successfully processed file for embeddings",
 		zap.String("path", fileCtx.FilePath),
 		zap.Int32("file_id", fileCtx.FileID),
 		zap.Int("chunks", len(chunks)))
@@ -105,7 +116,7 @@ func (ep *EmbeddingProcessor) ProcessFile(ctx context.Context, repo *config.Repo
 // PostProcess performs any cleanup or finalization after all files are processed
 func (ep *EmbeddingProcessor) PostProcess(ctx context.Context, repo *config.Repository) error {
 	totalChunks := ep.chunkCount.Load()
-	ep.logger.Info("Embedding processing completed",
+	ep.log(ctx).Info("Embedding processing completed",
 		zap.String("repo_name", repo.Name),
 		zap.Int64("total_chunks", totalChunks))
 
@@ -113,3 +124,13 @@ func (ep *EmbeddingProcessor) PostProcess(ctx context.Context, repo *config.Repo
 	ep.chunkCount.Store(0)
 	return nil
 }
+
+// EmbeddedChunks returns the number of chunks embedded since the processor was created
+func (ep *EmbeddingProcessor) EmbeddedChunks() int64 {
+	return ep.totalChunks.Load()
+}
+
+// log returns the logger with the request ID carried by ctx attached
+func (ep *EmbeddingProcessor) log(ctx context.Context) *zap.Logger {
+	return logging.FromContext(ctx, ep.logger)
+}
@@ -0,0 +1,167 @@
+package controller
+
+import (
+	"net/http"
+	"time"
+
+	"bot-go/internal/logging"
+	"bot-go/internal/model/ast"
+	"bot-go/internal/model/schema"
+	"bot-go/internal/service/codegraph"
+	"bot-go/internal/tracing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// maxEnrichedRelations bounds the relations of one enrichment request
+const maxEnrichedRelations = 10000
+
+// maxTraceExportBytes bounds the body of a trace import
+const maxTraceExportBytes = 256 << 20
+
+// CreateRelationsRequest is a report of relations between existing nodes by an external tool
+type CreateRelationsRequest struct {
+	Source    string                 `json:"source" binding:"required"` // the reporting tool, recorded on every relation
+	Relations []EnrichedRelationSpec `json:"relations" binding:"required"`
+}
+
+// EnrichedRelationSpec is one relation of a CreateRelationsRequest
+type EnrichedRelationSpec struct {
+	From       int64          `json:"from"`
+	To         int64          `json:"to"`
+	Type       string         `json:"type"`
+	Properties map[string]any `json:"properties,omitempty"`
+}
+
+// EnrichmentController lets external tools add typed relations to the code graph, such as
+// RUNTIME_CALLS edges from tracing data
+type EnrichmentController struct {
+	codeGraph *codegraph.CodeGraph
+	logger    *zap.Logger
+}
+
+// NewEnrichmentController creates a new EnrichmentController
+func NewEnrichmentController(codeGraph *codegraph.CodeGraph, logger *zap.Logger) *EnrichmentController {
+	return &EnrichmentController{
+		codeGraph: codeGraph,
+		logger:    logger,
+	}
+}
+
+func (ec *EnrichmentController) log(c *gin.Context) *zap.Logger {
+	return logging.FromContext(c.Request.Context(), ec.logger)
+}
+
+// RelationTypes lists the relation types external tools may create, with the labels their
+// endpoints may have
+func (ec *EnrichmentController) RelationTypes(c *gin.Context) {
+	relations := schema.ExternalRelations()
+	types := make([]gin.H, len(relations))
+	for i, r := range relations {
+		types[i] = gin.H{
+			"type":        r.Type,
+			"description": schema.Describe(r.Type),
+			"from":        r.From,
+			"to":          r.To,
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"relation_types": types})
+}
+
+// CreateRelations creates or updates the reported relations, all or none of them. Every
+// relation records its source, the request that last reported it, when it was first and
+// last reported and how many times.
+func (ec *EnrichmentController) CreateRelations(c *gin.Context) {
+	var req CreateRelationsRequest
+	if err := bindRequest(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, invalidRequestBody(err))
+		return
+	}
+
+	relations := make([]codegraph.EnrichedRelation, len(req.Relations))
+	for i, r := range req.Relations {
+		relations[i] = codegraph.EnrichedRelation{
+			From:       ast.NodeID(r.From),
+			To:         ast.NodeID(r.To),
+			Type:       schema.RelationType(r.Type),
+			Properties: r.Properties,
+		}
+	}
+	ctx := c.Request.Context()
+	written, err := ec.codeGraph.CreateEnrichedRelations(ctx, relations, codegraph.RelationProvenance{
+		Source:    req.Source,
+		RequestID: logging.RequestID(ctx),
+		Time:      time.Now(),
+	})
+	if err != nil {
+		ec.log(c).Warn("Failed to create enriched relations", zap.String("source", req.Source), zap.Error(err))
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"source": req.Source, "written": written})
+}
+
+// DeleteRelations deletes the relations of ?type= reported by ?source=, e.g. before a tool
+// reports a fresh trace
+func (ec *EnrichmentController) DeleteRelations(c *gin.Context) {
+	relType, source := c.Query("type"), c.Query("source")
+	var fields []FieldError
+	if relType == "" {
+		fields = append(fields, FieldError{Field: "type", Message: "is required"})
+	}
+	if source == "" {
+		fields = append(fields, FieldError{Field: "source", Message: "is required"})
+	}
+	if len(fields) > 0 {
+		c.JSON(http.StatusBadRequest, invalidRequestBody(&ValidationError{Fields: fields}))
+		return
+	}
+
+	deleted, err := ec.codeGraph.DeleteEnrichedRelations(c.Request.Context(), schema.RelationType(relType), source)
+	if err != nil {
+		ec.log(c).Warn("Failed to delete enriched relations", zap.String("source", source), zap.Error(err))
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"type": relType, "source": source, "deleted": deleted})
+}
+
+// ImportTraces maps the spans of the OTLP/JSON trace export in the body to the functions of
+// ?repo= and writes the calls between them as RUNTIME_CALLS relations of ?source= (default
+// "otel"). With ?replace=true the relations previously imported from the source are deleted
+// first.
+func (ec *EnrichmentController) ImportTraces(c *gin.Context) {
+	repoName := c.Query("repo")
+	if repoName == "" {
+		c.JSON(http.StatusBadRequest, invalidRequestBody(&ValidationError{Fields: []FieldError{{Field: "repo", Message: "is required"}}}))
+		return
+	}
+	source := c.DefaultQuery("source", tracing.DefaultSource)
+
+	spans, err := tracing.ParseOTLP(http.MaxBytesReader(c.Writer, c.Request.Body, maxTraceExportBytes))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	if c.Query("replace") == "true" {
+		if _, err := ec.codeGraph.DeleteEnrichedRelations(ctx, tracing.RelRuntimeCalls, source); err != nil {
+			ec.log(c).Warn("Failed to delete imported runtime calls", zap.String("source", source), zap.Error(err))
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+	}
+	result, err := tracing.NewImporter(ec.codeGraph, ec.log(c)).Import(ctx, repoName, spans, codegraph.RelationProvenance{
+		Source:    source,
+		RequestID: logging.RequestID(ctx),
+		Time:      time.Now(),
+	})
+	if err != nil {
+		ec.log(c).Warn("Failed to import traces", zap.String("repo_name", repoName), zap.Error(err))
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
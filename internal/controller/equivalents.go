@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"bot-go/internal/model"
+	"bot-go/internal/model/ast"
+	"bot-go/internal/service/vector"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// FindEquivalentsRequest asks for the functions of a repository most similar to a function of
+// another repository
+type FindEquivalentsRequest struct {
+	NodeID          int64    `json:"node_id" binding:"required"` // Function node of the source repository
+	Limit           int      `json:"limit"`                      // default 10
+	SignatureWeight *float64 `json:"signature_weight"`           // Share of the score from signature similarity (0-1, default 0.3)
+}
+
+// FindEquivalents searches the repository in the path for the functions most similar to the
+// function node of the request, usually from another repository, to find code to reuse or
+// the counterpart of a function during a migration. Candidates are found by embedding
+// similarity and reranked with signature similarity (see vector.SignatureSimilarity).
+func (rc *RepoController) FindEquivalents(c *gin.Context) {
+	var req FindEquivalentsRequest
+	if err := bindRequest(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, invalidRequestBody(err))
+		return
+	}
+	target, err := rc.config.GetRepository(c.Param("name"))
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	if rc.codeGraph == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "code graph is not enabled"})
+		return
+	}
+	if rc.chunkService == nil {
+		serviceUnavailable(c, rc.unavailable, ServiceVectorSearch, "Code chunk service not available")
+		return
+	}
+	if req.Limit <= 0 {
+		req.Limit = 10
+	}
+	signatureWeight := vector.DefaultSignatureWeight
+	if req.SignatureWeight != nil {
+		signatureWeight = *req.SignatureWeight
+	}
+
+	ctx := c.Request.Context()
+	found, err := rc.codeGraph.FindNodeFile(ctx, ast.NodeID(req.NodeID), ast.NodeTypeFunction)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	source, err := rc.config.GetRepository(found.RepoName)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	content, reason := rc.nodeFileContent(ctx, source, found)
+	if reason != "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Source not available", "details": reason})
+		return
+	}
+
+	query, results, err := rc.chunkService.FindEquivalents(ctx, target.Name, found.FilePath, source.Language,
+		content, found.Node.Range.Start.Line, req.Limit, signatureWeight)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	rc.log(c).Info("Found equivalent functions",
+		zap.String("repo_name", target.Name),
+		zap.String("source_repo", source.Name),
+		zap.Int64("node_id", req.NodeID),
+		zap.Int("results", len(results)))
+	c.JSON(http.StatusOK, model.FindEquivalentsResponse{
+		RepoName: target.Name,
+		Query: model.EquivalentQuery{
+			NodeID:    req.NodeID,
+			RepoName:  source.Name,
+			Path:      found.FilePath,
+			Name:      query.Name,
+			Signature: query.Signature,
+			Range:     query.Range,
+		},
+		Results: results,
+	})
+}
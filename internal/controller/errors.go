@@ -0,0 +1,27 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"bot-go/internal/apperrors"
+)
+
+// errorStatus maps an error from the service layer to an HTTP status code, falling back to
+// 500 for errors outside the apperrors taxonomy
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, apperrors.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, apperrors.ErrBackendUnavailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, apperrors.ErrUnsupportedLanguage):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, apperrors.ErrInvalidArgument):
+		return http.StatusBadRequest
+	case errors.Is(err, apperrors.ErrConflict):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
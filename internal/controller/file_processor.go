@@ -27,6 +27,36 @@ type FileContext struct {
 
 	// Ephemeral indicates if this is an uncommitted/working directory version
 	Ephemeral bool
+
+	// Submodule is the repository-relative root of the git submodule containing the file
+	// ("" for files of the repository itself), and SubmoduleCommit the commit it is pinned to
+	Submodule       string
+	SubmoduleCommit string
+
+	// LightweightReason is set for files that only get a FileScope node (too large,
+	// generated or minified; see util.LightweightReason). Processors skip their full work.
+	LightweightReason string
+}
+
+// CommitSHA returns the commit the file was read from, or "" for ephemeral files
+func (fc *FileContext) CommitSHA() string {
+	if fc.CommitID == nil {
+		return ""
+	}
+	return *fc.CommitID
+}
+
+// BufferFlusher is implemented by processors that buffer data between files and can
+// write it out early when an index build approaches its memory budget
+type BufferFlusher interface {
+	FlushBuffers(ctx context.Context) error
+}
+
+// Finalizer is implemented by processors whose repository-level work reads what the other
+// processors' post-processing writes, such as resolved calls. Finalize runs once all
+// PostProcess calls have completed, one processor at a time.
+type Finalizer interface {
+	Finalize(ctx context.Context, repo *config.Repository) error
 }
 
 // FileProcessor defines the interface for processing individual files
@@ -0,0 +1,187 @@
+package controller
+
+import (
+	"bot-go/internal/config"
+	"bot-go/internal/db"
+	"bot-go/internal/model"
+	"bot-go/internal/model/ast"
+	"bot-go/internal/service/codegraph"
+	"bot-go/internal/util"
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// GetFunctionHistory lists every indexed version of a function (one per indexed version of
+// its file) with its range, commit and a line diff summary against the previous version.
+// With ?include_source=true each version also carries its source text.
+func (rc *RepoController) GetFunctionHistory(c *gin.Context) {
+	if rc.codeGraph == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "code graph is not enabled"})
+		return
+	}
+
+	functionID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, invalidRequestBody(&ValidationError{
+			Fields: []FieldError{{Field: "id", Message: "must be a numeric node ID"}},
+		}))
+		return
+	}
+	includeSource := false
+	if raw := c.Query("include_source"); raw != "" {
+		if includeSource, err = strconv.ParseBool(raw); err != nil {
+			c.JSON(http.StatusBadRequest, invalidRequestBody(&ValidationError{
+				Fields: []FieldError{{Field: "include_source", Message: "must be a boolean"}},
+			}))
+			return
+		}
+	}
+
+	repo, err := rc.config.GetRepository(c.Param("name"))
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	versions, err := rc.codeGraph.FindFunctionVersions(ctx, repo.Name, ast.NodeID(functionID))
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	current := versions[0]
+	for _, v := range versions {
+		if v.Function.ID == ast.NodeID(functionID) {
+			current = v
+		}
+	}
+	fileVersions := rc.fileVersionsByID(repo, current.Path)
+
+	response := model.FunctionHistoryResponse{
+		RepoName:   repo.Name,
+		FunctionID: functionID,
+		Name:       current.Function.Name,
+		Path:       current.Path,
+		Versions:   make([]model.FunctionVersionInfo, 0, len(versions)),
+	}
+	var prevLines []string
+	prevStart := 0
+	for i, v := range versions {
+		info := model.FunctionVersionInfo{
+			NodeID: int64(v.Function.ID),
+			FileID: v.FileID,
+			Commit: v.Commit,
+			Range:  v.Function.Range,
+		}
+		fv := fileVersions[v.FileID]
+		if fv != nil {
+			indexedAt := fv.CreatedAt
+			info.IndexedAt = &indexedAt
+			info.Ephemeral = fv.Ephemeral
+			if info.Commit == "" && fv.CommitID != nil {
+				info.Commit = *fv.CommitID
+			}
+		} else {
+			info.Ephemeral = info.Commit == ""
+		}
+
+		lines, reason := rc.functionVersionLines(ctx, repo, v, info.Commit, fv, i == len(versions)-1)
+		if lines == nil {
+			info.SourceUnavailable = reason
+		} else if includeSource {
+			info.Source = strings.Join(lines, "\n")
+		}
+		if lines != nil && prevLines != nil {
+			added, removed := util.LineDiffStats(prevLines, lines)
+			info.Diff = &model.VersionDiff{
+				LinesAdded:   added,
+				LinesRemoved: removed,
+				Moved:        prevStart != info.Range.Start.Line,
+			}
+		}
+		prevLines, prevStart = lines, info.Range.Start.Line
+		response.Versions = append(response.Versions, info)
+	}
+
+	rc.log(c).Debug("Function history",
+		zap.String("repo_name", repo.Name),
+		zap.Int64("function_id", functionID),
+		zap.Int("versions", len(response.Versions)))
+	c.JSON(http.StatusOK, response)
+}
+
+// fileVersionsByID returns the MySQL file versions of a path keyed by FileID, or nil if file
+// tracking is unavailable
+func (rc *RepoController) fileVersionsByID(repo *config.Repository, relPath string) map[int32]*db.FileVersion {
+	if rc.mysqlConn == nil {
+		return nil
+	}
+	fileVersionRepo, err := db.NewFileVersionRepository(rc.mysqlConn.GetDB(), repo.Name, rc.logger)
+	if err != nil {
+		return nil
+	}
+	files, err := fileVersionRepo.GetFilesByPath(relPath)
+	if err != nil {
+		rc.logger.Warn("Failed to read file versions",
+			zap.String("repo_name", repo.Name),
+			zap.String("path", relPath),
+			zap.Error(err))
+		return nil
+	}
+	byID := make(map[int32]*db.FileVersion, len(files))
+	for _, f := range files {
+		byID[f.FileID] = f
+	}
+	return byID
+}
+
+// functionVersionLines reads the lines of one function version. Returns nil and the reason
+// when the source cannot be read.
+func (rc *RepoController) functionVersionLines(ctx context.Context, repo *config.Repository, v codegraph.FunctionVersion, commit string, fv *db.FileVersion, newest bool) ([]string, string) {
+	content, reason := rc.indexedFileContent(ctx, repo, v.Path, commit, fv, newest)
+	if reason != "" {
+		return nil, reason
+	}
+
+	lines := strings.Split(string(content), "\n")
+	start, end := v.Function.Range.Start.Line, v.Function.Range.End.Line
+	if start < 0 || end < start || end >= len(lines) {
+		return nil, "function range is outside the file"
+	}
+	return lines[start : end+1], ""
+}
+
+// indexedFileContent reads one indexed version of a file. Committed versions are read from
+// git; working tree versions only while the file on disk still matches the indexed version
+// (checked by SHA when file tracking is available, otherwise only the newest version is
+// assumed current). Returns nil and the reason when the file cannot be read.
+func (rc *RepoController) indexedFileContent(ctx context.Context, repo *config.Repository, relPath, commit string, fv *db.FileVersion, newest bool) ([]byte, string) {
+	if commit != "" {
+		content, err := util.GetFileContentAtCommit(repo.Path, relPath, commit)
+		if err != nil {
+			rc.log(ctx).Debug("Failed to read file version from git",
+				zap.String("path", relPath),
+				zap.String("commit", commit),
+				zap.Error(err))
+			return nil, "file not readable at commit " + commit
+		}
+		return content, ""
+	}
+
+	content, err := os.ReadFile(filepath.Join(repo.Path, filepath.FromSlash(relPath)))
+	if err != nil {
+		return nil, "file no longer exists in the working tree"
+	}
+	if (fv != nil && util.CalculateFileSHA256(content) != fv.FileSHA) || (fv == nil && !newest) {
+		return nil, "working tree changed since this version was indexed"
+	}
+	return content, ""
+}
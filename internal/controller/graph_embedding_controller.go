@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+
+	"bot-go/internal/logging"
+	"bot-go/internal/model/ast"
+	"bot-go/internal/service/graphembed"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// GraphEmbeddingController handles HTTP requests for structural (graph) embeddings
+type GraphEmbeddingController struct {
+	service *graphembed.GraphEmbeddingService
+	logger  *zap.Logger
+}
+
+// NewGraphEmbeddingController creates a new GraphEmbeddingController
+func NewGraphEmbeddingController(service *graphembed.GraphEmbeddingService, logger *zap.Logger) *GraphEmbeddingController {
+	return &GraphEmbeddingController{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// BuildGraphEmbeddingsRequest is the request for (re)building graph embeddings of a repository
+type BuildGraphEmbeddingsRequest struct {
+	RepoName string `json:"repo_name" binding:"required"`
+}
+
+// StructurallySimilarRequest is the request for finding structurally similar functions/classes
+type StructurallySimilarRequest struct {
+	RepoName string `json:"repo_name" binding:"required"`
+	NodeID   int64  `json:"node_id" binding:"required"`
+	Limit    int    `json:"limit"`
+}
+
+// BuildGraphEmbeddings computes node2vec embeddings for all functions and classes in a repository
+func (c *GraphEmbeddingController) BuildGraphEmbeddings(ctx *gin.Context) {
+	var req BuildGraphEmbeddingsRequest
+	if err := bindRequest(ctx, &req); err != nil {
+		ctx.JSON(http.StatusBadRequest, invalidRequestBody(err))
+		return
+	}
+
+	count, err := c.service.BuildEmbeddings(ctx.Request.Context(), req.RepoName)
+	if err != nil {
+		c.log(ctx).Error("Failed to build graph embeddings", zap.String("repo", req.RepoName), zap.Error(err))
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{
+		"repo_name":  req.RepoName,
+		"collection": graphembed.CollectionName(req.RepoName),
+		"count":      count,
+	})
+}
+
+// SearchStructurallySimilar returns functions/classes whose graph neighborhood resembles the given node's
+func (c *GraphEmbeddingController) SearchStructurallySimilar(ctx *gin.Context) {
+	var req StructurallySimilarRequest
+	if err := bindRequest(ctx, &req); err != nil {
+		ctx.JSON(http.StatusBadRequest, invalidRequestBody(err))
+		return
+	}
+	if req.Limit <= 0 {
+		req.Limit = 10
+	}
+
+	matches, err := c.service.FindStructurallySimilar(ctx.Request.Context(), req.RepoName, ast.NodeID(req.NodeID), req.Limit)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"matches": matches})
+}
+
+// log returns the logger with the request ID carried by ctx attached
+func (c *GraphEmbeddingController) log(ctx context.Context) *zap.Logger {
+	return logging.FromContext(ctx, c.logger)
+}
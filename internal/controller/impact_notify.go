@@ -0,0 +1,208 @@
+package controller
+
+import (
+	"bot-go/internal/codeapi"
+	gitutil "bot-go/internal/signals/util"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Sources of an impact notification
+const (
+	NotifySourceCodeOwners = "codeowners"
+	NotifySourceBlame      = "blame"
+)
+
+// Limits of the markdown summary; the JSON response is complete
+const (
+	markdownFilesPerOwner = 3
+	markdownTopNodes      = 10
+)
+
+// ImpactNotifyRequest is the request for the owners to notify about a change
+type ImpactNotifyRequest struct {
+	RepoName        string                `json:"repo_name" binding:"required"`
+	ChangedFiles    []string              `json:"changed_files" binding:"required"` // Repository-relative paths
+	MaxDepth        int                   `json:"max_depth"`
+	IncludeDataFlow bool                  `json:"include_data_flow"`
+	Scoring         *ImpactScoringRequest `json:"scoring"`
+}
+
+// ImpactNotification is a team or person to notify about a change
+type ImpactNotification struct {
+	Owner  string   `json:"owner"`           // CODEOWNERS owner ("@org/team", "@user", email) or blame author
+	Email  string   `json:"email,omitempty"` // Blame author email
+	Source string   `json:"source"`          // "codeowners" or "blame"
+	Files  []string `json:"files"`           // Affected files owned (codeowners) or last changed (blame)
+	Nodes  int      `json:"nodes"`           // Affected nodes
+	Score  float64  `json:"score"`           // Sum of the affected nodes' impact scores
+}
+
+// GetImpactNotifications runs impact analysis for the changed files and returns the
+// CODEOWNERS owners of the affected files and the last authors (per git blame) of the
+// affected nodes, with a markdown summary for posting on a pull request
+func (c *CodeAPIController) GetImpactNotifications(ctx *gin.Context) {
+	var req ImpactNotifyRequest
+	if err := bindRequest(ctx, &req); err != nil {
+		ctx.JSON(http.StatusBadRequest, invalidRequestBody(err))
+		return
+	}
+
+	if req.MaxDepth <= 0 {
+		req.MaxDepth = 3
+	}
+	scoring := req.Scoring.scoring()
+	opts := codeapi.ImpactOptions{
+		MaxDepth:         req.MaxDepth,
+		IncludeCallGraph: true,
+		IncludeDataFlow:  req.IncludeDataFlow,
+		Scoring:          &scoring,
+	}
+	impact, err := c.api.Analyzer().GetChangeImpact(ctx.Request.Context(), req.RepoName, req.ChangedFiles, opts)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	var owners *gitutil.CodeOwners
+	if c.config != nil {
+		if repo, err := c.config.GetRepository(req.RepoName); err == nil {
+			if owners, err = gitutil.LoadCodeOwners(repo.Path); err != nil {
+				c.logger.Warn("Failed to read CODEOWNERS", zap.String("repo_name", req.RepoName), zap.Error(err))
+			}
+		}
+	}
+
+	notifications := impactNotifications(impact, owners)
+	ctx.JSON(http.StatusOK, gin.H{
+		"repo_name":     req.RepoName,
+		"changed_files": impact.ChangedFiles,
+		"functions":     impact.Functions,
+		"affected":      len(impact.Affected),
+		"risk_score":    impact.RiskScore,
+		"risk_level":    impact.RiskLevel,
+		"truncated":     impact.Truncated,
+		"notify":        notifications,
+		"markdown":      impactMarkdown(impact, notifications),
+	})
+}
+
+// impactNotifications groups the affected nodes by CODEOWNERS owner of their file and by
+// blame author, highest total score first
+func impactNotifications(impact *codeapi.ChangeImpact, owners *gitutil.CodeOwners) []ImpactNotification {
+	byKey := make(map[string]*ImpactNotification)
+	files := make(map[string]map[string]bool)
+	add := func(source, owner, email string, node *codeapi.ImpactNode) {
+		key := source + "\x00" + owner
+		n := byKey[key]
+		if n == nil {
+			n = &ImpactNotification{Owner: owner, Email: email, Source: source}
+			byKey[key] = n
+			files[key] = make(map[string]bool)
+		}
+		n.Nodes++
+		n.Score += node.Score
+		if node.FilePath != "" && !files[key][node.FilePath] {
+			files[key][node.FilePath] = true
+			n.Files = append(n.Files, node.FilePath)
+		}
+	}
+
+	emails := make(map[string]string, len(impact.Owners))
+	for _, o := range impact.Owners {
+		emails[o.Author] = o.Email
+	}
+	for _, node := range impact.Affected {
+		for _, owner := range owners.Owners(node.FilePath) {
+			add(NotifySourceCodeOwners, owner, "", node)
+		}
+		if node.LastModifiedBy != "" {
+			add(NotifySourceBlame, node.LastModifiedBy, emails[node.LastModifiedBy], node)
+		}
+	}
+
+	notifications := make([]ImpactNotification, 0, len(byKey))
+	for _, n := range byKey {
+		sort.Strings(n.Files)
+		notifications = append(notifications, *n)
+	}
+	sort.Slice(notifications, func(i, j int) bool {
+		a, b := notifications[i], notifications[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Source != b.Source {
+			return a.Source == NotifySourceCodeOwners
+		}
+		return a.Owner < b.Owner
+	})
+	return notifications
+}
+
+// impactMarkdown formats a change impact and its notification list for a pull request comment
+func impactMarkdown(impact *codeapi.ChangeImpact, notifications []ImpactNotification) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### Change impact: %s risk (score %.2f)\n\n", strings.ToUpper(impact.RiskLevel), impact.RiskScore)
+
+	affectedFiles := make(map[string]bool)
+	for _, node := range impact.Affected {
+		affectedFiles[node.FilePath] = true
+	}
+	fmt.Fprintf(&b, "%d changed file(s) with %d function(s) affect %d node(s) in %d file(s).",
+		len(impact.ChangedFiles), impact.Functions, len(impact.Affected), len(affectedFiles))
+	if impact.Truncated {
+		b.WriteString(" Only the first functions of the changed files were analyzed.")
+	}
+	b.WriteString("\n\n")
+
+	if len(notifications) == 0 {
+		b.WriteString("No owners to notify.\n")
+		return b.String()
+	}
+
+	b.WriteString("| Notify | Source | Nodes | Score | Files |\n|---|---|---|---|---|\n")
+	for _, n := range notifications {
+		source := "CODEOWNERS"
+		if n.Source == NotifySourceBlame {
+			source = "last author"
+		}
+		shown := n.Files
+		more := ""
+		if len(shown) > markdownFilesPerOwner {
+			more = fmt.Sprintf(" (+%d more)", len(shown)-markdownFilesPerOwner)
+			shown = shown[:markdownFilesPerOwner]
+		}
+		fmt.Fprintf(&b, "| %s | %s | %d | %.2f | %s%s |\n",
+			markdownCell(n.Owner), source, n.Nodes, n.Score, markdownCodeList(shown), more)
+	}
+
+	top := impact.Affected
+	if len(top) > markdownTopNodes {
+		top = top[:markdownTopNodes]
+	}
+	b.WriteString("\n<details><summary>Most affected nodes</summary>\n\n")
+	b.WriteString("| Node | File | Score |\n|---|---|---|\n")
+	for _, node := range top {
+		fmt.Fprintf(&b, "| `%s` | `%s` | %.2f |\n", markdownCell(node.Name), markdownCell(node.FilePath), node.Score)
+	}
+	b.WriteString("\n</details>\n")
+	return b.String()
+}
+
+func markdownCodeList(items []string) string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = "`" + markdownCell(item) + "`"
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// markdownCell escapes characters that would break a markdown table cell
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
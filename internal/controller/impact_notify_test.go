@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	"bot-go/internal/codeapi"
+	gitutil "bot-go/internal/signals/util"
+)
+
+func TestImpactNotifications(t *testing.T) {
+	owners, err := gitutil.ParseCodeOwners(strings.NewReader("* @org/core\n/api/ @org/api\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	impact := &codeapi.ChangeImpact{
+		ChangedFiles: []string{"lib/x.go"},
+		Functions:    1,
+		Affected: []*codeapi.ImpactNode{
+			{ID: 1, Name: "Serve", FilePath: "api/server.go", Score: 1, LastModifiedBy: "ann"},
+			{ID: 2, Name: "Route", FilePath: "api/router.go", Score: 0.5, LastModifiedBy: "bob"},
+			{ID: 3, Name: "Main", FilePath: "cmd/main.go", Score: 0.25, LastModifiedBy: "ann"},
+		},
+		Owners:    []codeapi.ImpactOwner{{Author: "ann", Email: "ann@example.com", Nodes: 2}, {Author: "bob", Nodes: 1}},
+		RiskScore: 1.75,
+		RiskLevel: codeapi.RiskLow,
+	}
+
+	got := impactNotifications(impact, owners)
+	var summary []string
+	for _, n := range got {
+		summary = append(summary, n.Source+":"+n.Owner+":"+strings.Join(n.Files, "+"))
+	}
+	want := []string{
+		"codeowners:@org/api:api/router.go+api/server.go",
+		"blame:ann:api/server.go+cmd/main.go",
+		"blame:bob:api/router.go",
+		"codeowners:@org/core:cmd/main.go",
+	}
+	if strings.Join(summary, " ") != strings.Join(want, " ") {
+		t.Fatalf("impactNotifications() = %v, want %v", summary, want)
+	}
+	if got[1].Email != "ann@example.com" {
+		t.Errorf("expected blame email to be carried, got %q", got[1].Email)
+	}
+
+	md := impactMarkdown(impact, got)
+	for _, s := range []string{"LOW risk", "| @org/api | CODEOWNERS | 2 | 1.50 |", "| ann | last author |", "`Serve`"} {
+		if !strings.Contains(md, s) {
+			t.Errorf("markdown missing %q:\n%s", s, md)
+		}
+	}
+
+	// Without CODEOWNERS only blame authors are notified
+	if got := impactNotifications(impact, nil); len(got) != 2 {
+		t.Errorf("expected 2 blame notifications without CODEOWNERS, got %d", len(got))
+	}
+}
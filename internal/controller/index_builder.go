@@ -1,13 +1,24 @@
 package controller
 
 import (
+	"bot-go/internal/access"
+	"bot-go/internal/apisurface"
+	"bot-go/internal/cluster"
 	"bot-go/internal/config"
 	"bot-go/internal/db"
+	"bot-go/internal/logging"
+	"bot-go/internal/service/codegraph"
+	"bot-go/internal/storage"
+	"bot-go/internal/techstack"
 	"bot-go/internal/util"
 	"context"
+	"errors"
 	"fmt"
+	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -19,6 +30,26 @@ type IndexBuilder struct {
 	processors      []FileProcessor
 	logger          *zap.Logger
 	fileVersionRepo *db.FileVersionRepository
+	scheduler       *ProcessingScheduler // optional; nil runs processors without a shared limit
+	locker          cluster.Locker       // optional; nil builds without excluding other builds of the repository
+	store           storage.Store        // optional; where API snapshots of library repositories are kept
+	partial         bool                 // only a subset of the processors runs (see SelectProcessors)
+	summary         BuildSummary         // counts for the last processFiles run
+	fromArchive     bool                 // the repository was extracted from an archive (see SetArchiveSource)
+	archiveVersion  string
+	journal         *util.IndexJournal // nil unless index_building.journal is set (see openJournal)
+	buildLock       cluster.Lock       // lock of the running build, nil without a locker
+}
+
+// lockCheckFiles is the number of files a build processes between checks that it still holds
+// its lock
+const lockCheckFiles = 100
+
+// BuildSummary counts what happened to the files walked by a build
+type BuildSummary struct {
+	FilesProcessed   int            `json:"files_processed"`
+	FilesLightweight int            `json:"files_lightweight"`       // FileScope only (see util.LightweightReason)
+	FilesSkipped     map[string]int `json:"files_skipped,omitempty"` // not indexed, by reason (e.g. "binary", "non_utf8")
 }
 
 // NewIndexBuilder creates a new index builder with the specified processors
@@ -31,6 +62,80 @@ func NewIndexBuilder(config *config.Config, processors []FileProcessor, fileVers
 	}
 }
 
+// SetScheduler makes processor executions of this builder go through the shared scheduler
+// at background priority
+func (ib *IndexBuilder) SetScheduler(scheduler *ProcessingScheduler) {
+	ib.scheduler = scheduler
+}
+
+// SetLocker makes builds of a repository exclusive among the builders sharing locker, e.g.
+// every server instance with cluster.locking: mysql. A build waits up to
+// cluster.build_lock_wait_seconds for another build of the repository, then fails with
+// cluster.ErrLocked.
+func (ib *IndexBuilder) SetLocker(locker cluster.Locker) {
+	ib.locker = locker
+}
+
+// SetStorage sets where builds of library repositories keep their API snapshots. Without
+// a store no snapshots are taken.
+func (ib *IndexBuilder) SetStorage(store storage.Store) {
+	ib.store = store
+}
+
+// SetArchiveSource marks the repository as extracted from a source archive into a temporary
+// directory. Its files are recorded under version (e.g. a release tag) in place of a commit,
+// or as ephemeral without one, and an interrupted build does not resume.
+func (ib *IndexBuilder) SetArchiveSource(version string) {
+	ib.fromArchive = true
+	ib.archiveVersion = version
+}
+
+// SelectProcessors restricts the builder to the named processors (matched case-insensitively
+// against FileProcessor.Name). An empty list keeps all processors. Files already marked done
+// are reprocessed by a partial build and stay done. Other files are not marked done by it,
+// since the other processors did not run.
+func (ib *IndexBuilder) SelectProcessors(names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	available := make([]string, len(ib.processors))
+	for i, p := range ib.processors {
+		available[i] = p.Name()
+	}
+
+	selected := make([]FileProcessor, 0, len(names))
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		var match FileProcessor
+		for _, p := range ib.processors {
+			if strings.EqualFold(p.Name(), name) {
+				match = p
+				break
+			}
+		}
+		if match == nil {
+			return fmt.Errorf("unknown or disabled processor %q (available: %s)", name, strings.Join(available, ", "))
+		}
+		if seen[match.Name()] {
+			continue
+		}
+		seen[match.Name()] = true
+		selected = append(selected, match)
+	}
+
+	if len(selected) == 0 {
+		return nil
+	}
+	ib.partial = len(selected) < len(ib.processors)
+	ib.processors = selected
+	return nil
+}
+
 // BuildIndex processes a repository through all registered processors
 func (ib *IndexBuilder) BuildIndex(ctx context.Context, repo *config.Repository) error {
 	return ib.BuildIndexWithGitInfo(ctx, repo, false, nil)
@@ -39,12 +144,32 @@ func (ib *IndexBuilder) BuildIndex(ctx context.Context, repo *config.Repository)
 // BuildIndexWithGitInfo processes a repository with optional git HEAD optimization
 func (ib *IndexBuilder) BuildIndexWithGitInfo(ctx context.Context, repo *config.Repository, useHead bool, gitInfo *util.GitInfo) error {
 	if len(ib.processors) == 0 {
-		ib.logger.Warn("No processors registered, skipping index building",
+		ib.log(ctx).Warn("No processors registered, skipping index building",
 			zap.String("repo_name", repo.Name))
 		return nil
 	}
 
-	ib.logger.Info("Starting index building for repository",
+	if ib.locker != nil {
+		wait := time.Duration(ib.config.Cluster.BuildLockWaitSeconds) * time.Second
+		lock, err := ib.locker.Lock(ctx, "build:"+repo.Name, wait)
+		if err != nil {
+			return fmt.Errorf("cannot build %s: %w", repo.Name, err)
+		}
+		ib.buildLock = lock
+		defer func() {
+			ib.buildLock = nil
+			if err := lock.Unlock(); err != nil {
+				ib.log(ctx).Warn("Failed to release the build lock",
+					zap.String("repo_name", repo.Name),
+					zap.Error(err))
+			}
+		}()
+	}
+
+	// Builds read back what they write, which a lagging read replica may not have yet
+	ctx = codegraph.WithLeaderReads(ctx)
+
+	ib.log(ctx).Info("Starting index building for repository",
 		zap.String("repo_name", repo.Name),
 		zap.String("path", repo.Path),
 		zap.Int("processor_count", len(ib.processors)))
@@ -54,42 +179,167 @@ func (ib *IndexBuilder) BuildIndexWithGitInfo(ctx context.Context, repo *config.
 	for i, p := range ib.processors {
 		processorNames[i] = p.Name()
 	}
-	ib.logger.Info("Active processors", zap.Strings("processors", processorNames))
+	ib.log(ctx).Info("Active processors", zap.Strings("processors", processorNames))
 
 	// Log git info if using HEAD
 	if useHead && gitInfo != nil && gitInfo.IsGitRepo {
-		ib.logger.Info("Using git HEAD for index building",
+		ib.log(ctx).Info("Using git HEAD for index building",
 			zap.String("commit_sha", gitInfo.HeadCommitSHA),
 			zap.String("commit_msg", gitInfo.HeadCommitMsg),
 			zap.Int("modified_files", len(gitInfo.ModifiedFiles)))
 	}
 
+	ib.journal = ib.openJournal(ctx, repo)
+	defer ib.closeJournal(ctx)
+	buildCommit := ""
+	if useHead && gitInfo != nil {
+		buildCommit = gitInfo.HeadCommitSHA
+	}
+	ib.appendJournal(ctx, util.JournalEntry{Op: util.JournalBuildStarted, Commit: buildCommit})
+
 	// Phase 1: Process all files in parallel
-	err := ib.processFiles(ctx, repo, useHead, gitInfo)
+	cursor := ib.loadWalkCursor(repo)
+	err := ib.processFiles(ctx, repo, useHead, gitInfo, cursor)
 	if err != nil {
 		return fmt.Errorf("failed to process files for repository %s: %w", repo.Name, err)
 	}
 
 	// Phase 2: Run post-processing steps in parallel
+	if err := ib.checkBuildLock(ctx, repo); err != nil {
+		return err
+	}
 	err = ib.postProcessRepository(ctx, repo)
 	if err != nil {
 		return fmt.Errorf("failed to post-process repository %s: %w", repo.Name, err)
 	}
 
-	ib.logger.Info("Completed index building for repository",
+	ib.appendJournal(ctx, util.JournalEntry{Op: util.JournalBuildCompleted, Commit: buildCommit})
+	ib.profileStack(ctx, repo)
+	if repo.Library {
+		ib.extractAPISurface(ctx, repo, gitInfo)
+	}
+
+	// The build is complete, so the next one starts from the beginning
+	if err := cursor.Clear(); err != nil {
+		ib.log(ctx).Warn("Failed to clear walk cursor", zap.String("repo_name", repo.Name), zap.Error(err))
+	}
+
+	ib.log(ctx).Info("Completed index building for repository",
 		zap.String("repo_name", repo.Name))
 	return nil
 }
 
-// processFiles walks the repository directory and processes each file through all processors in parallel
-func (ib *IndexBuilder) processFiles(ctx context.Context, repo *config.Repository, useHead bool, gitInfo *util.GitInfo) error {
-	ib.logger.Info("Processing files",
+// profileStack stores the languages, frameworks and build systems of the repository under the
+// work directory for the stats endpoint. A failure is logged and does not fail the build.
+func (ib *IndexBuilder) profileStack(ctx context.Context, repo *config.Repository) {
+	if ib.config.App.WorkDir == "" {
+		return
+	}
+	profile, err := techstack.Analyze(ctx, repo.Name, repo.Path)
+	if err == nil {
+		err = techstack.Save(techstack.ProfileFile(ib.config.App.WorkDir, repo.Name), profile)
+	}
+	if err != nil {
+		ib.log(ctx).Warn("Failed to profile repository stack",
+			zap.String("repo_name", repo.Name),
+			zap.Error(err))
+		return
+	}
+	ib.log(ctx).Info("Profiled repository stack",
+		zap.String("repo_name", repo.Name),
+		zap.Int("languages", len(profile.Languages)),
+		zap.Int("frameworks", len(profile.Frameworks)))
+}
+
+// extractAPISurface stores the public API of a library repository in the builder's store,
+// keyed by the archive version or else the HEAD commit, so that versions can be diffed. A
+// failure is logged and does not fail the build.
+func (ib *IndexBuilder) extractAPISurface(ctx context.Context, repo *config.Repository, gitInfo *util.GitInfo) {
+	if ib.store == nil {
+		return
+	}
+	if gitInfo == nil && !ib.fromArchive {
+		gitInfo, _ = util.GetGitInfo(repo.Path)
+	}
+	commit := ""
+	if gitInfo != nil && gitInfo.IsGitRepo {
+		commit = gitInfo.HeadCommitSHA
+	}
+	version := ib.archiveVersion
+	if version == "" {
+		version = commit
+	}
+	if version == "" {
+		version = "working-tree"
+	}
+
+	surface, err := apisurface.Extract(ctx, ib.logger, repo.Name, repo.Path)
+	if err == nil {
+		surface.Version = version
+		surface.Commit = commit
+		err = apisurface.Save(ctx, ib.store, repo.Name, surface)
+	}
+	if err != nil {
+		ib.log(ctx).Warn("Failed to extract API surface",
+			zap.String("repo_name", repo.Name),
+			zap.Error(err))
+		return
+	}
+	ib.log(ctx).Info("Extracted API surface",
+		zap.String("repo_name", repo.Name),
+		zap.String("version", version),
+		zap.Int("symbols", len(surface.Symbols)))
+}
+
+// Summary returns the file counts of the most recent build
+func (ib *IndexBuilder) Summary() BuildSummary {
+	return ib.summary
+}
+
+// loadWalkCursor returns the resumable walk cursor for a full build of the repository, stored
+// under the work directory. Partial builds and builds without a work directory don't resume.
+func (ib *IndexBuilder) loadWalkCursor(repo *config.Repository) *util.WalkCursor {
+	if ib.partial || ib.fromArchive || ib.config.App.WorkDir == "" {
+		return nil
+	}
+	file := filepath.Join(ib.config.App.WorkDir, "walk_cursors", repo.Name+".json")
+	cursor, err := util.LoadWalkCursor(file, repo.Path)
+	if err != nil {
+		ib.logger.Warn("Ignoring unreadable walk cursor, starting from the beginning",
+			zap.String("repo_name", repo.Name),
+			zap.Error(err))
+		return nil
+	}
+	return cursor
+}
+
+// checkBuildLock returns an error wrapping cluster.ErrLockLost if the running build no longer
+// holds its lock, e.g. because the MySQL connection holding it was lost. Another build of the
+// repository may have started since, so the build must stop.
+func (ib *IndexBuilder) checkBuildLock(ctx context.Context, repo *config.Repository) error {
+	if ib.buildLock == nil || ib.buildLock.Held(ctx) || ctx.Err() != nil {
+		return nil
+	}
+	ib.log(ctx).Error("Lost the build lock; stopping the build", zap.String("repo_name", repo.Name))
+	return fmt.Errorf("cannot build %s: %w", repo.Name, cluster.ErrLockLost)
+}
+
+// processFiles walks the repository directory and processes each file through all processors in parallel.
+// If cursor is non-nil, files completed by an interrupted build are skipped. Every
+// lockCheckFiles files the build lock is checked, and the walk stops once it is lost.
+func (ib *IndexBuilder) processFiles(ctx context.Context, repo *config.Repository, useHead bool, gitInfo *util.GitInfo, cursor *util.WalkCursor) error {
+	ib.log(ctx).Info("Processing files",
 		zap.String("repo_name", repo.Name),
 		zap.String("path", repo.Path))
 
+	ctx, stop := context.WithCancelCause(ctx)
+	defer stop(nil)
+	var started atomic.Int64
+
 	fileCount := 0
 	filesFromGit := 0
 	filesFromDisk := 0
+	summary := BuildSummary{FilesSkipped: make(map[string]int)}
 	var mu sync.Mutex
 
 	// Get configuration for WalkDirTree
@@ -116,7 +366,7 @@ func (ib *IndexBuilder) processFiles(ctx context.Context, repo *config.Repositor
 	// Define the walk function that processes each file
 	walkFunc := func(filePath string, err error) error {
 		if err != nil {
-			ib.logger.Error("Error accessing file", zap.String("path", filePath), zap.Error(err))
+			ib.log(ctx).Error("Error accessing file", zap.String("path", filePath), zap.Error(err))
 			return nil // Continue processing other files
 		}
 
@@ -126,12 +376,18 @@ func (ib *IndexBuilder) processFiles(ctx context.Context, repo *config.Repositor
 			return ctx.Err()
 		default:
 		}
+		if started.Add(1)%lockCheckFiles == 0 {
+			if err := ib.checkBuildLock(ctx, repo); err != nil {
+				stop(err)
+				return err
+			}
+		}
 
 		// Skip special files (Dockerfile, vendor/, node_modules/, etc.) before any processing
 		// Also skip files not matching repo language if SkipOtherLanguages is enabled
 		if util.ShouldSkipFile(filePath, repo) {
 			relPath, _ := util.GetRelativePath(repo.Path, filePath)
-			ib.logger.Debug("Skipping special file",
+			ib.log(ctx).Debug("Skipping special file",
 				zap.String("path", relPath))
 			return nil // Continue processing other files
 		}
@@ -143,11 +399,11 @@ func (ib *IndexBuilder) processFiles(ctx context.Context, repo *config.Repositor
 			// In HEAD mode, skip untracked files gracefully
 			if useHead && strings.Contains(err.Error(), "file not tracked by git") {
 				relPath, _ := util.GetRelativePath(repo.Path, filePath)
-				ib.logger.Debug("Skipping untracked file in HEAD mode",
+				ib.log(ctx).Debug("Skipping untracked file in HEAD mode",
 					zap.String("path", relPath))
 				return nil // Continue processing other files
 			}
-			ib.logger.Error("Failed to read file", zap.String("path", filePath), zap.Error(err))
+			ib.log(ctx).Error("Failed to read file", zap.String("path", filePath), zap.Error(err))
 			return nil // Continue processing other files
 		}
 
@@ -165,16 +421,39 @@ func (ib *IndexBuilder) processFiles(ctx context.Context, repo *config.Repositor
 		// Generate FileContext with FileID from MySQL
 		fileCtx, err := ib.createFileContext(repo.Path, filePath, content, useHead, gitInfo)
 		if err != nil {
-			ib.logger.Error("Failed to create file context", zap.String("path", filePath), zap.Error(err))
+			ib.log(ctx).Error("Failed to create file context", zap.String("path", filePath), zap.Error(err))
 			return nil // Continue processing other files
 		}
 
+		// Binary and non-UTF8 content is recorded as skipped instead of being parsed
+		if reason := util.ContentSkipReason(content); reason != "" {
+			ib.log(ctx).Debug("Skipping non-source file",
+				zap.String("path", fileCtx.RelativePath),
+				zap.String("reason", reason))
+			if err := ib.fileVersionRepo.MarkSkipped(fileCtx.FileID, reason); err != nil {
+				ib.log(ctx).Warn("Failed to record skipped file",
+					zap.Int32("file_id", fileCtx.FileID),
+					zap.Error(err))
+			}
+			mu.Lock()
+			summary.FilesSkipped[reason]++
+			mu.Unlock()
+			return nil
+		}
+
+		if fileCtx.LightweightReason = util.LightweightReason(filePath, content, repo); fileCtx.LightweightReason != "" {
+			ib.log(ctx).Debug("Indexing file on the lightweight path",
+				zap.String("path", fileCtx.RelativePath),
+				zap.String("reason", fileCtx.LightweightReason))
+		}
+
 		// Check if file was already fully processed (same SHA/commit, status="done")
 		// This optimization skips reprocessing unchanged files
 		existingFile, err := ib.fileVersionRepo.GetFileByID(fileCtx.FileID)
-		if err == nil && existingFile.Status == "done" {
+		alreadyDone := err == nil && existingFile.Status == "done"
+		if alreadyDone && !ib.partial {
 			// File already fully processed with this exact SHA and commit
-			ib.logger.Debug("Skipping already processed file",
+			ib.log(ctx).Debug("Skipping already processed file",
 				zap.String("path", fileCtx.RelativePath),
 				zap.Int32("file_id", fileCtx.FileID),
 				zap.String("sha", fileCtx.FileSHA),
@@ -190,7 +469,7 @@ func (ib *IndexBuilder) processFiles(ctx context.Context, repo *config.Repositor
 				go func(p FileProcessor) {
 					defer wg.Done()
 					if err := p.ProcessFile(ctx, repo, fileCtx); err != nil {
-						ib.logger.Error("Processor failed to process file",
+						ib.log(ctx).Error("Processor failed to process file",
 							zap.String("processor", p.Name()),
 							zap.String("path", filePath),
 							zap.Error(err))
@@ -201,18 +480,22 @@ func (ib *IndexBuilder) processFiles(ctx context.Context, repo *config.Repositor
 		*/
 
 		for _, processor := range ib.processors {
-			err := processor.ProcessFile(ctx, repo, fileCtx)
+			ib.journalFile(ctx, processor, fileCtx)
+			err := ib.scheduler.Run(ctx, PriorityBackground, func() error {
+				return processor.ProcessFile(ctx, repo, fileCtx)
+			})
 			if err != nil {
-				ib.logger.Error("Processor failed to process file",
+				ib.log(ctx).Error("Processor failed to process file",
 					zap.String("processor", processor.Name()),
 					zap.String("path", filePath),
 					zap.Error(err))
 				// Continue processing other processors
-			} else {
-				// Update status to indicate this processor completed
+			} else if !alreadyDone {
+				// Update status to indicate this processor completed. A partial build keeps
+				// the done status of a file, as the other processors' output is still current.
 				processorStatus := fmt.Sprintf("%s_done", processor.Name())
 				if err := ib.fileVersionRepo.UpdateStatus(fileCtx.FileID, processorStatus); err != nil {
-					ib.logger.Warn("Failed to update processor status",
+					ib.log(ctx).Warn("Failed to update processor status",
 						zap.String("processor", processor.Name()),
 						zap.Int32("file_id", fileCtx.FileID),
 						zap.Error(err))
@@ -221,44 +504,83 @@ func (ib *IndexBuilder) processFiles(ctx context.Context, repo *config.Repositor
 		}
 
 		// Mark file as fully processed (all processors done)
-		if err := ib.fileVersionRepo.UpdateStatus(fileCtx.FileID, "done"); err != nil {
-			ib.logger.Warn("Failed to update final status",
-				zap.Int32("file_id", fileCtx.FileID),
-				zap.Error(err))
+		if !ib.partial {
+			if err := ib.fileVersionRepo.UpdateStatus(fileCtx.FileID, "done"); err != nil {
+				ib.log(ctx).Warn("Failed to update final status",
+					zap.Int32("file_id", fileCtx.FileID),
+					zap.Error(err))
+			}
 		}
 
 		// Increment file count
+		access.Record(ctx, access.Usage{FilesIndexed: 1})
 		mu.Lock()
 		fileCount++
+		if fileCtx.LightweightReason != "" {
+			summary.FilesLightweight++
+		}
 		mu.Unlock()
 
 		return nil
 	}
 
 	// Walk the directory tree using the utility function
-	err := util.WalkDirTree(repo.Path, walkFunc, skipFunc, ib.logger, gcThreshold, numThreads)
+	err := util.WalkDirTreeWithOptions(ctx, repo.Path, walkFunc, skipFunc, ib.logger, gcThreshold, numThreads, ib.memoryGovernor(ctx), cursor, util.WalkOptionsFor(repo))
+	summary.FilesProcessed = fileCount
+	ib.summary = summary
+	if cause := context.Cause(ctx); errors.Is(cause, cluster.ErrLockLost) {
+		return cause
+	}
 	if err != nil {
 		return fmt.Errorf("failed to walk directory tree: %w", err)
 	}
 
 	if useHead && gitInfo != nil && gitInfo.IsGitRepo {
-		ib.logger.Info("Completed file processing",
+		ib.log(ctx).Info("Completed file processing",
 			zap.String("repo_name", repo.Name),
 			zap.Int("files_processed", fileCount),
+			zap.Int("files_lightweight", summary.FilesLightweight),
+			zap.Any("files_skipped", summary.FilesSkipped),
 			zap.Int("files_from_git_head", filesFromGit),
 			zap.Int("files_from_disk", filesFromDisk))
 	} else {
-		ib.logger.Info("Completed file processing",
+		ib.log(ctx).Info("Completed file processing",
 			zap.String("repo_name", repo.Name),
-			zap.Int("files_processed", fileCount))
+			zap.Int("files_processed", fileCount),
+			zap.Int("files_lightweight", summary.FilesLightweight),
+			zap.Any("files_skipped", summary.FilesSkipped))
 	}
 
 	return nil
 }
 
+// memoryGovernor creates the governor for one build from the configured budget, registering
+// processors that can flush their buffers. Returns nil when no budget is configured.
+func (ib *IndexBuilder) memoryGovernor(ctx context.Context) *util.MemoryGovernor {
+	if ib.config.App.MemoryBudgetMB <= 0 {
+		return nil
+	}
+	governor := util.NewMemoryGovernor(uint64(ib.config.App.MemoryBudgetMB)*1024*1024, ib.logger)
+	for _, processor := range ib.processors {
+		flusher, ok := processor.(BufferFlusher)
+		if !ok {
+			continue
+		}
+		name := processor.Name()
+		governor.RegisterFlusher(name, func() {
+			if err := flusher.FlushBuffers(ctx); err != nil {
+				ib.log(ctx).Warn("Failed to flush buffers under memory pressure",
+					zap.String("processor", name),
+					zap.Error(err))
+			}
+		})
+	}
+	return governor
+}
+
 // postProcessRepository runs post-processing steps for all processors in parallel
 func (ib *IndexBuilder) postProcessRepository(ctx context.Context, repo *config.Repository) error {
-	ib.logger.Info("Running post-processing steps",
+	ib.log(ctx).Info("Running post-processing steps",
 		zap.String("repo_name", repo.Name))
 
 	var wg sync.WaitGroup
@@ -269,12 +591,12 @@ func (ib *IndexBuilder) postProcessRepository(ctx context.Context, repo *config.
 		wg.Add(1)
 		go func(p FileProcessor) {
 			defer wg.Done()
-			ib.logger.Info("Starting post-processing",
+			ib.log(ctx).Info("Starting post-processing",
 				zap.String("processor", p.Name()),
 				zap.String("repo_name", repo.Name))
 
 			if err := p.PostProcess(ctx, repo); err != nil {
-				ib.logger.Error("Post-processing failed",
+				ib.log(ctx).Error("Post-processing failed",
 					zap.String("processor", p.Name()),
 					zap.String("repo_name", repo.Name),
 					zap.Error(err))
@@ -282,7 +604,7 @@ func (ib *IndexBuilder) postProcessRepository(ctx context.Context, repo *config.
 				return
 			}
 
-			ib.logger.Info("Completed post-processing",
+			ib.log(ctx).Info("Completed post-processing",
 				zap.String("processor", p.Name()),
 				zap.String("repo_name", repo.Name))
 		}(processor)
@@ -302,7 +624,17 @@ func (ib *IndexBuilder) postProcessRepository(ctx context.Context, repo *config.
 		return fmt.Errorf("post-processing encountered %d error(s): %v", len(errors), errors)
 	}
 
-	ib.logger.Info("Completed all post-processing steps",
+	for _, processor := range ib.processors {
+		finalizer, ok := processor.(Finalizer)
+		if !ok {
+			continue
+		}
+		if err := finalizer.Finalize(ctx, repo); err != nil {
+			return fmt.Errorf("processor %s finalization failed: %w", processor.Name(), err)
+		}
+	}
+
+	ib.log(ctx).Info("Completed all post-processing steps",
 		zap.String("repo_name", repo.Name))
 
 	return nil
@@ -343,6 +675,10 @@ func (ib *IndexBuilder) createFileContext(repoPath, filePath string, content []b
 			// Using HEAD mode and file is unmodified, use HEAD commit
 			commitID = &gitInfo.HeadCommitSHA
 		}
+	} else if ib.archiveVersion != "" {
+		// Files of a versioned archive are recorded under its version
+		version := ib.archiveVersion
+		commitID = &version
 	} else {
 		// Not a git repo, all files are ephemeral
 		ephemeral = true
@@ -354,7 +690,7 @@ func (ib *IndexBuilder) createFileContext(repoPath, filePath string, content []b
 		return nil, fmt.Errorf("failed to get or create FileID: %w", err)
 	}
 
-	return &FileContext{
+	fileCtx := &FileContext{
 		FileID:       fileID,
 		FilePath:     filePath,
 		RelativePath: relativePath,
@@ -362,5 +698,15 @@ func (ib *IndexBuilder) createFileContext(repoPath, filePath string, content []b
 		FileSHA:      fileSHA,
 		CommitID:     commitID,
 		Ephemeral:    ephemeral,
-	}, nil
+	}
+	if subDir, subCommit := util.SubmoduleFor(gitInfo, filePath); subDir != "" {
+		fileCtx.Submodule = util.ToRelativePath(repoPath, subDir)
+		fileCtx.SubmoduleCommit = subCommit
+	}
+	return fileCtx, nil
+}
+
+// log returns the logger with the request ID carried by ctx attached
+func (ib *IndexBuilder) log(ctx context.Context) *zap.Logger {
+	return logging.FromContext(ctx, ib.logger)
 }
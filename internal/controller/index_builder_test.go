@@ -0,0 +1,173 @@
+package controller
+
+import (
+	"bot-go/internal/cluster"
+	"bot-go/internal/config"
+	"bot-go/internal/db"
+	"bot-go/internal/testenv"
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// countingProcessor counts the files it processes and the post-processing runs
+type countingProcessor struct {
+	name  string
+	files atomic.Int32
+	posts atomic.Int32
+}
+
+func (p *countingProcessor) ProcessFile(ctx context.Context, repo *config.Repository, fileCtx *FileContext) error {
+	p.files.Add(1)
+	return nil
+}
+
+func (p *countingProcessor) PostProcess(ctx context.Context, repo *config.Repository) error {
+	p.posts.Add(1)
+	return nil
+}
+
+func (p *countingProcessor) Name() string { return p.name }
+
+func TestPartialBuildKeepsDoneStatus(t *testing.T) {
+	env := testenv.Start(t, testenv.Options{MySQL: true})
+	ctx := context.Background()
+
+	conn, err := db.NewMySQLConnection(env.Config.MySQL, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to connect to MySQL: %v", err)
+	}
+	defer conn.Close()
+	if err := conn.EnsureDatabase(env.Config.MySQL.Database); err != nil {
+		t.Fatal(err)
+	}
+	fileVersionRepo, err := db.NewFileVersionRepository(conn.GetDB(), env.Repo.Name, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	build := func(names ...string) (codeGraph, embedding *countingProcessor) {
+		codeGraph = &countingProcessor{name: "CodeGraph"}
+		embedding = &countingProcessor{name: "Embedding"}
+		ib := NewIndexBuilder(env.Config, []FileProcessor{codeGraph, embedding}, fileVersionRepo, zap.NewNop())
+		if err := ib.SelectProcessors(names); err != nil {
+			t.Fatal(err)
+		}
+		if err := ib.BuildIndex(ctx, env.Repo); err != nil {
+			t.Fatal(err)
+		}
+		return codeGraph, embedding
+	}
+	assertDone := func(when string) {
+		t.Helper()
+		files, err := fileVersionRepo.GetAllFiles()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) == 0 {
+			t.Fatalf("%s: no files recorded", when)
+		}
+		for _, f := range files {
+			if f.Status != "done" {
+				t.Errorf("%s: %s has status %q, want done", when, f.RelativePath, f.Status)
+			}
+		}
+	}
+
+	build()
+	assertDone("after the full build")
+
+	// Re-embedding reprocesses every file without undoing the full build
+	codeGraph, embedding := build("Embedding")
+	if codeGraph.files.Load() != 0 || embedding.files.Load() == 0 {
+		t.Errorf("partial build processed %d files with CodeGraph and %d with Embedding", codeGraph.files.Load(), embedding.files.Load())
+	}
+	assertDone("after the partial build")
+
+	// So the next full build has nothing to do
+	codeGraph, embedding = build()
+	if codeGraph.files.Load() != 0 || embedding.files.Load() != 0 {
+		t.Errorf("full build reprocessed %d files with CodeGraph and %d with Embedding", codeGraph.files.Load(), embedding.files.Load())
+	}
+}
+
+// lostLocker hands out locks that are no longer held once checked a number of times
+type lostLocker struct {
+	heldChecks int
+	checks     atomic.Int32
+	unlocked   atomic.Bool
+}
+
+func (l *lostLocker) Lock(ctx context.Context, name string, wait time.Duration) (cluster.Lock, error) {
+	return l, nil
+}
+
+func (l *lostLocker) Held(ctx context.Context) bool {
+	return int(l.checks.Add(1)) <= l.heldChecks
+}
+
+func (l *lostLocker) Unlock() error {
+	l.unlocked.Store(true)
+	return errors.New("connection gone")
+}
+
+func TestCheckBuildLock(t *testing.T) {
+	ctx := context.Background()
+	repo := &config.Repository{Name: "repo"}
+	ib := NewIndexBuilder(&config.Config{}, nil, nil, zap.NewNop())
+	if err := ib.checkBuildLock(ctx, repo); err != nil {
+		t.Errorf("without a lock: %v", err)
+	}
+
+	ib.buildLock = &lostLocker{heldChecks: 1}
+	if err := ib.checkBuildLock(ctx, repo); err != nil {
+		t.Errorf("lock held: %v", err)
+	}
+	if err := ib.checkBuildLock(ctx, repo); !errors.Is(err, cluster.ErrLockLost) {
+		t.Errorf("lock lost: got %v, want ErrLockLost", err)
+	}
+}
+
+func TestBuildStopsWhenLockLost(t *testing.T) {
+	env := testenv.Start(t, testenv.Options{MySQL: true})
+
+	conn, err := db.NewMySQLConnection(env.Config.MySQL, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to connect to MySQL: %v", err)
+	}
+	defer conn.Close()
+	if err := conn.EnsureDatabase(env.Config.MySQL.Database); err != nil {
+		t.Fatal(err)
+	}
+	fileVersionRepo, err := db.NewFileVersionRepository(conn.GetDB(), env.Repo.Name, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One thread, so no file is in flight when the lock is found lost
+	env.Config.App.NumFileThreads = 1
+	processor := &countingProcessor{name: "CodeGraph"}
+	ib := NewIndexBuilder(env.Config, []FileProcessor{processor}, fileVersionRepo, zap.NewNop())
+	locker := &lostLocker{}
+	ib.SetLocker(locker)
+
+	// The lock is checked every lockCheckFiles files and before post-processing, so the
+	// build stops at whichever comes first
+	err = ib.BuildIndex(context.Background(), env.Repo)
+	if !errors.Is(err, cluster.ErrLockLost) {
+		t.Fatalf("got %v, want ErrLockLost", err)
+	}
+	if n := processor.files.Load(); n >= lockCheckFiles {
+		t.Errorf("processed %d files after losing the lock", n)
+	}
+	if processor.posts.Load() != 0 {
+		t.Error("post-processed after losing the lock")
+	}
+	if !locker.unlocked.Load() {
+		t.Error("lock not released")
+	}
+}
@@ -0,0 +1,224 @@
+package controller
+
+import (
+	"bot-go/internal/config"
+	"bot-go/internal/service/codegraph"
+	"bot-go/internal/util"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// JournalFile returns where the index journal of the repository is kept
+func JournalFile(workDir, repoName string) string {
+	return filepath.Join(workDir, "journals", repoName+".jsonl")
+}
+
+// ReplaySummary counts what happened to the file operations of a journal replay
+type ReplaySummary struct {
+	Entries    int `json:"entries"`    // file operations recorded in the replayed range
+	Replayed   int `json:"replayed"`   // operations run again
+	Superseded int `json:"superseded"` // a later entry covers the same processor and file
+	Skipped    int `json:"skipped"`    // the processor is not enabled or not selected
+	Unreadable int `json:"unreadable"` // the recorded file version can no longer be read
+	Failed     int `json:"failed"`     // the processor returned an error
+}
+
+// openJournal opens the journal of the repository when journaling is enabled. Builds from
+// archives are not journaled, since their files are gone once the build finishes.
+func (ib *IndexBuilder) openJournal(ctx context.Context, repo *config.Repository) *util.IndexJournal {
+	if !ib.config.IndexBuilding.Journal || ib.fromArchive || ib.config.App.WorkDir == "" {
+		return nil
+	}
+	journal, err := util.OpenIndexJournal(JournalFile(ib.config.App.WorkDir, repo.Name))
+	if err != nil {
+		ib.log(ctx).Warn("Index journal unavailable, building without it",
+			zap.String("repo_name", repo.Name),
+			zap.Error(err))
+		return nil
+	}
+	return journal
+}
+
+// closeJournal closes the journal opened for the current build
+func (ib *IndexBuilder) closeJournal(ctx context.Context) {
+	if err := ib.journal.Close(); err != nil {
+		ib.log(ctx).Warn("Failed to close index journal", zap.Error(err))
+	}
+	ib.journal = nil
+}
+
+// appendJournal records an entry; a failed write is logged and does not stop the build
+func (ib *IndexBuilder) appendJournal(ctx context.Context, entry util.JournalEntry) {
+	if err := ib.journal.Append(entry); err != nil {
+		ib.log(ctx).Warn("Failed to write index journal entry",
+			zap.String("op", string(entry.Op)),
+			zap.String("path", entry.Path),
+			zap.Error(err))
+	}
+}
+
+// journalFile records that the processor is about to run on the file
+func (ib *IndexBuilder) journalFile(ctx context.Context, processor FileProcessor, fileCtx *FileContext) {
+	if ib.journal == nil {
+		return
+	}
+	ib.appendJournal(ctx, util.JournalEntry{
+		Op:        journalOp(processor.Name()),
+		Processor: processor.Name(),
+		FileID:    fileCtx.FileID,
+		Path:      fileCtx.RelativePath,
+		SHA:       fileCtx.FileSHA,
+		Commit:    fileCtx.CommitSHA(),
+	})
+}
+
+// journalOp names the journal operation of a processor after the store it writes to
+func journalOp(processorName string) util.JournalOp {
+	switch processorName {
+	case "CodeGraph", "Blame", "Commits", "StringLiterals":
+		return util.JournalNodesWritten
+	case "Embedding":
+		return util.JournalChunksUpserted
+	}
+	return util.JournalFileProcessed
+}
+
+// ReplayJournal runs the file operations journaled for the repository at or after since
+// again, followed by post-processing, to bring stores restored from a backup taken at that
+// time up to date. Only the latest entry for each processor and file is replayed, on the
+// file version it recorded. Processors write idempotently (nodes are upserted by key,
+// chunks by ID), so replaying operations the backup already contains is harmless. File
+// tracking in MySQL is not changed.
+func (ib *IndexBuilder) ReplayJournal(ctx context.Context, repo *config.Repository, since time.Time) (ReplaySummary, error) {
+	var summary ReplaySummary
+	if ib.config.App.WorkDir == "" {
+		return summary, fmt.Errorf("no work directory configured, so there is no journal to replay")
+	}
+	entries, err := util.ReadIndexJournal(JournalFile(ib.config.App.WorkDir, repo.Name), since)
+	if err != nil {
+		return summary, err
+	}
+
+	// Replays read back what they write, as builds do
+	ctx = codegraph.WithLeaderReads(ctx)
+
+	processors := make(map[string]FileProcessor, len(ib.processors))
+	for _, p := range ib.processors {
+		processors[p.Name()] = p
+	}
+
+	type opKey struct{ processor, path string }
+	latest := make(map[opKey]util.JournalEntry)
+	for _, entry := range entries {
+		if !entry.IsFileOp() {
+			continue
+		}
+		summary.Entries++
+		key := opKey{entry.Processor, entry.Path}
+		if _, ok := latest[key]; ok {
+			summary.Superseded++
+		}
+		latest[key] = entry
+	}
+	ops := make([]util.JournalEntry, 0, len(latest))
+	for _, entry := range latest {
+		ops = append(ops, entry)
+	}
+	// Sequence order runs processors on a file in the order the build did
+	sort.Slice(ops, func(i, j int) bool { return ops[i].Seq < ops[j].Seq })
+
+	ib.log(ctx).Info("Replaying index journal",
+		zap.String("repo_name", repo.Name),
+		zap.Time("since", since),
+		zap.Int("entries", summary.Entries),
+		zap.Int("operations", len(ops)))
+
+	files := make(map[opKey]*FileContext) // by path and SHA, read once for all processors
+	for _, entry := range ops {
+		if err := ctx.Err(); err != nil {
+			return summary, err
+		}
+		processor, ok := processors[entry.Processor]
+		if !ok {
+			summary.Skipped++
+			continue
+		}
+
+		fileKey := opKey{entry.Path, entry.SHA}
+		fileCtx, ok := files[fileKey]
+		if !ok {
+			fileCtx = ib.journaledFileContext(ctx, repo, entry)
+			files[fileKey] = fileCtx
+		}
+		if fileCtx == nil {
+			summary.Unreadable++
+			continue
+		}
+
+		err := ib.scheduler.Run(ctx, PriorityBackground, func() error {
+			return processor.ProcessFile(ctx, repo, fileCtx)
+		})
+		if err != nil {
+			ib.log(ctx).Error("Processor failed to replay file",
+				zap.String("processor", processor.Name()),
+				zap.String("path", entry.Path),
+				zap.Error(err))
+			summary.Failed++
+			continue
+		}
+		summary.Replayed++
+	}
+
+	if summary.Replayed > 0 {
+		if err := ib.postProcessRepository(ctx, repo); err != nil {
+			return summary, fmt.Errorf("failed to post-process repository %s: %w", repo.Name, err)
+		}
+	}
+	return summary, nil
+}
+
+// journaledFileContext rebuilds the FileContext of the file version a journal entry
+// recorded, reading committed versions from git and working tree versions from disk while
+// they are unchanged. Returns nil if the version cannot be read.
+func (ib *IndexBuilder) journaledFileContext(ctx context.Context, repo *config.Repository, entry util.JournalEntry) *FileContext {
+	filePath := filepath.Join(repo.Path, filepath.FromSlash(entry.Path))
+
+	var content []byte
+	var err error
+	if entry.Commit != "" {
+		content, err = util.GetFileContentAtCommit(repo.Path, entry.Path, entry.Commit)
+	} else {
+		content, err = os.ReadFile(filePath)
+	}
+	if err == nil && util.CalculateFileSHA256(content) != entry.SHA {
+		err = fmt.Errorf("file changed since it was journaled")
+	}
+	if err != nil {
+		ib.log(ctx).Warn("Cannot replay journaled file version",
+			zap.String("path", entry.Path),
+			zap.String("commit", entry.Commit),
+			zap.Error(err))
+		return nil
+	}
+
+	fileCtx := &FileContext{
+		FileID:       entry.FileID,
+		FilePath:     filePath,
+		RelativePath: entry.Path,
+		Content:      content,
+		FileSHA:      entry.SHA,
+		Ephemeral:    entry.Commit == "",
+	}
+	if entry.Commit != "" {
+		commit := entry.Commit
+		fileCtx.CommitID = &commit
+	}
+	fileCtx.LightweightReason = util.LightweightReason(filePath, content, repo)
+	return fileCtx
+}
@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"bot-go/internal/config"
+	"bot-go/internal/util"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// recordingProcessor records the files it processes
+type recordingProcessor struct {
+	name          string
+	files         []string
+	postProcessed bool
+}
+
+func (p *recordingProcessor) ProcessFile(ctx context.Context, repo *config.Repository, fileCtx *FileContext) error {
+	p.files = append(p.files, fileCtx.RelativePath+"@"+string(fileCtx.Content))
+	return nil
+}
+
+func (p *recordingProcessor) PostProcess(ctx context.Context, repo *config.Repository) error {
+	p.postProcessed = true
+	return nil
+}
+
+func (p *recordingProcessor) Name() string { return p.name }
+
+func TestReplayJournal(t *testing.T) {
+	workDir := t.TempDir()
+	repo := &config.Repository{Name: "repo", Path: t.TempDir()}
+	write := func(rel, content string) {
+		if err := os.WriteFile(filepath.Join(repo.Path, rel), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.go", "a2")
+	write("b.go", "b-changed")
+
+	journal, err := util.OpenIndexJournal(JournalFile(workDir, repo.Name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendFile := func(processor, path, content string) {
+		journal.Append(util.JournalEntry{Op: journalOp(processor), Processor: processor, FileID: 1, Path: path, SHA: util.CalculateFileSHA256([]byte(content))})
+	}
+	appendFile("CodeGraph", "a.go", "a1")
+	appendFile("CodeGraph", "a.go", "a2") // supersedes the first
+	appendFile("Embedding", "a.go", "a2")
+	appendFile("CodeGraph", "b.go", "b") // changed on disk since
+	appendFile("NGram", "a.go", "a2")    // not enabled
+	journal.Append(util.JournalEntry{Op: util.JournalBuildCompleted})
+	journal.Close()
+
+	codeGraph := &recordingProcessor{name: "CodeGraph"}
+	embedding := &recordingProcessor{name: "Embedding"}
+	cfg := &config.Config{App: config.App{WorkDir: workDir}}
+	ib := NewIndexBuilder(cfg, []FileProcessor{codeGraph, embedding}, nil, zap.NewNop())
+
+	summary, err := ib.ReplayJournal(context.Background(), repo, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ReplaySummary{Entries: 5, Replayed: 2, Superseded: 1, Skipped: 1, Unreadable: 1}
+	if summary != want {
+		t.Errorf("summary = %+v, want %+v", summary, want)
+	}
+	if len(codeGraph.files) != 1 || codeGraph.files[0] != "a.go@a2" {
+		t.Errorf("CodeGraph replayed %v", codeGraph.files)
+	}
+	if len(embedding.files) != 1 || embedding.files[0] != "a.go@a2" {
+		t.Errorf("Embedding replayed %v", embedding.files)
+	}
+	if !codeGraph.postProcessed || !embedding.postProcessed {
+		t.Error("expected post-processing after the replay")
+	}
+
+	// Nothing was journaled after the cutoff
+	summary, err = ib.ReplayJournal(context.Background(), repo, time.Now().Add(time.Hour))
+	if err != nil || summary != (ReplaySummary{}) {
+		t.Errorf("replay after cutoff = %+v, %v", summary, err)
+	}
+}
@@ -2,8 +2,10 @@ package controller
 
 import (
 	"bot-go/internal/config"
+	"bot-go/internal/logging"
 	"bot-go/internal/service/ngram"
 	"context"
+	"sync"
 	"sync/atomic"
 
 	"go.uber.org/zap"
@@ -16,6 +18,10 @@ type NGramProcessor struct {
 	n            int  // N-gram size (e.g., 3 for trigrams)
 	override     bool // Whether to override existing models
 	fileCount    atomic.Int64
+
+	// FileIDs seen during the walk, applied to file models in PostProcess
+	fileIDs   map[string]ngram.FileIdentity
+	fileIDsMu sync.Mutex
 }
 
 // NewNGramProcessor creates a new n-gram processor
@@ -25,6 +31,7 @@ func NewNGramProcessor(ngramService *ngram.NGramService, n int, override bool, l
 		logger:       logger,
 		n:            n,
 		override:     override,
+		fileIDs:      make(map[string]ngram.FileIdentity),
 	}
 }
 
@@ -35,17 +42,27 @@ func (np *NGramProcessor) Name() string {
 
 // ProcessFile processes a single file for n-gram model building
 func (np *NGramProcessor) ProcessFile(ctx context.Context, repo *config.Repository, fileCtx *FileContext) error {
-	np.logger.Debug("Processing file for n-gram model",
+	// Lightweight files are also left out of the corpus by the service's own walk
+	if fileCtx.LightweightReason != "" {
+		return nil
+	}
+
+	np.log(ctx).Debug("Processing file for n-gram model",
 		zap.String("path", fileCtx.FilePath),
 		zap.Int32("file_id", fileCtx.FileID))
 
 	// The actual file processing happens in the service's ProcessRepository method
 	// which handles tokenization and n-gram extraction
-	// Here we just track that we've seen the file
-	// FileID is available in fileCtx but not used for n-grams yet
+	// Here we just record the file's identity so file models can be looked up by FileID
+	np.fileIDsMu.Lock()
+	np.fileIDs[fileCtx.FilePath] = ngram.FileIdentity{
+		FileID:    fileCtx.FileID,
+		CommitSHA: fileCtx.CommitSHA(),
+	}
+	np.fileIDsMu.Unlock()
 	np.fileCount.Add(1)
 
-	np.logger.Debug("Tracked file for n-gram processing",
+	np.log(ctx).Debug("Tracked file for n-gram processing",
 		zap.String("path", fileCtx.FilePath),
 		zap.Int32("file_id", fileCtx.FileID))
 	return nil
@@ -53,13 +70,18 @@ func (np *NGramProcessor) ProcessFile(ctx context.Context, repo *config.Reposito
 
 // PostProcess performs n-gram model building for the entire repository
 func (np *NGramProcessor) PostProcess(ctx context.Context, repo *config.Repository) error {
-	np.logger.Info("Building n-gram model",
+	np.log(ctx).Info("Building n-gram model",
 		zap.String("repo_name", repo.Name),
 		zap.Int("n", np.n))
 
-	err := np.ngramService.ProcessRepository(ctx, repo, np.n, np.override)
+	np.fileIDsMu.Lock()
+	fileIDs := np.fileIDs
+	np.fileIDs = make(map[string]ngram.FileIdentity)
+	np.fileIDsMu.Unlock()
+
+	err := np.ngramService.ProcessRepositoryWithFileIDs(ctx, repo, np.n, np.override, fileIDs)
 	if err != nil {
-		np.logger.Error("Failed to build n-gram model",
+		np.log(ctx).Error("Failed to build n-gram model",
 			zap.String("repo_name", repo.Name),
 			zap.Error(err))
 		return err
@@ -68,11 +90,11 @@ func (np *NGramProcessor) PostProcess(ctx context.Context, repo *config.Reposito
 	// Get and log statistics
 	stats, err := np.ngramService.GetRepositoryStats(ctx, repo.Name)
 	if err != nil {
-		np.logger.Error("Failed to get n-gram stats",
+		np.log(ctx).Error("Failed to get n-gram stats",
 			zap.String("repo_name", repo.Name),
 			zap.Error(err))
 	} else {
-		np.logger.Info("N-gram model built successfully",
+		np.log(ctx).Info("N-gram model built successfully",
 			zap.String("repo_name", repo.Name),
 			zap.Int("n", np.n),
 			zap.Int("files", stats.TotalFiles),
@@ -83,3 +105,8 @@ func (np *NGramProcessor) PostProcess(ctx context.Context, repo *config.Reposito
 	np.fileCount.Store(0)
 	return nil
 }
+
+// log returns the logger with the request ID carried by ctx attached
+func (np *NGramProcessor) log(ctx context.Context) *zap.Logger {
+	return logging.FromContext(ctx, np.logger)
+}
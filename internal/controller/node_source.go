@@ -0,0 +1,206 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"bot-go/internal/config"
+	"bot-go/internal/model"
+	"bot-go/internal/model/ast"
+	"bot-go/internal/service/codegraph"
+	"bot-go/internal/util"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// GetNodeSource returns the source text of a Function or Class node, read from the file
+// version recorded on its FileScope. With ?context=N the N lines before and after the node
+// are returned as well.
+func (rc *RepoController) GetNodeSource(c *gin.Context) {
+	if rc.codeGraph == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "code graph is not enabled"})
+		return
+	}
+
+	nodeID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, invalidRequestBody(&ValidationError{
+			Fields: []FieldError{{Field: "id", Message: "must be a numeric node ID"}},
+		}))
+		return
+	}
+	contextLines := 0
+	if raw := c.Query("context"); raw != "" {
+		contextLines, err = strconv.Atoi(raw)
+		if err != nil || contextLines < 0 || contextLines > maxContextLines {
+			c.JSON(http.StatusBadRequest, invalidRequestBody(&ValidationError{
+				Fields: []FieldError{{Field: "context", Message: "must be an integer between 0 and " + strconv.Itoa(maxContextLines)}},
+			}))
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	found, err := rc.codeGraph.FindNodeFile(ctx, ast.NodeID(nodeID), ast.NodeTypeFunction, ast.NodeTypeClass)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	repo, err := rc.config.GetRepository(found.RepoName)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	content, reason := rc.nodeFileContent(ctx, repo, found)
+	if reason != "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Source not available", "details": reason})
+		return
+	}
+
+	rng := found.Node.Range
+	index := util.NewLineIndex(content)
+	if rng.Start.Line < 0 || rng.End.Line < rng.Start.Line || rng.End.Line >= index.LineCount() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Source not available", "details": "node range is outside the file"})
+		return
+	}
+
+	response := model.NodeSourceResponse{
+		NodeID:   nodeID,
+		Type:     found.Label,
+		Name:     found.Node.Name,
+		RepoName: repo.Name,
+		Path:     found.FilePath,
+		Commit:   found.Commit,
+		Range:    rng,
+		Source:   string(index.Slice(rng.Start.Line, rng.Start.Character, rng.End.Line, rng.End.Character)),
+	}
+	for line := max(0, rng.Start.Line-contextLines); line < rng.Start.Line; line++ {
+		response.ContextBefore = append(response.ContextBefore, string(index.Line(line)))
+	}
+	for line := rng.End.Line + 1; line <= rng.End.Line+contextLines && line < index.LineCount(); line++ {
+		response.ContextAfter = append(response.ContextAfter, string(index.Line(line)))
+	}
+
+	rc.log(c).Debug("Node source",
+		zap.String("repo_name", repo.Name),
+		zap.Int64("node_id", nodeID),
+		zap.String("path", found.FilePath))
+	c.JSON(http.StatusOK, response)
+}
+
+// NodeBatchRequest asks for the details of many nodes in one round trip
+type NodeBatchRequest struct {
+	NodeIDs       []int64 `json:"node_ids" binding:"required"`
+	IncludeSource bool    `json:"include_source"` // Add the source text of each node's range
+}
+
+// GetNodesBatch hydrates a list of node IDs with their type, name, range, file and metadata,
+// and optionally their source text. Each file is read once however many of its nodes are
+// requested; unknown IDs are listed under "missing".
+func (rc *RepoController) GetNodesBatch(c *gin.Context) {
+	if rc.codeGraph == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "code graph is not enabled"})
+		return
+	}
+
+	var request NodeBatchRequest
+	if err := bindRequest(c, &request); err != nil {
+		rc.log(c).Error("Invalid request", zap.Error(err))
+		c.JSON(http.StatusBadRequest, invalidRequestBody(err))
+		return
+	}
+
+	ctx := c.Request.Context()
+	ids := make([]ast.NodeID, len(request.NodeIDs))
+	for i, id := range request.NodeIDs {
+		ids[i] = ast.NodeID(id)
+	}
+	found, err := rc.codeGraph.FindNodeFiles(ctx, ids)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	byID := make(map[int64]*codegraph.NodeFile, len(found))
+	for _, f := range found {
+		byID[int64(f.Node.ID)] = f
+	}
+
+	type fileContent struct {
+		index  *util.LineIndex
+		commit string
+		reason string
+	}
+	files := make(map[string]*fileContent)
+	readFile := func(f *codegraph.NodeFile) *fileContent {
+		key := f.RepoName + "\x00" + strconv.Itoa(int(f.Node.FileID))
+		if fc, ok := files[key]; ok {
+			return fc
+		}
+		fc := &fileContent{}
+		if repo, err := rc.config.GetRepository(f.RepoName); err != nil {
+			fc.reason = "repository is not configured"
+		} else if content, reason := rc.nodeFileContent(ctx, repo, f); reason != "" {
+			fc.reason = reason
+		} else {
+			fc.index = util.NewLineIndex(content)
+		}
+		fc.commit = f.Commit
+		files[key] = fc
+		return fc
+	}
+
+	response := model.NodeBatchResponse{Nodes: make([]model.NodeDetail, 0, len(found))}
+	seen := make(map[int64]bool, len(request.NodeIDs))
+	for _, id := range request.NodeIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		f, ok := byID[id]
+		if !ok {
+			response.Missing = append(response.Missing, id)
+			continue
+		}
+		detail := model.NodeDetail{
+			NodeID:   id,
+			Type:     f.Label,
+			Name:     f.Node.Name,
+			RepoName: f.RepoName,
+			Path:     f.FilePath,
+			Commit:   f.Commit,
+			Range:    f.Node.Range,
+			MetaData: f.Node.MetaData,
+		}
+		if request.IncludeSource {
+			if f.FilePath == "" {
+				detail.SourceUnavailable = "node has no file"
+			} else if fc := readFile(f); fc.index == nil {
+				detail.SourceUnavailable = fc.reason
+			} else {
+				rng := f.Node.Range
+				detail.Commit = fc.commit // may come from file tracking
+				detail.Source = string(fc.index.Slice(rng.Start.Line, rng.Start.Character, rng.End.Line, rng.End.Character))
+			}
+		}
+		response.Nodes = append(response.Nodes, detail)
+	}
+
+	rc.log(c).Debug("Node batch",
+		zap.Int("requested", len(request.NodeIDs)),
+		zap.Int("found", len(response.Nodes)),
+		zap.Int("files_read", len(files)))
+	c.JSON(http.StatusOK, response)
+}
+
+// nodeFileContent reads the file version a node was parsed from. A FileScope without a
+// commit takes the one file tracking recorded for it, if any; found.Commit is updated.
+func (rc *RepoController) nodeFileContent(ctx context.Context, repo *config.Repository, found *codegraph.NodeFile) ([]byte, string) {
+	fv := rc.fileVersionsByID(repo, found.FilePath)[found.Node.FileID]
+	if found.Commit == "" && fv != nil && fv.CommitID != nil {
+		found.Commit = *fv.CommitID
+	}
+	return rc.indexedFileContent(ctx, repo, found.FilePath, found.Commit, fv, true)
+}
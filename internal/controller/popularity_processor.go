@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"bot-go/internal/config"
+	"bot-go/internal/logging"
+	"bot-go/internal/service/codegraph"
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// PopularityProcessor implements FileProcessor for popularity metrics: once the build has
+// resolved calls, it stores the reference counts of the repository's functions, classes,
+// fields and files as node properties (see codegraph.ComputePopularity), which completion,
+// symbol search and impact ranking read. It has no per-file work.
+type PopularityProcessor struct {
+	codeGraph *codegraph.CodeGraph
+	logger    *zap.Logger
+}
+
+// NewPopularityProcessor creates a new popularity processor
+func NewPopularityProcessor(codeGraph *codegraph.CodeGraph, logger *zap.Logger) *PopularityProcessor {
+	return &PopularityProcessor{
+		codeGraph: codeGraph,
+		logger:    logger,
+	}
+}
+
+// Name returns the processor name
+func (pp *PopularityProcessor) Name() string {
+	return "Popularity"
+}
+
+// ProcessFile does nothing; counts are computed over the whole graph in Finalize
+func (pp *PopularityProcessor) ProcessFile(ctx context.Context, repo *config.Repository, fileCtx *FileContext) error {
+	return nil
+}
+
+// PostProcess does nothing; calls are not resolved until the code graph post-processing
+// completes (see Finalize)
+func (pp *PopularityProcessor) PostProcess(ctx context.Context, repo *config.Repository) error {
+	return nil
+}
+
+// Finalize computes and stores the reference counts of the repository (see Finalizer)
+func (pp *PopularityProcessor) Finalize(ctx context.Context, repo *config.Repository) error {
+	stats, err := pp.codeGraph.ComputePopularity(ctx, repo.Name)
+	if err != nil {
+		return fmt.Errorf("failed to compute popularity: %w", err)
+	}
+	logging.FromContext(ctx, pp.logger).Info("Popularity computed",
+		zap.String("repo_name", repo.Name),
+		zap.Int("functions", stats.Functions),
+		zap.Int("classes", stats.Classes),
+		zap.Int("fields", stats.Fields),
+		zap.Int("files", stats.Files))
+	return nil
+}
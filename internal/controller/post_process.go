@@ -98,16 +98,9 @@ func (pp *PostProcessor) nodeToFunctionDefinition(ctx context.Context, fileUri s
 		Name: functionNode.Name,
 		Location: base.Location{
 			URI: fileUri,
-			Range: base.Range{
-				Start: base.Position{
-					Line:      functionNode.Range.Start.Line,
-					Character: functionNode.Range.Start.Character,
-				},
-				End: base.Position{
-					Line:      functionNode.Range.End.Line,
-					Character: functionNode.Range.End.Character,
-				},
-			},
+			// Graph ranges are stored in LSP units (UTF-16 characters, see util.LineIndex),
+			// so they can be sent to the language server unchanged
+			Range: functionNode.Range,
 		},
 	}
 }
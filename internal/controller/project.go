@@ -0,0 +1,355 @@
+package controller
+
+import (
+	"bot-go/internal/apperrors"
+	"bot-go/internal/config"
+	"bot-go/internal/db"
+	"bot-go/internal/model"
+	"bot-go/internal/service/vector"
+	"bot-go/internal/techstack"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// projectRepos returns the repositories of the named project. A non-empty repoName must be
+// one of them.
+func projectRepos(cfg *config.Config, name, repoName string) ([]string, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("%w: %s", apperrors.ErrProjectNotFound, name)
+	}
+	project, err := cfg.GetProject(name)
+	if err != nil {
+		return nil, err
+	}
+	if repoName != "" && !project.HasRepository(repoName) {
+		return nil, fmt.Errorf("%w: repository %s is not part of project %s", apperrors.ErrInvalidArgument, repoName, name)
+	}
+	return project.Repositories, nil
+}
+
+// filterRepos keeps the repositories that are members, in their original order
+func filterRepos(repos, members []string) []string {
+	keep := make(map[string]bool, len(members))
+	for _, name := range members {
+		keep[name] = true
+	}
+	filtered := make([]string, 0, len(members))
+	for _, name := range repos {
+		if keep[name] {
+			filtered = append(filtered, name)
+		}
+	}
+	return filtered
+}
+
+// mergeProjectResults orders the results of all repositories by score and truncates them to
+// limit. Ties keep repository order.
+func mergeProjectResults(results []model.ProjectCodeResult, limit int) []model.ProjectCodeResult {
+	sort.SliceStable(results, func(a, b int) bool {
+		return results[a].Score > results[b].Score
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// ListProjects lists the configured projects with their repositories
+func (rc *RepoController) ListProjects(c *gin.Context) {
+	projects := make([]model.ProjectInfo, 0, len(rc.config.Source.Projects))
+	for _, project := range rc.config.Source.Projects {
+		projects = append(projects, model.ProjectInfo{
+			Name:         project.Name,
+			Description:  project.Description,
+			Repositories: project.Repositories,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"projects": projects})
+}
+
+// SearchProjectCode searches similar code in every repository of a project and merges the
+// results by score. Each repository's scoring rules apply to its own results.
+func (rc *RepoController) SearchProjectCode(c *gin.Context) {
+	var request model.ProjectSearchRequest
+	if err := bindRequest(c, &request); err != nil {
+		c.JSON(http.StatusBadRequest, invalidRequestBody(err))
+		return
+	}
+	noteQuery(c, "", request.CodeSnippet) // spans the project's repositories
+	if rc.chunkService == nil {
+		serviceUnavailable(c, rc.unavailable, ServiceVectorSearch, "Code chunk service not available")
+		return
+	}
+	projectName := c.Param("name")
+	repos, err := projectRepos(rc.config, projectName, "")
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	limit := request.Limit
+	if limit <= 0 {
+		limit = 10
+	}
+
+	rc.log(c).Info("Searching for similar code in project",
+		zap.String("project", projectName),
+		zap.Int("repos", len(repos)),
+		zap.String("language", request.Language),
+		zap.Int("limit", limit))
+
+	var queryChunks []*model.CodeChunk
+	var results []model.ProjectCodeResult
+	for _, repoName := range repos {
+		adjuster := rc.scoreAdjuster(repoName)
+		fetchLimit := limit
+		if adjuster != nil {
+			fetchLimit = limit * 2
+		}
+		chunks, resultChunks, scores, queryChunkIndices, err := rc.chunkService.SearchSimilarCodeBySnippet(
+			c.Request.Context(),
+			repoName, // the collection is named after the repository
+			request.CodeSnippet,
+			request.Language,
+			fetchLimit,
+			nil,
+			true,
+		)
+		if err != nil {
+			// Parsing and embedding the snippet fail the same way for every repository
+			rc.log(c).Error("Failed to search project for similar code",
+				zap.String("project", projectName),
+				zap.String("repo_name", repoName),
+				zap.Error(err))
+			c.JSON(errorStatus(err), model.ProjectSearchResponse{
+				Project: projectName,
+				Repos:   repos,
+				Query:   model.QueryInfo{CodeSnippet: request.CodeSnippet, Language: request.Language},
+				Results: []model.ProjectCodeResult{},
+				Success: false,
+				Message: fmt.Sprintf("Failed to search: %v", err),
+			})
+			return
+		}
+		queryChunks = chunks
+
+		repoResults := make([]model.SimilarCodeResult, len(resultChunks))
+		for i, chunk := range resultChunks {
+			repoResults[i] = rc.projectCodeResult(c, &request, queryChunks, chunk, scores[i], queryChunkIndices[i])
+		}
+		rc.adjustSimilarCodeScores(c, adjuster, queryChunks, repoResults)
+		for _, result := range repoResults {
+			results = append(results, model.ProjectCodeResult{RepoName: repoName, SimilarCodeResult: result})
+		}
+	}
+	results = mergeProjectResults(results, limit)
+	if results == nil {
+		results = []model.ProjectCodeResult{}
+	}
+	noteResults(c, len(results))
+
+	c.JSON(http.StatusOK, model.ProjectSearchResponse{
+		Project: projectName,
+		Repos:   repos,
+		Query: model.QueryInfo{
+			CodeSnippet: request.CodeSnippet,
+			Language:    request.Language,
+			ChunksFound: len(queryChunks),
+			Chunks:      queryChunks,
+		},
+		Results:     results,
+		Success:     true,
+		Message:     "Search completed successfully",
+		Diagnostics: rc.projectSearchDiagnostics(c, repos, results),
+	})
+}
+
+// projectSearchDiagnostics explains an empty project search with the status of each
+// repository's collection and index
+func (rc *RepoController) projectSearchDiagnostics(c *gin.Context, repos []string, results []model.ProjectCodeResult) []model.SearchDiagnostic {
+	if len(results) > 0 {
+		return nil
+	}
+	d := rc.newSearchDiagnostics(c)
+	for _, repoName := range repos {
+		d.collection(repoName)
+		if repo, err := rc.config.GetRepository(repoName); err == nil {
+			d.indexAge(repo)
+		}
+	}
+	return d.result()
+}
+
+// projectCodeResult builds the result for one matched chunk, reading its code for
+// include_code and the match highlight
+func (rc *RepoController) projectCodeResult(c *gin.Context, request *model.ProjectSearchRequest, queryChunks []*model.CodeChunk, chunk *model.CodeChunk, score float32, queryChunkIndex int) model.SimilarCodeResult {
+	result := model.SimilarCodeResult{
+		Chunk:           chunk,
+		Score:           score,
+		QueryChunkIndex: queryChunkIndex,
+	}
+	var queryChunk *model.CodeChunk
+	if queryChunkIndex >= 0 && queryChunkIndex < len(queryChunks) {
+		queryChunk = queryChunks[queryChunkIndex]
+		result.QueryChunkName = queryChunk.Name
+	}
+	code, err := rc.chunkService.ReadCodeFromFile(chunk.FilePath, chunk.StartLine, chunk.EndLine)
+	if err != nil {
+		rc.log(c).Warn("Failed to read code from file",
+			zap.String("file", chunk.FilePath),
+			zap.Error(err))
+		return result
+	}
+	if request.IncludeCode {
+		result.Code = code
+	}
+	if queryChunk != nil {
+		result.Highlight = vector.ComputeMatchHighlight(queryChunk.Content, code, chunk.StartLine)
+	}
+	return result
+}
+
+// GetRepoStats summarizes the tracked files, graph nodes and technology stack of a repository.
+// With ?refresh=true the stack is profiled again instead of read from the last index build.
+func (rc *RepoController) GetRepoStats(c *gin.Context) {
+	repo, err := rc.config.GetRepository(c.Param("name"))
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	refresh := false
+	if raw := c.Query("refresh"); raw != "" {
+		if refresh, err = strconv.ParseBool(raw); err != nil {
+			c.JSON(http.StatusBadRequest, invalidRequestBody(&ValidationError{
+				Fields: []FieldError{{Field: "refresh", Message: "must be a boolean"}},
+			}))
+			return
+		}
+	}
+	stats, err := rc.repoStats(c, repo, refresh)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+// GetProjectStats summarizes every repository of a project, with totals
+func (rc *RepoController) GetProjectStats(c *gin.Context) {
+	project, err := rc.config.GetProject(c.Param("name"))
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	response := model.ProjectStats{
+		Project:     project.Name,
+		Description: project.Description,
+		Repos:       make([]model.RepoStats, 0, len(project.Repositories)),
+	}
+	var profiles []*techstack.Profile
+	frameworks := make(map[string]bool)
+	for _, repoName := range project.Repositories {
+		repo, err := rc.config.GetRepository(repoName)
+		if err != nil {
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		stats, err := rc.repoStats(c, repo, false)
+		if err != nil {
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		if stats.Stack != nil {
+			profiles = append(profiles, stats.Stack)
+			for _, fw := range stats.Stack.Frameworks {
+				frameworks[fw.Name] = true
+			}
+		}
+		if stats.Files != nil {
+			response.TotalFiles += stats.Files.Total
+		}
+		if stats.GraphNodes != nil {
+			response.TotalNodes += *stats.GraphNodes
+		}
+		response.Repos = append(response.Repos, *stats)
+	}
+	response.Languages = techstack.MergeLanguages(profiles)
+	response.Frameworks = make([]string, 0, len(frameworks))
+	for name := range frameworks {
+		response.Frameworks = append(response.Frameworks, name)
+	}
+	sort.Strings(response.Frameworks)
+	c.JSON(http.StatusOK, response)
+}
+
+// repoStats collects the statistics of a repository from the stores that are enabled
+func (rc *RepoController) repoStats(c *gin.Context, repo *config.Repository, refreshStack bool) (*model.RepoStats, error) {
+	stats := &model.RepoStats{RepoName: repo.Name}
+	for _, project := range rc.config.Source.Projects {
+		if project.HasRepository(repo.Name) {
+			stats.Projects = append(stats.Projects, project.Name)
+		}
+	}
+
+	if rc.mysqlConn != nil {
+		fileVersionRepo, err := db.NewFileVersionRepository(rc.mysqlConn.GetDB(), repo.Name, rc.logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open file versions of %s: %w", repo.Name, err)
+		}
+		files := &model.FileStats{}
+		if files.Total, files.Ephemeral, files.Committed, err = fileVersionRepo.GetStats(); err != nil {
+			return nil, fmt.Errorf("failed to count file versions of %s: %w", repo.Name, err)
+		}
+		if files.Skipped, err = fileVersionRepo.GetSkippedCounts(); err != nil {
+			return nil, err
+		}
+		stats.Files = files
+	}
+
+	if rc.codeGraph != nil {
+		nodes, err := rc.codeGraph.CountRepoNodes(c.Request.Context(), repo.Name)
+		if err != nil {
+			return nil, err
+		}
+		stats.GraphNodes = &nodes
+	}
+
+	stats.Stack = rc.stackProfile(c, repo, refreshStack)
+	return stats, nil
+}
+
+// stackProfile returns the stack profile stored by the last index build of the repository.
+// A repository without one, or a refresh, is profiled now, and the profile stored when there
+// is a work directory. Returns nil if the repository cannot be profiled.
+func (rc *RepoController) stackProfile(c *gin.Context, repo *config.Repository, refresh bool) *techstack.Profile {
+	file := ""
+	if rc.config.App.WorkDir != "" {
+		file = techstack.ProfileFile(rc.config.App.WorkDir, repo.Name)
+	}
+	if file != "" && !refresh {
+		profile, err := techstack.Load(file)
+		if err != nil {
+			rc.log(c).Warn("Ignoring unreadable stack profile", zap.String("repo_name", repo.Name), zap.Error(err))
+		} else if profile != nil {
+			return profile
+		}
+	}
+
+	profile, err := techstack.Analyze(c.Request.Context(), repo.Name, repo.Path)
+	if err != nil {
+		rc.log(c).Warn("Failed to profile repository stack", zap.String("repo_name", repo.Name), zap.Error(err))
+		return nil
+	}
+	if file != "" {
+		if err := techstack.Save(file, profile); err != nil {
+			rc.log(c).Warn("Failed to store stack profile", zap.String("repo_name", repo.Name), zap.Error(err))
+		}
+	}
+	return profile
+}
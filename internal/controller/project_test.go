@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"bot-go/internal/apperrors"
+	"bot-go/internal/config"
+	"bot-go/internal/model"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestProjectRepos(t *testing.T) {
+	cfg := &config.Config{Source: config.SourceConfig{
+		Projects: []config.Project{{Name: "shop", Repositories: []string{"api", "web"}}},
+	}}
+
+	repos, err := projectRepos(cfg, "shop", "web")
+	if err != nil || !reflect.DeepEqual(repos, []string{"api", "web"}) {
+		t.Errorf("projectRepos = %v, %v", repos, err)
+	}
+	if _, err := projectRepos(cfg, "shop", "billing"); !errors.Is(err, apperrors.ErrInvalidArgument) {
+		t.Errorf("repository outside the project: err = %v, want ErrInvalidArgument", err)
+	}
+	if _, err := projectRepos(cfg, "missing", ""); !errors.Is(err, apperrors.ErrProjectNotFound) {
+		t.Errorf("unknown project: err = %v, want ErrProjectNotFound", err)
+	}
+
+	if got := filterRepos([]string{"web", "billing", "api"}, repos); !reflect.DeepEqual(got, []string{"web", "api"}) {
+		t.Errorf("filterRepos = %v", got)
+	}
+}
+
+func TestMergeProjectResults(t *testing.T) {
+	result := func(repo string, score float32) model.ProjectCodeResult {
+		return model.ProjectCodeResult{RepoName: repo, SimilarCodeResult: model.SimilarCodeResult{Score: score}}
+	}
+	merged := mergeProjectResults([]model.ProjectCodeResult{
+		result("api", 0.5), result("api", 0.9), result("web", 0.9), result("web", 0.7),
+	}, 3)
+
+	var got []string
+	for _, r := range merged {
+		got = append(got, r.RepoName)
+	}
+	if want := []string{"api", "web", "web"}; !reflect.DeepEqual(got, want) || merged[2].Score != 0.7 {
+		t.Errorf("merged = %+v", merged)
+	}
+}
@@ -0,0 +1,141 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"bot-go/internal/querylog"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	queryNoteKey = "query_log_note"
+
+	defaultAnalyticsHours = 7 * 24
+	maxAnalyticsHours     = 365 * 24
+	defaultAnalyticsLimit = 20
+	maxAnalyticsLimit     = 200
+)
+
+// queryNote is the query of a search or graph request, noted by its handler for the query log
+type queryNote struct {
+	repoName string
+	query    string
+	results  int
+}
+
+// noteQuery marks the request as a query to log, with the repository and query text it asked
+// for. Requests that never call it are not logged.
+func noteQuery(c *gin.Context, repoName, query string) {
+	c.Set(queryNoteKey, &queryNote{repoName: repoName, query: query})
+}
+
+// noteResults records the number of results a noted query returned
+func noteResults(c *gin.Context, results int) {
+	if note, ok := c.Get(queryNoteKey); ok {
+		note.(*queryNote).results = results
+	}
+}
+
+// graphQuery is the query text of a graph request: the node ID, or the qualified name of the
+// node it looks up by name
+func graphQuery(nodeID int64, filePath, className, name string) string {
+	if nodeID != 0 {
+		return "node:" + strconv.FormatInt(nodeID, 10)
+	}
+	if className != "" {
+		name = className + "." + name
+	}
+	if filePath != "" {
+		name = filePath + ":" + name
+	}
+	return name
+}
+
+// QueryLogMiddleware records the requests whose handler noted a query, with their latency,
+// status and result count. A nil recorder records nothing.
+func QueryLogMiddleware(recorder *querylog.Recorder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if recorder == nil {
+			c.Next()
+			return
+		}
+		start := time.Now()
+		c.Next()
+		value, ok := c.Get(queryNoteKey)
+		if !ok {
+			return
+		}
+		note := value.(*queryNote)
+		recorder.Record(querylog.Entry{
+			Time:      start,
+			Endpoint:  c.Request.Method + " " + c.FullPath(),
+			RepoName:  note.repoName,
+			Query:     note.query,
+			LatencyMs: time.Since(start).Milliseconds(),
+			Results:   note.results,
+			Status:    c.Writer.Status(),
+		})
+	}
+}
+
+// SetQueryLog enables the query analytics endpoint
+func (rc *RepoController) SetQueryLog(queryLog *querylog.Recorder) {
+	rc.queryLog = queryLog
+}
+
+// GetQueryAnalytics summarizes the query log: per-endpoint counts and latencies, the most
+// frequent queries and the most frequent queries that found nothing. ?since_hours= sets the
+// period (default 7 days), ?repo= restricts it to one repository and ?limit= bounds the
+// query lists.
+func (rc *RepoController) GetQueryAnalytics(c *gin.Context) {
+	if rc.queryLog == nil {
+		serviceUnavailable(c, rc.unavailable, ServiceMySQL, "Query log is not enabled (logging.query_log.enabled, requires MySQL)")
+		return
+	}
+
+	hours, ok := queryInt(c, "since_hours", defaultAnalyticsHours, maxAnalyticsHours)
+	if !ok {
+		return
+	}
+	limit, ok := queryInt(c, "limit", defaultAnalyticsLimit, maxAnalyticsLimit)
+	if !ok {
+		return
+	}
+	repoName := c.Query("repo")
+	if repoName != "" {
+		if _, err := rc.config.GetRepository(repoName); err != nil {
+			c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	since := time.Now().Add(-time.Duration(hours) * time.Hour)
+	summary, err := rc.queryLog.Summary(c.Request.Context(), since, repoName, limit)
+	if err != nil {
+		rc.log(c).Error("Failed to summarize query log", zap.Error(err))
+		c.JSON(errorStatus(err), gin.H{"error": "Failed to summarize query log", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, summary)
+}
+
+// queryInt parses an optional positive integer query parameter, responding 400 when it is
+// invalid
+func queryInt(c *gin.Context, name string, defaultValue, maxValue int) (int, bool) {
+	raw := c.Query(name)
+	if raw == "" {
+		return defaultValue, true
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < 1 || value > maxValue {
+		c.JSON(http.StatusBadRequest, invalidRequestBody(&ValidationError{
+			Fields: []FieldError{{Field: name, Message: "must be an integer between 1 and " + strconv.Itoa(maxValue)}},
+		}))
+		return 0, false
+	}
+	return value, true
+}
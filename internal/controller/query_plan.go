@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"net/http"
+
+	"bot-go/internal/service/codegraph"
+
+	"github.com/gin-gonic/gin"
+)
+
+// QueryPlanMiddleware captures the Cypher plans of a request's graph reads when it asks for
+// them with ?explain=true (EXPLAIN: planned operators and row estimates) or ?explain=profile
+// (PROFILE: rows and db hits per operator, each distinct query runs once more). Handlers
+// attach the plans to their response with withQueryPlans.
+func QueryPlanMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var mode codegraph.PlanMode
+		switch c.Query("explain") {
+		case "", "false":
+			c.Next()
+			return
+		case "true":
+			mode = codegraph.PlanExplain
+		case "profile":
+			mode = codegraph.PlanProfile
+		default:
+			c.AbortWithStatusJSON(http.StatusBadRequest, invalidRequestBody(&ValidationError{
+				Fields: []FieldError{{Field: "explain", Message: `must be "true", "profile" or "false"`}},
+			}))
+			return
+		}
+		ctx, _ := codegraph.WithPlanCapture(c.Request.Context(), mode)
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// withQueryPlans adds the plans captured for the request, if any, to a response body under
+// "query_plans"
+func withQueryPlans(c *gin.Context, body gin.H) gin.H {
+	capture := codegraph.PlanCaptureFrom(c.Request.Context())
+	if capture == nil {
+		return body
+	}
+	plans := gin.H{"queries": capture.Plans()}
+	if skipped := capture.Skipped(); skipped > 0 {
+		plans["queries_not_planned"] = skipped
+	}
+	body["query_plans"] = plans
+	return body
+}
@@ -1,19 +1,37 @@
 package controller
 
 import (
+	"bot-go/internal/access"
+	"bot-go/internal/apperrors"
+	"bot-go/internal/cluster"
+	"bot-go/internal/codeapi"
 	"bot-go/internal/config"
 	"bot-go/internal/db"
+	"bot-go/internal/logging"
+	"bot-go/internal/model/ast"
 	"bot-go/internal/service/ngram"
+	"bot-go/internal/service/textsearch"
 	"bot-go/internal/service/vector"
+	"bot-go/internal/storage"
 	"bot-go/internal/util"
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"bot-go/internal/model"
+	"bot-go/internal/querylog"
 	"bot-go/internal/service"
+	"bot-go/internal/service/codecard"
+	"bot-go/internal/service/codegraph"
+	"bot-go/internal/service/completion"
+	"bot-go/internal/service/session"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -23,56 +41,90 @@ type RepoController struct {
 	repoService *service.RepoService
 	chunkService *vector.CodeChunkService
 	ngramService *ngram.NGramService
+	textSearch   *textsearch.TextSearchService
+	codeCards    *codecard.Service
+	completions  *completion.Service
 	processors   []FileProcessor
+	scheduler    *ProcessingScheduler
+	locker       cluster.Locker // optional; excludes concurrent builds of a repository
+	store        storage.Store  // optional; API snapshots of library repositories
 	mysqlConn    *db.MySQLConnection
+	codeGraph    *codegraph.CodeGraph
+	codeAPI      codeapi.CodeAPI
 	config       *config.Config
+	sessions     *session.Store
+	unavailable  UnavailableServices // optional subsystems the server started without
+	queryLog     *querylog.Recorder
 	logger       *zap.Logger
 }
 
-func NewRepoController(repoService *service.RepoService, chunkService *vector.CodeChunkService, ngramService *ngram.NGramService, processors []FileProcessor, mysqlConn *db.MySQLConnection, config *config.Config, logger *zap.Logger) *RepoController {
+func NewRepoController(repoService *service.RepoService, chunkService *vector.CodeChunkService, ngramService *ngram.NGramService, processors []FileProcessor, scheduler *ProcessingScheduler, mysqlConn *db.MySQLConnection, config *config.Config, logger *zap.Logger) *RepoController {
 	return &RepoController{
 		repoService:  repoService,
 		chunkService: chunkService,
 		ngramService: ngramService,
 		processors:   processors,
+		scheduler:    scheduler,
 		mysqlConn:    mysqlConn,
 		config:       config,
 		logger:       logger,
 	}
 }
 
+// SetSessionStore enables recording retrieved chunks in the session named by X-Session-ID
+func (rc *RepoController) SetSessionStore(store *session.Store) {
+	rc.sessions = store
+}
+
+// SetLocker makes the builds this controller starts exclusive per repository, answering
+// 409 while another build of the repository holds the lock
+func (rc *RepoController) SetLocker(locker cluster.Locker) {
+	rc.locker = locker
+}
+
+// SetStorage sets where the builds this controller starts keep API snapshots, which the API
+// surface endpoints read
+func (rc *RepoController) SetStorage(store storage.Store) {
+	rc.store = store
+}
+
+// SetCodeGraph enables the endpoints that read the code graph, such as function history
+func (rc *RepoController) SetCodeGraph(codeGraph *codegraph.CodeGraph) {
+	rc.codeGraph = codeGraph
+}
+
 type BuildIndexRequest struct {
-	RepoName string `json:"repo_name" binding:"required"`
-	UseHead  bool   `json:"use_head"` // Use git HEAD version instead of working directory
+	RepoName   string   `json:"repo_name" binding:"required"`
+	UseHead    bool     `json:"use_head"`   // Use git HEAD version instead of working directory
+	Processors []string `json:"processors"` // Run only these processors (e.g. "CodeGraph", "Embedding", "NGram"); empty = all
 }
 
 type BuildIndexResponse struct {
-	RepoName string `json:"repo_name"`
-	Status   string `json:"status"`
-	Message  string `json:"message,omitempty"`
+	RepoName string        `json:"repo_name"`
+	Status   string        `json:"status"`
+	Message  string        `json:"message,omitempty"`
+	Summary  *BuildSummary `json:"summary,omitempty"`
 }
 
 func (rc *RepoController) BuildIndex(c *gin.Context) {
 	var request BuildIndexRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
-		rc.logger.Error("Invalid request payload", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request payload",
-			"details": err.Error(),
-		})
+	if err := bindRequest(c, &request); err != nil {
+		rc.log(c).Error("Invalid request payload", zap.Error(err))
+		c.JSON(http.StatusBadRequest, invalidRequestBody(err))
 		return
 	}
 
-	rc.logger.Info("Processing repository",
+	rc.log(c).Info("Processing repository",
 		zap.String("repo_name", request.RepoName),
-		zap.Bool("use_head", request.UseHead))
+		zap.Bool("use_head", request.UseHead),
+		zap.Strings("processors", request.Processors))
 
 	ctx := c.Request.Context()
 
 	// Validate repository exists in config
 	repo, err := rc.config.GetRepository(request.RepoName)
 	if err != nil {
-		rc.logger.Error("Repository not found in configuration",
+		rc.log(c).Error("Repository not found in configuration",
 			zap.String("repo_name", request.RepoName),
 			zap.Error(err))
 		c.JSON(http.StatusNotFound, gin.H{
@@ -82,47 +134,27 @@ func (rc *RepoController) BuildIndex(c *gin.Context) {
 		return
 	}
 
-	// Check if MySQL connection is available
-	if rc.mysqlConn == nil {
-		rc.logger.Error("MySQL connection not available")
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "MySQL connection not available for file tracking",
-		})
-		return
-	}
-
-	// Create FileVersionRepository for this repository
-	fileVersionRepo, err := db.NewFileVersionRepository(rc.mysqlConn.GetDB(), repo.Name, rc.logger)
-	if err != nil {
-		rc.logger.Error("Failed to create file version repository",
-			zap.String("repo_name", repo.Name),
-			zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to initialize file tracking",
-			"details": err.Error(),
-		})
+	indexBuilder := rc.newIndexBuilder(c, repo, request.Processors)
+	if indexBuilder == nil {
 		return
 	}
 
-	// Create index builder with processors
-	indexBuilder := NewIndexBuilder(rc.config, rc.processors, fileVersionRepo, rc.logger)
-
 	// Get git info if using HEAD mode
 	var gitInfo *util.GitInfo
 	if request.UseHead {
 		gitInfo, err = util.GetGitInfo(repo.Path)
 		if err != nil {
-			rc.logger.Error("Failed to get git info",
+			rc.log(c).Error("Failed to get git info",
 				zap.String("repo_name", repo.Name),
 				zap.Error(err))
-			c.JSON(http.StatusInternalServerError, gin.H{
+			c.JSON(errorStatus(err), gin.H{
 				"error":   "Failed to get git information",
 				"details": err.Error(),
 			})
 			return
 		}
 		if !gitInfo.IsGitRepo {
-			rc.logger.Error("Repository is not a git repository, cannot use use_head flag",
+			rc.log(c).Error("Repository is not a git repository, cannot use use_head flag",
 				zap.String("repo_name", repo.Name),
 				zap.String("path", repo.Path))
 			c.JSON(http.StatusBadRequest, gin.H{
@@ -134,121 +166,166 @@ func (rc *RepoController) BuildIndex(c *gin.Context) {
 
 	// Build indexes
 	if err := indexBuilder.BuildIndexWithGitInfo(ctx, repo, request.UseHead, gitInfo); err != nil {
-		rc.logger.Error("Failed to build indexes for repository",
+		rc.log(c).Error("Failed to build indexes for repository",
 			zap.String("repo_name", repo.Name),
 			zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(errorStatus(err), gin.H{
 			"error":   "Failed to process repository",
 			"details": err.Error(),
 		})
 		return
 	}
 
-	rc.logger.Info("Successfully processed repository",
+	rc.log(c).Info("Successfully processed repository",
 		zap.String("repo_name", repo.Name),
 		zap.Bool("use_head", request.UseHead))
 
+	summary := indexBuilder.Summary()
 	c.JSON(http.StatusOK, BuildIndexResponse{
 		RepoName: repo.Name,
 		Status:   "completed",
 		Message:  "Repository indexed successfully",
+		Summary:  &summary,
 	})
 }
 
-func (rc *RepoController) GetFunctionsInFile(c *gin.Context) {
-	var request model.GetFunctionsInFileRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
-		rc.logger.Error("Invalid request payload", zap.Error(err))
+// newIndexBuilder creates the index builder of a repository running the named processors
+// (empty = all). It responds with the error and returns nil when the builder cannot be made.
+func (rc *RepoController) newIndexBuilder(c *gin.Context, repo *config.Repository, processors []string) *IndexBuilder {
+	// Check if MySQL connection is available
+	if rc.mysqlConn == nil {
+		rc.log(c).Error("MySQL connection not available")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "MySQL connection not available for file tracking",
+		})
+		return nil
+	}
+
+	// Create FileVersionRepository for this repository
+	fileVersionRepo, err := db.NewFileVersionRepository(rc.mysqlConn.GetDB(), repo.Name, rc.logger)
+	if err != nil {
+		rc.log(c).Error("Failed to create file version repository",
+			zap.String("repo_name", repo.Name),
+			zap.Error(err))
+		c.JSON(errorStatus(err), gin.H{
+			"error":   "Failed to initialize file tracking",
+			"details": err.Error(),
+		})
+		return nil
+	}
+
+	// Create index builder with processors
+	indexBuilder := NewIndexBuilder(rc.config, rc.processors, fileVersionRepo, rc.logger)
+	indexBuilder.SetScheduler(rc.scheduler)
+	indexBuilder.SetLocker(rc.locker)
+	indexBuilder.SetStorage(rc.store)
+	if err := indexBuilder.SelectProcessors(processors); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request payload",
+			"error":   "Invalid processors",
 			"details": err.Error(),
 		})
+		return nil
+	}
+	return indexBuilder
+}
+
+func (rc *RepoController) GetFunctionsInFile(c *gin.Context) {
+	var request model.GetFunctionsInFileRequest
+	if err := bindRequest(c, &request); err != nil {
+		rc.log(c).Error("Invalid request payload", zap.Error(err))
+		c.JSON(http.StatusBadRequest, invalidRequestBody(err))
 		return
 	}
 
-	rc.logger.Info("Getting functions in file",
+	rc.log(c).Info("Getting functions in file",
 		zap.String("repo_name", request.RepoName),
 		zap.String("relative_path", request.RelativePath))
 
 	/*response, err := rc.repoService.GetFunctionsInFile(request.RepoName, request.RelativePath)
 	if err != nil {
-		rc.logger.Error("Failed to get functions in file",
+		rc.log(c).Error("Failed to get functions in file",
 			zap.String("repo_name", request.RepoName),
 			zap.String("relative_path", request.RelativePath),
 			zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(errorStatus(err), gin.H{
 			"error":   "Failed to get functions in file",
 			"details": err.Error(),
 		})
 		return
 	}
 
-	rc.logger.Info("Successfully got functions in file",
+	rc.log(c).Info("Successfully got functions in file",
 		zap.String("repo_name", request.RepoName),
 		zap.String("relative_path", request.RelativePath),
 		zap.Int("function_count", len(response.Functions)))
 
-	rc.logger.Debug("About to send JSON response")
+	rc.log(c).Debug("About to send JSON response")
 	c.JSON(http.StatusOK, response)
 	*/
 	c.JSON(http.StatusOK, nil)
-	rc.logger.Debug("JSON response sent successfully")
+	rc.log(c).Debug("JSON response sent successfully")
 }
 
 func (rc *RepoController) GetFunctionDetails(c *gin.Context) {
 	var request model.GetFunctionDetailsRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
-		rc.logger.Error("Invalid request payload", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request payload",
-			"details": err.Error(),
-		})
+	if err := bindRequest(c, &request); err != nil {
+		rc.log(c).Error("Invalid request payload", zap.Error(err))
+		c.JSON(http.StatusBadRequest, invalidRequestBody(err))
 		return
 	}
 
-	rc.logger.Info("Getting function details",
+	if reason, ok := rc.unavailable[ServiceLSP]; ok {
+		rc.log(c).Warn("Language servers not available", zap.String("reason", reason))
+		serviceUnavailable(c, rc.unavailable, ServiceLSP, "Language servers not available")
+		return
+	}
+
+	rc.log(c).Info("Getting function details",
 		zap.String("repo_name", request.RepoName),
 		zap.String("relative_path", request.RelativePath),
 		zap.String("function_name", request.FunctionName))
 
 	response, err := rc.repoService.GetFunctionDetails(request.RepoName, request.RelativePath, request.FunctionName)
 	if err != nil {
-		rc.logger.Error("Failed to get function details",
+		rc.log(c).Error("Failed to get function details",
 			zap.String("repo_name", request.RepoName),
 			zap.String("relative_path", request.RelativePath),
 			zap.String("function_name", request.FunctionName),
 			zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(errorStatus(err), gin.H{
 			"error":   "Failed to get function details",
 			"details": err.Error(),
 		})
 		return
 	}
 
-	rc.logger.Info("Successfully got function details",
+	rc.log(c).Info("Successfully got function details",
 		zap.String("repo_name", request.RepoName),
 		zap.String("relative_path", request.RelativePath),
 		zap.String("function_name", request.FunctionName))
 
-	rc.logger.Debug("About to send JSON response")
+	rc.log(c).Debug("About to send JSON response")
 	c.JSON(http.StatusOK, response)
-	rc.logger.Debug("JSON response sent successfully")
+	rc.log(c).Debug("JSON response sent successfully")
 }
 
 func (rc *RepoController) GetFunctionDependencies(c *gin.Context) {
 	request := model.GetFunctionDependenciesRequest{
 		Depth: 2, // Default depth
 	}
-	if err := c.ShouldBindJSON(&request); err != nil {
-		rc.logger.Error("Invalid request payload", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request payload",
-			"details": err.Error(),
-		})
+	if err := bindRequest(c, &request); err != nil {
+		rc.log(c).Error("Invalid request payload", zap.Error(err))
+		c.JSON(http.StatusBadRequest, invalidRequestBody(err))
+		return
+	}
+
+	if reason, ok := rc.unavailable[ServiceLSP]; ok {
+		rc.log(c).Warn("Language servers not available", zap.String("reason", reason))
+		serviceUnavailable(c, rc.unavailable, ServiceLSP, "Language servers not available")
 		return
 	}
 
-	rc.logger.Info("Getting function dependencies",
+	rc.log(c).Info("Getting function dependencies",
 		zap.String("repo_name", request.RepoName),
 		zap.String("relative_path", request.RelativePath),
 		zap.String("function_name", request.FunctionName),
@@ -256,52 +333,47 @@ func (rc *RepoController) GetFunctionDependencies(c *gin.Context) {
 
 	response, err := rc.repoService.GetFunctionDependencies(c, request.RepoName, request.RelativePath, request.FunctionName, request.Depth)
 	if err != nil {
-		rc.logger.Error("Failed to get function dependencies",
+		rc.log(c).Error("Failed to get function dependencies",
 			zap.String("repo_name", request.RepoName),
 			zap.String("relative_path", request.RelativePath),
 			zap.String("function_name", request.FunctionName),
 			zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(errorStatus(err), gin.H{
 			"error":   "Failed to get function dependencies",
 			"details": err.Error(),
 		})
 		return
 	}
 
-	rc.logger.Info("Successfully got function dependencies",
+	rc.log(c).Info("Successfully got function dependencies",
 		zap.String("repo_name", request.RepoName),
 		zap.String("relative_path", request.RelativePath),
 		zap.String("function_name", request.FunctionName))
 
-	rc.logger.Debug("About to send JSON response")
+	rc.log(c).Debug("About to send JSON response")
 	c.JSON(http.StatusOK, response)
-	rc.logger.Debug("JSON response sent successfully")
+	rc.log(c).Debug("JSON response sent successfully")
 }
 
 func (rc *RepoController) ProcessDirectory(c *gin.Context) {
 	var request model.ProcessDirectoryRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
-		rc.logger.Error("Invalid request payload", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request payload",
-			"details": err.Error(),
-		})
+	if err := bindRequest(c, &request); err != nil {
+		rc.log(c).Error("Invalid request payload", zap.Error(err))
+		c.JSON(http.StatusBadRequest, invalidRequestBody(err))
 		return
 	}
 
 	// Check if chunk service is available
 	if rc.chunkService == nil {
-		rc.logger.Error("Code chunk service not available")
-		c.JSON(http.StatusServiceUnavailable, gin.H{
-			"error": "Code chunk service not available",
-		})
+		rc.log(c).Error("Code chunk service not available")
+		serviceUnavailable(c, rc.unavailable, ServiceVectorSearch, "Code chunk service not available")
 		return
 	}
 
 	// Get repository configuration
 	repo, err := rc.repoService.GetConfig().GetRepository(request.RepoName)
 	if err != nil {
-		rc.logger.Error("Repository not found",
+		rc.log(c).Error("Repository not found",
 			zap.String("repo_name", request.RepoName),
 			zap.Error(err))
 		c.JSON(http.StatusNotFound, gin.H{
@@ -317,17 +389,21 @@ func (rc *RepoController) ProcessDirectory(c *gin.Context) {
 		collectionName = request.RepoName
 	}
 
-	rc.logger.Info("Processing directory for code chunking",
+	rc.log(c).Info("Processing directory for code chunking",
 		zap.String("repo_name", request.RepoName),
 		zap.String("path", repo.Path),
 		zap.String("collection", collectionName))
 
+	if request.TTLHours > 0 {
+		rc.chunkService.SetCollectionTTL(collectionName, time.Duration(request.TTLHours)*time.Hour)
+	}
+
 	// Create collection if it doesn't exist
 	if err := rc.chunkService.CreateCollection(c.Request.Context(), collectionName); err != nil {
-		rc.logger.Error("Failed to create collection",
+		rc.log(c).Error("Failed to create collection",
 			zap.String("collection", collectionName),
 			zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(errorStatus(err), gin.H{
 			"error":   "Failed to create collection",
 			"details": err.Error(),
 		})
@@ -335,13 +411,13 @@ func (rc *RepoController) ProcessDirectory(c *gin.Context) {
 	}
 
 	// Process directory with repository configuration
-	totalChunks, err := rc.chunkService.ProcessDirectory(c.Request.Context(), repo.Path, collectionName, repo)
+	totalChunks, err := rc.chunkService.ProcessDirectory(c.Request.Context(), repo.Path, collectionName, repo, rc.fileIDResolver(repo))
 	if err != nil {
-		rc.logger.Error("Failed to process directory",
+		rc.log(c).Error("Failed to process directory",
 			zap.String("repo_name", request.RepoName),
 			zap.String("path", repo.Path),
 			zap.Error(err))
-		c.JSON(http.StatusInternalServerError, model.ProcessDirectoryResponse{
+		c.JSON(errorStatus(err), model.ProcessDirectoryResponse{
 			RepoName:       request.RepoName,
 			CollectionName: collectionName,
 			TotalChunks:    totalChunks,
@@ -351,7 +427,7 @@ func (rc *RepoController) ProcessDirectory(c *gin.Context) {
 		return
 	}
 
-	rc.logger.Info("Successfully processed directory",
+	rc.log(c).Info("Successfully processed directory",
 		zap.String("repo_name", request.RepoName),
 		zap.String("collection", collectionName),
 		zap.Int("total_chunks", totalChunks))
@@ -367,40 +443,52 @@ func (rc *RepoController) ProcessDirectory(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// fileIDResolver returns a resolver that assigns MySQL FileIDs to files chunked outside the
+// IndexBuilder, or nil if file tracking is unavailable (chunks are then stored without a FileID)
+func (rc *RepoController) fileIDResolver(repo *config.Repository) vector.FileIDResolver {
+	if rc.mysqlConn == nil {
+		return nil
+	}
+
+	fileVersionRepo, err := db.NewFileVersionRepository(rc.mysqlConn.GetDB(), repo.Name, rc.logger)
+	if err != nil {
+		rc.logger.Warn("File tracking unavailable, chunks will not carry FileIDs",
+			zap.String("repo_name", repo.Name),
+			zap.Error(err))
+		return nil
+	}
+
+	gitInfo, err := util.GetGitInfo(repo.Path)
+	if err != nil {
+		// Without git info every file is treated as ephemeral
+		gitInfo = nil
+	}
+
+	indexBuilder := NewIndexBuilder(rc.config, nil, fileVersionRepo, rc.logger)
+	return func(filePath string, content []byte) (int32, string, error) {
+		fileCtx, err := indexBuilder.createFileContext(repo.Path, filePath, content, false, gitInfo)
+		if err != nil {
+			return 0, "", err
+		}
+		return fileCtx.FileID, fileCtx.CommitSHA(), nil
+	}
+}
+
 // SearchSimilarCode handles searching for similar code using a code snippet
 func (rc *RepoController) SearchSimilarCode(c *gin.Context) {
 	var request model.SearchSimilarCodeRequest
 
-	if err := c.ShouldBindJSON(&request); err != nil {
-		rc.logger.Error("Invalid request", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request",
-			"details": err.Error(),
-		})
+	if err := bindRequest(c, &request); err != nil {
+		rc.log(c).Error("Invalid request", zap.Error(err))
+		c.JSON(http.StatusBadRequest, invalidRequestBody(err))
 		return
 	}
+	noteQuery(c, request.RepoName, request.CodeSnippet)
 
 	// Check if chunk service is available
 	if rc.chunkService == nil {
-		rc.logger.Error("Code chunk service not available")
-		c.JSON(http.StatusServiceUnavailable, gin.H{
-			"error": "Code chunk service not available",
-		})
-		return
-	}
-
-	// Validate language
-	validLanguages := map[string]bool{
-		"go":         true,
-		"python":     true,
-		"java":       true,
-		"javascript": true,
-		"typescript": true,
-	}
-	if !validLanguages[request.Language] {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Unsupported language. Supported: go, python, java, javascript, typescript",
-		})
+		rc.log(c).Error("Code chunk service not available")
+		serviceUnavailable(c, rc.unavailable, ServiceVectorSearch, "Code chunk service not available")
 		return
 	}
 
@@ -416,7 +504,24 @@ func (rc *RepoController) SearchSimilarCode(c *gin.Context) {
 		limit = 10
 	}
 
-	rc.logger.Info("Searching for similar code",
+	sess, err := requestSession(c, rc.sessions)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	var explainer *searchExplainer
+	if request.Explain {
+		explainer = rc.newSearchExplainer(c, &request, sess)
+	}
+	adjuster := rc.scoreAdjuster(request.RepoName)
+	// Over-fetch so results dropped or demoted as already seen, or reranked by the
+	// repository's scoring rules, can be replaced
+	fetchLimit := limit
+	if (sess != nil && request.SeenMode != "") || adjuster != nil {
+		fetchLimit = limit * 2
+	}
+
+	rc.log(c).Info("Searching for similar code",
 		zap.String("repo_name", request.RepoName),
 		zap.String("collection", collectionName),
 		zap.String("language", request.Language),
@@ -428,14 +533,48 @@ func (rc *RepoController) SearchSimilarCode(c *gin.Context) {
 		collectionName,
 		request.CodeSnippet,
 		request.Language,
-		limit,
+		fetchLimit,
 		nil, // no filter
+		!request.DisableDedupe,
 	)
+	if err != nil && errors.Is(err, apperrors.ErrEmbeddingUnavailable) && rc.ngramService != nil {
+		results, fallbackErr := rc.searchLexicalFallback(c, &request, fetchLimit, explainer)
+		if fallbackErr == nil {
+			rc.adjustSimilarCodeScores(c, adjuster, queryChunks, results)
+			results = rc.withExactMatches(c, &request, results, fetchLimit)
+			found := len(results)
+			results = recordSimilarCodeResults(sess, request.SeenMode, results, limit)
+			noteResults(c, len(results))
+			rc.log(c).Warn("Embedding provider unavailable, served lexical search results",
+				zap.String("repo_name", request.RepoName),
+				zap.Int("results", len(results)),
+				zap.Error(err))
+			c.JSON(http.StatusOK, model.SearchSimilarCodeResponse{
+				RepoName:       request.RepoName,
+				CollectionName: collectionName,
+				Query: model.QueryInfo{
+					CodeSnippet: request.CodeSnippet,
+					Language:    request.Language,
+					ChunksFound: len(queryChunks),
+					Chunks:      queryChunks,
+				},
+				Results:     results,
+				Degraded:    true,
+				Success:     true,
+				Message:     "Embedding provider unavailable, results ranked by n-gram overlap",
+				Diagnostics: rc.similarCodeDiagnostics(c, &request, collectionName, results, found, true),
+			})
+			return
+		}
+		rc.log(c).Warn("Lexical search fallback failed",
+			zap.String("repo_name", request.RepoName),
+			zap.Error(fallbackErr))
+	}
 	if err != nil {
-		rc.logger.Error("Failed to search for similar code",
+		rc.log(c).Error("Failed to search for similar code",
 			zap.String("repo_name", request.RepoName),
 			zap.Error(err))
-		c.JSON(http.StatusInternalServerError, model.SearchSimilarCodeResponse{
+		c.JSON(errorStatus(err), model.SearchSimilarCodeResponse{
 			RepoName:       request.RepoName,
 			CollectionName: collectionName,
 			Query: model.QueryInfo{
@@ -459,25 +598,51 @@ func (rc *RepoController) SearchSimilarCode(c *gin.Context) {
 			QueryChunkIndex: queryChunkIndices[i],
 		}
 
-		// Fetch code from file if requested
-		if request.IncludeCode {
-			code, err := rc.chunkService.ReadCodeFromFile(chunk.FilePath, chunk.StartLine, chunk.EndLine)
-			if err != nil {
-				rc.logger.Warn("Failed to read code from file",
-					zap.String("file", chunk.FilePath),
-					zap.Int("start_line", chunk.StartLine),
-					zap.Int("end_line", chunk.EndLine),
-					zap.Error(err))
-				// Continue without code rather than failing the entire request
-			} else {
+		var queryChunk *model.CodeChunk
+		if idx := queryChunkIndices[i]; idx >= 0 && idx < len(queryChunks) {
+			queryChunk = queryChunks[idx]
+			result.QueryChunkName = queryChunk.Name
+		}
+
+		// Code is needed both for include_code and for computing highlighted regions
+		code, err := rc.chunkService.ReadCodeFromFile(chunk.FilePath, chunk.StartLine, chunk.EndLine)
+		if err != nil {
+			rc.log(c).Warn("Failed to read code from file",
+				zap.String("file", chunk.FilePath),
+				zap.Int("start_line", chunk.StartLine),
+				zap.Int("end_line", chunk.EndLine),
+				zap.Error(err))
+			// Continue without code rather than failing the entire request
+		} else {
+			if request.IncludeCode {
 				result.Code = code
 			}
+			if queryChunk != nil {
+				result.Highlight = vector.ComputeMatchHighlight(queryChunk.Content, code, chunk.StartLine)
+			}
+		}
+
+		if explainer != nil {
+			queryCode := request.CodeSnippet
+			if queryChunk != nil {
+				queryCode = queryChunk.Content
+			}
+			result.Explanation = explainer.explain(queryCode, code, chunk, &scores[i])
 		}
 
 		results[i] = result
 	}
+	if explainer != nil {
+		explainer.addGraphProximity(c.Request.Context(), results)
+	}
+
+	rc.adjustSimilarCodeScores(c, adjuster, queryChunks, results)
+	results = rc.withExactMatches(c, &request, results, fetchLimit)
+	found := len(results)
+	results = recordSimilarCodeResults(sess, request.SeenMode, results, limit)
+	noteResults(c, len(results))
 
-	rc.logger.Info("Successfully found similar code",
+	rc.log(c).Info("Successfully found similar code",
 		zap.String("repo_name", request.RepoName),
 		zap.String("collection", collectionName),
 		zap.Int("query_chunks", len(queryChunks)),
@@ -493,39 +658,460 @@ func (rc *RepoController) SearchSimilarCode(c *gin.Context) {
 			ChunksFound: len(queryChunks),
 			Chunks:      queryChunks,
 		},
-		Results: results,
-		Success: true,
-		Message: "Search completed successfully",
+		Results:     results,
+		Success:     true,
+		Message:     "Search completed successfully",
+		Diagnostics: rc.similarCodeDiagnostics(c, &request, collectionName, results, found, false),
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
+// similarCodeDiagnostics explains an empty similar code search. found counts the results
+// before those already seen in the session were dropped; degraded is set when the results
+// come from the n-gram fallback.
+func (rc *RepoController) similarCodeDiagnostics(c *gin.Context, request *model.SearchSimilarCodeRequest, collectionName string, results []model.SimilarCodeResult, found int, degraded bool) []model.SearchDiagnostic {
+	if len(results) > 0 {
+		return nil
+	}
+	d := rc.newSearchDiagnostics(c)
+	if found > 0 {
+		d.add(DiagnosticSeenInSession, "All %d results were already returned in this session (seen_mode %q)", found, request.SeenMode)
+		return d.result()
+	}
+	if degraded {
+		d.add(DiagnosticEmbeddingDegraded,
+			"The embedding provider is unavailable and the n-gram overlap ranking used instead found nothing")
+	} else {
+		d.collection(collectionName)
+	}
+	if repo, err := rc.config.GetRepository(request.RepoName); err == nil {
+		d.indexAge(repo)
+	}
+	return d.result()
+}
+
+// maxProximityNodes is the number of the session's most recent graph nodes that graph
+// proximity is measured from
+const maxProximityNodes = 100
+
+// searchExplainer computes the per-result explanations of a SearchSimilarCode request
+type searchExplainer struct {
+	ngramScore func(filePath string) (float64, bool) // nil without an n-gram corpus
+	seenChunks map[string]bool                       // session working set before this request
+	seenFiles  map[string]bool
+	seenNodes  []int64 // graph nodes of the working set, the most recent maxProximityNodes
+	graph      *codegraph.CodeGraph
+	logger     *zap.Logger
+}
+
+func (rc *RepoController) newSearchExplainer(c *gin.Context, request *model.SearchSimilarCodeRequest, sess *session.Session) *searchExplainer {
+	e := &searchExplainer{
+		seenChunks: make(map[string]bool),
+		seenFiles:  make(map[string]bool),
+		graph:      rc.codeGraph,
+		logger:     rc.log(c),
+	}
+	if rc.ngramService != nil {
+		scorer, err := rc.ngramService.FileOverlapScorer(c.Request.Context(), request.RepoName, request.Language, []byte(request.CodeSnippet))
+		if err != nil {
+			rc.log(c).Debug("N-gram scores unavailable for search explanation", zap.Error(err))
+		} else {
+			e.ngramScore = scorer
+		}
+	}
+	if sess != nil {
+		for _, item := range sess.Snapshot().Items {
+			switch item.Kind {
+			case session.KindChunk:
+				e.seenChunks[item.ID] = true
+			case session.KindNode:
+				if id, err := strconv.ParseInt(item.ID, 10, 64); err == nil {
+					e.seenNodes = append(e.seenNodes, id)
+				}
+			}
+			if item.FilePath != "" {
+				e.seenFiles[item.FilePath] = true
+			}
+		}
+		if len(e.seenNodes) > maxProximityNodes {
+			e.seenNodes = e.seenNodes[len(e.seenNodes)-maxProximityNodes:]
+		}
+	}
+	return e
+}
+
+// explain describes why chunk matched queryCode. code is the chunk's source, empty if it
+// could not be read; embeddingScore is nil for results that were not ranked by vector search.
+func (e *searchExplainer) explain(queryCode, code string, chunk *model.CodeChunk, embeddingScore *float32) *model.SearchExplanation {
+	shared, overlap := vector.SharedIdentifiers(queryCode, code)
+	explanation := &model.SearchExplanation{
+		EmbeddingScore:    embeddingScore,
+		SharedIdentifiers: shared,
+		IdentifierOverlap: overlap,
+		ContextProximity:  "none",
+	}
+	if e.ngramScore != nil {
+		if score, ok := e.ngramScore(chunk.FilePath); ok {
+			explanation.NGramOverlap = &score
+		}
+	}
+	switch {
+	case e.seenChunks[chunkSessionID(chunk)]:
+		explanation.ContextProximity = "seen"
+	case e.seenFiles[chunk.FilePath]:
+		explanation.ContextProximity = "same_file"
+	}
+	return explanation
+}
+
+// addGraphProximity sets the graph distance of explained results to the session's graph
+// nodes. Results seen or sharing a file keep their proximity, others within reach become
+// "graph".
+// Function and class chunks are located by their start line, file chunks by their file.
+func (e *searchExplainer) addGraphProximity(ctx context.Context, results []model.SimilarCodeResult) {
+	if e.graph == nil || len(e.seenNodes) == 0 {
+		return
+	}
+	anchors := make([]codegraph.ProximityAnchor, len(results))
+	for i, result := range results {
+		chunk := result.Chunk
+		if result.Explanation == nil || chunk == nil {
+			continue
+		}
+		anchors[i] = codegraph.ProximityAnchor{
+			FileID:    chunk.FileID,
+			StartLine: chunk.StartLine,
+			WholeFile: chunk.ChunkType == model.ChunkTypeFile,
+		}
+	}
+	distances, err := e.graph.GraphDistances(ctx, anchors, e.seenNodes)
+	if err != nil {
+		e.logger.Debug("Graph proximity unavailable for search explanation", zap.Error(err))
+		return
+	}
+	for i, distance := range distances {
+		explanation := results[i].Explanation
+		explanation.GraphDistance = &distance
+		if explanation.ContextProximity == "none" {
+			explanation.ContextProximity = "graph"
+		}
+	}
+}
+
+// scoreAdjuster returns the scoring rules configured for a repository, or nil if there are
+// none. The recency boost reads the blame metadata of the code graph (see BlameProcessor).
+// An adjuster serves one request and is not safe for concurrent use.
+func (rc *RepoController) scoreAdjuster(repoName string) *vector.ScoreAdjuster {
+	repo, err := rc.config.GetRepository(repoName)
+	if err != nil || repo.Scoring == nil {
+		return nil
+	}
+	var lastModified vector.LastModifiedFunc
+	if repo.Scoring.RecencyBoost > 0 && rc.codeGraph != nil {
+		lastModified = blameLastModified(rc.codeGraph)
+	}
+	return vector.NewScoreAdjuster(repo, lastModified)
+}
+
+// blameLastModified returns a vector.LastModifiedFunc answering from the blame metadata of
+// the Function and Class nodes of a chunk's file, which are read once per file. A chunk
+// changed when the newest of the nodes within its lines did; a chunk within a single node,
+// such as part of a long function, when the innermost node containing it did.
+func blameLastModified(graph *codegraph.CodeGraph) vector.LastModifiedFunc {
+	files := make(map[int32][]*ast.Node)
+	return func(ctx context.Context, chunk *model.CodeChunk) (time.Time, error) {
+		nodes, ok := files[chunk.FileID]
+		if !ok {
+			var err error
+			if nodes, err = graph.FindNodesInFile(ctx, chunk.FileID, ast.NodeTypeFunction, ast.NodeTypeClass); err != nil {
+				return time.Time{}, err
+			}
+			files[chunk.FileID] = nodes
+		}
+
+		var newest int64
+		var innermost *ast.Node
+		for _, node := range nodes {
+			start, end := node.Range.Start.Line, node.Range.End.Line
+			switch {
+			case start >= chunk.StartLine && end <= chunk.EndLine:
+				newest = max(newest, blameTime(node))
+			case start <= chunk.StartLine && end >= chunk.EndLine:
+				if innermost == nil || end-start < innermost.Range.End.Line-innermost.Range.Start.Line {
+					innermost = node
+				}
+			}
+		}
+		if newest == 0 && innermost != nil {
+			newest = blameTime(innermost)
+		}
+		if newest == 0 {
+			return time.Time{}, fmt.Errorf("no blame metadata for %s lines %d-%d", chunk.FilePath, chunk.StartLine, chunk.EndLine)
+		}
+		return time.Unix(newest, 0), nil
+	}
+}
+
+// adjustSimilarCodeScores applies the repository's scoring rules to search results and
+// re-sorts them by the adjusted score. Explanations keep the raw embedding score and record
+// the multiplier.
+func (rc *RepoController) adjustSimilarCodeScores(c *gin.Context, adjuster *vector.ScoreAdjuster, queryChunks []*model.CodeChunk, results []model.SimilarCodeResult) {
+	if adjuster == nil {
+		return
+	}
+	queryNames := make(map[string]bool, len(queryChunks))
+	for _, chunk := range queryChunks {
+		if chunk.Name != "" {
+			queryNames[chunk.Name] = true
+		}
+	}
+	for i := range results {
+		multiplier := adjuster.Multiplier(c.Request.Context(), results[i].Chunk, queryNames)
+		results[i].Score = float32(float64(results[i].Score) * multiplier)
+		if results[i].Explanation != nil && multiplier != 1 {
+			results[i].Explanation.ScoreMultiplier = multiplier
+		}
+	}
+	sort.SliceStable(results, func(a, b int) bool {
+		return results[a].Score > results[b].Score
+	})
+}
+
+// recordSimilarCodeResults applies the session's seen mode to search results, truncates them
+// to limit and records the returned chunks in the session
+func recordSimilarCodeResults(sess *session.Session, seenMode string, results []model.SimilarCodeResult, limit int) []model.SimilarCodeResult {
+	if sess != nil {
+		results = session.Order(sess, seenMode, results, func(r model.SimilarCodeResult) (string, string) {
+			return session.KindChunk, chunkSessionID(r.Chunk)
+		})
+	}
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	if sess != nil {
+		for _, r := range results {
+			sess.Record(chunkItem("searchSimilarCode", r.Chunk))
+		}
+	}
+	return results
+}
+
+// chunkSessionID identifies a chunk in a session. Lexical fallback results are whole files
+// without a chunk ID and are keyed by path instead.
+func chunkSessionID(chunk *model.CodeChunk) string {
+	if chunk.ID == "" {
+		return "file:" + chunk.FilePath
+	}
+	return chunk.ID
+}
+
+func chunkItem(source string, chunk *model.CodeChunk) session.Item {
+	return session.Item{
+		Kind:     session.KindChunk,
+		ID:       chunkSessionID(chunk),
+		Name:     chunk.Name,
+		FilePath: chunk.FilePath,
+		Source:   source,
+	}
+}
+
+// searchLexicalFallback answers a similar code search from the repository's n-gram corpus
+// when no query embedding can be generated. Results are whole files ranked by the share of
+// the snippet's n-grams they contain, so scores are not comparable with vector scores.
+func (rc *RepoController) searchLexicalFallback(c *gin.Context, request *model.SearchSimilarCodeRequest, limit int, explainer *searchExplainer) ([]model.SimilarCodeResult, error) {
+	matches, err := rc.ngramService.SearchLexical(c.Request.Context(), request.RepoName, request.Language, []byte(request.CodeSnippet), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]model.SimilarCodeResult, 0, len(matches))
+	for _, match := range matches {
+		chunk := &model.CodeChunk{
+			FileID:    match.FileID,
+			ChunkType: model.ChunkTypeFile,
+			Level:     1,
+			Language:  match.Language,
+			FilePath:  match.FilePath,
+			Name:      filepath.Base(match.FilePath),
+		}
+		result := model.SimilarCodeResult{
+			Chunk:           chunk,
+			Score:           float32(match.Score),
+			QueryChunkIndex: -1,
+		}
+
+		code, err := rc.chunkService.ReadCodeFromFile(match.FilePath, 0, -1)
+		if err != nil {
+			rc.log(c).Warn("Failed to read code from file",
+				zap.String("file", match.FilePath),
+				zap.Error(err))
+		} else {
+			chunk.EndLine = strings.Count(code, "\n")
+			if request.IncludeCode {
+				result.Code = code
+			}
+		}
+		if explainer != nil {
+			result.Explanation = explainer.explain(request.CodeSnippet, code, chunk, nil)
+		}
+		results = append(results, result)
+	}
+	if explainer != nil {
+		explainer.addGraphProximity(c.Request.Context(), results)
+	}
+	return results, nil
+}
+
+// GetChunkNeighbors returns the parent, sibling and adjacent chunks of a search hit
+func (rc *RepoController) GetChunkNeighbors(c *gin.Context) {
+	var request model.GetChunkNeighborsRequest
+
+	if err := bindRequest(c, &request); err != nil {
+		rc.log(c).Error("Invalid request", zap.Error(err))
+		c.JSON(http.StatusBadRequest, invalidRequestBody(err))
+		return
+	}
+
+	if rc.chunkService == nil {
+		rc.log(c).Error("Code chunk service not available")
+		serviceUnavailable(c, rc.unavailable, ServiceVectorSearch, "Code chunk service not available")
+		return
+	}
+
+	sess, err := requestSession(c, rc.sessions)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	collectionName := request.CollectionName
+	if collectionName == "" {
+		collectionName = request.RepoName
+	}
+
+	neighborhood, err := rc.chunkService.GetChunkNeighborhood(c.Request.Context(), collectionName, request.ChunkID)
+	if err != nil {
+		rc.log(c).Error("Failed to get chunk neighbors",
+			zap.String("repo_name", request.RepoName),
+			zap.String("chunk_id", request.ChunkID),
+			zap.Error(err))
+		c.JSON(errorStatus(err), model.GetChunkNeighborsResponse{
+			RepoName:       request.RepoName,
+			CollectionName: collectionName,
+			Success:        false,
+			Message:        fmt.Sprintf("Failed to get chunk neighbors: %v", err),
+		})
+		return
+	}
+
+	if request.IncludeCode {
+		chunks := []*model.CodeChunk{neighborhood.Chunk, neighborhood.Parent, neighborhood.Previous, neighborhood.Next}
+		chunks = append(chunks, neighborhood.Siblings...)
+		for _, chunk := range chunks {
+			if chunk == nil {
+				continue
+			}
+			code, err := rc.chunkService.ReadCodeFromFile(chunk.FilePath, chunk.StartLine, chunk.EndLine)
+			if err != nil {
+				rc.log(c).Warn("Failed to read code from file",
+					zap.String("file", chunk.FilePath),
+					zap.Int("start_line", chunk.StartLine),
+					zap.Int("end_line", chunk.EndLine),
+					zap.Error(err))
+				continue
+			}
+			chunk.Content = code
+		}
+	}
+
+	if sess != nil {
+		chunks := []*model.CodeChunk{neighborhood.Chunk, neighborhood.Parent, neighborhood.Previous, neighborhood.Next}
+		for _, chunk := range append(chunks, neighborhood.Siblings...) {
+			if chunk != nil {
+				sess.Record(chunkItem("chunkNeighbors", chunk))
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, model.GetChunkNeighborsResponse{
+		RepoName:       request.RepoName,
+		CollectionName: collectionName,
+		Neighborhood:   neighborhood,
+		Success:        true,
+		Message:        "Neighbors retrieved successfully",
+	})
+}
+
+// PurgeChunks deletes the chunks of a collection matching the request filters
+func (rc *RepoController) PurgeChunks(c *gin.Context) {
+	var request model.PurgeChunksRequest
+	if err := bindRequest(c, &request); err != nil {
+		rc.log(c).Error("Invalid request payload", zap.Error(err))
+		c.JSON(http.StatusBadRequest, invalidRequestBody(err))
+		return
+	}
+
+	if rc.chunkService == nil {
+		rc.log(c).Error("Code chunk service not available")
+		serviceUnavailable(c, rc.unavailable, ServiceVectorSearch, "Code chunk service not available")
+		return
+	}
+
+	filter := vector.PurgeFilter{
+		PathPrefix: request.PathPrefix,
+		Language:   request.Language,
+	}
+	for _, chunkType := range request.ChunkTypes {
+		filter.ChunkTypes = append(filter.ChunkTypes, model.ChunkType(chunkType))
+	}
+	if request.IndexedBefore != nil {
+		filter.IndexedBefore = *request.IndexedBefore
+	}
+	if request.Expired {
+		filter.ExpiredBefore = time.Now()
+	}
+
+	deleted, err := rc.chunkService.PurgeChunks(c.Request.Context(), request.CollectionName, filter)
+	if err != nil {
+		rc.log(c).Error("Failed to purge chunks",
+			zap.String("collection", request.CollectionName),
+			zap.Int("deleted", deleted),
+			zap.Error(err))
+		c.JSON(errorStatus(err), gin.H{
+			"error":   "Failed to purge chunks",
+			"details": err.Error(),
+			"deleted": deleted,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.PurgeChunksResponse{
+		CollectionName: request.CollectionName,
+		Deleted:        deleted,
+	})
+}
+
 // ProcessNGram processes a repository and builds n-gram models
 func (rc *RepoController) ProcessNGram(c *gin.Context) {
 	var request model.ProcessNGramRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
-		rc.logger.Error("Invalid request payload", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request payload",
-			"details": err.Error(),
-		})
+	if err := bindRequest(c, &request); err != nil {
+		rc.log(c).Error("Invalid request payload", zap.Error(err))
+		c.JSON(http.StatusBadRequest, invalidRequestBody(err))
 		return
 	}
 
 	// Check if n-gram service is available
 	if rc.ngramService == nil {
-		rc.logger.Error("N-gram service not available")
-		c.JSON(http.StatusServiceUnavailable, gin.H{
-			"error": "N-gram service not available",
-		})
+		rc.log(c).Error("N-gram service not available")
+		serviceUnavailable(c, rc.unavailable, ServiceNgram, "N-gram service not available")
 		return
 	}
 
 	// Get repository configuration
 	repo, err := rc.repoService.GetConfig().GetRepository(request.RepoName)
 	if err != nil {
-		rc.logger.Error("Repository not found",
+		rc.log(c).Error("Repository not found",
 			zap.String("repo_name", request.RepoName),
 			zap.Error(err))
 		c.JSON(http.StatusNotFound, gin.H{
@@ -541,17 +1127,17 @@ func (rc *RepoController) ProcessNGram(c *gin.Context) {
 		n = 3
 	}
 
-	rc.logger.Info("Processing repository for n-gram model",
+	rc.log(c).Info("Processing repository for n-gram model",
 		zap.String("repo_name", request.RepoName),
 		zap.String("path", repo.Path),
 		zap.Int("n", n))
 
 	// Process repository
 	if err := rc.ngramService.ProcessRepository(c.Request.Context(), repo, n, request.Override); err != nil {
-		rc.logger.Error("Failed to process repository for n-gram",
+		rc.log(c).Error("Failed to process repository for n-gram",
 			zap.String("repo_name", request.RepoName),
 			zap.Error(err))
-		c.JSON(http.StatusInternalServerError, model.ProcessNGramResponse{
+		c.JSON(errorStatus(err), model.ProcessNGramResponse{
 			RepoName: request.RepoName,
 			N:        n,
 			Success:  false,
@@ -563,10 +1149,10 @@ func (rc *RepoController) ProcessNGram(c *gin.Context) {
 	// Get statistics
 	stats, err := rc.ngramService.GetRepositoryStats(c.Request.Context(), request.RepoName)
 	if err != nil {
-		rc.logger.Error("Failed to get repository stats",
+		rc.log(c).Error("Failed to get repository stats",
 			zap.String("repo_name", request.RepoName),
 			zap.Error(err))
-		c.JSON(http.StatusInternalServerError, model.ProcessNGramResponse{
+		c.JSON(errorStatus(err), model.ProcessNGramResponse{
 			RepoName: request.RepoName,
 			N:        n,
 			Success:  false,
@@ -575,7 +1161,7 @@ func (rc *RepoController) ProcessNGram(c *gin.Context) {
 		return
 	}
 
-	rc.logger.Info("Successfully processed repository for n-gram",
+	rc.log(c).Info("Successfully processed repository for n-gram",
 		zap.String("repo_name", request.RepoName),
 		zap.Int("n", n),
 		zap.Int("files", stats.TotalFiles),
@@ -598,31 +1184,26 @@ func (rc *RepoController) ProcessNGram(c *gin.Context) {
 // GetNGramStats returns statistics for a repository's n-gram model
 func (rc *RepoController) GetNGramStats(c *gin.Context) {
 	var request model.GetNGramStatsRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
-		rc.logger.Error("Invalid request payload", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request payload",
-			"details": err.Error(),
-		})
+	if err := bindRequest(c, &request); err != nil {
+		rc.log(c).Error("Invalid request payload", zap.Error(err))
+		c.JSON(http.StatusBadRequest, invalidRequestBody(err))
 		return
 	}
 
 	// Check if n-gram service is available
 	if rc.ngramService == nil {
-		rc.logger.Error("N-gram service not available")
-		c.JSON(http.StatusServiceUnavailable, gin.H{
-			"error": "N-gram service not available",
-		})
+		rc.log(c).Error("N-gram service not available")
+		serviceUnavailable(c, rc.unavailable, ServiceNgram, "N-gram service not available")
 		return
 	}
 
 	// Get statistics
 	stats, err := rc.ngramService.GetRepositoryStats(c.Request.Context(), request.RepoName)
 	if err != nil {
-		rc.logger.Error("Failed to get repository stats",
+		rc.log(c).Error("Failed to get repository stats",
 			zap.String("repo_name", request.RepoName),
 			zap.Error(err))
-		c.JSON(http.StatusNotFound, gin.H{
+		c.JSON(errorStatus(err), gin.H{
 			"error":   "Repository not found or not processed",
 			"details": err.Error(),
 		})
@@ -646,32 +1227,27 @@ func (rc *RepoController) GetNGramStats(c *gin.Context) {
 // GetFileEntropy returns the entropy for a specific file
 func (rc *RepoController) GetFileEntropy(c *gin.Context) {
 	var request model.GetFileEntropyRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
-		rc.logger.Error("Invalid request payload", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request payload",
-			"details": err.Error(),
-		})
+	if err := bindRequest(c, &request); err != nil {
+		rc.log(c).Error("Invalid request payload", zap.Error(err))
+		c.JSON(http.StatusBadRequest, invalidRequestBody(err))
 		return
 	}
 
 	// Check if n-gram service is available
 	if rc.ngramService == nil {
-		rc.logger.Error("N-gram service not available")
-		c.JSON(http.StatusServiceUnavailable, gin.H{
-			"error": "N-gram service not available",
-		})
+		rc.log(c).Error("N-gram service not available")
+		serviceUnavailable(c, rc.unavailable, ServiceNgram, "N-gram service not available")
 		return
 	}
 
 	// Get file entropy
 	entropy, err := rc.ngramService.GetFileEntropy(c.Request.Context(), request.RepoName, request.FilePath)
 	if err != nil {
-		rc.logger.Error("Failed to get file entropy",
+		rc.log(c).Error("Failed to get file entropy",
 			zap.String("repo_name", request.RepoName),
 			zap.String("file_path", request.FilePath),
 			zap.Error(err))
-		c.JSON(http.StatusNotFound, gin.H{
+		c.JSON(errorStatus(err), gin.H{
 			"error":   "File not found or not processed",
 			"details": err.Error(),
 		})
@@ -687,105 +1263,126 @@ func (rc *RepoController) GetFileEntropy(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-// AnalyzeCode analyzes a code snippet and returns naturalness metrics
+// AnalyzeCode analyzes a code snippet and files and returns naturalness metrics per file and
+// line. Lines at or above min_z_score are returned as findings, or as a SARIF log with
+// "format": "sarif" for upload as code scanning annotations.
 func (rc *RepoController) AnalyzeCode(c *gin.Context) {
 	var request model.AnalyzeCodeRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
-		rc.logger.Error("Invalid request payload", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request payload",
-			"details": err.Error(),
-		})
+	if err := bindRequest(c, &request); err != nil {
+		rc.log(c).Error("Invalid request payload", zap.Error(err))
+		c.JSON(http.StatusBadRequest, invalidRequestBody(err))
 		return
 	}
 
 	// Check if n-gram service is available
 	if rc.ngramService == nil {
-		rc.logger.Error("N-gram service not available")
-		c.JSON(http.StatusServiceUnavailable, gin.H{
-			"error": "N-gram service not available",
-		})
+		rc.log(c).Error("N-gram service not available")
+		serviceUnavailable(c, rc.unavailable, ServiceNgram, "N-gram service not available")
 		return
 	}
 
-	// Validate language
-	validLanguages := map[string]bool{
-		"go":         true,
-		"python":     true,
-		"java":       true,
-		"javascript": true,
-		"typescript": true,
+	minZScore := ngram.DefaultFindingZScore
+	if request.MinZScore != nil {
+		minZScore = *request.MinZScore
 	}
-	if !validLanguages[request.Language] {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Unsupported language. Supported: go, python, java, javascript, typescript",
-		})
-		return
+	response := model.AnalyzeCodeResponse{
+		RepoName: request.RepoName,
+		Language: request.Language,
 	}
+	var findings []ngram.Finding
 
 	// Analyze code
-	analysis, err := rc.ngramService.AnalyzeCode(
-		c.Request.Context(),
-		request.RepoName,
-		request.Language,
-		[]byte(request.Code),
-	)
-	if err != nil {
-		rc.logger.Error("Failed to analyze code",
-			zap.String("repo_name", request.RepoName),
-			zap.String("language", request.Language),
-			zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to analyze code",
-			"details": err.Error(),
+	if request.Code != "" {
+		analysis, err := rc.ngramService.AnalyzeCode(
+			c.Request.Context(),
+			request.RepoName,
+			request.Language,
+			[]byte(request.Code),
+		)
+		if err != nil {
+			rc.log(c).Error("Failed to analyze code",
+				zap.String("repo_name", request.RepoName),
+				zap.String("language", request.Language),
+				zap.Error(err))
+			c.JSON(errorStatus(err), gin.H{
+				"error":   "Failed to analyze code",
+				"details": err.Error(),
+			})
+			return
+		}
+		response.TokenCount = analysis.TokenCount
+		response.Entropy = analysis.Entropy
+		response.Perplexity = analysis.Perplexity
+		response.ZScore = analysis.ZScore
+		response.Lines = toLineScores(analysis.Lines)
+		findings = append(findings, analysis.Findings(request.FilePath, minZScore)...)
+	}
+
+	for _, file := range request.Files {
+		language := file.Language
+		if language == "" {
+			language = request.Language
+		}
+		analysis, err := rc.ngramService.AnalyzeFile(c.Request.Context(), request.RepoName, file.FilePath, language, []byte(file.Code))
+		if err != nil {
+			rc.log(c).Error("Failed to analyze file",
+				zap.String("repo_name", request.RepoName),
+				zap.String("file_path", file.FilePath),
+				zap.Error(err))
+			c.JSON(errorStatus(err), gin.H{
+				"error":   "Failed to analyze " + file.FilePath,
+				"details": err.Error(),
+			})
+			return
+		}
+		response.Files = append(response.Files, model.AnalyzedFile{
+			FilePath:   file.FilePath,
+			Language:   analysis.Language,
+			TokenCount: analysis.TokenCount,
+			Entropy:    analysis.Entropy,
+			Perplexity: analysis.Perplexity,
+			ZScore:     analysis.ZScore,
+			Lines:      toLineScores(analysis.Lines),
 		})
-		return
+		findings = append(findings, analysis.Findings(file.FilePath, minZScore)...)
 	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].ZScore > findings[j].ZScore
+	})
 
-	response := model.AnalyzeCodeResponse{
-		RepoName:   request.RepoName,
-		Language:   request.Language,
-		TokenCount: analysis.TokenCount,
-		Entropy:    analysis.Entropy,
-		Perplexity: analysis.Perplexity,
+	if request.Format == "sarif" {
+		c.JSON(http.StatusOK, ngram.NewSARIFLog(request.RepoName, findings))
+		return
+	}
+	response.Findings = make([]model.NaturalnessFinding, len(findings))
+	for i, f := range findings {
+		response.Findings[i] = model.NaturalnessFinding(f)
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
+func toLineScores(lines []ngram.LineScore) []model.LineScore {
+	scores := make([]model.LineScore, len(lines))
+	for i, line := range lines {
+		scores[i] = model.LineScore(line)
+	}
+	return scores
+}
+
 // CalculateZScore calculates z-score for a code snippet
 func (rc *RepoController) CalculateZScore(c *gin.Context) {
 	var request model.CalculateZScoreRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
-		rc.logger.Error("Invalid request payload", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request payload",
-			"details": err.Error(),
-		})
+	if err := bindRequest(c, &request); err != nil {
+		rc.log(c).Error("Invalid request payload", zap.Error(err))
+		c.JSON(http.StatusBadRequest, invalidRequestBody(err))
 		return
 	}
 
 	// Check if n-gram service is available
 	if rc.ngramService == nil {
-		rc.logger.Error("N-gram service not available")
-		c.JSON(http.StatusServiceUnavailable, gin.H{
-			"error": "N-gram service not available",
-		})
-		return
-	}
-
-	// Validate language
-	validLanguages := map[string]bool{
-		"go":         true,
-		"python":     true,
-		"java":       true,
-		"javascript": true,
-		"typescript": true,
-	}
-	if !validLanguages[request.Language] {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Unsupported language. Supported: go, python, java, javascript, typescript",
-		})
+		rc.log(c).Error("N-gram service not available")
+		serviceUnavailable(c, rc.unavailable, ServiceNgram, "N-gram service not available")
 		return
 	}
 
@@ -797,11 +1394,11 @@ func (rc *RepoController) CalculateZScore(c *gin.Context) {
 		[]byte(request.Code),
 	)
 	if err != nil {
-		rc.logger.Error("Failed to calculate z-score",
+		rc.log(c).Error("Failed to calculate z-score",
 			zap.String("repo_name", request.RepoName),
 			zap.String("language", request.Language),
 			zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(errorStatus(err), gin.H{
 			"error":   "Failed to calculate z-score",
 			"details": err.Error(),
 		})
@@ -863,33 +1460,22 @@ type IndexedFileResult struct {
 	FileSHA      string   `json:"file_sha,omitempty"`
 	Processors   []string `json:"processors_run,omitempty"`
 	Success      bool     `json:"success"`
+	Skipped      string   `json:"skipped,omitempty"` // why the file was not indexed (e.g. "binary")
 	Error        string   `json:"error,omitempty"`
 }
 
 // IndexFile indexes multiple files through all registered processors in parallel
 func (rc *RepoController) IndexFile(c *gin.Context) {
 	var request IndexFileRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
-		rc.logger.Error("Invalid request payload", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid request payload",
-			"details": err.Error(),
-		})
-		return
-	}
-
-	// Validate that we have files to process
-	if len(request.RelativePaths) == 0 {
-		rc.logger.Error("No files specified in request")
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "No files specified. Please provide at least one file path.",
-		})
+	if err := bindRequest(c, &request); err != nil {
+		rc.log(c).Error("Invalid request payload", zap.Error(err))
+		c.JSON(http.StatusBadRequest, invalidRequestBody(err))
 		return
 	}
 
 	// Check if processors are available
 	if len(rc.processors) == 0 {
-		rc.logger.Error("No processors available - processors may not be enabled")
+		rc.log(c).Error("No processors available - processors may not be enabled")
 		c.JSON(http.StatusServiceUnavailable, gin.H{
 			"error": "No processors available. Ensure processors are enabled in configuration.",
 		})
@@ -898,10 +1484,8 @@ func (rc *RepoController) IndexFile(c *gin.Context) {
 
 	// Check if MySQL is available (needed for file version tracking)
 	if rc.mysqlConn == nil {
-		rc.logger.Error("MySQL connection not available")
-		c.JSON(http.StatusServiceUnavailable, gin.H{
-			"error": "MySQL connection not available. File indexing requires MySQL.",
-		})
+		rc.log(c).Error("MySQL connection not available")
+		serviceUnavailable(c, rc.unavailable, ServiceMySQL, "MySQL connection not available. File indexing requires MySQL.")
 		return
 	}
 
@@ -910,7 +1494,7 @@ func (rc *RepoController) IndexFile(c *gin.Context) {
 	// Get repository configuration
 	repo, err := rc.config.GetRepository(request.RepoName)
 	if err != nil {
-		rc.logger.Error("Repository not found", zap.String("repo_name", request.RepoName), zap.Error(err))
+		rc.log(c).Error("Repository not found", zap.String("repo_name", request.RepoName), zap.Error(err))
 		c.JSON(http.StatusNotFound, gin.H{
 			"error":   "Repository not found",
 			"details": err.Error(),
@@ -921,10 +1505,10 @@ func (rc *RepoController) IndexFile(c *gin.Context) {
 	// Create FileVersionRepository for this repository (shared across all files)
 	fileVersionRepo, err := db.NewFileVersionRepository(rc.mysqlConn.GetDB(), repo.Name, rc.logger)
 	if err != nil {
-		rc.logger.Error("Failed to create file version repository",
+		rc.log(c).Error("Failed to create file version repository",
 			zap.String("repo_name", repo.Name),
 			zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(errorStatus(err), gin.H{
 			"error":   "Failed to create file version repository",
 			"details": err.Error(),
 		})
@@ -937,7 +1521,7 @@ func (rc *RepoController) IndexFile(c *gin.Context) {
 		maxConcurrent = 5
 	}
 
-	rc.logger.Info("Starting parallel file indexing",
+	rc.log(c).Info("Starting parallel file indexing",
 		zap.String("repo_name", request.RepoName),
 		zap.Int("file_count", len(request.RelativePaths)),
 		zap.Int("max_concurrent", maxConcurrent))
@@ -955,8 +1539,9 @@ func (rc *RepoController) IndexFile(c *gin.Context) {
 			failureCount++
 		}
 	}
+	access.Record(ctx, access.Usage{FilesIndexed: int64(successCount)})
 
-	rc.logger.Info("Completed parallel file indexing",
+	rc.log(c).Info("Completed parallel file indexing",
 		zap.String("repo_name", request.RepoName),
 		zap.Int("total_files", len(request.RelativePaths)),
 		zap.Int("successes", successCount),
@@ -986,7 +1571,7 @@ func (rc *RepoController) processFilesInParallel(ctx context.Context, repo *conf
 	for w := 0; w < maxConcurrent; w++ {
 		go func(workerID int) {
 			for job := range jobs {
-				rc.logger.Debug("Worker processing file",
+				rc.log(ctx).Debug("Worker processing file",
 					zap.Int("worker_id", workerID),
 					zap.String("file", job.relativePath))
 
@@ -1022,7 +1607,7 @@ func (rc *RepoController) processSingleFile(ctx context.Context, repo *config.Re
 
 	// Check if file exists
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		rc.logger.Error("File not found", zap.String("file_path", filePath))
+		rc.log(ctx).Error("File not found", zap.String("file_path", filePath))
 		return IndexedFileResult{
 			RelativePath: relativePath,
 			Success:      false,
@@ -1033,7 +1618,7 @@ func (rc *RepoController) processSingleFile(ctx context.Context, repo *config.Re
 	// Read file content
 	content, err := os.ReadFile(filePath)
 	if err != nil {
-		rc.logger.Error("Failed to read file", zap.String("file_path", filePath), zap.Error(err))
+		rc.log(ctx).Error("Failed to read file", zap.String("file_path", filePath), zap.Error(err))
 		return IndexedFileResult{
 			RelativePath: relativePath,
 			Success:      false,
@@ -1047,7 +1632,7 @@ func (rc *RepoController) processSingleFile(ctx context.Context, repo *config.Re
 	// Get or create FileID from MySQL
 	fileID, err := fileVersionRepo.GetOrCreateFileID(fileSHA, relativePath, true, nil)
 	if err != nil {
-		rc.logger.Error("Failed to create file ID", zap.String("file_path", filePath), zap.Error(err))
+		rc.log(ctx).Error("Failed to create file ID", zap.String("file_path", filePath), zap.Error(err))
 		return IndexedFileResult{
 			RelativePath: relativePath,
 			Success:      false,
@@ -1055,6 +1640,20 @@ func (rc *RepoController) processSingleFile(ctx context.Context, repo *config.Re
 		}
 	}
 
+	// Binary and non-UTF8 files are recorded as skipped rather than parsed
+	if reason := util.ContentSkipReason(content); reason != "" {
+		if err := fileVersionRepo.MarkSkipped(fileID, reason); err != nil {
+			rc.log(ctx).Warn("Failed to record skipped file", zap.Int32("file_id", fileID), zap.Error(err))
+		}
+		return IndexedFileResult{
+			RelativePath: relativePath,
+			FileID:       fileID,
+			FileSHA:      fileSHA,
+			Success:      true,
+			Skipped:      reason,
+		}
+	}
+
 	// Create FileContext
 	fileCtx := &FileContext{
 		FileID:       fileID,
@@ -1065,18 +1664,22 @@ func (rc *RepoController) processSingleFile(ctx context.Context, repo *config.Re
 		CommitID:     nil,
 		Ephemeral:    true,
 	}
+	fileCtx.LightweightReason = util.LightweightReason(filePath, content, repo)
 
 	// Process through all processors
 	processorsRun := []string{}
 	for _, processor := range rc.processors {
-		rc.logger.Debug("Processing file with processor",
+		rc.log(ctx).Debug("Processing file with processor",
 			zap.String("processor", processor.Name()),
 			zap.String("file_path", relativePath),
 			zap.Int32("file_id", fileID))
 
-		err := processor.ProcessFile(ctx, repo, fileCtx)
+		// Interactive requests (editor saves) are admitted ahead of bulk builds
+		err := rc.scheduler.Run(ctx, PriorityInteractive, func() error {
+			return processor.ProcessFile(ctx, repo, fileCtx)
+		})
 		if err != nil {
-			rc.logger.Error("Processor failed to process file",
+			rc.log(ctx).Error("Processor failed to process file",
 				zap.String("processor", processor.Name()),
 				zap.String("file_path", filePath),
 				zap.Error(err))
@@ -1094,7 +1697,7 @@ func (rc *RepoController) processSingleFile(ctx context.Context, repo *config.Re
 		// Update status to indicate this processor completed
 		processorStatus := fmt.Sprintf("%s_done", processor.Name())
 		if err := fileVersionRepo.UpdateStatus(fileID, processorStatus); err != nil {
-			rc.logger.Warn("Failed to update processor status",
+			rc.log(ctx).Warn("Failed to update processor status",
 				zap.String("processor", processor.Name()),
 				zap.Int32("file_id", fileID),
 				zap.Error(err))
@@ -1103,12 +1706,12 @@ func (rc *RepoController) processSingleFile(ctx context.Context, repo *config.Re
 
 	// Mark file as fully processed
 	if err := fileVersionRepo.UpdateStatus(fileID, "done"); err != nil {
-		rc.logger.Warn("Failed to update final status",
+		rc.log(ctx).Warn("Failed to update final status",
 			zap.Int32("file_id", fileID),
 			zap.Error(err))
 	}
 
-	rc.logger.Info("Successfully indexed file",
+	rc.log(ctx).Info("Successfully indexed file",
 		zap.String("repo_name", repo.Name),
 		zap.String("relative_path", relativePath),
 		zap.Int32("file_id", fileID),
@@ -1122,3 +1725,8 @@ func (rc *RepoController) processSingleFile(ctx context.Context, repo *config.Re
 		Success:      true,
 	}
 }
+
+// log returns the logger with the request ID carried by ctx attached
+func (rc *RepoController) log(ctx context.Context) *zap.Logger {
+	return logging.FromContext(ctx, rc.logger)
+}
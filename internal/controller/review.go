@@ -0,0 +1,438 @@
+package controller
+
+import (
+	"bot-go/internal/apperrors"
+	"bot-go/internal/codeapi"
+	"bot-go/internal/config"
+	"bot-go/internal/model"
+	"bot-go/internal/model/ast"
+	"bot-go/internal/service/ngram"
+	gitutil "bot-go/internal/signals/util"
+	"bot-go/internal/util"
+	"bot-go/pkg/lsp/base"
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	reviewTestDepth      = 3 // caller levels searched for the tests of a changed function
+	defaultReviewCallers = 10
+	defaultReviewSimilar = 3
+)
+
+// ReviewRequest asks for the review context of a change to a repository
+type ReviewRequest struct {
+	Diff         string `json:"diff" binding:"required"` // Unified diff against the indexed version, e.g. git diff output
+	MaxCallers   int    `json:"max_callers"`             // Direct callers listed per function (default 10)
+	SimilarLimit int    `json:"similar_limit"`           // Similar existing functions per hunk (default 3)
+}
+
+// ReviewResponse is the review context of every hunk of a diff
+type ReviewResponse struct {
+	RepoName string       `json:"repo_name"`
+	Files    int          `json:"files"`
+	Hunks    []ReviewHunk `json:"hunks"`
+	// Context left out because its service is not running, with the reason
+	Unavailable map[string]string `json:"unavailable,omitempty"`
+}
+
+// ReviewHunk is the context of one hunk. Functions are looked up in the indexed version of
+// the file, which the diff's old side is expected to match.
+type ReviewHunk struct {
+	FilePath    string                `json:"file_path"`
+	OldPath     string                `json:"old_path,omitempty"` // when the file was renamed
+	Status      string                `json:"status"`             // "added", "deleted", "renamed" or "modified"
+	OldStart    int                   `json:"old_start"`
+	OldLines    int                   `json:"old_lines"`
+	NewStart    int                   `json:"new_start"`
+	NewLines    int                   `json:"new_lines"`
+	Section     string                `json:"section,omitempty"`
+	Added       int                   `json:"added"`
+	Removed     int                   `json:"removed"`
+	Functions   []ReviewFunction      `json:"functions"`             // Functions with changed lines
+	Tests       []ReviewTest          `json:"tests"`                 // Tests calling those functions
+	Owners      []string              `json:"owners"`                // CODEOWNERS owners of the file
+	Naturalness *ReviewNaturalness    `json:"naturalness,omitempty"` // Of the added lines
+	Similar     []ReviewSimilarResult `json:"similar,omitempty"`     // Existing functions like the added lines
+}
+
+// ReviewFunction is a function a hunk changes
+type ReviewFunction struct {
+	ID             int64          `json:"id"`
+	Name           string         `json:"name"`
+	Range          base.Range     `json:"range"`
+	LastModifiedBy string         `json:"last_modified_by,omitempty"` // needs blame metadata
+	LastModifiedAt int64          `json:"last_modified_at,omitempty"` // unix seconds
+	CallerCount    int            `json:"caller_count"`
+	Callers        []ReviewCaller `json:"callers"` // up to max_callers direct callers
+}
+
+// ReviewCaller is a function calling a changed function
+type ReviewCaller struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	FilePath string `json:"file_path"`
+}
+
+// ReviewTest is a function of a test file that calls a changed function, directly or
+// through other functions
+type ReviewTest struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	FilePath string `json:"file_path"`
+	Depth    int    `json:"depth"` // call levels to the nearest changed function
+}
+
+// ReviewNaturalness scores the added lines of a hunk against the repository's n-gram corpus
+type ReviewNaturalness struct {
+	TokenCount int                        `json:"token_count"`
+	Entropy    float64                    `json:"entropy"`
+	ZScore     float64                    `json:"z_score"`
+	Findings   []model.NaturalnessFinding `json:"findings"` // Added lines at or above a z-score of 2, by new file line
+}
+
+// ReviewSimilarResult is an existing function similar to the added lines of a hunk
+type ReviewSimilarResult struct {
+	Chunk *model.CodeChunk `json:"chunk"`
+	Score float32          `json:"score"`
+}
+
+// SetCodeAPI enables the endpoints that traverse the code graph, such as review
+func (rc *RepoController) SetCodeAPI(api codeapi.CodeAPI) {
+	rc.codeAPI = api
+}
+
+// Review returns the context a reviewer needs for every hunk of a unified diff in one call:
+// the changed functions with their callers, the tests reaching them, the owners of the file,
+// the naturalness of the added lines and similar existing implementations. Naturalness and
+// similar code are left out, and listed under "unavailable", when their services are down.
+func (rc *RepoController) Review(c *gin.Context) {
+	var req ReviewRequest
+	if err := bindRequest(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, invalidRequestBody(err))
+		return
+	}
+	repo, err := rc.config.GetRepository(c.Param("name"))
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	if rc.codeGraph == nil || rc.codeAPI == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "code graph is not enabled"})
+		return
+	}
+	files, err := gitutil.ParseUnifiedDiff(req.Diff)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid diff", "details": err.Error()})
+		return
+	}
+	if req.MaxCallers <= 0 {
+		req.MaxCallers = defaultReviewCallers
+	}
+	if req.SimilarLimit <= 0 {
+		req.SimilarLimit = defaultReviewSimilar
+	}
+
+	owners, err := gitutil.LoadCodeOwners(repo.Path)
+	if err != nil {
+		rc.log(c).Warn("Failed to read CODEOWNERS", zap.String("repo_name", repo.Name), zap.Error(err))
+	}
+	r := &reviewer{
+		rc:          rc,
+		repo:        repo,
+		req:         req,
+		owners:      owners,
+		callers:     make(map[ast.NodeID]*codeapi.CallGraph),
+		unavailable: make(map[string]string),
+	}
+	if rc.ngramService == nil {
+		r.markUnavailable(ServiceNgram, "")
+	}
+	if rc.chunkService == nil {
+		r.markUnavailable(ServiceVectorSearch, "")
+	}
+
+	ctx := c.Request.Context()
+	response := ReviewResponse{RepoName: repo.Name, Files: len(files), Hunks: []ReviewHunk{}}
+	for i := range files {
+		for j := range files[i].Hunks {
+			hunk, err := r.hunk(ctx, &files[i], &files[i].Hunks[j])
+			if err != nil {
+				c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+				return
+			}
+			response.Hunks = append(response.Hunks, hunk)
+		}
+	}
+	if len(r.unavailable) > 0 {
+		response.Unavailable = r.unavailable
+	}
+
+	rc.log(c).Info("Built review context",
+		zap.String("repo_name", repo.Name),
+		zap.Int("files", len(files)),
+		zap.Int("hunks", len(response.Hunks)))
+	c.JSON(http.StatusOK, response)
+}
+
+// reviewer builds the context of the hunks of one review request
+type reviewer struct {
+	rc          *RepoController
+	repo        *config.Repository
+	req         ReviewRequest
+	owners      *gitutil.CodeOwners
+	callers     map[ast.NodeID]*codeapi.CallGraph // callers of each changed function, up to reviewTestDepth
+	unavailable map[string]string
+}
+
+func (r *reviewer) markUnavailable(service, reason string) {
+	r.unavailable[service] = r.rc.unavailableReason(service, reason)
+}
+
+func (r *reviewer) hunk(ctx context.Context, file *gitutil.FileDiff, h *gitutil.Hunk) (ReviewHunk, error) {
+	hunk := ReviewHunk{
+		FilePath:  file.Path(),
+		Status:    diffStatus(file),
+		OldStart:  h.OldStart,
+		OldLines:  h.OldLines,
+		NewStart:  h.NewStart,
+		NewLines:  h.NewLines,
+		Section:   h.Section,
+		Added:     len(h.Added),
+		Removed:   len(h.Removed),
+		Functions: []ReviewFunction{},
+		Tests:     []ReviewTest{},
+		Owners:    r.owners.Owners(file.Path()),
+	}
+	if hunk.Status == "renamed" {
+		hunk.OldPath = file.OldPath
+	}
+	if hunk.Owners == nil {
+		hunk.Owners = []string{}
+	}
+
+	if file.OldPath != "" {
+		functions, err := r.changedFunctions(ctx, file.OldPath, h)
+		if err != nil {
+			return hunk, err
+		}
+		tests := make(map[ast.NodeID]*ReviewTest)
+		for _, fn := range functions {
+			reviewed, err := r.function(ctx, fn, tests)
+			if err != nil {
+				return hunk, err
+			}
+			hunk.Functions = append(hunk.Functions, reviewed)
+		}
+		for _, test := range tests {
+			hunk.Tests = append(hunk.Tests, *test)
+		}
+		sort.Slice(hunk.Tests, func(i, j int) bool {
+			if hunk.Tests[i].Depth != hunk.Tests[j].Depth {
+				return hunk.Tests[i].Depth < hunk.Tests[j].Depth
+			}
+			return hunk.Tests[i].ID < hunk.Tests[j].ID
+		})
+	}
+
+	if len(h.Added) > 0 {
+		lines := make([]string, len(h.Added))
+		for i, line := range h.Added {
+			lines[i] = line.Text
+		}
+		code := strings.Join(lines, "\n")
+		hunk.Naturalness = r.naturalness(ctx, file.Path(), code, h.Added)
+		hunk.Similar = r.similar(ctx, file.OldPath, h, code)
+	}
+	return hunk, nil
+}
+
+func diffStatus(file *gitutil.FileDiff) string {
+	switch {
+	case file.OldPath == "":
+		return "added"
+	case file.NewPath == "":
+		return "deleted"
+	case file.OldPath != file.NewPath:
+		return "renamed"
+	}
+	return "modified"
+}
+
+// changedFunctions returns the functions of the indexed file containing a removed line or
+// the position of an added line. Context lines do not count, so the functions around a hunk
+// are not reported.
+func (r *reviewer) changedFunctions(ctx context.Context, oldPath string, h *gitutil.Hunk) ([]*ast.Node, error) {
+	first, last := h.OldStart+h.OldLines, h.OldStart-1
+	for _, line := range h.Removed {
+		first, last = min(first, line.Line), max(last, line.Line)
+	}
+	for _, line := range h.Added {
+		first, last = min(first, line.After), max(last, line.After+1)
+	}
+	if first > last {
+		return nil, nil
+	}
+
+	candidates, err := r.rc.codeGraph.FindNodesInLines(ctx, r.repo.Name, oldPath, max(first-1, 0), last-1, ast.NodeTypeFunction)
+	if err != nil {
+		return nil, err
+	}
+	var changed []*ast.Node
+	for _, fn := range candidates {
+		if hunkChanges(h, fn.Range) {
+			changed = append(changed, fn)
+		}
+	}
+	return changed, nil
+}
+
+// hunkChanges reports whether a hunk removes a line of the 0-based range or adds a line
+// inside it: after its first line and before its last
+func hunkChanges(h *gitutil.Hunk, rng base.Range) bool {
+	start, end := rng.Start.Line+1, rng.End.Line+1
+	for _, line := range h.Removed {
+		if line.Line >= start && line.Line <= end {
+			return true
+		}
+	}
+	for _, line := range h.Added {
+		if line.After >= start && line.After < end {
+			return true
+		}
+	}
+	return false
+}
+
+// function returns a changed function with its direct callers, and adds the test functions
+// among its callers to tests
+func (r *reviewer) function(ctx context.Context, fn *ast.Node, tests map[ast.NodeID]*ReviewTest) (ReviewFunction, error) {
+	reviewed := ReviewFunction{
+		ID:      int64(fn.ID),
+		Name:    fn.Name,
+		Range:   fn.Range,
+		Callers: []ReviewCaller{},
+	}
+	reviewed.LastModifiedBy, _ = fn.MetaData[MetaLastModifiedBy].(string)
+	reviewed.LastModifiedAt = blameTime(fn)
+
+	graph, ok := r.callers[fn.ID]
+	if !ok {
+		var err error
+		if graph, err = r.rc.codeAPI.Analyzer().GetCallers(ctx, fn.ID, reviewTestDepth); err != nil {
+			return reviewed, err
+		}
+		r.callers[fn.ID] = graph
+	}
+
+	nodes := make([]*codeapi.CallNode, 0, len(graph.Nodes))
+	for _, node := range graph.Nodes {
+		if node.ID != fn.ID && node.Depth > 0 {
+			nodes = append(nodes, node)
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].Depth != nodes[j].Depth {
+			return nodes[i].Depth < nodes[j].Depth
+		}
+		return nodes[i].ID < nodes[j].ID
+	})
+	for _, node := range nodes {
+		if node.Depth == 1 {
+			reviewed.CallerCount++
+			if len(reviewed.Callers) < r.req.MaxCallers {
+				reviewed.Callers = append(reviewed.Callers, ReviewCaller{ID: int64(node.ID), Name: node.Name, FilePath: node.FilePath})
+			}
+		}
+		if util.IsTestFile(node.FilePath) {
+			if test, ok := tests[node.ID]; !ok || node.Depth < test.Depth {
+				tests[node.ID] = &ReviewTest{ID: int64(node.ID), Name: node.Name, FilePath: node.FilePath, Depth: node.Depth}
+			}
+		}
+	}
+	return reviewed, nil
+}
+
+// naturalness scores the added lines of a hunk, with findings numbered in the new file. It
+// returns nil for languages without a tokenizer and repositories without an n-gram corpus.
+func (r *reviewer) naturalness(ctx context.Context, filePath, code string, added []gitutil.DiffLine) *ReviewNaturalness {
+	if r.rc.ngramService == nil {
+		return nil
+	}
+	analysis, err := r.rc.ngramService.AnalyzeFile(ctx, r.repo.Name, filePath, "", []byte(code))
+	if errors.Is(err, apperrors.ErrNotFound) {
+		r.markUnavailable(ServiceNgram, "no n-gram corpus for the repository; run /processNGram")
+		return nil
+	}
+	if err != nil {
+		if !errors.Is(err, apperrors.ErrUnsupportedLanguage) {
+			r.rc.log(ctx).Warn("Failed to analyze added lines",
+				zap.String("repo_name", r.repo.Name),
+				zap.String("file_path", filePath),
+				zap.Error(err))
+		}
+		return nil
+	}
+
+	naturalness := &ReviewNaturalness{
+		TokenCount: analysis.TokenCount,
+		Entropy:    analysis.Entropy,
+		ZScore:     analysis.ZScore,
+		Findings:   []model.NaturalnessFinding{},
+	}
+	for _, finding := range analysis.Findings(filePath, ngram.DefaultFindingZScore) {
+		finding.Line = added[finding.Line-1].Line
+		naturalness.Findings = append(naturalness.Findings, model.NaturalnessFinding(finding))
+	}
+	return naturalness
+}
+
+// similar returns the indexed functions most similar to the added lines of a hunk, other
+// than the ones the hunk changes
+func (r *reviewer) similar(ctx context.Context, oldPath string, h *gitutil.Hunk, code string) []ReviewSimilarResult {
+	if r.rc.chunkService == nil || r.unavailable[ServiceVectorSearch] != "" {
+		return nil
+	}
+	filter := map[string]interface{}{"chunk_type": string(model.ChunkTypeFunction)}
+	chunks, scores, err := r.rc.chunkService.SearchSimilarCode(ctx, r.repo.Name, code, r.req.SimilarLimit*2, filter, true)
+	if errors.Is(err, apperrors.ErrEmbeddingUnavailable) {
+		r.markUnavailable(ServiceVectorSearch, err.Error())
+		return nil
+	}
+	if err != nil {
+		r.rc.log(ctx).Warn("Failed to search code similar to added lines",
+			zap.String("repo_name", r.repo.Name),
+			zap.String("file_path", oldPath),
+			zap.Error(err))
+		return nil
+	}
+
+	results := []ReviewSimilarResult{}
+	for i, chunk := range chunks {
+		if len(results) == r.req.SimilarLimit {
+			break
+		}
+		chunkPath := chunk.FilePath
+		if filepath.IsAbs(chunkPath) {
+			if rel, err := filepath.Rel(r.repo.Path, chunkPath); err == nil {
+				chunkPath = filepath.ToSlash(rel)
+			}
+		}
+		// The indexed version of the changed code is not an existing implementation
+		if chunkPath == oldPath && hunkChanges(h, base.Range{
+			Start: base.Position{Line: chunk.StartLine},
+			End:   base.Position{Line: chunk.EndLine},
+		}) {
+			continue
+		}
+		results = append(results, ReviewSimilarResult{Chunk: chunk, Score: scores[i]})
+	}
+	return results
+}
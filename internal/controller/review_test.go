@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"testing"
+
+	gitutil "bot-go/internal/signals/util"
+	"bot-go/pkg/lsp/base"
+)
+
+func TestHunkChanges(t *testing.T) {
+	files, err := gitutil.ParseUnifiedDiff(`--- a/store.go
++++ b/store.go
+@@ -9,7 +9,8 @@
+ }
+ 
+ func Get(key string) string {
+-	return items[key]
++	value := items[key]
++	return value
+ }
+ 
+ func Put(key, value string) {
+@@ -30,0 +32,3 @@
++
++func Reset() {
++}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	modified, inserted := &files[0].Hunks[0], &files[0].Hunks[1]
+
+	// Ranges are 0-based: Get spans lines 11-13 of the old file, Put starts on line 16
+	lines := func(start, end int) base.Range {
+		return base.Range{Start: base.Position{Line: start - 1}, End: base.Position{Line: end - 1}}
+	}
+	tests := []struct {
+		name string
+		hunk *gitutil.Hunk
+		rng  base.Range
+		want bool
+	}{
+		{"changed function", modified, lines(11, 13), true},
+		{"function ending in the context", modified, lines(5, 9), false},
+		{"function starting in the context", modified, lines(15, 18), false},
+		{"function around an insertion", inserted, lines(25, 40), true},
+		{"function ending where lines are inserted", inserted, lines(25, 30), false},
+		{"function starting after an insertion", inserted, lines(31, 35), false},
+	}
+	for _, tt := range tests {
+		if got := hunkChanges(tt.hunk, tt.rng); got != tt.want {
+			t.Errorf("%s: hunkChanges = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDiffStatus(t *testing.T) {
+	tests := map[string]gitutil.FileDiff{
+		"added":    {NewPath: "a.go"},
+		"deleted":  {OldPath: "a.go"},
+		"renamed":  {OldPath: "a.go", NewPath: "b.go"},
+		"modified": {OldPath: "a.go", NewPath: "a.go"},
+	}
+	for want, file := range tests {
+		if got := diffStatus(&file); got != want {
+			t.Errorf("diffStatus(%+v) = %q, want %q", file, got, want)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"context"
+	"sync"
+)
+
+// Priority determines the order in which waiting processor executions are admitted
+type Priority int
+
+const (
+	// PriorityBackground is used for bulk index builds (BuildIndex, --build-index)
+	PriorityBackground Priority = iota
+	// PriorityInteractive is used for editor-driven requests (IndexFile) and preempts background work
+	PriorityInteractive
+)
+
+// ProcessingScheduler bounds the number of concurrent processor executions across all
+// builds in the process. When a slot frees up, interactive waiters are admitted before
+// background ones, so a file saved in the editor does not queue behind a bulk build.
+// A nil *ProcessingScheduler runs everything immediately without limits.
+type ProcessingScheduler struct {
+	mu       sync.Mutex
+	capacity int
+	running  int
+	waiting  [2][]chan struct{} // FIFO queues indexed by Priority
+}
+
+// NewProcessingScheduler creates a scheduler admitting at most capacity concurrent executions
+func NewProcessingScheduler(capacity int) *ProcessingScheduler {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &ProcessingScheduler{capacity: capacity}
+}
+
+// Acquire blocks until an execution slot is available for the given priority or ctx is done.
+// Every successful Acquire must be paired with a Release.
+func (s *ProcessingScheduler) Acquire(ctx context.Context, priority Priority) error {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	if s.running < s.capacity && s.canAdmitLocked(priority) {
+		s.running++
+		s.mu.Unlock()
+		return nil
+	}
+	ready := make(chan struct{})
+	s.waiting[priority] = append(s.waiting[priority], ready)
+	s.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		if !s.removeWaiterLocked(priority, ready) {
+			// The slot was handed to us concurrently with cancellation; pass it on
+			s.releaseLocked()
+		}
+		s.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Release frees a slot, handing it to the oldest interactive waiter if any,
+// otherwise to the oldest background waiter
+func (s *ProcessingScheduler) Release() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.releaseLocked()
+	s.mu.Unlock()
+}
+
+// Run executes fn once a slot is available and releases the slot afterwards
+func (s *ProcessingScheduler) Run(ctx context.Context, priority Priority, fn func() error) error {
+	if err := s.Acquire(ctx, priority); err != nil {
+		return err
+	}
+	defer s.Release()
+	return fn()
+}
+
+// Stats returns the number of running executions and waiters per priority
+func (s *ProcessingScheduler) Stats() (running, interactiveWaiting, backgroundWaiting int) {
+	if s == nil {
+		return 0, 0, 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running, len(s.waiting[PriorityInteractive]), len(s.waiting[PriorityBackground])
+}
+
+// canAdmitLocked reports whether a new arrival may skip the queue: nobody of equal or
+// higher priority may be waiting
+func (s *ProcessingScheduler) canAdmitLocked(priority Priority) bool {
+	for p := PriorityInteractive; p >= priority; p-- {
+		if len(s.waiting[p]) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *ProcessingScheduler) releaseLocked() {
+	for p := PriorityInteractive; p >= PriorityBackground; p-- {
+		if len(s.waiting[p]) > 0 {
+			next := s.waiting[p][0]
+			s.waiting[p] = s.waiting[p][1:]
+			close(next) // slot is transferred, running count unchanged
+			return
+		}
+	}
+	s.running--
+}
+
+func (s *ProcessingScheduler) removeWaiterLocked(priority Priority, ready chan struct{}) bool {
+	queue := s.waiting[priority]
+	for i, ch := range queue {
+		if ch == ready {
+			s.waiting[priority] = append(queue[:i], queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestProcessingScheduler_InteractiveFirst(t *testing.T) {
+	s := NewProcessingScheduler(1)
+	ctx := context.Background()
+
+	if err := s.Acquire(ctx, PriorityBackground); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	var mu sync.Mutex
+	var order []Priority
+	var wg sync.WaitGroup
+	start := func(p Priority) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.Run(ctx, p, func() error {
+				mu.Lock()
+				order = append(order, p)
+				mu.Unlock()
+				return nil
+			})
+		}()
+	}
+
+	// Queue a background waiter before the interactive one
+	start(PriorityBackground)
+	waitForWaiters(t, s, 0, 1)
+	start(PriorityInteractive)
+	waitForWaiters(t, s, 1, 1)
+
+	s.Release()
+	wg.Wait()
+
+	if len(order) != 2 || order[0] != PriorityInteractive || order[1] != PriorityBackground {
+		t.Fatalf("expected interactive before background, got %v", order)
+	}
+	if running, _, _ := s.Stats(); running != 0 {
+		t.Fatalf("expected no running executions, got %d", running)
+	}
+}
+
+func TestProcessingScheduler_CancelWhileWaiting(t *testing.T) {
+	s := NewProcessingScheduler(1)
+	if err := s.Acquire(context.Background(), PriorityBackground); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.Acquire(ctx, PriorityInteractive); err == nil {
+		t.Fatal("expected context error while scheduler is full")
+	}
+
+	s.Release()
+	if running, interactive, background := s.Stats(); running != 0 || interactive != 0 || background != 0 {
+		t.Fatalf("expected empty scheduler, got running=%d interactive=%d background=%d", running, interactive, background)
+	}
+}
+
+func waitForWaiters(t *testing.T, s *ProcessingScheduler, interactive, background int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if _, i, b := s.Stats(); i == interactive && b == background {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d interactive / %d background waiters", interactive, background)
+}
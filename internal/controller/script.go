@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"bot-go/internal/config"
+	"bot-go/internal/logging"
+	"bot-go/internal/script"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// RunScriptRequest is the request for running an analysis script
+type RunScriptRequest struct {
+	Script string         `json:"script" binding:"required"`
+	Args   map[string]any `json:"args"` // predeclared as the script's "args" dict
+}
+
+// RunScript evaluates a Starlark analysis script with the codeapi builtins (see package
+// script). Script errors return 400 with the Starlark backtrace and the output printed
+// before the failure.
+func (c *CodeAPIController) RunScript(ctx *gin.Context) {
+	var req RunScriptRequest
+	if err := bindRequest(ctx, &req); err != nil {
+		ctx.JSON(http.StatusBadRequest, invalidRequestBody(err))
+		return
+	}
+
+	var admin config.AdminConfig
+	if c.config != nil {
+		admin = c.config.Admin
+	}
+	result, err := script.Run(ctx.Request.Context(), c.api, req.Script, req.Args, script.ConfigLimits(admin))
+	if err != nil {
+		status := errorStatus(err)
+		if errors.Is(err, script.ErrScript) {
+			status = http.StatusBadRequest
+		}
+		response := gin.H{"error": err.Error()}
+		var scriptErr *script.Error
+		if errors.As(err, &scriptErr) {
+			response["backtrace"] = scriptErr.Backtrace
+			response["output"] = scriptErr.Output
+		}
+		ctx.JSON(status, response)
+		return
+	}
+	logging.FromContext(ctx.Request.Context(), c.logger).Info("Ran analysis script",
+		zap.Uint64("steps", result.Steps),
+		zap.Int("calls", result.Calls))
+	ctx.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,234 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"bot-go/internal/config"
+	"bot-go/internal/db"
+	"bot-go/internal/model"
+	"bot-go/internal/service/textsearch"
+	"bot-go/internal/util"
+
+	"go.uber.org/zap"
+)
+
+// Codes of the diagnostics returned with empty search results
+const (
+	DiagnosticCollectionMissing = "collection_missing"
+	DiagnosticCollectionEmpty   = "collection_empty"
+	DiagnosticNotIndexed        = "not_indexed"
+	DiagnosticLanguageFiltered  = "language_filtered"
+	DiagnosticPathFiltered      = "path_filtered"
+	DiagnosticNoCandidates      = "no_candidate_files"
+	DiagnosticCaseMismatch      = "case_mismatch"
+	DiagnosticStaleIndex        = "stale_index"
+	DiagnosticEmbeddingDegraded = "embedding_degraded"
+	DiagnosticSeenInSession     = "seen_in_session"
+)
+
+// maxListedLanguages bounds the indexed languages a language_filtered diagnostic lists
+const maxListedLanguages = 5
+
+// searchDiagnostics collects the hints returned with an empty search result. Checks that
+// fail are logged and skipped: diagnostics are best effort and never fail the search.
+type searchDiagnostics struct {
+	rc          *RepoController
+	ctx         context.Context
+	diagnostics []model.SearchDiagnostic
+}
+
+func (rc *RepoController) newSearchDiagnostics(ctx context.Context) *searchDiagnostics {
+	return &searchDiagnostics{rc: rc, ctx: ctx}
+}
+
+func (d *searchDiagnostics) add(code, format string, args ...any) {
+	d.diagnostics = append(d.diagnostics, model.SearchDiagnostic{Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+func (d *searchDiagnostics) skip(check string, err error) {
+	d.rc.log(d.ctx).Debug("Search diagnostic check failed", zap.String("check", check), zap.Error(err))
+}
+
+// collection checks that a vector collection exists and holds chunks. It returns false when
+// it does not, so callers can skip checks that only matter for a populated collection.
+func (d *searchDiagnostics) collection(collectionName string) bool {
+	if d.rc.chunkService == nil {
+		return true
+	}
+	vectorDB := d.rc.chunkService.GetVectorDB()
+	exists, err := vectorDB.CollectionExists(d.ctx, collectionName)
+	if err != nil {
+		d.skip("collection", err)
+		return true
+	}
+	if !exists {
+		d.add(DiagnosticCollectionMissing,
+			"Collection %q does not exist: the repository has not been indexed with embeddings (index_building.enable_embeddings)",
+			collectionName)
+		return false
+	}
+	chunks, _, err := vectorDB.ScrollChunks(d.ctx, collectionName, "", 1)
+	if err != nil {
+		d.skip("collection", err)
+		return true
+	}
+	if len(chunks) == 0 {
+		d.add(DiagnosticCollectionEmpty,
+			"Collection %q has no chunks: the last index build embedded nothing, or the chunks were purged",
+			collectionName)
+		return false
+	}
+	return true
+}
+
+// textFilters checks the language and path prefix of a search against the files of the
+// repository's text index. It returns the indexed files passing both filters, or nil when
+// the index cannot be read.
+func (d *searchDiagnostics) textFilters(repoName, language, pathPrefix string) []textsearch.Document {
+	if d.rc.textSearch == nil {
+		return nil
+	}
+	docs, err := d.rc.textSearch.Documents(repoName)
+	if err != nil {
+		d.skip("text_filters", err)
+		return nil
+	}
+	if len(docs) == 0 {
+		d.add(DiagnosticNotIndexed, "The text index of %s holds no files", repoName)
+		return nil
+	}
+
+	languages := make(map[string]int)
+	var inLanguage, inPath, passing []textsearch.Document
+	for _, doc := range docs {
+		languages[doc.Language]++
+		matchesLanguage := language == "" || strings.EqualFold(doc.Language, language)
+		matchesPath := pathPrefix == "" || strings.HasPrefix(doc.RelativePath, pathPrefix)
+		if matchesLanguage {
+			inLanguage = append(inLanguage, doc)
+		}
+		if matchesPath {
+			inPath = append(inPath, doc)
+		}
+		if matchesLanguage && matchesPath {
+			passing = append(passing, doc)
+		}
+	}
+	switch {
+	case len(inLanguage) == 0:
+		d.add(DiagnosticLanguageFiltered, "None of the %d indexed files of %s is %s; indexed languages: %s",
+			len(docs), repoName, language, languageCounts(languages))
+	case len(inPath) == 0:
+		d.add(DiagnosticPathFiltered, "No indexed file of %s is under %q", repoName, pathPrefix)
+	case len(passing) == 0:
+		d.add(DiagnosticPathFiltered, "%s has %d indexed %s files, none of them under %q",
+			repoName, len(inLanguage), language, pathPrefix)
+	}
+	return passing
+}
+
+// languageCounts formats the most common indexed languages, e.g. "go (120), python (4)"
+func languageCounts(counts map[string]int) string {
+	languages := make([]string, 0, len(counts))
+	for language := range counts {
+		languages = append(languages, language)
+	}
+	sort.Slice(languages, func(i, j int) bool {
+		if counts[languages[i]] != counts[languages[j]] {
+			return counts[languages[i]] > counts[languages[j]]
+		}
+		return languages[i] < languages[j]
+	})
+	parts := make([]string, 0, maxListedLanguages)
+	for i, language := range languages {
+		if i == maxListedLanguages {
+			parts = append(parts, fmt.Sprintf("%d more", len(languages)-i))
+			break
+		}
+		if language == "" {
+			language = "unknown"
+		}
+		parts = append(parts, fmt.Sprintf("%s (%d)", language, counts[languages[i]]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// indexAge reports a repository whose HEAD commit is newer than its last index build, so
+// recently committed code may not be indexed. The build time is that of the text index, or
+// else the last file version recorded in MySQL.
+func (d *searchDiagnostics) indexAge(repo *config.Repository) {
+	indexedAt, source, ok := d.lastIndexed(repo.Name)
+	if !ok {
+		return
+	}
+	head, err := util.HeadCommitTime(repo.Path)
+	if err != nil {
+		d.skip("index_age", err)
+		return
+	}
+	if head.After(indexedAt) {
+		d.add(DiagnosticStaleIndex,
+			"%s was last indexed at %s (%s), before its HEAD commit of %s: rebuild the index to search code committed since",
+			repo.Name, indexedAt.UTC().Format(time.RFC3339), source, head.UTC().Format(time.RFC3339))
+	}
+}
+
+// lastIndexed returns when the repository was last indexed and where that time comes from
+func (d *searchDiagnostics) lastIndexed(repoName string) (time.Time, string, bool) {
+	if d.rc.textSearch != nil {
+		if createdAt, err := d.rc.textSearch.IndexCreatedAt(repoName); err == nil {
+			return createdAt, "text index build", true
+		}
+	}
+	if d.rc.mysqlConn == nil {
+		return time.Time{}, "", false
+	}
+	fileVersionRepo, err := db.NewFileVersionRepository(d.rc.mysqlConn.GetDB(), repoName, d.rc.logger)
+	if err != nil {
+		d.skip("index_age", err)
+		return time.Time{}, "", false
+	}
+	last, ok, err := fileVersionRepo.LastIndexedAt()
+	if err != nil {
+		d.skip("index_age", err)
+		return time.Time{}, "", false
+	}
+	if !ok {
+		d.add(DiagnosticNotIndexed, "No file of %s has been indexed", repoName)
+		return time.Time{}, "", false
+	}
+	return last, "last file version recorded", true
+}
+
+// textMatches explains a text search that found nothing in the files passing its filters:
+// no file holds the literal text the pattern requires, or the matches differ in case only
+func (d *searchDiagnostics) textMatches(repoName string, query textsearch.Query, result *textsearch.SearchResult, passing int) {
+	if passing == 0 {
+		return
+	}
+	if result.FilesSearched == 0 {
+		d.add(DiagnosticNoCandidates, "None of the %d files searched contains the literal text the query requires", passing)
+	}
+	if !query.CaseSensitive {
+		return
+	}
+	query.CaseSensitive = false
+	query.MaxFiles = 1
+	folded, err := d.rc.textSearch.Search(d.ctx, repoName, query)
+	if err != nil {
+		d.skip("text_matches", err)
+		return
+	}
+	if len(folded.Files) > 0 {
+		d.add(DiagnosticCaseMismatch, "The query matches when case is ignored: retry with case_sensitive false")
+	}
+}
+
+// result returns the collected diagnostics
+func (d *searchDiagnostics) result() []model.SearchDiagnostic {
+	return d.diagnostics
+}
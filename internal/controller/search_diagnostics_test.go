@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"bot-go/internal/service/textsearch"
+
+	"go.uber.org/zap"
+)
+
+func TestTextSearchDiagnostics(t *testing.T) {
+	svc, err := textsearch.NewTextSearchServiceWithIndexDir(t.TempDir(), zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs := []textsearch.Document{
+		{FilePath: "/repo/cmd/main.go", RelativePath: "cmd/main.go", Language: "go", Content: []byte("func main() { RunServer() }")},
+		{FilePath: "/repo/internal/server.go", RelativePath: "internal/server.go", Language: "go", Content: []byte("func RunServer() {}")},
+		{FilePath: "/repo/scripts/deploy.py", RelativePath: "scripts/deploy.py", Language: "python", Content: []byte("def deploy(): pass")},
+	}
+	ctx := context.Background()
+	if _, err := svc.IndexRepository(ctx, "repo", docs); err != nil {
+		t.Fatal(err)
+	}
+	rc := &RepoController{textSearch: svc, logger: zap.NewNop()}
+
+	codes := func(d *searchDiagnostics) []string {
+		var codes []string
+		for _, diagnostic := range d.result() {
+			codes = append(codes, diagnostic.Code)
+		}
+		return codes
+	}
+	tests := []struct {
+		name       string
+		query      textsearch.Query
+		wantCodes  []string
+		wantPassed int
+	}{
+		{"language", textsearch.Query{Pattern: "deploy", Language: "java"}, []string{DiagnosticLanguageFiltered}, 0},
+		{"path", textsearch.Query{Pattern: "deploy", PathPrefix: "web/"}, []string{DiagnosticPathFiltered}, 0},
+		{"language under path", textsearch.Query{Pattern: "deploy", Language: "python", PathPrefix: "cmd/"}, []string{DiagnosticPathFiltered}, 0},
+		{"no candidates", textsearch.Query{Pattern: "Shutdown", Language: "go"}, []string{DiagnosticNoCandidates}, 2},
+		{"case", textsearch.Query{Pattern: "runserver", CaseSensitive: true}, []string{DiagnosticCaseMismatch}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := svc.Search(ctx, "repo", tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Files) != 0 {
+				t.Fatalf("query %+v found %d files", tt.query, len(result.Files))
+			}
+			d := rc.newSearchDiagnostics(ctx)
+			passing := d.textFilters("repo", tt.query.Language, tt.query.PathPrefix)
+			d.textMatches("repo", tt.query, result, len(passing))
+			if len(passing) != tt.wantPassed {
+				t.Errorf("%d files pass the filters, want %d", len(passing), tt.wantPassed)
+			}
+			got := codes(d)
+			if len(got) != len(tt.wantCodes) || (len(got) > 0 && got[0] != tt.wantCodes[0]) {
+				t.Errorf("diagnostics = %v, want %v", d.result(), tt.wantCodes)
+			}
+		})
+	}
+}
+
+func TestLanguageCounts(t *testing.T) {
+	counts := map[string]int{"go": 120, "python": 4, "": 4, "c": 1, "java": 2, "ruby": 1, "rust": 1}
+	want := "go (120), unknown (4), python (4), java (2), c (1), 2 more"
+	if got := languageCounts(counts); got != want {
+		t.Errorf("languageCounts = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"bot-go/internal/config"
+	"bot-go/internal/model"
+	"bot-go/internal/service/codegraph"
+
+	"go.uber.org/zap"
+)
+
+// recordsDatabase answers every read with the same records and keeps the last parameters
+type recordsDatabase struct {
+	codegraph.GraphDatabase
+	records []map[string]any
+	params  map[string]any
+	reads   int
+}
+
+func (d *recordsDatabase) ExecuteRead(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	d.params = params
+	d.reads++
+	return d.records, nil
+}
+
+func TestAddGraphProximity(t *testing.T) {
+	db := &recordsDatabase{records: []map[string]any{
+		{"index": int64(0), "distance": int64(3)},
+		{"index": int64(1), "distance": int64(4)},
+	}}
+	e := &searchExplainer{
+		seenNodes: []int64{42},
+		graph:     codegraph.NewCodeGraphWithDatabase(db, &config.Config{}, zap.NewNop()),
+		logger:    zap.NewNop(),
+	}
+	results := []model.SimilarCodeResult{
+		{
+			Chunk:       &model.CodeChunk{FileID: 7, StartLine: 12, ChunkType: model.ChunkTypeFunction},
+			Explanation: &model.SearchExplanation{ContextProximity: "none"},
+		},
+		{
+			Chunk:       &model.CodeChunk{FileID: 8, ChunkType: model.ChunkTypeFile},
+			Explanation: &model.SearchExplanation{ContextProximity: "same_file"},
+		},
+		{
+			Chunk:       &model.CodeChunk{FileID: 9, StartLine: 3, ChunkType: model.ChunkTypeFunction},
+			Explanation: &model.SearchExplanation{ContextProximity: "none"},
+		},
+	}
+
+	e.addGraphProximity(context.Background(), results)
+
+	if !reflect.DeepEqual(db.params["from"], []int64{42}) {
+		t.Errorf("distances measured from %v, want the session's nodes", db.params["from"])
+	}
+	targets, _ := db.params["targets"].([]map[string]any)
+	if len(targets) != 3 || targets[0]["startLine"] != int64(12) || targets[0]["wholeFile"] != false || targets[1]["wholeFile"] != true {
+		t.Errorf("targets = %v, want the function by its start line and the file chunk by its file", targets)
+	}
+
+	want := []struct {
+		proximity string
+		distance  int // -1 for none
+	}{{"graph", 3}, {"same_file", 4}, {"none", -1}}
+	for i, w := range want {
+		explanation := results[i].Explanation
+		distance := -1
+		if explanation.GraphDistance != nil {
+			distance = *explanation.GraphDistance
+		}
+		if explanation.ContextProximity != w.proximity || distance != w.distance {
+			t.Errorf("result %d: proximity %q at %d, want %q at %d", i, explanation.ContextProximity, distance, w.proximity, w.distance)
+		}
+	}
+}
+
+func TestAddGraphProximity_NoSessionNodes(t *testing.T) {
+	db := &recordsDatabase{}
+	e := &searchExplainer{
+		graph:  codegraph.NewCodeGraphWithDatabase(db, &config.Config{}, zap.NewNop()),
+		logger: zap.NewNop(),
+	}
+	results := []model.SimilarCodeResult{{
+		Chunk:       &model.CodeChunk{FileID: 7, ChunkType: model.ChunkTypeFunction},
+		Explanation: &model.SearchExplanation{ContextProximity: "none"},
+	}}
+
+	e.addGraphProximity(context.Background(), results)
+
+	if db.params != nil {
+		t.Error("queried the graph without session nodes")
+	}
+	if results[0].Explanation.GraphDistance != nil {
+		t.Error("graph distance set without session nodes")
+	}
+}
@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+
+	"bot-go/internal/logging"
+	"bot-go/internal/model/ast"
+	"bot-go/internal/service/session"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// SessionHeader carries the session ID on retrieval requests. Requests without it are not
+// recorded in any session.
+const SessionHeader = "X-Session-ID"
+
+// SessionController opens and closes agent sessions and returns their working sets
+type SessionController struct {
+	store  *session.Store
+	logger *zap.Logger
+}
+
+// NewSessionController creates a new SessionController
+func NewSessionController(store *session.Store, logger *zap.Logger) *SessionController {
+	return &SessionController{
+		store:  store,
+		logger: logger,
+	}
+}
+
+func (sc *SessionController) log(c *gin.Context) *zap.Logger {
+	return logging.FromContext(c.Request.Context(), sc.logger)
+}
+
+// Open starts a session; pass the returned ID in the X-Session-ID header of later requests
+func (sc *SessionController) Open(c *gin.Context) {
+	s := sc.store.Open()
+	sc.log(c).Info("Opened session", zap.String("session_id", s.ID()))
+	snapshot := s.Snapshot()
+	c.JSON(http.StatusOK, gin.H{
+		"session_id": snapshot.ID,
+		"expires_at": snapshot.ExpiresAt,
+	})
+}
+
+// Context returns every node and chunk returned so far in the session
+func (sc *SessionController) Context(c *gin.Context) {
+	s, err := sc.store.Get(c.Param("id"))
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, s.Snapshot())
+}
+
+// Close ends the session
+func (sc *SessionController) Close(c *gin.Context) {
+	if err := sc.store.Close(c.Param("id")); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"closed": true})
+}
+
+// requestSession returns the session named by the X-Session-ID header, nil if the header is
+// absent or no session store is configured, or a not found error for unknown sessions
+func requestSession(c *gin.Context, store *session.Store) (*session.Session, error) {
+	id := c.GetHeader(SessionHeader)
+	if id == "" || store == nil {
+		return nil, nil
+	}
+	return store.Get(id)
+}
+
+// nodeItem describes a graph node for a session working set
+func nodeItem(source string, id ast.NodeID, name, filePath string) session.Item {
+	return session.Item{
+		Kind:     session.KindNode,
+		ID:       fmt.Sprint(int64(id)),
+		Name:     name,
+		FilePath: filePath,
+		Source:   source,
+	}
+}
+
+// nodeKey identifies a graph node for session.Order
+func nodeKey(id ast.NodeID) (string, string) {
+	return session.KindNode, fmt.Sprint(int64(id))
+}
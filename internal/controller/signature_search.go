@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"net/http"
+
+	"bot-go/internal/service/codegraph"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FindBySignatureRequest describes the shape of the functions to find (see
+// codegraph.SignatureQuery). Type patterns ignore whitespace, "*" matches any run of
+// characters, and a pattern without a "." matches unqualified type names too.
+type FindBySignatureRequest struct {
+	Params    []string `json:"params"`     // positional parameter types; "..." last allows more parameters
+	Takes     []string `json:"takes"`      // parameter types in any position
+	Returns   []string `json:"returns"`    // positional result types; "..." last allows more results
+	Void      bool     `json:"void"`       // only functions declaring no results
+	MinParams int      `json:"min_params"` // default 0
+	MaxParams *int     `json:"max_params"` // default unbounded
+	Name      string   `json:"name"`       // name pattern, e.g. "Get*"
+	Limit     int      `json:"limit"`      // default 20
+}
+
+// FindFunctionsBySignature returns the functions of a repository whose declared parameter and
+// result types match the request, most referenced first, e.g. the functions taking a
+// context.Context and returning an error. Agents use it to find existing functions to call.
+func (rc *RepoController) FindFunctionsBySignature(c *gin.Context) {
+	var req FindBySignatureRequest
+	if err := bindRequest(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, invalidRequestBody(err))
+		return
+	}
+	repo, err := rc.config.GetRepository(c.Param("name"))
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	if rc.codeGraph == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "code graph is not enabled"})
+		return
+	}
+
+	query := req.query()
+	matches, err := rc.codeGraph.FindFunctionsBySignature(c.Request.Context(), repo.Name, query)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	noteResults(c, len(matches))
+	if matches == nil {
+		matches = []codegraph.SignatureMatch{}
+	}
+	c.JSON(http.StatusOK, gin.H{"repo_name": repo.Name, "functions": matches})
+}
+
+func (r *FindBySignatureRequest) query() codegraph.SignatureQuery {
+	query := codegraph.SignatureQuery{
+		Params:    r.Params,
+		Takes:     r.Takes,
+		Returns:   r.Returns,
+		Void:      r.Void,
+		MinParams: r.MinParams,
+		MaxParams: -1,
+		Name:      r.Name,
+		Limit:     r.Limit,
+	}
+	if r.MaxParams != nil {
+		query.MaxParams = *r.MaxParams
+	}
+	if query.Limit <= 0 {
+		query.Limit = 20
+	}
+	return query
+}
@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"bot-go/internal/codeapi"
+	"bot-go/internal/model/ast"
+	gitutil "bot-go/internal/signals/util"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ResolveStackTraceRequest is the request for symbolicating a stack trace
+type ResolveStackTraceRequest struct {
+	Text          string   `json:"text" binding:"required"` // Go, Python, Java or Node stack trace
+	Repos         []string `json:"repos"`                   // Repositories to search, empty = all
+	Commit        string   `json:"commit"`                  // Prefer file versions at this commit (prefix)
+	MaxCandidates int      `json:"max_candidates"`          // Alternative locations per frame
+}
+
+// ResolveStackTrace maps the frames of a stack trace to the functions containing their lines
+// in every indexed repository and file version. Each location carries its CODEOWNERS owners,
+// its last author per git blame and, for repositories with github_repo set, a source link.
+func (c *CodeAPIController) ResolveStackTrace(ctx *gin.Context) {
+	var req ResolveStackTraceRequest
+	if err := bindRequest(ctx, &req); err != nil {
+		ctx.JSON(http.StatusBadRequest, invalidRequestBody(err))
+		return
+	}
+
+	opts := codeapi.SymbolicateOptions{
+		Repos:         req.Repos,
+		Commit:        req.Commit,
+		MaxCandidates: req.MaxCandidates,
+	}
+	trace, err := c.api.Analyzer().SymbolicateStackTrace(ctx.Request.Context(), req.Text, opts)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.enrichFrameLocations(trace)
+	ctx.JSON(http.StatusOK, trace)
+}
+
+// enrichFrameLocations sets the CODEOWNERS owners and source links of the locations of a
+// trace from the configuration of their repositories
+func (c *CodeAPIController) enrichFrameLocations(trace *codeapi.SymbolicatedTrace) {
+	if c.config == nil {
+		return
+	}
+	owners := make(map[string]*gitutil.CodeOwners)
+	githubRepos := make(map[string]string)
+	enrich := func(loc *codeapi.FrameLocation, frameLine int) {
+		co, ok := owners[loc.RepoName]
+		if !ok {
+			if repo, err := c.config.GetRepository(loc.RepoName); err == nil {
+				githubRepos[loc.RepoName] = repo.GitHubRepo
+				if co, err = gitutil.LoadCodeOwners(repo.Path); err != nil {
+					c.logger.Warn("Failed to read CODEOWNERS", zap.String("repo_name", loc.RepoName), zap.Error(err))
+				}
+			}
+			owners[loc.RepoName] = co
+		}
+		loc.Owners = co.Owners(loc.FilePath)
+		// A function found by name in another version of the file is linked at its start
+		line := frameLine
+		if loc.FunctionID != ast.InvalidNodeID && !loc.ContainsLine {
+			line = loc.Range.Start.Line + 1
+		}
+		loc.SourceURL = githubSourceURL(githubRepos[loc.RepoName], loc.Commit, loc.FilePath, line)
+	}
+	for _, frame := range trace.Frames {
+		if frame.Resolved != nil {
+			enrich(frame.Resolved, frame.Line)
+		}
+		for _, loc := range frame.Candidates {
+			enrich(loc, frame.Line)
+		}
+	}
+}
+
+// githubSourceURL returns the GitHub link to a line of a file, at commit or else at the
+// default branch, or "" when the repository is not on GitHub
+func githubSourceURL(githubRepo, commit, path string, line int) string {
+	if githubRepo == "" || path == "" {
+		return ""
+	}
+	if commit == "" {
+		commit = "HEAD"
+	}
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	link := fmt.Sprintf("https://github.com/%s/blob/%s/%s", githubRepo, commit, strings.Join(segments, "/"))
+	if line > 0 {
+		link += fmt.Sprintf("#L%d", line)
+	}
+	return link
+}
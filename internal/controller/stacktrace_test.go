@@ -0,0 +1,20 @@
+package controller
+
+import "testing"
+
+func TestGitHubSourceURL(t *testing.T) {
+	tests := []struct {
+		repo, commit, path string
+		line               int
+		want               string
+	}{
+		{"acme/api", "abc123", "internal/app/server.go", 42, "https://github.com/acme/api/blob/abc123/internal/app/server.go#L42"},
+		{"acme/api", "", "docs/my notes.py", 0, "https://github.com/acme/api/blob/HEAD/docs/my%20notes.py"},
+		{"", "abc123", "main.go", 1, ""},
+	}
+	for _, tt := range tests {
+		if got := githubSourceURL(tt.repo, tt.commit, tt.path, tt.line); got != tt.want {
+			t.Errorf("githubSourceURL(%q, %q, %q, %d) = %q, want %q", tt.repo, tt.commit, tt.path, tt.line, got, tt.want)
+		}
+	}
+}
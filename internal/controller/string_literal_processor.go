@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"bot-go/internal/config"
+	"bot-go/internal/logging"
+	"bot-go/internal/model/ast"
+	"bot-go/internal/parse"
+	"bot-go/internal/service/codegraph"
+	"bot-go/internal/util"
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+// StringLiteralProcessor implements FileProcessor for the string literal index: it stores the
+// log messages, error strings and URLs of each file as StringLiteral nodes linked to the
+// Function containing them, so an error message can be traced to its source. It must run
+// after CodeGraphProcessor, which creates the Function nodes.
+type StringLiteralProcessor struct {
+	codeGraph    *codegraph.CodeGraph
+	parser       *parse.FileParser
+	minLength    int
+	logger       *zap.Logger
+	fileCount    atomic.Int64
+	literalCount atomic.Int64
+}
+
+// NewStringLiteralProcessor creates a new string literal processor. Literals shorter than
+// minLength characters are not indexed (0 = parse.DefaultStringLiteralMinLength).
+func NewStringLiteralProcessor(codeGraph *codegraph.CodeGraph, minLength int, logger *zap.Logger) *StringLiteralProcessor {
+	return &StringLiteralProcessor{
+		codeGraph: codeGraph,
+		parser:    parse.NewFileParser(logger, codeGraph, nil),
+		minLength: minLength,
+		logger:    logger,
+	}
+}
+
+// Name returns the processor name
+func (sp *StringLiteralProcessor) Name() string {
+	return "StringLiterals"
+}
+
+// ProcessFile extracts the significant string literals of a file and replaces those stored
+// for its path
+func (sp *StringLiteralProcessor) ProcessFile(ctx context.Context, repo *config.Repository, fileCtx *FileContext) error {
+	if fileCtx.LightweightReason != "" {
+		return nil
+	}
+
+	literals, err := sp.parser.ExtractStringLiterals(fileCtx.FilePath, fileCtx.Content, sp.minLength)
+	if err != nil {
+		return fmt.Errorf("failed to extract string literals of %s: %w", fileCtx.RelativePath, err)
+	}
+	if len(literals) > 0 {
+		functions, err := sp.codeGraph.FindNodesInFile(ctx, fileCtx.FileID, ast.NodeTypeFunction)
+		if err != nil {
+			return fmt.Errorf("failed to read functions of %s: %w", fileCtx.RelativePath, err)
+		}
+		assignFunctions(literals, functions)
+	}
+
+	// An empty list still clears the literals of an earlier version
+	path := util.CanonicalPath(fileCtx.RelativePath)
+	if err := sp.codeGraph.ReplaceStringLiterals(ctx, repo.Name, path, fileCtx.FileID, literals); err != nil {
+		return err
+	}
+	sp.fileCount.Add(1)
+	sp.literalCount.Add(int64(len(literals)))
+	return nil
+}
+
+// PostProcess has no repository-level work; it logs how many literals were indexed
+func (sp *StringLiteralProcessor) PostProcess(ctx context.Context, repo *config.Repository) error {
+	sp.log(ctx).Info("String literal indexing completed",
+		zap.String("repo_name", repo.Name),
+		zap.Int64("files", sp.fileCount.Swap(0)),
+		zap.Int64("literals", sp.literalCount.Swap(0)))
+	return nil
+}
+
+// assignFunctions sets the FunctionID of each literal to the innermost function containing
+// it, i.e. the containing function that starts last
+func assignFunctions(literals []codegraph.StringLiteral, functions []*ast.Node) {
+	for i := range literals {
+		var innermost *ast.Node
+		for _, fn := range functions {
+			if !fn.Range.ContainsRange(&literals[i].Range) {
+				continue
+			}
+			if innermost == nil || innermost.Range.ContainsRange(&fn.Range) {
+				innermost = fn
+			}
+		}
+		if innermost != nil {
+			literals[i].FunctionID = innermost.ID
+		}
+	}
+}
+
+// log returns the logger with the request ID carried by ctx attached
+func (sp *StringLiteralProcessor) log(ctx context.Context) *zap.Logger {
+	return logging.FromContext(ctx, sp.logger)
+}
@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"testing"
+
+	"bot-go/internal/model/ast"
+	"bot-go/internal/parse"
+	"bot-go/internal/service/codegraph"
+	"bot-go/pkg/lsp/base"
+
+	"go.uber.org/zap"
+)
+
+func TestStringLiteralExtraction(t *testing.T) {
+	src := `package p
+
+import "fmt"
+
+type T struct {
+	Name string ` + "`json:\"name\" yaml:\"the name\"`" + `
+}
+
+func load(path string) error {
+	logger.Info("loading config file", zap.String("path", path))
+	if path == "" {
+		return fmt.Errorf("config path is empty: %s", path)
+	}
+	logger.Error("could not reach the server")
+	url := "https://example.com/api/v1"
+	return errors.New("short")
+}
+`
+	fp := parse.NewFileParser(zap.NewNop(), nil, nil)
+	literals, err := fp.ExtractStringLiterals("load.go", []byte(src), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kinds := make(map[string]string)
+	for _, l := range literals {
+		kinds[l.Value] = l.Kind
+	}
+	want := map[string]string{
+		"loading config file":        codegraph.StringKindLog,
+		"config path is empty: %s":   codegraph.StringKindError,
+		"could not reach the server": codegraph.StringKindLog,
+		"https://example.com/api/v1": codegraph.StringKindURL,
+	}
+	for value, kind := range want {
+		if kinds[value] != kind {
+			t.Errorf("%q: kind = %q, want %q", value, kinds[value], kind)
+		}
+	}
+	// Import paths, struct tags, single words and short strings are not indexed
+	if len(literals) != len(want) {
+		t.Errorf("got %d literals, want %d: %v", len(literals), len(want), kinds)
+	}
+
+	fnRange := func(start, end int) base.Range {
+		return base.Range{Start: base.Position{Line: start}, End: base.Position{Line: end, Character: 1}}
+	}
+	functions := []*ast.Node{
+		{ID: 1, Range: fnRange(8, 16)},
+		{ID: 2, Range: fnRange(10, 12)}, // nested closure
+	}
+	assignFunctions(literals, functions)
+	for _, l := range literals {
+		wantID := ast.NodeID(1)
+		if l.Range.Start.Line == 11 {
+			wantID = 2
+		}
+		if l.FunctionID != wantID {
+			t.Errorf("%q at line %d: function = %d, want %d", l.Value, l.Range.Start.Line, l.FunctionID, wantID)
+		}
+	}
+}
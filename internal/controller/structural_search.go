@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"net/http"
+	"path/filepath"
+
+	"bot-go/internal/model"
+	"bot-go/internal/service/structural"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// StructuralSearchRequest searches a repository's stored file contents for code of a given
+// shape, with either a tree-sitter query or a comby-style pattern
+type StructuralSearchRequest struct {
+	Language   string `json:"language"`    // Required with query; restricts the files of a pattern
+	Query      string `json:"query"`       // Tree-sitter S-expression query, e.g. (call_expression) @call
+	Pattern    string `json:"pattern"`     // Comby-style template, e.g. "if err != nil { return :[x] }"
+	PathPrefix string `json:"path_prefix"` // Only files under this repository-relative path
+	Limit      int    `json:"limit"`       // Matches to return (default 100)
+}
+
+// StructuralSearchResponse lists the matches in path order
+type StructuralSearchResponse struct {
+	RepoName string `json:"repo_name"`
+	*structural.Result
+	// Diagnostics explain an empty result from the status of the text index
+	Diagnostics []model.SearchDiagnostic `json:"diagnostics,omitempty"`
+}
+
+// StructuralSearch runs a tree-sitter query or comby-style pattern over the file contents
+// stored by the TextSearch processor, searching files in parallel
+func (rc *RepoController) StructuralSearch(c *gin.Context) {
+	var request StructuralSearchRequest
+	if err := bindRequest(c, &request); err != nil {
+		rc.log(c).Error("Invalid request", zap.Error(err))
+		c.JSON(http.StatusBadRequest, invalidRequestBody(err))
+		return
+	}
+
+	repo, err := rc.config.GetRepository(c.Param("name"))
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	query := request.Query
+	if query == "" {
+		query = request.Pattern
+	}
+	noteQuery(c, repo.Name, query)
+
+	if rc.textSearch == nil {
+		serviceUnavailable(c, rc.unavailable, ServiceTextSearch, "Text search service not available")
+		return
+	}
+
+	docs, err := rc.textSearch.Documents(repo.Name)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"error":   "Failed to load stored file contents",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	result, err := structural.Search(c.Request.Context(), docs, structural.Query{
+		Language:        request.Language,
+		TreeSitterQuery: request.Query,
+		Pattern:         request.Pattern,
+		PathPrefix:      filepath.ToSlash(request.PathPrefix),
+		MaxMatches:      request.Limit,
+	})
+	if err != nil {
+		rc.log(c).Error("Failed to run structural search",
+			zap.String("repo_name", repo.Name),
+			zap.Error(err))
+		c.JSON(errorStatus(err), gin.H{
+			"error":   "Failed to run structural search",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	noteResults(c, len(result.Matches))
+
+	rc.log(c).Info("Structural search completed",
+		zap.String("repo_name", repo.Name),
+		zap.Bool("tree_sitter", request.Query != ""),
+		zap.Int("files_searched", result.FilesSearched),
+		zap.Int("matches", len(result.Matches)))
+
+	response := StructuralSearchResponse{
+		RepoName: repo.Name,
+		Result:   result,
+	}
+	if len(result.Matches) == 0 {
+		d := rc.newSearchDiagnostics(c)
+		d.textFilters(repo.Name, request.Language, filepath.ToSlash(request.PathPrefix))
+		d.indexAge(repo)
+		response.Diagnostics = d.result()
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,203 @@
+package controller
+
+import (
+	"net/http"
+	"path/filepath"
+
+	"bot-go/internal/model"
+	"bot-go/internal/service/textsearch"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// SearchTextRequest searches the contents of a repository's files for exact text or an RE2
+// regular expression, for when the identifier or string is known and semantic search would
+// only approximate it
+type SearchTextRequest struct {
+	RepoName          string `json:"repo_name" binding:"required"`
+	Query             string `json:"query" binding:"required"`
+	Regex             bool   `json:"regex"`          // Query is a regular expression rather than literal text
+	CaseSensitive     bool   `json:"case_sensitive"` // Default: case-insensitive
+	Language          string `json:"language"`
+	PathPrefix        string `json:"path_prefix"`          // Only files under this repository-relative path
+	Limit             int    `json:"limit"`                // Files to return (default 50)
+	MaxMatchesPerFile int    `json:"max_matches_per_file"` // Matching lines per file (default 20)
+}
+
+// SearchTextResponse lists the matching files, most matches first
+type SearchTextResponse struct {
+	RepoName string `json:"repo_name"`
+	Query    string `json:"query"`
+	*textsearch.SearchResult
+	// Diagnostics explain an empty result from the status of the text index
+	Diagnostics []model.SearchDiagnostic `json:"diagnostics,omitempty"`
+}
+
+// SetTextSearchService enables exact and regular expression search, and the exact_query of
+// similar code searches
+func (rc *RepoController) SetTextSearchService(textSearch *textsearch.TextSearchService) {
+	rc.textSearch = textSearch
+}
+
+// SearchText runs an exact or regular expression search over the trigram index built by the
+// TextSearch processor
+func (rc *RepoController) SearchText(c *gin.Context) {
+	var request SearchTextRequest
+	if err := bindRequest(c, &request); err != nil {
+		rc.log(c).Error("Invalid request", zap.Error(err))
+		c.JSON(http.StatusBadRequest, invalidRequestBody(err))
+		return
+	}
+	noteQuery(c, request.RepoName, request.Query)
+
+	if rc.textSearch == nil {
+		serviceUnavailable(c, rc.unavailable, ServiceTextSearch, "Text search service not available")
+		return
+	}
+
+	query := textsearch.Query{
+		Pattern:           request.Query,
+		Regex:             request.Regex,
+		CaseSensitive:     request.CaseSensitive,
+		Language:          request.Language,
+		PathPrefix:        filepath.ToSlash(request.PathPrefix),
+		MaxFiles:          request.Limit,
+		MaxMatchesPerFile: request.MaxMatchesPerFile,
+	}
+	result, err := rc.textSearch.Search(c.Request.Context(), request.RepoName, query)
+	if err != nil {
+		rc.log(c).Error("Failed to search text",
+			zap.String("repo_name", request.RepoName),
+			zap.Error(err))
+		c.JSON(errorStatus(err), gin.H{
+			"error":   "Failed to search text",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	noteResults(c, len(result.Files))
+
+	rc.log(c).Info("Text search completed",
+		zap.String("repo_name", request.RepoName),
+		zap.Bool("regex", request.Regex),
+		zap.Int("files_searched", result.FilesSearched),
+		zap.Int("files", len(result.Files)))
+
+	response := SearchTextResponse{
+		RepoName:     request.RepoName,
+		Query:        request.Query,
+		SearchResult: result,
+	}
+	if len(result.Files) == 0 {
+		d := rc.newSearchDiagnostics(c)
+		passing := d.textFilters(request.RepoName, query.Language, query.PathPrefix)
+		d.textMatches(request.RepoName, query, result, len(passing))
+		if repo, err := rc.config.GetRepository(request.RepoName); err == nil {
+			d.indexAge(repo)
+		}
+		response.Diagnostics = d.result()
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// withExactMatches merges the matches of a similar code search's exact_query into its results
+func (rc *RepoController) withExactMatches(c *gin.Context, request *model.SearchSimilarCodeRequest, results []model.SimilarCodeResult, limit int) []model.SimilarCodeResult {
+	files := rc.exactMatches(c, request, limit)
+	if len(files) == 0 {
+		return results
+	}
+	merged := mergeExactMatches(results, files)
+	if request.IncludeCode {
+		for i := range merged {
+			chunk := merged[i].Chunk
+			if merged[i].Code != "" || chunk.ID != "" {
+				continue
+			}
+			if code, err := rc.chunkService.ReadCodeFromFile(chunk.FilePath, chunk.StartLine, chunk.EndLine); err == nil {
+				merged[i].Code = code
+			}
+		}
+	}
+	return merged
+}
+
+// exactMatches runs the exact_query of a similar code search. Failures only lose the exact
+// matches, so they are logged rather than failing the search.
+func (rc *RepoController) exactMatches(c *gin.Context, request *model.SearchSimilarCodeRequest, limit int) []textsearch.FileMatch {
+	if request.ExactQuery == "" || rc.textSearch == nil {
+		return nil
+	}
+	result, err := rc.textSearch.Search(c.Request.Context(), request.RepoName, textsearch.Query{
+		Pattern:  request.ExactQuery,
+		Regex:    request.ExactRegex,
+		Language: request.Language,
+		MaxFiles: limit,
+	})
+	if err != nil {
+		rc.log(c).Warn("Exact search for similar code search failed",
+			zap.String("repo_name", request.RepoName),
+			zap.Error(err))
+		return nil
+	}
+	return result.Files
+}
+
+// mergeExactMatches merges exact matches into similar code results. Results containing an
+// exact match are annotated with the matching lines and ranked first, then one result for
+// each other file with exact matches, spanning its matching lines, then the remaining
+// results. Exact-only results carry no similarity score.
+func mergeExactMatches(results []model.SimilarCodeResult, files []textsearch.FileMatch) []model.SimilarCodeResult {
+	if len(files) == 0 {
+		return results
+	}
+	byPath := make(map[string]*textsearch.FileMatch, len(files))
+	for i := range files {
+		byPath[files[i].FilePath] = &files[i]
+	}
+
+	covered := make(map[string]bool) // files with an exact match inside a result
+	var exact, rest []model.SimilarCodeResult
+	for _, result := range results {
+		if file, ok := byPath[result.Chunk.FilePath]; ok {
+			for _, line := range file.Lines {
+				if line.Line >= result.Chunk.StartLine && line.Line <= result.Chunk.EndLine {
+					result.ExactMatchLines = append(result.ExactMatchLines, line.Line)
+				}
+			}
+		}
+		if len(result.ExactMatchLines) > 0 {
+			covered[result.Chunk.FilePath] = true
+			exact = append(exact, result)
+		} else {
+			rest = append(rest, result)
+		}
+	}
+
+	for _, file := range files {
+		if covered[file.FilePath] || len(file.Lines) == 0 {
+			continue
+		}
+		first, last := file.Lines[0], file.Lines[len(file.Lines)-1]
+		lines := make([]int, len(file.Lines))
+		for i, line := range file.Lines {
+			lines[i] = line.Line
+		}
+		exact = append(exact, model.SimilarCodeResult{
+			Chunk: &model.CodeChunk{
+				FileID:    file.FileID,
+				CommitSHA: file.CommitSHA,
+				ChunkType: model.ChunkTypeBlock,
+				Language:  file.Language,
+				FilePath:  file.FilePath,
+				Name:      filepath.Base(file.FilePath),
+				StartLine: first.Line,
+				EndLine:   last.Line,
+			},
+			QueryChunkIndex: -1,
+			ExactMatchLines: lines,
+		})
+	}
+	return append(exact, rest...)
+}
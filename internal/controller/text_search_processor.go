@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"bot-go/internal/config"
+	"bot-go/internal/logging"
+	"bot-go/internal/service/textsearch"
+	"bot-go/internal/util"
+	"context"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// TextSearchProcessor implements FileProcessor for the trigram index of exact and regular
+// expression search
+type TextSearchProcessor struct {
+	textSearch *textsearch.TextSearchService
+	logger     *zap.Logger
+
+	// Files seen since the last build, by repository and path, indexed in PostProcess
+	docs   map[string]map[string]textsearch.Document
+	docsMu sync.Mutex
+}
+
+// NewTextSearchProcessor creates a new text search processor
+func NewTextSearchProcessor(textSearch *textsearch.TextSearchService, logger *zap.Logger) *TextSearchProcessor {
+	return &TextSearchProcessor{
+		textSearch: textSearch,
+		logger:     logger,
+		docs:       make(map[string]map[string]textsearch.Document),
+	}
+}
+
+// Name returns the processor name
+func (tp *TextSearchProcessor) Name() string {
+	return "TextSearch"
+}
+
+// ProcessFile records the file's content for the index
+func (tp *TextSearchProcessor) ProcessFile(ctx context.Context, repo *config.Repository, fileCtx *FileContext) error {
+	// Generated and minified files are searchable; only oversized ones are left out
+	switch fileCtx.LightweightReason {
+	case util.LightweightTooLarge, util.LightweightTooManyLines:
+		return nil
+	}
+
+	tp.docsMu.Lock()
+	if tp.docs[repo.Name] == nil {
+		tp.docs[repo.Name] = make(map[string]textsearch.Document)
+	}
+	tp.docs[repo.Name][fileCtx.FilePath] = textsearch.Document{
+		FilePath:     fileCtx.FilePath,
+		RelativePath: fileCtx.RelativePath,
+		FileID:       fileCtx.FileID,
+		CommitSHA:    fileCtx.CommitSHA(),
+		Language:     repo.Language,
+		Content:      fileCtx.Content,
+	}
+	tp.docsMu.Unlock()
+	return nil
+}
+
+// PostProcess builds and saves the repository's trigram index
+func (tp *TextSearchProcessor) PostProcess(ctx context.Context, repo *config.Repository) error {
+	tp.docsMu.Lock()
+	docs := make([]textsearch.Document, 0, len(tp.docs[repo.Name]))
+	for _, doc := range tp.docs[repo.Name] {
+		docs = append(docs, doc)
+	}
+	delete(tp.docs, repo.Name)
+	tp.docsMu.Unlock()
+
+	if _, err := tp.textSearch.IndexRepository(ctx, repo.Name, docs); err != nil {
+		tp.log(ctx).Error("Failed to build text index",
+			zap.String("repo_name", repo.Name),
+			zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// log returns the logger with the request ID carried by ctx attached
+func (tp *TextSearchProcessor) log(ctx context.Context) *zap.Logger {
+	return logging.FromContext(ctx, tp.logger)
+}
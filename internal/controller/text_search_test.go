@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	"bot-go/internal/model"
+	"bot-go/internal/service/textsearch"
+)
+
+func TestMergeExactMatches(t *testing.T) {
+	results := []model.SimilarCodeResult{
+		{Chunk: &model.CodeChunk{ID: "a", FilePath: "/repo/a.go", StartLine: 0, EndLine: 10}, Score: 0.9},
+		{Chunk: &model.CodeChunk{ID: "b", FilePath: "/repo/b.go", StartLine: 20, EndLine: 30}, Score: 0.8},
+	}
+	files := []textsearch.FileMatch{
+		{FilePath: "/repo/b.go", MatchCount: 1, Lines: []textsearch.LineMatch{{Line: 25}}},
+		{FilePath: "/repo/c.go", FileID: 7, MatchCount: 2, Lines: []textsearch.LineMatch{{Line: 3}, {Line: 8}}},
+	}
+
+	merged := mergeExactMatches(results, files)
+	var order []string
+	for _, r := range merged {
+		order = append(order, r.Chunk.FilePath)
+	}
+	if want := []string{"/repo/b.go", "/repo/c.go", "/repo/a.go"}; !reflect.DeepEqual(order, want) {
+		t.Fatalf("merged order = %v, want %v", order, want)
+	}
+	if !reflect.DeepEqual(merged[0].ExactMatchLines, []int{25}) || merged[0].Score != 0.8 {
+		t.Errorf("vector result with an exact match = %+v", merged[0])
+	}
+	exactOnly := merged[1]
+	if exactOnly.Chunk.FileID != 7 || exactOnly.Chunk.StartLine != 3 || exactOnly.Chunk.EndLine != 8 || exactOnly.QueryChunkIndex != -1 {
+		t.Errorf("exact-only result = %+v, chunk %+v", exactOnly, exactOnly.Chunk)
+	}
+	if merged[2].ExactMatchLines != nil {
+		t.Errorf("result without exact matches got lines %v", merged[2].ExactMatchLines)
+	}
+}
@@ -0,0 +1,460 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+
+	"bot-go/internal/apperrors"
+	"bot-go/internal/model"
+	"bot-go/internal/service/codegraph"
+	"bot-go/internal/service/session"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// Bounds applied to request fields before they reach the service layer
+const (
+	maxTraversalDepth = 10   // call graph, data flow and impact traversals
+	maxResultLimit    = 1000 // limit on list/search endpoints
+	maxCallGraphEdges = 10000
+	maxNGramSize      = 10
+	maxContextLines   = 200 // context lines around node source
+)
+
+// supportedLanguages are the language names accepted by endpoints that take a "language" field
+var supportedLanguages = map[string]bool{
+	"go":         true,
+	"python":     true,
+	"java":       true,
+	"javascript": true,
+	"typescript": true,
+	"rust":       true,
+}
+
+// chunkTypeNames are the chunk types accepted by the purge endpoint
+var chunkTypeNames = []string{
+	string(model.ChunkTypeFile),
+	string(model.ChunkTypeClass),
+	string(model.ChunkTypeFunction),
+	string(model.ChunkTypeBlock),
+	string(model.ChunkTypeConditional),
+	string(model.ChunkTypeLoop),
+}
+
+// FieldError describes why a single request field was rejected
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every invalid field of a request. It wraps apperrors.ErrInvalidArgument.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		parts[i] = f.Field + ": " + f.Message
+	}
+	return "invalid request: " + strings.Join(parts, "; ")
+}
+
+func (e *ValidationError) Unwrap() error {
+	return apperrors.ErrInvalidArgument
+}
+
+// requestValidator collects field errors for one request
+type requestValidator struct {
+	fields []FieldError
+}
+
+func (v *requestValidator) fail(field, format string, args ...any) {
+	v.fields = append(v.fields, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// relativePath rejects absolute paths and paths that escape the repository root. Empty
+// values are accepted; use binding:"required" for mandatory paths.
+func (v *requestValidator) relativePath(field, p string) {
+	if p == "" {
+		return
+	}
+	if strings.ContainsRune(p, 0) {
+		v.fail(field, "must not contain NUL bytes")
+		return
+	}
+	slashed := strings.ReplaceAll(p, `\`, "/")
+	if path.IsAbs(slashed) || (len(slashed) >= 2 && slashed[1] == ':') {
+		v.fail(field, "must be relative to the repository root")
+		return
+	}
+	if cleaned := path.Clean(slashed); cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		v.fail(field, "must not escape the repository root")
+	}
+}
+
+func (v *requestValidator) language(field, lang string) {
+	if !supportedLanguages[lang] {
+		v.fail(field, "unsupported language %q, supported: %s", lang, strings.Join(supportedLanguageNames(), ", "))
+	}
+}
+
+// bounded checks value against [0, max]; zero means "use the default"
+func (v *requestValidator) bounded(field string, value, max int) {
+	if value < 0 || value > max {
+		v.fail(field, "must be between 0 and %d, got %d", max, value)
+	}
+}
+
+func (v *requestValidator) nonNegative(field string, value int) {
+	if value < 0 {
+		v.fail(field, "must not be negative, got %d", value)
+	}
+}
+
+func (v *requestValidator) nonNegativeFloat(field string, value float64) {
+	if value < 0 {
+		v.fail(field, "must not be negative, got %g", value)
+	}
+}
+
+// impactScoring checks the scoring overrides of an impact request; nil is valid
+func (v *requestValidator) impactScoring(r *ImpactScoringRequest) {
+	if r == nil {
+		return
+	}
+	scoring := r.scoring()
+	if scoring.DepthDecay <= 0 || scoring.DepthDecay > 1 {
+		v.fail("scoring.depth_decay", "must be in (0, 1], got %g", scoring.DepthDecay)
+	}
+	v.nonNegativeFloat("scoring.call_graph_weight", scoring.CallGraphWeight)
+	v.nonNegativeFloat("scoring.data_flow_weight", scoring.DataFlowWeight)
+	v.nonNegativeFloat("scoring.fan_in_weight", scoring.FanInWeight)
+	v.nonNegativeFloat("scoring.medium_risk_threshold", scoring.MediumRiskThreshold)
+	if scoring.HighRiskThreshold < scoring.MediumRiskThreshold {
+		v.fail("scoring.high_risk_threshold", "must not be below medium_risk_threshold (%g), got %g", scoring.MediumRiskThreshold, scoring.HighRiskThreshold)
+	}
+}
+
+func (v *requestValidator) oneOf(field, value string, allowed ...string) {
+	if value == "" {
+		return
+	}
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.fail(field, "must be one of %s, got %q", strings.Join(allowed, ", "), value)
+}
+
+// signaturePatterns checks a positional list of type patterns, which may only end with "..."
+func (v *requestValidator) signaturePatterns(field string, patterns []string) {
+	for i, p := range patterns {
+		if p == codegraph.SignatureRest && i != len(patterns)-1 {
+			v.fail(fmt.Sprintf("%s[%d]", field, i), "%q is only allowed last", codegraph.SignatureRest)
+		} else if strings.TrimSpace(p) == "" {
+			v.fail(fmt.Sprintf("%s[%d]", field, i), "must not be empty")
+		}
+	}
+}
+
+func (v *requestValidator) err() error {
+	if len(v.fields) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: v.fields}
+}
+
+func supportedLanguageNames() []string {
+	names := make([]string, 0, len(supportedLanguages))
+	for name := range supportedLanguages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validateRequest applies the field checks for a bound request. Request types without
+// extra constraints pass unchanged.
+func validateRequest(req any) error {
+	v := &requestValidator{}
+	switch r := req.(type) {
+	case *ListFilesRequest:
+		v.bounded("limit", r.Limit, maxResultLimit)
+		v.nonNegative("offset", r.Offset)
+	case *ListClassesRequest:
+		v.bounded("limit", r.Limit, maxResultLimit)
+		v.nonNegative("offset", r.Offset)
+	case *ListMethodsRequest:
+		v.bounded("limit", r.Limit, maxResultLimit)
+		v.nonNegative("offset", r.Offset)
+	case *FindClassesRequest:
+		v.relativePath("file_path", r.FilePath)
+		v.bounded("limit", r.Limit, maxResultLimit)
+		v.nonNegative("offset", r.Offset)
+	case *FindMethodsRequest:
+		v.relativePath("file_path", r.FilePath)
+		v.bounded("limit", r.Limit, maxResultLimit)
+		v.nonNegative("offset", r.Offset)
+	case *SearchSymbolsRequest:
+		v.bounded("limit", r.Limit, maxResultLimit)
+		v.oneOf("seen_mode", r.SeenMode, session.SeenExclude, session.SeenDeprioritize)
+	case *FindConstantsRequest:
+		v.bounded("limit", r.Limit, maxResultLimit)
+	case *SearchStringsRequest:
+		v.bounded("limit", r.Limit, maxResultLimit)
+		v.oneOf("kind", r.Kind, codegraph.StringKindError, codegraph.StringKindLog, codegraph.StringKindURL, codegraph.StringKindText)
+	case *NodeAtPositionRequest:
+		v.relativePath("file_path", r.FilePath)
+		v.nonNegative("line", r.Line)
+		v.nonNegative("character", r.Character)
+	case *GetCallGraphRequest:
+		v.relativePath("file_path", r.FilePath)
+		v.oneOf("direction", r.Direction, "outgoing", "incoming", "both")
+		v.bounded("max_depth", r.MaxDepth, maxTraversalDepth)
+		v.bounded("max_nodes", r.MaxNodes, maxResultLimit)
+		v.bounded("max_edges", r.MaxEdges, maxCallGraphEdges)
+		v.bounded("max_tokens", r.MaxTokens, maxBudgetTokens)
+	case *GetDataDependentsRequest:
+		v.relativePath("file_path", r.FilePath)
+		v.bounded("max_depth", r.MaxDepth, maxTraversalDepth)
+		v.bounded("max_tokens", r.MaxTokens, maxBudgetTokens)
+	case *GetImpactRequest:
+		v.relativePath("file_path", r.FilePath)
+		v.oneOf("node_type", r.NodeType, "function", "class", "field", "variable")
+		v.bounded("max_depth", r.MaxDepth, maxTraversalDepth)
+		v.nonNegative("modified_since_days", r.ModifiedSinceDays)
+		v.impactScoring(r.Scoring)
+		v.bounded("max_tokens", r.MaxTokens, maxBudgetTokens)
+	case *ImpactNotifyRequest:
+		if len(r.ChangedFiles) == 0 {
+			v.fail("changed_files", "at least one file path is required")
+		}
+		for i, p := range r.ChangedFiles {
+			field := fmt.Sprintf("changed_files[%d]", i)
+			if p == "" {
+				v.fail(field, "must not be empty")
+				continue
+			}
+			v.relativePath(field, p)
+		}
+		v.bounded("max_depth", r.MaxDepth, maxTraversalDepth)
+		v.impactScoring(r.Scoring)
+	case *GetNodeCommitsRequest:
+		v.bounded("limit", r.Limit, maxResultLimit)
+	case *ResolveErrorLocationRequest:
+		v.bounded("limit", r.Limit, maxResultLimit)
+	case *BuildIndexFromArchiveRequest:
+		v.relativePath("archive_path", r.ArchivePath)
+		if len(r.Version) > maxVersionLength {
+			v.fail("version", "must be at most %d characters, got %d", maxVersionLength, len(r.Version))
+		}
+		if r.Language != "" {
+			v.language("language", r.Language)
+		}
+	case *ResolveStackTraceRequest:
+		v.bounded("max_candidates", r.MaxCandidates, maxResultLimit)
+	case *GetModuleSummaryRequest:
+		v.relativePath("path", r.Path)
+		v.bounded("max_tokens", r.MaxTokens, maxBudgetTokens)
+	case *FindDuplicatesRequest:
+		v.nonNegative("min_size", r.MinSize)
+		v.nonNegative("min_count", r.MinCount)
+		v.bounded("limit", r.Limit, maxResultLimit)
+	case *SearchTextRequest:
+		if r.Language != "" {
+			v.language("language", r.Language)
+		}
+		v.relativePath("path_prefix", r.PathPrefix)
+		v.bounded("limit", r.Limit, maxResultLimit)
+		v.bounded("max_matches_per_file", r.MaxMatchesPerFile, maxResultLimit)
+	case *StructuralSearchRequest:
+		if (r.Query == "") == (r.Pattern == "") {
+			v.fail("query", "exactly one of query and pattern is required")
+		}
+		if r.Language != "" {
+			v.language("language", r.Language)
+		} else if r.Query != "" {
+			v.fail("language", "is required with a tree-sitter query")
+		}
+		v.relativePath("path_prefix", r.PathPrefix)
+		v.bounded("limit", r.Limit, maxResultLimit)
+	case *NodeBatchRequest:
+		if len(r.NodeIDs) == 0 {
+			v.fail("node_ids", "at least one node ID is required")
+		} else if len(r.NodeIDs) > maxResultLimit {
+			v.fail("node_ids", "must list at most %d IDs, got %d", maxResultLimit, len(r.NodeIDs))
+		}
+	case *StructurallySimilarRequest:
+		v.bounded("limit", r.Limit, maxResultLimit)
+	case *SearchCodeCardsRequest:
+		v.bounded("limit", r.Limit, maxResultLimit)
+	case *FindBySignatureRequest:
+		v.bounded("limit", r.Limit, codegraph.MaxSignatureMatches)
+		v.nonNegative("min_params", r.MinParams)
+		if r.MaxParams != nil {
+			v.nonNegative("max_params", *r.MaxParams)
+			if *r.MaxParams < r.MinParams {
+				v.fail("max_params", "must not be less than min_params")
+			}
+		}
+		v.signaturePatterns("params", r.Params)
+		v.signaturePatterns("returns", r.Returns)
+		if r.Void && len(r.Returns) > 0 {
+			v.fail("void", "cannot be combined with returns")
+		}
+	case *ReviewRequest:
+		v.bounded("max_callers", r.MaxCallers, maxResultLimit)
+		v.bounded("similar_limit", r.SimilarLimit, maxResultLimit)
+	case *CodegenContextRequest:
+		v.relativePath("file_path", r.FilePath)
+		v.bounded("sibling_limit", r.SiblingLimit, codegraph.MaxSignatureMatches)
+		v.bounded("similar_limit", r.SimilarLimit, maxResultLimit)
+	case *FindEquivalentsRequest:
+		v.bounded("limit", r.Limit, maxResultLimit)
+		if w := r.SignatureWeight; w != nil && (*w < 0 || *w > 1) {
+			v.fail("signature_weight", "must be between 0 and 1, got %g", *w)
+		}
+	case *CreateRelationsRequest:
+		if len(r.Relations) == 0 {
+			v.fail("relations", "at least one relation is required")
+		} else if len(r.Relations) > maxEnrichedRelations {
+			v.fail("relations", "must list at most %d relations, got %d", maxEnrichedRelations, len(r.Relations))
+		}
+		for i, rel := range r.Relations {
+			if rel.From <= 0 {
+				v.fail(fmt.Sprintf("relations[%d].from", i), "must be a node ID")
+			}
+			if rel.To <= 0 {
+				v.fail(fmt.Sprintf("relations[%d].to", i), "must be a node ID")
+			}
+			if rel.Type == "" {
+				v.fail(fmt.Sprintf("relations[%d].type", i), "is required")
+			}
+		}
+	case *IndexFileRequest:
+		if len(r.RelativePaths) == 0 {
+			v.fail("relative_paths", "at least one file path is required")
+		}
+		for i, p := range r.RelativePaths {
+			field := fmt.Sprintf("relative_paths[%d]", i)
+			if p == "" {
+				v.fail(field, "must not be empty")
+				continue
+			}
+			v.relativePath(field, p)
+		}
+	case *model.GetFunctionsInFileRequest:
+		v.relativePath("relative_path", r.RelativePath)
+	case *model.GetFunctionDetailsRequest:
+		v.relativePath("relative_path", r.RelativePath)
+	case *model.GetFunctionDependenciesRequest:
+		v.relativePath("relative_path", r.RelativePath)
+		v.bounded("depth", r.Depth, maxTraversalDepth)
+	case *model.SearchSimilarCodeRequest:
+		v.language("language", r.Language)
+		v.bounded("limit", r.Limit, maxResultLimit)
+		v.oneOf("seen_mode", r.SeenMode, session.SeenExclude, session.SeenDeprioritize)
+		if r.ExactRegex && r.ExactQuery == "" {
+			v.fail("exact_regex", "needs exact_query")
+		}
+	case *model.ProcessDirectoryRequest:
+		v.nonNegative("ttl_hours", r.TTLHours)
+	case *model.PurgeChunksRequest:
+		if r.Language != "" {
+			v.language("language", r.Language)
+		}
+		for i, chunkType := range r.ChunkTypes {
+			v.oneOf(fmt.Sprintf("chunk_types[%d]", i), chunkType, chunkTypeNames...)
+		}
+		if r.PathPrefix == "" && r.Language == "" && len(r.ChunkTypes) == 0 && r.IndexedBefore == nil && !r.Expired {
+			v.fail("filters", "at least one of path_prefix, language, chunk_types, indexed_before or expired is required")
+		}
+	case *model.ProcessNGramRequest:
+		v.bounded("n", r.N, maxNGramSize)
+	case *model.GetFileEntropyRequest:
+		v.relativePath("file_path", r.FilePath)
+	case *model.AnalyzeCodeRequest:
+		if r.Code == "" && len(r.Files) == 0 {
+			v.fail("code", "code or files is required")
+		}
+		if r.Code != "" || r.Language != "" {
+			v.language("language", r.Language)
+		}
+		v.relativePath("file_path", r.FilePath)
+		for i, file := range r.Files {
+			field := fmt.Sprintf("files[%d]", i)
+			if file.FilePath == "" {
+				v.fail(field+".file_path", "is required")
+			}
+			v.relativePath(field+".file_path", file.FilePath)
+			if file.Language != "" {
+				v.language(field+".language", file.Language)
+			}
+		}
+		v.oneOf("format", r.Format, "json", "sarif")
+		if r.Format == "sarif" && r.Code != "" && r.FilePath == "" {
+			v.fail("file_path", "is required with code for the sarif format")
+		}
+	case *model.CalculateZScoreRequest:
+		v.language("language", r.Language)
+	}
+	return v.err()
+}
+
+// bindRequest decodes the JSON body into req and validates it. Binding tag failures (e.g.
+// a missing required field) and validateRequest failures are both reported as a
+// *ValidationError; malformed JSON is returned as is.
+func bindRequest(c *gin.Context, req any) error {
+	if err := c.ShouldBindJSON(req); err != nil {
+		var tagErrs validator.ValidationErrors
+		if errors.As(err, &tagErrs) {
+			return bindingValidationError(req, tagErrs)
+		}
+		return fmt.Errorf("%w: %v", apperrors.ErrInvalidArgument, err)
+	}
+	return validateRequest(req)
+}
+
+// bindingValidationError converts binding tag failures into field errors keyed by JSON name
+func bindingValidationError(req any, tagErrs validator.ValidationErrors) *ValidationError {
+	t := reflect.TypeOf(req)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	v := &requestValidator{}
+	for _, fe := range tagErrs {
+		name := fe.Field()
+		if sf, ok := t.FieldByName(fe.StructField()); ok {
+			if tag := strings.Split(sf.Tag.Get("json"), ",")[0]; tag != "" && tag != "-" {
+				name = tag
+			}
+		}
+		if fe.Tag() == "required" {
+			v.fail(name, "is required")
+		} else {
+			v.fail(name, "failed %q validation", fe.Tag())
+		}
+	}
+	return &ValidationError{Fields: v.fields}
+}
+
+// invalidRequestBody builds the 400 response body for a bindRequest error, listing each
+// invalid field when available
+func invalidRequestBody(err error) gin.H {
+	body := gin.H{
+		"error":   "Invalid request payload",
+		"details": err.Error(),
+	}
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		body["fields"] = verr.Fields
+	}
+	return body
+}
@@ -0,0 +1,201 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"bot-go/internal/apperrors"
+	"bot-go/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+func fieldNames(err error) []string {
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		return nil
+	}
+	names := make([]string, len(verr.Fields))
+	for i, f := range verr.Fields {
+		names[i] = f.Field
+	}
+	return names
+}
+
+func TestValidateRequestRelativePaths(t *testing.T) {
+	cases := map[string]bool{
+		"src/main.go":           true,
+		"./src/../main.go":      true,
+		"../outside.go":         false,
+		"src/../../outside.go":  false,
+		"/etc/passwd":           false,
+		`..\windows\system.ini`: false,
+		`C:\repo\main.go`:       false,
+	}
+	for p, ok := range cases {
+		err := validateRequest(&IndexFileRequest{RepoName: "r", RelativePaths: []string{p}})
+		if (err == nil) != ok {
+			t.Errorf("path %q: got err=%v, want ok=%v", p, err, ok)
+		}
+	}
+}
+
+func TestValidateRequestCollectsAllFields(t *testing.T) {
+	err := validateRequest(&GetCallGraphRequest{
+		RepoName:  "r",
+		FilePath:  "../x.go",
+		Direction: "sideways",
+		MaxDepth:  100000,
+	})
+	if !errors.Is(err, apperrors.ErrInvalidArgument) {
+		t.Fatalf("expected ErrInvalidArgument, got %v", err)
+	}
+	got := strings.Join(fieldNames(err), ",")
+	if got != "file_path,direction,max_depth" {
+		t.Errorf("unexpected fields %q", got)
+	}
+
+	if err := validateRequest(&model.AnalyzeCodeRequest{Language: "cobol"}); err == nil {
+		t.Error("expected unsupported language to be rejected")
+	}
+	if err := validateRequest(&GetCallGraphRequest{RepoName: "r", MaxDepth: 3}); err != nil {
+		t.Errorf("expected valid request, got %v", err)
+	}
+}
+
+func TestValidatePurgeChunksRequest(t *testing.T) {
+	err := validateRequest(&model.PurgeChunksRequest{CollectionName: "c"})
+	if got := strings.Join(fieldNames(err), ","); got != "filters" {
+		t.Errorf("expected an unfiltered purge to be rejected, got %q (%v)", got, err)
+	}
+
+	err = validateRequest(&model.PurgeChunksRequest{CollectionName: "c", ChunkTypes: []string{"function", "method"}})
+	if got := strings.Join(fieldNames(err), ","); got != "chunk_types[1]" {
+		t.Errorf("expected unknown chunk type to be rejected, got %q (%v)", got, err)
+	}
+
+	if err := validateRequest(&model.PurgeChunksRequest{CollectionName: "c", Expired: true}); err != nil {
+		t.Errorf("expected valid request, got %v", err)
+	}
+}
+
+func TestValidateAnalyzeCodeRequest(t *testing.T) {
+	err := validateRequest(&model.AnalyzeCodeRequest{RepoName: "r"})
+	if got := strings.Join(fieldNames(err), ","); got != "code" {
+		t.Errorf("expected a request without code or files to be rejected, got %q (%v)", got, err)
+	}
+
+	err = validateRequest(&model.AnalyzeCodeRequest{
+		RepoName: "r",
+		Language: "go",
+		Code:     "x := 1",
+		Files:    []model.AnalyzeCodeFile{{Code: "y"}, {FilePath: "../a.go"}, {FilePath: "a.rb", Language: "ruby"}},
+		Format:   "sarif",
+	})
+	if got := strings.Join(fieldNames(err), ","); got != "files[0].file_path,files[1].file_path,files[2].language,file_path" {
+		t.Errorf("unexpected fields %q (%v)", got, err)
+	}
+
+	err = validateRequest(&model.AnalyzeCodeRequest{
+		RepoName: "r",
+		Files:    []model.AnalyzeCodeFile{{FilePath: "main.go", Code: "package main"}},
+		Format:   "sarif",
+	})
+	if err != nil {
+		t.Errorf("expected valid request, got %v", err)
+	}
+}
+
+func TestValidateImpactScoring(t *testing.T) {
+	decay, high := 0.0, 1.0
+	err := validateRequest(&GetImpactRequest{
+		RepoName: "r",
+		Scoring:  &ImpactScoringRequest{DepthDecay: &decay, HighRiskThreshold: &high},
+	})
+	if got := strings.Join(fieldNames(err), ","); got != "scoring.depth_decay,scoring.high_risk_threshold" {
+		t.Errorf("unexpected fields %q (%v)", got, err)
+	}
+
+	weight := 0.0
+	if err := validateRequest(&GetImpactRequest{RepoName: "r", Scoring: &ImpactScoringRequest{FanInWeight: &weight}}); err != nil {
+		t.Errorf("expected a zero fan-in weight to be accepted, got %v", err)
+	}
+}
+
+func TestValidateNodeBatchRequest(t *testing.T) {
+	if got := strings.Join(fieldNames(validateRequest(&NodeBatchRequest{NodeIDs: []int64{}})), ","); got != "node_ids" {
+		t.Errorf("expected an empty batch to be rejected, got %q", got)
+	}
+	if got := strings.Join(fieldNames(validateRequest(&NodeBatchRequest{NodeIDs: make([]int64, maxResultLimit+1)})), ","); got != "node_ids" {
+		t.Errorf("expected an oversized batch to be rejected, got %q", got)
+	}
+	if err := validateRequest(&NodeBatchRequest{NodeIDs: []int64{1, 2}, IncludeSource: true}); err != nil {
+		t.Errorf("expected valid request, got %v", err)
+	}
+}
+
+func TestValidateCreateRelationsRequest(t *testing.T) {
+	if got := strings.Join(fieldNames(validateRequest(&CreateRelationsRequest{Source: "tracer"})), ","); got != "relations" {
+		t.Errorf("expected an empty report to be rejected, got %q", got)
+	}
+	req := &CreateRelationsRequest{Source: "tracer", Relations: []EnrichedRelationSpec{
+		{From: 1, To: 2, Type: "RUNTIME_CALLS"},
+		{From: 0, To: 2},
+	}}
+	if got := strings.Join(fieldNames(validateRequest(req)), ","); got != "relations[1].from,relations[1].type" {
+		t.Errorf("got invalid fields %q", got)
+	}
+	req.Relations = req.Relations[:1]
+	if err := validateRequest(req); err != nil {
+		t.Errorf("expected valid request, got %v", err)
+	}
+}
+
+func TestBindRequestReportsRequiredFields(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"limit": 5}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	var req ListFilesRequest
+	err := bindRequest(c, &req)
+	if got := strings.Join(fieldNames(err), ","); got != "repo_name" {
+		t.Errorf("expected repo_name field error, got %q (%v)", got, err)
+	}
+	if errorStatus(err) != http.StatusBadRequest {
+		t.Errorf("expected 400 status, got %d", errorStatus(err))
+	}
+}
+
+func TestValidateFindBySignatureRequest(t *testing.T) {
+	maxParams := 1
+	req := &FindBySignatureRequest{
+		Params:    []string{"...", "context.Context"},
+		Returns:   []string{"error"},
+		Void:      true,
+		MinParams: 2,
+		MaxParams: &maxParams,
+	}
+	if got := strings.Join(fieldNames(validateRequest(req)), ","); got != "max_params,params[0],void" {
+		t.Errorf("got invalid fields %q", got)
+	}
+	req = &FindBySignatureRequest{Params: []string{"context.Context", "..."}, Returns: []string{"error"}}
+	if err := validateRequest(req); err != nil {
+		t.Errorf("expected a valid request, got %v", err)
+	}
+}
+
+func TestValidateCodegenContextRequest(t *testing.T) {
+	req := &CodegenContextRequest{FilePath: "../outside.go", Description: "x", SimilarLimit: -1}
+	if got := strings.Join(fieldNames(validateRequest(req)), ","); got != "file_path,similar_limit" {
+		t.Errorf("got invalid fields %q", got)
+	}
+	req = &CodegenContextRequest{FilePath: "internal/new.go", Description: "retry failed deliveries"}
+	if err := validateRequest(req); err != nil {
+		t.Errorf("expected a valid request, got %v", err)
+	}
+}
@@ -322,6 +322,44 @@ func (r *FileVersionRepository) GetFilesByPath(relativePath string) ([]*FileVers
 	return files, rows.Err()
 }
 
+// GetAllFiles retrieves every file version of the repository, ordered by FileID
+func (r *FileVersionRepository) GetAllFiles() ([]*FileVersion, error) {
+	tableName := r.tableName()
+
+	query := fmt.Sprintf(`
+		SELECT file_id, file_sha, relative_path, ephemeral, commit_id, status, created_at, updated_at
+		FROM %s
+		ORDER BY file_id
+	`, tableName)
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []*FileVersion
+	for rows.Next() {
+		var fv FileVersion
+		err := rows.Scan(
+			&fv.FileID,
+			&fv.FileSHA,
+			&fv.RelativePath,
+			&fv.Ephemeral,
+			&fv.CommitID,
+			&fv.Status,
+			&fv.CreatedAt,
+			&fv.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, &fv)
+	}
+
+	return files, rows.Err()
+}
+
 // DeleteEphemeralVersions deletes all ephemeral file versions
 func (r *FileVersionRepository) DeleteEphemeralVersions() (int64, error) {
 	tableName := r.tableName()
@@ -372,6 +410,44 @@ func (r *FileVersionRepository) UpdateStatus(fileID int32, status string) error
 	return nil
 }
 
+// SkippedStatusPrefix prefixes the status of file versions that were not indexed; the rest
+// of the status is the skip reason (e.g. "skipped:binary")
+const SkippedStatusPrefix = "skipped:"
+
+// MarkSkipped records that a file version was not indexed and why
+func (r *FileVersionRepository) MarkSkipped(fileID int32, reason string) error {
+	return r.UpdateStatus(fileID, SkippedStatusPrefix+reason)
+}
+
+// GetSkippedCounts returns the number of skipped file versions per skip reason
+func (r *FileVersionRepository) GetSkippedCounts() (map[string]int64, error) {
+	tableName := r.tableName()
+
+	query := fmt.Sprintf(`
+		SELECT status, COUNT(*)
+		FROM %s
+		WHERE status LIKE ?
+		GROUP BY status
+	`, tableName)
+
+	rows, err := r.db.Query(query, SkippedStatusPrefix+"%")
+	if err != nil {
+		return nil, fmt.Errorf("failed to count skipped files: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int64)
+	for rows.Next() {
+		var status string
+		var count int64
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan skipped count: %w", err)
+		}
+		counts[strings.TrimPrefix(status, SkippedStatusPrefix)] = count
+	}
+	return counts, rows.Err()
+}
+
 // GetStats returns statistics about the file versions
 func (r *FileVersionRepository) GetStats() (total int64, ephemeral int64, committed int64, err error) {
 	tableName := r.tableName()
@@ -379,8 +455,8 @@ func (r *FileVersionRepository) GetStats() (total int64, ephemeral int64, commit
 	query := fmt.Sprintf(`
 		SELECT
 			COUNT(*) as total,
-			SUM(CASE WHEN ephemeral = TRUE THEN 1 ELSE 0 END) as ephemeral,
-			SUM(CASE WHEN ephemeral = FALSE THEN 1 ELSE 0 END) as committed
+			COALESCE(SUM(CASE WHEN ephemeral = TRUE THEN 1 ELSE 0 END), 0) as ephemeral,
+			COALESCE(SUM(CASE WHEN ephemeral = FALSE THEN 1 ELSE 0 END), 0) as committed
 		FROM %s
 	`, tableName)
 
@@ -388,6 +464,17 @@ func (r *FileVersionRepository) GetStats() (total int64, ephemeral int64, commit
 	return
 }
 
+// LastIndexedAt returns when a file version was last recorded or updated. ok is false when
+// no file version is recorded.
+func (r *FileVersionRepository) LastIndexedAt() (last time.Time, ok bool, err error) {
+	var t sql.NullTime
+	query := fmt.Sprintf(`SELECT MAX(updated_at) FROM %s`, r.tableName())
+	if err := r.db.QueryRow(query).Scan(&t); err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to get last indexed time: %w", err)
+	}
+	return t.Time, t.Valid, nil
+}
+
 // DropTable drops the file_versions table for this repository.
 // This permanently deletes all file version tracking data for the repository.
 func (r *FileVersionRepository) DropTable() error {
@@ -0,0 +1,6 @@
+//go:build !faults
+
+package faults
+
+// Enabled reports whether fault injection is compiled in
+const Enabled = false
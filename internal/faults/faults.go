@@ -0,0 +1,253 @@
+// Package faults injects latency, errors and partial failures into the graph database,
+// vector database and embedding clients so retry and degradation logic can be exercised in
+// staging. Injection is compiled in only with the "faults" build tag; in other builds Enabled
+// is false and the hooks return immediately.
+package faults
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"bot-go/internal/apperrors"
+)
+
+// Targets are the clients faults can be injected into
+const (
+	TargetNeo4j     = "neo4j"
+	TargetQdrant    = "qdrant"
+	TargetEmbedding = "embedding"
+)
+
+// Targets lists every valid target
+var Targets = []string{TargetNeo4j, TargetQdrant, TargetEmbedding}
+
+// ErrInjected is wrapped by every injected error, together with apperrors.ErrBackendUnavailable
+// so callers treat it like a real outage
+var ErrInjected = errors.New("injected fault")
+
+// Rule describes the faults injected into the calls of one target
+type Rule struct {
+	ErrorRate   float64  `json:"error_rate"`           // Fraction of calls failing with ErrInjected
+	PartialRate float64  `json:"partial_rate"`         // Fraction of batch calls applying only part of the batch before failing
+	LatencyMs   int      `json:"latency_ms"`           // Delay added before every call
+	JitterMs    int      `json:"jitter_ms"`            // Plus a random delay of up to this much
+	Operations  []string `json:"operations,omitempty"` // Only these operations, e.g. "read" or "upsert" (empty = all)
+}
+
+// Validate checks the rates and delays of a rule
+func (r Rule) Validate() error {
+	if r.ErrorRate < 0 || r.ErrorRate > 1 {
+		return fmt.Errorf("%w: error_rate must be in [0, 1], got %g", apperrors.ErrInvalidArgument, r.ErrorRate)
+	}
+	if r.PartialRate < 0 || r.PartialRate > 1 {
+		return fmt.Errorf("%w: partial_rate must be in [0, 1], got %g", apperrors.ErrInvalidArgument, r.PartialRate)
+	}
+	if r.LatencyMs < 0 || r.JitterMs < 0 {
+		return fmt.Errorf("%w: latency_ms and jitter_ms must not be negative", apperrors.ErrInvalidArgument)
+	}
+	return nil
+}
+
+func (r Rule) applies(operation string) bool {
+	if len(r.Operations) == 0 {
+		return true
+	}
+	for _, op := range r.Operations {
+		if op == operation {
+			return true
+		}
+	}
+	return false
+}
+
+// Counts are the faults injected into one target since its rule was set
+type Counts struct {
+	Calls    int64 `json:"calls"`
+	Delayed  int64 `json:"delayed"`
+	Errors   int64 `json:"errors"`
+	Partials int64 `json:"partials"`
+}
+
+// Injector holds the active rules per target
+type Injector struct {
+	mu     sync.Mutex
+	rules  map[string]Rule
+	counts map[string]*Counts
+	rand   *rand.Rand
+}
+
+// NewInjector returns an injector without rules. seed makes the injected faults repeatable.
+func NewInjector(seed int64) *Injector {
+	return &Injector{
+		rules:  make(map[string]Rule),
+		counts: make(map[string]*Counts),
+		rand:   rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Default is the injector the client hooks consult and the admin endpoint configures
+var Default = NewInjector(time.Now().UnixNano())
+
+// Set replaces the rule of a target and resets its counts
+func (in *Injector) Set(target string, rule Rule) error {
+	if !validTarget(target) {
+		return fmt.Errorf("%w: unknown fault target %q", apperrors.ErrInvalidArgument, target)
+	}
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.rules[target] = rule
+	in.counts[target] = &Counts{}
+	return nil
+}
+
+// Clear removes the rule of a target, or of every target when target is empty
+func (in *Injector) Clear(target string) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if target == "" {
+		in.rules = make(map[string]Rule)
+		in.counts = make(map[string]*Counts)
+		return
+	}
+	delete(in.rules, target)
+	delete(in.counts, target)
+}
+
+// TargetState is the rule of a target and what it injected so far
+type TargetState struct {
+	Target string `json:"target"`
+	Rule   Rule   `json:"rule"`
+	Counts Counts `json:"counts"`
+}
+
+// State returns the active rules with their counts, by target name
+func (in *Injector) State() []TargetState {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	states := make([]TargetState, 0, len(in.rules))
+	for target, rule := range in.rules {
+		states = append(states, TargetState{Target: target, Rule: rule, Counts: *in.counts[target]})
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Target < states[j].Target })
+	return states
+}
+
+// decision is what to inject into one call
+type decision struct {
+	delay   time.Duration
+	fail    bool
+	partial bool
+}
+
+func (in *Injector) decide(target, operation string, batch bool) decision {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	rule, ok := in.rules[target]
+	if !ok || !rule.applies(operation) {
+		return decision{}
+	}
+	counts := in.counts[target]
+	counts.Calls++
+
+	var d decision
+	if rule.LatencyMs > 0 || rule.JitterMs > 0 {
+		d.delay = time.Duration(rule.LatencyMs) * time.Millisecond
+		if rule.JitterMs > 0 {
+			d.delay += time.Duration(in.rand.Intn(rule.JitterMs+1)) * time.Millisecond
+		}
+		counts.Delayed++
+	}
+	switch {
+	case in.rand.Float64() < rule.ErrorRate:
+		d.fail = true
+		counts.Errors++
+	case batch && in.rand.Float64() < rule.PartialRate:
+		d.partial = true
+		counts.Partials++
+	}
+	return d
+}
+
+// Inject applies the rule of target to one call of operation: it sleeps for the configured
+// latency (or until ctx is done) and returns an injected error at the configured rate
+func (in *Injector) Inject(ctx context.Context, target, operation string) error {
+	d := in.decide(target, operation, false)
+	if err := sleep(ctx, d.delay); err != nil {
+		return err
+	}
+	if d.fail {
+		return injectedError(target, operation)
+	}
+	return nil
+}
+
+// InjectBatch is Inject for a call applying n items. It returns how many leading items to
+// apply before failing with the returned error; a nil error means apply all n.
+func (in *Injector) InjectBatch(ctx context.Context, target, operation string, n int) (int, error) {
+	d := in.decide(target, operation, n > 1)
+	if err := sleep(ctx, d.delay); err != nil {
+		return 0, err
+	}
+	switch {
+	case d.fail:
+		return 0, injectedError(target, operation)
+	case d.partial:
+		in.mu.Lock()
+		applied := 1 + in.rand.Intn(n-1)
+		in.mu.Unlock()
+		return applied, fmt.Errorf("%w after %d of %d items", injectedError(target, operation), applied, n)
+	}
+	return n, nil
+}
+
+// Inject applies the Default rules to one call; a no-op unless built with the faults tag
+func Inject(ctx context.Context, target, operation string) error {
+	if !Enabled {
+		return nil
+	}
+	return Default.Inject(ctx, target, operation)
+}
+
+// InjectBatch applies the Default rules to a batch call; a no-op unless built with the
+// faults tag
+func InjectBatch(ctx context.Context, target, operation string, n int) (int, error) {
+	if !Enabled {
+		return n, nil
+	}
+	return Default.InjectBatch(ctx, target, operation, n)
+}
+
+func injectedError(target, operation string) error {
+	return apperrors.Unavailable(target, fmt.Errorf("%w in %s", ErrInjected, operation))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func validTarget(target string) bool {
+	for _, t := range Targets {
+		if t == target {
+			return true
+		}
+	}
+	return false
+}
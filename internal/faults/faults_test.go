@@ -0,0 +1,70 @@
+package faults
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"bot-go/internal/apperrors"
+)
+
+func TestInjectorErrorsAndPartials(t *testing.T) {
+	in := NewInjector(1)
+	ctx := context.Background()
+	if err := in.Inject(ctx, TargetNeo4j, "read"); err != nil {
+		t.Fatalf("expected no fault without a rule, got %v", err)
+	}
+
+	if err := in.Set(TargetNeo4j, Rule{ErrorRate: 1, Operations: []string{"write"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := in.Inject(ctx, TargetNeo4j, "read"); err != nil {
+		t.Errorf("expected reads to be unaffected, got %v", err)
+	}
+	err := in.Inject(ctx, TargetNeo4j, "write")
+	if !errors.Is(err, ErrInjected) || !errors.Is(err, apperrors.ErrBackendUnavailable) {
+		t.Errorf("expected an injected backend error, got %v", err)
+	}
+
+	if err := in.Set(TargetQdrant, Rule{PartialRate: 1}); err != nil {
+		t.Fatal(err)
+	}
+	applied, err := in.InjectBatch(ctx, TargetQdrant, "upsert", 10)
+	if err == nil || applied < 1 || applied >= 10 {
+		t.Errorf("expected a partial batch, got %d applied (%v)", applied, err)
+	}
+	if applied, err := in.InjectBatch(ctx, TargetQdrant, "upsert", 1); err != nil || applied != 1 {
+		t.Errorf("expected a single item batch to apply, got %d (%v)", applied, err)
+	}
+
+	states := in.State()
+	if len(states) != 2 || states[0].Target != TargetNeo4j || states[0].Counts.Errors != 1 || states[1].Counts.Partials != 1 {
+		t.Errorf("unexpected state %+v", states)
+	}
+	in.Clear("")
+	if len(in.State()) != 0 {
+		t.Error("expected Clear to remove every rule")
+	}
+}
+
+func TestInjectorRejectsInvalidRules(t *testing.T) {
+	in := NewInjector(1)
+	if err := in.Set("mongodb", Rule{}); !errors.Is(err, apperrors.ErrInvalidArgument) {
+		t.Errorf("expected an unknown target to be rejected, got %v", err)
+	}
+	if err := in.Set(TargetEmbedding, Rule{ErrorRate: 1.5}); !errors.Is(err, apperrors.ErrInvalidArgument) {
+		t.Errorf("expected an error rate above 1 to be rejected, got %v", err)
+	}
+}
+
+func TestInjectHonorsContextDuringLatency(t *testing.T) {
+	in := NewInjector(1)
+	if err := in.Set(TargetEmbedding, Rule{LatencyMs: 60000}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := in.Inject(ctx, TargetEmbedding, "embed"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the delay to end with the context, got %v", err)
+	}
+}
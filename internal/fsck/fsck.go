@@ -0,0 +1,147 @@
+// Package fsck cross-checks what MySQL file tracking, the code graph and the vector store
+// record about the files of a repository, and reports what only some of them know about.
+package fsck
+
+import (
+	"bot-go/internal/db"
+	"bot-go/internal/service/vector"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Reasons a FileScope is reported as belonging to a deleted file
+const (
+	ReasonUnknownFileID = "unknown_file_id" // no file_versions row has its file ID
+	ReasonNotInCheckout = "not_in_checkout" // its path no longer exists in the repository
+)
+
+// Snapshot is what each store records about the files of one repository. FileScopes and
+// ChunkFiles are nil when the code graph or the vector store is disabled, which skips the
+// checks needing them.
+type Snapshot struct {
+	Files      []*db.FileVersion
+	FileScopes map[int32]string // path by file ID
+	ChunkFiles []vector.ChunkFile
+	// ExpectsFileScope reports whether the code graph processor creates a FileScope for a
+	// repository-relative path; files it skips (e.g. unsupported languages or test files
+	// under skip_test_files) can be done without one. Nil expects a FileScope for every file.
+	ExpectsFileScope func(relPath string) bool
+	// PathExists reports whether a repository-relative path exists in the checkout. Nil
+	// when the checkout is not available, which skips the check.
+	PathExists func(relPath string) bool
+}
+
+// FileRef identifies a file reported by a check
+type FileRef struct {
+	FileID int32  `json:"file_id"`
+	Path   string `json:"path"`
+	Chunks int    `json:"chunks,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Report lists the inconsistencies found in one repository
+type Report struct {
+	Repo       string `json:"repo"`
+	Files      int    `json:"files"`
+	FileScopes int    `json:"file_scopes"`
+	ChunkFiles int    `json:"chunk_files"`
+	// DoneWithoutGraph are files marked done in MySQL without a FileScope in the graph
+	DoneWithoutGraph []FileRef `json:"done_without_graph"`
+	// UnknownChunkFiles are files with chunks that MySQL has no row for
+	UnknownChunkFiles []FileRef `json:"unknown_chunk_files"`
+	// DeletedGraphFiles are FileScopes whose file MySQL does not know or the checkout no
+	// longer contains
+	DeletedGraphFiles []FileRef `json:"deleted_graph_files"`
+}
+
+// Issues returns the number of inconsistencies in the report
+func (r *Report) Issues() int {
+	return len(r.DoneWithoutGraph) + len(r.UnknownChunkFiles) + len(r.DeletedGraphFiles)
+}
+
+// Check compares the stores of one repository
+func Check(repo string, snap Snapshot) *Report {
+	report := &Report{
+		Repo:       repo,
+		Files:      len(snap.Files),
+		FileScopes: len(snap.FileScopes),
+		ChunkFiles: len(snap.ChunkFiles),
+	}
+
+	known := make(map[int32]bool, len(snap.Files))
+	knownPaths := make(map[string]bool, len(snap.Files))
+	for _, fv := range snap.Files {
+		known[fv.FileID] = true
+		knownPaths[fv.RelativePath] = true
+	}
+
+	if snap.FileScopes != nil {
+		for _, fv := range snap.Files {
+			if fv.Status != "done" || (snap.ExpectsFileScope != nil && !snap.ExpectsFileScope(fv.RelativePath)) {
+				continue
+			}
+			if _, ok := snap.FileScopes[fv.FileID]; !ok {
+				report.DoneWithoutGraph = append(report.DoneWithoutGraph, FileRef{FileID: fv.FileID, Path: fv.RelativePath})
+			}
+		}
+
+		for fileID, path := range snap.FileScopes {
+			switch {
+			case !known[fileID]:
+				report.DeletedGraphFiles = append(report.DeletedGraphFiles, FileRef{FileID: fileID, Path: path, Reason: ReasonUnknownFileID})
+			case snap.PathExists != nil && !snap.PathExists(path):
+				report.DeletedGraphFiles = append(report.DeletedGraphFiles, FileRef{FileID: fileID, Path: path, Reason: ReasonNotInCheckout})
+			}
+		}
+		sortRefs(report.DeletedGraphFiles)
+	}
+
+	for _, cf := range snap.ChunkFiles {
+		// Chunks written before file IDs were recorded are matched by path
+		if known[cf.FileID] || (cf.FileID == 0 && knownPaths[cf.FilePath]) {
+			continue
+		}
+		report.UnknownChunkFiles = append(report.UnknownChunkFiles, FileRef{FileID: cf.FileID, Path: cf.FilePath, Chunks: cf.Chunks})
+	}
+	sortRefs(report.UnknownChunkFiles)
+
+	return report
+}
+
+// sortRefs orders refs by path, then file ID, so reports are stable
+func sortRefs(refs []FileRef) {
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].Path != refs[j].Path {
+			return refs[i].Path < refs[j].Path
+		}
+		return refs[i].FileID < refs[j].FileID
+	})
+}
+
+// WriteReport prints the report in a human-readable form
+func WriteReport(w io.Writer, r *Report) {
+	fmt.Fprintf(w, "%s: %d files tracked, %d FileScopes, %d files with chunks\n", r.Repo, r.Files, r.FileScopes, r.ChunkFiles)
+	section := func(title string, refs []FileRef) {
+		if len(refs) == 0 {
+			return
+		}
+		fmt.Fprintf(w, "  %s (%d):\n", title, len(refs))
+		for _, ref := range refs {
+			line := fmt.Sprintf("    %6d  %s", ref.FileID, ref.Path)
+			if ref.Chunks > 0 {
+				line += fmt.Sprintf("  (%d chunks)", ref.Chunks)
+			}
+			if ref.Reason != "" {
+				line += "  [" + ref.Reason + "]"
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+	section("Files marked done without graph nodes", r.DoneWithoutGraph)
+	section("Chunks for unknown files", r.UnknownChunkFiles)
+	section("Graph nodes for deleted files", r.DeletedGraphFiles)
+	if r.Issues() == 0 {
+		fmt.Fprintln(w, "  No inconsistencies found")
+	}
+}
@@ -0,0 +1,90 @@
+package fsck
+
+import (
+	"bot-go/internal/config"
+	"bot-go/internal/db"
+	"bot-go/internal/parse"
+	"bot-go/internal/service/vector"
+	"bytes"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestCheck(t *testing.T) {
+	snap := Snapshot{
+		Files: []*db.FileVersion{
+			{FileID: 1, RelativePath: "a.go", Status: "done"},
+			{FileID: 2, RelativePath: "b.go", Status: "done"},       // no FileScope
+			{FileID: 3, RelativePath: "c.go", Status: "processing"}, // not done yet
+			{FileID: 4, RelativePath: "README.md", Status: "done"},  // not parsed
+			{FileID: 5, RelativePath: "gone.go", Status: "done"},
+		},
+		FileScopes: map[int32]string{1: "a.go", 5: "gone.go", 9: "old.go"},
+		ChunkFiles: []vector.ChunkFile{
+			{FileID: 1, FilePath: "a.go", Chunks: 3},
+			{FileID: 0, FilePath: "a.go", Chunks: 1}, // written before file IDs, matched by path
+			{FileID: 0, FilePath: "legacy.go", Chunks: 2},
+			{FileID: 8, FilePath: "x.go", Chunks: 4},
+		},
+		ExpectsFileScope: func(relPath string) bool { return strings.HasSuffix(relPath, ".go") },
+		PathExists:       func(relPath string) bool { return relPath != "gone.go" },
+	}
+
+	report := Check("repo", snap)
+
+	if len(report.DoneWithoutGraph) != 1 || report.DoneWithoutGraph[0].FileID != 2 {
+		t.Errorf("DoneWithoutGraph = %+v", report.DoneWithoutGraph)
+	}
+	want := []FileRef{{FileID: 5, Path: "gone.go", Reason: ReasonNotInCheckout}, {FileID: 9, Path: "old.go", Reason: ReasonUnknownFileID}}
+	if len(report.DeletedGraphFiles) != 2 || report.DeletedGraphFiles[0] != want[0] || report.DeletedGraphFiles[1] != want[1] {
+		t.Errorf("DeletedGraphFiles = %+v, want %+v", report.DeletedGraphFiles, want)
+	}
+	if len(report.UnknownChunkFiles) != 2 || report.UnknownChunkFiles[0].Path != "legacy.go" || report.UnknownChunkFiles[1].FileID != 8 {
+		t.Errorf("UnknownChunkFiles = %+v", report.UnknownChunkFiles)
+	}
+	if report.Issues() != 5 {
+		t.Errorf("Issues() = %d, want 5", report.Issues())
+	}
+
+	var buf bytes.Buffer
+	WriteReport(&buf, report)
+	if !strings.Contains(buf.String(), "old.go  [unknown_file_id]") || !strings.Contains(buf.String(), "x.go  (4 chunks)") {
+		t.Errorf("unexpected report:\n%s", buf.String())
+	}
+}
+
+func TestCheck_DisabledStoresAreSkipped(t *testing.T) {
+	report := Check("repo", Snapshot{
+		Files: []*db.FileVersion{{FileID: 1, RelativePath: "a.go", Status: "done"}},
+	})
+	if report.Issues() != 0 {
+		t.Errorf("expected no issues without graph and vector snapshots, got %+v", report)
+	}
+}
+
+func TestCheck_SkippedTestFiles(t *testing.T) {
+	repo := &config.Repository{Name: "repo", Language: "go"}
+	snap := func(skipTestFiles bool) Snapshot {
+		cfg := &config.Config{}
+		cfg.CodeGraph.Languages = map[string]config.LanguageOptions{"go": {SkipTestFiles: skipTestFiles}}
+		parser := parse.NewFileParser(zap.NewNop(), nil, cfg)
+		return Snapshot{
+			Files: []*db.FileVersion{
+				{FileID: 1, RelativePath: "store.go", Status: "done"},
+				{FileID: 2, RelativePath: "store_test.go", Status: "done"}, // marked done, never parsed
+			},
+			FileScopes:       map[int32]string{1: "store.go"},
+			ExpectsFileScope: func(relPath string) bool { return parser.ExpectsFileScope(repo, relPath) },
+		}
+	}
+
+	if report := Check("repo", snap(true)); report.Issues() != 0 {
+		t.Errorf("skipped test file reported: %+v", report)
+	}
+	report := Check("repo", snap(false))
+	if len(report.DoneWithoutGraph) != 1 || report.DoneWithoutGraph[0].Path != "store_test.go" {
+		t.Errorf("DoneWithoutGraph = %+v, want the unparsed test file", report.DoneWithoutGraph)
+	}
+}
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"bytes"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"bot-go/internal/access"
+	"bot-go/internal/logging"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// APIKeyMiddleware authenticates requests with the API keys of the policy and stores the
+// client in the request context (see access.ClientFromContext). Requests with an unknown
+// key, or without one when a key is required, are refused with 401; requests of clients
+// that used up a quota are refused with 429 until the quota period ends.
+func APIKeyMiddleware(policy *access.Policy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		client, err := policy.Authenticate(c.Request)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		if resource, resets := client.Account.Exhausted(); resource != "" {
+			retry := int(math.Ceil(time.Until(resets).Seconds()))
+			c.Header("Retry-After", strconv.Itoa(max(retry, 1)))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error":     "quota exhausted",
+				"resource":  resource,
+				"resets_at": resets,
+			})
+			return
+		}
+		c.Request = c.Request.WithContext(access.WithClient(c.Request.Context(), client))
+		c.Next()
+	}
+}
+
+// UsageHandler returns the usage of the caller's API key in the current quota period
+func UsageHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		client := access.ClientFromContext(c.Request.Context())
+		if client == nil || client.Account == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "usage is not accounted"})
+			return
+		}
+		c.JSON(http.StatusOK, client.Account.Report())
+	}
+}
+
+// UsageReportsHandler lists the usage of every API client in the current quota period
+func UsageReportsHandler(policy *access.Policy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"clients": policy.Meter().Reports()})
+	}
+}
+
+// RedactionMiddleware filters the JSON responses sent to clients whose scope has redaction
+// rules. Other responses, e.g. streams, are passed through untouched.
+func RedactionMiddleware(policy *access.Policy, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		client := access.ClientFromContext(c.Request.Context())
+		if client == nil {
+			c.Next()
+			return
+		}
+		redactor := policy.Redactor(client.Scope)
+		if redactor == nil {
+			c.Next()
+			return
+		}
+
+		writer := &redactingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+		if !writer.buffering {
+			return
+		}
+		body, err := redactor.RedactJSON(writer.body.Bytes())
+		if err != nil {
+			// A response that cannot be filtered is not sent
+			logging.FromContext(c.Request.Context(), logger).Error("Failed to redact response",
+				zap.String("path", c.Request.URL.Path),
+				zap.Error(err))
+			c.Writer.WriteHeader(http.StatusInternalServerError)
+			body = []byte(`{"error":"failed to redact response"}`)
+		}
+		c.Writer.Write(body)
+	}
+}
+
+// redactingWriter holds back JSON bodies until the handler is done, so they can be filtered
+// as a whole. Whether a response is JSON is decided on its first write.
+type redactingWriter struct {
+	gin.ResponseWriter
+	decided   bool
+	buffering bool
+	body      bytes.Buffer
+}
+
+func (w *redactingWriter) Write(data []byte) (int, error) {
+	if !w.decided {
+		w.decided = true
+		w.buffering = strings.HasPrefix(w.Header().Get("Content-Type"), "application/json")
+	}
+	if w.buffering {
+		return w.body.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *redactingWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *redactingWriter) Flush() {
+	if !w.buffering {
+		w.ResponseWriter.Flush()
+	}
+}
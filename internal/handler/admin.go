@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"io"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"bot-go/internal/access"
+	"bot-go/internal/config"
+	"bot-go/internal/controller"
+	"bot-go/internal/faults"
+	"bot-go/internal/logging"
+	"bot-go/internal/storage"
+	"bot-go/internal/util"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// dumpProfiles are the runtime profiles /api/v1/admin/dump can write
+var dumpProfiles = map[string]bool{
+	"heap": true, "goroutine": true, "allocs": true, "block": true, "mutex": true, "threadcreate": true,
+}
+
+// RegisterAdminRoutes adds the profiling endpoints when admin.enable_profiling is set:
+// net/http/pprof under /debug/pprof and an on-demand profile dump at /api/v1/admin/dump.
+// Profiles are dumped under profiles/ in store, or in the system temp directory without one.
+// Builds with the faults tag also get the fault injection endpoints under
+// /api/v1/admin/faults. With admin.enable_scripting and the code graph (a non-nil
+// codeAPIController), analysis scripts run at /api/v1/admin/script. With an enabled
+// apiPolicy, the usage of every API key is listed at /api/v1/admin/usage.
+func RegisterAdminRoutes(router *gin.Engine, cfg config.AdminConfig, store storage.Store, codeAPIController *controller.CodeAPIController, apiPolicy *access.Policy, logger *zap.Logger) {
+	auth := AdminAuthMiddleware(cfg.Token)
+	if faults.Enabled {
+		logger.Warn("Fault injection is compiled in; do not run this build in production")
+		admin := router.Group("/api/v1/admin/faults", auth)
+		admin.GET("", FaultStateHandler(faults.Default))
+		admin.PUT("/:target", SetFaultHandler(faults.Default, logger))
+		admin.DELETE("/:target", ClearFaultHandler(faults.Default, logger))
+		admin.DELETE("", ClearFaultHandler(faults.Default, logger))
+	}
+
+	if cfg.EnableScripting {
+		if codeAPIController == nil {
+			logger.Warn("Scripting enabled without the code graph; /api/v1/admin/script is not served")
+		} else {
+			if cfg.Token == "" {
+				logger.Warn("Scripting endpoint enabled without admin.token; only local requests are allowed")
+			}
+			router.POST("/api/v1/admin/script", auth, codeAPIController.RunScript)
+		}
+	}
+
+	if apiPolicy.Enabled() {
+		router.GET("/api/v1/admin/usage", auth, UsageReportsHandler(apiPolicy))
+	}
+
+	if !cfg.EnableProfiling {
+		return
+	}
+	if cfg.Token == "" {
+		logger.Warn("Profiling endpoints enabled without admin.token; only local requests are allowed")
+	}
+
+	debug := router.Group("/debug/pprof", auth)
+	{
+		debug.GET("/", gin.WrapF(pprof.Index))
+		debug.GET("/cmdline", gin.WrapF(pprof.Cmdline))
+		debug.GET("/profile", gin.WrapF(pprof.Profile))
+		debug.GET("/symbol", gin.WrapF(pprof.Symbol))
+		debug.POST("/symbol", gin.WrapF(pprof.Symbol))
+		debug.GET("/trace", gin.WrapF(pprof.Trace))
+		// Named profiles: heap, goroutine, allocs, block, mutex, threadcreate
+		debug.GET("/:profile", func(c *gin.Context) {
+			pprof.Handler(c.Param("profile")).ServeHTTP(c.Writer, c.Request)
+		})
+	}
+
+	if store == nil {
+		store = storage.NewLocal(filepath.Join(os.TempDir(), "bot-go"))
+	}
+	router.POST("/api/v1/admin/dump", auth, ProfileDumpHandler(store, logger))
+}
+
+// AdminAuthMiddleware admits requests carrying token as "Authorization: Bearer <token>".
+// With an empty token only requests from the loopback interface are admitted.
+func AdminAuthMiddleware(token string) gin.HandlerFunc {
+	return BearerAuthMiddleware(token, "admin")
+}
+
+// BearerAuthMiddleware admits requests carrying token as "Authorization: Bearer <token>".
+// With an empty token only requests from the loopback interface are admitted. scope names
+// the guarded endpoints in error messages.
+func BearerAuthMiddleware(token, scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			if ip := net.ParseIP(c.RemoteIP()); ip == nil || !ip.IsLoopback() {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": scope + " endpoints are only available locally"})
+				return
+			}
+			c.Next()
+			return
+		}
+		given, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid or missing " + scope + " token"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// ProfileDumpHandler writes the runtime profiles named by the comma-separated "profiles"
+// query parameter (default heap,goroutine) to timestamped objects under profiles/ in store
+// and returns their locations. Unlike /debug/pprof the profiles are kept for later comparison.
+func ProfileDumpHandler(store storage.Store, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		names := strings.Split(c.DefaultQuery("profiles", "heap,goroutine"), ",")
+		for _, name := range names {
+			if !dumpProfiles[name] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "unknown profile: " + name})
+				return
+			}
+		}
+
+		stamp := time.Now().UTC().Format("20060102T150405.000Z")
+		files := make(map[string]string, len(names))
+		for _, name := range names {
+			key := "profiles/" + stamp + "-" + name + ".pprof"
+			err := store.Put(c.Request.Context(), key, func(w io.Writer) error {
+				return util.WriteProfileTo(name, w)
+			})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			files[name] = store.Location(key)
+		}
+		logging.FromContext(c.Request.Context(), logger).Info("Profiles dumped", zap.Any("files", files))
+		c.JSON(http.StatusOK, gin.H{"files": files})
+	}
+}
+
+// FaultStateHandler lists the active fault rules with the faults each has injected
+func FaultStateHandler(injector *faults.Injector) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"targets": faults.Targets, "rules": injector.State()})
+	}
+}
+
+// SetFaultHandler replaces the fault rule of the target named in the path with the rule in
+// the request body
+func SetFaultHandler(injector *faults.Injector, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var rule faults.Rule
+		if err := c.ShouldBindJSON(&rule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload", "details": err.Error()})
+			return
+		}
+		target := c.Param("target")
+		if err := injector.Set(target, rule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logging.FromContext(c.Request.Context(), logger).Warn("Fault rule set",
+			zap.String("target", target),
+			zap.Any("rule", rule))
+		c.JSON(http.StatusOK, gin.H{"rules": injector.State()})
+	}
+}
+
+// ClearFaultHandler removes the fault rule of the target named in the path, or every rule
+// without a target
+func ClearFaultHandler(injector *faults.Injector, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		target := c.Param("target")
+		injector.Clear(target)
+		logging.FromContext(c.Request.Context(), logger).Info("Fault rules cleared", zap.String("target", target))
+		c.JSON(http.StatusOK, gin.H{"rules": injector.State()})
+	}
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"bot-go/internal/apperrors"
+	"bot-go/internal/coldstorage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// restoreRetryAfter is the Retry-After, in seconds, of requests for an archived repository
+const restoreRetryAfter = "30"
+
+// ColdStorageMiddleware queues the restore of archived repositories targeted by a request,
+// answering 503 until they are back. The repository is the :name of /repos/:name routes or
+// the repo_name of a JSON body.
+func ColdStorageMiddleware(archiver *coldstorage.Archiver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		repo := targetRepo(c)
+		if repo == "" || !archiver.Archived(repo) {
+			c.Next()
+			return
+		}
+		status, err := archiver.Restore(repo)
+		if err != nil {
+			c.Next()
+			return
+		}
+		c.Header("Retry-After", restoreRetryAfter)
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+			"error":        "repository " + repo + " is archived; restore queued",
+			"cold_storage": status,
+		})
+	}
+}
+
+// targetRepo returns the repository a request is about, leaving the body readable
+func targetRepo(c *gin.Context) string {
+	if strings.Contains(c.FullPath(), "/repos/:name") {
+		return c.Param("name")
+	}
+	if c.Request.Body == nil || !strings.HasPrefix(c.ContentType(), "application/json") {
+		return ""
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+	var target struct {
+		RepoName string `json:"repo_name"`
+	}
+	if json.Unmarshal(body, &target) != nil {
+		return ""
+	}
+	return target.RepoName
+}
+
+// ColdStorageStatusHandler returns the cold storage state of a repository
+func ColdStorageStatusHandler(archiver *coldstorage.Archiver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status, err := archiver.Status(c.Param("name"))
+		respondColdStorage(c, http.StatusOK, status, err)
+	}
+}
+
+// ArchiveHandler queues the archive of a repository
+func ArchiveHandler(archiver *coldstorage.Archiver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status, err := archiver.Archive(c.Param("name"))
+		respondColdStorage(c, http.StatusAccepted, status, err)
+	}
+}
+
+// RestoreHandler queues the restore of an archived repository
+func RestoreHandler(archiver *coldstorage.Archiver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status, err := archiver.Restore(c.Param("name"))
+		respondColdStorage(c, http.StatusAccepted, status, err)
+	}
+}
+
+func respondColdStorage(c *gin.Context, code int, status coldstorage.Status, err error) {
+	switch {
+	case errors.Is(err, apperrors.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, coldstorage.ErrBusy):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "cold_storage": status})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(code, status)
+	}
+}
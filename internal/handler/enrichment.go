@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"bot-go/internal/config"
+	"bot-go/internal/controller"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// RegisterEnrichmentRoutes adds the endpoints external tools use to add relations to the code
+// graph when enrichment.enabled is set. They are guarded by enrichment.token, or only served
+// locally without one. A nil enrichmentController (no code graph) registers nothing.
+func RegisterEnrichmentRoutes(router *gin.Engine, cfg config.EnrichmentConfig, enrichmentController *controller.EnrichmentController, logger *zap.Logger) {
+	if !cfg.Enabled {
+		return
+	}
+	if enrichmentController == nil {
+		logger.Warn("Enrichment enabled without the code graph; /api/v1/enrichment is not served")
+		return
+	}
+	if cfg.Token == "" {
+		logger.Warn("Enrichment endpoints enabled without enrichment.token; only local requests are allowed")
+	}
+
+	enrichment := router.Group("/api/v1/enrichment", BearerAuthMiddleware(cfg.Token, "enrichment"))
+	{
+		enrichment.GET("/relation-types", enrichmentController.RelationTypes)
+		enrichment.POST("/relations", enrichmentController.CreateRelations)
+		enrichment.DELETE("/relations", enrichmentController.DeleteRelations)
+		enrichment.POST("/traces", enrichmentController.ImportTraces)
+	}
+}
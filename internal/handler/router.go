@@ -3,29 +3,63 @@ package handler
 import (
 	"net/http"
 	"runtime/debug"
+	"time"
 
+	"bot-go/internal/access"
+	"bot-go/internal/coldstorage"
 	"bot-go/internal/controller"
+	"bot-go/internal/logging"
+	"bot-go/internal/querylog"
+	"bot-go/internal/service/codegraph"
+	"bot-go/internal/slowlog"
 	"bot-go/pkg/mcp"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
-func SetupRouter(repoController *controller.RepoController, mcpServer *mcp.CodeGraphServer, codeAPIController *controller.CodeAPIController, logger *zap.Logger) *gin.Engine {
+// SetupRouter registers the HTTP API. With an enabled apiPolicy, /api/v1 and /codeapi/v1
+// authenticate API keys, enforce their quotas and redact the responses of scopes with
+// redaction rules. With an archiver, requests for archived repositories queue their restore.
+func SetupRouter(repoController *controller.RepoController, mcpServer *mcp.CodeGraphServer, codeAPIController *controller.CodeAPIController, graphEmbeddingController *controller.GraphEmbeddingController, sessionController *controller.SessionController, slowLog *slowlog.Log, queryLog *querylog.Recorder, apiPolicy *access.Policy, archiver *coldstorage.Archiver, logger *zap.Logger) *gin.Engine {
 	gin.SetMode(gin.ReleaseMode)
 
 	router := gin.New()
+	// Lets handlers pass *gin.Context where a context.Context is expected (e.g. to read the request ID)
+	router.ContextWithFallback = true
+	router.Use(RequestIDMiddleware())
 	router.Use(CustomRecoveryMiddleware(logger))
 	router.Use(LoggerMiddleware(logger))
+	router.Use(LatencyBudgetMiddleware(slowLog))
+	router.Use(controller.QueryLogMiddleware(queryLog))
 
-	v1 := router.Group("/api/v1")
+	var apiAccess []gin.HandlerFunc
+	if apiPolicy.Enabled() {
+		apiAccess = []gin.HandlerFunc{APIKeyMiddleware(apiPolicy), RedactionMiddleware(apiPolicy, logger)}
+	}
+	repoAccess := apiAccess
+	if archiver != nil {
+		repoAccess = append(append([]gin.HandlerFunc{}, apiAccess...), ColdStorageMiddleware(archiver))
+
+		// Cold storage state of a repository, and queueing its archive or restore
+		coldStorage := router.Group("/api/v1/repos/:name", apiAccess...)
+		coldStorage.GET("/archive", ColdStorageStatusHandler(archiver))
+		coldStorage.POST("/archive", ArchiveHandler(archiver))
+		coldStorage.POST("/restore", RestoreHandler(archiver))
+	}
+
+	v1 := router.Group("/api/v1", repoAccess...)
 	{
 		v1.POST("/buildIndex", repoController.BuildIndex)
+		v1.POST("/buildIndex/archive", repoController.BuildIndexFromArchive)
 		//v1.POST("/getFunctionsInFile", repoController.GetFunctionsInFile)
 		//v1.POST("/getFunctionDetails", repoController.GetFunctionDetails)
 		v1.POST("/functionDependencies", repoController.GetFunctionDependencies)
 		v1.POST("/processDirectory", repoController.ProcessDirectory)
 		v1.POST("/searchSimilarCode", repoController.SearchSimilarCode)
+		v1.POST("/searchText", repoController.SearchText)
+		v1.POST("/chunkNeighbors", repoController.GetChunkNeighbors)
+		v1.POST("/purgeChunks", repoController.PurgeChunks)
 
 		// Index building endpoints
 		v1.POST("/indexFile", repoController.IndexFile)
@@ -37,16 +71,83 @@ func SetupRouter(repoController *controller.RepoController, mcpServer *mcp.CodeG
 		v1.POST("/analyzeCode", repoController.AnalyzeCode)
 		v1.POST("/calculateZScore", repoController.CalculateZScore)
 
-		v1.GET("/health", func(c *gin.Context) {
-			c.JSON(200, gin.H{
-				"status": "healthy",
-			})
-		})
+		// Function history across indexed file versions (needs the code graph)
+		v1.GET("/repos/:name/functions/:id/history", repoController.GetFunctionHistory)
+
+		// Node routes; ?fields= limits the metadata keys of the nodes they read
+		nodes := v1.Group("/nodes", MetadataFieldsMiddleware())
+		// Source text of a Function or Class node, optionally with surrounding lines
+		nodes.GET("/:id/source", repoController.GetNodeSource)
+		// Details of many nodes (file path, range, metadata, optionally source) in one request
+		nodes.POST("/batch", repoController.GetNodesBatch)
+
+		// Tree-sitter query or comby-style pattern search over stored file contents
+		v1.POST("/repos/:name/structural-search", repoController.StructuralSearch)
+
+		// Functions of a repository most similar to a function node of another repository
+		v1.POST("/repos/:name/equivalents", repoController.FindEquivalents)
+
+		// Functions whose declared parameter and result types match a shape, e.g. taking a
+		// context.Context and returning an error
+		v1.POST("/repos/:name/functions/by-signature", repoController.FindFunctionsBySignature)
+
+		// Review context of a unified diff: changed functions, callers, tests, owners,
+		// naturalness and similar code per hunk
+		v1.POST("/repos/:name/review", repoController.Review)
+
+		// Conventions context of code about to be added to a file: imports, sibling
+		// signatures, directory naturalness and similar implementations
+		v1.POST("/repos/:name/codegen-context", repoController.CodegenContext)
+
+		// Projects group repositories configured in source.yaml
+		v1.GET("/projects", repoController.ListProjects)
+		v1.POST("/projects/:name/searchSimilarCode", repoController.SearchProjectCode)
+		v1.GET("/projects/:name/stats", repoController.GetProjectStats)
+		v1.GET("/repos/:name/stats", repoController.GetRepoStats)
+
+		// Public API snapshots of library repositories, taken on every index build
+		v1.GET("/repos/:name/api-surface", repoController.GetAPISurface)
+		v1.GET("/repos/:name/api-surface/versions", repoController.ListAPISurfaceVersions)
+		v1.GET("/repos/:name/api-surface/diff", repoController.DiffAPISurface)
+
+		// Per-function code cards stored by builds with index_building.code_cards, and search
+		// over their embeddings
+		v1.GET("/repos/:name/code-cards", repoController.GetCodeCards)
+		v1.POST("/repos/:name/code-cards/search", repoController.SearchCodeCards)
+
+		// Symbol names of a repository starting with ?prefix=, most referenced first
+		v1.GET("/repos/:name/complete", repoController.Complete)
+
+		// Structural (graph embedding) similarity endpoints
+		if graphEmbeddingController != nil {
+			v1.POST("/buildGraphEmbeddings", graphEmbeddingController.BuildGraphEmbeddings)
+			v1.POST("/searchStructurallySimilar", graphEmbeddingController.SearchStructurallySimilar)
+		}
+
+		// Agent sessions (retrieval endpoints record into the session named by X-Session-ID)
+		v1.POST("/session", sessionController.Open)
+		v1.GET("/session/:id/context", sessionController.Context)
+		v1.DELETE("/session/:id", sessionController.Close)
+
+		// Per-operation counts and durations of the slow query log
+		v1.GET("/metrics", MetricsHandler(slowLog))
+
+		// Popular and zero-result queries of the query log (logging.query_log)
+		v1.GET("/analytics/queries", repoController.GetQueryAnalytics)
+
+		// "healthy", or "degraded" with the optional services the server started without
+		v1.GET("/health", repoController.Health)
+
+		// Usage of the caller's API key in the current quota period
+		if apiPolicy.Enabled() {
+			v1.GET("/usage", UsageHandler())
+		}
 	}
 
 	// CodeAPI routes
 	if codeAPIController != nil {
-		codeAPI := router.Group("/codeapi/v1")
+		// ?fields= limits the metadata keys of the graph nodes every CodeAPI route reads
+		codeAPI := router.Group("/codeapi/v1", append(append([]gin.HandlerFunc{}, repoAccess...), MetadataFieldsMiddleware())...)
 		{
 			// Reader endpoints
 			codeAPI.GET("/repos", codeAPIController.ListRepos)
@@ -56,20 +157,34 @@ func SetupRouter(repoController *controller.RepoController, mcpServer *mcp.CodeG
 			codeAPI.POST("/functions", codeAPIController.ListFunctions)
 			codeAPI.POST("/classes/find", codeAPIController.FindClasses)
 			codeAPI.POST("/methods/find", codeAPIController.FindMethods)
+			codeAPI.POST("/symbols/search", codeAPIController.SearchSymbols)
+			codeAPI.POST("/strings/search", codeAPIController.SearchStrings)
+			codeAPI.POST("/nodes/at", codeAPIController.NodeAtPosition)
+			codeAPI.POST("/constants/find", codeAPIController.FindConstants)
 			codeAPI.POST("/class", codeAPIController.GetClass)
 			codeAPI.POST("/method", codeAPIController.GetMethod)
 			codeAPI.POST("/class/methods", codeAPIController.GetClassMethods)
 			codeAPI.POST("/class/fields", codeAPIController.GetClassFields)
 
-			// Analyzer endpoints
-			codeAPI.POST("/callgraph", codeAPIController.GetCallGraph)
-			codeAPI.POST("/callers", codeAPIController.GetCallers)
-			codeAPI.POST("/callees", codeAPIController.GetCallees)
-			codeAPI.POST("/data/dependents", codeAPIController.GetDataDependents)
-			codeAPI.POST("/data/sources", codeAPIController.GetDataSources)
-			codeAPI.POST("/impact", codeAPIController.GetImpact)
-			codeAPI.POST("/inheritance", codeAPIController.GetInheritanceTree)
-			codeAPI.POST("/field/accessors", codeAPIController.GetFieldAccessors)
+			// Analyzer endpoints; ?explain=true or ?explain=profile adds the Cypher query plans
+			analyzer := codeAPI.Group("", controller.QueryPlanMiddleware())
+			analyzer.POST("/callgraph", codeAPIController.GetCallGraph)
+			analyzer.POST("/callers", codeAPIController.GetCallers)
+			analyzer.POST("/callees", codeAPIController.GetCallees)
+			analyzer.POST("/data/dependents", codeAPIController.GetDataDependents)
+			analyzer.POST("/data/sources", codeAPIController.GetDataSources)
+			analyzer.POST("/impact", codeAPIController.GetImpact)
+			analyzer.POST("/inheritance", codeAPIController.GetInheritanceTree)
+			analyzer.POST("/field/accessors", codeAPIController.GetFieldAccessors)
+			codeAPI.POST("/impact/notify", codeAPIController.GetImpactNotifications)
+			codeAPI.POST("/duplicates", codeAPIController.FindDuplicateFunctions)
+			codeAPI.POST("/commits", codeAPIController.GetNodeCommits)
+			codeAPI.POST("/errors/resolve", codeAPIController.ResolveErrorLocation)
+			codeAPI.POST("/resolve-stacktrace", codeAPIController.ResolveStackTrace)
+
+			// Module endpoints
+			codeAPI.POST("/modules", codeAPIController.ListModules)
+			codeAPI.POST("/modules/summary", codeAPIController.GetModuleSummary)
 
 			// Raw Cypher endpoints
 			codeAPI.POST("/cypher", codeAPIController.ExecuteCypher)
@@ -88,9 +203,24 @@ func SetupRouter(repoController *controller.RepoController, mcpServer *mcp.CodeG
 	return router
 }
 
+// RequestIDMiddleware assigns each request an ID, honoring a valid X-Request-ID header from
+// the client. The ID is stored in the request context, so logs written downstream via
+// logging.FromContext carry it, and is echoed in the X-Request-ID response header.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(logging.RequestIDHeader)
+		if !logging.ValidRequestID(requestID) {
+			requestID = logging.NewRequestID()
+		}
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), requestID))
+		c.Header(logging.RequestIDHeader, requestID)
+		c.Next()
+	}
+}
+
 func LoggerMiddleware(logger *zap.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		logger.Info("HTTP Request",
+		logging.FromContext(c.Request.Context(), logger).Info("HTTP Request",
 			zap.String("method", c.Request.Method),
 			zap.String("path", c.Request.URL.Path),
 			zap.String("client_ip", c.ClientIP()),
@@ -99,18 +229,69 @@ func LoggerMiddleware(logger *zap.Logger) gin.HandlerFunc {
 	}
 }
 
+// LatencyBudgetMiddleware times each request against the latency budget of its route and
+// reports it to the slow query log. Requests that match no route are not counted.
+func LatencyBudgetMiddleware(slowLog *slowlog.Log) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if slowLog == nil {
+			c.Next()
+			return
+		}
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			return
+		}
+		operation := c.Request.Method + " " + route
+		slowLog.ObserveBudget(c.Request.Context(), slowlog.KindHTTP, operation, map[string]any{
+			"path":   c.Request.URL.Path,
+			"query":  c.Request.URL.RawQuery,
+			"status": c.Writer.Status(),
+		}, time.Since(start), slowLog.EndpointBudget(c.Request.Method, route))
+	}
+}
+
+// MetadataFieldsMiddleware applies the metadata projection of the "fields" query parameter
+// (e.g. fields=docstring,signature or fields=none) to the node reads of a request. Without
+// the parameter nodes keep all their metadata.
+func MetadataFieldsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if spec, ok := c.GetQuery("fields"); ok {
+			ctx := codegraph.WithMetadataFields(c.Request.Context(), codegraph.ParseMetadataFields(spec))
+			c.Request = c.Request.WithContext(ctx)
+		}
+		c.Next()
+	}
+}
+
+// MetricsHandler serves the counts and durations the slow query log aggregated per
+// operation, the operations with the most slow calls first
+func MetricsHandler(slowLog *slowlog.Log) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"slow_log": gin.H{
+				"enabled":      slowLog != nil,
+				"threshold_ms": slowLog.Threshold().Milliseconds(),
+				"operations":   slowLog.Snapshot(),
+			},
+		})
+	}
+}
+
 func CustomRecoveryMiddleware(logger *zap.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		defer func() {
 			if err := recover(); err != nil {
-				logger.Error("Panic recovered",
+				logging.FromContext(c.Request.Context(), logger).Error("Panic recovered",
 					zap.Any("error", err),
 					zap.String("stack", string(debug.Stack())),
 					zap.String("path", c.Request.URL.Path),
 					zap.String("method", c.Request.Method),
 				)
 				c.JSON(http.StatusInternalServerError, gin.H{
-					"error": "Internal server error",
+					"error":      "Internal server error",
+					"request_id": logging.RequestID(c.Request.Context()),
 				})
 				c.Abort()
 			}
@@ -1,15 +1,31 @@
 package init
 
 import (
+	"bot-go/internal/cache"
+	"bot-go/internal/chunk"
+	"bot-go/internal/cluster"
 	"bot-go/internal/config"
 	"bot-go/internal/controller"
 	"bot-go/internal/db"
+	"bot-go/internal/logging"
+	"bot-go/internal/model/schema"
+	"bot-go/internal/querylog"
 	"bot-go/internal/service"
+	"bot-go/internal/service/codecard"
 	"bot-go/internal/service/codegraph"
+	"bot-go/internal/service/completion"
+	"bot-go/internal/service/graphembed"
 	"bot-go/internal/service/ngram"
+	"bot-go/internal/service/textsearch"
 	"bot-go/internal/service/vector"
+	"bot-go/internal/slowlog"
+	"bot-go/internal/storage"
+	"bot-go/pkg/lsp"
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -25,34 +41,108 @@ type ServiceContainer struct {
 	EmbeddingModel vector.EmbeddingModel
 	ChunkService   *vector.CodeChunkService
 	NgramService   *ngram.NGramService
+	TextSearch     *textsearch.TextSearchService
 	RepoService    *service.RepoService
 
+	// Unavailable lists the optional subsystems that failed to start; the server runs
+	// degraded without them
+	Unavailable controller.UnavailableServices
+
+	// SlowLog times queries, searches and embedding calls; nil unless logging.slow_query.enabled
+	SlowLog *slowlog.Log
+
+	// QueryLog records the queries of search and graph endpoints; nil unless
+	// logging.query_log.enabled and MySQL is available
+	QueryLog *querylog.Recorder
+
+	// Structural embeddings (requires both CodeGraph and VectorDB)
+	GraphEmbeddingService *graphembed.GraphEmbeddingService
+
+	// Code cards (requires CodeGraph; embedded only with VectorDB)
+	CodeCardService *codecard.Service
+
+	// Symbol completion (requires CodeGraph)
+	CompletionService *completion.Service
+
+	// Cache is shared with the other instances using the same Redis; nil unless
+	// cache.redis_address is set and Redis answered at startup
+	Cache cache.Cache
+
+	// Storage keeps graph dumps, profile dumps and cold storage archives; nil with the local
+	// backend and neither storage.dir nor app.workdir
+	Storage storage.Store
+
 	// Processors
 	Processors []controller.FileProcessor
 
+	// Scheduler bounds processor executions shared by all builds, interactive requests first
+	Scheduler *controller.ProcessingScheduler
+
+	// Locker excludes concurrent builds of a repository and elects the leader running
+	// scheduled jobs; it spans instances with cluster.locking: mysql
+	Locker cluster.Locker
+
 	logger *zap.Logger
 }
 
 // ServiceInitOptions configures which services to initialize
 type ServiceInitOptions struct {
-	EnableMySQL      bool
-	EnableCodeGraph  bool
-	EnableEmbeddings bool
-	EnableNgram      bool
+	EnableMySQL       bool
+	EnableCodeGraph   bool
+	EnableEmbeddings  bool
+	EnableNgram       bool
+	EnableTextSearch  bool
 	EnableRepoService bool
+	EnableQueryLog    bool // record search and graph queries when logging.query_log is enabled
 
 	// For index building CLI mode
 	RequireMySQL bool // If true, fail if MySQL is not available
+
+	// Required lists optional subsystems (controller.Service*) whose failure aborts startup;
+	// others that fail are disabled and recorded in ServiceContainer.Unavailable
+	Required map[string]bool
 }
 
 // NewServiceContainer initializes all requested services based on options
 func NewServiceContainer(cfg *config.Config, opts ServiceInitOptions, logger *zap.Logger) (*ServiceContainer, error) {
+	maxProcessors := cfg.App.MaxConcurrentProcessors
+	if maxProcessors <= 0 {
+		maxProcessors = 4
+	}
 	container := &ServiceContainer{
-		logger: logger,
+		Scheduler:   controller.NewProcessingScheduler(maxProcessors),
+		SlowLog:     slowlog.FromConfig(cfg.Logging.SlowQuery, logger),
+		Unavailable: make(controller.UnavailableServices),
+		logger:      logger,
+	}
+	// degrade disables a subsystem that failed to start, or fails startup if it is required
+	degrade := func(service string, err error) error {
+		if opts.Required[service] {
+			return err
+		}
+		container.Unavailable[service] = err.Error()
+		logger.Warn("Service unavailable, starting in degraded mode", zap.String("service", service), zap.Error(err))
+		return nil
 	}
 
 	var err error
 
+	container.Storage, err = storage.New(cfg.Storage, cfg.App.WorkDir)
+	if err != nil {
+		return nil, fmt.Errorf("storage initialization failed: %w", err)
+	}
+
+	if cfg.Cache.Enabled() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		container.Cache, err = cache.New(ctx, cfg.Cache)
+		cancel()
+		if err != nil {
+			if err := degrade(controller.ServiceCache, err); err != nil {
+				return nil, fmt.Errorf("cache initialization failed (required): %w", err)
+			}
+		}
+	}
+
 	// Initialize MySQL if enabled
 	if opts.EnableMySQL && cfg.MySQL.Host != "" {
 		container.MySQLConn, err = initMySQL(cfg, logger, opts.RequireMySQL)
@@ -60,43 +150,116 @@ func NewServiceContainer(cfg *config.Config, opts ServiceInitOptions, logger *za
 			if opts.RequireMySQL {
 				return nil, fmt.Errorf("MySQL initialization failed (required): %w", err)
 			}
-			logger.Warn("MySQL initialization failed, continuing without it", zap.Error(err))
+			if err := degrade(controller.ServiceMySQL, err); err != nil {
+				return nil, fmt.Errorf("MySQL initialization failed (required): %w", err)
+			}
 		}
 	} else if opts.RequireMySQL {
 		return nil, fmt.Errorf("MySQL configuration is required but not provided")
 	}
 
+	var lockDB *sql.DB
+	if container.MySQLConn != nil {
+		lockDB = container.MySQLConn.GetDB()
+	}
+	if container.Locker, err = cluster.NewLocker(cfg.Cluster, lockDB); err != nil {
+		return nil, fmt.Errorf("cluster initialization failed: %w", err)
+	}
+
+	if opts.EnableQueryLog && cfg.Logging.QueryLog.Enabled {
+		if container.MySQLConn == nil {
+			logger.Warn("Query log disabled: it requires MySQL")
+		} else if container.QueryLog, err = querylog.New(container.MySQLConn.GetDB(), cfg.Logging.QueryLog, logger); err != nil {
+			logger.Warn("Query log disabled", zap.Error(err))
+		}
+	}
+
 	// Initialize RepoService if enabled (needed for LSP operations)
 	if opts.EnableRepoService {
 		container.RepoService = service.NewRepoService(cfg, logger)
 		logger.Info("RepoService initialized")
+
+		// Language servers start on first use; check now that their commands exist
+		if missing, available := lsp.CheckLanguageServers(cfg); len(missing) > 0 {
+			err := errors.New(lsp.MissingServersReason(missing))
+			if available > 0 {
+				logger.Warn("Some language servers are unavailable", zap.Error(err))
+			} else if err := degrade(controller.ServiceLSP, err); err != nil {
+				return nil, fmt.Errorf("LSP initialization failed (required): %w", err)
+			}
+		}
 	}
 
 	// Initialize CodeGraph if enabled
 	if opts.EnableCodeGraph {
-		container.CodeGraph, err = initCodeGraph(cfg, logger)
+		container.CodeGraph, err = initCodeGraph(cfg, logging.Module(logger, logging.ModuleCodeGraph))
 		if err != nil {
 			return nil, fmt.Errorf("CodeGraph initialization failed: %w", err)
 		}
+		container.CodeGraph.SetSlowLog(container.SlowLog)
+		if cfg.API.Enabled() {
+			container.CodeGraph.AccountUsage()
+		}
 		logger.Info("CodeGraph initialized")
+
+		if err := registerEnrichmentRelations(cfg.Enrichment.Relations); err != nil {
+			return nil, fmt.Errorf("enrichment.relations: %w", err)
+		}
 	}
 
 	// Initialize Vector DB and Embeddings if enabled
 	if opts.EnableEmbeddings {
-		container.VectorDB, container.EmbeddingModel, container.ChunkService, err = initVectorServices(cfg, logger)
+		container.VectorDB, container.EmbeddingModel, container.ChunkService, err = initVectorServices(cfg, container.SlowLog, container.Cache, logging.Module(logger, logging.ModuleVector))
 		if err != nil {
-			return nil, fmt.Errorf("Vector services initialization failed: %w", err)
+			if err := degrade(controller.ServiceVectorSearch, err); err != nil {
+				return nil, fmt.Errorf("Vector services initialization failed: %w", err)
+			}
+		} else {
+			logger.Info("Vector services initialized")
 		}
-		logger.Info("Vector services initialized")
+	}
+
+	// Graph embeddings need the code graph topology and a vector store
+	if container.CodeGraph != nil && container.VectorDB != nil {
+		container.GraphEmbeddingService = graphembed.NewGraphEmbeddingService(
+			container.CodeGraph, container.VectorDB, graphembed.DefaultNode2VecOptions(), logger)
+		logger.Info("Graph embedding service initialized")
+	}
+
+	// Code cards summarize the functions of the code graph, embedded when vector search is up
+	if container.CodeGraph != nil && cfg.IndexBuilding.CodeCards {
+		container.CodeCardService = codecard.NewService(
+			container.CodeGraph, container.VectorDB, container.EmbeddingModel, cfg.App.WorkDir, logger)
+		logger.Info("Code card service initialized", zap.Bool("embedded", container.CodeCardService.Searchable()))
+	}
+
+	// Completion indexes are loaded from the code graph on first use
+	if container.CodeGraph != nil {
+		container.CompletionService = completion.NewService(container.CodeGraph, logging.Module(logger, logging.ModuleCodeGraph))
 	}
 
 	// Initialize N-gram service if enabled
 	if opts.EnableNgram {
-		container.NgramService, err = initNgramService(logger)
+		container.NgramService, err = initNgramService(logging.Module(logger, logging.ModuleNgram))
 		if err != nil {
-			return nil, fmt.Errorf("N-gram service initialization failed: %w", err)
+			if err := degrade(controller.ServiceNgram, err); err != nil {
+				return nil, fmt.Errorf("N-gram service initialization failed: %w", err)
+			}
+		} else {
+			logger.Info("N-gram service initialized")
+		}
+	}
+
+	// Initialize text search if enabled
+	if opts.EnableTextSearch {
+		container.TextSearch, err = textsearch.NewTextSearchService(logging.Module(logger, logging.ModuleNgram))
+		if err != nil {
+			if err := degrade(controller.ServiceTextSearch, err); err != nil {
+				return nil, fmt.Errorf("Text search initialization failed: %w", err)
+			}
+		} else {
+			logger.Info("Text search service initialized")
 		}
-		logger.Info("N-gram service initialized")
 	}
 
 	return container, nil
@@ -111,14 +274,60 @@ func (sc *ServiceContainer) InitProcessors(cfg *config.Config) error {
 		if sc.RepoService == nil {
 			return fmt.Errorf("CodeGraph processor requires RepoService but it's not initialized")
 		}
-		codeGraphProcessor := controller.NewCodeGraphProcessor(cfg, sc.CodeGraph, sc.RepoService, sc.logger)
+		codeGraphProcessor := controller.NewCodeGraphProcessor(cfg, sc.CodeGraph, sc.RepoService, logging.Module(sc.logger, logging.ModuleParse))
 		processors = append(processors, codeGraphProcessor)
 		sc.logger.Info("CodeGraph processor added to pipeline")
 	}
 
+	// Add Popularity processor after CodeGraph, whose resolved calls it counts; its finalizer
+	// runs before that of the completion processor, which ranks by the counts
+	if sc.CodeGraph != nil {
+		popularityProcessor := controller.NewPopularityProcessor(sc.CodeGraph, logging.Module(sc.logger, logging.ModuleCodeGraph))
+		processors = append(processors, popularityProcessor)
+		sc.logger.Info("Popularity processor added to pipeline")
+	}
+
+	// Add Blame processor after CodeGraph, whose nodes it annotates
+	if sc.CodeGraph != nil && cfg.GitAnalysis.Blame {
+		blameProcessor := controller.NewBlameProcessor(sc.CodeGraph, logging.Module(sc.logger, logging.ModuleParse))
+		processors = append(processors, blameProcessor)
+		sc.logger.Info("Blame processor added to pipeline")
+	}
+
+	// Add Commits processor after CodeGraph, whose nodes it links to commits
+	if sc.CodeGraph != nil && cfg.GitAnalysis.Commits {
+		commitProcessor := controller.NewCommitProcessor(sc.CodeGraph, cfg.GitAnalysis, logging.Module(sc.logger, logging.ModuleParse))
+		processors = append(processors, commitProcessor)
+		sc.logger.Info("Commits processor added to pipeline")
+	}
+
+	// Add String literal processor after CodeGraph, whose functions it links literals to
+	if sc.CodeGraph != nil && cfg.CodeGraph.IndexStringLiterals {
+		if err := sc.CodeGraph.EnsureStringLiteralIndexes(context.Background()); err != nil {
+			sc.logger.Warn("String literal indexes unavailable", zap.Error(err))
+		}
+		stringLiteralProcessor := controller.NewStringLiteralProcessor(sc.CodeGraph, cfg.CodeGraph.StringLiteralMinLength, logging.Module(sc.logger, logging.ModuleParse))
+		processors = append(processors, stringLiteralProcessor)
+		sc.logger.Info("String literal processor added to pipeline")
+	}
+
+	// Add Code card processor after CodeGraph, whose resolved calls it summarizes
+	if sc.CodeCardService != nil {
+		codeCardProcessor := controller.NewCodeCardProcessor(sc.CodeCardService, logging.Module(sc.logger, logging.ModuleVector))
+		processors = append(processors, codeCardProcessor)
+		sc.logger.Info("Code card processor added to pipeline")
+	}
+
+	// Add Completion processor after CodeGraph, whose resolved calls rank the completions
+	if sc.CompletionService != nil {
+		completionProcessor := controller.NewCompletionProcessor(sc.CompletionService, logging.Module(sc.logger, logging.ModuleCodeGraph))
+		processors = append(processors, completionProcessor)
+		sc.logger.Info("Completion processor added to pipeline")
+	}
+
 	// Add Embedding processor if available
 	if sc.ChunkService != nil {
-		embeddingProcessor := controller.NewEmbeddingProcessor(sc.ChunkService, sc.logger)
+		embeddingProcessor := controller.NewEmbeddingProcessor(sc.ChunkService, logging.Module(sc.logger, logging.ModuleVector))
 		processors = append(processors, embeddingProcessor)
 		sc.logger.Info("Embedding processor added to pipeline")
 	}
@@ -127,17 +336,27 @@ func (sc *ServiceContainer) InitProcessors(cfg *config.Config) error {
 	if sc.NgramService != nil {
 		n := 3 // trigrams
 		override := false
-		ngramProcessor := controller.NewNGramProcessor(sc.NgramService, n, override, sc.logger)
+		ngramProcessor := controller.NewNGramProcessor(sc.NgramService, n, override, logging.Module(sc.logger, logging.ModuleNgram))
 		processors = append(processors, ngramProcessor)
 		sc.logger.Info("N-gram processor added to pipeline")
 	}
 
+	// Add Text search processor if enabled; servers search the indexes without building them
+	if sc.TextSearch != nil && cfg.IndexBuilding.EnableTextSearch {
+		textSearchProcessor := controller.NewTextSearchProcessor(sc.TextSearch, logging.Module(sc.logger, logging.ModuleNgram))
+		processors = append(processors, textSearchProcessor)
+		sc.logger.Info("Text search processor added to pipeline")
+	}
+
 	sc.Processors = processors
 	return nil
 }
 
 // Close cleans up all resources
 func (sc *ServiceContainer) Close(ctx context.Context) {
+	// Flush the query log while MySQL is still open
+	sc.QueryLog.Close()
+
 	if sc.MySQLConn != nil {
 		sc.MySQLConn.Close()
 		sc.logger.Info("MySQL connection closed")
@@ -152,6 +371,10 @@ func (sc *ServiceContainer) Close(ctx context.Context) {
 		sc.VectorDB.Close()
 		sc.logger.Info("Vector DB closed")
 	}
+
+	if closer, ok := sc.Cache.(interface{ Close() }); ok {
+		closer.Close()
+	}
 }
 
 // initMySQL initializes MySQL connection and ensures database exists
@@ -192,24 +415,43 @@ func initCodeGraph(cfg *config.Config, logger *zap.Logger) (*codegraph.CodeGraph
 		return nil, fmt.Errorf("failed to initialize CodeGraph: %w", err)
 	}
 
+	// Symbol search needs the full-text index; the rest of the graph works without it
+	if err := codeGraph.EnsureSymbolSearchIndex(context.Background()); err != nil {
+		logger.Warn("Symbol search index unavailable", zap.Error(err))
+	}
+	// Without these indexes re-indexing still works, but keyed upserts scan by label
+	if err := codeGraph.EnsureNodeKeyIndexes(context.Background()); err != nil {
+		logger.Warn("Node key indexes unavailable", zap.Error(err))
+	}
+
 	return codeGraph, nil
 }
 
 // initVectorServices initializes Vector DB, Embedding model, and CodeChunkService
-func initVectorServices(cfg *config.Config, logger *zap.Logger) (vector.VectorDatabase, vector.EmbeddingModel, *vector.CodeChunkService, error) {
+func initVectorServices(cfg *config.Config, slowLog *slowlog.Log, shared cache.Cache, logger *zap.Logger) (vector.VectorDatabase, vector.EmbeddingModel, *vector.CodeChunkService, error) {
 	// Validate configuration
 	if cfg.Qdrant.Host == "" || cfg.Ollama.URL == "" {
 		return nil, nil, nil, fmt.Errorf("Qdrant and Ollama configuration required for vector services")
 	}
 
 	// Initialize Qdrant
-	vectorDB, err := vector.NewQdrantDatabase(cfg.Qdrant.Host, cfg.Qdrant.Port, cfg.Qdrant.APIKey, logger)
+	qdrantDB, err := vector.NewQdrantDatabase(cfg.Qdrant.Host, cfg.Qdrant.Port, cfg.Qdrant.APIKey, logger)
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("failed to initialize Qdrant database: %w", err)
 	}
+	vectorDB := vector.WithSlowLog(vector.WithFaults(qdrantDB), slowLog)
+
+	// Collections reduced by --reduce-embeddings keep receiving full-size vectors from the
+	// embedding model
+	if cfg.App.WorkDir != "" {
+		if vectorDB, err = vector.WithReductions(vectorDB, vector.ReductionDir(cfg.App.WorkDir)); err != nil {
+			qdrantDB.Close()
+			return nil, nil, nil, fmt.Errorf("failed to load embedding reductions: %w", err)
+		}
+	}
 
 	// Initialize Ollama embedding model
-	embeddingModel, err := vector.NewOllamaEmbedding(vector.OllamaEmbeddingConfig{
+	ollama, err := vector.NewOllamaEmbedding(vector.OllamaEmbeddingConfig{
 		APIURL:    cfg.Ollama.URL,
 		APIKey:    cfg.Ollama.APIKey,
 		Model:     cfg.Ollama.Model,
@@ -219,6 +461,17 @@ func initVectorServices(cfg *config.Config, logger *zap.Logger) (vector.VectorDa
 		vectorDB.Close()
 		return nil, nil, nil, fmt.Errorf("failed to initialize Ollama embedding model: %w", err)
 	}
+	embeddingModel := vector.EmbeddingWithSlowLog(vector.EmbeddingWithFaults(ollama), slowLog)
+	if cfg.API.Enabled() {
+		embeddingModel = vector.EmbeddingWithUsage(embeddingModel)
+	}
+	// Cached embeddings are not charged to API clients
+	if embeddingTTL := time.Duration(cfg.Cache.EmbeddingTTLHours) * time.Hour; embeddingTTL >= 0 {
+		if embeddingTTL == 0 {
+			embeddingTTL = 7 * 24 * time.Hour
+		}
+		embeddingModel = vector.EmbeddingWithCache(embeddingModel, shared, embeddingTTL, logger)
+	}
 
 	// Set default thresholds
 	minConditionalLines := cfg.Chunking.MinConditionalLines
@@ -250,6 +503,28 @@ func initVectorServices(cfg *config.Config, logger *zap.Logger) (vector.VectorDa
 		numFileThreads,
 		logger,
 	)
+	if cfg.Chunking.TTLHours > 0 {
+		chunkService.SetDefaultTTL(time.Duration(cfg.Chunking.TTLHours) * time.Hour)
+	}
+
+	// Boilerplate left out of embeddings, by default and per repository
+	stop, err := chunk.NewStopSet(cfg.Chunking.StopChunks)
+	if err != nil {
+		vectorDB.Close()
+		return nil, nil, nil, fmt.Errorf("invalid chunking.stop_chunks: %w", err)
+	}
+	chunkService.SetDefaultStopChunks(stop)
+	for _, repo := range cfg.Source.Repositories {
+		if repo.StopChunks == nil {
+			continue
+		}
+		stop, err := chunk.NewStopSet(repo.StopChunks)
+		if err != nil {
+			vectorDB.Close()
+			return nil, nil, nil, fmt.Errorf("invalid stop_chunks of repository %s: %w", repo.Name, err)
+		}
+		chunkService.SetCollectionStopChunks(repo.Name, stop)
+	}
 
 	logger.Info("Vector services initialized",
 		zap.String("qdrant_host", cfg.Qdrant.Host),
@@ -272,6 +547,24 @@ func initNgramService(logger *zap.Logger) (*ngram.NGramService, error) {
 	return ngramService, nil
 }
 
+// registerEnrichmentRelations registers the relation types external tools may create in the
+// schema registry
+func registerEnrichmentRelations(relations []config.EnrichmentRelation) error {
+	for _, r := range relations {
+		external := schema.ExternalRelation{Type: schema.RelationType(r.Type)}
+		for _, label := range r.From {
+			external.From = append(external.From, schema.NodeLabel(label))
+		}
+		for _, label := range r.To {
+			external.To = append(external.To, schema.NodeLabel(label))
+		}
+		if err := schema.RegisterExternalRelation(external, r.Description); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // GetIndexBuildingOptions returns ServiceInitOptions configured for index building CLI
 func GetIndexBuildingOptions(cfg *config.Config) ServiceInitOptions {
 	return ServiceInitOptions{
@@ -280,7 +573,14 @@ func GetIndexBuildingOptions(cfg *config.Config) ServiceInitOptions {
 		EnableCodeGraph:   cfg.IndexBuilding.EnableCodeGraph,
 		EnableEmbeddings:  cfg.IndexBuilding.EnableEmbeddings,
 		EnableNgram:       cfg.IndexBuilding.EnableNgram,
+		EnableTextSearch:  cfg.IndexBuilding.EnableTextSearch,
 		EnableRepoService: cfg.IndexBuilding.EnableCodeGraph, // Only needed for CodeGraph
+		// A build must not silently skip a processor it was configured to run
+		Required: map[string]bool{
+			controller.ServiceVectorSearch: true,
+			controller.ServiceNgram:        true,
+			controller.ServiceTextSearch:   true,
+		},
 	}
 }
 
@@ -292,6 +592,18 @@ func GetServerModeOptions(cfg *config.Config) ServiceInitOptions {
 		EnableCodeGraph:   cfg.App.CodeGraph,
 		EnableEmbeddings:  cfg.Qdrant.Host != "" && cfg.Ollama.URL != "",
 		EnableNgram:       true, // Always try to enable N-gram in server mode
+		EnableTextSearch:  true, // Serves the indexes saved by builds
 		EnableRepoService: true, // Always needed in server mode
+		EnableQueryLog:    true,
+		Required:          requiredServices(cfg.App.RequiredServices),
+	}
+}
+
+// requiredServices turns app.required_services into ServiceInitOptions.Required
+func requiredServices(names []string) map[string]bool {
+	required := make(map[string]bool, len(names))
+	for _, name := range names {
+		required[name] = true
 	}
+	return required
 }
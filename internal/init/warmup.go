@@ -0,0 +1,126 @@
+package init
+
+import (
+	"bot-go/internal/config"
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// defaultWarmupTimeout bounds the warmup phase when warmup.timeout_seconds is unset
+const defaultWarmupTimeout = 120 * time.Second
+
+// Warmup preloads n-gram models and text indexes, pings the embedding provider, primes the Neo4j indexes
+// and optionally starts language servers for the active repositories, so the first request
+// after startup is not a cold start. Steps run concurrently; failures are logged and never
+// stop the server from starting. Warmup returns when all steps finish or the timeout passes.
+func (c *ServiceContainer) Warmup(ctx context.Context, cfg *config.Config) {
+	if !cfg.Warmup.Enabled {
+		return
+	}
+
+	timeout := time.Duration(cfg.Warmup.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = defaultWarmupTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	repoNames := warmupRepositories(cfg)
+	c.logger.Info("Starting warmup", zap.Strings("repositories", repoNames))
+	started := time.Now()
+
+	var wg sync.WaitGroup
+	step := func(name string, fn func(ctx context.Context) error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stepStarted := time.Now()
+			if err := fn(ctx); err != nil {
+				c.logger.Warn("Warmup step failed",
+					zap.String("step", name),
+					zap.Duration("elapsed", time.Since(stepStarted)),
+					zap.Error(err))
+				return
+			}
+			c.logger.Info("Warmup step done",
+				zap.String("step", name),
+				zap.Duration("elapsed", time.Since(stepStarted)))
+		}()
+	}
+
+	if c.NgramService != nil {
+		step("ngram", func(ctx context.Context) error {
+			for _, repoName := range repoNames {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				if _, err := c.NgramService.LoadPersistedModel(repoName); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	if c.TextSearch != nil {
+		step("text_search", func(ctx context.Context) error {
+			for _, repoName := range repoNames {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				if _, err := c.TextSearch.LoadPersistedIndex(repoName); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	if c.EmbeddingModel != nil {
+		step("embedding", func(ctx context.Context) error {
+			_, err := c.EmbeddingModel.GenerateEmbedding(ctx, "warmup")
+			return err
+		})
+	}
+	if c.CodeGraph != nil {
+		step("neo4j", func(ctx context.Context) error {
+			return c.CodeGraph.WarmupIndexes(ctx, repoNames)
+		})
+	}
+	if cfg.Warmup.LSP && c.RepoService != nil {
+		lspService := c.RepoService.GetLspService()
+		for _, repoName := range repoNames {
+			step("lsp:"+repoName, func(ctx context.Context) error {
+				return lspService.Warmup(repoName)
+			})
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		c.logger.Info("Warmup complete", zap.Duration("elapsed", time.Since(started)))
+	case <-ctx.Done():
+		// Steps that ignore the context (language server startup) keep running in the background
+		c.logger.Warn("Warmup timed out, starting anyway", zap.Duration("timeout", timeout))
+	}
+}
+
+// warmupRepositories returns the configured active repositories, or every enabled repository
+func warmupRepositories(cfg *config.Config) []string {
+	if len(cfg.Warmup.Repositories) > 0 {
+		return cfg.Warmup.Repositories
+	}
+	var names []string
+	for _, repo := range cfg.Source.Repositories {
+		if !repo.Disabled {
+			names = append(names, repo.Name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,176 @@
+// Package logging builds zap loggers from the logging section of app.yaml:
+// encoding, output sinks with optional rotation, sampling, and per-module levels.
+package logging
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"bot-go/internal/config"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Module names used when wiring services
+const (
+	ModuleParse     = "parse"
+	ModuleCodeGraph = "codegraph"
+	ModuleVector    = "vector"
+	ModuleNgram     = "ngram"
+	ModuleHandler   = "handler"
+)
+
+// Factory creates the root logger and per-module loggers that share its encoder and sinks
+type Factory struct {
+	cfg     config.LoggingConfig
+	encoder zapcore.Encoder
+	sink    zapcore.WriteSyncer
+	level   zapcore.Level
+	root    *zap.Logger
+}
+
+var (
+	defaultFactory   *Factory
+	defaultFactoryMu sync.RWMutex
+)
+
+// Setup builds a Factory from the config, installs it as the default used by Module,
+// and returns the root logger
+func Setup(cfg config.LoggingConfig) (*zap.Logger, error) {
+	factory, err := NewFactory(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defaultFactoryMu.Lock()
+	defaultFactory = factory
+	defaultFactoryMu.Unlock()
+	return factory.Logger(), nil
+}
+
+// NewFactory validates the config and opens the configured sinks
+func NewFactory(cfg config.LoggingConfig) (*Factory, error) {
+	level, err := parseLevel(cfg.Level, zapcore.InfoLevel)
+	if err != nil {
+		return nil, err
+	}
+	for module, moduleLevel := range cfg.Modules {
+		if _, err := parseLevel(moduleLevel, level); err != nil {
+			return nil, fmt.Errorf("logging.modules.%s: %w", module, err)
+		}
+	}
+
+	encoderCfg := zap.NewProductionEncoderConfig()
+	encoderCfg.EncodeTime = zapcore.ISO8601TimeEncoder
+	var encoder zapcore.Encoder
+	switch strings.ToLower(cfg.Encoding) {
+	case "", "json":
+		encoder = zapcore.NewJSONEncoder(encoderCfg)
+	case "console":
+		encoderCfg.EncodeLevel = zapcore.CapitalLevelEncoder
+		encoder = zapcore.NewConsoleEncoder(encoderCfg)
+	default:
+		return nil, fmt.Errorf("unsupported log encoding: %s (expected json or console)", cfg.Encoding)
+	}
+
+	sink, err := openSinks(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &Factory{
+		cfg:     cfg,
+		encoder: encoder,
+		sink:    sink,
+		level:   level,
+	}
+	f.root = zap.New(f.newCore(level), zap.AddCaller(), zap.ErrorOutput(zapcore.Lock(os.Stderr)))
+	return f, nil
+}
+
+// Logger returns the root logger
+func (f *Factory) Logger() *zap.Logger {
+	return f.root
+}
+
+// Module returns a named logger whose level is taken from logging.modules, falling back
+// to the default level
+func (f *Factory) Module(name string) *zap.Logger {
+	level := f.level
+	if moduleLevel, ok := f.cfg.Modules[name]; ok {
+		// Validated in NewFactory
+		level, _ = parseLevel(moduleLevel, f.level)
+	}
+	return f.root.WithOptions(zap.WrapCore(func(zapcore.Core) zapcore.Core {
+		return f.newCore(level)
+	})).Named(name)
+}
+
+// Module returns the logger for a module from the default Factory. If Setup has not been
+// called (e.g. in tests), it returns base named after the module.
+func Module(base *zap.Logger, name string) *zap.Logger {
+	defaultFactoryMu.RLock()
+	factory := defaultFactory
+	defaultFactoryMu.RUnlock()
+	if factory == nil {
+		return base.Named(name)
+	}
+	return factory.Module(name)
+}
+
+func (f *Factory) newCore(level zapcore.Level) zapcore.Core {
+	core := zapcore.NewCore(f.encoder, f.sink, level)
+	if f.cfg.Sampling != nil && f.cfg.Sampling.Thereafter > 0 {
+		core = zapcore.NewSamplerWithOptions(core, time.Second, f.cfg.Sampling.Initial, f.cfg.Sampling.Thereafter)
+	}
+	return core
+}
+
+func openSinks(cfg config.LoggingConfig) (zapcore.WriteSyncer, error) {
+	outputs := cfg.Outputs
+	if len(outputs) == 0 {
+		outputs = []string{"stdout", "all.log"}
+	}
+
+	syncers := make([]zapcore.WriteSyncer, 0, len(outputs))
+	for _, output := range outputs {
+		switch output {
+		case "stdout":
+			syncers = append(syncers, zapcore.Lock(os.Stdout))
+		case "stderr":
+			syncers = append(syncers, zapcore.Lock(os.Stderr))
+		default:
+			if cfg.Rotation.MaxSizeMB > 0 {
+				syncers = append(syncers, zapcore.AddSync(&lumberjack.Logger{
+					Filename:   output,
+					MaxSize:    cfg.Rotation.MaxSizeMB,
+					MaxBackups: cfg.Rotation.MaxBackups,
+					MaxAge:     cfg.Rotation.MaxAgeDays,
+					Compress:   cfg.Rotation.Compress,
+				}))
+				continue
+			}
+			file, err := os.OpenFile(output, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open log output %s: %w", output, err)
+			}
+			syncers = append(syncers, zapcore.Lock(file))
+		}
+	}
+	return zapcore.NewMultiWriteSyncer(syncers...), nil
+}
+
+func parseLevel(value string, fallback zapcore.Level) (zapcore.Level, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	var level zapcore.Level
+	if err := level.UnmarshalText([]byte(strings.ToLower(value))); err != nil {
+		return fallback, fmt.Errorf("invalid log level %q", value)
+	}
+	return level, nil
+}
@@ -0,0 +1,79 @@
+package logging
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"bot-go/internal/config"
+)
+
+func TestFactory_ModuleLevels(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "test.log")
+	factory, err := NewFactory(config.LoggingConfig{
+		Level:   "info",
+		Outputs: []string{logFile},
+		Modules: map[string]string{ModuleParse: "debug", ModuleVector: "error"},
+	})
+	if err != nil {
+		t.Fatalf("NewFactory: %v", err)
+	}
+
+	factory.Module(ModuleParse).Debug("parse-debug")
+	factory.Module(ModuleVector).Warn("vector-warn")
+	factory.Module(ModuleHandler).Info("handler-info")
+	factory.Logger().Debug("root-debug")
+	factory.Logger().Sync()
+
+	data, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("read log: %v", err)
+	}
+	out := string(data)
+	for msg, want := range map[string]bool{
+		"parse-debug":  true,
+		"vector-warn":  false,
+		"handler-info": true,
+		"root-debug":   false,
+	} {
+		if got := strings.Contains(out, msg); got != want {
+			t.Errorf("%s: logged=%v, want %v", msg, got, want)
+		}
+	}
+	if !strings.Contains(out, `"logger":"parse"`) {
+		t.Errorf("expected module name in output, got %s", out)
+	}
+}
+
+func TestNewFactory_InvalidConfig(t *testing.T) {
+	if _, err := NewFactory(config.LoggingConfig{Level: "loud"}); err == nil {
+		t.Error("expected error for invalid level")
+	}
+	if _, err := NewFactory(config.LoggingConfig{Modules: map[string]string{"parse": "loud"}}); err == nil {
+		t.Error("expected error for invalid module level")
+	}
+	if _, err := NewFactory(config.LoggingConfig{Encoding: "xml"}); err == nil {
+		t.Error("expected error for invalid encoding")
+	}
+}
+
+func TestRequestIDContext(t *testing.T) {
+	ctx := context.Background()
+	if RequestID(ctx) != "" {
+		t.Fatal("expected no request ID in empty context")
+	}
+	id := NewRequestID()
+	if !ValidRequestID(id) {
+		t.Fatalf("generated request ID %q is not valid", id)
+	}
+	if got := RequestID(WithRequestID(ctx, id)); got != id {
+		t.Errorf("expected %q, got %q", id, got)
+	}
+	for _, bad := range []string{"", "has space", "line\nbreak", strings.Repeat("x", maxRequestIDLength+1)} {
+		if ValidRequestID(bad) {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
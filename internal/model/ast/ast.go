@@ -20,6 +20,9 @@ const (
 	NodeTypeFileNumber   NodeType = 11
 	NodeTypeLoop         NodeType = 12
 	NodeTypeImport       NodeType = 13
+	NodeTypeAnnotation   NodeType = 14
+	NodeTypeConstant     NodeType = 15
+	NodeTypeEnum         NodeType = 16
 )
 
 type NodeID int64
@@ -36,6 +39,7 @@ type Node struct {
 	Range    base.Range     `json:"range"`
 	Version  int32          `json:"version,omitempty"`
 	ScopeID  NodeID         `json:"scope_id,omitempty"`
+	Key      string         `json:"key,omitempty"` // Stable identity across re-indexing; empty for nodes merged by ID
 	MetaData map[string]any `json:"metadata,omitempty"`
 }
 
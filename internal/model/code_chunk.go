@@ -24,6 +24,9 @@ type CodeChunk struct {
 	// FileID from MySQL file_versions table (shared with CodeGraph)
 	FileID int32 `json:"file_id"`
 
+	// CommitSHA of the indexed file version (empty for ephemeral files)
+	CommitSHA string `json:"commit_sha,omitempty"`
+
 	// Hierarchical metadata
 	ChunkType ChunkType `json:"chunk_type"`
 	Level     int       `json:"level"` // 1=file, 2=class, 3=function, 4=block
@@ -51,6 +54,19 @@ type CodeChunk struct {
 
 	// Additional metadata
 	Metadata map[string]interface{} `json:"metadata,omitempty"`
+
+	// Lifecycle (unix seconds). ExpiresAt is zero for chunks without a TTL.
+	IndexedAt int64 `json:"indexed_at,omitempty"`
+	ExpiresAt int64 `json:"expires_at,omitempty"`
+}
+
+// ChunkNeighborhood describes the chunks surrounding a given chunk in its file
+type ChunkNeighborhood struct {
+	Chunk    *CodeChunk   `json:"chunk"`
+	Parent   *CodeChunk   `json:"parent,omitempty"`   // enclosing class/file chunk
+	Siblings []*CodeChunk `json:"siblings"`           // chunks sharing the same parent
+	Previous *CodeChunk   `json:"previous,omitempty"` // nearest chunk ending before this one starts
+	Next     *CodeChunk   `json:"next,omitempty"`     // nearest chunk starting after this one ends
 }
 
 // NewCodeChunk creates a new code chunk with basic information
@@ -75,6 +91,12 @@ func (c *CodeChunk) WithFileID(fileID int32) *CodeChunk {
 	return c
 }
 
+// WithCommitSHA sets the commit the chunk's file was indexed from
+func (c *CodeChunk) WithCommitSHA(commitSHA string) *CodeChunk {
+	c.CommitSHA = commitSHA
+	return c
+}
+
 // WithParent sets the parent chunk ID
 func (c *CodeChunk) WithParent(parentID string) *CodeChunk {
 	c.ParentID = parentID
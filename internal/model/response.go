@@ -1,7 +1,9 @@
 package model
 
 import (
+	"bot-go/internal/techstack"
 	"bot-go/pkg/lsp/base"
+	"time"
 )
 
 type ProcessRepoResponse struct {
@@ -261,6 +263,7 @@ func FunctionDefinitionToKey(def FunctionDefinition) string {
 type ProcessDirectoryRequest struct {
 	RepoName       string `json:"repo_name" binding:"required"`
 	CollectionName string `json:"collection_name"`
+	TTLHours       int    `json:"ttl_hours,omitempty"` // Chunks expire this long after indexing (0 = chunking.ttl_hours)
 }
 
 type ProcessDirectoryResponse struct {
@@ -271,6 +274,22 @@ type ProcessDirectoryResponse struct {
 	Message        string `json:"message,omitempty"`
 }
 
+// PurgeChunksRequest deletes the chunks of a collection matching every given filter. At
+// least one filter is required; use a collection delete to drop everything.
+type PurgeChunksRequest struct {
+	CollectionName string     `json:"collection_name" binding:"required"`
+	PathPrefix     string     `json:"path_prefix,omitempty"`
+	Language       string     `json:"language,omitempty"`
+	ChunkTypes     []string   `json:"chunk_types,omitempty"`
+	IndexedBefore  *time.Time `json:"indexed_before,omitempty"` // RFC 3339
+	Expired        bool       `json:"expired,omitempty"`        // Only chunks whose TTL has passed
+}
+
+type PurgeChunksResponse struct {
+	CollectionName string `json:"collection_name"`
+	Deleted        int    `json:"deleted"`
+}
+
 type SearchSimilarCodeRequest struct {
 	RepoName       string `json:"repo_name" binding:"required"`
 	CollectionName string `json:"collection_name"`
@@ -278,6 +297,11 @@ type SearchSimilarCodeRequest struct {
 	Language       string `json:"language" binding:"required"`
 	Limit          int    `json:"limit"`
 	IncludeCode    bool   `json:"include_code"`
+	DisableDedupe  bool   `json:"disable_dedupe"` // Return both copies of dual-embedded (nocontext) chunks
+	SeenMode       string `json:"seen_mode"`      // exclude or deprioritize chunks already returned in the session
+	Explain        bool   `json:"explain"`        // Attach an explanation of the ranking to every result
+	ExactQuery     string `json:"exact_query"`    // Also search the file contents for this text and merge the matches into the results
+	ExactRegex     bool   `json:"exact_regex"`    // exact_query is a regular expression
 }
 
 type SearchSimilarCodeResponse struct {
@@ -285,8 +309,18 @@ type SearchSimilarCodeResponse struct {
 	CollectionName string              `json:"collection_name"`
 	Query          QueryInfo           `json:"query"`
 	Results        []SimilarCodeResult `json:"results"`
+	Degraded       bool                `json:"degraded,omitempty"` // Embeddings were unavailable; results come from n-gram overlap
 	Success        bool                `json:"success"`
 	Message        string              `json:"message,omitempty"`
+	// Diagnostics explain an empty result from the status of the indexes searched
+	Diagnostics []SearchDiagnostic `json:"diagnostics,omitempty"`
+}
+
+// SearchDiagnostic is a hint on why a search found nothing, such as a missing collection or
+// a language filter no indexed file passes
+type SearchDiagnostic struct {
+	Code    string `json:"code"` // e.g. "collection_missing", "language_filtered", "stale_index"
+	Message string `json:"message"`
 }
 
 type QueryInfo struct {
@@ -297,10 +331,82 @@ type QueryInfo struct {
 }
 
 type SimilarCodeResult struct {
-	Chunk           *CodeChunk `json:"chunk"`
-	Score           float32    `json:"score"`
-	QueryChunkIndex int        `json:"query_chunk_index"` // Index of the input chunk that matched this result (0-based)
-	Code            string     `json:"code,omitempty"`    // Actual code content from file (if include_code is true)
+	Chunk           *CodeChunk         `json:"chunk"`
+	Score           float32            `json:"score"`
+	QueryChunkIndex int                `json:"query_chunk_index"`           // Index of the input chunk that matched this result (0-based)
+	QueryChunkName  string             `json:"query_chunk_name,omitempty"`  // Name of the input chunk that matched (function/class name)
+	Code            string             `json:"code,omitempty"`              // Actual code content from file (if include_code is true)
+	Highlight       *MatchHighlight    `json:"highlight,omitempty"`         // Regions of the result that overlap the query chunk
+	Explanation     *SearchExplanation `json:"explanation,omitempty"`       // Why the result ranked where it did (if explain is true)
+	ExactMatchLines []int              `json:"exact_match_lines,omitempty"` // Lines (0-based) matching exact_query
+}
+
+// FindEquivalentsResponse lists the functions of a repository most similar to a function of
+// another repository
+type FindEquivalentsResponse struct {
+	RepoName string               `json:"repo_name"` // Repository searched
+	Query    EquivalentQuery      `json:"query"`
+	Results  []EquivalentFunction `json:"results"`
+}
+
+// EquivalentQuery is the function equivalents were searched for
+type EquivalentQuery struct {
+	NodeID    int64      `json:"node_id"`
+	RepoName  string     `json:"repo_name"`
+	Path      string     `json:"path"`
+	Name      string     `json:"name"`
+	Signature string     `json:"signature,omitempty"`
+	Range     base.Range `json:"range"`
+}
+
+// EquivalentFunction is a function similar to the query function
+type EquivalentFunction struct {
+	Chunk          *CodeChunk `json:"chunk"`
+	Score          float64    `json:"score"`           // Blend of the two scores below, by signature_weight
+	EmbeddingScore float32    `json:"embedding_score"` // Vector similarity
+	SignatureScore float64    `json:"signature_score"` // Similarity of names, parameter counts and signature words (0-1)
+}
+
+// SearchExplanation breaks down the signals behind a similar code result
+type SearchExplanation struct {
+	EmbeddingScore    *float32 `json:"embedding_score,omitempty"`  // Vector similarity (absent for lexical fallback results)
+	SharedIdentifiers []string `json:"shared_identifiers"`         // Identifiers found in both the query chunk and the result
+	IdentifierOverlap float64  `json:"identifier_overlap"`         // Share of the query chunk's identifiers found in the result
+	NGramOverlap      *float64 `json:"ngram_overlap,omitempty"`    // Share of the snippet's n-grams found in the result's file (needs an n-gram corpus)
+	ScoreMultiplier   float64  `json:"score_multiplier,omitempty"` // Factor applied by the repository's scoring rules (absent when 1)
+	ContextProximity  string   `json:"context_proximity"`          // "seen" (returned earlier in the session), "same_file" (shares a file with the session's working set), "graph" (within graph_distance of a node returned earlier) or "none"
+	GraphDistance     *int     `json:"graph_distance,omitempty"`   // CONTAINS and CALLS_FUNCTION hops to the nearest graph node returned earlier in the session (absent beyond codegraph.MaxProximityHops)
+}
+
+// MatchHighlight marks the parts of a result chunk that overlap the query chunk
+type MatchHighlight struct {
+	Spans        []TokenSpan `json:"spans"`         // Overlapping token runs, in file coordinates
+	MatchedLines []int       `json:"matched_lines"` // Best-matching lines (0-based file line numbers)
+	Coverage     float64     `json:"coverage"`      // Fraction of result tokens covered by spans
+	Snippet      string      `json:"snippet"`       // Result code with matched lines marked by ">>"
+}
+
+// TokenSpan is a contiguous run of tokens on a single line
+type TokenSpan struct {
+	Line      int    `json:"line"`
+	StartChar int    `json:"start_char"`
+	EndChar   int    `json:"end_char"`
+	Text      string `json:"text"`
+}
+
+type GetChunkNeighborsRequest struct {
+	RepoName       string `json:"repo_name" binding:"required"`
+	CollectionName string `json:"collection_name"`
+	ChunkID        string `json:"chunk_id" binding:"required"`
+	IncludeCode    bool   `json:"include_code"`
+}
+
+type GetChunkNeighborsResponse struct {
+	RepoName       string             `json:"repo_name"`
+	CollectionName string             `json:"collection_name"`
+	Neighborhood   *ChunkNeighborhood `json:"neighborhood,omitempty"`
+	Success        bool               `json:"success"`
+	Message        string             `json:"message,omitempty"`
 }
 
 // N-gram API models
@@ -349,17 +455,61 @@ type GetFileEntropyResponse struct {
 }
 
 type AnalyzeCodeRequest struct {
-	RepoName string `json:"repo_name" binding:"required"`
-	Language string `json:"language" binding:"required"`
-	Code     string `json:"code" binding:"required"`
+	RepoName  string            `json:"repo_name" binding:"required"`
+	Language  string            `json:"language"`    // Required with code; default language of files
+	Code      string            `json:"code"`        // Snippet to analyze; code or files is required
+	FilePath  string            `json:"file_path"`   // Path findings in code are reported at (required for SARIF)
+	Files     []AnalyzeCodeFile `json:"files"`       // Files to analyze
+	MinZScore *float64          `json:"min_z_score"` // Line z-score reported as a finding (default 2)
+	Format    string            `json:"format"`      // "json" (default) or "sarif"
+}
+
+// AnalyzeCodeFile is a file of an AnalyzeCodeRequest
+type AnalyzeCodeFile struct {
+	FilePath string `json:"file_path"` // Relative to the repository root
+	Language string `json:"language"`  // Default: the request's language, else detected from the extension
+	Code     string `json:"code"`
 }
 
 type AnalyzeCodeResponse struct {
-	RepoName   string  `json:"repo_name"`
-	Language   string  `json:"language"`
+	RepoName   string               `json:"repo_name"`
+	Language   string               `json:"language"`
+	TokenCount int                  `json:"token_count"`
+	Entropy    float64              `json:"entropy"`
+	Perplexity float64              `json:"perplexity"`
+	ZScore     float64              `json:"z_score"`
+	Lines      []LineScore          `json:"lines,omitempty"`
+	Files      []AnalyzedFile       `json:"files,omitempty"`
+	Findings   []NaturalnessFinding `json:"findings"` // Lines of code and files at or above min_z_score, most unusual first
+}
+
+// AnalyzedFile is the naturalness of a file of an AnalyzeCodeRequest
+type AnalyzedFile struct {
+	FilePath   string      `json:"file_path"`
+	Language   string      `json:"language"`
+	TokenCount int         `json:"token_count"`
+	Entropy    float64     `json:"entropy"`
+	Perplexity float64     `json:"perplexity"`
+	ZScore     float64     `json:"z_score"`
+	Lines      []LineScore `json:"lines"`
+}
+
+// LineScore is the naturalness of one line: the average surprisal of its tokens under the
+// repository's n-gram model, and its z-score against the corpus file entropies
+type LineScore struct {
+	Line       int     `json:"line"` // 1-based
 	TokenCount int     `json:"token_count"`
 	Entropy    float64 `json:"entropy"`
-	Perplexity float64 `json:"perplexity"`
+	ZScore     float64 `json:"z_score"`
+}
+
+// NaturalnessFinding is a line with unusually high entropy for the repository
+type NaturalnessFinding struct {
+	FilePath string  `json:"file_path"`
+	Line     int     `json:"line"` // 1-based
+	Entropy  float64 `json:"entropy"`
+	ZScore   float64 `json:"z_score"`
+	Level    string  `json:"level"` // "very_low", "low", "normal", "high", "very_high"
 }
 
 type CalculateZScoreRequest struct {
@@ -400,6 +550,136 @@ type ZScoreInterpretation struct {
 	Percentile  float64 `json:"percentile"` // Approximate percentile in corpus
 }
 
+// FunctionHistoryResponse lists the indexed versions of a function, oldest first
+type FunctionHistoryResponse struct {
+	RepoName   string                `json:"repo_name"`
+	FunctionID int64                 `json:"function_id"`
+	Name       string                `json:"name"`
+	Path       string                `json:"path"`
+	Versions   []FunctionVersionInfo `json:"versions"`
+}
+
+// FunctionVersionInfo is one indexed version of a function
+type FunctionVersionInfo struct {
+	NodeID    int64        `json:"node_id"`
+	FileID    int32        `json:"file_id"`
+	Commit    string       `json:"commit,omitempty"`     // Empty for working tree versions
+	IndexedAt *time.Time   `json:"indexed_at,omitempty"` // When the file version was first indexed (needs MySQL)
+	Ephemeral bool         `json:"ephemeral"`            // Working tree version not matching any commit
+	Range     base.Range   `json:"range"`                // 0-based lines in that file version
+	Diff      *VersionDiff `json:"diff,omitempty"`       // Against the previous version (absent for the first or without source)
+	Source    string       `json:"source,omitempty"`     // Function text (if include_source is true)
+	// SourceUnavailable explains why the source of this version could not be read
+	SourceUnavailable string `json:"source_unavailable,omitempty"`
+}
+
+// VersionDiff summarizes the line changes of a function version against the previous one
+type VersionDiff struct {
+	LinesAdded   int  `json:"lines_added"`
+	LinesRemoved int  `json:"lines_removed"`
+	Moved        bool `json:"moved"` // The function starts on a different line
+}
+
+// NodeSourceResponse is the source text of a Function or Class node
+type NodeSourceResponse struct {
+	NodeID        int64      `json:"node_id"`
+	Type          string     `json:"type"` // Node label, "Function" or "Class"
+	Name          string     `json:"name"`
+	RepoName      string     `json:"repo_name"`
+	Path          string     `json:"path"`
+	Commit        string     `json:"commit,omitempty"` // Empty for working tree versions
+	Range         base.Range `json:"range"`            // 0-based, characters in UTF-16 code units
+	Source        string     `json:"source"`           // Exact text of the range
+	ContextBefore []string   `json:"context_before,omitempty"`
+	ContextAfter  []string   `json:"context_after,omitempty"`
+}
+
+// NodeBatchResponse holds the nodes of a batch request in request order
+type NodeBatchResponse struct {
+	Nodes   []NodeDetail `json:"nodes"`
+	Missing []int64      `json:"missing,omitempty"` // Requested IDs with no node
+}
+
+// NodeDetail is one hydrated node of a batch request
+type NodeDetail struct {
+	NodeID   int64          `json:"node_id"`
+	Type     string         `json:"type"` // Node label, e.g. "Function"
+	Name     string         `json:"name"`
+	RepoName string         `json:"repo_name,omitempty"`
+	Path     string         `json:"path,omitempty"`
+	Commit   string         `json:"commit,omitempty"` // Empty for working tree versions
+	Range    base.Range     `json:"range"`
+	MetaData map[string]any `json:"metadata,omitempty"`
+	Source   string         `json:"source,omitempty"` // Exact text of the range (if include_source is true)
+	// SourceUnavailable explains why the source of this node could not be read
+	SourceUnavailable string `json:"source_unavailable,omitempty"`
+}
+
+// ProjectInfo describes a configured project
+type ProjectInfo struct {
+	Name         string   `json:"name"`
+	Description  string   `json:"description,omitempty"`
+	Repositories []string `json:"repositories"`
+}
+
+// ProjectSearchRequest searches similar code across the repositories of a project
+type ProjectSearchRequest struct {
+	CodeSnippet string `json:"code_snippet" binding:"required"`
+	Language    string `json:"language" binding:"required"`
+	Limit       int    `json:"limit"`
+	IncludeCode bool   `json:"include_code"`
+}
+
+// ProjectSearchResponse holds the merged results of a project-wide similar code search
+type ProjectSearchResponse struct {
+	Project string              `json:"project"`
+	Repos   []string            `json:"repos"`
+	Query   QueryInfo           `json:"query"`
+	Results []ProjectCodeResult `json:"results"`
+	Success bool                `json:"success"`
+	Message string              `json:"message,omitempty"`
+	// Diagnostics explain an empty result, by repository
+	Diagnostics []SearchDiagnostic `json:"diagnostics,omitempty"`
+}
+
+// ProjectCodeResult is a similar code result labelled with the repository it came from
+type ProjectCodeResult struct {
+	RepoName string `json:"repo_name"`
+	SimilarCodeResult
+}
+
+// RepoStats summarizes what is indexed for a repository
+type RepoStats struct {
+	RepoName string     `json:"repo_name"`
+	Projects []string   `json:"projects,omitempty"` // Projects the repository belongs to
+	Files    *FileStats `json:"files,omitempty"`    // Absent without MySQL
+	// GraphNodes counts the code graph nodes of the repository; absent without the code graph
+	GraphNodes *int64 `json:"graph_nodes,omitempty"`
+	// Stack holds the languages, frameworks and build systems of the repository
+	Stack *techstack.Profile `json:"stack,omitempty"`
+}
+
+// FileStats counts the tracked file versions of a repository
+type FileStats struct {
+	Total     int64            `json:"total"`
+	Ephemeral int64            `json:"ephemeral"`
+	Committed int64            `json:"committed"`
+	Skipped   map[string]int64 `json:"skipped,omitempty"` // By skip reason
+}
+
+// ProjectStats summarizes what is indexed for the repositories of a project
+type ProjectStats struct {
+	Project     string      `json:"project"`
+	Description string      `json:"description,omitempty"`
+	Repos       []RepoStats `json:"repos"`
+	TotalFiles  int64       `json:"total_files"`
+	TotalNodes  int64       `json:"total_graph_nodes"`
+	// Languages sums the language statistics of the repositories; Frameworks lists the
+	// frameworks and build systems any of them uses
+	Languages  []techstack.LanguageStat `json:"languages"`
+	Frameworks []string                 `json:"frameworks"`
+}
+
 func (fd *FunctionDependency) IsIn(rng *base.Range) bool {
 	for _, loc := range fd.CallLocations {
 		if rng.ContainsRange(&loc.Range) {
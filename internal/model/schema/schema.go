@@ -0,0 +1,349 @@
+// Package schema is the registry of node labels and relation types used in the code graph.
+//
+// Every ast.NodeType is stored under one label, and every relation the graph writes has a
+// registered type. Writers look labels up here instead of switching on node types, and
+// relation types are validated here before they are spliced into Cypher.
+//
+// To add a node or relation type, declare its constant and register it in the init function
+// of this package, or call RegisterNodeType / RegisterRelation from the init function of the
+// package that introduces it:
+//
+//	const NodeTypeAnnotation ast.NodeType = 14
+//
+//	func init() {
+//		schema.MustRegisterNodeType(NodeTypeAnnotation, "Annotation")
+//		schema.MustRegisterRelation("ANNOTATES", "an annotation applies to its target")
+//	}
+package schema
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"bot-go/internal/model/ast"
+)
+
+// NodeLabel is the Neo4j label of a node
+type NodeLabel string
+
+// RelationType is the Neo4j type of a relationship
+type RelationType string
+
+// Node labels of the built-in node types
+const (
+	LabelModuleScope  NodeLabel = "ModuleScope"
+	LabelFileScope    NodeLabel = "FileScope"
+	LabelBlock        NodeLabel = "Block"
+	LabelVariable     NodeLabel = "Variable"
+	LabelExpression   NodeLabel = "Expression"
+	LabelConditional  NodeLabel = "Conditional"
+	LabelFunction     NodeLabel = "Function"
+	LabelClass        NodeLabel = "Class"
+	LabelField        NodeLabel = "Field"
+	LabelFunctionCall NodeLabel = "FunctionCall"
+	LabelFileNumber   NodeLabel = "FileNumber"
+	LabelLoop         NodeLabel = "Loop"
+	LabelImport       NodeLabel = "Import"
+	LabelAnnotation   NodeLabel = "Annotation"
+	LabelConstant     NodeLabel = "Constant"
+	LabelEnum         NodeLabel = "Enum"
+
+	// LabelNode is the label of node types that are not registered
+	LabelNode NodeLabel = "Node"
+)
+
+// Built-in relation types
+const (
+	RelContains        RelationType = "CONTAINS"
+	RelHasField        RelationType = "HAS_FIELD"
+	RelCalls           RelationType = "CALLS"
+	RelInherits        RelationType = "INHERITS"
+	RelCallsFunction   RelationType = "CALLS_FUNCTION"
+	RelUsesVariable    RelationType = "USES_VARIABLE"
+	RelImports         RelationType = "IMPORTS"
+	RelBody            RelationType = "BODY"
+	RelAnnotation      RelationType = "ANNOTATION"
+	RelFunctionArg     RelationType = "FUNCTION_ARG"
+	RelFrom            RelationType = "FROM"
+	RelDataFlow        RelationType = "DATA_FLOW"
+	RelFunctionCallArg RelationType = "FUNCTION_CALL_ARG"
+	RelReturns         RelationType = "RETURNS"
+	RelAlias           RelationType = "ALIAS"
+	RelBranch          RelationType = "BRANCH"
+	RelModifiedIn      RelationType = "MODIFIED_IN"
+	RelCaptures        RelationType = "CAPTURES"
+	RelHasString       RelationType = "HAS_STRING"
+	RelThis            RelationType = "THIS"
+)
+
+var (
+	mu        sync.RWMutex
+	labels    = make(map[ast.NodeType]NodeLabel)
+	nodeTypes = make(map[NodeLabel]ast.NodeType)
+	relations = make(map[RelationType]string) // type -> description
+	external  = make(map[RelationType]ExternalRelation)
+)
+
+// ExternalRelation is a relation type that external tools create between existing nodes
+// through the enrichment API, such as RUNTIME_CALLS edges from tracing data. From and To
+// restrict the labels of its endpoints; empty allows any label.
+type ExternalRelation struct {
+	Type RelationType
+	From []NodeLabel
+	To   []NodeLabel
+}
+
+func init() {
+	for _, n := range []struct {
+		nodeType ast.NodeType
+		label    NodeLabel
+	}{
+		{ast.NodeTypeModuleScope, LabelModuleScope},
+		{ast.NodeTypeFileScope, LabelFileScope},
+		{ast.NodeTypeBlock, LabelBlock},
+		{ast.NodeTypeVariable, LabelVariable},
+		{ast.NodeTypeExpression, LabelExpression},
+		{ast.NodeTypeConditional, LabelConditional},
+		{ast.NodeTypeFunction, LabelFunction},
+		{ast.NodeTypeClass, LabelClass},
+		{ast.NodeTypeField, LabelField},
+		{ast.NodeTypeFunctionCall, LabelFunctionCall},
+		{ast.NodeTypeFileNumber, LabelFileNumber},
+		{ast.NodeTypeLoop, LabelLoop},
+		{ast.NodeTypeImport, LabelImport},
+		{ast.NodeTypeAnnotation, LabelAnnotation},
+		{ast.NodeTypeConstant, LabelConstant},
+		{ast.NodeTypeEnum, LabelEnum},
+	} {
+		MustRegisterNodeType(n.nodeType, n.label)
+	}
+
+	for _, r := range []struct {
+		relType     RelationType
+		description string
+	}{
+		{RelContains, "a scope contains a node"},
+		{RelHasField, "a class has a field"},
+		{RelCalls, "a call site calls a function"},
+		{RelInherits, "a class inherits from another"},
+		{RelCallsFunction, "a function calls another function"},
+		{RelUsesVariable, "a node reads a variable"},
+		{RelImports, "an import refers to an imported symbol"},
+		{RelBody, "a function or statement has a body block"},
+		{RelAnnotation, "a function or class has a decorator or annotation"},
+		{RelFunctionArg, "a function declares a parameter"},
+		{RelFrom, "a value comes from another node"},
+		{RelDataFlow, "data flows from one node to another"},
+		{RelFunctionCallArg, "a call passes an argument"},
+		{RelReturns, "a function returns a value"},
+		{RelAlias, "a name aliases another"},
+		{RelBranch, "a conditional has a branch"},
+		{RelModifiedIn, "a file or symbol was modified in a commit"},
+		{RelCaptures, "a nested function uses a variable of an enclosing function"},
+		{RelHasString, "a function contains a string literal"},
+		{RelThis, "a method receiver refers to its class"},
+	} {
+		MustRegisterRelation(r.relType, r.description)
+	}
+}
+
+// RegisterNodeType registers the label a node type is stored under. Neither the node type
+// nor the label may already be registered, and the label must be a valid Cypher identifier.
+func RegisterNodeType(nodeType ast.NodeType, label NodeLabel) error {
+	if !isIdentifier(string(label)) || label == LabelNode {
+		return fmt.Errorf("invalid node label %q", label)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if existing, ok := labels[nodeType]; ok {
+		return fmt.Errorf("node type %d is already registered as %s", nodeType, existing)
+	}
+	if existing, ok := nodeTypes[label]; ok {
+		return fmt.Errorf("node label %s is already registered for node type %d", label, existing)
+	}
+	labels[nodeType] = label
+	nodeTypes[label] = nodeType
+	return nil
+}
+
+// MustRegisterNodeType is RegisterNodeType for init functions; it panics on error
+func MustRegisterNodeType(nodeType ast.NodeType, label NodeLabel) {
+	if err := RegisterNodeType(nodeType, label); err != nil {
+		panic(err)
+	}
+}
+
+// RegisterRelation registers a relation type. By convention types are UPPER_SNAKE_CASE.
+func RegisterRelation(relType RelationType, description string) error {
+	if !isIdentifier(string(relType)) {
+		return fmt.Errorf("invalid relation type %q", relType)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := relations[relType]; ok {
+		return fmt.Errorf("relation type %s is already registered", relType)
+	}
+	relations[relType] = description
+	return nil
+}
+
+// MustRegisterRelation is RegisterRelation for init functions; it panics on error
+func MustRegisterRelation(relType RelationType, description string) {
+	if err := RegisterRelation(relType, description); err != nil {
+		panic(err)
+	}
+}
+
+// RegisterExternalRelation registers a relation type external tools may create. Like
+// RegisterRelation the type must not be registered yet; its endpoint labels must be.
+func RegisterExternalRelation(r ExternalRelation, description string) error {
+	for _, label := range append(append([]NodeLabel{}, r.From...), r.To...) {
+		if _, ok := NodeTypeOf(label); !ok {
+			return fmt.Errorf("relation type %s: unknown node label %q", r.Type, label)
+		}
+	}
+	if err := RegisterRelation(r.Type, description); err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	external[r.Type] = r
+	return nil
+}
+
+// ValidateExternalRelation returns an error unless relType was registered with
+// RegisterExternalRelation and admits a relation from a node labelled from to one labelled to
+func ValidateExternalRelation(relType RelationType, from, to NodeLabel) error {
+	mu.RLock()
+	defer mu.RUnlock()
+	r, ok := external[relType]
+	if !ok {
+		if _, builtIn := relations[relType]; builtIn {
+			return fmt.Errorf("relation type %s is written by index builds, not by external tools", relType)
+		}
+		return fmt.Errorf("unknown relation type %q", relType)
+	}
+	if !allowsLabel(r.From, from) {
+		return fmt.Errorf("relation type %s cannot start at a %s node (allowed: %s)", relType, from, joinLabels(r.From))
+	}
+	if !allowsLabel(r.To, to) {
+		return fmt.Errorf("relation type %s cannot end at a %s node (allowed: %s)", relType, to, joinLabels(r.To))
+	}
+	return nil
+}
+
+// IsExternalRelation reports whether relType was registered with RegisterExternalRelation
+func IsExternalRelation(relType RelationType) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	_, ok := external[relType]
+	return ok
+}
+
+// ExternalRelations returns the relation types registered with RegisterExternalRelation,
+// sorted by type
+func ExternalRelations() []ExternalRelation {
+	mu.RLock()
+	defer mu.RUnlock()
+	result := make([]ExternalRelation, 0, len(external))
+	for _, r := range external {
+		result = append(result, r)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Type < result[j].Type })
+	return result
+}
+
+func allowsLabel(allowed []NodeLabel, label NodeLabel) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		if a == label {
+			return true
+		}
+	}
+	return false
+}
+
+func joinLabels(labels []NodeLabel) string {
+	names := make([]string, len(labels))
+	for i, label := range labels {
+		names[i] = string(label)
+	}
+	return strings.Join(names, ", ")
+}
+
+// LabelOf returns the label of a node type, or LabelNode if it is not registered
+func LabelOf(nodeType ast.NodeType) NodeLabel {
+	mu.RLock()
+	defer mu.RUnlock()
+	if label, ok := labels[nodeType]; ok {
+		return label
+	}
+	return LabelNode
+}
+
+// NodeTypeOf returns the node type stored under a label
+func NodeTypeOf(label NodeLabel) (ast.NodeType, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	nodeType, ok := nodeTypes[label]
+	return nodeType, ok
+}
+
+// ValidateRelation returns an error unless relType is registered
+func ValidateRelation(relType RelationType) error {
+	mu.RLock()
+	defer mu.RUnlock()
+	if _, ok := relations[relType]; !ok {
+		return fmt.Errorf("unknown relation type %q", relType)
+	}
+	return nil
+}
+
+// NodeLabels returns every registered label, sorted
+func NodeLabels() []NodeLabel {
+	mu.RLock()
+	defer mu.RUnlock()
+	result := make([]NodeLabel, 0, len(nodeTypes))
+	for label := range nodeTypes {
+		result = append(result, label)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
+
+// RelationTypes returns every registered relation type, sorted
+func RelationTypes() []RelationType {
+	mu.RLock()
+	defer mu.RUnlock()
+	result := make([]RelationType, 0, len(relations))
+	for relType := range relations {
+		result = append(result, relType)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
+
+// Describe returns the description a relation type was registered with
+func Describe(relType RelationType) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return relations[relType]
+}
+
+// isIdentifier reports whether s can be used unquoted as a Cypher label or type
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (i > 0 && r >= '0' && r <= '9') {
+			continue
+		}
+		return false
+	}
+	return true
+}
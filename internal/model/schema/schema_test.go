@@ -0,0 +1,79 @@
+package schema
+
+import (
+	"testing"
+
+	"bot-go/internal/model/ast"
+)
+
+func TestRegistry(t *testing.T) {
+	if got := LabelOf(ast.NodeTypeFunction); got != LabelFunction {
+		t.Errorf("LabelOf(function) = %s", got)
+	}
+	if got := LabelOf(ast.NodeType(100)); got != LabelNode {
+		t.Errorf("LabelOf(unregistered) = %s, want %s", got, LabelNode)
+	}
+	if err := ValidateRelation(RelCallsFunction); err != nil {
+		t.Error(err)
+	}
+	if err := ValidateRelation("CALLS]->(x) DETACH DELETE x //"); err == nil {
+		t.Error("unregistered relation type was accepted")
+	}
+
+	// Duplicates and invalid identifiers are rejected
+	for name, err := range map[string]error{
+		"duplicate node type": RegisterNodeType(ast.NodeTypeFunction, "Function2"),
+		"duplicate label":     RegisterNodeType(ast.NodeType(100), LabelClass),
+		"invalid label":       RegisterNodeType(ast.NodeType(100), "Bad Label"),
+		"duplicate relation":  RegisterRelation(RelContains, ""),
+		"invalid relation":    RegisterRelation("BAD-TYPE", ""),
+		"reserved label":      RegisterNodeType(ast.NodeType(100), LabelNode),
+	} {
+		if err == nil {
+			t.Errorf("%s: registered", name)
+		}
+	}
+
+	// Registered types are picked up everywhere
+	const nodeTypeTest ast.NodeType = 101
+	if err := RegisterNodeType(nodeTypeTest, "TestNode"); err != nil {
+		t.Fatal(err)
+	}
+	if got := LabelOf(nodeTypeTest); got != "TestNode" {
+		t.Errorf("LabelOf(registered) = %s", got)
+	}
+	if nodeType, ok := NodeTypeOf("TestNode"); !ok || nodeType != nodeTypeTest {
+		t.Errorf("NodeTypeOf(TestNode) = %d, %v", nodeType, ok)
+	}
+}
+
+func TestExternalRelations(t *testing.T) {
+	runtimeCalls := ExternalRelation{Type: "TEST_RUNTIME_CALLS", From: []NodeLabel{LabelFunction}, To: []NodeLabel{LabelFunction}}
+	if err := RegisterExternalRelation(runtimeCalls, "a function called another at runtime"); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterExternalRelation(ExternalRelation{Type: "TEST_OWNS", To: []NodeLabel{"Team"}}, ""); err == nil {
+		t.Error("external relation with an unknown label was registered")
+	}
+	if err := ValidateRelation("TEST_RUNTIME_CALLS"); err != nil {
+		t.Errorf("external relation is not a registered relation: %v", err)
+	}
+
+	tests := []struct {
+		relType  RelationType
+		from, to NodeLabel
+		valid    bool
+	}{
+		{"TEST_RUNTIME_CALLS", LabelFunction, LabelFunction, true},
+		{"TEST_RUNTIME_CALLS", LabelClass, LabelFunction, false},
+		{"TEST_RUNTIME_CALLS", LabelFunction, LabelField, false},
+		{RelCallsFunction, LabelFunction, LabelFunction, false}, // built in
+		{"TEST_UNKNOWN", LabelFunction, LabelFunction, false},
+	}
+	for _, tt := range tests {
+		err := ValidateExternalRelation(tt.relType, tt.from, tt.to)
+		if (err == nil) != tt.valid {
+			t.Errorf("ValidateExternalRelation(%s, %s, %s) = %v, want valid %v", tt.relType, tt.from, tt.to, err, tt.valid)
+		}
+	}
+}
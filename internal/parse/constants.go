@@ -0,0 +1,121 @@
+package parse
+
+import (
+	"context"
+	"unicode"
+
+	"bot-go/internal/model/ast"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	"go.uber.org/zap"
+)
+
+// EnumMember is a member of an enum declaration
+type EnumMember struct {
+	Decl  *tree_sitter.Node // the whole member declaration, for its range
+	Name  *tree_sitter.Node
+	Value *tree_sitter.Node // nil when the value is implicit
+	// Metadata is added to the member's metadata, e.g. its iota index in Go
+	Metadata map[string]any
+}
+
+// CreateConstant creates a Constant node for a named constant declared by decl and adds it to
+// the current scope, so identifiers using it resolve to the constant. The source text of
+// value is stored as the "value" metadata, and value is traversed as the right-hand side
+// flowing into the constant.
+func (t *TranslateFromSyntaxTree) CreateConstant(ctx context.Context, decl, name, value *tree_sitter.Node,
+	metadata map[string]any, scopeID ast.NodeID) ast.NodeID {
+	if name == nil {
+		return ast.InvalidNodeID
+	}
+	constName := t.GetTreeNodeName(name)
+	if constName == "" {
+		return ast.InvalidNodeID
+	}
+
+	constNode := t.NewNode(ast.NodeTypeConstant, constName, t.ToRange(decl), scopeID)
+	constNode.MetaData = make(map[string]any, len(metadata)+1)
+	for key, v := range metadata {
+		constNode.MetaData[key] = v
+	}
+	if value != nil {
+		constNode.MetaData["value"] = t.String(value)
+	}
+	if err := t.CodeGraph.CreateConstant(ctx, constNode); err != nil {
+		t.Logger.Error("Failed to create constant", zap.String("name", constName), zap.Error(err))
+		return ast.InvalidNodeID
+	}
+	// A redefinition keeps resolving to the first definition, as for variables
+	_ = t.CurrentScope.AddSymbol(NewSymbol(constNode))
+
+	if value != nil {
+		if rhsID := t.HandleRhsWithFakeVariable(ctx, "__rhs__", value, scopeID, nil); rhsID != ast.InvalidNodeID {
+			t.CodeGraph.CreateDataFlowRelation(ctx, rhsID, constNode.ID, t.FileID)
+		}
+	}
+	return constNode.ID
+}
+
+// HandleEnum creates an Enum node with a Constant for each member. Members get their name
+// as well as "enum" and "ordinal" metadata. When scoped, members are reachable only
+// through the enum (Color.RED in Python and TypeScript), so they are fields of the enum's
+// symbol. Otherwise they are also bare names in the enclosing scope, like Go's iota
+// constants. Methods are traversed as members of the enum.
+func (t *TranslateFromSyntaxTree) HandleEnum(ctx context.Context, scopeID ast.NodeID, enum *tree_sitter.Node,
+	name string, members []EnumMember, methods []*tree_sitter.Node, scoped bool) ast.NodeID {
+	if name == "" {
+		name = t.GetTreeNodeName(enum)
+	}
+	if name == "" {
+		return ast.InvalidNodeID
+	}
+
+	enumNode := t.NewNode(ast.NodeTypeEnum, name, t.ToRange(enum), scopeID)
+	if err := t.CodeGraph.CreateEnum(ctx, enumNode); err != nil {
+		t.Logger.Error("Failed to create enum", zap.String("name", name), zap.Error(err))
+		return ast.InvalidNodeID
+	}
+	enumSym := t.CurrentScope.GetSymbol(name)
+	if enumSym == nil {
+		enumSym = NewSymbol(enumNode)
+		_ = t.CurrentScope.AddSymbol(enumSym)
+	}
+
+	if scoped {
+		t.PushScope(false)
+		defer t.PopScope(ctx, enumNode.ID)
+	}
+	for i, member := range members {
+		metadata := map[string]any{"enum": name, "ordinal": i}
+		for key, v := range member.Metadata {
+			metadata[key] = v
+		}
+		memberID := t.CreateConstant(ctx, member.Decl, member.Name, member.Value, metadata, enumNode.ID)
+		if memberID == ast.InvalidNodeID {
+			continue
+		}
+		_ = enumSym.AddField(NewSymbol(t.Nodes[memberID]))
+		t.CreateContainsRelation(ctx, enumNode.ID, memberID, t.FileID)
+	}
+	for _, method := range methods {
+		if methodID := t.Visitor.TraverseNode(ctx, method, enumNode.ID); methodID != ast.InvalidNodeID {
+			t.CreateContainsRelation(ctx, enumNode.ID, methodID, t.FileID)
+		}
+	}
+	return enumNode.ID
+}
+
+// isConstantName reports whether name follows the UPPER_CASE convention for constants
+func isConstantName(name string) bool {
+	hasLetter := false
+	for _, r := range name {
+		switch {
+		case unicode.IsUpper(r):
+			hasLetter = true
+		case r == '_' || unicode.IsDigit(r):
+		default:
+			return false
+		}
+	}
+	return hasLetter
+}
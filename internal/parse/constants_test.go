@@ -0,0 +1,155 @@
+package parse
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"bot-go/internal/config"
+	"bot-go/internal/model/ast"
+)
+
+// metadata returns the formatted metadata of the recorded node of a type and name, with
+// absent entries as empty strings
+func (g *graphRecorder) metadata(t *testing.T, nodeType ast.NodeType, name string, keys ...string) []string {
+	t.Helper()
+	i := slices.IndexFunc(g.nodes, func(n recordedNode) bool { return n.nodeType == nodeType && n.name == name })
+	if i < 0 {
+		t.Errorf("%s missing", name)
+		return nil
+	}
+	values := make([]string, len(keys))
+	for k, key := range keys {
+		if v, ok := g.nodes[i].props["md_"+key]; ok {
+			values[k] = fmt.Sprint(v)
+		}
+	}
+	return values
+}
+
+func TestGoConstants(t *testing.T) {
+	g := translateSource(t, "color.go", `package p
+
+type Color int
+
+const (
+	Red Color = iota
+	Green
+	Blue
+)
+
+const MaxSize = 10
+
+const (
+	first = iota * 2
+	second
+)
+
+const low, high = 1, 2
+
+func f() int {
+	const local = "x"
+	return MaxSize
+}
+`, config.LanguageOptions{})
+
+	if got := g.names(ast.NodeTypeEnum); !slices.Equal(got, []string{"Color"}) {
+		t.Errorf("enums = %v, want Color only", got)
+	}
+	constants := g.names(ast.NodeTypeConstant)
+	slices.Sort(constants)
+	want := []string{"Blue", "Green", "MaxSize", "Red", "first", "high", "local", "low", "second"}
+	if !slices.Equal(constants, want) {
+		t.Errorf("constants = %v, want %v", constants, want)
+	}
+	members := []string{"Color -> Blue", "Color -> Green", "Color -> Red"}
+	if got := g.related("CONTAINS"); !containsAll(got, members) {
+		t.Errorf("CONTAINS = %v, want the enum to contain %v", got, members)
+	}
+
+	tests := []struct {
+		name string
+		want []string // value, enum, ordinal, iota
+	}{
+		{"Red", []string{"iota", "Color", "0", "0"}},
+		{"Green", []string{"iota", "Color", "1", "1"}},
+		{"Blue", []string{"iota", "Color", "2", "2"}},
+		{"MaxSize", []string{"10", "", "", ""}},
+		{"first", []string{"iota * 2", "", "", "0"}},
+		{"second", []string{"iota * 2", "", "", "1"}},
+		{"low", []string{"1", "", "", ""}},
+		{"high", []string{"2", "", "", ""}},
+		{"local", []string{`"x"`, "", "", ""}},
+	}
+	for _, tt := range tests {
+		if got := g.metadata(t, ast.NodeTypeConstant, tt.name, "value", "enum", "ordinal", "iota"); got != nil && !slices.Equal(got, tt.want) {
+			t.Errorf("%s: metadata %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPythonConstants(t *testing.T) {
+	g := translateSource(t, "color.py", `import enum
+from enum import Enum
+
+MAX_RETRIES = 3
+timeout = 5
+
+class Color(Enum):
+    RED = 1
+    GREEN = "green"
+
+    def describe(self):
+        return self.name
+
+class Mode(enum.IntFlag):
+    READ = 1
+
+class Plain:
+    LIMIT = 2
+
+def retry():
+    ATTEMPTS = MAX_RETRIES
+    return ATTEMPTS
+`, config.LanguageOptions{})
+
+	if got := g.names(ast.NodeTypeEnum); !slices.Equal(got, []string{"Color", "Mode"}) {
+		t.Errorf("enums = %v, want Color and Mode", got)
+	}
+	if got := g.names(ast.NodeTypeClass); slices.Contains(got, "Color") || !slices.Contains(got, "Plain") {
+		t.Errorf("classes = %v, want Plain but not the enums", got)
+	}
+	constants := g.names(ast.NodeTypeConstant)
+	slices.Sort(constants)
+	if want := []string{"GREEN", "MAX_RETRIES", "READ", "RED"}; !slices.Equal(constants, want) {
+		t.Errorf("constants = %v, want %v", constants, want)
+	}
+	members := []string{"Color -> GREEN", "Color -> RED", "Color -> describe", "Mode -> READ"}
+	if got := g.related("CONTAINS"); !containsAll(got, members) {
+		t.Errorf("CONTAINS = %v, want %v", got, members)
+	}
+
+	tests := []struct {
+		name string
+		want []string // value, enum, ordinal
+	}{
+		{"MAX_RETRIES", []string{"3", "", ""}},
+		{"RED", []string{"1", "Color", "0"}},
+		{"GREEN", []string{`"green"`, "Color", "1"}},
+		{"READ", []string{"1", "Mode", "0"}},
+	}
+	for _, tt := range tests {
+		if got := g.metadata(t, ast.NodeTypeConstant, tt.name, "value", "enum", "ordinal"); got != nil && !slices.Equal(got, tt.want) {
+			t.Errorf("%s: metadata %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func containsAll(got, want []string) bool {
+	for _, w := range want {
+		if !slices.Contains(got, w) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,60 @@
+package parse
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// ASTFingerprint computes a normalized structural hash of a syntax subtree.
+// Identifiers and literal values are abstracted away (only node kinds are kept),
+// and comments are ignored, so copy-pasted code with renamed variables or
+// changed constants produces the same fingerprint. Also returns the number of
+// named nodes in the subtree, which callers can use to ignore trivial matches.
+func ASTFingerprint(node *tree_sitter.Node) (string, int) {
+	if node == nil {
+		return "", 0
+	}
+	var sb strings.Builder
+	size := writeNormalizedNode(&sb, node)
+	hash := sha256.Sum256([]byte(sb.String()))
+	return hex.EncodeToString(hash[:]), size
+}
+
+func writeNormalizedNode(sb *strings.Builder, node *tree_sitter.Node) int {
+	kind := node.Kind()
+	if kind == "comment" || strings.HasSuffix(kind, "_comment") {
+		return 0
+	}
+
+	if node.ChildCount() == 0 {
+		switch {
+		case kind == "identifier" || strings.HasSuffix(kind, "_identifier"):
+			sb.WriteString("$id ")
+		default:
+			// Named leaves (literals etc.) keep only their kind; anonymous leaves
+			// are keywords/operators whose kind is the token itself
+			sb.WriteString(kind)
+			sb.WriteByte(' ')
+		}
+		if node.IsNamed() {
+			return 1
+		}
+		return 0
+	}
+
+	size := 0
+	if node.IsNamed() {
+		size = 1
+	}
+	sb.WriteByte('(')
+	sb.WriteString(kind)
+	sb.WriteByte(' ')
+	for i := uint(0); i < node.ChildCount(); i++ {
+		size += writeNormalizedNode(sb, node.Child(i))
+	}
+	sb.WriteString(") ")
+	return size
+}
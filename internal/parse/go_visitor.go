@@ -33,6 +33,8 @@ func (gv *GoVisitor) TraverseNode(ctx context.Context, tsNode *tree_sitter.Node,
 		return gv.handleFunctionDeclaration(ctx, tsNode, scopeID)
 	case "method_declaration":
 		return gv.handleMethodDeclaration(ctx, tsNode, scopeID)
+	case "func_literal":
+		return gv.handleFuncLiteral(ctx, tsNode, scopeID)
 	case "block":
 		return gv.translate.HandleBlock(ctx, tsNode, scopeID)
 	case "type_declaration":
@@ -92,6 +94,7 @@ func (gv *GoVisitor) handlePackage(ctx context.Context, tsNode *tree_sitter.Node
 		gv.translate.GetTreeNodeName(nameNode), gv.translate.ToRange(tsNode), gv.translate.Version,
 		ast.NodeID(gv.translate.FileID),
 	)
+	gv.translate.AssignNodeKey(moduleNode)
 	gv.translate.CodeGraph.CreateModuleScope(ctx, moduleNode)
 	return moduleNode.ID
 }
@@ -129,10 +132,22 @@ func (gv *GoVisitor) createFakeClass(ctx context.Context, className string, file
 	classNode.MetaData = map[string]any{
 		"is_fake": true,
 	}
+	gv.translate.AssignNodeKey(classNode)
 	gv.translate.CodeGraph.CreateClass(ctx, classNode)
 	return classNode
 }
 
+// handleFuncLiteral handles a closure such as the function of a go or defer statement
+func (gv *GoVisitor) handleFuncLiteral(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
+	paramsNode := gv.translate.TreeChildByFieldName(tsNode, "parameters")
+	bodyNode := gv.translate.TreeChildByFieldName(tsNode, "body")
+	var params []*tree_sitter.Node
+	if paramsNode != nil {
+		params = gv.translate.NamedChildren(paramsNode)
+	}
+	return gv.translate.CreateClosure(ctx, scopeID, tsNode, params, bodyNode)
+}
+
 func (gv *GoVisitor) handleMethodDeclaration(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
 	nameNode := gv.translate.TreeChildByKind(tsNode, "field_identifier")
 	methodName := ""
@@ -239,6 +254,10 @@ func (gv *GoVisitor) handleTypeDeclaration(ctx context.Context, tsNode *tree_sit
 		}
 		interfaceType := gv.translate.TreeChildByKind(typeSpec, "interface_type")
 		if interfaceType != nil {
+			// Interfaces declare no behaviour of their own
+			if gv.translate.Options.SkipTypeOnly {
+				continue
+			}
 			childID := gv.handleInterfaceType(ctx, typeSpec, scopeID)
 
 			/*
@@ -448,18 +467,100 @@ func (gv *GoVisitor) handleVarDeclaration(ctx context.Context, tsNode *tree_sitt
 	return ast.InvalidNodeID
 }
 
+// handleConstDeclaration records every constant of a const declaration. A block whose first
+// constant has a type and uses iota, such as
+//
+//	const (
+//		Red Color = iota
+//		Green
+//	)
+//
+// is Go's enum idiom and is recorded as an Enum named after the type. A constant without a
+// value repeats the previous expression, whose text is recorded as its value.
 func (gv *GoVisitor) handleConstDeclaration(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
 	specs := gv.translate.TreeChildrenByKind(tsNode, "const_spec")
+	if len(specs) == 0 {
+		return ast.InvalidNodeID
+	}
+	usesIota := false
 	for _, spec := range specs {
-		nameNode := gv.translate.TreeChildByFieldName(spec, "name")
-		valueNode := gv.translate.TreeChildByFieldName(spec, "value")
-		if nameNode != nil && valueNode != nil {
-			gv.translate.HandleAssignment(ctx, spec, nameNode, valueNode, scopeID)
+		for _, value := range gv.constSpecField(spec, "value") {
+			usesIota = usesIota || gv.usesIota(value)
 		}
 	}
+
+	var members []EnumMember
+	var previous []*tree_sitter.Node
+	for i, spec := range specs {
+		values := previous
+		implicit := true
+		if specValues := gv.constSpecField(spec, "value"); len(specValues) > 0 {
+			values = specValues
+			previous = values
+			implicit = false
+		}
+		for j, nameNode := range gv.constSpecField(spec, "name") {
+			member := EnumMember{Decl: spec, Name: nameNode, Metadata: make(map[string]any)}
+			if j < len(values) {
+				if implicit {
+					member.Metadata["value"] = gv.translate.String(values[j])
+				} else {
+					member.Value = values[j]
+				}
+			}
+			if usesIota {
+				member.Metadata["iota"] = i
+			}
+			members = append(members, member)
+		}
+	}
+
+	enumType := gv.translate.TreeChildByFieldName(specs[0], "type")
+	if usesIota && enumType != nil && len(specs) > 1 {
+		gv.translate.HandleEnum(ctx, scopeID, tsNode, gv.translate.String(enumType), members, nil, false)
+		return ast.InvalidNodeID
+	}
+	for _, member := range members {
+		gv.translate.CreateConstant(ctx, member.Decl, member.Name, member.Value, member.Metadata, scopeID)
+	}
 	return ast.InvalidNodeID
 }
 
+// constSpecField returns the children of a const spec in a field. A spec may declare several
+// names and values, as in const a, b = 1, 2.
+func (gv *GoVisitor) constSpecField(spec *tree_sitter.Node, fieldName string) []*tree_sitter.Node {
+	var children []*tree_sitter.Node
+	for i := uint(0); i < spec.ChildCount(); i++ {
+		// The separating commas are reported in the field too
+		child := spec.Child(i)
+		if !child.IsNamed() || spec.FieldNameForChild(uint32(i)) != fieldName {
+			continue
+		}
+		if child.Kind() == "expression_list" {
+			children = append(children, gv.translate.NamedChildren(child)...)
+		} else {
+			children = append(children, child)
+		}
+	}
+	return children
+}
+
+// usesIota reports whether an expression refers to iota
+func (gv *GoVisitor) usesIota(node *tree_sitter.Node) bool {
+	if node == nil {
+		return false
+	}
+	if node.Kind() == "iota" || (node.Kind() == "identifier" && gv.translate.String(node) == "iota") {
+		return true
+	}
+	for i := uint(0); i < node.NamedChildCount(); i++ {
+		if gv.usesIota(node.NamedChild(i)) {
+			return true
+		}
+	}
+	return false
+}
+
 func (gv *GoVisitor) handleSwitchStatement(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
 	valueNode := gv.translate.TreeChildByFieldName(tsNode, "value")
 	bodyNode := gv.translate.TreeChildByFieldName(tsNode, "body")
@@ -571,7 +672,8 @@ func (gv *GoVisitor) handleTypeSwitchStatement(ctx context.Context, tsNode *tree
 }
 
 func (gv *GoVisitor) handleGoStatement(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
-	callNode := gv.translate.TreeChildByFieldName(tsNode, "call")
+	// The grammar does not name the call, so it is the only named child
+	callNode := tsNode.NamedChild(0)
 	if callNode != nil {
 		return gv.TraverseNode(ctx, callNode, scopeID)
 	}
@@ -579,7 +681,8 @@ func (gv *GoVisitor) handleGoStatement(ctx context.Context, tsNode *tree_sitter.
 }
 
 func (gv *GoVisitor) handleDeferStatement(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
-	callNode := gv.translate.TreeChildByFieldName(tsNode, "call")
+	// The grammar does not name the call, so it is the only named child
+	callNode := tsNode.NamedChild(0)
 	if callNode != nil {
 		return gv.TraverseNode(ctx, callNode, scopeID)
 	}
@@ -719,6 +822,8 @@ func (gv *GoVisitor) handleImportSpec(ctx context.Context, tsNode *tree_sitter.N
 		scopeID,
 	)
 
+	gv.translate.AssignNodeKey(importNode)
+
 	// Store the full import path in metadata
 	importNode.MetaData = map[string]any{
 		"importPath": importPath,
@@ -0,0 +1,76 @@
+package parse
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"bot-go/internal/apperrors"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// importKinds are the kinds of the top-level syntax nodes declaring imports, by language
+var importKinds = map[LanguageType][]string{
+	Go:         {"import_declaration"},
+	Python:     {"import_statement", "import_from_statement", "future_import_statement"},
+	JavaScript: {"import_statement"},
+	TypeScript: {"import_statement"},
+	Java:       {"import_declaration"},
+	Rust:       {"use_declaration"},
+}
+
+// Imports returns the top-level imports of a source file in order, as written on one line,
+// e.g. `gitutil "bot-go/internal/signals/util"` for Go, whose grouped imports are listed one
+// by one, and "from typing import Optional" for Python. The language is detected from the
+// file's extension.
+func Imports(filePath string, content []byte) ([]string, error) {
+	fp := &FileParser{}
+	langType := fp.DetectLanguage(filePath)
+	language, err := fp.GetLanguageParser(langType)
+	if err != nil {
+		return nil, err
+	}
+	kinds := importKinds[langType]
+	if kinds == nil {
+		return nil, fmt.Errorf("%w: no imports for %s", apperrors.ErrUnsupportedLanguage, filePath)
+	}
+
+	parser := tree_sitter.NewParser()
+	defer parser.Close()
+	if err := parser.SetLanguage(language); err != nil {
+		return nil, fmt.Errorf("failed to set parser language: %w", err)
+	}
+	tree := parser.Parse(content, nil)
+	if tree == nil {
+		return nil, fmt.Errorf("failed to parse file: %s", filePath)
+	}
+	defer tree.Close()
+
+	t := &TranslateFromSyntaxTree{FileContent: content}
+	imports := []string{}
+	for _, node := range t.NamedChildren(tree.RootNode()) {
+		if !slices.Contains(kinds, node.Kind()) {
+			continue
+		}
+		if langType != Go {
+			imports = append(imports, oneLine(t.String(node)))
+			continue
+		}
+		for _, spec := range t.NamedChildren(node) {
+			switch spec.Kind() {
+			case "import_spec":
+				imports = append(imports, oneLine(t.String(spec)))
+			case "import_spec_list":
+				for _, s := range t.TreeChildrenByKind(spec, "import_spec") {
+					imports = append(imports, oneLine(t.String(s)))
+				}
+			}
+		}
+	}
+	return imports, nil
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,39 @@
+package parse
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestImports(t *testing.T) {
+	tests := []struct {
+		filePath string
+		source   string
+		want     []string
+	}{
+		{"a.go", "package p\n\nimport \"fmt\"\n\nimport (\n\t\"context\"\n\n\tgitutil \"bot-go/internal/signals/util\"\n)\n\nfunc f() {}\n",
+			[]string{`"fmt"`, `"context"`, `gitutil "bot-go/internal/signals/util"`}},
+		{"a.py", "from __future__ import annotations\nimport os, sys\nfrom typing import (\n    Optional,\n)\n\ndef f():\n    import json\n",
+			[]string{"from __future__ import annotations", "import os, sys", "from typing import ( Optional, )"}},
+		{"a.ts", "import { a } from './a';\nimport * as b from 'b';\nexport const c = 1;\n",
+			[]string{"import { a } from './a';", "import * as b from 'b';"}},
+		{"A.java", "package p;\nimport java.util.List;\nimport static java.lang.Math.max;\nclass A {}\n",
+			[]string{"import java.util.List;", "import static java.lang.Math.max;"}},
+		{"a.rs", "use std::io;\nuse crate::store::{Store, Item as I};\n\nfn f() {}\n",
+			[]string{"use std::io;", "use crate::store::{Store, Item as I};"}},
+		{"a.go", "package p\n", []string{}},
+	}
+	for _, tt := range tests {
+		got, err := Imports(tt.filePath, []byte(tt.source))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.filePath, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Imports(%s) = %q, want %q", tt.filePath, got, tt.want)
+		}
+	}
+
+	if _, err := Imports("README.md", []byte("# readme")); err == nil {
+		t.Error("Imports(README.md) succeeded, want an unsupported language error")
+	}
+}
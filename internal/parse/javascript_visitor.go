@@ -11,6 +11,7 @@ import (
 type JavaScriptVisitor struct {
 	translate *TranslateFromSyntaxTree
 	logger    *zap.Logger
+	programID ast.NodeID
 }
 
 func NewJavaScriptVisitor(logger *zap.Logger, ts *TranslateFromSyntaxTree) *JavaScriptVisitor {
@@ -72,6 +73,8 @@ func (jsv *JavaScriptVisitor) TraverseNode(ctx context.Context, tsNode *tree_sit
 		return jsv.handleVariableDeclaration(ctx, tsNode, scopeID)
 	case "lexical_declaration":
 		return jsv.handleLexicalDeclaration(ctx, tsNode, scopeID)
+	case "enum_declaration":
+		return jsv.handleEnumDeclaration(ctx, tsNode, scopeID)
 	case "switch_statement":
 		return jsv.handleSwitchStatement(ctx, tsNode, scopeID)
 	case "try_statement":
@@ -108,7 +111,9 @@ func (jsv *JavaScriptVisitor) handleProgram(ctx context.Context, tsNode *tree_si
 		jsv.translate.GetTreeNodeName(tsNode), jsv.translate.ToRange(tsNode), jsv.translate.Version,
 		ast.NodeID(jsv.translate.FileID),
 	)
+	jsv.translate.AssignNodeKey(moduleNode)
 	jsv.translate.CodeGraph.CreateModuleScope(ctx, moduleNode)
+	jsv.programID = moduleNode.ID
 	jsv.translate.PushScope(false)
 	defer jsv.translate.PopScope(ctx, moduleNode.ID)
 	childNodes := jsv.translate.TraverseChildren(ctx, tsNode, moduleNode.ID)
@@ -132,13 +137,23 @@ func (jsv *JavaScriptVisitor) handleArrowFunction(ctx context.Context, tsNode *t
 	}
 	bodyNode := jsv.translate.TreeChildByFieldName(tsNode, "body")
 
-	return jsv.translate.CreateFunction(ctx, scopeID, tsNode, "", jsv.translate.NamedChildren(paramsNode), bodyNode)
+	// A single unparenthesized parameter is the parameter itself
+	var params []*tree_sitter.Node
+	if paramsNode != nil && paramsNode.Kind() == "identifier" {
+		params = []*tree_sitter.Node{paramsNode}
+	} else if paramsNode != nil {
+		params = jsv.translate.NamedChildren(paramsNode)
+	}
+	return jsv.translate.CreateClosure(ctx, scopeID, tsNode, params, bodyNode)
 }
 
 func (jsv *JavaScriptVisitor) handleFunctionExpression(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
 	paramsNode := jsv.translate.TreeChildByFieldName(tsNode, "parameters")
 	bodyNode := jsv.translate.TreeChildByFieldName(tsNode, "body")
 
+	if jsv.translate.TreeChildByFieldName(tsNode, "name") == nil {
+		return jsv.translate.CreateClosure(ctx, scopeID, tsNode, jsv.translate.NamedChildren(paramsNode), bodyNode)
+	}
 	return jsv.translate.CreateFunction(ctx, scopeID, tsNode, "", jsv.translate.NamedChildren(paramsNode), bodyNode)
 }
 
@@ -401,18 +416,51 @@ func (jsv *JavaScriptVisitor) handleVariableDeclaration(ctx context.Context, tsN
 	return ast.InvalidNodeID
 }
 
+// handleLexicalDeclaration handles let and const declarations. A top-level const with an
+// UPPER_CASE name, such as const MAX_RETRIES = 3, is recorded as a Constant.
 func (jsv *JavaScriptVisitor) handleLexicalDeclaration(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
+	isConst := jsv.translate.String(jsv.translate.TreeChildByFieldName(tsNode, "kind")) == "const"
 	declarators := jsv.translate.TreeChildrenByKind(tsNode, "variable_declarator")
 	for _, declarator := range declarators {
 		nameNode := jsv.translate.TreeChildByFieldName(declarator, "name")
 		valueNode := jsv.translate.TreeChildByFieldName(declarator, "value")
-		if nameNode != nil && valueNode != nil {
-			jsv.translate.HandleAssignment(ctx, declarator, nameNode, valueNode, scopeID)
+		if nameNode == nil || valueNode == nil {
+			continue
+		}
+		if isConst && scopeID == jsv.programID && nameNode.Kind() == "identifier" &&
+			isConstantName(jsv.translate.String(nameNode)) {
+			jsv.translate.CreateConstant(ctx, declarator, nameNode, valueNode, nil, scopeID)
+			continue
 		}
+		jsv.translate.HandleAssignment(ctx, declarator, nameNode, valueNode, scopeID)
 	}
 	return ast.InvalidNodeID
 }
 
+// handleEnumDeclaration handles a TypeScript enum. Members without an initializer are
+// numbered from the previous member, so only their ordinal is recorded.
+func (jsv *JavaScriptVisitor) handleEnumDeclaration(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
+	body := jsv.translate.TreeChildByFieldName(tsNode, "body")
+	if body == nil {
+		return ast.InvalidNodeID
+	}
+	var members []EnumMember
+	for _, child := range jsv.translate.NamedChildren(body) {
+		switch child.Kind() {
+		case "property_identifier":
+			members = append(members, EnumMember{Decl: child, Name: child})
+		case "enum_assignment":
+			members = append(members, EnumMember{
+				Decl:  child,
+				Name:  jsv.translate.TreeChildByFieldName(child, "name"),
+				Value: jsv.translate.TreeChildByFieldName(child, "value"),
+			})
+		}
+	}
+	name := jsv.translate.String(jsv.translate.TreeChildByFieldName(tsNode, "name"))
+	return jsv.translate.HandleEnum(ctx, scopeID, tsNode, name, members, nil, true)
+}
+
 func (jsv *JavaScriptVisitor) handleSwitchStatement(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
 	discriminantNode := jsv.translate.TreeChildByFieldName(tsNode, "value")
 	bodyNode := jsv.translate.TreeChildByFieldName(tsNode, "body")
@@ -1,9 +1,13 @@
 package parse
 
 import (
+	"bot-go/internal/apperrors"
 	"bot-go/internal/config"
 	"bot-go/internal/model/ast"
 	"bot-go/internal/service/codegraph"
+	"bot-go/internal/util"
+	"bot-go/pkg/lsp/base"
+	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -16,6 +20,7 @@ import (
 	java "github.com/tree-sitter/tree-sitter-java/bindings/go"
 	javascript "github.com/tree-sitter/tree-sitter-javascript/bindings/go"
 	python "github.com/tree-sitter/tree-sitter-python/bindings/go"
+	rust "github.com/tree-sitter/tree-sitter-rust/bindings/go"
 	typescript "github.com/tree-sitter/tree-sitter-typescript/bindings/go"
 	"go.uber.org/zap"
 )
@@ -28,6 +33,7 @@ const (
 	TypeScript
 	Python
 	Java
+	Rust
 	Unknown
 )
 
@@ -50,6 +56,8 @@ func (lt LanguageType) String() string {
 		return "python"
 	case Java:
 		return "java"
+	case Rust:
+		return "rust"
 	default:
 		return "unknown"
 	}
@@ -67,6 +75,8 @@ func NewLanguageTypeFromString(lang string) LanguageType {
 		return Python
 	case "java":
 		return Java
+	case "rust":
+		return Rust
 	default:
 		return Unknown
 	}
@@ -94,6 +104,8 @@ func (fp *FileParser) DetectLanguage(filePath string) LanguageType {
 		return Python
 	case ".java":
 		return Java
+	case ".rs":
+		return Rust
 	default:
 		return Unknown
 	}
@@ -111,8 +123,10 @@ func (fp *FileParser) GetLanguageParser(langType LanguageType) (*tree_sitter.Lan
 		return tree_sitter.NewLanguage(python.Language()), nil
 	case Java:
 		return tree_sitter.NewLanguage(java.Language()), nil
+	case Rust:
+		return tree_sitter.NewLanguage(rust.Language()), nil
 	default:
-		return nil, fmt.Errorf("unsupported language type: %v", langType)
+		return nil, fmt.Errorf("%w type: %v", apperrors.ErrUnsupportedLanguage, langType)
 	}
 }
 
@@ -136,8 +150,11 @@ func (fp *FileParser) GetLanguageVisitor(langType LanguageType, ts *TranslateFro
 	case JavaScript, TypeScript:
 		return NewPrintVisitor(ts), nil
 
+	case Rust:
+		return NewRustVisitor(fp.logger, ts), nil
+
 	default:
-		return nil, fmt.Errorf("unsupported language type: %v", langType)
+		return nil, fmt.Errorf("%w type: %v", apperrors.ErrUnsupportedLanguage, langType)
 	}
 }
 
@@ -167,9 +184,18 @@ func (fp *FileParser) CreateTranslatorWithContent(ctx context.Context, filePath
 	}
 
 	translator := NewTranslateFromSyntaxTree(fileID, version, fp.CodeGraph, content, fp.logger)
+	translator.Options = fp.languageOptions(langType)
 	return tree, translator, nil
 }
 
+// languageOptions returns the code_graph.languages options of a language
+func (fp *FileParser) languageOptions(langType LanguageType) config.LanguageOptions {
+	if fp.Config == nil {
+		return config.LanguageOptions{}
+	}
+	return fp.Config.CodeGraph.Language(langType.String())
+}
+
 func (fp *FileParser) ReadFile(filePath string) ([]byte, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -190,7 +216,7 @@ func (fp *FileParser) relativePath(repo *config.Repository, fullPath string) str
 	if err != nil {
 		return fullPath
 	}
-	return relPath
+	return util.CanonicalPath(relPath)
 }
 
 /*
@@ -199,11 +225,13 @@ func (fp *FileParser) ParseAndTraverse(ctx context.Context, repo *config.Reposit
 	if err != nil {
 		return fmt.Errorf("failed to read file %s: %w", filePath, err)
 	}
-	return fp.ParseAndTraverseWithContent(ctx, repo, info, filePath, fileID, version, content)
+	return fp.ParseAndTraverseWithContent(ctx, repo, info, filePath, fileID, version, "", content)
 }
 */
 
-func (fp *FileParser) ParseAndTraverseWithContent(ctx context.Context, repo *config.Repository, info os.FileInfo, filePath string, fileID int32, version int32, content []byte) error {
+// ParseAndTraverseWithContent builds the code graph for a file. commitSHA is recorded on the
+// FileScope so graph nodes can be joined with chunks and n-gram models by FileID and commit.
+func (fp *FileParser) ParseAndTraverseWithContent(ctx context.Context, repo *config.Repository, info os.FileInfo, filePath string, fileID int32, version int32, commitSHA string, content []byte) error {
 	languageType := fp.DetectLanguage(filePath)
 	if languageType == Unknown {
 		return fmt.Errorf("unsupported file type for file: %s", filePath)
@@ -239,6 +267,9 @@ func (fp *FileParser) ParseAndTraverseWithContent(ctx context.Context, repo *con
 		"modified": info.ModTime().Unix(),
 		"language": languageType.String(),
 	}
+	if commitSHA != "" {
+		fileScope.MetaData["commit"] = commitSHA
+	}
 
 	fp.CodeGraph.CreateFileScope(ctx, fileScope)
 
@@ -254,6 +285,36 @@ func (fp *FileParser) ParseAndTraverseWithContent(ctx context.Context, repo *con
 	return nil
 }
 
+// CreateFileScopeOnly records a file in the graph without parsing it, for files routed to
+// the lightweight indexing path (see util.LightweightReason). The FileScope spans the whole
+// file and carries the reason in its "lightweight" metadata.
+func (fp *FileParser) CreateFileScopeOnly(ctx context.Context, repo *config.Repository, info os.FileInfo, filePath string, fileID int32, version int32, commitSHA string, content []byte, reason string) error {
+	languageType := fp.DetectLanguage(filePath)
+	if languageType == Unknown {
+		return fmt.Errorf("unsupported file type for file: %s", filePath)
+	}
+
+	lines := bytes.Count(content, []byte{'\n'})
+	fileScope := ast.NewNode(
+		ast.NodeID(fileID), ast.NodeTypeFileScope,
+		fileID,
+		filepath.Base(filePath),
+		base.Range{End: base.Position{Line: lines}},
+		version, ast.InvalidNodeID,
+	)
+	fileScope.MetaData = map[string]any{
+		"repo":        repo.Name,
+		"path":        fp.relativePath(repo, filePath),
+		"modified":    info.ModTime().Unix(),
+		"language":    languageType.String(),
+		"lightweight": reason,
+	}
+	if commitSHA != "" {
+		fileScope.MetaData["commit"] = commitSHA
+	}
+	return fp.CodeGraph.CreateFileScope(ctx, fileScope)
+}
+
 func (fp *FileParser) ShouldSkipFile(ctx context.Context, repo *config.Repository, info os.FileInfo, filePath string) bool {
 	// Skip common directories and files that shouldn't be parsed
 	skipPaths := []string{
@@ -284,6 +345,11 @@ func (fp *FileParser) ShouldSkipFile(ctx context.Context, repo *config.Repositor
 		return true
 	}
 
+	if fp.languageOptions(languageType).SkipTestFiles && util.IsTestFile(fp.relativePath(repo, filePath)) {
+		fp.logger.Debug("Skipping test file", zap.String("path", filePath))
+		return true
+	}
+
 	fileScopes, err := fp.CodeGraph.FindFileScopes(ctx, repo.Name, fp.relativePath(repo, filePath))
 	if err != nil {
 		//fp.logger.Error("Failed to find file scopes", zap.String("path", filePath), zap.Error(err))
@@ -306,6 +372,17 @@ func (fp *FileParser) ShouldSkipFile(ctx context.Context, repo *config.Repositor
 	return false
 }
 
+// ExpectsFileScope reports whether a repository-relative file gets a FileScope: its language
+// is parsed for the repository and it is not a test file skipped by skip_test_files. Files it
+// rejects are skipped by ShouldSkipFile.
+func (fp *FileParser) ExpectsFileScope(repo *config.Repository, relPath string) bool {
+	languageType := fp.DetectLanguage(relPath)
+	if languageType == Unknown || !fp.isAllowedFileExtensionsInRepo(repo, languageType) {
+		return false
+	}
+	return !(fp.languageOptions(languageType).SkipTestFiles && util.IsTestFile(relPath))
+}
+
 func (fp *FileParser) isAllowedFileExtensionsInRepo(repo *config.Repository, languageType LanguageType) bool {
 	switch repo.Language {
 	case "python":
@@ -318,6 +395,8 @@ func (fp *FileParser) isAllowedFileExtensionsInRepo(repo *config.Repository, lan
 		return languageType == Go
 	case "java":
 		return languageType == Java
+	case "rust":
+		return languageType == Rust
 	default:
 		return false
 	}
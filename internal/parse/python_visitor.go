@@ -3,6 +3,7 @@ package parse
 import (
 	"bot-go/internal/model/ast"
 	"context"
+	"strings"
 
 	tree_sitter "github.com/tree-sitter/go-tree-sitter"
 	"go.uber.org/zap"
@@ -11,6 +12,7 @@ import (
 type PythonVisitor struct {
 	translate *TranslateFromSyntaxTree
 	logger    *zap.Logger
+	moduleID  ast.NodeID
 }
 
 func NewPythonVisitor(logger *zap.Logger, ts *TranslateFromSyntaxTree) *PythonVisitor {
@@ -34,6 +36,10 @@ func (pv *PythonVisitor) TraverseNode(ctx context.Context, tsNode *tree_sitter.N
 		return pv.translate.HandleBlock(ctx, tsNode, scopeID)
 	case "class_definition":
 		return pv.handleClassDefinition(ctx, tsNode, scopeID)
+	case "decorated_definition":
+		return pv.handleDecoratedDefinition(ctx, tsNode, scopeID)
+	case "lambda":
+		return pv.handleLambda(ctx, tsNode, scopeID)
 	case "return_statement":
 		return pv.handleReturnStatement(ctx, tsNode, scopeID)
 	case "call":
@@ -76,7 +82,9 @@ func (pv *PythonVisitor) handleModule(ctx context.Context, tsNode *tree_sitter.N
 		pv.translate.GetTreeNodeName(tsNode), pv.translate.ToRange(tsNode), pv.translate.Version,
 		ast.NodeID(pv.translate.FileID),
 	)
+	pv.translate.AssignNodeKey(moduleNode)
 	pv.translate.CodeGraph.CreateModuleScope(ctx, moduleNode)
+	pv.moduleID = moduleNode.ID
 	pv.translate.PushScope(false)
 	defer pv.translate.PopScope(ctx, moduleNode.ID)
 	childNodes := pv.translate.TraverseChildren(ctx, tsNode, moduleNode.ID)
@@ -93,15 +101,141 @@ func (pv *PythonVisitor) handleFunctionDefinition(ctx context.Context, tsNode *t
 	return pv.translate.CreateFunction(ctx, scopeID, tsNode, "", pv.translate.NamedChildren(paramsNode), bodyNode)
 }
 
+func (pv *PythonVisitor) handleLambda(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
+	var params []*tree_sitter.Node
+	if paramsNode := pv.translate.TreeChildByFieldName(tsNode, "parameters"); paramsNode != nil {
+		params = pv.translate.NamedChildren(paramsNode)
+	}
+	bodyNode := pv.translate.TreeChildByFieldName(tsNode, "body")
+	return pv.translate.CreateClosure(ctx, scopeID, tsNode, params, bodyNode)
+}
+
 func (pv *PythonVisitor) handleClassDefinition(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
+	if pv.translate.Options.SkipTypeOnly && pv.hasBase(tsNode, protocolBases) {
+		return ast.InvalidNodeID
+	}
+	if pv.isEnumClass(tsNode) {
+		return pv.handleEnumClass(ctx, tsNode, scopeID)
+	}
 	body := pv.translate.TreeChildByFieldName(tsNode, "body")
 	var methods []*tree_sitter.Node
 	if body != nil {
-		methods = pv.translate.TreeChildrenByKind(body, "function_definition")
+		for _, child := range pv.translate.NamedChildren(body) {
+			if child.Kind() == "function_definition" || child.Kind() == "decorated_definition" {
+				methods = append(methods, child)
+			}
+		}
 	}
 	return pv.translate.HandleClass(ctx, scopeID, tsNode, "", methods, nil)
 }
 
+// enumBases are the base classes that make a class an enum
+var enumBases = map[string]bool{"Enum": true, "IntEnum": true, "StrEnum": true, "Flag": true, "IntFlag": true}
+
+// protocolBases are the base classes of structural types, which only declare methods
+var protocolBases = map[string]bool{"Protocol": true}
+
+// isEnumClass reports whether a class derives from one of the enum base classes, directly
+// as in class Color(Enum) or qualified as in class Color(enum.Enum)
+func (pv *PythonVisitor) isEnumClass(tsNode *tree_sitter.Node) bool {
+	return pv.hasBase(tsNode, enumBases)
+}
+
+// hasBase reports whether a class derives from one of bases, by plain or qualified name
+func (pv *PythonVisitor) hasBase(tsNode *tree_sitter.Node, bases map[string]bool) bool {
+	superclasses := pv.translate.TreeChildByFieldName(tsNode, "superclasses")
+	if superclasses == nil {
+		return false
+	}
+	for _, base := range pv.translate.NamedChildren(superclasses) {
+		name := pv.translate.String(base)
+		if bases[name[strings.LastIndex(name, ".")+1:]] {
+			return true
+		}
+	}
+	return false
+}
+
+// handleEnumClass handles an enum class. Assignments to plain names in the class body are
+// its members and functions are its methods.
+func (pv *PythonVisitor) handleEnumClass(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
+	var members []EnumMember
+	var methods []*tree_sitter.Node
+	if body := pv.translate.TreeChildByFieldName(tsNode, "body"); body != nil {
+		for _, child := range pv.translate.NamedChildren(body) {
+			switch child.Kind() {
+			case "function_definition", "decorated_definition":
+				methods = append(methods, child)
+			case "expression_statement":
+				assignment := child.NamedChild(0)
+				if assignment == nil || assignment.Kind() != "assignment" {
+					continue
+				}
+				lhs := pv.translate.TreeChildByFieldName(assignment, "left")
+				if lhs == nil || lhs.Kind() != "identifier" {
+					continue
+				}
+				members = append(members, EnumMember{
+					Decl:  assignment,
+					Name:  lhs,
+					Value: pv.translate.TreeChildByFieldName(assignment, "right"),
+				})
+			}
+		}
+	}
+	return pv.translate.HandleEnum(ctx, scopeID, tsNode, "", members, methods, true)
+}
+
+// handleDecoratedDefinition handles a function or class with decorators. Decorator
+// expressions are evaluated in the enclosing scope, as Python does, and each decorator
+// becomes an Annotation of the definition.
+func (pv *PythonVisitor) handleDecoratedDefinition(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
+	decorators := pv.translate.TreeChildrenByKind(tsNode, "decorator")
+	for _, decorator := range decorators {
+		pv.translate.TraverseChildren(ctx, decorator, scopeID)
+	}
+
+	definitionID := pv.TraverseNode(ctx, pv.translate.TreeChildByFieldName(tsNode, "definition"), scopeID)
+	if definitionID == ast.InvalidNodeID {
+		return ast.InvalidNodeID
+	}
+	for i, decorator := range decorators {
+		pv.handleDecorator(ctx, decorator, definitionID, i)
+	}
+	return definitionID
+}
+
+// handleDecorator records a decorator such as @app.route("/users", methods=["GET"]) as an
+// Annotation named app.route with its arguments
+func (pv *PythonVisitor) handleDecorator(ctx context.Context, decorator *tree_sitter.Node, targetID ast.NodeID, position int) {
+	expr := decorator.NamedChild(0)
+	if expr == nil {
+		return
+	}
+	var args []string
+	namedArgs := make(map[string]string)
+	if expr.Kind() == "call" {
+		if argList := pv.translate.TreeChildByFieldName(expr, "arguments"); argList != nil {
+			for _, arg := range pv.translate.NamedChildren(argList) {
+				switch arg.Kind() {
+				case "keyword_argument":
+					name := pv.translate.TreeChildByFieldName(arg, "name")
+					value := pv.translate.TreeChildByFieldName(arg, "value")
+					if name != nil && value != nil {
+						namedArgs[pv.translate.String(name)] = pv.translate.String(value)
+					}
+				case "comment":
+					// Not an argument
+				default:
+					args = append(args, pv.translate.String(arg))
+				}
+			}
+		}
+		expr = pv.translate.TreeChildByFieldName(expr, "function")
+	}
+	pv.translate.CreateAnnotation(ctx, decorator, pv.translate.String(expr), targetID, position, args, namedArgs)
+}
+
 func (pv *PythonVisitor) handleReturnStatement(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
 	if tsNode.ChildCount() < 2 {
 		return ast.InvalidNodeID
@@ -157,6 +291,12 @@ func (pv *PythonVisitor) handleIfStatement(ctx context.Context, tsNode *tree_sit
 	if elseNode != nil {
 		branches = append(branches, elseNode)
 	}
+	// if TYPE_CHECKING: holds imports for type annotations only
+	if pv.translate.Options.SkipTypeOnly && len(branches) == 1 && conditionNode != nil {
+		if cond := pv.translate.String(conditionNode); cond == "TYPE_CHECKING" || cond == "typing.TYPE_CHECKING" {
+			return ast.InvalidNodeID
+		}
+	}
 	return pv.translate.HandleConditional(ctx, tsNode, conditions, branches, scopeID)
 }
 
@@ -203,5 +343,11 @@ func (pv *PythonVisitor) handleAssignment(ctx context.Context, tsNode *tree_sitt
 	lhsNode := tsNode.Child(0)
 	rhsNode := tsNode.Child(2)
 
+	// UPPER_CASE names assigned at module level are constants by convention
+	if scopeID == pv.moduleID && lhsNode.Kind() == "identifier" && isConstantName(pv.translate.String(lhsNode)) {
+		if value := pv.translate.TreeChildByFieldName(tsNode, "right"); value != nil {
+			return pv.translate.CreateConstant(ctx, tsNode, lhsNode, value, nil, scopeID)
+		}
+	}
 	return pv.translate.HandleAssignment(ctx, tsNode, lhsNode, rhsNode, scopeID)
 }
@@ -0,0 +1,71 @@
+package parse
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"bot-go/internal/config"
+	"bot-go/internal/model/ast"
+)
+
+func TestPythonDecorators(t *testing.T) {
+	g := translateSource(t, "views.py", `@dataclass(frozen=True)
+class User:
+    name: str
+
+    @property
+    @cache
+    def display(self):
+        return self.name
+
+@app.route("/users", methods=["GET"])
+def list_users():
+    return []
+`, config.LanguageOptions{})
+
+	// The decorated method is indexed along with the decorated function
+	if got := g.names(ast.NodeTypeFunction); !slices.Contains(got, "display") || !slices.Contains(got, "list_users") {
+		t.Errorf("functions = %v, want display and list_users", got)
+	}
+	want := []string{"User -> dataclass", "display -> cache", "display -> property", "list_users -> app.route"}
+	if got := g.related("ANNOTATION"); !slices.Equal(got, want) {
+		t.Errorf("ANNOTATION = %v, want %v", got, want)
+	}
+	if got := g.related("CONTAINS"); !slices.Contains(got, "list_users -> app.route") {
+		t.Errorf("list_users does not contain its decorator, CONTAINS = %v", got)
+	}
+
+	tests := []struct {
+		name                string
+		position            int
+		arguments, namedArg string // formatted metadata, empty if absent
+	}{
+		{"dataclass", 0, "", `{"frozen":"True"}`},
+		{"property", 0, "", ""},
+		{"cache", 1, "", ""},
+		{"app.route", 0, `["/users"]`, `{"methods":"[\"GET\"]"}`},
+	}
+	for _, tt := range tests {
+		i := slices.IndexFunc(g.nodes, func(n recordedNode) bool {
+			return n.nodeType == ast.NodeTypeAnnotation && n.name == tt.name
+		})
+		if i < 0 {
+			t.Errorf("annotation %s missing", tt.name)
+			continue
+		}
+		props := g.nodes[i].props
+		if got := fmt.Sprint(props["md_position"]); got != fmt.Sprint(tt.position) {
+			t.Errorf("%s: position %s, want %d", tt.name, got, tt.position)
+		}
+		for key, want := range map[string]string{"md_arguments": tt.arguments, "md_named_arguments": tt.namedArg} {
+			got := ""
+			if v, ok := props[key]; ok {
+				got = fmt.Sprint(v)
+			}
+			if got != want {
+				t.Errorf("%s: %s = %s, want %s", tt.name, key, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,486 @@
+package parse
+
+import (
+	"bot-go/internal/model/ast"
+	"bot-go/pkg/lsp/base"
+	"context"
+	"strings"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	"go.uber.org/zap"
+)
+
+// RustVisitor translates Rust syntax trees. Structs and traits become classes, enums
+// become enums, and the functions of an impl block become methods of the type it
+// implements, like Go's methods of their receiver type.
+type RustVisitor struct {
+	translate *TranslateFromSyntaxTree
+	logger    *zap.Logger
+}
+
+func NewRustVisitor(logger *zap.Logger, ts *TranslateFromSyntaxTree) *RustVisitor {
+	return &RustVisitor{
+		translate: ts,
+		logger:    logger,
+	}
+}
+
+func (rv *RustVisitor) TraverseNode(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
+	if tsNode == nil {
+		return ast.InvalidNodeID
+	}
+
+	switch tsNode.Kind() {
+	case "source_file":
+		return rv.handleSourceFile(ctx, tsNode)
+	case "function_item", "function_signature_item":
+		return rv.handleFunctionItem(ctx, tsNode, scopeID)
+	case "closure_expression":
+		return rv.handleClosureExpression(ctx, tsNode, scopeID)
+	case "block":
+		return rv.translate.HandleBlock(ctx, tsNode, scopeID)
+	case "struct_item":
+		return rv.handleStructItem(ctx, tsNode, scopeID)
+	case "trait_item":
+		return rv.handleTraitItem(ctx, tsNode, scopeID)
+	case "enum_item":
+		return rv.handleEnumItem(ctx, tsNode, scopeID)
+	case "impl_item":
+		return rv.handleImplItem(ctx, tsNode, scopeID)
+	case "use_declaration":
+		return rv.handleUseDeclaration(ctx, tsNode, scopeID)
+	case "const_item":
+		return rv.handleConstItem(ctx, tsNode, scopeID)
+	case "static_item", "let_declaration":
+		return rv.handleLetDeclaration(ctx, tsNode, scopeID)
+	case "assignment_expression", "compound_assignment_expr":
+		return rv.handleAssignmentExpression(ctx, tsNode, scopeID)
+	case "return_expression":
+		return rv.handleReturnExpression(ctx, tsNode, scopeID)
+	case "call_expression":
+		return rv.handleCallExpression(ctx, tsNode, scopeID)
+	case "field_expression":
+		return rv.handleFieldExpression(ctx, tsNode, scopeID)
+	case "scoped_identifier":
+		return rv.handleScopedIdentifier(ctx, tsNode, scopeID)
+	case "identifier", "self":
+		return rv.translate.HandleIdentifier(ctx, tsNode, scopeID)
+	case "if_expression":
+		return rv.handleIfExpression(ctx, tsNode, scopeID)
+	case "match_expression":
+		return rv.handleMatchExpression(ctx, tsNode, scopeID)
+	case "for_expression":
+		return rv.handleForExpression(ctx, tsNode, scopeID)
+	case "while_expression":
+		return rv.handleWhileExpression(ctx, tsNode, scopeID)
+	case "loop_expression":
+		body := rv.translate.TreeChildByFieldName(tsNode, "body")
+		if body == nil {
+			return ast.InvalidNodeID
+		}
+		return rv.translate.HandleLoop(ctx, tsNode, ast.InvalidNodeID, ast.InvalidNodeID, body, scopeID)
+	case "line_comment", "block_comment", "attribute_item", "inner_attribute_item":
+		return ast.InvalidNodeID
+	default:
+		rv.translate.TraverseChildren(ctx, tsNode, scopeID)
+		return ast.InvalidNodeID
+	}
+}
+
+// handleSourceFile creates the module scope of a file. Inline modules (mod x { ... }) are
+// traversed as part of it.
+func (rv *RustVisitor) handleSourceFile(ctx context.Context, tsNode *tree_sitter.Node) ast.NodeID {
+	moduleNode := ast.NewNode(
+		rv.translate.NextNodeID(), ast.NodeTypeModuleScope, rv.translate.FileID,
+		rv.translate.GetTreeNodeName(tsNode), rv.translate.ToRange(tsNode), rv.translate.Version,
+		ast.NodeID(rv.translate.FileID),
+	)
+	rv.translate.AssignNodeKey(moduleNode)
+	rv.translate.CodeGraph.CreateModuleScope(ctx, moduleNode)
+	rv.translate.PushScope(false)
+	defer rv.translate.PopScope(ctx, moduleNode.ID)
+	childNodes := rv.translate.TraverseChildren(ctx, tsNode, moduleNode.ID)
+	if len(childNodes) > 0 {
+		rv.translate.CreateContainsRelations(ctx, moduleNode.ID, childNodes)
+	}
+	return moduleNode.ID
+}
+
+// handleFunctionItem handles a function, or a function signature in a trait. The self
+// parameter of a method is its first parameter, as in Python.
+func (rv *RustVisitor) handleFunctionItem(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
+	var params []*tree_sitter.Node
+	if paramsNode := rv.translate.TreeChildByFieldName(tsNode, "parameters"); paramsNode != nil {
+		for _, param := range rv.translate.NamedChildren(paramsNode) {
+			if param.Kind() == "parameter" || param.Kind() == "self_parameter" {
+				params = append(params, param)
+			}
+		}
+	}
+	bodyNode := rv.translate.TreeChildByFieldName(tsNode, "body")
+
+	return rv.translate.CreateFunction(ctx, scopeID, tsNode, "", params, bodyNode)
+}
+
+func (rv *RustVisitor) handleClosureExpression(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
+	var params []*tree_sitter.Node
+	if paramsNode := rv.translate.TreeChildByFieldName(tsNode, "parameters"); paramsNode != nil {
+		params = rv.translate.NamedChildren(paramsNode)
+	}
+	bodyNode := rv.translate.TreeChildByFieldName(tsNode, "body")
+	return rv.translate.CreateClosure(ctx, scopeID, tsNode, params, bodyNode)
+}
+
+// handleStructItem creates a class with the named fields of a struct. Tuple structs have
+// no named fields.
+func (rv *RustVisitor) handleStructItem(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
+	var fields []*tree_sitter.Node
+	if body := rv.translate.TreeChildByFieldName(tsNode, "body"); body != nil && body.Kind() == "field_declaration_list" {
+		for _, decl := range rv.translate.TreeChildrenByKind(body, "field_declaration") {
+			if name := rv.translate.TreeChildByFieldName(decl, "name"); name != nil {
+				fields = append(fields, name)
+			}
+		}
+	}
+	return rv.translate.HandleClass(ctx, scopeID, tsNode, rv.itemName(tsNode), nil, fields)
+}
+
+// handleTraitItem creates a class with the methods of a trait, with or without a default
+// body. With skip_type_only, traits are skipped as they declare no behaviour of their own.
+func (rv *RustVisitor) handleTraitItem(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
+	if rv.translate.Options.SkipTypeOnly {
+		return ast.InvalidNodeID
+	}
+	return rv.translate.HandleClass(ctx, scopeID, tsNode, rv.itemName(tsNode), rv.declarations(tsNode), nil)
+}
+
+// handleEnumItem creates an enum whose members are its variants, reachable through the
+// enum as in Color::Red
+func (rv *RustVisitor) handleEnumItem(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
+	var members []EnumMember
+	if body := rv.translate.TreeChildByFieldName(tsNode, "body"); body != nil {
+		for _, variant := range rv.translate.TreeChildrenByKind(body, "enum_variant") {
+			members = append(members, EnumMember{
+				Decl:  variant,
+				Name:  rv.translate.TreeChildByFieldName(variant, "name"),
+				Value: rv.translate.TreeChildByFieldName(variant, "value"),
+			})
+		}
+	}
+	return rv.translate.HandleEnum(ctx, scopeID, tsNode, rv.itemName(tsNode), members, nil, true)
+}
+
+// handleImplItem adds the functions of an impl block to the class of the implemented type,
+// declared in this file or created as a fake class. Trait impls add to the type as well.
+func (rv *RustVisitor) handleImplItem(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
+	typeNode := rv.translate.TreeChildByFieldName(tsNode, "type")
+	if typeNode == nil {
+		return ast.InvalidNodeID
+	}
+	// Store<T> and crate::Store are methods of Store
+	if generic := rv.translate.TreeChildByFieldName(typeNode, "type"); typeNode.Kind() == "generic_type" && generic != nil {
+		typeNode = generic
+	}
+	className := rv.translate.String(typeNode)
+	if i := strings.LastIndex(className, "::"); i >= 0 {
+		className = className[i+2:]
+	}
+
+	classNodes, err := rv.translate.CodeGraph.FindNodesByNameAndTypeInFile(ctx, className, ast.NodeTypeClass, rv.translate.FileID)
+	if err != nil {
+		rv.logger.Error("Error in find class for impl",
+			zap.String("class_name", className),
+			zap.Int32("file_id", rv.translate.FileID),
+			zap.Error(err))
+		return ast.InvalidNodeID
+	}
+	var classNode *ast.Node
+	if len(classNodes) > 0 {
+		classNode = classNodes[0]
+	} else {
+		classNode = rv.createFakeClass(ctx, className, scopeID)
+	}
+
+	for _, fn := range rv.declarations(tsNode) {
+		if functionID := rv.TraverseNode(ctx, fn, classNode.ID); functionID != ast.InvalidNodeID {
+			rv.translate.CreateContainsRelation(ctx, classNode.ID, functionID, rv.translate.FileID)
+		}
+	}
+	return ast.InvalidNodeID
+}
+
+func (rv *RustVisitor) createFakeClass(ctx context.Context, className string, scopeID ast.NodeID) *ast.Node {
+	classNode := ast.NewNode(
+		rv.translate.NextNodeID(), ast.NodeTypeClass, rv.translate.FileID,
+		className, base.Range{}, rv.translate.Version,
+		scopeID,
+	)
+	classNode.MetaData = map[string]any{
+		"is_fake": true,
+	}
+	rv.translate.AssignNodeKey(classNode)
+	rv.translate.CodeGraph.CreateClass(ctx, classNode)
+	return classNode
+}
+
+// declarations returns the functions and function signatures in the body of a trait or
+// impl block
+func (rv *RustVisitor) declarations(tsNode *tree_sitter.Node) []*tree_sitter.Node {
+	body := rv.translate.TreeChildByFieldName(tsNode, "body")
+	if body == nil {
+		return nil
+	}
+	var fns []*tree_sitter.Node
+	for _, child := range rv.translate.NamedChildren(body) {
+		if child.Kind() == "function_item" || child.Kind() == "function_signature_item" {
+			fns = append(fns, child)
+		}
+	}
+	return fns
+}
+
+// itemName returns the name of a struct, trait or enum
+func (rv *RustVisitor) itemName(tsNode *tree_sitter.Node) string {
+	return rv.translate.String(rv.translate.TreeChildByFieldName(tsNode, "name"))
+}
+
+// handleUseDeclaration creates an Import node for every name a use declaration brings
+// into scope, so paths starting with it resolve to the import:
+//
+//	use std::collections::HashMap;       // HashMap
+//	use std::io::{self, Write as W};     // io, W
+//	use crate::store::*;                 // nothing: glob imports name no symbol
+func (rv *RustVisitor) handleUseDeclaration(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
+	rv.handleUseTree(ctx, tsNode, rv.translate.TreeChildByFieldName(tsNode, "argument"), "", scopeID)
+	return ast.InvalidNodeID
+}
+
+func (rv *RustVisitor) handleUseTree(ctx context.Context, decl, tree *tree_sitter.Node, prefix string, scopeID ast.NodeID) {
+	if tree == nil {
+		return
+	}
+	switch tree.Kind() {
+	case "use_list":
+		for _, item := range rv.translate.NamedChildren(tree) {
+			rv.handleUseTree(ctx, decl, item, prefix, scopeID)
+		}
+	case "scoped_use_list":
+		path := prefix + rv.translate.String(rv.translate.TreeChildByFieldName(tree, "path"))
+		rv.handleUseTree(ctx, decl, rv.translate.TreeChildByFieldName(tree, "list"), path+"::", scopeID)
+	case "use_as_clause":
+		path := prefix + rv.translate.String(rv.translate.TreeChildByFieldName(tree, "path"))
+		rv.createImport(ctx, decl, rv.translate.String(rv.translate.TreeChildByFieldName(tree, "alias")), path, scopeID)
+	case "identifier", "scoped_identifier", "self", "crate", "super":
+		// use std::io::{self} imports io
+		path := strings.TrimSuffix(prefix+rv.translate.String(tree), "::self")
+		segments := strings.Split(path, "::")
+		rv.createImport(ctx, decl, segments[len(segments)-1], path, scopeID)
+	}
+}
+
+func (rv *RustVisitor) createImport(ctx context.Context, decl *tree_sitter.Node, name, path string, scopeID ast.NodeID) {
+	if name == "" || name == "_" || name == "self" || name == "crate" || name == "super" {
+		return
+	}
+	importNode := ast.NewNode(
+		rv.translate.NextNodeID(),
+		ast.NodeTypeImport,
+		rv.translate.FileID,
+		name,
+		rv.translate.ToRange(decl),
+		rv.translate.Version,
+		scopeID,
+	)
+	rv.translate.AssignNodeKey(importNode)
+	importNode.MetaData = map[string]any{
+		"importPath": path,
+	}
+	rv.translate.CodeGraph.CreateImport(ctx, importNode)
+	rv.translate.CurrentScope.AddSymbol(NewSymbol(importNode))
+	rv.translate.Nodes[importNode.ID] = importNode
+}
+
+func (rv *RustVisitor) handleConstItem(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
+	nameNode := rv.translate.TreeChildByFieldName(tsNode, "name")
+	valueNode := rv.translate.TreeChildByFieldName(tsNode, "value")
+	return rv.translate.CreateConstant(ctx, tsNode, nameNode, valueNode, nil, scopeID)
+}
+
+// handleLetDeclaration handles let bindings and statics. The else block of let-else is
+// traversed in the enclosing scope.
+func (rv *RustVisitor) handleLetDeclaration(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
+	lhsNode := rv.translate.TreeChildByFieldName(tsNode, "pattern")
+	if lhsNode == nil {
+		lhsNode = rv.translate.TreeChildByFieldName(tsNode, "name")
+	}
+	valueNode := rv.translate.TreeChildByFieldName(tsNode, "value")
+	if lhsNode == nil {
+		return ast.InvalidNodeID
+	}
+	if alternative := rv.translate.TreeChildByFieldName(tsNode, "alternative"); alternative != nil {
+		rv.TraverseNode(ctx, alternative, scopeID)
+	}
+	if valueNode == nil {
+		return rv.TraverseNode(ctx, lhsNode, scopeID)
+	}
+	return rv.translate.HandleAssignment(ctx, tsNode, lhsNode, valueNode, scopeID)
+}
+
+func (rv *RustVisitor) handleAssignmentExpression(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
+	leftNode := rv.translate.TreeChildByFieldName(tsNode, "left")
+	rightNode := rv.translate.TreeChildByFieldName(tsNode, "right")
+	return rv.translate.HandleAssignment(ctx, tsNode, leftNode, rightNode, scopeID)
+}
+
+func (rv *RustVisitor) handleReturnExpression(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
+	return rv.translate.HandleReturn(ctx, tsNode.NamedChild(0), scopeID)
+}
+
+func (rv *RustVisitor) handleCallExpression(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
+	functionNode := rv.translate.TreeChildByFieldName(tsNode, "function")
+	argumentsNode := rv.translate.TreeChildByFieldName(tsNode, "arguments")
+
+	var args []*tree_sitter.Node
+	if argumentsNode != nil {
+		for _, arg := range rv.translate.NamedChildren(argumentsNode) {
+			if arg.Kind() != "line_comment" && arg.Kind() != "block_comment" && arg.Kind() != "attribute_item" {
+				args = append(args, arg)
+			}
+		}
+	}
+
+	fnNameNodeID := rv.translate.HandleRhsWithFakeVariable(ctx, "__fn__", functionNode, scopeID, nil)
+	return rv.translate.HandleCall(ctx, fnNameNodeID, args, scopeID, rv.translate.ToRange(tsNode))
+}
+
+// handleFieldExpression resolves value.field, e.g. self.store
+func (rv *RustVisitor) handleFieldExpression(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
+	var names []*tree_sitter.Node
+	if valueNode := rv.translate.TreeChildByFieldName(tsNode, "value"); valueNode != nil {
+		names = append(names, valueNode)
+	}
+	if fieldNode := rv.translate.TreeChildByFieldName(tsNode, "field"); fieldNode != nil {
+		names = append(names, fieldNode)
+	}
+
+	resolvedNodeId := rv.translate.ResolveNameChain(ctx, names, scopeID)
+	if rv.translate.CurrentScope.IsRhs() && resolvedNodeId != ast.InvalidNodeID {
+		rv.translate.CurrentScope.AddRhsVar(resolvedNodeId)
+	}
+	return resolvedNodeId
+}
+
+// handleScopedIdentifier resolves a path such as io::stdin or Color::Red when its first
+// segment is in scope. Other paths, e.g. Vec::new, resolve to nothing; a call through
+// them is named after the whole path.
+func (rv *RustVisitor) handleScopedIdentifier(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
+	segments := rv.pathSegments(tsNode)
+	if len(segments) == 0 || rv.translate.CurrentScope.Resolve(rv.translate.String(segments[0])) == nil {
+		return ast.InvalidNodeID
+	}
+
+	resolvedNodeId := rv.translate.ResolveNameChain(ctx, segments, scopeID)
+	if rv.translate.CurrentScope.IsRhs() && resolvedNodeId != ast.InvalidNodeID {
+		rv.translate.CurrentScope.AddRhsVar(resolvedNodeId)
+	}
+	return resolvedNodeId
+}
+
+// pathSegments returns the identifiers of a path, or nil when it has other segments such
+// as crate, super or generic types
+func (rv *RustVisitor) pathSegments(tsNode *tree_sitter.Node) []*tree_sitter.Node {
+	switch tsNode.Kind() {
+	case "identifier":
+		return []*tree_sitter.Node{tsNode}
+	case "scoped_identifier":
+		pathNode := rv.translate.TreeChildByFieldName(tsNode, "path")
+		nameNode := rv.translate.TreeChildByFieldName(tsNode, "name")
+		if pathNode == nil || nameNode == nil || nameNode.Kind() != "identifier" {
+			return nil
+		}
+		segments := rv.pathSegments(pathNode)
+		if segments == nil {
+			return nil
+		}
+		return append(segments, nameNode)
+	}
+	return nil
+}
+
+// handleIfExpression handles an if expression and its else-if chain as one conditional.
+// The bindings of if let are traversed as part of the condition.
+func (rv *RustVisitor) handleIfExpression(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
+	var conditions []*tree_sitter.Node
+	var branches []*tree_sitter.Node
+	for ifNode := tsNode; ifNode != nil; {
+		conditions = append(conditions, rv.translate.TreeChildByFieldName(ifNode, "condition"))
+		branches = append(branches, rv.translate.TreeChildByFieldName(ifNode, "consequence"))
+
+		elseClause := rv.translate.TreeChildByFieldName(ifNode, "alternative")
+		ifNode = nil
+		if elseClause == nil {
+			break
+		}
+		if alternative := elseClause.NamedChild(0); alternative != nil {
+			if alternative.Kind() == "if_expression" {
+				ifNode = alternative
+			} else {
+				branches = append(branches, alternative)
+			}
+		}
+	}
+	return rv.translate.HandleConditional(ctx, tsNode, conditions, branches, scopeID)
+}
+
+// handleMatchExpression handles a match like Go's switch: the scrutinee and the arm
+// patterns are its conditions, the arm values its branches
+func (rv *RustVisitor) handleMatchExpression(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
+	valueNode := rv.translate.TreeChildByFieldName(tsNode, "value")
+	if valueNode == nil {
+		return ast.InvalidNodeID
+	}
+	conditions := []*tree_sitter.Node{valueNode}
+	var branches []*tree_sitter.Node
+	if body := rv.translate.TreeChildByFieldName(tsNode, "body"); body != nil {
+		for _, arm := range rv.translate.TreeChildrenByKind(body, "match_arm") {
+			if pattern := rv.translate.TreeChildByFieldName(arm, "pattern"); pattern != nil {
+				conditions = append(conditions, pattern)
+			}
+			if value := rv.translate.TreeChildByFieldName(arm, "value"); value != nil {
+				branches = append(branches, value)
+			}
+		}
+	}
+	return rv.translate.HandleConditional(ctx, tsNode, conditions, branches, scopeID)
+}
+
+func (rv *RustVisitor) handleForExpression(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
+	patternNode := rv.translate.TreeChildByFieldName(tsNode, "pattern")
+	valueNode := rv.translate.TreeChildByFieldName(tsNode, "value")
+	body := rv.translate.TreeChildByFieldName(tsNode, "body")
+	if valueNode == nil || body == nil {
+		return ast.InvalidNodeID
+	}
+
+	var inits []*tree_sitter.Node
+	if patternNode != nil {
+		inits = append(inits, patternNode)
+	}
+	inits = append(inits, valueNode)
+
+	rv.translate.PushScope(false)
+	defer rv.translate.PopScope(ctx, ast.InvalidNodeID)
+
+	initCondID := rv.translate.HandleRhsExprsWithFakeVariable(ctx, "__init__", inits, scopeID, nil)
+	return rv.translate.HandleLoop(ctx, tsNode, ast.InvalidNodeID, initCondID, body, scopeID)
+}
+
+func (rv *RustVisitor) handleWhileExpression(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
+	conditionNode := rv.translate.TreeChildByFieldName(tsNode, "condition")
+	body := rv.translate.TreeChildByFieldName(tsNode, "body")
+	if conditionNode == nil || body == nil {
+		return ast.InvalidNodeID
+	}
+	conditionID := rv.translate.HandleRhsWithFakeVariable(ctx, "__cond__", conditionNode, scopeID, nil)
+	return rv.translate.HandleLoop(ctx, tsNode, ast.InvalidNodeID, conditionID, body, scopeID)
+}
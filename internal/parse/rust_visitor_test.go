@@ -0,0 +1,74 @@
+package parse
+
+import (
+	"slices"
+	"testing"
+
+	"bot-go/internal/config"
+	"bot-go/internal/model/ast"
+)
+
+const rustSource = `pub enum Color {
+    Red,
+    Green = 2,
+}
+
+pub trait Shape {
+    fn area(&self) -> f64;
+    fn describe(&self) -> String {
+        String::from("shape")
+    }
+}
+
+pub struct Square {
+    side: f64,
+}
+
+impl Square {
+    pub fn new(side: f64) -> Self {
+        Square { side }
+    }
+}
+
+impl Shape for Square {
+    fn area(&self) -> f64 {
+        let double = |x: f64| x * 2.0;
+        double(self.side) / 2.0 * self.side
+    }
+}
+`
+
+func TestRustVisitor(t *testing.T) {
+	g := translateSource(t, "shapes.rs", rustSource, config.LanguageOptions{})
+
+	tests := []struct {
+		nodeType ast.NodeType
+		want     []string
+	}{
+		{ast.NodeTypeEnum, []string{"Color"}},
+		{ast.NodeTypeConstant, []string{"Red", "Green"}},
+		{ast.NodeTypeClass, []string{"Shape", "Square"}},
+		// Trait methods with and without a body, inherent and trait impl methods, the closure
+		{ast.NodeTypeFunction, []string{"area", "describe", "new", "<anonymous>"}},
+	}
+	for _, tt := range tests {
+		got := g.names(tt.nodeType)
+		for _, name := range tt.want {
+			if !slices.Contains(got, name) {
+				t.Errorf("%v %s missing, got %v", tt.nodeType, name, got)
+			}
+		}
+	}
+	if got := g.names(ast.NodeTypeFunction); len(got) != 5 {
+		t.Errorf("got functions %v, want area twice, describe, new and the closure", got)
+	}
+
+	// Traits declare no behaviour of their own
+	g = translateSource(t, "shapes.rs", rustSource, config.LanguageOptions{SkipTypeOnly: true})
+	if got := g.names(ast.NodeTypeClass); slices.Contains(got, "Shape") || !slices.Contains(got, "Square") {
+		t.Errorf("skip_type_only: got classes %v, want Square without Shape", got)
+	}
+	if got := g.names(ast.NodeTypeFunction); slices.Contains(got, "describe") {
+		t.Errorf("skip_type_only: got functions %v, want no trait methods", got)
+	}
+}
@@ -0,0 +1,132 @@
+package parse
+
+import (
+	"strings"
+
+	"bot-go/internal/service/codegraph"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// maxSignatureLength bounds the signature text stored on a function
+const maxSignatureLength = 300
+
+// signatureMetadata returns the declared signature of a function as Function metadata (see
+// codegraph.MetaSignature): its declaration without the body, its parameter types and its
+// result types. Parameters come from the declaration's parameter list, or params when it
+// has none.
+func (t *TranslateFromSyntaxTree) signatureMetadata(fn *tree_sitter.Node, params []*tree_sitter.Node, body *tree_sitter.Node) map[string]any {
+	if list := t.TreeChildByFieldName(fn, "parameters"); list != nil {
+		params = t.NamedChildren(list)
+	}
+	var paramTypes []string
+	for i, param := range params {
+		switch param.Kind() {
+		case "comment", "line_comment", "block_comment", "attribute_item",
+			"keyword_separator", "positional_separator":
+			continue
+		case "self_parameter":
+			// Rust's self is bound by the call, not passed
+			continue
+		case "identifier":
+			// Python's self and cls are bound by the call, not passed
+			if name := t.String(param); i == 0 && (name == "self" || name == "cls") {
+				continue
+			}
+		}
+		typ := t.declaredType(param)
+		if param.Kind() == "variadic_parameter_declaration" {
+			typ = "..." + typ
+		}
+		// Go declares several parameters of one type together: a, b int
+		for n := max(t.countField(param, "name"), 1); n > 0; n-- {
+			paramTypes = append(paramTypes, typ)
+		}
+	}
+
+	metadata := map[string]any{
+		codegraph.MetaSignature:  t.signatureText(fn, body),
+		codegraph.MetaParamCount: len(paramTypes),
+	}
+	if len(paramTypes) > 0 {
+		metadata[codegraph.MetaParamTypes] = paramTypes
+	}
+	if returnTypes := t.returnTypes(fn); len(returnTypes) > 0 {
+		metadata[codegraph.MetaReturnTypes] = returnTypes
+	}
+	return metadata
+}
+
+// signatureText is the source of a function up to its body, on one line
+func (t *TranslateFromSyntaxTree) signatureText(fn *tree_sitter.Node, body *tree_sitter.Node) string {
+	if body == nil {
+		body = t.TreeChildByFieldName(fn, "body")
+	}
+	end := fn.EndByte()
+	if body != nil && body.StartByte() > fn.StartByte() && body.StartByte() <= end {
+		end = body.StartByte()
+	}
+	text := strings.Join(strings.Fields(string(t.FileContent[fn.StartByte():end])), " ")
+	text = strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(text, "=>"), ":"))
+	if len(text) > maxSignatureLength {
+		text = strings.ToValidUTF8(text[:maxSignatureLength], "")
+	}
+	return text
+}
+
+// returnTypes returns the declared result types of a function: Go's result, Python's,
+// TypeScript's and Rust's return_type, Java's type. void, None and () declare no results.
+func (t *TranslateFromSyntaxTree) returnTypes(fn *tree_sitter.Node) []string {
+	var result *tree_sitter.Node
+	for _, field := range []string{"result", "return_type", "type"} {
+		if result = t.TreeChildByFieldName(fn, field); result != nil {
+			break
+		}
+	}
+	if result == nil {
+		return nil
+	}
+	if result.Kind() == "parameter_list" {
+		var types []string
+		for _, decl := range t.NamedChildren(result) {
+			if decl.Kind() == "comment" {
+				continue
+			}
+			typ := t.declaredType(decl)
+			for n := max(t.countField(decl, "name"), 1); n > 0; n-- {
+				types = append(types, typ)
+			}
+		}
+		return types
+	}
+	typ := typeText(t.String(result))
+	if typ == "" || typ == "void" || typ == "None" || typ == "()" {
+		return nil
+	}
+	return []string{typ}
+}
+
+// declaredType returns the type declared for a parameter, or "" when it has none
+func (t *TranslateFromSyntaxTree) declaredType(param *tree_sitter.Node) string {
+	if typ := t.TreeChildByFieldName(param, "type"); typ != nil {
+		return typeText(t.String(typ))
+	}
+	return ""
+}
+
+// countField counts the children of node in a field
+func (t *TranslateFromSyntaxTree) countField(node *tree_sitter.Node, field string) int {
+	n := 0
+	for i := uint(0); i < node.ChildCount(); i++ {
+		if node.FieldNameForChild(uint32(i)) == field {
+			n++
+		}
+	}
+	return n
+}
+
+// typeText normalizes the source of a type: one line, without TypeScript's leading colon
+func typeText(s string) string {
+	s = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(s), ":"))
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,78 @@
+package parse
+
+import (
+	"reflect"
+	"testing"
+
+	"bot-go/internal/service/codegraph"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+func TestSignatureMetadata(t *testing.T) {
+	tests := []struct {
+		language LanguageType
+		source   string
+		kind     string // of the function node
+		want     map[string]any
+	}{
+		{Go, "package p\nfunc (s *Store) Load(ctx context.Context, a, b int, opts ...Option) (*Item, error) {\n\treturn nil, nil\n}\n",
+			"method_declaration", map[string]any{
+				codegraph.MetaSignature:   "func (s *Store) Load(ctx context.Context, a, b int, opts ...Option) (*Item, error)",
+				codegraph.MetaParamCount:  4,
+				codegraph.MetaParamTypes:  []string{"context.Context", "int", "int", "...Option"},
+				codegraph.MetaReturnTypes: []string{"*Item", "error"},
+			}},
+		{Go, "package p\nfunc Close() {}\n",
+			"function_declaration", map[string]any{
+				codegraph.MetaSignature:  "func Close()",
+				codegraph.MetaParamCount: 0,
+			}},
+		{Python, "class C:\n    def get(self, key: str, default=None) -> Optional[str]:\n        return None\n",
+			"function_definition", map[string]any{
+				codegraph.MetaSignature:   "def get(self, key: str, default=None) -> Optional[str]",
+				codegraph.MetaParamCount:  2,
+				codegraph.MetaParamTypes:  []string{"str", ""},
+				codegraph.MetaReturnTypes: []string{"Optional[str]"},
+			}},
+		{TypeScript, "function add(a: number, b?: number): Promise<void> { return null }\n",
+			"function_declaration", map[string]any{
+				codegraph.MetaSignature:   "function add(a: number, b?: number): Promise<void>",
+				codegraph.MetaParamCount:  2,
+				codegraph.MetaParamTypes:  []string{"number", "number"},
+				codegraph.MetaReturnTypes: []string{"Promise<void>"},
+			}},
+		{Rust, "impl Store {\n    fn get(&self, key: &str, n: usize) -> Option<String> {\n        None\n    }\n}\n",
+			"function_item", map[string]any{
+				codegraph.MetaSignature:   "fn get(&self, key: &str, n: usize) -> Option<String>",
+				codegraph.MetaParamCount:  2,
+				codegraph.MetaParamTypes:  []string{"&str", "usize"},
+				codegraph.MetaReturnTypes: []string{"Option<String>"},
+			}},
+	}
+
+	fp := &FileParser{}
+	parser := tree_sitter.NewParser()
+	defer parser.Close()
+	for _, tt := range tests {
+		grammar, err := fp.GetLanguageParser(tt.language)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := parser.SetLanguage(grammar); err != nil {
+			t.Fatal(err)
+		}
+		content := []byte(tt.source)
+		tree := parser.Parse(content, nil)
+		translate := &TranslateFromSyntaxTree{FileContent: content}
+		fn := translate.SubtreeNodeByKind(tree.RootNode(), tt.kind)
+		if fn == nil {
+			t.Fatalf("%s: no %s in %q", tt.language, tt.kind, tt.source)
+		}
+		got := translate.signatureMetadata(fn, nil, nil)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: signatureMetadata = %#v, want %#v", tt.language, got, tt.want)
+		}
+		tree.Close()
+	}
+}
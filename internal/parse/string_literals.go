@@ -0,0 +1,187 @@
+package parse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"bot-go/internal/service/codegraph"
+	"bot-go/internal/util"
+	"bot-go/pkg/lsp/base"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// DefaultStringLiteralMinLength is the shortest literal ExtractStringLiterals keeps when no
+// minimum is given
+const DefaultStringLiteralMinLength = 8
+
+// stringLiteralKinds are the syntax node kinds of string literals per language
+var stringLiteralKinds = map[LanguageType]map[string]bool{
+	Go:         {"interpreted_string_literal": true, "raw_string_literal": true},
+	Python:     {"string": true},
+	JavaScript: {"string": true, "template_string": true},
+	TypeScript: {"string": true, "template_string": true},
+	Java:       {"string_literal": true},
+}
+
+// literalSkipParents are parent node kinds whose strings are names rather than text:
+// import paths, struct tags and Python docstrings (a bare expression statement)
+var literalSkipParents = map[string]bool{
+	"import_spec": true, "import_statement": true, "export_statement": true,
+	"field_declaration": true, "expression_statement": true,
+}
+
+// errorCallees are the callees, by last name segment, whose string arguments are errors
+var errorCallees = map[string]bool{
+	"New": true, "Errorf": true, "Wrap": true, "Wrapf": true, "panic": true,
+}
+
+// logCallees are the callees, by lowercased last name segment, whose string arguments are
+// log messages
+var logCallees = map[string]bool{
+	"debug": true, "debugf": true, "info": true, "infof": true, "warn": true, "warnf": true,
+	"warning": true, "error": true, "errorf": true, "fatal": true, "fatalf": true,
+	"exception": true, "critical": true, "print": true, "printf": true, "println": true, "log": true,
+}
+
+// ExtractStringLiterals returns the significant string literals of a file: URLs and
+// literals of at least minLength characters made of two or more words, such as log
+// messages and error strings. Import paths, struct tags and docstrings are left out. The
+// kind of each literal comes from where it is used; FunctionID is not set.
+func (fp *FileParser) ExtractStringLiterals(filePath string, content []byte, minLength int) ([]codegraph.StringLiteral, error) {
+	langType := fp.DetectLanguage(filePath)
+	kinds, ok := stringLiteralKinds[langType]
+	if !ok {
+		return nil, nil
+	}
+	language, err := fp.GetLanguageParser(langType)
+	if err != nil {
+		return nil, err
+	}
+	if minLength <= 0 {
+		minLength = DefaultStringLiteralMinLength
+	}
+
+	// FileParser's own parser is shared by the code graph; processors run concurrently
+	parser := tree_sitter.NewParser()
+	defer parser.Close()
+	if err := parser.SetLanguage(language); err != nil {
+		return nil, fmt.Errorf("failed to set parser language: %w", err)
+	}
+	tree := parser.Parse(content, nil)
+	if tree == nil {
+		return nil, fmt.Errorf("failed to parse file: %s", filePath)
+	}
+	defer tree.Close()
+
+	index := util.NewLineIndex(content)
+	var literals []codegraph.StringLiteral
+	var walk func(node *tree_sitter.Node)
+	walk = func(node *tree_sitter.Node) {
+		if kinds[node.Kind()] {
+			parent := node.Parent()
+			if parent != nil && literalSkipParents[parent.Kind()] {
+				return
+			}
+			value := literalValue(langType, node, content)
+			if kind := literalKind(node, value, minLength, content); kind != "" {
+				start, end := node.StartPosition(), node.EndPosition()
+				literals = append(literals, codegraph.StringLiteral{
+					Value: value,
+					Kind:  kind,
+					Range: base.Range{
+						Start: base.Position{Line: int(start.Row), Character: index.UTF16Column(int(start.Row), int(start.Column))},
+						End:   base.Position{Line: int(end.Row), Character: index.UTF16Column(int(end.Row), int(end.Column))},
+					},
+				})
+			}
+			// Strings do not nest, except in interpolations, which are not indexed
+			return
+		}
+		for i := uint(0); i < node.NamedChildCount(); i++ {
+			walk(node.NamedChild(i))
+		}
+	}
+	walk(tree.RootNode())
+	return literals, nil
+}
+
+// literalValue returns the text of a string literal without quotes or prefixes. Go escapes
+// are decoded; other languages keep the source text, which is what is searched for.
+func literalValue(langType LanguageType, node *tree_sitter.Node, content []byte) string {
+	text := string(content[node.StartByte():node.EndByte()])
+	if node.Kind() == "interpreted_string_literal" {
+		if value, err := strconv.Unquote(text); err == nil {
+			return value
+		}
+	}
+	if langType == Python {
+		text = strings.TrimLeft(text, "rbfuRBFU")
+	}
+	for _, quote := range []string{`"""`, `'''`, `"`, `'`, "`"} {
+		if len(text) >= 2*len(quote) && strings.HasPrefix(text, quote) && strings.HasSuffix(text, quote) {
+			return text[len(quote) : len(text)-len(quote)]
+		}
+	}
+	return text
+}
+
+// literalKind classifies a string literal, or returns "" when it is not significant
+func literalKind(node *tree_sitter.Node, value string, minLength int, content []byte) string {
+	trimmed := strings.TrimSpace(value)
+	if strings.Contains(trimmed, "://") && !strings.ContainsAny(trimmed, " \t\n") {
+		return codegraph.StringKindURL
+	}
+	if len([]rune(trimmed)) < minLength || len(strings.Fields(trimmed)) < 2 ||
+		!strings.ContainsFunc(trimmed, unicode.IsLetter) {
+		return ""
+	}
+
+	// Walk up through the argument list (and string concatenations) to the call or
+	// statement that uses the literal
+	for parent := node.Parent(); parent != nil; parent = parent.Parent() {
+		switch parent.Kind() {
+		case "raise_statement", "throw_statement":
+			return codegraph.StringKindError
+		case "call_expression", "call", "method_invocation":
+			return calleeKind(parent, false, content)
+		case "new_expression", "object_creation_expression":
+			return calleeKind(parent, true, content)
+		case "argument_list", "arguments", "keyword_argument", "binary_expression", "binary_operator",
+			"parenthesized_expression":
+		default:
+			return codegraph.StringKindText
+		}
+	}
+	return codegraph.StringKindText
+}
+
+// calleeKind classifies the literal arguments of a call by the name of what it calls.
+// Constructors named like errors or exceptions (new Error, ValueError, IOException) make
+// errors, while logger.Error is a log call.
+func calleeKind(call *tree_sitter.Node, constructor bool, content []byte) string {
+	var callee *tree_sitter.Node
+	for _, field := range []string{"function", "constructor", "type", "name"} {
+		if callee = call.ChildByFieldName(field); callee != nil {
+			break
+		}
+	}
+	if callee == nil {
+		return codegraph.StringKindText
+	}
+	name := string(content[callee.StartByte():callee.EndByte()])
+	last := name[strings.LastIndexAny(name, ".:")+1:]
+	errorType := strings.HasSuffix(last, "Error") || strings.HasSuffix(last, "Exception")
+
+	switch {
+	case constructor && errorType,
+		errorType && (last != "Error" || name == "Error"),
+		errorCallees[last] && (last != "New" || strings.HasSuffix(name, "errors.New")):
+		return codegraph.StringKindError
+	case logCallees[strings.ToLower(last)]:
+		return codegraph.StringKindLog
+	}
+	return codegraph.StringKindText
+}
@@ -1,11 +1,14 @@
 package parse
 
 import (
+	"bot-go/internal/config"
 	"bot-go/internal/model/ast"
 	"bot-go/internal/service/codegraph"
+	"bot-go/internal/util"
 	"bot-go/pkg/lsp/base"
 	"context"
 	"fmt"
+	"hash/fnv"
 	"maps"
 	"strings"
 
@@ -45,6 +48,7 @@ type Scope struct {
 	Parent            *Scope
 	rhsVars           map[ast.NodeID]bool
 	notContainedNodes map[ast.NodeID]bool
+	function          ast.NodeID // the function whose body this scope is, if any
 }
 
 func (s *Scope) AddSymbol(sym *Symbol) error {
@@ -105,13 +109,29 @@ func (s *Scope) GetRhsVars() []ast.NodeID {
 }
 
 func (s *Scope) Resolve(name string) *Symbol {
+	sym, _ := s.resolveWithScope(name)
+	return sym
+}
+
+// resolveWithScope resolves name and also returns the scope that defines it
+func (s *Scope) resolveWithScope(name string) (*Symbol, *Scope) {
 	if sym := s.GetSymbol(name); sym != nil {
-		return sym
+		return sym, s
 	}
 	if s.Parent != nil {
-		return s.Parent.Resolve(name)
+		return s.Parent.resolveWithScope(name)
 	}
-	return nil
+	return nil, nil
+}
+
+// enclosingFunction returns the innermost function whose body contains this scope
+func (s *Scope) enclosingFunction() ast.NodeID {
+	for scope := s; scope != nil; scope = scope.Parent {
+		if scope.function != ast.InvalidNodeID {
+			return scope.function
+		}
+	}
+	return ast.InvalidNodeID
 }
 
 func NewScope(parent *Scope, isRhs bool) *Scope {
@@ -147,7 +167,22 @@ type TranslateFromSyntaxTree struct {
 	BatchSize         int
 	nodeBuffer        []*ast.Node
 	relationBuffer    []codegraph.RelationSpec
-}
+	// lineIndex converts tree-sitter byte columns to LSP UTF-16 characters; built on first use
+	lineIndex *util.LineIndex
+	// nodePaths holds the structural path hash of each keyed node, and keyOrdinals counts
+	// the siblings sharing a path segment so that each gets its own key
+	nodePaths   map[ast.NodeID]uint64
+	keyOrdinals map[uint64]int
+	// captures holds the recorded (function, variable) CAPTURES pairs
+	captures map[[2]ast.NodeID]bool
+	// Options tunes the translation of the file's language, from code_graph.languages
+	Options config.LanguageOptions
+	// nesting counts the conditionals and loops around the current node within its function
+	nesting int
+}
+
+// anonymousFunctionName names lambdas, closures and other functions without a name
+const anonymousFunctionName = "<anonymous>"
 
 func NewTranslateFromSyntaxTree(fileID int32, version int32, codeGraph *codegraph.CodeGraph,
 	fileContent []byte,
@@ -163,22 +198,78 @@ func NewTranslateFromSyntaxTree(fileID int32, version int32, codeGraph *codegrap
 		FileContent:  fileContent,
 		Logger:       logger,
 		Nodes:        make(map[ast.NodeID]*ast.Node),
+		nodePaths:    make(map[ast.NodeID]uint64),
+		keyOrdinals:  make(map[uint64]int),
+		captures:     make(map[[2]ast.NodeID]bool),
 	}
 }
 
 func (t *TranslateFromSyntaxTree) NewNode(nodeType ast.NodeType, name string, rng base.Range, parentID ast.NodeID) *ast.Node {
 	node := ast.NewNode(t.NextNodeID(), nodeType, t.FileID, name, rng, t.Version, parentID)
+	t.AssignNodeKey(node)
 	t.Nodes[node.ID] = node
 	t.CurrentScope.AddNotContainedNode(node.ID)
 	return node
 }
 
+// AssignNodeKey sets the node's stable key, which identifies it across re-indexing runs
+// where its numeric ID may change. The key combines the file ID and version with a hash of
+// the node's structural path: the type and name of each enclosing scope down to the node,
+// plus an ordinal telling apart siblings with the same type and name. Nodes must be keyed
+// in traversal order, after their scope.
+func (t *TranslateFromSyntaxTree) AssignNodeKey(node *ast.Node) {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%x/%v:%s", t.nodePaths[node.ScopeID], node.NodeType, node.Name) // parent is zero for the file scope
+	segment := h.Sum64()
+	ordinal := t.keyOrdinals[segment]
+	t.keyOrdinals[segment] = ordinal + 1
+
+	fmt.Fprintf(h, "#%d", ordinal)
+	path := h.Sum64()
+	t.nodePaths[node.ID] = path
+	node.Key = fmt.Sprintf("%d:%d:%016x", node.FileID, node.Version, path)
+}
+
 func (t *TranslateFromSyntaxTree) PushScope(rhs bool) {
 	newScope := NewScope(t.CurrentScope, rhs)
 	t.ScopeStack = append(t.ScopeStack, newScope)
 	t.CurrentScope = newScope
 }
 
+// pushFunctionScope opens the scope of a function body; variables it resolves from the
+// scopes of enclosing functions are captured
+func (t *TranslateFromSyntaxTree) pushFunctionScope(functionID ast.NodeID) {
+	t.PushScope(false)
+	t.CurrentScope.function = functionID
+}
+
+// resolve resolves name in the current scope. When the symbol is a local of an enclosing
+// function, every function between the two scopes captures it.
+func (t *TranslateFromSyntaxTree) resolve(ctx context.Context, name string) *Symbol {
+	sym, defining := t.CurrentScope.resolveWithScope(name)
+	if sym == nil || defining.enclosingFunction() == ast.InvalidNodeID {
+		return sym
+	}
+	for scope := t.CurrentScope; scope != defining; scope = scope.Parent {
+		if scope.function != ast.InvalidNodeID {
+			t.createCapture(ctx, scope.function, sym.Node.ID)
+		}
+	}
+	return sym
+}
+
+func (t *TranslateFromSyntaxTree) createCapture(ctx context.Context, functionID, variableID ast.NodeID) {
+	pair := [2]ast.NodeID{functionID, variableID}
+	if t.captures[pair] {
+		return
+	}
+	t.captures[pair] = true
+	if err := t.CodeGraph.CreateCapturesRelation(ctx, functionID, variableID, t.FileID); err != nil {
+		t.Logger.Error("Failed to create captures relation", zap.Int64("functionID", int64(functionID)),
+			zap.Int64("variableID", int64(variableID)), zap.Error(err))
+	}
+}
+
 func (t *TranslateFromSyntaxTree) PopScope(ctx context.Context, closingScopeId ast.NodeID) {
 	if len(t.ScopeStack) == 0 {
 		t.Logger.Error("Scope stack underflow")
@@ -280,30 +371,17 @@ func (t *TranslateFromSyntaxTree) GetAstNodeText(node *ast.Node) string {
 	if node == nil {
 		return ""
 	}
-	return t.getTextFromSourceAndRange(t.FileContent, node.Range)
+	// Ranges are LSP positions (UTF-16 characters), so map them back to byte offsets
+	rng := node.Range
+	return string(t.positions().Slice(rng.Start.Line, rng.Start.Character, rng.End.Line, rng.End.Character))
 }
 
-func (t *TranslateFromSyntaxTree) getTextFromSourceAndRange(sourceCode []byte, rng base.Range) string {
-	// iterate over the source code to get the text in the range
-	// for every /n increment line count
-	var currentLine, currentChar int
-	var startByte, endByte int
-	for i := 0; i < len(sourceCode); i++ {
-		if currentLine == rng.Start.Line && currentChar == rng.Start.Character {
-			startByte = i
-		}
-		if currentLine == rng.End.Line && currentChar == rng.End.Character {
-			endByte = i
-			break
-		}
-		if sourceCode[i] == '\n' {
-			currentLine++
-			currentChar = 0
-		} else {
-			currentChar++
-		}
+// positions returns the line index over the file content
+func (t *TranslateFromSyntaxTree) positions() *util.LineIndex {
+	if t.lineIndex == nil {
+		t.lineIndex = util.NewLineIndex(t.FileContent)
 	}
-	return string(sourceCode[startByte:endByte])
+	return t.lineIndex
 }
 
 func (t *TranslateFromSyntaxTree) Chindren(node *tree_sitter.Node) []*tree_sitter.Node {
@@ -326,16 +404,18 @@ func (t *TranslateFromSyntaxTree) ToRange(node *tree_sitter.Node) base.Range {
 	if node == nil {
 		return base.Range{}
 	}
+	// tree-sitter columns are byte offsets; ranges use LSP UTF-16 characters
 	startPos := node.StartPosition()
 	endPos := node.EndPosition()
+	index := t.positions()
 	return base.Range{
 		Start: base.Position{
 			Line:      int(startPos.Row),
-			Character: int(startPos.Column),
+			Character: index.UTF16Column(int(startPos.Row), int(startPos.Column)),
 		},
 		End: base.Position{
 			Line:      int(endPos.Row),
-			Character: int(endPos.Column),
+			Character: index.UTF16Column(int(endPos.Row), int(endPos.Column)),
 		},
 	}
 }
@@ -352,6 +432,10 @@ func (t *TranslateFromSyntaxTree) GetTreeNodeName(node *tree_sitter.Node) string
 		strings.HasSuffix(kind, "_identifier") {
 		return t.String(node)
 	}
+	// Rust's self, as a parameter and as an expression
+	if kind == "self" || kind == "self_parameter" {
+		return "self"
+	}
 
 	idNode := t.TreeChildByKind(node, "scoped_identifier")
 	if idNode == nil {
@@ -423,15 +507,62 @@ func (t *TranslateFromSyntaxTree) CreateFunction(ctx context.Context,
 	if funcName == "" {
 		return ast.InvalidNodeID
 	}
+	return t.createFunction(ctx, scopeID, fn, funcName, false, params, body)
+}
+
+// CreateClosure creates a function node for a lambda, closure or other anonymous function.
+// It is named <anonymous> and marked with the anonymous metadata flag. Like any nested
+// function, it CAPTURES the variables of enclosing functions that its body uses.
+//
+// With lambdas: expression the closure gets no node of its own: its parameters and body
+// belong to the enclosing node, and the ID of its body is returned.
+func (t *TranslateFromSyntaxTree) CreateClosure(ctx context.Context,
+	scopeID ast.NodeID,
+	fn *tree_sitter.Node,
+	params []*tree_sitter.Node, body *tree_sitter.Node) ast.NodeID {
+	if t.Options.Lambdas != config.LambdasAsExpressions {
+		return t.createFunction(ctx, scopeID, fn, anonymousFunctionName, true, params, body)
+	}
+
+	t.PushScope(false)
+	defer t.PopScope(ctx, ast.InvalidNodeID)
+	for _, param := range params {
+		t.HandleVariable(ctx, param, scopeID)
+	}
+	if body == nil {
+		return ast.InvalidNodeID
+	}
+	return t.Visitor.TraverseNode(ctx, body, scopeID)
+}
 
+func (t *TranslateFromSyntaxTree) createFunction(ctx context.Context,
+	scopeID ast.NodeID,
+	fn *tree_sitter.Node,
+	funcName string,
+	anonymous bool,
+	params []*tree_sitter.Node, body *tree_sitter.Node) ast.NodeID {
 	funcNode := t.NewNode(
 		ast.NodeTypeFunction, funcName, t.ToRange(fn), scopeID,
 	)
+	astHash, astSize := ASTFingerprint(fn)
+	funcNode.MetaData = map[string]any{
+		"ast_hash": astHash,
+		"ast_size": astSize,
+	}
+	if anonymous {
+		funcNode.MetaData["anonymous"] = true
+	}
+	maps.Copy(funcNode.MetaData, t.signatureMetadata(fn, params, body))
 	t.CodeGraph.CreateFunction(ctx, funcNode)
 
-	t.PushScope(false)
+	t.pushFunctionScope(funcNode.ID)
 	defer t.PopScope(ctx, funcNode.ID)
 
+	// Nesting depth counts from the function body
+	outerNesting := t.nesting
+	t.nesting = 0
+	defer func() { t.nesting = outerNesting }()
+
 	// Handle parameters
 	for idx, param := range params {
 		paramNodeID := t.HandleVariable(ctx, param, funcNode.ID)
@@ -450,7 +581,47 @@ func (t *TranslateFromSyntaxTree) CreateFunction(ctx context.Context,
 	return funcNode.ID
 }
 
+// CreateAnnotation creates an Annotation node for a decorator or annotation applied to
+// targetID. The node is named after the annotation without its "@" and arguments, and its
+// metadata records its position among the target's annotations and its argument source
+// texts, positional and named. The target contains it and links to it with ANNOTATION.
+func (t *TranslateFromSyntaxTree) CreateAnnotation(ctx context.Context, annotation *tree_sitter.Node,
+	name string, targetID ast.NodeID, position int, args []string, namedArgs map[string]string) ast.NodeID {
+	name = strings.TrimPrefix(strings.TrimSpace(name), "@")
+	if name == "" || targetID == ast.InvalidNodeID {
+		return ast.InvalidNodeID
+	}
+
+	annotationNode := t.NewNode(ast.NodeTypeAnnotation, name, t.ToRange(annotation), targetID)
+	annotationNode.MetaData = map[string]any{
+		"position": position,
+	}
+	if len(args) > 0 {
+		annotationNode.MetaData["arguments"] = args
+	}
+	if len(namedArgs) > 0 {
+		annotationNode.MetaData["named_arguments"] = namedArgs
+	}
+	if err := t.CodeGraph.CreateAnnotation(ctx, annotationNode); err != nil {
+		t.Logger.Error("Failed to create annotation", zap.String("name", name), zap.Error(err))
+		return ast.InvalidNodeID
+	}
+
+	t.CreateContainsRelation(ctx, targetID, annotationNode.ID, t.FileID)
+	if err := t.CodeGraph.CreateAnnotationRelation(ctx, targetID, annotationNode.ID, t.FileID); err != nil {
+		t.Logger.Error("Failed to create annotation relation", zap.String("name", name), zap.Error(err))
+	}
+	return annotationNode.ID
+}
+
 func (t *TranslateFromSyntaxTree) HandleBlock(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
+	if t.flattened() {
+		t.PushScope(false)
+		defer t.PopScope(ctx, ast.InvalidNodeID)
+		t.TraverseChildren(ctx, tsNode, scopeID)
+		return ast.InvalidNodeID
+	}
+
 	blockNode := t.NewNode(
 		ast.NodeTypeBlock, "", t.ToRange(tsNode), scopeID,
 	)
@@ -523,7 +694,7 @@ func (t *TranslateFromSyntaxTree) ResolveNameChain(ctx context.Context, nameChai
 			sym = NewSymbol(varNode)
 		} else {
 			if sym == nil {
-				sym = t.CurrentScope.Resolve(varName)
+				sym = t.resolve(ctx, varName)
 			} else {
 				newSym := sym.GetField(varName)
 
@@ -696,7 +867,7 @@ func (t *TranslateFromSyntaxTree) HandleIdentifier(ctx context.Context, idNode *
 
 	varId := ast.InvalidNodeID
 	// Check if the identifier is already in the current scope
-	if sym := t.CurrentScope.Resolve(name); sym != nil {
+	if sym := t.resolve(ctx, name); sym != nil {
 		varId = sym.Node.ID
 	} else {
 		varNode := t.NewNode(
@@ -714,7 +885,26 @@ func (t *TranslateFromSyntaxTree) HandleIdentifier(ctx context.Context, idNode *
 	return varId
 }
 
+// flattened reports whether the current node is nested in more conditionals and loops than
+// max_nesting_depth allows. Such conditionals, loops and blocks get no node: what they hold
+// belongs to the enclosing node.
+func (t *TranslateFromSyntaxTree) flattened() bool {
+	return t.Options.MaxNestingDepth > 0 && t.nesting > t.Options.MaxNestingDepth
+}
+
 func (t *TranslateFromSyntaxTree) HandleConditional(ctx context.Context, conditionalNode *tree_sitter.Node, conditions []*tree_sitter.Node, branches []*tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
+	t.nesting++
+	defer func() { t.nesting-- }()
+	if t.flattened() {
+		for _, cond := range conditions {
+			t.HandleRhsWithFakeVariable(ctx, "__cond__", cond, scopeID, nil)
+		}
+		for _, branch := range branches {
+			t.Visitor.TraverseNode(ctx, branch, scopeID)
+		}
+		return ast.InvalidNodeID
+	}
+
 	condNode := t.NewNode(
 		ast.NodeTypeConditional, "", t.ToRange(conditions[0]), scopeID,
 	)
@@ -753,6 +943,13 @@ func (t *TranslateFromSyntaxTree) HandleConditional(ctx context.Context, conditi
 func (t *TranslateFromSyntaxTree) HandleLoop(ctx context.Context, loopNode *tree_sitter.Node,
 	initID ast.NodeID, conditionID ast.NodeID, body *tree_sitter.Node,
 	scopeID ast.NodeID) ast.NodeID {
+	t.nesting++
+	defer func() { t.nesting-- }()
+	if t.flattened() {
+		t.Visitor.TraverseNode(ctx, body, scopeID)
+		return ast.InvalidNodeID
+	}
+
 	node := t.NewNode(ast.NodeTypeLoop, "", t.ToRange(loopNode), scopeID)
 	bodyID := t.Visitor.TraverseNode(ctx, body, node.ID)
 
@@ -0,0 +1,278 @@
+package parse
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sync"
+	"testing"
+
+	"bot-go/internal/config"
+	"bot-go/internal/model/ast"
+	"bot-go/internal/service/codegraph"
+
+	"go.uber.org/zap"
+)
+
+// graphRecorder is a graph database that keeps the nodes and relations written to it and
+// finds nothing
+type graphRecorder struct {
+	mu        sync.Mutex
+	nodes     []recordedNode
+	relations []recordedRelation
+}
+
+type recordedNode struct {
+	id       int64
+	nodeType ast.NodeType
+	name     string
+	props    map[string]any // parameters of the write
+}
+
+type recordedRelation struct {
+	label    string
+	from, to int64
+}
+
+// relationLabel finds the type of the relation a write merges
+var relationLabel = regexp.MustCompile(`\[r:(\w+)\]`)
+
+func (g *graphRecorder) ExecuteRead(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	return nil, nil
+}
+
+func (g *graphRecorder) ExecuteWrite(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if nodeType, ok := params["nodeType"].(int64); ok {
+		g.nodes = append(g.nodes, recordedNode{id: params["id"].(int64), nodeType: ast.NodeType(nodeType), name: params["name"].(string), props: params})
+	} else if m := relationLabel.FindStringSubmatch(query); m != nil {
+		from, _ := params["parentId"].(int64)
+		to, _ := params["childId"].(int64)
+		g.relations = append(g.relations, recordedRelation{label: m[1], from: from, to: to})
+	}
+	return nil, nil
+}
+
+func (g *graphRecorder) ExecuteReadSingle(ctx context.Context, query string, params map[string]any) (map[string]any, error) {
+	return nil, nil
+}
+
+func (g *graphRecorder) ExecuteWriteSingle(ctx context.Context, query string, params map[string]any) (map[string]any, error) {
+	g.ExecuteWrite(ctx, query, params)
+	return nil, nil
+}
+
+func (g *graphRecorder) Close(ctx context.Context) error { return nil }
+
+func (g *graphRecorder) VerifyConnectivity(ctx context.Context) error { return nil }
+
+// names returns the names of the recorded nodes of a type, in the order they were written
+func (g *graphRecorder) names(nodeType ast.NodeType) []string {
+	var names []string
+	for _, n := range g.nodes {
+		if n.nodeType == nodeType {
+			names = append(names, n.name)
+		}
+	}
+	return names
+}
+
+// related returns the relations of a type as "from -> to" node names, sorted
+func (g *graphRecorder) related(label string) []string {
+	names := make(map[int64]string, len(g.nodes))
+	for _, n := range g.nodes {
+		names[n.id] = n.name
+	}
+	var related []string
+	for _, r := range g.relations {
+		if r.label == label {
+			related = append(related, names[r.from]+" -> "+names[r.to])
+		}
+	}
+	slices.Sort(related)
+	return related
+}
+
+// translateSource builds the graph of a single file with the given translation options
+func translateSource(t *testing.T, fileName, source string, opts config.LanguageOptions) *graphRecorder {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, fileName)
+	if err := os.WriteFile(path, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := &graphRecorder{}
+	fp := &FileParser{}
+	cfg := &config.Config{}
+	cfg.CodeGraph.Languages = map[string]config.LanguageOptions{fp.DetectLanguage(fileName).String(): opts}
+	graph := codegraph.NewCodeGraphWithDatabase(recorder, cfg, zap.NewNop())
+	parser := NewFileParser(zap.NewNop(), graph, cfg)
+	repo := &config.Repository{Name: "repo", Path: dir}
+	if err := parser.ParseAndTraverseWithContent(context.Background(), repo, info, path, 1, 1, "", []byte(source)); err != nil {
+		t.Fatal(err)
+	}
+	return recorder
+}
+
+const goSource = `package p
+
+type Reader interface {
+	Read() int
+}
+
+func Read(n int) int {
+	if n > 0 {
+		for i := 0; i < n; i++ {
+			if i > 1 {
+				return i
+			}
+		}
+	}
+	f := func() int { return 1 }
+	return f()
+}
+`
+
+const pythonSource = `from typing import Protocol, TYPE_CHECKING
+if TYPE_CHECKING:
+    import os
+
+class Readable(Protocol):
+    def read(self) -> int: ...
+
+class File:
+    def read(self):
+        f = lambda x: x + 1
+        return f(1)
+`
+
+func TestSkipTypeOnly(t *testing.T) {
+	tests := []struct {
+		fileName, source string
+		nodeType         ast.NodeType
+		name             string
+		function         string // kept with skip_type_only
+	}{
+		{"reader.go", goSource, ast.NodeTypeClass, "Reader", "Read"},
+		{"file.py", pythonSource, ast.NodeTypeClass, "Readable", "read"},
+		{"file.py", pythonSource, ast.NodeTypeVariable, "os", "read"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fileName+"/"+tt.name, func(t *testing.T) {
+			if got := translateSource(t, tt.fileName, tt.source, config.LanguageOptions{}).names(tt.nodeType); !slices.Contains(got, tt.name) {
+				t.Errorf("%s missing by default, got %v", tt.name, got)
+			}
+			got := translateSource(t, tt.fileName, tt.source, config.LanguageOptions{SkipTypeOnly: true})
+			if names := got.names(tt.nodeType); slices.Contains(names, tt.name) {
+				t.Errorf("%s kept with skip_type_only, got %v", tt.name, names)
+			}
+			if names := got.names(ast.NodeTypeFunction); !slices.Contains(names, tt.function) {
+				t.Errorf("%s missing with skip_type_only, got %v", tt.function, names)
+			}
+		})
+	}
+}
+
+func TestMaxNestingDepth(t *testing.T) {
+	tests := []struct {
+		depth               int
+		conditionals, loops int
+	}{
+		{0, 2, 1},
+		{2, 1, 1},
+		{1, 1, 0},
+	}
+	for _, tt := range tests {
+		g := translateSource(t, "reader.go", goSource, config.LanguageOptions{MaxNestingDepth: tt.depth})
+		if got := len(g.names(ast.NodeTypeConditional)); got != tt.conditionals {
+			t.Errorf("max_nesting_depth %d: %d conditionals, want %d", tt.depth, got, tt.conditionals)
+		}
+		if got := len(g.names(ast.NodeTypeLoop)); got != tt.loops {
+			t.Errorf("max_nesting_depth %d: %d loops, want %d", tt.depth, got, tt.loops)
+		}
+		// What flattened conditionals and loops hold is kept
+		if got := g.names(ast.NodeTypeVariable); !slices.Contains(got, "i") {
+			t.Errorf("max_nesting_depth %d: variable i missing, got %v", tt.depth, got)
+		}
+	}
+}
+
+func TestLambdas(t *testing.T) {
+	tests := []struct {
+		fileName, source string
+	}{
+		{"reader.go", goSource},
+		{"file.py", pythonSource},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fileName, func(t *testing.T) {
+			for _, lambdas := range []string{"", config.LambdasAsFunctions} {
+				if got := translateSource(t, tt.fileName, tt.source, config.LanguageOptions{Lambdas: lambdas}).names(ast.NodeTypeFunction); !slices.Contains(got, "<anonymous>") {
+					t.Errorf("lambdas %q: no function for the lambda, got %v", lambdas, got)
+				}
+			}
+			g := translateSource(t, tt.fileName, tt.source, config.LanguageOptions{Lambdas: config.LambdasAsExpressions})
+			if got := g.names(ast.NodeTypeFunction); slices.Contains(got, "<anonymous>") {
+				t.Errorf("lambdas expression: function for the lambda, got %v", got)
+			}
+			// The variable holding the lambda is still there
+			if got := g.names(ast.NodeTypeVariable); !slices.Contains(got, "f") {
+				t.Errorf("lambdas expression: variable f missing, got %v", got)
+			}
+		})
+	}
+}
+
+func TestCaptures(t *testing.T) {
+	tests := []struct {
+		fileName, source string
+		want             []string
+	}{
+		{"outer.go", `package p
+
+var global = 1
+
+func outer(n int) int {
+	x := n
+	y := 2
+	f := func(a int) int {
+		g := func() int { return x + x + global }
+		return g() + y + a
+	}
+	return f(x)
+}
+`, []string{
+			// The inner closure captures x once, and so does the closure around it, which
+			// it reaches x through; parameters, own locals and globals are not captured
+			"<anonymous> -> x",
+			"<anonymous> -> x",
+			"<anonymous> -> y",
+		}},
+		{"outer.py", `def outer(n):
+    x = n
+    return lambda a: a + x + n
+`, []string{"<anonymous> -> n", "<anonymous> -> x"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fileName, func(t *testing.T) {
+			g := translateSource(t, tt.fileName, tt.source, config.LanguageOptions{})
+			if got := g.related("CAPTURES"); !slices.Equal(got, tt.want) {
+				t.Errorf("CAPTURES = %v, want %v", got, tt.want)
+			}
+
+			// Lambdas translated as expressions capture nothing
+			g = translateSource(t, tt.fileName, tt.source, config.LanguageOptions{Lambdas: config.LambdasAsExpressions})
+			if got := g.related("CAPTURES"); len(got) != 0 {
+				t.Errorf("lambdas expression: CAPTURES = %v, want none", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+// Package querylog records the queries of search and graph endpoints into MySQL, with their
+// latency and result counts, and summarizes them to show which queries are popular and
+// which find nothing. Entries are anonymized: no client address, request or session ID is
+// stored, and with hash_queries only a hash of the query text is kept.
+package querylog
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"bot-go/internal/config"
+
+	"go.uber.org/zap"
+)
+
+const (
+	tableName = "query_log"
+
+	// MaxQueryLength is the number of characters of a query that are stored
+	MaxQueryLength = 512
+
+	defaultRetentionDays = 30
+	bufferSize           = 1024
+	batchSize            = 100
+	flushInterval        = 2 * time.Second
+	purgeInterval        = time.Hour
+	// purgeBatch bounds the rows one purge deletes, so it never holds locks for long
+	purgeBatch = 10000
+)
+
+// Entry is one logged query
+type Entry struct {
+	Time      time.Time
+	Endpoint  string // "METHOD /route"
+	RepoName  string
+	Query     string
+	LatencyMs int64
+	Results   int
+	Status    int // HTTP status of the response
+}
+
+// Recorder writes entries to the query_log table in the background. A nil *Recorder records
+// nothing, so callers need not check whether query logging is enabled.
+type Recorder struct {
+	db          *sql.DB
+	hashQueries bool
+	retention   time.Duration
+	logger      *zap.Logger
+
+	entries   chan Entry
+	done      chan struct{} // closed by Close
+	stopped   chan struct{} // closed by run after the last flush
+	closeOnce sync.Once
+	dropped   atomic.Int64
+}
+
+// New creates the query_log table if needed and starts the background writer
+func New(db *sql.DB, cfg config.QueryLogConfig, logger *zap.Logger) (*Recorder, error) {
+	retentionDays := cfg.RetentionDays
+	if retentionDays <= 0 {
+		retentionDays = defaultRetentionDays
+	}
+	r := &Recorder{
+		db:          db,
+		hashQueries: cfg.HashQueries,
+		retention:   time.Duration(retentionDays) * 24 * time.Hour,
+		logger:      logger,
+		entries:     make(chan Entry, bufferSize),
+		done:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+	if err := r.EnsureTable(); err != nil {
+		return nil, fmt.Errorf("failed to ensure query log table: %w", err)
+	}
+	go r.run()
+	return r, nil
+}
+
+// EnsureTable creates the query_log table if it doesn't exist
+func (r *Recorder) EnsureTable() error {
+	query := `
+		CREATE TABLE IF NOT EXISTS ` + tableName + ` (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			created_at TIMESTAMP(3) NOT NULL,
+			endpoint VARCHAR(128) NOT NULL,
+			repo_name VARCHAR(255) NOT NULL DEFAULT '',
+			query_text VARCHAR(512) NOT NULL DEFAULT '',
+			query_hash CHAR(64) NOT NULL,
+			latency_ms INT NOT NULL,
+			result_count INT NOT NULL,
+			status SMALLINT NOT NULL,
+			INDEX idx_created_at (created_at),
+			INDEX idx_endpoint_hash (endpoint, query_hash)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci
+	`
+	if _, err := r.db.Exec(query); err != nil {
+		return fmt.Errorf("failed to create table: %w", err)
+	}
+	return nil
+}
+
+// Record queues an entry for writing. It never blocks: when the writer falls behind, the
+// entry is dropped and counted.
+func (r *Recorder) Record(entry Entry) {
+	if r == nil {
+		return
+	}
+	select {
+	case <-r.done:
+		return
+	default:
+	}
+	select {
+	case r.entries <- entry:
+	default:
+		r.dropped.Add(1)
+	}
+}
+
+// Close stops the writer after flushing the queued entries
+func (r *Recorder) Close() {
+	if r == nil {
+		return
+	}
+	r.closeOnce.Do(func() {
+		close(r.done)
+		<-r.stopped
+	})
+}
+
+// run writes queued entries in batches and purges entries past the retention period
+func (r *Recorder) run() {
+	defer close(r.stopped)
+	flush := time.NewTicker(flushInterval)
+	defer flush.Stop()
+	purge := time.NewTicker(purgeInterval)
+	defer purge.Stop()
+
+	r.purge()
+	batch := make([]Entry, 0, batchSize)
+	write := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := r.insert(batch); err != nil {
+			r.logger.Warn("Failed to write query log entries", zap.Int("entries", len(batch)), zap.Error(err))
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case entry := <-r.entries:
+			batch = append(batch, entry)
+			if len(batch) == batchSize {
+				write()
+			}
+		case <-flush.C:
+			write()
+			if dropped := r.dropped.Swap(0); dropped > 0 {
+				r.logger.Warn("Query log buffer full, entries dropped", zap.Int64("dropped", dropped))
+			}
+		case <-purge.C:
+			r.purge()
+		case <-r.done:
+			for {
+				select {
+				case entry := <-r.entries:
+					batch = append(batch, entry)
+					if len(batch) == batchSize {
+						write()
+					}
+				default:
+					write()
+					return
+				}
+			}
+		}
+	}
+}
+
+// insert writes entries with one multi-row INSERT
+func (r *Recorder) insert(entries []Entry) error {
+	var sb strings.Builder
+	sb.WriteString("INSERT INTO " + tableName +
+		" (created_at, endpoint, repo_name, query_text, query_hash, latency_ms, result_count, status) VALUES ")
+	args := make([]any, 0, len(entries)*8)
+	for i, e := range entries {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString("(?, ?, ?, ?, ?, ?, ?, ?)")
+		text := Normalize(e.Query)
+		hash := Hash(text)
+		if r.hashQueries {
+			text = ""
+		}
+		text = truncate(text)
+		args = append(args, e.Time.UTC(), e.Endpoint, e.RepoName, text, hash, e.LatencyMs, e.Results, e.Status)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := r.db.ExecContext(ctx, sb.String(), args...)
+	return err
+}
+
+// purge deletes entries older than the retention period
+func (r *Recorder) purge() {
+	cutoff := time.Now().Add(-r.retention).UTC()
+	for {
+		result, err := r.db.Exec("DELETE FROM "+tableName+" WHERE created_at < ? LIMIT ?", cutoff, purgeBatch)
+		if err != nil {
+			r.logger.Warn("Failed to purge query log", zap.Error(err))
+			return
+		}
+		if n, err := result.RowsAffected(); err != nil || n < purgeBatch {
+			return
+		}
+	}
+}
+
+// Normalize collapses the whitespace of a query, so that queries differing only in layout
+// are counted together
+func Normalize(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+// truncate shortens a query to the MaxQueryLength characters the table stores
+func truncate(query string) string {
+	if utf8.RuneCountInString(query) <= MaxQueryLength {
+		return query
+	}
+	return string([]rune(query)[:MaxQueryLength])
+}
+
+// Hash returns the hex SHA-256 of a normalized query. It identifies the query when the text
+// is not stored or is truncated.
+func Hash(normalized string) string {
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,65 @@
+package querylog
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		query, want string
+	}{
+		{"parseConfig", "parseConfig"},
+		{"  func  main() {\n\treturn\n}\n", "func main() { return }"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.query); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestHashIgnoresLayout(t *testing.T) {
+	a := Hash(Normalize("if err != nil {\n\treturn err\n}"))
+	b := Hash(Normalize("if err != nil { return err }"))
+	if a != b {
+		t.Errorf("hashes of the same query differ: %s, %s", a, b)
+	}
+	if len(a) != 64 {
+		t.Errorf("hash length = %d, want 64", len(a))
+	}
+	if a == Hash(Normalize("if err == nil { return err }")) {
+		t.Error("hashes of different queries are equal")
+	}
+}
+
+func TestTruncateCountsCharacters(t *testing.T) {
+	query := strings.Repeat("é", MaxQueryLength+10)
+	got := truncate(query)
+	if n := utf8.RuneCountInString(got); n != MaxQueryLength {
+		t.Errorf("truncated to %d characters, want %d", n, MaxQueryLength)
+	}
+	if !utf8.ValidString(got) {
+		t.Error("truncated query is not valid UTF-8")
+	}
+	if short := "short query"; truncate(short) != short {
+		t.Errorf("truncate(%q) = %q", short, truncate(short))
+	}
+}
+
+func TestRecordDoesNotBlock(t *testing.T) {
+	var nilRecorder *Recorder
+	nilRecorder.Record(Entry{Query: "ignored"})
+	nilRecorder.Close()
+
+	// Without a writer draining it, the buffer fills and later entries are dropped
+	r := &Recorder{entries: make(chan Entry, 2), done: make(chan struct{})}
+	for i := 0; i < 5; i++ {
+		r.Record(Entry{Query: "q"})
+	}
+	if len(r.entries) != 2 || r.dropped.Load() != 3 {
+		t.Errorf("queued %d and dropped %d entries, want 2 and 3", len(r.entries), r.dropped.Load())
+	}
+}
@@ -0,0 +1,121 @@
+package querylog
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Summary describes the queries logged since a point in time
+type Summary struct {
+	Since          time.Time       `json:"since"`
+	RepoName       string          `json:"repo_name,omitempty"`
+	Total          int64           `json:"total"`
+	ZeroResults    int64           `json:"zero_results"`
+	Errors         int64           `json:"errors"`
+	ZeroResultRate float64         `json:"zero_result_rate"` // of successful queries
+	Endpoints      []EndpointStats `json:"endpoints"`
+	Popular        []QueryStats    `json:"popular_queries"`
+	ZeroResult     []QueryStats    `json:"zero_result_queries"`
+}
+
+// EndpointStats aggregates the logged queries of one endpoint
+type EndpointStats struct {
+	Endpoint     string  `json:"endpoint"`
+	Count        int64   `json:"count"`
+	ZeroResults  int64   `json:"zero_results"`
+	Errors       int64   `json:"errors"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	MaxLatencyMs int64   `json:"max_latency_ms"`
+}
+
+// QueryStats aggregates the occurrences of one query on one endpoint
+type QueryStats struct {
+	Endpoint     string    `json:"endpoint"`
+	Query        string    `json:"query,omitempty"` // empty when only hashes are stored
+	QueryHash    string    `json:"query_hash"`
+	Count        int64     `json:"count"`
+	AvgResults   float64   `json:"avg_results"`
+	AvgLatencyMs float64   `json:"avg_latency_ms"`
+	LastSeen     time.Time `json:"last_seen"`
+}
+
+// Summary summarizes the queries logged since since, for one repository or all of them when
+// repoName is empty. Popular and zero-result queries are limited to limit each. Failed
+// requests (status 400 and above) count as errors, not as zero-result queries.
+func (r *Recorder) Summary(ctx context.Context, since time.Time, repoName string, limit int) (*Summary, error) {
+	where := "created_at >= ?"
+	args := []any{since.UTC()}
+	if repoName != "" {
+		where += " AND repo_name = ?"
+		args = append(args, repoName)
+	}
+
+	summary := &Summary{Since: since, RepoName: repoName}
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT endpoint, COUNT(*),
+			SUM(result_count = 0 AND status < 400), SUM(status >= 400),
+			AVG(latency_ms), MAX(latency_ms)
+		FROM `+tableName+`
+		WHERE `+where+`
+		GROUP BY endpoint
+		ORDER BY COUNT(*) DESC`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize endpoints: %w", err)
+	}
+	defer rows.Close()
+	summary.Endpoints = []EndpointStats{}
+	for rows.Next() {
+		var s EndpointStats
+		if err := rows.Scan(&s.Endpoint, &s.Count, &s.ZeroResults, &s.Errors, &s.AvgLatencyMs, &s.MaxLatencyMs); err != nil {
+			return nil, fmt.Errorf("failed to scan endpoint summary: %w", err)
+		}
+		summary.Endpoints = append(summary.Endpoints, s)
+		summary.Total += s.Count
+		summary.ZeroResults += s.ZeroResults
+		summary.Errors += s.Errors
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to summarize endpoints: %w", err)
+	}
+	if succeeded := summary.Total - summary.Errors; succeeded > 0 {
+		summary.ZeroResultRate = float64(summary.ZeroResults) / float64(succeeded)
+	}
+
+	if summary.Popular, err = r.queryStats(ctx, where, args, limit); err != nil {
+		return nil, fmt.Errorf("failed to summarize popular queries: %w", err)
+	}
+	if summary.ZeroResult, err = r.queryStats(ctx, where+" AND result_count = 0 AND status < 400", args, limit); err != nil {
+		return nil, fmt.Errorf("failed to summarize zero-result queries: %w", err)
+	}
+	return summary, nil
+}
+
+// queryStats returns the most frequent queries of the entries matching where
+func (r *Recorder) queryStats(ctx context.Context, where string, args []any, limit int) ([]QueryStats, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT endpoint, query_hash, MAX(query_text), COUNT(*),
+			AVG(result_count), AVG(latency_ms), MAX(created_at)
+		FROM `+tableName+`
+		WHERE `+where+`
+		GROUP BY endpoint, query_hash
+		ORDER BY COUNT(*) DESC, MAX(created_at) DESC
+		LIMIT ?`, append(args[:len(args):len(args)], limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := []QueryStats{}
+	for rows.Next() {
+		var s QueryStats
+		var lastSeen sql.NullTime
+		if err := rows.Scan(&s.Endpoint, &s.QueryHash, &s.Query, &s.Count, &s.AvgResults, &s.AvgLatencyMs, &lastSeen); err != nil {
+			return nil, err
+		}
+		s.LastSeen = lastSeen.Time
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
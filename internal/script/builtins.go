@@ -0,0 +1,284 @@
+package script
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"bot-go/internal/apperrors"
+	"bot-go/internal/codeapi"
+	"bot-go/internal/model/ast"
+
+	"go.starlark.net/starlark"
+)
+
+// defaultLimit caps the entities a find builtin returns when the script does not pass limit
+const defaultLimit = 100
+
+// environment holds the state the builtins of one run share
+type environment struct {
+	ctx      context.Context
+	api      codeapi.CodeAPI
+	maxCalls int
+	calls    int
+	output   []string
+	// backendErr is the last error a codeapi call failed with, reported as the cause of
+	// the run's failure when the script does not recover from it
+	backendErr error
+}
+
+// builtinFunc is a builtin that calls codeapi and returns a value to convert
+type builtinFunc func(args starlark.Tuple, kwargs []starlark.Tuple) (any, error)
+
+func (env *environment) builtins() starlark.StringDict {
+	funcs := map[string]func(name string) builtinFunc{
+		"repos":          env.repos,
+		"find_files":     env.findFiles,
+		"find_classes":   env.findClasses,
+		"find_functions": env.findFunctions,
+		"get_class":      env.getClass,
+		"get_function":   env.getFunction,
+		"callers":        env.callers,
+		"callees":        env.callees,
+		"call_graph":     env.callGraph,
+		"impact":         env.impact,
+		"inheritance":    env.inheritance,
+		"modules":        env.modules,
+		"module_summary": env.moduleSummary,
+		"cypher":         env.cypher,
+	}
+	dict := make(starlark.StringDict, len(funcs))
+	for name, fn := range funcs {
+		call := fn(name)
+		dict[name] = starlark.NewBuiltin(name, func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			if env.calls >= env.maxCalls {
+				return nil, fmt.Errorf("%s: script exceeded %d codeapi calls", b.Name(), env.maxCalls)
+			}
+			env.calls++
+			value, err := call(args, kwargs)
+			if err != nil {
+				var usage usageError
+				if !errors.As(err, &usage) {
+					env.backendErr = err
+				}
+				return nil, err
+			}
+			return toJSONValue(value)
+		})
+	}
+	return dict
+}
+
+// toJSONValue converts a codeapi result to Starlark through its JSON form, as the HTTP
+// endpoints return it
+func toJSONValue(value any) (starlark.Value, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var decoded any
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, err
+	}
+	return toStarlark(decoded)
+}
+
+// usageError is a builtin called with bad arguments, as opposed to a failed codeapi call
+type usageError struct{ err error }
+
+func (e usageError) Error() string { return e.err.Error() }
+
+// unpack parses the arguments of a builtin
+func unpack(name string, args starlark.Tuple, kwargs []starlark.Tuple, pairs ...any) error {
+	if err := starlark.UnpackArgs(name, args, kwargs, pairs...); err != nil {
+		return usageError{err}
+	}
+	return nil
+}
+
+// orNone turns a not-found error into None, so scripts can test for missing entities
+func orNone(value any, err error) (any, error) {
+	if errors.Is(err, apperrors.ErrNotFound) {
+		return nil, nil
+	}
+	return value, err
+}
+
+func (env *environment) repos(name string) builtinFunc {
+	return func(args starlark.Tuple, kwargs []starlark.Tuple) (any, error) {
+		if err := unpack(name, args, kwargs); err != nil {
+			return nil, err
+		}
+		return env.api.Reader().ListRepos(env.ctx)
+	}
+}
+
+func (env *environment) findFiles(name string) builtinFunc {
+	return func(args starlark.Tuple, kwargs []starlark.Tuple) (any, error) {
+		var repo string
+		filter := codeapi.FileFilter{Limit: defaultLimit}
+		if err := unpack(name, args, kwargs, "repo", &repo, "path?", &filter.Path, "path_like?", &filter.PathLike,
+			"language?", &filter.Language, "limit?", &filter.Limit, "offset?", &filter.Offset); err != nil {
+			return nil, err
+		}
+		return env.api.Reader().Repo(repo).FindFiles(env.ctx, filter)
+	}
+}
+
+func (env *environment) findClasses(name string) builtinFunc {
+	return func(args starlark.Tuple, kwargs []starlark.Tuple) (any, error) {
+		var repo string
+		filter := codeapi.ClassFilter{Limit: defaultLimit}
+		if err := unpack(name, args, kwargs, "repo", &repo, "name?", &filter.Name, "name_like?", &filter.NameLike,
+			"file?", &filter.FilePath, "limit?", &filter.Limit, "offset?", &filter.Offset); err != nil {
+			return nil, err
+		}
+		return env.api.Reader().Repo(repo).FindClasses(env.ctx, filter)
+	}
+}
+
+func (env *environment) findFunctions(name string) builtinFunc {
+	return func(args starlark.Tuple, kwargs []starlark.Tuple) (any, error) {
+		var repo string
+		var methods starlark.Value = starlark.None
+		filter := codeapi.MethodFilter{Limit: defaultLimit}
+		if err := unpack(name, args, kwargs, "repo", &repo, "name?", &filter.Name, "name_like?", &filter.NameLike,
+			"class_name?", &filter.ClassName, "file?", &filter.FilePath, "methods?", &methods,
+			"limit?", &filter.Limit, "offset?", &filter.Offset); err != nil {
+			return nil, err
+		}
+		if methods != starlark.None {
+			isMethod := bool(methods.Truth())
+			filter.IsMethod = &isMethod
+		}
+		return env.api.Reader().Repo(repo).FindMethods(env.ctx, filter)
+	}
+}
+
+func (env *environment) getClass(name string) builtinFunc {
+	return func(args starlark.Tuple, kwargs []starlark.Tuple) (any, error) {
+		var repo string
+		var id int64
+		if err := unpack(name, args, kwargs, "repo", &repo, "id", &id); err != nil {
+			return nil, err
+		}
+		return orNone(env.api.Reader().Repo(repo).GetClassFull(env.ctx, ast.NodeID(id), codeapi.LoadOptions{IncludeMethods: true, IncludeFields: true}))
+	}
+}
+
+func (env *environment) getFunction(name string) builtinFunc {
+	return func(args starlark.Tuple, kwargs []starlark.Tuple) (any, error) {
+		var repo string
+		var id int64
+		if err := unpack(name, args, kwargs, "repo", &repo, "id", &id); err != nil {
+			return nil, err
+		}
+		return orNone(env.api.Reader().Repo(repo).GetMethod(env.ctx, ast.NodeID(id)))
+	}
+}
+
+func (env *environment) callers(name string) builtinFunc {
+	return func(args starlark.Tuple, kwargs []starlark.Tuple) (any, error) {
+		var id int64
+		depth := 1
+		if err := unpack(name, args, kwargs, "id", &id, "depth?", &depth); err != nil {
+			return nil, err
+		}
+		return env.api.Analyzer().GetCallers(env.ctx, ast.NodeID(id), depth)
+	}
+}
+
+func (env *environment) callees(name string) builtinFunc {
+	return func(args starlark.Tuple, kwargs []starlark.Tuple) (any, error) {
+		var id int64
+		depth := 1
+		if err := unpack(name, args, kwargs, "id", &id, "depth?", &depth); err != nil {
+			return nil, err
+		}
+		return env.api.Analyzer().GetCallees(env.ctx, ast.NodeID(id), depth)
+	}
+}
+
+func (env *environment) callGraph(name string) builtinFunc {
+	return func(args starlark.Tuple, kwargs []starlark.Tuple) (any, error) {
+		var id int64
+		direction := string(codeapi.DirectionOutgoing)
+		opts := codeapi.DefaultCallGraphOptions()
+		if err := unpack(name, args, kwargs, "id", &id, "direction?", &direction, "depth?", &opts.MaxDepth,
+			"max_nodes?", &opts.MaxNodes, "include_tests?", &opts.IncludeTests); err != nil {
+			return nil, err
+		}
+		switch codeapi.Direction(direction) {
+		case codeapi.DirectionOutgoing, codeapi.DirectionIncoming, codeapi.DirectionBoth:
+			opts.Direction = codeapi.Direction(direction)
+		default:
+			return nil, usageError{fmt.Errorf("%s: direction must be outgoing, incoming or both", name)}
+		}
+		return env.api.Analyzer().GetCallGraph(env.ctx, ast.NodeID(id), opts)
+	}
+}
+
+func (env *environment) impact(name string) builtinFunc {
+	return func(args starlark.Tuple, kwargs []starlark.Tuple) (any, error) {
+		var id int64
+		opts := codeapi.DefaultImpactOptions()
+		if err := unpack(name, args, kwargs, "id", &id, "depth?", &opts.MaxDepth,
+			"include_tests?", &opts.IncludeTests); err != nil {
+			return nil, err
+		}
+		return env.api.Analyzer().GetImpact(env.ctx, ast.NodeID(id), opts)
+	}
+}
+
+func (env *environment) inheritance(name string) builtinFunc {
+	return func(args starlark.Tuple, kwargs []starlark.Tuple) (any, error) {
+		var id int64
+		if err := unpack(name, args, kwargs, "id", &id); err != nil {
+			return nil, err
+		}
+		return orNone(env.api.Analyzer().GetInheritanceTree(env.ctx, ast.NodeID(id)))
+	}
+}
+
+func (env *environment) modules(name string) builtinFunc {
+	return func(args starlark.Tuple, kwargs []starlark.Tuple) (any, error) {
+		var repo string
+		if err := unpack(name, args, kwargs, "repo", &repo); err != nil {
+			return nil, err
+		}
+		return env.api.Modules().ListModules(env.ctx, repo)
+	}
+}
+
+func (env *environment) moduleSummary(name string) builtinFunc {
+	return func(args starlark.Tuple, kwargs []starlark.Tuple) (any, error) {
+		var repo, path string
+		if err := unpack(name, args, kwargs, "repo", &repo, "path", &path); err != nil {
+			return nil, err
+		}
+		return orNone(env.api.Modules().GetModuleSummary(env.ctx, repo, path))
+	}
+}
+
+// cypher runs a Cypher query in a read session; keyword arguments are its parameters
+func (env *environment) cypher(name string) builtinFunc {
+	return func(args starlark.Tuple, kwargs []starlark.Tuple) (any, error) {
+		var query string
+		if err := unpack(name, args, nil, "query", &query); err != nil {
+			return nil, err
+		}
+		params := make(map[string]any, len(kwargs))
+		for _, kv := range kwargs {
+			value, err := fromStarlark(kv[1])
+			if err != nil {
+				return nil, usageError{fmt.Errorf("%s: parameter %s: %v", name, kv[0], err)}
+			}
+			params[string(kv[0].(starlark.String))] = value
+		}
+		return env.api.ExecuteCypher(env.ctx, query, params)
+	}
+}
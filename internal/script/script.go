@@ -0,0 +1,255 @@
+// Package script runs short Starlark analysis scripts against the code graph. Scripts call
+// read-only codeapi primitives (finding classes and functions, call graphs, impact, modules,
+// Cypher reads) as builtins and compose them into multi-step analyses, so that one-off
+// questions don't each need Go code and a deployment.
+//
+//	fns = find_functions("my-repo", name_like="Handle*")
+//	result = {f["Name"]: len(callers(f["ID"])["Nodes"]) for f in fns}
+//
+// Builtins return the JSON form of the codeapi types as dicts and lists. The script's result
+// is the value of its global "result", and print output is captured.
+package script
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"bot-go/internal/apperrors"
+	"bot-go/internal/codeapi"
+	"bot-go/internal/config"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// ErrScript marks errors of the script itself: syntax errors, failed statements and
+// exceeded limits
+var ErrScript = errors.New("script error")
+
+// Limits bound the work of one script run
+type Limits struct {
+	MaxSteps uint64        // Starlark execution steps (0 = 10,000,000)
+	MaxCalls int           // calls of codeapi builtins (0 = 200)
+	Timeout  time.Duration // wall-clock time (0 = 30s)
+}
+
+// ConfigLimits returns the limits configured under admin
+func ConfigLimits(cfg config.AdminConfig) Limits {
+	return Limits{
+		MaxCalls: cfg.ScriptMaxCalls,
+		Timeout:  time.Duration(cfg.ScriptTimeoutSeconds) * time.Second,
+	}
+}
+
+func (l Limits) withDefaults() Limits {
+	if l.MaxSteps == 0 {
+		l.MaxSteps = 10_000_000
+	}
+	if l.MaxCalls == 0 {
+		l.MaxCalls = 200
+	}
+	if l.Timeout == 0 {
+		l.Timeout = 30 * time.Second
+	}
+	return l
+}
+
+// Result is the outcome of a script run
+type Result struct {
+	Value  any      `json:"result"` // the script's "result" global, nil if unset
+	Output []string `json:"output"` // lines printed by the script
+	Steps  uint64   `json:"steps"`
+	Calls  int      `json:"calls"` // codeapi builtin calls
+}
+
+// Error is a failed script run. It matches ErrScript, or the backend error that failed a
+// builtin call (e.g. apperrors.ErrBackendUnavailable).
+type Error struct {
+	Message   string
+	Backtrace string   // Starlark call stack, empty for syntax errors
+	Output    []string // lines printed before the failure
+	cause     error
+}
+
+func (e *Error) Error() string { return e.Message }
+
+func (e *Error) Unwrap() error { return e.cause }
+
+// fileOptions allow top-level loops and while statements, which short scripts use freely
+var fileOptions = &syntax.FileOptions{While: true, TopLevelControl: true, GlobalReassign: true}
+
+// Run executes src with the codeapi builtins and args (a dict of JSON values) predeclared
+func Run(ctx context.Context, api codeapi.CodeAPI, src string, args map[string]any, limits Limits) (*Result, error) {
+	limits = limits.withDefaults()
+	ctx, cancel := context.WithTimeout(ctx, limits.Timeout)
+	defer cancel()
+
+	env := &environment{ctx: ctx, api: api, maxCalls: limits.MaxCalls}
+	thread := &starlark.Thread{
+		Name:  "script",
+		Print: func(_ *starlark.Thread, msg string) { env.output = append(env.output, msg) },
+	}
+	thread.SetMaxExecutionSteps(limits.MaxSteps)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			thread.Cancel(ctx.Err().Error())
+		case <-done:
+		}
+	}()
+
+	argsValue, err := toStarlark(args)
+	if err != nil {
+		return nil, &Error{Message: fmt.Sprintf("invalid args: %v", err), cause: apperrors.ErrInvalidArgument}
+	}
+	predeclared := env.builtins()
+	predeclared["args"] = argsValue
+
+	globals, err := starlark.ExecFileOptions(fileOptions, thread, "script.star", src, predeclared)
+	if err != nil {
+		return nil, env.scriptError(err)
+	}
+
+	result := &Result{Output: env.output, Steps: thread.ExecutionSteps(), Calls: env.calls}
+	if result.Output == nil {
+		result.Output = []string{}
+	}
+	if value, ok := globals["result"]; ok {
+		if result.Value, err = fromStarlark(value); err != nil {
+			return nil, &Error{Message: fmt.Sprintf("result: %v", err), Output: env.output, cause: ErrScript}
+		}
+	}
+	return result, nil
+}
+
+// scriptError wraps a failed run, keeping the backend error of a failed builtin call
+func (env *environment) scriptError(err error) *Error {
+	scriptErr := &Error{Message: err.Error(), Output: env.output, cause: ErrScript}
+	var evalErr *starlark.EvalError
+	if errors.As(err, &evalErr) {
+		scriptErr.Backtrace = evalErr.Backtrace()
+	}
+	if env.backendErr != nil && errors.Is(err, env.backendErr) {
+		scriptErr.cause = env.backendErr
+	}
+	return scriptErr
+}
+
+// toStarlark converts JSON values (as produced by encoding/json) to Starlark values
+func toStarlark(v any) (starlark.Value, error) {
+	switch v := v.(type) {
+	case nil:
+		return starlark.None, nil
+	case bool:
+		return starlark.Bool(v), nil
+	case string:
+		return starlark.String(v), nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return starlark.MakeInt64(i), nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return starlark.Float(f), nil
+	case float64:
+		return starlark.Float(v), nil
+	case int:
+		return starlark.MakeInt(v), nil
+	case int64:
+		return starlark.MakeInt64(v), nil
+	case []any:
+		elems := make([]starlark.Value, len(v))
+		for i, e := range v {
+			value, err := toStarlark(e)
+			if err != nil {
+				return nil, err
+			}
+			elems[i] = value
+		}
+		return starlark.NewList(elems), nil
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		dict := starlark.NewDict(len(v))
+		for _, k := range keys {
+			value, err := toStarlark(v[k])
+			if err != nil {
+				return nil, err
+			}
+			if err := dict.SetKey(starlark.String(k), value); err != nil {
+				return nil, err
+			}
+		}
+		return dict, nil
+	}
+	return nil, fmt.Errorf("unsupported value of type %T", v)
+}
+
+// fromStarlark converts a Starlark value to a JSON value. Dict keys that are not strings are
+// written in their Starlark form.
+func fromStarlark(v starlark.Value) (any, error) {
+	switch v := v.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case starlark.Bool:
+		return bool(v), nil
+	case starlark.String:
+		return string(v), nil
+	case starlark.Int:
+		if i, ok := v.Int64(); ok {
+			return i, nil
+		}
+		return v.String(), nil
+	case starlark.Float:
+		return float64(v), nil
+	case starlark.Indexable: // list and tuple
+		values := make([]any, v.Len())
+		for i := range values {
+			value, err := fromStarlark(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		return values, nil
+	case *starlark.Dict:
+		values := make(map[string]any, v.Len())
+		for _, item := range v.Items() {
+			key, ok := starlark.AsString(item[0])
+			if !ok {
+				key = item[0].String()
+			}
+			value, err := fromStarlark(item[1])
+			if err != nil {
+				return nil, err
+			}
+			values[key] = value
+		}
+		return values, nil
+	case *starlark.Set:
+		var values []any
+		iter := v.Iterate()
+		defer iter.Done()
+		var elem starlark.Value
+		for iter.Next(&elem) {
+			value, err := fromStarlark(elem)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("cannot return a %s", v.Type())
+}
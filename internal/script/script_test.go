@@ -0,0 +1,130 @@
+package script
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"bot-go/internal/apperrors"
+	"bot-go/internal/codeapi"
+	"bot-go/internal/model/ast"
+)
+
+// fakeAPI implements the parts of codeapi.CodeAPI the tests call; the embedded nil
+// interfaces panic on anything else
+type fakeAPI struct {
+	codeapi.CodeAPI
+	cypherErr error
+}
+
+func (f *fakeAPI) Reader() codeapi.CodeReader      { return fakeReader{} }
+func (f *fakeAPI) Analyzer() codeapi.GraphAnalyzer { return fakeAnalyzer{} }
+
+func (f *fakeAPI) ExecuteCypher(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	if f.cypherErr != nil {
+		return nil, f.cypherErr
+	}
+	return []map[string]any{{"query": query, "params": params}}, nil
+}
+
+type fakeReader struct{ codeapi.CodeReader }
+
+func (fakeReader) ListRepos(ctx context.Context) ([]string, error) { return []string{"shop"}, nil }
+func (fakeReader) Repo(name string) codeapi.RepoReader             { return fakeRepo{} }
+
+type fakeRepo struct{ codeapi.RepoReader }
+
+func (fakeRepo) FindMethods(ctx context.Context, filter codeapi.MethodFilter) ([]*codeapi.MethodInfo, error) {
+	return []*codeapi.MethodInfo{
+		{ID: 1, Name: "HandleOrder", IsMethod: filter.IsMethod != nil && *filter.IsMethod},
+		{ID: 2, Name: "HandleRefund"},
+	}, nil
+}
+
+func (fakeRepo) GetMethod(ctx context.Context, id ast.NodeID) (*codeapi.MethodInfo, error) {
+	return nil, apperrors.NotFound("function", id)
+}
+
+type fakeAnalyzer struct{ codeapi.GraphAnalyzer }
+
+func (fakeAnalyzer) GetCallers(ctx context.Context, id ast.NodeID, depth int) (*codeapi.CallGraph, error) {
+	graph := &codeapi.CallGraph{Nodes: map[ast.NodeID]*codeapi.CallNode{id: {ID: id}}}
+	for i := 0; i < int(id); i++ {
+		caller := ast.NodeID(100 + i)
+		graph.Nodes[caller] = &codeapi.CallNode{ID: caller}
+	}
+	return graph, nil
+}
+
+func TestRunComposesBuiltins(t *testing.T) {
+	src := `
+fns = find_functions(args["repo"], name_like="Handle*", methods=False)
+counts = {}
+for f in fns:
+    counts[f["Name"]] = len(callers(f["ID"])["Nodes"]) - 1
+print("functions:", len(fns))
+result = {"counts": counts, "missing": get_function("shop", 9), "repos": repos()}
+`
+	result, err := Run(context.Background(), &fakeAPI{}, src, map[string]any{"repo": "shop"}, Limits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"counts":  map[string]any{"HandleOrder": int64(1), "HandleRefund": int64(2)},
+		"missing": nil,
+		"repos":   []any{"shop"},
+	}
+	if !reflect.DeepEqual(result.Value, want) {
+		t.Errorf("result = %#v, want %#v", result.Value, want)
+	}
+	if !reflect.DeepEqual(result.Output, []string{"functions: 2"}) || result.Calls != 5 {
+		t.Errorf("output = %q, calls = %d", result.Output, result.Calls)
+	}
+}
+
+func TestRunCypherParams(t *testing.T) {
+	result, err := Run(context.Background(), &fakeAPI{}, `result = cypher("MATCH (n) WHERE n.repo = $repo RETURN n", repo="shop")[0]["params"]`, nil, Limits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result.Value, map[string]any{"repo": "shop"}) {
+		t.Errorf("params = %#v", result.Value)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	unavailable := apperrors.Unavailable("neo4j", errors.New("connection refused"))
+	tests := []struct {
+		name    string
+		src     string
+		api     *fakeAPI
+		limits  Limits
+		is      error
+		message string
+	}{
+		{name: "syntax", src: "result = (", is: ErrScript},
+		{name: "runtime", src: "result = 1 // 0", is: ErrScript, message: "division by zero"},
+		{name: "bad arguments", src: "find_functions()", is: ErrScript, message: "missing argument for repo"},
+		{name: "steps", src: "x = 0\nwhile True:\n    x += 1", limits: Limits{MaxSteps: 1000}, is: ErrScript, message: "too many steps"},
+		{name: "calls", src: "for i in range(5):\n    repos()", limits: Limits{MaxCalls: 3}, is: ErrScript, message: "exceeded 3 codeapi calls"},
+		{name: "backend", src: "cypher('RETURN 1')", api: &fakeAPI{cypherErr: unavailable}, is: apperrors.ErrBackendUnavailable},
+		{name: "result type", src: "result = repos", is: ErrScript, message: "cannot return"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := tt.api
+			if api == nil {
+				api = &fakeAPI{}
+			}
+			_, err := Run(context.Background(), api, tt.src, nil, tt.limits)
+			if !errors.Is(err, tt.is) {
+				t.Fatalf("err = %v, want %v", err, tt.is)
+			}
+			if !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("err = %q, want it to contain %q", err, tt.message)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+// Package codecard builds "code cards": a compact text summary of each function (signature,
+// doc comment, callers and callees by name, complexity) generated at index time from the code
+// graph. Cards are stored as a JSON artifact per repository and embedded into a dedicated
+// vector collection, giving retrieval a smaller, higher-signal unit than raw code chunks.
+package codecard
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	maxCardNames = 8   // callers and callees named on a card; the rest are counted
+	maxDocChars  = 400 // doc comment text kept on a card
+)
+
+// Set is the code cards of one repository
+type Set struct {
+	Repo        string    `json:"repo"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Cards       []*Card   `json:"cards"` // ordered by file, then line
+}
+
+// Card summarizes one function of the latest indexed version of its file
+type Card struct {
+	FunctionID int64  `json:"function_id"`
+	Name       string `json:"name"`
+	Class      string `json:"class,omitempty"`
+	File       string `json:"file"`
+	FileID     int32  `json:"file_id"`
+	Line       int    `json:"line"` // 0-based
+	Lines      int    `json:"lines"`
+	Signature  string `json:"signature,omitempty"`
+	Doc        string `json:"doc,omitempty"`
+
+	// Callers and Callees are qualified names (Class.method), at most maxCardNames each;
+	// the counts include those left out
+	Callers     []string `json:"callers,omitempty"`
+	Callees     []string `json:"callees,omitempty"`
+	CallerCount int      `json:"caller_count"`
+	CalleeCount int      `json:"callee_count"`
+
+	// Complexity approximates cyclomatic complexity: one plus the conditionals and loops in
+	// the function
+	Complexity int `json:"complexity"`
+}
+
+// QualifiedName is the name of the function, prefixed with its class for methods
+func (c *Card) QualifiedName() string {
+	return qualify(c.Class, c.Name)
+}
+
+// Text renders the card as the compact text that is embedded, e.g.
+//
+//	Store.Get (store/store.go:12)
+//	func (s *Store) Get(key string) (string, error)
+//	Get returns the value of key
+//	Called by (2): Server.handle, main
+//	Calls (1): cache.Get
+//	Complexity 3, 14 lines
+func (c *Card) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s (%s:%d)\n", c.QualifiedName(), c.File, c.Line+1)
+	if c.Signature != "" {
+		b.WriteString(c.Signature + "\n")
+	}
+	if c.Doc != "" {
+		b.WriteString(c.Doc + "\n")
+	}
+	writeNames(&b, "Called by", c.Callers, c.CallerCount)
+	writeNames(&b, "Calls", c.Callees, c.CalleeCount)
+	fmt.Fprintf(&b, "Complexity %d, %d lines", c.Complexity, c.Lines)
+	return b.String()
+}
+
+func writeNames(b *strings.Builder, label string, names []string, count int) {
+	if count == 0 {
+		return
+	}
+	fmt.Fprintf(b, "%s (%d): %s", label, count, strings.Join(names, ", "))
+	if more := count - len(names); more > 0 {
+		fmt.Fprintf(b, " (+%d more)", more)
+	}
+	b.WriteString("\n")
+}
+
+// topNames returns the first maxCardNames of the sorted, distinct names, and how many
+// distinct names there are
+func topNames(names []string) ([]string, int) {
+	seen := make(map[string]bool, len(names))
+	distinct := make([]string, 0, len(names))
+	for _, name := range names {
+		if name != "" && !seen[name] {
+			seen[name] = true
+			distinct = append(distinct, name)
+		}
+	}
+	sort.Strings(distinct)
+	return distinct[:min(len(distinct), maxCardNames)], len(distinct)
+}
+
+// shortDoc keeps the first paragraph of a doc comment, up to maxDocChars
+func shortDoc(doc string) string {
+	doc = strings.TrimSpace(doc)
+	if i := strings.Index(doc, "\n\n"); i >= 0 {
+		doc = doc[:i]
+	}
+	doc = strings.Join(strings.Fields(doc), " ")
+	if len(doc) > maxDocChars {
+		cut := strings.LastIndex(doc[:maxDocChars], " ")
+		if cut <= 0 {
+			cut = maxDocChars
+		}
+		doc = doc[:cut] + "..."
+	}
+	return doc
+}
+
+func qualify(class, name string) string {
+	if class == "" {
+		return name
+	}
+	return class + "." + name
+}
+
+// File returns where the code cards of a repository are stored under the work directory
+func File(workDir, repo string) string {
+	return filepath.Join(workDir, "codecards", repo+".json")
+}
+
+// Save writes the card set to file
+func Save(file string, set *Set) error {
+	data, err := json.MarshalIndent(set, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode code cards: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return fmt.Errorf("failed to create code card directory: %w", err)
+	}
+	// Write then rename so readers never see a truncated set
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write code cards: %w", err)
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		return fmt.Errorf("failed to write code cards: %w", err)
+	}
+	return nil
+}
+
+// Load reads the card set stored in file; it returns nil without error if there is none
+func Load(file string) (*Set, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read code cards: %w", err)
+	}
+	var set Set
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse code cards %s: %w", file, err)
+	}
+	return &set, nil
+}
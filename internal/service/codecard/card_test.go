@@ -0,0 +1,87 @@
+package codecard
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCardText(t *testing.T) {
+	card := &Card{
+		Name:        "Get",
+		Class:       "Store",
+		File:        "store/store.go",
+		Line:        11,
+		Lines:       14,
+		Signature:   "func (s *Store) Get(key string) (string, error)",
+		Doc:         "Get returns the value of key",
+		Callers:     []string{"Server.handle", "main"},
+		CallerCount: 2,
+		CalleeCount: 0,
+		Complexity:  3,
+	}
+	want := `Store.Get (store/store.go:12)
+func (s *Store) Get(key string) (string, error)
+Get returns the value of key
+Called by (2): Server.handle, main
+Complexity 3, 14 lines`
+	if got := card.Text(); got != want {
+		t.Errorf("Text() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestTopNames(t *testing.T) {
+	var names []string
+	for i := 10; i > 0; i-- {
+		names = append(names, fmt.Sprintf("f%02d", i), fmt.Sprintf("f%02d", i))
+	}
+	names = append(names, "")
+
+	top, count := topNames(names)
+	if count != 10 {
+		t.Errorf("count = %d, want 10", count)
+	}
+	want := []string{"f01", "f02", "f03", "f04", "f05", "f06", "f07", "f08"}
+	if !reflect.DeepEqual(top, want) {
+		t.Errorf("top = %v, want %v", top, want)
+	}
+
+	card := &Card{Name: "run", Callees: top, CalleeCount: count}
+	if !strings.Contains(card.Text(), "Calls (10): f01, ") || !strings.Contains(card.Text(), "f08 (+2 more)") {
+		t.Errorf("Text() = %q", card.Text())
+	}
+}
+
+func TestShortDoc(t *testing.T) {
+	if got := shortDoc("  Get returns\n  the value.\n\nDetails follow."); got != "Get returns the value." {
+		t.Errorf("shortDoc = %q", got)
+	}
+	long := strings.Repeat("word ", 200)
+	got := shortDoc(long)
+	if len(got) > maxDocChars+3 || !strings.HasSuffix(got, "word...") {
+		t.Errorf("shortDoc of long doc = %q", got)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	file := File(t.TempDir(), "repo")
+	if set, err := Load(file); set != nil || err != nil {
+		t.Fatalf("Load of missing file = %v, %v", set, err)
+	}
+	set := &Set{Repo: "repo", Cards: []*Card{{FunctionID: 7, Name: "main", File: "main.go", Complexity: 1}}}
+	if err := Save(file, set); err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(filepath.Dir(file)) != "codecards" {
+		t.Errorf("File = %s", file)
+	}
+	loaded, err := Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.Cards, set.Cards) {
+		t.Errorf("loaded %+v, want %+v", loaded.Cards[0], set.Cards[0])
+	}
+}
@@ -0,0 +1,395 @@
+package codecard
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"bot-go/internal/apisurface"
+	"bot-go/internal/apperrors"
+	"bot-go/internal/config"
+	"bot-go/internal/model"
+	"bot-go/internal/service/codegraph"
+	"bot-go/internal/service/vector"
+	"bot-go/pkg/lsp/base"
+
+	"go.uber.org/zap"
+)
+
+// CollectionSuffix is appended to the repository name to form the code card collection
+const CollectionSuffix = "_cards"
+
+const (
+	callBatchSize  = 1000 // functions per caller/callee query
+	embedBatchSize = 64   // cards per embedding request and upsert
+	maxSourceBytes = 2 << 20
+)
+
+// Service builds the code cards of a repository from its code graph, stores them under the
+// work directory and embeds them into the repository's card collection
+type Service struct {
+	graph    *codegraph.CodeGraph
+	vectorDB vector.VectorDatabase // nil: cards are stored but not embedded
+	embedder vector.EmbeddingModel
+	workDir  string // "": cards are embedded but not stored
+	logger   *zap.Logger
+}
+
+// Match is a code card similar to a search query
+type Match struct {
+	FunctionID int64      `json:"function_id"`
+	Name       string     `json:"name"`
+	FilePath   string     `json:"file_path"`
+	Range      base.Range `json:"range"`
+	Card       string     `json:"card"` // the card text
+	Score      float32    `json:"score"`
+}
+
+// NewService creates a code card service. Without a vector database or embedding model,
+// cards are only stored under workDir.
+func NewService(graph *codegraph.CodeGraph, vectorDB vector.VectorDatabase, embedder vector.EmbeddingModel, workDir string, logger *zap.Logger) *Service {
+	if vectorDB == nil || embedder == nil {
+		vectorDB, embedder = nil, nil
+	}
+	return &Service{
+		graph:    graph,
+		vectorDB: vectorDB,
+		embedder: embedder,
+		workDir:  workDir,
+		logger:   logger,
+	}
+}
+
+// CollectionName returns the vector collection holding the code cards of a repository
+func CollectionName(repoName string) string {
+	return repoName + CollectionSuffix
+}
+
+// Searchable reports whether cards are embedded, so that Search can be used
+func (s *Service) Searchable() bool {
+	return s.vectorDB != nil
+}
+
+// WorkDir returns the directory cards are stored under, "" if they are not stored
+func (s *Service) WorkDir() string {
+	return s.workDir
+}
+
+// Build generates a card for every function of the latest indexed version of each file of the
+// repository, stores the set and replaces the repository's card collection with it. It needs
+// calls to be resolved, so it runs after the code graph post-processing.
+func (s *Service) Build(ctx context.Context, repo *config.Repository) (*Set, error) {
+	cards, err := s.loadCards(ctx, repo.Name)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.loadCalls(ctx, cards); err != nil {
+		return nil, err
+	}
+	s.describe(ctx, repo.Path, cards)
+
+	set := &Set{Repo: repo.Name, GeneratedAt: time.Now().UTC(), Cards: cards}
+	if s.workDir != "" {
+		if err := Save(File(s.workDir, repo.Name), set); err != nil {
+			return nil, err
+		}
+	}
+	if s.vectorDB != nil {
+		if err := s.embed(ctx, repo.Name, cards); err != nil {
+			return nil, err
+		}
+	}
+	return set, nil
+}
+
+// Search returns the code cards closest to a natural language or code query
+func (s *Service) Search(ctx context.Context, repoName, query string, limit int) ([]*Match, error) {
+	if s.vectorDB == nil {
+		return nil, fmt.Errorf("code cards are not embedded without vector search")
+	}
+	collectionName := CollectionName(repoName)
+	exists, err := s.vectorDB.CollectionExists(ctx, collectionName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check collection existence: %w", err)
+	}
+	if !exists {
+		return nil, apperrors.NotFound("code cards", repoName+" (index it with index_building.code_cards)")
+	}
+	embedding, err := s.embedder.GenerateEmbedding(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+	chunks, scores, err := s.vectorDB.SearchSimilar(ctx, collectionName, embedding, limit, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search code cards: %w", err)
+	}
+	matches := make([]*Match, len(chunks))
+	for i, c := range chunks {
+		matches[i] = &Match{
+			FunctionID: toInt64(c.Metadata["node_id"]),
+			Name:       c.Name,
+			FilePath:   c.FilePath,
+			Range:      c.Range,
+			Card:       toString(c.Metadata["card"]),
+			Score:      scores[i],
+		}
+	}
+	return matches, nil
+}
+
+// loadCards reads the functions of the latest version of each file, with their complexity
+func (s *Service) loadCards(ctx context.Context, repoName string) ([]*Card, error) {
+	records, err := s.graph.ExecuteRead(ctx, `
+		MATCH (fs:FileScope {repo: $repo})
+		WITH fs.path AS path, max(fs.id) AS fileId
+		MATCH (f:Function {fileId: fileId})
+		OPTIONAL MATCH (c:Class)-[:CONTAINS]->(f)
+		OPTIONAL MATCH (f)-[:CONTAINS*]->(branch)
+		WHERE branch:Conditional OR branch:Loop
+		RETURN f.id AS id, f.name AS name, c.name AS className, path, fileId,
+		       f {.startLine, .startChar, .endLine, .endChar, .range} AS range,
+		       f.md_signature AS signature, f.md_docstring AS docstring,
+		       count(DISTINCT branch) AS branches
+		ORDER BY path, id
+	`, map[string]any{"repo": repoName})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load functions: %w", err)
+	}
+
+	cards := make([]*Card, len(records))
+	for i, record := range records {
+		rng := codegraph.RangeFromValue(record["range"])
+		cards[i] = &Card{
+			FunctionID: toInt64(record["id"]),
+			Name:       toString(record["name"]),
+			Class:      toString(record["className"]),
+			File:       toString(record["path"]),
+			FileID:     int32(toInt64(record["fileId"])),
+			Line:       rng.Start.Line,
+			Lines:      rng.End.Line - rng.Start.Line + 1,
+			Signature:  toString(record["signature"]),
+			Doc:        toString(record["docstring"]),
+			Complexity: 1 + int(toInt64(record["branches"])),
+		}
+	}
+	sort.SliceStable(cards, func(i, j int) bool {
+		if cards[i].File != cards[j].File {
+			return cards[i].File < cards[j].File
+		}
+		return cards[i].Line < cards[j].Line
+	})
+	return cards, nil
+}
+
+// loadCalls fills in the callers and callees of the cards by name
+func (s *Service) loadCalls(ctx context.Context, cards []*Card) error {
+	byID := make(map[int64]*Card, len(cards))
+	for _, card := range cards {
+		byID[card.FunctionID] = card
+	}
+	queries := []struct {
+		query string
+		set   func(card *Card, names []string)
+	}{
+		{`
+			MATCH (f:Function)-[:CONTAINS*]->(:FunctionCall)-[:CALLS_FUNCTION]->(other:Function)
+			WHERE f.id IN $ids
+			OPTIONAL MATCH (c:Class)-[:CONTAINS]->(other)
+			RETURN f.id AS id, collect(coalesce(c.name + '.', '') + other.name) AS names
+		`, func(card *Card, names []string) { card.Callees, card.CalleeCount = topNames(names) }},
+		{`
+			MATCH (other:Function)-[:CONTAINS*]->(:FunctionCall)-[:CALLS_FUNCTION]->(f:Function)
+			WHERE f.id IN $ids
+			OPTIONAL MATCH (c:Class)-[:CONTAINS]->(other)
+			RETURN f.id AS id, collect(coalesce(c.name + '.', '') + other.name) AS names
+		`, func(card *Card, names []string) { card.Callers, card.CallerCount = topNames(names) }},
+	}
+
+	for start := 0; start < len(cards); start += callBatchSize {
+		batch := cards[start:min(start+callBatchSize, len(cards))]
+		ids := make([]int64, len(batch))
+		for i, card := range batch {
+			ids[i] = card.FunctionID
+		}
+		for _, q := range queries {
+			records, err := s.graph.ExecuteRead(ctx, q.query, map[string]any{"ids": ids})
+			if err != nil {
+				return fmt.Errorf("failed to load calls: %w", err)
+			}
+			for _, record := range records {
+				card := byID[toInt64(record["id"])]
+				if card == nil {
+					continue
+				}
+				values, _ := record["names"].([]any)
+				names := make([]string, 0, len(values))
+				for _, v := range values {
+					names = append(names, toString(v))
+				}
+				q.set(card, names)
+			}
+		}
+	}
+	return nil
+}
+
+// describe fills in the signatures and doc comments the graph does not have from the source
+// files under root. Functions are matched by start line, or else by name when it is unique
+// in the file; files that cannot be read keep cards without them.
+func (s *Service) describe(ctx context.Context, root string, cards []*Card) {
+	byFile := make(map[string][]*Card)
+	var files []string
+	for _, card := range cards {
+		if byFile[card.File] == nil {
+			files = append(files, card.File)
+		}
+		byFile[card.File] = append(byFile[card.File], card)
+	}
+
+	for _, file := range files {
+		if ctx.Err() != nil {
+			return
+		}
+		var symbols []apisurface.Symbol
+		content, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(file)))
+		if err == nil && len(content) <= maxSourceBytes {
+			symbols, err = apisurface.Functions(ctx, s.logger, file, content)
+		}
+		if err != nil {
+			s.logger.Debug("No signatures for code cards of file", zap.String("path", file), zap.Error(err))
+		}
+
+		byLine := make(map[int]*apisurface.Symbol, len(symbols))
+		byName := make(map[string]*apisurface.Symbol, len(symbols))
+		nameCount := make(map[string]int, len(symbols))
+		for i := range symbols {
+			byLine[symbols[i].Line] = &symbols[i]
+			name := qualify(symbols[i].Class, symbols[i].Name)
+			byName[name] = &symbols[i]
+			nameCount[name]++
+		}
+		for _, card := range byFile[file] {
+			symbol := byLine[card.Line]
+			if symbol == nil && nameCount[card.QualifiedName()] == 1 {
+				symbol = byName[card.QualifiedName()]
+			}
+			if symbol != nil {
+				if card.Signature == "" {
+					card.Signature = symbol.Signature
+				}
+				if card.Doc == "" {
+					card.Doc = symbol.Doc
+				}
+				if card.Class == "" {
+					card.Class = symbol.Class
+				}
+			}
+			card.Doc = shortDoc(card.Doc)
+		}
+	}
+}
+
+// embed replaces the card collection of the repository with the embedded card texts
+func (s *Service) embed(ctx context.Context, repoName string, cards []*Card) error {
+	collectionName := CollectionName(repoName)
+	exists, err := s.vectorDB.CollectionExists(ctx, collectionName)
+	if err != nil {
+		return fmt.Errorf("failed to check collection existence: %w", err)
+	}
+	if exists {
+		// Function IDs change with every file version, so start fresh
+		if err := s.vectorDB.DeleteCollection(ctx, collectionName); err != nil {
+			return fmt.Errorf("failed to reset code card collection: %w", err)
+		}
+	}
+	if err := s.vectorDB.CreateCollection(ctx, collectionName, s.embedder.GetDimension(), vector.DistanceMetricCosine); err != nil {
+		return fmt.Errorf("failed to create code card collection: %w", err)
+	}
+
+	for start := 0; start < len(cards); start += embedBatchSize {
+		batch := cards[start:min(start+embedBatchSize, len(cards))]
+		texts := make([]string, len(batch))
+		for i, card := range batch {
+			texts[i] = card.Text()
+		}
+		embeddings, err := s.embedder.GenerateEmbeddings(ctx, texts)
+		if err != nil {
+			return fmt.Errorf("failed to embed code cards: %w", err)
+		}
+		if len(embeddings) != len(batch) {
+			return fmt.Errorf("failed to embed code cards: got %d embeddings for %d cards", len(embeddings), len(batch))
+		}
+		chunks := make([]*model.CodeChunk, len(batch))
+		for i, card := range batch {
+			chunks[i] = cardToChunk(repoName, card, texts[i], embeddings[i])
+		}
+		if err := s.vectorDB.UpsertChunks(ctx, collectionName, chunks); err != nil {
+			return fmt.Errorf("failed to store code cards: %w", err)
+		}
+	}
+
+	s.logger.Info("Stored code cards",
+		zap.String("repo", repoName),
+		zap.String("collection", collectionName),
+		zap.Int("count", len(cards)))
+	return nil
+}
+
+func cardToChunk(repoName string, card *Card, text string, embedding []float32) *model.CodeChunk {
+	rng := base.Range{
+		Start: base.Position{Line: card.Line},
+		End:   base.Position{Line: card.Line + card.Lines - 1},
+	}
+	chunk := model.NewCodeChunk(cardChunkID(repoName, card.FunctionID), model.ChunkTypeFunction, 3, text, "", card.File, rng).
+		WithFileID(card.FileID).
+		WithName(card.QualifiedName()).
+		WithSignature(card.Signature).
+		WithDocstring(card.Doc)
+	chunk.Embedding = embedding
+	chunk.Metadata["node_id"] = card.FunctionID
+	chunk.Metadata["file_id"] = int64(card.FileID)
+	chunk.Metadata["embedding_kind"] = "code_card"
+	// Chunk content is not stored in the collection, so the card text travels in the metadata
+	chunk.Metadata["card"] = text
+	return chunk
+}
+
+// cardChunkID derives a stable UUID-formatted point ID from repository and function ID
+func cardChunkID(repoName string, functionID int64) string {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s:card:%d", repoName, functionID)))
+	hashStr := hex.EncodeToString(hash[:])
+	return fmt.Sprintf("%s-%s-%s-%s-%s",
+		hashStr[0:8],
+		hashStr[8:12],
+		hashStr[12:16],
+		hashStr[16:20],
+		hashStr[20:32],
+	)
+}
+
+func toInt64(v any) int64 {
+	switch val := v.(type) {
+	case int64:
+		return val
+	case int:
+		return int64(val)
+	case int32:
+		return int64(val)
+	case float64:
+		return int64(val)
+	default:
+		return 0
+	}
+}
+
+func toString(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return ""
+}
@@ -0,0 +1,470 @@
+package codegraph
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// archivePageSize is the number of nodes read, or rows written, per query of an export or import
+const archivePageSize = 1000
+
+// satelliteLabels are the labels of repository nodes without an id: they are selected by
+// their repo property, and only relations from nodes with an id point to them
+var satelliteLabels = []string{"Commit", "StringLiteral"}
+
+// GraphArchive counts what an export of a repository holds. FileIDs are the files of the
+// repository, so that a partial restore can be cleaned up.
+type GraphArchive struct {
+	Nodes      int     `json:"nodes"`
+	Relations  int     `json:"relations"`
+	Satellites int     `json:"satellites"` // Commit and StringLiteral nodes
+	FileIDs    []int64 `json:"file_ids"`
+}
+
+// archiveRecord is a line of a repository export. Type is "node" or "satellite" in the node
+// stream, and "relation" in the relation stream.
+type archiveRecord struct {
+	Type     string             `json:"type"`
+	Labels   []string           `json:"labels,omitempty"`
+	Props    map[string]any     `json:"props,omitempty"`
+	Relation *archiveRelation   `json:"relation,omitempty"`
+	Incoming map[string][]int64 `json:"incoming,omitempty"` // of a satellite: ids of the nodes relating to it, by relation type
+}
+
+type archiveRelation struct {
+	From  int64          `json:"from"`
+	To    int64          `json:"to"`
+	Type  string         `json:"type"`
+	Props map[string]any `json:"props,omitempty"`
+}
+
+// ExportRepository writes the code graph of a repository as JSON lines: its nodes, then its
+// satellites, to nodes, and the relations between nodes with an id that start or end in the
+// repository to relations. Properties are written as stored, so ImportRepository recreates
+// the graph as it was; relations to satellites keep only their type.
+func (cg *CodeGraph) ExportRepository(ctx context.Context, repoName string, nodes, relations io.Writer) (*GraphArchive, error) {
+	fileIDs, err := cg.repositoryFileIDs(ctx, repoName)
+	if err != nil {
+		return nil, err
+	}
+	archive := &GraphArchive{FileIDs: fileIDs}
+	nodeEnc, relationEnc := json.NewEncoder(nodes), json.NewEncoder(relations)
+
+	query := `
+		MATCH (n)
+		WHERE n.fileId IN $fileIds AND n.id > $afterId
+		WITH n ORDER BY n.id LIMIT $limit
+		OPTIONAL MATCH (n)-[r]->(b)
+		WHERE b.id IS NOT NULL
+		WITH n, collect({to: b.id, type: type(r), props: properties(r)}) AS outgoing
+		OPTIONAL MATCH (a)-[r]->(n)
+		WHERE a.id IS NOT NULL AND NOT a.fileId IN $fileIds
+		RETURN n.id AS id, labels(n) AS labels, properties(n) AS props, outgoing,
+		       collect({from: a.id, type: type(r), props: properties(r)}) AS incoming
+		ORDER BY id
+	`
+	params := map[string]any{"fileIds": fileIDs, "afterId": int64(-1), "limit": int64(archivePageSize)}
+	for {
+		records, err := cg.db.ExecuteRead(ctx, query, params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read nodes of %s: %w", repoName, err)
+		}
+		for _, record := range records {
+			id := cg.convertToInt64(record["id"])
+			if err := nodeEnc.Encode(archiveRecord{Type: "node", Labels: archiveLabels(record["labels"]), Props: archiveProps(record["props"])}); err != nil {
+				return nil, err
+			}
+			archive.Nodes++
+			for _, rel := range cg.archiveRelations(record["outgoing"], "to") {
+				rel.From, rel.To = id, rel.From
+				if err := relationEnc.Encode(archiveRecord{Type: "relation", Relation: rel}); err != nil {
+					return nil, err
+				}
+				archive.Relations++
+			}
+			for _, rel := range cg.archiveRelations(record["incoming"], "from") {
+				rel.To = id
+				if err := relationEnc.Encode(archiveRecord{Type: "relation", Relation: rel}); err != nil {
+					return nil, err
+				}
+				archive.Relations++
+			}
+			params["afterId"] = id
+		}
+		if len(records) < archivePageSize {
+			break
+		}
+	}
+
+	for _, label := range satelliteLabels {
+		n, err := cg.exportSatellites(ctx, repoName, label, nodeEnc)
+		if err != nil {
+			return nil, err
+		}
+		archive.Satellites += n
+	}
+
+	cg.log(ctx).Info("Exported code graph of repository",
+		zap.String("repo", repoName),
+		zap.Int("nodes", archive.Nodes),
+		zap.Int("relations", archive.Relations),
+		zap.Int("satellites", archive.Satellites))
+	return archive, nil
+}
+
+// exportSatellites writes the nodes of a satellite label of a repository with the ids of the
+// nodes relating to them
+func (cg *CodeGraph) exportSatellites(ctx context.Context, repoName, label string, enc *json.Encoder) (int, error) {
+	// Satellites have no id property, so they are paged by their database id
+	query := fmt.Sprintf(`
+		MATCH (s:%s {repo: $repo})
+		WHERE id(s) > $after
+		WITH s ORDER BY id(s) LIMIT $limit
+		OPTIONAL MATCH (a)-[r]->(s)
+		WHERE a.id IS NOT NULL
+		RETURN id(s) AS key, labels(s) AS labels, properties(s) AS props,
+		       collect({from: a.id, type: type(r)}) AS incoming
+		ORDER BY key
+	`, cypherName(label))
+	params := map[string]any{"repo": repoName, "after": int64(-1), "limit": int64(archivePageSize)}
+	exported := 0
+	for {
+		records, err := cg.db.ExecuteRead(ctx, query, params)
+		if err != nil {
+			return exported, fmt.Errorf("failed to read %s nodes of %s: %w", label, repoName, err)
+		}
+		for _, record := range records {
+			incoming := make(map[string][]int64)
+			for _, rel := range cg.archiveRelations(record["incoming"], "from") {
+				incoming[rel.Type] = append(incoming[rel.Type], rel.From)
+			}
+			satellite := archiveRecord{Type: "satellite", Labels: archiveLabels(record["labels"]), Props: archiveProps(record["props"])}
+			if len(incoming) > 0 {
+				satellite.Incoming = incoming
+			}
+			if err := enc.Encode(satellite); err != nil {
+				return exported, err
+			}
+			exported++
+		}
+		if len(records) > 0 {
+			params["after"] = cg.convertToInt64(records[len(records)-1]["key"])
+		}
+		if len(records) < archivePageSize {
+			return exported, nil
+		}
+	}
+}
+
+// ImportRepository recreates the code graph written by ExportRepository. Nodes are merged on
+// their id, so importing over a partial import does not duplicate them; relations are
+// created, so the repository should be dropped first (see DropRepository). Relations to
+// nodes that no longer exist, e.g. of a repository deleted since, are skipped.
+func (cg *CodeGraph) ImportRepository(ctx context.Context, nodes, relations io.Reader) (*GraphArchive, error) {
+	imp := &graphImporter{cg: cg, groups: make(map[string][]map[string]any), archive: &GraphArchive{}}
+	if err := imp.read(ctx, nodes); err != nil {
+		return nil, err
+	}
+	if err := imp.read(ctx, relations); err != nil {
+		return nil, err
+	}
+	cg.log(ctx).Info("Imported code graph",
+		zap.Int("nodes", imp.archive.Nodes),
+		zap.Int("relations", imp.archive.Relations),
+		zap.Int("satellites", imp.archive.Satellites))
+	return imp.archive, nil
+}
+
+// DropRepository deletes the code graph of a repository (see CleanRepository) and the nodes
+// of its files that are not contained by a FileScope. fileIDs adds files whose FileScope may
+// be missing, e.g. after an interrupted import.
+func (cg *CodeGraph) DropRepository(ctx context.Context, repoName string, fileIDs []int64) error {
+	found, err := cg.repositoryFileIDs(ctx, repoName)
+	if err != nil {
+		return err
+	}
+	if err := cg.CleanRepository(ctx, repoName); err != nil {
+		return err
+	}
+	fileIDs = append(slices.Clone(fileIDs), found...)
+	if len(fileIDs) == 0 {
+		return nil
+	}
+	query := `
+		MATCH (n)
+		WHERE n.fileId IN $fileIds
+		WITH n LIMIT $limit
+		DETACH DELETE n
+		RETURN count(*) AS deleted
+	`
+	params := map[string]any{"fileIds": fileIDs, "limit": int64(archivePageSize)}
+	for {
+		record, err := cg.db.ExecuteWriteSingle(ctx, query, params)
+		if err != nil {
+			return fmt.Errorf("failed to delete nodes of %s: %w", repoName, err)
+		}
+		if cg.convertToInt64(record["deleted"]) < archivePageSize {
+			return nil
+		}
+	}
+}
+
+// repositoryFileIDs returns the file IDs of the FileScopes of a repository
+func (cg *CodeGraph) repositoryFileIDs(ctx context.Context, repoName string) ([]int64, error) {
+	records, err := cg.db.ExecuteRead(ctx, `
+		MATCH (fs:FileScope {repo: $repo})
+		RETURN fs.fileId AS fileId
+	`, map[string]any{"repo": repoName})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read files of %s: %w", repoName, err)
+	}
+	fileIDs := make([]int64, 0, len(records))
+	for _, record := range records {
+		if record["fileId"] != nil {
+			fileIDs = append(fileIDs, cg.convertToInt64(record["fileId"]))
+		}
+	}
+	return fileIDs, nil
+}
+
+// graphImporter batches the records of an export by the query that writes them. All batches
+// are written before a record of another type, so nodes exist before what points to them.
+type graphImporter struct {
+	cg        *CodeGraph
+	recType   string
+	groups    map[string][]map[string]any // by labels or relation type
+	satellite map[string]satelliteGroup   // query parts of satellite groups
+	archive   *GraphArchive
+}
+
+type satelliteGroup struct {
+	labels string
+	types  []string
+}
+
+func (imp *graphImporter) read(ctx context.Context, r io.Reader) error {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	for {
+		var record archiveRecord
+		if err := dec.Decode(&record); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return fmt.Errorf("failed to read graph archive: %w", err)
+		}
+		if record.Type != imp.recType {
+			if err := imp.flushAll(ctx); err != nil {
+				return err
+			}
+			imp.recType = record.Type
+		}
+		key, row, err := imp.row(record)
+		if err != nil {
+			return err
+		}
+		imp.groups[key] = append(imp.groups[key], row)
+		if len(imp.groups[key]) == archivePageSize {
+			if err := imp.flush(ctx, key); err != nil {
+				return err
+			}
+		}
+	}
+	return imp.flushAll(ctx)
+}
+
+// row returns the group of a record and its query parameters
+func (imp *graphImporter) row(record archiveRecord) (string, map[string]any, error) {
+	switch record.Type {
+	case "node":
+		props := restoreProps(record.Props)
+		return cypherLabels(record.Labels), map[string]any{"id": props["id"], "props": props}, nil
+	case "relation":
+		rel := record.Relation
+		if rel == nil {
+			return "", nil, fmt.Errorf("graph archive: relation record without a relation")
+		}
+		return cypherName(rel.Type), map[string]any{"from": rel.From, "to": rel.To, "props": restoreProps(rel.Props)}, nil
+	case "satellite":
+		group := satelliteGroup{labels: cypherLabels(record.Labels)}
+		for relType := range record.Incoming {
+			group.types = append(group.types, relType)
+		}
+		sort.Strings(group.types)
+		key := group.labels + " " + strings.Join(group.types, " ")
+		if imp.satellite == nil {
+			imp.satellite = make(map[string]satelliteGroup)
+		}
+		imp.satellite[key] = group
+		row := map[string]any{"props": restoreProps(record.Props)}
+		for i, relType := range group.types {
+			row[fmt.Sprintf("in%d", i)] = record.Incoming[relType]
+		}
+		return key, row, nil
+	}
+	return "", nil, fmt.Errorf("graph archive: unknown record type %q", record.Type)
+}
+
+func (imp *graphImporter) flushAll(ctx context.Context) error {
+	keys := make([]string, 0, len(imp.groups))
+	for key := range imp.groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := imp.flush(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (imp *graphImporter) flush(ctx context.Context, key string) error {
+	rows := imp.groups[key]
+	delete(imp.groups, key)
+	if len(rows) == 0 {
+		return nil
+	}
+	var query string
+	switch imp.recType {
+	case "node":
+		query = fmt.Sprintf(`
+			UNWIND $rows AS row
+			MERGE (n:%s {id: row.id})
+			SET n = row.props
+		`, key)
+		imp.archive.Nodes += len(rows)
+	case "relation":
+		query = fmt.Sprintf(`
+			UNWIND $rows AS row
+			MATCH (a {id: row.from})
+			MATCH (b {id: row.to})
+			CREATE (a)-[r:%s]->(b)
+			SET r = row.props
+		`, key)
+		imp.archive.Relations += len(rows)
+	case "satellite":
+		group := imp.satellite[key]
+		var b strings.Builder
+		fmt.Fprintf(&b, "UNWIND $rows AS row\nCREATE (s:%s)\nSET s = row.props\n", group.labels)
+		for i, relType := range group.types {
+			fmt.Fprintf(&b, "WITH DISTINCT s, row\nOPTIONAL MATCH (a%d) WHERE a%d.id IN row.in%d\n", i, i, i)
+			fmt.Fprintf(&b, "FOREACH (_ IN CASE WHEN a%d IS NULL THEN [] ELSE [1] END | CREATE (a%d)-[:%s]->(s))\n", i, i, cypherName(relType))
+		}
+		query = b.String()
+		imp.archive.Satellites += len(rows)
+	}
+	if _, err := imp.cg.db.ExecuteWrite(ctx, query, map[string]any{"rows": rows}); err != nil {
+		return fmt.Errorf("failed to import %s records (%s): %w", imp.recType, key, err)
+	}
+	return nil
+}
+
+// cypherName quotes a label or relation type read from the database for a query
+func cypherName(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+func cypherLabels(labels []string) string {
+	quoted := make([]string, len(labels))
+	for i, label := range labels {
+		quoted[i] = cypherName(label)
+	}
+	return strings.Join(quoted, ":")
+}
+
+func archiveLabels(v any) []string {
+	list, _ := v.([]any)
+	labels := make([]string, 0, len(list))
+	for _, label := range list {
+		if s, ok := label.(string); ok {
+			labels = append(labels, s)
+		}
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// archiveRelations reads the relations collected by an export query, skipping the empty
+// entry of a node without any; the other endpoint (key "to" or "from") is returned in From
+func (cg *CodeGraph) archiveRelations(v any, endpoint string) []*archiveRelation {
+	list, _ := v.([]any)
+	var relations []*archiveRelation
+	for _, item := range list {
+		m, _ := item.(map[string]any)
+		if m == nil || m[endpoint] == nil || m["type"] == nil {
+			continue
+		}
+		relType, _ := m["type"].(string)
+		rel := &archiveRelation{From: cg.convertToInt64(m[endpoint]), Type: relType}
+		if props := archiveProps(m["props"]); len(props) > 0 {
+			rel.Props = props
+		}
+		relations = append(relations, rel)
+	}
+	return relations
+}
+
+// archiveProps prepares properties for JSON: floats keep a decimal point, so restoreProps
+// can tell them from integers
+func archiveProps(v any) map[string]any {
+	props, _ := v.(map[string]any)
+	for key, value := range props {
+		props[key] = archiveValue(value)
+	}
+	return props
+}
+
+func archiveValue(v any) any {
+	switch v := v.(type) {
+	case float64:
+		s := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eEN") { // N: NaN and Inf, which JSON cannot hold anyway
+			s += ".0"
+		}
+		return json.Number(s)
+	case []any:
+		for i, item := range v {
+			v[i] = archiveValue(item)
+		}
+	}
+	return v
+}
+
+// restoreProps converts the numbers of properties decoded with UseNumber back to int64 and
+// float64
+func restoreProps(props map[string]any) map[string]any {
+	if props == nil {
+		return map[string]any{}
+	}
+	for key, value := range props {
+		props[key] = restoreValue(value)
+	}
+	return props
+}
+
+func restoreValue(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if !strings.ContainsAny(string(v), ".eE") {
+			if n, err := v.Int64(); err == nil {
+				return n
+			}
+		}
+		f, _ := v.Float64()
+		return f
+	case []any:
+		for i, item := range v {
+			v[i] = restoreValue(item)
+		}
+	}
+	return v
+}
@@ -0,0 +1,83 @@
+package codegraph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestArchivePropsRoundTrip(t *testing.T) {
+	props := map[string]any{
+		"id":     int64(1<<32 | 7),
+		"name":   "handler",
+		"score":  float64(2),
+		"weight": 0.25,
+		"lines":  []any{int64(3), int64(4)},
+		"cached": true,
+	}
+	want := map[string]any{}
+	for k, v := range props {
+		want[k] = v
+	}
+	want["lines"] = []any{int64(3), int64(4)}
+
+	data, err := json.Marshal(archiveProps(props))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var decoded map[string]any
+	if err := dec.Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	if got := restoreProps(decoded); !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip = %#v, want %#v", got, want)
+	}
+}
+
+func TestImportRepository(t *testing.T) {
+	db := &fakeDatabase{name: "leader"}
+	cg := &CodeGraph{db: db, logger: zap.NewNop(), fileIDCache: make(map[int32]string)}
+
+	nodes := strings.Join([]string{
+		`{"type":"node","labels":["FileScope"],"props":{"id":10,"fileId":1,"repo":"shop"}}`,
+		`{"type":"node","labels":["Function"],"props":{"id":11,"fileId":1,"name":"a"}}`,
+		`{"type":"node","labels":["Function"],"props":{"id":12,"fileId":1,"name":"b","score":1.0}}`,
+		`{"type":"satellite","labels":["Commit"],"props":{"repo":"shop","hash":"abc"},"incoming":{"MODIFIED_IN":[10,11]}}`,
+	}, "\n")
+	relations := `{"type":"relation","relation":{"from":11,"to":12,"type":"CALLS","props":{"fileId":1}}}`
+
+	archive, err := cg.ImportRepository(context.Background(), strings.NewReader(nodes), strings.NewReader(relations))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if archive.Nodes != 3 || archive.Relations != 1 || archive.Satellites != 1 {
+		t.Errorf("imported %+v", archive)
+	}
+
+	// One write per label, then the satellites, then the relations
+	if len(db.writes) != 4 {
+		t.Fatalf("got %d writes, want 4", len(db.writes))
+	}
+	functions := db.writes[1]["rows"].([]map[string]any)
+	if len(functions) != 2 || functions[0]["id"] != int64(11) {
+		t.Errorf("function rows = %v", functions)
+	}
+	if score := functions[1]["props"].(map[string]any)["score"]; score != float64(1) {
+		t.Errorf("score = %#v, want float64 1", score)
+	}
+	commit := db.writes[2]["rows"].([]map[string]any)[0]
+	if !reflect.DeepEqual(commit["in0"], []int64{10, 11}) {
+		t.Errorf("commit row = %v", commit)
+	}
+	call := db.writes[3]["rows"].([]map[string]any)[0]
+	if call["from"] != int64(11) || call["to"] != int64(12) {
+		t.Errorf("relation row = %v", call)
+	}
+}
@@ -0,0 +1,94 @@
+package codegraph
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// tunerWindow is the number of full batches measured before the size changes
+	tunerWindow = 8
+	// tunerStep is the factor by which the size grows or shrinks
+	tunerStep = 1.5
+)
+
+// batchTuner adjusts the write batch size within bounds by hill climbing on throughput.
+// After each window of batches it compares the items written per second with the previous
+// window's and keeps moving the size in the same direction while throughput improves,
+// reversing when it drops or a bound is reached.
+type batchTuner struct {
+	minSize int
+	maxSize int
+	size    atomic.Int64
+	logger  *zap.Logger
+
+	mu             sync.Mutex
+	items          int
+	elapsed        time.Duration
+	batches        int
+	lastThroughput float64
+	growing        bool
+}
+
+func newBatchTuner(initial, minSize, maxSize int, logger *zap.Logger) *batchTuner {
+	t := &batchTuner{minSize: minSize, maxSize: maxSize, logger: logger, growing: true}
+	t.size.Store(int64(min(max(initial, minSize), maxSize)))
+	return t
+}
+
+// Size returns the current batch size
+func (t *batchTuner) Size() int {
+	return int(t.size.Load())
+}
+
+// observe records one batch write of items taking elapsed
+func (t *batchTuner) observe(items int, elapsed time.Duration) {
+	size := t.Size()
+	// Remainders flushed when a file finishes say little about the batch size
+	if items < size/2 || elapsed <= 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.items += items
+	t.elapsed += elapsed
+	t.batches++
+	if t.batches < tunerWindow {
+		return
+	}
+
+	throughput := float64(t.items) / t.elapsed.Seconds()
+	t.items, t.elapsed, t.batches = 0, 0, 0
+	if t.lastThroughput > 0 && throughput < t.lastThroughput {
+		t.growing = !t.growing
+	}
+	t.lastThroughput = throughput
+
+	next := t.step(size)
+	if next == size {
+		// At a bound: try the other direction next
+		t.growing = !t.growing
+		next = t.step(size)
+	}
+	if next == size {
+		return
+	}
+	t.size.Store(int64(next))
+	t.logger.Info("Tuned code graph batch size",
+		zap.Int("from", size),
+		zap.Int("to", next),
+		zap.Float64("items_per_second", throughput))
+}
+
+// step returns the next size in the current direction, within bounds
+func (t *batchTuner) step(size int) int {
+	next := int(float64(size) / tunerStep)
+	if t.growing {
+		next = int(float64(size)*tunerStep + 0.5)
+	}
+	return min(max(next, t.minSize), t.maxSize)
+}
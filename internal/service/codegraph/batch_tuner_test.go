@@ -0,0 +1,40 @@
+package codegraph
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestBatchTuner(t *testing.T) {
+	// Simulated backend: per-batch overhead plus per-item cost that grows past 400 items
+	latency := func(items int) time.Duration {
+		cost := 20*time.Millisecond + time.Duration(items)*50*time.Microsecond
+		if items > 400 {
+			cost += time.Duration(items-400) * time.Millisecond
+		}
+		return cost
+	}
+
+	tuner := newBatchTuner(10, 10, 2000, zap.NewNop())
+	for i := 0; i < 60*tunerWindow; i++ {
+		size := tuner.Size()
+		if size < 10 || size > 2000 {
+			t.Fatalf("size %d out of bounds", size)
+		}
+		tuner.observe(size, latency(size))
+	}
+	if size := tuner.Size(); size < 150 || size > 900 {
+		t.Errorf("size settled at %d, want near the 400 item optimum", size)
+	}
+
+	// Small remainders are ignored
+	before := tuner.Size()
+	for i := 0; i < 10*tunerWindow; i++ {
+		tuner.observe(1, time.Second)
+	}
+	if tuner.Size() != before {
+		t.Errorf("remainder batches moved the size from %d to %d", before, tuner.Size())
+	}
+}
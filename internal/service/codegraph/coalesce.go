@@ -0,0 +1,91 @@
+package codegraph
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"bot-go/internal/model/ast"
+
+	"go.uber.org/zap"
+)
+
+// writeCoalescer collects what is left in per-file buffers when their files finish, so that
+// small files are written in cross-file batches instead of one tiny transaction each. The
+// nodes of every pending file are written before any of their relations, which keeps each
+// file's nodes ahead of its relations.
+type writeCoalescer struct {
+	mu        sync.Mutex // held while writing, so pending files are never half written
+	nodes     []*ast.Node
+	relations []RelationSpec
+	files     map[int32]bool // files with pending data
+}
+
+func newWriteCoalescer() *writeCoalescer {
+	return &writeCoalescer{files: make(map[int32]bool)}
+}
+
+// coalesce adds the remainder of a file's buffer and writes the pending batch once it
+// reaches the batch size
+func (cg *CodeGraph) coalesce(ctx context.Context, fileID int32, buffer *Buffer) error {
+	if len(buffer.Nodes) == 0 && len(buffer.Relations) == 0 {
+		return nil
+	}
+	c := cg.coalescer
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.nodes = append(c.nodes, buffer.Nodes...)
+	c.relations = append(c.relations, buffer.Relations...)
+	c.files[fileID] = true
+	buffer.Nodes, buffer.Relations = nil, nil
+	if len(c.nodes)+len(c.relations) < cg.currentBatchSize() {
+		return nil
+	}
+	return cg.writeCoalescedLocked(ctx)
+}
+
+// flushCoalesced writes every pending file
+func (cg *CodeGraph) flushCoalesced(ctx context.Context) error {
+	if cg.coalescer == nil {
+		return nil
+	}
+	cg.coalescer.mu.Lock()
+	defer cg.coalescer.mu.Unlock()
+	return cg.writeCoalescedLocked(ctx)
+}
+
+// flushCoalescedFile writes the pending batch if it holds data of fileID, so that reads of
+// a finished file see all of it
+func (cg *CodeGraph) flushCoalescedFile(ctx context.Context, fileID int32) error {
+	if cg.coalescer == nil {
+		return nil
+	}
+	cg.coalescer.mu.Lock()
+	defer cg.coalescer.mu.Unlock()
+	if !cg.coalescer.files[fileID] {
+		return nil
+	}
+	return cg.writeCoalescedLocked(ctx)
+}
+
+func (cg *CodeGraph) writeCoalescedLocked(ctx context.Context) error {
+	c := cg.coalescer
+	if len(c.files) == 0 {
+		return nil
+	}
+	nodes, relations, files := c.nodes, c.relations, len(c.files)
+	c.nodes, c.relations, c.files = nil, nil, make(map[int32]bool)
+
+	cg.log(ctx).Debug("Flushing cross-file batch",
+		zap.Int("files", files),
+		zap.Int("nodes", len(nodes)),
+		zap.Int("relations", len(relations)))
+	if err := cg.BatchWriteNodes(ctx, nodes); err != nil {
+		return fmt.Errorf("failed to flush nodes of %d files: %w", files, err)
+	}
+	if err := cg.BatchCreateRelations(ctx, relations); err != nil {
+		return fmt.Errorf("failed to flush relations of %d files: %w", files, err)
+	}
+	return nil
+}
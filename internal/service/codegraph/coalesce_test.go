@@ -0,0 +1,64 @@
+package codegraph
+
+import (
+	"context"
+	"testing"
+
+	"bot-go/internal/model/ast"
+
+	"go.uber.org/zap"
+)
+
+func TestCrossFileBatching(t *testing.T) {
+	ctx := context.Background()
+	db := &fakeDatabase{name: "leader"}
+	cg := &CodeGraph{
+		db:                db,
+		logger:            zap.NewNop(),
+		fileIDCache:       make(map[int32]string),
+		enableBatchWrites: true,
+		batchSize:         10,
+		buffers:           make(map[int32]*Buffer),
+		coalescer:         newWriteCoalescer(),
+	}
+
+	// Two small files: each leaves one node and one relation behind
+	for _, fileID := range []int32{1, 2} {
+		cg.InitializeFileBuffers(fileID)
+		node := &ast.Node{ID: ast.NodeID(fileID * 100), NodeType: ast.NodeTypeFunction, FileID: fileID, Name: "f"}
+		if err := cg.writeNode(ctx, node); err != nil {
+			t.Fatal(err)
+		}
+		if err := cg.CreateContainsRelation(ctx, ast.NodeID(fileID), node.ID, fileID); err != nil {
+			t.Fatal(err)
+		}
+		if err := cg.CleanupFileBuffers(ctx, fileID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(db.writes) != 0 {
+		t.Fatalf("small files were written individually: %d writes", len(db.writes))
+	}
+
+	// Reading a pending file writes the whole batch, nodes first
+	if _, err := cg.FindNodesInFile(ctx, 2, ast.NodeTypeFunction); err != nil {
+		t.Fatal(err)
+	}
+	if len(db.writes) != 2 {
+		t.Fatalf("got %d writes, want one node and one relation batch", len(db.writes))
+	}
+	if nodes, _ := db.writes[0]["nodes"].([]map[string]any); len(nodes) != 2 {
+		t.Errorf("first write = %v, want both files' nodes", db.writes[0])
+	}
+	if relations, _ := db.writes[1]["relations"].([]map[string]any); len(relations) != 2 {
+		t.Errorf("second write = %v, want both files' relations", db.writes[1])
+	}
+
+	// Nothing is left pending
+	if err := cg.Flush(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(db.writes) != 2 {
+		t.Errorf("flush rewrote the batch: %d writes", len(db.writes))
+	}
+}
@@ -1,17 +1,20 @@
 package codegraph
 
 import (
-	"bufio"
 	"context"
 	"fmt"
-	"os"
 	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"bot-go/internal/apperrors"
 	"bot-go/internal/config"
+	"bot-go/internal/faults"
+	"bot-go/internal/logging"
 	"bot-go/internal/model/ast"
+	"bot-go/internal/model/schema"
+	"bot-go/internal/util"
 	"bot-go/pkg/lsp/base"
 
 	"go.uber.org/zap"
@@ -32,20 +35,45 @@ type CodeGraph struct {
 	batchSize         int
 	buffers           map[int32]*Buffer // Map: fileID -> buffer
 	bufferMutex       sync.Mutex        // Protects buffer maps
+	coalescer         *writeCoalescer   // Merges finished files' buffers; nil unless cross_file_batching
+	tuner             *batchTuner       // Adjusts the batch size; nil unless auto_tune_batch_size
 }
 
 func NewCodeGraph(uri, username, password string, config *config.Config, logger *zap.Logger) (*CodeGraph, error) {
-	db, err := NewNeo4jDatabase(uri, username, password, logger)
+	leader, err := NewNeo4jDatabase(uri, username, password, logger)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Neo4j database: %w", err)
 	}
 
-	err = db.VerifyConnectivity(context.Background())
+	err = leader.VerifyConnectivity(context.Background())
 	if err != nil {
-		db.Close(context.Background())
+		leader.Close(context.Background())
 		return nil, fmt.Errorf("failed to verify database connectivity: %w", err)
 	}
 
+	var db GraphDatabase = leader
+	if len(config.Neo4j.ReadURIs) > 0 {
+		replicas := make(map[string]GraphDatabase, len(config.Neo4j.ReadURIs))
+		for _, readURI := range config.Neo4j.ReadURIs {
+			replica, err := NewNeo4jDatabase(readURI, username, password, logger)
+			if err != nil {
+				logger.Warn("Skipping read replica", zap.String("replica", readURI), zap.Error(err))
+				continue
+			}
+			replicas[readURI] = replica
+		}
+		interval := time.Duration(config.Neo4j.HealthCheckSeconds) * time.Second
+		db = NewRoutedDatabase(leader, replicas, interval, logger)
+	}
+	if faults.Enabled {
+		db = &faultDatabase{GraphDatabase: db}
+	}
+	return NewCodeGraphWithDatabase(db, config, logger), nil
+}
+
+// NewCodeGraphWithDatabase creates a CodeGraph on an already connected database, e.g. an
+// in-memory fake in tests
+func NewCodeGraphWithDatabase(db GraphDatabase, config *config.Config, logger *zap.Logger) *CodeGraph {
 	// Initialize batch writing configuration
 	enableBatch := config.CodeGraph.EnableBatchWrites
 	batchSize := config.CodeGraph.BatchSize
@@ -53,7 +81,7 @@ func NewCodeGraph(uri, username, password string, config *config.Config, logger
 		batchSize = 100 // default
 	}
 
-	return &CodeGraph{
+	cg := &CodeGraph{
 		db:                db,
 		config:            config,
 		logger:            logger,
@@ -61,7 +89,40 @@ func NewCodeGraph(uri, username, password string, config *config.Config, logger
 		enableBatchWrites: enableBatch,
 		batchSize:         batchSize,
 		buffers:           make(map[int32]*Buffer),
-	}, nil
+	}
+	if enableBatch && config.CodeGraph.CrossFileBatching {
+		cg.coalescer = newWriteCoalescer()
+	}
+	if enableBatch && config.CodeGraph.AutoTuneBatchSize {
+		minSize, maxSize := config.CodeGraph.MinBatchSize, config.CodeGraph.MaxBatchSize
+		if minSize <= 0 {
+			minSize = 10
+		}
+		if maxSize < minSize {
+			maxSize = max(5000, minSize)
+		}
+		cg.tuner = newBatchTuner(batchSize, minSize, maxSize, logger)
+		logger.Info("Tuning code graph batch size",
+			zap.Int("initial", cg.tuner.Size()),
+			zap.Int("min", minSize),
+			zap.Int("max", maxSize))
+	}
+	return cg
+}
+
+// currentBatchSize returns the number of buffered nodes or relations that triggers a write
+func (cg *CodeGraph) currentBatchSize() int {
+	if cg.tuner != nil {
+		return cg.tuner.Size()
+	}
+	return cg.batchSize
+}
+
+// observeBatch reports a batch write to the tuner
+func (cg *CodeGraph) observeBatch(items int, elapsed time.Duration) {
+	if cg.tuner != nil {
+		cg.tuner.observe(items, elapsed)
+	}
 }
 
 func (cg *CodeGraph) Close(ctx context.Context) error {
@@ -84,8 +145,8 @@ func (cg *CodeGraph) InitializeFileBuffers(fileID int32) {
 
 	// Initialize buffers for this file
 	cg.buffers[fileID] = &Buffer{
-		Nodes:     make([]*ast.Node, 0, cg.batchSize),
-		Relations: make([]RelationSpec, 0, cg.batchSize),
+		Nodes:     make([]*ast.Node, 0, cg.currentBatchSize()),
+		Relations: make([]RelationSpec, 0, cg.currentBatchSize()),
 	}
 }
 
@@ -96,16 +157,25 @@ func (cg *CodeGraph) CleanupFileBuffers(ctx context.Context, fileID int32) error
 		return nil
 	}
 
-	// Flush any remaining data for this file
-	if err := cg.Flush(ctx, &fileID); err != nil {
+	// Flush any remaining data for this file, or hand it to the cross-file batch
+	if cg.coalescer != nil {
+		cg.bufferMutex.Lock()
+		buffer := cg.buffers[fileID]
+		cg.bufferMutex.Unlock()
+		if buffer != nil {
+			if err := cg.coalesce(ctx, fileID, buffer); err != nil {
+				return err
+			}
+		}
+	} else if err := cg.Flush(ctx, &fileID); err != nil {
 		return err
 	}
 
 	// Remove buffers to free memory
 	cg.bufferMutex.Lock()
-	//cg.logger.Debug("Acquired bufferMutex lock in CleanupFileBuffers", zap.Int32("fileID", fileID))
+	//cg.log(ctx).Debug("Acquired bufferMutex lock in CleanupFileBuffers", zap.Int32("fileID", fileID))
 	defer func() {
-		//cg.logger.Debug("Releasing bufferMutex lock in CleanupFileBuffers", zap.Int32("fileID", fileID))
+		//cg.log(ctx).Debug("Releasing bufferMutex lock in CleanupFileBuffers", zap.Int32("fileID", fileID))
 		cg.bufferMutex.Unlock()
 	}()
 
@@ -134,16 +204,16 @@ func (cg *CodeGraph) FlushNodes(ctx context.Context, fileID *int32) error {
 		nodes := make([]*ast.Node, len(buffers.Nodes))
 		copy(nodes, buffers.Nodes)
 
-		buffers.Nodes = make([]*ast.Node, 0, cg.batchSize)
+		buffers.Nodes = make([]*ast.Node, 0, cg.currentBatchSize())
 
 		if len(nodes) == 0 {
-			cg.logger.Debug("Flushing node buffer for file",
+			cg.log(ctx).Debug("Flushing node buffer for file",
 				zap.Int32("file_id", *fileID),
 				zap.Int("count", 0))
 			return nil
 		}
 
-		cg.logger.Debug("Flushing node buffer for file",
+		cg.log(ctx).Debug("Flushing node buffer for file",
 			zap.Int32("file_id", *fileID),
 			zap.Int("count", len(nodes)))
 
@@ -153,9 +223,9 @@ func (cg *CodeGraph) FlushNodes(ctx context.Context, fileID *int32) error {
 		}
 	} else {
 		cg.bufferMutex.Lock()
-		//cg.logger.Debug("Acquired bufferMutex lock in FlushNodes (flush all)")
+		//cg.log(ctx).Debug("Acquired bufferMutex lock in FlushNodes (flush all)")
 		defer func() {
-			//cg.logger.Debug("Releasing bufferMutex lock in FlushNodes (flush all)")
+			//cg.log(ctx).Debug("Releasing bufferMutex lock in FlushNodes (flush all)")
 			cg.bufferMutex.Unlock()
 		}()
 
@@ -169,7 +239,7 @@ func (cg *CodeGraph) FlushNodes(ctx context.Context, fileID *int32) error {
 			return nil
 		}
 
-		cg.logger.Debug("Flushing all node buffers", zap.Int("count", totalCount))
+		cg.log(ctx).Debug("Flushing all node buffers", zap.Int("count", totalCount))
 
 		// Collect all nodes from all files
 		allNodes := make([]*ast.Node, 0, totalCount)
@@ -208,16 +278,16 @@ func (cg *CodeGraph) FlushRelations(ctx context.Context, fileID *int32) error {
 		relations := make([]RelationSpec, len(buffers.Relations))
 		copy(relations, buffers.Relations)
 
-		buffers.Relations = make([]RelationSpec, 0, cg.batchSize)
+		buffers.Relations = make([]RelationSpec, 0, cg.currentBatchSize())
 
 		if len(relations) == 0 {
-			cg.logger.Debug("Flushing relation buffer for file",
+			cg.log(ctx).Debug("Flushing relation buffer for file",
 				zap.Int32("file_id", *fileID),
 				zap.Int("count", 0))
 			return nil
 		}
 
-		cg.logger.Debug("Flushing relation buffer for file",
+		cg.log(ctx).Debug("Flushing relation buffer for file",
 			zap.Int32("file_id", *fileID),
 			zap.Int("count", len(relations)))
 
@@ -227,9 +297,9 @@ func (cg *CodeGraph) FlushRelations(ctx context.Context, fileID *int32) error {
 		}
 	} else {
 		cg.bufferMutex.Lock()
-		//cg.logger.Debug("Acquired bufferMutex lock in FlushRelations (flush all)")
+		//cg.log(ctx).Debug("Acquired bufferMutex lock in FlushRelations (flush all)")
 		defer func() {
-			//cg.logger.Debug("Releasing bufferMutex lock in FlushRelations (flush all)")
+			//cg.log(ctx).Debug("Releasing bufferMutex lock in FlushRelations (flush all)")
 			cg.bufferMutex.Unlock()
 		}()
 
@@ -243,7 +313,7 @@ func (cg *CodeGraph) FlushRelations(ctx context.Context, fileID *int32) error {
 			return nil
 		}
 
-		cg.logger.Debug("Flushing all relation buffers", zap.Int("count", totalCount))
+		cg.log(ctx).Debug("Flushing all relation buffers", zap.Int("count", totalCount))
 
 		// Collect all relations from all files
 		allRelations := make([]RelationSpec, 0, totalCount)
@@ -272,6 +342,13 @@ func (cg *CodeGraph) Flush(ctx context.Context, fileID *int32) error {
 		return nil // No-op if batch writes not enabled
 	}
 
+	// Finished files in the cross-file batch go first
+	if fileID == nil {
+		if err := cg.flushCoalesced(ctx); err != nil {
+			return err
+		}
+	}
+
 	// Flush nodes first (required for relations to reference them)
 	if err := cg.FlushNodes(ctx, fileID); err != nil {
 		return err
@@ -285,30 +362,32 @@ func (cg *CodeGraph) Flush(ctx context.Context, fileID *int32) error {
 	return nil
 }
 
-func (cg *CodeGraph) dbRecordToNode(record GraphNode) (*ast.Node, error) {
+func (cg *CodeGraph) dbRecordToNode(ctx context.Context, record GraphNode) (*ast.Node, error) {
 	recordMap := make(map[string]any)
 	for key, value := range record.GetProperties() {
 		recordMap[key] = value
 	}
 
-	return cg.recordToNode(recordMap)
+	return cg.recordToNode(ctx, recordMap)
 }
 
-func (cg *CodeGraph) recordToNode(record map[string]any) (*ast.Node, error) {
+// recordToNode converts node properties read from the database into a node. Metadata keys
+// outside the projection carried by ctx (see WithMetadataFields) are dropped.
+func (cg *CodeGraph) recordToNode(ctx context.Context, record map[string]any) (*ast.Node, error) {
 	id := record["id"]
 	nodeType := record["nodeType"]
 	fileID := record["fileId"]
 	name := record["name"]
-	rangeStr := record["range"]
 	version := record["version"]
 	scopeID := record["scopeId"]
 
+	fields := metadataFieldsFrom(ctx)
 	newMetadata := make(map[string]any)
 	for key, value := range record {
 		if cg.isFirstClassMetadata(key) {
 			newMetadata[key] = value
-		} else if strings.HasPrefix(key, "md_") {
-			newMetadata[key[3:]] = value
+		} else if strings.HasPrefix(key, metadataPrefix) && fields.Keeps(key[len(metadataPrefix):]) {
+			newMetadata[key[len(metadataPrefix):]] = value
 		}
 	}
 
@@ -321,8 +400,9 @@ func (cg *CodeGraph) recordToNode(record map[string]any) (*ast.Node, error) {
 		ScopeID:  ast.NodeID(cg.convertToInt64(scopeID)),
 	}
 
-	if rangeStr != nil {
-		node.Range = strToRange(rangeStr.(string))
+	node.Range = RangeFromProperties(record)
+	if key, ok := record[PropNodeKey].(string); ok {
+		node.Key = key
 	}
 
 	if len(newMetadata) > 0 {
@@ -359,36 +439,7 @@ func parseRange(rangeMap map[string]any) base.Range {
 */
 
 func (cg *CodeGraph) getNodeLabel(nodeType ast.NodeType) string {
-	switch nodeType {
-	case ast.NodeTypeModuleScope:
-		return "ModuleScope"
-	case ast.NodeTypeFileScope:
-		return "FileScope"
-	case ast.NodeTypeBlock:
-		return "Block"
-	case ast.NodeTypeVariable:
-		return "Variable"
-	case ast.NodeTypeExpression:
-		return "Expression"
-	case ast.NodeTypeConditional:
-		return "Conditional"
-	case ast.NodeTypeFunction:
-		return "Function"
-	case ast.NodeTypeClass:
-		return "Class"
-	case ast.NodeTypeField:
-		return "Field"
-	case ast.NodeTypeFunctionCall:
-		return "FunctionCall"
-	case ast.NodeTypeFileNumber:
-		return "FileNumber"
-	case ast.NodeTypeLoop:
-		return "Loop"
-	case ast.NodeTypeImport:
-		return "Import"
-	default:
-		return "Node"
-	}
+	return string(schema.LabelOf(nodeType))
 }
 
 func (cg *CodeGraph) CreateFunction(ctx context.Context, node *ast.Node) error {
@@ -430,7 +481,7 @@ func (cg *CodeGraph) ReadFunctionArgs(ctx context.Context, functionNodeID ast.No
 		var nodes []*ast.Node
 		for result.Next(ctx) {
 			record := result.Record()
-			node, err := cg.recordToNode(record)
+			node, err := cg.recordToNode(ctx, record)
 			if err != nil {
 				return nil, err
 			}
@@ -445,7 +496,7 @@ func (cg *CodeGraph) ReadFunctionArgs(ctx context.Context, functionNodeID ast.No
 	})
 
 	if err != nil {
-		cg.logger.Error("Failed to read function arguments", zap.Int64("functionId", int64(functionNodeID)), zap.Error(err))
+		cg.log(ctx).Error("Failed to read function arguments", zap.Int64("functionId", int64(functionNodeID)), zap.Error(err))
 		return nil, fmt.Errorf("failed to read function arguments: %w", err)
 	}
 
@@ -487,7 +538,7 @@ func (cg *CodeGraph) FindFileScopes(ctx context.Context, repoName, filePath stri
 	}
 
 	if filePath != "" {
-		params["path"] = filePath
+		params["path"] = util.CanonicalPath(filePath)
 	}
 	nodes, err := cg.readNodes(ctx, ast.NodeTypeFileScope, params)
 	if err != nil {
@@ -580,6 +631,43 @@ func (cg *CodeGraph) ReadImport(ctx context.Context, nodeID ast.NodeID) (*ast.No
 	return cg.readNodeByType(ctx, nodeID, ast.NodeTypeImport)
 }
 
+func (cg *CodeGraph) CreateAnnotation(ctx context.Context, node *ast.Node) error {
+	if node.NodeType != ast.NodeTypeAnnotation {
+		return fmt.Errorf("invalid node type: expected %d, got %d", ast.NodeTypeAnnotation, node.NodeType)
+	}
+	return cg.writeNode(ctx, node)
+}
+
+func (cg *CodeGraph) CreateConstant(ctx context.Context, node *ast.Node) error {
+	if node.NodeType != ast.NodeTypeConstant {
+		return fmt.Errorf("invalid node type: expected %d, got %d", ast.NodeTypeConstant, node.NodeType)
+	}
+	return cg.writeNode(ctx, node)
+}
+
+func (cg *CodeGraph) CreateEnum(ctx context.Context, node *ast.Node) error {
+	if node.NodeType != ast.NodeTypeEnum {
+		return fmt.Errorf("invalid node type: expected %d, got %d", ast.NodeTypeEnum, node.NodeType)
+	}
+	return cg.writeNode(ctx, node)
+}
+
+// FindAnnotatedNodes returns the nodes of a repository carrying a decorator or annotation
+// with the given name, such as "Transactional" or "app.route", in ID order
+func (cg *CodeGraph) FindAnnotatedNodes(ctx context.Context, repoName, annotation string) ([]*ast.Node, error) {
+	query := `
+		MATCH (fs:FileScope {repo: $repo})
+		MATCH (n)-[:ANNOTATION]->(a:Annotation {name: $name})
+		WHERE n.fileId = fs.id
+		RETURN DISTINCT n
+		ORDER BY n.id
+	`
+	return cg.readNodesByQuery(ctx, "n", query, map[string]any{
+		"repo": repoName,
+		"name": annotation,
+	})
+}
+
 func (cg *CodeGraph) CreateFunctionCall(ctx context.Context, node *ast.Node) error {
 	if node.NodeType != ast.NodeTypeFunctionCall {
 		return fmt.Errorf("invalid node type: expected %d, got %d", ast.NodeTypeFunctionCall, node.NodeType)
@@ -602,10 +690,63 @@ func (cg *CodeGraph) ReadModuleScope(ctx context.Context, nodeID ast.NodeID) (*a
 	return cg.readNodeByType(ctx, nodeID, ast.NodeTypeModuleScope)
 }
 
+// rangeToString formats a range for dumps and logs
 func rangeToString(rng base.Range) string {
 	return fmt.Sprintf("(%d,%d)-(%d,%d)", rng.Start.Line, rng.Start.Character, rng.End.Line, rng.End.Character)
 }
 
+// Node properties holding a node's range. Graphs built before these existed store a
+// single "range" string instead, which RangeFromProperties still reads.
+const (
+	PropStartLine   = "startLine"
+	PropStartChar   = "startChar"
+	PropEndLine     = "endLine"
+	PropEndChar     = "endChar"
+	legacyRangeProp = "range"
+)
+
+// setRangeProperties adds the numeric range properties of rng to a node's parameters
+func setRangeProperties(params map[string]any, rng base.Range) {
+	params[PropStartLine] = int64(rng.Start.Line)
+	params[PropStartChar] = int64(rng.Start.Character)
+	params[PropEndLine] = int64(rng.End.Line)
+	params[PropEndChar] = int64(rng.End.Character)
+}
+
+// RangeFromProperties reads a node's range from its properties, falling back to the
+// legacy "range" string for nodes written before ranges were stored as numbers
+func RangeFromProperties(props map[string]any) base.Range {
+	if startLine, ok := rangeInt(props[PropStartLine]); ok {
+		var rng base.Range
+		rng.Start.Line = startLine
+		rng.Start.Character, _ = rangeInt(props[PropStartChar])
+		rng.End.Line, _ = rangeInt(props[PropEndLine])
+		rng.End.Character, _ = rangeInt(props[PropEndChar])
+		return rng
+	}
+	if s, ok := props[legacyRangeProp].(string); ok {
+		return strToRange(s)
+	}
+	return base.Range{}
+}
+
+// RangeFromValue decodes a range returned by a query, either a map of range properties
+// (e.g. "n {.startLine, .startChar, .endLine, .endChar, .range}") or a legacy range string
+func RangeFromValue(v any) base.Range {
+	switch val := v.(type) {
+	case map[string]any:
+		return RangeFromProperties(val)
+	case string:
+		return strToRange(val)
+	}
+	return base.Range{}
+}
+
+// StringToRange parses a legacy range string back into a base.Range
+func StringToRange(s string) base.Range {
+	return strToRange(s)
+}
+
 func strToRange(s string) base.Range {
 	var rng base.Range
 	_, err := fmt.Sscanf(s, "(%d,%d)-(%d,%d)", &rng.Start.Line, &rng.Start.Character, &rng.End.Line, &rng.End.Character)
@@ -615,6 +756,20 @@ func strToRange(s string) base.Range {
 	return rng
 }
 
+func rangeInt(v any) (int, bool) {
+	switch n := v.(type) {
+	case int64:
+		return int(n), true
+	case int:
+		return n, true
+	case int32:
+		return int(n), true
+	case float64:
+		return int(n), true
+	}
+	return 0, false
+}
+
 var (
 	FirstClassMetadata = map[string]bool{
 		"fake":     true,
@@ -623,6 +778,7 @@ var (
 		"repo":     true,
 		"path":     true,
 		"language": true,
+		"commit":   true,
 	}
 )
 
@@ -632,14 +788,17 @@ func (cg *CodeGraph) isFirstClassMetadata(key string) bool {
 
 func (cg *CodeGraph) populateFirstClassMetadata(metadata map[string]any,
 	param map[string]any,
-	newMetadata map[string]any) {
+	newMetadata map[string]any) error {
 	for key, value := range metadata {
-		if cg.isFirstClassMetadata(key) {
-			param[key] = value
-		} else {
+		if !cg.isFirstClassMetadata(key) {
 			newMetadata[key] = value
+			continue
+		}
+		if err := setProperty(param, key, key, value); err != nil {
+			return err
 		}
 	}
+	return nil
 }
 
 func (cg *CodeGraph) mapToSetParamString(m map[string]any, varName string) string {
@@ -657,10 +816,20 @@ func (cg *CodeGraph) mapToSetParamString(m map[string]any, varName string) strin
 	return setClauses
 }
 
-func (cg *CodeGraph) flattenMetadata(metadata map[string]any, param map[string]any) {
+// flattenMetadata stores each metadata entry as an md_-prefixed property. Keys are sanitized
+// into Cypher identifiers and values converted to storable types; a key that collides with
+// another property after sanitizing is an error rather than a silent overwrite.
+func (cg *CodeGraph) flattenMetadata(metadata map[string]any, param map[string]any) error {
 	for key, value := range metadata {
-		param["md_"+key] = value
+		property, err := sanitizePropertyKey(key)
+		if err != nil {
+			return err
+		}
+		if err := setProperty(param, metadataPrefix+property, key, value); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
 func (cg *CodeGraph) writeNodeReal(ctx context.Context, node *ast.Node) error {
@@ -671,32 +840,39 @@ func (cg *CodeGraph) writeNodeReal(ctx context.Context, node *ast.Node) error {
 		"nodeType": int64(node.NodeType),
 		"fileId":   int64(node.FileID),
 		"name":     node.Name,
-		"range":    rangeToString(node.Range),
 		"version":  int64(node.Version),
 		"scopeId":  int64(node.ScopeID),
 	}
+	setRangeProperties(parameters, node.Range)
+	if node.Key != "" {
+		parameters[PropNodeKey] = node.Key
+	}
 
 	if node.MetaData != nil {
 		newMetadata := make(map[string]any)
-		cg.populateFirstClassMetadata(node.MetaData, parameters, newMetadata)
+		if err := cg.populateFirstClassMetadata(node.MetaData, parameters, newMetadata); err != nil {
+			return fmt.Errorf("node %d: %w", node.ID, err)
+		}
 		if len(newMetadata) > 0 {
-			cg.flattenMetadata(newMetadata, parameters)
+			if err := cg.flattenMetadata(newMetadata, parameters); err != nil {
+				return fmt.Errorf("node %d: %w", node.ID, err)
+			}
 			//parameters["metaData"] = newMetadata
 		}
 	}
 
-	// cg.logger.Debug("Writing node", zap.Int64("nodeId", int64(node.ID)), zap.Any("parameters", parameters))
+	// cg.log(ctx).Debug("Writing node", zap.Int64("nodeId", int64(node.ID)), zap.Any("parameters", parameters))
 
 	setQ := cg.mapToSetParamString(parameters, "n")
 	query := fmt.Sprintf(`
-		MERGE (n:%s {id: $id})
+		%s
 		SET %s
 		RETURN n
-	`, nodeLabel, setQ)
+	`, nodeMergeClause(nodeLabel, node.Key != "", "$"), setQ)
 
 	_, err := cg.db.ExecuteWrite(ctx, query, parameters)
 	if err != nil {
-		cg.logger.Error("Failed to write node", zap.Int64("nodeId", int64(node.ID)), zap.Error(err))
+		cg.log(ctx).Error("Failed to write node", zap.Int64("nodeId", int64(node.ID)), zap.Error(err))
 		return fmt.Errorf("failed to write node: %w", err)
 	}
 
@@ -716,7 +892,7 @@ func (cg *CodeGraph) writeNode(ctx context.Context, node *ast.Node) error {
 		if buffers != nil {
 			// These operations are safe without lock since each file is processed by a single thread
 			buffers.Nodes = append(buffers.Nodes, node)
-			shouldFlush := len(buffers.Nodes) >= cg.batchSize
+			shouldFlush := len(buffers.Nodes) >= cg.currentBatchSize()
 
 			// Flush if this file's buffer is full
 			if shouldFlush {
@@ -741,13 +917,18 @@ func (cg *CodeGraph) BatchWriteNodes(ctx context.Context, nodes []*ast.Node) err
 		return nil
 	}
 
-	cg.logger.Debug("Batch writing nodes", zap.Int("count", len(nodes)))
+	cg.log(ctx).Debug("Batch writing nodes", zap.Int("count", len(nodes)))
+	start := time.Now()
 
-	// Group nodes by label for efficient batch operations
-	nodesByLabel := make(map[string][]map[string]any)
-	astNodesByLabel := make(map[string][]*ast.Node)
+	// Group nodes by label (and by whether they merge on a key) for efficient batch operations
+	type nodeGroup struct {
+		label string
+		keyed bool
+	}
+	nodesByLabel := make(map[nodeGroup][]map[string]any)
+	astNodesByLabel := make(map[nodeGroup][]*ast.Node)
 	for _, node := range nodes {
-		label := cg.getNodeLabel(node.NodeType)
+		label := nodeGroup{cg.getNodeLabel(node.NodeType), node.Key != ""}
 		astNodesByLabel[label] = append(astNodesByLabel[label], node)
 
 		// Convert node to parameters
@@ -756,16 +937,23 @@ func (cg *CodeGraph) BatchWriteNodes(ctx context.Context, nodes []*ast.Node) err
 			"nodeType": int64(node.NodeType),
 			"fileId":   int64(node.FileID),
 			"name":     node.Name,
-			"range":    rangeToString(node.Range),
 			"version":  int64(node.Version),
 			"scopeId":  int64(node.ScopeID),
 		}
+		setRangeProperties(parameters, node.Range)
+		if node.Key != "" {
+			parameters[PropNodeKey] = node.Key
+		}
 
 		if node.MetaData != nil {
 			newMetadata := make(map[string]any)
-			cg.populateFirstClassMetadata(node.MetaData, parameters, newMetadata)
+			if err := cg.populateFirstClassMetadata(node.MetaData, parameters, newMetadata); err != nil {
+				return fmt.Errorf("node %d: %w", node.ID, err)
+			}
 			if len(newMetadata) > 0 {
-				cg.flattenMetadata(newMetadata, parameters)
+				if err := cg.flattenMetadata(newMetadata, parameters); err != nil {
+					return fmt.Errorf("node %d: %w", node.ID, err)
+				}
 			}
 		}
 
@@ -773,7 +961,8 @@ func (cg *CodeGraph) BatchWriteNodes(ctx context.Context, nodes []*ast.Node) err
 	}
 
 	// Write each label group in batch
-	for label, nodeParams := range nodesByLabel {
+	for group, nodeParams := range nodesByLabel {
+		label := group.label
 		// Build dynamic SET clause from first node's properties
 		if len(nodeParams) == 0 {
 			continue
@@ -781,7 +970,7 @@ func (cg *CodeGraph) BatchWriteNodes(ctx context.Context, nodes []*ast.Node) err
 
 		// if len(nodeParams) == 1, use regular writeNode instead
 		if len(nodeParams) == 1 {
-			err := cg.writeNodeReal(ctx, astNodesByLabel[label][0])
+			err := cg.writeNodeReal(ctx, astNodesByLabel[group][0])
 			if err != nil {
 				return fmt.Errorf("failed to write single node for label %s: %w", label, err)
 			}
@@ -800,25 +989,26 @@ func (cg *CodeGraph) BatchWriteNodes(ctx context.Context, nodes []*ast.Node) err
 
 		query := fmt.Sprintf(`
 			UNWIND $nodes AS nodeData
-			MERGE (n:%s {id: nodeData.id})
+			%s
 			SET %s
 			RETURN count(n) as created
-		`, label, setClause)
+		`, nodeMergeClause(label, group.keyed, "nodeData."), setClause)
 
 		_, err := cg.db.ExecuteWrite(ctx, query, map[string]any{"nodes": nodeParams})
 		if err != nil {
-			cg.logger.Error("Failed to batch write nodes",
+			cg.log(ctx).Error("Failed to batch write nodes",
 				zap.String("label", label),
 				zap.Int("count", len(nodeParams)),
 				zap.Error(err))
 			return fmt.Errorf("failed to batch write nodes for label %s: %w", label, err)
 		}
 
-		cg.logger.Debug("Batch wrote nodes",
+		cg.log(ctx).Debug("Batch wrote nodes",
 			zap.String("label", label),
 			zap.Int("count", len(nodeParams)))
 	}
 
+	cg.observeBatch(len(nodes), time.Since(start))
 	return nil
 }
 
@@ -838,7 +1028,8 @@ func (cg *CodeGraph) BatchCreateRelations(ctx context.Context, relations []Relat
 		return nil
 	}
 
-	cg.logger.Debug("Batch creating relations", zap.Int("count", len(relations)))
+	cg.log(ctx).Debug("Batch creating relations", zap.Int("count", len(relations)))
+	start := time.Now()
 
 	// Group relations by label for efficient processing
 	relationsByLabel := make(map[string][]map[string]any)
@@ -851,7 +1042,9 @@ func (cg *CodeGraph) BatchCreateRelations(ctx context.Context, relations []Relat
 		// Add metadata if present
 		if rel.Metadata != nil {
 			newMetadata := make(map[string]any)
-			cg.flattenMetadata(rel.Metadata, newMetadata)
+			if err := cg.flattenMetadata(rel.Metadata, newMetadata); err != nil {
+				return fmt.Errorf("%s relation %d->%d: %w", rel.Label, rel.ParentID, rel.ChildID, err)
+			}
 			for key, value := range newMetadata {
 				relData[key] = value
 			}
@@ -891,25 +1084,26 @@ func (cg *CodeGraph) BatchCreateRelations(ctx context.Context, relations []Relat
 
 		_, err := cg.db.ExecuteWrite(ctx, query, map[string]any{"relations": relParams})
 		if err != nil {
-			cg.logger.Error("Failed to batch create relations",
+			cg.log(ctx).Error("Failed to batch create relations",
 				zap.String("label", label),
 				zap.Int("count", len(relParams)),
 				zap.Error(err))
 			return fmt.Errorf("failed to batch create relations for label %s: %w", label, err)
 		}
 
-		cg.logger.Debug("Batch created relations",
+		cg.log(ctx).Debug("Batch created relations",
 			zap.String("label", label),
 			zap.Int("count", len(relParams)))
 	}
 
+	cg.observeBatch(len(relations), time.Since(start))
 	return nil
 }
 
 func (cg *CodeGraph) readNodesByQuery(ctx context.Context, nodeVarName string, query string, params map[string]any) ([]*ast.Node, error) {
 	records, err := cg.db.ExecuteRead(ctx, query, params)
 	if err != nil {
-		cg.logger.Error("Failed to read nodes",
+		cg.log(ctx).Error("Failed to read nodes",
 			zap.String("Raw Query", query),
 			zap.Any("Parameters", params),
 			zap.Error(err))
@@ -933,7 +1127,7 @@ func (cg *CodeGraph) readNodesByQuery(ctx context.Context, nodeVarName string, q
 			continue
 		}
 
-		node, err := cg.recordToNode(nodeMap)
+		node, err := cg.recordToNode(ctx, nodeMap)
 		if err != nil {
 			return nil, err
 		}
@@ -970,7 +1164,7 @@ func (cg *CodeGraph) readNodes(ctx context.Context, nodeType ast.NodeType, query
 	/*
 		records, err := cg.db.ExecuteRead(ctx, fullQuery, query)
 		if err != nil {
-			cg.logger.Error("Failed to read node",
+			cg.log(ctx).Error("Failed to read node",
 				zap.Int64("nodeType", int64(nodeType)),
 				zap.Error(err))
 			return nil, fmt.Errorf("failed to read node: %w", err)
@@ -993,7 +1187,7 @@ func (cg *CodeGraph) readNodes(ctx context.Context, nodeType ast.NodeType, query
 				continue
 			}
 
-			node, err := cg.recordToNode(nodeMap)
+			node, err := cg.recordToNode(ctx, nodeMap)
 			if err != nil {
 				return nil, err
 			}
@@ -1035,7 +1229,9 @@ func (cg *CodeGraph) CreateRelationReal(ctx context.Context, parentNodeID, child
 		//parameters["metaData"] = metaData
 		//setMetaDataQ = "SET r.metaData = $metaData"
 		newMetadata := make(map[string]any)
-		cg.flattenMetadata(metaData, newMetadata)
+		if err := cg.flattenMetadata(metaData, newMetadata); err != nil {
+			return fmt.Errorf("%s relation %d->%d: %w", relationLabel, parentNodeID, childNodeID, err)
+		}
 		setMetaDataQ = cg.mapToSetParamString(newMetadata, "r")
 		if setMetaDataQ != "" {
 			setMetaDataQ = "SET " + setMetaDataQ
@@ -1055,7 +1251,7 @@ func (cg *CodeGraph) CreateRelationReal(ctx context.Context, parentNodeID, child
 
 	_, err := cg.db.ExecuteWrite(ctx, query, parameters)
 	if err != nil {
-		cg.logger.Error("Failed to create relation",
+		cg.log(ctx).Error("Failed to create relation",
 			zap.Int64("parentId", int64(parentNodeID)),
 			zap.Int64("childId", int64(childNodeID)),
 			zap.String("relationLabel", relationLabel),
@@ -1068,6 +1264,9 @@ func (cg *CodeGraph) CreateRelationReal(ctx context.Context, parentNodeID, child
 
 func (cg *CodeGraph) CreateRelation(ctx context.Context, parentNodeID, childNodeID ast.NodeID,
 	relationLabel string, metaData map[string]any, fileID int32) error {
+	if err := schema.ValidateRelation(schema.RelationType(relationLabel)); err != nil {
+		return fmt.Errorf("%w: %v", apperrors.ErrInvalidArgument, err)
+	}
 
 	// If batch writes are enabled, buffer the relation instead of writing immediately
 	if cg.enableBatchWrites {
@@ -1086,7 +1285,7 @@ func (cg *CodeGraph) CreateRelation(ctx context.Context, parentNodeID, childNode
 				FileID:   fileID,
 			}
 			buffers.Relations = append(buffers.Relations, relSpec)
-			shouldFlush := len(buffers.Relations) >= cg.batchSize
+			shouldFlush := len(buffers.Relations) >= cg.currentBatchSize()
 
 			// Flush if this file's buffer is full
 			if shouldFlush {
@@ -1106,14 +1305,14 @@ func (cg *CodeGraph) CreateRelation(ctx context.Context, parentNodeID, childNode
 }
 
 func (cg *CodeGraph) CreateContainsRelation(ctx context.Context, parentNodeID, childNodeID ast.NodeID, fileID int32) error {
-	return cg.CreateRelation(ctx, parentNodeID, childNodeID, "CONTAINS", nil, fileID)
+	return cg.CreateRelation(ctx, parentNodeID, childNodeID, string(schema.RelContains), nil, fileID)
 }
 
 func (cg *CodeGraph) CreateHasFieldRelation(ctx context.Context, parentNodeID, childNodeID ast.NodeID, fileID int32) error {
-	return cg.CreateRelation(ctx, parentNodeID, childNodeID, "HAS_FIELD", nil, fileID)
+	return cg.CreateRelation(ctx, parentNodeID, childNodeID, string(schema.RelHasField), nil, fileID)
 }
 func (cg *CodeGraph) CreateCallsRelation(ctx context.Context, callerNodeID, calleeNodeID ast.NodeID, fileID int32) error {
-	return cg.CreateRelation(ctx, callerNodeID, calleeNodeID, "CALLS", nil, fileID)
+	return cg.CreateRelation(ctx, callerNodeID, calleeNodeID, string(schema.RelCalls), nil, fileID)
 }
 
 /*
@@ -1123,11 +1322,11 @@ func (cg *CodeGraph) CreateContainedByRelation(ctx context.Context, parentNodeID
 */
 
 func (cg *CodeGraph) CreateInheritsRelation(ctx context.Context, parentNodeID, childNodeID ast.NodeID, fileID int32) error {
-	return cg.CreateRelation(ctx, parentNodeID, childNodeID, "INHERITS", nil, fileID)
+	return cg.CreateRelation(ctx, parentNodeID, childNodeID, string(schema.RelInherits), nil, fileID)
 }
 
 func (cg *CodeGraph) CreateCallsFunctionRelation(ctx context.Context, callerNodeID, calleeNodeID ast.NodeID, fileID int32) error {
-	return cg.CreateRelation(ctx, callerNodeID, calleeNodeID, "CALLS_FUNCTION", nil, fileID)
+	return cg.CreateRelation(ctx, callerNodeID, calleeNodeID, string(schema.RelCallsFunction), nil, fileID)
 }
 
 // GetNodesByName returns all nodes with a given name and type
@@ -1159,7 +1358,7 @@ func (cg *CodeGraph) GetNodeByID(ctx context.Context, nodeID ast.NodeID) (*ast.N
 		}
 	}
 
-	return nil, fmt.Errorf("node with id %d not found", nodeID)
+	return nil, apperrors.NodeNotFound("node", nodeID)
 }
 
 // RelationInfo represents a relationship between nodes
@@ -1167,6 +1366,7 @@ type RelationInfo struct {
 	FromNodeID ast.NodeID
 	ToNodeID   ast.NodeID
 	Label      string
+	MetaData   map[string]any // relation properties, e.g. position on FUNCTION_ARG
 }
 
 // GetChildNodes returns all child nodes of a given parent via a relationship
@@ -1195,7 +1395,7 @@ func (cg *CodeGraph) GetChildNodes(ctx context.Context, parentID ast.NodeID, rel
 			continue
 		}
 
-		node, err := cg.recordToNode(childMap)
+		node, err := cg.recordToNode(ctx, childMap)
 		if err != nil {
 			continue
 		}
@@ -1208,12 +1408,26 @@ func (cg *CodeGraph) GetChildNodes(ctx context.Context, parentID ast.NodeID, rel
 
 // GetOutgoingRelations returns all outgoing relationships from a node
 func (cg *CodeGraph) GetOutgoingRelations(ctx context.Context, fromNodeID ast.NodeID, relationLabel string) ([]RelationInfo, error) {
+	return cg.GetOutgoingRelationsWhere(ctx, fromNodeID, relationLabel, nil)
+}
+
+// GetOutgoingRelationsWhere returns the outgoing relationships from a node whose metadata
+// equals every value in filter (e.g. {"position": 0}). Results are ordered by the
+// "position" metadata when present, then by target ID.
+func (cg *CodeGraph) GetOutgoingRelationsWhere(ctx context.Context, fromNodeID ast.NodeID, relationLabel string, filter map[string]any) ([]RelationInfo, error) {
+	params := map[string]any{"fromId": int64(fromNodeID)}
+	where, err := relationFilterClause(filter, params)
+	if err != nil {
+		return nil, err
+	}
 	query := fmt.Sprintf(`
 		MATCH (from {id: $fromId})-[r:%s]->(to)
-		RETURN to.id as toId
-	`, relationLabel)
+		%s
+		RETURN to.id as toId, properties(r) as props
+		ORDER BY r.md_position, to.id
+	`, relationLabel, where)
 
-	records, err := cg.db.ExecuteRead(ctx, query, map[string]any{"fromId": int64(fromNodeID)})
+	records, err := cg.db.ExecuteRead(ctx, query, params)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get outgoing relations: %w", err)
 	}
@@ -1230,6 +1444,7 @@ func (cg *CodeGraph) GetOutgoingRelations(ctx context.Context, fromNodeID ast.No
 			FromNodeID: fromNodeID,
 			ToNodeID:   ast.NodeID(toNodeID),
 			Label:      relationLabel,
+			MetaData:   relationMetadata(record["props"]),
 		})
 	}
 
@@ -1238,12 +1453,26 @@ func (cg *CodeGraph) GetOutgoingRelations(ctx context.Context, fromNodeID ast.No
 
 // GetIncomingRelations returns all incoming relationships to a node
 func (cg *CodeGraph) GetIncomingRelations(ctx context.Context, toNodeID ast.NodeID, relationLabel string) ([]RelationInfo, error) {
+	return cg.GetIncomingRelationsWhere(ctx, toNodeID, relationLabel, nil)
+}
+
+// GetIncomingRelationsWhere returns the incoming relationships to a node whose metadata
+// equals every value in filter. Results are ordered by the "position" metadata when
+// present, then by source ID.
+func (cg *CodeGraph) GetIncomingRelationsWhere(ctx context.Context, toNodeID ast.NodeID, relationLabel string, filter map[string]any) ([]RelationInfo, error) {
+	params := map[string]any{"toId": int64(toNodeID)}
+	where, err := relationFilterClause(filter, params)
+	if err != nil {
+		return nil, err
+	}
 	query := fmt.Sprintf(`
 		MATCH (from)-[r:%s]->(to {id: $toId})
-		RETURN from.id as fromId
-	`, relationLabel)
+		%s
+		RETURN from.id as fromId, properties(r) as props
+		ORDER BY r.md_position, from.id
+	`, relationLabel, where)
 
-	records, err := cg.db.ExecuteRead(ctx, query, map[string]any{"toId": int64(toNodeID)})
+	records, err := cg.db.ExecuteRead(ctx, query, params)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get incoming relations: %w", err)
 	}
@@ -1260,61 +1489,116 @@ func (cg *CodeGraph) GetIncomingRelations(ctx context.Context, toNodeID ast.Node
 			FromNodeID: ast.NodeID(fromNodeID),
 			ToNodeID:   toNodeID,
 			Label:      relationLabel,
+			MetaData:   relationMetadata(record["props"]),
 		})
 	}
 
 	return results, nil
 }
 
+// relationFilterClause builds a WHERE clause matching relation r's metadata against filter,
+// adding the values to params. Keys must be plain identifiers since they become property names.
+func relationFilterClause(filter map[string]any, params map[string]any) (string, error) {
+	if len(filter) == 0 {
+		return "", nil
+	}
+	keys := make([]string, 0, len(filter))
+	for key := range filter {
+		if !isPropertyIdentifier(key) {
+			return "", fmt.Errorf("invalid relation metadata key: %q", key)
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	conditions := make([]string, 0, len(keys))
+	for i, key := range keys {
+		param := fmt.Sprintf("relFilter%d", i)
+		params[param] = filter[key]
+		conditions = append(conditions, fmt.Sprintf("r.md_%s = $%s", key, param))
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), nil
+}
+
+// relationMetadata converts stored relation properties (md_-prefixed) back to metadata keys
+func relationMetadata(props any) map[string]any {
+	propMap, ok := props.(map[string]any)
+	if !ok || len(propMap) == 0 {
+		return nil
+	}
+	metadata := make(map[string]any, len(propMap))
+	for key, value := range propMap {
+		metadata[strings.TrimPrefix(key, metadataPrefix)] = value
+	}
+	return metadata
+}
+
+func isPropertyIdentifier(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i, r := range key {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (i > 0 && r >= '0' && r <= '9') {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
 func (cg *CodeGraph) CreateUsesVariableRelation(ctx context.Context, userNodeID, variableNodeID ast.NodeID, fileID int32) error {
-	return cg.CreateRelation(ctx, userNodeID, variableNodeID, "USES_VARIABLE", nil, fileID)
+	return cg.CreateRelation(ctx, userNodeID, variableNodeID, string(schema.RelUsesVariable), nil, fileID)
 }
 
 func (cg *CodeGraph) CreateImportsRelation(ctx context.Context, importerNodeID, importedNodeID ast.NodeID, fileID int32) error {
-	return cg.CreateRelation(ctx, importerNodeID, importedNodeID, "IMPORTS", nil, fileID)
+	return cg.CreateRelation(ctx, importerNodeID, importedNodeID, string(schema.RelImports), nil, fileID)
 }
 
 func (cg *CodeGraph) CreateBodyRelation(ctx context.Context, parentNodeID, bodyNodeID ast.NodeID, fileID int32) error {
-	return cg.CreateRelation(ctx, parentNodeID, bodyNodeID, "BODY", nil, fileID)
+	return cg.CreateRelation(ctx, parentNodeID, bodyNodeID, string(schema.RelBody), nil, fileID)
 }
 
 func (cg *CodeGraph) CreateAnnotationRelation(ctx context.Context, parentNodeID, annotationNodeID ast.NodeID, fileID int32) error {
-	return cg.CreateRelation(ctx, parentNodeID, annotationNodeID, "ANNOTATION", nil, fileID)
+	return cg.CreateRelation(ctx, parentNodeID, annotationNodeID, string(schema.RelAnnotation), nil, fileID)
+}
+
+func (cg *CodeGraph) CreateCapturesRelation(ctx context.Context, functionNodeID, variableNodeID ast.NodeID, fileID int32) error {
+	return cg.CreateRelation(ctx, functionNodeID, variableNodeID, string(schema.RelCaptures), nil, fileID)
 }
 
 func (cg *CodeGraph) CreateFunctionArgRelation(ctx context.Context, functionNodeID, argNodeID ast.NodeID,
 	position int, fileID int32) error {
-	return cg.CreateRelation(ctx, functionNodeID, argNodeID, "FUNCTION_ARG", map[string]any{
+	return cg.CreateRelation(ctx, functionNodeID, argNodeID, string(schema.RelFunctionArg), map[string]any{
 		"position": position,
 	}, fileID)
 }
 
 func (cg *CodeGraph) CreateFromRelation(ctx context.Context, fromNodeID, toNodeID ast.NodeID, fileID int32) error {
-	return cg.CreateRelation(ctx, fromNodeID, toNodeID, "FROM", nil, fileID)
+	return cg.CreateRelation(ctx, fromNodeID, toNodeID, string(schema.RelFrom), nil, fileID)
 }
 
 func (cg *CodeGraph) CreateDataFlowRelation(ctx context.Context, sourceNodeID, targetNodeID ast.NodeID, fileID int32) error {
-	return cg.CreateRelation(ctx, sourceNodeID, targetNodeID, "DATA_FLOW", nil, fileID)
+	return cg.CreateRelation(ctx, sourceNodeID, targetNodeID, string(schema.RelDataFlow), nil, fileID)
 }
 
 func (cg *CodeGraph) CreateFunctionCallArgRelation(ctx context.Context, callNodeID, argNodeID ast.NodeID,
 	position int, fileID int32) error {
-	return cg.CreateRelation(ctx, callNodeID, argNodeID, "FUNCTION_CALL_ARG", map[string]any{
+	return cg.CreateRelation(ctx, callNodeID, argNodeID, string(schema.RelFunctionCallArg), map[string]any{
 		"position": position,
 	}, fileID)
 }
 
 func (cg *CodeGraph) CreateReturnsRelation(ctx context.Context, functionNodeID, returnNodeID ast.NodeID, fileID int32) error {
-	return cg.CreateRelation(ctx, functionNodeID, returnNodeID, "RETURNS", nil, fileID)
+	return cg.CreateRelation(ctx, functionNodeID, returnNodeID, string(schema.RelReturns), nil, fileID)
 }
 
 func (cg *CodeGraph) CreateAliasRelation(ctx context.Context, aliasNodeID, originalNodeID ast.NodeID, fileID int32) error {
-	return cg.CreateRelation(ctx, aliasNodeID, originalNodeID, "ALIAS", nil, fileID)
+	return cg.CreateRelation(ctx, aliasNodeID, originalNodeID, string(schema.RelAlias), nil, fileID)
 }
 
 func (cg *CodeGraph) CreateConditionalRelation(ctx context.Context, condNodeID,
 	branchNodeID ast.NodeID, position int, conditionID ast.NodeID, fileID int32) error {
-	return cg.CreateRelation(ctx, condNodeID, branchNodeID, "BRANCH", map[string]any{
+	return cg.CreateRelation(ctx, condNodeID, branchNodeID, string(schema.RelBranch), map[string]any{
 		"position":  position,
 		"condition": conditionID,
 	}, fileID)
@@ -1366,7 +1650,7 @@ func (cg *CodeGraph) FindFunctionCalls(ctx context.Context, fileID ast.NodeID) (
 
 	records, err := cg.db.ExecuteRead(ctx, query, parameters)
 	if err != nil {
-		cg.logger.Error("Failed to find function calls", zap.Error(err))
+		cg.log(ctx).Error("Failed to find function calls", zap.Error(err))
 		return nil, fmt.Errorf("failed to find function calls: %w", err)
 	}
 
@@ -1382,7 +1666,7 @@ func (cg *CodeGraph) FindFunctionCalls(ctx context.Context, fileID ast.NodeID) (
 			continue
 		}
 
-		node, err := cg.recordToNode(fcMap)
+		node, err := cg.recordToNode(ctx, fcMap)
 		if err != nil {
 			return nil, fmt.Errorf("failed to convert record to node: %w", err)
 		}
@@ -1406,6 +1690,27 @@ func (cg *CodeGraph) FindFunctionsByName(ctx context.Context, fileID int, name s
 	})
 }
 
+// FindNodesInFile returns the nodes of the given types that belong to a file, in ID order
+func (cg *CodeGraph) FindNodesInFile(ctx context.Context, fileID int32, nodeTypes ...ast.NodeType) ([]*ast.Node, error) {
+	if err := cg.flushCoalescedFile(ctx, fileID); err != nil {
+		return nil, err
+	}
+	types := make([]int64, len(nodeTypes))
+	for i, t := range nodeTypes {
+		types[i] = int64(t)
+	}
+	query := `
+		MATCH (n)
+		WHERE n.fileId = $fileId AND n.nodeType IN $nodeTypes
+		RETURN n
+		ORDER BY n.id
+	`
+	return cg.readNodesByQuery(ctx, "n", query, map[string]any{
+		"fileId":    int64(fileID),
+		"nodeTypes": types,
+	})
+}
+
 // convertToInt64 safely converts various integer types to int64
 func (cg *CodeGraph) convertToInt64(value any) int64 {
 	switch v := value.(type) {
@@ -1474,7 +1779,7 @@ func (cg *CodeGraph) UpdateNodeMetaData(ctx context.Context, nodeID ast.NodeID,
 					for key, value := range metadata {
 						node.MetaData[key] = value
 					}
-					cg.logger.Debug("Updated node metadata in buffer",
+					cg.log(ctx).Debug("Updated node metadata in buffer",
 						zap.Int64("nodeId", int64(nodeID)),
 						zap.Int32("fileId", fileID))
 					return nil
@@ -1495,11 +1800,15 @@ func (cg *CodeGraph) updateNodeMetaDataReal(ctx context.Context, nodeID ast.Node
 	newMetadata := make(map[string]any)
 
 	// Process metadata to separate first-class properties from nested metadata
-	cg.populateFirstClassMetadata(metadata, parameters, newMetadata)
+	if err := cg.populateFirstClassMetadata(metadata, parameters, newMetadata); err != nil {
+		return fmt.Errorf("node %d: %w", nodeID, err)
+	}
 
 	// Flatten remaining metadata
 	if len(newMetadata) > 0 {
-		cg.flattenMetadata(newMetadata, parameters)
+		if err := cg.flattenMetadata(newMetadata, parameters); err != nil {
+			return fmt.Errorf("node %d: %w", nodeID, err)
+		}
 	}
 
 	if len(parameters) == 0 {
@@ -1532,17 +1841,17 @@ func (cg *CodeGraph) updateNodeMetaDataReal(ctx context.Context, nodeID ast.Node
 
 	records, err := cg.db.ExecuteWrite(ctx, query, parameters)
 	if err != nil {
-		cg.logger.Error("Failed to update node metadata",
+		cg.log(ctx).Error("Failed to update node metadata",
 			zap.Int64("nodeId", int64(nodeID)),
 			zap.Error(err))
 		return fmt.Errorf("failed to update node metadata: %w", err)
 	}
 
 	if len(records) == 0 {
-		return fmt.Errorf("node with id %d not found", nodeID)
+		return apperrors.NodeNotFound("node", nodeID)
 	}
 
-	cg.logger.Debug("Updated node metadata in database",
+	cg.log(ctx).Debug("Updated node metadata in database",
 		zap.Int64("nodeId", int64(nodeID)),
 		zap.Int("propertiesUpdated", len(parameters)-1))
 
@@ -1556,7 +1865,7 @@ func (cg *CodeGraph) BatchUpdateNodeMetaData(ctx context.Context, updates map[as
 		return nil
 	}
 
-	cg.logger.Debug("Batch updating node metadata", zap.Int("count", len(updates)))
+	cg.log(ctx).Debug("Batch updating node metadata", zap.Int("count", len(updates)))
 
 	// If batch writes are enabled, try to update buffered nodes first
 	if cg.enableBatchWrites {
@@ -1595,7 +1904,7 @@ func (cg *CodeGraph) BatchUpdateNodeMetaData(ctx context.Context, updates map[as
 
 		// If all nodes were updated in buffers, we're done
 		if len(remainingUpdates) == 0 {
-			cg.logger.Debug("All nodes updated in buffers", zap.Int("count", len(updates)))
+			cg.log(ctx).Debug("All nodes updated in buffers", zap.Int("count", len(updates)))
 			return nil
 		}
 
@@ -1610,9 +1919,13 @@ func (cg *CodeGraph) BatchUpdateNodeMetaData(ctx context.Context, updates map[as
 		parameters := make(map[string]any)
 		newMetadata := make(map[string]any)
 
-		cg.populateFirstClassMetadata(metadata, parameters, newMetadata)
+		if err := cg.populateFirstClassMetadata(metadata, parameters, newMetadata); err != nil {
+			return fmt.Errorf("node %d: %w", nodeID, err)
+		}
 		if len(newMetadata) > 0 {
-			cg.flattenMetadata(newMetadata, parameters)
+			if err := cg.flattenMetadata(newMetadata, parameters); err != nil {
+				return fmt.Errorf("node %d: %w", nodeID, err)
+			}
 		}
 
 		if len(parameters) > 0 {
@@ -1635,7 +1948,7 @@ func (cg *CodeGraph) BatchUpdateNodeMetaData(ctx context.Context, updates map[as
 
 	records, err := cg.db.ExecuteWrite(ctx, query, map[string]any{"updates": updateItems})
 	if err != nil {
-		cg.logger.Error("Failed to batch update node metadata", zap.Error(err))
+		cg.log(ctx).Error("Failed to batch update node metadata", zap.Error(err))
 		return fmt.Errorf("failed to batch update node metadata: %w", err)
 	}
 
@@ -1646,7 +1959,7 @@ func (cg *CodeGraph) BatchUpdateNodeMetaData(ctx context.Context, updates map[as
 		}
 	}
 
-	cg.logger.Debug("Batch updated node metadata in database",
+	cg.log(ctx).Debug("Batch updated node metadata in database",
 		zap.Int64("nodesUpdated", updatedCount),
 		zap.Int("requested", len(updates)))
 
@@ -1673,7 +1986,12 @@ func (cg *CodeGraph) FindClassInFile(ctx context.Context, name string, fileId in
 */
 
 func (t *CodeGraph) MarkThis(ctx context.Context, fileID int32, thisNodeId ast.NodeID, classNodeId ast.NodeID) {
-	_ = t.CreateRelation(ctx, thisNodeId, classNodeId, "THIS", nil, fileID)
+	if err := t.CreateRelation(ctx, thisNodeId, classNodeId, string(schema.RelThis), nil, fileID); err != nil {
+		t.log(ctx).Warn("Failed to mark receiver",
+			zap.Int64("thisId", int64(thisNodeId)),
+			zap.Int64("classId", int64(classNodeId)),
+			zap.Error(err))
+	}
 }
 
 // GetMethodsOfClass returns all methods (functions) contained by a class
@@ -1778,7 +2096,7 @@ func (cg *CodeGraph) GetModuleName(ctx context.Context, fileId int32) (string, e
 
 	records, err := cg.db.ExecuteRead(ctx, query, parameters)
 	if err != nil {
-		cg.logger.Error("Failed to get module name", zap.Error(err))
+		cg.log(ctx).Error("Failed to get module name", zap.Error(err))
 		return "", fmt.Errorf("failed to get module name: %w", err)
 	}
 
@@ -1868,7 +2186,7 @@ func (cg *CodeGraph) UpdateFakeClasses(ctx context.Context, fileID int32) error
 				return fmt.Errorf("failed to delete fake class: %w", err)
 			}
 
-			cg.logger.Debug("Replaced fake class with actual class",
+			cg.log(ctx).Debug("Replaced fake class with actual class",
 				zap.String("className", fakeClass.Name),
 				zap.Int64("fakeClassID", int64(fakeClass.ID)),
 				zap.Int64("actualClassID", int64(actualClasses[0].ID)))
@@ -1914,214 +2232,51 @@ func (cg *CodeGraph) GetFieldsWrittenByMethod(ctx context.Context, methodID ast.
 	return cg.readNodesByQuery(ctx, "f", query, map[string]any{"methodId": int64(methodID)})
 }
 
-// DumpToFile dumps the code graph for the specified repositories to a file.
-// FileScopes are output in alphabetical order by their path.
-// For each FileScope, all nodes and relations within that file are dumped.
-func (cg *CodeGraph) DumpToFile(ctx context.Context, filePath string, repoNames []string) error {
-	file, err := os.Create(filePath)
+// CountRepoNodes returns the number of nodes in all files of a repository, FileScopes included
+func (cg *CodeGraph) CountRepoNodes(ctx context.Context, repoName string) (int64, error) {
+	query := `
+		MATCH (fs:FileScope {repo: $repo})
+		MATCH (n {fileId: fs.fileId})
+		RETURN count(n) AS nodes
+	`
+	result, err := cg.db.ExecuteReadSingle(ctx, query, map[string]any{"repo": repoName})
 	if err != nil {
-		return fmt.Errorf("failed to create dump file: %w", err)
+		return 0, fmt.Errorf("failed to count nodes: %w", err)
 	}
-	defer file.Close()
-
-	writer := bufio.NewWriter(file)
-	defer writer.Flush()
-
-	// Write header
-	fmt.Fprintf(writer, "# Code Graph Dump\n")
-	fmt.Fprintf(writer, "# Repositories: %s\n", strings.Join(repoNames, ", "))
-	fmt.Fprintf(writer, "# Generated at: %s\n\n", time.Now().Format(time.RFC3339))
-
-	// For each repository
-	for _, repoName := range repoNames {
-		fmt.Fprintf(writer, "================================================================================\n")
-		fmt.Fprintf(writer, "REPOSITORY: %s\n", repoName)
-		fmt.Fprintf(writer, "================================================================================\n\n")
-
-		// Get all FileScopes for this repository
-		fileScopes, err := cg.FindFileScopes(ctx, repoName, "")
-		if err != nil {
-			cg.logger.Error("Failed to find file scopes", zap.String("repo", repoName), zap.Error(err))
-			fmt.Fprintf(writer, "ERROR: Failed to find file scopes: %v\n\n", err)
-			continue
-		}
-
-		if len(fileScopes) == 0 {
-			fmt.Fprintf(writer, "No file scopes found for repository.\n\n")
-			continue
-		}
-
-		// Sort FileScopes by path alphabetically
-		sort.Slice(fileScopes, func(i, j int) bool {
-			pathI := ""
-			pathJ := ""
-			if fileScopes[i].MetaData != nil {
-				if p, ok := fileScopes[i].MetaData["path"].(string); ok {
-					pathI = p
-				}
-			}
-			if fileScopes[j].MetaData != nil {
-				if p, ok := fileScopes[j].MetaData["path"].(string); ok {
-					pathJ = p
-				}
-			}
-			return pathI < pathJ
-		})
-
-		fmt.Fprintf(writer, "Total files: %d\n\n", len(fileScopes))
-
-		// For each FileScope, dump all nodes and relations
-		for _, fs := range fileScopes {
-			filePath := ""
-			if fs.MetaData != nil {
-				if p, ok := fs.MetaData["path"].(string); ok {
-					filePath = p
-				}
-			}
-
-			fmt.Fprintf(writer, "--------------------------------------------------------------------------------\n")
-			fmt.Fprintf(writer, "FILE: %s (FileID: %d)\n", filePath, fs.FileID)
-			fmt.Fprintf(writer, "--------------------------------------------------------------------------------\n\n")
-
-			// Dump the FileScope node itself
-			fmt.Fprintf(writer, "## Nodes\n\n")
-			cg.writeNodeToFile(writer, fs, 0)
-
-			// Get all nodes in this file
-			nodesInFile, err := cg.getAllNodesInFile(ctx, fs.FileID)
-			if err != nil {
-				cg.logger.Error("Failed to get nodes in file", zap.Int32("fileId", fs.FileID), zap.Error(err))
-				fmt.Fprintf(writer, "ERROR: Failed to get nodes: %v\n\n", err)
-				continue
-			}
-
-			// Sort nodes by ID for consistent output
-			sort.Slice(nodesInFile, func(i, j int) bool {
-				return nodesInFile[i].ID < nodesInFile[j].ID
-			})
-
-			for _, node := range nodesInFile {
-				cg.writeNodeToFile(writer, node, 1)
-			}
-
-			// Get all relations for this file
-			fmt.Fprintf(writer, "\n## Relations\n\n")
-			relations, err := cg.getAllRelationsInFile(ctx, fs.FileID)
-			if err != nil {
-				cg.logger.Error("Failed to get relations in file", zap.Int32("fileId", fs.FileID), zap.Error(err))
-				fmt.Fprintf(writer, "ERROR: Failed to get relations: %v\n\n", err)
-				continue
-			}
-
-			// Sort relations for consistent output
-			sort.Slice(relations, func(i, j int) bool {
-				if relations[i].fromID != relations[j].fromID {
-					return relations[i].fromID < relations[j].fromID
-				}
-				if relations[i].relType != relations[j].relType {
-					return relations[i].relType < relations[j].relType
-				}
-				return relations[i].toID < relations[j].toID
-			})
-
-			for _, rel := range relations {
-				fmt.Fprintf(writer, "  (%d) -[%s]-> (%d)\n", rel.fromID, rel.relType, rel.toID)
-			}
-
-			fmt.Fprintf(writer, "\nTotal nodes in file: %d\n", len(nodesInFile)+1) // +1 for FileScope
-			fmt.Fprintf(writer, "Total relations in file: %d\n\n", len(relations))
-		}
-	}
-
-	return nil
+	return cg.convertToInt64(result["nodes"]), nil
 }
 
-// relationInfo holds information about a relationship for dumping
-type relationInfo struct {
-	fromID  int64
-	toID    int64
-	relType string
-}
-
-// writeNodeToFile writes a single node to the dump file
-func (cg *CodeGraph) writeNodeToFile(writer *bufio.Writer, node *ast.Node, indent int) {
-	indentStr := strings.Repeat("  ", indent)
-	nodeTypeName := cg.getNodeLabel(node.NodeType)
-
-	fmt.Fprintf(writer, "%s[%s] ID:%d Name:%q Range:%s\n",
-		indentStr, nodeTypeName, node.ID, node.Name, rangeToString(node.Range))
-
-	// Print metadata if present
-	if node.MetaData != nil && len(node.MetaData) > 0 {
-		// Sort metadata keys for consistent output
-		keys := make([]string, 0, len(node.MetaData))
-		for k := range node.MetaData {
-			keys = append(keys, k)
-		}
-		sort.Strings(keys)
-
-		for _, k := range keys {
-			v := node.MetaData[k]
-			fmt.Fprintf(writer, "%s    %s: %v\n", indentStr, k, v)
-		}
+// DeleteFileNodes deletes every node of the given files, FileScopes included, with their
+// relationships, and returns the number of nodes deleted
+func (cg *CodeGraph) DeleteFileNodes(ctx context.Context, fileIDs []int32) (int64, error) {
+	if len(fileIDs) == 0 {
+		return 0, nil
 	}
-}
-
-// getAllNodesInFile retrieves all nodes (except FileScope) that belong to a specific file
-func (cg *CodeGraph) getAllNodesInFile(ctx context.Context, fileID int32) ([]*ast.Node, error) {
-	// Query all node types except FileScope and FileNumber
-	query := `
-		MATCH (n)
-		WHERE n.fileId = $fileId
-		  AND n.nodeType <> $fileScopeType
-		  AND n.nodeType <> $fileNumberType
-		RETURN n
-	`
-	params := map[string]any{
-		"fileId":         int64(fileID),
-		"fileScopeType":  int64(ast.NodeTypeFileScope),
-		"fileNumberType": int64(ast.NodeTypeFileNumber),
+	ids := make([]int64, len(fileIDs))
+	for i, id := range fileIDs {
+		ids[i] = int64(id)
 	}
-
-	return cg.readNodesByQuery(ctx, "n", query, params)
-}
-
-// getAllRelationsInFile retrieves all relationships where either the source or target is in the file
-func (cg *CodeGraph) getAllRelationsInFile(ctx context.Context, fileID int32) ([]relationInfo, error) {
 	query := `
-		MATCH (from)-[r]->(to)
-		WHERE from.fileId = $fileId OR to.fileId = $fileId
-		RETURN from.id as fromId, type(r) as relType, to.id as toId
+		UNWIND $fileIds AS fileId
+		MATCH (n {fileId: fileId})
+		DETACH DELETE n
+		RETURN count(n) AS deleted
 	`
-	params := map[string]any{
-		"fileId": int64(fileID),
-	}
-
-	records, err := cg.db.ExecuteRead(ctx, query, params)
+	result, err := cg.db.ExecuteWrite(ctx, query, map[string]any{"fileIds": ids})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get relations: %w", err)
+		return 0, fmt.Errorf("failed to delete file nodes: %w", err)
 	}
-
-	var relations []relationInfo
-	for _, record := range records {
-		fromID := cg.convertToInt64(record["fromId"])
-		toID := cg.convertToInt64(record["toId"])
-		relType, _ := record["relType"].(string)
-
-		relations = append(relations, relationInfo{
-			fromID:  fromID,
-			toID:    toID,
-			relType: relType,
-		})
+	var deleted int64
+	for _, record := range result {
+		deleted += cg.convertToInt64(record["deleted"])
 	}
-
-	return relations, nil
+	return deleted, nil
 }
 
 // CleanRepository deletes all nodes and relationships for a specific repository from Neo4j.
 // This includes all FileScopes and their descendant nodes (functions, classes, variables, etc.)
 func (cg *CodeGraph) CleanRepository(ctx context.Context, repoName string) error {
-	cg.logger.Info("Starting Neo4j cleanup for repository", zap.String("repo", repoName))
+	cg.log(ctx).Info("Starting Neo4j cleanup for repository", zap.String("repo", repoName))
 
 	// First, get count of nodes to be deleted for logging
 	countQuery := `
@@ -2131,11 +2286,11 @@ func (cg *CodeGraph) CleanRepository(ctx context.Context, repoName string) error
 	`
 	countResult, err := cg.db.ExecuteReadSingle(ctx, countQuery, map[string]any{"repo": repoName})
 	if err != nil {
-		cg.logger.Warn("Failed to count nodes for deletion", zap.Error(err))
+		cg.log(ctx).Warn("Failed to count nodes for deletion", zap.Error(err))
 	} else {
 		fsCount := cg.convertToInt64(countResult["fileScopeCount"])
 		descCount := cg.convertToInt64(countResult["descendantCount"])
-		cg.logger.Info("Nodes to be deleted",
+		cg.log(ctx).Info("Nodes to be deleted",
 			zap.String("repo", repoName),
 			zap.Int64("file_scopes", fsCount),
 			zap.Int64("descendants", descCount))
@@ -2151,7 +2306,7 @@ func (cg *CodeGraph) CleanRepository(ctx context.Context, repoName string) error
 	if err != nil {
 		return fmt.Errorf("failed to delete descendant nodes: %w", err)
 	}
-	cg.logger.Debug("Deleted descendant nodes", zap.String("repo", repoName))
+	cg.log(ctx).Debug("Deleted descendant nodes", zap.String("repo", repoName))
 
 	// Now delete the FileScope nodes themselves
 	deleteFileScopesQuery := `
@@ -2162,9 +2317,16 @@ func (cg *CodeGraph) CleanRepository(ctx context.Context, repoName string) error
 	if err != nil {
 		return fmt.Errorf("failed to delete FileScope nodes: %w", err)
 	}
-	cg.logger.Debug("Deleted FileScope nodes", zap.String("repo", repoName))
+	cg.log(ctx).Debug("Deleted FileScope nodes", zap.String("repo", repoName))
 
-	cg.logger.Info("Neo4j cleanup completed for repository", zap.String("repo", repoName))
+	if err := cg.DeleteCommits(ctx, repoName); err != nil {
+		return err
+	}
+	if err := cg.DeleteStringLiterals(ctx, repoName); err != nil {
+		return err
+	}
+
+	cg.log(ctx).Info("Neo4j cleanup completed for repository", zap.String("repo", repoName))
 	return nil
 }
 
@@ -2185,3 +2347,8 @@ func (cg *CodeGraph) ExecuteReadSingle(ctx context.Context, query string, params
 func (cg *CodeGraph) ExecuteWrite(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
 	return cg.db.ExecuteWrite(ctx, query, params)
 }
+
+// log returns the logger with the request ID carried by ctx attached
+func (cg *CodeGraph) log(ctx context.Context) *zap.Logger {
+	return logging.FromContext(ctx, cg.logger)
+}
@@ -0,0 +1,27 @@
+package codegraph
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"bot-go/internal/config"
+
+	"go.uber.org/zap"
+)
+
+func TestMarkThis(t *testing.T) {
+	db := &fakeDatabase{name: "leader"}
+	cg := NewCodeGraphWithDatabase(db, &config.Config{}, zap.NewNop())
+
+	cg.MarkThis(context.Background(), 1, 4294967300, 4294967297)
+	if len(db.writes) != 1 {
+		t.Fatalf("got %d writes, want the THIS relation", len(db.writes))
+	}
+	if !strings.Contains(db.queries[0], "[r:THIS]") {
+		t.Errorf("query does not create a THIS relation:\n%s", db.queries[0])
+	}
+	if db.writes[0]["parentId"] != int64(4294967300) || db.writes[0]["childId"] != int64(4294967297) {
+		t.Errorf("relation %v -> %v, want the receiver to its class", db.writes[0]["parentId"], db.writes[0]["childId"])
+	}
+}
@@ -0,0 +1,167 @@
+package codegraph
+
+import (
+	"context"
+	"fmt"
+
+	"bot-go/internal/model/ast"
+
+	"go.uber.org/zap"
+)
+
+// commitWriteBatch is the number of commits or links written per UNWIND query
+const commitWriteBatch = 1000
+
+// Commit is a git commit stored as a Commit node, keyed by repository and hash. FileScope,
+// Function and Class nodes point to the commits that changed them with MODIFIED_IN relations.
+type Commit struct {
+	Hash        string `json:"hash"`
+	Author      string `json:"author"`
+	AuthorEmail string `json:"author_email,omitempty"`
+	Time        int64  `json:"time"` // unix seconds, committer time
+	Subject     string `json:"subject"`
+	Message     string `json:"message,omitempty"` // body after the subject
+	PRNumber    int    `json:"pr_number,omitempty"`
+	PRTitle     string `json:"pr_title,omitempty"`
+	PRURL       string `json:"pr_url,omitempty"`
+}
+
+// CommitLink says that a commit modified a node
+type CommitLink struct {
+	NodeID ast.NodeID
+	Hash   string
+}
+
+// UpsertCommits creates or updates the Commit nodes of a repository
+func (cg *CodeGraph) UpsertCommits(ctx context.Context, repoName string, commits []Commit) error {
+	for start := 0; start < len(commits); start += commitWriteBatch {
+		end := min(start+commitWriteBatch, len(commits))
+		rows := make([]map[string]any, 0, end-start)
+		for _, c := range commits[start:end] {
+			rows = append(rows, map[string]any{
+				"hash":        c.Hash,
+				"author":      c.Author,
+				"authorEmail": c.AuthorEmail,
+				"time":        c.Time,
+				"subject":     c.Subject,
+				"message":     c.Message,
+				"prNumber":    int64(c.PRNumber),
+				"prTitle":     c.PRTitle,
+				"prUrl":       c.PRURL,
+			})
+		}
+		query := `
+			UNWIND $commits AS c
+			MERGE (n:Commit {repo: $repo, hash: c.hash})
+			SET n.author = c.author, n.authorEmail = c.authorEmail, n.time = c.time,
+			    n.subject = c.subject, n.message = c.message,
+			    n.prNumber = c.prNumber, n.prTitle = c.prTitle, n.prUrl = c.prUrl
+		`
+		if _, err := cg.db.ExecuteWrite(ctx, query, map[string]any{"repo": repoName, "commits": rows}); err != nil {
+			return fmt.Errorf("failed to write commits: %w", err)
+		}
+	}
+	cg.log(ctx).Debug("Upserted commits", zap.String("repo", repoName), zap.Int("count", len(commits)))
+	return nil
+}
+
+// LinkCommitsToFiles creates MODIFIED_IN relations from the FileScopes of a repository to
+// the commits that touched them. filesByCommit maps a commit hash to repository-relative
+// paths; paths without a FileScope are ignored. Returns the number of relations created.
+func (cg *CodeGraph) LinkCommitsToFiles(ctx context.Context, repoName string, filesByCommit map[string][]string) (int, error) {
+	var rows []map[string]any
+	for hash, paths := range filesByCommit {
+		for _, path := range paths {
+			rows = append(rows, map[string]any{"hash": hash, "path": path})
+		}
+	}
+	query := `
+		UNWIND $links AS l
+		MATCH (f:FileScope {repo: $repo, path: l.path})
+		MATCH (c:Commit {repo: $repo, hash: l.hash})
+		MERGE (f)-[:MODIFIED_IN]->(c)
+		RETURN count(*) AS linked
+	`
+	return cg.writeCommitLinks(ctx, repoName, query, rows)
+}
+
+// LinkCommitsToNodes creates MODIFIED_IN relations from nodes to commits of a repository.
+// Returns the number of relations created.
+func (cg *CodeGraph) LinkCommitsToNodes(ctx context.Context, repoName string, links []CommitLink) (int, error) {
+	rows := make([]map[string]any, 0, len(links))
+	for _, l := range links {
+		rows = append(rows, map[string]any{"id": int64(l.NodeID), "hash": l.Hash})
+	}
+	query := `
+		UNWIND $links AS l
+		MATCH (n {id: l.id})
+		MATCH (c:Commit {repo: $repo, hash: l.hash})
+		MERGE (n)-[:MODIFIED_IN]->(c)
+		RETURN count(*) AS linked
+	`
+	return cg.writeCommitLinks(ctx, repoName, query, rows)
+}
+
+func (cg *CodeGraph) writeCommitLinks(ctx context.Context, repoName, query string, rows []map[string]any) (int, error) {
+	linked := 0
+	for start := 0; start < len(rows); start += commitWriteBatch {
+		end := min(start+commitWriteBatch, len(rows))
+		records, err := cg.db.ExecuteWrite(ctx, query, map[string]any{"repo": repoName, "links": rows[start:end]})
+		if err != nil {
+			return linked, fmt.Errorf("failed to link commits: %w", err)
+		}
+		if len(records) > 0 {
+			linked += int(cg.convertToInt64(records[0]["linked"]))
+		}
+	}
+	return linked, nil
+}
+
+// GetNodeCommits returns the commits of a repository linked to a node by MODIFIED_IN, newest
+// first
+func (cg *CodeGraph) GetNodeCommits(ctx context.Context, repoName string, nodeID ast.NodeID, limit int) ([]Commit, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	query := `
+		MATCH (n {id: $id})-[:MODIFIED_IN]->(c:Commit {repo: $repo})
+		RETURN c.hash AS hash, c.author AS author, c.authorEmail AS authorEmail, c.time AS time,
+		       c.subject AS subject, c.message AS message,
+		       c.prNumber AS prNumber, c.prTitle AS prTitle, c.prUrl AS prUrl
+		ORDER BY c.time DESC
+		LIMIT $limit
+	`
+	records, err := cg.db.ExecuteRead(ctx, query, map[string]any{"id": int64(nodeID), "repo": repoName, "limit": int64(limit)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commits of node %d: %w", nodeID, err)
+	}
+
+	commits := make([]Commit, 0, len(records))
+	for _, r := range records {
+		commits = append(commits, Commit{
+			Hash:        recordString(r, "hash"),
+			Author:      recordString(r, "author"),
+			AuthorEmail: recordString(r, "authorEmail"),
+			Time:        cg.convertToInt64(r["time"]),
+			Subject:     recordString(r, "subject"),
+			Message:     recordString(r, "message"),
+			PRNumber:    int(cg.convertToInt64(r["prNumber"])),
+			PRTitle:     recordString(r, "prTitle"),
+			PRURL:       recordString(r, "prUrl"),
+		})
+	}
+	return commits, nil
+}
+
+func recordString(record map[string]any, key string) string {
+	s, _ := record[key].(string)
+	return s
+}
+
+// DeleteCommits removes the Commit nodes of a repository and their relations
+func (cg *CodeGraph) DeleteCommits(ctx context.Context, repoName string) error {
+	if _, err := cg.db.ExecuteWrite(ctx, `MATCH (c:Commit {repo: $repo}) DETACH DELETE c`, map[string]any{"repo": repoName}); err != nil {
+		return fmt.Errorf("failed to delete commits: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,643 @@
+package codegraph
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"bot-go/internal/model/ast"
+	"bot-go/internal/storage"
+	"bot-go/internal/util"
+
+	"go.uber.org/zap"
+)
+
+// ErrDumpTruncated is returned (wrapped) when a dump stops early because its context was
+// cancelled or its timeout expired. The output written so far ends with a truncation marker.
+var ErrDumpTruncated = errors.New("code graph dump truncated")
+
+// dumpPageSize is the number of nodes or relations read per query while streaming a file
+const dumpPageSize = 1000
+
+// DumpFormat selects the output format of a dump
+type DumpFormat string
+
+const (
+	// DumpFormatText is the human-readable format used by the parser tests
+	DumpFormatText DumpFormat = "text"
+	// DumpFormatJSONL writes one JSON object per line (see dumpRecord)
+	DumpFormatJSONL DumpFormat = "jsonl"
+)
+
+// DumpOptions bounds and shapes the output of Dump
+type DumpOptions struct {
+	// Timeout is a hard limit on the whole dump (0 = no limit beyond ctx)
+	Timeout time.Duration
+	// MaxRowsPerFile caps the nodes and the relations read for a single file (0 = no cap).
+	// Files over the cap are written partially and marked as truncated.
+	MaxRowsPerFile int
+	// Format of the output (default DumpFormatText)
+	Format DumpFormat
+	// Compress gzips the output. Implied for file names and keys ending in ".gz".
+	Compress bool
+	// Paths restricts the dump to files whose path equals or lies under one of these paths
+	Paths []string
+	// NodeTypes restricts the dumped nodes (other than the FileScope) to these types
+	NodeTypes []ast.NodeType
+	// Reproducible makes text dumps of the same source byte-identical, whichever FileIDs the
+	// builds assigned: the generation time is omitted, files are identified by a key derived
+	// from repository and path, node IDs are written as <file key>:<sequence in the file>
+	// wherever they appear (IDs, relations, ID-valued metadata and the names of generated
+	// variables), the FileScope's checkout-dependent modification time is omitted, and the
+	// relations of each file are sorted by these IDs
+	Reproducible bool
+}
+
+// dumpRecord is a line of JSONL dump output. Type is one of "repository", "file", "node",
+// "relation", "file_end", "error" or "truncated"; only the fields relevant to it are set.
+type dumpRecord struct {
+	Type      string         `json:"type"`
+	Repo      string         `json:"repo,omitempty"`
+	Path      string         `json:"path,omitempty"`
+	FileID    int32          `json:"file_id,omitempty"`
+	ID        int64          `json:"id,omitempty"`
+	NodeType  string         `json:"node_type,omitempty"`
+	Name      string         `json:"name,omitempty"`
+	Range     string         `json:"range,omitempty"`
+	MetaData  map[string]any `json:"metadata,omitempty"`
+	From      int64          `json:"from,omitempty"`
+	To        int64          `json:"to,omitempty"`
+	RelType   string         `json:"rel_type,omitempty"`
+	Nodes     int            `json:"nodes,omitempty"`
+	Relations int            `json:"relations,omitempty"`
+	Message   string         `json:"message,omitempty"`
+}
+
+// dumpWriter renders dump events in a particular format
+type dumpWriter interface {
+	header(repoNames []string)
+	repository(repoName string, fileCount int)
+	file(repoName, path string, fs *ast.Node)
+	node(node *ast.Node)
+	relationsStart()
+	relation(rel relationInfo)
+	fileEnd(nodes, relations int)
+	note(kind, message string) // kind is "error", "truncated" or "empty"
+}
+
+// DumpToFile dumps the code graph for the specified repositories to a file.
+// FileScopes are output in alphabetical order by their path.
+// For each FileScope, all nodes and relations within that file are dumped.
+func (cg *CodeGraph) DumpToFile(ctx context.Context, filePath string, repoNames []string) error {
+	return cg.DumpToFileWithOptions(ctx, filePath, repoNames, DumpOptions{})
+}
+
+// DumpToFileWithOptions is DumpToFile with output options; see Dump. A file name ending in
+// ".gz" implies opts.Compress.
+func (cg *CodeGraph) DumpToFileWithOptions(ctx context.Context, filePath string, repoNames []string, opts DumpOptions) error {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to create dump file: %w", err)
+	}
+	defer file.Close()
+	opts.Compress = opts.Compress || strings.HasSuffix(filePath, ".gz")
+	return cg.Dump(ctx, file, repoNames, opts)
+}
+
+// DumpToStore dumps the code graph for the specified repositories to an object of a storage
+// backend. A key ending in ".gz" implies opts.Compress. A truncated dump is stored and its
+// ErrDumpTruncated error returned; a dump failing otherwise leaves no object.
+func (cg *CodeGraph) DumpToStore(ctx context.Context, store storage.Store, key string, repoNames []string, opts DumpOptions) error {
+	opts.Compress = opts.Compress || strings.HasSuffix(key, ".gz")
+	var truncated error
+	err := store.Put(ctx, key, func(w io.Writer) error {
+		err := cg.Dump(ctx, w, repoNames, opts)
+		if errors.Is(err, ErrDumpTruncated) {
+			truncated = err
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return truncated
+}
+
+// Dump writes the code graph for the specified repositories to w. Nodes and relations are
+// streamed page by page in ID order, so memory use does not grow with the graph size. ctx
+// is checked between pages; if it is cancelled (or the timeout expires) the dump stops, a
+// truncation marker is written after the partial output, and an error wrapping
+// ErrDumpTruncated is returned.
+func (cg *CodeGraph) Dump(ctx context.Context, w io.Writer, repoNames []string, opts DumpOptions) (err error) {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	out := w
+	if opts.Compress {
+		gz := gzip.NewWriter(w)
+		defer func() {
+			if closeErr := gz.Close(); closeErr != nil && err == nil {
+				err = fmt.Errorf("failed to finish compressed dump: %w", closeErr)
+			}
+		}()
+		out = gz
+	}
+
+	buffered := bufio.NewWriter(out)
+	defer func() {
+		if flushErr := buffered.Flush(); flushErr != nil && err == nil {
+			err = fmt.Errorf("failed to write dump: %w", flushErr)
+		}
+	}()
+
+	var writer dumpWriter
+	switch opts.Format {
+	case "", DumpFormatText:
+		text := &textDumpWriter{cg: cg, w: buffered, reproducible: opts.Reproducible}
+		if opts.Reproducible {
+			text.fileKeys = cg.dumpFileKeys(ctx, repoNames)
+		}
+		writer = text
+	case DumpFormatJSONL:
+		writer = &jsonlDumpWriter{cg: cg, enc: json.NewEncoder(buffered)}
+	default:
+		return fmt.Errorf("unsupported dump format: %s", opts.Format)
+	}
+
+	filesDumped := 0
+	truncate := func(reason error) error {
+		writer.note("truncated", fmt.Sprintf("%v (after %d files)", reason, filesDumped))
+		cg.log(ctx).Warn("Code graph dump truncated",
+			zap.Int("files_dumped", filesDumped),
+			zap.Error(reason))
+		return fmt.Errorf("%w: %v", ErrDumpTruncated, reason)
+	}
+
+	writer.header(repoNames)
+
+	for _, repoName := range repoNames {
+		if ctx.Err() != nil {
+			return truncate(ctx.Err())
+		}
+
+		// Get all FileScopes for this repository
+		fileScopes, err := cg.FindFileScopes(ctx, repoName, "")
+		if err != nil {
+			if ctx.Err() != nil {
+				return truncate(ctx.Err())
+			}
+			cg.log(ctx).Error("Failed to find file scopes", zap.String("repo", repoName), zap.Error(err))
+			writer.repository(repoName, 0)
+			writer.note("error", fmt.Sprintf("Failed to find file scopes: %v", err))
+			continue
+		}
+
+		fileScopes = filterFileScopes(fileScopes, opts.Paths)
+		writer.repository(repoName, len(fileScopes))
+		if len(fileScopes) == 0 {
+			writer.note("empty", "No file scopes found for repository.")
+			continue
+		}
+
+		// Sort FileScopes by path alphabetically
+		sort.Slice(fileScopes, func(i, j int) bool {
+			return fileScopePath(fileScopes[i]) < fileScopePath(fileScopes[j])
+		})
+
+		for _, fs := range fileScopes {
+			if ctx.Err() != nil {
+				return truncate(ctx.Err())
+			}
+			if err := cg.dumpFile(ctx, writer, repoName, fs, opts); err != nil {
+				if ctx.Err() != nil {
+					return truncate(ctx.Err())
+				}
+				writer.note("error", err.Error())
+				continue
+			}
+			filesDumped++
+		}
+	}
+
+	return nil
+}
+
+// dumpFile streams the nodes and relations of one file to writer
+func (cg *CodeGraph) dumpFile(ctx context.Context, writer dumpWriter, repoName string, fs *ast.Node, opts DumpOptions) error {
+	writer.file(repoName, fileScopePath(fs), fs)
+
+	nodeCount := 0
+	afterID := int64(-1)
+	for {
+		pageSize := dumpPageLimit(opts.MaxRowsPerFile, nodeCount)
+		nodes, err := cg.readFileNodesPage(ctx, fs.FileID, opts.NodeTypes, afterID, pageSize)
+		if err != nil {
+			cg.log(ctx).Error("Failed to get nodes in file", zap.Int32("fileId", fs.FileID), zap.Error(err))
+			return fmt.Errorf("failed to get nodes: %w", err)
+		}
+		for _, node := range nodes {
+			if opts.MaxRowsPerFile > 0 && nodeCount == opts.MaxRowsPerFile {
+				writer.note("truncated", fmt.Sprintf("more than %d nodes in file", opts.MaxRowsPerFile))
+				break
+			}
+			writer.node(node)
+			nodeCount++
+			afterID = int64(node.ID)
+		}
+		if len(nodes) < pageSize || (opts.MaxRowsPerFile > 0 && nodeCount >= opts.MaxRowsPerFile) {
+			break
+		}
+	}
+
+	writer.relationsStart()
+	relationCount := 0
+	var after *relationInfo
+	for {
+		pageSize := dumpPageLimit(opts.MaxRowsPerFile, relationCount)
+		relations, err := cg.readFileRelationsPage(ctx, fs.FileID, after, pageSize)
+		if err != nil {
+			cg.log(ctx).Error("Failed to get relations in file", zap.Int32("fileId", fs.FileID), zap.Error(err))
+			return fmt.Errorf("failed to get relations: %w", err)
+		}
+		for i := range relations {
+			if opts.MaxRowsPerFile > 0 && relationCount == opts.MaxRowsPerFile {
+				writer.note("truncated", fmt.Sprintf("more than %d relations in file", opts.MaxRowsPerFile))
+				break
+			}
+			writer.relation(relations[i])
+			relationCount++
+			after = &relations[i]
+		}
+		if len(relations) < pageSize || (opts.MaxRowsPerFile > 0 && relationCount >= opts.MaxRowsPerFile) {
+			break
+		}
+	}
+
+	writer.fileEnd(nodeCount+1, relationCount) // +1 for FileScope
+	return nil
+}
+
+// dumpPageLimit returns how many rows to request next: a full page, or one row past the
+// remaining per-file cap so the cap being exceeded can be detected
+func dumpPageLimit(maxRows, read int) int {
+	if maxRows <= 0 || maxRows-read+1 > dumpPageSize {
+		return dumpPageSize
+	}
+	return maxRows - read + 1
+}
+
+// relationInfo holds information about a relationship for dumping
+type relationInfo struct {
+	fromID  int64
+	toID    int64
+	relType string
+}
+
+// readFileNodesPage reads up to limit nodes (except FileScope and FileNumber) of a file
+// with IDs greater than afterID, in ID order, optionally restricted to nodeTypes
+func (cg *CodeGraph) readFileNodesPage(ctx context.Context, fileID int32, nodeTypes []ast.NodeType, afterID int64, limit int) ([]*ast.Node, error) {
+	params := map[string]any{
+		"fileId":         int64(fileID),
+		"fileScopeType":  int64(ast.NodeTypeFileScope),
+		"fileNumberType": int64(ast.NodeTypeFileNumber),
+		"afterId":        afterID,
+		"limit":          int64(limit),
+	}
+	typeFilter := ""
+	if len(nodeTypes) > 0 {
+		types := make([]int64, len(nodeTypes))
+		for i, t := range nodeTypes {
+			types[i] = int64(t)
+		}
+		params["nodeTypes"] = types
+		typeFilter = "AND n.nodeType IN $nodeTypes"
+	}
+	query := `
+		MATCH (n)
+		WHERE n.fileId = $fileId
+		  AND n.nodeType <> $fileScopeType
+		  AND n.nodeType <> $fileNumberType
+		  AND n.id > $afterId
+		  ` + typeFilter + `
+		RETURN n
+		ORDER BY n.id
+		LIMIT $limit
+	`
+	return cg.readNodesByQuery(ctx, "n", query, params)
+}
+
+// readFileRelationsPage reads up to limit relationships where either the source or target
+// is in the file, ordered by (from, type, to) and starting after the given relation (nil
+// for the first page)
+func (cg *CodeGraph) readFileRelationsPage(ctx context.Context, fileID int32, after *relationInfo, limit int) ([]relationInfo, error) {
+	params := map[string]any{
+		"fileId": int64(fileID),
+		"limit":  int64(limit),
+	}
+	keyset := ""
+	if after != nil {
+		params["afterFrom"] = after.fromID
+		params["afterType"] = after.relType
+		params["afterTo"] = after.toID
+		keyset = `AND (from.id > $afterFrom
+		       OR (from.id = $afterFrom AND (type(r) > $afterType
+		       OR (type(r) = $afterType AND to.id > $afterTo))))`
+	}
+	query := `
+		MATCH (from)-[r]->(to)
+		WHERE (from.fileId = $fileId OR to.fileId = $fileId)
+		  ` + keyset + `
+		RETURN from.id as fromId, type(r) as relType, to.id as toId
+		ORDER BY fromId, relType, toId
+		LIMIT $limit
+	`
+
+	records, err := cg.db.ExecuteRead(ctx, query, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get relations: %w", err)
+	}
+
+	relations := make([]relationInfo, 0, len(records))
+	for _, record := range records {
+		relType, _ := record["relType"].(string)
+		relations = append(relations, relationInfo{
+			fromID:  cg.convertToInt64(record["fromId"]),
+			toID:    cg.convertToInt64(record["toId"]),
+			relType: relType,
+		})
+	}
+	return relations, nil
+}
+
+// filterFileScopes keeps the file scopes whose path equals or lies under one of paths
+// (all of them if paths is empty)
+func filterFileScopes(fileScopes []*ast.Node, paths []string) []*ast.Node {
+	if len(paths) == 0 {
+		return fileScopes
+	}
+	var filtered []*ast.Node
+	for _, fs := range fileScopes {
+		path := util.CanonicalPath(fileScopePath(fs))
+		for _, p := range paths {
+			p = util.CanonicalPath(p)
+			if p == "" || path == p || strings.HasPrefix(path, p+"/") {
+				filtered = append(filtered, fs)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+func fileScopePath(fs *ast.Node) string {
+	if fs.MetaData != nil {
+		if p, ok := fs.MetaData["path"].(string); ok {
+			return p
+		}
+	}
+	return ""
+}
+
+// dumpFileKeys maps the FileIDs of the repositories' files to their dump file keys.
+// Repositories whose files cannot be listed are left out; the dump reports them.
+func (cg *CodeGraph) dumpFileKeys(ctx context.Context, repoNames []string) map[int32]string {
+	keys := make(map[int32]string)
+	for _, repoName := range repoNames {
+		fileScopes, err := cg.FindFileScopes(ctx, repoName, "")
+		if err != nil {
+			continue
+		}
+		for _, fs := range fileScopes {
+			keys[fs.FileID] = dumpFileKey(repoName, fileScopePath(fs))
+		}
+	}
+	return keys
+}
+
+// dumpFileKey identifies a file in reproducible dumps, independently of its FileID
+func dumpFileKey(repoName, path string) string {
+	sum := sha256.Sum256([]byte(repoName + "\x00" + path))
+	return hex.EncodeToString(sum[:6])
+}
+
+// idMetadata are the metadata keys holding node IDs, rewritten like IDs in reproducible dumps
+var idMetadata = map[string]bool{"condition": true, "init": true, "nameID": true}
+
+// generatedNameID matches the node ID that ends the names of generated variables, as in
+// __rhs___4294967301
+var generatedNameID = regexp.MustCompile(`_(\d+)$`)
+
+// textDumpWriter writes the human-readable dump format
+type textDumpWriter struct {
+	cg           *CodeGraph
+	w            *bufio.Writer
+	reproducible bool
+	fileKeys     map[int32]string // by FileID, when reproducible
+	relations    []string         // of the current file, written sorted by fileEnd when reproducible
+}
+
+// formatID writes a node ID, as <file key>:<sequence> when reproducible. Node IDs hold
+// their FileID in the upper 32 bits; IDs of files outside the dump are written as is.
+func (t *textDumpWriter) formatID(id int64) string {
+	if key, ok := t.fileKeys[int32(id>>32)]; ok && t.reproducible {
+		return fmt.Sprintf("%s:%d", key, uint32(id))
+	}
+	return strconv.FormatInt(id, 10)
+}
+
+func (t *textDumpWriter) header(repoNames []string) {
+	fmt.Fprintf(t.w, "# Code Graph Dump\n")
+	fmt.Fprintf(t.w, "# Repositories: %s\n", strings.Join(repoNames, ", "))
+	if t.reproducible {
+		fmt.Fprintf(t.w, "\n")
+		return
+	}
+	fmt.Fprintf(t.w, "# Generated at: %s\n\n", time.Now().Format(time.RFC3339))
+}
+
+func (t *textDumpWriter) repository(repoName string, fileCount int) {
+	fmt.Fprintf(t.w, "================================================================================\n")
+	fmt.Fprintf(t.w, "REPOSITORY: %s\n", repoName)
+	fmt.Fprintf(t.w, "================================================================================\n\n")
+	if fileCount > 0 {
+		fmt.Fprintf(t.w, "Total files: %d\n\n", fileCount)
+	}
+}
+
+func (t *textDumpWriter) file(repoName, path string, fs *ast.Node) {
+	fmt.Fprintf(t.w, "--------------------------------------------------------------------------------\n")
+	if t.reproducible {
+		fmt.Fprintf(t.w, "FILE: %s (FileKey: %s)\n", path, t.fileKeys[fs.FileID])
+	} else {
+		fmt.Fprintf(t.w, "FILE: %s (FileID: %d)\n", path, fs.FileID)
+	}
+	fmt.Fprintf(t.w, "--------------------------------------------------------------------------------\n\n")
+	fmt.Fprintf(t.w, "## Nodes\n\n")
+	t.writeNode(fs, 0)
+}
+
+func (t *textDumpWriter) node(node *ast.Node) {
+	t.writeNode(node, 1)
+}
+
+func (t *textDumpWriter) relationsStart() {
+	fmt.Fprintf(t.w, "\n## Relations\n\n")
+}
+
+func (t *textDumpWriter) relation(rel relationInfo) {
+	line := fmt.Sprintf("  (%s) -[%s]-> (%s)\n", t.formatID(rel.fromID), rel.relType, t.formatID(rel.toID))
+	if t.reproducible {
+		// Relations come in FileID order, which differs between builds
+		t.relations = append(t.relations, line)
+		return
+	}
+	t.w.WriteString(line)
+}
+
+func (t *textDumpWriter) fileEnd(nodes, relations int) {
+	t.flushRelations()
+	fmt.Fprintf(t.w, "\nTotal nodes in file: %d\n", nodes)
+	fmt.Fprintf(t.w, "Total relations in file: %d\n\n", relations)
+}
+
+// flushRelations writes the relations held back by a reproducible dump
+func (t *textDumpWriter) flushRelations() {
+	sort.Strings(t.relations)
+	for _, line := range t.relations {
+		t.w.WriteString(line)
+	}
+	t.relations = t.relations[:0]
+}
+
+func (t *textDumpWriter) note(kind, message string) {
+	t.flushRelations()
+	switch kind {
+	case "error":
+		fmt.Fprintf(t.w, "ERROR: %s\n\n", message)
+	case "truncated":
+		fmt.Fprintf(t.w, "# TRUNCATED: %s\n", message)
+	default:
+		fmt.Fprintf(t.w, "%s\n\n", message)
+	}
+}
+
+// writeNode writes a single node with its metadata sorted by key
+func (t *textDumpWriter) writeNode(node *ast.Node, indent int) {
+	indentStr := strings.Repeat("  ", indent)
+	nodeTypeName := t.cg.getNodeLabel(node.NodeType)
+
+	fmt.Fprintf(t.w, "%s[%s] ID:%s Name:%q Range:%s\n",
+		indentStr, nodeTypeName, t.formatID(int64(node.ID)), t.formatName(node), rangeToString(node.Range))
+
+	// Print metadata if present
+	if len(node.MetaData) > 0 {
+		// Sort metadata keys for consistent output
+		keys := make([]string, 0, len(node.MetaData))
+		for k := range node.MetaData {
+			if t.reproducible && node.NodeType == ast.NodeTypeFileScope && k == "modified" {
+				continue
+			}
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			fmt.Fprintf(t.w, "%s    %s: %s\n", indentStr, k, t.formatMetadata(k, node.MetaData[k]))
+		}
+	}
+}
+
+// formatName returns the node's name, with the node ID ending the names of generated
+// variables rewritten when reproducible
+func (t *textDumpWriter) formatName(node *ast.Node) string {
+	if fake, _ := node.MetaData["fake"].(bool); !fake || !t.reproducible {
+		return node.Name
+	}
+	return generatedNameID.ReplaceAllStringFunc(node.Name, func(suffix string) string {
+		id, err := strconv.ParseInt(suffix[1:], 10, 64)
+		if err != nil {
+			return suffix
+		}
+		return "_" + t.formatID(id)
+	})
+}
+
+// formatMetadata returns a metadata value, with node IDs rewritten when reproducible
+func (t *textDumpWriter) formatMetadata(key string, value any) string {
+	if t.reproducible && idMetadata[key] {
+		switch id := value.(type) {
+		case int64:
+			return t.formatID(id)
+		case ast.NodeID:
+			return t.formatID(int64(id))
+		}
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// jsonlDumpWriter writes one dumpRecord per line
+type jsonlDumpWriter struct {
+	cg     *CodeGraph
+	enc    *json.Encoder
+	repo   string
+	fileID int32
+}
+
+func (j *jsonlDumpWriter) header(repoNames []string) {}
+
+func (j *jsonlDumpWriter) repository(repoName string, fileCount int) {
+	j.repo = repoName
+	j.fileID = 0
+	j.enc.Encode(dumpRecord{Type: "repository", Repo: repoName})
+}
+
+func (j *jsonlDumpWriter) file(repoName, path string, fs *ast.Node) {
+	j.fileID = fs.FileID
+	j.enc.Encode(dumpRecord{Type: "file", Repo: repoName, Path: path, FileID: fs.FileID})
+	j.node(fs)
+}
+
+func (j *jsonlDumpWriter) node(node *ast.Node) {
+	j.enc.Encode(dumpRecord{
+		Type:     "node",
+		FileID:   node.FileID,
+		ID:       int64(node.ID),
+		NodeType: j.cg.getNodeLabel(node.NodeType),
+		Name:     node.Name,
+		Range:    rangeToString(node.Range),
+		MetaData: node.MetaData,
+	})
+}
+
+func (j *jsonlDumpWriter) relationsStart() {}
+
+func (j *jsonlDumpWriter) relation(rel relationInfo) {
+	j.enc.Encode(dumpRecord{Type: "relation", FileID: j.fileID, From: rel.fromID, To: rel.toID, RelType: rel.relType})
+}
+
+func (j *jsonlDumpWriter) fileEnd(nodes, relations int) {
+	j.enc.Encode(dumpRecord{Type: "file_end", FileID: j.fileID, Nodes: nodes, Relations: relations})
+}
+
+func (j *jsonlDumpWriter) note(kind, message string) {
+	if kind == "empty" {
+		return
+	}
+	j.enc.Encode(dumpRecord{Type: kind, Repo: j.repo, FileID: j.fileID, Message: message})
+}
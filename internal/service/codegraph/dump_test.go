@@ -0,0 +1,65 @@
+package codegraph
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+
+	"bot-go/internal/model/ast"
+	"bot-go/pkg/lsp/base"
+)
+
+func TestReproducibleTextDump(t *testing.T) {
+	// The same two files, numbered differently by two builds
+	dump := func(mainID, storeID int32) string {
+		var buf bytes.Buffer
+		w := &textDumpWriter{cg: &CodeGraph{}, w: bufio.NewWriter(&buf), reproducible: true, fileKeys: map[int32]string{
+			mainID:  dumpFileKey("repo", "main.go"),
+			storeID: dumpFileKey("repo", "store.go"),
+		}}
+		id := func(fileID int32, seq uint32) ast.NodeID { return ast.NodeID(fileID)<<32 | ast.NodeID(seq) }
+
+		w.header([]string{"repo"})
+		w.repository("repo", 2)
+		fileScope := ast.NewNode(id(mainID, 1), ast.NodeTypeFileScope, mainID, "main.go", base.Range{}, 0, 0)
+		fileScope.MetaData = map[string]any{"path": "main.go", "modified": int64(1700000000 + mainID)} // checkouts differ in mtime
+		w.file("repo", "main.go", fileScope)
+		w.node(ast.NewNode(id(mainID, 2), ast.NodeTypeFunction, mainID, "main", base.Range{}, 0, 0))
+		cond := ast.NewNode(id(mainID, 3), ast.NodeTypeVariable, mainID, "__cond___"+strconv.FormatInt(int64(id(mainID, 3)), 10), base.Range{}, 0, 0)
+		cond.MetaData = map[string]any{"fake": true}
+		w.node(cond)
+		loop := ast.NewNode(id(mainID, 4), ast.NodeTypeLoop, mainID, "", base.Range{}, 0, 0)
+		loop.MetaData = map[string]any{"condition": int64(id(mainID, 3))}
+		w.node(loop)
+		w.relationsStart()
+		w.relation(relationInfo{fromID: int64(id(mainID, 1)), toID: int64(id(mainID, 2)), relType: "CONTAINS"})
+		w.relation(relationInfo{fromID: int64(id(mainID, 2)), toID: int64(id(storeID, 2)), relType: "CALLS"})
+		w.relation(relationInfo{fromID: int64(id(mainID, 2)), toID: int64(id(99, 1)), relType: "CALLS"})
+		w.fileEnd(2, 3)
+		w.w.Flush()
+		return buf.String()
+	}
+
+	first, second := dump(1, 2), dump(7, 3)
+	if first != second {
+		t.Errorf("dumps differ:\n%s\n---\n%s", first, second)
+	}
+	for _, want := range []string{
+		"FILE: main.go (FileKey: " + dumpFileKey("repo", "main.go") + ")",
+		"ID:" + dumpFileKey("repo", "main.go") + ":2 ",
+		"-[CALLS]-> (" + dumpFileKey("repo", "store.go") + ":2)",
+		"-[CALLS]-> (425201762305)", // a file outside the dump keeps its node ID
+		`Name:"__cond___` + dumpFileKey("repo", "main.go") + `:3"`,
+		"condition: " + dumpFileKey("repo", "main.go") + ":3",
+		"path: main.go",
+	} {
+		if !strings.Contains(first, want) {
+			t.Errorf("dump lacks %q:\n%s", want, first)
+		}
+	}
+	if strings.Contains(first, "Generated at") || strings.Contains(first, "modified:") {
+		t.Errorf("reproducible dump has a generation or modification time:\n%s", first)
+	}
+}
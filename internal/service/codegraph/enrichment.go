@@ -0,0 +1,182 @@
+package codegraph
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"bot-go/internal/apperrors"
+	"bot-go/internal/model/ast"
+	"bot-go/internal/model/schema"
+
+	"go.uber.org/zap"
+)
+
+// enrichmentWriteBatch is the number of relations written or deleted per query
+const enrichmentWriteBatch = 1000
+
+// Provenance properties of the relations created by external tools
+const (
+	PropEnrichmentSource    = "source"    // the tool that reported the relation
+	PropEnrichmentRequestID = "requestId" // the request that last reported it
+	PropEnrichmentCreatedAt = "createdAt" // unix seconds of the first report
+	PropEnrichmentUpdatedAt = "updatedAt" // unix seconds of the last report
+	PropEnrichmentReports   = "reports"   // number of reports
+)
+
+// EnrichedRelation is a relation between two existing nodes reported by an external tool,
+// such as a runtime call observed by tracing
+type EnrichedRelation struct {
+	From       ast.NodeID
+	To         ast.NodeID
+	Type       schema.RelationType
+	Properties map[string]any // stored under the metadata prefix, like node metadata
+}
+
+// RelationProvenance identifies a report of enriched relations
+type RelationProvenance struct {
+	Source    string // the reporting tool; each source has its own relation between two nodes
+	RequestID string
+	Time      time.Time
+}
+
+// CreateEnrichedRelations creates or updates relations reported by an external tool. Every
+// relation type must be an external relation of the schema registry (see
+// schema.RegisterExternalRelation) that admits the labels of its endpoints, and both
+// endpoints must exist; otherwise nothing is written. A relation reported again by the same
+// source is updated: its properties are merged and its report count incremented. Returns
+// the number of relations written.
+func (cg *CodeGraph) CreateEnrichedRelations(ctx context.Context, relations []EnrichedRelation, provenance RelationProvenance) (int, error) {
+	if provenance.Source == "" {
+		return 0, fmt.Errorf("%w: relation source is empty", apperrors.ErrInvalidArgument)
+	}
+	labels, err := cg.nodeLabels(ctx, relations)
+	if err != nil {
+		return 0, err
+	}
+
+	byType := make(map[schema.RelationType][]map[string]any)
+	for i, r := range relations {
+		from, ok := labels[r.From]
+		if !ok {
+			return 0, fmt.Errorf("relations[%d]: %w", i, apperrors.NodeNotFound("node", r.From))
+		}
+		to, ok := labels[r.To]
+		if !ok {
+			return 0, fmt.Errorf("relations[%d]: %w", i, apperrors.NodeNotFound("node", r.To))
+		}
+		if err := schema.ValidateExternalRelation(r.Type, from, to); err != nil {
+			return 0, fmt.Errorf("relations[%d]: %w: %v", i, apperrors.ErrInvalidArgument, err)
+		}
+		props := make(map[string]any)
+		if err := cg.flattenMetadata(r.Properties, props); err != nil {
+			return 0, fmt.Errorf("relations[%d]: %w", i, err)
+		}
+		byType[r.Type] = append(byType[r.Type], map[string]any{"from": int64(r.From), "to": int64(r.To), "props": props})
+	}
+
+	types := make([]schema.RelationType, 0, len(byType))
+	for relType := range byType {
+		types = append(types, relType)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+
+	params := map[string]any{
+		"source":    provenance.Source,
+		"requestId": provenance.RequestID,
+		"time":      provenance.Time.Unix(),
+	}
+	written := 0
+	for _, relType := range types {
+		// The type is spliced into the query; ValidateExternalRelation checked it is registered
+		query := fmt.Sprintf(`
+			UNWIND $rows AS row
+			MATCH (a {id: row.from})
+			MATCH (b {id: row.to})
+			MERGE (a)-[r:%s {%s: $source}]->(b)
+			ON CREATE SET r.%s = $time, r.%s = 0
+			SET r += row.props, r.%s = $time, r.%s = $requestId, r.%s = r.%s + 1
+			RETURN count(r) AS written
+		`, relType, PropEnrichmentSource,
+			PropEnrichmentCreatedAt, PropEnrichmentReports,
+			PropEnrichmentUpdatedAt, PropEnrichmentRequestID, PropEnrichmentReports, PropEnrichmentReports)
+		rows := byType[relType]
+		for start := 0; start < len(rows); start += enrichmentWriteBatch {
+			end := min(start+enrichmentWriteBatch, len(rows))
+			params["rows"] = rows[start:end]
+			records, err := cg.db.ExecuteWrite(ctx, query, params)
+			if err != nil {
+				return written, fmt.Errorf("failed to write %s relations: %w", relType, err)
+			}
+			if len(records) > 0 {
+				written += int(cg.convertToInt64(records[0]["written"]))
+			}
+		}
+	}
+	cg.log(ctx).Info("Enriched relations written",
+		zap.String("source", provenance.Source),
+		zap.Int("relations", written))
+	return written, nil
+}
+
+// nodeLabels returns the labels of the endpoints of relations that exist
+func (cg *CodeGraph) nodeLabels(ctx context.Context, relations []EnrichedRelation) (map[ast.NodeID]schema.NodeLabel, error) {
+	seen := make(map[ast.NodeID]bool, 2*len(relations))
+	ids := make([]int64, 0, 2*len(relations))
+	for _, r := range relations {
+		for _, id := range []ast.NodeID{r.From, r.To} {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, int64(id))
+			}
+		}
+	}
+	records, err := cg.db.ExecuteRead(ctx, `
+		MATCH (n)
+		WHERE n.id IN $ids
+		RETURN n.id AS id, n.nodeType AS nodeType
+	`, map[string]any{"ids": ids})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read relation endpoints: %w", err)
+	}
+	labels := make(map[ast.NodeID]schema.NodeLabel, len(records))
+	for _, record := range records {
+		labels[ast.NodeID(cg.convertToInt64(record["id"]))] = schema.LabelOf(ast.NodeType(cg.convertToInt64(record["nodeType"])))
+	}
+	return labels, nil
+}
+
+// DeleteEnrichedRelations deletes the relations of an external relation type reported by a
+// source, e.g. before a tool reports a fresh trace. Returns the number deleted.
+func (cg *CodeGraph) DeleteEnrichedRelations(ctx context.Context, relType schema.RelationType, source string) (int64, error) {
+	if !schema.IsExternalRelation(relType) {
+		return 0, fmt.Errorf("%w: %q is not an external relation type", apperrors.ErrInvalidArgument, relType)
+	}
+	query := fmt.Sprintf(`
+		MATCH ()-[r:%s {%s: $source}]->()
+		WITH r LIMIT $batch
+		DELETE r
+		RETURN count(*) AS deleted
+	`, relType, PropEnrichmentSource)
+	var deleted int64
+	for {
+		records, err := cg.db.ExecuteWrite(ctx, query, map[string]any{"source": source, "batch": int64(enrichmentWriteBatch)})
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete %s relations: %w", relType, err)
+		}
+		n := int64(0)
+		if len(records) > 0 {
+			n = cg.convertToInt64(records[0]["deleted"])
+		}
+		deleted += n
+		if n < enrichmentWriteBatch {
+			break
+		}
+	}
+	cg.log(ctx).Info("Enriched relations deleted",
+		zap.String("type", string(relType)),
+		zap.String("source", source),
+		zap.Int64("relations", deleted))
+	return deleted, nil
+}
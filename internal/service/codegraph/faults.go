@@ -0,0 +1,45 @@
+package codegraph
+
+import (
+	"context"
+
+	"bot-go/internal/faults"
+)
+
+// faultDatabase injects the faults configured for the neo4j target before every query. It
+// is only installed in builds with the faults tag.
+type faultDatabase struct {
+	GraphDatabase
+}
+
+// ExecuteRead injects a "read" fault, then runs the read
+func (db *faultDatabase) ExecuteRead(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	if err := faults.Inject(ctx, faults.TargetNeo4j, "read"); err != nil {
+		return nil, err
+	}
+	return db.GraphDatabase.ExecuteRead(ctx, query, params)
+}
+
+// ExecuteWrite injects a "write" fault, then runs the write
+func (db *faultDatabase) ExecuteWrite(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	if err := faults.Inject(ctx, faults.TargetNeo4j, "write"); err != nil {
+		return nil, err
+	}
+	return db.GraphDatabase.ExecuteWrite(ctx, query, params)
+}
+
+// ExecuteReadSingle injects a "read" fault, then runs the read
+func (db *faultDatabase) ExecuteReadSingle(ctx context.Context, query string, params map[string]any) (map[string]any, error) {
+	if err := faults.Inject(ctx, faults.TargetNeo4j, "read"); err != nil {
+		return nil, err
+	}
+	return db.GraphDatabase.ExecuteReadSingle(ctx, query, params)
+}
+
+// ExecuteWriteSingle injects a "write" fault, then runs the write
+func (db *faultDatabase) ExecuteWriteSingle(ctx context.Context, query string, params map[string]any) (map[string]any, error) {
+	if err := faults.Inject(ctx, faults.TargetNeo4j, "write"); err != nil {
+		return nil, err
+	}
+	return db.GraphDatabase.ExecuteWriteSingle(ctx, query, params)
+}
@@ -0,0 +1,192 @@
+package codegraph
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"bot-go/internal/model/ast"
+
+	"go.uber.org/zap"
+)
+
+// SymbolSearchIndex is the name of the Neo4j full-text index over symbol names and docstrings
+const SymbolSearchIndex = "symbolSearch"
+
+// symbolSearchLabels are the node labels covered by the symbol search index
+var symbolSearchLabels = []string{"Function", "Class", "Field", "Variable", "ModuleScope", "FileScope"}
+
+// SymbolMatchMode controls how a symbol search term is matched
+type SymbolMatchMode string
+
+const (
+	SymbolMatchExact     SymbolMatchMode = "exact"     // whole-token match
+	SymbolMatchPrefix    SymbolMatchMode = "prefix"    // token starts with the term (default)
+	SymbolMatchSubstring SymbolMatchMode = "substring" // token contains the term
+	SymbolMatchFuzzy     SymbolMatchMode = "fuzzy"     // token within a small edit distance of the term
+)
+
+// SymbolSearchOptions narrows a symbol search
+type SymbolSearchOptions struct {
+	Mode      SymbolMatchMode
+	NodeTypes []ast.NodeType // empty = all indexed types
+	Limit     int            // default 20
+}
+
+// SymbolSearchResult is a node matched by SearchSymbols with its relevance score
+type SymbolSearchResult struct {
+	Node     *ast.Node
+	Label    string // node label, e.g. "Function"
+	FilePath string
+	Score    float64
+}
+
+// EnsureSymbolSearchIndex creates the full-text index used by SearchSymbols if it does not
+// exist. It covers the name and docstring (md_docstring) properties of symbol nodes.
+func (cg *CodeGraph) EnsureSymbolSearchIndex(ctx context.Context) error {
+	query := fmt.Sprintf(`
+		CREATE FULLTEXT INDEX %s IF NOT EXISTS
+		FOR (n:%s)
+		ON EACH [n.name, n.md_docstring]
+	`, SymbolSearchIndex, strings.Join(symbolSearchLabels, "|"))
+	if _, err := cg.db.ExecuteWrite(ctx, query, nil); err != nil {
+		return fmt.Errorf("failed to create symbol search index: %w", err)
+	}
+	cg.log(ctx).Info("Symbol search index ready", zap.String("index", SymbolSearchIndex))
+	return nil
+}
+
+// WarmupIndexes waits for index population to finish and runs a throwaway query against the
+// symbol search index and each repository's FileScopes, so the first interactive query does
+// not pay for loading index readers and pages from disk
+func (cg *CodeGraph) WarmupIndexes(ctx context.Context, repoNames []string) error {
+	if _, err := cg.db.ExecuteRead(ctx, "CALL db.awaitIndexes(60)", nil); err != nil {
+		return fmt.Errorf("failed waiting for indexes: %w", err)
+	}
+
+	query := fmt.Sprintf(`
+		CALL db.index.fulltext.queryNodes('%s', 'warmup') YIELD node
+		RETURN count(node) AS hits
+	`, SymbolSearchIndex)
+	if _, err := cg.db.ExecuteReadSingle(ctx, query, nil); err != nil {
+		return fmt.Errorf("failed to prime symbol search index: %w", err)
+	}
+
+	for _, repoName := range repoNames {
+		if _, err := cg.db.ExecuteReadSingle(ctx, `
+			MATCH (fs:FileScope {repo: $repo})
+			RETURN count(fs) AS files
+		`, map[string]any{"repo": repoName}); err != nil {
+			return fmt.Errorf("failed to prime repository %s: %w", repoName, err)
+		}
+	}
+	return nil
+}
+
+// DropSymbolSearchIndex removes the symbol search index, e.g. before rebuilding it
+func (cg *CodeGraph) DropSymbolSearchIndex(ctx context.Context) error {
+	query := fmt.Sprintf("DROP INDEX %s IF EXISTS", SymbolSearchIndex)
+	if _, err := cg.db.ExecuteWrite(ctx, query, nil); err != nil {
+		return fmt.Errorf("failed to drop symbol search index: %w", err)
+	}
+	return nil
+}
+
+// SearchSymbols finds nodes of a repository whose name or docstring matches text, using the
+// full-text index (see EnsureSymbolSearchIndex). Results are ordered by score, then by
+// reference count (see ComputePopularity).
+func (cg *CodeGraph) SearchSymbols(ctx context.Context, repoName, text string, opts SymbolSearchOptions) ([]SymbolSearchResult, error) {
+	luceneQuery := symbolLuceneQuery(text, opts.Mode)
+	if luceneQuery == "" {
+		return nil, fmt.Errorf("search text is empty")
+	}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+
+	params := map[string]any{
+		"index": SymbolSearchIndex,
+		"query": luceneQuery,
+		"repo":  repoName,
+		"limit": int64(limit),
+	}
+	typeFilter := ""
+	if len(opts.NodeTypes) > 0 {
+		types := make([]int64, len(opts.NodeTypes))
+		for i, t := range opts.NodeTypes {
+			types[i] = int64(t)
+		}
+		params["nodeTypes"] = types
+		typeFilter = "AND node.nodeType IN $nodeTypes"
+	}
+
+	query := `
+		CALL db.index.fulltext.queryNodes($index, $query) YIELD node, score
+		MATCH (f:FileScope {repo: $repo})
+		WHERE node.fileId = f.fileId ` + typeFilter + `
+		RETURN node, score, f.path AS path
+		ORDER BY score DESC, coalesce(node.` + PropRefCount + `, 0) DESC
+		LIMIT $limit
+	`
+	records, err := cg.db.ExecuteRead(ctx, query, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search symbols: %w", err)
+	}
+
+	results := make([]SymbolSearchResult, 0, len(records))
+	for _, record := range records {
+		nodeMap, ok := record["node"].(map[string]any)
+		if !ok {
+			continue
+		}
+		node, err := cg.recordToNode(ctx, nodeMap)
+		if err != nil {
+			return nil, err
+		}
+		score, _ := record["score"].(float64)
+		path, _ := record["path"].(string)
+		results = append(results, SymbolSearchResult{Node: node, Label: cg.getNodeLabel(node.NodeType), FilePath: path, Score: score})
+	}
+	return results, nil
+}
+
+// symbolLuceneQuery turns user text into a Lucene query for the given mode. Each
+// whitespace-separated term is escaped and all terms must match.
+func symbolLuceneQuery(text string, mode SymbolMatchMode) string {
+	terms := strings.Fields(text)
+	if len(terms) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(terms))
+	for _, term := range terms {
+		escaped := escapeLucene(term)
+		if mode != SymbolMatchExact {
+			// Wildcard and fuzzy terms bypass the analyzer, so match its lowercasing
+			escaped = strings.ToLower(escaped)
+		}
+		switch mode {
+		case SymbolMatchExact:
+			parts = append(parts, escaped)
+		case SymbolMatchSubstring:
+			parts = append(parts, "*"+escaped+"*")
+		case SymbolMatchFuzzy:
+			parts = append(parts, escaped+"~")
+		default:
+			parts = append(parts, escaped+"*")
+		}
+	}
+	return strings.Join(parts, " AND ")
+}
+
+// escapeLucene escapes characters with special meaning in Lucene query syntax
+func escapeLucene(term string) string {
+	var b strings.Builder
+	for _, r := range term {
+		if strings.ContainsRune(`+-&|!(){}[]^"~*?:\/`, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,77 @@
+package codegraph
+
+import (
+	"context"
+	"fmt"
+
+	"bot-go/internal/apperrors"
+	"bot-go/internal/model/ast"
+)
+
+// FunctionVersion is one indexed version of a function: the Function node parsed from one
+// version of its file (FileScope)
+type FunctionVersion struct {
+	Function *ast.Node
+	FileID   int32
+	Path     string // repository-relative path of the file
+	Commit   string // commit the file version was indexed at; empty for working tree versions
+	Modified int64  // file modification time (unix seconds) when it was indexed
+}
+
+// FindFunctionVersions returns every indexed version of a function of a repository, oldest
+// file version first. Versions are matched by file path, function name and containing class name; file
+// versions that do not define the function are absent.
+func (cg *CodeGraph) FindFunctionVersions(ctx context.Context, repoName string, functionID ast.NodeID) ([]FunctionVersion, error) {
+	query := `
+		MATCH (f:Function {id: $id})
+		MATCH (fs:FileScope {id: f.fileId, repo: $repo})
+		OPTIONAL MATCH (c:Class)-[:CONTAINS]->(f)
+		WITH f, fs, c.name AS className
+		MATCH (ofs:FileScope {repo: fs.repo, path: fs.path})
+		MATCH (o:Function {fileId: ofs.id, name: f.name})
+		OPTIONAL MATCH (oc:Class)-[:CONTAINS]->(o)
+		WITH o, ofs, className, oc.name AS otherClassName
+		WHERE coalesce(otherClassName, '') = coalesce(className, '')
+		RETURN o, ofs.id AS fileId, ofs.path AS path, ofs.commit AS commit, ofs.md_modified AS modified
+		ORDER BY ofs.id, o.id
+	`
+	records, err := cg.db.ExecuteRead(ctx, query, map[string]any{"repo": repoName, "id": int64(functionID)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read versions of function %d: %w", functionID, err)
+	}
+	if len(records) == 0 {
+		return nil, apperrors.NodeNotFound("function", functionID)
+	}
+
+	versions := make([]FunctionVersion, 0, len(records))
+	byFile := make(map[int32]int, len(records))
+	for _, r := range records {
+		nodeMap, ok := r["o"].(map[string]any)
+		if !ok {
+			continue
+		}
+		node, err := cg.recordToNode(ctx, nodeMap)
+		if err != nil {
+			return nil, err
+		}
+		version := FunctionVersion{
+			Function: node,
+			FileID:   cg.convertToInt32(r["fileId"]),
+			Path:     recordString(r, "path"),
+			Commit:   recordString(r, "commit"),
+			Modified: cg.convertToInt64(r["modified"]),
+		}
+
+		// Overloads share a name; keep one definition per file version, preferring the
+		// requested node in its own file
+		if i, ok := byFile[version.FileID]; ok {
+			if node.ID == functionID {
+				versions[i] = version
+			}
+			continue
+		}
+		byFile[version.FileID] = len(versions)
+		versions = append(versions, version)
+	}
+	return versions, nil
+}
@@ -0,0 +1,225 @@
+package codegraph
+
+import (
+	"context"
+	"fmt"
+
+	"bot-go/internal/apperrors"
+	"bot-go/internal/model/ast"
+	"bot-go/internal/util"
+
+	"go.uber.org/zap"
+)
+
+// PositionResult is the node found by FindNodeAtPosition
+type PositionResult struct {
+	Node     *ast.Node
+	Label    string // node label, e.g. "Function"
+	FilePath string
+}
+
+// FindNodeAtPosition returns the innermost node of a file that contains the LSP position
+// (0-based line, UTF-16 character), or nil if none does. nodeTypes restricts the candidates,
+// e.g. ast.NodeTypeFunction to answer "which function is this line in". The lookup uses the
+// numeric range properties, so nodes from graphs that only have the legacy range string are
+// not found until their file is re-indexed.
+func (cg *CodeGraph) FindNodeAtPosition(ctx context.Context, repoName, filePath string, line, character int, nodeTypes ...ast.NodeType) (*PositionResult, error) {
+	path := util.CanonicalPath(filePath)
+	params := map[string]any{
+		"repo":      repoName,
+		"path":      path,
+		"pathKey":   util.PathKey(path),
+		"line":      int64(line),
+		"character": int64(character),
+	}
+	typeFilter := ""
+	if len(nodeTypes) > 0 {
+		types := make([]int64, len(nodeTypes))
+		for i, t := range nodeTypes {
+			types[i] = int64(t)
+		}
+		params["nodeTypes"] = types
+		typeFilter = "AND n.nodeType IN $nodeTypes"
+	}
+
+	// Ranges of nested nodes are nested, so the innermost match is the one that starts
+	// last, and of those the one that ends first
+	query := `
+		MATCH (f:FileScope {repo: $repo})
+		WHERE f.path = $path OR toLower(f.path) = $pathKey
+		WITH f
+		ORDER BY CASE WHEN f.path = $path THEN 0 ELSE 1 END, f.fileId DESC
+		LIMIT 1
+		MATCH (n {fileId: f.fileId})
+		WHERE n.startLine IS NOT NULL ` + typeFilter + `
+		  AND (n.startLine < $line OR (n.startLine = $line AND n.startChar <= $character))
+		  AND (n.endLine > $line OR (n.endLine = $line AND n.endChar >= $character))
+		RETURN n, f.path AS path
+		ORDER BY n.startLine DESC, n.startChar DESC, n.endLine, n.endChar, n.id DESC
+		LIMIT 1
+	`
+	records, err := cg.db.ExecuteRead(ctx, query, params)
+	if err != nil {
+		cg.log(ctx).Error("Failed to find node at position",
+			zap.String("repo", repoName),
+			zap.String("path", path),
+			zap.Int("line", line),
+			zap.Int("character", character),
+			zap.Error(err))
+		return nil, fmt.Errorf("failed to find node at position: %w", err)
+	}
+
+	for _, record := range records {
+		nodeMap, ok := record["n"].(map[string]any)
+		if !ok {
+			continue
+		}
+		node, err := cg.recordToNode(ctx, nodeMap)
+		if err != nil {
+			return nil, err
+		}
+		resultPath, _ := record["path"].(string)
+		return &PositionResult{Node: node, Label: cg.getNodeLabel(node.NodeType), FilePath: resultPath}, nil
+	}
+	return nil, nil
+}
+
+// FindNodesInLines returns the nodes of the latest indexed version of a file whose range
+// overlaps the 0-based lines startLine to endLine, in source order. Like FindNodeAtPosition
+// it needs the numeric range properties.
+func (cg *CodeGraph) FindNodesInLines(ctx context.Context, repoName, filePath string, startLine, endLine int, nodeTypes ...ast.NodeType) ([]*ast.Node, error) {
+	path := util.CanonicalPath(filePath)
+	params := map[string]any{
+		"repo":      repoName,
+		"path":      path,
+		"pathKey":   util.PathKey(path),
+		"startLine": int64(startLine),
+		"endLine":   int64(endLine),
+	}
+	typeFilter := ""
+	if len(nodeTypes) > 0 {
+		types := make([]int64, len(nodeTypes))
+		for i, t := range nodeTypes {
+			types[i] = int64(t)
+		}
+		params["nodeTypes"] = types
+		typeFilter = "AND n.nodeType IN $nodeTypes"
+	}
+
+	query := `
+		MATCH (f:FileScope {repo: $repo})
+		WHERE f.path = $path OR toLower(f.path) = $pathKey
+		WITH f
+		ORDER BY CASE WHEN f.path = $path THEN 0 ELSE 1 END, f.fileId DESC
+		LIMIT 1
+		MATCH (n {fileId: f.fileId})
+		WHERE n.startLine IS NOT NULL ` + typeFilter + `
+		  AND n.startLine <= $endLine AND n.endLine >= $startLine
+		RETURN n
+		ORDER BY n.startLine, n.startChar, n.id
+	`
+	records, err := cg.db.ExecuteRead(ctx, query, params)
+	if err != nil {
+		cg.log(ctx).Error("Failed to find nodes in lines",
+			zap.String("repo", repoName),
+			zap.String("path", path),
+			zap.Int("start_line", startLine),
+			zap.Int("end_line", endLine),
+			zap.Error(err))
+		return nil, fmt.Errorf("failed to find nodes in lines: %w", err)
+	}
+
+	nodes := make([]*ast.Node, 0, len(records))
+	for _, record := range records {
+		nodeMap, ok := record["n"].(map[string]any)
+		if !ok {
+			continue
+		}
+		node, err := cg.recordToNode(ctx, nodeMap)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// NodeFile is a node with the file version it was parsed from, found by FindNodeFile
+type NodeFile struct {
+	Node     *ast.Node
+	Label    string // node label, e.g. "Function"
+	RepoName string
+	FilePath string // repository-relative path of the file
+	Commit   string // commit the file version was indexed at; empty for working tree versions
+}
+
+// FindNodeFile returns a node together with the repository, path and commit recorded on its
+// FileScope. nodeTypes restricts which nodes are found; a node of another type is reported
+// as not found.
+func (cg *CodeGraph) FindNodeFile(ctx context.Context, nodeID ast.NodeID, nodeTypes ...ast.NodeType) (*NodeFile, error) {
+	found, err := cg.FindNodeFiles(ctx, []ast.NodeID{nodeID}, nodeTypes...)
+	if err != nil {
+		return nil, err
+	}
+	if len(found) == 0 {
+		return nil, apperrors.NodeNotFound("node", nodeID)
+	}
+	return found[0], nil
+}
+
+// FindNodeFiles looks up many nodes in one query, each with the file recorded on its
+// FileScope (empty for nodes without one). IDs with no node of the given types are left out;
+// the order of the result is unspecified.
+func (cg *CodeGraph) FindNodeFiles(ctx context.Context, nodeIDs []ast.NodeID, nodeTypes ...ast.NodeType) ([]*NodeFile, error) {
+	if len(nodeIDs) == 0 {
+		return nil, nil
+	}
+	ids := make([]int64, len(nodeIDs))
+	for i, id := range nodeIDs {
+		ids[i] = int64(id)
+	}
+	params := map[string]any{"ids": ids}
+	typeFilter := ""
+	if len(nodeTypes) > 0 {
+		types := make([]int64, len(nodeTypes))
+		for i, t := range nodeTypes {
+			types[i] = int64(t)
+		}
+		params["nodeTypes"] = types
+		typeFilter = "WHERE n.nodeType IN $nodeTypes"
+	}
+
+	query := `
+		UNWIND $ids AS id
+		MATCH (n {id: id}) ` + typeFilter + `
+		OPTIONAL MATCH (f:FileScope {id: n.fileId})
+		RETURN n, f.repo AS repo, f.path AS path, f.commit AS commit
+	`
+	records, err := cg.db.ExecuteRead(ctx, query, params)
+	if err != nil {
+		cg.log(ctx).Error("Failed to find files of nodes",
+			zap.Int("nodes", len(nodeIDs)),
+			zap.Error(err))
+		return nil, fmt.Errorf("failed to find files of %d nodes: %w", len(nodeIDs), err)
+	}
+
+	found := make([]*NodeFile, 0, len(records))
+	for _, record := range records {
+		nodeMap, ok := record["n"].(map[string]any)
+		if !ok {
+			continue
+		}
+		node, err := cg.recordToNode(ctx, nodeMap)
+		if err != nil {
+			return nil, err
+		}
+		found = append(found, &NodeFile{
+			Node:     node,
+			Label:    cg.getNodeLabel(node.NodeType),
+			RepoName: recordString(record, "repo"),
+			FilePath: recordString(record, "path"),
+			Commit:   recordString(record, "commit"),
+		})
+	}
+	return found, nil
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 
+	"bot-go/internal/apperrors"
+
 	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
 	"go.uber.org/zap"
 )
@@ -76,12 +78,42 @@ func (db *Neo4jDatabase) ExecuteRead(ctx context.Context, query string, params m
 
 	if err != nil {
 		db.logger.Error("Failed to execute read query", zap.String("query", query), zap.Error(err))
-		return nil, fmt.Errorf("failed to execute read query: %w", err)
+		return nil, fmt.Errorf("failed to execute read query: %w", classifyNeo4jError(err))
 	}
 
+	if capture := PlanCaptureFrom(ctx); capture != nil {
+		if captured := capture.begin(query); captured != nil {
+			plan, err := db.readPlan(ctx, query, params, capture.Mode())
+			capture.finish(captured, plan, err)
+		}
+	}
 	return result.([]map[string]any), nil
 }
 
+// readPlan runs a read query under EXPLAIN or PROFILE and returns its plan. PROFILE executes
+// the query once more.
+func (db *Neo4jDatabase) readPlan(ctx context.Context, query string, params map[string]any, mode PlanMode) (*QueryPlan, error) {
+	session := db.driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close(ctx)
+
+	result, err := session.Run(ctx, planPrefix(mode)+query, params)
+	if err != nil {
+		return nil, classifyNeo4jError(err)
+	}
+	summary, err := result.Consume(ctx)
+	if err != nil {
+		return nil, classifyNeo4jError(err)
+	}
+	if mode == PlanProfile {
+		if plan := convertProfile(summary.Profile()); plan != nil {
+			return plan, nil
+		}
+	} else if plan := convertPlan(summary.Plan()); plan != nil {
+		return plan, nil
+	}
+	return nil, fmt.Errorf("no plan in the query summary")
+}
+
 // ExecuteWrite executes a write Cypher query and returns the raw records
 func (db *Neo4jDatabase) ExecuteWrite(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
 	session := db.driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
@@ -119,7 +151,7 @@ func (db *Neo4jDatabase) ExecuteWrite(ctx context.Context, query string, params
 
 	if err != nil {
 		db.logger.Error("Failed to execute write query", zap.String("query", query), zap.Error(err))
-		return nil, fmt.Errorf("failed to execute write query: %w", err)
+		return nil, fmt.Errorf("failed to execute write query: %w", classifyNeo4jError(err))
 	}
 
 	return result.([]map[string]any), nil
@@ -132,15 +164,7 @@ func (db *Neo4jDatabase) ExecuteReadSingle(ctx context.Context, query string, pa
 		return nil, err
 	}
 
-	if len(records) == 0 {
-		return nil, fmt.Errorf("no records returned")
-	}
-
-	if len(records) > 1 {
-		return nil, fmt.Errorf("expected single record, got %d", len(records))
-	}
-
-	return records[0], nil
+	return singleRecord(records)
 }
 
 // ExecuteWriteSingle executes a write Cypher query expecting a single record
@@ -150,15 +174,7 @@ func (db *Neo4jDatabase) ExecuteWriteSingle(ctx context.Context, query string, p
 		return nil, err
 	}
 
-	if len(records) == 0 {
-		return nil, fmt.Errorf("no records returned")
-	}
-
-	if len(records) > 1 {
-		return nil, fmt.Errorf("expected single record, got %d", len(records))
-	}
-
-	return records[0], nil
+	return singleRecord(records)
 }
 
 // Neo4jNode wraps a Neo4j node to implement the GraphNode interface
@@ -175,3 +191,11 @@ func (n *Neo4jNode) GetProperties() map[string]any {
 func WrapNeo4jNode(node neo4j.Node) GraphNode {
 	return &Neo4jNode{node: node}
 }
+
+// classifyNeo4jError marks connectivity failures as apperrors.ErrBackendUnavailable
+func classifyNeo4jError(err error) error {
+	if neo4j.IsConnectivityError(err) {
+		return apperrors.Unavailable("neo4j", err)
+	}
+	return err
+}
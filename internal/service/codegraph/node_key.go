@@ -0,0 +1,59 @@
+package codegraph
+
+import (
+	"context"
+	"fmt"
+
+	"bot-go/internal/model/schema"
+
+	"go.uber.org/zap"
+)
+
+// PropNodeKey is the node property holding ast.Node.Key, the stable identity of a parsed
+// node. Nodes with a key are upserted by it instead of by id, so re-indexing a file updates
+// its nodes in place even when their numeric IDs change.
+const PropNodeKey = "nodeKey"
+
+// nodeKeyLabels returns the labels of nodes created with a key: every registered label
+// except the file-level ones, which are merged by their stable file ID
+func nodeKeyLabels() []string {
+	var result []string
+	for _, label := range schema.NodeLabels() {
+		if label != schema.LabelFileScope && label != schema.LabelFileNumber {
+			result = append(result, string(label))
+		}
+	}
+	return result
+}
+
+// nodeMergeClause returns the MERGE that finds or creates node n. Keyed nodes merge on
+// their key; a node written before keys existed, with the same id and no key, is adopted
+// first so it is updated rather than duplicated. v prefixes the id and key values: "$"
+// for query parameters or "nodeData." inside an UNWIND over nodeData.
+func nodeMergeClause(label string, keyed bool, v string) string {
+	if !keyed {
+		return fmt.Sprintf("MERGE (n:%s {id: %sid})", label, v)
+	}
+	carry := "legacy"
+	if v != "$" {
+		carry = "nodeData"
+	}
+	return fmt.Sprintf(`OPTIONAL MATCH (legacy:%[1]s {id: %[2]sid}) WHERE legacy.%[3]s IS NULL
+		SET legacy.%[3]s = %[2]s%[3]s
+		WITH %[4]s
+		MERGE (n:%[1]s {%[3]s: %[2]s%[3]s})`, label, v, PropNodeKey, carry)
+}
+
+// EnsureNodeKeyIndexes creates the indexes that keyed upserts look nodes up by
+func (cg *CodeGraph) EnsureNodeKeyIndexes(ctx context.Context) error {
+	labels := nodeKeyLabels()
+	for _, label := range labels {
+		query := fmt.Sprintf(`CREATE INDEX node_key_%s IF NOT EXISTS FOR (n:%s) ON (n.%s)`,
+			label, label, PropNodeKey)
+		if _, err := cg.db.ExecuteWrite(ctx, query, nil); err != nil {
+			return fmt.Errorf("failed to create node key index for %s: %w", label, err)
+		}
+	}
+	cg.log(ctx).Info("Node key indexes ready", zap.Int("labels", len(labels)))
+	return nil
+}
@@ -0,0 +1,48 @@
+package codegraph
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"bot-go/internal/model/ast"
+
+	"go.uber.org/zap"
+)
+
+func TestBatchWriteNodesByKey(t *testing.T) {
+	db := &fakeDatabase{name: "leader"}
+	cg := &CodeGraph{db: db, logger: zap.NewNop(), fileIDCache: make(map[int32]string)}
+
+	nodes := []*ast.Node{
+		{ID: 1<<32 | 1, NodeType: ast.NodeTypeFunction, FileID: 1, Name: "a", Key: "1:0:00000000000000aa"},
+		{ID: 1<<32 | 2, NodeType: ast.NodeTypeFunction, FileID: 1, Name: "b", Key: "1:0:00000000000000bb"},
+		{ID: 1<<32 | 3, NodeType: ast.NodeTypeFunction, FileID: 1, Name: "c"},
+		{ID: 1<<32 | 4, NodeType: ast.NodeTypeFunction, FileID: 1, Name: "d"},
+	}
+	if err := cg.BatchWriteNodes(context.Background(), nodes); err != nil {
+		t.Fatal(err)
+	}
+
+	// Keyed and unkeyed nodes of one label are merged by separate queries
+	if len(db.writes) != 2 {
+		t.Fatalf("got %d writes, want one per merge strategy", len(db.writes))
+	}
+	for _, write := range db.writes {
+		batch, _ := write["nodes"].([]map[string]any)
+		if len(batch) != 2 {
+			t.Fatalf("write = %v, want two nodes", write)
+		}
+		_, keyed := batch[0][PropNodeKey]
+		if _, other := batch[1][PropNodeKey]; keyed != other {
+			t.Errorf("keyed and unkeyed nodes share a batch: %v", batch)
+		}
+	}
+
+	if clause := nodeMergeClause("Function", true, "nodeData."); !strings.Contains(clause, "MERGE (n:Function {nodeKey: nodeData.nodeKey})") {
+		t.Errorf("keyed merge clause = %q", clause)
+	}
+	if clause := nodeMergeClause("Function", false, "$"); clause != "MERGE (n:Function {id: $id})" {
+		t.Errorf("unkeyed merge clause = %q", clause)
+	}
+}
@@ -0,0 +1,247 @@
+package codegraph
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// Metadata keys of the popularity counts written by ComputePopularity. They are stored as
+// node properties under the metadata prefix (e.g. md_ref_count), so they are returned with
+// the node's metadata and ranking queries read them instead of counting edges.
+const (
+	MetaCallerCount   = "caller_count"   // Function: distinct functions calling it
+	MetaAccessorCount = "accessor_count" // Field: distinct functions accessing it
+	MetaSubclassCount = "subclass_count" // Class: classes inheriting from it
+	MetaImporterCount = "importer_count" // FileScope: files of other directories importing its package
+	MetaRefCount      = "ref_count"      // all of them: the references ranking layers sort by
+)
+
+// Node properties holding the popularity counts read by ranking queries
+const (
+	PropRefCount    = metadataPrefix + MetaRefCount
+	PropCallerCount = metadataPrefix + MetaCallerCount
+)
+
+// popularityWriteBatch is the number of nodes updated per UNWIND query
+const popularityWriteBatch = 1000
+
+// latestFilesQuery binds fileId to the latest version of each file of $repo
+const latestFilesQuery = `
+	MATCH (fs:FileScope {repo: $repo})
+	WITH fs.path AS path, max(fs.id) AS fileId
+`
+
+// PopularityStats counts the nodes ComputePopularity updated
+type PopularityStats struct {
+	Functions int
+	Classes   int
+	Fields    int
+	Files     int
+}
+
+// popularityCount is the count of one node, written as the named metadata key and as
+// MetaRefCount
+type popularityCount struct {
+	id    int64
+	count int64 // written as the label's metadata key
+	refs  int64 // written as MetaRefCount
+}
+
+// ComputePopularity counts the incoming references of the nodes of the latest version of each
+// file of a repository and stores them as node properties (see MetaRefCount):
+//   - functions: distinct calling functions; ref_count is the number of call sites
+//   - classes: subclasses; ref_count adds the call sites of their methods
+//   - fields: distinct accessing functions; ref_count is the number of accesses
+//   - files: files of other directories whose import path names the file's directory (Go
+//     packages); ref_count is the same count
+//
+// Only references from the latest file versions count. It runs once calls are resolved, at
+// the end of a build.
+func (cg *CodeGraph) ComputePopularity(ctx context.Context, repoName string) (PopularityStats, error) {
+	var stats PopularityStats
+	params := map[string]any{"repo": repoName}
+
+	records, err := cg.db.ExecuteRead(ctx, latestFilesQuery+`
+		WITH collect(fileId) AS latest
+		UNWIND latest AS fileId
+		MATCH (f:Function {fileId: fileId})
+		OPTIONAL MATCH (call:FunctionCall)-[:CALLS_FUNCTION]->(f)
+		WHERE call.fileId IN latest
+		OPTIONAL MATCH (caller:Function)-[:CONTAINS*]->(call)
+		RETURN f.id AS id, count(DISTINCT caller) AS callers, count(DISTINCT call) AS calls
+	`, params)
+	if err != nil {
+		return stats, fmt.Errorf("failed to count function callers: %w", err)
+	}
+	functions := make([]popularityCount, len(records))
+	calls := make(map[int64]int64, len(records))
+	for i, record := range records {
+		id := cg.convertToInt64(record["id"])
+		functions[i] = popularityCount{id: id, count: cg.convertToInt64(record["callers"]), refs: cg.convertToInt64(record["calls"])}
+		calls[id] = functions[i].refs
+	}
+
+	records, err = cg.db.ExecuteRead(ctx, latestFilesQuery+`
+		WITH collect(fileId) AS latest
+		UNWIND latest AS fileId
+		MATCH (c:Class {fileId: fileId})
+		OPTIONAL MATCH (child:Class)-[:INHERITS]->(c)
+		WHERE child.fileId IN latest
+		WITH c, count(DISTINCT child) AS subclasses
+		OPTIONAL MATCH (c)-[:CONTAINS]->(m:Function)
+		RETURN c.id AS id, subclasses, collect(m.id) AS methods
+	`, params)
+	if err != nil {
+		return stats, fmt.Errorf("failed to count subclasses: %w", err)
+	}
+	classes := make([]popularityCount, len(records))
+	for i, record := range records {
+		subclasses := cg.convertToInt64(record["subclasses"])
+		refs := subclasses
+		methods, _ := record["methods"].([]any)
+		for _, m := range methods {
+			refs += calls[cg.convertToInt64(m)]
+		}
+		classes[i] = popularityCount{id: cg.convertToInt64(record["id"]), count: subclasses, refs: refs}
+	}
+
+	records, err = cg.db.ExecuteRead(ctx, latestFilesQuery+`
+		WITH collect(fileId) AS latest
+		UNWIND latest AS fileId
+		MATCH (f:Field {fileId: fileId})
+		OPTIONAL MATCH (accessor)-[:HAS_FIELD]->(f)
+		WHERE accessor.fileId IN latest
+		OPTIONAL MATCH (m:Function)-[:CONTAINS*]->(accessor)
+		RETURN f.id AS id, count(DISTINCT m) AS accessors, count(DISTINCT accessor) AS accesses
+	`, params)
+	if err != nil {
+		return stats, fmt.Errorf("failed to count field accessors: %w", err)
+	}
+	fields := make([]popularityCount, len(records))
+	for i, record := range records {
+		fields[i] = popularityCount{
+			id:    cg.convertToInt64(record["id"]),
+			count: cg.convertToInt64(record["accessors"]),
+			refs:  cg.convertToInt64(record["accesses"]),
+		}
+	}
+
+	files, err := cg.importerCounts(ctx, params)
+	if err != nil {
+		return stats, err
+	}
+
+	for _, w := range []struct {
+		label  string
+		key    string
+		counts []popularityCount
+	}{
+		{"Function", MetaCallerCount, functions},
+		{"Class", MetaSubclassCount, classes},
+		{"Field", MetaAccessorCount, fields},
+		{"FileScope", MetaImporterCount, files},
+	} {
+		if err := cg.writePopularity(ctx, w.label, w.key, w.counts); err != nil {
+			return stats, err
+		}
+	}
+	stats = PopularityStats{Functions: len(functions), Classes: len(classes), Fields: len(fields), Files: len(files)}
+	cg.log(ctx).Debug("Computed popularity",
+		zap.String("repo", repoName),
+		zap.Int("functions", stats.Functions),
+		zap.Int("classes", stats.Classes),
+		zap.Int("fields", stats.Fields),
+		zap.Int("files", stats.Files))
+	return stats, nil
+}
+
+// importerCounts counts, for each latest file, the latest files of other directories with
+// an import whose path ends with the file's directory
+func (cg *CodeGraph) importerCounts(ctx context.Context, params map[string]any) ([]popularityCount, error) {
+	records, err := cg.db.ExecuteRead(ctx, latestFilesQuery+`
+		OPTIONAL MATCH (i:Import {fileId: fileId})
+		RETURN fileId, path, collect(i.md_importPath) AS imports
+	`, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read imports: %w", err)
+	}
+	files := make([]importingFile, len(records))
+	for i, record := range records {
+		p, _ := record["path"].(string)
+		files[i] = importingFile{fileID: cg.convertToInt64(record["fileId"]), path: p}
+		imports, _ := record["imports"].([]any)
+		for _, imp := range imports {
+			if s, ok := imp.(string); ok && s != "" {
+				files[i].imports = append(files[i].imports, s)
+			}
+		}
+	}
+	return countImporters(files), nil
+}
+
+// importingFile is a file with the import paths it declares
+type importingFile struct {
+	fileID  int64
+	path    string
+	imports []string
+}
+
+// countImporters returns the number of files importing the directory of each file. An import
+// path names a directory when it equals it or ends with "/" and it, e.g. "bot-go/internal/util"
+// names internal/util. Files of the root directory cannot be imported this way.
+func countImporters(files []importingFile) []popularityCount {
+	importers := make(map[string]map[int64]bool) // directory -> importing files
+	dirs := make(map[string]bool)
+	for _, f := range files {
+		dirs[path.Dir(f.path)] = true
+	}
+	for _, f := range files {
+		own := path.Dir(f.path)
+		for _, imp := range f.imports {
+			// Try the import path and each of its suffixes after a "/"
+			for suffix := imp; ; {
+				if dirs[suffix] && suffix != own && suffix != "." {
+					if importers[suffix] == nil {
+						importers[suffix] = make(map[int64]bool)
+					}
+					importers[suffix][f.fileID] = true
+				}
+				i := strings.IndexByte(suffix, '/')
+				if i < 0 {
+					break
+				}
+				suffix = suffix[i+1:]
+			}
+		}
+	}
+	counts := make([]popularityCount, len(files))
+	for i, f := range files {
+		n := int64(len(importers[path.Dir(f.path)]))
+		counts[i] = popularityCount{id: f.fileID, count: n, refs: n}
+	}
+	return counts
+}
+
+// writePopularity stores the counts of nodes of one label as key and MetaRefCount
+func (cg *CodeGraph) writePopularity(ctx context.Context, label, key string, counts []popularityCount) error {
+	query := fmt.Sprintf(`
+		UNWIND $rows AS row
+		MATCH (n:%s {id: row.id})
+		SET n.%s = row.count, n.%s = row.refs
+	`, label, metadataPrefix+key, PropRefCount)
+	for start := 0; start < len(counts); start += popularityWriteBatch {
+		end := min(start+popularityWriteBatch, len(counts))
+		rows := make([]map[string]any, 0, end-start)
+		for _, c := range counts[start:end] {
+			rows = append(rows, map[string]any{"id": c.id, "count": c.count, "refs": c.refs})
+		}
+		if _, err := cg.db.ExecuteWrite(ctx, query, map[string]any{"rows": rows}); err != nil {
+			return fmt.Errorf("failed to store %s popularity: %w", label, err)
+		}
+	}
+	return nil
+}
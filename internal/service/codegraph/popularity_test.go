@@ -0,0 +1,20 @@
+package codegraph
+
+import "testing"
+
+func TestCountImporters(t *testing.T) {
+	files := []importingFile{
+		{fileID: 1, path: "main.go", imports: []string{"bot-go/internal/util", "bot-go/internal/config", "fmt"}},
+		{fileID: 2, path: "internal/util/git.go", imports: []string{"os/exec"}},
+		{fileID: 3, path: "internal/util/utils.go"},
+		{fileID: 4, path: "internal/config/config.go", imports: []string{"bot-go/internal/util", "bot-go/internal/util/sub"}},
+		{fileID: 5, path: "internal/config/config_test.go", imports: []string{"bot-go/internal/config"}},
+		{fileID: 6, path: "cmd/tool/main.go", imports: []string{"bot-go/internal/util", "bot-go/internal/util"}},
+	}
+	want := map[int64]int64{1: 0, 2: 3, 3: 3, 4: 1, 5: 1, 6: 0}
+	for _, count := range countImporters(files) {
+		if count.count != want[count.id] || count.refs != count.count {
+			t.Errorf("file %d: %d importers (ref_count %d), want %d", count.id, count.count, count.refs, want[count.id])
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package codegraph
+
+import (
+	"context"
+	"strings"
+)
+
+// MetadataFields selects which metadata keys node reads return, so large values such as
+// docstrings and signatures are only carried when a client asks for them. First-class
+// metadata (repo, path, language, ...) is always kept since graph code relies on it.
+type MetadataFields struct {
+	keys map[string]bool // nil keeps every key
+}
+
+// ParseMetadataFields parses a comma-separated projection: "" or "*" keeps every key, "none"
+// keeps only first-class metadata, anything else lists the keys to keep
+func ParseMetadataFields(spec string) MetadataFields {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || spec == "*" {
+		return MetadataFields{}
+	}
+	keys := make(map[string]bool)
+	if spec == "none" {
+		return MetadataFields{keys: keys}
+	}
+	for _, key := range strings.Split(spec, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys[key] = true
+		}
+	}
+	return MetadataFields{keys: keys}
+}
+
+// All reports whether every metadata key is kept
+func (f MetadataFields) All() bool {
+	return f.keys == nil
+}
+
+// Keeps reports whether a metadata key is part of the projection
+func (f MetadataFields) Keeps(key string) bool {
+	return f.keys == nil || f.keys[key] || FirstClassMetadata[key]
+}
+
+type metadataFieldsKey struct{}
+
+// WithMetadataFields returns a context under which node reads apply the projection
+func WithMetadataFields(ctx context.Context, fields MetadataFields) context.Context {
+	return context.WithValue(ctx, metadataFieldsKey{}, fields)
+}
+
+// metadataFieldsFrom returns the projection carried by ctx, keeping every key if there is none
+func metadataFieldsFrom(ctx context.Context) MetadataFields {
+	if ctx == nil {
+		return MetadataFields{}
+	}
+	fields, _ := ctx.Value(metadataFieldsKey{}).(MetadataFields)
+	return fields
+}
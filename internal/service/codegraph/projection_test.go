@@ -0,0 +1,48 @@
+package codegraph
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRecordToNodeMetadataProjection(t *testing.T) {
+	cg := &CodeGraph{}
+	record := map[string]any{
+		"id":           int64(7),
+		"nodeType":     int64(7),
+		"fileId":       int64(3),
+		"name":         "hello",
+		"path":         "src/hello.go",
+		"md_docstring": "Says hello",
+		"md_signature": "func hello()",
+		"md_ast_hash":  "abc",
+		"version":      int64(1),
+		"scopeId":      int64(0),
+	}
+
+	cases := map[string][]string{
+		"":                   {"path", "docstring", "signature", "ast_hash"},
+		"*":                  {"path", "docstring", "signature", "ast_hash"},
+		"none":               {"path"},
+		"docstring, unknown": {"path", "docstring"},
+	}
+	for spec, want := range cases {
+		ctx := context.Background()
+		if spec != "" {
+			ctx = WithMetadataFields(ctx, ParseMetadataFields(spec))
+		}
+		node, err := cg.recordToNode(ctx, record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(node.MetaData) != len(want) {
+			t.Errorf("fields=%q: got metadata %v, want keys %v", spec, node.MetaData, want)
+			continue
+		}
+		for _, key := range want {
+			if _, ok := node.MetaData[key]; !ok {
+				t.Errorf("fields=%q: missing key %q in %v", spec, key, node.MetaData)
+			}
+		}
+	}
+}
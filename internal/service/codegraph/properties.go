@@ -0,0 +1,125 @@
+package codegraph
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"bot-go/internal/apperrors"
+)
+
+// metadataPrefix prefixes the property name of every metadata key that is not first class
+const metadataPrefix = "md_"
+
+// reservedProperties are the properties the graph writes itself. Metadata cannot replace them.
+var reservedProperties = map[string]bool{
+	"id": true, "nodeType": true, "fileId": true, "name": true, "version": true, "scopeId": true,
+	PropStartLine: true, PropStartChar: true, PropEndLine: true, PropEndChar: true,
+	PropNodeKey: true, "parentId": true, "childId": true,
+}
+
+// sanitizePropertyKey turns a metadata key into a valid unquoted Cypher identifier, since keys
+// are spliced into SET clauses. Characters other than letters, digits and underscores become
+// underscores.
+func sanitizePropertyKey(key string) (string, error) {
+	if key == "" {
+		return "", fmt.Errorf("%w: empty metadata key", apperrors.ErrInvalidArgument)
+	}
+	var b strings.Builder
+	for i, r := range key {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String(), nil
+}
+
+// propertyValue converts a metadata value into one Neo4j can store. Scalars, times and lists of
+// a single scalar type are stored as they are, named scalar types as their underlying type.
+// Maps, mixed lists and structs are stored as JSON strings, which come back as strings when
+// the node is read.
+func propertyValue(key string, value any) (any, error) {
+	switch v := value.(type) {
+	case nil, bool, string, int, int8, int16, int32, int64, uint8, uint16, uint32,
+		float32, float64, time.Time, []byte, []string, []bool, []int, []int64, []float64:
+		return v, nil
+	case uint:
+		return int64(v), nil
+	case uint64:
+		return int64(v), nil
+	case []any:
+		if homogeneousScalars(v) {
+			return v, nil
+		}
+	}
+
+	// Named scalar types such as ast.NodeID are stored as their underlying type
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Bool:
+		return rv.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct, reflect.Pointer:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: metadata %q cannot be stored: %v", apperrors.ErrInvalidArgument, key, err)
+		}
+		return string(data), nil
+	}
+	return nil, fmt.Errorf("%w: metadata %q has unsupported type %T", apperrors.ErrInvalidArgument, key, value)
+}
+
+// homogeneousScalars reports whether every element of list is a scalar of the same type
+func homogeneousScalars(list []any) bool {
+	var first reflect.Type
+	for _, item := range list {
+		switch item.(type) {
+		case bool, string, int, int32, int64, float64:
+		default:
+			return false
+		}
+		t := reflect.TypeOf(item)
+		if first == nil {
+			first = t
+		} else if t != first {
+			return false
+		}
+	}
+	return true
+}
+
+// setProperty validates a property and adds it to params, refusing to replace a property
+// that is reserved or already set
+func setProperty(params map[string]any, property, key string, value any) error {
+	if reservedProperties[property] {
+		return fmt.Errorf("%w: metadata %q would overwrite the reserved property %q",
+			apperrors.ErrInvalidArgument, key, property)
+	}
+	if _, ok := params[property]; ok {
+		return fmt.Errorf("%w: metadata %q collides with property %q",
+			apperrors.ErrInvalidArgument, key, property)
+	}
+	converted, err := propertyValue(key, value)
+	if err != nil {
+		return err
+	}
+	params[property] = converted
+	return nil
+}
@@ -0,0 +1,62 @@
+package codegraph
+
+import (
+	"errors"
+	"testing"
+
+	"bot-go/internal/apperrors"
+	"bot-go/internal/model/ast"
+)
+
+func TestFlattenMetadata(t *testing.T) {
+	cg := &CodeGraph{}
+
+	params := map[string]any{}
+	err := cg.flattenMetadata(map[string]any{
+		"docstring":   "Says hello",
+		"data-flow":   true,
+		"2fa":         1,
+		"params":      []string{"a", "b"},
+		"mixed":       []any{"a", 1},
+		"annotations": map[string]any{"deprecated": true},
+		"condition":   ast.NodeID(4294967304),
+	}, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"md_docstring":   "Says hello",
+		"md_data_flow":   true,
+		"md__2fa":        1,
+		"md_mixed":       `["a",1]`,
+		"md_annotations": `{"deprecated":true}`,
+		"md_condition":   int64(4294967304),
+	}
+	for key, value := range want {
+		if params[key] != value {
+			t.Errorf("%s = %#v, want %#v", key, params[key], value)
+		}
+	}
+	if list, _ := params["md_params"].([]string); len(list) != 2 {
+		t.Errorf("md_params = %#v, want the list unchanged", params["md_params"])
+	}
+
+	// Keys that sanitize to the same property are rejected rather than overwritten
+	err = cg.flattenMetadata(map[string]any{"a-b": 1, "a.b": 2}, map[string]any{})
+	if !errors.Is(err, apperrors.ErrInvalidArgument) {
+		t.Errorf("colliding keys: err = %v, want invalid argument", err)
+	}
+
+	// First-class metadata cannot replace a reserved property
+	params = map[string]any{"id": int64(1)}
+	FirstClassMetadata["id"] = true
+	defer delete(FirstClassMetadata, "id")
+	err = cg.populateFirstClassMetadata(map[string]any{"id": 2}, params, map[string]any{})
+	if !errors.Is(err, apperrors.ErrInvalidArgument) || params["id"] != int64(1) {
+		t.Errorf("reserved property: err = %v, id = %v", err, params["id"])
+	}
+
+	if _, err := propertyValue("callback", func() {}); !errors.Is(err, apperrors.ErrInvalidArgument) {
+		t.Errorf("func value: err = %v, want invalid argument", err)
+	}
+}
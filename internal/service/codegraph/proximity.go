@@ -0,0 +1,73 @@
+package codegraph
+
+import (
+	"context"
+	"fmt"
+
+	"bot-go/internal/model/ast"
+)
+
+// MaxProximityHops bounds the paths GraphDistances searches
+const MaxProximityHops = 6
+
+// ProximityAnchor locates the graph node of a search result: the function or class of a file
+// version starting on a line, or the file itself
+type ProximityAnchor struct {
+	FileID    int32
+	StartLine int  // 0-based line the function or class starts on
+	WholeFile bool // anchor on the file's FileScope instead
+}
+
+// GraphDistances returns, per anchor index, the length of the shortest path of CONTAINS and
+// CALLS_FUNCTION edges (in either direction) from the anchor's node to any of the nodes in
+// from, up to MaxProximityHops. Anchors without a node or without a path within the bound are
+// left out; an anchor whose node is in from has distance 0. A call from one function to
+// another is three hops (function, call site, callee), two functions of a file four.
+func (cg *CodeGraph) GraphDistances(ctx context.Context, anchors []ProximityAnchor, from []int64) (map[int]int, error) {
+	distances := make(map[int]int)
+	if len(anchors) == 0 || len(from) == 0 {
+		return distances, nil
+	}
+	targets := make([]map[string]any, 0, len(anchors))
+	for i, anchor := range anchors {
+		if anchor.FileID == 0 {
+			continue
+		}
+		targets = append(targets, map[string]any{
+			"index":     int64(i),
+			"fileId":    int64(anchor.FileID),
+			"startLine": int64(anchor.StartLine),
+			"wholeFile": anchor.WholeFile,
+		})
+	}
+
+	// shortestPath refuses identical end nodes, so anchors in from are matched separately
+	records, err := cg.db.ExecuteRead(ctx, fmt.Sprintf(`
+		UNWIND $targets AS target
+		MATCH (n {fileId: target.fileId})
+		WHERE (target.wholeFile AND n.id = target.fileId)
+		   OR (NOT target.wholeFile AND n.startLine = target.startLine AND n.nodeType IN $nodeTypes)
+		CALL {
+			WITH n
+			WITH n WHERE n.id IN $from
+			RETURN 0 AS distance
+			UNION
+			WITH n
+			MATCH (s) WHERE s.id IN $from AND s <> n
+			MATCH p = shortestPath((n)-[:CONTAINS|CALLS_FUNCTION*..%d]-(s))
+			RETURN length(p) AS distance
+		}
+		RETURN target.index AS index, min(distance) AS distance
+	`, MaxProximityHops), map[string]any{
+		"targets":   targets,
+		"from":      from,
+		"nodeTypes": []int64{int64(ast.NodeTypeFunction), int64(ast.NodeTypeClass)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute graph distances: %w", err)
+	}
+	for _, record := range records {
+		distances[int(cg.convertToInt64(record["index"]))] = int(cg.convertToInt64(record["distance"]))
+	}
+	return distances, nil
+}
@@ -0,0 +1,196 @@
+package codegraph
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// PlanMode selects how query plans are captured
+type PlanMode int
+
+const (
+	PlanExplain PlanMode = iota + 1 // EXPLAIN: the planned operators and row estimates, nothing is executed
+	PlanProfile                     // PROFILE: runs the query again and reports rows and db hits per operator
+)
+
+// maxCapturedQueries bounds the distinct queries a capture plans; traversals run a handful
+// of queries many times, so later distinct queries are only counted
+const maxCapturedQueries = 20
+
+// QueryPlan is one operator of a Cypher execution plan
+type QueryPlan struct {
+	Operator      string       `json:"operator"`
+	Details       string       `json:"details,omitempty"`
+	Identifiers   []string     `json:"identifiers,omitempty"`
+	EstimatedRows float64      `json:"estimated_rows,omitempty"`
+	Rows          int64        `json:"rows,omitempty"`    // PROFILE only
+	DbHits        int64        `json:"db_hits,omitempty"` // PROFILE only
+	Children      []*QueryPlan `json:"children,omitempty"`
+}
+
+// CapturedPlan is the plan of one distinct query run while a capture was active
+type CapturedPlan struct {
+	Query      string     `json:"query"`
+	Executions int        `json:"executions"` // times the query ran during the request
+	Plan       *QueryPlan `json:"plan,omitempty"`
+	// IndexOperators are the operators that read through an index, e.g. NodeIndexSeek
+	IndexOperators []string `json:"index_operators,omitempty"`
+	// Scans are the operators that read every node (of a label), usually a missing index
+	Scans       []string `json:"scans,omitempty"`
+	TotalDbHits int64    `json:"total_db_hits,omitempty"` // PROFILE only
+	Error       string   `json:"error,omitempty"`         // why the plan could not be read
+}
+
+// PlanCapture collects the plans of the read queries run under a context from
+// WithPlanCapture. It is safe for concurrent use.
+type PlanCapture struct {
+	mode    PlanMode
+	mu      sync.Mutex
+	byQuery map[string]*CapturedPlan
+	order   []*CapturedPlan
+	skipped int
+}
+
+type planCaptureKey struct{}
+
+// WithPlanCapture returns a context under which Neo4j reads also capture their plan in the
+// returned PlanCapture
+func WithPlanCapture(ctx context.Context, mode PlanMode) (context.Context, *PlanCapture) {
+	capture := &PlanCapture{mode: mode, byQuery: make(map[string]*CapturedPlan)}
+	return context.WithValue(ctx, planCaptureKey{}, capture), capture
+}
+
+// PlanCaptureFrom returns the capture carried by ctx, or nil
+func PlanCaptureFrom(ctx context.Context) *PlanCapture {
+	if ctx == nil {
+		return nil
+	}
+	capture, _ := ctx.Value(planCaptureKey{}).(*PlanCapture)
+	return capture
+}
+
+// Mode returns how plans are captured
+func (pc *PlanCapture) Mode() PlanMode {
+	return pc.mode
+}
+
+// Plans returns the captured plans in the order their queries first ran
+func (pc *PlanCapture) Plans() []*CapturedPlan {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	return append([]*CapturedPlan(nil), pc.order...)
+}
+
+// Skipped returns the number of distinct queries past maxCapturedQueries that were not planned
+func (pc *PlanCapture) Skipped() int {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	return pc.skipped
+}
+
+// begin counts an execution of query and returns the entry to fill when its plan has not
+// been captured yet, or nil
+func (pc *PlanCapture) begin(query string) *CapturedPlan {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if captured, ok := pc.byQuery[query]; ok {
+		captured.Executions++
+		return nil
+	}
+	if len(pc.order) >= maxCapturedQueries {
+		pc.skipped++
+		return nil
+	}
+	captured := &CapturedPlan{Query: strings.TrimSpace(query), Executions: 1}
+	pc.byQuery[query] = captured
+	pc.order = append(pc.order, captured)
+	return captured
+}
+
+// finish stores the plan read for a captured query
+func (pc *PlanCapture) finish(captured *CapturedPlan, plan *QueryPlan, err error) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if err != nil {
+		captured.Error = err.Error()
+		return
+	}
+	captured.Plan = plan
+	indexes := make(map[string]bool)
+	var walk func(p *QueryPlan)
+	walk = func(p *QueryPlan) {
+		op := p.Operator
+		switch {
+		case strings.Contains(op, "Index"):
+			indexes[op] = true
+		case strings.HasPrefix(op, "AllNodesScan"), strings.HasPrefix(op, "NodeByLabelScan"):
+			scan := op
+			if p.Details != "" {
+				scan += " " + p.Details
+			}
+			captured.Scans = append(captured.Scans, scan)
+		}
+		captured.TotalDbHits += p.DbHits
+		for _, child := range p.Children {
+			walk(child)
+		}
+	}
+	walk(plan)
+	for op := range indexes {
+		captured.IndexOperators = append(captured.IndexOperators, op)
+	}
+	sort.Strings(captured.IndexOperators)
+}
+
+// planPrefix returns the Cypher keyword that plans a query in mode
+func planPrefix(mode PlanMode) string {
+	if mode == PlanProfile {
+		return "PROFILE "
+	}
+	return "EXPLAIN "
+}
+
+// convertPlan converts an EXPLAIN plan from the driver
+func convertPlan(plan neo4j.Plan) *QueryPlan {
+	if plan == nil {
+		return nil
+	}
+	p := newQueryPlan(plan.Operator(), plan.Arguments(), plan.Identifiers())
+	for _, child := range plan.Children() {
+		p.Children = append(p.Children, convertPlan(child))
+	}
+	return p
+}
+
+// convertProfile converts a PROFILE plan from the driver
+func convertProfile(plan neo4j.ProfiledPlan) *QueryPlan {
+	if plan == nil {
+		return nil
+	}
+	p := newQueryPlan(plan.Operator(), plan.Arguments(), plan.Identifiers())
+	p.Rows = plan.Records()
+	p.DbHits = plan.DbHits()
+	for _, child := range plan.Children() {
+		p.Children = append(p.Children, convertProfile(child))
+	}
+	return p
+}
+
+func newQueryPlan(operator string, args map[string]any, identifiers []string) *QueryPlan {
+	// Operators carry the database they ran on, e.g. "NodeIndexSeek@neo4j"
+	if i := strings.IndexByte(operator, '@'); i > 0 {
+		operator = operator[:i]
+	}
+	p := &QueryPlan{Operator: operator, Identifiers: identifiers}
+	if details, ok := args["Details"].(string); ok {
+		p.Details = details
+	}
+	if rows, ok := args["EstimatedRows"].(float64); ok {
+		p.EstimatedRows = rows
+	}
+	return p
+}
@@ -0,0 +1,58 @@
+package codegraph
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestPlanCaptureSummarizesPlans(t *testing.T) {
+	ctx, capture := WithPlanCapture(context.Background(), PlanProfile)
+	if PlanCaptureFrom(ctx) != capture {
+		t.Fatal("capture not carried by the context")
+	}
+
+	captured := capture.begin("MATCH (f:Function {id: $id}) RETURN f")
+	if capture.begin("MATCH (f:Function {id: $id}) RETURN f") != nil {
+		t.Error("expected a repeated query to be counted, not planned again")
+	}
+	capture.finish(captured, &QueryPlan{
+		Operator: "ProduceResults",
+		DbHits:   1,
+		Children: []*QueryPlan{
+			{Operator: "Expand(All)", DbHits: 4, Children: []*QueryPlan{
+				{Operator: "NodeIndexSeek", DbHits: 2},
+				{Operator: "NodeByLabelScan", Details: "c:Class", DbHits: 100},
+			}},
+		},
+	}, nil)
+
+	plans := capture.Plans()
+	if len(plans) != 1 {
+		t.Fatalf("got %d plans, want 1", len(plans))
+	}
+	got := plans[0]
+	if got.Executions != 2 {
+		t.Errorf("executions = %d, want 2", got.Executions)
+	}
+	if !reflect.DeepEqual(got.IndexOperators, []string{"NodeIndexSeek"}) {
+		t.Errorf("index operators = %v", got.IndexOperators)
+	}
+	if !reflect.DeepEqual(got.Scans, []string{"NodeByLabelScan c:Class"}) {
+		t.Errorf("scans = %v", got.Scans)
+	}
+	if got.TotalDbHits != 107 {
+		t.Errorf("total db hits = %d, want 107", got.TotalDbHits)
+	}
+}
+
+func TestPlanCaptureBoundsDistinctQueries(t *testing.T) {
+	_, capture := WithPlanCapture(context.Background(), PlanExplain)
+	for i := 0; i < maxCapturedQueries+3; i++ {
+		capture.begin(fmt.Sprintf("RETURN %d", i))
+	}
+	if len(capture.Plans()) != maxCapturedQueries || capture.Skipped() != 3 {
+		t.Errorf("got %d plans and %d skipped, want %d and 3", len(capture.Plans()), capture.Skipped(), maxCapturedQueries)
+	}
+}
@@ -0,0 +1,182 @@
+package codegraph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"bot-go/internal/apperrors"
+
+	"go.uber.org/zap"
+)
+
+// defaultReplicaHealthInterval is how often unhealthy read replicas are probed when the
+// configuration does not say
+const defaultReplicaHealthInterval = 30 * time.Second
+
+type leaderReadsKey struct{}
+
+// WithLeaderReads returns a context whose reads go to the leader. Index builds use it so
+// that they read their own writes instead of a replica that may lag behind.
+func WithLeaderReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, leaderReadsKey{}, true)
+}
+
+func leaderReads(ctx context.Context) bool {
+	v, _ := ctx.Value(leaderReadsKey{}).(bool)
+	return v
+}
+
+// readReplica is a read endpoint and its last known health
+type readReplica struct {
+	name    string
+	db      GraphDatabase
+	healthy atomic.Bool
+}
+
+// RoutedDatabase implements GraphDatabase over a leader and read replicas. Writes go to the
+// leader; reads are spread round-robin over the healthy replicas and fall back to the leader
+// when none is healthy. A replica is marked unhealthy when a read fails to reach it and is
+// restored by a background health check.
+type RoutedDatabase struct {
+	leader   GraphDatabase
+	replicas []*readReplica
+	next     atomic.Uint64
+	logger   *zap.Logger
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewRoutedDatabase routes between leader and the given replicas, keyed by a name for logs
+// (e.g. the URI). Replicas are probed every healthInterval (0 = 30s); they start healthy
+// if they answer the first probe.
+func NewRoutedDatabase(leader GraphDatabase, replicas map[string]GraphDatabase, healthInterval time.Duration, logger *zap.Logger) *RoutedDatabase {
+	if healthInterval <= 0 {
+		healthInterval = defaultReplicaHealthInterval
+	}
+	db := &RoutedDatabase{
+		leader: leader,
+		logger: logger,
+		stop:   make(chan struct{}),
+	}
+	names := make([]string, 0, len(replicas))
+	for name := range replicas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		db.replicas = append(db.replicas, &readReplica{name: name, db: replicas[name]})
+	}
+	db.checkReplicas(context.Background())
+
+	db.wg.Add(1)
+	go func() {
+		defer db.wg.Done()
+		ticker := time.NewTicker(healthInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				db.checkReplicas(context.Background())
+			case <-db.stop:
+				return
+			}
+		}
+	}()
+	return db
+}
+
+// checkReplicas probes every replica and updates its health
+func (db *RoutedDatabase) checkReplicas(ctx context.Context) {
+	for _, r := range db.replicas {
+		err := r.db.VerifyConnectivity(ctx)
+		healthy := err == nil
+		if r.healthy.Swap(healthy) != healthy {
+			if healthy {
+				db.logger.Info("Read replica is healthy", zap.String("replica", r.name))
+			} else {
+				db.logger.Warn("Read replica is unhealthy", zap.String("replica", r.name), zap.Error(err))
+			}
+		}
+	}
+}
+
+// VerifyConnectivity checks the leader; replicas are optional for serving
+func (db *RoutedDatabase) VerifyConnectivity(ctx context.Context) error {
+	return db.leader.VerifyConnectivity(ctx)
+}
+
+// Close stops the health checks and closes the leader and every replica
+func (db *RoutedDatabase) Close(ctx context.Context) error {
+	db.stopOnce.Do(func() { close(db.stop) })
+	db.wg.Wait()
+
+	var errs []error
+	for _, r := range db.replicas {
+		if err := r.db.Close(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("replica %s: %w", r.name, err))
+		}
+	}
+	if err := db.leader.Close(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// ExecuteRead runs a read on a healthy replica, trying the next one when a replica is
+// unreachable, and on the leader when none is left or ctx asks for leader reads
+func (db *RoutedDatabase) ExecuteRead(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	if !leaderReads(ctx) && len(db.replicas) > 0 {
+		start := int(db.next.Add(1) % uint64(len(db.replicas)))
+		for i := range db.replicas {
+			r := db.replicas[(start+i)%len(db.replicas)]
+			if !r.healthy.Load() {
+				continue
+			}
+			records, err := r.db.ExecuteRead(ctx, query, params)
+			if err == nil || !errors.Is(err, apperrors.ErrBackendUnavailable) {
+				return records, err
+			}
+			if r.healthy.Swap(false) {
+				db.logger.Warn("Read replica is unreachable, failing over", zap.String("replica", r.name), zap.Error(err))
+			}
+		}
+	}
+	return db.leader.ExecuteRead(ctx, query, params)
+}
+
+// ExecuteWrite runs a write on the leader
+func (db *RoutedDatabase) ExecuteWrite(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	return db.leader.ExecuteWrite(ctx, query, params)
+}
+
+// ExecuteReadSingle executes a read-only Cypher query expecting a single record
+func (db *RoutedDatabase) ExecuteReadSingle(ctx context.Context, query string, params map[string]any) (map[string]any, error) {
+	records, err := db.ExecuteRead(ctx, query, params)
+	if err != nil {
+		return nil, err
+	}
+	return singleRecord(records)
+}
+
+// ExecuteWriteSingle executes a write Cypher query expecting a single record
+func (db *RoutedDatabase) ExecuteWriteSingle(ctx context.Context, query string, params map[string]any) (map[string]any, error) {
+	return db.leader.ExecuteWriteSingle(ctx, query, params)
+}
+
+// singleRecord returns the only record of a result
+func singleRecord(records []map[string]any) (map[string]any, error) {
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no records returned")
+	}
+	if len(records) > 1 {
+		return nil, fmt.Errorf("expected single record, got %d", len(records))
+	}
+	return records[0], nil
+}
@@ -0,0 +1,111 @@
+package codegraph
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"bot-go/internal/apperrors"
+
+	"go.uber.org/zap"
+)
+
+// fakeDatabase answers every read with its name, or fails with err, and records writes
+type fakeDatabase struct {
+	name    string
+	err     error
+	reads   int
+	writes  []map[string]any // parameters of each write
+	queries []string         // query of each write
+}
+
+func (f *fakeDatabase) ExecuteRead(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	f.reads++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return []map[string]any{{"db": f.name}}, nil
+}
+
+func (f *fakeDatabase) ExecuteWrite(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	f.writes = append(f.writes, params)
+	f.queries = append(f.queries, query)
+	return []map[string]any{{"db": f.name}}, nil
+}
+
+func (f *fakeDatabase) ExecuteReadSingle(ctx context.Context, query string, params map[string]any) (map[string]any, error) {
+	records, err := f.ExecuteRead(ctx, query, params)
+	if err != nil {
+		return nil, err
+	}
+	return singleRecord(records)
+}
+
+func (f *fakeDatabase) ExecuteWriteSingle(ctx context.Context, query string, params map[string]any) (map[string]any, error) {
+	return map[string]any{"db": f.name}, nil
+}
+
+func (f *fakeDatabase) Close(ctx context.Context) error { return nil }
+
+func (f *fakeDatabase) VerifyConnectivity(ctx context.Context) error { return f.err }
+
+func readFrom(t *testing.T, ctx context.Context, db GraphDatabase) string {
+	t.Helper()
+	record, err := db.ExecuteReadSingle(ctx, "RETURN 1", nil)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	return record["db"].(string)
+}
+
+func TestRoutedDatabase(t *testing.T) {
+	ctx := context.Background()
+	leader := &fakeDatabase{name: "leader"}
+	a := &fakeDatabase{name: "a"}
+	b := &fakeDatabase{name: "b"}
+	db := NewRoutedDatabase(leader, map[string]GraphDatabase{"a": a, "b": b}, time.Hour, zap.NewNop())
+	defer db.Close(ctx)
+
+	// Reads alternate between the replicas
+	first, second := readFrom(t, ctx, db), readFrom(t, ctx, db)
+	if first == second || first == "leader" || second == "leader" {
+		t.Fatalf("reads went to %s and %s, want both replicas", first, second)
+	}
+	if got := readFrom(t, WithLeaderReads(ctx), db); got != "leader" {
+		t.Errorf("leader read went to %s", got)
+	}
+	if record, _ := db.ExecuteWriteSingle(ctx, "CREATE ()", nil); record["db"] != "leader" {
+		t.Errorf("write went to %v", record["db"])
+	}
+
+	// An unreachable replica is skipped until a health check restores it
+	a.err = apperrors.Unavailable("neo4j", errors.New("connection refused"))
+	for i := 0; i < 3; i++ {
+		if got := readFrom(t, ctx, db); got != "b" {
+			t.Fatalf("read %d went to %s, want b", i, got)
+		}
+	}
+	if a.reads != 2 {
+		t.Errorf("unhealthy replica got %d reads, want 2", a.reads)
+	}
+
+	// Without a healthy replica reads fall back to the leader
+	b.err = a.err
+	if got := readFrom(t, ctx, db); got != "leader" {
+		t.Errorf("read went to %s, want leader", got)
+	}
+
+	a.err, b.err = nil, nil
+	db.checkReplicas(ctx)
+	if got := readFrom(t, ctx, db); got == "leader" {
+		t.Error("read went to the leader after the replicas recovered")
+	}
+
+	// Query errors are returned, not failed over
+	queryErr := errors.New("syntax error")
+	a.err, b.err = queryErr, queryErr
+	if _, err := db.ExecuteRead(ctx, "RETURN", nil); !errors.Is(err, queryErr) {
+		t.Errorf("err = %v, want the query error", err)
+	}
+}
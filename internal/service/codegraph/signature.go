@@ -0,0 +1,310 @@
+package codegraph
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"bot-go/internal/util"
+	"bot-go/pkg/lsp/base"
+)
+
+// Metadata keys of the declared signature of Function nodes, written by the parser
+const (
+	MetaSignature   = "signature"    // the declaration without its body, e.g. "func Load(ctx context.Context) error"
+	MetaParamTypes  = "param_types"  // declared parameter types in order; "" for untyped parameters
+	MetaParamCount  = "param_count"  // number of parameters, without Python's self and cls
+	MetaReturnTypes = "return_types" // declared result types in order; absent for none or undeclared
+)
+
+// SignatureRest ends a positional list of type patterns to match any further types
+const SignatureRest = "..."
+
+// MaxSignatureMatches bounds the functions returned by FindFunctionsBySignature
+const MaxSignatureMatches = 200
+
+// SignatureQuery describes the shape of the functions to find. Type patterns match a
+// declared type with whitespace removed; "*" matches any run of characters, and a pattern
+// without a "." also matches types by their unqualified name ("Context" matches
+// "context.Context").
+type SignatureQuery struct {
+	Params    []string // positional parameter type patterns; SignatureRest last allows more parameters
+	Takes     []string // type patterns each matched by a different parameter, in any position
+	Returns   []string // positional result type patterns; SignatureRest last allows more results
+	Void      bool     // only functions declaring no results
+	MinParams int
+	MaxParams int    // < 0 for no bound
+	Name      string // name pattern; "*" matches any run of characters
+	Limit     int
+}
+
+// SignatureMatch is a function matching a SignatureQuery
+type SignatureMatch struct {
+	FunctionID  int64      `json:"function_id"`
+	Name        string     `json:"name"`
+	Class       string     `json:"class,omitempty"`
+	FilePath    string     `json:"file_path"`
+	Range       base.Range `json:"range"`
+	Signature   string     `json:"signature"`
+	ParamTypes  []string   `json:"param_types"`
+	ReturnTypes []string   `json:"return_types"`
+	References  int64      `json:"references"` // see MetaRefCount
+}
+
+// FindFunctionsBySignature returns the named functions of the latest version of each file of a
+// repository whose declared signature matches the query, most referenced first. Functions
+// indexed before signatures were recorded have no signature metadata and never match.
+func (cg *CodeGraph) FindFunctionsBySignature(ctx context.Context, repoName string, query SignatureQuery) ([]SignatureMatch, error) {
+	minParams, maxParams := query.paramBounds()
+	records, err := cg.db.ExecuteRead(ctx, latestFilesQuery+`
+		MATCH (f:Function {fileId: fileId})
+		WHERE f.`+metadataPrefix+MetaParamCount+` >= $minParams
+		  AND ($maxParams < 0 OR f.`+metadataPrefix+MetaParamCount+` <= $maxParams)
+		  AND coalesce(f.md_anonymous, false) = false
+		OPTIONAL MATCH (c:Class)-[:CONTAINS]->(f)
+		RETURN f.id AS id, f.name AS name, c.name AS className, path,
+		       f {.startLine, .startChar, .endLine, .endChar, .range} AS range,
+		       f.`+metadataPrefix+MetaSignature+` AS signature,
+		       coalesce(f.`+metadataPrefix+MetaParamTypes+`, []) AS paramTypes,
+		       coalesce(f.`+metadataPrefix+MetaReturnTypes+`, []) AS returnTypes,
+		       coalesce(f.`+PropRefCount+`, 0) AS refs
+	`, map[string]any{"repo": repoName, "minParams": int64(minParams), "maxParams": int64(maxParams)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read function signatures: %w", err)
+	}
+
+	var matches []SignatureMatch
+	for _, record := range records {
+		if m := cg.signatureMatch(record); query.matches(m.Name, m.ParamTypes, m.ReturnTypes) {
+			matches = append(matches, m)
+		}
+	}
+	sortByReferences(matches)
+	return limitMatches(matches, query.Limit), nil
+}
+
+// SiblingSignatures returns the signatures of the named functions of the latest version of a
+// file, in order, or when it has none, e.g. because it is not indexed yet, those of the latest
+// files directly in its directory, most referenced first. It reports whether the functions
+// are the file's own.
+func (cg *CodeGraph) SiblingSignatures(ctx context.Context, repoName, filePath string, limit int) ([]SignatureMatch, bool, error) {
+	filePath = util.CanonicalPath(filePath)
+	prefix := ""
+	if dir := path.Dir(filePath); dir != "." {
+		prefix = dir + "/"
+	}
+	records, err := cg.db.ExecuteRead(ctx, latestFilesQuery+`
+		WHERE path = $path
+		   OR (path STARTS WITH $prefix AND NOT substring(path, size($prefix)) CONTAINS '/')
+		MATCH (f:Function {fileId: fileId})
+		WHERE f.`+metadataPrefix+MetaSignature+` IS NOT NULL
+		  AND coalesce(f.md_anonymous, false) = false
+		OPTIONAL MATCH (c:Class)-[:CONTAINS]->(f)
+		RETURN f.id AS id, f.name AS name, c.name AS className, path,
+		       f {.startLine, .startChar, .endLine, .endChar, .range} AS range,
+		       f.`+metadataPrefix+MetaSignature+` AS signature,
+		       coalesce(f.`+metadataPrefix+MetaParamTypes+`, []) AS paramTypes,
+		       coalesce(f.`+metadataPrefix+MetaReturnTypes+`, []) AS returnTypes,
+		       coalesce(f.`+PropRefCount+`, 0) AS refs
+	`, map[string]any{"repo": repoName, "path": filePath, "prefix": prefix})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read sibling signatures: %w", err)
+	}
+
+	var own, siblings []SignatureMatch
+	for _, record := range records {
+		m := cg.signatureMatch(record)
+		if m.FilePath == filePath {
+			own = append(own, m)
+		} else {
+			siblings = append(siblings, m)
+		}
+	}
+	if len(own) > 0 {
+		sort.SliceStable(own, func(i, j int) bool {
+			return own[i].Range.Start.Line < own[j].Range.Start.Line
+		})
+		return limitMatches(own, limit), true, nil
+	}
+	sortByReferences(siblings)
+	return limitMatches(siblings, limit), false, nil
+}
+
+// signatureMatch reads a function returned by a signature query
+func (cg *CodeGraph) signatureMatch(record map[string]any) SignatureMatch {
+	return SignatureMatch{
+		FunctionID:  cg.convertToInt64(record["id"]),
+		Name:        stringValue(record["name"]),
+		FilePath:    stringValue(record["path"]),
+		Class:       stringValue(record["className"]),
+		Range:       RangeFromValue(record["range"]),
+		Signature:   stringValue(record["signature"]),
+		ParamTypes:  stringList(record["paramTypes"]),
+		ReturnTypes: stringList(record["returnTypes"]),
+		References:  cg.convertToInt64(record["refs"]),
+	}
+}
+
+// sortByReferences orders functions most referenced first, then by position
+func sortByReferences(matches []SignatureMatch) {
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.References != b.References {
+			return a.References > b.References
+		}
+		if a.FilePath != b.FilePath {
+			return a.FilePath < b.FilePath
+		}
+		return a.Range.Start.Line < b.Range.Start.Line
+	})
+}
+
+// limitMatches truncates functions to limit, or to MaxSignatureMatches when limit is not
+// positive or above it
+func limitMatches(matches []SignatureMatch, limit int) []SignatureMatch {
+	if limit <= 0 || limit > MaxSignatureMatches {
+		limit = MaxSignatureMatches
+	}
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// paramBounds returns the parameter counts the query admits, narrowed by its patterns
+func (q SignatureQuery) paramBounds() (int, int) {
+	minParams, maxParams := q.MinParams, q.MaxParams
+	fixed, open := splitRest(q.Params)
+	if q.Params != nil {
+		minParams = max(minParams, len(fixed))
+		if !open && (maxParams < 0 || maxParams > len(fixed)) {
+			maxParams = len(fixed)
+		}
+	}
+	minParams = max(minParams, len(q.Takes))
+	return minParams, maxParams
+}
+
+// matches reports whether a function with the given name and declared types has the shape
+// of the query
+func (q SignatureQuery) matches(name string, paramTypes, returnTypes []string) bool {
+	if q.Name != "" && !globMatch(q.Name, name) {
+		return false
+	}
+	minParams, maxParams := q.paramBounds()
+	if len(paramTypes) < minParams || (maxParams >= 0 && len(paramTypes) > maxParams) {
+		return false
+	}
+	if q.Params != nil && !matchPositional(q.Params, paramTypes) {
+		return false
+	}
+	if !matchUnordered(q.Takes, paramTypes) {
+		return false
+	}
+	if q.Void && len(returnTypes) > 0 {
+		return false
+	}
+	return q.Returns == nil || matchPositional(q.Returns, returnTypes)
+}
+
+func splitRest(patterns []string) ([]string, bool) {
+	if n := len(patterns); n > 0 && patterns[n-1] == SignatureRest {
+		return patterns[:n-1], true
+	}
+	return patterns, false
+}
+
+// matchPositional matches each pattern with the type at its position
+func matchPositional(patterns, types []string) bool {
+	fixed, open := splitRest(patterns)
+	if len(types) < len(fixed) || (!open && len(types) != len(fixed)) {
+		return false
+	}
+	for i, pattern := range fixed {
+		if !typeMatches(pattern, types[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchUnordered matches each pattern with a different type. Patterns are assigned greedily
+// in order, trying the most specific ones first.
+func matchUnordered(patterns, types []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	ordered := append([]string(nil), patterns...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return strings.Count(ordered[i], "*") < strings.Count(ordered[j], "*")
+	})
+	used := make([]bool, len(types))
+	for _, pattern := range ordered {
+		found := false
+		for i, typ := range types {
+			if !used[i] && typeMatches(pattern, typ) {
+				used[i], found = true, true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// qualifier matches the package or module qualifiers of a type, e.g. "context." and "pkg."
+var qualifier = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*\.`)
+
+// typeMatches reports whether a type pattern matches a declared type (see SignatureQuery)
+func typeMatches(pattern, typ string) bool {
+	pattern, typ = stripSpace(pattern), stripSpace(typ)
+	if globMatch(pattern, typ) {
+		return true
+	}
+	return !strings.Contains(pattern, ".") && globMatch(pattern, qualifier.ReplaceAllString(typ, ""))
+}
+
+func stripSpace(s string) string {
+	return strings.Join(strings.Fields(s), "")
+}
+
+// globMatch matches s against a pattern in which "*" matches any run of characters
+func globMatch(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == s
+	}
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(s, part)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(part):]
+	}
+	return len(s) >= len(last) && strings.HasSuffix(s, last)
+}
+
+func stringValue(v any) string {
+	s, _ := v.(string)
+	return s
+}
+
+func stringList(v any) []string {
+	items, _ := v.([]any)
+	list := make([]string, 0, len(items))
+	for _, item := range items {
+		s, _ := item.(string)
+		list = append(list, s)
+	}
+	return list
+}
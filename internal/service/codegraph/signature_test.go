@@ -0,0 +1,86 @@
+package codegraph
+
+import "testing"
+
+func TestSignatureQueryMatches(t *testing.T) {
+	type function struct {
+		name    string
+		params  []string
+		returns []string
+	}
+	load := function{"Load", []string{"context.Context", "string"}, []string{"*Item", "error"}}
+	save := function{"Save", []string{"context.Context", "*Item"}, []string{"error"}}
+	closer := function{"Close", nil, nil}
+	keys := function{"keys", []string{"map[string] int"}, []string{"[]string"}}
+
+	tests := []struct {
+		name  string
+		query SignatureQuery
+		want  []function
+	}{
+		{"context first, any results",
+			SignatureQuery{Params: []string{"context.Context", SignatureRest}, MaxParams: -1},
+			[]function{load, save}},
+		{"context first, returning only error",
+			SignatureQuery{Params: []string{"Context", SignatureRest}, Returns: []string{"error"}, MaxParams: -1},
+			[]function{save}},
+		{"taking an item anywhere",
+			SignatureQuery{Takes: []string{"*Item"}, MaxParams: -1},
+			[]function{save}},
+		{"exactly two parameters, the first a context",
+			SignatureQuery{Params: []string{"context.Context", "*"}, MaxParams: -1},
+			[]function{load, save}},
+		{"void",
+			SignatureQuery{Void: true, MaxParams: -1},
+			[]function{closer}},
+		{"returning a pointer and an error",
+			SignatureQuery{Returns: []string{"*", "error"}, MaxParams: -1},
+			[]function{load}},
+		{"map type with spaces",
+			SignatureQuery{Params: []string{"map[string]int"}, Returns: []string{"[]string"}, MaxParams: -1},
+			[]function{keys}},
+		{"name pattern and parameter bounds",
+			SignatureQuery{Name: "*o*", MinParams: 2, MaxParams: 2},
+			[]function{load}},
+		{"no parameters",
+			SignatureQuery{MaxParams: 0},
+			[]function{closer}},
+	}
+	for _, tt := range tests {
+		var got []function
+		for _, f := range []function{load, save, closer, keys} {
+			if tt.query.matches(f.name, f.params, f.returns) {
+				got = append(got, f)
+			}
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: matched %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i].name != tt.want[i].name {
+				t.Errorf("%s: matched %v, want %v", tt.name, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"error", "error", true},
+		{"*", "", true},
+		{"*Repository", "FileVersionRepository", true},
+		{"Get*By*", "GetFileByPath", true},
+		{"Get*By*", "GetFile", false},
+		{"a*a", "a", false},
+		{"[]*", "[]string", true},
+	}
+	for _, tt := range tests {
+		if got := globMatch(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("globMatch(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+}
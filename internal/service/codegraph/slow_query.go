@@ -0,0 +1,51 @@
+package codegraph
+
+import (
+	"context"
+	"time"
+
+	"bot-go/internal/slowlog"
+)
+
+// slowQueryDatabase times the queries of a GraphDatabase and reports them to a slow query log
+type slowQueryDatabase struct {
+	GraphDatabase
+	log *slowlog.Log
+}
+
+// SetSlowLog reports the duration of every query to log, which logs slow queries with their
+// parameters. Call it before the graph is used.
+func (cg *CodeGraph) SetSlowLog(log *slowlog.Log) {
+	if log == nil {
+		return
+	}
+	cg.db = &slowQueryDatabase{GraphDatabase: cg.db, log: log}
+}
+
+func (db *slowQueryDatabase) observe(ctx context.Context, query string, params map[string]any, start time.Time) {
+	db.log.Observe(ctx, slowlog.KindNeo4j, slowlog.QueryLabel(query), params, time.Since(start))
+}
+
+// ExecuteRead runs a read and records its duration
+func (db *slowQueryDatabase) ExecuteRead(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	defer db.observe(ctx, query, params, time.Now())
+	return db.GraphDatabase.ExecuteRead(ctx, query, params)
+}
+
+// ExecuteWrite runs a write and records its duration
+func (db *slowQueryDatabase) ExecuteWrite(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	defer db.observe(ctx, query, params, time.Now())
+	return db.GraphDatabase.ExecuteWrite(ctx, query, params)
+}
+
+// ExecuteReadSingle runs a read expecting a single record and records its duration
+func (db *slowQueryDatabase) ExecuteReadSingle(ctx context.Context, query string, params map[string]any) (map[string]any, error) {
+	defer db.observe(ctx, query, params, time.Now())
+	return db.GraphDatabase.ExecuteReadSingle(ctx, query, params)
+}
+
+// ExecuteWriteSingle runs a write expecting a single record and records its duration
+func (db *slowQueryDatabase) ExecuteWriteSingle(ctx context.Context, query string, params map[string]any) (map[string]any, error) {
+	defer db.observe(ctx, query, params, time.Now())
+	return db.GraphDatabase.ExecuteWriteSingle(ctx, query, params)
+}
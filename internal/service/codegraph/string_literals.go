@@ -0,0 +1,186 @@
+package codegraph
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"bot-go/internal/model/ast"
+	"bot-go/pkg/lsp/base"
+
+	"go.uber.org/zap"
+)
+
+// StringLiteralSearchIndex is the name of the Neo4j full-text index over string literal values
+const StringLiteralSearchIndex = "stringLiteralSearch"
+
+// Kinds of string literals, from how the literal is used
+const (
+	StringKindError = "error" // error constructor, panic, raise or throw
+	StringKindLog   = "log"   // logging or printing call
+	StringKindURL   = "url"
+	StringKindText  = "text"
+)
+
+// StringLiteral is a significant string literal of a file, stored as a StringLiteral node
+// keyed by repository and file path. The Function containing it points to it with HAS_STRING.
+type StringLiteral struct {
+	Value      string
+	Kind       string
+	Range      base.Range
+	FunctionID ast.NodeID // innermost containing function, InvalidNodeID at top level
+}
+
+// StringLiteralSearchOptions narrows a string literal search
+type StringLiteralSearchOptions struct {
+	// MatchAll requires every term of the text. By default literals sharing any term are
+	// returned, best match first, so a message with runtime values filled in still finds
+	// the format string it came from.
+	MatchAll bool
+	Kind     string // empty = all kinds
+	Limit    int    // default 20
+}
+
+// StringLiteralMatch is a string literal found by SearchStringLiterals
+type StringLiteralMatch struct {
+	Value        string
+	Kind         string
+	FilePath     string
+	FileID       int32
+	Range        base.Range
+	FunctionID   ast.NodeID
+	FunctionName string
+	Score        float64
+}
+
+// EnsureStringLiteralIndexes creates the full-text index used by SearchStringLiterals and the
+// index literals are replaced by, if they do not exist
+func (cg *CodeGraph) EnsureStringLiteralIndexes(ctx context.Context) error {
+	queries := []string{
+		fmt.Sprintf("CREATE FULLTEXT INDEX %s IF NOT EXISTS FOR (s:StringLiteral) ON EACH [s.value]", StringLiteralSearchIndex),
+		"CREATE INDEX stringLiteralFile IF NOT EXISTS FOR (s:StringLiteral) ON (s.repo, s.path)",
+	}
+	for _, query := range queries {
+		if _, err := cg.db.ExecuteWrite(ctx, query, nil); err != nil {
+			return fmt.Errorf("failed to create string literal index: %w", err)
+		}
+	}
+	cg.log(ctx).Info("String literal indexes ready", zap.String("index", StringLiteralSearchIndex))
+	return nil
+}
+
+// ReplaceStringLiterals stores the string literals of a file, replacing those stored for
+// any earlier version of the same path
+func (cg *CodeGraph) ReplaceStringLiterals(ctx context.Context, repoName, path string, fileID int32, literals []StringLiteral) error {
+	params := map[string]any{"repo": repoName, "path": path}
+	if _, err := cg.db.ExecuteWrite(ctx, `
+		MATCH (s:StringLiteral {repo: $repo, path: $path})
+		DETACH DELETE s
+	`, params); err != nil {
+		return fmt.Errorf("failed to delete string literals of %s: %w", path, err)
+	}
+
+	for start := 0; start < len(literals); start += commitWriteBatch {
+		end := min(start+commitWriteBatch, len(literals))
+		rows := make([]map[string]any, 0, end-start)
+		for _, l := range literals[start:end] {
+			rows = append(rows, map[string]any{
+				"value":       l.Value,
+				"kind":        l.Kind,
+				"functionId":  int64(l.FunctionID),
+				PropStartLine: l.Range.Start.Line,
+				PropStartChar: l.Range.Start.Character,
+				PropEndLine:   l.Range.End.Line,
+				PropEndChar:   l.Range.End.Character,
+			})
+		}
+		query := `
+			UNWIND $literals AS l
+			CREATE (s:StringLiteral {repo: $repo, path: $path, fileId: $fileId, value: l.value, kind: l.kind,
+			        startLine: l.startLine, startChar: l.startChar, endLine: l.endLine, endChar: l.endChar})
+			WITH s, l
+			OPTIONAL MATCH (fn:Function {id: l.functionId})
+			FOREACH (_ IN CASE WHEN fn IS NULL THEN [] ELSE [1] END | MERGE (fn)-[:HAS_STRING]->(s))
+		`
+		if _, err := cg.db.ExecuteWrite(ctx, query, map[string]any{
+			"repo": repoName, "path": path, "fileId": fileID, "literals": rows,
+		}); err != nil {
+			return fmt.Errorf("failed to write string literals of %s: %w", path, err)
+		}
+	}
+	cg.log(ctx).Debug("Stored string literals", zap.String("path", path), zap.Int("count", len(literals)))
+	return nil
+}
+
+// SearchStringLiterals finds string literals of a repository matching text through the
+// full-text index (see EnsureStringLiteralIndexes), ordered by score
+func (cg *CodeGraph) SearchStringLiterals(ctx context.Context, repoName, text string, opts StringLiteralSearchOptions) ([]StringLiteralMatch, error) {
+	terms := strings.Fields(text)
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("search text is empty")
+	}
+	for i, term := range terms {
+		terms[i] = escapeLucene(term)
+	}
+	operator := " OR "
+	if opts.MatchAll {
+		operator = " AND "
+	}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+
+	params := map[string]any{
+		"index": StringLiteralSearchIndex,
+		"query": strings.Join(terms, operator),
+		"repo":  repoName,
+		"limit": int64(limit),
+	}
+	kindFilter := ""
+	if opts.Kind != "" {
+		params["kind"] = opts.Kind
+		kindFilter = "AND node.kind = $kind"
+	}
+
+	query := `
+		CALL db.index.fulltext.queryNodes($index, $query) YIELD node, score
+		WHERE node.repo = $repo ` + kindFilter + `
+		OPTIONAL MATCH (fn:Function)-[:HAS_STRING]->(node)
+		RETURN node, score, fn.id AS functionId, fn.name AS functionName
+		ORDER BY score DESC, node.path, node.startLine
+		LIMIT $limit
+	`
+	records, err := cg.db.ExecuteRead(ctx, query, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search string literals: %w", err)
+	}
+
+	results := make([]StringLiteralMatch, 0, len(records))
+	for _, record := range records {
+		props, ok := record["node"].(map[string]any)
+		if !ok {
+			continue
+		}
+		score, _ := record["score"].(float64)
+		results = append(results, StringLiteralMatch{
+			Value:        recordString(props, "value"),
+			Kind:         recordString(props, "kind"),
+			FilePath:     recordString(props, "path"),
+			FileID:       int32(cg.convertToInt64(props["fileId"])),
+			Range:        RangeFromProperties(props),
+			FunctionID:   ast.NodeID(cg.convertToInt64(record["functionId"])),
+			FunctionName: recordString(record, "functionName"),
+			Score:        score,
+		})
+	}
+	return results, nil
+}
+
+// DeleteStringLiterals removes the StringLiteral nodes of a repository and their relations
+func (cg *CodeGraph) DeleteStringLiterals(ctx context.Context, repoName string) error {
+	if _, err := cg.db.ExecuteWrite(ctx, `MATCH (s:StringLiteral {repo: $repo}) DETACH DELETE s`, map[string]any{"repo": repoName}); err != nil {
+		return fmt.Errorf("failed to delete string literals: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package codegraph
+
+import (
+	"context"
+	"time"
+
+	"bot-go/internal/access"
+)
+
+// usageDatabase charges the time of every query to the API client of its request
+type usageDatabase struct {
+	GraphDatabase
+}
+
+// AccountUsage charges the duration of queries made for API requests to the usage account
+// of their client (see access.Record). Call it before the graph is used.
+func (cg *CodeGraph) AccountUsage() {
+	cg.db = &usageDatabase{GraphDatabase: cg.db}
+}
+
+func (db *usageDatabase) record(ctx context.Context, start time.Time) {
+	access.Record(ctx, access.Usage{QueryTime: time.Since(start)})
+}
+
+// ExecuteRead runs a read and charges its duration
+func (db *usageDatabase) ExecuteRead(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	defer db.record(ctx, time.Now())
+	return db.GraphDatabase.ExecuteRead(ctx, query, params)
+}
+
+// ExecuteWrite runs a write and charges its duration
+func (db *usageDatabase) ExecuteWrite(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	defer db.record(ctx, time.Now())
+	return db.GraphDatabase.ExecuteWrite(ctx, query, params)
+}
+
+// ExecuteReadSingle runs a read expecting a single record and charges its duration
+func (db *usageDatabase) ExecuteReadSingle(ctx context.Context, query string, params map[string]any) (map[string]any, error) {
+	defer db.record(ctx, time.Now())
+	return db.GraphDatabase.ExecuteReadSingle(ctx, query, params)
+}
+
+// ExecuteWriteSingle runs a write expecting a single record and charges its duration
+func (db *usageDatabase) ExecuteWriteSingle(ctx context.Context, query string, params map[string]any) (map[string]any, error) {
+	defer db.record(ctx, time.Now())
+	return db.GraphDatabase.ExecuteWriteSingle(ctx, query, params)
+}
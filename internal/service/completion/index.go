@@ -0,0 +1,118 @@
+package completion
+
+import (
+	"sort"
+	"strings"
+)
+
+// MaxSuggestions bounds the completions of one prefix: every trie node keeps its best
+// MaxSuggestions symbols, so a lookup never ranks more than that
+const MaxSuggestions = 64
+
+// Symbol is a completion: a function, class or field of the latest indexed file versions
+type Symbol struct {
+	ID         int64  `json:"id"`
+	Name       string `json:"name"`
+	Kind       string `json:"kind"`                // "function", "class" or "field"
+	Container  string `json:"container,omitempty"` // enclosing class of methods and fields
+	FilePath   string `json:"file_path"`
+	Line       int    `json:"line"`
+	References int64  `json:"references"` // calls, subclasses or field accesses in the graph
+}
+
+// Index answers prefix queries over the symbol names of a repository. Matching ignores
+// case; completions are ranked by reference count, then shorter names first.
+type Index struct {
+	symbols []Symbol
+	root    *trieNode
+}
+
+// trieNode is a node of the trie over lowercased names. top holds the best symbols (indexes
+// into Index.symbols, best first) whose name starts with the node's prefix.
+type trieNode struct {
+	children []trieEdge
+	top      []int32
+}
+
+type trieEdge struct {
+	b    byte
+	node *trieNode
+}
+
+// NewIndex builds the index of symbols; it takes ownership of the slice
+func NewIndex(symbols []Symbol) *Index {
+	sort.SliceStable(symbols, func(i, j int) bool {
+		a, b := symbols[i], symbols[j]
+		if a.References != b.References {
+			return a.References > b.References
+		}
+		if len(a.Name) != len(b.Name) {
+			return len(a.Name) < len(b.Name)
+		}
+		return a.Name < b.Name
+	})
+
+	idx := &Index{symbols: symbols, root: &trieNode{}}
+	// Symbols are inserted best first, so each node's top list fills in rank order and
+	// stops growing once full
+	for i, symbol := range symbols {
+		node := idx.root
+		node.add(int32(i))
+		name := strings.ToLower(symbol.Name)
+		for j := 0; j < len(name); j++ {
+			node = node.child(name[j])
+			node.add(int32(i))
+		}
+	}
+	return idx
+}
+
+func (n *trieNode) add(i int32) {
+	if len(n.top) < MaxSuggestions {
+		n.top = append(n.top, i)
+	}
+}
+
+func (n *trieNode) child(b byte) *trieNode {
+	if next := n.lookup(b); next != nil {
+		return next
+	}
+	next := &trieNode{}
+	n.children = append(n.children, trieEdge{b: b, node: next})
+	return next
+}
+
+func (n *trieNode) lookup(b byte) *trieNode {
+	for _, edge := range n.children {
+		if edge.b == b {
+			return edge.node
+		}
+	}
+	return nil
+}
+
+// Complete returns up to limit symbols whose name starts with prefix, best first. An empty
+// prefix returns the most referenced symbols.
+func (idx *Index) Complete(prefix string, limit int) []Symbol {
+	node := idx.root
+	prefix = strings.ToLower(prefix)
+	for i := 0; i < len(prefix) && node != nil; i++ {
+		node = node.lookup(prefix[i])
+	}
+	if node == nil {
+		return []Symbol{}
+	}
+	if limit > len(node.top) {
+		limit = len(node.top)
+	}
+	completions := make([]Symbol, limit)
+	for i := range completions {
+		completions[i] = idx.symbols[node.top[i]]
+	}
+	return completions
+}
+
+// Len returns the number of symbols in the index
+func (idx *Index) Len() int {
+	return len(idx.symbols)
+}
@@ -0,0 +1,57 @@
+package completion
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCompleteRanksByReferences(t *testing.T) {
+	idx := NewIndex([]Symbol{
+		{Name: "parseArgs", Kind: "function", References: 3},
+		{Name: "ParseConfig", Kind: "function", References: 12},
+		{Name: "Parser", Kind: "class", References: 12},
+		{Name: "parse", Kind: "function", References: 0},
+		{Name: "render", Kind: "function", References: 40},
+	})
+
+	tests := []struct {
+		prefix string
+		limit  int
+		want   []string
+	}{
+		{"pars", 10, []string{"Parser", "ParseConfig", "parseArgs", "parse"}},
+		{"PARSEc", 10, []string{"ParseConfig"}},
+		{"parse", 2, []string{"Parser", "ParseConfig"}},
+		{"", 1, []string{"render"}},
+		{"x", 10, nil},
+	}
+	for _, tt := range tests {
+		got := idx.Complete(tt.prefix, tt.limit)
+		var names []string
+		for _, symbol := range got {
+			names = append(names, symbol.Name)
+		}
+		if fmt.Sprint(names) != fmt.Sprint(tt.want) {
+			t.Errorf("Complete(%q, %d) = %v, want %v", tt.prefix, tt.limit, names, tt.want)
+		}
+	}
+}
+
+func TestCompleteKeepsBestSuggestions(t *testing.T) {
+	symbols := make([]Symbol, 3*MaxSuggestions)
+	for i := range symbols {
+		symbols[i] = Symbol{Name: fmt.Sprintf("handler%d", i), References: int64(i)}
+	}
+	idx := NewIndex(symbols)
+
+	got := idx.Complete("handler", 2*MaxSuggestions)
+	if len(got) != MaxSuggestions {
+		t.Fatalf("got %d completions, want %d", len(got), MaxSuggestions)
+	}
+	if want := int64(len(symbols) - 1); got[0].References != want {
+		t.Errorf("best completion has %d references, want %d", got[0].References, want)
+	}
+	if got := idx.Complete("handler1", 5); got[0].Name != "handler191" {
+		t.Errorf("best completion of handler1 = %s, want handler191", got[0].Name)
+	}
+}
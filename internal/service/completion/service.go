@@ -0,0 +1,229 @@
+package completion
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"bot-go/internal/service/codegraph"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// checkInterval is how often a loaded index is compared with the graph, to pick up
+	// builds run by other processes (such as the index builder CLI)
+	checkInterval = time.Minute
+	checkTimeout  = 30 * time.Second
+)
+
+// Service keeps an in-memory completion index per repository. An index is loaded from the
+// code graph on first use, rebuilt by Refresh after every index build of the server, and
+// rebuilt in the background when a periodic check finds the graph has changed.
+type Service struct {
+	graph  *codegraph.CodeGraph
+	logger *zap.Logger
+
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+// entry is the index of one repository
+type entry struct {
+	build    sync.Mutex // serializes loads of the index
+	index    atomic.Pointer[loadedIndex]
+	checking atomic.Bool
+}
+
+// loadedIndex is an index with the graph version it was loaded from
+type loadedIndex struct {
+	*Index
+	version   graphVersion
+	checkedAt time.Time
+}
+
+// graphVersion identifies the indexed file versions of a repository: every build that
+// changes a file adds a file scope with a higher ID, and deleting files lowers the count
+type graphVersion struct {
+	lastFileID int64
+	files      int64
+}
+
+// NewService creates a completion service over the code graph
+func NewService(graph *codegraph.CodeGraph, logger *zap.Logger) *Service {
+	return &Service{
+		graph:   graph,
+		logger:  logger,
+		entries: make(map[string]*entry),
+	}
+}
+
+func (s *Service) entry(repoName string) *entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[repoName]
+	if !ok {
+		e = &entry{}
+		s.entries[repoName] = e
+	}
+	return e
+}
+
+// Complete returns up to limit symbols of the repository whose name starts with prefix,
+// most referenced first. The first call for a repository loads its index from the graph;
+// later calls are served from memory.
+func (s *Service) Complete(ctx context.Context, repoName, prefix string, limit int) ([]Symbol, error) {
+	e := s.entry(repoName)
+	loaded := e.index.Load()
+	if loaded == nil {
+		var err error
+		if loaded, err = s.load(ctx, repoName, e, false); err != nil {
+			return nil, err
+		}
+	} else if time.Since(loaded.checkedAt) > checkInterval && e.checking.CompareAndSwap(false, true) {
+		go s.check(repoName, e, loaded)
+	}
+	return loaded.Complete(prefix, limit), nil
+}
+
+// Refresh rebuilds the index of a repository after an index build. Repositories whose index
+// was never loaded are skipped: they load on their first completion.
+func (s *Service) Refresh(ctx context.Context, repoName string) error {
+	e := s.entry(repoName)
+	if e.index.Load() == nil {
+		return nil
+	}
+	_, err := s.load(ctx, repoName, e, true)
+	return err
+}
+
+// load reads the symbols of the repository from the graph and swaps in their index.
+// Without force, an index loaded meanwhile by another caller is returned instead.
+func (s *Service) load(ctx context.Context, repoName string, e *entry, force bool) (*loadedIndex, error) {
+	e.build.Lock()
+	defer e.build.Unlock()
+	if loaded := e.index.Load(); loaded != nil && !force {
+		return loaded, nil
+	}
+
+	start := time.Now()
+	version, err := s.version(ctx, repoName)
+	if err != nil {
+		return nil, err
+	}
+	symbols, err := s.symbols(ctx, repoName)
+	if err != nil {
+		return nil, err
+	}
+	loaded := &loadedIndex{Index: NewIndex(symbols), version: version, checkedAt: time.Now()}
+	e.index.Store(loaded)
+	s.logger.Info("Completion index loaded",
+		zap.String("repo_name", repoName),
+		zap.Int("symbols", loaded.Len()),
+		zap.Duration("duration", time.Since(start)))
+	return loaded, nil
+}
+
+// check reloads the index when the graph version has changed since it was loaded
+func (s *Service) check(repoName string, e *entry, loaded *loadedIndex) {
+	defer e.checking.Store(false)
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+
+	version, err := s.version(ctx, repoName)
+	if err != nil {
+		s.logger.Warn("Failed to check completion index", zap.String("repo_name", repoName), zap.Error(err))
+		return
+	}
+	if version == loaded.version {
+		e.index.CompareAndSwap(loaded, &loadedIndex{Index: loaded.Index, version: version, checkedAt: time.Now()})
+		return
+	}
+	if _, err := s.load(ctx, repoName, e, true); err != nil {
+		s.logger.Warn("Failed to reload completion index", zap.String("repo_name", repoName), zap.Error(err))
+	}
+}
+
+func (s *Service) version(ctx context.Context, repoName string) (graphVersion, error) {
+	record, err := s.graph.ExecuteReadSingle(ctx, `
+		MATCH (fs:FileScope {repo: $repo})
+		RETURN max(fs.id) AS lastFileId, count(fs) AS files
+	`, map[string]any{"repo": repoName})
+	if err != nil {
+		return graphVersion{}, fmt.Errorf("failed to read graph version: %w", err)
+	}
+	return graphVersion{lastFileID: toInt64(record["lastFileId"]), files: toInt64(record["files"])}, nil
+}
+
+// symbolQuery reads the nodes of one label (%s) of the latest version of each file with
+// their reference counts, precomputed at the end of each build (see
+// codegraph.ComputePopularity)
+const symbolQuery = `
+	MATCH (fs:FileScope {repo: $repo})
+	WITH fs.path AS path, max(fs.id) AS fileId
+	MATCH (n:%s {fileId: fileId})
+	OPTIONAL MATCH (c:Class)-[:CONTAINS]->(n)
+	RETURN n.id AS id, n.name AS name, c.name AS container, path,
+	       n {.startLine, .startChar, .endLine, .endChar, .range} AS range,
+	       coalesce(n.` + codegraph.PropRefCount + `, 0) AS refs
+`
+
+// symbolKinds are the labels of the completed nodes with the kind reported for them
+var symbolKinds = []struct {
+	label string
+	kind  string
+}{
+	{"Function", "function"},
+	{"Class", "class"},
+	{"Field", "field"},
+}
+
+func (s *Service) symbols(ctx context.Context, repoName string) ([]Symbol, error) {
+	var symbols []Symbol
+	for _, k := range symbolKinds {
+		records, err := s.graph.ExecuteRead(ctx, fmt.Sprintf(symbolQuery, k.label), map[string]any{"repo": repoName})
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s symbols: %w", k.kind, err)
+		}
+		for _, record := range records {
+			name := toString(record["name"])
+			if name == "" {
+				continue
+			}
+			symbols = append(symbols, Symbol{
+				ID:         toInt64(record["id"]),
+				Name:       name,
+				Kind:       k.kind,
+				Container:  toString(record["container"]),
+				FilePath:   toString(record["path"]),
+				Line:       codegraph.RangeFromValue(record["range"]).Start.Line,
+				References: toInt64(record["refs"]),
+			})
+		}
+	}
+	return symbols, nil
+}
+
+func toInt64(v any) int64 {
+	switch val := v.(type) {
+	case int64:
+		return val
+	case int:
+		return int64(val)
+	case int32:
+		return int64(val)
+	case float64:
+		return int64(val)
+	default:
+		return 0
+	}
+}
+
+func toString(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return ""
+}
@@ -0,0 +1,298 @@
+// Package graphembed computes structural embeddings for code graph nodes.
+// Unlike text embeddings, these vectors are derived purely from graph topology
+// (calls, containment, inheritance), so functions with similar roles in the
+// graph end up close together even when their source text differs.
+package graphembed
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"bot-go/internal/model"
+	"bot-go/internal/model/ast"
+	"bot-go/internal/service/codegraph"
+	"bot-go/internal/service/vector"
+	"bot-go/pkg/lsp/base"
+
+	"go.uber.org/zap"
+)
+
+// CollectionSuffix is appended to the repository name to form the graph embedding collection
+const CollectionSuffix = "_graph"
+
+// GraphEmbeddingService builds node2vec embeddings for Function/Class nodes
+// and stores them in a dedicated vector collection per repository
+type GraphEmbeddingService struct {
+	graph    *codegraph.CodeGraph
+	vectorDB vector.VectorDatabase
+	opts     Node2VecOptions
+	logger   *zap.Logger
+}
+
+// StructuralMatch is a node that is structurally similar to the query node
+type StructuralMatch struct {
+	NodeID   ast.NodeID   `json:"node_id"`
+	Name     string       `json:"name"`
+	NodeType ast.NodeType `json:"node_type"`
+	FileID   int32        `json:"file_id"`
+	FilePath string       `json:"file_path"`
+	Range    base.Range   `json:"range"`
+	Score    float32      `json:"score"`
+}
+
+type graphNodeInfo struct {
+	id       int64
+	name     string
+	nodeType ast.NodeType
+	fileID   int32
+	filePath string
+	rng      base.Range
+}
+
+// NewGraphEmbeddingService creates a new graph embedding service
+func NewGraphEmbeddingService(graph *codegraph.CodeGraph, vectorDB vector.VectorDatabase, opts Node2VecOptions, logger *zap.Logger) *GraphEmbeddingService {
+	return &GraphEmbeddingService{
+		graph:    graph,
+		vectorDB: vectorDB,
+		opts:     opts,
+		logger:   logger,
+	}
+}
+
+// CollectionName returns the vector collection holding graph embeddings for a repository
+func CollectionName(repoName string) string {
+	return repoName + CollectionSuffix
+}
+
+// BuildEmbeddings computes graph embeddings for all functions and classes in the repository
+// and upserts them into the repository's graph collection. Returns the number of stored vectors.
+func (s *GraphEmbeddingService) BuildEmbeddings(ctx context.Context, repoName string) (int, error) {
+	nodes, err := s.loadNodes(ctx, repoName)
+	if err != nil {
+		return 0, err
+	}
+	if len(nodes) == 0 {
+		return 0, fmt.Errorf("no functions or classes found for repository: %s", repoName)
+	}
+
+	ids := make([]int64, 0, len(nodes))
+	g := NewGraph()
+	for id := range nodes {
+		ids = append(ids, id)
+	}
+	// Add nodes in a stable order so embeddings are reproducible for the same graph
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		g.AddNode(id)
+	}
+
+	edges, err := s.loadEdges(ctx, ids)
+	if err != nil {
+		return 0, err
+	}
+	for _, edge := range edges {
+		if _, ok := nodes[edge[0]]; !ok {
+			continue
+		}
+		if _, ok := nodes[edge[1]]; !ok {
+			continue
+		}
+		g.AddEdge(edge[0], edge[1])
+	}
+
+	s.logger.Info("Computing graph embeddings",
+		zap.String("repo", repoName),
+		zap.Int("nodes", len(ids)),
+		zap.Int("edges", len(edges)),
+		zap.Int("dimension", s.opts.Dimension))
+
+	vectors := Node2Vec(g, s.opts)
+
+	collectionName := CollectionName(repoName)
+	exists, err := s.vectorDB.CollectionExists(ctx, collectionName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check collection existence: %w", err)
+	}
+	if exists {
+		// Dimensions or node IDs may have changed since the last build, so start fresh
+		if err := s.vectorDB.DeleteCollection(ctx, collectionName); err != nil {
+			return 0, fmt.Errorf("failed to reset graph collection: %w", err)
+		}
+	}
+	if err := s.vectorDB.CreateCollection(ctx, collectionName, s.opts.Dimension, vector.DistanceMetricCosine); err != nil {
+		return 0, fmt.Errorf("failed to create graph collection: %w", err)
+	}
+
+	const upsertBatchSize = 500
+	batch := make([]*model.CodeChunk, 0, upsertBatchSize)
+	stored := 0
+	for _, id := range ids {
+		batch = append(batch, s.nodeToChunk(repoName, nodes[id], vectors[id]))
+		if len(batch) >= upsertBatchSize {
+			if err := s.vectorDB.UpsertChunks(ctx, collectionName, batch); err != nil {
+				return stored, fmt.Errorf("failed to store graph embeddings: %w", err)
+			}
+			stored += len(batch)
+			batch = batch[:0]
+		}
+	}
+	if len(batch) > 0 {
+		if err := s.vectorDB.UpsertChunks(ctx, collectionName, batch); err != nil {
+			return stored, fmt.Errorf("failed to store graph embeddings: %w", err)
+		}
+		stored += len(batch)
+	}
+
+	s.logger.Info("Stored graph embeddings",
+		zap.String("repo", repoName),
+		zap.String("collection", collectionName),
+		zap.Int("count", stored))
+	return stored, nil
+}
+
+// FindStructurallySimilar returns nodes whose graph embedding is closest to the given node's
+func (s *GraphEmbeddingService) FindStructurallySimilar(ctx context.Context, repoName string, nodeID ast.NodeID, limit int) ([]*StructuralMatch, error) {
+	collectionName := CollectionName(repoName)
+	chunk, err := s.vectorDB.GetChunkByID(ctx, collectionName, nodeChunkID(repoName, int64(nodeID)))
+	if err != nil {
+		return nil, fmt.Errorf("no graph embedding for node %d (run buildGraphEmbeddings first): %w", nodeID, err)
+	}
+	if len(chunk.Embedding) == 0 {
+		return nil, fmt.Errorf("graph embedding for node %d has no vector", nodeID)
+	}
+
+	// Ask for one extra result since the query node itself is always the top hit
+	chunks, scores, err := s.vectorDB.SearchSimilar(ctx, collectionName, chunk.Embedding, limit+1, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search graph embeddings: %w", err)
+	}
+
+	matches := make([]*StructuralMatch, 0, limit)
+	for i, c := range chunks {
+		matchID := ast.NodeID(toInt64(c.Metadata["node_id"]))
+		if matchID == nodeID {
+			continue
+		}
+		matches = append(matches, &StructuralMatch{
+			NodeID:   matchID,
+			Name:     c.Name,
+			NodeType: ast.NodeType(toInt64(c.Metadata["node_type"])),
+			FileID:   int32(toInt64(c.Metadata["file_id"])),
+			FilePath: c.FilePath,
+			Range:    c.Range,
+			Score:    scores[i],
+		})
+		if len(matches) >= limit {
+			break
+		}
+	}
+	return matches, nil
+}
+
+func (s *GraphEmbeddingService) loadNodes(ctx context.Context, repoName string) (map[int64]*graphNodeInfo, error) {
+	query := `
+		MATCH (f:FileScope {repo: $repo})
+		MATCH (n)
+		WHERE n.fileId = f.fileId AND (n:Function OR n:Class)
+		RETURN n.id AS id, n.name AS name, n.nodeType AS nodeType, n.fileId AS fileId,
+		       n {.startLine, .startChar, .endLine, .endChar, .range} AS range, f.path AS path
+	`
+	records, err := s.graph.ExecuteRead(ctx, query, map[string]any{"repo": repoName})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load graph nodes: %w", err)
+	}
+
+	nodes := make(map[int64]*graphNodeInfo, len(records))
+	for _, record := range records {
+		id := toInt64(record["id"])
+		name, _ := record["name"].(string)
+		path, _ := record["path"].(string)
+		nodes[id] = &graphNodeInfo{
+			id:       id,
+			name:     name,
+			nodeType: ast.NodeType(toInt64(record["nodeType"])),
+			fileID:   int32(toInt64(record["fileId"])),
+			filePath: path,
+			rng:      codegraph.RangeFromValue(record["range"]),
+		}
+	}
+	return nodes, nil
+}
+
+// loadEdges returns call, containment and inheritance edges between the given nodes
+func (s *GraphEmbeddingService) loadEdges(ctx context.Context, ids []int64) ([][2]int64, error) {
+	queries := []string{
+		`
+		MATCH (a:Function)-[:CONTAINS*]->(:FunctionCall)-[:CALLS_FUNCTION]->(b:Function)
+		WHERE a.id IN $ids AND b.id IN $ids
+		RETURN DISTINCT a.id AS source, b.id AS target
+		`,
+		`
+		MATCH (a)-[:CONTAINS|HAS_FIELD|INHERITS]->(b)
+		WHERE a.id IN $ids AND b.id IN $ids
+		RETURN DISTINCT a.id AS source, b.id AS target
+		`,
+	}
+
+	var edges [][2]int64
+	for _, query := range queries {
+		records, err := s.graph.ExecuteRead(ctx, query, map[string]any{"ids": ids})
+		if err != nil {
+			return nil, fmt.Errorf("failed to load graph edges: %w", err)
+		}
+		for _, record := range records {
+			edges = append(edges, [2]int64{toInt64(record["source"]), toInt64(record["target"])})
+		}
+	}
+	return edges, nil
+}
+
+func (s *GraphEmbeddingService) nodeToChunk(repoName string, node *graphNodeInfo, embedding []float32) *model.CodeChunk {
+	chunkType := model.ChunkTypeFunction
+	level := 3
+	if node.nodeType == ast.NodeTypeClass {
+		chunkType = model.ChunkTypeClass
+		level = 2
+	}
+
+	chunk := model.NewCodeChunk(nodeChunkID(repoName, node.id), chunkType, level, "", "", node.filePath, node.rng).
+		WithFileID(node.fileID)
+	chunk.Name = node.name
+	chunk.Embedding = embedding
+	chunk.Metadata["node_id"] = node.id
+	chunk.Metadata["node_type"] = int64(node.nodeType)
+	chunk.Metadata["file_id"] = int64(node.fileID)
+	chunk.Metadata["embedding_kind"] = "node2vec"
+	return chunk
+}
+
+// nodeChunkID derives a stable UUID-formatted point ID from repository and node ID
+func nodeChunkID(repoName string, nodeID int64) string {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s:graph:%d", repoName, nodeID)))
+	hashStr := hex.EncodeToString(hash[:])
+	return fmt.Sprintf("%s-%s-%s-%s-%s",
+		hashStr[0:8],
+		hashStr[8:12],
+		hashStr[12:16],
+		hashStr[16:20],
+		hashStr[20:32],
+	)
+}
+
+func toInt64(v any) int64 {
+	switch val := v.(type) {
+	case int64:
+		return val
+	case int:
+		return int64(val)
+	case int32:
+		return int64(val)
+	case float64:
+		return int64(val)
+	default:
+		return 0
+	}
+}
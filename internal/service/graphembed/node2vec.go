@@ -0,0 +1,293 @@
+package graphembed
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Node2VecOptions controls random walk generation and skip-gram training
+type Node2VecOptions struct {
+	Dimension       int     // size of the generated embedding vectors
+	WalkLength      int     // number of nodes in each random walk
+	WalksPerNode    int     // number of walks started from every node
+	WindowSize      int     // skip-gram context window
+	NegativeSamples int     // negative samples per positive pair
+	Epochs          int     // passes over the walk corpus
+	LearningRate    float64 // initial SGD learning rate (decays linearly)
+	ReturnParam     float64 // node2vec p: likelihood of revisiting the previous node
+	InOutParam      float64 // node2vec q: BFS (q > 1) vs DFS (q < 1) exploration
+	Seed            int64   // random seed, fixed so rebuilds are reproducible
+}
+
+// DefaultNode2VecOptions returns sensible defaults for code graphs
+func DefaultNode2VecOptions() Node2VecOptions {
+	return Node2VecOptions{
+		Dimension:       64,
+		WalkLength:      20,
+		WalksPerNode:    10,
+		WindowSize:      5,
+		NegativeSamples: 5,
+		Epochs:          2,
+		LearningRate:    0.025,
+		ReturnParam:     1.0,
+		InOutParam:      0.5,
+		Seed:            42,
+	}
+}
+
+// Graph is an undirected adjacency structure used for walk generation
+type Graph struct {
+	adj   map[int64]map[int64]struct{}
+	nodes []int64
+}
+
+// NewGraph creates an empty graph
+func NewGraph() *Graph {
+	return &Graph{
+		adj: make(map[int64]map[int64]struct{}),
+	}
+}
+
+// AddNode adds a node without edges. Adding an existing node is a no-op.
+func (g *Graph) AddNode(id int64) {
+	if _, ok := g.adj[id]; ok {
+		return
+	}
+	g.adj[id] = make(map[int64]struct{})
+	g.nodes = append(g.nodes, id)
+}
+
+// AddEdge adds an undirected edge, creating missing endpoints. Self loops are ignored.
+func (g *Graph) AddEdge(a, b int64) {
+	if a == b {
+		return
+	}
+	g.AddNode(a)
+	g.AddNode(b)
+	g.adj[a][b] = struct{}{}
+	g.adj[b][a] = struct{}{}
+}
+
+// Nodes returns the node IDs in insertion order
+func (g *Graph) Nodes() []int64 {
+	return g.nodes
+}
+
+// HasEdge reports whether a and b are adjacent
+func (g *Graph) HasEdge(a, b int64) bool {
+	_, ok := g.adj[a][b]
+	return ok
+}
+
+// neighbors returns the sorted neighbors of a node so walks are deterministic for a given seed
+func (g *Graph) neighbors(id int64) []int64 {
+	result := make([]int64, 0, len(g.adj[id]))
+	for n := range g.adj[id] {
+		result = append(result, n)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
+
+// GenerateWalks produces node2vec biased random walks starting from every node
+func GenerateWalks(g *Graph, opts Node2VecOptions, rng *rand.Rand) [][]int64 {
+	neighborCache := make(map[int64][]int64, len(g.nodes))
+	for _, id := range g.nodes {
+		neighborCache[id] = g.neighbors(id)
+	}
+
+	walks := make([][]int64, 0, len(g.nodes)*opts.WalksPerNode)
+	for w := 0; w < opts.WalksPerNode; w++ {
+		order := rng.Perm(len(g.nodes))
+		for _, idx := range order {
+			walks = append(walks, biasedWalk(g, neighborCache, g.nodes[idx], opts, rng))
+		}
+	}
+	return walks
+}
+
+func biasedWalk(g *Graph, neighborCache map[int64][]int64, start int64, opts Node2VecOptions, rng *rand.Rand) []int64 {
+	walk := []int64{start}
+	for len(walk) < opts.WalkLength {
+		cur := walk[len(walk)-1]
+		candidates := neighborCache[cur]
+		if len(candidates) == 0 {
+			break
+		}
+
+		if len(walk) == 1 {
+			walk = append(walk, candidates[rng.Intn(len(candidates))])
+			continue
+		}
+
+		prev := walk[len(walk)-2]
+		weights := make([]float64, len(candidates))
+		total := 0.0
+		for i, next := range candidates {
+			switch {
+			case next == prev:
+				weights[i] = 1.0 / opts.ReturnParam
+			case g.HasEdge(prev, next):
+				weights[i] = 1.0
+			default:
+				weights[i] = 1.0 / opts.InOutParam
+			}
+			total += weights[i]
+		}
+
+		r := rng.Float64() * total
+		chosen := candidates[len(candidates)-1]
+		for i, weight := range weights {
+			r -= weight
+			if r <= 0 {
+				chosen = candidates[i]
+				break
+			}
+		}
+		walk = append(walk, chosen)
+	}
+	return walk
+}
+
+// TrainEmbeddings learns node vectors from walks using skip-gram with negative sampling.
+// The returned vectors are L2-normalized so they can be stored in a cosine collection.
+func TrainEmbeddings(g *Graph, walks [][]int64, opts Node2VecOptions, rng *rand.Rand) map[int64][]float32 {
+	index := make(map[int64]int, len(g.nodes))
+	for i, id := range g.nodes {
+		index[id] = i
+	}
+	dim := opts.Dimension
+
+	input := make([][]float64, len(g.nodes))
+	output := make([][]float64, len(g.nodes))
+	for i := range g.nodes {
+		input[i] = make([]float64, dim)
+		output[i] = make([]float64, dim)
+		for d := 0; d < dim; d++ {
+			input[i][d] = (rng.Float64() - 0.5) / float64(dim)
+		}
+	}
+
+	negTable := buildNegativeTable(walks, index)
+	if len(negTable) == 0 {
+		return normalizeVectors(g.nodes, input)
+	}
+
+	totalSteps := float64(opts.Epochs * len(walks))
+	step := 0.0
+	grad := make([]float64, dim)
+
+	for epoch := 0; epoch < opts.Epochs; epoch++ {
+		for _, walk := range walks {
+			lr := opts.LearningRate * (1.0 - step/totalSteps)
+			if lr < opts.LearningRate*0.0001 {
+				lr = opts.LearningRate * 0.0001
+			}
+			step++
+
+			for i, center := range walk {
+				ci := index[center]
+				for j := i - opts.WindowSize; j <= i+opts.WindowSize; j++ {
+					if j < 0 || j >= len(walk) || j == i {
+						continue
+					}
+					ctxIdx := index[walk[j]]
+
+					for d := range grad {
+						grad[d] = 0
+					}
+					updatePair(input[ci], output[ctxIdx], 1.0, lr, grad)
+					for n := 0; n < opts.NegativeSamples; n++ {
+						neg := negTable[rng.Intn(len(negTable))]
+						if neg == ctxIdx {
+							continue
+						}
+						updatePair(input[ci], output[neg], 0.0, lr, grad)
+					}
+					for d := range grad {
+						input[ci][d] += grad[d]
+					}
+				}
+			}
+		}
+	}
+
+	return normalizeVectors(g.nodes, input)
+}
+
+// Node2Vec generates walks and trains embeddings in one step
+func Node2Vec(g *Graph, opts Node2VecOptions) map[int64][]float32 {
+	rng := rand.New(rand.NewSource(opts.Seed))
+	walks := GenerateWalks(g, opts, rng)
+	return TrainEmbeddings(g, walks, opts, rng)
+}
+
+// updatePair applies one logistic-regression step for a (center, context) pair.
+// The center gradient is accumulated in grad and applied by the caller.
+func updatePair(center, context []float64, label, lr float64, grad []float64) {
+	dot := 0.0
+	for d := range center {
+		dot += center[d] * context[d]
+	}
+	g := (label - sigmoid(dot)) * lr
+	for d := range center {
+		grad[d] += g * context[d]
+		context[d] += g * center[d]
+	}
+}
+
+func sigmoid(x float64) float64 {
+	if x > 6 {
+		return 1
+	}
+	if x < -6 {
+		return 0
+	}
+	return 1.0 / (1.0 + math.Exp(-x))
+}
+
+// buildNegativeTable builds a unigram^0.75 sampling table from walk frequencies
+func buildNegativeTable(walks [][]int64, index map[int64]int) []int {
+	counts := make(map[int]int)
+	for _, walk := range walks {
+		for _, id := range walk {
+			counts[index[id]]++
+		}
+	}
+
+	keys := make([]int, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+
+	const tableScale = 10
+	table := make([]int, 0, len(keys)*tableScale)
+	for _, k := range keys {
+		n := int(math.Ceil(math.Pow(float64(counts[k]), 0.75)))
+		for i := 0; i < n; i++ {
+			table = append(table, k)
+		}
+	}
+	return table
+}
+
+func normalizeVectors(nodes []int64, vectors [][]float64) map[int64][]float32 {
+	result := make(map[int64][]float32, len(nodes))
+	for i, id := range nodes {
+		norm := 0.0
+		for _, v := range vectors[i] {
+			norm += v * v
+		}
+		norm = math.Sqrt(norm)
+		out := make([]float32, len(vectors[i]))
+		for d, v := range vectors[i] {
+			if norm > 0 {
+				out[d] = float32(v / norm)
+			}
+		}
+		result[id] = out
+	}
+	return result
+}
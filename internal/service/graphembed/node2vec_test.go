@@ -0,0 +1,70 @@
+package graphembed
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestGenerateWalks(t *testing.T) {
+	g := NewGraph()
+	g.AddEdge(1, 2)
+	g.AddEdge(2, 3)
+	g.AddNode(4) // isolated
+
+	opts := DefaultNode2VecOptions()
+	opts.WalksPerNode = 3
+	opts.WalkLength = 5
+	walks := GenerateWalks(g, opts, rand.New(rand.NewSource(1)))
+
+	if len(walks) != 4*opts.WalksPerNode {
+		t.Fatalf("expected %d walks, got %d", 4*opts.WalksPerNode, len(walks))
+	}
+	for _, walk := range walks {
+		if walk[0] == 4 {
+			if len(walk) != 1 {
+				t.Errorf("walk from isolated node should stop immediately, got %v", walk)
+			}
+			continue
+		}
+		if len(walk) != opts.WalkLength {
+			t.Errorf("expected walk length %d, got %v", opts.WalkLength, walk)
+		}
+		for i := 1; i < len(walk); i++ {
+			if !g.HasEdge(walk[i-1], walk[i]) {
+				t.Errorf("walk %v follows a non-existent edge %d->%d", walk, walk[i-1], walk[i])
+			}
+		}
+	}
+}
+
+func TestNode2VecSeparatesCommunities(t *testing.T) {
+	// Two 4-cliques joined by a single bridge edge
+	g := NewGraph()
+	for _, clique := range [][]int64{{1, 2, 3, 4}, {5, 6, 7, 8}} {
+		for i := range clique {
+			for j := i + 1; j < len(clique); j++ {
+				g.AddEdge(clique[i], clique[j])
+			}
+		}
+	}
+	g.AddEdge(4, 5)
+
+	opts := DefaultNode2VecOptions()
+	opts.Dimension = 16
+	opts.Epochs = 5
+	vectors := Node2Vec(g, opts)
+
+	cosine := func(a, b []float32) float32 {
+		var dot float32
+		for i := range a {
+			dot += a[i] * b[i]
+		}
+		return dot
+	}
+
+	same := cosine(vectors[1], vectors[2])
+	across := cosine(vectors[1], vectors[8])
+	if same <= across {
+		t.Errorf("expected nodes in the same community to be closer: same=%f across=%f", same, across)
+	}
+}
@@ -1,9 +1,12 @@
 package ngram
 
 import (
+	"bot-go/internal/apperrors"
 	"bot-go/internal/service/tokenizer"
 	"context"
 	"fmt"
+	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 
@@ -13,6 +16,8 @@ import (
 // FileModel represents the n-gram model for a single file
 type FileModel struct {
 	FilePath     string
+	FileID       int32  // FileID from MySQL file_versions table (0 if unknown)
+	CommitSHA    string // Commit the file content was read from (empty if ephemeral)
 	Language     string
 	TokenCount   int
 	LastModified time.Time
@@ -25,6 +30,7 @@ type FileModel struct {
 type CorpusManager struct {
 	globalModel *NGramModelTrie       // Global model (trie + bloom filter)
 	fileModels  map[string]*FileModel // file path -> file model
+	fileIDs     map[int32]string      // FileID -> file path
 	tokenizer   *tokenizer.TokenizerRegistry
 	n           int // N-gram size
 	smoother    Smoother
@@ -51,6 +57,7 @@ func NewCorpusManager(n int, smoother Smoother, tokenizerRegistry *tokenizer.Tok
 	return &CorpusManager{
 		globalModel: globalModel,
 		fileModels:  make(map[string]*FileModel),
+		fileIDs:     make(map[int32]string),
 		tokenizer:   tokenizerRegistry,
 		n:           n,
 		smoother:    smoother,
@@ -181,8 +188,11 @@ func (cm *CorpusManager) UpdateFile(ctx context.Context, filePath string, source
 	// Update global model
 	cm.globalModel.Add(normalizedTokens)
 
-	// Update file model
+	// Update file model. The old FileID referred to the previous content, so drop it.
 	cm.mu.Lock()
+	if existingModel.FileID != 0 {
+		delete(cm.fileIDs, existingModel.FileID)
+	}
 	cm.fileModels[filePath] = fm
 	cm.mu.Unlock()
 
@@ -205,23 +215,58 @@ func (cm *CorpusManager) RemoveFile(ctx context.Context, filePath string) error
 
 	fileModel, exists := cm.fileModels[filePath]
 	if !exists {
-		return fmt.Errorf("file not found in corpus: %s", filePath)
+		return apperrors.NotFound("corpus file", filePath)
 	}
 
 	// Note: Removing from global model is complex without tracking
 	// In a production system, we'd need better bookkeeping
 	delete(cm.fileModels, filePath)
+	if fileModel.FileID != 0 {
+		delete(cm.fileIDs, fileModel.FileID)
+	}
 
 	cm.logger.Debug("Removed file from corpus",
 		zap.String("path", filePath),
 	)
 
-	// Suppress unused variable warning
-	_ = fileModel
+	return nil
+}
+
+// SetFileIdentity associates a file in the corpus with its FileID and commit,
+// so the n-gram index can be joined with the code graph and vector store by FileID
+func (cm *CorpusManager) SetFileIdentity(filePath string, fileID int32, commitSHA string) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	fileModel, exists := cm.fileModels[filePath]
+	if !exists {
+		return apperrors.NotFound("corpus file", filePath)
+	}
 
+	if fileModel.FileID != 0 && fileModel.FileID != fileID {
+		delete(cm.fileIDs, fileModel.FileID)
+	}
+	fileModel.FileID = fileID
+	fileModel.CommitSHA = commitSHA
+	if fileID != 0 {
+		cm.fileIDs[fileID] = filePath
+	}
 	return nil
 }
 
+// GetFileModelByID returns the n-gram model for the file with the given FileID
+func (cm *CorpusManager) GetFileModelByID(ctx context.Context, fileID int32) (*FileModel, error) {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	filePath, exists := cm.fileIDs[fileID]
+	if !exists {
+		return nil, apperrors.NotFound("corpus file ID", fileID)
+	}
+
+	return cm.fileModels[filePath], nil
+}
+
 // GetFileEntropy returns the entropy for a specific file
 func (cm *CorpusManager) GetFileEntropy(ctx context.Context, filePath string) (float64, error) {
 	cm.mu.RLock()
@@ -229,7 +274,7 @@ func (cm *CorpusManager) GetFileEntropy(ctx context.Context, filePath string) (f
 
 	fileModel, exists := cm.fileModels[filePath]
 	if !exists {
-		return 0, fmt.Errorf("file not found in corpus: %s", filePath)
+		return 0, apperrors.NotFound("corpus file", filePath)
 	}
 
 	return fileModel.Entropy, nil
@@ -270,7 +315,7 @@ func (cm *CorpusManager) GetFileModel(ctx context.Context, filePath string) (*Fi
 
 	fileModel, exists := cm.fileModels[filePath]
 	if !exists {
-		return nil, fmt.Errorf("file not found in corpus: %s", filePath)
+		return nil, apperrors.NotFound("corpus file", filePath)
 	}
 
 	return fileModel, nil
@@ -338,6 +383,49 @@ func (cm *CorpusManager) ListFiles(ctx context.Context) []string {
 	return files
 }
 
+// LexicalMatch is a corpus file ranked by n-gram overlap with a query
+type LexicalMatch struct {
+	FilePath string  `json:"file_path"`
+	FileID   int32   `json:"file_id,omitempty"`
+	Language string  `json:"language"`
+	Score    float64 `json:"score"` // fraction of the query's n-grams found in the file
+}
+
+// RankFilesByOverlap returns up to limit files of the given language (any language when
+// empty) containing the most of the query's n-grams, best first. Files sharing no n-gram
+// with the query are omitted.
+func (cm *CorpusManager) RankFilesByOverlap(tokens []string, language string, limit int) []LexicalMatch {
+	cm.mu.RLock()
+	matches := make([]LexicalMatch, 0)
+	for path, fm := range cm.fileModels {
+		if language != "" && fm.Language != language {
+			continue
+		}
+		score := fm.Model.Coverage(tokens)
+		if score == 0 {
+			continue
+		}
+		matches = append(matches, LexicalMatch{
+			FilePath: path,
+			FileID:   fm.FileID,
+			Language: fm.Language,
+			Score:    score,
+		})
+	}
+	cm.mu.RUnlock()
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].FilePath < matches[j].FilePath
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
 // CorpusStats contains statistics about the entire corpus
 type CorpusStats struct {
 	TotalFiles     int            `json:"total_files"`
@@ -385,6 +473,47 @@ func (cm *CorpusManager) CalculateZScore(ctx context.Context, entropy float64) f
 	return (entropy - stats.Mean) / stats.StdDev
 }
 
+// DirectoryProfile describes the naturalness of the files of one directory against the
+// whole corpus
+type DirectoryProfile struct {
+	Directory  string         `json:"directory"`
+	Files      int            `json:"files"`
+	Tokens     int            `json:"tokens"`
+	Languages  map[string]int `json:"languages"`  // files per language
+	Entropy    EntropyStats   `json:"entropy"`    // of the directory's files
+	Repository EntropyStats   `json:"repository"` // of all files of the corpus
+	ZScore     float64        `json:"z_score"`    // of the directory's mean file entropy; 0 without files
+}
+
+// GetDirectoryProfile returns the naturalness profile of the files directly in a directory,
+// given like the corpus file paths
+func (cm *CorpusManager) GetDirectoryProfile(ctx context.Context, dir string) DirectoryProfile {
+	dir = filepath.Clean(dir)
+	profile := DirectoryProfile{Directory: dir, Languages: make(map[string]int)}
+
+	cm.mu.RLock()
+	all := make([]float64, 0, len(cm.fileModels))
+	var entropies []float64
+	for path, fm := range cm.fileModels {
+		all = append(all, fm.Entropy)
+		if filepath.Dir(path) != dir {
+			continue
+		}
+		entropies = append(entropies, fm.Entropy)
+		profile.Tokens += fm.TokenCount
+		profile.Languages[fm.Language]++
+	}
+	cm.mu.RUnlock()
+
+	profile.Files = len(entropies)
+	profile.Entropy = calculateEntropyStatistics(entropies)
+	profile.Repository = calculateEntropyStatistics(all)
+	if profile.Files > 0 && profile.Repository.StdDev > 0 {
+		profile.ZScore = (profile.Entropy.Mean - profile.Repository.Mean) / profile.Repository.StdDev
+	}
+	return profile
+}
+
 // calculateEntropyStatistics computes mean, stddev, min, max from entropy values
 func calculateEntropyStatistics(entropies []float64) EntropyStats {
 	if len(entropies) == 0 {
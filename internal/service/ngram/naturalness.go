@@ -0,0 +1,92 @@
+package ngram
+
+import "math"
+
+const (
+	// DefaultFindingZScore is the line z-score from which a line is reported as a finding,
+	// the lower bound of the "very_high" interpretation
+	DefaultFindingZScore = 2.0
+
+	// minFindingTokens is the fewest tokens a line needs to be reported: the entropy of a
+	// line with one or two tokens says little about the line
+	minFindingTokens = 3
+)
+
+// LineScore is the naturalness of one line of analyzed code
+type LineScore struct {
+	Line       int     `json:"line"` // 1-based
+	TokenCount int     `json:"token_count"`
+	Entropy    float64 `json:"entropy"` // Average surprisal of the line's tokens in bits
+	ZScore     float64 `json:"z_score"` // Entropy against the corpus file entropies
+}
+
+// Finding is a line whose entropy is unusually high for the repository's corpus, which
+// often points at bugs or code that does not follow the repository's conventions
+type Finding struct {
+	FilePath string  `json:"file_path"`
+	Line     int     `json:"line"` // 1-based
+	Entropy  float64 `json:"entropy"`
+	ZScore   float64 `json:"z_score"`
+	Level    string  `json:"level"` // Interpretation of the z-score, e.g. "very_high"
+}
+
+// Findings returns the lines of the analysis with a z-score of at least minZScore and
+// enough tokens to be scored, in line order
+func (a *CodeAnalysis) Findings(filePath string, minZScore float64) []Finding {
+	var findings []Finding
+	for _, line := range a.Lines {
+		if line.TokenCount < minFindingTokens || line.ZScore < minZScore {
+			continue
+		}
+		findings = append(findings, Finding{
+			FilePath: filePath,
+			Line:     line.Line,
+			Entropy:  line.Entropy,
+			ZScore:   line.ZScore,
+			Level:    interpretZScore(line.ZScore).Level,
+		})
+	}
+	return findings
+}
+
+// scoreLines averages the surprisal of each token, computed as in CrossEntropy, over the
+// tokens of each line. lines holds the line of each token.
+func scoreLines(model *NGramModelTrie, tokens []string, lines []int) []LineScore {
+	var scores []LineScore
+	var total float64
+	for i := range tokens {
+		if len(scores) == 0 || scores[len(scores)-1].Line != lines[i] {
+			closeLine(scores, total)
+			scores = append(scores, LineScore{Line: lines[i]})
+			total = 0
+		}
+		contextStart := max(0, i-model.n+1)
+		context := make([]string, i-contextStart, model.n)
+		copy(context, tokens[contextStart:i])
+		// Tokens the model gives no probability are skipped, as in CrossEntropy
+		if prob := model.Probability(tokens[i], context); prob > 0 {
+			total -= math.Log2(prob)
+			scores[len(scores)-1].TokenCount++
+		}
+	}
+	closeLine(scores, total)
+
+	scored := scores[:0]
+	for _, score := range scores {
+		if score.TokenCount > 0 {
+			scored = append(scored, score)
+		}
+	}
+	return scored
+}
+
+// closeLine sets the entropy of the last line of scores from the line's total surprisal
+func closeLine(scores []LineScore, total float64) {
+	if len(scores) == 0 {
+		return
+	}
+	last := &scores[len(scores)-1]
+	if last.TokenCount > 0 {
+		last.Entropy = total / float64(last.TokenCount)
+	}
+}
@@ -0,0 +1,106 @@
+package ngram
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestScoreLines(t *testing.T) {
+	model := NewNGramModelTrie(3, NewWittenBellSmoother())
+	for i := 0; i < 20; i++ {
+		model.Add([]string{"x", "=", "ID", "+", "1", ";"})
+	}
+
+	tokens := []string{"x", "=", "ID", "+", "1", ";", "ID", "ID", "ID", "ID", ";"}
+	lines := []int{1, 1, 1, 1, 1, 1, 3, 3, 3, 3, 3}
+	scores := scoreLines(model, tokens, lines)
+	if len(scores) != 2 || scores[0].Line != 1 || scores[1].Line != 3 {
+		t.Fatalf("scoreLines = %+v, want lines 1 and 3", scores)
+	}
+	if scores[0].TokenCount != 6 || scores[1].TokenCount != 5 {
+		t.Errorf("token counts = %d and %d, want 6 and 5", scores[0].TokenCount, scores[1].TokenCount)
+	}
+	if scores[1].Entropy <= scores[0].Entropy {
+		t.Errorf("unseen line scored %f, not above the common line %f", scores[1].Entropy, scores[0].Entropy)
+	}
+
+	analysis := &CodeAnalysis{Lines: []LineScore{
+		{Line: 1, TokenCount: 6, ZScore: 0.5},
+		{Line: 2, TokenCount: 2, ZScore: 4},
+		{Line: 3, TokenCount: 5, ZScore: 2.5},
+		{Line: 4, TokenCount: 5, ZScore: 1.5},
+	}}
+	findings := analysis.Findings("a.go", DefaultFindingZScore)
+	if len(findings) != 1 || findings[0].Line != 3 || findings[0].Level != "very_high" {
+		t.Errorf("Findings = %+v, want line 3 only", findings)
+	}
+}
+
+func TestNewSARIFLog(t *testing.T) {
+	log := NewSARIFLog("repo", []Finding{
+		{FilePath: "a.go", Line: 3, Entropy: 9, ZScore: 2.5, Level: "very_high"},
+		{FilePath: "b.go", Line: 7, Entropy: 7, ZScore: 1.5, Level: "high"},
+	})
+	data, err := json.Marshal(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Results []struct {
+				RuleID    string `json:"ruleId"`
+				Level     string `json:"level"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							URI string `json:"uri"`
+						} `json:"artifactLocation"`
+						Region struct {
+							StartLine int `json:"startLine"`
+						} `json:"region"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Version != "2.1.0" || len(decoded.Runs) != 1 || len(decoded.Runs[0].Results) != 2 {
+		t.Fatalf("unexpected log %s", data)
+	}
+	first, second := decoded.Runs[0].Results[0], decoded.Runs[0].Results[1]
+	location := first.Locations[0].PhysicalLocation
+	if first.RuleID != UnnaturalLineRule || location.ArtifactLocation.URI != "a.go" || location.Region.StartLine != 3 {
+		t.Errorf("unexpected first result %+v", first)
+	}
+	if first.Level != "warning" || second.Level != "note" {
+		t.Errorf("levels = %q and %q, want warning and note", first.Level, second.Level)
+	}
+}
+
+func TestGetDirectoryProfile(t *testing.T) {
+	cm := &CorpusManager{fileModels: map[string]*FileModel{
+		"/repo/a/x.go":   {Language: "go", TokenCount: 10, Entropy: 2},
+		"/repo/a/y.go":   {Language: "go", TokenCount: 20, Entropy: 4},
+		"/repo/a/b/z.py": {Language: "python", TokenCount: 5, Entropy: 6},
+		"/repo/w.go":     {Language: "go", TokenCount: 5, Entropy: 8},
+	}}
+
+	profile := cm.GetDirectoryProfile(context.Background(), "/repo/a/")
+	if profile.Directory != "/repo/a" || profile.Files != 2 || profile.Tokens != 30 || profile.Languages["go"] != 2 {
+		t.Errorf("GetDirectoryProfile = %+v, want the 2 Go files of /repo/a", profile)
+	}
+	if profile.Entropy.Mean != 3 || profile.Repository.Mean != 5 || profile.Repository.Count != 4 {
+		t.Errorf("entropy = %+v and repository = %+v, want means 3 and 5", profile.Entropy, profile.Repository)
+	}
+	if profile.ZScore >= 0 {
+		t.Errorf("ZScore = %f, want below 0 for a directory more natural than the repository", profile.ZScore)
+	}
+
+	if empty := cm.GetDirectoryProfile(context.Background(), "/repo/none"); empty.Files != 0 || empty.ZScore != 0 {
+		t.Errorf("GetDirectoryProfile of an unknown directory = %+v, want no files", empty)
+	}
+}
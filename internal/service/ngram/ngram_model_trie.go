@@ -203,6 +203,23 @@ func (m *NGramModelTrie) Perplexity(tokens []string) float64 {
 	return math.Pow(2, entropy)
 }
 
+// Coverage returns the fraction of the sequence's n-grams that occur in the model, a
+// smoothing-free lexical overlap score in [0, 1]
+func (m *NGramModelTrie) Coverage(tokens []string) float64 {
+	ngrams := m.extractNGrams(tokens)
+	if len(ngrams) == 0 {
+		return 0.0
+	}
+
+	seen := 0
+	for _, ng := range ngrams {
+		if m.ngramTrie.GetCount(ng) > 0 {
+			seen++
+		}
+	}
+	return float64(seen) / float64(len(ngrams))
+}
+
 // extractNGrams extracts all n-grams from a token sequence (returns as []string slices)
 func (m *NGramModelTrie) extractNGrams(tokens []string) [][]string {
 	if len(tokens) == 0 {
@@ -40,6 +40,8 @@ type SerializableNGramModel struct {
 // FileMetadata stores minimal file information for statistics
 type FileMetadata struct {
 	Path       string  `json:"path"`
+	FileID     int32   `json:"file_id"`
+	CommitSHA  string  `json:"commit_sha"`
 	Language   string  `json:"language"`
 	TokenCount int     `json:"token_count"`
 	Entropy    float64 `json:"entropy"`
@@ -93,6 +95,8 @@ func (p *NGramPersistence) SaveCorpusManager(cm *CorpusManager, repoName string)
 	for path, fm := range cm.fileModels {
 		model.FileMetadata[path] = FileMetadata{
 			Path:       path,
+			FileID:     fm.FileID,
+			CommitSHA:  fm.CommitSHA,
 			Language:   fm.Language,
 			TokenCount: fm.TokenCount,
 			Entropy:    fm.Entropy,
@@ -149,11 +153,16 @@ func (p *NGramPersistence) LoadCorpusManager(repoName string, tokenizerRegistry
 	for path, metadata := range model.FileMetadata {
 		cm.fileModels[path] = &FileModel{
 			FilePath:     metadata.Path,
+			FileID:       metadata.FileID,
+			CommitSHA:    metadata.CommitSHA,
 			Language:     metadata.Language,
 			TokenCount:   metadata.TokenCount,
 			Entropy:      metadata.Entropy,
 			LastModified: model.CreatedAt,
 		}
+		if metadata.FileID != 0 {
+			cm.fileIDs[metadata.FileID] = path
+		}
 	}
 	cm.mu.Unlock()
 
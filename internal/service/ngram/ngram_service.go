@@ -1,6 +1,7 @@
 package ngram
 
 import (
+	"bot-go/internal/apperrors"
 	"bot-go/internal/config"
 	"bot-go/internal/service/tokenizer"
 	"bot-go/internal/util"
@@ -64,6 +65,12 @@ func NewNGramServiceWithOutputDir(outputDir string, logger *zap.Logger) (*NGramS
 	}
 	registry.Register("java", javaTokenizer, []string{".java"})
 
+	rustTokenizer, err := tokenizer.NewRustTokenizer()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Rust tokenizer: %w", err)
+	}
+	registry.Register("rust", rustTokenizer, []string{".rs"})
+
 	// Initialize persistence
 	persistence, err := NewNGramPersistence(outputDir, logger)
 	if err != nil {
@@ -80,6 +87,18 @@ func NewNGramServiceWithOutputDir(outputDir string, logger *zap.Logger) (*NGramS
 
 // ProcessRepository processes all files in a repository and builds n-gram models
 func (ns *NGramService) ProcessRepository(ctx context.Context, repo *config.Repository, n int, override bool) error {
+	return ns.ProcessRepositoryWithFileIDs(ctx, repo, n, override, nil)
+}
+
+// FileIdentity identifies the indexed version of a file (shared with CodeGraph and vector chunks)
+type FileIdentity struct {
+	FileID    int32
+	CommitSHA string
+}
+
+// ProcessRepositoryWithFileIDs builds (or loads) the repository model and tags each file
+// model with its FileID. fileIDs is keyed by absolute file path and may be nil.
+func (ns *NGramService) ProcessRepositoryWithFileIDs(ctx context.Context, repo *config.Repository, n int, override bool, fileIDs map[string]FileIdentity) error {
 	ns.logger.Info("Processing repository for n-gram model",
 		zap.String("repo", repo.Name),
 		zap.String("path", repo.Path),
@@ -98,6 +117,14 @@ func (ns *NGramService) ProcessRepository(ctx context.Context, repo *config.Repo
 			ns.corpusManagers[repo.Name] = corpusManager
 			ns.mu.Unlock()
 
+			if applied := ns.applyFileIdentities(corpusManager, fileIDs); applied > 0 {
+				if err := ns.persistence.SaveCorpusManager(corpusManager, repo.Name); err != nil {
+					ns.logger.Warn("Failed to save n-gram model after updating file IDs",
+						zap.String("repo", repo.Name),
+						zap.Error(err))
+				}
+			}
+
 			ns.logger.Info("Successfully loaded n-gram model from disk",
 				zap.String("repo", repo.Name))
 			return nil
@@ -119,7 +146,7 @@ func (ns *NGramService) ProcessRepository(ctx context.Context, repo *config.Repo
 	fileCount := 0
 	var mu sync.Mutex
 
-	err := util.WalkDirTree(repo.Path,
+	err := util.WalkDirTreeWithOptions(ctx, repo.Path,
 		// Walk function - called for each file
 		func(path string, err error) error {
 			if err != nil {
@@ -147,6 +174,11 @@ func (ns *NGramService) ProcessRepository(ctx context.Context, repo *config.Repo
 				return nil
 			}
 
+			// Binary, non-UTF8, large, generated and minified files would skew the corpus statistics
+			if util.ContentSkipReason(source) != "" || util.LightweightReason(path, source, repo) != "" {
+				return nil
+			}
+
 			// Add file to corpus
 			err = corpusManager.AddFile(ctx, path, source, language)
 			if err != nil {
@@ -157,6 +189,10 @@ func (ns *NGramService) ProcessRepository(ctx context.Context, repo *config.Repo
 				return nil
 			}
 
+			if identity, ok := fileIDs[path]; ok {
+				corpusManager.SetFileIdentity(path, identity.FileID, identity.CommitSHA)
+			}
+
 			mu.Lock()
 			fileCount++
 			currentCount := fileCount
@@ -181,8 +217,11 @@ func (ns *NGramService) ProcessRepository(ctx context.Context, repo *config.Repo
 			return false
 		},
 		ns.logger,
-		0, // gcThreshold: 0 = disabled
-		2, // numThreads: use 2 workers
+		0,   // gcThreshold: 0 = disabled
+		2,   // numThreads: use 2 workers
+		nil, // no memory governor
+		nil, // no resume cursor
+		util.WalkOptionsFor(repo),
 	)
 
 	if err != nil {
@@ -208,6 +247,41 @@ func (ns *NGramService) ProcessRepository(ctx context.Context, repo *config.Repo
 	return nil
 }
 
+// LoadPersistedModel loads a repository's saved n-gram model into memory without walking the
+// repository. It returns false when the model is already loaded or nothing was saved.
+func (ns *NGramService) LoadPersistedModel(repoName string) (bool, error) {
+	ns.mu.RLock()
+	_, loaded := ns.corpusManagers[repoName]
+	ns.mu.RUnlock()
+	if loaded || !ns.persistence.ModelExists(repoName) {
+		return false, nil
+	}
+
+	corpusManager, err := ns.persistence.LoadCorpusManager(repoName, ns.registry, ns.logger)
+	if err != nil {
+		return false, fmt.Errorf("failed to load n-gram model for %s: %w", repoName, err)
+	}
+
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	if _, loaded := ns.corpusManagers[repoName]; loaded {
+		return false, nil
+	}
+	ns.corpusManagers[repoName] = corpusManager
+	return true, nil
+}
+
+// applyFileIdentities tags already loaded file models with their FileIDs. Returns the number of files updated.
+func (ns *NGramService) applyFileIdentities(cm *CorpusManager, fileIDs map[string]FileIdentity) int {
+	applied := 0
+	for path, identity := range fileIDs {
+		if err := cm.SetFileIdentity(path, identity.FileID, identity.CommitSHA); err == nil {
+			applied++
+		}
+	}
+	return applied
+}
+
 // GetCorpusManager returns the corpus manager for a repository
 func (ns *NGramService) GetCorpusManager(repoName string) (*CorpusManager, error) {
 	ns.mu.RLock()
@@ -215,7 +289,7 @@ func (ns *NGramService) GetCorpusManager(repoName string) (*CorpusManager, error
 
 	cm, exists := ns.corpusManagers[repoName]
 	if !exists {
-		return nil, fmt.Errorf("no corpus manager found for repository: %s", repoName)
+		return nil, apperrors.NotFound("n-gram corpus for repository", repoName)
 	}
 
 	return cm, nil
@@ -231,6 +305,28 @@ func (ns *NGramService) GetFileEntropy(ctx context.Context, repoName, filePath s
 	return cm.GetFileEntropy(ctx, filePath)
 }
 
+// GetDirectoryProfile returns the naturalness profile of the files directly in a directory
+// of a repository. The directory is absolute, like the paths of the processed files.
+func (ns *NGramService) GetDirectoryProfile(ctx context.Context, repoName, dir string) (*DirectoryProfile, error) {
+	cm, err := ns.GetCorpusManager(repoName)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := cm.GetDirectoryProfile(ctx, dir)
+	return &profile, nil
+}
+
+// GetFileModelByID returns the n-gram file model for a FileID
+func (ns *NGramService) GetFileModelByID(ctx context.Context, repoName string, fileID int32) (*FileModel, error) {
+	cm, err := ns.GetCorpusManager(repoName)
+	if err != nil {
+		return nil, err
+	}
+
+	return cm.GetFileModelByID(ctx, fileID)
+}
+
 // GetRepositoryStats returns statistics for a repository
 func (ns *NGramService) GetRepositoryStats(ctx context.Context, repoName string) (*CorpusStats, error) {
 	cm, err := ns.GetCorpusManager(repoName)
@@ -252,7 +348,7 @@ func (ns *NGramService) AnalyzeCode(ctx context.Context, repoName, language stri
 	// Get tokenizer for language
 	tokenizer, ok := ns.registry.GetTokenizer(language)
 	if !ok {
-		return nil, fmt.Errorf("no tokenizer found for language: %s", language)
+		return nil, fmt.Errorf("%w: no tokenizer for %s", apperrors.ErrUnsupportedLanguage, language)
 	}
 
 	// Tokenize code
@@ -263,9 +359,11 @@ func (ns *NGramService) AnalyzeCode(ctx context.Context, repoName, language stri
 
 	// Normalize tokens
 	normalizedTokens := make([]string, 0, len(tokens))
+	lines := make([]int, 0, len(tokens))
 	for _, token := range tokens {
 		normalized := tokenizer.Normalize(token)
 		normalizedTokens = append(normalizedTokens, normalized)
+		lines = append(lines, token.Line)
 	}
 
 	// Calculate entropy and perplexity using global model
@@ -273,14 +371,87 @@ func (ns *NGramService) AnalyzeCode(ctx context.Context, repoName, language stri
 	entropy := globalModel.CrossEntropy(normalizedTokens)
 	perplexity := globalModel.Perplexity(normalizedTokens)
 
+	lineScores := scoreLines(globalModel, normalizedTokens, lines)
+	for i := range lineScores {
+		lineScores[i].ZScore = cm.CalculateZScore(ctx, lineScores[i].Entropy)
+	}
+
 	return &CodeAnalysis{
 		TokenCount: len(normalizedTokens),
 		Entropy:    entropy,
 		Perplexity: perplexity,
+		ZScore:     cm.CalculateZScore(ctx, entropy),
 		Language:   language,
+		Lines:      lineScores,
 	}, nil
 }
 
+// AnalyzeFile analyzes the code of a file like AnalyzeCode. An empty language is detected
+// from the file's extension.
+func (ns *NGramService) AnalyzeFile(ctx context.Context, repoName, filePath, language string, code []byte) (*CodeAnalysis, error) {
+	if language == "" {
+		language = ns.detectLanguage(filePath)
+		if language == "" {
+			return nil, fmt.Errorf("%w: cannot detect the language of %s", apperrors.ErrUnsupportedLanguage, filePath)
+		}
+	}
+	return ns.AnalyzeCode(ctx, repoName, language, code)
+}
+
+// SearchLexical ranks the repository's files by n-gram overlap with a code snippet. It
+// needs no embedding model, so it serves as a fallback when vector search is unavailable.
+func (ns *NGramService) SearchLexical(ctx context.Context, repoName, language string, code []byte, limit int) ([]LexicalMatch, error) {
+	cm, err := ns.GetCorpusManager(repoName)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := ns.normalizedTokens(ctx, language, code)
+	if err != nil {
+		return nil, err
+	}
+	return cm.RankFilesByOverlap(tokens, language, limit), nil
+}
+
+// FileOverlapScorer returns a function scoring the share of the snippet's n-grams found in
+// a corpus file, as SearchLexical does. It reports false for files not in the corpus.
+func (ns *NGramService) FileOverlapScorer(ctx context.Context, repoName, language string, code []byte) (func(filePath string) (float64, bool), error) {
+	cm, err := ns.GetCorpusManager(repoName)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := ns.normalizedTokens(ctx, language, code)
+	if err != nil {
+		return nil, err
+	}
+	return func(filePath string) (float64, bool) {
+		fm, err := cm.GetFileModel(ctx, filePath)
+		if err != nil {
+			return 0, false
+		}
+		return fm.Model.Coverage(tokens), true
+	}, nil
+}
+
+// normalizedTokens tokenizes code with the language's tokenizer and normalizes each token
+func (ns *NGramService) normalizedTokens(ctx context.Context, language string, code []byte) ([]string, error) {
+	tokenizer, ok := ns.registry.GetTokenizer(language)
+	if !ok {
+		return nil, fmt.Errorf("%w: no tokenizer for %s", apperrors.ErrUnsupportedLanguage, language)
+	}
+
+	tokens, err := tokenizer.Tokenize(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("tokenization failed: %w", err)
+	}
+
+	normalizedTokens := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		normalizedTokens = append(normalizedTokens, tokenizer.Normalize(token))
+	}
+	return normalizedTokens, nil
+}
+
 // CalculateZScore analyzes code and calculates z-score with detailed n-gram information
 func (ns *NGramService) CalculateZScore(ctx context.Context, repoName, language string, code []byte) (*ZScoreAnalysis, error) {
 	cm, err := ns.GetCorpusManager(repoName)
@@ -291,7 +462,7 @@ func (ns *NGramService) CalculateZScore(ctx context.Context, repoName, language
 	// Get tokenizer for language
 	tokenizer, ok := ns.registry.GetTokenizer(language)
 	if !ok {
-		return nil, fmt.Errorf("no tokenizer found for language: %s", language)
+		return nil, fmt.Errorf("%w: no tokenizer for %s", apperrors.ErrUnsupportedLanguage, language)
 	}
 
 	// Tokenize code
@@ -473,6 +644,8 @@ func (ns *NGramService) detectLanguage(filePath string) string {
 		return "typescript"
 	case ".java":
 		return "java"
+	case ".rs":
+		return "rust"
 	default:
 		return ""
 	}
@@ -495,10 +668,12 @@ func (ns *NGramService) readFile(filePath string) ([]byte, error) {
 
 // CodeAnalysis contains the analysis results for a code snippet
 type CodeAnalysis struct {
-	TokenCount int     `json:"token_count"`
-	Entropy    float64 `json:"entropy"`
-	Perplexity float64 `json:"perplexity"`
-	Language   string  `json:"language"`
+	TokenCount int         `json:"token_count"`
+	Entropy    float64     `json:"entropy"`
+	Perplexity float64     `json:"perplexity"`
+	ZScore     float64     `json:"z_score"` // Entropy against the corpus file entropies
+	Language   string      `json:"language"`
+	Lines      []LineScore `json:"lines"` // Lines with tokens, in order
 }
 
 // ZScoreAnalysis contains z-score analysis results
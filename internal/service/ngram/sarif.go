@@ -0,0 +1,120 @@
+package ngram
+
+import "fmt"
+
+// SARIF 2.1.0 (https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html) output
+// of naturalness findings, which CI systems upload as code scanning annotations. Only the
+// parts of the format the findings use are modeled.
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+
+	// UnnaturalLineRule is the SARIF rule of naturalness findings
+	UnnaturalLineRule = "ngram/unnatural-line"
+)
+
+// SARIFLog is a SARIF log with one run
+type SARIFLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []SARIFRun `json:"runs"`
+}
+
+type SARIFRun struct {
+	Tool    SARIFTool     `json:"tool"`
+	Results []SARIFResult `json:"results"`
+}
+
+type SARIFTool struct {
+	Driver SARIFDriver `json:"driver"`
+}
+
+type SARIFDriver struct {
+	Name  string      `json:"name"`
+	Rules []SARIFRule `json:"rules"`
+}
+
+type SARIFRule struct {
+	ID                   string             `json:"id"`
+	Name                 string             `json:"name"`
+	ShortDescription     SARIFMessage       `json:"shortDescription"`
+	FullDescription      SARIFMessage       `json:"fullDescription"`
+	DefaultConfiguration SARIFConfiguration `json:"defaultConfiguration"`
+}
+
+type SARIFConfiguration struct {
+	Level string `json:"level"`
+}
+
+type SARIFMessage struct {
+	Text string `json:"text"`
+}
+
+type SARIFResult struct {
+	RuleID     string             `json:"ruleId"`
+	Level      string             `json:"level"` // "warning" or "note"
+	Message    SARIFMessage       `json:"message"`
+	Locations  []SARIFLocation    `json:"locations"`
+	Properties map[string]float64 `json:"properties"`
+}
+
+type SARIFLocation struct {
+	PhysicalLocation SARIFPhysicalLocation `json:"physicalLocation"`
+}
+
+type SARIFPhysicalLocation struct {
+	ArtifactLocation SARIFArtifactLocation `json:"artifactLocation"`
+	Region           SARIFRegion           `json:"region"`
+}
+
+type SARIFArtifactLocation struct {
+	URI string `json:"uri"` // Relative to the repository root
+}
+
+type SARIFRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// NewSARIFLog converts findings into a SARIF log. Findings interpreted as "very_high" are
+// warnings and the others, reported when the threshold is lowered, are notes.
+func NewSARIFLog(repoName string, findings []Finding) *SARIFLog {
+	results := make([]SARIFResult, len(findings))
+	for i, f := range findings {
+		level := "note"
+		if f.Level == "very_high" {
+			level = "warning"
+		}
+		results[i] = SARIFResult{
+			RuleID: UnnaturalLineRule,
+			Level:  level,
+			Message: SARIFMessage{Text: fmt.Sprintf(
+				"This line is unusual for %s (entropy %.2f bits, z-score %.2f); check it for bugs or code that does not follow the repository's conventions",
+				repoName, f.Entropy, f.ZScore)},
+			Locations: []SARIFLocation{{PhysicalLocation: SARIFPhysicalLocation{
+				ArtifactLocation: SARIFArtifactLocation{URI: f.FilePath},
+				Region:           SARIFRegion{StartLine: f.Line},
+			}}},
+			Properties: map[string]float64{"entropy": f.Entropy, "zScore": f.ZScore},
+		}
+	}
+
+	return &SARIFLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []SARIFRun{{
+			Tool: SARIFTool{Driver: SARIFDriver{
+				Name: "bot-go",
+				Rules: []SARIFRule{{
+					ID:               UnnaturalLineRule,
+					Name:             "UnnaturalLine",
+					ShortDescription: SARIFMessage{Text: "Line with unusually high n-gram entropy"},
+					FullDescription: SARIFMessage{Text: "The line's tokens are improbable under the repository's n-gram model. " +
+						"Unnatural code is more likely to contain bugs."},
+					DefaultConfiguration: SARIFConfiguration{Level: "warning"},
+				}},
+			}},
+			Results: results,
+		}},
+	}
+}
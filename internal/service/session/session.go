@@ -0,0 +1,372 @@
+// Package session keeps per-client working sets for agents: which graph nodes and chunks
+// retrieval endpoints already returned, so later queries can skip or demote them and the
+// client can ask for everything it has seen so far.
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"bot-go/internal/apperrors"
+	"bot-go/internal/cache"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Item kinds
+const (
+	KindNode  = "node"  // CodeGraph node, ID is the node ID
+	KindChunk = "chunk" // vector chunk, ID is the chunk ID
+)
+
+// Seen modes accepted by Order
+const (
+	SeenExclude      = "exclude"
+	SeenDeprioritize = "deprioritize"
+)
+
+// Defaults used when NewStore gets zero values
+const (
+	DefaultTTL      = 30 * time.Minute
+	DefaultMaxItems = 5000
+)
+
+// Item is a node or chunk returned to the client during a session
+type Item struct {
+	Kind      string    `json:"kind"`
+	ID        string    `json:"id"`
+	Name      string    `json:"name,omitempty"`
+	FilePath  string    `json:"file_path,omitempty"`
+	Source    string    `json:"source"` // endpoint that first returned the item
+	Hits      int       `json:"hits"`   // times the item was returned
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// Snapshot is the accumulated working set of a session
+type Snapshot struct {
+	ID        string    `json:"session_id"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Items     []Item    `json:"items"` // in the order they were first returned
+}
+
+// Session is one client's working set. It is safe for concurrent use.
+type Session struct {
+	id        string
+	createdAt time.Time
+	store     *Store
+
+	mu       sync.Mutex
+	lastUsed time.Time
+	items    map[string]*Item // kind + "/" + id -> item
+	order    []string         // keys in first-seen order
+}
+
+// ID returns the session ID
+func (s *Session) ID() string {
+	return s.id
+}
+
+// Record adds items to the working set, or bumps their hit count if already present.
+// When the set exceeds the store's limit the oldest items are forgotten.
+func (s *Session) Record(items ...Item) {
+	s.record(items)
+	s.store.share(s)
+}
+
+func (s *Session) record(items []Item) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.store.now()
+	s.lastUsed = now
+	for _, item := range items {
+		key := itemKey(item.Kind, item.ID)
+		if existing, ok := s.items[key]; ok {
+			existing.Hits++
+			existing.LastSeen = now
+			continue
+		}
+		item.Hits = 1
+		item.FirstSeen = now
+		item.LastSeen = now
+		s.items[key] = &item
+		s.order = append(s.order, key)
+	}
+
+	if excess := len(s.order) - s.store.maxItems; excess > 0 {
+		for _, key := range s.order[:excess] {
+			delete(s.items, key)
+		}
+		s.order = append([]string(nil), s.order[excess:]...)
+	}
+}
+
+// Seen reports whether the item was already returned in this session
+func (s *Session) Seen(kind, id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.items[itemKey(kind, id)]
+	return ok
+}
+
+// Snapshot returns a copy of the working set
+func (s *Session) Snapshot() Snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := make([]Item, 0, len(s.order))
+	for _, key := range s.order {
+		items = append(items, *s.items[key])
+	}
+	return Snapshot{
+		ID:        s.id,
+		CreatedAt: s.createdAt,
+		ExpiresAt: s.lastUsed.Add(s.store.ttl),
+		Items:     items,
+	}
+}
+
+func (s *Session) expired(now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return now.Sub(s.lastUsed) > s.store.ttl
+}
+
+func (s *Session) touch(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastUsed = now
+}
+
+// Store holds the open sessions in memory, and in a shared cache when one is set. Sessions
+// expire after a period without use.
+type Store struct {
+	ttl      time.Duration
+	maxItems int
+	now      func() time.Time
+	cache    cache.Cache // optional; shares the sessions with other instances
+	logger   *zap.Logger
+
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+// NewStore creates a session store. Zero values select DefaultTTL and DefaultMaxItems.
+func NewStore(ttl time.Duration, maxItems int) *Store {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	if maxItems <= 0 {
+		maxItems = DefaultMaxItems
+	}
+	return &Store{
+		ttl:      ttl,
+		maxItems: maxItems,
+		now:      time.Now,
+		sessions: make(map[string]*Session),
+	}
+}
+
+// SetCache shares the sessions with the other instances using c. Every Get loads the
+// session from c and every change writes it back, so when two instances record items of one
+// session at the same time the items of one may be lost. Sessions are served from memory
+// while c fails.
+func (st *Store) SetCache(c cache.Cache, logger *zap.Logger) {
+	st.cache = c
+	st.logger = logger
+}
+
+// Open starts a new session. Expired sessions are dropped at the same time.
+func (st *Store) Open() *Session {
+	now := st.now()
+	session := &Session{
+		id:        uuid.NewString(),
+		createdAt: now,
+		store:     st,
+		lastUsed:  now,
+		items:     make(map[string]*Item),
+	}
+
+	st.share(session)
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for id, s := range st.sessions {
+		if s.expired(now) {
+			delete(st.sessions, id)
+		}
+	}
+	st.sessions[session.id] = session
+	return session
+}
+
+// Get returns an open session and extends its lifetime
+func (st *Store) Get(id string) (*Session, error) {
+	now := st.now()
+	if st.cache != nil {
+		session, ok, err := st.load(id)
+		switch {
+		case err != nil:
+			st.logger.Warn("Failed to load shared session, using the copy in memory",
+				zap.String("session_id", id),
+				zap.Error(err))
+		case !ok:
+			st.mu.Lock()
+			delete(st.sessions, id)
+			st.mu.Unlock()
+			return nil, apperrors.NotFound("session", id)
+		default:
+			session.touch(now)
+			st.share(session)
+			st.mu.Lock()
+			st.sessions[id] = session
+			st.mu.Unlock()
+			return session, nil
+		}
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	session, ok := st.sessions[id]
+	if ok && session.expired(now) {
+		delete(st.sessions, id)
+		ok = false
+	}
+	if !ok {
+		return nil, apperrors.NotFound("session", id)
+	}
+	session.touch(now)
+	return session, nil
+}
+
+// Close ends a session and forgets its working set
+func (st *Store) Close(id string) error {
+	shared := false
+	if st.cache != nil {
+		ctx := context.Background()
+		_, found, err := st.cache.Get(ctx, sessionCacheKey(id))
+		if err == nil && found {
+			err = st.cache.Delete(ctx, sessionCacheKey(id))
+		}
+		if err != nil {
+			st.logger.Warn("Failed to close shared session", zap.String("session_id", id), zap.Error(err))
+		}
+		shared = found
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if _, ok := st.sessions[id]; !ok && !shared {
+		return apperrors.NotFound("session", id)
+	}
+	delete(st.sessions, id)
+	return nil
+}
+
+// sharedSession is a session as kept in the shared cache
+type sharedSession struct {
+	CreatedAt time.Time `json:"created_at"`
+	LastUsed  time.Time `json:"last_used"`
+	Items     []Item    `json:"items"` // in first-seen order
+}
+
+func sessionCacheKey(id string) string {
+	return "session:" + id
+}
+
+// share writes a session to the shared cache, expiring it with the session
+func (st *Store) share(s *Session) {
+	if st.cache == nil {
+		return
+	}
+	s.mu.Lock()
+	shared := sharedSession{CreatedAt: s.createdAt, LastUsed: s.lastUsed, Items: make([]Item, 0, len(s.order))}
+	for _, key := range s.order {
+		shared.Items = append(shared.Items, *s.items[key])
+	}
+	s.mu.Unlock()
+
+	data, err := json.Marshal(shared)
+	if err == nil {
+		err = st.cache.Set(context.Background(), sessionCacheKey(s.id), data, st.ttl)
+	}
+	if err != nil {
+		st.logger.Warn("Failed to share session", zap.String("session_id", s.id), zap.Error(err))
+	}
+}
+
+// load reads a session from the shared cache; ok is false when it is closed or expired
+func (st *Store) load(id string) (session *Session, ok bool, err error) {
+	data, ok, err := st.cache.Get(context.Background(), sessionCacheKey(id))
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	var shared sharedSession
+	if err := json.Unmarshal(data, &shared); err != nil {
+		return nil, false, err
+	}
+	session = &Session{
+		id:        id,
+		createdAt: shared.CreatedAt,
+		store:     st,
+		lastUsed:  shared.LastUsed,
+		items:     make(map[string]*Item, len(shared.Items)),
+	}
+	for i := range shared.Items {
+		item := &shared.Items[i]
+		key := itemKey(item.Kind, item.ID)
+		session.items[key] = item
+		session.order = append(session.order, key)
+	}
+	return session, true, nil
+}
+
+// Order applies a seen mode to results of a retrieval: "exclude" drops results already
+// returned in the session, "deprioritize" moves them after the unseen ones keeping the
+// relative order, and "" keeps the results unchanged. keyOf maps a result to its kind and ID.
+func Order[T any](s *Session, mode string, results []T, keyOf func(T) (kind, id string)) []T {
+	if s == nil || mode == "" {
+		return results
+	}
+
+	seen := make([]bool, len(results))
+	for i, r := range results {
+		kind, id := keyOf(r)
+		seen[i] = s.Seen(kind, id)
+	}
+
+	switch mode {
+	case SeenExclude:
+		kept := make([]T, 0, len(results))
+		for i, r := range results {
+			if !seen[i] {
+				kept = append(kept, r)
+			}
+		}
+		return kept
+	case SeenDeprioritize:
+		indices := make([]int, len(results))
+		for i := range indices {
+			indices[i] = i
+		}
+		sort.SliceStable(indices, func(a, b int) bool {
+			return !seen[indices[a]] && seen[indices[b]]
+		})
+		ordered := make([]T, len(results))
+		for i, idx := range indices {
+			ordered[i] = results[idx]
+		}
+		return ordered
+	}
+	return results
+}
+
+func itemKey(kind, id string) string {
+	return kind + "/" + id
+}
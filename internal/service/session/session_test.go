@@ -0,0 +1,86 @@
+package session
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"bot-go/internal/apperrors"
+	"bot-go/internal/cache"
+
+	"go.uber.org/zap"
+)
+
+func TestSessionRecordAndOrder(t *testing.T) {
+	store := NewStore(time.Minute, 0)
+	s := store.Open()
+	s.Record(Item{Kind: KindNode, ID: "2", Source: "symbols"})
+	s.Record(Item{Kind: KindNode, ID: "2", Source: "class"}, Item{Kind: KindChunk, ID: "c1"})
+
+	snap := s.Snapshot()
+	if len(snap.Items) != 2 || snap.Items[0].Hits != 2 || snap.Items[0].Source != "symbols" {
+		t.Fatalf("unexpected working set: %+v", snap.Items)
+	}
+
+	ids := []string{"1", "2", "3"}
+	key := func(id string) (string, string) { return KindNode, id }
+	if got := Order(s, SeenExclude, ids, key); len(got) != 2 || got[0] != "1" || got[1] != "3" {
+		t.Errorf("exclude: got %v", got)
+	}
+	if got := Order(s, SeenDeprioritize, ids, key); got[0] != "1" || got[1] != "3" || got[2] != "2" {
+		t.Errorf("deprioritize: got %v", got)
+	}
+	if got := Order[string](nil, SeenExclude, ids, key); len(got) != 3 {
+		t.Errorf("without a session results should be unchanged, got %v", got)
+	}
+}
+
+func TestSessionExpiryAndLimit(t *testing.T) {
+	now := time.Now()
+	store := NewStore(time.Minute, 2)
+	store.now = func() time.Time { return now }
+
+	s := store.Open()
+	s.Record(Item{Kind: KindChunk, ID: "a"}, Item{Kind: KindChunk, ID: "b"}, Item{Kind: KindChunk, ID: "c"})
+	if s.Seen(KindChunk, "a") || !s.Seen(KindChunk, "c") {
+		t.Error("expected the oldest item to be evicted")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, err := store.Get(s.ID()); !errors.Is(err, apperrors.ErrNotFound) {
+		t.Errorf("expected expired session to be gone, got %v", err)
+	}
+}
+
+func TestSharedSessions(t *testing.T) {
+	shared := cache.NewMemory()
+	a, b := NewStore(time.Minute, 0), NewStore(time.Minute, 0)
+	a.SetCache(shared, zap.NewNop())
+	b.SetCache(shared, zap.NewNop())
+
+	s := a.Open()
+	s.Record(Item{Kind: KindNode, ID: "1", Source: "symbols"})
+	other, err := b.Get(s.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !other.Seen(KindNode, "1") {
+		t.Fatal("expected the other instance to see the recorded item")
+	}
+	other.Record(Item{Kind: KindChunk, ID: "c1"}, Item{Kind: KindNode, ID: "1"})
+
+	again, err := a.Get(s.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap := again.Snapshot(); len(snap.Items) != 2 || snap.Items[0].Hits != 2 {
+		t.Errorf("unexpected shared working set: %+v", snap.Items)
+	}
+
+	if err := b.Close(s.ID()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Get(s.ID()); !errors.Is(err, apperrors.ErrNotFound) {
+		t.Errorf("expected the session closed on another instance to be gone, got %v", err)
+	}
+}
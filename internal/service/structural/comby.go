@@ -0,0 +1,301 @@
+package structural
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"bot-go/internal/apperrors"
+)
+
+// maxCombySteps bounds the backtracking of one file, so a pattern with many holes cannot
+// stall a worker on a large file
+const maxCombySteps = 1_000_000
+
+// combySegment is a piece of a comby-style pattern
+type combySegment struct {
+	literal    string // text that must appear as is
+	whitespace bool   // a run of whitespace in the pattern: matches any whitespace
+	hole       string // name of a hole; "_" binds nothing
+	identifier bool   // :[[name]] holes match identifier characters only
+}
+
+// combyPattern is a comby-style match template. :[name] matches any text with balanced
+// (), [] and {} delimiters, lazily and without crossing an unbalanced delimiter; string
+// literals are matched whole. :[[name]] matches an identifier. A hole used twice must match
+// the same text both times. Whitespace in the template matches any run of whitespace, or
+// none where it does not separate two identifiers.
+type combyPattern struct {
+	segments []combySegment
+}
+
+func parseCombyPattern(pattern string) (*combyPattern, error) {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" {
+		return nil, fmt.Errorf("%w: the pattern is empty", apperrors.ErrInvalidArgument)
+	}
+	p := &combyPattern{}
+	var literal strings.Builder
+	flush := func() {
+		if literal.Len() > 0 {
+			p.segments = append(p.segments, combySegment{literal: literal.String()})
+			literal.Reset()
+		}
+	}
+
+	for i := 0; i < len(pattern); {
+		switch {
+		case isSpace(pattern[i]):
+			flush()
+			for i < len(pattern) && isSpace(pattern[i]) {
+				i++
+			}
+			p.segments = append(p.segments, combySegment{whitespace: true})
+		case strings.HasPrefix(pattern[i:], ":[["):
+			end := strings.Index(pattern[i:], "]]")
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unterminated hole at offset %d", apperrors.ErrInvalidArgument, i)
+			}
+			name := pattern[i+3 : i+end]
+			if !validHoleName(name) {
+				return nil, fmt.Errorf("%w: invalid hole name %q", apperrors.ErrInvalidArgument, name)
+			}
+			flush()
+			p.segments = append(p.segments, combySegment{hole: name, identifier: true})
+			i += end + 2
+		case strings.HasPrefix(pattern[i:], ":["):
+			end := strings.IndexByte(pattern[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unterminated hole at offset %d", apperrors.ErrInvalidArgument, i)
+			}
+			name := pattern[i+2 : i+end]
+			if !validHoleName(name) {
+				return nil, fmt.Errorf("%w: invalid hole name %q", apperrors.ErrInvalidArgument, name)
+			}
+			flush()
+			p.segments = append(p.segments, combySegment{hole: name})
+			i += end + 1
+		default:
+			literal.WriteByte(pattern[i])
+			i++
+		}
+	}
+	flush()
+
+	for i := 1; i < len(p.segments); i++ {
+		prev, cur := p.segments[i-1], p.segments[i]
+		if prev.hole != "" && !prev.identifier && cur.hole != "" && !cur.identifier {
+			return nil, fmt.Errorf("%w: holes :[%s] and :[%s] must be separated by text", apperrors.ErrInvalidArgument, prev.hole, cur.hole)
+		}
+	}
+	// A :[name] hole is delimited by the text around it
+	for _, s := range []combySegment{p.segments[0], p.segments[len(p.segments)-1]} {
+		if s.hole != "" && !s.identifier {
+			return nil, fmt.Errorf("%w: the pattern must not start or end with a :[%s] hole", apperrors.ErrInvalidArgument, s.hole)
+		}
+	}
+	return p, nil
+}
+
+func validHoleName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !isIdentifierByte(name[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// combyMatch is one match of a pattern: its byte range and the ranges bound to its holes
+type combyMatch struct {
+	start, end int
+	holes      map[string][2]int
+}
+
+// findAll returns the non-overlapping matches of the pattern in content, leftmost first
+func (p *combyPattern) findAll(content []byte) ([]combyMatch, error) {
+	m := &combyMatcher{pattern: p, content: content}
+	var matches []combyMatch
+	for start := 0; start < len(content); {
+		start = m.nextStart(start)
+		if start < 0 {
+			break
+		}
+		m.holes = make(map[string][2]int)
+		if end, ok := m.match(0, start); ok {
+			if end > start {
+				matches = append(matches, combyMatch{start: start, end: end, holes: m.holes})
+				start = end
+				continue
+			}
+		}
+		if m.steps > maxCombySteps {
+			return matches, fmt.Errorf("%w: the pattern is too expensive to match, make it more specific", apperrors.ErrInvalidArgument)
+		}
+		start++
+	}
+	return matches, nil
+}
+
+type combyMatcher struct {
+	pattern *combyPattern
+	content []byte
+	holes   map[string][2]int
+	steps   int
+}
+
+// nextStart returns the first offset from which a match could start
+func (m *combyMatcher) nextStart(from int) int {
+	first := m.pattern.segments[0]
+	switch {
+	case first.literal != "":
+		i := bytes.Index(m.content[from:], []byte(first.literal))
+		if i < 0 {
+			return -1
+		}
+		return from + i
+	case first.identifier:
+		// Identifier holes start at the beginning of an identifier
+		for i := from; i < len(m.content); i++ {
+			if isIdentifierByte(m.content[i]) && (i == 0 || !isIdentifierByte(m.content[i-1])) {
+				return i
+			}
+		}
+		return -1
+	}
+	return from
+}
+
+// match matches segments[seg:] at pos and returns the end of the match
+func (m *combyMatcher) match(seg, pos int) (int, bool) {
+	m.steps++
+	if m.steps > maxCombySteps {
+		return 0, false
+	}
+	if seg == len(m.pattern.segments) {
+		return pos, true
+	}
+	s := m.pattern.segments[seg]
+	switch {
+	case s.literal != "":
+		if !bytes.HasPrefix(m.content[pos:], []byte(s.literal)) {
+			return 0, false
+		}
+		return m.match(seg+1, pos+len(s.literal))
+	case s.whitespace:
+		end := pos
+		for end < len(m.content) && isSpace(m.content[end]) {
+			end++
+		}
+		if end == pos {
+			// Whitespace may be left out next to punctuation, as in "f(a)" for "f( a )"
+			if pos > 0 && pos < len(m.content) && isIdentifierByte(m.content[pos-1]) && isIdentifierByte(m.content[pos]) {
+				return 0, false
+			}
+		}
+		return m.match(seg+1, end)
+	case s.identifier:
+		end := pos
+		for end < len(m.content) && isIdentifierByte(m.content[end]) {
+			end++
+		}
+		if end == pos || (pos > 0 && isIdentifierByte(m.content[pos-1])) {
+			return 0, false
+		}
+		return m.bind(s.hole, pos, end, seg)
+	default:
+		return m.matchHole(s.hole, seg, pos)
+	}
+}
+
+// bind records a hole's text, or checks it against the text the hole matched before
+func (m *combyMatcher) bind(name string, start, end, seg int) (int, bool) {
+	if name == "_" {
+		return m.match(seg+1, end)
+	}
+	if prev, ok := m.holes[name]; ok {
+		if !bytes.Equal(m.content[prev[0]:prev[1]], m.content[start:end]) {
+			return 0, false
+		}
+		return m.match(seg+1, end)
+	}
+	m.holes[name] = [2]int{start, end}
+	if result, ok := m.match(seg+1, end); ok {
+		return result, true
+	}
+	delete(m.holes, name)
+	return 0, false
+}
+
+// matchHole tries the balanced extents of a :[name] hole from pos, shortest first
+func (m *combyMatcher) matchHole(name string, seg, pos int) (int, bool) {
+	var stack []byte
+	for end := pos; end <= len(m.content); {
+		if len(stack) == 0 {
+			if result, ok := m.bind(name, pos, end, seg); ok {
+				return result, true
+			}
+			if m.steps > maxCombySteps {
+				return 0, false
+			}
+		}
+		if end == len(m.content) {
+			break
+		}
+		c := m.content[end]
+		switch c {
+		case '(', '[', '{':
+			stack = append(stack, closing(c))
+		case ')', ']', '}':
+			if len(stack) == 0 || stack[len(stack)-1] != c {
+				return 0, false // an unbalanced delimiter ends the hole's reach
+			}
+			stack = stack[:len(stack)-1]
+		case '"', '\'', '`':
+			end = skipString(m.content, end)
+			continue
+		}
+		end++
+	}
+	return 0, false
+}
+
+func closing(open byte) byte {
+	switch open {
+	case '(':
+		return ')'
+	case '[':
+		return ']'
+	}
+	return '}'
+}
+
+// skipString returns the offset after the string literal starting at i, or after the quote
+// when it is not closed on the same line
+func skipString(content []byte, i int) int {
+	quote := content[i]
+	for j := i + 1; j < len(content); j++ {
+		switch content[j] {
+		case '\\':
+			j++
+		case quote:
+			return j + 1
+		case '\n':
+			if quote != '`' {
+				return i + 1
+			}
+		}
+	}
+	return i + 1
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isIdentifierByte(c byte) bool {
+	return c == '_' || c == '$' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') || c >= 0x80
+}
@@ -0,0 +1,390 @@
+// Package structural runs structural code searches: tree-sitter queries and comby-style
+// match templates, over the file contents stored in a repository's text index.
+package structural
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"bot-go/internal/apperrors"
+	"bot-go/internal/service/textsearch"
+	"bot-go/pkg/lsp/base"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	golang "github.com/tree-sitter/tree-sitter-go/bindings/go"
+	java "github.com/tree-sitter/tree-sitter-java/bindings/go"
+	javascript "github.com/tree-sitter/tree-sitter-javascript/bindings/go"
+	python "github.com/tree-sitter/tree-sitter-python/bindings/go"
+	typescript "github.com/tree-sitter/tree-sitter-typescript/bindings/go"
+)
+
+// Limits applied when a Query leaves them unset
+const (
+	DefaultMaxMatches = 100
+	maxMatchText      = 1000 // longer match and capture texts are cut
+)
+
+// grammar is a tree-sitter grammar and the file extensions parsed with it
+type grammar struct {
+	extensions []string
+	language   func() *tree_sitter.Language
+}
+
+// grammars lists the grammars of each language; TypeScript has separate .ts and .tsx grammars
+var grammars = map[string][]grammar{
+	"go":         {{[]string{".go"}, func() *tree_sitter.Language { return tree_sitter.NewLanguage(golang.Language()) }}},
+	"python":     {{[]string{".py", ".pyw"}, func() *tree_sitter.Language { return tree_sitter.NewLanguage(python.Language()) }}},
+	"java":       {{[]string{".java"}, func() *tree_sitter.Language { return tree_sitter.NewLanguage(java.Language()) }}},
+	"javascript": {{[]string{".js", ".jsx", ".mjs"}, func() *tree_sitter.Language { return tree_sitter.NewLanguage(javascript.Language()) }}},
+	"typescript": {
+		{[]string{".ts"}, func() *tree_sitter.Language { return tree_sitter.NewLanguage(typescript.LanguageTypescript()) }},
+		{[]string{".tsx"}, func() *tree_sitter.Language { return tree_sitter.NewLanguage(typescript.LanguageTSX()) }},
+	},
+}
+
+// Query is a structural search. Exactly one of TreeSitterQuery and Pattern is set.
+type Query struct {
+	Language        string // required with TreeSitterQuery; filters the files of a Pattern
+	TreeSitterQuery string // S-expression query, e.g. (call_expression function: (identifier) @fn)
+	Pattern         string // comby-style template, e.g. "errors.Wrap(:[err], :[msg])"
+	PathPrefix      string // only files whose relative path starts with this prefix
+	MaxMatches      int
+	Workers         int // files searched in parallel (default: number of CPUs)
+}
+
+// Capture is text bound by a tree-sitter capture or a comby hole
+type Capture struct {
+	Text  string     `json:"text"`
+	Range base.Range `json:"range"`
+}
+
+// Match is one structural match. Lines are 0-based and columns are byte offsets in the line.
+type Match struct {
+	FilePath     string             `json:"file_path"`
+	RelativePath string             `json:"relative_path"`
+	FileID       int32              `json:"file_id"`
+	Range        base.Range         `json:"range"`
+	Text         string             `json:"text"`
+	Captures     map[string]Capture `json:"captures,omitempty"`
+}
+
+// Result lists the matches of a search in file order
+type Result struct {
+	Matches       []Match `json:"matches"`
+	FilesSearched int     `json:"files_searched"`
+	Truncated     bool    `json:"truncated"` // the search stopped at MaxMatches
+}
+
+// fileMatcher finds the matches in one file. Each worker has its own, since tree-sitter
+// parsers and query cursors are not safe for concurrent use.
+type fileMatcher interface {
+	matches(doc *textsearch.Document) ([]Match, error)
+	close()
+}
+
+// Search runs a structural query over docs with parallel workers. Invalid queries and
+// patterns fail with apperrors.ErrInvalidArgument.
+func Search(ctx context.Context, docs []textsearch.Document, q Query) (*Result, error) {
+	if (q.TreeSitterQuery == "") == (q.Pattern == "") {
+		return nil, fmt.Errorf("%w: exactly one of a tree-sitter query and a pattern is required", apperrors.ErrInvalidArgument)
+	}
+	if q.MaxMatches <= 0 {
+		q.MaxMatches = DefaultMaxMatches
+	}
+	if q.Workers <= 0 {
+		q.Workers = runtime.NumCPU()
+	}
+
+	newMatcher, accepts, err := compile(q)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []*textsearch.Document
+	for i := range docs {
+		doc := &docs[i]
+		if accepts(doc.FilePath) && strings.HasPrefix(doc.RelativePath, q.PathPrefix) {
+			files = append(files, doc)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	work := make(chan *textsearch.Document)
+	var (
+		mu       sync.Mutex
+		matches  []Match
+		firstErr error
+		found    atomic.Int64
+	)
+	var wg sync.WaitGroup
+	for w := 0; w < min(q.Workers, max(len(files), 1)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			matcher := newMatcher()
+			defer matcher.close()
+			for doc := range work {
+				fileMatches, err := matcher.matches(doc)
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				matches = append(matches, fileMatches...)
+				mu.Unlock()
+				if found.Add(int64(len(fileMatches))) > int64(q.MaxMatches) {
+					cancel()
+				}
+			}
+		}()
+	}
+
+	searched := 0
+feed:
+	for _, doc := range files {
+		select {
+		case work <- doc:
+			searched++
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil && found.Load() <= int64(q.MaxMatches) {
+		return nil, err
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].RelativePath != matches[j].RelativePath {
+			return matches[i].RelativePath < matches[j].RelativePath
+		}
+		a, b := matches[i].Range.Start, matches[j].Range.Start
+		return a.Line < b.Line || (a.Line == b.Line && a.Character < b.Character)
+	})
+	result := &Result{Matches: matches, FilesSearched: searched}
+	if len(result.Matches) > q.MaxMatches {
+		result.Matches = result.Matches[:q.MaxMatches]
+		result.Truncated = true
+	}
+	return result, nil
+}
+
+// compile validates the query and returns a constructor of per-worker matchers and the
+// filter of the files to search
+func compile(q Query) (func() fileMatcher, func(path string) bool, error) {
+	var langGrammars []grammar
+	if q.Language != "" {
+		var ok bool
+		if langGrammars, ok = grammars[strings.ToLower(q.Language)]; !ok {
+			return nil, nil, fmt.Errorf("%w: %s", apperrors.ErrUnsupportedLanguage, q.Language)
+		}
+	}
+	inLanguage := func(path string) bool {
+		if len(langGrammars) == 0 {
+			return true
+		}
+		_, ok := grammarFor(langGrammars, path)
+		return ok
+	}
+
+	if q.Pattern != "" {
+		pattern, err := parseCombyPattern(q.Pattern)
+		if err != nil {
+			return nil, nil, err
+		}
+		return func() fileMatcher { return &combyFileMatcher{pattern: pattern} }, inLanguage, nil
+	}
+
+	if len(langGrammars) == 0 {
+		return nil, nil, fmt.Errorf("%w: a tree-sitter query needs a language", apperrors.ErrInvalidArgument)
+	}
+	// Queries are immutable once compiled and are shared by the workers' cursors. A query
+	// that only compiles against some of a language's grammars (.ts but not .tsx) skips the
+	// files of the others.
+	queries := make([]*tree_sitter.Query, len(langGrammars))
+	var compileErr *tree_sitter.QueryError
+	for i, g := range langGrammars {
+		query, qerr := tree_sitter.NewQuery(g.language(), q.TreeSitterQuery)
+		if qerr != nil {
+			if compileErr == nil {
+				compileErr = qerr
+			}
+			continue
+		}
+		queries[i] = query
+	}
+	if queries[0] == nil {
+		return nil, nil, fmt.Errorf("%w: invalid tree-sitter query: %s (row %d, column %d)",
+			apperrors.ErrInvalidArgument, compileErr.Message, compileErr.Row, compileErr.Column)
+	}
+	accepts := func(path string) bool {
+		i, ok := grammarFor(langGrammars, path)
+		return ok && queries[i] != nil
+	}
+	return func() fileMatcher {
+		return &treeSitterMatcher{grammars: langGrammars, queries: queries}
+	}, accepts, nil
+}
+
+func grammarFor(langGrammars []grammar, path string) (int, bool) {
+	ext := strings.ToLower(filepath.Ext(path))
+	for i, g := range langGrammars {
+		for _, e := range g.extensions {
+			if e == ext {
+				return i, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// treeSitterMatcher runs a tree-sitter query; a match spans its captures
+type treeSitterMatcher struct {
+	grammars []grammar
+	queries  []*tree_sitter.Query
+	parsers  map[int]*tree_sitter.Parser
+	cursor   *tree_sitter.QueryCursor
+}
+
+func (m *treeSitterMatcher) matches(doc *textsearch.Document) ([]Match, error) {
+	i, _ := grammarFor(m.grammars, doc.FilePath)
+	if m.parsers == nil {
+		m.parsers = make(map[int]*tree_sitter.Parser)
+		m.cursor = tree_sitter.NewQueryCursor()
+	}
+	parser, ok := m.parsers[i]
+	if !ok {
+		parser = tree_sitter.NewParser()
+		if err := parser.SetLanguage(m.grammars[i].language()); err != nil {
+			parser.Close()
+			return nil, fmt.Errorf("failed to set parser language: %w", err)
+		}
+		m.parsers[i] = parser
+	}
+
+	tree := parser.Parse(doc.Content, nil)
+	if tree == nil {
+		return nil, nil
+	}
+	defer tree.Close()
+
+	query := m.queries[i]
+	names := query.CaptureNames()
+	lines := newLineIndex(doc.Content)
+	var matches []Match
+	qm := m.cursor.Matches(query, tree.RootNode(), doc.Content)
+	for match := qm.Next(); match != nil; match = qm.Next() {
+		if len(match.Captures) == 0 {
+			continue
+		}
+		start, end := ^uint(0), uint(0)
+		captures := make(map[string]Capture, len(match.Captures))
+		for _, capture := range match.Captures {
+			s, e := capture.Node.StartByte(), capture.Node.EndByte()
+			start, end = min(start, s), max(end, e)
+			name := names[capture.Index]
+			if _, seen := captures[name]; !seen {
+				captures[name] = Capture{Text: clip(doc.Content[s:e]), Range: lines.rangeOf(int(s), int(e))}
+			}
+		}
+		matches = append(matches, newMatch(doc, lines, int(start), int(end), captures))
+	}
+	return matches, nil
+}
+
+func (m *treeSitterMatcher) close() {
+	for _, parser := range m.parsers {
+		parser.Close()
+	}
+	if m.cursor != nil {
+		m.cursor.Close()
+	}
+}
+
+// combyFileMatcher runs a comby-style pattern
+type combyFileMatcher struct {
+	pattern *combyPattern
+}
+
+func (m *combyFileMatcher) matches(doc *textsearch.Document) ([]Match, error) {
+	found, err := m.pattern.findAll(doc.Content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", doc.RelativePath, err)
+	}
+	if len(found) == 0 {
+		return nil, nil
+	}
+	lines := newLineIndex(doc.Content)
+	matches := make([]Match, len(found))
+	for i, f := range found {
+		captures := make(map[string]Capture, len(f.holes))
+		for name, r := range f.holes {
+			captures[name] = Capture{Text: clip(doc.Content[r[0]:r[1]]), Range: lines.rangeOf(r[0], r[1])}
+		}
+		matches[i] = newMatch(doc, lines, f.start, f.end, captures)
+	}
+	return matches, nil
+}
+
+func (m *combyFileMatcher) close() {}
+
+func newMatch(doc *textsearch.Document, lines lineIndex, start, end int, captures map[string]Capture) Match {
+	if len(captures) == 0 {
+		captures = nil
+	}
+	return Match{
+		FilePath:     doc.FilePath,
+		RelativePath: doc.RelativePath,
+		FileID:       doc.FileID,
+		Range:        lines.rangeOf(start, end),
+		Text:         clip(doc.Content[start:end]),
+		Captures:     captures,
+	}
+}
+
+// lineIndex maps byte offsets to line and column
+type lineIndex []int // offsets of line starts
+
+func newLineIndex(content []byte) lineIndex {
+	starts := lineIndex{0}
+	for i, c := range content {
+		if c == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return starts
+}
+
+func (l lineIndex) position(offset int) base.Position {
+	line := sort.Search(len(l), func(i int) bool { return l[i] > offset }) - 1
+	return base.Position{Line: line, Character: offset - l[line]}
+}
+
+func (l lineIndex) rangeOf(start, end int) base.Range {
+	return base.Range{Start: l.position(start), End: l.position(end)}
+}
+
+// clip returns text cut to maxMatchText bytes at a rune boundary
+func clip(text []byte) string {
+	if len(text) <= maxMatchText {
+		return string(text)
+	}
+	end := maxMatchText
+	for end > 0 && !utf8.RuneStart(text[end]) {
+		end--
+	}
+	return string(text[:end]) + "..."
+}
@@ -0,0 +1,80 @@
+package structural
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"bot-go/internal/apperrors"
+	"bot-go/internal/service/textsearch"
+	"bot-go/pkg/lsp/base"
+)
+
+var testDocs = []textsearch.Document{
+	{FilePath: "/repo/main.go", RelativePath: "main.go", Content: []byte(`package main
+
+func run() error {
+	if err := load(cfg("a", f(1))); err != nil {
+		return errors.Wrap(err, "load")
+	}
+	return save(x)
+}
+`)},
+	{FilePath: "/repo/app.py", RelativePath: "app.py", Content: []byte("def run():\n    load(cfg)\n")},
+}
+
+func TestCombyPattern(t *testing.T) {
+	result, err := Search(context.Background(), testDocs, Query{Pattern: "load(:[arg])"})
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if len(result.Matches) != 2 || result.FilesSearched != 2 {
+		t.Fatalf("Search() = %+v, want a match in each file", result)
+	}
+	m := result.Matches[1]
+	if m.RelativePath != "main.go" || m.Captures["arg"].Text != `cfg("a", f(1))` {
+		t.Errorf("match = %+v, want the balanced argument of load in main.go", m)
+	}
+	if want := (base.Range{Start: base.Position{Line: 3, Character: 11}, End: base.Position{Line: 3, Character: 31}}); m.Range != want {
+		t.Errorf("match range = %+v, want %+v", m.Range, want)
+	}
+
+	// Whitespace in the pattern matches any layout, and a repeated hole must bind the same text
+	result, _ = Search(context.Background(), testDocs, Query{Pattern: "if :[[v]] := :[_]; :[[v]] != nil {", Language: "go"})
+	if len(result.Matches) != 1 || result.Matches[0].Captures["v"].Text != "err" {
+		t.Errorf("Search() = %+v, want the err check", result.Matches)
+	}
+	result, _ = Search(context.Background(), testDocs, Query{Pattern: "return :[[a]](:[[a]])"})
+	if len(result.Matches) != 0 {
+		t.Errorf("Search() = %+v, want no match for differing identifiers", result.Matches)
+	}
+
+	for _, pattern := range []string{":[x] foo", "a(:[x]:[y])", "f(:[x)"} {
+		if _, err := Search(context.Background(), testDocs, Query{Pattern: pattern}); !errors.Is(err, apperrors.ErrInvalidArgument) {
+			t.Errorf("Search(%q) error = %v, want ErrInvalidArgument", pattern, err)
+		}
+	}
+}
+
+func TestTreeSitterQuery(t *testing.T) {
+	query := `(call_expression function: (identifier) @fn (#eq? @fn "save"))`
+	result, err := Search(context.Background(), testDocs, Query{Language: "go", TreeSitterQuery: query})
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if result.FilesSearched != 1 || len(result.Matches) != 1 {
+		t.Fatalf("Search() = %+v, want one match in main.go", result)
+	}
+	if m := result.Matches[0]; m.Text != "save" || m.Range.Start.Line != 6 {
+		t.Errorf("match = %+v, want save on line 6", m)
+	}
+
+	result, _ = Search(context.Background(), testDocs, Query{Language: "go", TreeSitterQuery: "(call_expression) @call", MaxMatches: 2})
+	if len(result.Matches) != 2 || !result.Truncated {
+		t.Errorf("Search() with MaxMatches = %+v, want 2 matches, truncated", result)
+	}
+
+	if _, err := Search(context.Background(), testDocs, Query{Language: "go", TreeSitterQuery: "(not_a_node) @x"}); !errors.Is(err, apperrors.ErrInvalidArgument) {
+		t.Errorf("Search() with an invalid query error = %v, want ErrInvalidArgument", err)
+	}
+}
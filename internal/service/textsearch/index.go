@@ -0,0 +1,392 @@
+package textsearch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"bot-go/internal/apperrors"
+)
+
+// Limits applied when a Query leaves them unset
+const (
+	DefaultMaxFiles          = 50
+	DefaultMaxMatchesPerFile = 20
+	maxLineLength            = 500 // longer matching lines are cut around the first match
+)
+
+// Document is one indexed file
+type Document struct {
+	FilePath     string // absolute path, as stored with code chunks
+	RelativePath string
+	FileID       int32
+	CommitSHA    string
+	Language     string
+	Content      []byte
+}
+
+// Index is a trigram index over the contents of a repository's files. Trigrams are indexed
+// with ASCII letters lowercased, so one index serves case-sensitive and case-insensitive
+// queries; candidates are always verified against the content.
+type Index struct {
+	RepoName  string
+	CreatedAt time.Time
+	Docs      []Document
+	Postings  map[uint32][]uint32 // trigram -> ascending indexes into Docs
+}
+
+// NewIndex builds the trigram index of docs
+func NewIndex(repoName string, docs []Document) *Index {
+	// Sorted so doc indexes, and with them the tie order of results, do not depend on the
+	// order files were processed in
+	sort.Slice(docs, func(i, j int) bool { return docs[i].FilePath < docs[j].FilePath })
+	idx := &Index{
+		RepoName:  repoName,
+		CreatedAt: time.Now(),
+		Docs:      docs,
+		Postings:  make(map[uint32][]uint32),
+	}
+	seen := make(map[uint32]bool)
+	for i, doc := range docs {
+		clear(seen)
+		forEachTrigram(doc.Content, func(t uint32) {
+			if !seen[t] {
+				seen[t] = true
+				idx.Postings[t] = append(idx.Postings[t], uint32(i))
+			}
+		})
+	}
+	return idx
+}
+
+// Query is an exact text or regular expression search
+type Query struct {
+	Pattern           string
+	Regex             bool // Pattern is an RE2 regular expression rather than literal text
+	CaseSensitive     bool
+	Language          string // only files of this language ("" = all)
+	PathPrefix        string // only files whose relative path starts with this prefix
+	MaxFiles          int
+	MaxMatchesPerFile int
+}
+
+// Range is a byte range of a match within its line
+type Range struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// LineMatch is a line containing at least one match
+type LineMatch struct {
+	Line   int     `json:"line"` // 0-based, like code chunk lines
+	Text   string  `json:"text"`
+	Ranges []Range `json:"ranges"`
+}
+
+// FileMatch lists the matching lines of a file
+type FileMatch struct {
+	FilePath     string      `json:"file_path"`
+	RelativePath string      `json:"relative_path"`
+	FileID       int32       `json:"file_id"`
+	CommitSHA    string      `json:"commit_sha,omitempty"`
+	Language     string      `json:"language"`
+	MatchCount   int         `json:"match_count"` // all matches in the file, including lines not returned
+	Lines        []LineMatch `json:"lines"`
+}
+
+// SearchResult holds the files matching a query, most matches first
+type SearchResult struct {
+	Files          []FileMatch `json:"files"`
+	FilesSearched  int         `json:"files_searched"` // candidates left after the trigram filter
+	FilesIndexed   int         `json:"files_indexed"`
+	Truncated      bool        `json:"truncated"` // more files matched than MaxFiles
+	IndexCreatedAt time.Time   `json:"index_created_at"`
+}
+
+// Search runs a query. Invalid patterns fail with apperrors.ErrInvalidArgument.
+func (idx *Index) Search(ctx context.Context, q Query) (*SearchResult, error) {
+	if q.Pattern == "" {
+		return nil, fmt.Errorf("%w: the pattern is empty", apperrors.ErrInvalidArgument)
+	}
+	re, required, err := compileQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	if q.MaxFiles <= 0 {
+		q.MaxFiles = DefaultMaxFiles
+	}
+	if q.MaxMatchesPerFile <= 0 {
+		q.MaxMatchesPerFile = DefaultMaxMatchesPerFile
+	}
+
+	candidates := idx.candidates(required)
+	result := &SearchResult{FilesIndexed: len(idx.Docs), IndexCreatedAt: idx.CreatedAt}
+	for _, i := range candidates {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		doc := &idx.Docs[i]
+		if q.Language != "" && !strings.EqualFold(doc.Language, q.Language) {
+			continue
+		}
+		if q.PathPrefix != "" && !strings.HasPrefix(doc.RelativePath, q.PathPrefix) {
+			continue
+		}
+		result.FilesSearched++
+		if match, ok := matchDocument(doc, re, q.MaxMatchesPerFile); ok {
+			result.Files = append(result.Files, match)
+		}
+	}
+
+	sort.SliceStable(result.Files, func(i, j int) bool {
+		return result.Files[i].MatchCount > result.Files[j].MatchCount
+	})
+	if len(result.Files) > q.MaxFiles {
+		result.Files = result.Files[:q.MaxFiles]
+		result.Truncated = true
+	}
+	return result, nil
+}
+
+// compileQuery returns the regular expression verifying matches and the literal strings
+// every match contains, whose trigrams select the candidate files
+func compileQuery(q Query) (*regexp.Regexp, []string, error) {
+	expr := q.Pattern
+	if !q.Regex {
+		expr = regexp.QuoteMeta(q.Pattern)
+	}
+	flags := syntax.Perl
+	if !q.CaseSensitive {
+		flags |= syntax.FoldCase
+	}
+	parsed, err := syntax.Parse(expr, flags)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: invalid regular expression: %v", apperrors.ErrInvalidArgument, err)
+	}
+	if !q.CaseSensitive {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: invalid regular expression: %v", apperrors.ErrInvalidArgument, err)
+	}
+	return re, requiredLiterals(parsed.Simplify()), nil
+}
+
+// requiredLiterals returns literal strings that every match of re contains. It is
+// conservative: alternations and optional parts contribute nothing.
+func requiredLiterals(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		if lit, ok := indexableLiteral(re); ok {
+			return []string{lit}
+		}
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiterals(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min >= 1 {
+			return requiredLiterals(re.Sub[0])
+		}
+	case syntax.OpConcat:
+		var literals []string
+		var run strings.Builder
+		flush := func() {
+			if run.Len() > 0 {
+				literals = append(literals, run.String())
+				run.Reset()
+			}
+		}
+		for _, sub := range re.Sub {
+			if lit, ok := indexableLiteral(sub); ok {
+				run.WriteString(lit)
+				continue
+			}
+			flush()
+			literals = append(literals, requiredLiterals(sub)...)
+		}
+		flush()
+		return literals
+	}
+	return nil
+}
+
+// indexableLiteral returns the text of a literal node, unless it folds case beyond ASCII,
+// which the index does not fold
+func indexableLiteral(re *syntax.Regexp) (string, bool) {
+	if re.Op != syntax.OpLiteral {
+		return "", false
+	}
+	lit := string(re.Rune)
+	if re.Flags&syntax.FoldCase != 0 {
+		for _, r := range re.Rune {
+			if r >= utf8.RuneSelf {
+				return "", false
+			}
+		}
+	}
+	return lit, true
+}
+
+// candidates returns the indexes of the documents containing every trigram of the
+// required literals, or all documents when the literals are too short to filter by
+func (idx *Index) candidates(required []string) []uint32 {
+	var lists [][]uint32
+	seen := make(map[uint32]bool)
+	for _, lit := range required {
+		missing := false
+		forEachTrigram([]byte(lit), func(t uint32) {
+			if seen[t] {
+				return
+			}
+			seen[t] = true
+			postings, ok := idx.Postings[t]
+			if !ok {
+				missing = true
+			}
+			lists = append(lists, postings)
+		})
+		if missing {
+			return nil
+		}
+	}
+	if len(lists) == 0 {
+		all := make([]uint32, len(idx.Docs))
+		for i := range all {
+			all[i] = uint32(i)
+		}
+		return all
+	}
+
+	// Intersect the shortest lists first
+	sort.Slice(lists, func(i, j int) bool { return len(lists[i]) < len(lists[j]) })
+	result := append([]uint32(nil), lists[0]...)
+	for _, list := range lists[1:] {
+		result = intersect(result, list)
+		if len(result) == 0 {
+			break
+		}
+	}
+	return result
+}
+
+func intersect(a, b []uint32) []uint32 {
+	out := a[:0]
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	return out
+}
+
+// matchDocument finds the matches of re in a document, keeping the first maxLines lines
+func matchDocument(doc *Document, re *regexp.Regexp, maxLines int) (FileMatch, bool) {
+	locs := re.FindAllIndex(doc.Content, -1)
+	if len(locs) == 0 {
+		return FileMatch{}, false
+	}
+	match := FileMatch{
+		FilePath:     doc.FilePath,
+		RelativePath: doc.RelativePath,
+		FileID:       doc.FileID,
+		CommitSHA:    doc.CommitSHA,
+		Language:     doc.Language,
+	}
+
+	content := doc.Content
+	line, lineStart := 0, 0
+	for _, loc := range locs {
+		if loc[0] == loc[1] {
+			continue // empty matches, e.g. of "x*", say nothing about a line
+		}
+		match.MatchCount++
+		// Advance to the line holding the start of the match
+		for {
+			next := bytes.IndexByte(content[lineStart:], '\n')
+			if next < 0 || lineStart+next >= loc[0] {
+				break
+			}
+			lineStart += next + 1
+			line++
+		}
+		lineEnd := len(content)
+		if next := bytes.IndexByte(content[lineStart:], '\n'); next >= 0 {
+			lineEnd = lineStart + next
+		}
+		// Matches spanning lines are cut at the end of their first line
+		r := Range{Start: loc[0] - lineStart, End: min(loc[1], lineEnd) - lineStart}
+
+		if n := len(match.Lines); n > 0 && match.Lines[n-1].Line == line {
+			match.Lines[n-1].Ranges = append(match.Lines[n-1].Ranges, r)
+			continue
+		}
+		if len(match.Lines) < maxLines {
+			match.Lines = append(match.Lines, LineMatch{Line: line, Text: string(content[lineStart:lineEnd]), Ranges: []Range{r}})
+		}
+	}
+	if match.MatchCount == 0 {
+		return FileMatch{}, false
+	}
+	for i := range match.Lines {
+		trimLine(&match.Lines[i])
+	}
+	return match, true
+}
+
+// trimLine shortens very long lines (minified code) to a window around the first match
+func trimLine(lm *LineMatch) {
+	if len(lm.Text) <= maxLineLength {
+		return
+	}
+	start := max(0, lm.Ranges[0].Start-maxLineLength/4)
+	for start > 0 && !utf8.RuneStart(lm.Text[start]) {
+		start--
+	}
+	end := min(len(lm.Text), start+maxLineLength)
+	for end < len(lm.Text) && !utf8.RuneStart(lm.Text[end]) {
+		end++
+	}
+	lm.Text = lm.Text[start:end]
+	ranges := lm.Ranges[:0]
+	for _, r := range lm.Ranges {
+		if r.Start >= end {
+			break
+		}
+		ranges = append(ranges, Range{Start: max(r.Start, start) - start, End: min(r.End, end) - start})
+	}
+	lm.Ranges = ranges
+}
+
+// forEachTrigram calls fn with every trigram of data, ASCII letters lowercased
+func forEachTrigram(data []byte, fn func(uint32)) {
+	if len(data) < 3 {
+		return
+	}
+	a, b := fold(data[0]), fold(data[1])
+	for _, c := range data[2:] {
+		c = fold(c)
+		fn(uint32(a)<<16 | uint32(b)<<8 | uint32(c))
+		a, b = b, c
+	}
+}
+
+func fold(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
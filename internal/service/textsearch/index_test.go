@@ -0,0 +1,102 @@
+package textsearch
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"bot-go/internal/apperrors"
+)
+
+func testIndex() *Index {
+	return NewIndex("repo", []Document{
+		{FilePath: "/repo/server.go", RelativePath: "server.go", Language: "go", Content: []byte("package main\n\nfunc NewServer() *Server {\n\treturn &Server{}\n}\n")},
+		{FilePath: "/repo/client.go", RelativePath: "client.go", Language: "go", Content: []byte("package main\n\n// uses newServer internally\nfunc dial() { NewServer(); NewServer() }\n")},
+		{FilePath: "/repo/web/app.py", RelativePath: "web/app.py", Language: "python", Content: []byte("ERROR_CODE = 42\n")},
+	})
+}
+
+func TestIndexSearch(t *testing.T) {
+	idx := testIndex()
+	ctx := context.Background()
+
+	result, err := idx.Search(ctx, Query{Pattern: "NewServer", CaseSensitive: true})
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if len(result.Files) != 2 || result.Files[0].RelativePath != "client.go" || result.Files[0].MatchCount != 2 {
+		t.Fatalf("Search() = %+v, want client.go (2 matches) ranked before server.go", result.Files)
+	}
+	want := LineMatch{Line: 3, Text: "func dial() { NewServer(); NewServer() }", Ranges: []Range{{14, 23}, {27, 36}}}
+	if got := result.Files[0].Lines[0]; !reflect.DeepEqual(got, want) {
+		t.Errorf("line match = %+v, want %+v", got, want)
+	}
+	if result.FilesSearched != 2 {
+		t.Errorf("FilesSearched = %d, want the trigram filter to leave 2 candidates", result.FilesSearched)
+	}
+
+	result, _ = idx.Search(ctx, Query{Pattern: "newserver"})
+	if len(result.Files) != 2 || result.Files[0].MatchCount != 3 {
+		t.Errorf("case-insensitive Search() = %+v, want 3 matches in client.go", result.Files)
+	}
+
+	result, _ = idx.Search(ctx, Query{Pattern: `func \w+\(\)`, Regex: true, Language: "go"})
+	if len(result.Files) != 2 {
+		t.Errorf("regex Search() = %+v, want both Go files", result.Files)
+	}
+
+	result, _ = idx.Search(ctx, Query{Pattern: "error_code", PathPrefix: "web/"})
+	if len(result.Files) != 1 || result.Files[0].Lines[0].Line != 0 {
+		t.Errorf("path prefix Search() = %+v, want web/app.py line 0", result.Files)
+	}
+
+	if _, err := idx.Search(ctx, Query{Pattern: "(", Regex: true}); !errors.Is(err, apperrors.ErrInvalidArgument) {
+		t.Errorf("Search() with an invalid regex error = %v, want ErrInvalidArgument", err)
+	}
+}
+
+func TestRequiredLiterals(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{`NewServer`, []string{"NewServer"}},
+		{`func \w+Handler\(`, []string{"func ", "Handler("}},
+		{`(foo|bar)baz`, []string{"baz"}},
+		{`a.*b`, []string{"a", "b"}},
+		{`(?:abc)?`, nil},
+	}
+	for _, tt := range tests {
+		_, got, err := compileQuery(Query{Pattern: tt.pattern, Regex: true, CaseSensitive: true})
+		if err != nil {
+			t.Fatalf("compileQuery(%q) error = %v", tt.pattern, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("requiredLiterals(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestTextSearchServicePersistence(t *testing.T) {
+	dir := t.TempDir()
+	svc, err := NewTextSearchServiceWithIndexDir(dir, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.IndexRepository(context.Background(), "repo", testIndex().Docs); err != nil {
+		t.Fatalf("IndexRepository() error = %v", err)
+	}
+
+	// A second service, as in a server started after a CLI build, loads the saved index
+	reloaded, _ := NewTextSearchServiceWithIndexDir(dir, zap.NewNop())
+	result, err := reloaded.Search(context.Background(), "repo", Query{Pattern: "ERROR_CODE"})
+	if err != nil || len(result.Files) != 1 {
+		t.Fatalf("Search() after reload = %+v, %v", result, err)
+	}
+	if _, err := reloaded.Search(context.Background(), "other", Query{Pattern: "x"}); !errors.Is(err, apperrors.ErrNotFound) {
+		t.Errorf("Search() of an unindexed repository error = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,198 @@
+package textsearch
+
+import (
+	"context"
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"bot-go/internal/apperrors"
+
+	"go.uber.org/zap"
+)
+
+// indexFormatVersion changes whenever the persisted Index layout does
+const indexFormatVersion = 1
+
+// TextSearchService keeps the trigram indexes of repositories for exact and regular
+// expression search. Indexes are built by index builds, saved to disk and loaded on first
+// use, so a server finds the indexes built by the CLI.
+type TextSearchService struct {
+	indexes  map[string]*Index // repo name -> index
+	indexDir string
+	logger   *zap.Logger
+	mu       sync.RWMutex
+}
+
+// persistedIndex is the on-disk form of an Index
+type persistedIndex struct {
+	Version int
+	Index   *Index
+}
+
+// NewTextSearchService creates a text search service with the default index directory
+func NewTextSearchService(logger *zap.Logger) (*TextSearchService, error) {
+	return NewTextSearchServiceWithIndexDir("./text_indexes", logger)
+}
+
+// NewTextSearchServiceWithIndexDir creates a text search service saving indexes in indexDir
+func NewTextSearchServiceWithIndexDir(indexDir string, logger *zap.Logger) (*TextSearchService, error) {
+	if err := os.MkdirAll(indexDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create text index directory: %w", err)
+	}
+	return &TextSearchService{
+		indexes:  make(map[string]*Index),
+		indexDir: indexDir,
+		logger:   logger,
+	}, nil
+}
+
+// IndexRepository replaces the index of a repository with one over docs and saves it. Files
+// of the previous index missing from docs are kept while they still exist on disk, so a build
+// resumed after an interruption keeps the files indexed before it.
+func (s *TextSearchService) IndexRepository(ctx context.Context, repoName string, docs []Document) (*Index, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if previous, err := s.index(repoName); err == nil {
+		seen := make(map[string]bool, len(docs))
+		for _, doc := range docs {
+			seen[doc.FilePath] = true
+		}
+		for _, doc := range previous.Docs {
+			if _, err := os.Stat(doc.FilePath); !seen[doc.FilePath] && err == nil {
+				docs = append(docs, doc)
+			}
+		}
+	}
+	idx := NewIndex(repoName, docs)
+	if err := s.save(idx); err != nil {
+		return nil, fmt.Errorf("failed to save text index for %s: %w", repoName, err)
+	}
+
+	s.mu.Lock()
+	s.indexes[repoName] = idx
+	s.mu.Unlock()
+
+	s.logger.Info("Built text index",
+		zap.String("repo_name", repoName),
+		zap.Int("files", len(idx.Docs)),
+		zap.Int("trigrams", len(idx.Postings)))
+	return idx, nil
+}
+
+// Search runs a query over the index of a repository
+func (s *TextSearchService) Search(ctx context.Context, repoName string, q Query) (*SearchResult, error) {
+	idx, err := s.index(repoName)
+	if err != nil {
+		return nil, err
+	}
+	return idx.Search(ctx, q)
+}
+
+// Documents returns the indexed files of a repository, sorted by path, for searches that
+// scan stored contents rather than use the trigram postings
+func (s *TextSearchService) Documents(repoName string) ([]Document, error) {
+	idx, err := s.index(repoName)
+	if err != nil {
+		return nil, err
+	}
+	return idx.Docs, nil
+}
+
+// IndexCreatedAt returns when the index of a repository was built
+func (s *TextSearchService) IndexCreatedAt(repoName string) (time.Time, error) {
+	idx, err := s.index(repoName)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return idx.CreatedAt, nil
+}
+
+// LoadPersistedIndex loads a repository's saved index into memory. It returns false when the
+// index is already loaded or nothing was saved.
+func (s *TextSearchService) LoadPersistedIndex(repoName string) (bool, error) {
+	s.mu.RLock()
+	_, loaded := s.indexes[repoName]
+	s.mu.RUnlock()
+	if loaded {
+		return false, nil
+	}
+	if _, err := os.Stat(s.indexPath(repoName)); err != nil {
+		return false, nil
+	}
+
+	idx, err := s.load(repoName)
+	if err != nil {
+		return false, fmt.Errorf("failed to load text index for %s: %w", repoName, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, loaded := s.indexes[repoName]; loaded {
+		return false, nil
+	}
+	s.indexes[repoName] = idx
+	return true, nil
+}
+
+// index returns the index of a repository, loading a saved one on first use
+func (s *TextSearchService) index(repoName string) (*Index, error) {
+	if _, err := s.LoadPersistedIndex(repoName); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	idx, ok := s.indexes[repoName]
+	if !ok {
+		return nil, apperrors.NotFound("text index for repository", repoName)
+	}
+	return idx, nil
+}
+
+func (s *TextSearchService) indexPath(repoName string) string {
+	return filepath.Join(s.indexDir, fmt.Sprintf("%s_trigram.gob", repoName))
+}
+
+// save writes the index to a temporary file renamed into place, so a server loading the
+// index never reads a partial one
+func (s *TextSearchService) save(idx *Index) error {
+	path := s.indexPath(idx.RepoName)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := gob.NewEncoder(tmp).Encode(persistedIndex{Version: indexFormatVersion, Index: idx}); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *TextSearchService) load(repoName string) (*Index, error) {
+	file, err := os.Open(s.indexPath(repoName))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var persisted persistedIndex
+	if err := gob.NewDecoder(file).Decode(&persisted); err != nil {
+		return nil, err
+	}
+	if persisted.Version != indexFormatVersion || persisted.Index == nil {
+		return nil, fmt.Errorf("unsupported text index format %d, rebuild the index", persisted.Version)
+	}
+	return persisted.Index, nil
+}
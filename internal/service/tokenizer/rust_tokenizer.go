@@ -0,0 +1,113 @@
+package tokenizer
+
+import (
+	"bot-go/internal/model/ngram"
+	"context"
+	"fmt"
+	"sync"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	rust "github.com/tree-sitter/tree-sitter-rust/bindings/go"
+)
+
+// RustTokenizer implements tokenization for Rust source code
+type RustTokenizer struct {
+	parser   *tree_sitter.Parser
+	language *tree_sitter.Language
+	mu       sync.Mutex // Protects parser (tree-sitter parsers are not thread-safe)
+}
+
+// NewRustTokenizer creates a new Rust tokenizer
+func NewRustTokenizer() (*RustTokenizer, error) {
+	parser := tree_sitter.NewParser()
+	language := tree_sitter.NewLanguage(rust.Language())
+
+	err := parser.SetLanguage(language)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set Rust language: %w", err)
+	}
+
+	return &RustTokenizer{
+		parser:   parser,
+		language: language,
+	}, nil
+}
+
+func (t *RustTokenizer) Tokenize(ctx context.Context, source []byte) (ngram.TokenSequence, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tree := t.parser.Parse(source, nil)
+	if tree == nil {
+		return nil, fmt.Errorf("failed to parse Rust source")
+	}
+	defer tree.Close()
+
+	rootNode := tree.RootNode()
+	var tokens ngram.TokenSequence
+
+	t.traverseNode(rootNode, source, &tokens)
+
+	return tokens, nil
+}
+
+func (t *RustTokenizer) traverseNode(node *tree_sitter.Node, source []byte, tokens *ngram.TokenSequence) {
+	if node == nil {
+		return
+	}
+
+	nodeType := node.Kind()
+	// Comments have doc comment children; skip them whole
+	if nodeType == "line_comment" || nodeType == "block_comment" {
+		return
+	}
+
+	// Literals have children (quotes, escapes) but are one token
+	if node.ChildCount() == 0 || nodeType == "string_literal" || nodeType == "raw_string_literal" || nodeType == "char_literal" {
+		content := node.Utf8Text(source)
+
+		// Skip empty tokens
+		if content == "" {
+			return
+		}
+
+		startPoint := node.StartPosition()
+		token := ngram.Token{
+			Type:   nodeType,
+			Value:  content,
+			Line:   int(startPoint.Row) + 1,
+			Column: int(startPoint.Column) + 1,
+		}
+		*tokens = append(*tokens, token)
+		return
+	}
+
+	// Recursively traverse children
+	for i := uint(0); i < node.ChildCount(); i++ {
+		child := node.Child(i)
+		t.traverseNode(child, source, tokens)
+	}
+}
+
+func (t *RustTokenizer) Normalize(token ngram.Token) string {
+	// Normalize based on token type
+	switch token.Type {
+	case "identifier", "type_identifier", "field_identifier":
+		return "ID"
+	case "integer_literal", "float_literal":
+		return "NUM"
+	case "string_literal", "raw_string_literal":
+		return "STR"
+	case "char_literal":
+		return "CHAR"
+	case "true", "false":
+		return "BOOL"
+	default:
+		// Return the actual value for keywords, operators, and punctuation
+		return token.Value
+	}
+}
+
+func (t *RustTokenizer) Language() string {
+	return "rust"
+}
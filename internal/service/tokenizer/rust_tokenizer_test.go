@@ -0,0 +1,42 @@
+package tokenizer
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRustTokenizer(t *testing.T) {
+	tok, err := NewRustTokenizer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := `/// Adds one
+fn add(a: i32) -> bool {
+    let s = "a \"b\""; // trailing
+    a + 1 == 'c' as i32 && true
+}
+`
+	tokens, err := tok.Tokenize(context.Background(), []byte(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Comments are dropped and literals are single tokens
+	normalized := make([]string, len(tokens))
+	for i, token := range tokens {
+		normalized[i] = tok.Normalize(token)
+	}
+	want := "fn ID ( ID : i32 ) -> bool { let ID = STR ; ID + NUM == CHAR as i32 && BOOL }"
+	if got := strings.Join(normalized, " "); got != want {
+		t.Errorf("normalized tokens:\n got %s\nwant %s", got, want)
+	}
+
+	for _, token := range tokens {
+		if token.Type == "string_literal" {
+			if token.Value != `"a \"b\""` || token.Line != 3 || token.Column != 13 {
+				t.Errorf("string literal = %q at %d:%d, want %q at 3:13", token.Value, token.Line, token.Column, `"a \"b\""`)
+			}
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package vector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"bot-go/internal/model"
+)
+
+// CollectionArchive describes an exported collection
+type CollectionArchive struct {
+	Chunks    int `json:"chunks"`
+	Dimension int `json:"dimension"` // of the embeddings; 0 for an empty collection
+}
+
+// ExportCollection writes every chunk of a collection, with its embedding and payload, to w as
+// JSON lines
+func ExportCollection(ctx context.Context, db VectorDatabase, collectionName string, w io.Writer) (*CollectionArchive, error) {
+	enc := json.NewEncoder(w)
+	archive := &CollectionArchive{}
+	err := scrollChunks(ctx, db, collectionName, func(chunks []*model.CodeChunk) error {
+		for _, chunk := range chunks {
+			if archive.Dimension == 0 {
+				archive.Dimension = len(chunk.Embedding)
+			}
+			if err := enc.Encode(chunk); err != nil {
+				return err
+			}
+			archive.Chunks++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to export collection %s: %w", collectionName, err)
+	}
+	return archive, nil
+}
+
+// ImportCollection creates a collection of the archived dimension and upserts the chunks
+// written by ExportCollection. An empty collection is not created; indexing creates it.
+func ImportCollection(ctx context.Context, db VectorDatabase, collectionName string, archive CollectionArchive, r io.Reader) (int, error) {
+	if archive.Dimension == 0 {
+		return 0, nil
+	}
+	if err := db.CreateCollection(ctx, collectionName, archive.Dimension, DistanceMetricCosine); err != nil {
+		return 0, fmt.Errorf("failed to create collection %s: %w", collectionName, err)
+	}
+	dec := json.NewDecoder(r)
+	batch := make([]*model.CodeChunk, 0, reduceScrollPageSize)
+	imported := 0
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := db.UpsertChunks(ctx, collectionName, batch); err != nil {
+			return fmt.Errorf("failed to import chunks into %s: %w", collectionName, err)
+		}
+		imported += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+	for {
+		var chunk model.CodeChunk
+		if err := dec.Decode(&chunk); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return imported, fmt.Errorf("failed to read archive of %s: %w", collectionName, err)
+		}
+		batch = append(batch, &chunk)
+		if len(batch) == reduceScrollPageSize {
+			if err := flush(); err != nil {
+				return imported, err
+			}
+		}
+	}
+	return imported, flush()
+}
@@ -0,0 +1,48 @@
+package vector
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"bot-go/internal/model"
+)
+
+func TestExportImportCollection(t *testing.T) {
+	ctx := context.Background()
+	db := newMemoryVectorDatabase()
+	if err := db.CreateCollection(ctx, "repo", 8, DistanceMetricCosine); err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range planeVectors(700, 8) { // more than one page
+		chunk := model.NewCodeChunk(fmt.Sprintf("%04d", i), model.ChunkTypeFunction, 3, "", "go", "main.go", rangeOf(i))
+		chunk.Embedding = v
+		if err := db.UpsertChunks(ctx, "repo", []*model.CodeChunk{chunk}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	archive, err := ExportCollection(ctx, db, "repo", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if archive.Chunks != 700 || archive.Dimension != 8 {
+		t.Errorf("archive = %+v", archive)
+	}
+	want := db.collections["repo"]["0042"]
+	db.DeleteCollection(ctx, "repo")
+
+	imported, err := ImportCollection(ctx, db, "repo", *archive, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported != 700 || db.dims["repo"] != 8 {
+		t.Errorf("imported %d chunks of dimension %d", imported, db.dims["repo"])
+	}
+	if got := db.collections["repo"]["0042"]; !reflect.DeepEqual(got.Embedding, want.Embedding) || got.StartLine != want.StartLine {
+		t.Errorf("chunk 0042 = %+v, want %+v", got, want)
+	}
+}
@@ -1,8 +1,10 @@
 package vector
 
 import (
+	"bot-go/internal/apperrors"
 	"bot-go/internal/chunk"
 	"bot-go/internal/config"
+	"bot-go/internal/logging"
 	"bot-go/internal/model"
 	"bot-go/internal/util"
 	"context"
@@ -12,14 +14,17 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	tree_sitter "github.com/tree-sitter/go-tree-sitter"
 	golang "github.com/tree-sitter/tree-sitter-go/bindings/go"
 	java "github.com/tree-sitter/tree-sitter-java/bindings/go"
 	javascript "github.com/tree-sitter/tree-sitter-javascript/bindings/go"
 	python "github.com/tree-sitter/tree-sitter-python/bindings/go"
+	rust "github.com/tree-sitter/tree-sitter-rust/bindings/go"
 	typescript "github.com/tree-sitter/tree-sitter-typescript/bindings/go"
 	"go.uber.org/zap"
 )
@@ -35,8 +40,20 @@ type CodeChunkService struct {
 	minLoopLines        int
 	gcThreshold         int64
 	numFileThreads      int
+
+	ttlMutex       sync.RWMutex
+	defaultTTL     time.Duration            // applied to collections without their own TTL (0 = never expire)
+	collectionTTLs map[string]time.Duration // per-collection overrides
+
+	stopMutex       sync.RWMutex
+	defaultStop     chunk.StopSet            // boilerplate not embedded in collections without their own set
+	collectionStops map[string]chunk.StopSet // per-collection overrides
 }
 
+// FileIDResolver maps a file to its FileID (from MySQL file_versions) and commit SHA,
+// so chunks produced outside the IndexBuilder still carry the shared file identity
+type FileIDResolver func(filePath string, content []byte) (fileID int32, commitSHA string, err error)
+
 // NewCodeChunkService creates a new code chunk service
 func NewCodeChunkService(vectorDB VectorDatabase, embedding EmbeddingModel, minConditionalLines, minLoopLines int, gcThreshold int64, numFileThreads int, logger *zap.Logger) *CodeChunkService {
 	return &CodeChunkService{
@@ -48,9 +65,72 @@ func NewCodeChunkService(vectorDB VectorDatabase, embedding EmbeddingModel, minC
 		minLoopLines:        minLoopLines,
 		gcThreshold:         gcThreshold,
 		numFileThreads:      numFileThreads,
+		collectionTTLs:      make(map[string]time.Duration),
+		collectionStops:     make(map[string]chunk.StopSet),
 	}
 }
 
+// SetDefaultTTL sets how long chunks live after they are indexed when their collection
+// has no TTL of its own. Zero keeps chunks until they are deleted explicitly.
+func (ccs *CodeChunkService) SetDefaultTTL(ttl time.Duration) {
+	ccs.ttlMutex.Lock()
+	defer ccs.ttlMutex.Unlock()
+	ccs.defaultTTL = ttl
+}
+
+// SetCollectionTTL sets the TTL for chunks written to collectionName from now on. The
+// override is kept in memory only; chunks already stored keep their expiry.
+func (ccs *CodeChunkService) SetCollectionTTL(collectionName string, ttl time.Duration) {
+	ccs.ttlMutex.Lock()
+	defer ccs.ttlMutex.Unlock()
+	ccs.collectionTTLs[collectionName] = ttl
+}
+
+// stampExpiry sets ExpiresAt on chunks about to be stored in collectionName. Re-indexed
+// chunks get a fresh expiry, so only data that stops being refreshed ages out.
+func (ccs *CodeChunkService) stampExpiry(collectionName string, chunks []*model.CodeChunk) {
+	ccs.ttlMutex.RLock()
+	ttl, ok := ccs.collectionTTLs[collectionName]
+	if !ok {
+		ttl = ccs.defaultTTL
+	}
+	ccs.ttlMutex.RUnlock()
+
+	var expiresAt int64
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl).Unix()
+	}
+	for _, chunk := range chunks {
+		chunk.ExpiresAt = expiresAt
+	}
+}
+
+// SetDefaultStopChunks sets the boilerplate left out of collections without a set of their
+// own (see chunk.StopSet)
+func (ccs *CodeChunkService) SetDefaultStopChunks(stop chunk.StopSet) {
+	ccs.stopMutex.Lock()
+	defer ccs.stopMutex.Unlock()
+	ccs.defaultStop = stop
+}
+
+// SetCollectionStopChunks sets the boilerplate left out of collectionName, e.g. from the
+// stop_chunks of its repository
+func (ccs *CodeChunkService) SetCollectionStopChunks(collectionName string, stop chunk.StopSet) {
+	ccs.stopMutex.Lock()
+	defer ccs.stopMutex.Unlock()
+	ccs.collectionStops[collectionName] = stop
+}
+
+// stopChunks returns the boilerplate left out of collectionName
+func (ccs *CodeChunkService) stopChunks(collectionName string) chunk.StopSet {
+	ccs.stopMutex.RLock()
+	defer ccs.stopMutex.RUnlock()
+	if stop, ok := ccs.collectionStops[collectionName]; ok {
+		return stop
+	}
+	return ccs.defaultStop
+}
+
 // ProcessFile processes a single source file and stores chunks in vector DB
 // Returns (chunks, error) - if error is non-nil, processing failed but can be retried
 func (ccs *CodeChunkService) ProcessFile(ctx context.Context, filePath, language, collectionName string) ([]*model.CodeChunk, error) {
@@ -58,7 +138,7 @@ func (ccs *CodeChunkService) ProcessFile(ctx context.Context, filePath, language
 	sourceCode, err := ccs.readFile(filePath)
 	if err != nil {
 		// File read errors are common (permissions, symlinks, etc.) - log and skip
-		ccs.logger.Warn("Failed to read file, skipping",
+		ccs.log(ctx).Warn("Failed to read file, skipping",
 			zap.String("file", filePath),
 			zap.Error(err))
 		return nil, nil // Return nil error to continue processing other files
@@ -73,17 +153,17 @@ func (ccs *CodeChunkService) ProcessFileWithContent(ctx context.Context, filePat
 	// Check for existing chunks in the database
 	existingChunks, err := ccs.vectorDB.GetChunksByFilePath(ctx, collectionName, filePath)
 	if err != nil {
-		ccs.logger.Warn("Failed to fetch existing chunks, will process file anyway",
+		ccs.log(ctx).Warn("Failed to fetch existing chunks, will process file anyway",
 			zap.String("file", filePath),
 			zap.Error(err))
 		existingChunks = nil
 	}
 
 	// Parse file and generate chunks
-	chunks, err := ccs.parseAndChunk(ctx, filePath, language, sourceCode)
+	chunks, err := ccs.parseAndChunk(ctx, filePath, language, sourceCode, ccs.stopChunks(collectionName))
 	if err != nil {
 		// Parse errors might indicate corrupted files or unsupported syntax - log and skip
-		ccs.logger.Warn("Failed to parse file, skipping",
+		ccs.log(ctx).Warn("Failed to parse file, skipping",
 			zap.String("file", filePath),
 			zap.String("language", language),
 			zap.Error(err))
@@ -91,7 +171,7 @@ func (ccs *CodeChunkService) ProcessFileWithContent(ctx context.Context, filePat
 	}
 
 	if len(chunks) == 0 {
-		ccs.logger.Debug("No chunks generated for file", zap.String("file", filePath))
+		ccs.log(ctx).Debug("No chunks generated for file", zap.String("file", filePath))
 		return nil, nil
 	}
 
@@ -118,7 +198,7 @@ func (ccs *CodeChunkService) ProcessFileWithContent(ctx context.Context, filePat
 		}
 	}
 
-	ccs.logger.Info("Chunk analysis for file",
+	ccs.log(ctx).Info("Chunk analysis for file",
 		zap.String("file", filePath),
 		zap.Int("total_chunks", len(chunks)),
 		zap.Int("existing_chunks", len(existingMatchedChunks)),
@@ -130,7 +210,7 @@ func (ccs *CodeChunkService) ProcessFileWithContent(ctx context.Context, filePat
 		newChunksWithEmbeddings, err := ccs.generateAndPrepareEmbeddings(ctx, newChunks)
 		if err != nil {
 			// Embedding errors might be transient (API issues) - log and skip
-			ccs.logger.Warn("Failed to generate embeddings, skipping file",
+			ccs.log(ctx).Warn("Failed to generate embeddings, skipping file",
 				zap.String("file", filePath),
 				zap.Error(err))
 			return nil, nil // Return nil error to continue processing other files
@@ -143,16 +223,17 @@ func (ccs *CodeChunkService) ProcessFileWithContent(ctx context.Context, filePat
 
 	// Store all chunks in vector database (upsert will update existing ones)
 	if len(chunksToStore) > 0 {
+		ccs.stampExpiry(collectionName, chunksToStore)
 		if err := ccs.vectorDB.UpsertChunks(ctx, collectionName, chunksToStore); err != nil {
 			// Vector DB errors might be transient - log and skip
-			ccs.logger.Warn("Failed to store chunks, skipping file",
+			ccs.log(ctx).Warn("Failed to store chunks, skipping file",
 				zap.String("file", filePath),
 				zap.Error(err))
 			return nil, nil // Return nil error to continue processing other files
 		}
 	}
 
-	ccs.logger.Info("Processed file successfully",
+	ccs.log(ctx).Info("Processed file successfully",
 		zap.String("file", filePath),
 		zap.Int("original_chunks", len(chunks)),
 		zap.Int("new_embeddings_generated", len(newChunks)),
@@ -163,12 +244,13 @@ func (ccs *CodeChunkService) ProcessFileWithContent(ctx context.Context, filePat
 
 // ProcessFileWithContentAndFileID processes a single source file with provided content and FileID
 // This version is used by the IndexBuilder which provides centralized FileID from MySQL
+// commitSHA is empty for ephemeral (uncommitted) files
 // Returns (chunks, error) - if error is non-nil, processing failed but can be retried
-func (ccs *CodeChunkService) ProcessFileWithContentAndFileID(ctx context.Context, filePath, language, collectionName string, sourceCode []byte, fileID int32) ([]*model.CodeChunk, error) {
+func (ccs *CodeChunkService) ProcessFileWithContentAndFileID(ctx context.Context, filePath, language, collectionName string, sourceCode []byte, fileID int32, commitSHA string) ([]*model.CodeChunk, error) {
 	// Check for existing chunks in the database
 	existingChunks, err := ccs.vectorDB.GetChunksByFilePath(ctx, collectionName, filePath)
 	if err != nil {
-		ccs.logger.Warn("Failed to fetch existing chunks, will process file anyway",
+		ccs.log(ctx).Warn("Failed to fetch existing chunks, will process file anyway",
 			zap.String("file", filePath),
 			zap.Int32("file_id", fileID),
 			zap.Error(err))
@@ -176,10 +258,10 @@ func (ccs *CodeChunkService) ProcessFileWithContentAndFileID(ctx context.Context
 	}
 
 	// Parse file and generate chunks
-	chunks, err := ccs.parseAndChunk(ctx, filePath, language, sourceCode)
+	chunks, err := ccs.parseAndChunk(ctx, filePath, language, sourceCode, ccs.stopChunks(collectionName))
 	if err != nil {
 		// Parse errors might indicate corrupted files or unsupported syntax - log and skip
-		ccs.logger.Warn("Failed to parse file, skipping",
+		ccs.log(ctx).Warn("Failed to parse file, skipping",
 			zap.String("file", filePath),
 			zap.String("language", language),
 			zap.Int32("file_id", fileID),
@@ -188,15 +270,15 @@ func (ccs *CodeChunkService) ProcessFileWithContentAndFileID(ctx context.Context
 	}
 
 	if len(chunks) == 0 {
-		ccs.logger.Debug("No chunks generated for file",
+		ccs.log(ctx).Debug("No chunks generated for file",
 			zap.String("file", filePath),
 			zap.Int32("file_id", fileID))
 		return nil, nil
 	}
 
-	// Set FileID on all chunks
+	// Set FileID and commit on all chunks
 	for _, chunk := range chunks {
-		chunk.WithFileID(fileID)
+		chunk.WithFileID(fileID).WithCommitSHA(commitSHA)
 	}
 
 	// Build a map of existing chunk IDs for quick lookup
@@ -222,7 +304,7 @@ func (ccs *CodeChunkService) ProcessFileWithContentAndFileID(ctx context.Context
 		}
 	}
 
-	ccs.logger.Info("Chunk analysis for file",
+	ccs.log(ctx).Info("Chunk analysis for file",
 		zap.String("file", filePath),
 		zap.Int32("file_id", fileID),
 		zap.Int("total_chunks", len(chunks)),
@@ -235,7 +317,7 @@ func (ccs *CodeChunkService) ProcessFileWithContentAndFileID(ctx context.Context
 		newChunksWithEmbeddings, err := ccs.generateAndPrepareEmbeddings(ctx, newChunks)
 		if err != nil {
 			// Embedding errors might be transient (API issues) - log and skip
-			ccs.logger.Warn("Failed to generate embeddings, skipping file",
+			ccs.log(ctx).Warn("Failed to generate embeddings, skipping file",
 				zap.String("file", filePath),
 				zap.Int32("file_id", fileID),
 				zap.Error(err))
@@ -249,9 +331,10 @@ func (ccs *CodeChunkService) ProcessFileWithContentAndFileID(ctx context.Context
 
 	// Store all chunks in vector database (upsert will update existing ones)
 	if len(chunksToStore) > 0 {
+		ccs.stampExpiry(collectionName, chunksToStore)
 		if err := ccs.vectorDB.UpsertChunks(ctx, collectionName, chunksToStore); err != nil {
 			// Vector DB errors might be transient - log and skip
-			ccs.logger.Warn("Failed to store chunks, skipping file",
+			ccs.log(ctx).Warn("Failed to store chunks, skipping file",
 				zap.String("file", filePath),
 				zap.Int32("file_id", fileID),
 				zap.Error(err))
@@ -259,7 +342,7 @@ func (ccs *CodeChunkService) ProcessFileWithContentAndFileID(ctx context.Context
 		}
 	}
 
-	ccs.logger.Info("Processed file successfully",
+	ccs.log(ctx).Info("Processed file successfully",
 		zap.String("file", filePath),
 		zap.Int32("file_id", fileID),
 		zap.Int("original_chunks", len(chunks)),
@@ -271,39 +354,43 @@ func (ccs *CodeChunkService) ProcessFileWithContentAndFileID(ctx context.Context
 
 // ProcessDirectory processes all supported files in a directory recursively
 // Gracefully skips files that fail to read or process
-func (ccs *CodeChunkService) ProcessDirectory(ctx context.Context, dirPath, collectionName string, repoConfig interface{}) (int, error) {
+// If resolveFileID is non-nil, chunks are tagged with the FileID and commit it returns
+func (ccs *CodeChunkService) ProcessDirectory(ctx context.Context, dirPath, collectionName string, repoConfig interface{}, resolveFileID FileIDResolver) (int, error) {
 	totalChunks := 0
 	filesFailed := 0
 
 	// Extract repository configuration if provided
 	var skipOtherLanguages bool
 	var repoLanguage string
-	if repo, ok := repoConfig.(*config.Repository); ok && repo != nil {
+	var walkOpts util.WalkOptions
+	repo, _ := repoConfig.(*config.Repository)
+	if repo != nil {
+		walkOpts = util.WalkOptionsFor(repo)
 		skipOtherLanguages = repo.SkipOtherLanguages
 		repoLanguage = repo.Language
 		if skipOtherLanguages {
-			ccs.logger.Info("Skip other languages enabled",
+			ccs.log(ctx).Info("Skip other languages enabled",
 				zap.String("repo_language", repoLanguage),
 				zap.String("dir", dirPath))
 		}
 	}
 
-	err := util.WalkDirTree(dirPath, func(path string, err error) error {
+	err := util.WalkDirTreeWithOptions(ctx, dirPath, func(path string, err error) error {
 		if err != nil {
 			return err
 		}
 
 		language := ccs.detectLanguage(path)
 		if language == "" {
-			ccs.logger.Info("WalkDirTree - Skipping unsupported file", zap.String("path", path))
+			ccs.log(ctx).Info("WalkDirTree - Skipping unsupported file", zap.String("path", path))
 			return nil
 		}
 		// Process file
-		chunks, err := ccs.ProcessFile(ctx, path, language, collectionName)
+		chunks, err := ccs.processDirectoryFile(ctx, repo, path, language, collectionName, resolveFileID)
 		if err != nil {
 			// This shouldn't happen as ProcessFile now handles errors internally
 			// But keep this as a safeguard
-			ccs.logger.Error("WalkDirTree - Unexpected error processing file", zap.String("path", path), zap.Error(err))
+			ccs.log(ctx).Error("WalkDirTree - Unexpected error processing file", zap.String("path", path), zap.Error(err))
 			filesFailed++
 			return nil // Continue processing other files
 		}
@@ -322,7 +409,7 @@ func (ccs *CodeChunkService) ProcessDirectory(ctx context.Context, dirPath, coll
 			// Skip excluded directories
 			if isDir {
 				if ccs.shouldSkipDirectory(path, filepath.Base(path)) {
-					ccs.logger.Info("WalkDirTree - Skipping directory", zap.String("path", path))
+					ccs.log(ctx).Info("WalkDirTree - Skipping directory", zap.String("path", path))
 					return true
 				}
 				return false
@@ -330,13 +417,13 @@ func (ccs *CodeChunkService) ProcessDirectory(ctx context.Context, dirPath, coll
 
 			language := ccs.detectLanguage(path)
 			if language == "" {
-				ccs.logger.Info("WalkDirTree - Skipping unsupported file", zap.String("path", path))
+				ccs.log(ctx).Info("WalkDirTree - Skipping unsupported file", zap.String("path", path))
 				return true
 			}
 
 			// Skip files of other languages if skip_other_languages is enabled
 			if skipOtherLanguages && language != repoLanguage {
-				ccs.logger.Error("WalkDirTree - Skipping file due to language mismatch",
+				ccs.log(ctx).Error("WalkDirTree - Skipping file due to language mismatch",
 					zap.String("path", path),
 					zap.String("file_language", language),
 					zap.String("repo_language", repoLanguage))
@@ -346,7 +433,10 @@ func (ccs *CodeChunkService) ProcessDirectory(ctx context.Context, dirPath, coll
 		},
 		ccs.logger,
 		ccs.gcThreshold,
-		ccs.numFileThreads)
+		ccs.numFileThreads,
+		nil,
+		nil,
+		walkOpts)
 
 	if err != nil {
 		return totalChunks, fmt.Errorf("WalkDirTree - failed to process directory: %w", err)
@@ -355,7 +445,7 @@ func (ccs *CodeChunkService) ProcessDirectory(ctx context.Context, dirPath, coll
 	// Final GC to clean up
 	runtime.GC()
 
-	ccs.logger.Info("WalkDirTree - Processed directory successfully",
+	ccs.log(ctx).Info("WalkDirTree - Processed directory successfully",
 		zap.String("dir", dirPath),
 		//zap.Int("files_processed", filesProcessed),
 		//zap.Int("files_skipped", filesSkipped),
@@ -364,27 +454,144 @@ func (ccs *CodeChunkService) ProcessDirectory(ctx context.Context, dirPath, coll
 	return totalChunks, nil
 }
 
-// SearchSimilarCode searches for code chunks similar to the given query text
-func (ccs *CodeChunkService) SearchSimilarCode(ctx context.Context, collectionName, queryText string, limit int, filter map[string]interface{}) ([]*model.CodeChunk, []float32, error) {
+// processDirectoryFile processes one file from ProcessDirectory, resolving its FileID when possible
+func (ccs *CodeChunkService) processDirectoryFile(ctx context.Context, repo *config.Repository, filePath, language, collectionName string, resolveFileID FileIDResolver) ([]*model.CodeChunk, error) {
+	sourceCode, err := ccs.readFile(filePath)
+	if err != nil {
+		ccs.log(ctx).Warn("Failed to read file, skipping",
+			zap.String("file", filePath),
+			zap.Error(err))
+		return nil, nil
+	}
+
+	// Binary, non-UTF8, large, generated and minified files are not chunked
+	reason := util.ContentSkipReason(sourceCode)
+	if reason == "" {
+		reason = util.LightweightReason(filePath, sourceCode, repo)
+	}
+	if reason != "" {
+		ccs.log(ctx).Debug("Skipping chunking for lightweight file",
+			zap.String("file", filePath),
+			zap.String("reason", reason))
+		return []*model.CodeChunk{}, nil
+	}
+
+	if resolveFileID == nil {
+		return ccs.ProcessFileWithContent(ctx, filePath, language, collectionName, sourceCode)
+	}
+
+	fileID, commitSHA, err := resolveFileID(filePath, sourceCode)
+	if err != nil {
+		// Still index the file, just without a FileID
+		ccs.log(ctx).Warn("Failed to resolve FileID, processing without it",
+			zap.String("file", filePath),
+			zap.Error(err))
+		return ccs.ProcessFileWithContent(ctx, filePath, language, collectionName, sourceCode)
+	}
+	return ccs.ProcessFileWithContentAndFileID(ctx, filePath, language, collectionName, sourceCode, fileID, commitSHA)
+}
+
+// SearchSimilarCode searches for code chunks similar to the given query text.
+// If dedupe is true, no-context duplicates are collapsed into their original chunk.
+func (ccs *CodeChunkService) SearchSimilarCode(ctx context.Context, collectionName, queryText string, limit int, filter map[string]interface{}, dedupe bool) ([]*model.CodeChunk, []float32, error) {
 	// Generate embedding for query text
 	queryVector, err := ccs.embedding.GenerateEmbedding(ctx, queryText)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to generate query embedding: %w", err)
+		return nil, nil, fmt.Errorf("failed to generate query embedding: %w: %w", apperrors.ErrEmbeddingUnavailable, err)
 	}
 
 	// Search in vector database
-	chunks, scores, err := ccs.vectorDB.SearchSimilar(ctx, collectionName, queryVector, limit, filter)
+	chunks, scores, err := ccs.vectorDB.SearchSimilar(ctx, collectionName, queryVector, searchFetchLimit(limit, dedupe), filter)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to search: %w", err)
 	}
 
+	if !dedupe {
+		return chunks, scores, nil
+	}
+
+	results := make(map[string]*resultWithScore)
+	for i, chunk := range chunks {
+		mergeSearchResult(results, chunk, scores[i], 0, dedupe)
+	}
+	chunks, scores, _ = sortedSearchResults(results, limit)
 	return chunks, scores, nil
 }
 
-// SearchSimilarCodeBySnippet chunks a code snippet and searches for similar code in the database
-func (ccs *CodeChunkService) SearchSimilarCodeBySnippet(ctx context.Context, collectionName, codeSnippet, language string, limit int, filter map[string]interface{}) ([]*model.CodeChunk, []*model.CodeChunk, []float32, []int, error) {
+// searchFetchLimit over-fetches when deduplicating so collapsing duplicate
+// copies does not leave the caller with fewer than limit results
+func searchFetchLimit(limit int, dedupe bool) int {
+	if dedupe {
+		return limit * 2
+	}
+	return limit
+}
+
+// dedupeKey returns the identity a search hit is aggregated under. With dedupe
+// enabled, a no-context duplicate maps to the chunk it was derived from.
+func dedupeKey(chunk *model.CodeChunk, dedupe bool) string {
+	if dedupe && chunk.Metadata != nil {
+		if originalID, ok := chunk.Metadata["original_id"].(string); ok && originalID != "" {
+			return originalID
+		}
+	}
+	return chunk.ID
+}
+
+// mergeSearchResult adds a hit to the aggregated results, keeping the best score per key.
+// When an original and its no-context copy both match, the original chunk is reported.
+func mergeSearchResult(results map[string]*resultWithScore, chunk *model.CodeChunk, score float32, queryChunkIndex int, dedupe bool) {
+	key := dedupeKey(chunk, dedupe)
+	existing, ok := results[key]
+	if !ok {
+		results[key] = &resultWithScore{
+			chunk:           chunk,
+			score:           score,
+			queryChunkIndex: queryChunkIndex,
+		}
+		return
+	}
+
+	if chunk.ID == key {
+		existing.chunk = chunk
+	}
+	// Keep the higher score and update query chunk index
+	if score > existing.score {
+		existing.score = score
+		existing.queryChunkIndex = queryChunkIndex
+	}
+}
+
+// sortedSearchResults flattens aggregated results sorted by score descending, truncated to limit
+func sortedSearchResults(results map[string]*resultWithScore, limit int) ([]*model.CodeChunk, []float32, []int) {
+	ordered := make([]*resultWithScore, 0, len(results))
+	for _, result := range results {
+		ordered = append(ordered, result)
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].score > ordered[j].score
+	})
+
+	if len(ordered) > limit {
+		ordered = ordered[:limit]
+	}
+
+	chunks := make([]*model.CodeChunk, 0, len(ordered))
+	scores := make([]float32, 0, len(ordered))
+	queryChunkIndices := make([]int, 0, len(ordered))
+	for _, result := range ordered {
+		chunks = append(chunks, result.chunk)
+		scores = append(scores, result.score)
+		queryChunkIndices = append(queryChunkIndices, result.queryChunkIndex)
+	}
+	return chunks, scores, queryChunkIndices
+}
+
+// SearchSimilarCodeBySnippet chunks a code snippet and searches for similar code in the database.
+// If dedupe is true, no-context duplicates are collapsed into their original chunk.
+func (ccs *CodeChunkService) SearchSimilarCodeBySnippet(ctx context.Context, collectionName, codeSnippet, language string, limit int, filter map[string]interface{}, dedupe bool) ([]*model.CodeChunk, []*model.CodeChunk, []float32, []int, error) {
 	// Parse and chunk the code snippet
-	queryChunks, err := ccs.parseAndChunk(ctx, "query.snippet", language, []byte(codeSnippet))
+	queryChunks, err := ccs.parseAndChunk(ctx, "query.snippet", language, []byte(codeSnippet), nil)
 	if err != nil {
 		return nil, nil, nil, nil, fmt.Errorf("failed to parse code snippet: %w", err)
 	}
@@ -396,22 +603,26 @@ func (ccs *CodeChunkService) SearchSimilarCodeBySnippet(ctx context.Context, col
 	// For each query chunk, generate embeddings and search
 	// We'll aggregate results from all query chunks
 	allResults := make(map[string]*resultWithScore)
+	var embedErr error
+	embedded := 0
 
 	for queryChunkIndex, queryChunk := range queryChunks {
 		// Generate embedding for the query chunk (with context)
 		searchableText := queryChunk.GetSearchableText(true)
 		queryVector, err := ccs.embedding.GenerateEmbedding(ctx, searchableText)
 		if err != nil {
-			ccs.logger.Warn("Failed to generate embedding for query chunk",
+			ccs.log(ctx).Warn("Failed to generate embedding for query chunk",
 				zap.String("chunk_type", string(queryChunk.ChunkType)),
 				zap.Error(err))
+			embedErr = err
 			continue
 		}
+		embedded++
 
 		// Search in vector database
-		resultChunks, scores, err := ccs.vectorDB.SearchSimilar(ctx, collectionName, queryVector, limit, filter)
+		resultChunks, scores, err := ccs.vectorDB.SearchSimilar(ctx, collectionName, queryVector, searchFetchLimit(limit, dedupe), filter)
 		if err != nil {
-			ccs.logger.Warn("Failed to search for query chunk",
+			ccs.log(ctx).Warn("Failed to search for query chunk",
 				zap.String("chunk_type", string(queryChunk.ChunkType)),
 				zap.Error(err))
 			continue
@@ -419,51 +630,16 @@ func (ccs *CodeChunkService) SearchSimilarCodeBySnippet(ctx context.Context, col
 
 		// Aggregate results (keep highest score for each unique chunk)
 		for i, chunk := range resultChunks {
-			if existing, ok := allResults[chunk.ID]; ok {
-				// Keep the higher score and update query chunk index
-				if scores[i] > existing.score {
-					existing.score = scores[i]
-					existing.queryChunkIndex = queryChunkIndex
-				}
-			} else {
-				allResults[chunk.ID] = &resultWithScore{
-					chunk:           chunk,
-					score:           scores[i],
-					queryChunkIndex: queryChunkIndex,
-				}
-			}
+			mergeSearchResult(allResults, chunk, scores[i], queryChunkIndex, dedupe)
 		}
 	}
 
-	// Convert map to slices and sort by score
-	chunks := make([]*model.CodeChunk, 0, len(allResults))
-	scores := make([]float32, 0, len(allResults))
-	queryChunkIndices := make([]int, 0, len(allResults))
-
-	for _, result := range allResults {
-		chunks = append(chunks, result.chunk)
-		scores = append(scores, result.score)
-		queryChunkIndices = append(queryChunkIndices, result.queryChunkIndex)
-	}
-
-	// Sort by score descending (keep indices aligned)
-	for i := 0; i < len(scores)-1; i++ {
-		for j := i + 1; j < len(scores); j++ {
-			if scores[j] > scores[i] {
-				scores[i], scores[j] = scores[j], scores[i]
-				chunks[i], chunks[j] = chunks[j], chunks[i]
-				queryChunkIndices[i], queryChunkIndices[j] = queryChunkIndices[j], queryChunkIndices[i]
-			}
-		}
-	}
-
-	// Limit results
-	if len(chunks) > limit {
-		chunks = chunks[:limit]
-		scores = scores[:limit]
-		queryChunkIndices = queryChunkIndices[:limit]
+	// Without a single query embedding the empty result would be misleading
+	if embedded == 0 {
+		return queryChunks, nil, nil, nil, fmt.Errorf("failed to generate query embeddings: %w: %w", apperrors.ErrEmbeddingUnavailable, embedErr)
 	}
 
+	chunks, scores, queryChunkIndices := sortedSearchResults(allResults, limit)
 	return queryChunks, chunks, scores, queryChunkIndices, nil
 }
 
@@ -473,6 +649,73 @@ type resultWithScore struct {
 	queryChunkIndex int
 }
 
+// GetChunksByFileID returns the chunks stored for a FileID, so callers holding a
+// CodeGraph node or n-gram file model can join on FileID rather than file path
+func (ccs *CodeChunkService) GetChunksByFileID(ctx context.Context, collectionName string, fileID int32) ([]*model.CodeChunk, error) {
+	chunks, err := ccs.vectorDB.GetChunksByFileID(ctx, collectionName, fileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chunks for file ID %d: %w", fileID, err)
+	}
+	return chunks, nil
+}
+
+// GetChunkNeighborhood returns the parent, siblings and preceding/following chunks
+// of the given chunk so callers can expand context around a search hit.
+// No-context duplicates are skipped and embeddings are stripped from the result.
+func (ccs *CodeChunkService) GetChunkNeighborhood(ctx context.Context, collectionName, chunkID string) (*model.ChunkNeighborhood, error) {
+	chunk, err := ccs.vectorDB.GetChunkByID(ctx, collectionName, chunkID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chunk: %w", err)
+	}
+	// A no-context duplicate stands in for its original chunk
+	if originalID, ok := chunk.Metadata["original_id"].(string); ok && originalID != "" {
+		if original, err := ccs.vectorDB.GetChunkByID(ctx, collectionName, originalID); err == nil {
+			chunk = original
+		}
+	}
+
+	fileChunks, err := ccs.vectorDB.GetChunksByFilePath(ctx, collectionName, chunk.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chunks for file %s: %w", chunk.FilePath, err)
+	}
+
+	chunk.Embedding = nil
+	neighborhood := &model.ChunkNeighborhood{
+		Chunk:    chunk,
+		Siblings: make([]*model.CodeChunk, 0),
+	}
+
+	for _, other := range fileChunks {
+		if other.ID == chunk.ID || other.Metadata["context_mode"] == "nocontext" {
+			continue
+		}
+		other.Embedding = nil
+
+		if chunk.ParentID != "" && other.ID == chunk.ParentID {
+			neighborhood.Parent = other
+		}
+		if chunk.ParentID != "" && other.ParentID == chunk.ParentID {
+			neighborhood.Siblings = append(neighborhood.Siblings, other)
+		}
+		if other.EndLine < chunk.StartLine {
+			if neighborhood.Previous == nil || other.EndLine > neighborhood.Previous.EndLine {
+				neighborhood.Previous = other
+			}
+		}
+		if other.StartLine > chunk.EndLine {
+			if neighborhood.Next == nil || other.StartLine < neighborhood.Next.StartLine {
+				neighborhood.Next = other
+			}
+		}
+	}
+
+	sort.Slice(neighborhood.Siblings, func(i, j int) bool {
+		return neighborhood.Siblings[i].StartLine < neighborhood.Siblings[j].StartLine
+	})
+
+	return neighborhood, nil
+}
+
 // CreateCollection creates a new collection in the vector database
 func (ccs *CodeChunkService) CreateCollection(ctx context.Context, collectionName string) error {
 	exists, err := ccs.vectorDB.CollectionExists(ctx, collectionName)
@@ -481,7 +724,7 @@ func (ccs *CodeChunkService) CreateCollection(ctx context.Context, collectionNam
 	}
 
 	if exists {
-		ccs.logger.Info("Collection already exists", zap.String("collection", collectionName))
+		ccs.log(ctx).Info("Collection already exists", zap.String("collection", collectionName))
 		return nil
 	}
 
@@ -490,7 +733,7 @@ func (ccs *CodeChunkService) CreateCollection(ctx context.Context, collectionNam
 		return fmt.Errorf("failed to create collection: %w", err)
 	}
 
-	ccs.logger.Info("Created collection", zap.String("collection", collectionName), zap.Int("dimension", dimension))
+	ccs.log(ctx).Info("Created collection", zap.String("collection", collectionName), zap.Int("dimension", dimension))
 	return nil
 }
 
@@ -500,13 +743,57 @@ func (ccs *CodeChunkService) DeleteCollection(ctx context.Context, collectionNam
 		return fmt.Errorf("failed to delete collection: %w", err)
 	}
 
-	ccs.logger.Info("Deleted collection", zap.String("collection", collectionName))
+	ccs.log(ctx).Info("Deleted collection", zap.String("collection", collectionName))
 	return nil
 }
 
+// PurgeChunks deletes the chunks of collectionName matching filter
+func (ccs *CodeChunkService) PurgeChunks(ctx context.Context, collectionName string, filter PurgeFilter) (int, error) {
+	deleted, err := ccs.vectorDB.PurgeChunks(ctx, collectionName, filter)
+	if err != nil {
+		return deleted, fmt.Errorf("failed to purge chunks: %w", err)
+	}
+
+	ccs.log(ctx).Info("Purged chunks",
+		zap.String("collection", collectionName),
+		zap.String("path_prefix", filter.PathPrefix),
+		zap.String("language", filter.Language),
+		zap.Int("deleted", deleted))
+	return deleted, nil
+}
+
+// SweepExpiredChunks purges chunks whose TTL has passed from every collection. A failing
+// collection is logged and skipped so one bad collection does not block the rest.
+func (ccs *CodeChunkService) SweepExpiredChunks(ctx context.Context) (int, error) {
+	collections, err := ccs.vectorDB.ListCollections(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list collections: %w", err)
+	}
+
+	total := 0
+	filter := PurgeFilter{ExpiredBefore: time.Now()}
+	for _, collectionName := range collections {
+		deleted, err := ccs.vectorDB.PurgeChunks(ctx, collectionName, filter)
+		total += deleted
+		if err != nil {
+			ccs.log(ctx).Warn("Failed to sweep expired chunks",
+				zap.String("collection", collectionName),
+				zap.Error(err))
+			continue
+		}
+		if deleted > 0 {
+			ccs.log(ctx).Info("Swept expired chunks",
+				zap.String("collection", collectionName),
+				zap.Int("deleted", deleted))
+		}
+	}
+	return total, nil
+}
+
 // Helper methods
 
-func (ccs *CodeChunkService) parseAndChunk(ctx context.Context, filePath, language string, sourceCode []byte) ([]*model.CodeChunk, error) {
+// parseAndChunk splits a file into chunks, leaving out the boilerplate in stop
+func (ccs *CodeChunkService) parseAndChunk(ctx context.Context, filePath, language string, sourceCode []byte, stop chunk.StopSet) ([]*model.CodeChunk, error) {
 	// Get tree-sitter language
 	tsLanguage, err := ccs.getTreeSitterLanguage(language)
 	if err != nil {
@@ -531,11 +818,17 @@ func (ccs *CodeChunkService) parseAndChunk(ctx context.Context, filePath, langua
 
 	// Create chunk visitor
 	visitor := chunk.NewChunkVisitor(ccs.logger, language, filePath, sourceCode, ccs.minConditionalLines, ccs.minLoopLines)
+	visitor.SetStopChunks(stop)
 
 	// Traverse syntax tree
 	rootNode := tree.RootNode()
 	visitor.TraverseNode(ctx, rootNode, nil)
 
+	if stopped := visitor.Stopped(); len(stopped) > 0 {
+		ccs.log(ctx).Debug("Skipped boilerplate chunks",
+			zap.String("file", filePath),
+			zap.Any("stopped", stopped))
+	}
 	return visitor.GetChunks(), nil
 }
 
@@ -567,7 +860,7 @@ func (ccs *CodeChunkService) generateAndPrepareEmbeddings(ctx context.Context, c
 				texts = append(texts, text)
 				validChunks = append(validChunks, chunk)
 			} else {
-				ccs.logger.Warn("Skipping chunk with empty searchable text",
+				ccs.log(ctx).Warn("Skipping chunk with empty searchable text",
 					zap.String("id", chunk.ID),
 					zap.String("type", string(chunk.ChunkType)),
 					zap.String("file", chunk.FilePath))
@@ -575,7 +868,7 @@ func (ccs *CodeChunkService) generateAndPrepareEmbeddings(ctx context.Context, c
 		}
 
 		if len(texts) == 0 {
-			ccs.logger.Warn("No valid texts for embedding generation in needsOneEmbedding")
+			ccs.log(ctx).Warn("No valid texts for embedding generation in needsOneEmbedding")
 		} else {
 			embeddings, err := ccs.embedding.GenerateEmbeddings(ctx, texts)
 			if err != nil {
@@ -585,7 +878,7 @@ func (ccs *CodeChunkService) generateAndPrepareEmbeddings(ctx context.Context, c
 			for i, embedding := range embeddings {
 				validChunks[i].Embedding = embedding
 				/*
-					ccs.logger.Info("Generated embedding for chunk",
+					ccs.log(ctx).Info("Generated embedding for chunk",
 						zap.String("id", validChunks[i].ID),
 						zap.String("type", string(validChunks[i].ChunkType)),
 						zap.String("file", validChunks[i].FilePath),
@@ -614,7 +907,7 @@ func (ccs *CodeChunkService) generateAndPrepareEmbeddings(ctx context.Context, c
 				textsWithContext = append(textsWithContext, text)
 				validTwoEmbeddingChunks = append(validTwoEmbeddingChunks, chunk)
 			} else {
-				ccs.logger.Warn("Skipping chunk with empty searchable text (with context)",
+				ccs.log(ctx).Warn("Skipping chunk with empty searchable text (with context)",
 					zap.String("id", chunk.ID),
 					zap.String("type", string(chunk.ChunkType)),
 					zap.String("file", chunk.FilePath))
@@ -622,7 +915,7 @@ func (ccs *CodeChunkService) generateAndPrepareEmbeddings(ctx context.Context, c
 		}
 
 		if len(textsWithContext) == 0 {
-			ccs.logger.Warn("No valid texts for embedding generation in needsTwoEmbeddings")
+			ccs.log(ctx).Warn("No valid texts for embedding generation in needsTwoEmbeddings")
 		} else {
 			embeddingsWithContext, err := ccs.embedding.GenerateEmbeddings(ctx, textsWithContext)
 			if err != nil {
@@ -655,7 +948,7 @@ func (ccs *CodeChunkService) generateAndPrepareEmbeddings(ctx context.Context, c
 				//noContextID := ccs.generateNoContextID(validTwoEmbeddingChunks[i].ID)
 
 				/*
-					ccs.logger.Info("Generated embedding for chunk (with context)",
+					ccs.log(ctx).Info("Generated embedding for chunk (with context)",
 						zap.String("id", validTwoEmbeddingChunks[i].ID),
 						zap.String("type", string(validTwoEmbeddingChunks[i].ChunkType)),
 						zap.String("file", validTwoEmbeddingChunks[i].FilePath),
@@ -666,7 +959,7 @@ func (ccs *CodeChunkService) generateAndPrepareEmbeddings(ctx context.Context, c
 						zap.Int("embedding_dim", len(embeddingsWithContext[i])),
 						zap.Bool("with_context", true))
 
-					ccs.logger.Info("Generated embedding for chunk (without context)",
+					ccs.log(ctx).Info("Generated embedding for chunk (without context)",
 						zap.String("id", noContextID),
 						zap.String("type", string(validTwoEmbeddingChunks[i].ChunkType)),
 						zap.String("file", validTwoEmbeddingChunks[i].FilePath),
@@ -738,6 +1031,8 @@ func (ccs *CodeChunkService) detectLanguage(filePath string) string {
 		return "javascript"
 	case ".ts", ".tsx":
 		return "typescript"
+	case ".rs":
+		return "rust"
 	default:
 		return ""
 	}
@@ -755,8 +1050,10 @@ func (ccs *CodeChunkService) getTreeSitterLanguage(language string) (*tree_sitte
 		return tree_sitter.NewLanguage(javascript.Language()), nil
 	case "typescript":
 		return tree_sitter.NewLanguage(typescript.LanguageTypescript()), nil
+	case "rust":
+		return tree_sitter.NewLanguage(rust.Language()), nil
 	default:
-		return nil, fmt.Errorf("unsupported language: %s", language)
+		return nil, fmt.Errorf("%w: %s", apperrors.ErrUnsupportedLanguage, language)
 	}
 }
 
@@ -770,28 +1067,34 @@ func (ccs *CodeChunkService) readFile(filePath string) ([]byte, error) {
 	return content, nil
 }
 
-// ReadCodeFromFile reads specific lines from a file
+// ReadCodeFromFile reads specific lines from a file. Lines are split with the same
+// util.LineIndex used to build chunk ranges, so CRLF endings and multi-byte characters
+// line up with the stored positions.
 func (ccs *CodeChunkService) ReadCodeFromFile(filePath string, startLine, endLine int) (string, error) {
 	content, err := ccs.readFile(filePath)
 	if err != nil {
 		return "", fmt.Errorf("failed to read file: %w", err)
 	}
 
-	lines := strings.Split(string(content), "\n")
+	index := util.NewLineIndex(content)
+	lineCount := index.LineCount()
 
 	// Validate line numbers (0-indexed internally)
-	if startLine < 0 || startLine >= len(lines) {
+	if startLine < 0 || startLine >= lineCount {
 		return "", fmt.Errorf("invalid start line: %d", startLine)
 	}
-	if endLine < 0 || endLine >= len(lines) {
-		endLine = len(lines) - 1
+	if endLine < 0 || endLine >= lineCount {
+		endLine = lineCount - 1
 	}
 	if startLine > endLine {
 		return "", fmt.Errorf("start line (%d) greater than end line (%d)", startLine, endLine)
 	}
 
 	// Extract lines (inclusive)
-	codeLines := lines[startLine : endLine+1]
+	codeLines := make([]string, 0, endLine-startLine+1)
+	for line := startLine; line <= endLine; line++ {
+		codeLines = append(codeLines, string(index.Line(line)))
+	}
 	return strings.Join(codeLines, "\n"), nil
 }
 
@@ -871,3 +1174,8 @@ func (ccs *CodeChunkService) generateNoContextID(originalID string) string {
 		hashStr[20:32],
 	)
 }
+
+// log returns the logger with the request ID carried by ctx attached
+func (ccs *CodeChunkService) log(ctx context.Context) *zap.Logger {
+	return logging.FromContext(ctx, ccs.logger)
+}
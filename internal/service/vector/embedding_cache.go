@@ -0,0 +1,111 @@
+package vector
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"time"
+
+	"bot-go/internal/cache"
+
+	"go.uber.org/zap"
+)
+
+// cachedEmbeddingModel serves the embeddings of texts embedded before from a cache
+type cachedEmbeddingModel struct {
+	EmbeddingModel
+	cache  cache.Cache
+	ttl    time.Duration
+	logger *zap.Logger
+}
+
+// EmbeddingWithCache keeps the embeddings computed by model in c for ttl, keyed by the model
+// name and the SHA-256 of the text, so the instances sharing c embed a text once. When c
+// fails the texts are embedded again. A nil c returns model unchanged.
+func EmbeddingWithCache(model EmbeddingModel, c cache.Cache, ttl time.Duration, logger *zap.Logger) EmbeddingModel {
+	if c == nil {
+		return model
+	}
+	return &cachedEmbeddingModel{EmbeddingModel: model, cache: c, ttl: ttl, logger: logger}
+}
+
+// GenerateEmbedding returns the cached embedding of text, or embeds and caches it
+func (m *cachedEmbeddingModel) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	if embedding, ok := m.lookup(ctx, text); ok {
+		return embedding, nil
+	}
+	embedding, err := m.EmbeddingModel.GenerateEmbedding(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	m.store(ctx, text, embedding)
+	return embedding, nil
+}
+
+// GenerateEmbeddings embeds the texts missing from the cache in one batch
+func (m *cachedEmbeddingModel) GenerateEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, len(texts))
+	var missing []int
+	for i, text := range texts {
+		if embedding, ok := m.lookup(ctx, text); ok {
+			embeddings[i] = embedding
+		} else {
+			missing = append(missing, i)
+		}
+	}
+	if len(missing) == 0 {
+		return embeddings, nil
+	}
+
+	batch := make([]string, len(missing))
+	for j, i := range missing {
+		batch[j] = texts[i]
+	}
+	computed, err := m.EmbeddingModel.GenerateEmbeddings(ctx, batch)
+	if err != nil {
+		return nil, err
+	}
+	if len(computed) != len(batch) {
+		return nil, fmt.Errorf("embedding model returned %d embeddings for %d texts", len(computed), len(batch))
+	}
+	for j, i := range missing {
+		embeddings[i] = computed[j]
+		m.store(ctx, texts[i], computed[j])
+	}
+	return embeddings, nil
+}
+
+func (m *cachedEmbeddingModel) key(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return "embedding:" + m.GetModelName() + ":" + hex.EncodeToString(sum[:])
+}
+
+func (m *cachedEmbeddingModel) lookup(ctx context.Context, text string) ([]float32, bool) {
+	data, ok, err := m.cache.Get(ctx, m.key(text))
+	if err != nil {
+		m.logger.Warn("Failed to read cached embedding", zap.Error(err))
+		return nil, false
+	}
+	if !ok || len(data) == 0 || len(data)%4 != 0 {
+		return nil, false
+	}
+	embedding := make([]float32, len(data)/4)
+	for i := range embedding {
+		embedding[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+	}
+	return embedding, true
+}
+
+// store caches an embedding as little-endian float32s
+func (m *cachedEmbeddingModel) store(ctx context.Context, text string, embedding []float32) {
+	data := make([]byte, 4*len(embedding))
+	for i, v := range embedding {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(v))
+	}
+	if err := m.cache.Set(ctx, m.key(text), data, m.ttl); err != nil {
+		m.logger.Warn("Failed to cache embedding", zap.Error(err))
+	}
+}
@@ -0,0 +1,61 @@
+package vector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bot-go/internal/cache"
+
+	"go.uber.org/zap"
+)
+
+// countingEmbeddingModel embeds a text as its length and records the texts it embedded
+type countingEmbeddingModel struct {
+	EmbeddingModel
+	embedded []string
+}
+
+func (m *countingEmbeddingModel) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	m.embedded = append(m.embedded, text)
+	return []float32{float32(len(text)), 0.5}, nil
+}
+
+func (m *countingEmbeddingModel) GenerateEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		embeddings[i], _ = m.GenerateEmbedding(ctx, text)
+	}
+	return embeddings, nil
+}
+
+func (m *countingEmbeddingModel) GetModelName() string {
+	return "test-model"
+}
+
+func TestEmbeddingWithCache(t *testing.T) {
+	ctx := context.Background()
+	shared := cache.NewMemory()
+	first, second := &countingEmbeddingModel{}, &countingEmbeddingModel{}
+	a := EmbeddingWithCache(first, shared, time.Hour, zap.NewNop())
+	b := EmbeddingWithCache(second, shared, time.Hour, zap.NewNop())
+
+	if _, err := a.GenerateEmbedding(ctx, "ab"); err != nil {
+		t.Fatal(err)
+	}
+	embeddings, err := b.GenerateEmbeddings(ctx, []string{"abc", "ab", "abcd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []float32{3, 2, 4} {
+		if len(embeddings[i]) != 2 || embeddings[i][0] != want || embeddings[i][1] != 0.5 {
+			t.Errorf("embedding %d = %v, want [%v 0.5]", i, embeddings[i], want)
+		}
+	}
+	if len(second.embedded) != 2 || second.embedded[0] != "abc" || second.embedded[1] != "abcd" {
+		t.Errorf("second instance embedded %q, want only the texts missing from the cache", second.embedded)
+	}
+	if _, err := a.GenerateEmbedding(ctx, "abcd"); err != nil || len(first.embedded) != 1 {
+		t.Errorf("first instance embedded %q, want the cached text served from the cache", first.embedded)
+	}
+}
@@ -0,0 +1,206 @@
+package vector
+
+import (
+	"bot-go/internal/apperrors"
+	"bot-go/internal/model"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultSignatureWeight is the share of an equivalent function's score that comes from
+	// signature similarity; the rest is embedding similarity
+	DefaultSignatureWeight = 0.3
+
+	equivalentCandidates = 3 // functions fetched per requested result, then reranked
+)
+
+// FindEquivalents returns the functions of collectionName most similar to the function that
+// starts on line (0-based) of a file from another repository, and that function's chunk. The
+// file is chunked as at indexing so the query embeds like the functions it is compared to.
+// Candidates found by embedding similarity are reranked by
+// (1-signatureWeight)·embedding + signatureWeight·SignatureSimilarity.
+func (ccs *CodeChunkService) FindEquivalents(ctx context.Context, collectionName, filePath, language string, content []byte, line, limit int, signatureWeight float64) (*model.CodeChunk, []model.EquivalentFunction, error) {
+	if detected := ccs.detectLanguage(filePath); detected != "" {
+		language = detected
+	}
+	chunks, err := ccs.parseAndChunk(ctx, filePath, language, content, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse %s: %w", filePath, err)
+	}
+	var query *model.CodeChunk
+	for _, chunk := range chunks {
+		if chunk.ChunkType == model.ChunkTypeFunction && chunk.StartLine == line {
+			query = chunk
+			break
+		}
+	}
+	if query == nil {
+		return nil, nil, fmt.Errorf("%w: no function chunk starts on line %d of %s", apperrors.ErrInvalidArgument, line+1, filePath)
+	}
+
+	queryVector, err := ccs.embedding.GenerateEmbedding(ctx, query.GetSearchableText(true))
+	if err != nil {
+		return query, nil, fmt.Errorf("failed to generate query embedding: %w: %w", apperrors.ErrEmbeddingUnavailable, err)
+	}
+	filter := map[string]interface{}{"chunk_type": string(model.ChunkTypeFunction)}
+	candidates, scores, err := ccs.vectorDB.SearchSimilar(ctx, collectionName, queryVector, limit*equivalentCandidates, filter)
+	if err != nil {
+		return query, nil, fmt.Errorf("failed to search %s: %w", collectionName, err)
+	}
+
+	results := make([]model.EquivalentFunction, len(candidates))
+	for i, candidate := range candidates {
+		signature := SignatureSimilarity(query, candidate)
+		results[i] = model.EquivalentFunction{
+			Chunk:          candidate,
+			Score:          (1-signatureWeight)*float64(scores[i]) + signatureWeight*signature,
+			EmbeddingScore: scores[i],
+			SignatureScore: signature,
+		}
+	}
+	sort.SliceStable(results, func(a, b int) bool {
+		return results[a].Score > results[b].Score
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	ccs.log(ctx).Debug("Found equivalent functions",
+		zap.String("collection", collectionName),
+		zap.String("function", query.Name),
+		zap.Int("candidates", len(candidates)),
+		zap.Int("results", len(results)))
+	return query, results, nil
+}
+
+// SignatureSimilarity scores how alike the signatures of two functions are, from 0 to 1,
+// across languages: half from the words of their names (getUser and get_user match), a
+// fifth from their parameter counts and the rest from the other words of their signatures,
+// such as parameter and type names
+func SignatureSimilarity(a, b *model.CodeChunk) float64 {
+	name := jaccard(identifierWords(a.Name), identifierWords(b.Name))
+
+	arity := 1.0
+	if pa, pb := len(signatureParameters(a)), len(signatureParameters(b)); pa != pb {
+		arity = 1 - float64(abs(pa-pb))/float64(max(pa, pb))
+	}
+
+	return 0.5*name + 0.2*arity + 0.3*jaccard(signatureWords(a), signatureWords(b))
+}
+
+// identifierWords splits an identifier into lowercase words at underscores and case changes:
+// parseHTTPRequest has the words parse, http and request
+func identifierWords(identifier string) map[string]bool {
+	words := make(map[string]bool)
+	runes := []rune(identifier)
+	start := 0
+	flush := func(end int) {
+		if end > start {
+			words[strings.ToLower(string(runes[start:end]))] = true
+		}
+	}
+	for i, r := range runes {
+		switch {
+		case r == '_' || r == '$':
+			flush(i)
+			start = i + 1
+		case i > start && unicode.IsUpper(r) &&
+			(!unicode.IsUpper(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))):
+			flush(i)
+			start = i
+		}
+	}
+	flush(len(runes))
+	return words
+}
+
+// signatureParameters returns the parameters of a function's signature, without self, cls
+// and this
+func signatureParameters(chunk *model.CodeChunk) []string {
+	signature := chunk.Signature
+	// Start after the name, so that a Go receiver is not taken for the parameters
+	if i := strings.Index(signature, chunk.Name); chunk.Name != "" && i >= 0 {
+		signature = signature[i+len(chunk.Name):]
+	}
+	open := strings.IndexByte(signature, '(')
+	if open < 0 {
+		return nil
+	}
+
+	var params []string
+	depth, start := 0, open+1
+	for i := open; i < len(signature); i++ {
+		switch signature[i] {
+		case '(', '[', '{', '<':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case '>':
+			if prev := signature[i-1]; prev != '=' && prev != '-' { // not => or ->
+				depth--
+			}
+		case ',':
+			if depth == 1 {
+				params = append(params, signature[start:i])
+				start = i + 1
+			}
+		}
+		if depth == 0 {
+			params = append(params, signature[start:i])
+			break
+		}
+	}
+
+	kept := params[:0]
+	for _, param := range params {
+		param = strings.TrimSpace(param)
+		name := strings.FieldsFunc(param, func(r rune) bool { return r == ':' || r == ' ' })
+		if param == "" || (len(name) > 0 && (name[0] == "self" || name[0] == "cls" || name[0] == "this")) {
+			continue
+		}
+		kept = append(kept, param)
+	}
+	return kept
+}
+
+// signatureWords returns the words of the identifiers of a signature other than the
+// function's name and keywords
+func signatureWords(chunk *model.CodeChunk) map[string]bool {
+	words := make(map[string]bool)
+	for identifier := range identifierSet(chunk.Signature) {
+		if identifier == chunk.Name {
+			continue
+		}
+		for word := range identifierWords(identifier) {
+			words[word] = true
+		}
+	}
+	return words
+}
+
+// jaccard returns the share of the words of a and b that both have
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for word := range a {
+		if b[word] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,66 @@
+package vector
+
+import (
+	"reflect"
+	"testing"
+
+	"bot-go/internal/model"
+)
+
+func function(name, signature string) *model.CodeChunk {
+	return &model.CodeChunk{ChunkType: model.ChunkTypeFunction, Name: name, Signature: signature}
+}
+
+func TestIdentifierWords(t *testing.T) {
+	tests := map[string][]string{
+		"parseHTTPRequest": {"http", "parse", "request"},
+		"get_user_by_id":   {"by", "get", "id", "user"},
+		"GetUserByID":      {"by", "get", "id", "user"},
+		"__init__":         {"init"},
+	}
+	for identifier, want := range tests {
+		got := make(map[string]bool)
+		for _, word := range want {
+			got[word] = true
+		}
+		if words := identifierWords(identifier); !reflect.DeepEqual(words, got) {
+			t.Errorf("identifierWords(%q) = %v, want %v", identifier, words, want)
+		}
+	}
+}
+
+func TestSignatureParameters(t *testing.T) {
+	tests := []struct {
+		chunk *model.CodeChunk
+		want  int
+	}{
+		{function("Get", "func (s *Store) Get(ctx context.Context, key string) (string, error)"), 2},
+		{function("get", "def get(self, key: str, default=None) -> str"), 2},
+		{function("put", "public void put(Map<String, Integer> values)"), 1},
+		{function("map", "map(fn: (x: number) => string, items: number[])"), 2},
+		{function("Close", "func (s *Store) Close() error"), 0},
+	}
+	for _, tt := range tests {
+		if got := signatureParameters(tt.chunk); len(got) != tt.want {
+			t.Errorf("signatureParameters(%q) = %q, want %d parameters", tt.chunk.Signature, got, tt.want)
+		}
+	}
+}
+
+func TestSignatureSimilarity(t *testing.T) {
+	query := function("GetUserByID", "func (s *Store) GetUserByID(ctx context.Context, id int64) (*User, error)")
+	counterpart := function("get_user_by_id", "def get_user_by_id(self, user_id: int) -> User")
+	unrelated := function("flush", "def flush(self, force, timeout, retries)")
+
+	similar := SignatureSimilarity(query, counterpart)
+	different := SignatureSimilarity(query, unrelated)
+	if similar <= different {
+		t.Errorf("counterpart scored %f, not above unrelated function %f", similar, different)
+	}
+	if similar < 0 || similar > 1 || different < 0 || different > 1 {
+		t.Errorf("scores %f and %f are outside [0, 1]", similar, different)
+	}
+	if same := SignatureSimilarity(query, query); same != 1 {
+		t.Errorf("a function scored %f against itself, want 1", same)
+	}
+}
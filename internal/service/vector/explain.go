@@ -0,0 +1,55 @@
+package vector
+
+import (
+	"regexp"
+	"sort"
+)
+
+var identifierPattern = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*`)
+
+// explainStopwords are keywords and builtins of the supported languages. Sharing them says
+// nothing about why two pieces of code match.
+var explainStopwords = map[string]bool{
+	"func": true, "def": true, "function": true, "class": true, "struct": true, "interface": true,
+	"return": true, "if": true, "else": true, "elif": true, "for": true, "while": true, "do": true,
+	"switch": true, "case": true, "default": true, "break": true, "continue": true, "range": true,
+	"var": true, "let": true, "const": true, "type": true, "package": true, "import": true,
+	"from": true, "as": true, "in": true, "is": true, "not": true, "and": true, "or": true,
+	"new": true, "this": true, "self": true, "public": true, "private": true, "protected": true,
+	"static": true, "final": true, "void": true, "int": true, "string": true, "bool": true,
+	"boolean": true, "error": true, "nil": true, "null": true, "None": true, "true": true,
+	"false": true, "True": true, "False": true, "err": true, "try": true, "catch": true,
+	"except": true, "finally": true, "throw": true, "throws": true, "raise": true, "async": true,
+	"await": true, "export": true, "extends": true, "implements": true, "go": true, "defer": true,
+}
+
+// SharedIdentifiers returns the identifiers that appear in both query and result, sorted,
+// and the fraction of the query's distinct identifiers they cover. Keywords and names
+// shorter than two characters are ignored.
+func SharedIdentifiers(query, result string) ([]string, float64) {
+	queryIdents := identifierSet(query)
+	if len(queryIdents) == 0 {
+		return []string{}, 0
+	}
+	resultIdents := identifierSet(result)
+
+	shared := make([]string, 0)
+	for ident := range queryIdents {
+		if resultIdents[ident] {
+			shared = append(shared, ident)
+		}
+	}
+	sort.Strings(shared)
+	return shared, float64(len(shared)) / float64(len(queryIdents))
+}
+
+func identifierSet(code string) map[string]bool {
+	idents := make(map[string]bool)
+	for _, ident := range identifierPattern.FindAllString(code, -1) {
+		if len(ident) < 2 || explainStopwords[ident] {
+			continue
+		}
+		idents[ident] = true
+	}
+	return idents
+}
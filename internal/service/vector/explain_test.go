@@ -0,0 +1,26 @@
+package vector
+
+import "testing"
+
+func TestSharedIdentifiers(t *testing.T) {
+	query := "func handleRequest(w http.ResponseWriter, r *http.Request) error { return nil }"
+	result := "func serve(w http.ResponseWriter, req *http.Request) { log(req) }"
+
+	shared, overlap := SharedIdentifiers(query, result)
+	want := []string{"Request", "ResponseWriter", "http"}
+	if len(shared) != len(want) {
+		t.Fatalf("expected %v, got %v", want, shared)
+	}
+	for i := range want {
+		if shared[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, shared)
+		}
+	}
+	if overlap != 0.75 {
+		t.Errorf("expected overlap 0.75, got %v", overlap)
+	}
+
+	if shared, overlap := SharedIdentifiers("", result); len(shared) != 0 || overlap != 0 {
+		t.Errorf("empty query should share nothing, got %v %v", shared, overlap)
+	}
+}
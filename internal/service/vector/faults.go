@@ -0,0 +1,120 @@
+package vector
+
+import (
+	"context"
+
+	"bot-go/internal/faults"
+	"bot-go/internal/model"
+)
+
+// faultVectorDatabase injects the faults configured for the qdrant target into the data
+// operations of a VectorDatabase
+type faultVectorDatabase struct {
+	VectorDatabase
+}
+
+// WithFaults injects the faults configured through the admin endpoint into db. Without the
+// faults build tag it returns db unchanged.
+func WithFaults(db VectorDatabase) VectorDatabase {
+	if !faults.Enabled {
+		return db
+	}
+	return &faultVectorDatabase{VectorDatabase: db}
+}
+
+// UpsertChunks may upsert only the leading chunks of the batch before failing
+func (db *faultVectorDatabase) UpsertChunks(ctx context.Context, collectionName string, chunks []*model.CodeChunk) error {
+	applied, injected := faults.InjectBatch(ctx, faults.TargetQdrant, "upsert", len(chunks))
+	if applied > 0 {
+		if err := db.VectorDatabase.UpsertChunks(ctx, collectionName, chunks[:applied]); err != nil {
+			return err
+		}
+	}
+	return injected
+}
+
+func (db *faultVectorDatabase) SearchSimilar(ctx context.Context, collectionName string, queryVector []float32, limit int, filter map[string]interface{}) ([]*model.CodeChunk, []float32, error) {
+	if err := faults.Inject(ctx, faults.TargetQdrant, "search"); err != nil {
+		return nil, nil, err
+	}
+	return db.VectorDatabase.SearchSimilar(ctx, collectionName, queryVector, limit, filter)
+}
+
+func (db *faultVectorDatabase) GetChunkByID(ctx context.Context, collectionName string, chunkID string) (*model.CodeChunk, error) {
+	if err := faults.Inject(ctx, faults.TargetQdrant, "get"); err != nil {
+		return nil, err
+	}
+	return db.VectorDatabase.GetChunkByID(ctx, collectionName, chunkID)
+}
+
+func (db *faultVectorDatabase) GetChunksByFilePath(ctx context.Context, collectionName string, filePath string) ([]*model.CodeChunk, error) {
+	if err := faults.Inject(ctx, faults.TargetQdrant, "get"); err != nil {
+		return nil, err
+	}
+	return db.VectorDatabase.GetChunksByFilePath(ctx, collectionName, filePath)
+}
+
+func (db *faultVectorDatabase) GetChunksByFileID(ctx context.Context, collectionName string, fileID int32) ([]*model.CodeChunk, error) {
+	if err := faults.Inject(ctx, faults.TargetQdrant, "get"); err != nil {
+		return nil, err
+	}
+	return db.VectorDatabase.GetChunksByFileID(ctx, collectionName, fileID)
+}
+
+func (db *faultVectorDatabase) ListChunkFiles(ctx context.Context, collectionName string) ([]ChunkFile, error) {
+	if err := faults.Inject(ctx, faults.TargetQdrant, "get"); err != nil {
+		return nil, err
+	}
+	return db.VectorDatabase.ListChunkFiles(ctx, collectionName)
+}
+
+func (db *faultVectorDatabase) ScrollChunks(ctx context.Context, collectionName string, offset string, limit int) ([]*model.CodeChunk, string, error) {
+	if err := faults.Inject(ctx, faults.TargetQdrant, "get"); err != nil {
+		return nil, "", err
+	}
+	return db.VectorDatabase.ScrollChunks(ctx, collectionName, offset, limit)
+}
+
+func (db *faultVectorDatabase) DeleteChunk(ctx context.Context, collectionName string, chunkID string) error {
+	if err := faults.Inject(ctx, faults.TargetQdrant, "delete"); err != nil {
+		return err
+	}
+	return db.VectorDatabase.DeleteChunk(ctx, collectionName, chunkID)
+}
+
+func (db *faultVectorDatabase) PurgeChunks(ctx context.Context, collectionName string, filter PurgeFilter) (int, error) {
+	if err := faults.Inject(ctx, faults.TargetQdrant, "delete"); err != nil {
+		return 0, err
+	}
+	return db.VectorDatabase.PurgeChunks(ctx, collectionName, filter)
+}
+
+// faultEmbeddingModel injects the faults configured for the embedding target
+type faultEmbeddingModel struct {
+	EmbeddingModel
+}
+
+// EmbeddingWithFaults injects the faults configured through the admin endpoint into model.
+// Without the faults build tag it returns model unchanged.
+func EmbeddingWithFaults(model EmbeddingModel) EmbeddingModel {
+	if !faults.Enabled {
+		return model
+	}
+	return &faultEmbeddingModel{EmbeddingModel: model}
+}
+
+func (m *faultEmbeddingModel) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	if err := faults.Inject(ctx, faults.TargetEmbedding, "embed"); err != nil {
+		return nil, err
+	}
+	return m.EmbeddingModel.GenerateEmbedding(ctx, text)
+}
+
+// GenerateEmbeddings fails the whole batch on an injected partial failure, since callers
+// expect one embedding per text
+func (m *faultEmbeddingModel) GenerateEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
+	if _, err := faults.InjectBatch(ctx, faults.TargetEmbedding, "embed", len(texts)); err != nil {
+		return nil, err
+	}
+	return m.EmbeddingModel.GenerateEmbeddings(ctx, texts)
+}
@@ -0,0 +1,135 @@
+package vector
+
+import (
+	"bot-go/internal/model"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// highlightShingleSize is the number of consecutive tokens that must match for a token to be highlighted
+	highlightShingleSize = 3
+	// highlightLineThreshold is the fraction of a line's tokens that must be matched to mark the line
+	highlightLineThreshold = 0.5
+)
+
+var highlightTokenPattern = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*|[0-9]+(?:\.[0-9]+)?|\S`)
+
+type highlightToken struct {
+	text  string
+	line  int // 0-based line within the code
+	start int
+	end   int
+}
+
+func tokenizeForHighlight(code string) []highlightToken {
+	var tokens []highlightToken
+	for lineIdx, line := range strings.Split(code, "\n") {
+		for _, loc := range highlightTokenPattern.FindAllStringIndex(line, -1) {
+			tokens = append(tokens, highlightToken{
+				text:  line[loc[0]:loc[1]],
+				line:  lineIdx,
+				start: loc[0],
+				end:   loc[1],
+			})
+		}
+	}
+	return tokens
+}
+
+func shingleKey(tokens []highlightToken, i, n int) string {
+	parts := make([]string, n)
+	for k := 0; k < n; k++ {
+		parts[k] = tokens[i+k].text
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// ComputeMatchHighlight finds the regions of resultCode that overlap queryCode.
+// A result token is considered matched when it belongs to a run of
+// highlightShingleSize tokens that also appears in the query, which avoids
+// lighting up every common keyword or punctuation mark. resultStartLine is
+// the file line of the first line of resultCode, used to report file coordinates.
+func ComputeMatchHighlight(queryCode, resultCode string, resultStartLine int) *model.MatchHighlight {
+	queryTokens := tokenizeForHighlight(queryCode)
+	resultTokens := tokenizeForHighlight(resultCode)
+
+	highlight := &model.MatchHighlight{
+		Spans:        make([]model.TokenSpan, 0),
+		MatchedLines: make([]int, 0),
+	}
+	if len(resultTokens) == 0 {
+		return highlight
+	}
+
+	n := highlightShingleSize
+	if len(queryTokens) < n || len(resultTokens) < n {
+		n = 1
+	}
+
+	queryShingles := make(map[string]bool)
+	for i := 0; i+n <= len(queryTokens); i++ {
+		queryShingles[shingleKey(queryTokens, i, n)] = true
+	}
+
+	matched := make([]bool, len(resultTokens))
+	for i := 0; i+n <= len(resultTokens); i++ {
+		if queryShingles[shingleKey(resultTokens, i, n)] {
+			for k := 0; k < n; k++ {
+				matched[i+k] = true
+			}
+		}
+	}
+
+	// Merge matched tokens into per-line spans
+	lines := strings.Split(resultCode, "\n")
+	lineTotal := make(map[int]int)
+	lineMatched := make(map[int]int)
+	matchedCount := 0
+	var current *model.TokenSpan
+	for i, tok := range resultTokens {
+		lineTotal[tok.line]++
+		if !matched[i] {
+			current = nil
+			continue
+		}
+		matchedCount++
+		lineMatched[tok.line]++
+		if current != nil && current.Line == tok.line+resultStartLine {
+			current.EndChar = tok.end
+			current.Text = lines[tok.line][current.StartChar:current.EndChar]
+			continue
+		}
+		highlight.Spans = append(highlight.Spans, model.TokenSpan{
+			Line:      tok.line + resultStartLine,
+			StartChar: tok.start,
+			EndChar:   tok.end,
+			Text:      tok.text,
+		})
+		current = &highlight.Spans[len(highlight.Spans)-1]
+	}
+	highlight.Coverage = float64(matchedCount) / float64(len(resultTokens))
+
+	marked := make(map[int]bool)
+	for line, total := range lineTotal {
+		if float64(lineMatched[line])/float64(total) >= highlightLineThreshold {
+			marked[line] = true
+			highlight.MatchedLines = append(highlight.MatchedLines, line+resultStartLine)
+		}
+	}
+	sort.Ints(highlight.MatchedLines)
+
+	var sb strings.Builder
+	for i, line := range lines {
+		prefix := "  "
+		if marked[i] {
+			prefix = ">>"
+		}
+		fmt.Fprintf(&sb, "%s %5d | %s\n", prefix, i+resultStartLine+1, line)
+	}
+	highlight.Snippet = sb.String()
+
+	return highlight
+}
@@ -1,14 +1,20 @@
 package vector
 
 import (
+	"bot-go/internal/apperrors"
+	"bot-go/internal/logging"
 	"bot-go/internal/model"
 	"bot-go/pkg/lsp/base"
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/qdrant/go-client/qdrant"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // QdrantDatabase implements VectorDatabase interface using Qdrant
@@ -58,10 +64,10 @@ func (q *QdrantDatabase) CreateCollection(ctx context.Context, collectionName st
 		}),
 	})
 	if err != nil {
-		return fmt.Errorf("failed to create collection: %w", err)
+		return fmt.Errorf("failed to create collection: %w", classifyQdrantError(err))
 	}
 
-	q.logger.Info("Created Qdrant collection", zap.String("collection", collectionName), zap.Int("dim", vectorDim))
+	q.log(ctx).Info("Created Qdrant collection", zap.String("collection", collectionName), zap.Int("dim", vectorDim))
 	return nil
 }
 
@@ -69,7 +75,7 @@ func (q *QdrantDatabase) CreateCollection(ctx context.Context, collectionName st
 func (q *QdrantDatabase) DeleteCollection(ctx context.Context, collectionName string) error {
 	err := q.client.DeleteCollection(ctx, collectionName)
 	if err != nil {
-		return fmt.Errorf("failed to delete collection: %w", err)
+		return fmt.Errorf("failed to delete collection: %w", classifyQdrantError(err))
 	}
 	return nil
 }
@@ -78,7 +84,7 @@ func (q *QdrantDatabase) DeleteCollection(ctx context.Context, collectionName st
 func (q *QdrantDatabase) CollectionExists(ctx context.Context, collectionName string) (bool, error) {
 	exists, err := q.client.CollectionExists(ctx, collectionName)
 	if err != nil {
-		return false, fmt.Errorf("failed to check collection existence: %w", err)
+		return false, fmt.Errorf("failed to check collection existence: %w", classifyQdrantError(err))
 	}
 	return exists, nil
 }
@@ -90,47 +96,55 @@ func (q *QdrantDatabase) UpsertChunks(ctx context.Context, collectionName string
 	}
 
 	points := make([]*qdrant.PointStruct, 0, len(chunks))
+	indexedAt := time.Now().Unix()
 
 	for _, chunk := range chunks {
 		if len(chunk.Embedding) == 0 {
-			q.logger.Warn("Skipping chunk without embedding", zap.String("id", chunk.ID))
+			q.log(ctx).Warn("Skipping chunk without embedding", zap.String("id", chunk.ID))
 			continue
 		}
 
 		// Convert CodeChunk to Qdrant point
 		// Note: content is excluded to save storage space - use file_path and line numbers to retrieve content
+		payload := map[string]any{
+			"file_id":     int64(chunk.FileID),
+			"commit_sha":  chunk.CommitSHA,
+			"chunk_type":  string(chunk.ChunkType),
+			"level":       chunk.Level,
+			"parent_id":   chunk.ParentID,
+			"language":    chunk.Language,
+			"file_path":   chunk.FilePath,
+			"start_line":  chunk.StartLine,
+			"end_line":    chunk.EndLine,
+			"range":       rangeToMap(chunk.Range),
+			"name":        chunk.Name,
+			"signature":   chunk.Signature,
+			"docstring":   chunk.Docstring,
+			"module_name": chunk.ModuleName,
+			"class_name":  chunk.ClassName,
+			"metadata":    chunk.Metadata,
+			"indexed_at":  indexedAt,
+		}
+		if chunk.ExpiresAt > 0 {
+			payload["expires_at"] = chunk.ExpiresAt
+		}
 		point := &qdrant.PointStruct{
 			Id: qdrant.NewIDUUID(chunk.ID),
 			Vectors: qdrant.NewVectorsMap(map[string]*qdrant.Vector{
 				"": qdrant.NewVector(chunk.Embedding...),
 			}),
-			Payload: qdrant.NewValueMap(map[string]any{
-				"chunk_type":  string(chunk.ChunkType),
-				"level":       chunk.Level,
-				"parent_id":   chunk.ParentID,
-				"language":    chunk.Language,
-				"file_path":   chunk.FilePath,
-				"start_line":  chunk.StartLine,
-				"end_line":    chunk.EndLine,
-				"range":       rangeToMap(chunk.Range),
-				"name":        chunk.Name,
-				"signature":   chunk.Signature,
-				"docstring":   chunk.Docstring,
-				"module_name": chunk.ModuleName,
-				"class_name":  chunk.ClassName,
-				"metadata":    chunk.Metadata,
-			}),
+			Payload: qdrant.NewValueMap(payload),
 		}
 		points = append(points, point)
 	}
 
 	if len(points) == 0 {
-		q.logger.Warn("No points to upsert after filtering", zap.String("collection", collectionName))
+		q.log(ctx).Warn("No points to upsert after filtering", zap.String("collection", collectionName))
 		return nil
 	}
 
 	// Log details before upsert
-	q.logger.Debug("Attempting upsert",
+	q.log(ctx).Debug("Attempting upsert",
 		zap.String("collection", collectionName),
 		zap.Int("points_count", len(points)))
 
@@ -139,13 +153,13 @@ func (q *QdrantDatabase) UpsertChunks(ctx context.Context, collectionName string
 		Points:         points,
 	})
 	if err != nil {
-		q.logger.Error("Upsert failed",
+		q.log(ctx).Error("Upsert failed",
 			zap.String("collection", collectionName),
 			zap.Error(err))
-		return fmt.Errorf("failed to upsert chunks: %w", err)
+		return fmt.Errorf("failed to upsert chunks: %w", classifyQdrantError(err))
 	}
 
-	q.logger.Info("Upserted chunks to Qdrant",
+	q.log(ctx).Info("Upserted chunks to Qdrant",
 		zap.String("collection", collectionName),
 		zap.Int("count", len(points)))
 	return nil
@@ -180,7 +194,7 @@ func (q *QdrantDatabase) SearchSimilar(ctx context.Context, collectionName strin
 		WithPayload:    qdrant.NewWithPayload(true),
 	})
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to search: %w", err)
+		return nil, nil, fmt.Errorf("failed to search: %w", classifyQdrantError(err))
 	}
 
 	chunks := make([]*model.CodeChunk, 0, len(searchResult))
@@ -203,13 +217,14 @@ func (q *QdrantDatabase) GetChunkByID(ctx context.Context, collectionName string
 		CollectionName: collectionName,
 		Ids:            []*qdrant.PointId{qdrant.NewIDUUID(chunkID)},
 		WithPayload:    qdrant.NewWithPayload(true),
+		WithVectors:    qdrant.NewWithVectors(true),
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get chunk: %w", err)
+		return nil, fmt.Errorf("failed to get chunk: %w", classifyQdrantError(err))
 	}
 
 	if len(points) == 0 {
-		return nil, fmt.Errorf("chunk not found: %s", chunkID)
+		return nil, apperrors.NotFound("chunk", chunkID)
 	}
 
 	return retrievedPointToCodeChunk(points[0]), nil
@@ -228,7 +243,7 @@ func (q *QdrantDatabase) DeleteChunk(ctx context.Context, collectionName string,
 		},
 	})
 	if err != nil {
-		return fmt.Errorf("failed to delete chunk: %w", err)
+		return fmt.Errorf("failed to delete chunk: %w", classifyQdrantError(err))
 	}
 	return nil
 }
@@ -249,6 +264,21 @@ func (q *QdrantDatabase) GetChunksByFilePath(ctx context.Context, collectionName
 		},
 	}
 
+	return q.scrollFileChunks(ctx, collectionName, filter)
+}
+
+// GetChunksByFileID retrieves all chunks for a specific FileID
+func (q *QdrantDatabase) GetChunksByFileID(ctx context.Context, collectionName string, fileID int32) ([]*model.CodeChunk, error) {
+	filter := &qdrant.Filter{
+		Must: []*qdrant.Condition{
+			qdrant.NewMatchInt("file_id", int64(fileID)),
+		},
+	}
+	return q.scrollFileChunks(ctx, collectionName, filter)
+}
+
+// scrollFileChunks returns every chunk matching a per-file filter
+func (q *QdrantDatabase) scrollFileChunks(ctx context.Context, collectionName string, filter *qdrant.Filter) ([]*model.CodeChunk, error) {
 	// Scroll through all points matching the filter
 	// Using a large limit to get all chunks for a file (unlikely to have >10000 chunks in one file)
 	// Note: We DO need vectors here because we reuse embeddings from existing chunks
@@ -260,7 +290,7 @@ func (q *QdrantDatabase) GetChunksByFilePath(ctx context.Context, collectionName
 		WithVectors:    qdrant.NewWithVectors(true), // Required: we reuse these embeddings
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to scroll points: %w", err)
+		return nil, fmt.Errorf("failed to scroll points: %w", classifyQdrantError(err))
 	}
 
 	chunks := make([]*model.CodeChunk, 0, len(scrollResult))
@@ -274,6 +304,173 @@ func (q *QdrantDatabase) GetChunksByFilePath(ctx context.Context, collectionName
 	return chunks, nil
 }
 
+// purgePageSize is the number of points scrolled and deleted per round trip when purging
+const purgePageSize = 1000
+
+// PurgeChunks deletes every chunk matching the filter. Language, chunk type, file ID and timestamp
+// filters are evaluated by Qdrant; file_path has no prefix index, so the prefix is checked
+// on the scrolled payloads before deleting by ID.
+func (q *QdrantDatabase) PurgeChunks(ctx context.Context, collectionName string, filter PurgeFilter) (int, error) {
+	if filter.IsEmpty() {
+		return 0, fmt.Errorf("%w: purge requires at least one filter", apperrors.ErrInvalidArgument)
+	}
+
+	qdrantFilter := purgeConditions(filter)
+	withPayload := qdrant.NewWithPayload(false)
+	if filter.PathPrefix != "" {
+		withPayload = qdrant.NewWithPayloadInclude("file_path")
+	}
+
+	// Collect IDs first: deleting while scrolling would shift the pages
+	var ids []*qdrant.PointId
+	var offset *qdrant.PointId
+	for {
+		points, next, err := q.client.ScrollAndOffset(ctx, &qdrant.ScrollPoints{
+			CollectionName: collectionName,
+			Filter:         qdrantFilter,
+			Offset:         offset,
+			Limit:          qdrant.PtrOf(uint32(purgePageSize)),
+			WithPayload:    withPayload,
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to scroll points: %w", classifyQdrantError(err))
+		}
+		for _, point := range points {
+			if filter.PathPrefix != "" && !strings.HasPrefix(getStringValue(point.GetPayload(), "file_path"), filter.PathPrefix) {
+				continue
+			}
+			ids = append(ids, point.GetId())
+		}
+		if next == nil || len(points) == 0 {
+			break
+		}
+		offset = next
+	}
+
+	for start := 0; start < len(ids); start += purgePageSize {
+		end := min(start+purgePageSize, len(ids))
+		_, err := q.client.Delete(ctx, &qdrant.DeletePoints{
+			CollectionName: collectionName,
+			Points:         qdrant.NewPointsSelectorIDs(ids[start:end]),
+		})
+		if err != nil {
+			return start, fmt.Errorf("failed to delete chunks: %w", classifyQdrantError(err))
+		}
+	}
+
+	q.log(ctx).Info("Purged chunks from Qdrant",
+		zap.String("collection", collectionName),
+		zap.Int("count", len(ids)))
+	return len(ids), nil
+}
+
+// purgeConditions builds the server-side part of a purge filter
+func purgeConditions(filter PurgeFilter) *qdrant.Filter {
+	var must []*qdrant.Condition
+	if filter.Language != "" {
+		must = append(must, qdrant.NewMatchKeyword("language", filter.Language))
+	}
+	if len(filter.ChunkTypes) > 0 {
+		types := make([]string, len(filter.ChunkTypes))
+		for i, t := range filter.ChunkTypes {
+			types[i] = string(t)
+		}
+		must = append(must, qdrant.NewMatchKeywords("chunk_type", types...))
+	}
+	if !filter.IndexedBefore.IsZero() {
+		must = append(must, qdrant.NewRange("indexed_at", &qdrant.Range{Lt: qdrant.PtrOf(float64(filter.IndexedBefore.Unix()))}))
+	}
+	if !filter.ExpiredBefore.IsZero() {
+		must = append(must, qdrant.NewRange("expires_at", &qdrant.Range{Lt: qdrant.PtrOf(float64(filter.ExpiredBefore.Unix()))}))
+	}
+	if len(filter.FileIDs) > 0 {
+		ids := make([]int64, len(filter.FileIDs))
+		for i, id := range filter.FileIDs {
+			ids[i] = int64(id)
+		}
+		must = append(must, qdrant.NewMatchInts("file_id", ids...))
+	}
+	if len(must) == 0 {
+		return nil
+	}
+	return &qdrant.Filter{Must: must}
+}
+
+// ListChunkFiles scrolls the payloads of the whole collection and counts the chunks per file
+func (q *QdrantDatabase) ListChunkFiles(ctx context.Context, collectionName string) ([]ChunkFile, error) {
+	type fileKey struct {
+		id   int32
+		path string
+	}
+	counts := make(map[fileKey]int)
+	var order []fileKey
+	var offset *qdrant.PointId
+	for {
+		points, next, err := q.client.ScrollAndOffset(ctx, &qdrant.ScrollPoints{
+			CollectionName: collectionName,
+			Offset:         offset,
+			Limit:          qdrant.PtrOf(uint32(purgePageSize)),
+			WithPayload:    qdrant.NewWithPayloadInclude("file_id", "file_path"),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scroll points: %w", classifyQdrantError(err))
+		}
+		for _, point := range points {
+			key := fileKey{int32(getIntValue(point.GetPayload(), "file_id")), getStringValue(point.GetPayload(), "file_path")}
+			if counts[key] == 0 {
+				order = append(order, key)
+			}
+			counts[key]++
+		}
+		if next == nil || len(points) == 0 {
+			break
+		}
+		offset = next
+	}
+
+	files := make([]ChunkFile, len(order))
+	for i, key := range order {
+		files[i] = ChunkFile{FileID: key.id, FilePath: key.path, Chunks: counts[key]}
+	}
+	return files, nil
+}
+
+// ScrollChunks returns one page of the chunks of the collection, in point ID order
+func (q *QdrantDatabase) ScrollChunks(ctx context.Context, collectionName string, offset string, limit int) ([]*model.CodeChunk, string, error) {
+	request := &qdrant.ScrollPoints{
+		CollectionName: collectionName,
+		Limit:          qdrant.PtrOf(uint32(limit)),
+		WithPayload:    qdrant.NewWithPayload(true),
+		WithVectors:    qdrant.NewWithVectors(true),
+	}
+	if offset != "" {
+		request.Offset = qdrant.NewIDUUID(offset)
+	}
+	points, next, err := q.client.ScrollAndOffset(ctx, request)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to scroll points: %w", classifyQdrantError(err))
+	}
+	chunks := make([]*model.CodeChunk, 0, len(points))
+	for _, point := range points {
+		if chunk := retrievedPointToCodeChunk(point); chunk != nil {
+			chunks = append(chunks, chunk)
+		}
+	}
+	if next == nil || len(points) == 0 {
+		return chunks, "", nil
+	}
+	return chunks, next.GetUuid(), nil
+}
+
+// ListCollections returns the names of all collections
+func (q *QdrantDatabase) ListCollections(ctx context.Context) ([]string, error) {
+	names, err := q.client.ListCollections(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collections: %w", classifyQdrantError(err))
+	}
+	return names, nil
+}
+
 // Close closes the database connection
 func (q *QdrantDatabase) Close() error {
 	if q.client != nil {
@@ -286,7 +483,7 @@ func (q *QdrantDatabase) Close() error {
 func (q *QdrantDatabase) Health(ctx context.Context) error {
 	_, err := q.client.HealthCheck(ctx)
 	if err != nil {
-		return fmt.Errorf("health check failed: %w", err)
+		return fmt.Errorf("health check failed: %w", classifyQdrantError(err))
 	}
 	return nil
 }
@@ -329,7 +526,31 @@ func pointToCodeChunk(point *qdrant.ScoredPoint) *model.CodeChunk {
 
 func retrievedPointToCodeChunk(point *qdrant.RetrievedPoint) *model.CodeChunk {
 	payload := point.GetPayload()
-	return payloadToCodeChunk(point.Id.GetUuid(), payload)
+	chunk := payloadToCodeChunk(point.Id.GetUuid(), payload)
+	if chunk != nil {
+		chunk.Embedding = vectorsOutputToEmbedding(point.GetVectors())
+	}
+	return chunk
+}
+
+// vectorsOutputToEmbedding extracts the default dense vector if it was requested
+func vectorsOutputToEmbedding(vectors *qdrant.VectorsOutput) []float32 {
+	if vectors == nil {
+		return nil
+	}
+	vec := vectors.GetVector()
+	if vec == nil {
+		if named := vectors.GetVectors(); named != nil {
+			vec = named.GetVectors()[""]
+		}
+	}
+	if vec == nil {
+		return nil
+	}
+	if dense := vec.GetDense(); dense != nil {
+		return dense.GetData()
+	}
+	return vec.GetData()
 }
 
 func payloadToCodeChunk(id string, payload map[string]*qdrant.Value) *model.CodeChunk {
@@ -345,6 +566,8 @@ func payloadToCodeChunk(id string, payload map[string]*qdrant.Value) *model.Code
 
 	chunk := &model.CodeChunk{
 		ID:         chunkID,
+		FileID:     int32(getIntValue(payload, "file_id")),
+		CommitSHA:  getStringValue(payload, "commit_sha"),
 		ChunkType:  model.ChunkType(getStringValue(payload, "chunk_type")),
 		Level:      int(getIntValue(payload, "level")),
 		ParentID:   getStringValue(payload, "parent_id"),
@@ -358,6 +581,8 @@ func payloadToCodeChunk(id string, payload map[string]*qdrant.Value) *model.Code
 		Docstring:  getStringValue(payload, "docstring"),
 		ModuleName: getStringValue(payload, "module_name"),
 		ClassName:  getStringValue(payload, "class_name"),
+		IndexedAt:  getIntValue(payload, "indexed_at"),
+		ExpiresAt:  getIntValue(payload, "expires_at"),
 	}
 
 	// Parse range
@@ -414,3 +639,17 @@ func structToMap(s *qdrant.Struct) map[string]interface{} {
 	}
 	return result
 }
+
+// log returns the logger with the request ID carried by ctx attached
+func (q *QdrantDatabase) log(ctx context.Context) *zap.Logger {
+	return logging.FromContext(ctx, q.logger)
+}
+
+// classifyQdrantError marks failures to reach Qdrant as apperrors.ErrBackendUnavailable
+func classifyQdrantError(err error) error {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return apperrors.Unavailable("qdrant", err)
+	}
+	return err
+}
@@ -0,0 +1,238 @@
+package vector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"bot-go/internal/model"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultReductionSample = 20000 // vectors PCA is fitted on
+	reduceScrollPageSize   = 500
+	reducingSuffix         = "_reducing" // collection holding the reduced vectors during the job
+)
+
+// ReduceOptions controls the reduction of a collection
+type ReduceOptions struct {
+	Method     string // ReductionPCA or ReductionTruncate
+	Dimension  int    // dimension of the reduced vectors
+	SampleSize int    // vectors PCA is fitted on, sampled uniformly (default 20,000)
+}
+
+// reducingVectorDatabase applies the recorded reductions to the vectors written to and
+// searched in reduced collections
+type reducingVectorDatabase struct {
+	VectorDatabase
+	reductions map[string]*Reduction
+}
+
+// WithReductions applies the reductions recorded in dir (see ReduceCollection) to db: reduced
+// collections are recreated with the reduced dimension, and full-size vectors upserted or
+// searched in them are reduced first. Without recorded reductions it returns db unchanged.
+func WithReductions(db VectorDatabase, dir string) (VectorDatabase, error) {
+	reductions, err := LoadReductions(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(reductions) == 0 {
+		return db, nil
+	}
+	return &reducingVectorDatabase{VectorDatabase: db, reductions: reductions}, nil
+}
+
+// CreateCollection creates a reduced collection with the reduced dimension
+func (db *reducingVectorDatabase) CreateCollection(ctx context.Context, collectionName string, vectorDim int, distance DistanceMetric) error {
+	if r := db.reductions[collectionName]; r != nil && vectorDim == r.SourceDim {
+		vectorDim = r.Dimension
+	}
+	return db.VectorDatabase.CreateCollection(ctx, collectionName, vectorDim, distance)
+}
+
+// UpsertChunks reduces the embeddings of chunks written to a reduced collection. The chunks
+// passed in keep their full-size embeddings.
+func (db *reducingVectorDatabase) UpsertChunks(ctx context.Context, collectionName string, chunks []*model.CodeChunk) error {
+	r := db.reductions[collectionName]
+	if r == nil {
+		return db.VectorDatabase.UpsertChunks(ctx, collectionName, chunks)
+	}
+	reduced := make([]*model.CodeChunk, len(chunks))
+	for i, chunk := range chunks {
+		copied := *chunk
+		copied.Embedding = r.Apply(chunk.Embedding)
+		reduced[i] = &copied
+	}
+	return db.VectorDatabase.UpsertChunks(ctx, collectionName, reduced)
+}
+
+// SearchSimilar reduces the query vector of a search in a reduced collection
+func (db *reducingVectorDatabase) SearchSimilar(ctx context.Context, collectionName string, queryVector []float32, limit int, filter map[string]interface{}) ([]*model.CodeChunk, []float32, error) {
+	if r := db.reductions[collectionName]; r != nil {
+		queryVector = r.Apply(queryVector)
+	}
+	return db.VectorDatabase.SearchSimilar(ctx, collectionName, queryVector, limit, filter)
+}
+
+// ReduceCollection fits a reduction to the vectors of a collection, rebuilds the collection
+// with the reduced vectors and records the reduction in dir so that servers opened with
+// WithReductions keep applying it. The reduced vectors are first written to a temporary
+// collection, so the original is only replaced once they all exist. Payloads are kept, but
+// their indexed_at is reset. A collection can be reduced once; to change the dimension,
+// re-embed it into a new collection.
+func ReduceCollection(ctx context.Context, db VectorDatabase, dir, collectionName string, opts ReduceOptions, logger *zap.Logger) (*Reduction, error) {
+	if opts.Method != ReductionPCA && opts.Method != ReductionTruncate {
+		return nil, fmt.Errorf("unknown reduction method %q (valid: %s, %s)", opts.Method, ReductionPCA, ReductionTruncate)
+	}
+	reductions, err := LoadReductions(dir)
+	if err != nil {
+		return nil, err
+	}
+	if r := reductions[collectionName]; r != nil {
+		return nil, fmt.Errorf("collection %s was already reduced to %d dimensions on %s", collectionName, r.Dimension, r.CreatedAt.Format(time.RFC3339))
+	}
+	if exists, err := db.CollectionExists(ctx, collectionName); err != nil {
+		return nil, fmt.Errorf("failed to check collection existence: %w", err)
+	} else if !exists {
+		return nil, fmt.Errorf("collection %s does not exist", collectionName)
+	}
+	staging := collectionName + reducingSuffix
+	if exists, err := db.CollectionExists(ctx, staging); err != nil {
+		return nil, fmt.Errorf("failed to check collection existence: %w", err)
+	} else if exists {
+		return nil, fmt.Errorf("collection %s is left from an interrupted reduction; if %s is intact, delete it and run again", staging, collectionName)
+	}
+
+	r, err := fitReduction(ctx, db, collectionName, opts)
+	if err != nil {
+		return nil, err
+	}
+	r.Collection = collectionName
+	logger.Info("Fitted embedding reduction",
+		zap.String("collection", collectionName),
+		zap.String("method", r.Method),
+		zap.Int("source_dim", r.SourceDim),
+		zap.Int("dimension", r.Dimension),
+		zap.Int("samples", r.Samples),
+		zap.Float64("explained_variance", r.ExplainedVariance))
+
+	if err := db.CreateCollection(ctx, staging, r.Dimension, DistanceMetricCosine); err != nil {
+		return nil, fmt.Errorf("failed to create collection %s: %w", staging, err)
+	}
+	copied, err := copyChunks(ctx, db, collectionName, staging, r.Apply)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write reduced vectors to %s: %w", staging, err)
+	}
+
+	// From here on the reduced vectors only exist in the staging collection until the copy
+	// back completes
+	if err := db.DeleteCollection(ctx, collectionName); err != nil {
+		return nil, fmt.Errorf("failed to delete collection %s: %w", collectionName, err)
+	}
+	if err := db.CreateCollection(ctx, collectionName, r.Dimension, DistanceMetricCosine); err != nil {
+		return nil, fmt.Errorf("failed to recreate collection %s (the reduced vectors are in %s): %w", collectionName, staging, err)
+	}
+	if _, err := copyChunks(ctx, db, staging, collectionName, nil); err != nil {
+		return nil, fmt.Errorf("failed to copy reduced vectors back to %s (they are in %s): %w", collectionName, staging, err)
+	}
+	if err := SaveReduction(dir, r); err != nil {
+		return nil, err
+	}
+	if err := db.DeleteCollection(ctx, staging); err != nil {
+		logger.Warn("Failed to delete staging collection", zap.String("collection", staging), zap.Error(err))
+	}
+
+	logger.Info("Reduced collection",
+		zap.String("collection", collectionName),
+		zap.Int("vectors", copied),
+		zap.Int("dimension", r.Dimension))
+	return r, nil
+}
+
+// fitReduction fits the reduction of opts to the vectors of a collection. PCA is fitted on a
+// uniform sample; truncation only needs the dimension of the first vector.
+func fitReduction(ctx context.Context, db VectorDatabase, collectionName string, opts ReduceOptions) (*Reduction, error) {
+	sampleSize := opts.SampleSize
+	if sampleSize <= 0 {
+		sampleSize = defaultReductionSample
+	}
+	rng := rand.New(rand.NewSource(1))
+	var sample [][]float32
+	total := 0
+	err := scrollChunks(ctx, db, collectionName, func(chunks []*model.CodeChunk) error {
+		for _, chunk := range chunks {
+			if len(chunk.Embedding) == 0 {
+				continue
+			}
+			total++
+			// Reservoir sampling keeps each vector with the same probability
+			if len(sample) < sampleSize {
+				sample = append(sample, chunk.Embedding)
+			} else if i := rng.Intn(total); i < sampleSize {
+				sample[i] = chunk.Embedding
+			}
+			if opts.Method == ReductionTruncate {
+				return errStopScroll
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStopScroll) {
+		return nil, err
+	}
+	if len(sample) == 0 {
+		return nil, fmt.Errorf("collection %s has no vectors", collectionName)
+	}
+
+	if opts.Method == ReductionTruncate {
+		return Truncation(len(sample[0]), opts.Dimension)
+	}
+	return FitPCA(sample, opts.Dimension)
+}
+
+// errStopScroll ends scrollChunks early without an error
+var errStopScroll = errors.New("stop scrolling")
+
+// scrollChunks passes every page of the chunks of a collection to visit
+func scrollChunks(ctx context.Context, db VectorDatabase, collectionName string, visit func([]*model.CodeChunk) error) error {
+	offset := ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		chunks, next, err := db.ScrollChunks(ctx, collectionName, offset, reduceScrollPageSize)
+		if err != nil {
+			return err
+		}
+		if err := visit(chunks); err != nil {
+			return err
+		}
+		if next == "" {
+			return nil
+		}
+		offset = next
+	}
+}
+
+// copyChunks upserts the chunks of one collection into another, transforming their
+// embeddings with transform unless it is nil, and returns how many were copied
+func copyChunks(ctx context.Context, db VectorDatabase, from, to string, transform func([]float32) []float32) (int, error) {
+	copied := 0
+	err := scrollChunks(ctx, db, from, func(chunks []*model.CodeChunk) error {
+		if transform != nil {
+			for _, chunk := range chunks {
+				chunk.Embedding = transform(chunk.Embedding)
+			}
+		}
+		if err := db.UpsertChunks(ctx, to, chunks); err != nil {
+			return err
+		}
+		copied += len(chunks)
+		return nil
+	})
+	return copied, err
+}
@@ -0,0 +1,400 @@
+package vector
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Reduction methods
+const (
+	ReductionPCA      = "pca"      // project on the principal components of a sample of the vectors
+	ReductionTruncate = "truncate" // keep the leading dimensions (Matryoshka-trained models)
+)
+
+// Reduction is the transform applied to the vectors of a collection to store them with fewer
+// dimensions. Vectors written to and searched in the collection afterwards go through the
+// same transform, so the embedding model keeps producing full-size vectors.
+type Reduction struct {
+	Collection string    `json:"collection"`
+	Method     string    `json:"method"`
+	SourceDim  int       `json:"source_dim"`
+	Dimension  int       `json:"dimension"`
+	CreatedAt  time.Time `json:"created_at"`
+
+	// PCA only: the sample mean, the components (Dimension rows of SourceDim values, by
+	// decreasing variance), how many vectors they were fitted on and the share of the
+	// sample's variance they keep
+	Mean              []float32   `json:"mean,omitempty"`
+	Components        [][]float32 `json:"components,omitempty"`
+	Samples           int         `json:"samples,omitempty"`
+	ExplainedVariance float64     `json:"explained_variance,omitempty"`
+}
+
+// Truncation returns the reduction that keeps the first dim of sourceDim dimensions
+func Truncation(sourceDim, dim int) (*Reduction, error) {
+	if dim <= 0 || dim >= sourceDim {
+		return nil, fmt.Errorf("reduced dimension must be between 1 and %d, got %d", sourceDim-1, dim)
+	}
+	return &Reduction{Method: ReductionTruncate, SourceDim: sourceDim, Dimension: dim, CreatedAt: time.Now().UTC()}, nil
+}
+
+// FitPCA fits the projection of vectors on their dim principal components, the leading
+// eigenvectors of the sample covariance
+func FitPCA(vectors [][]float32, dim int) (*Reduction, error) {
+	if len(vectors) < 2 {
+		return nil, fmt.Errorf("PCA needs at least 2 vectors, got %d", len(vectors))
+	}
+	d := len(vectors[0])
+	if dim <= 0 || dim >= d {
+		return nil, fmt.Errorf("reduced dimension must be between 1 and %d, got %d", d-1, dim)
+	}
+
+	mean := make([]float64, d)
+	for _, v := range vectors {
+		if len(v) != d {
+			return nil, fmt.Errorf("vectors have different dimensions (%d and %d)", d, len(v))
+		}
+		for i, x := range v {
+			mean[i] += float64(x)
+		}
+	}
+	for i := range mean {
+		mean[i] /= float64(len(vectors))
+	}
+
+	// Upper triangle of the covariance, mirrored afterwards
+	cov := make([][]float64, d)
+	for i := range cov {
+		cov[i] = make([]float64, d)
+	}
+	centered := make([]float64, d)
+	for _, v := range vectors {
+		for i, x := range v {
+			centered[i] = float64(x) - mean[i]
+		}
+		for i, ci := range centered {
+			row := cov[i]
+			for j := i; j < d; j++ {
+				row[j] += ci * centered[j]
+			}
+		}
+	}
+	trace := 0.0
+	for i := 0; i < d; i++ {
+		for j := i; j < d; j++ {
+			cov[i][j] /= float64(len(vectors) - 1)
+			cov[j][i] = cov[i][j]
+		}
+		trace += cov[i][i]
+	}
+
+	values, vectorsByColumn := symmetricEigen(cov)
+	order := make([]int, d)
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return values[order[a]] > values[order[b]] })
+
+	result := &Reduction{
+		Method:     ReductionPCA,
+		SourceDim:  d,
+		Dimension:  dim,
+		CreatedAt:  time.Now().UTC(),
+		Mean:       toFloat32(mean),
+		Components: make([][]float32, dim),
+		Samples:    len(vectors),
+	}
+	kept := 0.0
+	for c, column := range order[:dim] {
+		component := make([]float32, d)
+		for k := range component {
+			component[k] = float32(vectorsByColumn[k][column])
+		}
+		result.Components[c] = component
+		kept += values[column]
+	}
+	if trace > 0 {
+		result.ExplainedVariance = kept / trace
+	}
+	return result, nil
+}
+
+// symmetricEigen returns the eigenvalues of the symmetric matrix a and its eigenvectors as
+// the columns of the second result, by Householder reduction to tridiagonal form and the
+// QL algorithm with implicit shifts (tred2 and tql2 of EISPACK). a is overwritten.
+func symmetricEigen(a [][]float64) ([]float64, [][]float64) {
+	n := len(a)
+	v := a
+	d := make([]float64, n)
+	e := make([]float64, n)
+
+	// Householder reduction to tridiagonal form
+	copy(d, v[n-1])
+	for i := n - 1; i > 0; i-- {
+		scale, h := 0.0, 0.0
+		for k := 0; k < i; k++ {
+			scale += math.Abs(d[k])
+		}
+		if scale == 0 {
+			e[i] = d[i-1]
+			for j := 0; j < i; j++ {
+				d[j] = v[i-1][j]
+				v[i][j] = 0
+				v[j][i] = 0
+			}
+		} else {
+			for k := 0; k < i; k++ {
+				d[k] /= scale
+				h += d[k] * d[k]
+			}
+			f := d[i-1]
+			g := math.Sqrt(h)
+			if f > 0 {
+				g = -g
+			}
+			e[i] = scale * g
+			h -= f * g
+			d[i-1] = f - g
+			for j := 0; j < i; j++ {
+				e[j] = 0
+			}
+			for j := 0; j < i; j++ {
+				f = d[j]
+				v[j][i] = f
+				g = e[j] + v[j][j]*f
+				for k := j + 1; k <= i-1; k++ {
+					g += v[k][j] * d[k]
+					e[k] += v[k][j] * f
+				}
+				e[j] = g
+			}
+			f = 0
+			for j := 0; j < i; j++ {
+				e[j] /= h
+				f += e[j] * d[j]
+			}
+			hh := f / (h + h)
+			for j := 0; j < i; j++ {
+				e[j] -= hh * d[j]
+			}
+			for j := 0; j < i; j++ {
+				f, g = d[j], e[j]
+				for k := j; k <= i-1; k++ {
+					v[k][j] -= f*e[k] + g*d[k]
+				}
+				d[j] = v[i-1][j]
+				v[i][j] = 0
+			}
+		}
+		d[i] = h
+	}
+	for i := 0; i < n-1; i++ {
+		v[n-1][i] = v[i][i]
+		v[i][i] = 1
+		h := d[i+1]
+		if h != 0 {
+			for k := 0; k <= i; k++ {
+				d[k] = v[k][i+1] / h
+			}
+			for j := 0; j <= i; j++ {
+				g := 0.0
+				for k := 0; k <= i; k++ {
+					g += v[k][i+1] * v[k][j]
+				}
+				for k := 0; k <= i; k++ {
+					v[k][j] -= g * d[k]
+				}
+			}
+		}
+		for k := 0; k <= i; k++ {
+			v[k][i+1] = 0
+		}
+	}
+	for j := 0; j < n; j++ {
+		d[j] = v[n-1][j]
+		v[n-1][j] = 0
+	}
+	v[n-1][n-1] = 1
+	e[0] = 0
+
+	// QL iterations on the tridiagonal matrix
+	for i := 1; i < n; i++ {
+		e[i-1] = e[i]
+	}
+	e[n-1] = 0
+	f, tst1 := 0.0, 0.0
+	const eps = 0x1p-52
+	for l := 0; l < n; l++ {
+		tst1 = math.Max(tst1, math.Abs(d[l])+math.Abs(e[l]))
+		m := l
+		for m < n-1 && math.Abs(e[m]) > eps*tst1 {
+			m++
+		}
+		if m > l {
+			for {
+				g := d[l]
+				p := (d[l+1] - g) / (2 * e[l])
+				r := math.Hypot(p, 1)
+				if p < 0 {
+					r = -r
+				}
+				d[l] = e[l] / (p + r)
+				d[l+1] = e[l] * (p + r)
+				dl1 := d[l+1]
+				h := g - d[l]
+				for i := l + 2; i < n; i++ {
+					d[i] -= h
+				}
+				f += h
+
+				p = d[m]
+				c, c2, c3 := 1.0, 1.0, 1.0
+				el1 := e[l+1]
+				s, s2 := 0.0, 0.0
+				for i := m - 1; i >= l; i-- {
+					c3, c2, s2 = c2, c, s
+					g = c * e[i]
+					h = c * p
+					r = math.Hypot(p, e[i])
+					e[i+1] = s * r
+					s = e[i] / r
+					c = p / r
+					p = c*d[i] - s*g
+					d[i+1] = h + s*(c*g+s*d[i])
+					for k := 0; k < n; k++ {
+						h = v[k][i+1]
+						v[k][i+1] = s*v[k][i] + c*h
+						v[k][i] = c*v[k][i] - s*h
+					}
+				}
+				p = -s * s2 * c3 * el1 * e[l] / dl1
+				e[l] = s * p
+				d[l] = c * p
+				if math.Abs(e[l]) <= eps*tst1 {
+					break
+				}
+			}
+		}
+		d[l] += f
+		e[l] = 0
+	}
+	return d, v
+}
+
+func dot(a, b []float64) float64 {
+	sum := 0.0
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+func toFloat32(v []float64) []float32 {
+	out := make([]float32, len(v))
+	for i, x := range v {
+		out[i] = float32(x)
+	}
+	return out
+}
+
+// Apply reduces a vector of SourceDim dimensions and normalizes the result to unit length.
+// Vectors of any other size, such as ones already reduced, are returned unchanged.
+func (r *Reduction) Apply(v []float32) []float32 {
+	if len(v) != r.SourceDim {
+		return v
+	}
+	out := make([]float64, r.Dimension)
+	switch r.Method {
+	case ReductionPCA:
+		centered := make([]float64, len(v))
+		for i, x := range v {
+			centered[i] = float64(x) - float64(r.Mean[i])
+		}
+		for c, component := range r.Components {
+			sum := 0.0
+			for i, w := range component {
+				sum += float64(w) * centered[i]
+			}
+			out[c] = sum
+		}
+	default:
+		for i := range out {
+			out[i] = float64(v[i])
+		}
+	}
+	if norm := math.Sqrt(dot(out, out)); norm > 0 {
+		for i := range out {
+			out[i] /= norm
+		}
+	}
+	return toFloat32(out)
+}
+
+// ReductionDir returns where the reductions of collections are recorded under the work
+// directory
+func ReductionDir(workDir string) string {
+	return filepath.Join(workDir, "reductions")
+}
+
+// ReductionFile returns the file recording the reduction of a collection
+func ReductionFile(dir, collectionName string) string {
+	return filepath.Join(dir, collectionName+".json")
+}
+
+// SaveReduction records the reduction of its collection in dir
+func SaveReduction(dir string, r *Reduction) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to encode reduction: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create reduction directory: %w", err)
+	}
+	// Write then rename so readers never see a truncated record
+	file := ReductionFile(dir, r.Collection)
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write reduction: %w", err)
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		return fmt.Errorf("failed to write reduction: %w", err)
+	}
+	return nil
+}
+
+// LoadReductions reads the reductions recorded in dir, by collection. A missing directory
+// has none.
+func LoadReductions(dir string) (map[string]*Reduction, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read reductions: %w", err)
+	}
+	reductions := make(map[string]*Reduction)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read reduction: %w", err)
+		}
+		var r Reduction
+		if err := json.Unmarshal(data, &r); err != nil {
+			return nil, fmt.Errorf("failed to parse reduction %s: %w", entry.Name(), err)
+		}
+		if r.Method == ReductionPCA && (len(r.Mean) != r.SourceDim || len(r.Components) != r.Dimension) {
+			return nil, fmt.Errorf("reduction %s is incomplete", entry.Name())
+		}
+		reductions[r.Collection] = &r
+	}
+	return reductions, nil
+}
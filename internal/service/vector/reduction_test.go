@@ -0,0 +1,210 @@
+package vector
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"bot-go/internal/model"
+	"bot-go/pkg/lsp/base"
+
+	"go.uber.org/zap"
+)
+
+// planeVectors returns n vectors of dimension d that vary along two directions, plus a
+// little noise in every dimension
+func planeVectors(n, d int) [][]float32 {
+	rng := rand.New(rand.NewSource(7))
+	vectors := make([][]float32, n)
+	for i := range vectors {
+		a, b := rng.NormFloat64()*3, rng.NormFloat64()*2
+		v := make([]float32, d)
+		for j := range v {
+			v[j] = float32(1 + rng.NormFloat64()*0.01)
+		}
+		v[1] += float32(a)
+		v[2] += float32(a)
+		v[4] += float32(b)
+		vectors[i] = v
+	}
+	return vectors
+}
+
+func norm(v []float32) float64 {
+	sum := 0.0
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	return math.Sqrt(sum)
+}
+
+func TestFitPCA(t *testing.T) {
+	vectors := planeVectors(500, 8)
+	r, err := FitPCA(vectors, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.ExplainedVariance < 0.99 {
+		t.Errorf("explained variance = %f, want the plane's variance", r.ExplainedVariance)
+	}
+	// The first component is the direction with the most variance, (e1 + e2)/√2
+	first := r.Components[0]
+	if math.Abs(math.Abs(float64(first[1]))-math.Sqrt2/2) > 0.01 || math.Abs(float64(first[1])-float64(first[2])) > 0.01 {
+		t.Errorf("first component = %v", first)
+	}
+
+	reduced := r.Apply(vectors[0])
+	if len(reduced) != 2 || math.Abs(norm(reduced)-1) > 1e-5 {
+		t.Errorf("Apply = %v, want a unit vector of 2 dimensions", reduced)
+	}
+	if again := r.Apply(reduced); len(again) != 2 {
+		t.Errorf("Apply of a reduced vector = %v", again)
+	}
+
+	if _, err := FitPCA(vectors, 8); err == nil {
+		t.Error("FitPCA to the source dimension succeeded")
+	}
+}
+
+func TestTruncation(t *testing.T) {
+	r, err := Truncation(4, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := r.Apply([]float32{3, 4, 100, 100})
+	if len(got) != 2 || math.Abs(float64(got[0])-0.6) > 1e-6 || math.Abs(float64(got[1])-0.8) > 1e-6 {
+		t.Errorf("Apply = %v, want [0.6 0.8]", got)
+	}
+	if _, err := Truncation(4, 0); err == nil {
+		t.Error("Truncation to 0 dimensions succeeded")
+	}
+}
+
+// memoryVectorDatabase keeps collections in memory for the reduction job
+type memoryVectorDatabase struct {
+	VectorDatabase
+	dims        map[string]int
+	collections map[string]map[string]*model.CodeChunk
+}
+
+func newMemoryVectorDatabase() *memoryVectorDatabase {
+	return &memoryVectorDatabase{dims: map[string]int{}, collections: map[string]map[string]*model.CodeChunk{}}
+}
+
+func (db *memoryVectorDatabase) CreateCollection(ctx context.Context, name string, dim int, distance DistanceMetric) error {
+	db.dims[name] = dim
+	db.collections[name] = map[string]*model.CodeChunk{}
+	return nil
+}
+
+func (db *memoryVectorDatabase) DeleteCollection(ctx context.Context, name string) error {
+	delete(db.dims, name)
+	delete(db.collections, name)
+	return nil
+}
+
+func (db *memoryVectorDatabase) CollectionExists(ctx context.Context, name string) (bool, error) {
+	_, ok := db.collections[name]
+	return ok, nil
+}
+
+func (db *memoryVectorDatabase) UpsertChunks(ctx context.Context, name string, chunks []*model.CodeChunk) error {
+	for _, chunk := range chunks {
+		if len(chunk.Embedding) != db.dims[name] {
+			return fmt.Errorf("vector of %d dimensions in collection of %d", len(chunk.Embedding), db.dims[name])
+		}
+		copied := *chunk
+		db.collections[name][chunk.ID] = &copied
+	}
+	return nil
+}
+
+func (db *memoryVectorDatabase) ScrollChunks(ctx context.Context, name string, offset string, limit int) ([]*model.CodeChunk, string, error) {
+	ids := make([]string, 0, len(db.collections[name]))
+	for id := range db.collections[name] {
+		if id >= offset {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	next := ""
+	if len(ids) > limit {
+		next = ids[limit]
+		ids = ids[:limit]
+	}
+	chunks := make([]*model.CodeChunk, len(ids))
+	for i, id := range ids {
+		copied := *db.collections[name][id]
+		chunks[i] = &copied
+	}
+	return chunks, next, nil
+}
+
+func (db *memoryVectorDatabase) SearchSimilar(ctx context.Context, name string, query []float32, limit int, filter map[string]interface{}) ([]*model.CodeChunk, []float32, error) {
+	if len(query) != db.dims[name] {
+		return nil, nil, fmt.Errorf("query of %d dimensions in collection of %d", len(query), db.dims[name])
+	}
+	return nil, nil, nil
+}
+
+func TestReduceCollection(t *testing.T) {
+	ctx := context.Background()
+	db := newMemoryVectorDatabase()
+	vectors := planeVectors(1200, 8) // more than two scroll pages
+	if err := db.CreateCollection(ctx, "repo", 8, DistanceMetricCosine); err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range vectors {
+		chunk := model.NewCodeChunk(fmt.Sprintf("%04d", i), model.ChunkTypeFunction, 3, "", "go", "main.go", rangeOf(i))
+		chunk.Embedding = v
+		if err := db.UpsertChunks(ctx, "repo", []*model.CodeChunk{chunk}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dir := t.TempDir()
+	r, err := ReduceCollection(ctx, db, dir, "repo", ReduceOptions{Method: ReductionPCA, Dimension: 3, SampleSize: 300}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Samples != 300 || db.dims["repo"] != 3 || len(db.collections["repo"]) != len(vectors) {
+		t.Errorf("samples = %d, dimension = %d, chunks = %d", r.Samples, db.dims["repo"], len(db.collections["repo"]))
+	}
+	if _, ok := db.collections["repo"+reducingSuffix]; ok {
+		t.Error("staging collection was not deleted")
+	}
+	if chunk := db.collections["repo"]["0007"]; chunk.FilePath != "main.go" || chunk.StartLine != 7 {
+		t.Errorf("payload not kept: %+v", chunk)
+	}
+
+	if _, err := ReduceCollection(ctx, db, dir, "repo", ReduceOptions{Method: ReductionPCA, Dimension: 2}, zap.NewNop()); err == nil {
+		t.Error("second reduction of the collection succeeded")
+	}
+
+	// A server opened on the reduced collection keeps writing and searching full-size vectors
+	reducing, err := WithReductions(db, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunk := model.NewCodeChunk("new", model.ChunkTypeFunction, 3, "", "go", "main.go", rangeOf(0))
+	chunk.Embedding = vectors[0]
+	if err := reducing.UpsertChunks(ctx, "repo", []*model.CodeChunk{chunk}); err != nil {
+		t.Fatal(err)
+	}
+	if len(chunk.Embedding) != 8 {
+		t.Error("UpsertChunks changed the caller's embedding")
+	}
+	if _, _, err := reducing.SearchSimilar(ctx, "repo", vectors[1], 10, nil); err != nil {
+		t.Error(err)
+	}
+	if err := reducing.CreateCollection(ctx, "repo", 8, DistanceMetricCosine); err != nil || db.dims["repo"] != 3 {
+		t.Errorf("recreated collection has %d dimensions (%v)", db.dims["repo"], err)
+	}
+}
+
+func rangeOf(line int) base.Range {
+	return base.Range{Start: base.Position{Line: line}, End: base.Position{Line: line}}
+}
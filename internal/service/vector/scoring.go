@@ -0,0 +1,127 @@
+package vector
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"bot-go/internal/config"
+	"bot-go/internal/model"
+	"bot-go/internal/util"
+)
+
+// defaultRecencyHalfLifeDays is used when a ScoringConfig sets a recency boost without a half-life
+const defaultRecencyHalfLifeDays = 90
+
+// LastModifiedFunc returns when the lines of a chunk last changed, e.g. from the blame
+// metadata of the graph nodes they belong to
+type LastModifiedFunc func(ctx context.Context, chunk *model.CodeChunk) (time.Time, error)
+
+// ScoreAdjuster applies a repository's scoring configuration to search results: path rules,
+// recency of the matched lines and exact symbol name matches. It is used for both vector and
+// lexical results so configured preferences hold whichever ranking produced them.
+type ScoreAdjuster struct {
+	cfg          config.ScoringConfig
+	repoPath     string
+	patterns     []*regexp.Regexp // compiled PathRules patterns, nil for class rules
+	lastModified LastModifiedFunc
+	now          func() time.Time
+}
+
+// NewScoreAdjuster returns an adjuster for repo, or nil if the repository configures no
+// scoring. lastModified may be nil, which disables the recency boost.
+func NewScoreAdjuster(repo *config.Repository, lastModified LastModifiedFunc) *ScoreAdjuster {
+	if repo == nil || repo.Scoring == nil {
+		return nil
+	}
+	a := &ScoreAdjuster{
+		cfg:          *repo.Scoring,
+		repoPath:     repo.Path,
+		patterns:     make([]*regexp.Regexp, len(repo.Scoring.PathRules)),
+		lastModified: lastModified,
+		now:          time.Now,
+	}
+	for i, rule := range repo.Scoring.PathRules {
+		if rule.Pattern != "" {
+			a.patterns[i] = globToRegexp(rule.Pattern)
+		}
+	}
+	return a
+}
+
+// Multiplier returns the factor to apply to the raw score of chunk. queryNames holds the
+// symbol names of the query chunks for the exact-name boost.
+func (a *ScoreAdjuster) Multiplier(ctx context.Context, chunk *model.CodeChunk, queryNames map[string]bool) float64 {
+	if a == nil {
+		return 1
+	}
+	factor := 1.0
+
+	relPath := chunk.FilePath
+	if filepath.IsAbs(relPath) && a.repoPath != "" {
+		if rel, err := filepath.Rel(a.repoPath, relPath); err == nil {
+			relPath = rel
+		}
+	}
+	relPath = filepath.ToSlash(relPath)
+	for i, rule := range a.cfg.PathRules {
+		if a.matchesRule(i, rule, relPath) {
+			factor *= rule.Weight
+		}
+	}
+
+	if a.cfg.ExactNameBoost > 0 && chunk.Name != "" && queryNames[chunk.Name] {
+		factor *= 1 + a.cfg.ExactNameBoost
+	}
+
+	if a.cfg.RecencyBoost > 0 && a.lastModified != nil {
+		// Failures (e.g. no blame metadata) leave the score unboosted
+		if modified, err := a.lastModified(ctx, chunk); err == nil {
+			halfLife := a.cfg.RecencyHalfLifeDays
+			if halfLife <= 0 {
+				halfLife = defaultRecencyHalfLifeDays
+			}
+			ageDays := math.Max(0, a.now().Sub(modified).Hours()/24)
+			factor *= 1 + a.cfg.RecencyBoost*math.Pow(0.5, ageDays/float64(halfLife))
+		}
+	}
+	return factor
+}
+
+func (a *ScoreAdjuster) matchesRule(i int, rule config.PathScoreRule, relPath string) bool {
+	switch rule.Class {
+	case "test":
+		return util.IsTestFile(relPath)
+	case "generated":
+		return util.IsGeneratedFile(relPath, nil)
+	}
+	return a.patterns[i] != nil && a.patterns[i].MatchString(relPath)
+}
+
+// globToRegexp compiles a slash-separated glob: "**" matches across directories, "*" and "?"
+// within one path segment
+func globToRegexp(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			b.WriteString("[^/]*")
+		case pattern[i] == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
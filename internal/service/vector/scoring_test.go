@@ -0,0 +1,51 @@
+package vector
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"bot-go/internal/config"
+	"bot-go/internal/model"
+)
+
+func TestScoreAdjusterMultiplier(t *testing.T) {
+	now := time.Now()
+	repo := &config.Repository{
+		Path: "/repo",
+		Scoring: &config.ScoringConfig{
+			PathRules: []config.PathScoreRule{
+				{Class: "test", Weight: 0.5},
+				{Pattern: "internal/**/*.go", Weight: 1.2},
+			},
+			RecencyBoost:        0.4,
+			RecencyHalfLifeDays: 10,
+			ExactNameBoost:      0.25,
+		},
+	}
+	adjuster := NewScoreAdjuster(repo, func(ctx context.Context, chunk *model.CodeChunk) (time.Time, error) {
+		return now.Add(-10 * 24 * time.Hour), nil
+	})
+	adjuster.now = func() time.Time { return now }
+
+	tests := []struct {
+		name  string
+		chunk *model.CodeChunk
+		want  float64
+	}{
+		{"test file in internal", &model.CodeChunk{FilePath: "/repo/internal/a/b_test.go"}, 0.5 * 1.2 * 1.2},
+		{"exact name", &model.CodeChunk{FilePath: "/repo/cmd/main.go", Name: "Serve"}, 1.25 * 1.2},
+		{"no rule", &model.CodeChunk{FilePath: "/repo/cmd/main.go", Name: "other"}, 1.2},
+	}
+	for _, tt := range tests {
+		got := adjuster.Multiplier(context.Background(), tt.chunk, map[string]bool{"Serve": true})
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: Multiplier() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if NewScoreAdjuster(&config.Repository{}, nil) != nil {
+		t.Error("expected no adjuster without scoring configuration")
+	}
+}
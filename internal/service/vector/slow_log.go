@@ -0,0 +1,76 @@
+package vector
+
+import (
+	"context"
+	"time"
+
+	"bot-go/internal/model"
+	"bot-go/internal/slowlog"
+)
+
+// slowLogVectorDatabase times the similarity searches of a VectorDatabase
+type slowLogVectorDatabase struct {
+	VectorDatabase
+	log *slowlog.Log
+}
+
+// WithSlowLog reports the duration of every similarity search of db to log, which logs slow
+// searches with their parameters. A nil log returns db unchanged.
+func WithSlowLog(db VectorDatabase, log *slowlog.Log) VectorDatabase {
+	if log == nil {
+		return db
+	}
+	return &slowLogVectorDatabase{VectorDatabase: db, log: log}
+}
+
+// SearchSimilar runs the search and records its duration per collection
+func (db *slowLogVectorDatabase) SearchSimilar(ctx context.Context, collectionName string, queryVector []float32, limit int, filter map[string]interface{}) ([]*model.CodeChunk, []float32, error) {
+	start := time.Now()
+	chunks, scores, err := db.VectorDatabase.SearchSimilar(ctx, collectionName, queryVector, limit, filter)
+	db.log.Observe(ctx, slowlog.KindVectorSearch, collectionName, map[string]any{
+		"collection": collectionName,
+		"limit":      limit,
+		"filter":     filter,
+		"results":    len(chunks),
+	}, time.Since(start))
+	return chunks, scores, err
+}
+
+// slowLogEmbeddingModel times the calls of an EmbeddingModel
+type slowLogEmbeddingModel struct {
+	EmbeddingModel
+	log *slowlog.Log
+}
+
+// EmbeddingWithSlowLog reports the duration of every embedding call of model to log. The
+// texts are not logged, only their number and size. A nil log returns model unchanged.
+func EmbeddingWithSlowLog(model EmbeddingModel, log *slowlog.Log) EmbeddingModel {
+	if log == nil {
+		return model
+	}
+	return &slowLogEmbeddingModel{EmbeddingModel: model, log: log}
+}
+
+// GenerateEmbedding embeds one text and records the duration
+func (m *slowLogEmbeddingModel) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	defer m.observe(ctx, []string{text}, time.Now())
+	return m.EmbeddingModel.GenerateEmbedding(ctx, text)
+}
+
+// GenerateEmbeddings embeds a batch of texts and records the duration
+func (m *slowLogEmbeddingModel) GenerateEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
+	defer m.observe(ctx, texts, time.Now())
+	return m.EmbeddingModel.GenerateEmbeddings(ctx, texts)
+}
+
+func (m *slowLogEmbeddingModel) observe(ctx context.Context, texts []string, start time.Time) {
+	chars := 0
+	for _, text := range texts {
+		chars += len(text)
+	}
+	m.log.Observe(ctx, slowlog.KindEmbedding, m.GetModelName(), map[string]any{
+		"model": m.GetModelName(),
+		"texts": len(texts),
+		"chars": chars,
+	}, time.Since(start))
+}
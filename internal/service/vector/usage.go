@@ -0,0 +1,39 @@
+package vector
+
+import (
+	"context"
+
+	"bot-go/internal/access"
+)
+
+// usageEmbeddingModel charges the tokens of embedded texts to the API client of their request
+type usageEmbeddingModel struct {
+	EmbeddingModel
+}
+
+// EmbeddingWithUsage charges the texts embedded for API requests to the usage account of
+// their client (see access.Record). Tokens are estimated from the size of the texts, at
+// access.BytesPerToken bytes each, whether or not the call succeeds.
+func EmbeddingWithUsage(model EmbeddingModel) EmbeddingModel {
+	return &usageEmbeddingModel{EmbeddingModel: model}
+}
+
+// GenerateEmbedding embeds one text and charges its tokens
+func (m *usageEmbeddingModel) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	m.record(ctx, []string{text})
+	return m.EmbeddingModel.GenerateEmbedding(ctx, text)
+}
+
+// GenerateEmbeddings embeds a batch of texts and charges their tokens
+func (m *usageEmbeddingModel) GenerateEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
+	m.record(ctx, texts)
+	return m.EmbeddingModel.GenerateEmbeddings(ctx, texts)
+}
+
+func (m *usageEmbeddingModel) record(ctx context.Context, texts []string) {
+	var tokens int64
+	for _, text := range texts {
+		tokens += (int64(len(text)) + access.BytesPerToken - 1) / access.BytesPerToken
+	}
+	access.Record(ctx, access.Usage{EmbeddingTokens: tokens})
+}
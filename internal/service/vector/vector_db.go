@@ -3,6 +3,7 @@ package vector
 import (
 	"bot-go/internal/model"
 	"context"
+	"time"
 )
 
 // VectorDatabase represents a generic vector database interface
@@ -32,6 +33,22 @@ type VectorDatabase interface {
 	// GetChunksByFilePath retrieves all chunks for a specific file path
 	GetChunksByFilePath(ctx context.Context, collectionName string, filePath string) ([]*model.CodeChunk, error)
 
+	// GetChunksByFileID retrieves all chunks for a specific FileID (shared with CodeGraph and n-gram models)
+	GetChunksByFileID(ctx context.Context, collectionName string, fileID int32) ([]*model.CodeChunk, error)
+
+	// PurgeChunks deletes every chunk matching the filter and returns how many were deleted
+	PurgeChunks(ctx context.Context, collectionName string, filter PurgeFilter) (int, error)
+
+	// ListChunkFiles returns the files that have chunks in the collection, with their chunk counts
+	ListChunkFiles(ctx context.Context, collectionName string) ([]ChunkFile, error)
+
+	// ScrollChunks returns up to limit chunks of the collection with their embeddings, starting
+	// at offset ("" for the first page), and the offset of the next page ("" after the last)
+	ScrollChunks(ctx context.Context, collectionName string, offset string, limit int) ([]*model.CodeChunk, string, error)
+
+	// ListCollections returns the names of all collections
+	ListCollections(ctx context.Context) ([]string, error)
+
 	// Close closes the database connection
 	Close() error
 
@@ -39,6 +56,32 @@ type VectorDatabase interface {
 	Health(ctx context.Context) error
 }
 
+// PurgeFilter selects chunks to delete. Set fields are combined with AND; at least one
+// must be set. Time filters only match chunks that carry the corresponding timestamp,
+// so chunks indexed before timestamps were recorded are never purged by age.
+type PurgeFilter struct {
+	PathPrefix    string            // file_path starts with this prefix
+	Language      string            // exact language
+	ChunkTypes    []model.ChunkType // any of these chunk types
+	IndexedBefore time.Time         // indexed_at is before this time
+	ExpiredBefore time.Time         // expires_at is before this time (TTL has passed)
+	FileIDs       []int32           // any of these file IDs
+}
+
+// IsEmpty reports whether no filter is set
+func (f PurgeFilter) IsEmpty() bool {
+	return f.PathPrefix == "" && f.Language == "" && len(f.ChunkTypes) == 0 &&
+		f.IndexedBefore.IsZero() && f.ExpiredBefore.IsZero() && len(f.FileIDs) == 0
+}
+
+// ChunkFile is one file with chunks in a collection. Chunks written before file IDs were
+// recorded have FileID 0.
+type ChunkFile struct {
+	FileID   int32  `json:"file_id"`
+	FilePath string `json:"file_path"`
+	Chunks   int    `json:"chunks"`
+}
+
 // DistanceMetric represents the distance metric used for vector similarity
 type DistanceMetric string
 
@@ -0,0 +1,121 @@
+package util
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// codeOwnersLocations are the paths GitHub reads a CODEOWNERS file from, in priority order
+var codeOwnersLocations = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// CodeOwners maps repository paths to owners per a CODEOWNERS file
+type CodeOwners struct {
+	rules []codeOwnersRule
+}
+
+type codeOwnersRule struct {
+	pattern *regexp.Regexp
+	owners  []string
+}
+
+// LoadCodeOwners reads the CODEOWNERS file of a repository. It returns nil without error
+// when the repository has none.
+func LoadCodeOwners(repoPath string) (*CodeOwners, error) {
+	for _, location := range codeOwnersLocations {
+		f, err := os.Open(filepath.Join(repoPath, filepath.FromSlash(location)))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return ParseCodeOwners(f)
+	}
+	return nil, nil
+}
+
+// ParseCodeOwners parses CODEOWNERS content: one "pattern owner..." rule per line, with
+// gitignore-style patterns. Comments and blank lines are skipped.
+func ParseCodeOwners(r io.Reader) (*CodeOwners, error) {
+	co := &CodeOwners{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		co.rules = append(co.rules, codeOwnersRule{
+			pattern: codeOwnersPattern(fields[0]),
+			owners:  fields[1:],
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return co, nil
+}
+
+// Owners returns the owners of a repository-relative path. The last matching rule wins, as
+// on GitHub; a matching rule without owners leaves the path unowned.
+func (co *CodeOwners) Owners(relPath string) []string {
+	if co == nil {
+		return nil
+	}
+	relPath = strings.TrimPrefix(filepath.ToSlash(relPath), "/")
+	for i := len(co.rules) - 1; i >= 0; i-- {
+		if co.rules[i].pattern.MatchString(relPath) {
+			return co.rules[i].owners
+		}
+	}
+	return nil
+}
+
+// codeOwnersPattern converts a gitignore-style pattern to a regexp over relative paths.
+// Patterns with a leading or inner slash are anchored at the root, others match at any
+// depth; a pattern matching a directory matches everything below it.
+func codeOwnersPattern(pattern string) *regexp.Regexp {
+	anchored := strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+	dirOnly := strings.HasSuffix(pattern, "/")
+	pattern = strings.Trim(pattern, "/")
+
+	var b strings.Builder
+	b.WriteString("^")
+	if !anchored {
+		b.WriteString("(.*/)?")
+	}
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			b.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			b.WriteString("[^/]*")
+		case pattern[i] == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	switch {
+	case dirOnly:
+		b.WriteString("/.*$")
+	case strings.HasSuffix(pattern, "/*"):
+		// "docs/*" matches the files directly in docs only
+		b.WriteString("$")
+	default:
+		b.WriteString("(/.*)?$")
+	}
+	return regexp.MustCompile(b.String())
+}
@@ -0,0 +1,171 @@
+package util
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// FileDiff is the change of one file in a unified diff
+type FileDiff struct {
+	OldPath string // empty for an added file
+	NewPath string // empty for a deleted file
+	Binary  bool   // a binary change, which has no hunks
+	Hunks   []Hunk
+}
+
+// Path returns the path of the file after the change, or before it for a deleted file
+func (f *FileDiff) Path() string {
+	if f.NewPath != "" {
+		return f.NewPath
+	}
+	return f.OldPath
+}
+
+// Hunk is a hunk of a unified diff. Line numbers are 1-based.
+type Hunk struct {
+	OldStart, OldLines int
+	NewStart, NewLines int
+	Section            string     // text after the range header, often the enclosing function
+	Removed            []DiffLine // removed lines, numbered in the old file
+	Added              []DiffLine // added lines, numbered in the new file
+}
+
+// DiffLine is a line removed or added by a hunk
+type DiffLine struct {
+	Line  int
+	Text  string
+	After int // for added lines, the line of the old file the line was inserted after
+}
+
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@ ?(.*)$`)
+
+// ParseUnifiedDiff parses the output of git diff or diff -u. File headers without hunks,
+// such as mode changes and pure renames, yield a FileDiff without hunks.
+func ParseUnifiedDiff(diff string) ([]FileDiff, error) {
+	var files []FileDiff
+	var file *FileDiff
+	var hunk *Hunk
+	oldLine, newLine := 0, 0 // next line of each side in the current hunk
+	complete := false        // the current file's ---/+++ or binary header was read
+
+	inHunk := func() bool {
+		return hunk != nil && (oldLine < hunk.OldStart+hunk.OldLines || newLine < hunk.NewStart+hunk.NewLines)
+	}
+	startFile := func() {
+		files = append(files, FileDiff{})
+		file = &files[len(files)-1]
+		hunk = nil
+		complete = false
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(diff))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if inHunk() {
+			switch {
+			case strings.HasPrefix(line, "-"):
+				hunk.Removed = append(hunk.Removed, DiffLine{Line: oldLine, Text: line[1:]})
+				oldLine++
+			case strings.HasPrefix(line, "+"):
+				hunk.Added = append(hunk.Added, DiffLine{Line: newLine, Text: line[1:], After: oldLine - 1})
+				newLine++
+			case strings.HasPrefix(line, " ") || line == "":
+				oldLine++
+				newLine++
+			case strings.HasPrefix(line, `\`): // "\ No newline at end of file"
+			default:
+				return nil, fmt.Errorf("line %d: unexpected line in hunk: %q", n, line)
+			}
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			startFile()
+			if a, b, ok := strings.Cut(strings.TrimPrefix(line, "diff --git "), " b/"); ok {
+				file.OldPath, file.NewPath = strings.TrimPrefix(a, "a/"), b
+			}
+		case strings.HasPrefix(line, "--- "):
+			// Without git headers each file starts at its --- line
+			if file == nil || complete {
+				startFile()
+			}
+			file.OldPath = diffPath(line[4:], "a/")
+		case strings.HasPrefix(line, "+++ ") && file != nil:
+			file.NewPath = diffPath(line[4:], "b/")
+			complete = true
+		case strings.HasPrefix(line, "new file mode") && file != nil:
+			file.OldPath = ""
+		case strings.HasPrefix(line, "deleted file mode") && file != nil:
+			file.NewPath = ""
+		case strings.HasPrefix(line, "rename from ") && file != nil:
+			file.OldPath = strings.TrimPrefix(line, "rename from ")
+		case strings.HasPrefix(line, "rename to ") && file != nil:
+			file.NewPath = strings.TrimPrefix(line, "rename to ")
+		case strings.HasPrefix(line, "Binary files ") && file != nil:
+			file.Binary = true
+			complete = true
+		case strings.HasPrefix(line, "@@"):
+			if file == nil {
+				return nil, fmt.Errorf("line %d: hunk before any file header", n)
+			}
+			m := hunkHeader.FindStringSubmatch(line)
+			if m == nil {
+				return nil, fmt.Errorf("line %d: malformed hunk header %q", n, line)
+			}
+			file.Hunks = append(file.Hunks, Hunk{
+				OldStart: atoi(m[1]),
+				OldLines: rangeLength(m[2]),
+				NewStart: atoi(m[3]),
+				NewLines: rangeLength(m[4]),
+				Section:  m[5],
+			})
+			hunk = &file.Hunks[len(file.Hunks)-1]
+			oldLine, newLine = hunk.OldStart, hunk.NewStart
+			// The start of an empty side is the line before it; move it to the line after,
+			// so that every range covers [start, start+lines)
+			if hunk.OldLines == 0 {
+				oldLine++
+				hunk.OldStart++
+			}
+			if hunk.NewLines == 0 {
+				newLine++
+				hunk.NewStart++
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	// A diff ending inside a hunk is accepted: editors and chat clients often strip the
+	// blank context lines at the end of a pasted diff
+	return files, nil
+}
+
+// diffPath returns the path of a ---/+++ header without its a/ or b/ prefix and timestamp,
+// or "" for /dev/null
+func diffPath(header, prefix string) string {
+	if i := strings.IndexByte(header, '\t'); i >= 0 {
+		header = header[:i]
+	}
+	if header == "/dev/null" {
+		return ""
+	}
+	return strings.TrimPrefix(header, prefix)
+}
+
+func rangeLength(s string) int {
+	if s == "" {
+		return 1
+	}
+	return atoi(s)
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
@@ -0,0 +1,91 @@
+package util
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseUnifiedDiff(t *testing.T) {
+	diff := `diff --git a/store/store.go b/store/store.go
+index 1111111..2222222 100644
+--- a/store/store.go
++++ b/store/store.go
+@@ -10,6 +10,7 @@ func (s *Store) Get(key string) string {
+ 	s.mu.Lock()
+ 	defer s.mu.Unlock()
+-	return s.items[key]
++	value := s.items[key]
++	return strings.TrimSpace(value)
+ }
+ 
+ func (s *Store) Put(key, value string) {
+@@ -40,0 +42,2 @@ func (s *Store) Len() int {
++
++func (s *Store) Reset() { s.items = nil }
+diff --git a/old.py b/old.py
+deleted file mode 100644
+--- a/old.py
++++ /dev/null
+@@ -1,2 +0,0 @@
+-def f():
+-    pass
+diff --git a/logo.png b/logo.png
+Binary files a/logo.png and b/logo.png differ
+--- new.txt	2026-01-02 10:00:00
++++ new.txt	2026-01-02 10:01:00
+@@ -1 +1 @@
+-a
++b
+`
+	files, err := ParseUnifiedDiff(diff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 4 {
+		t.Fatalf("got %d files, want 4: %+v", len(files), files)
+	}
+
+	store := files[0]
+	if store.OldPath != "store/store.go" || store.NewPath != "store/store.go" || len(store.Hunks) != 2 {
+		t.Fatalf("unexpected store.go diff %+v", store)
+	}
+	first := store.Hunks[0]
+	if first.OldStart != 10 || first.OldLines != 6 || first.NewStart != 10 || first.NewLines != 7 {
+		t.Errorf("unexpected ranges %+v", first)
+	}
+	if first.Section != "func (s *Store) Get(key string) string {" {
+		t.Errorf("section = %q", first.Section)
+	}
+	if want := []DiffLine{{Line: 12, Text: "\treturn s.items[key]"}}; !reflect.DeepEqual(first.Removed, want) {
+		t.Errorf("removed = %+v, want %+v", first.Removed, want)
+	}
+	if len(first.Added) != 2 || first.Added[0].Line != 12 || first.Added[1].Line != 13 || first.Added[1].After != 12 {
+		t.Errorf("unexpected added lines %+v", first.Added)
+	}
+	insertion := store.Hunks[1]
+	if insertion.OldStart != 41 || insertion.OldLines != 0 || len(insertion.Added) != 2 || insertion.Added[1].After != 40 {
+		t.Errorf("unexpected insertion hunk %+v", insertion)
+	}
+
+	if deleted := files[1]; deleted.NewPath != "" || deleted.Path() != "old.py" || len(deleted.Hunks[0].Removed) != 2 {
+		t.Errorf("unexpected deleted file %+v", deleted)
+	}
+	if binary := files[2]; !binary.Binary || binary.Path() != "logo.png" || len(binary.Hunks) != 0 {
+		t.Errorf("unexpected binary file %+v", binary)
+	}
+	if plain := files[3]; plain.OldPath != "new.txt" || plain.NewPath != "new.txt" || len(plain.Hunks[0].Added) != 1 {
+		t.Errorf("unexpected plain diff %+v", plain)
+	}
+}
+
+func TestParseUnifiedDiffErrors(t *testing.T) {
+	for _, diff := range []string{
+		"@@ -1 +1 @@\n-a\n+b\n",
+		"--- a/x\n+++ b/x\n@@ -1 +1 @@\n-a\n?b\n",
+		"--- a/x\n+++ b/x\n@@ -x +1 @@\n",
+	} {
+		if _, err := ParseUnifiedDiff(diff); err == nil {
+			t.Errorf("expected an error for %q", diff)
+		}
+	}
+}
@@ -6,7 +6,9 @@ import (
 	"os/exec"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"bot-go/internal/config"
 )
@@ -27,6 +29,15 @@ type GitAnalyzer interface {
 
 	// GetCoChangedFiles returns files that frequently change together
 	GetCoChangedFiles(ctx context.Context, filePath string, lookbackCommits int) ([]CoChangeInfo, error)
+
+	// GetBlame returns the last change of every line of a file as of a commit
+	GetBlame(ctx context.Context, filePath, commit string) ([]BlameLine, error)
+
+	// GetCommitLog returns the most recent commits of HEAD with the files each one touched
+	GetCommitLog(ctx context.Context, lookbackCommits int) ([]CommitInfo, error)
+
+	// GetCommits returns the given commits with the files each one touched
+	GetCommits(ctx context.Context, hashes []string) ([]CommitInfo, error)
 }
 
 // CoChangeInfo represents co-change information
@@ -46,6 +57,32 @@ type ChangeInfo struct {
 	LinesRemoved int
 }
 
+// BlameLine is the last change of one line of a file
+type BlameLine struct {
+	Line          int // 1-based line number in the blamed version
+	CommitHash    string
+	Author        string
+	AuthorEmail   string
+	AuthorTime    time.Time
+	CommitterTime time.Time
+}
+
+// Uncommitted reports whether the line only exists in the working tree
+func (b BlameLine) Uncommitted() bool {
+	return strings.Trim(b.CommitHash, "0") == ""
+}
+
+// CommitInfo describes a commit and the repository-relative paths it touched
+type CommitInfo struct {
+	Hash        string
+	Author      string
+	AuthorEmail string
+	Time        time.Time // committer time
+	Subject     string
+	Body        string
+	Files       []string
+}
+
 // NewGitAnalyzer creates a new GitAnalyzer based on configuration
 // Currently only supports "ondemand" mode; "precompute" mode is not yet implemented
 // Returns an error if:
@@ -196,6 +233,136 @@ func (g *OnDemandGitAnalyzer) GetCoChangedFiles(ctx context.Context, filePath st
 	return results, nil
 }
 
+// GetBlame returns the blame of every line of a file as of commit ("" for the working tree)
+func (g *OnDemandGitAnalyzer) GetBlame(ctx context.Context, filePath, commit string) ([]BlameLine, error) {
+	if commit == "" {
+		return g.blame(ctx, filePath)
+	}
+	return g.blame(ctx, filePath, commit)
+}
+
+// blame runs git blame --line-porcelain with extra arguments placed before the path
+func (g *OnDemandGitAnalyzer) blame(ctx context.Context, filePath string, args ...string) ([]BlameLine, error) {
+	relPath, err := g.getRelativePath(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get relative path: %w", err)
+	}
+
+	cmdArgs := append([]string{"blame", "--line-porcelain"}, args...)
+	cmdArgs = append(cmdArgs, "--", relPath)
+	cmd := exec.CommandContext(ctx, "git", cmdArgs...)
+	cmd.Dir = g.repoPath
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git blame failed for %s: %w", relPath, err)
+	}
+	return parseBlamePorcelain(string(output)), nil
+}
+
+// parseBlamePorcelain parses git blame --line-porcelain output, where every line of the file
+// is preceded by a full header ("<sha> <orig-line> <final-line>", author, committer-time, ...)
+// and followed by the line content prefixed with a tab
+func parseBlamePorcelain(output string) []BlameLine {
+	var lines []BlameLine
+	var current BlameLine
+	inHeader := false
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, "\t") {
+			if inHeader {
+				lines = append(lines, current)
+			}
+			inHeader = false
+			continue
+		}
+		if !inHeader {
+			fields := strings.Fields(line)
+			if len(fields) < 3 {
+				continue
+			}
+			finalLine, _ := strconv.Atoi(fields[2])
+			current = BlameLine{CommitHash: fields[0], Line: finalLine}
+			inHeader = true
+			continue
+		}
+
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "author":
+			current.Author = value
+		case "author-mail":
+			current.AuthorEmail = strings.Trim(value, "<>")
+		case "author-time":
+			if ts, err := strconv.ParseInt(value, 10, 64); err == nil {
+				current.AuthorTime = time.Unix(ts, 0)
+			}
+		case "committer-time":
+			if ts, err := strconv.ParseInt(value, 10, 64); err == nil {
+				current.CommitterTime = time.Unix(ts, 0)
+			}
+		}
+	}
+	return lines
+}
+
+// commitLogFormat separates commits with RS and fields with US so subjects and bodies can
+// hold any text; --name-only appends the touched files after the last field
+const commitLogFormat = "--format=%x1e%H%x1f%an%x1f%ae%x1f%ct%x1f%s%x1f%b%x1f"
+
+// GetCommitLog returns the lookbackCommits most recent commits of HEAD, newest first
+func (g *OnDemandGitAnalyzer) GetCommitLog(ctx context.Context, lookbackCommits int) ([]CommitInfo, error) {
+	if lookbackCommits <= 0 {
+		lookbackCommits = g.lookbackCommits
+	}
+	return g.commitLog(ctx, fmt.Sprintf("-n%d", lookbackCommits))
+}
+
+// GetCommits returns the given commits, newest first. Unknown hashes fail the whole call.
+func (g *OnDemandGitAnalyzer) GetCommits(ctx context.Context, hashes []string) ([]CommitInfo, error) {
+	if len(hashes) == 0 {
+		return nil, nil
+	}
+	return g.commitLog(ctx, append([]string{"--no-walk"}, hashes...)...)
+}
+
+func (g *OnDemandGitAnalyzer) commitLog(ctx context.Context, args ...string) ([]CommitInfo, error) {
+	cmdArgs := append([]string{"log", "--name-only", commitLogFormat}, args...)
+	cmd := exec.CommandContext(ctx, "git", cmdArgs...)
+	cmd.Dir = g.repoPath
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git log failed: %w", err)
+	}
+	return parseCommitLog(string(output)), nil
+}
+
+// parseCommitLog parses the output of git log with commitLogFormat and --name-only
+func parseCommitLog(output string) []CommitInfo {
+	var commits []CommitInfo
+	for _, record := range strings.Split(output, "\x1e") {
+		fields := strings.Split(record, "\x1f")
+		if len(fields) < 7 {
+			continue
+		}
+		commit := CommitInfo{
+			Hash:        fields[0],
+			Author:      fields[1],
+			AuthorEmail: fields[2],
+			Subject:     fields[4],
+			Body:        strings.TrimSpace(fields[5]),
+		}
+		if ts, err := strconv.ParseInt(fields[3], 10, 64); err == nil {
+			commit.Time = time.Unix(ts, 0)
+		}
+		for _, file := range strings.Split(fields[6], "\n") {
+			if file = strings.TrimSpace(file); file != "" {
+				commit.Files = append(commit.Files, file)
+			}
+		}
+		commits = append(commits, commit)
+	}
+	return commits
+}
+
 // getRelativePath converts an absolute or relative file path to a path relative to repo root
 func (g *OnDemandGitAnalyzer) getRelativePath(filePath string) (string, error) {
 	// If the path is already relative, use it as-is
@@ -0,0 +1,67 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+)
+
+// prNumberPatterns find the pull request a commit came from in its subject: GitHub merge
+// commits ("Merge pull request #12 from ...") and squash merges ("Fix parser (#12)")
+var prNumberPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^Merge pull request #(\d+)`),
+	regexp.MustCompile(`\(#(\d+)\)\s*$`),
+}
+
+// PullRequestNumber returns the pull request number referenced by a commit subject, or 0
+func PullRequestNumber(subject string) int {
+	for _, pattern := range prNumberPatterns {
+		if m := pattern.FindStringSubmatch(subject); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			return n
+		}
+	}
+	return 0
+}
+
+// PullRequestInfo is the part of a GitHub pull request stored with its commits
+type PullRequestInfo struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	URL    string `json:"html_url"`
+}
+
+// GitHubAPIURL is the base URL of the GitHub REST API
+const GitHubAPIURL = "https://api.github.com"
+
+// FetchPullRequest reads a pull request of repo ("owner/name") from the GitHub API. token may
+// be empty for public repositories, at a much lower rate limit.
+func FetchPullRequest(ctx context.Context, client *http.Client, repo, token string, number int) (*PullRequestInfo, error) {
+	url := fmt.Sprintf("%s/repos/%s/pulls/%d", GitHubAPIURL, repo, number)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch pull request %s#%d: %w", repo, number, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch pull request %s#%d: %s", repo, number, resp.Status)
+	}
+
+	var pr PullRequestInfo
+	if err := json.NewDecoder(resp.Body).Decode(&pr); err != nil {
+		return nil, fmt.Errorf("failed to decode pull request %s#%d: %w", repo, number, err)
+	}
+	return &pr, nil
+}
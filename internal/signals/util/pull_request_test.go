@@ -0,0 +1,19 @@
+package util
+
+import "testing"
+
+func TestPullRequestNumber(t *testing.T) {
+	tests := []struct {
+		subject string
+		want    int
+	}{
+		{"Fix parser crash (#42)", 42},
+		{"Merge pull request #7 from fork/branch", 7},
+		{"Fix #42 in the parser", 0},
+	}
+	for _, tt := range tests {
+		if got := PullRequestNumber(tt.subject); got != tt.want {
+			t.Errorf("PullRequestNumber(%q) = %d, want %d", tt.subject, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+// Package slowlog times Neo4j queries, vector searches, embedding calls and HTTP requests.
+// Operations slower than their threshold are logged with their sanitized parameters, and
+// per-operation counts and durations are kept for the metrics endpoint.
+package slowlog
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"bot-go/internal/config"
+	"bot-go/internal/logging"
+
+	"go.uber.org/zap"
+)
+
+// Kinds of timed operations
+const (
+	KindNeo4j        = "neo4j"
+	KindVectorSearch = "vector_search"
+	KindEmbedding    = "embedding"
+	KindHTTP         = "http"
+)
+
+const (
+	// maxOperations bounds the distinct operations tracked; later ones are counted together
+	maxOperations    = 1000
+	otherOperation   = "(other)"
+	maxLabelLength   = 160
+	maxStringParam   = 200
+	maxSliceParam    = 10
+	redactedParam    = "[redacted]"
+	defaultThreshold = 500 * time.Millisecond
+	sanitizeDepth    = 4
+	truncatedMarker  = "..."
+)
+
+// sensitiveParams are substrings of parameter names whose values are never logged
+var sensitiveParams = []string{"password", "secret", "token", "apikey", "api_key", "credential"}
+
+// Stat aggregates the calls of one operation
+type Stat struct {
+	Kind      string  `json:"kind"`
+	Operation string  `json:"operation"` // normalized query text, collection, model or route
+	Count     int64   `json:"count"`
+	Slow      int64   `json:"slow"` // calls over the threshold or budget
+	TotalMs   float64 `json:"total_ms"`
+	MaxMs     float64 `json:"max_ms"`
+}
+
+type statKey struct {
+	kind, operation string
+}
+
+// Log records the duration of operations. A nil *Log records nothing, so callers need not
+// check whether slow query logging is enabled.
+type Log struct {
+	threshold time.Duration
+	logger    *zap.Logger
+
+	// Latency budgets of HTTP endpoints, keyed by "METHOD /route"
+	defaultBudget time.Duration
+	budgets       map[string]time.Duration
+
+	mu    sync.Mutex
+	stats map[statKey]*Stat
+}
+
+// New creates a Log that logs operations taking longer than threshold (0 = 500ms)
+func New(threshold time.Duration, logger *zap.Logger) *Log {
+	if threshold <= 0 {
+		threshold = defaultThreshold
+	}
+	return &Log{
+		threshold: threshold,
+		logger:    logger,
+		stats:     make(map[statKey]*Stat),
+	}
+}
+
+// FromConfig creates the Log configured by cfg, or returns nil when it is disabled
+func FromConfig(cfg config.SlowQueryConfig, logger *zap.Logger) *Log {
+	if !cfg.Enabled {
+		return nil
+	}
+	l := New(time.Duration(cfg.ThresholdMs)*time.Millisecond, logger)
+	budgets := make(map[string]time.Duration, len(cfg.EndpointBudgets))
+	for route, ms := range cfg.EndpointBudgets {
+		budgets[route] = time.Duration(ms) * time.Millisecond
+	}
+	l.SetEndpointBudgets(time.Duration(cfg.EndpointBudgetMs)*time.Millisecond, budgets)
+	return l
+}
+
+// SetEndpointBudgets sets the latency budgets of HTTP endpoints: budgets by "METHOD /route",
+// and defaultBudget for other routes (0 = none). Call it before the log is used.
+func (l *Log) SetEndpointBudgets(defaultBudget time.Duration, budgets map[string]time.Duration) {
+	l.defaultBudget = defaultBudget
+	l.budgets = budgets
+}
+
+// EndpointBudget returns the latency budget of a route, 0 when it has none
+func (l *Log) EndpointBudget(method, route string) time.Duration {
+	if l == nil {
+		return 0
+	}
+	if budget, ok := l.budgets[method+" "+route]; ok {
+		return budget
+	}
+	return l.defaultBudget
+}
+
+// Threshold returns the duration above which operations are logged
+func (l *Log) Threshold() time.Duration {
+	if l == nil {
+		return 0
+	}
+	return l.threshold
+}
+
+// Observe records an operation that took elapsed and logs it with its sanitized params
+// when it exceeded the threshold
+func (l *Log) Observe(ctx context.Context, kind, operation string, params map[string]any, elapsed time.Duration) {
+	if l == nil {
+		return
+	}
+	l.ObserveBudget(ctx, kind, operation, params, elapsed, l.threshold)
+}
+
+// ObserveBudget is Observe with an operation-specific threshold, such as the latency budget
+// of an endpoint. A zero budget only counts the call.
+func (l *Log) ObserveBudget(ctx context.Context, kind, operation string, params map[string]any, elapsed, budget time.Duration) {
+	if l == nil {
+		return
+	}
+	slow := budget > 0 && elapsed > budget
+	ms := float64(elapsed) / float64(time.Millisecond)
+
+	l.mu.Lock()
+	key := statKey{kind, operation}
+	stat, ok := l.stats[key]
+	if !ok {
+		if len(l.stats) >= maxOperations {
+			key.operation = otherOperation
+			stat = l.stats[key]
+		}
+		if stat == nil {
+			stat = &Stat{Kind: kind, Operation: key.operation}
+			l.stats[key] = stat
+		}
+	}
+	stat.Count++
+	stat.TotalMs += ms
+	stat.MaxMs = max(stat.MaxMs, ms)
+	if slow {
+		stat.Slow++
+	}
+	l.mu.Unlock()
+
+	if slow {
+		logging.FromContext(ctx, l.logger).Warn("Slow operation",
+			zap.String("kind", kind),
+			zap.String("operation", operation),
+			zap.Duration("duration", elapsed),
+			zap.Duration("threshold", budget),
+			zap.Any("params", Sanitize(params)))
+	}
+}
+
+// Snapshot returns the aggregated stats, operations with the most slow calls first
+func (l *Log) Snapshot() []Stat {
+	if l == nil {
+		return []Stat{}
+	}
+	l.mu.Lock()
+	stats := make([]Stat, 0, len(l.stats))
+	for _, stat := range l.stats {
+		stats = append(stats, *stat)
+	}
+	l.mu.Unlock()
+
+	sort.Slice(stats, func(i, j int) bool {
+		a, b := stats[i], stats[j]
+		if a.Slow != b.Slow {
+			return a.Slow > b.Slow
+		}
+		if a.TotalMs != b.TotalMs {
+			return a.TotalMs > b.TotalMs
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Operation < b.Operation
+	})
+	return stats
+}
+
+// QueryLabel returns a query's text with whitespace collapsed and cut to a bounded length, to
+// name it in logs and stats
+func QueryLabel(query string) string {
+	label := strings.Join(strings.Fields(query), " ")
+	if len(label) > maxLabelLength {
+		label = label[:maxLabelLength] + truncatedMarker
+	}
+	return label
+}
+
+// Sanitize returns a copy of params that is safe and small enough to log: credentials are
+// redacted, long strings are cut and long lists (such as embedding vectors) are summarized
+func Sanitize(params map[string]any) map[string]any {
+	if params == nil {
+		return nil
+	}
+	return sanitizeMap(params, 0)
+}
+
+func sanitizeMap(params map[string]any, depth int) map[string]any {
+	out := make(map[string]any, len(params))
+	for name, value := range params {
+		if isSensitive(name) {
+			out[name] = redactedParam
+			continue
+		}
+		out[name] = sanitizeValue(value, depth+1)
+	}
+	return out
+}
+
+func sanitizeValue(value any, depth int) any {
+	switch v := value.(type) {
+	case nil, bool, int, int32, int64, float32, float64, time.Time, time.Duration:
+		return v
+	case string:
+		if len(v) > maxStringParam {
+			return fmt.Sprintf("%s%s (%d chars)", v[:maxStringParam], truncatedMarker, len(v))
+		}
+		return v
+	case map[string]any:
+		if depth >= sanitizeDepth {
+			return fmt.Sprintf("{%d keys}", len(v))
+		}
+		return sanitizeMap(v, depth)
+	}
+
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return sanitizeValue(fmt.Sprintf("%v", value), depth)
+	}
+	if rv.Len() > maxSliceParam || depth >= sanitizeDepth {
+		return fmt.Sprintf("[%d items]", rv.Len())
+	}
+	items := make([]any, rv.Len())
+	for i := range items {
+		items[i] = sanitizeValue(rv.Index(i).Interface(), depth+1)
+	}
+	return items
+}
+
+func isSensitive(name string) bool {
+	lower := strings.ToLower(name)
+	for _, s := range sensitiveParams {
+		if strings.Contains(lower, s) {
+			return true
+		}
+	}
+	return false
+}
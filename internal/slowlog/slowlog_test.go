@@ -0,0 +1,86 @@
+package slowlog
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestSanitize(t *testing.T) {
+	params := map[string]any{
+		"repo":     "bot-go",
+		"password": "hunter2",
+		"apiKey":   "abc",
+		"vector":   make([]float32, 768),
+		"ids":      []int64{1, 2, 3},
+		"source":   strings.Repeat("x", 500),
+		"filter":   map[string]any{"authToken": "t", "language": "go"},
+	}
+	got := Sanitize(params)
+
+	if got["repo"] != "bot-go" {
+		t.Errorf("repo = %v", got["repo"])
+	}
+	for _, name := range []string{"password", "apiKey"} {
+		if got[name] != redactedParam {
+			t.Errorf("%s = %v, want redacted", name, got[name])
+		}
+	}
+	if got["vector"] != "[768 items]" {
+		t.Errorf("vector = %v", got["vector"])
+	}
+	if ids, ok := got["ids"].([]any); !ok || len(ids) != 3 {
+		t.Errorf("ids = %v", got["ids"])
+	}
+	if s, _ := got["source"].(string); !strings.HasSuffix(s, "... (500 chars)") {
+		t.Errorf("source = %q", s)
+	}
+	filter, _ := got["filter"].(map[string]any)
+	if filter["authToken"] != redactedParam || filter["language"] != "go" {
+		t.Errorf("filter = %v", filter)
+	}
+	if params["password"] != "hunter2" {
+		t.Error("Sanitize modified its input")
+	}
+}
+
+func TestLogObserve(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	l := New(100*time.Millisecond, zap.New(core))
+	ctx := context.Background()
+
+	query := QueryLabel("MATCH (n)\n\t\tWHERE n.id = $id\n\t\tRETURN n")
+	l.Observe(ctx, KindNeo4j, query, map[string]any{"id": 1}, 50*time.Millisecond)
+	l.Observe(ctx, KindNeo4j, query, map[string]any{"id": 2}, 300*time.Millisecond)
+	l.Observe(ctx, KindEmbedding, "nomic", nil, 20*time.Millisecond)
+
+	stats := l.Snapshot()
+	if len(stats) != 2 {
+		t.Fatalf("got %d stats, want 2", len(stats))
+	}
+	s := stats[0]
+	if s.Operation != "MATCH (n) WHERE n.id = $id RETURN n" || s.Count != 2 || s.Slow != 1 || s.MaxMs != 300 || s.TotalMs != 350 {
+		t.Errorf("unexpected stat %+v", s)
+	}
+	if logs.Len() != 1 {
+		t.Fatalf("got %d slow logs, want 1", logs.Len())
+	}
+
+	var nilLog *Log
+	nilLog.Observe(ctx, KindNeo4j, query, nil, time.Second)
+	if len(nilLog.Snapshot()) != 0 || nilLog.EndpointBudget("GET", "/x") != 0 {
+		t.Error("nil log recorded an operation")
+	}
+
+	l.SetEndpointBudgets(time.Second, map[string]time.Duration{"POST /codeapi/v1/impact": 5 * time.Second})
+	if got := l.EndpointBudget("POST", "/codeapi/v1/impact"); got != 5*time.Second {
+		t.Errorf("impact budget = %v", got)
+	}
+	if got := l.EndpointBudget("GET", "/api/v1/health"); got != time.Second {
+		t.Errorf("default budget = %v", got)
+	}
+}
@@ -0,0 +1,319 @@
+package storage
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"bot-go/internal/config"
+)
+
+const (
+	defaultGCSEndpoint = "https://storage.googleapis.com"
+	gcsMetadataToken   = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+	gcsScope           = "https://www.googleapis.com/auth/devstorage.read_write"
+)
+
+// GCS stores objects in a Google Cloud Storage bucket through its JSON API, authenticated
+// with a service account key or, on Google Cloud, the instance's service account
+type GCS struct {
+	client   *http.Client
+	bucket   string
+	prefix   string
+	endpoint string
+	tokens   *gcsTokenSource
+}
+
+// NewGCS returns the store of a GCS bucket. The service account key is read from
+// cfg.CredentialsFile or $GOOGLE_APPLICATION_CREDENTIALS; without one, tokens come from the
+// metadata server.
+func NewGCS(cfg config.StorageConfig) (*GCS, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("storage.bucket is not set")
+	}
+	client := &http.Client{}
+	g := &GCS{
+		client:   client,
+		bucket:   cfg.Bucket,
+		prefix:   cfg.Prefix,
+		endpoint: strings.TrimRight(firstNonEmpty(cfg.Endpoint, defaultGCSEndpoint), "/"),
+		tokens:   &gcsTokenSource{client: client, metadataURL: gcsMetadataToken},
+	}
+	if file := firstNonEmpty(cfg.CredentialsFile, os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")); file != "" {
+		key, err := readServiceAccountKey(file)
+		if err != nil {
+			return nil, err
+		}
+		g.tokens.key = key
+	}
+	return g, nil
+}
+
+func (g *GCS) do(ctx context.Context, method, u string, body io.Reader, size int64) (*http.Response, error) {
+	token, err := g.tokens.token(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get a GCS access token: %w", err)
+	}
+	if body != nil && size == 0 {
+		body = http.NoBody // a zero length with a body would be sent chunked
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	return g.client.Do(req)
+}
+
+func (g *GCS) objectURL(key string) string {
+	return g.endpoint + "/storage/v1/b/" + url.PathEscape(g.bucket) + "/o/" + url.PathEscape(g.prefix+key)
+}
+
+// Put uploads the object in a single media upload
+func (g *GCS) Put(ctx context.Context, key string, write func(io.Writer) error) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	file, size, err := spool(write)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	u := g.endpoint + "/upload/storage/v1/b/" + url.PathEscape(g.bucket) + "/o?" +
+		url.Values{"uploadType": {"media"}, "name": {g.prefix + key}}.Encode()
+	resp, err := g.do(ctx, http.MethodPost, u, file, size)
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", g.Location(key), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError("upload", g.Location(key), resp)
+	}
+	return nil
+}
+
+func (g *GCS) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+	resp, err := g.do(ctx, http.MethodGet, g.objectURL(key)+"?alt=media", nil, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", g.Location(key), err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %w", g.Location(key), fs.ErrNotExist)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, responseError("download", g.Location(key), resp)
+	}
+	return resp.Body, nil
+}
+
+func (g *GCS) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	token := ""
+	for {
+		query := url.Values{"prefix": {g.prefix + prefix}, "fields": {"items(name),nextPageToken"}}
+		if token != "" {
+			query.Set("pageToken", token)
+		}
+		page, err := g.listPage(ctx, query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", g.Location(prefix), err)
+		}
+		for _, item := range page.Items {
+			keys = append(keys, strings.TrimPrefix(item.Name, g.prefix))
+		}
+		if page.NextPageToken == "" {
+			break
+		}
+		token = page.NextPageToken
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+type gcsObjectList struct {
+	Items []struct {
+		Name string `json:"name"`
+	} `json:"items"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+func (g *GCS) listPage(ctx context.Context, query url.Values) (*gcsObjectList, error) {
+	u := g.endpoint + "/storage/v1/b/" + url.PathEscape(g.bucket) + "/o?" + query.Encode()
+	resp, err := g.do(ctx, http.MethodGet, u, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError("list", "gs://"+g.bucket, resp)
+	}
+	var page gcsObjectList
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, fmt.Errorf("unexpected GCS response: %w", err)
+	}
+	return &page, nil
+}
+
+func (g *GCS) Delete(ctx context.Context, key string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	resp, err := g.do(ctx, http.MethodDelete, g.objectURL(key), nil, 0)
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", g.Location(key), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return responseError("delete", g.Location(key), resp)
+	}
+	return nil
+}
+
+func (g *GCS) Location(key string) string {
+	return "gs://" + g.bucket + "/" + g.prefix + key
+}
+
+// serviceAccountKey holds the fields of a service account key file used to get tokens
+type serviceAccountKey struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+
+	signer *rsa.PrivateKey
+}
+
+func readServiceAccountKey(file string) (*serviceAccountKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GCS credentials: %w", err)
+	}
+	var key serviceAccountKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, fmt.Errorf("failed to parse GCS credentials %s: %w", file, err)
+	}
+	if key.ClientEmail == "" || key.TokenURI == "" {
+		return nil, fmt.Errorf("GCS credentials %s are not a service account key", file)
+	}
+	block, _ := pem.Decode([]byte(key.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("GCS credentials %s have no private key", file)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the private key of %s: %w", file, err)
+	}
+	signer, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("the private key of %s is not an RSA key", file)
+	}
+	key.signer = signer
+	return &key, nil
+}
+
+// gcsTokenSource caches an OAuth access token until shortly before it expires
+type gcsTokenSource struct {
+	client      *http.Client
+	key         *serviceAccountKey // nil: ask the metadata server
+	metadataURL string
+
+	mu      sync.Mutex
+	current string
+	expiry  time.Time
+}
+
+func (s *gcsTokenSource) token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current != "" && time.Until(s.expiry) > time.Minute {
+		return s.current, nil
+	}
+	var req *http.Request
+	var err error
+	if s.key != nil {
+		req, err = s.key.tokenRequest(ctx, time.Now())
+	} else {
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, s.metadataURL, nil)
+		if req != nil {
+			req.Header.Set("Metadata-Flavor", "Google")
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", responseError("get a token from", req.URL.Host, resp)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil || token.AccessToken == "" {
+		return "", fmt.Errorf("unexpected token response from %s", req.URL.Host)
+	}
+	s.current = token.AccessToken
+	s.expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	return s.current, nil
+}
+
+// tokenRequest exchanges a JWT signed with the key for an access token (RFC 7523)
+func (k *serviceAccountKey) tokenRequest(ctx context.Context, now time.Time) (*http.Request, error) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]any{
+		"iss":   k.ClientEmail,
+		"scope": gcsScope,
+		"aud":   k.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, k.signer, crypto.SHA256, digest[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign token request: %w", err)
+	}
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, k.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req, nil
+}
@@ -0,0 +1,242 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"bot-go/internal/awsauth"
+	"bot-go/internal/config"
+)
+
+// emptyPayloadHash is the SHA-256 of an empty request body
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3 stores objects in an S3 bucket, or a bucket of an S3-compatible service such as MinIO,
+// with the credentials in AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN
+type S3 struct {
+	client   *http.Client
+	bucket   string
+	prefix   string
+	region   string
+	endpoint *url.URL // of path-style requests; nil for the bucket's AWS virtual host
+	creds    awsauth.Credentials
+}
+
+// NewS3 returns the store of an S3 bucket
+func NewS3(cfg config.StorageConfig) (*S3, error) {
+	s := &S3{
+		client: &http.Client{},
+		bucket: cfg.Bucket,
+		prefix: cfg.Prefix,
+		region: firstNonEmpty(cfg.Region, os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION")),
+		creds:  awsauth.CredentialsFromEnv(),
+	}
+	if s.bucket == "" {
+		return nil, fmt.Errorf("storage.bucket is not set")
+	}
+	if !s.creds.Valid() {
+		return nil, fmt.Errorf("AWS credentials are not set (AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY)")
+	}
+	if cfg.Endpoint != "" {
+		endpoint, err := url.Parse(strings.TrimRight(cfg.Endpoint, "/"))
+		if err != nil || endpoint.Host == "" {
+			return nil, fmt.Errorf("invalid storage.endpoint %q", cfg.Endpoint)
+		}
+		s.endpoint = endpoint
+		if s.region == "" {
+			s.region = "us-east-1" // what S3-compatible services expect in signatures
+		}
+	}
+	if s.region == "" {
+		return nil, fmt.Errorf("AWS region is not set (storage.region or AWS_REGION)")
+	}
+	return s, nil
+}
+
+// objectURL returns the URL of a key ("" for the bucket) with a canonical query string
+func (s *S3) objectURL(key string, query url.Values) *url.URL {
+	u := &url.URL{Scheme: "https", Host: s.bucket + ".s3." + s.region + ".amazonaws.com", Path: "/" + key}
+	if s.endpoint != nil {
+		u = &url.URL{Scheme: s.endpoint.Scheme, Host: s.endpoint.Host, Path: s.endpoint.Path + "/" + s.bucket + "/" + key}
+	}
+	u.RawPath = awsEscape(u.Path, false)
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	params := make([]string, len(names))
+	for i, name := range names {
+		params[i] = awsEscape(name, true) + "=" + awsEscape(query.Get(name), true)
+	}
+	u.RawQuery = strings.Join(params, "&")
+	return u
+}
+
+// awsEscape percent-encodes everything but unreserved characters, and slashes unless
+// escapeSlash is set, as Signature Version 4 expects
+func awsEscape(s string, escapeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' && !escapeSlash {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func (s *S3) do(ctx context.Context, method string, u *url.URL, body io.Reader, size int64, payloadHash string) (*http.Response, error) {
+	if body != nil && size == 0 {
+		body = http.NoBody // a zero length with a body would be sent chunked
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.URL = u // keep the escaping that was signed
+	req.ContentLength = size
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	awsauth.Sign(req, payloadHash, s.creds, s.region, "s3", time.Now())
+	return s.client.Do(req)
+}
+
+// Put uploads the object in a single request, which S3 limits to 5 GB
+func (s *S3) Put(ctx context.Context, key string, write func(io.Writer) error) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	file, size, err := spool(write)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return fmt.Errorf("failed to buffer upload: %w", err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to buffer upload: %w", err)
+	}
+
+	resp, err := s.do(ctx, http.MethodPut, s.objectURL(s.prefix+key, nil), file, size, hex.EncodeToString(hash.Sum(nil)))
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", s.Location(key), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError("upload", s.Location(key), resp)
+	}
+	return nil
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+	resp, err := s.do(ctx, http.MethodGet, s.objectURL(s.prefix+key, nil), nil, 0, emptyPayloadHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", s.Location(key), err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %w", s.Location(key), fs.ErrNotExist)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, responseError("download", s.Location(key), resp)
+	}
+	return resp.Body, nil
+}
+
+// List pages through ListObjectsV2
+func (s *S3) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {s.prefix + prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		page, err := s.listPage(ctx, query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", s.Location(prefix), err)
+		}
+		for _, object := range page.Contents {
+			keys = append(keys, strings.TrimPrefix(object.Key, s.prefix))
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			break
+		}
+		token = page.NextContinuationToken
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *S3) listPage(ctx context.Context, query url.Values) (*listBucketResult, error) {
+	resp, err := s.do(ctx, http.MethodGet, s.objectURL("", query), nil, 0, emptyPayloadHash)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError("list", "s3://"+s.bucket, resp)
+	}
+	var page listBucketResult
+	if err := xml.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, fmt.Errorf("unexpected S3 response: %w", err)
+	}
+	return &page, nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	resp, err := s.do(ctx, http.MethodDelete, s.objectURL(s.prefix+key, nil), nil, 0, emptyPayloadHash)
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", s.Location(key), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return responseError("delete", s.Location(key), resp)
+	}
+	return nil
+}
+
+func (s *S3) Location(key string) string {
+	return "s3://" + s.bucket + "/" + s.prefix + key
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
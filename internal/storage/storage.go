@@ -0,0 +1,123 @@
+// Package storage keeps the files the server produces for later use (graph dumps, profile
+// dumps, cold storage archives) in a local directory, an S3 bucket or a GCS bucket, as
+// configured by the storage section of app.yaml. Objects are named by slash-separated keys
+// such as cold_storage/shop/manifest.json.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"bot-go/internal/apperrors"
+	"bot-go/internal/config"
+)
+
+// Backends
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+	BackendGCS   = "gcs"
+)
+
+// Store keeps objects by key. Errors for missing objects wrap fs.ErrNotExist.
+type Store interface {
+	// Put stores what write writes under key, replacing any earlier object. The object
+	// appears once write returns nil, and not at all when it fails.
+	Put(ctx context.Context, key string, write func(io.Writer) error) error
+	// Get opens an object for reading
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// List returns the keys starting with prefix, sorted
+	List(ctx context.Context, prefix string) ([]string, error)
+	// Delete removes an object; removing a missing object is not an error
+	Delete(ctx context.Context, key string) error
+	// Location describes where a key is stored, e.g. s3://bucket/key, for logs and responses
+	Location(key string) string
+}
+
+// New returns the store configured by cfg. The local backend stores under workDir unless
+// cfg.Dir is set; without either, New returns nil and features storing files are disabled.
+func New(cfg config.StorageConfig, workDir string) (Store, error) {
+	switch cfg.Backend {
+	case "", BackendLocal:
+		dir := cfg.Dir
+		if dir == "" {
+			dir = workDir
+		}
+		if dir == "" {
+			return nil, nil
+		}
+		return NewLocal(dir), nil
+	case BackendS3:
+		return NewS3(cfg)
+	case BackendGCS:
+		return NewGCS(cfg)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Backend)
+	}
+}
+
+// DeletePrefix removes every object whose key starts with prefix
+func DeletePrefix(ctx context.Context, store Store, prefix string) error {
+	keys, err := store.List(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := store.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkKey rejects keys that could escape the store: absolute, empty or with . or .. segments
+func checkKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, `\`) {
+		return fmt.Errorf("%w: storage key %q", apperrors.ErrInvalidArgument, key)
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return fmt.Errorf("%w: storage key %q", apperrors.ErrInvalidArgument, key)
+		}
+	}
+	return nil
+}
+
+// spool writes an object to a temporary file and rewinds it, so that remote backends can
+// send its length and checksum before its content. The caller removes the file.
+func spool(write func(io.Writer) error) (*os.File, int64, error) {
+	file, err := os.CreateTemp("", "bot-go-upload-*")
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to buffer upload: %w", err)
+	}
+	fail := func(err error) (*os.File, int64, error) {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, 0, err
+	}
+	if err := write(file); err != nil {
+		return fail(err)
+	}
+	size, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fail(fmt.Errorf("failed to buffer upload: %w", err))
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return fail(fmt.Errorf("failed to buffer upload: %w", err))
+	}
+	return file, size, nil
+}
+
+// responseError describes a failed request to a remote backend
+func responseError(op, location string, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	detail := strings.TrimSpace(string(body))
+	if detail == "" {
+		return fmt.Errorf("failed to %s %s: %s", op, location, resp.Status)
+	}
+	return fmt.Errorf("failed to %s %s: %s: %s", op, location, resp.Status, detail)
+}
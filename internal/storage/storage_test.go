@@ -0,0 +1,269 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"bot-go/internal/config"
+)
+
+// exerciseStore puts, lists, reads and deletes objects, including a failed put
+func exerciseStore(t *testing.T, store Store) {
+	t.Helper()
+	ctx := context.Background()
+	put := func(key, content string) {
+		t.Helper()
+		if err := store.Put(ctx, key, func(w io.Writer) error {
+			_, err := io.WriteString(w, content)
+			return err
+		}); err != nil {
+			t.Fatalf("Put(%s) error = %v", key, err)
+		}
+	}
+	put("dumps/a b.txt", "first")
+	put("dumps/nested/c.txt", "second")
+	put("profiles/heap.pprof", "")
+
+	failure := errors.New("export failed")
+	if err := store.Put(ctx, "dumps/failed.txt", func(w io.Writer) error { return failure }); !errors.Is(err, failure) {
+		t.Errorf("failed Put error = %v", err)
+	}
+	if err := store.Put(ctx, "../escape", func(w io.Writer) error { return nil }); err == nil {
+		t.Error("expected an error for a key leaving the store")
+	}
+
+	keys, err := store.List(ctx, "dumps/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"dumps/a b.txt", "dumps/nested/c.txt"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("List(dumps/) = %v, want %v", keys, want)
+	}
+
+	r, err := store.Get(ctx, "dumps/a b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "first" {
+		t.Errorf("Get() = %q, want first", data)
+	}
+	if _, err := store.Get(ctx, "dumps/missing.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Get(missing) error = %v, want fs.ErrNotExist", err)
+	}
+
+	if err := DeletePrefix(ctx, store, "dumps/"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(ctx, "dumps/missing.txt"); err != nil {
+		t.Errorf("Delete(missing) error = %v", err)
+	}
+	keys, err = store.List(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"profiles/heap.pprof"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("List() after delete = %v, want %v", keys, want)
+	}
+}
+
+func TestLocal(t *testing.T) {
+	root := t.TempDir()
+	exerciseStore(t, NewLocal(root))
+	if _, err := os.Stat(filepath.Join(root, "dumps")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected the emptied dumps directory to be removed, stat error = %v", err)
+	}
+}
+
+// fakeBucket serves objects from memory, checking each request with authorized
+type fakeBucket struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (b *fakeBucket) sorted(prefix string) []string {
+	var keys []string
+	for key := range b.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// page returns the keys after the token, two at a time so that listing pages
+func page(keys []string, token string) ([]string, string) {
+	start := sort.SearchStrings(keys, token)
+	if token == "" {
+		start = 0
+	}
+	end := min(start+2, len(keys))
+	next := ""
+	if end < len(keys) {
+		next = keys[end]
+	}
+	return keys[start:end], next
+}
+
+func TestS3(t *testing.T) {
+	bucket := &fakeBucket{objects: map[string][]byte{}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") || r.Header.Get("X-Amz-Content-Sha256") == "" {
+			http.Error(w, "unsigned", http.StatusForbidden)
+			return
+		}
+		key, ok := strings.CutPrefix(r.URL.Path, "/bucket/")
+		if !ok && r.URL.Path != "/bucket/" {
+			http.Error(w, "no such bucket", http.StatusNotFound)
+			return
+		}
+		bucket.mu.Lock()
+		defer bucket.mu.Unlock()
+		switch {
+		case r.Method == http.MethodGet && key == "":
+			keys, next := page(bucket.sorted(r.URL.Query().Get("prefix")), r.URL.Query().Get("continuation-token"))
+			var result listBucketResult
+			for _, key := range keys {
+				result.Contents = append(result.Contents, struct {
+					Key string `xml:"Key"`
+				}{key})
+			}
+			result.IsTruncated, result.NextContinuationToken = next != "", next
+			xml.NewEncoder(w).Encode(result)
+		case r.Method == http.MethodPut:
+			bucket.objects[key], _ = io.ReadAll(r.Body)
+		case r.Method == http.MethodGet:
+			data, ok := bucket.objects[key]
+			if !ok {
+				http.Error(w, "NoSuchKey", http.StatusNotFound)
+				return
+			}
+			w.Write(data)
+		case r.Method == http.MethodDelete:
+			delete(bucket.objects, key)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	store, err := New(config.StorageConfig{Backend: BackendS3, Bucket: "bucket", Prefix: "prod/", Endpoint: server.URL}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exerciseStore(t, store)
+	if _, ok := bucket.objects["prod/profiles/heap.pprof"]; !ok {
+		t.Errorf("expected keys under the prefix, got %v", bucket.sorted(""))
+	}
+}
+
+func TestGCS(t *testing.T) {
+	signer, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bucket := &fakeBucket{objects: map[string][]byte{}}
+	tokens := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" || strings.Count(r.FormValue("assertion"), ".") != 2 {
+			http.Error(w, "bad grant", http.StatusBadRequest)
+			return
+		}
+		tokens++
+		fmt.Fprint(w, `{"access_token":"gcs-token","expires_in":3600}`)
+	})
+	authorized := func(handler http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer gcs-token" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			bucket.mu.Lock()
+			defer bucket.mu.Unlock()
+			handler(w, r)
+		}
+	}
+	mux.HandleFunc("POST /upload/storage/v1/b/bucket/o", authorized(func(w http.ResponseWriter, r *http.Request) {
+		bucket.objects[r.URL.Query().Get("name")], _ = io.ReadAll(r.Body)
+		fmt.Fprint(w, `{}`)
+	}))
+	mux.HandleFunc("GET /storage/v1/b/bucket/o", authorized(func(w http.ResponseWriter, r *http.Request) {
+		keys, next := page(bucket.sorted(r.URL.Query().Get("prefix")), r.URL.Query().Get("pageToken"))
+		var list gcsObjectList
+		for _, key := range keys {
+			list.Items = append(list.Items, struct {
+				Name string `json:"name"`
+			}{key})
+		}
+		list.NextPageToken = next
+		json.NewEncoder(w).Encode(list)
+	}))
+	mux.HandleFunc("/storage/v1/b/bucket/o/{key...}", authorized(func(w http.ResponseWriter, r *http.Request) {
+		key := r.PathValue("key")
+		switch r.Method {
+		case http.MethodGet:
+			data, ok := bucket.objects[key]
+			if !ok || r.URL.Query().Get("alt") != "media" {
+				http.Error(w, "not found", http.StatusNotFound)
+				return
+			}
+			w.Write(data)
+		case http.MethodDelete:
+			if _, ok := bucket.objects[key]; !ok {
+				http.Error(w, "not found", http.StatusNotFound)
+				return
+			}
+			delete(bucket.objects, key)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	keyFile := filepath.Join(t.TempDir(), "key.json")
+	credentials, _ := json.Marshal(map[string]string{
+		"type":         "service_account",
+		"client_email": "bot@project.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":    server.URL + "/token",
+	})
+	if err := os.WriteFile(keyFile, credentials, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := New(config.StorageConfig{Backend: BackendGCS, Bucket: "bucket", Endpoint: server.URL, CredentialsFile: keyFile}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exerciseStore(t, store)
+	if tokens != 1 {
+		t.Errorf("fetched %d tokens, want 1 cached token", tokens)
+	}
+}
@@ -0,0 +1,428 @@
+// Package techstack profiles the technology stack of a repository: how much of it is
+// written in each language, and which frameworks and build systems it uses, detected from
+// manifest files (go.mod, package.json, pom.xml, ...) and the imports of source files.
+package techstack
+
+import (
+	"bot-go/internal/util"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Kinds of detected technologies
+const (
+	KindFramework   = "framework"
+	KindBuildSystem = "build_system"
+)
+
+// maxFileBytes bounds how much of a file is read; larger files are counted by size only
+const maxFileBytes = 2 << 20
+
+// maxEvidence bounds the example files recorded per detected technology
+const maxEvidence = 5
+
+// Profile is the technology stack of a repository
+type Profile struct {
+	Repo       string         `json:"repo"`
+	AnalyzedAt time.Time      `json:"analyzed_at"`
+	Languages  []LanguageStat `json:"languages"`  // most lines first
+	Frameworks []Framework    `json:"frameworks"` // frameworks and build systems, by name
+}
+
+// LanguageStat counts the source files of one language. Generated and minified files are
+// not counted.
+type LanguageStat struct {
+	Language  string `json:"language"`
+	Files     int    `json:"files"`
+	TestFiles int    `json:"test_files"`
+	Lines     int    `json:"lines"`
+	Bytes     int64  `json:"bytes"`
+}
+
+// Framework is a framework or build system found in the repository
+type Framework struct {
+	Name     string `json:"name"`
+	Kind     string `json:"kind"` // KindFramework or KindBuildSystem
+	Language string `json:"language"`
+	// Manifests are the files declaring it (e.g. go.mod, package.json), at most maxEvidence
+	Manifests []string `json:"manifests,omitempty"`
+	// ImportingFiles counts the source files importing it; Importers lists some of them
+	ImportingFiles int      `json:"importing_files,omitempty"`
+	Importers      []string `json:"importers,omitempty"`
+}
+
+// rule detects one technology. It is found when a manifest file matches, or when a source
+// file of its language imports it.
+type rule struct {
+	name, kind, language string
+	// manifests match file base names to a pattern their content must match; a nil pattern
+	// matches on the presence of the file
+	manifests map[string]*regexp.Regexp
+	imports   *regexp.Regexp
+}
+
+var rules = []rule{
+	{
+		name: "Gin", kind: KindFramework, language: "go",
+		manifests: map[string]*regexp.Regexp{"go.mod": regexp.MustCompile(`github\.com/gin-gonic/gin\s`)},
+		imports:   regexp.MustCompile(`"github\.com/gin-gonic/gin"`),
+	},
+	{
+		name: "Echo", kind: KindFramework, language: "go",
+		manifests: map[string]*regexp.Regexp{"go.mod": regexp.MustCompile(`github\.com/labstack/echo`)},
+		imports:   regexp.MustCompile(`"github\.com/labstack/echo(/v\d+)?"`),
+	},
+	{
+		name: "React", kind: KindFramework, language: "javascript",
+		manifests: map[string]*regexp.Regexp{"package.json": regexp.MustCompile(`"react"\s*:`)},
+		imports:   jsImport("react"),
+	},
+	{
+		name: "Vue", kind: KindFramework, language: "javascript",
+		manifests: map[string]*regexp.Regexp{"package.json": regexp.MustCompile(`"vue"\s*:`)},
+		imports:   jsImport("vue"),
+	},
+	{
+		name: "Express", kind: KindFramework, language: "javascript",
+		manifests: map[string]*regexp.Regexp{"package.json": regexp.MustCompile(`"express"\s*:`)},
+		imports:   jsImport("express"),
+	},
+	{
+		name: "Django", kind: KindFramework, language: "python",
+		manifests: pythonManifests("django"),
+		imports:   pythonImport("django"),
+	},
+	{
+		name: "Flask", kind: KindFramework, language: "python",
+		manifests: pythonManifests("flask"),
+		imports:   pythonImport("flask"),
+	},
+	{
+		name: "FastAPI", kind: KindFramework, language: "python",
+		manifests: pythonManifests("fastapi"),
+		imports:   pythonImport("fastapi"),
+	},
+	{
+		name: "Spring Boot", kind: KindFramework, language: "java",
+		manifests: map[string]*regexp.Regexp{
+			"pom.xml":          regexp.MustCompile(`spring-boot`),
+			"build.gradle":     regexp.MustCompile(`org\.springframework\.boot`),
+			"build.gradle.kts": regexp.MustCompile(`org\.springframework\.boot`),
+		},
+		imports: regexp.MustCompile(`(?m)^import\s+org\.springframework\.boot\.`),
+	},
+	{
+		name: "Maven", kind: KindBuildSystem, language: "java",
+		manifests: map[string]*regexp.Regexp{"pom.xml": nil},
+	},
+	{
+		name: "Gradle", kind: KindBuildSystem, language: "java",
+		manifests: map[string]*regexp.Regexp{"build.gradle": nil, "build.gradle.kts": nil, "settings.gradle": nil, "settings.gradle.kts": nil},
+	},
+	{
+		name: "Go modules", kind: KindBuildSystem, language: "go",
+		manifests: map[string]*regexp.Regexp{"go.mod": nil},
+	},
+	{
+		name: "npm", kind: KindBuildSystem, language: "javascript",
+		manifests: map[string]*regexp.Regexp{"package.json": nil},
+	},
+	{
+		name: "Poetry", kind: KindBuildSystem, language: "python",
+		manifests: map[string]*regexp.Regexp{"pyproject.toml": regexp.MustCompile(`\[tool\.poetry\]`)},
+	},
+	{
+		name: "pip", kind: KindBuildSystem, language: "python",
+		manifests: map[string]*regexp.Regexp{"requirements.txt": nil},
+	},
+}
+
+// jsImport matches ES module imports and require calls of a package or its subpaths
+func jsImport(pkg string) *regexp.Regexp {
+	q := regexp.QuoteMeta(pkg)
+	return regexp.MustCompile(`(?:from\s+|import\s+|require\(\s*)['"]` + q + `(?:/[^'"]*)?['"]`)
+}
+
+// pythonImport matches import statements of a package or its submodules
+func pythonImport(pkg string) *regexp.Regexp {
+	return regexp.MustCompile(`(?m)^\s*(?:from|import)\s+` + regexp.QuoteMeta(pkg) + `\b`)
+}
+
+// pythonManifests matches the package in the dependency files of Python projects
+func pythonManifests(pkg string) map[string]*regexp.Regexp {
+	requirement := regexp.MustCompile(`(?im)^\s*` + regexp.QuoteMeta(pkg) + `\b`)
+	quoted := regexp.MustCompile(`(?i)["']` + regexp.QuoteMeta(pkg) + `\b`)
+	return map[string]*regexp.Regexp{
+		"requirements.txt": requirement,
+		"Pipfile":          requirement,
+		"pyproject.toml":   regexp.MustCompile(`(?im)(?:^\s*|["'])` + regexp.QuoteMeta(pkg) + `\b`),
+		"setup.py":         quoted,
+	}
+}
+
+// languageByExt maps file extensions to the language counted for them
+var languageByExt = map[string]string{
+	".go":    "go",
+	".py":    "python",
+	".pyw":   "python",
+	".java":  "java",
+	".kt":    "kotlin",
+	".kts":   "kotlin",
+	".js":    "javascript",
+	".jsx":   "javascript",
+	".mjs":   "javascript",
+	".cjs":   "javascript",
+	".ts":    "typescript",
+	".tsx":   "typescript",
+	".rb":    "ruby",
+	".rs":    "rust",
+	".c":     "c",
+	".h":     "c",
+	".cc":    "cpp",
+	".cpp":   "cpp",
+	".hpp":   "cpp",
+	".cs":    "csharp",
+	".php":   "php",
+	".swift": "swift",
+	".scala": "scala",
+	".sh":    "shell",
+	".sql":   "sql",
+}
+
+// importLanguage is the language whose rules apply to the imports of a source language
+func importLanguage(language string) string {
+	if language == "typescript" {
+		return "javascript"
+	}
+	return language
+}
+
+// Detector accumulates the files of a repository into a Profile. It is not safe for
+// concurrent use.
+type Detector struct {
+	languages map[string]*LanguageStat
+	found     map[string]*Framework
+}
+
+// NewDetector creates an empty detector
+func NewDetector() *Detector {
+	return &Detector{
+		languages: make(map[string]*LanguageStat),
+		found:     make(map[string]*Framework),
+	}
+}
+
+// AddFile counts a file given its repository-relative path, size and content. content may
+// be nil for files too large to read, which are counted by size only.
+func (d *Detector) AddFile(relPath string, size int64, content []byte) {
+	relPath = filepath.ToSlash(relPath)
+	base := filepath.Base(relPath)
+
+	for i := range rules {
+		r := &rules[i]
+		pattern, ok := r.manifests[base]
+		if ok && (pattern == nil || (content != nil && pattern.Match(content))) {
+			fw := d.framework(r)
+			if len(fw.Manifests) < maxEvidence {
+				fw.Manifests = append(fw.Manifests, relPath)
+			}
+		}
+	}
+
+	language, ok := languageByExt[strings.ToLower(filepath.Ext(base))]
+	if !ok || (content != nil && (util.IsGeneratedFile(relPath, content) || util.IsMinified(relPath, content))) {
+		return
+	}
+	stat := d.languages[language]
+	if stat == nil {
+		stat = &LanguageStat{Language: language}
+		d.languages[language] = stat
+	}
+	stat.Files++
+	stat.Bytes += size
+	if util.IsTestFile(relPath) {
+		stat.TestFiles++
+	}
+	if content == nil {
+		return
+	}
+	stat.Lines += bytes.Count(content, []byte{'\n'})
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		stat.Lines++
+	}
+
+	for i := range rules {
+		r := &rules[i]
+		if r.imports == nil || r.language != importLanguage(language) || !r.imports.Match(content) {
+			continue
+		}
+		fw := d.framework(r)
+		fw.ImportingFiles++
+		if len(fw.Importers) < maxEvidence {
+			fw.Importers = append(fw.Importers, relPath)
+		}
+	}
+}
+
+func (d *Detector) framework(r *rule) *Framework {
+	fw := d.found[r.name]
+	if fw == nil {
+		fw = &Framework{Name: r.name, Kind: r.kind, Language: r.language}
+		d.found[r.name] = fw
+	}
+	return fw
+}
+
+// Profile returns what the detector found so far for the repository
+func (d *Detector) Profile(repo string) *Profile {
+	profile := &Profile{
+		Repo:       repo,
+		AnalyzedAt: time.Now().UTC(),
+		Languages:  make([]LanguageStat, 0, len(d.languages)),
+		Frameworks: make([]Framework, 0, len(d.found)),
+	}
+	for _, stat := range d.languages {
+		profile.Languages = append(profile.Languages, *stat)
+	}
+	sortLanguages(profile.Languages)
+	for _, fw := range d.found {
+		sort.Strings(fw.Manifests)
+		sort.Strings(fw.Importers)
+		profile.Frameworks = append(profile.Frameworks, *fw)
+	}
+	sort.Slice(profile.Frameworks, func(i, j int) bool {
+		return profile.Frameworks[i].Name < profile.Frameworks[j].Name
+	})
+	return profile
+}
+
+// MergeLanguages sums the language statistics of several profiles, most lines first
+func MergeLanguages(profiles []*Profile) []LanguageStat {
+	totals := make(map[string]*LanguageStat)
+	for _, profile := range profiles {
+		for _, stat := range profile.Languages {
+			total := totals[stat.Language]
+			if total == nil {
+				total = &LanguageStat{Language: stat.Language}
+				totals[stat.Language] = total
+			}
+			total.Files += stat.Files
+			total.TestFiles += stat.TestFiles
+			total.Lines += stat.Lines
+			total.Bytes += stat.Bytes
+		}
+	}
+	merged := make([]LanguageStat, 0, len(totals))
+	for _, total := range totals {
+		merged = append(merged, *total)
+	}
+	sortLanguages(merged)
+	return merged
+}
+
+// sortLanguages orders language statistics by lines, then name
+func sortLanguages(stats []LanguageStat) {
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Lines != stats[j].Lines {
+			return stats[i].Lines > stats[j].Lines
+		}
+		return stats[i].Language < stats[j].Language
+	})
+}
+
+// Analyze walks the repository at root and profiles its files, skipping the directories
+// index builds skip (dependencies, build output, hidden directories)
+func Analyze(ctx context.Context, repo, root string) (*Profile, error) {
+	detector := NewDetector()
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path != root && util.ShouldSkipDirectory(path) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil // removed since the directory was read
+		}
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		var content []byte
+		if info.Size() <= maxFileBytes {
+			if content, err = os.ReadFile(path); err != nil {
+				return nil
+			}
+			if util.ContentSkipReason(content) != "" {
+				return nil
+			}
+		}
+		detector.AddFile(relPath, info.Size(), content)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze %s: %w", root, err)
+	}
+	return detector.Profile(repo), nil
+}
+
+// ProfileFile returns where the stack profile of the repository is kept
+func ProfileFile(workDir, repo string) string {
+	return filepath.Join(workDir, "techstack", repo+".json")
+}
+
+// Save writes the profile to file
+func Save(file string, profile *Profile) error {
+	data, err := json.MarshalIndent(profile, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode stack profile: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return fmt.Errorf("failed to create stack profile directory: %w", err)
+	}
+	// Write then rename so readers never see a truncated profile
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write stack profile: %w", err)
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		return fmt.Errorf("failed to write stack profile: %w", err)
+	}
+	return nil
+}
+
+// Load reads the profile stored in file; it returns nil without error if there is none
+func Load(file string) (*Profile, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read stack profile: %w", err)
+	}
+	var profile Profile
+	if err := json.Unmarshal(data, &profile); err != nil {
+		return nil, fmt.Errorf("failed to parse stack profile %s: %w", file, err)
+	}
+	return &profile, nil
+}
@@ -0,0 +1,92 @@
+package techstack
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	root := t.TempDir()
+	write := func(rel, content string) {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("go.mod", "module shop\n\nrequire github.com/gin-gonic/gin v1.9.1\n")
+	write("api/server.go", "package api\n\nimport (\n\t\"github.com/gin-gonic/gin\"\n)\n")
+	write("api/server_test.go", "package api\n")
+	write("web/package.json", `{"dependencies": {"react": "^18.2.0", "react-dom": "^18.2.0"}}`)
+	write("web/src/App.tsx", "import React from 'react';\nexport const App = () => null;")
+	write("web/src/util.js", "import { dom } from 'react-dom';\n")
+	write("backend/requirements.txt", "Django==4.2\nrequests\n")
+	write("backend/app/views.py", "from django.http import HttpResponse\n")
+	write("legacy/pom.xml", "<project></project>\n")
+	write("web/node_modules/vue/package.json", `{"name": "vue"}`) // dependencies are not walked
+
+	profile, err := Analyze(context.Background(), "shop", root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := make(map[string]Framework)
+	for _, fw := range profile.Frameworks {
+		found[fw.Name] = fw
+	}
+	for _, name := range []string{"Gin", "Go modules", "React", "npm", "Django", "pip", "Maven"} {
+		if _, ok := found[name]; !ok {
+			t.Errorf("%s not detected in %+v", name, profile.Frameworks)
+		}
+	}
+	if _, ok := found["Vue"]; ok {
+		t.Error("Vue detected from node_modules")
+	}
+	if gin := found["Gin"]; gin.ImportingFiles != 1 || gin.Importers[0] != "api/server.go" || gin.Manifests[0] != "go.mod" {
+		t.Errorf("Gin = %+v", gin)
+	}
+	if react := found["React"]; react.ImportingFiles != 1 || react.Kind != KindFramework {
+		t.Errorf("React = %+v", react)
+	}
+	if maven := found["Maven"]; maven.Kind != KindBuildSystem || maven.Manifests[0] != "legacy/pom.xml" {
+		t.Errorf("Maven = %+v", maven)
+	}
+
+	languages := make(map[string]LanguageStat)
+	for _, stat := range profile.Languages {
+		languages[stat.Language] = stat
+	}
+	if goStat := languages["go"]; goStat.Files != 2 || goStat.TestFiles != 1 || goStat.Lines != 6 {
+		t.Errorf("go = %+v", goStat)
+	}
+	if profile.Languages[0].Language != "go" {
+		t.Errorf("languages not ordered by lines: %+v", profile.Languages)
+	}
+	if ts := languages["typescript"]; ts.Files != 1 || ts.Lines != 2 {
+		t.Errorf("typescript = %+v", ts)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	file := ProfileFile(t.TempDir(), "shop")
+	if profile, err := Load(file); err != nil || profile != nil {
+		t.Fatalf("Load of a missing profile = %+v, %v", profile, err)
+	}
+
+	want := NewDetector()
+	want.AddFile("pom.xml", 20, []byte("<project/>"))
+	if err := Save(file, want.Profile("shop")); err != nil {
+		t.Fatal(err)
+	}
+	profile, err := Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.Repo != "shop" || len(profile.Frameworks) != 1 || profile.Frameworks[0].Name != "Maven" {
+		t.Errorf("loaded %+v", profile)
+	}
+}
@@ -0,0 +1,82 @@
+package testenv
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// containerSpec describes a throwaway backend container
+type containerSpec struct {
+	image string
+	port  string // container port to publish on a random loopback port
+	env   []string
+}
+
+// startContainer runs spec in Docker and returns the host address of its published port.
+// The container is removed when the test finishes. The test is skipped when Docker use is
+// not enabled via BOTGO_TESTENV_DOCKER=1 or docker is not installed.
+func startContainer(t testing.TB, backend string, spec containerSpec) string {
+	t.Helper()
+	if os.Getenv(EnvDocker) != "1" {
+		t.Skipf("testenv: %s not configured; set %s or %s=1 to start it in Docker", backend, backendEnvVar(backend), EnvDocker)
+	}
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skipf("testenv: docker not found, cannot start %s", backend)
+	}
+
+	args := []string{"run", "-d", "--rm", "-p", "127.0.0.1::" + spec.port}
+	for _, e := range spec.env {
+		args = append(args, "-e", e)
+	}
+	args = append(args, spec.image)
+	id, err := docker(args...)
+	if err != nil {
+		t.Fatalf("testenv: failed to start %s container: %v", backend, err)
+	}
+	t.Cleanup(func() {
+		if _, err := docker("rm", "-f", id); err != nil {
+			t.Logf("testenv: failed to remove %s container %s: %v", backend, id, err)
+		}
+	})
+
+	mapping, err := docker("port", id, spec.port+"/tcp")
+	if err != nil {
+		t.Fatalf("testenv: failed to read %s port mapping: %v", backend, err)
+	}
+	// docker port may list several bindings (IPv4 and IPv6); the first is loopback IPv4
+	addr := strings.TrimSpace(strings.SplitN(mapping, "\n", 2)[0])
+
+	if err := waitForPort(addr, readyTimeout); err != nil {
+		logs, _ := docker("logs", "--tail", "50", id)
+		t.Fatalf("testenv: %s did not become ready: %v\n%s", backend, err, logs)
+	}
+	t.Logf("testenv: started %s (%s) on %s", backend, spec.image, addr)
+	return addr
+}
+
+func backendEnvVar(backend string) string {
+	switch backend {
+	case "neo4j":
+		return EnvNeo4jURI
+	case "mysql":
+		return EnvMySQLAddr
+	default:
+		return EnvQdrantAddr
+	}
+}
+
+// docker runs the docker CLI and returns its trimmed stdout
+func docker(args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("docker", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("docker %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
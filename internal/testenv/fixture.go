@@ -0,0 +1,88 @@
+package testenv
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// DefaultFixture is a small Go repository with a type, methods, and calls within and
+// across files, enough to exercise parsing, call graph and chunking paths
+var DefaultFixture = map[string]string{
+	"go.mod": "module example.com/fixture\n\ngo 1.23\n",
+	"main.go": `package main
+
+import "fmt"
+
+func main() {
+	store := NewStore()
+	store.Put("greeting", "hello")
+	fmt.Println(describe(store, "greeting"))
+}
+
+func describe(s *Store, key string) string {
+	value, ok := s.Get(key)
+	if !ok {
+		return "missing " + key
+	}
+	return key + "=" + value
+}
+`,
+	"store.go": `package main
+
+// Store is an in-memory key/value store
+type Store struct {
+	items map[string]string
+}
+
+// NewStore creates an empty Store
+func NewStore() *Store {
+	return &Store{items: make(map[string]string)}
+}
+
+// Put stores value under key
+func (s *Store) Put(key, value string) {
+	s.items[key] = value
+}
+
+// Get returns the value stored under key
+func (s *Store) Get(key string) (string, bool) {
+	value, ok := s.items[key]
+	return value, ok
+}
+`,
+}
+
+// SeedRepo writes files into a temporary directory and returns its path. When git is
+// installed the directory is initialised as a repository with one commit, so commit
+// tracking behaves as it does for real sources.
+func SeedRepo(t testing.TB, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for rel, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("testenv: failed to create %s: %v", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("testenv: failed to write %s: %v", path, err)
+		}
+	}
+
+	if _, err := exec.LookPath("git"); err != nil {
+		return dir
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "-A"},
+		{"-c", "user.name=testenv", "-c", "user.email=testenv@example.com", "commit", "-q", "-m", "fixture"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("testenv: git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	return dir
+}
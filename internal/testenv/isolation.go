@@ -0,0 +1,123 @@
+package testenv
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"testing"
+	"time"
+
+	"bot-go/internal/config"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// cleanupTimeout bounds the deletion of a test's data from a backend
+const cleanupTimeout = time.Minute
+
+// uniqueSuffix returns a random suffix naming one test's repository and database
+func uniqueSuffix(t testing.TB) string {
+	t.Helper()
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatalf("testenv: failed to generate a name: %v", err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// createMySQLDatabase creates cfg.Database on the server and drops it when the test ends
+func createMySQLDatabase(t testing.TB, cfg config.MySQLConfig) {
+	t.Helper()
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/", cfg.Username, cfg.Password, cfg.Host, cfg.Port)
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		t.Fatalf("testenv: failed to open MySQL connection: %v", err)
+	}
+
+	// A fresh container accepts connections before it accepts logins
+	deadline := time.Now().Add(readyTimeout)
+	for {
+		if err = db.Ping(); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			db.Close()
+			t.Fatalf("testenv: MySQL not ready after %s: %v", readyTimeout, err)
+		}
+		time.Sleep(time.Second)
+	}
+
+	query := fmt.Sprintf("CREATE DATABASE `%s` CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci", cfg.Database)
+	if _, err := db.Exec(query); err != nil {
+		db.Close()
+		t.Fatalf("testenv: failed to create database %s: %v", cfg.Database, err)
+	}
+	t.Cleanup(func() {
+		defer db.Close()
+		if _, err := db.Exec(fmt.Sprintf("DROP DATABASE IF EXISTS `%s`", cfg.Database)); err != nil {
+			t.Logf("testenv: failed to drop database %s: %v", cfg.Database, err)
+		}
+	})
+}
+
+// cleanNeo4jRepo deletes the nodes of repoName from Neo4j when the test ends: its file
+// scopes with everything they contain, and other nodes recorded under the repository such
+// as commits and string literals
+func cleanNeo4jRepo(t testing.TB, cfg config.Neo4jConfig, repoName string) {
+	t.Helper()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+		defer cancel()
+
+		driver, err := neo4j.NewDriverWithContext(cfg.URI, neo4j.BasicAuth(cfg.Username, cfg.Password, ""))
+		if err != nil {
+			t.Logf("testenv: failed to connect to Neo4j for cleanup: %v", err)
+			return
+		}
+		defer driver.Close(ctx)
+
+		queries := []string{
+			`MATCH (:FileScope {repo: $repo})-[:CONTAINS*]->(n) DETACH DELETE n`,
+			`MATCH (n {repo: $repo}) DETACH DELETE n`,
+		}
+		for _, query := range queries {
+			_, err := neo4j.ExecuteQuery(ctx, driver, query, map[string]any{"repo": repoName}, neo4j.EagerResultTransformer)
+			if err != nil {
+				t.Logf("testenv: failed to delete Neo4j nodes of %s: %v", repoName, err)
+				return
+			}
+		}
+	})
+}
+
+// cleanQdrantCollection deletes the collection of repoName, named after the repository by
+// default, when the test ends
+func cleanQdrantCollection(t testing.TB, cfg config.QdrantConfig, repoName string) {
+	t.Helper()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+		defer cancel()
+
+		client, err := qdrant.NewClient(&qdrant.Config{Host: cfg.Host, Port: cfg.Port, APIKey: cfg.APIKey})
+		if err != nil {
+			t.Logf("testenv: failed to connect to Qdrant for cleanup: %v", err)
+			return
+		}
+		defer client.Close()
+
+		exists, err := client.CollectionExists(ctx, repoName)
+		if err != nil {
+			t.Logf("testenv: failed to check Qdrant collection %s: %v", repoName, err)
+			return
+		}
+		if exists {
+			if err := client.DeleteCollection(ctx, repoName); err != nil {
+				t.Logf("testenv: failed to delete Qdrant collection %s: %v", repoName, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,198 @@
+// Package testenv bootstraps ephemeral backends (Neo4j, MySQL, Qdrant) and a small fixture
+// repository for integration tests.
+//
+// Each backend comes from, in order:
+//   - an existing server named by an environment variable (BOTGO_TEST_NEO4J_URI,
+//     BOTGO_TEST_MYSQL_ADDR, BOTGO_TEST_QDRANT_ADDR), or
+//   - a throwaway Docker container when BOTGO_TESTENV_DOCKER=1 and docker is on the PATH.
+//
+// When neither is available the test is skipped, so integration tests can live next to unit
+// tests and `go test ./...` stays green on machines without the backends.
+//
+// Tests sharing a server do not see each other's data: every Env has its own fixture
+// repository name, which names its Neo4j nodes and Qdrant collection, and its own MySQL
+// database. All of them are deleted when the test ends.
+//
+// testenv only builds configuration; tests construct services from Env.Config. Packages
+// that init_services depends on should use it from an external _test package to avoid
+// import cycles.
+package testenv
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"bot-go/internal/config"
+)
+
+// Environment variables selecting existing backends or Docker
+const (
+	EnvNeo4jURI      = "BOTGO_TEST_NEO4J_URI"
+	EnvNeo4jUser     = "BOTGO_TEST_NEO4J_USER"
+	EnvNeo4jPassword = "BOTGO_TEST_NEO4J_PASSWORD"
+	EnvMySQLAddr     = "BOTGO_TEST_MYSQL_ADDR"
+	EnvMySQLUser     = "BOTGO_TEST_MYSQL_USER"
+	EnvMySQLPassword = "BOTGO_TEST_MYSQL_PASSWORD"
+	EnvQdrantAddr    = "BOTGO_TEST_QDRANT_ADDR"
+	EnvDocker        = "BOTGO_TESTENV_DOCKER"
+)
+
+// FixtureRepoName prefixes the repository name the fixture repository is registered under,
+// e.g. testenv-fixture-3f9a1c2e
+const FixtureRepoName = "testenv-fixture"
+
+// readyTimeout bounds how long a started container may take to accept connections
+const readyTimeout = 2 * time.Minute
+
+// Options selects the backends a test needs
+type Options struct {
+	Neo4j  bool
+	MySQL  bool
+	Qdrant bool
+
+	// Files overrides the fixture repository contents (relative path -> content);
+	// DefaultFixture is used when nil
+	Files map[string]string
+}
+
+// Env is a bootstrapped integration environment. Everything it started or wrote to a
+// backend is torn down by t.Cleanup.
+type Env struct {
+	// Config points at the backends and has the fixture repository as its only source
+	Config *config.Config
+
+	// Repo is the fixture repository inside Config
+	Repo *config.Repository
+}
+
+// Start brings up the requested backends and seeds the fixture repository, skipping the
+// test if a backend is unavailable
+func Start(t testing.TB, opts Options) *Env {
+	t.Helper()
+
+	files := opts.Files
+	if files == nil {
+		files = DefaultFixture
+	}
+	repoPath := SeedRepo(t, files)
+	suffix := uniqueSuffix(t)
+	repoName := FixtureRepoName + "-" + suffix
+
+	cfg := &config.Config{
+		Source: config.SourceConfig{Repositories: []config.Repository{{
+			Name:     repoName,
+			Path:     repoPath,
+			Language: "go",
+		}}},
+		IndexBuilding: config.IndexBuildingConfig{
+			EnableCodeGraph:  opts.Neo4j,
+			EnableEmbeddings: opts.Qdrant,
+		},
+	}
+	cfg.CodeGraph.BatchSize = 100
+
+	if opts.Neo4j {
+		cfg.Neo4j = neo4jBackend(t)
+		cleanNeo4jRepo(t, cfg.Neo4j, repoName)
+	}
+	if opts.MySQL {
+		cfg.MySQL = mysqlBackend(t)
+		cfg.MySQL.Database = "botgo_test_" + suffix
+		createMySQLDatabase(t, cfg.MySQL)
+	}
+	if opts.Qdrant {
+		cfg.Qdrant = qdrantBackend(t)
+		cleanQdrantCollection(t, cfg.Qdrant, repoName)
+	}
+
+	return &Env{Config: cfg, Repo: &cfg.Source.Repositories[0]}
+}
+
+func neo4jBackend(t testing.TB) config.Neo4jConfig {
+	if uri := os.Getenv(EnvNeo4jURI); uri != "" {
+		return config.Neo4jConfig{
+			URI:      uri,
+			Username: envOr(EnvNeo4jUser, "neo4j"),
+			Password: os.Getenv(EnvNeo4jPassword),
+		}
+	}
+	const password = "testenv-password"
+	addr := startContainer(t, "neo4j", containerSpec{
+		image: "neo4j:5",
+		port:  "7687",
+		env:   []string{"NEO4J_AUTH=neo4j/" + password},
+	})
+	return config.Neo4jConfig{URI: "bolt://" + addr, Username: "neo4j", Password: password}
+}
+
+func mysqlBackend(t testing.TB) config.MySQLConfig {
+	if addr := os.Getenv(EnvMySQLAddr); addr != "" {
+		host, port := splitAddr(t, addr)
+		return config.MySQLConfig{
+			Host:     host,
+			Port:     port,
+			Username: envOr(EnvMySQLUser, "root"),
+			Password: os.Getenv(EnvMySQLPassword),
+		}
+	}
+	const password = "testenv-password"
+	addr := startContainer(t, "mysql", containerSpec{
+		image: "mysql:8",
+		port:  "3306",
+		env:   []string{"MYSQL_ROOT_PASSWORD=" + password},
+	})
+	host, port := splitAddr(t, addr)
+	return config.MySQLConfig{Host: host, Port: port, Username: "root", Password: password}
+}
+
+func qdrantBackend(t testing.TB) config.QdrantConfig {
+	addr := os.Getenv(EnvQdrantAddr)
+	if addr == "" {
+		addr = startContainer(t, "qdrant", containerSpec{
+			image: "qdrant/qdrant",
+			port:  "6334",
+		})
+	}
+	host, port := splitAddr(t, addr)
+	return config.QdrantConfig{Host: host, Port: port}
+}
+
+func envOr(name, fallback string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func splitAddr(t testing.TB, addr string) (string, int) {
+	t.Helper()
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatalf("testenv: invalid address %q: %v", addr, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		t.Fatalf("testenv: invalid port in %q: %v", addr, err)
+	}
+	return host, port
+}
+
+// waitForPort polls addr until it accepts TCP connections or the timeout expires
+func waitForPort(addr string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := net.DialTimeout("tcp", addr, time.Second)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%s not reachable after %s: %w", addr, timeout, err)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
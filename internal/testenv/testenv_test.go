@@ -0,0 +1,88 @@
+package testenv_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"bot-go/internal/db"
+	"bot-go/internal/service/codegraph"
+	"bot-go/internal/testenv"
+	"bot-go/internal/util"
+
+	"go.uber.org/zap"
+)
+
+func TestSeedRepo(t *testing.T) {
+	dir := testenv.SeedRepo(t, map[string]string{"pkg/a.go": "package pkg\n"})
+
+	content, err := os.ReadFile(filepath.Join(dir, "pkg", "a.go"))
+	if err != nil || string(content) != "package pkg\n" {
+		t.Fatalf("fixture file not written: %q, %v", content, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		info, err := util.GetGitInfo(dir)
+		if err != nil || info.HeadCommitSHA == "" {
+			t.Errorf("expected the fixture to be committed, got %+v, %v", info, err)
+		}
+	}
+}
+
+func TestStartIsolatesRepositories(t *testing.T) {
+	first := testenv.Start(t, testenv.Options{})
+	second := testenv.Start(t, testenv.Options{})
+
+	if !strings.HasPrefix(first.Repo.Name, testenv.FixtureRepoName+"-") {
+		t.Errorf("repository name %q does not start with %q", first.Repo.Name, testenv.FixtureRepoName)
+	}
+	if first.Repo.Name == second.Repo.Name {
+		t.Errorf("both environments use repository %q", first.Repo.Name)
+	}
+}
+
+func TestStartMySQL(t *testing.T) {
+	env := testenv.Start(t, testenv.Options{MySQL: true})
+
+	cfg := env.Config.MySQL
+	if !strings.HasPrefix(cfg.Database, "botgo_test_") {
+		t.Errorf("database %q is not a test database", cfg.Database)
+	}
+	conn, err := db.NewMySQLConnection(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to connect to MySQL: %v", err)
+	}
+	defer conn.Close()
+	if err := conn.EnsureDatabase(cfg.Database); err != nil {
+		t.Fatal(err)
+	}
+
+	var tables int
+	query := "SELECT COUNT(*) FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE()"
+	if err := conn.GetDB().QueryRow(query).Scan(&tables); err != nil {
+		t.Fatal(err)
+	}
+	if tables != 0 {
+		t.Errorf("expected an empty database, found %d tables", tables)
+	}
+}
+
+func TestStartNeo4j(t *testing.T) {
+	env := testenv.Start(t, testenv.Options{Neo4j: true})
+
+	cfg := env.Config
+	graph, err := codegraph.NewCodeGraph(cfg.Neo4j.URI, cfg.Neo4j.Username, cfg.Neo4j.Password, cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to connect to Neo4j: %v", err)
+	}
+	defer graph.Close(context.Background())
+
+	scopes, err := graph.FindFileScopes(context.Background(), env.Repo.Name, "main.go")
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if len(scopes) != 0 {
+		t.Errorf("expected an empty graph, found %d file scopes", len(scopes))
+	}
+}
@@ -0,0 +1,383 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"bot-go/internal/model/ast"
+	"bot-go/internal/model/schema"
+	"bot-go/internal/service/codegraph"
+
+	"go.uber.org/zap"
+)
+
+// RelRuntimeCalls is the relation from a function to a function it called at runtime
+const RelRuntimeCalls schema.RelationType = "RUNTIME_CALLS"
+
+// DefaultSource is the provenance source of imported relations when none is given
+const DefaultSource = "otel"
+
+// Metadata keys of the RUNTIME_CALLS relations. Each import sets them from its own trace.
+const (
+	MetaCalls          = "calls"            // calls observed
+	MetaAvgLatencyMs   = "avg_latency_ms"   // mean duration of the called span
+	MetaMaxLatencyMs   = "max_latency_ms"   // longest duration of the called span
+	MetaTotalLatencyMs = "total_latency_ms" // summed duration of the called spans
+)
+
+// maxSamples bounds the function names reported as unresolved or ambiguous
+const maxSamples = 20
+
+func init() {
+	if err := schema.RegisterExternalRelation(schema.ExternalRelation{
+		Type: RelRuntimeCalls,
+		From: []schema.NodeLabel{schema.LabelFunction},
+		To:   []schema.NodeLabel{schema.LabelFunction},
+	}, "a function called another at runtime, observed by tracing"); err != nil {
+		panic(err)
+	}
+}
+
+// Result summarizes an import
+type Result struct {
+	Spans      int      `json:"spans"`
+	Located    int      `json:"located"`           // spans with a code.function attribute
+	Resolved   int      `json:"resolved"`          // located spans mapped to one function
+	Unresolved int      `json:"unresolved"`        // located spans matching no function
+	Ambiguous  int      `json:"ambiguous"`         // located spans matching several functions
+	Samples    []string `json:"samples,omitempty"` // some unresolved or ambiguous function names
+	Edges      int      `json:"edges"`             // distinct caller/callee pairs observed
+	Written    int      `json:"written"`
+}
+
+// Importer writes the calls observed in traces to the code graph
+type Importer struct {
+	graph  *codegraph.CodeGraph
+	logger *zap.Logger
+}
+
+// NewImporter creates an importer writing to graph
+func NewImporter(graph *codegraph.CodeGraph, logger *zap.Logger) *Importer {
+	return &Importer{graph: graph, logger: logger}
+}
+
+// Import maps the spans to Function nodes of the latest file versions of a repository and
+// writes a RUNTIME_CALLS relation from the function of each span's nearest mapped ancestor
+// to the span's function. Relations reported again by the same source are updated with the
+// counts and latencies of this trace.
+func (im *Importer) Import(ctx context.Context, repoName string, spans []Span, provenance codegraph.RelationProvenance) (*Result, error) {
+	if provenance.Source == "" {
+		provenance.Source = DefaultSource
+	}
+	if provenance.Time.IsZero() {
+		provenance.Time = time.Now()
+	}
+	result := &Result{Spans: len(spans)}
+
+	locations := make(map[spanKey]codeLocation, len(spans))
+	nameSet := make(map[string]bool)
+	for _, s := range spans {
+		if loc, ok := spanLocation(s); ok {
+			locations[keyOf(s.TraceID, s.SpanID)] = loc
+			nameSet[loc.function] = true
+		}
+	}
+	result.Located = len(locations)
+	if len(locations) == 0 {
+		return result, nil
+	}
+
+	candidates, err := im.functions(ctx, repoName, nameSet)
+	if err != nil {
+		return nil, err
+	}
+	functions := make(map[spanKey]int64, len(locations))
+	sampled := make(map[string]bool)
+	for key, loc := range locations {
+		id, status := resolve(loc, candidates[loc.function])
+		switch status {
+		case resolved:
+			functions[key] = id
+			result.Resolved++
+			continue
+		case unresolved:
+			result.Unresolved++
+		case ambiguous:
+			result.Ambiguous++
+		}
+		if len(result.Samples) < maxSamples && !sampled[loc.qualified] {
+			sampled[loc.qualified] = true
+			result.Samples = append(result.Samples, loc.qualified)
+		}
+	}
+	sort.Strings(result.Samples)
+
+	edges := aggregateCalls(spans, functions)
+	result.Edges = len(edges)
+	if len(edges) == 0 {
+		return result, nil
+	}
+	relations := make([]codegraph.EnrichedRelation, len(edges))
+	for i, e := range edges {
+		relations[i] = codegraph.EnrichedRelation{
+			From: ast.NodeID(e.from),
+			To:   ast.NodeID(e.to),
+			Type: RelRuntimeCalls,
+			Properties: map[string]any{
+				MetaCalls:          e.calls,
+				MetaAvgLatencyMs:   milliseconds(e.total) / float64(e.calls),
+				MetaMaxLatencyMs:   milliseconds(e.max),
+				MetaTotalLatencyMs: milliseconds(e.total),
+			},
+		}
+	}
+	if result.Written, err = im.graph.CreateEnrichedRelations(ctx, relations, provenance); err != nil {
+		return result, err
+	}
+	im.logger.Info("Imported runtime calls",
+		zap.String("repo_name", repoName),
+		zap.String("source", provenance.Source),
+		zap.Int("spans", result.Spans),
+		zap.Int("resolved", result.Resolved),
+		zap.Int("unresolved", result.Unresolved),
+		zap.Int("ambiguous", result.Ambiguous),
+		zap.Int("relations", result.Written))
+	return result, nil
+}
+
+// functions reads the functions of the latest file versions named in names, by name
+func (im *Importer) functions(ctx context.Context, repoName string, names map[string]bool) (map[string][]functionNode, error) {
+	list := make([]string, 0, len(names))
+	for name := range names {
+		list = append(list, name)
+	}
+	records, err := im.graph.ExecuteRead(ctx, `
+		MATCH (fs:FileScope {repo: $repo})
+		WITH fs.path AS path, max(fs.id) AS fileId
+		MATCH (f:Function {fileId: fileId})
+		WHERE f.name IN $names
+		OPTIONAL MATCH (c:Class)-[:CONTAINS]->(f)
+		RETURN f.id AS id, f.name AS name, c.name AS className, path,
+		       f {.startLine, .startChar, .endLine, .endChar, .range} AS range
+	`, map[string]any{"repo": repoName, "names": list})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read traced functions: %w", err)
+	}
+	byName := make(map[string][]functionNode, len(names))
+	for _, record := range records {
+		name, _ := record["name"].(string)
+		className, _ := record["className"].(string)
+		path, _ := record["path"].(string)
+		id, _ := record["id"].(int64)
+		rng := codegraph.RangeFromValue(record["range"])
+		byName[name] = append(byName[name], functionNode{
+			id:        id,
+			class:     className,
+			path:      path,
+			startLine: rng.Start.Line,
+			endLine:   rng.End.Line,
+		})
+	}
+	return byName, nil
+}
+
+// codeLocation is the code a span reports it ran, from its code.* attributes
+type codeLocation struct {
+	qualified string // the reported function name
+	function  string // its last segment, the name of the Function node
+	class     string // receiver type or namespace, used to pick among same-named functions
+	filePath  string
+	line      int // 0-based, like the graph; -1 when not reported
+}
+
+// spanLocation reads the code location of a span, accepting the current and the deprecated
+// semantic convention attribute names
+func spanLocation(s Span) (codeLocation, bool) {
+	qualified := firstAttribute(s.Attributes, "code.function.name", "code.function")
+	if qualified == "" {
+		return codeLocation{}, false
+	}
+	loc := codeLocation{
+		qualified: qualified,
+		filePath:  firstAttribute(s.Attributes, "code.file.path", "code.filepath"),
+		line:      -1,
+	}
+	if n, err := strconv.Atoi(firstAttribute(s.Attributes, "code.line.number", "code.lineno")); err == nil && n > 0 {
+		loc.line = n - 1
+	}
+	if namespace := firstAttribute(s.Attributes, "code.namespace"); namespace != "" {
+		loc.class = lastSegment(namespace)
+	}
+
+	// Qualified names are dotted after the last "/", as in "com.example.Cart.add" or Go's
+	// "example.com/shop/cart.(*Cart).Add"
+	name := qualified[strings.LastIndexByte(qualified, '/')+1:]
+	segments := strings.Split(name, ".")
+	loc.function = segments[len(segments)-1]
+	if loc.class == "" && len(segments) > 1 {
+		loc.class = strings.Trim(segments[len(segments)-2], "(*)")
+	}
+	return loc, loc.function != ""
+}
+
+func firstAttribute(attributes map[string]string, keys ...string) string {
+	for _, key := range keys {
+		if v := attributes[key]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func lastSegment(s string) string {
+	return s[strings.LastIndexAny(s, "./:")+1:]
+}
+
+// functionNode is a Function node a span may map to
+type functionNode struct {
+	id        int64
+	class     string
+	path      string
+	startLine int
+	endLine   int
+}
+
+type resolution int
+
+const (
+	resolved resolution = iota
+	unresolved
+	ambiguous
+)
+
+// resolve picks the function of a location among the same-named functions of the graph.
+// A reported file path must match; the class and line narrow the rest when they match any.
+func resolve(loc codeLocation, candidates []functionNode) (int64, resolution) {
+	matches := candidates
+	if loc.filePath != "" {
+		matches = filterFunctions(matches, func(f functionNode) bool { return samePath(loc.filePath, f.path) })
+	}
+	if loc.class != "" {
+		matches = narrowFunctions(matches, func(f functionNode) bool { return f.class == loc.class })
+	}
+	if loc.line >= 0 {
+		matches = narrowFunctions(matches, func(f functionNode) bool { return f.startLine <= loc.line && loc.line <= f.endLine })
+	}
+	switch len(matches) {
+	case 0:
+		return 0, unresolved
+	case 1:
+		return matches[0].id, resolved
+	default:
+		return 0, ambiguous
+	}
+}
+
+func filterFunctions(functions []functionNode, keep func(functionNode) bool) []functionNode {
+	var kept []functionNode
+	for _, f := range functions {
+		if keep(f) {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+// narrowFunctions keeps the functions matching keep, or all of them when none does
+func narrowFunctions(functions []functionNode, keep func(functionNode) bool) []functionNode {
+	if kept := filterFunctions(functions, keep); len(kept) > 0 {
+		return kept
+	}
+	return functions
+}
+
+// samePath reports whether a traced file path names a repository file. Traced paths are
+// usually absolute paths of the build machine, so either may be a suffix of the other.
+func samePath(traced, repoPath string) bool {
+	traced = strings.TrimPrefix(strings.ReplaceAll(traced, "\\", "/"), "./")
+	return traced == repoPath ||
+		strings.HasSuffix(traced, "/"+repoPath) ||
+		strings.HasSuffix(repoPath, "/"+traced)
+}
+
+type spanKey struct {
+	traceID string
+	spanID  string
+}
+
+func keyOf(traceID, spanID string) spanKey {
+	return spanKey{traceID: traceID, spanID: spanID}
+}
+
+// callEdge aggregates the calls observed from one function to another
+type callEdge struct {
+	from, to int64
+	calls    int64
+	total    time.Duration
+	max      time.Duration
+}
+
+// aggregateCalls returns a call from the function of each mapped span's nearest mapped
+// ancestor to the span's function, aggregated per pair of functions. Spans between them
+// that map to no function (HTTP clients, database drivers, ...) are skipped, and recursive
+// calls of a function are not counted.
+func aggregateCalls(spans []Span, functions map[spanKey]int64) []callEdge {
+	parents := make(map[spanKey]spanKey, len(spans))
+	for _, s := range spans {
+		if s.ParentSpanID != "" {
+			parents[keyOf(s.TraceID, s.SpanID)] = keyOf(s.TraceID, s.ParentSpanID)
+		}
+	}
+
+	byPair := make(map[[2]int64]*callEdge)
+	for _, s := range spans {
+		key := keyOf(s.TraceID, s.SpanID)
+		to, ok := functions[key]
+		if !ok {
+			continue
+		}
+		// Bounded by the number of spans, in case of a cycle in corrupt parent IDs
+		var from int64
+		found := false
+		for i, parent := 0, key; i < len(spans) && !found; i++ {
+			next, ok := parents[parent]
+			if !ok {
+				break
+			}
+			from, found = functions[next]
+			parent = next
+		}
+		if !found || from == to {
+			continue
+		}
+		pair := [2]int64{from, to}
+		e := byPair[pair]
+		if e == nil {
+			e = &callEdge{from: from, to: to}
+			byPair[pair] = e
+		}
+		e.calls++
+		e.total += s.Duration
+		e.max = max(e.max, s.Duration)
+	}
+
+	edges := make([]callEdge, 0, len(byPair))
+	for _, e := range byPair {
+		edges = append(edges, *e)
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].from != edges[j].from {
+			return edges[i].from < edges[j].from
+		}
+		return edges[i].to < edges[j].to
+	})
+	return edges
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,138 @@
+package tracing
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const otlpDocument = `{
+  "resourceSpans": [{
+    "resource": {"attributes": [{"key": "service.name", "value": {"stringValue": "shop"}}]},
+    "scopeSpans": [{"spans": [
+      {"traceId": "t1", "spanId": "a", "name": "GET /cart",
+       "startTimeUnixNano": "1000000000", "endTimeUnixNano": "1050000000",
+       "attributes": [{"key": "code.function", "value": {"stringValue": "example.com/shop/cart.(*Handler).Get"}},
+                      {"key": "code.lineno", "value": {"intValue": "12"}}]},
+      {"traceId": "t1", "spanId": "b", "parentSpanId": "a", "name": "load",
+       "startTimeUnixNano": 1010000000, "endTimeUnixNano": 1030000000,
+       "attributes": [{"key": "code.function.name", "value": {"stringValue": "Load"}},
+                      {"key": "cached", "value": {"boolValue": false}}]}
+    ]}]
+  }]
+}`
+
+func TestParseOTLP(t *testing.T) {
+	single, err := ParseOTLP(strings.NewReader(otlpDocument))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(single) != 2 {
+		t.Fatalf("got %d spans, want 2", len(single))
+	}
+	a, b := single[0], single[1]
+	if a.Service != "shop" || a.Duration != 50*time.Millisecond || a.Attributes["code.lineno"] != "12" {
+		t.Errorf("unexpected first span %+v", a)
+	}
+	if b.ParentSpanID != "a" || b.Duration != 20*time.Millisecond || b.Attributes["cached"] != "false" {
+		t.Errorf("unexpected second span %+v", b)
+	}
+
+	line := strings.Join(strings.Fields(otlpDocument), "")
+	lines, err := ParseOTLP(strings.NewReader(line + "\n" + line + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 4 {
+		t.Errorf("got %d spans from JSON lines, want 4", len(lines))
+	}
+
+	if _, err := ParseOTLP(strings.NewReader(otlpDocument[:100])); err == nil {
+		t.Error("expected an error for a truncated export")
+	}
+}
+
+func TestSpanLocation(t *testing.T) {
+	tests := []struct {
+		attributes map[string]string
+		want       codeLocation
+	}{
+		{
+			map[string]string{"code.function": "example.com/shop/cart.(*Handler).Get", "code.filepath": "/src/shop/cart/handler.go", "code.lineno": "12"},
+			codeLocation{function: "Get", class: "Handler", filePath: "/src/shop/cart/handler.go", line: 11},
+		},
+		{
+			map[string]string{"code.function.name": "com.example.Cart.add"},
+			codeLocation{function: "add", class: "Cart", line: -1},
+		},
+		{
+			map[string]string{"code.function": "checkout", "code.namespace": "shop.orders.OrderService"},
+			codeLocation{function: "checkout", class: "OrderService", line: -1},
+		},
+	}
+	for _, tt := range tests {
+		got, ok := spanLocation(Span{Attributes: tt.attributes})
+		got.qualified = ""
+		if !ok || got != tt.want {
+			t.Errorf("spanLocation(%v) = %+v, %v; want %+v", tt.attributes, got, ok, tt.want)
+		}
+	}
+	if _, ok := spanLocation(Span{Attributes: map[string]string{"http.method": "GET"}}); ok {
+		t.Error("expected no location without code.function")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	candidates := []functionNode{
+		{id: 1, class: "Handler", path: "cart/handler.go", startLine: 10, endLine: 20},
+		{id: 2, class: "Handler", path: "cart/handler.go", startLine: 30, endLine: 40},
+		{id: 3, class: "Client", path: "cart/client.go", startLine: 5, endLine: 15},
+	}
+	tests := []struct {
+		loc    codeLocation
+		wantID int64
+		want   resolution
+	}{
+		{codeLocation{filePath: "/build/shop/cart/handler.go", line: 11}, 1, resolved},
+		{codeLocation{filePath: "/build/shop/cart/handler.go", line: -1}, 0, ambiguous},
+		{codeLocation{class: "Client", line: -1}, 3, resolved},
+		{codeLocation{class: "Unknown", line: 35}, 2, resolved},
+		{codeLocation{filePath: "cart/other.go", line: -1}, 0, unresolved},
+	}
+	for _, tt := range tests {
+		id, got := resolve(tt.loc, candidates)
+		if id != tt.wantID || got != tt.want {
+			t.Errorf("resolve(%+v) = %d, %v; want %d, %v", tt.loc, id, got, tt.wantID, tt.want)
+		}
+	}
+}
+
+func TestAggregateCalls(t *testing.T) {
+	ms := time.Millisecond
+	spans := []Span{
+		{TraceID: "t1", SpanID: "a"},
+		{TraceID: "t1", SpanID: "b", ParentSpanID: "a", Duration: 10 * ms},
+		{TraceID: "t1", SpanID: "http", ParentSpanID: "b"},
+		{TraceID: "t1", SpanID: "c", ParentSpanID: "http", Duration: 4 * ms},
+		{TraceID: "t1", SpanID: "d", ParentSpanID: "c", Duration: 2 * ms}, // recursive call of c
+		{TraceID: "t2", SpanID: "a"},
+		{TraceID: "t2", SpanID: "b", ParentSpanID: "a", Duration: 30 * ms},
+	}
+	functions := map[spanKey]int64{
+		keyOf("t1", "a"): 1, keyOf("t1", "b"): 2, keyOf("t1", "c"): 3, keyOf("t1", "d"): 3,
+		keyOf("t2", "a"): 1, keyOf("t2", "b"): 2,
+	}
+	want := []callEdge{
+		{from: 1, to: 2, calls: 2, total: 40 * ms, max: 30 * ms},
+		{from: 2, to: 3, calls: 1, total: 4 * ms, max: 4 * ms},
+	}
+	got := aggregateCalls(spans, functions)
+	if len(got) != len(want) {
+		t.Fatalf("got edges %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("edge %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
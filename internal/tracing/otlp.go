@@ -0,0 +1,131 @@
+// Package tracing imports OpenTelemetry traces into the code graph. Spans carrying code
+// attributes (code.function, code.filepath, ...) are mapped to Function nodes, and every
+// call observed between two mapped spans becomes a RUNTIME_CALLS relation with its call
+// count and latencies, next to the static CALLS_FUNCTION relations of the parser.
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Span is the part of an OpenTelemetry span the importer uses
+type Span struct {
+	TraceID      string
+	SpanID       string
+	ParentSpanID string
+	Name         string
+	Service      string // service.name of the span's resource
+	Duration     time.Duration
+	Attributes   map[string]string // string, integer and boolean attributes, as text
+}
+
+// otlpExport is an OTLP/JSON trace export (ExportTraceServiceRequest), as written by the
+// collector's file exporter or posted to an OTLP/HTTP endpoint
+type otlpExport struct {
+	ResourceSpans []struct {
+		Resource struct {
+			Attributes []otlpAttribute `json:"attributes"`
+		} `json:"resource"`
+		ScopeSpans []struct {
+			Spans []otlpSpan `json:"spans"`
+		} `json:"scopeSpans"`
+	} `json:"resourceSpans"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId"`
+	Name              string          `json:"name"`
+	StartTimeUnixNano otlpInt         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   otlpInt         `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes"`
+}
+
+type otlpAttribute struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue *string  `json:"stringValue"`
+		IntValue    *otlpInt `json:"intValue"`
+		BoolValue   *bool    `json:"boolValue"`
+	} `json:"value"`
+}
+
+// otlpInt is a 64-bit integer, which OTLP/JSON encodes as a string or a number
+type otlpInt int64
+
+func (n *otlpInt) UnmarshalJSON(data []byte) error {
+	s := string(bytes.Trim(data, `"`))
+	if s == "" || s == "null" {
+		return nil
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		u, uerr := strconv.ParseUint(s, 10, 64)
+		if uerr != nil {
+			return fmt.Errorf("invalid integer %s", data)
+		}
+		v = int64(u)
+	}
+	*n = otlpInt(v)
+	return nil
+}
+
+func attributeMap(attributes []otlpAttribute) map[string]string {
+	values := make(map[string]string, len(attributes))
+	for _, a := range attributes {
+		switch {
+		case a.Value.StringValue != nil:
+			values[a.Key] = *a.Value.StringValue
+		case a.Value.IntValue != nil:
+			values[a.Key] = strconv.FormatInt(int64(*a.Value.IntValue), 10)
+		case a.Value.BoolValue != nil:
+			values[a.Key] = strconv.FormatBool(*a.Value.BoolValue)
+		}
+	}
+	return values
+}
+
+// ParseOTLP reads the spans of an OTLP/JSON trace export: one ExportTraceServiceRequest
+// document, or a sequence of them, such as the one-per-line files of the collector's file
+// exporter
+func ParseOTLP(r io.Reader) ([]Span, error) {
+	var spans []Span
+	decoder := json.NewDecoder(r)
+	for {
+		var export otlpExport
+		err := decoder.Decode(&export)
+		if err == io.EOF {
+			return spans, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid OTLP/JSON export near byte %d: %w", decoder.InputOffset(), err)
+		}
+		spans = appendSpans(spans, &export)
+	}
+}
+
+func appendSpans(spans []Span, export *otlpExport) []Span {
+	for _, rs := range export.ResourceSpans {
+		service := attributeMap(rs.Resource.Attributes)["service.name"]
+		for _, ss := range rs.ScopeSpans {
+			for _, s := range ss.Spans {
+				spans = append(spans, Span{
+					TraceID:      s.TraceID,
+					SpanID:       s.SpanID,
+					ParentSpanID: s.ParentSpanID,
+					Name:         s.Name,
+					Service:      service,
+					Duration:     time.Duration(max(int64(s.EndTimeUnixNano)-int64(s.StartTimeUnixNano), 0)),
+					Attributes:   attributeMap(s.Attributes),
+				})
+			}
+		}
+	}
+	return spans
+}
@@ -0,0 +1,219 @@
+package util
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Default bounds on what ExtractArchive writes
+const (
+	DefaultArchiveMaxBytes = 2 << 30 // 2 GiB uncompressed
+	DefaultArchiveMaxFiles = 200000
+)
+
+// ErrArchiveTooLarge is returned when an archive expands past its ArchiveLimits
+var ErrArchiveTooLarge = errors.New("archive exceeds the extraction limits")
+
+// ArchiveLimits bounds the extraction of an untrusted archive (0 = default)
+type ArchiveLimits struct {
+	MaxBytes int64
+	MaxFiles int
+}
+
+// IsArchive reports whether a file name has an archive extension ExtractArchive reads:
+// .zip, .tar, .tar.gz or .tgz
+func IsArchive(name string) bool {
+	return archiveFormat(name) != ""
+}
+
+func archiveFormat(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return "zip"
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return "tar.gz"
+	case strings.HasSuffix(lower, ".tar"):
+		return "tar"
+	}
+	return ""
+}
+
+// ExtractArchive extracts a zip or (gzipped) tar archive into destDir and returns the
+// directory holding the sources: destDir, or the archive's single top-level directory as in
+// release tarballs (project-1.2/...). Only regular files and directories are extracted;
+// links and devices are skipped, and entries escaping destDir fail the extraction.
+func ExtractArchive(archivePath, destDir string, limits ArchiveLimits) (string, error) {
+	if limits.MaxBytes <= 0 {
+		limits.MaxBytes = DefaultArchiveMaxBytes
+	}
+	if limits.MaxFiles <= 0 {
+		limits.MaxFiles = DefaultArchiveMaxFiles
+	}
+	x := &extractor{dest: destDir, limits: limits, topLevel: make(map[string]bool)}
+
+	var err error
+	switch archiveFormat(archivePath) {
+	case "zip":
+		err = x.extractZip(archivePath)
+	case "tar", "tar.gz":
+		err = x.extractTar(archivePath, archiveFormat(archivePath) == "tar.gz")
+	default:
+		return "", fmt.Errorf("unsupported archive format: %s (supported: .zip, .tar, .tar.gz, .tgz)", filepath.Base(archivePath))
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if len(x.topLevel) == 1 && !x.topLevelFile {
+		for dir := range x.topLevel {
+			return filepath.Join(destDir, dir), nil
+		}
+	}
+	return destDir, nil
+}
+
+type extractor struct {
+	dest         string
+	limits       ArchiveLimits
+	bytes        int64
+	files        int
+	topLevel     map[string]bool
+	topLevelFile bool // a file sits directly at the top level
+}
+
+func (x *extractor) extractZip(archivePath string) error {
+	r, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open zip archive: %w", err)
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		mode := f.Mode()
+		switch {
+		case mode.IsDir():
+			if _, err := x.target(f.Name, true); err != nil {
+				return err
+			}
+		case mode.IsRegular():
+			rc, err := f.Open()
+			if err != nil {
+				return fmt.Errorf("failed to read %s from archive: %w", f.Name, err)
+			}
+			err = x.writeFile(f.Name, rc)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (x *extractor) extractTar(archivePath string, gzipped bool) error {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open tar archive: %w", err)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if gzipped {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("failed to decompress archive: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar archive: %w", err)
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if _, err := x.target(header.Name, true); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := x.writeFile(header.Name, tr); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// target returns where an entry is extracted, rejecting names that escape the destination,
+// and records the entry's top-level directory
+func (x *extractor) target(name string, dir bool) (string, error) {
+	slashed := strings.ReplaceAll(name, "\\", "/")
+	for _, segment := range strings.Split(slashed, "/") {
+		if segment == ".." {
+			return "", fmt.Errorf("archive entry %q escapes the extraction directory", name)
+		}
+	}
+	clean := strings.TrimPrefix(path.Clean("/"+slashed), "/")
+	if clean == "" {
+		return x.dest, nil
+	}
+	top, _, nested := strings.Cut(clean, "/")
+	x.topLevel[top] = true
+	if !nested && !dir {
+		x.topLevelFile = true
+	}
+
+	target := filepath.Join(x.dest, filepath.FromSlash(clean))
+	if dir {
+		if err := os.MkdirAll(target, 0o755); err != nil {
+			return "", fmt.Errorf("failed to create %s: %w", clean, err)
+		}
+	}
+	return target, nil
+}
+
+func (x *extractor) writeFile(name string, r io.Reader) error {
+	x.files++
+	if x.files > x.limits.MaxFiles {
+		return fmt.Errorf("%w: more than %d files", ErrArchiveTooLarge, x.limits.MaxFiles)
+	}
+	target, err := x.target(name, false)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", name, err)
+	}
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", name, err)
+	}
+	// Copy one byte past the remaining budget to detect archives that expand beyond it
+	remaining := x.limits.MaxBytes - x.bytes
+	n, err := io.CopyN(out, r, remaining+1)
+	x.bytes += n
+	if cerr := out.Close(); err == nil || err == io.EOF {
+		err = cerr
+	}
+	if x.bytes > x.limits.MaxBytes {
+		return fmt.Errorf("%w: more than %d bytes", ErrArchiveTooLarge, x.limits.MaxBytes)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to extract %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package util
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTarGz(t *testing.T, path string, files map[string]string, symlink string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(content))
+	}
+	if symlink != "" {
+		tw.WriteHeader(&tar.Header{Name: symlink, Linkname: "/etc/passwd", Typeflag: tar.TypeSymlink})
+	}
+	tw.Close()
+	gz.Close()
+}
+
+func TestExtractArchiveTarGz(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "project-1.2.tar.gz")
+	writeTarGz(t, archive, map[string]string{
+		"project-1.2/main.go":     "package main\n",
+		"project-1.2/pkg/util.go": "package pkg\n",
+	}, "project-1.2/link")
+
+	root, err := ExtractArchive(archive, filepath.Join(dir, "out"), ArchiveLimits{})
+	if err != nil {
+		t.Fatalf("ExtractArchive() error = %v", err)
+	}
+	if want := filepath.Join(dir, "out", "project-1.2"); root != want {
+		t.Errorf("root = %s, want the single top-level directory %s", root, want)
+	}
+	if data, err := os.ReadFile(filepath.Join(root, "pkg", "util.go")); err != nil || string(data) != "package pkg\n" {
+		t.Errorf("pkg/util.go = %q, %v", data, err)
+	}
+	if _, err := os.Lstat(filepath.Join(root, "link")); !os.IsNotExist(err) {
+		t.Errorf("symlinks should be skipped, got %v", err)
+	}
+}
+
+func TestExtractArchiveZip(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "src.zip")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for _, name := range []string{"a.py", "lib/b.py"} {
+		w, _ := zw.Create(name)
+		w.Write([]byte("x = 1\n"))
+	}
+	zw.Close()
+	f.Close()
+
+	out := filepath.Join(dir, "out")
+	root, err := ExtractArchive(archive, out, ArchiveLimits{})
+	if err != nil {
+		t.Fatalf("ExtractArchive() error = %v", err)
+	}
+	if root != out {
+		t.Errorf("root = %s, want %s for an archive with files at the top level", root, out)
+	}
+	if _, err := os.Stat(filepath.Join(out, "lib", "b.py")); err != nil {
+		t.Errorf("lib/b.py not extracted: %v", err)
+	}
+}
+
+func TestExtractArchiveRejectsUnsafeArchives(t *testing.T) {
+	dir := t.TempDir()
+
+	traversal := filepath.Join(dir, "evil.tar.gz")
+	writeTarGz(t, traversal, map[string]string{"../escape.txt": "x"}, "")
+	if _, err := ExtractArchive(traversal, filepath.Join(dir, "out1"), ArchiveLimits{}); err == nil {
+		t.Error("ExtractArchive() should reject entries escaping the destination")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "escape.txt")); !os.IsNotExist(err) {
+		t.Error("an escaping entry was written")
+	}
+
+	large := filepath.Join(dir, "large.tar.gz")
+	writeTarGz(t, large, map[string]string{"a.txt": "0123456789", "b.txt": "0123456789"}, "")
+	_, err := ExtractArchive(large, filepath.Join(dir, "out2"), ArchiveLimits{MaxBytes: 15})
+	if !errors.Is(err, ErrArchiveTooLarge) {
+		t.Errorf("ExtractArchive() error = %v, want ErrArchiveTooLarge", err)
+	}
+}
@@ -0,0 +1,29 @@
+package util
+
+// LineDiffStats returns how many lines a line-based diff from oldLines to newLines adds and
+// removes, using the longest common subsequence of the two
+func LineDiffStats(oldLines, newLines []string) (added, removed int) {
+	// Common prefix and suffix never appear in the diff
+	for len(oldLines) > 0 && len(newLines) > 0 && oldLines[0] == newLines[0] {
+		oldLines, newLines = oldLines[1:], newLines[1:]
+	}
+	for len(oldLines) > 0 && len(newLines) > 0 && oldLines[len(oldLines)-1] == newLines[len(newLines)-1] {
+		oldLines, newLines = oldLines[:len(oldLines)-1], newLines[:len(newLines)-1]
+	}
+
+	// LCS length, two rows at a time
+	prev := make([]int, len(newLines)+1)
+	curr := make([]int, len(newLines)+1)
+	for i := 1; i <= len(oldLines); i++ {
+		for j := 1; j <= len(newLines); j++ {
+			if oldLines[i-1] == newLines[j-1] {
+				curr[j] = prev[j-1] + 1
+			} else {
+				curr[j] = max(prev[j], curr[j-1])
+			}
+		}
+		prev, curr = curr, prev
+	}
+	common := prev[len(newLines)]
+	return len(newLines) - common, len(oldLines) - common
+}
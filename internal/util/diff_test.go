@@ -0,0 +1,30 @@
+package util
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLineDiffStats(t *testing.T) {
+	tests := []struct {
+		name           string
+		old, new       string
+		added, removed int
+	}{
+		{"identical", "a\nb\nc", "a\nb\nc", 0, 0},
+		{"appended", "a\nb", "a\nb\nc", 1, 0},
+		{"removed in middle", "a\nb\nc", "a\nc", 0, 1},
+		{"changed line", "a\nb\nc", "a\nx\nc", 1, 1},
+		{"from empty", "", "a\nb", 2, 1}, // "" splits into one empty line
+		{"reordered", "a\nb\nc\nd", "d\na\nb\nc", 1, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			added, removed := LineDiffStats(strings.Split(tt.old, "\n"), strings.Split(tt.new, "\n"))
+			if added != tt.added || removed != tt.removed {
+				t.Errorf("LineDiffStats() = +%d -%d, want +%d -%d", added, removed, tt.added, tt.removed)
+			}
+		})
+	}
+}
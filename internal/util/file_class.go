@@ -0,0 +1,164 @@
+package util
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"bot-go/internal/config"
+)
+
+// Reasons a file is routed to the lightweight indexing path (FileScope node only, no
+// parsing, chunking, embedding or n-gram modelling)
+const (
+	LightweightTooLarge     = "too_large"
+	LightweightTooManyLines = "too_many_lines"
+	LightweightGenerated    = "generated"
+	LightweightMinified     = "minified"
+)
+
+// Reasons a file is not indexed at all because its content is not source text
+const (
+	SkipBinary  = "binary"
+	SkipNonUTF8 = "non_utf8"
+)
+
+// sniffBytes is how much of a file is searched for NUL bytes, as git does for its binary check
+const sniffBytes = 8000
+
+// Limits used when a repository does not set max_file_size_kb / max_file_lines
+const (
+	DefaultMaxFileSizeKB = 1024
+	DefaultMaxFileLines  = 20000
+)
+
+// generatedFileSuffixes are file name endings produced by common code generators
+var generatedFileSuffixes = []string{
+	".pb.go", ".pb.gw.go", "_grpc.pb.go", "_pb2.py", "_pb2_grpc.py", ".pb.ts", "_pb.js",
+	"_generated.go", ".generated.ts", ".generated.js", ".min.js", ".min.mjs",
+}
+
+// headerScanBytes is how much of a file is searched for a generated-code marker
+const headerScanBytes = 2048
+
+// LightweightReason returns why a file should only get a FileScope node, or "" if it should
+// be fully indexed. The size and line limits come from repo (defaults when unset, disabled
+// when negative); generated and minified files are detected unless repo.IndexGenerated is set.
+func LightweightReason(filePath string, content []byte, repo *config.Repository) string {
+	maxSizeKB, maxLines := DefaultMaxFileSizeKB, DefaultMaxFileLines
+	indexGenerated := false
+	if repo != nil {
+		if repo.MaxFileSizeKB != 0 {
+			maxSizeKB = repo.MaxFileSizeKB
+		}
+		if repo.MaxFileLines != 0 {
+			maxLines = repo.MaxFileLines
+		}
+		indexGenerated = repo.IndexGenerated
+	}
+
+	if maxSizeKB > 0 && len(content) > maxSizeKB*1024 {
+		return LightweightTooLarge
+	}
+	if maxLines > 0 && bytes.Count(content, []byte{'\n'}) >= maxLines {
+		return LightweightTooManyLines
+	}
+	if indexGenerated {
+		return ""
+	}
+	if IsGeneratedFile(filePath, content) {
+		return LightweightGenerated
+	}
+	if IsMinified(filePath, content) {
+		return LightweightMinified
+	}
+	return ""
+}
+
+// IsGeneratedFile reports whether a file looks machine-generated, either by its name
+// (e.g. .pb.go) or by a marker such as "Code generated ... DO NOT EDIT." or "@generated"
+// near the top of the file
+func IsGeneratedFile(filePath string, content []byte) bool {
+	lowerName := strings.ToLower(filepath.Base(filePath))
+	for _, suffix := range generatedFileSuffixes {
+		if strings.HasSuffix(lowerName, suffix) {
+			return true
+		}
+	}
+
+	header := content
+	if len(header) > headerScanBytes {
+		header = header[:headerScanBytes]
+	}
+	lowerHeader := strings.ToLower(string(header))
+	switch {
+	case strings.Contains(lowerHeader, "code generated") && strings.Contains(lowerHeader, "do not edit"):
+		return true
+	case strings.Contains(lowerHeader, "@generated"),
+		strings.Contains(lowerHeader, "<auto-generated"),
+		strings.Contains(lowerHeader, "generated by the protocol buffer compiler"):
+		return true
+	}
+	return false
+}
+
+// testDirs are directory names that only hold tests
+var testDirs = []string{"test", "tests", "__tests__", "testdata"}
+
+// IsTestFile reports whether a file holds tests, judged by the naming conventions of the
+// supported languages (foo_test.go, test_foo.py, FooTest.java, foo.spec.ts) or a test directory
+func IsTestFile(filePath string) bool {
+	slashed := filepath.ToSlash(filePath)
+	for _, dir := range testDirs {
+		if strings.HasPrefix(slashed, dir+"/") || strings.Contains(slashed, "/"+dir+"/") {
+			return true
+		}
+	}
+
+	base := filepath.Base(filePath)
+	stem := strings.TrimSuffix(base, filepath.Ext(base))
+	switch strings.ToLower(filepath.Ext(base)) {
+	case ".go":
+		return strings.HasSuffix(stem, "_test")
+	case ".py":
+		return strings.HasPrefix(stem, "test_") || strings.HasSuffix(stem, "_test")
+	case ".java":
+		return strings.HasSuffix(stem, "Test") || strings.HasSuffix(stem, "Tests")
+	case ".js", ".mjs", ".cjs", ".jsx", ".ts", ".tsx":
+		return strings.HasSuffix(stem, ".test") || strings.HasSuffix(stem, ".spec")
+	}
+	return false
+}
+
+// IsMinified reports whether a JavaScript/TypeScript file looks minified: long enough to
+// matter and with an average line length no hand-written source has
+func IsMinified(filePath string, content []byte) bool {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".js", ".mjs", ".cjs", ".jsx", ".ts", ".tsx":
+	default:
+		return false
+	}
+	const minSize, maxAvgLineLength = 4096, 500
+	if len(content) < minSize {
+		return false
+	}
+	lines := bytes.Count(content, []byte{'\n'}) + 1
+	return len(content)/lines > maxAvgLineLength
+}
+
+// ContentSkipReason sniffs file content and returns SkipBinary for binary data (a NUL byte
+// near the start), SkipNonUTF8 for text that is not valid UTF-8, or "" for indexable source
+func ContentSkipReason(content []byte) string {
+	head := content
+	if len(head) > sniffBytes {
+		head = head[:sniffBytes]
+	}
+	if bytes.IndexByte(head, 0) >= 0 {
+		return SkipBinary
+	}
+	if !utf8.Valid(content) {
+		return SkipNonUTF8
+	}
+	return ""
+}
@@ -0,0 +1,71 @@
+package util
+
+import (
+	"strings"
+	"testing"
+
+	"bot-go/internal/config"
+)
+
+func TestLightweightReason(t *testing.T) {
+	minified := []byte(strings.Repeat("var a=1;", 1000))
+	tests := []struct {
+		name    string
+		path    string
+		content []byte
+		repo    *config.Repository
+		want    string
+	}{
+		{"plain source", "main.go", []byte("package main\n\nfunc main() {}\n"), nil, ""},
+		{"go generated header", "api.go", []byte("// Code generated by mockgen. DO NOT EDIT.\n\npackage api\n"), nil, LightweightGenerated},
+		{"protobuf name", "api/v1/service.pb.go", []byte("package v1\n"), nil, LightweightGenerated},
+		{"minified js", "dist.js", minified, nil, LightweightMinified},
+		{"too many lines", "big.py", []byte(strings.Repeat("x = 1\n", 50)), &config.Repository{MaxFileLines: 10}, LightweightTooManyLines},
+		{"too large", "big.py", make([]byte, 3*1024), &config.Repository{MaxFileSizeKB: 2}, LightweightTooLarge},
+		{"limits disabled", "big.py", []byte(strings.Repeat("x = 1\n", 50)), &config.Repository{MaxFileLines: -1}, ""},
+		{"generated indexed on request", "service.pb.go", []byte("package v1\n"), &config.Repository{IndexGenerated: true}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := LightweightReason(tt.path, tt.content, tt.repo); got != tt.want {
+				t.Errorf("LightweightReason(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContentSkipReason(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []byte
+		want    string
+	}{
+		{"ascii source", []byte("package main\n"), ""},
+		{"utf8 with emoji and CJK", []byte("// 你好 🎉\nvar s = \"é\"\n"), ""},
+		{"binary with NUL", []byte{0x89, 'P', 'N', 'G', 0x00, 0x01}, SkipBinary},
+		{"latin-1 text", []byte("caf\xe9\n"), SkipNonUTF8},
+	}
+	for _, tt := range tests {
+		if got := ContentSkipReason(tt.content); got != tt.want {
+			t.Errorf("%s: ContentSkipReason() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIsTestFile(t *testing.T) {
+	tests := map[string]bool{
+		"internal/util/paths_test.go":  true,
+		"pkg/test_parser.py":           true,
+		"src/main/FooServiceTest.java": true,
+		"web/src/app.spec.ts":          true,
+		"tests/fixtures/data.go":       true,
+		"internal/util/paths.go":       false,
+		"src/contest.py":               false,
+		"src/main/Latest.java":         false,
+	}
+	for path, want := range tests {
+		if got := IsTestFile(path); got != want {
+			t.Errorf("IsTestFile(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
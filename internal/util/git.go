@@ -8,15 +8,17 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 // GitInfo contains git repository information
 type GitInfo struct {
-	HeadCommitSHA  string
-	HeadCommitMsg  string
-	ModifiedFiles  map[string]bool // Set of files modified compared to HEAD (absolute paths)
-	GitRootPath    string          // Absolute path to git repository root
-	IsGitRepo      bool
+	HeadCommitSHA string
+	HeadCommitMsg string
+	ModifiedFiles map[string]bool // Set of files modified compared to HEAD (absolute paths)
+	GitRootPath   string          // Absolute path to git repository root
+	IsGitRepo     bool
+	Submodules    map[string]string // Submodule root (absolute path) -> commit pinned by the superproject
 }
 
 // GetGitInfo retrieves git information for a repository path
@@ -82,9 +84,69 @@ func GetGitInfo(repoPath string) (*GitInfo, error) {
 		}
 	}
 
+	// Submodules are optional; a repository without readable .gitmodules simply has none
+	info.Submodules, _ = listSubmodules(info.GitRootPath)
+
 	return info, nil
 }
 
+// listSubmodules returns the submodules declared in .gitmodules, keyed by absolute path,
+// with the commit each one is pinned to in the superproject's index
+func listSubmodules(gitRootPath string) (map[string]string, error) {
+	submodules := make(map[string]string)
+	if _, err := os.Stat(filepath.Join(gitRootPath, ".gitmodules")); err != nil {
+		return submodules, nil
+	}
+
+	cmd := exec.Command("git", "config", "--file", ".gitmodules", "--get-regexp", `^submodule\..*\.path$`)
+	cmd.Dir = gitRootPath
+	output, err := cmd.Output()
+	if err != nil {
+		return submodules, fmt.Errorf("failed to read .gitmodules: %w", err)
+	}
+	var paths []string
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if _, path, ok := strings.Cut(line, " "); ok && path != "" {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		return submodules, nil
+	}
+
+	// Gitlink entries have mode 160000: "<mode> <sha> <stage>\t<path>"
+	cmd = exec.Command("git", append([]string{"ls-files", "--stage", "--"}, paths...)...)
+	cmd.Dir = gitRootPath
+	output, err = cmd.Output()
+	if err != nil {
+		return submodules, fmt.Errorf("failed to read submodule commits: %w", err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		meta, path, ok := strings.Cut(line, "\t")
+		fields := strings.Fields(meta)
+		if !ok || len(fields) < 2 || fields[0] != "160000" {
+			continue
+		}
+		submodules[filepath.Join(gitRootPath, path)] = fields[1]
+	}
+	return submodules, nil
+}
+
+// SubmoduleFor returns the submodule containing filePath (an absolute path) and the commit
+// it is pinned to, or empty strings if the file belongs to the superproject
+func SubmoduleFor(gitInfo *GitInfo, filePath string) (dir, commit string) {
+	if gitInfo == nil {
+		return "", ""
+	}
+	for subDir, subCommit := range gitInfo.Submodules {
+		// Nested submodules are not listed, so at most one entry contains the file
+		if strings.HasPrefix(filePath, subDir+string(filepath.Separator)) {
+			return subDir, subCommit
+		}
+	}
+	return "", ""
+}
+
 // GetFileContentFromGit retrieves file content from git HEAD
 // Returns error if file is not tracked by git
 // gitRootPath should be the git repository root (from GitInfo.GitRootPath)
@@ -95,14 +157,19 @@ func GetFileContentFromGit(gitRootPath, filePath string) ([]byte, error) {
 		return nil, fmt.Errorf("failed to get relative path: %w", err)
 	}
 
-	// Use git show to get file content from HEAD
-	cmd := exec.Command("git", "show", fmt.Sprintf("HEAD:%s", relPath))
+	return GetFileContentAtCommit(gitRootPath, relPath, "HEAD")
+}
+
+// GetFileContentAtCommit returns the content of a file (relative to the git root) as of a
+// commit or other revision
+func GetFileContentAtCommit(gitRootPath, relPath, commit string) ([]byte, error) {
+	cmd := exec.Command("git", "show", fmt.Sprintf("%s:%s", commit, filepath.ToSlash(relPath)))
 	cmd.Dir = gitRootPath
 	output, err := cmd.Output()
 	if err != nil {
 		// Check if it's because the file doesn't exist in git
 		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 128 {
-			return nil, fmt.Errorf("file not tracked by git: %s", relPath)
+			return nil, fmt.Errorf("file not tracked by git at %s: %s", commit, relPath)
 		}
 		return nil, fmt.Errorf("failed to get file content from git: %w", err)
 	}
@@ -120,7 +187,13 @@ func IsFileModified(gitInfo *GitInfo, filePath string) bool {
 
 // ReadFileOptimized reads file content, using git HEAD if useHead is true and file is unmodified
 // In HEAD mode, untracked files are skipped (returns nil content with error)
+// Files of a submodule are read from the submodule at the commit the superproject pins, which
+// is the commit recorded for them, in both modes
 func ReadFileOptimized(repoPath, filePath string, useHead bool, gitInfo *GitInfo) ([]byte, error) {
+	if subDir, subCommit := SubmoduleFor(gitInfo, filePath); subDir != "" {
+		return readSubmoduleFile(subDir, subCommit, filePath, useHead)
+	}
+
 	// If not using HEAD mode, read from disk
 	if !useHead || gitInfo == nil || !gitInfo.IsGitRepo {
 		return os.ReadFile(filePath)
@@ -147,6 +220,24 @@ func ReadFileOptimized(repoPath, filePath string, useHead bool, gitInfo *GitInfo
 	return content, nil
 }
 
+// readSubmoduleFile reads a file of a submodule at its pinned commit. In HEAD mode a file the
+// commit does not have is skipped like an untracked file; otherwise, and on other git errors
+// (e.g. the pinned commit is not fetched), the file is read from disk.
+func readSubmoduleFile(subDir, subCommit, filePath string, useHead bool) ([]byte, error) {
+	relPath, err := filepath.Rel(subDir, filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get relative path: %w", err)
+	}
+	content, err := GetFileContentAtCommit(subDir, relPath, subCommit)
+	if err != nil {
+		if useHead && strings.Contains(err.Error(), "file not tracked by git") {
+			return nil, err
+		}
+		return os.ReadFile(filePath)
+	}
+	return content, nil
+}
+
 // GetLastCommitForFile gets the commit SHA of the last commit that modified a file
 func GetLastCommitForFile(repoPath, filePath string) (string, error) {
 	// Get git root directory (in case repoPath is a subdirectory)
@@ -180,17 +271,29 @@ func GetLastCommitForFile(repoPath, filePath string) (string, error) {
 	return commitSHA, nil
 }
 
+// HeadCommitTime returns the committer time of the HEAD commit of the repository
+func HeadCommitTime(repoPath string) (time.Time, error) {
+	cmd := exec.Command("git", "log", "-1", "--pretty=%cI", "HEAD")
+	cmd.Dir = repoPath
+	output, err := cmd.Output()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get HEAD commit time: %w", err)
+	}
+	return time.Parse(time.RFC3339, strings.TrimSpace(string(output)))
+}
+
 // CalculateFileSHA256 calculates the SHA256 hash of file content
 func CalculateFileSHA256(content []byte) string {
 	hash := sha256.Sum256(content)
 	return hex.EncodeToString(hash[:])
 }
 
-// GetRelativePath returns the relative path of a file from the repository root
+// GetRelativePath returns the canonical relative path (see CanonicalPath) of a file from the
+// repository root
 func GetRelativePath(repoPath, filePath string) (string, error) {
 	relPath, err := filepath.Rel(repoPath, filePath)
 	if err != nil {
 		return "", fmt.Errorf("failed to get relative path: %w", err)
 	}
-	return relPath, nil
+	return CanonicalPath(relPath), nil
 }
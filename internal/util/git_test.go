@@ -0,0 +1,71 @@
+package util
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// gitRun runs a git command in dir, failing the test on error
+func gitRun(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "protocol.file.allow=always"}, args...)...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+}
+
+func TestReadFileOptimized_Submodule(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+
+	// A library with two commits; the superproject pins the first
+	lib := filepath.Join(dir, "lib")
+	os.MkdirAll(lib, 0755)
+	gitRun(t, lib, "init", "-q")
+	os.WriteFile(filepath.Join(lib, "lib.go"), []byte("package lib // pinned\n"), 0644)
+	gitRun(t, lib, "add", ".")
+	gitRun(t, lib, "commit", "-q", "-m", "pinned")
+
+	super := filepath.Join(dir, "super")
+	os.MkdirAll(super, 0755)
+	gitRun(t, super, "init", "-q")
+	os.WriteFile(filepath.Join(super, "main.go"), []byte("package main\n"), 0644)
+	gitRun(t, super, "submodule", "add", "-q", lib, "lib")
+	gitRun(t, super, "add", ".")
+	gitRun(t, super, "commit", "-q", "-m", "init")
+
+	// The submodule checkout moves past the pinned commit and gains an untracked file
+	sub := filepath.Join(super, "lib")
+	os.WriteFile(filepath.Join(sub, "lib.go"), []byte("package lib // newer\n"), 0644)
+	gitRun(t, sub, "commit", "-q", "-am", "newer")
+	os.WriteFile(filepath.Join(sub, "scratch.go"), []byte("package lib\n"), 0644)
+
+	info, err := GetGitInfo(super)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subDir, _ := SubmoduleFor(info, filepath.Join(sub, "lib.go")); subDir == "" {
+		t.Fatalf("submodule not found in %v", info.Submodules)
+	}
+
+	for _, useHead := range []bool{true, false} {
+		content, err := ReadFileOptimized(super, filepath.Join(sub, "lib.go"), useHead, info)
+		if err != nil || string(content) != "package lib // pinned\n" {
+			t.Errorf("useHead=%v: read %q, %v; want the pinned version", useHead, content, err)
+		}
+	}
+	if _, err := ReadFileOptimized(super, filepath.Join(sub, "scratch.go"), true, info); err == nil {
+		t.Error("untracked submodule file was read in HEAD mode")
+	}
+	if content, err := ReadFileOptimized(super, filepath.Join(sub, "scratch.go"), false, info); err != nil || len(content) == 0 {
+		t.Errorf("untracked submodule file not read from disk: %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// JournalOp is the kind of operation recorded by an IndexJournal entry
+type JournalOp string
+
+const (
+	JournalBuildStarted   JournalOp = "build_started"
+	JournalBuildCompleted JournalOp = "build_completed"
+	// One entry per processor and file, named after the store the processor writes to
+	JournalNodesWritten   JournalOp = "nodes_written"   // graph processors
+	JournalChunksUpserted JournalOp = "chunks_upserted" // embedding processor
+	JournalFileProcessed  JournalOp = "file_processed"  // any other processor
+)
+
+// JournalEntry is one journaled index operation. File operations name the processor and
+// the exact file version it was run on, which is enough to run it again.
+type JournalEntry struct {
+	Seq       int64     `json:"seq"`
+	Time      time.Time `json:"time"`
+	Op        JournalOp `json:"op"`
+	Processor string    `json:"processor,omitempty"`
+	FileID    int32     `json:"file_id,omitempty"`
+	Path      string    `json:"path,omitempty"`
+	SHA       string    `json:"sha,omitempty"`
+	Commit    string    `json:"commit,omitempty"` // "" for working tree versions
+}
+
+// IsFileOp reports whether the entry records a processor run on a file
+func (e JournalEntry) IsFileOp() bool {
+	switch e.Op {
+	case JournalNodesWritten, JournalChunksUpserted, JournalFileProcessed:
+		return true
+	}
+	return false
+}
+
+// IndexJournal appends index operations to a JSON Lines file before they are applied, so
+// that stores restored from a backup can be brought up to date by replaying the entries
+// recorded since the backup. Entries reach the file as they are appended but are only
+// synced to disk on Close. A nil *IndexJournal is valid and records nothing.
+type IndexJournal struct {
+	file *os.File
+	mu   sync.Mutex
+	seq  int64
+}
+
+// OpenIndexJournal opens the journal in file for appending, creating it if needed.
+// Sequence numbers continue from the last entry already in the file, and a truncated last
+// line is dropped so that new entries start on a line of their own.
+func OpenIndexJournal(file string) (*IndexJournal, error) {
+	data, err := os.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		if err := os.Truncate(file, int64(bytes.LastIndexByte(data, '\n')+1)); err != nil {
+			return nil, fmt.Errorf("failed to drop truncated journal entry: %w", err)
+		}
+	}
+	entries, err := ReadIndexJournal(file, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create journal directory: %w", err)
+	}
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+
+	journal := &IndexJournal{file: f}
+	if len(entries) > 0 {
+		journal.seq = entries[len(entries)-1].Seq
+	}
+	return journal, nil
+}
+
+// Append assigns the entry the next sequence number and the current time, and writes it
+func (j *IndexJournal) Append(entry JournalEntry) error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.seq++
+	entry.Seq = j.seq
+	entry.Time = time.Now().UTC()
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode journal entry: %w", err)
+	}
+	if _, err := j.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write journal entry: %w", err)
+	}
+	return nil
+}
+
+// Close syncs and closes the journal file
+func (j *IndexJournal) Close() error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err := j.file.Sync(); err != nil {
+		j.file.Close()
+		return fmt.Errorf("failed to sync journal: %w", err)
+	}
+	return j.file.Close()
+}
+
+// ReadIndexJournal returns the entries of the journal in file recorded at or after since, in
+// sequence order. A missing file has no entries. A truncated last line, left by a process
+// that died mid-write, is ignored.
+func ReadIndexJournal(file string, since time.Time) ([]JournalEntry, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+	defer f.Close()
+
+	var entries []JournalEntry
+	var pending error
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if pending != nil {
+			// Only the last line may be damaged
+			return nil, pending
+		}
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			pending = fmt.Errorf("failed to parse journal %s line %d: %w", file, line, err)
+			continue
+		}
+		if !entry.Time.Before(since) {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,104 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIndexJournal_ReopenContinuesSequence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "journals", "repo.jsonl")
+
+	journal, err := OpenIndexJournal(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	journal.Append(JournalEntry{Op: JournalBuildStarted})
+	journal.Append(JournalEntry{Op: JournalNodesWritten, Processor: "CodeGraph", FileID: 7, Path: "a.go", SHA: "abc"})
+	if err := journal.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	journal, err = OpenIndexJournal(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	journal.Append(JournalEntry{Op: JournalBuildCompleted})
+	journal.Close()
+
+	entries, err := ReadIndexJournal(file, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(entries))
+	}
+	for i, entry := range entries {
+		if entry.Seq != int64(i+1) {
+			t.Errorf("entry %d has seq %d", i, entry.Seq)
+		}
+	}
+	if !entries[1].IsFileOp() || entries[1].Path != "a.go" || entries[1].FileID != 7 {
+		t.Errorf("unexpected file entry %+v", entries[1])
+	}
+}
+
+func TestIndexJournal_TruncatedLastLine(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "repo.jsonl")
+	data := `{"seq":1,"time":"2026-01-01T00:00:00Z","op":"build_started"}` + "\n" + `{"seq":2,"ti`
+	if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := ReadIndexJournal(file, time.Time{})
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("ReadIndexJournal = %d entries, %v; want 1 entry", len(entries), err)
+	}
+
+	// Reopening drops the partial entry so the next one is readable
+	journal, err := OpenIndexJournal(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	journal.Append(JournalEntry{Op: JournalBuildCompleted})
+	journal.Close()
+
+	entries, err = ReadIndexJournal(file, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[1].Seq != 2 || entries[1].Op != JournalBuildCompleted {
+		t.Fatalf("unexpected entries after reopen: %+v", entries)
+	}
+}
+
+func TestReadIndexJournal_Since(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "repo.jsonl")
+	data := `{"seq":1,"time":"2026-01-01T00:00:00Z","op":"build_started"}` + "\n" +
+		`{"seq":2,"time":"2026-01-02T00:00:00Z","op":"build_completed"}` + "\n"
+	if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := ReadIndexJournal(file, time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Seq != 2 {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+
+	// A damaged line before the last one is an error
+	if err := os.WriteFile(file, []byte("garbage\n"+data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadIndexJournal(file, time.Time{}); err == nil {
+		t.Error("expected an error for a damaged entry in the middle of the journal")
+	}
+
+	// A missing journal has no entries
+	if entries, err := ReadIndexJournal(filepath.Join(t.TempDir(), "none.jsonl"), time.Time{}); err != nil || entries != nil {
+		t.Errorf("missing journal = %v, %v", entries, err)
+	}
+}
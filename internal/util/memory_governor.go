@@ -0,0 +1,186 @@
+package util
+
+import (
+	"context"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// MemoryPressure describes how close heap usage is to the configured budget
+type MemoryPressure int
+
+const (
+	MemoryPressureNone MemoryPressure = iota // below the soft limit
+	MemoryPressureSoft                       // above the soft limit: flush buffers, halve workers
+	MemoryPressureHard                       // at or above the budget: flush, GC, single worker
+)
+
+func (p MemoryPressure) String() string {
+	switch p {
+	case MemoryPressureSoft:
+		return "soft"
+	case MemoryPressureHard:
+		return "hard"
+	default:
+		return "none"
+	}
+}
+
+const (
+	memoryGovernorSoftRatio     = 0.8
+	memoryGovernorCheckInterval = 250 * time.Millisecond
+	memoryGovernorPollInterval  = 50 * time.Millisecond
+)
+
+// MemoryGovernor keeps index builds within a heap budget. Workers call Admit before taking
+// on a new file; when the budget is approached, registered flushers are run to drain
+// buffers, and the number of workers allowed to proceed is reduced (down to one, so a
+// build always makes progress). A nil *MemoryGovernor admits everything.
+type MemoryGovernor struct {
+	budget   uint64
+	logger   *zap.Logger
+	readHeap func() uint64
+
+	mu        sync.Mutex
+	flushers  map[string]func()
+	level     MemoryPressure
+	heap      uint64
+	lastCheck time.Time
+	lastFlush time.Time
+}
+
+// NewMemoryGovernor creates a governor for the given heap budget. Returns nil if budgetBytes is 0.
+func NewMemoryGovernor(budgetBytes uint64, logger *zap.Logger) *MemoryGovernor {
+	if budgetBytes == 0 {
+		return nil
+	}
+	return &MemoryGovernor{
+		budget:   budgetBytes,
+		logger:   logger,
+		readHeap: readHeapAlloc,
+		flushers: make(map[string]func()),
+	}
+}
+
+// RegisterFlusher registers a callback that releases buffered data under memory pressure.
+// Registering the same name again replaces the previous callback.
+func (g *MemoryGovernor) RegisterFlusher(name string, flush func()) {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	g.flushers[name] = flush
+	g.mu.Unlock()
+}
+
+// Level returns the current memory pressure, re-reading heap statistics at most every 250ms
+func (g *MemoryGovernor) Level() MemoryPressure {
+	if g == nil {
+		return MemoryPressureNone
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.refreshLocked(false)
+}
+
+// AllowedWorkers returns how many of numWorkers may take on new work at the current pressure
+func (g *MemoryGovernor) AllowedWorkers(numWorkers int) int {
+	if numWorkers < 1 {
+		numWorkers = 1
+	}
+	switch g.Level() {
+	case MemoryPressureHard:
+		return 1
+	case MemoryPressureSoft:
+		if numWorkers/2 < 1 {
+			return 1
+		}
+		return numWorkers / 2
+	default:
+		return numWorkers
+	}
+}
+
+// Admit blocks worker (0-based, out of numWorkers) until it is allowed to take on new work.
+// Worker 0 is always admitted after buffers have been flushed, so intake never stalls completely.
+func (g *MemoryGovernor) Admit(ctx context.Context, worker, numWorkers int) error {
+	if g == nil {
+		return nil
+	}
+	for {
+		level := g.Level()
+		if level == MemoryPressureNone {
+			return nil
+		}
+		g.relieve(level)
+		if worker < g.AllowedWorkers(numWorkers) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(memoryGovernorPollInterval):
+		}
+	}
+}
+
+// relieve runs the flushers (and a GC at hard pressure), at most once per check interval
+func (g *MemoryGovernor) relieve(level MemoryPressure) {
+	g.mu.Lock()
+	if time.Since(g.lastFlush) < memoryGovernorCheckInterval {
+		g.mu.Unlock()
+		return
+	}
+	g.lastFlush = time.Now()
+	flushers := make([]func(), 0, len(g.flushers))
+	for _, flush := range g.flushers {
+		flushers = append(flushers, flush)
+	}
+	heap := g.heap
+	g.mu.Unlock()
+
+	g.logger.Info("Memory budget approached, releasing buffers",
+		zap.String("pressure", level.String()),
+		zap.Uint64("heap_bytes", heap),
+		zap.Uint64("budget_bytes", g.budget),
+		zap.Int("flushers", len(flushers)))
+
+	for _, flush := range flushers {
+		flush()
+	}
+	if level == MemoryPressureHard {
+		debug.FreeOSMemory()
+	}
+
+	g.mu.Lock()
+	g.refreshLocked(true)
+	g.mu.Unlock()
+}
+
+func (g *MemoryGovernor) refreshLocked(force bool) MemoryPressure {
+	if !force && time.Since(g.lastCheck) < memoryGovernorCheckInterval {
+		return g.level
+	}
+	g.lastCheck = time.Now()
+	g.heap = g.readHeap()
+
+	switch {
+	case g.heap >= g.budget:
+		g.level = MemoryPressureHard
+	case float64(g.heap) >= float64(g.budget)*memoryGovernorSoftRatio:
+		g.level = MemoryPressureSoft
+	default:
+		g.level = MemoryPressureNone
+	}
+	return g.level
+}
+
+func readHeapAlloc() uint64 {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
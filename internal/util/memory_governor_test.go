@@ -0,0 +1,68 @@
+package util
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestMemoryGovernor_Levels(t *testing.T) {
+	g := NewMemoryGovernor(1000, zap.NewNop())
+	heap := uint64(100)
+	g.readHeap = func() uint64 { return heap }
+
+	cases := []struct {
+		heap    uint64
+		level   MemoryPressure
+		workers int
+	}{
+		{100, MemoryPressureNone, 4},
+		{850, MemoryPressureSoft, 2},
+		{1200, MemoryPressureHard, 1},
+	}
+	for _, tc := range cases {
+		heap = tc.heap
+		g.mu.Lock()
+		g.refreshLocked(true)
+		g.mu.Unlock()
+		if got := g.Level(); got != tc.level {
+			t.Errorf("heap %d: expected level %v, got %v", tc.heap, tc.level, got)
+		}
+		if got := g.AllowedWorkers(4); got != tc.workers {
+			t.Errorf("heap %d: expected %d workers, got %d", tc.heap, tc.workers, got)
+		}
+	}
+}
+
+func TestMemoryGovernor_AdmitFlushes(t *testing.T) {
+	g := NewMemoryGovernor(1000, zap.NewNop())
+	heap := uint64(1200)
+	g.readHeap = func() uint64 { return heap }
+
+	flushed := 0
+	g.RegisterFlusher("buffers", func() {
+		flushed++
+		heap = 100 // flushing releases the memory
+	})
+
+	if err := g.Admit(context.Background(), 3, 4); err != nil {
+		t.Fatalf("admit: %v", err)
+	}
+	if flushed != 1 {
+		t.Fatalf("expected one flush, got %d", flushed)
+	}
+	if g.Level() != MemoryPressureNone {
+		t.Fatalf("expected pressure to drop after flush, got %v", g.Level())
+	}
+}
+
+func TestMemoryGovernor_Nil(t *testing.T) {
+	var g *MemoryGovernor
+	if NewMemoryGovernor(0, zap.NewNop()) != nil {
+		t.Fatal("expected nil governor for zero budget")
+	}
+	if err := g.Admit(context.Background(), 5, 2); err != nil {
+		t.Fatalf("nil governor should admit: %v", err)
+	}
+}
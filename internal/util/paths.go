@@ -0,0 +1,33 @@
+package util
+
+import (
+	"path"
+	"strings"
+)
+
+// CanonicalPath returns the canonical form of a repository-relative path as stored in the
+// graph and file version tables: forward slashes, cleaned, with no leading "./" or "/".
+// Windows-style separators are converted regardless of the host OS. An empty path stays empty.
+func CanonicalPath(p string) string {
+	if p == "" {
+		return ""
+	}
+	p = path.Clean(strings.ReplaceAll(p, `\`, "/"))
+	p = strings.TrimLeft(p, "/")
+	if p == "." {
+		return ""
+	}
+	return p
+}
+
+// PathKey returns a case-folded canonical path for matching paths that came from a
+// case-insensitive filesystem
+func PathKey(p string) string {
+	return strings.ToLower(CanonicalPath(p))
+}
+
+// SamePath reports whether two repository-relative paths refer to the same file, ignoring
+// separator style and letter case
+func SamePath(a, b string) bool {
+	return PathKey(a) == PathKey(b)
+}
@@ -0,0 +1,32 @@
+package util
+
+import "testing"
+
+func TestCanonicalPath(t *testing.T) {
+	cases := map[string]string{
+		"":                   "",
+		".":                  "",
+		"src/main.go":        "src/main.go",
+		"./src/main.go":      "src/main.go",
+		`src\pkg\main.go`:    "src/pkg/main.go",
+		"src//pkg/../a.go":   "src/a.go",
+		"/src/main.go":       "src/main.go",
+		`.\src\Main.go`:      "src/Main.go",
+		"src/dir/":           "src/dir",
+		"../outside/file.go": "../outside/file.go",
+	}
+	for in, want := range cases {
+		if got := CanonicalPath(in); got != want {
+			t.Errorf("CanonicalPath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestSamePath(t *testing.T) {
+	if !SamePath(`Src\Main.go`, "src/main.go") {
+		t.Error("expected paths differing in case and separators to match")
+	}
+	if SamePath("src/main.go", "src/main_test.go") {
+		t.Error("expected different files not to match")
+	}
+}
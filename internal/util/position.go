@@ -0,0 +1,147 @@
+package util
+
+import (
+	"sort"
+	"unicode/utf8"
+)
+
+// LineIndex maps between the two position systems used for source ranges: tree-sitter
+// reports (row, byte column) while LSP uses (line, character) where character counts
+// UTF-16 code units. They only agree on ASCII lines; a line containing CJK text or emoji
+// shifts every later column unless it is converted. Lines end at '\n'; a trailing '\r'
+// is treated as part of the line terminator.
+type LineIndex struct {
+	content    []byte
+	lineStarts []int
+}
+
+// NewLineIndex builds a LineIndex over content
+func NewLineIndex(content []byte) *LineIndex {
+	starts := []int{0}
+	for i, b := range content {
+		if b == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return &LineIndex{content: content, lineStarts: starts}
+}
+
+// LineCount returns the number of lines, counting a trailing empty line after a final newline
+func (li *LineIndex) LineCount() int {
+	return len(li.lineStarts)
+}
+
+// Line returns the bytes of line (0-based) without its line terminator, or nil when out of range
+func (li *LineIndex) Line(line int) []byte {
+	if line < 0 || line >= len(li.lineStarts) {
+		return nil
+	}
+	start := li.lineStarts[line]
+	end := len(li.content)
+	if line+1 < len(li.lineStarts) {
+		end = li.lineStarts[line+1] - 1
+	}
+	if end > start && li.content[end-1] == '\r' {
+		end--
+	}
+	return li.content[start:end]
+}
+
+// UTF16Column converts a byte column on line to a UTF-16 character offset
+func (li *LineIndex) UTF16Column(line, byteCol int) int {
+	return ByteColumnToUTF16(li.Line(line), byteCol)
+}
+
+// ByteColumn converts a UTF-16 character offset on line to a byte column
+func (li *LineIndex) ByteColumn(line, utf16Col int) int {
+	return UTF16ToByteColumn(li.Line(line), utf16Col)
+}
+
+// Offset returns the byte offset into the content of an LSP (line, UTF-16 character)
+// position, clamped to the content
+func (li *LineIndex) Offset(line, utf16Col int) int {
+	if line < 0 {
+		return 0
+	}
+	if line >= len(li.lineStarts) {
+		return len(li.content)
+	}
+	return li.lineStarts[line] + li.ByteColumn(line, utf16Col)
+}
+
+// Position returns the LSP (line, UTF-16 character) position of a byte offset into the content
+func (li *LineIndex) Position(offset int) (line, utf16Col int) {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > len(li.content) {
+		offset = len(li.content)
+	}
+	line = sort.Search(len(li.lineStarts), func(i int) bool { return li.lineStarts[i] > offset }) - 1
+	return line, li.UTF16Column(line, offset-li.lineStarts[line])
+}
+
+// Slice returns the content between two LSP positions
+func (li *LineIndex) Slice(startLine, startChar, endLine, endChar int) []byte {
+	start := li.Offset(startLine, startChar)
+	end := li.Offset(endLine, endChar)
+	if end < start {
+		return nil
+	}
+	return li.content[start:end]
+}
+
+// ByteColumnToUTF16 converts a byte column within line to the number of UTF-16 code units
+// before it. Columns past the end of the line are extended by their excess bytes so
+// positions at or after the line terminator stay distinct.
+func ByteColumnToUTF16(line []byte, byteCol int) int {
+	if byteCol <= 0 {
+		return 0
+	}
+	excess := 0
+	if byteCol > len(line) {
+		excess = byteCol - len(line)
+		byteCol = len(line)
+	}
+	units := 0
+	for i := 0; i < byteCol; {
+		r, size := utf8.DecodeRune(line[i:])
+		if i+size > byteCol {
+			// Column points inside a multi-byte sequence; count the partial rune once
+			units++
+			break
+		}
+		units += utf16Len(r)
+		i += size
+	}
+	return units + excess
+}
+
+// UTF16ToByteColumn converts a UTF-16 character offset within line to a byte column.
+// An offset that falls between the two halves of a surrogate pair maps to the start of
+// that character; offsets past the end of the line map to its length.
+func UTF16ToByteColumn(line []byte, utf16Col int) int {
+	units := 0
+	for i := 0; i < len(line); {
+		if units >= utf16Col {
+			return i
+		}
+		r, size := utf8.DecodeRune(line[i:])
+		n := utf16Len(r)
+		if units+n > utf16Col {
+			return i
+		}
+		units += n
+		i += size
+	}
+	return len(line)
+}
+
+// utf16Len is the number of UTF-16 code units needed to encode r; invalid bytes decode as
+// utf8.RuneError and count as one unit
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
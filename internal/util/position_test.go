@@ -0,0 +1,75 @@
+package util
+
+import (
+	"strings"
+	"testing"
+)
+
+// Each line mixes ASCII with multi-byte text: "世界" is 3 bytes / 1 UTF-16 unit per
+// character, "😀" is 4 bytes / 2 UTF-16 units
+const positionSource = "package main\r\n" +
+	"// 世界 greeting\n" +
+	"func hi() string { return \"😀\" + name }\n" +
+	"var name = \"名前\"\n"
+
+func TestLineIndexColumns(t *testing.T) {
+	index := NewLineIndex([]byte(positionSource))
+
+	if got := index.LineCount(); got != 5 {
+		t.Fatalf("LineCount() = %d, want 5", got)
+	}
+	if got := string(index.Line(0)); got != "package main" {
+		t.Errorf("Line(0) = %q, want CR stripped", got)
+	}
+
+	cases := []struct {
+		line   int
+		substr string
+		utf16  int
+	}{
+		{1, "greeting", 6},
+		{2, "+ name", 31},
+		{3, "\"名前\"", 11},
+	}
+	for _, tc := range cases {
+		byteCol := strings.Index(string(index.Line(tc.line)), tc.substr)
+		if got := index.UTF16Column(tc.line, byteCol); got != tc.utf16 {
+			t.Errorf("UTF16Column(%d, %d) for %q = %d, want %d", tc.line, byteCol, tc.substr, got, tc.utf16)
+		}
+		if got := index.ByteColumn(tc.line, tc.utf16); got != byteCol {
+			t.Errorf("ByteColumn(%d, %d) for %q = %d, want %d", tc.line, tc.utf16, tc.substr, got, byteCol)
+		}
+	}
+}
+
+func TestLineIndexSliceAndPosition(t *testing.T) {
+	index := NewLineIndex([]byte(positionSource))
+
+	// The emoji string literal on line 2 spans UTF-16 characters 26..30
+	if got := string(index.Slice(2, 26, 2, 30)); got != "\"😀\"" {
+		t.Errorf("Slice = %q, want the emoji literal", got)
+	}
+
+	offset := strings.Index(positionSource, "name }")
+	line, char := index.Position(offset)
+	if line != 2 || char != 33 {
+		t.Errorf("Position(%d) = (%d, %d), want (2, 33)", offset, line, char)
+	}
+	if got := index.Offset(line, char); got != offset {
+		t.Errorf("Offset(%d, %d) = %d, want %d", line, char, got, offset)
+	}
+}
+
+func TestUTF16ToByteColumnInsideSurrogatePair(t *testing.T) {
+	line := []byte("a😀b")
+	// Character 2 is the second half of the emoji's surrogate pair
+	if got := UTF16ToByteColumn(line, 2); got != 1 {
+		t.Errorf("UTF16ToByteColumn mid-pair = %d, want 1", got)
+	}
+	if got := UTF16ToByteColumn(line, 3); got != 5 {
+		t.Errorf("UTF16ToByteColumn after emoji = %d, want 5", got)
+	}
+	if got := ByteColumnToUTF16(line, len(line)); got != 4 {
+		t.Errorf("ByteColumnToUTF16 at end = %d, want 4", got)
+	}
+}
@@ -0,0 +1,73 @@
+package util
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+)
+
+// Profile file names written by StartProfiling
+const (
+	CPUProfileFile  = "cpu.pprof"
+	HeapProfileFile = "heap.pprof"
+)
+
+// StartProfiling starts a CPU profile written to dir/cpu.pprof. The returned stop function
+// ends it and writes a heap profile to dir/heap.pprof. Both open with `go tool pprof`.
+func StartProfiling(dir string) (stop func() error, err error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create profile directory: %w", err)
+	}
+	cpuFile, err := os.Create(filepath.Join(dir, CPUProfileFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CPU profile: %w", err)
+	}
+	if err := pprof.StartCPUProfile(cpuFile); err != nil {
+		cpuFile.Close()
+		return nil, fmt.Errorf("failed to start CPU profile: %w", err)
+	}
+
+	return func() error {
+		pprof.StopCPUProfile()
+		if err := cpuFile.Close(); err != nil {
+			return fmt.Errorf("failed to write CPU profile: %w", err)
+		}
+		return WriteProfile("heap", filepath.Join(dir, HeapProfileFile))
+	}, nil
+}
+
+// WriteProfile writes the named runtime profile (heap, goroutine, allocs, block, mutex,
+// threadcreate) to path in the pprof format. The heap profile is taken after a GC so it
+// shows live objects.
+func WriteProfile(name, path string) error {
+	if pprof.Lookup(name) == nil {
+		return fmt.Errorf("unknown profile %q", name)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s profile: %w", name, err)
+	}
+	if err := WriteProfileTo(name, f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// WriteProfileTo is WriteProfile writing to w
+func WriteProfileTo(name string, w io.Writer) error {
+	profile := pprof.Lookup(name)
+	if profile == nil {
+		return fmt.Errorf("unknown profile %q", name)
+	}
+	if name == "heap" {
+		runtime.GC()
+	}
+	if err := profile.WriteTo(w, 0); err != nil {
+		return fmt.Errorf("failed to write %s profile: %w", name, err)
+	}
+	return nil
+}
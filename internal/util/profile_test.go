@@ -0,0 +1,27 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStartProfiling(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "profiles")
+	stop, err := StartProfiling(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stop(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{CPUProfileFile, HeapProfileFile} {
+		if info, err := os.Stat(filepath.Join(dir, name)); err != nil || info.Size() == 0 {
+			t.Errorf("%s not written: %v", name, err)
+		}
+	}
+
+	if err := WriteProfile("nope", filepath.Join(dir, "nope.pprof")); err == nil {
+		t.Error("expected an error for an unknown profile")
+	}
+}
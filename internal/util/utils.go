@@ -24,7 +24,7 @@ func ToRelativePath(rootPath, fullPath string) string {
 	if err != nil {
 		return fullPath
 	}
-	return relPath
+	return CanonicalPath(relPath)
 }
 
 func ExtractPathFromURI(uri string) string {
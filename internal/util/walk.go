@@ -1,10 +1,15 @@
 package util
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"sync"
+	"sync/atomic"
+
+	"bot-go/internal/config"
 
 	"go.uber.org/zap"
 )
@@ -14,117 +19,306 @@ import (
 type WalkFunc func(path string, err error) error
 type SkipFunc func(path string, isDir bool) bool
 
-type walkItem struct {
-	path string
-	//info fs.FileInfo
-}
-
 // Walk traverses a directory tree concurrently, calling walkFn for each file
 // and directory. Unlike filepath.Walk, this implementation does not guarantee
 // any particular ordering of children directories and files.
 // gcThreshold controls how often to trigger GC (every N files). Set to 0 to disable.
 // Uses 2 worker goroutines to process files concurrently.
 func WalkDirTree(root string, walkFn WalkFunc, skipPath SkipFunc, logger *zap.Logger, gcThreshold int64, numThreads int) error {
-	processedCount := int64(0)
-	// Create channels for work distribution
-	workQueue := make(chan walkItem, 2)
-	var wg sync.WaitGroup
-	var mu sync.Mutex
+	return WalkDirTreeWithGovernor(root, walkFn, skipPath, logger, gcThreshold, numThreads, nil)
+}
+
+// WalkDirTreeWithGovernor is WalkDirTree with workers admitted through a MemoryGovernor,
+// so fewer files are in flight when the heap approaches the governor's budget
+func WalkDirTreeWithGovernor(root string, walkFn WalkFunc, skipPath SkipFunc, logger *zap.Logger, gcThreshold int64, numThreads int, governor *MemoryGovernor) error {
+	return WalkDirTreeResumable(context.Background(), root, walkFn, skipPath, logger, gcThreshold, numThreads, governor, nil)
+}
+
+// WalkDirTreeResumable is WalkDirTreeWithGovernor with a resumable cursor. Files up to the
+// cursor position are skipped, and the cursor advances as files complete, so an interrupted
+// walk restarts where it left off. The cursor is not cleared here; callers clear it once
+// the work that depends on the whole walk has finished. cursor may be nil.
+func WalkDirTreeResumable(ctx context.Context, root string, walkFn WalkFunc, skipPath SkipFunc, logger *zap.Logger, gcThreshold int64, numThreads int, governor *MemoryGovernor, cursor *WalkCursor) error {
+	return WalkDirTreeWithOptions(ctx, root, walkFn, skipPath, logger, gcThreshold, numThreads, governor, cursor, WalkOptions{})
+}
+
+// WalkDirTreeWithOptions is WalkDirTreeResumable with an explicit symlink and submodule policy
+func WalkDirTreeWithOptions(ctx context.Context, root string, walkFn WalkFunc, skipPath SkipFunc, logger *zap.Logger, gcThreshold int64, numThreads int, governor *MemoryGovernor, cursor *WalkCursor, opts WalkOptions) error {
+	if _, err := os.Lstat(root); err != nil {
+		logger.Error("WalkDirTree - Failed to stat root", zap.String("path", root), zap.Error(err))
+		return nil
+	}
+	if numThreads < 1 {
+		numThreads = 1
+	}
+
+	walker := NewFileWalker(root, skipPath, cursor, 2*numThreads, logger)
+	walker.SetOptions(opts)
+	files := walker.Walk(ctx)
 
-	// Start 2 worker goroutines
+	var processedCount atomic.Int64
+	var wg sync.WaitGroup
 	for i := 0; i < numThreads; i++ {
 		wg.Add(1)
-		go func() {
+		go func(worker int) {
 			defer wg.Done()
-			for item := range workQueue {
-				// Increment processed count
-				mu.Lock()
-				processedCount++
-				mu.Unlock()
+			for path := range files {
+				// Wait for memory headroom before taking on the file
+				governor.Admit(ctx, worker, numThreads)
 
 				// Trigger GC if needed
-				if gcThreshold > 0 && processedCount%gcThreshold == 0 {
+				count := processedCount.Add(1)
+				if gcThreshold > 0 && count%gcThreshold == 0 {
 					logger.Info("WalkDirTree - Triggering GC after processing files",
-						zap.Int64("files_processed", processedCount))
+						zap.Int64("files_processed", count))
 					runtime.GC()
 				}
 
 				// Call the walk function
-				err := walkFn(item.path, nil)
+				err := walkFn(path, nil)
 				if err != nil {
-					logger.Error("WalkDirTree - Failed to process file", zap.String("path", item.path), zap.Error(err))
-					if err != filepath.SkipDir {
-						/*select {
-						case errorChan <- err:
-						default:
-						}*/
-						// Continue processing other files even if one fails
-					}
+					// Continue processing other files even if one fails. The cursor is not
+					// advanced past a failed file so a resumed walk retries it.
+					logger.Error("WalkDirTree - Failed to process file", zap.String("path", path), zap.Error(err))
+					continue
 				}
+				walker.Done(path)
 			}
-		}()
+		}(i)
 	}
 
-	// Walk the directory tree and send items to workers
-	_, err := os.Lstat(root)
-	if err != nil {
-		logger.Error("WalkDirTree - Failed to stat root", zap.String("path", root), zap.Error(err))
-		// Send error item to work queue
-		return nil
+	// Wait for all workers to finish
+	wg.Wait()
+
+	if err := cursor.Save(); err != nil {
+		logger.Warn("WalkDirTree - Failed to save walk cursor", zap.Error(err))
 	}
+	return walker.Err()
+}
 
-	processedCount = 0
+// WalkOptions is the policy for links and nested repositories met during a walk. The zero
+// value skips symbolic links and git submodules.
+type WalkOptions struct {
+	// FollowSymlinks walks symlinked files and directories. Directory links are resolved and
+	// each real directory is visited at most once, so link cycles terminate.
+	FollowSymlinks bool
 
-	err = walk(root, workQueue, skipPath, &processedCount, gcThreshold, logger)
-	close(workQueue)
+	// IncludeSubmodules descends into git submodules (directories below the root with their
+	// own .git entry)
+	IncludeSubmodules bool
+}
 
-	// Wait for all workers to finish
-	wg.Wait()
+// WalkOptionsFor returns the walk policy configured for a repository
+func WalkOptionsFor(repo *config.Repository) WalkOptions {
+	if repo == nil {
+		return WalkOptions{}
+	}
+	return WalkOptions{FollowSymlinks: repo.FollowSymlinks, IncludeSubmodules: repo.IncludeSubmodules}
+}
 
-	// Check for errors
-	if err != nil {
-		return err
+// FileWalker produces the files of a directory tree on a bounded channel in a deterministic
+// depth-first order (entries sorted by name). Consumers pull files at their own pace and
+// acknowledge them with Done; the cursor then advances past every file whose predecessors
+// have all been acknowledged.
+type FileWalker struct {
+	root       string
+	skipPath   SkipFunc
+	cursor     *WalkCursor
+	bufferSize int
+	logger     *zap.Logger
+	opts       WalkOptions
+	visited    map[string]bool // real paths of walked directories, used when following symlinks
+
+	mu      sync.Mutex
+	pending []string        // emitted but not yet covered by the cursor, in walk order
+	done    map[string]bool // acknowledged files still in pending
+	err     error
+}
+
+// NewFileWalker creates a walker for root. cursor may be nil; bufferSize bounds how many
+// files are queued ahead of the consumers.
+func NewFileWalker(root string, skipPath SkipFunc, cursor *WalkCursor, bufferSize int, logger *zap.Logger) *FileWalker {
+	if bufferSize < 1 {
+		bufferSize = 1
+	}
+	return &FileWalker{
+		root:       root,
+		skipPath:   skipPath,
+		cursor:     cursor,
+		bufferSize: bufferSize,
+		logger:     logger,
+		done:       make(map[string]bool),
 	}
+}
 
-	return nil
+// SetOptions sets the symlink and submodule policy. It must be called before Walk.
+func (w *FileWalker) SetOptions(opts WalkOptions) {
+	w.opts = opts
+}
+
+// Walk starts the traversal and returns the channel of file paths. The channel is closed
+// when the traversal completes or ctx is cancelled.
+func (w *FileWalker) Walk(ctx context.Context) <-chan string {
+	files := make(chan string, w.bufferSize)
+	resumeAfter := w.cursor.Position()
+	if resumeAfter != "" {
+		w.logger.Info("WalkDirTree - Resuming walk from cursor",
+			zap.String("root", w.root),
+			zap.String("after", resumeAfter))
+	}
+
+	if w.opts.FollowSymlinks {
+		w.visited = make(map[string]bool)
+	}
+
+	go func() {
+		defer close(files)
+		if err := w.walk(ctx, w.root, resumeAfter, files); err != nil && err != filepath.SkipDir {
+			w.mu.Lock()
+			w.err = err
+			w.mu.Unlock()
+		}
+	}()
+	return files
+}
+
+// Done acknowledges that a file produced by Walk has been processed
+func (w *FileWalker) Done(path string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.done[path] = true
+	for len(w.pending) > 0 && w.done[w.pending[0]] {
+		next := w.pending[0]
+		delete(w.done, next)
+		w.pending = w.pending[1:]
+		w.cursor.Advance(w.relative(next))
+	}
 }
 
-// walk recursively traverses the directory tree and sends items to the work queue
-func walk(path string, fileQueue chan<- walkItem, skipPath SkipFunc, processedCount *int64, gcThreshold int64, logger *zap.Logger) error {
+// Err returns the error that stopped the traversal, if any (including context cancellation)
+func (w *FileWalker) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+func (w *FileWalker) walk(ctx context.Context, path, resumeAfter string, files chan<- string) error {
 	// This must be a directory. Don't call for files
-	if skipPath(path, true) {
-		logger.Info("WalkDirTree - Skipping path", zap.String("path", path))
+	if w.skipPath(path, true) {
+		w.logger.Info("WalkDirTree - Skipping path", zap.String("path", path))
 		return filepath.SkipDir
 	}
 
-	// Read directory entries
+	if w.visited != nil {
+		// Resolve the real directory so a symlink back to an ancestor (or to a directory
+		// already walked through another link) is not walked again
+		real, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			w.logger.Warn("WalkDirTree - Failed to resolve directory", zap.String("path", path), zap.Error(err))
+			return nil
+		}
+		if w.visited[real] {
+			w.logger.Info("WalkDirTree - Skipping already visited directory (symlink cycle or alias)",
+				zap.String("path", path), zap.String("target", real))
+			return filepath.SkipDir
+		}
+		w.visited[real] = true
+	}
+
+	// Read directory entries (sorted by name, which makes the walk order deterministic)
 	entries, err := os.ReadDir(path)
 	if err != nil {
-		logger.Error("WalkDirTree - Failed to read directory", zap.String("path", path), zap.Error(err))
+		w.logger.Error("WalkDirTree - Failed to read directory", zap.String("path", path), zap.Error(err))
 		return nil
 	}
 
-	// Recursively walk child entries
 	for _, entry := range entries {
 		childPath := filepath.Join(path, entry.Name())
-		*processedCount++
+		rel := w.relative(childPath)
+
+		isDir := entry.IsDir()
+		if entry.Type()&os.ModeSymlink != 0 {
+			if !w.opts.FollowSymlinks {
+				w.logger.Debug("WalkDirTree - Skipping symlink", zap.String("path", childPath))
+				continue
+			}
+			target, err := os.Stat(childPath)
+			if err != nil {
+				w.logger.Warn("WalkDirTree - Skipping broken symlink", zap.String("path", childPath), zap.Error(err))
+				continue
+			}
+			isDir = target.IsDir()
+		}
 
-		if gcThreshold > 0 && *processedCount%gcThreshold == 0 {
-			logger.Info("WalkDirTree - Triggering GC after processing files",
-				zap.Int64("files_processed", *processedCount))
-			runtime.GC()
+		if isDir && !w.opts.IncludeSubmodules && IsSubmoduleDir(childPath) {
+			w.logger.Info("WalkDirTree - Skipping git submodule", zap.String("path", childPath))
+			continue
 		}
 
-		if !entry.IsDir() {
-			if skipPath(childPath, false) {
-				logger.Info("WalkDirTree - Skipping file", zap.String("path", childPath))
+		if !isDir {
+			// Already processed before the walk was interrupted
+			if resumeAfter != "" && !walkOrderLess(resumeAfter, rel) {
 				continue
 			}
-			fileQueue <- walkItem{path: childPath}
-		} else {
-			walk(childPath, fileQueue, skipPath, processedCount, gcThreshold, logger)
+			if w.skipPath(childPath, false) {
+				w.logger.Info("WalkDirTree - Skipping file", zap.String("path", childPath))
+				continue
+			}
+
+			w.mu.Lock()
+			w.pending = append(w.pending, childPath)
+			w.mu.Unlock()
+
+			select {
+			case files <- childPath:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			continue
+		}
+
+		// Skip directories that lie entirely before the cursor
+		if resumeAfter != "" && walkOrderLess(rel, resumeAfter) && !isPathPrefix(rel, resumeAfter) {
+			continue
+		}
+		if err := w.walk(ctx, childPath, resumeAfter, files); err != nil && err != filepath.SkipDir {
+			return err
 		}
 	}
 
 	return nil
 }
+
+func (w *FileWalker) relative(path string) string {
+	rel, err := filepath.Rel(w.root, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// walkOrderLess reports whether slash-separated relative path a is visited before b
+// in a depth-first walk with entries sorted by name
+func walkOrderLess(a, b string) bool {
+	ap := strings.Split(a, "/")
+	bp := strings.Split(b, "/")
+	for i := 0; i < len(ap) && i < len(bp); i++ {
+		if ap[i] != bp[i] {
+			return ap[i] < bp[i]
+		}
+	}
+	return len(ap) < len(bp)
+}
+
+// isPathPrefix reports whether dir is an ancestor directory of path
+func isPathPrefix(dir, path string) bool {
+	return strings.HasPrefix(path, dir+"/")
+}
+
+// IsSubmoduleDir reports whether dir is the root of a nested git checkout, i.e. it has its
+// own .git entry (a gitlink file for submodules, a directory for nested clones)
+func IsSubmoduleDir(dir string) bool {
+	_, err := os.Lstat(filepath.Join(dir, ".git"))
+	return err == nil
+}
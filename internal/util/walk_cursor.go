@@ -0,0 +1,121 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// walkCursorSaveEvery controls how many cursor advances happen between writes to disk
+const walkCursorSaveEvery = 50
+
+// WalkCursor records the last file (relative to Root) up to which a directory walk has
+// completed, persisted as JSON so an interrupted walk can resume. A nil *WalkCursor is
+// valid and does nothing.
+type WalkCursor struct {
+	Root      string    `json:"root"`
+	LastPath  string    `json:"last_path"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	file      string
+	mu        sync.Mutex
+	sinceSave int
+}
+
+// LoadWalkCursor loads the cursor stored in file for root. A missing file, or a cursor
+// saved for a different root, yields a fresh cursor that starts at the beginning.
+func LoadWalkCursor(file, root string) (*WalkCursor, error) {
+	cursor := &WalkCursor{Root: root, file: file}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cursor, nil
+		}
+		return nil, fmt.Errorf("failed to read walk cursor: %w", err)
+	}
+
+	var saved WalkCursor
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("failed to parse walk cursor %s: %w", file, err)
+	}
+	if saved.Root == root {
+		cursor.LastPath = saved.LastPath
+		cursor.UpdatedAt = saved.UpdatedAt
+	}
+	return cursor, nil
+}
+
+// Position returns the relative path of the last completed file ("" if none)
+func (c *WalkCursor) Position() string {
+	if c == nil {
+		return ""
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.LastPath
+}
+
+// Advance moves the cursor to relPath, saving periodically
+func (c *WalkCursor) Advance(relPath string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.LastPath = relPath
+	c.UpdatedAt = time.Now()
+	c.sinceSave++
+	shouldSave := c.sinceSave >= walkCursorSaveEvery
+	c.mu.Unlock()
+
+	if shouldSave {
+		// Best effort: a failed save only means more files are revisited on resume
+		c.Save()
+	}
+}
+
+// Save writes the cursor to disk
+func (c *WalkCursor) Save() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("failed to encode walk cursor: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.file), 0755); err != nil {
+		return fmt.Errorf("failed to create walk cursor directory: %w", err)
+	}
+	// Write then rename so an interruption never leaves a truncated cursor
+	tmp := c.file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write walk cursor: %w", err)
+	}
+	if err := os.Rename(tmp, c.file); err != nil {
+		return fmt.Errorf("failed to write walk cursor: %w", err)
+	}
+	c.sinceSave = 0
+	return nil
+}
+
+// Clear resets the cursor and removes it from disk, typically after a walk completes
+func (c *WalkCursor) Clear() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.LastPath = ""
+	c.sinceSave = 0
+	if err := os.Remove(c.file); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove walk cursor: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package util
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestWalkOrderLess(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected bool
+	}{
+		{"a.go", "b.go", true},
+		{"a/z.go", "b.go", true},
+		{"b.go", "a/z.go", false},
+		{"a", "a/b.go", true},
+		{"a-b/x.go", "a/x.go", false}, // directory "a" is read before "a-b"
+		{"a/x.go", "a/x.go", false},
+	}
+	for _, tt := range tests {
+		if got := walkOrderLess(tt.a, tt.b); got != tt.expected {
+			t.Errorf("walkOrderLess(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.expected)
+		}
+	}
+}
+
+func TestWalkDirTreeResumable_ResumesFromCursor(t *testing.T) {
+	root := t.TempDir()
+	files := []string{"a.go", "dir1/b.go", "dir1/sub/c.go", "dir2/d.go", "e.go"}
+	for _, f := range files {
+		path := filepath.Join(root, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cursorFile := filepath.Join(t.TempDir(), "cursor.json")
+	cursor, err := LoadWalkCursor(cursorFile, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cursor.Advance("dir1/sub/c.go")
+	if err := cursor.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	resumed, err := LoadWalkCursor(cursorFile, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var visited []string
+	noSkip := func(path string, isDir bool) bool { return false }
+	err = WalkDirTreeResumable(context.Background(), root, func(path string, err error) error {
+		rel, _ := filepath.Rel(root, path)
+		mu.Lock()
+		visited = append(visited, filepath.ToSlash(rel))
+		mu.Unlock()
+		return nil
+	}, noSkip, zap.NewNop(), 0, 2, nil, resumed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Strings(visited)
+	if len(visited) != 2 || visited[0] != "dir2/d.go" || visited[1] != "e.go" {
+		t.Fatalf("expected only files after the cursor, got %v", visited)
+	}
+	if resumed.Position() != "e.go" {
+		t.Fatalf("expected cursor at last file, got %q", resumed.Position())
+	}
+}
+
+func TestWalkDirTreeWithOptions_SymlinksAndSubmodules(t *testing.T) {
+	root := t.TempDir()
+	for _, f := range []string{"src/a.go", "sub/.git", "sub/b.go"} {
+		path := filepath.Join(root, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A link back to the root would loop forever without cycle detection
+	if err := os.Symlink(root, filepath.Join(root, "src", "loop")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(root, "src", "a.go"), filepath.Join(root, "link.go")); err != nil {
+		t.Fatal(err)
+	}
+
+	walk := func(opts WalkOptions) []string {
+		var mu sync.Mutex
+		var visited []string
+		skipGit := func(path string, isDir bool) bool { return filepath.Base(path) == ".git" }
+		err := WalkDirTreeWithOptions(context.Background(), root, func(path string, err error) error {
+			rel, _ := filepath.Rel(root, path)
+			mu.Lock()
+			visited = append(visited, filepath.ToSlash(rel))
+			mu.Unlock()
+			return nil
+		}, skipGit, zap.NewNop(), 0, 2, nil, nil, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(visited)
+		return visited
+	}
+
+	if got := walk(WalkOptions{}); len(got) != 1 || got[0] != "src/a.go" {
+		t.Errorf("default policy: expected only src/a.go, got %v", got)
+	}
+	got := walk(WalkOptions{FollowSymlinks: true, IncludeSubmodules: true})
+	want := []string{"link.go", "src/a.go", "sub/b.go"}
+	if len(got) != len(want) {
+		t.Fatalf("follow policy: expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("follow policy: expected %v, got %v", want, got)
+		}
+	}
+}
@@ -1,9 +1,12 @@
 package lsp
 
 import (
+	"bot-go/internal/apperrors"
 	"bot-go/internal/config"
 	"bot-go/pkg/lsp/base"
 	"fmt"
+	"os/exec"
+	"sort"
 	"strings"
 
 	"go.uber.org/zap"
@@ -32,6 +35,60 @@ func NewLSPLanguageServer(config *config.Config, language, rootPath string, logg
 	case "javascript", "js", "typescript", "ts":
 		return NewTypeScriptLanguageServerClient(rootPath, logger)
 	default:
-		return nil, fmt.Errorf("unsupported language: %s", language)
+		return nil, fmt.Errorf("%w: %s", apperrors.ErrUnsupportedLanguage, language)
 	}
 }
+
+// serverCommand returns the command that starts the language server of a language, or ""
+// for languages without one
+func serverCommand(config *config.Config, language string) string {
+	switch strings.ToLower(language) {
+	case "go", "golang":
+		return config.App.Gopls
+	case "python", "py":
+		return config.App.Python
+	case "javascript", "js", "typescript", "ts":
+		return "typescript-language-server"
+	}
+	return ""
+}
+
+// CheckLanguageServers looks up the language server command of every enabled repository's
+// language without starting it. It returns the languages whose server is missing, with the
+// reason, and how many languages have a server at all.
+func CheckLanguageServers(config *config.Config) (missing map[string]string, available int) {
+	missing = make(map[string]string)
+	checked := make(map[string]bool)
+	for _, repo := range config.Source.Repositories {
+		language := strings.ToLower(repo.Language)
+		if repo.Disabled || checked[language] {
+			continue
+		}
+		checked[language] = true
+		command := serverCommand(config, language)
+		if command == "" {
+			continue
+		}
+		if _, err := exec.LookPath(command); err != nil {
+			missing[language] = err.Error()
+			continue
+		}
+		available++
+	}
+	return missing, available
+}
+
+// MissingServersReason describes the missing language servers found by
+// CheckLanguageServers in one line
+func MissingServersReason(missing map[string]string) string {
+	languages := make([]string, 0, len(missing))
+	for language := range missing {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	parts := make([]string, len(languages))
+	for i, language := range languages {
+		parts[i] = language + ": " + missing[language]
+	}
+	return "language servers not found (" + strings.Join(parts, "; ") + ")"
+}
@@ -68,6 +68,13 @@ func (rs *LspService) getLanguageServerClient(repoName string) (base.LSPClient,
 	return client, nil
 }
 
+// Warmup starts and initializes the language server for a repository so the first request
+// does not pay the startup cost. Already running servers are left as they are.
+func (rs *LspService) Warmup(repoName string) error {
+	_, err := rs.getLanguageServerClient(repoName)
+	return err
+}
+
 func (rs *LspService) getSymbolsOfType(ctx context.Context, lspClient base.LSPClient, fileUri string, symType int) ([]interface{}, error) {
 	lspClient.DidOpenFile(ctx, fileUri)
 
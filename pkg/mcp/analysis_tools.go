@@ -0,0 +1,408 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"bot-go/internal/codeapi"
+	"bot-go/internal/service/codegraph"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"go.uber.org/zap"
+)
+
+// The graph analysis tools summarize their results (counts per distance and file, the top
+// entries by score) rather than dumping every node, so that they fit an agent's context.
+const (
+	defaultImpactTop = 10
+	maxImpactTop     = 50
+	maxImpactFiles   = 10
+
+	defaultSignatureLimit = 20
+	maxSignatureLimit     = 50
+)
+
+type ImpactParams struct {
+	RepoName     string `json:"repo_name" jsonschema:"the name of the repository"`
+	FunctionName string `json:"function_name" jsonschema:"the function whose change is analyzed"`
+	ClassName    string `json:"class_name,omitempty" jsonschema:"class of the function, to disambiguate methods"`
+	FilePath     string `json:"file_path,omitempty" jsonschema:"path of the file containing the function, to disambiguate"`
+	Depth        int    `json:"depth,omitempty" jsonschema:"how many levels of callers and data dependents to follow (default 3)"`
+	IncludeTests bool   `json:"include_tests,omitempty" jsonschema:"count affected test code"`
+	Top          int    `json:"top,omitempty" jsonschema:"number of most affected elements to list (default 10, at most 50)"`
+	NoCache      bool   `json:"no_cache,omitempty" jsonschema:"compute the result again instead of returning the result of an identical recent call"`
+}
+
+type CallPathsParams struct {
+	RepoName     string `json:"repo_name" jsonschema:"the name of the repository"`
+	FromFunction string `json:"from_function" jsonschema:"the function the paths start from (the caller)"`
+	FromClass    string `json:"from_class,omitempty" jsonschema:"class of from_function, to disambiguate methods"`
+	FromFile     string `json:"from_file,omitempty" jsonschema:"path of the file containing from_function, to disambiguate"`
+	ToFunction   string `json:"to_function" jsonschema:"the function the paths end at (the callee)"`
+	ToClass      string `json:"to_class,omitempty" jsonschema:"class of to_function, to disambiguate methods"`
+	ToFile       string `json:"to_file,omitempty" jsonschema:"path of the file containing to_function, to disambiguate"`
+	MaxDepth     int    `json:"max_depth,omitempty" jsonschema:"longest path to search for, in calls (default 6)"`
+	MaxPaths     int    `json:"max_paths,omitempty" jsonschema:"number of paths to list (default 10)"`
+	NoCache      bool   `json:"no_cache,omitempty" jsonschema:"compute the result again instead of returning the result of an identical recent call"`
+}
+
+type FindBySignatureParams struct {
+	RepoName  string   `json:"repo_name" jsonschema:"the name of the repository"`
+	Params    []string `json:"params,omitempty" jsonschema:"parameter types in order; a last \"...\" allows more parameters. * matches any characters and unqualified names match qualified types"`
+	Takes     []string `json:"takes,omitempty" jsonschema:"parameter types that must appear in any position, e.g. [\"context.Context\"]"`
+	Returns   []string `json:"returns,omitempty" jsonschema:"result types in order; a last \"...\" allows more results, e.g. [\"error\"]"`
+	Void      bool     `json:"void,omitempty" jsonschema:"only functions returning nothing"`
+	MinParams int      `json:"min_params,omitempty" jsonschema:"fewest parameters"`
+	MaxParams *int     `json:"max_params,omitempty" jsonschema:"most parameters"`
+	Name      string   `json:"name,omitempty" jsonschema:"function name pattern, e.g. Get*"`
+	Limit     int      `json:"limit,omitempty" jsonschema:"number of functions to list (default 20, at most 50)"`
+	NoCache   bool     `json:"no_cache,omitempty" jsonschema:"compute the result again instead of returning the result of an identical recent call"`
+}
+
+// SetCodeAPI gives the server access to the code graph analyses for the impact and call
+// path tools
+func (s *CodeGraphServer) SetCodeAPI(api codeapi.CodeAPI) {
+	s.codeAPI = api
+}
+
+func (s *CodeGraphServer) handleImpact(ctx context.Context, req *mcp.CallToolRequest, args ImpactParams) (*mcp.CallToolResult, any, error) {
+	s.logger.Info("Handling impact request",
+		zap.String("repo_name", args.RepoName),
+		zap.String("function_name", args.FunctionName))
+
+	if s.codeAPI == nil {
+		return errorResult("Code graph is not available"), nil, nil
+	}
+	function, err := s.resolveFunction(ctx, args.RepoName, args.FunctionName, args.ClassName, args.FilePath)
+	if err != nil {
+		return errorResult(err.Error()), nil, nil
+	}
+
+	opts := codeapi.DefaultImpactOptions()
+	if args.Depth > 0 {
+		opts.MaxDepth = args.Depth
+	}
+	opts.IncludeTests = args.IncludeTests
+	impact, err := s.codeAPI.Analyzer().GetImpact(ctx, function.ID, opts)
+	if err != nil {
+		s.logger.Error("Failed to analyze impact", zap.String("repo_name", args.RepoName), zap.Error(err))
+		return errorResult(fmt.Sprintf("Failed to analyze impact: %v", err)), nil, nil
+	}
+	if s.codeGraph != nil {
+		for _, node := range impact.AffectedNodes {
+			if node.FilePath == "" {
+				node.FilePath = s.codeGraph.GetFilePath(ctx, node.FileID)
+			}
+		}
+	}
+
+	return textResult(formatImpact(function, impact, args.Top)), nil, nil
+}
+
+func (s *CodeGraphServer) handleCallPaths(ctx context.Context, req *mcp.CallToolRequest, args CallPathsParams) (*mcp.CallToolResult, any, error) {
+	s.logger.Info("Handling callPaths request",
+		zap.String("repo_name", args.RepoName),
+		zap.String("from_function", args.FromFunction),
+		zap.String("to_function", args.ToFunction))
+
+	if s.codeAPI == nil {
+		return errorResult("Code graph is not available"), nil, nil
+	}
+	from, err := s.resolveFunction(ctx, args.RepoName, args.FromFunction, args.FromClass, args.FromFile)
+	if err != nil {
+		return errorResult("from_function: " + err.Error()), nil, nil
+	}
+	to, err := s.resolveFunction(ctx, args.RepoName, args.ToFunction, args.ToClass, args.ToFile)
+	if err != nil {
+		return errorResult("to_function: " + err.Error()), nil, nil
+	}
+
+	opts := codeapi.DefaultCallPathOptions()
+	if args.MaxDepth > 0 {
+		opts.MaxDepth = args.MaxDepth
+	}
+	if args.MaxPaths > 0 {
+		opts.MaxPaths = args.MaxPaths
+	}
+	paths, err := s.codeAPI.Analyzer().FindCallPaths(ctx, from.ID, to.ID, opts)
+	if err != nil {
+		s.logger.Error("Failed to find call paths", zap.String("repo_name", args.RepoName), zap.Error(err))
+		return errorResult(fmt.Sprintf("Failed to find call paths: %v", err)), nil, nil
+	}
+
+	return textResult(formatCallPaths(from, to, paths, opts.MaxDepth)), nil, nil
+}
+
+func (s *CodeGraphServer) handleFindBySignature(ctx context.Context, req *mcp.CallToolRequest, args FindBySignatureParams) (*mcp.CallToolResult, any, error) {
+	s.logger.Info("Handling findFunctionsBySignature request",
+		zap.String("repo_name", args.RepoName),
+		zap.Strings("params", args.Params),
+		zap.Strings("takes", args.Takes),
+		zap.Strings("returns", args.Returns))
+
+	if s.codeGraph == nil {
+		return errorResult("Code graph is not available"), nil, nil
+	}
+	if _, err := s.config.GetRepository(args.RepoName); err != nil {
+		return errorResult(err.Error()), nil, nil
+	}
+	for i, p := range args.Params {
+		if p == codegraph.SignatureRest && i != len(args.Params)-1 {
+			return errorResult(`"..." is only allowed as the last of params`), nil, nil
+		}
+	}
+	limit := args.Limit
+	if limit <= 0 {
+		limit = defaultSignatureLimit
+	}
+	query := codegraph.SignatureQuery{
+		Params:    args.Params,
+		Takes:     args.Takes,
+		Returns:   args.Returns,
+		Void:      args.Void,
+		MinParams: args.MinParams,
+		MaxParams: -1,
+		Name:      args.Name,
+		Limit:     min(limit, maxSignatureLimit),
+	}
+	if args.MaxParams != nil {
+		query.MaxParams = *args.MaxParams
+	}
+	matches, err := s.codeGraph.FindFunctionsBySignature(ctx, args.RepoName, query)
+	if err != nil {
+		s.logger.Error("Failed to find functions by signature", zap.String("repo_name", args.RepoName), zap.Error(err))
+		return errorResult(fmt.Sprintf("Failed to find functions: %v", err)), nil, nil
+	}
+	return textResult(formatSignatureMatches(matches, query.Limit)), nil, nil
+}
+
+// resolveFunction finds a function by name, asking for the file or class when the name is
+// ambiguous
+func (s *CodeGraphServer) resolveFunction(ctx context.Context, repoName, name, className, filePath string) (*codeapi.MethodInfo, error) {
+	if name == "" {
+		return nil, fmt.Errorf("a function name is required")
+	}
+	const maxCandidates = 5
+	functions, err := s.codeAPI.Reader().Repo(repoName).FindMethods(ctx, codeapi.MethodFilter{
+		Name:      name,
+		ClassName: className,
+		FilePath:  filePath,
+		Limit:     maxCandidates + 1,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find function %s: %v", name, err)
+	}
+	switch len(functions) {
+	case 0:
+		return nil, fmt.Errorf("no function %s found in %s", qualifiedName(className, name), repoName)
+	case 1:
+		return functions[0], nil
+	}
+
+	candidates := make([]string, 0, maxCandidates)
+	for i, fn := range functions {
+		if i == maxCandidates {
+			candidates = append(candidates, "...")
+			break
+		}
+		candidates = append(candidates, fmt.Sprintf("%s (%s)", qualifiedName(fn.ClassName, fn.Name), fn.FilePath))
+	}
+	return nil, fmt.Errorf("%s matches several functions, pass the file path or class name: %s",
+		name, strings.Join(candidates, ", "))
+}
+
+// formatImpact summarizes an impact analysis: totals, counts per distance and file, and the
+// top affected elements by score
+func formatImpact(source *codeapi.MethodInfo, impact *codeapi.ImpactResult, top int) string {
+	if top <= 0 {
+		top = defaultImpactTop
+	}
+	top = min(top, maxImpactTop)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Impact of %s (%s): %d affected", qualifiedName(source.ClassName, source.Name), source.FilePath, impact.TotalAffected)
+	if impact.RiskLevel != "" {
+		fmt.Fprintf(&b, ", risk %s (score %.1f)", impact.RiskLevel, impact.RiskScore)
+	}
+	b.WriteString("\n")
+	if impact.TotalAffected == 0 {
+		return b.String()
+	}
+
+	byDistance := make(map[int]int)
+	byFile := make(map[string]int)
+	for _, node := range impact.AffectedNodes {
+		byDistance[distance(node.Depth)]++
+		byFile[nodeFile(node)]++
+	}
+	distances := make([]int, 0, len(byDistance))
+	for d := range byDistance {
+		distances = append(distances, d)
+	}
+	sort.Ints(distances)
+	parts := make([]string, len(distances))
+	for i, d := range distances {
+		parts[i] = fmt.Sprintf("%d: %d", d, byDistance[d])
+	}
+	fmt.Fprintf(&b, "By distance: %s\n", strings.Join(parts, ", "))
+
+	files := make([]string, 0, len(byFile))
+	for file := range byFile {
+		files = append(files, file)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if byFile[files[i]] != byFile[files[j]] {
+			return byFile[files[i]] > byFile[files[j]]
+		}
+		return files[i] < files[j]
+	})
+	parts = parts[:0]
+	for i, file := range files {
+		if i == maxImpactFiles {
+			parts = append(parts, fmt.Sprintf("+%d more", len(files)-maxImpactFiles))
+			break
+		}
+		parts = append(parts, fmt.Sprintf("%s %d", file, byFile[file]))
+	}
+	fmt.Fprintf(&b, "Files (%d): %s\n", len(files), strings.Join(parts, ", "))
+
+	ranked := impact.Ranked
+	if len(ranked) == 0 {
+		ranked = impact.AffectedNodes
+	}
+	fmt.Fprintf(&b, "Top %d:\n", min(top, len(ranked)))
+	for i, node := range ranked {
+		if i == top {
+			fmt.Fprintf(&b, "(+%d more)\n", len(ranked)-top)
+			break
+		}
+		fmt.Fprintf(&b, "- %s (%s) distance %d, %s, score %.2f", node.Name, nodeFile(node), distance(node.Depth), node.Impact, node.Score)
+		if node.FanIn > 0 {
+			fmt.Fprintf(&b, ", %d callers", node.FanIn)
+		}
+		b.WriteString("\n")
+	}
+
+	if len(impact.Owners) > 0 {
+		owners := make([]string, 0, len(impact.Owners))
+		for i, owner := range impact.Owners {
+			if i == 5 {
+				owners = append(owners, fmt.Sprintf("+%d more", len(impact.Owners)-5))
+				break
+			}
+			owners = append(owners, fmt.Sprintf("%s %d", owner.Author, owner.Nodes))
+		}
+		fmt.Fprintf(&b, "Owners: %s\n", strings.Join(owners, ", "))
+	}
+	if impact.Truncated {
+		b.WriteString("Results were truncated; pass a smaller depth for a complete analysis.\n")
+	}
+	return b.String()
+}
+
+// formatCallPaths lists the call paths by function name, then the location of each function
+// on them once
+func formatCallPaths(from, to *codeapi.MethodInfo, paths *codeapi.CallPaths, maxDepth int) string {
+	fromName, toName := qualifiedName(from.ClassName, from.Name), qualifiedName(to.ClassName, to.Name)
+	var b strings.Builder
+	if len(paths.Paths) == 0 {
+		fmt.Fprintf(&b, "No call path from %s to %s within %d calls.\n", fromName, toName, maxDepth)
+		if paths.Truncated {
+			b.WriteString("The search stopped early in a large call graph; the functions may still be connected.\n")
+		}
+		return b.String()
+	}
+
+	noun := "paths"
+	if len(paths.Paths) == 1 {
+		noun = "path"
+	}
+	fmt.Fprintf(&b, "%d shortest call %s from %s to %s (%d calls each):\n", len(paths.Paths), noun, fromName, toName, paths.Length)
+	var order []*codeapi.CallNode
+	seen := make(map[int64]bool)
+	for i, path := range paths.Paths {
+		names := make([]string, len(path))
+		for j, node := range path {
+			names[j] = qualifiedName(node.ClassName, node.Name)
+			if !seen[int64(node.ID)] {
+				seen[int64(node.ID)] = true
+				order = append(order, node)
+			}
+		}
+		fmt.Fprintf(&b, "%d. %s\n", i+1, strings.Join(names, " -> "))
+	}
+	if paths.Truncated {
+		b.WriteString("More paths exist; pass a larger max_paths to list them.\n")
+	}
+
+	b.WriteString("Functions:\n")
+	for _, node := range order {
+		fmt.Fprintf(&b, "- %s %s:%d\n", qualifiedName(node.ClassName, node.Name), node.FilePath, node.Range.Start.Line+1)
+	}
+	return b.String()
+}
+
+// formatSignatureMatches lists the functions found by signature with their location and
+// declaration, one per line
+func formatSignatureMatches(matches []codegraph.SignatureMatch, limit int) string {
+	if len(matches) == 0 {
+		return "No function with that signature.\n"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d functions, most referenced first:\n", len(matches))
+	for _, m := range matches {
+		fmt.Fprintf(&b, "- %s %s:%d", qualifiedName(m.Class, m.Name), m.FilePath, m.Range.Start.Line+1)
+		if m.References > 0 {
+			fmt.Fprintf(&b, " (%d refs)", m.References)
+		}
+		if m.Signature != "" {
+			b.WriteString("\n  " + m.Signature)
+		}
+		b.WriteString("\n")
+	}
+	if len(matches) == limit {
+		b.WriteString("More functions may match; narrow the signature or pass a larger limit.\n")
+	}
+	return b.String()
+}
+
+func qualifiedName(className, name string) string {
+	if className == "" {
+		return name
+	}
+	return className + "." + name
+}
+
+// distance is the number of edges from the analyzed node; callers have negative depths
+func distance(depth int) int {
+	if depth < 0 {
+		return -depth
+	}
+	return depth
+}
+
+func nodeFile(node *codeapi.ImpactNode) string {
+	file := node.FilePath
+	if file == "" {
+		file = "?"
+	}
+	if node.Repo != "" {
+		file = node.Repo + ":" + file
+	}
+	return file
+}
+
+func textResult(text string) *mcp.CallToolResult {
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: text}},
+	}
+}
+
+func errorResult(text string) *mcp.CallToolResult {
+	return &mcp.CallToolResult{
+		IsError: true,
+		Content: []mcp.Content{&mcp.TextContent{Text: text}},
+	}
+}
@@ -0,0 +1,92 @@
+package mcp
+
+import (
+	"strings"
+	"testing"
+
+	"bot-go/internal/codeapi"
+	"bot-go/internal/model/ast"
+	"bot-go/internal/service/codegraph"
+	"bot-go/pkg/lsp/base"
+)
+
+func TestFormatImpact(t *testing.T) {
+	impact := &codeapi.ImpactResult{
+		TotalAffected: 3,
+		RiskLevel:     codeapi.RiskMedium,
+		RiskScore:     4.3,
+		AffectedNodes: []*codeapi.ImpactNode{
+			{ID: 1, Name: "Create", FilePath: "api/orders.go", Depth: -1, Impact: codeapi.ImpactTypeCallGraph, Score: 2, FanIn: 3},
+			{ID: 2, Name: "Update", FilePath: "api/orders.go", Depth: -2, Impact: codeapi.ImpactTypeCallGraph, Score: 1.5},
+			{ID: 3, Name: "total", FilePath: "billing/invoice.go", Depth: 1, Impact: codeapi.ImpactTypeDataFlow, Score: 0.75},
+		},
+		Owners: []codeapi.ImpactOwner{{Author: "alice", Nodes: 2}},
+	}
+	impact.Ranked = impact.AffectedNodes
+
+	text := formatImpact(&codeapi.MethodInfo{Name: "Save", ClassName: "Store", FilePath: "store/store.go"}, impact, 2)
+	for _, want := range []string{
+		"Impact of Store.Save (store/store.go): 3 affected, risk medium (score 4.3)\n",
+		"By distance: 1: 2, 2: 1\n",
+		"Files (2): api/orders.go 2, billing/invoice.go 1\n",
+		"Top 2:\n- Create (api/orders.go) distance 1, call_graph, score 2.00, 3 callers\n- Update",
+		"(+1 more)\n",
+		"Owners: alice 2\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("output does not contain %q:\n%s", want, text)
+		}
+	}
+}
+
+func TestFormatCallPaths(t *testing.T) {
+	node := func(id int, class, name, file string, line int) *codeapi.CallNode {
+		return &codeapi.CallNode{ID: ast.NodeID(id), ClassName: class, Name: name, FilePath: file,
+			Range: base.Range{Start: base.Position{Line: line}}}
+	}
+	handler, svc, repo, save := node(1, "", "HandleOrder", "api.go", 9), node(2, "Service", "Create", "svc.go", 19),
+		node(3, "Repo", "Insert", "repo.go", 29), node(4, "", "Save", "db.go", 39)
+	paths := &codeapi.CallPaths{
+		Paths:     [][]*codeapi.CallNode{{handler, svc, save}, {handler, repo, save}},
+		Length:    2,
+		Truncated: true,
+	}
+	from, to := &codeapi.MethodInfo{Name: "HandleOrder"}, &codeapi.MethodInfo{Name: "Save"}
+
+	text := formatCallPaths(from, to, paths, 6)
+	want := "2 shortest call paths from HandleOrder to Save (2 calls each):\n" +
+		"1. HandleOrder -> Service.Create -> Save\n" +
+		"2. HandleOrder -> Repo.Insert -> Save\n" +
+		"More paths exist; pass a larger max_paths to list them.\n" +
+		"Functions:\n" +
+		"- HandleOrder api.go:10\n" +
+		"- Service.Create svc.go:20\n" +
+		"- Save db.go:40\n" +
+		"- Repo.Insert repo.go:30\n"
+	if text != want {
+		t.Errorf("output:\n%s\nwant:\n%s", text, want)
+	}
+
+	text = formatCallPaths(from, to, &codeapi.CallPaths{}, 6)
+	if text != "No call path from HandleOrder to Save within 6 calls.\n" {
+		t.Errorf("output without paths: %q", text)
+	}
+}
+
+func TestFormatSignatureMatches(t *testing.T) {
+	matches := []codegraph.SignatureMatch{
+		{Name: "Load", Class: "Store", FilePath: "store/store.go", Range: base.Range{Start: base.Position{Line: 11}},
+			Signature: "func (s *Store) Load(ctx context.Context) error", References: 4},
+		{Name: "flush", FilePath: "store/flush.go", Range: base.Range{Start: base.Position{Line: 2}}},
+	}
+	want := "2 functions, most referenced first:\n" +
+		"- Store.Load store/store.go:12 (4 refs)\n  func (s *Store) Load(ctx context.Context) error\n" +
+		"- flush store/flush.go:3\n" +
+		"More functions may match; narrow the signature or pass a larger limit.\n"
+	if text := formatSignatureMatches(matches, 2); text != want {
+		t.Errorf("output:\n%s\nwant:\n%s", text, want)
+	}
+	if text := formatSignatureMatches(nil, 20); text != "No function with that signature.\n" {
+		t.Errorf("output without matches: %q", text)
+	}
+}
@@ -0,0 +1,208 @@
+package mcp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"bot-go/internal/cache"
+	"bot-go/internal/config"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"go.uber.org/zap"
+)
+
+// Defaults used when the mcp cache settings are zero
+const (
+	DefaultCacheTTL        = 30 * time.Second
+	DefaultCacheMaxEntries = 1000
+)
+
+// noCacheArg is the tool argument that bypasses the cache. The fresh result replaces the
+// cached one.
+const noCacheArg = "no_cache"
+
+// toolCache keeps the results of tool calls per MCP session for a short time, since agents
+// often repeat a call within a session. Entries are keyed by session, tool name and the
+// canonical JSON of the arguments. It is safe for concurrent use.
+type toolCache struct {
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+	logger     *zap.Logger
+	shared     cache.Cache // optional; replaces entries when set
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	result  *mcp.CallToolResult
+	expires time.Time
+}
+
+// newToolCache returns the cache configured under mcp, or nil when it is disabled
+// (cache_ttl_seconds < 0)
+func newToolCache(cfg config.McpConfig, logger *zap.Logger) *toolCache {
+	ttl := DefaultCacheTTL
+	switch {
+	case cfg.CacheTTLSeconds < 0:
+		return nil
+	case cfg.CacheTTLSeconds > 0:
+		ttl = time.Duration(cfg.CacheTTLSeconds) * time.Second
+	}
+	maxEntries := cfg.CacheMaxEntries
+	if maxEntries <= 0 {
+		maxEntries = DefaultCacheMaxEntries
+	}
+	return &toolCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		now:        time.Now,
+		logger:     logger,
+		entries:    make(map[string]cacheEntry),
+	}
+}
+
+// sharedToolCacheKey names an entry in the shared cache, where keys should stay short
+func sharedToolCacheKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "mcp:" + hex.EncodeToString(sum[:])
+}
+
+func (c *toolCache) get(key string) (*mcp.CallToolResult, bool) {
+	if c.shared != nil {
+		return c.getShared(key)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !c.now().Before(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.result, true
+}
+
+func (c *toolCache) put(key string, result *mcp.CallToolResult) {
+	if c.shared != nil {
+		c.putShared(key, result)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.maxEntries {
+		c.evict(now)
+	}
+	c.entries[key] = cacheEntry{result: result, expires: now.Add(c.ttl)}
+}
+
+// getShared reads an entry of the shared cache; failures are logged and count as misses
+func (c *toolCache) getShared(key string) (*mcp.CallToolResult, bool) {
+	data, ok, err := c.shared.Get(context.Background(), sharedToolCacheKey(key))
+	if err != nil || !ok {
+		if err != nil {
+			c.logger.Warn("Failed to read shared tool cache", zap.Error(err))
+		}
+		return nil, false
+	}
+	var result mcp.CallToolResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		c.logger.Warn("Ignoring undecodable shared tool cache entry", zap.Error(err))
+		return nil, false
+	}
+	return &result, true
+}
+
+func (c *toolCache) putShared(key string, result *mcp.CallToolResult) {
+	data, err := json.Marshal(result)
+	if err == nil {
+		err = c.shared.Set(context.Background(), sharedToolCacheKey(key), data, c.ttl)
+	}
+	if err != nil {
+		c.logger.Warn("Failed to write shared tool cache", zap.Error(err))
+	}
+}
+
+// evict drops the expired entries, or the entry closest to expiring if none has expired.
+// Called with mu held.
+func (c *toolCache) evict(now time.Time) {
+	var oldestKey string
+	var oldest time.Time
+	for key, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, key)
+			continue
+		}
+		if oldestKey == "" || entry.expires.Before(oldest) {
+			oldestKey, oldest = key, entry.expires
+		}
+	}
+	if len(c.entries) >= c.maxEntries {
+		delete(c.entries, oldestKey)
+	}
+}
+
+// toolCacheKey returns the cache key of a call and whether the caller asked to bypass the
+// cache. Arguments are canonicalized through a JSON round trip, which orders object keys,
+// and the bypass argument is left out of the key.
+func toolCacheKey(sessionID, tool string, args any) (key string, bypass bool, err error) {
+	data, err := json.Marshal(args)
+	if err != nil {
+		return "", false, err
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return "", false, err
+	}
+	bypass, _ = fields[noCacheArg].(bool)
+	delete(fields, noCacheArg)
+	if data, err = json.Marshal(fields); err != nil {
+		return "", false, err
+	}
+	return sessionID + "\x00" + tool + "\x00" + string(data), bypass, nil
+}
+
+// cachedTool wraps a tool handler with the session cache. Calls outside a session are not
+// cached.
+func cachedTool[In any](s *CodeGraphServer, tool string, handler mcp.ToolHandlerFor[In, any]) mcp.ToolHandlerFor[In, any] {
+	return func(ctx context.Context, req *mcp.CallToolRequest, args In) (*mcp.CallToolResult, any, error) {
+		if s.cache == nil || req == nil || req.Session == nil || req.Session.ID() == "" {
+			return handler(ctx, req, args)
+		}
+		return s.cache.call(req.Session.ID(), tool, args, func() (*mcp.CallToolResult, any, error) {
+			return handler(ctx, req, args)
+		})
+	}
+}
+
+// call returns the cached result of an identical call in the session, or makes the call and
+// caches its result. Calls with no_cache skip the lookup, and failed calls (errors and
+// IsError results) are not cached.
+func (c *toolCache) call(sessionID, tool string, args any, handler func() (*mcp.CallToolResult, any, error)) (*mcp.CallToolResult, any, error) {
+	key, bypass, err := toolCacheKey(sessionID, tool, args)
+	if err != nil {
+		c.logger.Warn("Not caching tool call with unencodable arguments", zap.String("tool", tool), zap.Error(err))
+		return handler()
+	}
+	if !bypass {
+		if result, ok := c.get(key); ok {
+			c.logger.Debug("Serving tool call from cache",
+				zap.String("tool", tool),
+				zap.String("session_id", sessionID))
+			return result, nil, nil
+		}
+	}
+	result, out, err := handler()
+	if err == nil && result != nil && !result.IsError {
+		c.put(key, result)
+	}
+	return result, out, err
+}
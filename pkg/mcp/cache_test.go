@@ -0,0 +1,129 @@
+package mcp
+
+import (
+	"testing"
+	"time"
+
+	"bot-go/internal/cache"
+	"bot-go/internal/config"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"go.uber.org/zap"
+)
+
+func TestToolCacheKey(t *testing.T) {
+	key, bypass, err := toolCacheKey("s1", "getCallGraph", CallGraphParams{RepoName: "shop", FunctionName: "Charge"})
+	if err != nil || bypass {
+		t.Fatalf("key: %v, bypass = %v", err, bypass)
+	}
+	same, bypass, _ := toolCacheKey("s1", "getCallGraph", CallGraphParams{RepoName: "shop", FunctionName: "Charge", NoCache: true})
+	if same != key || !bypass {
+		t.Errorf("no_cache changed the key or was not detected: %q vs %q, bypass = %v", same, key, bypass)
+	}
+	reordered, _, _ := toolCacheKey("s1", "getCallGraph", map[string]any{"function_name": "Charge", "repo_name": "shop"})
+	if reordered != key {
+		t.Errorf("argument order changed the key: %q vs %q", reordered, key)
+	}
+	for _, other := range []struct{ session, tool string }{{"s2", "getCallGraph"}, {"s1", "getCallerGraph"}} {
+		if k, _, _ := toolCacheKey(other.session, other.tool, CallGraphParams{RepoName: "shop", FunctionName: "Charge"}); k == key {
+			t.Errorf("%s/%s shares the key", other.session, other.tool)
+		}
+	}
+}
+
+func TestToolCacheCall(t *testing.T) {
+	cache := newToolCache(config.McpConfig{CacheTTLSeconds: 10, CacheMaxEntries: 2}, zap.NewNop())
+	now := time.Unix(1000, 0)
+	cache.now = func() time.Time { return now }
+
+	calls := 0
+	failing := false
+	handler := func() (*mcp.CallToolResult, any, error) {
+		calls++
+		return &mcp.CallToolResult{IsError: failing, Content: []mcp.Content{&mcp.TextContent{Text: "graph"}}}, nil, nil
+	}
+	call := func(args CallGraphParams) {
+		t.Helper()
+		if _, _, err := cache.call("s1", "getCallGraph", args, handler); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	a := CallGraphParams{RepoName: "shop", FunctionName: "A"}
+	call(a)
+	call(a)
+	if calls != 1 {
+		t.Fatalf("repeated call ran %d times, want 1", calls)
+	}
+	call(CallGraphParams{RepoName: "shop", FunctionName: "A", NoCache: true})
+	if calls != 2 {
+		t.Fatalf("no_cache was served from the cache")
+	}
+
+	now = now.Add(11 * time.Second)
+	call(a)
+	if calls != 3 {
+		t.Fatalf("expired result was served")
+	}
+
+	now = now.Add(time.Second)
+	failing = true
+	b := CallGraphParams{RepoName: "shop", FunctionName: "B"}
+	call(b)
+	call(b)
+	if calls != 5 {
+		t.Fatalf("failed result was cached")
+	}
+
+	failing = false
+	call(b)
+	call(CallGraphParams{RepoName: "shop", FunctionName: "C"})
+	if len(cache.entries) != 2 {
+		t.Errorf("cache holds %d entries, want at most 2", len(cache.entries))
+	}
+	if _, ok := cache.entries[mustKey(t, a)]; ok {
+		t.Errorf("the entry closest to expiring was not evicted")
+	}
+}
+
+func mustKey(t *testing.T, args CallGraphParams) string {
+	t.Helper()
+	key, _, err := toolCacheKey("s1", "getCallGraph", args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestNewToolCacheDisabled(t *testing.T) {
+	if newToolCache(config.McpConfig{CacheTTLSeconds: -1}, zap.NewNop()) != nil {
+		t.Error("negative cache_ttl_seconds did not disable the cache")
+	}
+}
+
+func TestSharedToolCache(t *testing.T) {
+	shared := cache.NewMemory()
+	a := newToolCache(config.McpConfig{}, zap.NewNop())
+	b := newToolCache(config.McpConfig{}, zap.NewNop())
+	a.shared, b.shared = shared, shared
+
+	calls := 0
+	handler := func() (*mcp.CallToolResult, any, error) {
+		calls++
+		return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: "graph"}}}, nil, nil
+	}
+	args := CallGraphParams{RepoName: "shop", FunctionName: "A"}
+	if _, _, err := a.call("s1", "getCallGraph", args, handler); err != nil {
+		t.Fatal(err)
+	}
+	result, _, err := b.call("s1", "getCallGraph", args, handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Fatalf("the other instance ran the call again")
+	}
+	if text, ok := result.Content[0].(*mcp.TextContent); !ok || text.Text != "graph" {
+		t.Errorf("shared result = %+v", result.Content)
+	}
+}
@@ -7,9 +7,13 @@ import (
 	"net/http"
 	"strings"
 
+	"bot-go/internal/cache"
+	"bot-go/internal/codeapi"
 	"bot-go/internal/config"
 	"bot-go/internal/model"
+	"bot-go/internal/model/ast"
 	"bot-go/internal/service"
+	"bot-go/internal/service/codegraph"
 
 	"github.com/gin-gonic/gin"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
@@ -19,15 +23,28 @@ import (
 type CodeGraphServer struct {
 	server      *mcp.Server
 	repoService *service.RepoService
+	codeGraph   *codegraph.CodeGraph
+	codeAPI     codeapi.CodeAPI
 	config      *config.Config
 	logger      *zap.Logger
 	handler     *mcp.StreamableHTTPHandler
+	cache       *toolCache // nil when mcp.cache_ttl_seconds < 0
 }
 
 type CallGraphParams struct {
 	RepoName     string `json:"repo_name" jsonschema:"the name of the repository to analyze"`
 	FunctionName string `json:"function_name,omitempty" jsonschema:"specific function to analyze"`
 	FilePath     string `json:"file_path,omitempty" jsonschema:"specific file path containing the function"`
+	NoCache      bool   `json:"no_cache,omitempty" jsonschema:"compute the result again instead of returning the result of an identical recent call"`
+}
+
+type NodeAtPositionParams struct {
+	RepoName     string `json:"repo_name" jsonschema:"the name of the repository"`
+	FilePath     string `json:"file_path" jsonschema:"path of the file relative to the repository root"`
+	Line         int    `json:"line" jsonschema:"0-based line number"`
+	Character    int    `json:"character,omitempty" jsonschema:"0-based character offset in UTF-16 code units"`
+	FunctionOnly bool   `json:"function_only,omitempty" jsonschema:"return the enclosing function instead of the innermost node"`
+	NoCache      bool   `json:"no_cache,omitempty" jsonschema:"compute the result again instead of returning the result of an identical recent call"`
 }
 
 func NewCodeGraphServer(repoService *service.RepoService, cfg *config.Config, logger *zap.Logger) *CodeGraphServer {
@@ -35,6 +52,7 @@ func NewCodeGraphServer(repoService *service.RepoService, cfg *config.Config, lo
 		repoService: repoService,
 		config:      cfg,
 		logger:      logger,
+		cache:       newToolCache(cfg.Mcp, logger),
 	}
 
 	mcpServer := mcp.NewServer(&mcp.Implementation{
@@ -42,17 +60,43 @@ func NewCodeGraphServer(repoService *service.RepoService, cfg *config.Config, lo
 		Version: "1.0.0",
 	}, nil)
 
+	// Tool results are cached per session for a short time (see toolCache)
+
 	// Register the getCallGraph tool
 	mcp.AddTool(mcpServer, &mcp.Tool{
 		Name:        "getCallGraph",
 		Description: "Retrieve the call graph for a given function in a file. Returns a graph with each function being called, their location and their call graph",
-	}, server.handleCallGraph)
+	}, cachedTool(server, "getCallGraph", server.handleCallGraph))
 
 	// Register the getCallerGraph tool
 	mcp.AddTool(mcpServer, &mcp.Tool{
 		Name:        "getCallerGraph",
 		Description: "Retrieve the caller graph for a given function in a file. Returns a graph with each function calling this function, their location and their caller graph",
-	}, server.handleCallerGraph)
+	}, cachedTool(server, "getCallerGraph", server.handleCallerGraph))
+
+	// Register the getNodeAtPosition tool
+	mcp.AddTool(mcpServer, &mcp.Tool{
+		Name:        "getNodeAtPosition",
+		Description: "Find the innermost code element (function, class, block, call, ...) containing a line and character in a file, or the enclosing function when function_only is set",
+	}, cachedTool(server, "getNodeAtPosition", server.handleNodeAtPosition))
+
+	// Register the getImpact tool
+	mcp.AddTool(mcpServer, &mcp.Tool{
+		Name:        "getImpact",
+		Description: "Estimate what a change to a function affects: its callers and data dependents up to a depth, summarized as a risk level, counts per distance and per file, and the most affected functions by score",
+	}, cachedTool(server, "getImpact", server.handleImpact))
+
+	// Register the findCallPaths tool
+	mcp.AddTool(mcpServer, &mcp.Tool{
+		Name:        "findCallPaths",
+		Description: "Find the shortest call chains from one function to another, e.g. how a handler reaches a database write. Lists each path by function name, then the file:line (1-based) of every function on them",
+	}, cachedTool(server, "findCallPaths", server.handleCallPaths))
+
+	// Register the findFunctionsBySignature tool
+	mcp.AddTool(mcpServer, &mcp.Tool{
+		Name:        "findFunctionsBySignature",
+		Description: "Find existing functions to call by the shape of their signature: parameter types (in order, or anywhere), parameter counts and result types, e.g. functions taking a context.Context and returning an error. Lists the most referenced first with file:line (1-based) and declaration",
+	}, cachedTool(server, "findFunctionsBySignature", server.handleFindBySignature))
 
 	server.handler = mcp.NewStreamableHTTPHandler(func(req *http.Request) *mcp.Server {
 		return mcpServer
@@ -70,6 +114,7 @@ func (s *CodeGraphServer) handleCallGraph(ctx context.Context, req *mcp.CallTool
 	if err != nil {
 		s.logger.Error("Repository not found", zap.String("repo_name", args.RepoName), zap.Error(err))
 		return &mcp.CallToolResult{
+			IsError: true,
 			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Repository not found: %s", args.RepoName)}},
 		}, nil, nil
 	}
@@ -79,6 +124,7 @@ func (s *CodeGraphServer) handleCallGraph(ctx context.Context, req *mcp.CallTool
 	if err != nil {
 		s.logger.Error("Failed to generate call graph", zap.String("repo_name", args.RepoName), zap.Error(err))
 		return &mcp.CallToolResult{
+			IsError: true,
 			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Failed to generate call graph: %v", err)}},
 		}, nil, nil
 	}
@@ -107,6 +153,7 @@ func (s *CodeGraphServer) handleCallerGraph(ctx context.Context, req *mcp.CallTo
 	if err != nil {
 		s.logger.Error("Repository not found", zap.String("repo_name", args.RepoName), zap.Error(err))
 		return &mcp.CallToolResult{
+			IsError: true,
 			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Repository not found: %s", args.RepoName)}},
 		}, nil, nil
 	}
@@ -116,6 +163,7 @@ func (s *CodeGraphServer) handleCallerGraph(ctx context.Context, req *mcp.CallTo
 	if err != nil {
 		s.logger.Error("Failed to generate caller graph", zap.String("repo_name", args.RepoName), zap.Error(err))
 		return &mcp.CallToolResult{
+			IsError: true,
 			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Failed to generate caller graph: %v", err)}},
 		}, nil, nil
 	}
@@ -135,6 +183,60 @@ func (s *CodeGraphServer) generateCallerGraph(ctx context.Context, repo *config.
 	return callerGraph, nil
 }
 
+// SetCache keeps tool results in a cache shared by the server instances using c instead of
+// in memory. Results are still cached per MCP session, for mcp.cache_ttl_seconds.
+func (s *CodeGraphServer) SetCache(c cache.Cache) {
+	if s.cache != nil {
+		s.cache.shared = c
+	}
+}
+
+// SetCodeGraph gives the server access to the code graph for graph-backed tools
+func (s *CodeGraphServer) SetCodeGraph(codeGraph *codegraph.CodeGraph) {
+	s.codeGraph = codeGraph
+}
+
+func (s *CodeGraphServer) handleNodeAtPosition(ctx context.Context, req *mcp.CallToolRequest, args NodeAtPositionParams) (*mcp.CallToolResult, any, error) {
+	s.logger.Info("Handling nodeAtPosition request",
+		zap.String("repo_name", args.RepoName),
+		zap.String("file_path", args.FilePath),
+		zap.Int("line", args.Line),
+		zap.Int("character", args.Character))
+
+	if s.codeGraph == nil {
+		return &mcp.CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{&mcp.TextContent{Text: "Code graph is not available"}},
+		}, nil, nil
+	}
+
+	var nodeTypes []ast.NodeType
+	if args.FunctionOnly {
+		nodeTypes = []ast.NodeType{ast.NodeTypeFunction}
+	}
+	result, err := s.codeGraph.FindNodeAtPosition(ctx, args.RepoName, args.FilePath, args.Line, args.Character, nodeTypes...)
+	if err != nil {
+		s.logger.Error("Failed to find node at position", zap.String("repo_name", args.RepoName), zap.Error(err))
+		return &mcp.CallToolResult{
+			IsError: true,
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Failed to find node at position: %v", err)}},
+		}, nil, nil
+	}
+	if result == nil {
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("No code element found at %s:%d:%d", args.FilePath, args.Line, args.Character)}},
+		}, nil, nil
+	}
+
+	rng := result.Node.Range
+	text := fmt.Sprintf("%s %q (id %d) in %s, lines %d:%d-%d:%d",
+		result.Label, result.Node.Name, result.Node.ID, result.FilePath,
+		rng.Start.Line, rng.Start.Character, rng.End.Line, rng.End.Character)
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: text}},
+	}, nil, nil
+}
+
 func (s *CodeGraphServer) formatCallGraph(ctx context.Context, repoName string, cg *model.CallGraph) string {
 	if cg == nil {
 		return "No call graph available."